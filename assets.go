@@ -51,6 +51,12 @@ var DeviceVerificationHTML string
 //go:embed templates/email/new_device_login.html
 var NewDeviceLoginHTML string
 
+//go:embed templates/email/account_unlock.html
+var AccountUnlockHTML string
+
+//go:embed templates/email/account_unlocked.html
+var AccountUnlockedHTML string
+
 //go:embed static/js/client.js
 var AssistantJsModule string
 
@@ -141,29 +147,39 @@ func GetRenderPageContext(c *gin.Context) map[string]any {
 		loginNext = utils.GetValue(db, constants.KEY_SITE_LOGIN_NEXT)
 	}
 
+	site := map[string]any{
+		"Url":                  utils.GetValue(db, constants.KEY_SITE_URL),
+		"Name":                 utils.GetValue(db, constants.KEY_SITE_NAME),
+		"Admin":                utils.GetValue(db, constants.KEY_SITE_ADMIN),
+		"Keywords":             utils.GetValue(db, constants.KEY_SITE_KEYWORDS),
+		"Description":          utils.GetValue(db, constants.KEY_SITE_DESCRIPTION),
+		"GA":                   utils.GetValue(db, constants.KEY_SITE_GA),
+		"LogoUrl":              utils.GetValue(db, constants.KEY_SITE_LOGO_URL),
+		"FaviconUrl":           utils.GetValue(db, constants.KEY_SITE_FAVICON_URL),
+		"TermsUrl":             utils.GetValue(db, constants.KEY_SITE_TERMS_URL),
+		"PrivacyUrl":           utils.GetValue(db, constants.KEY_SITE_PRIVACY_URL),
+		"SigninUrl":            utils.GetValue(db, constants.KEY_SITE_SIGNIN_URL),
+		"SignupUrl":            utils.GetValue(db, constants.KEY_SITE_SIGNUP_URL),
+		"LogoutUrl":            utils.GetValue(db, constants.KEY_SITE_LOGOUT_URL),
+		"ResetPasswordUrl":     utils.GetValue(db, constants.KEY_SITE_RESET_PASSWORD_URL),
+		"SigninApi":            utils.GetValue(db, constants.KEY_SITE_SIGNIN_API),
+		"SignupApi":            utils.GetValue(db, constants.KEY_SITE_SIGNUP_API),
+		"ResetPasswordDoneApi": utils.GetValue(db, constants.KEY_SITE_RESET_PASSWORD_DONE_API),
+		"UserIdType":           utils.GetValue(db, constants.KEY_SITE_USER_ID_TYPE),
+	}
+	// Requests on an organization's custom domain use its branding
+	if branding, ok := c.Get(constants.DomainField); ok {
+		if values, ok := branding.(map[string]any); ok {
+			for k, v := range values {
+				site[k] = v
+			}
+		}
+	}
+
 	return map[string]any{
 		"LoginNext":    loginNext,
 		"RegisterNext": utils.GetValue(db, constants.KEY_SITE_SIGNIN_URL),
-		"Site": map[string]any{
-			"Url":                  utils.GetValue(db, constants.KEY_SITE_URL),
-			"Name":                 utils.GetValue(db, constants.KEY_SITE_NAME),
-			"Admin":                utils.GetValue(db, constants.KEY_SITE_ADMIN),
-			"Keywords":             utils.GetValue(db, constants.KEY_SITE_KEYWORDS),
-			"Description":          utils.GetValue(db, constants.KEY_SITE_DESCRIPTION),
-			"GA":                   utils.GetValue(db, constants.KEY_SITE_GA),
-			"LogoUrl":              utils.GetValue(db, constants.KEY_SITE_LOGO_URL),
-			"FaviconUrl":           utils.GetValue(db, constants.KEY_SITE_FAVICON_URL),
-			"TermsUrl":             utils.GetValue(db, constants.KEY_SITE_TERMS_URL),
-			"PrivacyUrl":           utils.GetValue(db, constants.KEY_SITE_PRIVACY_URL),
-			"SigninUrl":            utils.GetValue(db, constants.KEY_SITE_SIGNIN_URL),
-			"SignupUrl":            utils.GetValue(db, constants.KEY_SITE_SIGNUP_URL),
-			"LogoutUrl":            utils.GetValue(db, constants.KEY_SITE_LOGOUT_URL),
-			"ResetPasswordUrl":     utils.GetValue(db, constants.KEY_SITE_RESET_PASSWORD_URL),
-			"SigninApi":            utils.GetValue(db, constants.KEY_SITE_SIGNIN_API),
-			"SignupApi":            utils.GetValue(db, constants.KEY_SITE_SIGNUP_API),
-			"ResetPasswordDoneApi": utils.GetValue(db, constants.KEY_SITE_RESET_PASSWORD_DONE_API),
-			"UserIdType":           utils.GetValue(db, constants.KEY_SITE_USER_ID_TYPE),
-		},
+		"Site":         site,
 	}
 }
 
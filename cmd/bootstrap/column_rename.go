@@ -0,0 +1,99 @@
+package bootstrap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"gorm.io/gorm"
+)
+
+// Column rename steps, run in this order for a zero-downtime rename:
+// backfill -> verify -> switch -> (release without the old field) -> drop
+const (
+	ColumnRenameStatus   = "status"
+	ColumnRenameBackfill = "backfill"
+	ColumnRenameVerify   = "verify"
+	ColumnRenameSwitch   = "switch"
+	ColumnRenameRollback = "rollback"
+	ColumnRenameDrop     = "drop"
+)
+
+// AllColumnRenames name selecting every registered rename for the status step
+const AllColumnRenames = "all"
+
+// RunColumnRename runs one step of a registered column rename and prints the
+// outcome to w. AllColumnRenames with the status step lists every rename.
+func RunColumnRename(w io.Writer, db *gorm.DB, name, step string) error {
+	if step == "" {
+		step = ColumnRenameStatus
+	}
+	if name == AllColumnRenames {
+		if step != ColumnRenameStatus {
+			return fmt.Errorf("column rename name is required for step %q", step)
+		}
+		for _, r := range models.ColumnRenames() {
+			if err := printColumnRenameStatus(w, db, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	r, err := models.GetColumnRename(name)
+	if err != nil {
+		return err
+	}
+	switch step {
+	case ColumnRenameStatus:
+	case ColumnRenameBackfill:
+		rows, err := r.Backfill(db, models.DefaultColumnRenameBatch)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s: backfilled %d rows\n", r.Name, rows)
+	case ColumnRenameVerify:
+		mismatches, err := r.Verify(db)
+		if err != nil {
+			return err
+		}
+		if mismatches > 0 {
+			return fmt.Errorf("%w: %d rows", models.ErrColumnRenameMismatch, mismatches)
+		}
+		fmt.Fprintf(w, "%s: columns match\n", r.Name)
+	case ColumnRenameSwitch:
+		if err := r.SwitchToNew(db); err != nil {
+			return err
+		}
+	case ColumnRenameRollback:
+		if err := r.SwitchBack(db); err != nil {
+			return err
+		}
+	case ColumnRenameDrop:
+		if err := r.DropOldColumn(db); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown column rename step %q, expected one of %s", step, strings.Join([]string{
+			ColumnRenameStatus, ColumnRenameBackfill, ColumnRenameVerify, ColumnRenameSwitch, ColumnRenameRollback, ColumnRenameDrop,
+		}, ", "))
+	}
+	return printColumnRenameStatus(w, db, r)
+}
+
+func printColumnRenameStatus(w io.Writer, db *gorm.DB, r *models.ColumnRename) error {
+	state, err := r.State(db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s: %s.%s -> %s, phase %s", r.Name, r.Table, r.From, r.To, state.Phase)
+	if state.BackfilledAt != nil {
+		fmt.Fprintf(w, ", backfilled %d rows at %s", state.BackfilledRows, state.BackfilledAt.Format("2006-01-02 15:04:05"))
+	}
+	if state.VerifiedAt != nil {
+		fmt.Fprintf(w, ", verified at %s", state.VerifiedAt.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintln(w)
+	return nil
+}
@@ -0,0 +1,42 @@
+package bootstrap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRunColumnRename(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Knowledge{}, &models.ColumnRenameState{}))
+	t.Cleanup(func() { _ = models.KnowledgeUpdatedAtRename.SwitchBack(db) })
+
+	// Rows written before the rename only have the old column
+	require.NoError(t, db.Exec("INSERT INTO knowledges (user_id, knowledge_key, update_at) VALUES (1, 'kb', ?)", time.Now()).Error)
+	name := models.KnowledgeUpdatedAtRename.Name
+
+	var out bytes.Buffer
+	assert.ErrorIs(t, RunColumnRename(&out, db, name, ColumnRenameSwitch), models.ErrColumnRenameMismatch)
+	assert.ErrorIs(t, RunColumnRename(&out, db, name, ColumnRenameVerify), models.ErrColumnRenameMismatch)
+
+	require.NoError(t, RunColumnRename(&out, db, name, ColumnRenameBackfill))
+	assert.Contains(t, out.String(), "backfilled 1 rows")
+	require.NoError(t, RunColumnRename(&out, db, name, ColumnRenameVerify))
+	require.NoError(t, RunColumnRename(&out, db, name, ColumnRenameSwitch))
+
+	out.Reset()
+	require.NoError(t, RunColumnRename(&out, db, AllColumnRenames, ColumnRenameStatus))
+	assert.Contains(t, out.String(), "knowledges.update_at -> updated_at, phase read_new")
+
+	assert.ErrorIs(t, RunColumnRename(&out, db, "missing", ColumnRenameStatus), models.ErrUnknownColumnRename)
+	assert.Error(t, RunColumnRename(&out, db, name, "rename"))
+	assert.Error(t, RunColumnRename(&out, db, AllColumnRenames, ColumnRenameBackfill))
+}
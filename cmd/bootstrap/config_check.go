@@ -0,0 +1,45 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ValidateConfig runs the configuration checks, optionally followed by the
+// connectivity probes, and prints the consolidated report to w.
+// Problems are only fatal in strict mode, the report error is returned then.
+func ValidateConfig(w io.Writer, cfg *config.Config, strict, probe bool) error {
+	report := cfg.Check()
+	if probe {
+		report.Merge(cfg.Probe(context.Background(), config.DefaultProbeTimeout))
+	}
+
+	for _, issue := range report.Issues {
+		fields := []zap.Field{
+			zap.String("section", issue.Section),
+			zap.String("env", issue.Env),
+			zap.String("hint", issue.Hint),
+		}
+		if issue.Severity == config.SeverityError {
+			logger.Error("config check: "+issue.Message, fields...)
+		} else {
+			logger.Warn("config check: "+issue.Message, fields...)
+		}
+	}
+	if w != nil && len(report.Issues) > 0 {
+		fmt.Fprintln(w, report.String())
+	}
+
+	if strict {
+		return report.Err()
+	}
+	if report.HasErrors() {
+		logger.Warn("configuration has errors, starting anyway (set CONFIG_STRICT=true to refuse)")
+	}
+	return nil
+}
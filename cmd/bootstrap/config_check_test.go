@@ -0,0 +1,41 @@
+package bootstrap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestValidateConfig(t *testing.T) {
+	core, recorded := observer.New(zapcore.InfoLevel)
+	originalLogger := logger.Lg
+	logger.Lg = zap.New(core)
+	defer func() {
+		logger.Lg = originalLogger
+	}()
+
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Addr: ":7072", Mode: "development"},
+		Database: config.DatabaseConfig{Driver: "oracle", DSN: "x"},
+		Auth:     config.AuthConfig{SessionSecret: "a-long-and-random-session-secret", SecretExpireDays: "7"},
+		Cache:    cache.Config{Type: "local"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, ValidateConfig(&buf, cfg, false, false))
+	assert.Contains(t, buf.String(), `unsupported driver "oracle"`)
+	assert.NotEmpty(t, recorded.FilterLevelExact(zapcore.ErrorLevel).All())
+
+	buf.Reset()
+	err := ValidateConfig(&buf, cfg, true, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_DRIVER")
+}
@@ -179,10 +179,15 @@ func RunMigrations(db *gorm.DB) error {
 		&models.GroupInvitation{},
 		&models.Assistant{},
 		&models.AssistantTool{},
+		&models.AssistantPromptRelease{},
 		&models.ChatSessionLog{},
 		&notification.InternalNotification{},
 		&notification.MailLog{},
 		&models.Knowledge{},
+		&models.KnowledgeWebhook{},
+		&models.KnowledgeIngestionJob{},
+		&models.KnowledgeSourceFile{},
+		&models.ColumnRenameState{},
 		&models.VoiceTrainingTask{},
 		&models.VoiceClone{},
 		&models.Voiceprint{},
@@ -195,6 +200,7 @@ func RunMigrations(db *gorm.DB) error {
 		&models.Device{},
 		&models.OTA{},
 		&models.UsageRecord{},
+		&models.APIKeyUsageRollup{},
 		&models.Bill{},
 		&models.AlertRule{},
 		&models.Alert{},
@@ -227,5 +233,73 @@ func RunMigrations(db *gorm.DB) error {
 		&models.MCPUserInstallation{},
 		&models.MCPReview{},
 		&models.MCPCategory{},
+		&models.CustomFieldDefinition{},
+		&models.CustomFieldValue{},
+		&models.DeviceLocation{},
+		&models.DeviceGeofence{},
+		&models.EscalationConnector{},
+		&models.EscalationTicket{},
+		&models.CalendarCredential{},
+		&models.AssistantCalendar{},
+		&models.PresenceStatus{},
+		&models.RecordingConsentPolicy{},
+		&models.AICallCheckpoint{},
+		&models.DIDNumber{},
+		&models.CallSurveySettings{},
+		&models.CallSurvey{},
+		&models.SipCallback{},
+		&models.TranscriptCorrection{},
+		&models.AssistantVocabulary{},
+		&models.InterpretationSegment{},
+		&models.TechnicianToken{},
+		&models.DeviceClaim{},
+		&models.SipHALease{},
+		&models.SipHANode{},
+		&models.SipFailoverEvent{},
+		&models.OAuthIdentity{},
+		&models.ProviderIncident{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.TwoFactorBackupCode{},
+		&models.AuthzPolicy{},
+		&models.NotificationPreference{},
+		&models.ScimToken{},
+		&models.ScimIdentity{}, &models.SamlConnection{}, &models.SSOEvent{},
+		&models.SyncTombstone{},
+		&models.RecordingDigest{},
+		&models.CustomDomain{},
+		&models.MaintenanceWindow{},
+		&models.DeviceAction{},
+		&models.DeviceSigningKey{},
+		&models.DeviceActionManifest{},
+		&models.DiagnosticsBundle{},
+		&models.ConversationExport{},
+		&models.GroupSecurityPolicy{},
+		&models.KnowledgeSnapshot{},
+		&models.KnowledgeSnapshotRestore{},
+		&models.PushDeviceToken{},
+		&models.DeviceCommandLog{},
+		&models.InboxConversation{},
+		&models.InboxNote{},
+		&models.ComplianceArchive{},
+		&models.ComplianceExport{},
+		&models.CallerLookup{},
+		&models.CallerLookupLog{},
+		&models.DeviceAudioTest{},
+		&models.FeatureFlag{},
+		&models.FeatureFlagAudit{},
+		&models.AssistantShare{},
+		&models.VoiceCloneConsent{},
+		&models.SessionEvent{},
+		&models.QAReview{},
+		&models.Region{},
+		&models.WebWidget{},
+		&models.WebWidgetSession{},
+		&models.LoginLocationRollup{},
+		&models.SessionAnnotation{},
+		&models.SessionAnnotationMention{},
+		&models.KnowledgeFAQProposal{},
+		&models.GroupSubscription{},
+		&models.SubscriptionEvent{},
 	})
 }
@@ -0,0 +1,48 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// DemoDocumentDir where the demo knowledge base documents are written, the
+// directory uploaded knowledge base documents are kept in
+const DemoDocumentDir = "./lingstorage/knowledge"
+
+var ErrDemoTenantProduction = errors.New("refusing to seed the demo tenant in production")
+
+// RunDemoSeed creates the demo tenant, or brings an existing one up to date,
+// and prints what it contains to w. It refuses to run when APP_ENV is production.
+func RunDemoSeed(w io.Writer, db *gorm.DB, opts models.DemoTenantOptions) error {
+	if utils.GetEnv("APP_ENV") == "production" {
+		return ErrDemoTenantProduction
+	}
+	tenant, err := models.SeedDemoTenant(db, opts)
+	if err != nil {
+		return err
+	}
+
+	password := opts.Password
+	if password == "" {
+		password = models.DemoDefaultPassword
+	}
+	fmt.Fprintf(w, "demo tenant: %q (id %d), %d records created\n", tenant.Group.Name, tenant.Group.ID, tenant.Created)
+	for _, user := range tenant.Users {
+		fmt.Fprintf(w, "  user      %s (%s)\n", user.Email, user.DisplayName)
+	}
+	fmt.Fprintf(w, "  password  %s (users created by this run)\n", password)
+	fmt.Fprintf(w, "  assistant %s (id %d)\n", tenant.Assistant.Name, tenant.Assistant.ID)
+	fmt.Fprintf(w, "  knowledge %s, %d documents\n", tenant.Knowledge.KnowledgeKey, len(tenant.Documents))
+	for _, sipUser := range tenant.SipUsers {
+		fmt.Fprintf(w, "  sip user  %s (%s)\n", sipUser.Username, sipUser.SchemeName)
+	}
+	for _, device := range tenant.Devices {
+		fmt.Fprintf(w, "  device    %s (%s)\n", device.ID, device.DeviceName)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package bootstrap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRunDemoSeed(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Group{}, &models.GroupMember{}, &models.Knowledge{},
+		&models.KnowledgeSourceFile{}, &models.Assistant{}, &models.SipUser{}, &models.Device{}, &models.DeviceLocation{}))
+
+	var out bytes.Buffer
+	require.NoError(t, RunDemoSeed(&out, db, models.DemoTenantOptions{Devices: 1}))
+	assert.Contains(t, out.String(), "records created")
+	assert.Contains(t, out.String(), models.DemoUserEmail("owner"))
+	assert.Contains(t, out.String(), "sip user  demo1001")
+	assert.Contains(t, out.String(), "device    02:de:00:00:00:01")
+
+	out.Reset()
+	require.NoError(t, RunDemoSeed(&out, db, models.DemoTenantOptions{Devices: 1}))
+	assert.Contains(t, out.String(), ", 0 records created")
+
+	t.Setenv("APP_ENV", "production")
+	assert.ErrorIs(t, RunDemoSeed(&out, db, models.DemoTenantOptions{}), ErrDemoTenantProduction)
+}
@@ -22,10 +22,14 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/logger"
 	"github.com/code-100-precent/LingEcho/pkg/metrics"
 	"github.com/code-100-precent/LingEcho/pkg/middleware"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/queue"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"github.com/code-100-precent/LingEcho/pkg/utils/backup"
 	"github.com/code-100-precent/LingEcho/pkg/utils/search"
+	"github.com/code-100-precent/LingEcho/pkg/wallboard"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -58,6 +62,12 @@ func main() {
 	seed := flag.Bool("seed", false, "seed database")
 	mode := flag.String("mode", "", "running environment (development, test, production)")
 	initSQL := flag.String("init-sql", "", "path to database init .sql script (optional)")
+	strictConfig := flag.Bool("strict-config", false, "refuse to start when the configuration check reports errors (CONFIG_STRICT)")
+	probeConfig := flag.Bool("probe-config", false, "probe connectivity of configured services at startup (CONFIG_PROBE)")
+	columnRename := flag.String("column-rename", "", "run a step of a column rename (or \"all\" for the status of every rename) and exit, e.g. knowledge.update_at")
+	columnRenameStep := flag.String("column-rename-step", bootstrap.ColumnRenameStatus, "column rename step: status, backfill, verify, switch, rollback or drop")
+	seedDemo := flag.Bool("seed-demo", false, "create the demo tenant (organization, users, assistant, knowledge base, SIP users, devices) and exit; safe to re-run, refused in production")
+	seedDemoDevices := flag.Int("seed-demo-devices", models.DemoDefaultDevices, "number of fake devices in the demo tenant")
 	flag.Parse()
 
 	// 3. Set Environment Variables
@@ -79,6 +89,13 @@ func main() {
 	// 6. Print Configuration
 	bootstrap.LogConfigInfo()
 
+	// Validate configuration, strict mode refuses to start on errors
+	strict := *strictConfig || config.GlobalConfig.Server.StrictConfig
+	probe := *probeConfig || config.GlobalConfig.Server.ProbeConfig
+	if err := bootstrap.ValidateConfig(os.Stderr, config.GlobalConfig, strict, probe); err != nil {
+		logger.Fatal("configuration check failed in strict mode", zap.Error(err))
+	}
+
 	// 7. Load Data Source
 	db, err := bootstrap.SetupDatabase(os.Stdout, &bootstrap.Options{
 		InitSQLPath: *initSQL, // Can be specified via --init-sql
@@ -90,6 +107,24 @@ func main() {
 		return
 	}
 
+	// Column renames: one-off maintenance steps, otherwise load the phases used to pick columns
+	if *columnRename != "" {
+		if err := bootstrap.RunColumnRename(os.Stdout, db, *columnRename, *columnRenameStep); err != nil {
+			logger.Fatal("column rename failed", zap.String("rename", *columnRename), zap.String("step", *columnRenameStep), zap.Error(err))
+		}
+		return
+	}
+	if *seedDemo {
+		opts := models.DemoTenantOptions{Devices: *seedDemoDevices, DocumentDir: bootstrap.DemoDocumentDir}
+		if err := bootstrap.RunDemoSeed(os.Stdout, db, opts); err != nil {
+			logger.Fatal("demo tenant seed failed", zap.Error(err))
+		}
+		return
+	}
+	if err := models.LoadColumnRenamePhases(db); err != nil {
+		logger.Warn("failed to load column rename phases, reading the old columns", zap.Error(err))
+	}
+
 	// 8. Load Base Configs
 	var addr = config.GlobalConfig.Server.Addr
 	if addr == "" {
@@ -126,18 +161,46 @@ func main() {
 	// Initialize global distributed lock
 	utils.InitGlobalDistributedLock()
 
-	// Initialize global captcha manager
-	captcha.InitGlobalCaptchaManager(nil) // Use memory storage, can be replaced with Redis storage
+	// Initialize captcha provider, the image captcha uses memory storage (can be replaced with Redis storage)
+	if err := captcha.InitGlobalCaptchaProvider(config.GlobalConfig.Auth.Captcha, nil); err != nil {
+		logger.Fatal("failed to initialize captcha provider", zap.Error(err))
+	}
+
+	// Emails and SMS are delivered by the job queue with retries and a dead letter list,
+	// the redis backend shares the REDIS_* connection settings with the cache
+	redisConfig := config.GlobalConfig.Cache.Redis
+	jobQueue, err := queue.NewFromConfig(config.GlobalConfig.Queue, &redis.Options{
+		Addr:     redisConfig.Addr,
+		Password: redisConfig.Password,
+		DB:       redisConfig.DB,
+	})
+	if err != nil {
+		logger.Fatal("failed to initialize job queue", zap.Error(err))
+	}
+	if err := notification.RegisterJobs(jobQueue, db, config.GlobalConfig.Services.Mail, config.GlobalConfig.Services.SMS); err != nil {
+		logger.Fatal("failed to register notification jobs", zap.Error(err))
+	}
+	jobQueue.Start()
+	defer jobQueue.Stop()
 
 	// Initialize global login security manager
 	utils.InitGlobalLoginSecurityManager(logger.Lg)
+	utils.GlobalLoginSecurityManager.ConfigureLoginAnomaly(config.GlobalConfig.Auth.LoginAnomaly)
 
 	// Initialize global intelligent risk control manager
 	utils.InitGlobalIntelligentRiskControl(logger.Lg)
 
+	// Initialize optional authorization policy engine (evaluated after RBAC)
+	if err := models.InitPolicyEngine(db, config.GlobalConfig.Auth); err != nil {
+		logger.Error("failed to initialize policy engine, only RBAC applies", zap.Error(err))
+	}
+
 	//// 11. New App
 	app := NewLingEchoApp(db)
 
+	// Aggregate call events for the supervisor wallboard before SIP traffic arrives
+	wallboard.Start()
+
 	// 11.5. Initialize SIP Server (if enabled)
 	// Check if SIP server should be enabled via environment variable
 	sipEnabled := utils.GetBoolEnv("SIP_ENABLED")
@@ -263,6 +326,22 @@ func main() {
 	// 15. Start Timed task
 	// Start Email Cleaner Task
 	task.StartEmailCleaner(db)
+	task.StartSyncTombstoneCleaner(db)
+	task.StartAuthTokenCleaner(db)
+	task.StartAccountLockExpiry(db)
+	task.StartRecordingDigestAnchor(db)
+	task.StartMaintenanceWindowDispatcher(db)
+	task.StartComplianceExporter(db)
+	task.StartLoginLocationRollup(db)
+	task.StartAPIKeyUsageFlusher(db)
+	task.StartPromptReleaseEvaluator(app.handlers.EvaluatePromptReleases)
+	task.StartConversationExporter(db)
+	task.StartKnowledgeSnapshotWorker(app.handlers.RunKnowledgeSnapshotJobs)
+	task.StartKnowledgeFAQGenerator(db)
+	task.StartCallCostRater(db)
+	task.StartDeviceEventNotifier(db)
+	task.StartInboxSLAMonitor(db)
+	task.StartSubscriptionChecker(db)
 	// Start Quota Alert Checker
 	task.StartQuotaAlertChecker(db)
 	// Start Backup Data
@@ -280,8 +359,8 @@ func main() {
 	r.RedirectTrailingSlash = false
 	r.RedirectFixedPath = false
 
-	// Set maximum memory limit for multipart forms (32MB)
-	r.MaxMultipartMemory = 32 << 20 // 32 MB
+	// Set maximum memory limit for multipart forms, larger files spill to temp files
+	r.MaxMultipartMemory = config.GlobalConfig.Middleware.BodyLimit.MultipartMemory
 
 	// 16. use middleware
 	// Monitoring Middleware
@@ -365,6 +444,7 @@ func main() {
 	listeners.InitLLMListenerWithDB(db)
 	listeners.InitBillingListenerWithDB(db)
 	listeners.InitSystemListeners()
+	listeners.InitCustomDomainCertificates(db)
 
 	// 20. Start Search Indexer (if enabled)
 	searchEnabled := utils.GetBoolValue(db, constants.KEY_SEARCH_ENABLED)
@@ -433,7 +513,7 @@ func main() {
 		}
 
 		if tlsConfig != nil {
-			httpServer.TLSConfig = tlsConfig
+			httpServer.TLSConfig = listeners.WithCustomDomainCertificates(tlsConfig)
 			logger.Info("Starting HTTPS server", zap.String("addr", addr))
 			if err := httpServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 				logger.Error("HTTPS server run failed", zap.Error(err))
@@ -445,6 +525,9 @@ func main() {
 			}
 		}
 	} else {
+		if listeners.CustomDomainCertificatesEnabled() {
+			logger.Warn("Custom domain certificates need the HTTPS listener, enable SSL or terminate TLS in a proxy")
+		}
 		logger.Info("Starting HTTP server", zap.String("addr", addr))
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("HTTP server run failed", zap.Error(err))
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// accountUnlockRequested is returned whether or not the email has a locked account,
+// so the endpoint can't be used to probe accounts
+const accountUnlockRequested = "If the account is locked, an unlock link has been sent to its email"
+
+// RequestAccountUnlock emails a one-time unlock link to the owner of an account locked
+// by failed logins
+// POST /auth/unlock-account
+func (h *Handlers) RequestAccountUnlock(c *gin.Context) {
+	var form struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+
+	user, err := models.GetUserByEmail(h.db, form.Email)
+	if err != nil {
+		response.Success(c, accountUnlockRequested, nil)
+		return
+	}
+	lock, err := models.GetAccountLock(h.db, user.Email, 0)
+	if err != nil {
+		response.Fail(c, "Failed to load account lock", err)
+		return
+	}
+	if lock == nil || !lock.IsLocked() {
+		response.Success(c, accountUnlockRequested, nil)
+		return
+	}
+
+	token, err := models.IssueAccountUnlockToken(h.db, lock, time.Now())
+	if errors.Is(err, models.ErrAccountUnlockTooFrequent) {
+		response.Success(c, accountUnlockRequested, nil)
+		return
+	}
+	if err != nil {
+		response.Fail(c, "Failed to generate unlock token", err)
+		return
+	}
+
+	utils.Sig().Emit(constants.SigUserAccountUnlockRequest, user, lock, token, h.db)
+
+	response.Success(c, accountUnlockRequested, nil)
+}
+
+// ConfirmAccountUnlock lifts the lock with the token from the unlock email
+// POST /auth/unlock-account/confirm
+func (h *Handlers) ConfirmAccountUnlock(c *gin.Context) {
+	var form struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+
+	lock, err := models.UnlockAccountByToken(h.db, form.Token, time.Now())
+	if err != nil {
+		response.Fail(c, "Invalid or expired token", err)
+		return
+	}
+	clearFailedLogins(lock.Email)
+
+	logger.Info("Account unlocked by its owner", zap.String("email", lock.Email), zap.Uint("lockID", lock.ID))
+	response.Success(c, "Account unlocked, you can sign in again", nil)
+}
+
+// ListAccountLocks lists the accounts currently locked by failed logins, optionally
+// filtered by email (admin)
+// GET /auth/account-locks?email=&page=&size=
+func (h *Handlers) ListAccountLocks(c *gin.Context) {
+	page, size := claimPage(c)
+	locks, total, err := models.ListActiveAccountLocks(h.db, c.Query("email"), time.Now(), page, size)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"locks": locks, "total": total, "page": page, "size": size})
+}
+
+// ClearAccountLock lifts a lock before it expires and notifies the user (admin)
+// DELETE /auth/account-locks/:id
+func (h *Handlers) ClearAccountLock(c *gin.Context) {
+	admin := models.CurrentUser(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid request", "invalid lock id")
+		return
+	}
+	var lock models.AccountLock
+	if err := h.db.Where("id = ? AND is_active = ?", id, true).First(&lock).Error; err != nil {
+		response.Fail(c, "not found", "Account lock does not exist or was already cleared")
+		return
+	}
+
+	released, err := models.ReleaseAccountLock(h.db, &lock, models.AccountUnlockAdmin, admin.ID, time.Now())
+	if err != nil {
+		response.Fail(c, "clear failed", err.Error())
+		return
+	}
+	if !released {
+		response.Fail(c, "not found", "Account lock does not exist or was already cleared")
+		return
+	}
+	clearFailedLogins(lock.Email)
+	if user, err := models.GetUserByEmail(h.db, lock.Email); err == nil {
+		utils.Sig().Emit(constants.SigUserAccountUnlocked, user, &lock, h.db)
+	}
+
+	logger.Info("Admin cleared account lock",
+		zap.Uint("adminID", admin.ID),
+		zap.Uint("lockID", lock.ID),
+		zap.String("email", lock.Email))
+	response.Success(c, "Account lock cleared", lock)
+}
+
+// clearFailedLogins resets the failure counter so the next wrong password doesn't lock
+// the account again right away
+func clearFailedLogins(email string) {
+	if utils.GlobalLoginSecurityManager != nil {
+		utils.GlobalLoginSecurityManager.ClearFailedLoginCount(email)
+	}
+}
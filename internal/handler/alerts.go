@@ -8,6 +8,7 @@ import (
 
 	"github.com/code-100-precent/LingEcho/internal/models"
 	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -79,7 +80,7 @@ func (h *Handlers) CreateAlertRule(c *gin.Context) {
 
 	for _, channel := range req.Channels {
 		switch channel {
-		case models.NotificationChannelEmail, models.NotificationChannelInternal, models.NotificationChannelWebhook, models.NotificationChannelSMS:
+		case models.NotificationChannelEmail, models.NotificationChannelInternal, models.NotificationChannelWebhook, models.NotificationChannelSMS, models.NotificationChannelPush:
 			// Valid channel
 		default:
 			response.Fail(c, "Parameter error", fmt.Sprintf("Invalid notification channel: %s", channel))
@@ -99,6 +100,12 @@ func (h *Handlers) CreateAlertRule(c *gin.Context) {
 		response.Fail(c, "Parameter error", "Webhook URL is required when using Webhook notification")
 		return
 	}
+	if req.WebhookURL != "" {
+		if err := webhook.ValidateURL(c.Request.Context(), req.WebhookURL); err != nil {
+			response.Fail(c, "Parameter error", "Invalid webhook URL: "+err.Error())
+			return
+		}
+	}
 
 	// Set default values
 	if req.Cooldown <= 0 {
@@ -280,6 +287,12 @@ func (h *Handlers) UpdateAlertRule(c *gin.Context) {
 		}
 	}
 	if req.WebhookURL != nil {
+		if *req.WebhookURL != "" {
+			if err := webhook.ValidateURL(c.Request.Context(), *req.WebhookURL); err != nil {
+				response.Fail(c, "Parameter error", "Invalid webhook URL: "+err.Error())
+				return
+			}
+		}
 		rule.WebhookURL = *req.WebhookURL
 	}
 	if req.WebhookMethod != nil {
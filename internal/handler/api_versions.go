@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/middleware"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionInfo describes one supported API version
+type APIVersionInfo struct {
+	Version   string  `json:"version"`
+	Prefix    string  `json:"prefix"`
+	Status    string  `json:"status"` // current or deprecated
+	Successor string  `json:"successor,omitempty"`
+	Sunset    *string `json:"sunset,omitempty"` // YYYY-MM-DD
+}
+
+// APIResourceVersions lists the versions a resource is served under
+type APIResourceVersions struct {
+	Resource string   `json:"resource"`
+	Versions []string `json:"versions"`
+}
+
+// GetAPIVersions lists the supported API versions and, per resource, the versions serving it
+// GET /versions
+func (h *Handlers) GetAPIVersions(c *gin.Context) {
+	prefix := strings.TrimSuffix(config.GlobalConfig.Server.APIPrefix, "/")
+	legacy := APIVersionInfo{
+		Version:   middleware.APIVersionLegacy,
+		Prefix:    prefix,
+		Status:    "deprecated",
+		Successor: middleware.APIVersionV1,
+	}
+	if sunset := config.GlobalConfig.Server.LegacyAPISunset; sunset != "" {
+		legacy.Sunset = &sunset
+	}
+	versions := []APIVersionInfo{
+		{Version: middleware.APIVersionV1, Prefix: prefix + "/" + middleware.APIVersionV1, Status: "current"},
+		legacy,
+	}
+
+	var resources []APIResourceVersions
+	if h.engine != nil {
+		resources = apiResourceVersions(h.engine.Routes(), prefix)
+	}
+	response.Success(c, "success", gin.H{
+		"current":   middleware.APIVersionV1,
+		"versions":  versions,
+		"resources": resources,
+	})
+}
+
+// apiResourceVersions groups the registered routes by their first path segment
+// below the API prefix, and collects the versions each resource is served under
+func apiResourceVersions(routes gin.RoutesInfo, prefix string) []APIResourceVersions {
+	versionPrefix := prefix + "/" + middleware.APIVersionV1 + "/"
+	seen := make(map[string]map[string]bool)
+	for _, route := range routes {
+		version, rest := middleware.APIVersionLegacy, ""
+		switch {
+		case strings.HasPrefix(route.Path, versionPrefix):
+			version, rest = middleware.APIVersionV1, strings.TrimPrefix(route.Path, versionPrefix)
+		case strings.HasPrefix(route.Path, prefix+"/"):
+			rest = strings.TrimPrefix(route.Path, prefix+"/")
+		default:
+			continue
+		}
+		resource, _, _ := strings.Cut(rest, "/")
+		if resource == "" || resource == "versions" || strings.HasPrefix(resource, ":") || strings.HasPrefix(resource, "*") {
+			continue
+		}
+		if seen[resource] == nil {
+			seen[resource] = make(map[string]bool)
+		}
+		seen[resource][version] = true
+	}
+
+	resources := make([]APIResourceVersions, 0, len(seen))
+	for resource, versions := range seen {
+		item := APIResourceVersions{Resource: resource}
+		for _, version := range []string{middleware.APIVersionV1, middleware.APIVersionLegacy} {
+			if versions[version] {
+				item.Versions = append(item.Versions, version)
+			}
+		}
+		resources = append(resources, item)
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Resource < resources[j].Resource })
+	return resources
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// promptReleaseRequest body of a prompt canary release
+type promptReleaseRequest struct {
+	CandidatePrompt             string  `json:"candidatePrompt" binding:"required"`
+	Note                        string  `json:"note"`
+	TrafficPercent              int     `json:"trafficPercent"`
+	WindowMinutes               int     `json:"windowMinutes"`
+	MinCalls                    int     `json:"minCalls"`
+	MaxInterruptionRateIncrease float64 `json:"maxInterruptionRateIncrease"`
+	MaxHandleTimeIncrease       float64 `json:"maxHandleTimeIncrease"`
+	MaxSentimentDrop            float64 `json:"maxSentimentDrop"`
+}
+
+// promptReleaseView a release with its latest analysis
+func promptReleaseView(release *models.AssistantPromptRelease) gin.H {
+	return gin.H{
+		"release":  release,
+		"analysis": release.Analysis(),
+	}
+}
+
+// ownedPromptRelease loads the release from :releaseId within the owned assistant
+func (h *Handlers) ownedPromptRelease(c *gin.Context) (*models.Assistant, *models.AssistantPromptRelease, bool) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return nil, nil, false
+	}
+	var release models.AssistantPromptRelease
+	if err := h.db.Where("id = ? AND assistant_id = ?", c.Param("releaseId"), assistant.ID).First(&release).Error; err != nil {
+		response.Fail(c, "prompt release not found", nil)
+		return nil, nil, false
+	}
+	return assistant, &release, true
+}
+
+// StartPromptRelease routes a share of the assistant's device calls to a new
+// system prompt and compares them against the current prompt over a window
+// POST /assistant/:id/prompt-releases
+func (h *Handlers) StartPromptRelease(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	var req promptReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if strings.TrimSpace(req.CandidatePrompt) == strings.TrimSpace(assistant.SystemPrompt) {
+		response.Fail(c, "candidate prompt is the same as the current prompt", nil)
+		return
+	}
+
+	release := &models.AssistantPromptRelease{
+		CandidatePrompt:             req.CandidatePrompt,
+		Note:                        req.Note,
+		TrafficPercent:              req.TrafficPercent,
+		WindowSeconds:               int64(req.WindowMinutes) * 60,
+		MinCalls:                    req.MinCalls,
+		MaxInterruptionRateIncrease: req.MaxInterruptionRateIncrease,
+		MaxHandleTimeIncrease:       req.MaxHandleTimeIncrease,
+		MaxSentimentDrop:            req.MaxSentimentDrop,
+	}
+	if err := models.StartPromptRelease(h.db, assistant, release, time.Now()); err != nil {
+		if errors.Is(err, models.ErrPromptReleaseRunning) {
+			response.Fail(c, "a prompt release is already running", nil)
+			return
+		}
+		response.Fail(c, "failed to start prompt release", err.Error())
+		return
+	}
+	response.Success(c, "prompt release started", release)
+}
+
+// ListPromptReleases lists the latest prompt releases of an assistant
+// GET /assistant/:id/prompt-releases
+func (h *Handlers) ListPromptReleases(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	releases, err := models.ListPromptReleases(h.db, assistant.ID, limit)
+	if err != nil {
+		response.Fail(c, "failed to list prompt releases", err.Error())
+		return
+	}
+	response.Success(c, "success", releases)
+}
+
+// GetPromptRelease returns a release with a fresh comparison of both arms while it runs
+// GET /assistant/:id/prompt-releases/:releaseId
+func (h *Handlers) GetPromptRelease(c *gin.Context) {
+	_, release, ok := h.ownedPromptRelease(c)
+	if !ok {
+		return
+	}
+	if release.Status == models.PromptReleaseRunning {
+		analysis, err := models.AnalyzePromptRelease(h.db, release, time.Now())
+		if err != nil {
+			response.Fail(c, "failed to analyze prompt release", err.Error())
+			return
+		}
+		response.Success(c, "success", gin.H{"release": release, "analysis": analysis})
+		return
+	}
+	response.Success(c, "success", promptReleaseView(release))
+}
+
+// PromotePromptRelease ends a running release early and makes the candidate the assistant prompt
+// POST /assistant/:id/prompt-releases/:releaseId/promote
+func (h *Handlers) PromotePromptRelease(c *gin.Context) {
+	h.finishPromptReleaseManually(c, models.PromptReleasePromoted, "promoted manually")
+}
+
+// RollbackPromptRelease ends a running release and sends every call back to the baseline prompt
+// POST /assistant/:id/prompt-releases/:releaseId/rollback
+func (h *Handlers) RollbackPromptRelease(c *gin.Context) {
+	h.finishPromptReleaseManually(c, models.PromptReleaseRolledBack, "rolled back manually")
+}
+
+func (h *Handlers) finishPromptReleaseManually(c *gin.Context, status, reason string) {
+	assistant, release, ok := h.ownedPromptRelease(c)
+	if !ok {
+		return
+	}
+	if err := models.FinishPromptRelease(h.db, release, status, reason, time.Now()); err != nil {
+		if errors.Is(err, models.ErrPromptReleaseNotRunning) {
+			response.Fail(c, "prompt release is not running", nil)
+			return
+		}
+		response.Fail(c, "failed to update prompt release", err.Error())
+		return
+	}
+	h.invalidateAssistantCache(assistant.UserID, assistant.GroupID)
+	response.Success(c, "success", promptReleaseView(release))
+}
+
+// EvaluatePromptReleases compares the arms of every running release, rolls
+// back regressed candidates, promotes healthy ones when their window ends and
+// notifies the owner of each decision. It runs on a schedule.
+func (h *Handlers) EvaluatePromptReleases(now time.Time) {
+	releases, err := models.ListRunningPromptReleases(h.db)
+	if err != nil {
+		logger.Error("Failed to list running prompt releases", zap.Error(err))
+		return
+	}
+	for i := range releases {
+		h.evaluatePromptRelease(&releases[i], now)
+	}
+}
+
+func (h *Handlers) evaluatePromptRelease(release *models.AssistantPromptRelease, now time.Time) {
+	analysis, err := models.AnalyzePromptRelease(h.db, release, now)
+	if err != nil {
+		logger.Error("Failed to analyze prompt release", zap.Uint("releaseId", release.ID), zap.Error(err))
+		return
+	}
+	if err := models.SavePromptAnalysis(h.db, release, analysis); err != nil {
+		logger.Warn("Failed to save prompt release analysis", zap.Uint("releaseId", release.ID), zap.Error(err))
+	}
+
+	var status, reason string
+	switch analysis.Decide() {
+	case models.PromptReleaseRollback:
+		status = models.PromptReleaseRolledBack
+		reason = "regression: " + strings.Join(analysis.Regressions, "; ")
+	case models.PromptReleasePromote:
+		status = models.PromptReleasePromoted
+		reason = fmt.Sprintf("no regression after %d baseline and %d candidate calls",
+			analysis.Baseline.Calls, analysis.Candidate.Calls)
+	case models.PromptReleaseExpire:
+		status = models.PromptReleaseInconclusive
+		reason = fmt.Sprintf("window ended with %d baseline and %d candidate calls, %d needed per arm",
+			analysis.Baseline.Calls, analysis.Candidate.Calls, release.MinCalls)
+	default:
+		return
+	}
+
+	if err := models.FinishPromptRelease(h.db, release, status, reason, now); err != nil {
+		if !errors.Is(err, models.ErrPromptReleaseNotRunning) {
+			logger.Error("Failed to finish prompt release", zap.Uint("releaseId", release.ID), zap.Error(err))
+		}
+		return
+	}
+	var assistant models.Assistant
+	h.db.Select("id", "name", "group_id").First(&assistant, release.AssistantID)
+	h.invalidateAssistantCache(release.UserID, assistant.GroupID)
+	logger.Info("Prompt release finished",
+		zap.Uint("releaseId", release.ID),
+		zap.Int64("assistantId", release.AssistantID),
+		zap.String("status", status),
+		zap.String("reason", reason))
+
+	title := fmt.Sprintf("Prompt release for %s: %s", assistant.Name, strings.ReplaceAll(status, "_", " "))
+	if err := notification.NewInternalNotificationService(h.db).Send(release.UserID, title, reason); err != nil {
+		logger.Warn("Failed to notify prompt release owner", zap.Uint("releaseId", release.ID), zap.Error(err))
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shareAssistantRequest body of sharing an assistant with an organization
+type shareAssistantRequest struct {
+	GroupID    uint   `json:"groupId" binding:"required"`
+	Permission string `json:"permission"` // use (default) or edit
+}
+
+// ListAssistantShares lists the organizations the assistant is shared with
+// GET /assistant/:id/shares
+func (h *Handlers) ListAssistantShares(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	shares, err := models.ListAssistantShares(h.db, assistant.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", shares)
+}
+
+// ShareAssistant shares a personal assistant with an organization the owner
+// belongs to, or changes the permission of an existing share. Members see it in
+// their assistant list and their usage is attributed to the organization.
+// PUT /assistant/:id/shares
+func (h *Handlers) ShareAssistant(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	var req shareAssistantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if req.Permission == "" {
+		req.Permission = models.AssistantSharePermissionUse
+	}
+	var group models.Group
+	if err := h.db.First(&group, req.GroupID).Error; err != nil {
+		response.Fail(c, "organization not found", nil)
+		return
+	}
+	user := models.CurrentUser(c)
+	if !models.IsGroupMember(h.db, &group, user.ID) {
+		response.Fail(c, "forbidden", "You can only share with organizations you belong to")
+		return
+	}
+	share, err := models.ShareAssistant(h.db, assistant, group.ID, req.Permission, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidSharePermission) || errors.Is(err, models.ErrShareOrgAssistant) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "share failed", err.Error())
+		return
+	}
+	share.Group = &group
+	response.Success(c, "shared", share)
+}
+
+// UnshareAssistant stops sharing the assistant with an organization; usage
+// already recorded stays attributed to it
+// DELETE /assistant/:id/shares/:groupId
+func (h *Handlers) UnshareAssistant(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid organization id", nil)
+		return
+	}
+	if err := models.UnshareAssistant(h.db, assistant.ID, uint(groupID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "share not found", nil)
+			return
+		}
+		response.Fail(c, "unshare failed", err.Error())
+		return
+	}
+	response.Success(c, "unshared", nil)
+}
+
+// GetAssistantShareUsage summarizes the usage of the assistant by organization
+// members over the last days (default 30, at most 365), optionally for one groupId
+// GET /assistant/:id/shares/usage
+func (h *Handlers) GetAssistantShareUsage(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	var groupID *uint
+	if v := c.Query("groupId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			response.Fail(c, "invalid organization id", nil)
+			return
+		}
+		gid := uint(id)
+		groupID = &gid
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	usage, err := models.GetAssistantMemberUsage(h.db, assistant.ID, groupID, since)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"usage": usage, "since": since, "days": days})
+}
@@ -62,6 +62,20 @@ func (h *Handlers) LoadAssistantToolsToHandler(handler *llm.LLMHandler, assistan
 		// Don't fail the entire loading process if workflow loading fails
 	}
 
+	// Register the built-in calendar tools granted to this assistant
+	if err := h.LoadCalendarToolsToHandler(handler, assistantID); err != nil {
+		logger.Warn("Failed to load calendar tools",
+			zap.Int64("assistantID", assistantID),
+			zap.Error(err))
+	}
+
+	// Register the device control tools when the owner allowed them
+	if err := h.LoadDeviceControlToolsToHandler(handler, assistantID); err != nil {
+		logger.Warn("Failed to load device control tools",
+			zap.Int64("assistantID", assistantID),
+			zap.Error(err))
+	}
+
 	return nil
 }
 
@@ -20,12 +20,21 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/constants"
 	"github.com/code-100-precent/LingEcho/pkg/graph"
 	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/plans"
 	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/sessionlimit"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// Bounds of the per-assistant concurrency settings
+const (
+	maxAssistantConcurrentSessions = 1000
+	maxAssistantSessionQueue       = 100
+	maxAssistantQueueTimeout       = 300
+)
+
 // hashString 计算字符串的哈希值（用于灰度发布）
 func hashString(s string) int {
 	hash := sha256.Sum256([]byte(s))
@@ -89,6 +98,7 @@ func (h *Handlers) CreateAssistant(c *gin.Context) {
 		return
 	}
 	utils.Sig().Emit(constants.AssistantCreate, user, h.db, assistant)
+	h.invalidateAssistantCache(user.ID, assistant.GroupID)
 	response.Success(c, fmt.Sprintf("Successfully created assistant %s", assistant.Name), assistant)
 }
 
@@ -104,24 +114,54 @@ func (h *Handlers) ListAssistants(c *gin.Context) {
 	// Query user's own assistants and organization-shared assistants
 	// 1. Assistants created by the user (user_id = ?)
 	// 2. Organization-shared assistants (group_id IN (list of organization IDs the user belongs to))
+	// 3. Personal assistants shared with one of those organizations (assistant_shares)
 	var groupIDs []uint
 	h.db.Model(&models.GroupMember{}).
 		Where("user_id = ?", user.ID).
 		Pluck("group_id", &groupIDs)
+	sharedIDs := models.SharedAssistantIDs(h.db, user.ID)
 
 	query := h.db.Model(&models.Assistant{})
-	if len(groupIDs) > 0 {
+	switch {
+	case len(groupIDs) > 0 && len(sharedIDs) > 0:
+		query = query.Where("user_id = ? OR (group_id IN ? AND group_id IS NOT NULL) OR id IN ?", user.ID, groupIDs, sharedIDs)
+	case len(groupIDs) > 0:
 		// User's own assistants OR organization-shared assistants
 		query = query.Where("user_id = ? OR (group_id IN ? AND group_id IS NOT NULL)", user.ID, groupIDs)
-	} else {
+	case len(sharedIDs) > 0:
+		query = query.Where("user_id = ? OR id IN ?", user.ID, sharedIDs)
+	default:
 		// Only query user's own assistants
 		query = query.Where("user_id = ?", user.ID)
 	}
 
+	// Filter by custom fields (?cf.<key>=<value>)
+	matched, filtered, err := h.customFieldMatches(c, models.CustomFieldEntityAssistant)
+	if err != nil {
+		response.Fail(c, "select assistants failed", nil)
+		return
+	}
+	if filtered {
+		ids := make([]int64, 0, len(matched))
+		for id := range matched {
+			if v, err := strconv.ParseInt(id, 10, 64); err == nil {
+				ids = append(ids, v)
+			}
+		}
+		if len(ids) == 0 {
+			response.Success(c, "select assistants successful", list)
+			return
+		}
+		query = query.Where("id IN ?", ids)
+	}
+
 	if err := query.Order("created_at desc").Find(&list).Error; err != nil {
 		response.Fail(c, "select assistants failed", nil)
 		return
 	}
+	if err := models.AttachAssistantCustomFields(h.db, list); err != nil {
+		logger.Warn("Failed to load assistant custom fields", zap.Error(err))
+	}
 
 	response.Success(c, "select assistants successful", list)
 }
@@ -135,7 +175,7 @@ func (h *Handlers) GetAssistant(c *gin.Context) {
 		response.Fail(c, "not found", "this assistant is not exist")
 		return
 	}
-	if user.ID != assistant.UserID {
+	if !models.CanUseAssistant(h.db, &assistant, user.ID) {
 		response.Fail(c, "permission denied", "you are not allowed to access this assistant")
 		return
 	}
@@ -153,25 +193,36 @@ func (h *Handlers) UpdateAssistant(c *gin.Context) {
 	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
 
 	var input struct {
-		Name                 string   `json:"name"`
-		Description          string   `json:"description"`
-		Icon                 string   `json:"icon"`
-		SystemPrompt         string   `json:"systemPrompt"`
-		PersonaTag           string   `json:"persona_tag"`
-		Temperature          float32  `json:"temperature"`
-		MaxTokens            int      `json:"maxTokens"`
-		Language             string   `json:"language"`
-		Speaker              string   `json:"speaker"`
-		VoiceCloneId         *int     `json:"voiceCloneId"`
-		KnowledgeBaseId      *string  `json:"knowledgeBaseId"`
-		TtsProvider          string   `json:"ttsProvider"`
-		ApiKey               string   `json:"apiKey"`
-		ApiSecret            string   `json:"apiSecret"`
-		LLMModel             string   `json:"llmModel"` // LLM model name
-		EnableGraphMemory    *bool    `json:"enableGraphMemory"`
-		EnableVAD            *bool    `json:"enableVAD"`            // 是否启用VAD
-		VADThreshold         *float64 `json:"vadThreshold"`         // VAD阈值
-		VADConsecutiveFrames *int     `json:"vadConsecutiveFrames"` // VAD连续帧数
+		Name                  string                   `json:"name"`
+		Description           string                   `json:"description"`
+		Icon                  string                   `json:"icon"`
+		SystemPrompt          string                   `json:"systemPrompt"`
+		PersonaTag            string                   `json:"persona_tag"`
+		Temperature           float32                  `json:"temperature"`
+		MaxTokens             int                      `json:"maxTokens"`
+		Language              string                   `json:"language"`
+		Speaker               string                   `json:"speaker"`
+		VoiceCloneId          *int                     `json:"voiceCloneId"`
+		KnowledgeBaseId       *string                  `json:"knowledgeBaseId"`
+		TtsProvider           string                   `json:"ttsProvider"`
+		ApiKey                string                   `json:"apiKey"`
+		ApiSecret             string                   `json:"apiSecret"`
+		LLMModel              string                   `json:"llmModel"` // LLM model name
+		EnableGraphMemory     *bool                    `json:"enableGraphMemory"`
+		EnableVAD             *bool                    `json:"enableVAD"`             // 是否启用VAD
+		VADThreshold          *float64                 `json:"vadThreshold"`          // VAD阈值
+		VADConsecutiveFrames  *int                     `json:"vadConsecutiveFrames"`  // VAD连续帧数
+		TTSNormalization      *models.TTSNormalization `json:"ttsNormalization"`      // TTS文本规范化配置
+		MaxConcurrentSessions *int                     `json:"maxConcurrentSessions"` // 0 = unlimited
+		SessionQueueSize      *int                     `json:"sessionQueueSize"`
+		SessionQueueTimeout   *int                     `json:"sessionQueueTimeout"` // Seconds
+		CallbackOfferAfter    *int                     `json:"callbackOfferAfter"`  // Seconds in queue before a SIP callback is offered, 0 = never
+		EnableDeviceControl   *bool                    `json:"enableDeviceControl"`
+		InterpreterMode       *bool                    `json:"interpreterMode"`     // Dial InterpreterTarget and interpret SIP calls both ways
+		InterpreterLanguage   *string                  `json:"interpreterLanguage"` // Language of the dialed party
+		InterpreterTarget     *string                  `json:"interpreterTarget"`   // SIP URI dialed for every incoming call
+		InterpreterSpeaker    *string                  `json:"interpreterSpeaker"`  // TTS voice for the dialed party's language
+		ProviderGuard         *models.ProviderGuard    `json:"providerGuard"`       // Stage budgets, filler/apology prompts and failover credential
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		response.Fail(c, "invalid request", "parameter error")
@@ -184,7 +235,8 @@ func (h *Handlers) UpdateAssistant(c *gin.Context) {
 		return
 	}
 
-	if assistant.UserID != user.ID {
+	// Owners and organizations the assistant is shared with for editing
+	if !models.CanEditAssistant(h.db, &assistant, user.ID) {
 		response.Fail(c, "forbidden", "No permission to operate this assistant.")
 		return
 	}
@@ -223,6 +275,13 @@ func (h *Handlers) UpdateAssistant(c *gin.Context) {
 		updateData["speaker"] = input.Speaker
 	}
 	if input.VoiceCloneId != nil {
+		// Cloned voices of other accounts cannot be assigned
+		if *input.VoiceCloneId > 0 {
+			if _, err := models.GetAssistantVoiceClone(h.db, int64(*input.VoiceCloneId), &assistant); err != nil {
+				response.Fail(c, "invalid request", "voice clone is not available to this assistant")
+				return
+			}
+		}
 		updateData["voice_clone_id"] = input.VoiceCloneId
 	}
 	if input.KnowledgeBaseId != nil {
@@ -252,6 +311,92 @@ func (h *Handlers) UpdateAssistant(c *gin.Context) {
 	if input.VADConsecutiveFrames != nil {
 		updateData["vad_consecutive_frames"] = *input.VADConsecutiveFrames
 	}
+	if input.TTSNormalization != nil {
+		updateData["tts_normalization"] = input.TTSNormalization
+	}
+	if input.MaxConcurrentSessions != nil {
+		if *input.MaxConcurrentSessions < 0 || *input.MaxConcurrentSessions > maxAssistantConcurrentSessions {
+			response.Fail(c, "invalid request", fmt.Sprintf("maxConcurrentSessions must be between 0 and %d", maxAssistantConcurrentSessions))
+			return
+		}
+		updateData["max_concurrent_sessions"] = *input.MaxConcurrentSessions
+	}
+	if input.SessionQueueSize != nil {
+		if *input.SessionQueueSize < 0 || *input.SessionQueueSize > maxAssistantSessionQueue {
+			response.Fail(c, "invalid request", fmt.Sprintf("sessionQueueSize must be between 0 and %d", maxAssistantSessionQueue))
+			return
+		}
+		updateData["session_queue_size"] = *input.SessionQueueSize
+	}
+	if input.SessionQueueTimeout != nil {
+		if *input.SessionQueueTimeout < 0 || *input.SessionQueueTimeout > maxAssistantQueueTimeout {
+			response.Fail(c, "invalid request", fmt.Sprintf("sessionQueueTimeout must be between 0 and %d seconds", maxAssistantQueueTimeout))
+			return
+		}
+		updateData["session_queue_timeout"] = *input.SessionQueueTimeout
+	}
+	if input.CallbackOfferAfter != nil {
+		if *input.CallbackOfferAfter < 0 || *input.CallbackOfferAfter > maxAssistantQueueTimeout {
+			response.Fail(c, "invalid request", fmt.Sprintf("callbackOfferAfter must be between 0 and %d seconds", maxAssistantQueueTimeout))
+			return
+		}
+		updateData["callback_offer_after"] = *input.CallbackOfferAfter
+	}
+	if input.EnableDeviceControl != nil {
+		// The tool acts on the owner's devices, so only the owner may grant it
+		if assistant.UserID != user.ID {
+			response.Fail(c, "forbidden", "Only the assistant owner can change device control.")
+			return
+		}
+		updateData["enable_device_control"] = *input.EnableDeviceControl
+	}
+	if input.InterpreterMode != nil || input.InterpreterLanguage != nil || input.InterpreterTarget != nil {
+		// Every incoming call places an outbound call, so only the owner with SIP trunking may configure it
+		if assistant.UserID != user.ID {
+			response.Fail(c, "forbidden", "Only the assistant owner can change interpreter mode.")
+			return
+		}
+		if !models.PlanAllows(h.db, user, plans.FeatureSIPTrunking) {
+			response.Fail(c, "forbidden", "Interpreter mode requires SIP trunking in your plan.")
+			return
+		}
+		mode, language, target := assistant.InterpreterMode, assistant.InterpreterLanguage, assistant.InterpreterTarget
+		if input.InterpreterMode != nil {
+			mode = *input.InterpreterMode
+		}
+		if input.InterpreterLanguage != nil {
+			language = strings.TrimSpace(*input.InterpreterLanguage)
+		}
+		if input.InterpreterTarget != nil {
+			target = strings.TrimSpace(*input.InterpreterTarget)
+		}
+		if err := models.ValidateInterpreter(mode, language, target); err != nil {
+			response.Fail(c, "invalid request", err.Error())
+			return
+		}
+		updateData["interpreter_mode"] = mode
+		updateData["interpreter_language"] = language
+		updateData["interpreter_target"] = target
+	}
+	if input.InterpreterSpeaker != nil {
+		updateData["interpreter_speaker"] = strings.TrimSpace(*input.InterpreterSpeaker)
+	}
+
+	if input.ProviderGuard != nil {
+		if err := input.ProviderGuard.Validate(); err != nil {
+			response.Fail(c, "invalid request", err.Error())
+			return
+		}
+		// The failover provider is billed to the owner, so it must be one of the owner's credentials
+		if input.ProviderGuard.FailoverCredentialID != 0 {
+			var failover models.UserCredential
+			if err := h.db.Where("id = ? AND user_id = ?", input.ProviderGuard.FailoverCredentialID, assistant.UserID).First(&failover).Error; err != nil {
+				response.Fail(c, "invalid request", "failover credential does not belong to the assistant owner")
+				return
+			}
+		}
+		updateData["provider_guard"] = input.ProviderGuard
+	}
 
 	if err := h.db.Model(&assistant).Where("id = ?", id).Updates(updateData).Error; err != nil {
 		response.Fail(c, "update failed", "Update failed")
@@ -264,6 +409,8 @@ func (h *Handlers) UpdateAssistant(c *gin.Context) {
 		return
 	}
 
+	h.invalidateAssistantCache(assistant.UserID, assistant.GroupID)
+	h.invalidateLLMCache(uint(assistant.ID))
 	response.Success(c, "Update successful", assistant)
 }
 
@@ -310,6 +457,7 @@ func (h *Handlers) UpdateAssistantJS(c *gin.Context) {
 		response.Fail(c, "Update failed", nil)
 		return
 	}
+	h.invalidateAssistantCache(assistant.UserID, assistant.GroupID)
 
 	response.Success(c, "Update successful", nil)
 }
@@ -364,6 +512,38 @@ func (h *Handlers) GetAssistantGraphData(c *gin.Context) {
 	response.Success(c, "Graph data retrieved successfully", graphData)
 }
 
+// GetAssistantSessionStats returns the concurrency limit and the live session counters of an assistant
+func (h *Handlers) GetAssistantSessionStats(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", "User not logged in")
+		return
+	}
+
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	var assistant models.Assistant
+	if err := h.db.First(&assistant, id).Error; err != nil {
+		response.Fail(c, "not found", "Assistant does not exist")
+		return
+	}
+	if assistant.UserID != user.ID {
+		response.Fail(c, "forbidden", "No permission to access this assistant")
+		return
+	}
+
+	stats := sessionlimit.Default().Stats(models.AssistantSessionKey(assistant.ID))
+	response.Success(c, "success", gin.H{
+		"maxConcurrentSessions": assistant.MaxConcurrentSessions,
+		"sessionQueueSize":      assistant.SessionQueueSize,
+		"sessionQueueTimeout":   assistant.SessionQueueTimeout,
+		"callbackOfferAfter":    assistant.CallbackOfferAfter,
+		"callbackEnabled":       assistant.CallbackOffer() > 0,
+		"stats":                 stats,
+		"avgWaitMs":             stats.AvgWait().Milliseconds(),
+		"maxWaitMs":             stats.MaxWait.Milliseconds(),
+	})
+}
+
 // DeleteAssistant Delete assistant
 func (h *Handlers) DeleteAssistant(c *gin.Context) {
 	user := models.CurrentUser(c)
@@ -389,6 +569,17 @@ func (h *Handlers) DeleteAssistant(c *gin.Context) {
 		response.Fail(c, "delete failed", "Delete failed")
 		return
 	}
+	if err := models.DeleteAssistantShares(h.db, assistant.ID); err != nil {
+		logger.Warn("Failed to delete assistant shares", zap.Int64("assistantId", assistant.ID), zap.Error(err))
+	}
+	if err := models.DeleteCustomFieldValues(h.db, models.CustomFieldEntityAssistant, strconv.FormatInt(assistant.ID, 10)); err != nil {
+		logger.Warn("Failed to delete assistant custom fields", zap.Int64("assistantId", assistant.ID), zap.Error(err))
+	}
+	if err := models.RecordSyncTombstone(h.db, assistant.UserID, models.SyncEntityAssistant, strconv.FormatInt(assistant.ID, 10)); err != nil {
+		logger.Warn("Failed to record sync tombstone", zap.Int64("assistantId", assistant.ID), zap.Error(err))
+	}
+	h.invalidateAssistantCache(assistant.UserID, assistant.GroupID)
+	h.invalidateLLMCache(uint(assistant.ID))
 
 	response.Success(c, "Delete successful", nil)
 }
@@ -961,3 +1152,28 @@ func isValidURL(urlStr string) bool {
 	// Only allow HTTP and HTTPS protocols
 	return parsedURL.Scheme == "http" || parsedURL.Scheme == "https"
 }
+
+// loadAssistantParam loads the assistant from :id for the current user, allowed decides
+// whether the user may access it. Writes the error response and returns false otherwise
+func (h *Handlers) loadAssistantParam(c *gin.Context, allowed func(assistant *models.Assistant, userID uint) bool) (*models.Assistant, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", "User not logged in")
+		return nil, false
+	}
+	assistantID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid assistant id", nil)
+		return nil, false
+	}
+	var assistant models.Assistant
+	if err := h.db.First(&assistant, assistantID).Error; err != nil {
+		response.Fail(c, "not found", "Assistant does not exist")
+		return nil, false
+	}
+	if !allowed(&assistant, user.ID) {
+		response.Fail(c, "forbidden", "No permission to access this assistant")
+		return nil, false
+	}
+	return &assistant, true
+}
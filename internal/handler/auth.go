@@ -23,6 +23,7 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/notification"
 	"github.com/code-100-precent/LingEcho/pkg/response"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
 	"github.com/gin-gonic/gin"
 	"github.com/pquerna/otp/totp"
 	"github.com/skip2/go-qrcode"
@@ -52,11 +53,30 @@ func (h *Handlers) registerAuthRoutes(r *gin.RouterGroup) {
 		auth.POST("/login", h.handleUserSignin)
 		auth.POST("/login/password", h.handleUserSigninByPassword)
 		auth.POST("/login/email", h.handleUserSigninByEmail)
+		auth.POST("/login/phone", h.SignInByPhone)
+		auth.POST("/login/phone/send", h.SendPhoneLoginCode)
+
+		// social login (OAuth2)
+		auth.GET("/oauth/providers", h.ListOAuthProviders)
+		auth.GET("/oauth/:provider/login", h.OAuthLogin)
+		auth.GET("/oauth/:provider/callback", h.OAuthCallback)
+		auth.POST("/oauth/two-factor", h.OAuthTwoFactor)
+		auth.GET("/oauth/identities", models.AuthRequired, h.ListMyOAuthIdentities)
+		auth.DELETE("/oauth/identities/:id", models.AuthRequired, h.UnlinkMyOAuthIdentity)
+
+		// enterprise single sign-on (OpenID Connect)
+		auth.GET("/oidc/login", h.OIDCLogin)
+		auth.GET("/oidc/callback", h.OIDCCallback)
 
 		// logout
 		auth.GET("/logout", models.AuthRequired, h.handleUserLogout)
 		auth.GET("/info", models.AuthRequired, h.handleUserInfo)
 
+		// access token refresh and revocation
+		auth.POST("/token/refresh", h.RefreshAuthToken)
+		auth.POST("/token/revoke", models.AuthRequired, h.RevokeAuthToken)
+		auth.POST("/token/revoke-user/:id", models.AuthRequired, models.WithAdminAuth(), h.AdminRevokeUserTokens)
+
 		// password management
 		auth.GET("/reset-password", h.handleUserResetPasswordPage)
 		auth.POST("/reset-password", h.handleResetPassword)
@@ -64,11 +84,18 @@ func (h *Handlers) registerAuthRoutes(r *gin.RouterGroup) {
 		auth.POST("/change-password", models.AuthRequired, h.handleChangePassword)
 		auth.POST("/change-password/email", models.AuthRequired, h.handleChangePasswordByEmail)
 
+		// account locks after failed logins
+		auth.POST("/unlock-account", h.RequestAccountUnlock)
+		auth.POST("/unlock-account/confirm", h.ConfirmAccountUnlock)
+		auth.GET("/account-locks", models.AuthRequired, models.WithAdminAuth(), h.ListAccountLocks)
+		auth.DELETE("/account-locks/:id", models.AuthRequired, models.WithAdminAuth(), h.ClearAccountLock)
+
 		// device management
 		auth.GET("/devices", models.AuthRequired, h.handleGetUserDevices)
 		auth.DELETE("/devices", models.AuthRequired, h.handleDeleteUserDevice)
 		auth.POST("/devices/trust", models.AuthRequired, h.handleTrustUserDevice)
 		auth.POST("/devices/untrust", models.AuthRequired, h.handleUntrustUserDevice)
+		auth.GET("/login-locations", models.AuthRequired, h.handleGetLoginLocationHeatmap)
 
 		// device verification (no auth required for login flow)
 		auth.POST("/devices/verify", h.handleVerifyDeviceForLogin)
@@ -77,6 +104,7 @@ func (h *Handlers) registerAuthRoutes(r *gin.RouterGroup) {
 		// email verification
 		auth.GET("/verify-email", h.handleVerifyEmail)
 		auth.POST("/send-email-verification", models.AuthRequired, h.handleSendEmailVerification)
+		auth.GET("/email-verification-policy", models.AuthRequired, h.handleGetEmailVerificationPolicy)
 
 		// phone verification
 		auth.POST("/verify-phone", models.AuthRequired, h.handleVerifyPhone)
@@ -89,6 +117,8 @@ func (h *Handlers) registerAuthRoutes(r *gin.RouterGroup) {
 
 		// notification settings
 		auth.PUT("/notification-settings", models.AuthRequired, h.handleUpdateNotificationSettings)
+		auth.GET("/notification-preferences", models.AuthRequired, h.handleGetNotificationPreferences)
+		auth.PUT("/notification-preferences", models.AuthRequired, h.handleUpdateNotificationPreferences)
 
 		// user preferences
 		auth.PUT("/user-preferences", models.AuthRequired, h.handleUpdateUserPreferences)
@@ -104,6 +134,7 @@ func (h *Handlers) registerAuthRoutes(r *gin.RouterGroup) {
 		auth.POST("/two-factor/enable", models.AuthRequired, h.handleTwoFactorEnable)
 		auth.POST("/two-factor/disable", models.AuthRequired, h.handleTwoFactorDisable)
 		auth.GET("/two-factor/status", models.AuthRequired, h.handleTwoFactorStatus)
+		auth.POST("/two-factor/backup-codes/regenerate", models.AuthRequired, h.handleTwoFactorRegenerateBackupCodes)
 
 		// user activity logs
 		auth.GET("/activity", models.AuthRequired, h.handleGetUserActivity)
@@ -134,6 +165,12 @@ func (h *Handlers) handleUserSigninPage(c *gin.Context) {
 func (h *Handlers) handleUserLogout(c *gin.Context) {
 	user := models.CurrentUser(c)
 	if user != nil {
+		// The bearer token stops working right away instead of at its expiry
+		if claims := h.requestAccessClaims(c); claims != nil {
+			if err := models.RevokeAccessToken(h.db, user.ID, claims, "logout", time.Now()); err != nil {
+				logger.Warn("Failed to revoke token on logout", zap.Uint("userID", user.ID), zap.Error(err))
+			}
+		}
 		models.Logout(c, user)
 	}
 	next := c.Query("next")
@@ -151,16 +188,6 @@ func (h *Handlers) handleUserInfo(c *gin.Context) {
 		response.AbortWithStatus(c, http.StatusUnauthorized)
 		return
 	}
-	withToken := c.Query("with_token")
-	if withToken != "" {
-		expired, err := time.ParseDuration(withToken)
-		if err == nil {
-			if expired >= 24*time.Hour {
-				expired = 24 * time.Hour
-			}
-			user.AuthToken = models.BuildAuthToken(user, expired, false)
-		}
-	}
 	response.Success(c, "success", user)
 }
 
@@ -204,14 +231,14 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 		}
 	}
 
-	// 3. 图形验证码验证
-	if captcha.GlobalCaptchaManager != nil {
-		if form.CaptchaID == "" || form.CaptchaCode == "" {
+	// 3. 人机验证（图形验证码或 Turnstile 等）
+	if captcha.Enabled() {
+		if form.CaptchaCode == "" {
 			LingEcho.AbortWithJSONError(c, http.StatusBadRequest, errors.New("captcha is required"))
 			return
 		}
 
-		valid, err := captcha.GlobalCaptchaManager.Verify(form.CaptchaID, form.CaptchaCode)
+		valid, err := captcha.Verify(c.Request.Context(), form.CaptchaID, form.CaptchaCode, clientIP)
 		if err != nil || !valid {
 			if utils.GlobalLoginSecurityManager != nil {
 				recordFunc := func(db *gorm.DB, email string, userID uint, ipAddress string, failedCount int) error {
@@ -251,9 +278,8 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 		return
 	}
 
-	// 从缓存中获取验证码
-	cachedCode, ok := utils.GlobalCache.Get(form.Email)
-	if !ok || cachedCode != form.Code {
+	// 校验验证码，通过后即失效
+	if err := utils.GlobalEmailCodes.Verify(utils.CodePurposeLogin, form.Email, form.Code); err != nil {
 		if utils.GlobalLoginSecurityManager != nil {
 			recordFunc := func(db *gorm.DB, email string, userID uint, ipAddress string, failedCount int) error {
 				_, err := models.CreateOrUpdateAccountLock(db, email, userID, ipAddress, failedCount)
@@ -261,13 +287,10 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 			}
 			utils.GlobalLoginSecurityManager.RecordFailedLogin(db, form.Email, user.ID, clientIP, recordFunc)
 		}
-		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, errors.New("invalid verification code"))
+		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	// 清除已用验证码
-	utils.GlobalCache.Remove(form.Email)
-
 	// 6. 检查用户是否允许登录（激活、启用等）
 	err = models.CheckUserAllowLogin(db, user)
 	if err != nil {
@@ -281,6 +304,12 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 		LingEcho.AbortWithJSONError(c, http.StatusForbidden, err)
 		return
 	}
+	if h.rejectForEnforcedSSO(c, user.Email, user) {
+		return
+	}
+	if h.rejectForSecurityPolicy(c, user) {
+		return
+	}
 
 	// 7. 获取IP地理位置
 	country, city, location := "Unknown", "Unknown", "Unknown"
@@ -319,6 +348,15 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 	deviceType, os, browser := utils.ParseUserAgent(userAgent)
 	deviceID := utils.GetDeviceID(userAgent, clientIP)
 
+	// 登录风险评分（规则或外部模型），邮箱验证码本身满足 challenge，只处理 block
+	anomaly := h.scoreLoginAnomaly(c, db, user.ID, clientIP, deviceID, country, city)
+	if h.rejectForLoginRisk(c, db, user, anomaly, clientIP, location, country, city, userAgent, deviceID, "email") {
+		return
+	}
+	if anomaly != nil && anomaly.Suspicious {
+		isSuspicious = true
+	}
+
 	// 10. 检查设备信任状态
 	isTrusted, err := models.CheckDeviceTrust(db, user.ID, deviceID)
 	if err != nil {
@@ -350,12 +388,12 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 	}
 
 	// 12. 记录登录历史
-	if err := models.RecordLoginHistory(db, user.ID, form.Email, clientIP, location, country, city, userAgent, deviceID, "email", true, "", isSuspicious); err != nil {
+	if err := models.RecordLoginHistoryWithAnomaly(db, user.ID, form.Email, clientIP, location, country, city, userAgent, deviceID, "email", true, "", isSuspicious, anomaly); err != nil {
 		logger.Warn("Failed to record login history", zap.Error(err))
 	}
 
 	// 13. 发送新设备登录警告邮件（异步）
-	logger.Info("Checking new device login alert conditions",
+	logger.Debug("Checking new device login alert conditions",
 		zap.Bool("isTrusted", isTrusted),
 		zap.Bool("isSuspicious", isSuspicious),
 		zap.String("deviceID", deviceID))
@@ -375,7 +413,7 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 	utils.Sig().Emit(constants.SigUserNewDeviceLogin, user, deviceInfo, db)
 
 	if !isTrusted || isSuspicious {
-		logger.Info("Sending new device login alert signal",
+		logger.Debug("Sending new device login alert signal",
 			zap.String("email", user.Email),
 			zap.String("deviceID", deviceID),
 			zap.Bool("isTrusted", isTrusted),
@@ -394,7 +432,7 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 		}
 		utils.Sig().Emit(constants.SigUserNewDeviceLogin, user, deviceInfo, db)
 	} else {
-		logger.Info("Skipping new device login alert - device is trusted and not suspicious",
+		logger.Debug("Skipping new device login alert - device is trusted and not suspicious",
 			zap.String("email", user.Email),
 			zap.String("deviceID", deviceID))
 	}
@@ -442,19 +480,15 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 	}
 
 	// 如果需要 Token，生成 AuthToken
-	if form.AuthToken {
-		val := utils.GetValue(db, constants.KEY_AUTH_TOKEN_EXPIRED)
-		expired, _ := time.ParseDuration(val)
-		if expired < 24*time.Hour {
-			expired = 24 * time.Hour
-		}
-		user.AuthToken = models.BuildAuthToken(user, expired, false)
+	if form.AuthToken && !h.issueLoginTokens(c, db, user) {
+		return
 	}
 
 	// 返回登录结果（包含可疑登录警告）
 	responseData := gin.H{
-		"user":  user,
-		"token": user.AuthToken, // 为了兼容前端，同时返回token字段
+		"user":         user,
+		"token":        user.AuthToken, // 为了兼容前端，同时返回token字段
+		"refreshToken": user.RefreshToken,
 	}
 	if isSuspicious {
 		responseData["suspiciousLogin"] = true
@@ -611,15 +645,15 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 			}
 		}
 
-		// 6. 图形验证码验证（密码登录需要）
-		if captcha.GlobalCaptchaManager != nil {
-			if form.CaptchaID == "" || form.CaptchaCode == "" {
+		// 6. 人机验证（密码登录需要）
+		if captcha.Enabled() {
+			if form.CaptchaCode == "" {
 				logger.Warn("Login failed: captcha is required", zap.String("email", form.Email), zap.Uint("userID", user.ID), zap.String("ip", clientIP))
 				response.Fail(c, "请输入图形验证码", nil)
 				return
 			}
 
-			valid, err := captcha.GlobalCaptchaManager.Verify(form.CaptchaID, form.CaptchaCode)
+			valid, err := captcha.Verify(c.Request.Context(), form.CaptchaID, form.CaptchaCode, clientIP)
 			if err != nil || !valid {
 				logger.Warn("Login failed: invalid captcha code", zap.String("email", form.Email), zap.Uint("userID", user.ID), zap.String("ip", clientIP), zap.String("captchaID", form.CaptchaID), zap.Error(err))
 				if utils.GlobalLoginSecurityManager != nil {
@@ -639,10 +673,10 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 		// 检查是否是加密密码格式（passwordHash:encryptedHash:salt:timestamp）
 		if strings.Contains(form.Password, ":") && len(strings.Split(form.Password, ":")) == 4 {
 			// 加密密码验证
-			logger.Info("Verifying encrypted password",
+			logger.Debug("Verifying encrypted password",
 				zap.String("email", form.Email))
 			passwordValid = models.VerifyEncryptedPassword(form.Password, user.Password)
-			logger.Info("Encrypted password verification result",
+			logger.Debug("Encrypted password verification result",
 				zap.String("email", form.Email),
 				zap.Bool("valid", passwordValid))
 		} else {
@@ -678,6 +712,12 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 		response.Fail(c, "user no authorization to login", err)
 		return
 	}
+	if form.Password != "" && h.rejectForEnforcedSSO(c, user.Email, user) {
+		return
+	}
+	if h.rejectForSecurityPolicy(c, user) {
+		return
+	}
 
 	// 8. 获取IP地理位置
 	country, city, location := "Unknown", "Unknown", "Unknown"
@@ -716,6 +756,32 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 	deviceType, os, browser := utils.ParseUserAgent(userAgent)
 	deviceID := utils.GetDeviceID(userAgent, clientIP)
 
+	// 登录风险评分（规则或外部模型）
+	anomaly := h.scoreLoginAnomaly(c, db, user.ID, clientIP, deviceID, country, city)
+	if h.rejectForLoginRisk(c, db, user, anomaly, clientIP, location, country, city, userAgent, deviceID, "password") {
+		return
+	}
+	if anomaly != nil && anomaly.Suspicious {
+		isSuspicious = true
+	}
+	// 风险达到 challenge 时需要第二因素：开启了两步验证的用户在后面输入验证码，其他用户改用邮箱验证码登录
+	if anomaly != nil && anomaly.Decision == utils.LoginRiskChallenge && form.Password != "" && !user.TwoFactorEnabled {
+		if err := models.RecordLoginHistoryWithAnomaly(db, user.ID, form.Email, clientIP, location, country, city, userAgent, deviceID, "password", false, "risk challenge", true, anomaly); err != nil {
+			logger.Warn("Failed to record login history for risk challenge", zap.Error(err))
+		}
+		logger.Warn("Risky password login, email verification required",
+			zap.Uint("userID", user.ID),
+			zap.String("ip", clientIP),
+			zap.Float64("score", anomaly.Score),
+			zap.Strings("reasons", anomaly.Reasons))
+		response.Success(c, "Email verification required", gin.H{
+			"requiresEmailVerification": true,
+			"riskChallenge":             true,
+			"message":                   "This sign-in looks unusual. Please verify with an email code.",
+		})
+		return
+	}
+
 	// 11. 检查设备信任状态
 	isTrusted, err := models.CheckDeviceTrust(db, user.ID, deviceID)
 	if err != nil {
@@ -732,7 +798,7 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 			}
 
 			// 记录可疑登录尝试
-			if err := models.RecordLoginHistory(db, user.ID, form.Email, clientIP, location, country, city, userAgent, deviceID, "password", false, "untrusted device", true); err != nil {
+			if err := models.RecordLoginHistoryWithAnomaly(db, user.ID, form.Email, clientIP, location, country, city, userAgent, deviceID, "password", false, "untrusted device", true, anomaly); err != nil {
 				logger.Warn("Failed to record login history for untrusted device", zap.Error(err))
 			}
 
@@ -765,25 +831,25 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 	}
 
 	// 13. 记录登录历史
-	if err := models.RecordLoginHistory(db, user.ID, form.Email, clientIP, location, country, city, userAgent, deviceID, "password", true, "", isSuspicious); err != nil {
+	if err := models.RecordLoginHistoryWithAnomaly(db, user.ID, form.Email, clientIP, location, country, city, userAgent, deviceID, "password", true, "", isSuspicious, anomaly); err != nil {
 		logger.Warn("Failed to record login history", zap.Error(err))
 	}
 
 	// 14. 发送新设备登录警告邮件
-	logger.Info("Checking new device login alert conditions",
+	logger.Debug("Checking new device login alert conditions",
 		zap.Bool("isTrusted", isTrusted),
 		zap.Bool("isSuspicious", isSuspicious),
 		zap.String("deviceID", deviceID))
 
 	if !isTrusted || isSuspicious {
-		logger.Info("Sending new device login alert signal",
+		logger.Debug("Sending new device login alert signal",
 			zap.String("email", user.Email),
 			zap.String("deviceID", deviceID),
 			zap.Bool("isTrusted", isTrusted),
 			zap.Bool("isSuspicious", isSuspicious))
 		utils.Sig().Emit(constants.SigUserNewDeviceLogin, user, "", db)
 	} else {
-		logger.Info("Skipping new device login alert - device is trusted and not suspicious",
+		logger.Debug("Skipping new device login alert - device is trusted and not suspicious",
 			zap.String("email", user.Email),
 			zap.String("deviceID", deviceID))
 	}
@@ -797,7 +863,7 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 	if user.TwoFactorEnabled {
 		// 如果提供了两步验证码，验证它
 		if form.TwoFactorCode != "" {
-			valid := totp.Validate(form.TwoFactorCode, user.TwoFactorSecret)
+			valid := h.verifyTwoFactorCode(user, form.TwoFactorCode)
 			if !valid {
 				response.Fail(c, "Invalid two-factor authentication code", errors.New("invalid 2fa code"))
 				return
@@ -832,20 +898,16 @@ func (h *Handlers) handleUserSigninByPassword(c *gin.Context) {
 		user = updatedUser // 使用更新后的用户信息
 	}
 
-	// 生成认证Token
-	val := utils.GetValue(db, constants.KEY_AUTH_TOKEN_EXPIRED) // 7d
-	expired, err := time.ParseDuration(val)
-	if err != nil {
-		logger.Warn("Failed to parse auth token expired duration, using default 7 days", zap.Error(err))
-		// 7 days
-		expired = 7 * 24 * time.Hour
+	// 生成访问令牌和刷新令牌
+	if !h.issueLoginTokens(c, db, user) {
+		return
 	}
-	user.AuthToken = models.BuildAuthToken(user, expired, false)
 
 	// 17. 返回登录结果（包含可疑登录警告）
 	responseData := gin.H{
-		"user":  user,
-		"token": user.AuthToken, // 为了兼容前端，同时返回token字段
+		"user":         user,
+		"token":        user.AuthToken, // 为了兼容前端，同时返回token字段
+		"refreshToken": user.RefreshToken,
 	}
 	if isSuspicious {
 		responseData["suspiciousLogin"] = true
@@ -900,12 +962,46 @@ func (h *Handlers) handleUserSignin(c *gin.Context) {
 		LingEcho.AbortWithJSONError(c, http.StatusForbidden, err)
 		return
 	}
+	if form.Password != "" && h.rejectForEnforcedSSO(c, user.Email, user) {
+		return
+	}
+	if h.rejectForSecurityPolicy(c, user) {
+		return
+	}
+
+	// 登录风险评分，与密码登录相同：block 直接拒绝，challenge 时未开启两步验证的密码登录改用邮箱验证码登录
+	clientIP := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	country, city, location := "Unknown", "Unknown", "Unknown"
+	if h.ipLocationService != nil {
+		country, city, location, _ = h.ipLocationService.GetLocation(clientIP)
+	}
+	deviceID := utils.GetDeviceID(userAgent, clientIP)
+	anomaly := h.scoreLoginAnomaly(c, db, user.ID, clientIP, deviceID, country, city)
+	if h.rejectForLoginRisk(c, db, user, anomaly, clientIP, location, country, city, userAgent, deviceID, "password") {
+		return
+	}
+	if anomaly != nil && anomaly.Decision == utils.LoginRiskChallenge && form.Password != "" && !user.TwoFactorEnabled {
+		if err := models.RecordLoginHistoryWithAnomaly(db, user.ID, user.Email, clientIP, location, country, city, userAgent, deviceID, "password", false, "risk challenge", true, anomaly); err != nil {
+			logger.Warn("Failed to record login history for risk challenge", zap.Error(err))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"msg":  "Email verification required",
+			"data": gin.H{
+				"requiresEmailVerification": true,
+				"riskChallenge":             true,
+				"message":                   "This sign-in looks unusual. Please verify with an email code.",
+			},
+		})
+		return
+	}
 
 	// 检查是否启用了两步验证
 	if user.TwoFactorEnabled {
 		// 如果提供了两步验证码，验证它
 		if form.TwoFactorCode != "" {
-			valid := totp.Validate(form.TwoFactorCode, user.TwoFactorSecret)
+			valid := h.verifyTwoFactorCode(user, form.TwoFactorCode)
 			if !valid {
 				LingEcho.AbortWithJSONError(c, http.StatusUnauthorized, errors.New("invalid 2fa code"))
 				return
@@ -930,14 +1026,8 @@ func (h *Handlers) handleUserSignin(c *gin.Context) {
 
 	models.Login(c, user)
 
-	if form.Remember {
-		val := utils.GetValue(db, constants.KEY_AUTH_TOKEN_EXPIRED) // 7d
-		expired, err := time.ParseDuration(val)
-		if err != nil {
-			// 7 days
-			expired = 7 * 24 * time.Hour
-		}
-		user.AuthToken = models.BuildAuthToken(user, expired, false)
+	if form.Remember && !h.issueLoginTokens(c, db, user) {
+		return
 	}
 	c.JSON(http.StatusOK, user)
 }
@@ -1011,9 +1101,9 @@ func (h *Handlers) handleUserSignup(c *gin.Context) {
 		}
 	}
 
-	// 3. 图形验证码验证
-	if captcha.GlobalCaptchaManager != nil {
-		if form.CaptchaID == "" || form.CaptchaCode == "" {
+	// 3. 人机验证（图形验证码或 Turnstile 等）
+	if captcha.Enabled() {
+		if form.CaptchaCode == "" {
 			if utils.GlobalRegistrationGuard != nil {
 				utils.GlobalRegistrationGuard.RecordRegistrationAttempt(clientIP, form.Email, false, "captcha required")
 			}
@@ -1021,7 +1111,7 @@ func (h *Handlers) handleUserSignup(c *gin.Context) {
 			return
 		}
 
-		valid, err := captcha.GlobalCaptchaManager.Verify(form.CaptchaID, form.CaptchaCode)
+		valid, err := captcha.Verify(c.Request.Context(), form.CaptchaID, form.CaptchaCode, clientIP)
 		if err != nil || !valid {
 			if utils.GlobalRegistrationGuard != nil {
 				utils.GlobalRegistrationGuard.RecordRegistrationAttempt(clientIP, form.Email, false, "invalid captcha")
@@ -1031,6 +1121,11 @@ func (h *Handlers) handleUserSignup(c *gin.Context) {
 		}
 	}
 
+	// 域名强制 SSO 时账号由 IdP 开通
+	if h.rejectForEnforcedSSO(c, form.Email, nil) {
+		return
+	}
+
 	// 4. 获取并发注册锁
 	lockAcquired, err := utils.AcquireRegistrationLock(form.Email)
 	if err != nil || !lockAcquired {
@@ -1149,6 +1244,9 @@ func (h *Handlers) handleUserSignupByEmail(c *gin.Context) {
 		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
 		return
 	}
+	if h.rejectForEnforcedSSO(c, form.Email, nil) {
+		return
+	}
 
 	form.Password, err = utils.SanitizeAndValidate(form.Password, "password")
 	if err != nil {
@@ -1172,9 +1270,9 @@ func (h *Handlers) handleUserSignupByEmail(c *gin.Context) {
 		}
 	}
 
-	// 2. 图形验证码验证
-	if captcha.GlobalCaptchaManager != nil {
-		if form.CaptchaID == "" || form.CaptchaCode == "" {
+	// 2. 人机验证（图形验证码或 Turnstile 等）
+	if captcha.Enabled() {
+		if form.CaptchaCode == "" {
 			if utils.GlobalRegistrationGuard != nil {
 				utils.GlobalRegistrationGuard.RecordRegistrationAttempt(clientIP, form.Email, false, "captcha required")
 			}
@@ -1182,7 +1280,7 @@ func (h *Handlers) handleUserSignupByEmail(c *gin.Context) {
 			return
 		}
 
-		valid, err := captcha.GlobalCaptchaManager.Verify(form.CaptchaID, form.CaptchaCode)
+		valid, err := captcha.Verify(c.Request.Context(), form.CaptchaID, form.CaptchaCode, clientIP)
 		if err != nil || !valid {
 			if utils.GlobalRegistrationGuard != nil {
 				utils.GlobalRegistrationGuard.RecordRegistrationAttempt(clientIP, form.Email, false, "invalid captcha")
@@ -1220,19 +1318,15 @@ func (h *Handlers) handleUserSignupByEmail(c *gin.Context) {
 		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, errors.New("email has exists"))
 		return
 	}
-	// 从缓存中获取验证码（假设你使用的是 util.GlobalCache）
-	cachedCode, ok := utils.GlobalCache.Get(form.Email)
-	if !ok || cachedCode != form.Code {
+	// 校验验证码，通过后即失效
+	if err := utils.GlobalEmailCodes.Verify(utils.CodePurposeRegister, form.Email, form.Code); err != nil {
 		if utils.GlobalRegistrationGuard != nil {
-			utils.GlobalRegistrationGuard.RecordRegistrationAttempt(clientIP, form.Email, false, "invalid verification code")
+			utils.GlobalRegistrationGuard.RecordRegistrationAttempt(clientIP, form.Email, false, err.Error())
 		}
-		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, errors.New("invalid verification code"))
+		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	// 清除已用验证码
-	utils.GlobalCache.Remove(form.Email)
-
 	// 处理加密密码：如果是加密格式，提取原始密码哈希
 	passwordToStore := form.Password
 	if strings.Contains(form.Password, ":") && len(strings.Split(form.Password, ":")) == 4 {
@@ -1289,8 +1383,10 @@ func (h *Handlers) handleUserUpdate(c *gin.Context) {
 	if req.Email != "" {
 		vals["email"] = req.Email
 	}
-	if req.Phone != "" {
-		vals["phone"] = req.Phone
+	if phone := models.NormalizePhone(req.Phone); phone != "" && phone != user.Phone {
+		// 更换手机号后需要重新验证
+		vals["phone"] = phone
+		vals["phone_verified"] = false
 	}
 	if req.FirstName != "" {
 		vals["first_name"] = req.FirstName
@@ -1453,12 +1549,18 @@ func (h *Handlers) handleChangePassword(c *gin.Context) {
 		return
 	}
 
+	if err := models.CheckPasswordPolicy(h.db, user, form.NewPassword); err != nil {
+		response.Fail(c, err.Error(), err)
+		return
+	}
+
 	if err := models.ChangePassword(h.db, user, oldPassword, form.NewPassword); err != nil {
 		response.Fail(c, "Change password failed", err)
 		return
 	}
 
-	// 修改密码成功后强制下线，要求重新登录
+	// 修改密码成功后强制下线，要求重新登录，已签发的刷新令牌一并作废
+	h.revokeTokensAfterPasswordChange(user)
 	models.Logout(c, user)
 	response.Success(c, "Password changed successfully", map[string]any{"logout": true})
 }
@@ -1502,15 +1604,21 @@ func (h *Handlers) handleChangePasswordByEmail(c *gin.Context) {
 		return
 	}
 
-	// 从缓存中获取验证码
-	cachedCode, ok := utils.GlobalCache.Get(user.Email)
-	if !ok || cachedCode != form.EmailCode {
-		response.Fail(c, "邮箱验证码无效或已过期", errors.New("invalid or expired email code"))
+	// 校验验证码，通过后即失效
+	if err := utils.GlobalEmailCodes.Verify(utils.CodePurposeChangePassword, user.Email, form.EmailCode); err != nil {
+		if errors.Is(err, utils.ErrCodeExhausted) {
+			response.Fail(c, "邮箱验证码错误次数过多，请重新获取", err)
+		} else {
+			response.Fail(c, "邮箱验证码无效或已过期", errors.New("invalid or expired email code"))
+		}
 		return
 	}
 
-	// 清除已用验证码
-	utils.GlobalCache.Remove(user.Email)
+	// 组织的密码规则
+	if err := models.CheckPasswordPolicy(h.db, user, form.NewPassword); err != nil {
+		response.Fail(c, err.Error(), err)
+		return
+	}
 
 	// 设置新密码（不验证旧密码）
 	err := models.SetPassword(h.db, user, form.NewPassword)
@@ -1531,7 +1639,8 @@ func (h *Handlers) handleChangePasswordByEmail(c *gin.Context) {
 
 	user.LastPasswordChange = &now
 
-	// 修改密码成功后强制下线，要求重新登录
+	// 修改密码成功后强制下线，要求重新登录，已签发的刷新令牌一并作废
+	h.revokeTokensAfterPasswordChange(user)
 	models.Logout(c, user)
 	response.Success(c, "密码修改成功", map[string]any{"logout": true})
 }
@@ -1645,16 +1754,12 @@ func (h *Handlers) handleVerifyDeviceForLogin(c *gin.Context) {
 
 	db := c.MustGet(constants.DbField).(*gorm.DB)
 
-	// 验证邮箱验证码
-	cachedCode, ok := utils.GlobalCache.Get(form.Email + ":device_verify")
-	if !ok || cachedCode != form.VerifyCode {
-		response.Fail(c, "验证码无效或已过期", errors.New("invalid or expired verification code"))
+	// 验证邮箱验证码，验证码只能信任发送时指定的设备，通过后即失效
+	if err := utils.GlobalEmailCodes.Verify(utils.DeviceVerifyPurpose(form.DeviceID), form.Email, form.VerifyCode); err != nil {
+		response.Fail(c, "验证码无效或已过期", err)
 		return
 	}
 
-	// 清除验证码
-	utils.GlobalCache.Remove(form.Email + ":device_verify")
-
 	// 获取用户
 	user, err := models.GetUserByEmail(db, form.Email)
 	if err != nil {
@@ -1699,17 +1804,22 @@ func (h *Handlers) handleSendDeviceVerificationCode(c *gin.Context) {
 	}
 
 	// 生成验证码
-	code := utils.RandNumberText(6)
-	cacheKey := form.Email + ":device_verify"
-	utils.GlobalCache.Add(cacheKey, code)
+	purpose := utils.DeviceVerifyPurpose(form.DeviceID)
+	code, err := utils.GlobalEmailCodes.Issue(purpose, user.Email, c.ClientIP())
+	if err != nil {
+		abortCodeCooldown(c, err)
+		return
+	}
 
 	// 发送邮件
-	go func() {
-		err := notification.NewMailNotificationWithDB(config.GlobalConfig.Services.Mail, db, user.ID).SendDeviceVerificationCode(user.Email, user.DisplayName, code, form.DeviceID)
-		if err != nil {
-			logger.Error("Failed to send device verification email", zap.Error(err), zap.String("email", user.Email))
-		}
-	}()
+	mailConfig := models.RequestMailConfig(c, config.GlobalConfig.Services.Mail)
+	err = notification.NewMailNotificationWithDB(mailConfig, db, user.ID).SendDeviceVerificationCode(user.Email, user.DisplayName, code, form.DeviceID)
+	if err != nil {
+		logger.Error("Failed to send device verification email", zap.Error(err), zap.String("email", user.Email))
+		utils.GlobalEmailCodes.Revoke(purpose, user.Email)
+		response.Fail(c, "Failed to send verification code", err)
+		return
+	}
 
 	logger.Info("Device verification code sent",
 		zap.Uint("userID", user.ID),
@@ -1766,11 +1876,17 @@ func (h *Handlers) handleResetPasswordConfirm(c *gin.Context) {
 		return
 	}
 
+	if err := models.CheckPasswordPolicy(h.db, user, form.Password); err != nil {
+		response.Fail(c, err.Error(), err)
+		return
+	}
+
 	err = models.ResetPassword(h.db, user, form.Password)
 	if err != nil {
 		response.Fail(c, "Reset password failed", err)
 		return
 	}
+	h.revokeTokensAfterPasswordChange(user)
 
 	response.Success(c, "Password reset successfully", nil)
 }
@@ -1792,6 +1908,25 @@ func (h *Handlers) handleVerifyEmail(c *gin.Context) {
 	response.Success(c, "Email verified successfully", user)
 }
 
+// handleGetEmailVerificationPolicy 返回需要验证邮箱的操作以及当前用户受限的操作，
+// 前端据此提前提示用户验证邮箱
+func (h *Handlers) handleGetEmailVerificationPolicy(c *gin.Context) {
+	user := models.CurrentUser(c)
+	required := models.EmailVerificationRequiredActions(h.db)
+	blocked := make([]string, 0, len(required))
+	for _, action := range required {
+		if !models.EmailVerificationAllowed(h.db, user, action) {
+			blocked = append(blocked, action)
+		}
+	}
+	response.Success(c, "success", gin.H{
+		"emailVerified":   user.EmailVerified,
+		"requiredActions": required,
+		"blockedActions":  blocked,
+		"actions":         models.EmailVerificationActions,
+	})
+}
+
 // handleSendEmailVerification 发送邮箱验证邮件
 func (h *Handlers) handleSendEmailVerification(c *gin.Context) {
 	user := models.CurrentUser(c)
@@ -1848,11 +1983,14 @@ func (h *Handlers) handleVerifyPhone(c *gin.Context) {
 		return
 	}
 
-	err := models.VerifyPhone(h.db, user, form.Code)
-	if err != nil {
+	if err := utils.GlobalSMSCodes.Verify(utils.CodePurposePhoneVerify, models.NormalizePhone(user.Phone), form.Code); err != nil {
 		response.Fail(c, "Invalid verification code", err)
 		return
 	}
+	if err := models.MarkPhoneVerified(h.db, user); err != nil {
+		response.Fail(c, "Failed to verify phone", err)
+		return
+	}
 
 	response.Success(c, "Phone verified successfully", nil)
 }
@@ -1895,17 +2033,12 @@ func (h *Handlers) handleSendPhoneVerification(c *gin.Context) {
 		return
 	}
 
-	token, err := models.GeneratePhoneVerifyToken(h.db, user)
-	if err != nil {
-		response.Fail(c, "Failed to generate verification code", err)
+	// 通过短信服务发送验证码，未配置短信服务时只记录日志
+	if !h.issueSMSCode(c, utils.CodePurposePhoneVerify, models.NormalizePhone(user.Phone)) {
 		return
 	}
 
-	// 这里可以集成短信服务发送验证码
-	// 目前只是记录日志
-	logger.Info("Phone verification code", zap.String("phone", user.Phone), zap.String("code", token))
-
-	response.Success(c, "Verification code sent", nil)
+	response.Success(c, "Verification code sent", codeLimits(utils.GlobalSMSCodes))
 }
 
 // handleUpdateNotificationSettings 更新通知设置
@@ -1932,6 +2065,85 @@ func (h *Handlers) handleUpdateNotificationSettings(c *gin.Context) {
 	response.Success(c, "Notification settings updated successfully", nil)
 }
 
+// notificationPreferencesRequest structured notification preferences
+type notificationPreferencesRequest struct {
+	Channels          map[models.NotificationEvent][]models.NotificationChannel `json:"channels"`
+	QuietHoursEnabled bool                                                      `json:"quietHoursEnabled"`
+	QuietStart        string                                                    `json:"quietStart"`
+	QuietEnd          string                                                    `json:"quietEnd"`
+	QuietTimezone     string                                                    `json:"quietTimezone"`
+	CriticalOverride  bool                                                      `json:"criticalOverride"`
+	WebhookURL        string                                                    `json:"webhookUrl"`
+	WebhookSecret     *string                                                   `json:"webhookSecret"` // Keeps the current secret when omitted
+}
+
+// handleGetNotificationPreferences 获取结构化通知偏好
+func (h *Handlers) handleGetNotificationPreferences(c *gin.Context) {
+	user := models.CurrentUser(c)
+	pref, err := models.GetNotificationPreference(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "Get notification preferences failed", err)
+		return
+	}
+	response.Success(c, "success", notificationPreferencesView(pref))
+}
+
+// handleUpdateNotificationPreferences 更新结构化通知偏好
+func (h *Handlers) handleUpdateNotificationPreferences(c *gin.Context) {
+	var req notificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+
+	user := models.CurrentUser(c)
+	pref, err := models.GetNotificationPreference(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "Get notification preferences failed", err)
+		return
+	}
+	if req.Channels != nil {
+		if err := pref.SetChannelMap(req.Channels); err != nil {
+			response.Fail(c, "Invalid notification channels", err.Error())
+			return
+		}
+	}
+	pref.QuietHoursEnabled = req.QuietHoursEnabled
+	pref.QuietStart = req.QuietStart
+	pref.QuietEnd = req.QuietEnd
+	pref.QuietTimezone = req.QuietTimezone
+	pref.CriticalOverride = req.CriticalOverride
+	if req.WebhookURL != "" {
+		if err := webhook.ValidateURL(c.Request.Context(), req.WebhookURL); err != nil {
+			response.Fail(c, "Invalid webhook url", err.Error())
+			return
+		}
+	}
+	pref.WebhookURL = req.WebhookURL
+	if req.WebhookSecret != nil {
+		pref.WebhookSecret = *req.WebhookSecret
+	}
+	if err := models.SaveNotificationPreference(h.db, pref); err != nil {
+		response.Fail(c, "Update notification preferences failed", err.Error())
+		return
+	}
+	response.Success(c, "Notification preferences updated successfully", notificationPreferencesView(pref))
+}
+
+func notificationPreferencesView(pref *models.NotificationPreference) gin.H {
+	return gin.H{
+		"channels":          pref.ChannelMap(),
+		"events":            models.NotificationEvents,
+		"quietHoursEnabled": pref.QuietHoursEnabled,
+		"quietStart":        pref.QuietStart,
+		"quietEnd":          pref.QuietEnd,
+		"quietTimezone":     pref.QuietTimezone,
+		"criticalOverride":  pref.CriticalOverride,
+		"webhookUrl":        pref.WebhookURL,
+		"webhookSecretSet":  pref.WebhookSecret != "",
+	}
+}
+
 // handleUpdateUserPreferences 更新用户偏好设置
 func (h *Handlers) handleUpdateUserPreferences(c *gin.Context) {
 	var preferences map[string]string
@@ -2149,19 +2361,54 @@ func (h *Handlers) handleSendEmailCode(context *gin.Context) {
 	}
 	req.UserAgent = context.Request.UserAgent()
 	req.ClientIp = context.ClientIP()
-	text := utils.RandNumberText(6)
-	utils.GlobalCache.Add(req.Email, text)
-	go func() {
-		// Use IP address for tracking since no user context
-		mailNotif := notification.NewMailNotificationWithIP(config.GlobalConfig.Services.Mail, h.db, req.ClientIp)
-		err := mailNotif.SendVerificationCode(req.Email, text)
-		if err != nil {
-			LingEcho.AbortWithJSONError(context, http.StatusBadRequest, err)
-			return
-		}
-	}()
-	response.Success(context, "success", "Send Email Successful, Must be verified within the valid time [5 minutes]")
-	return
+	// 验证码只能用于申请时的用途，未指定时用于登录
+	switch req.Purpose {
+	case "":
+		req.Purpose = utils.CodePurposeLogin
+	case utils.CodePurposeLogin, utils.CodePurposeRegister, utils.CodePurposeChangePassword:
+	default:
+		LingEcho.AbortWithJSONError(context, http.StatusBadRequest, fmt.Errorf("unknown verification code purpose %q", req.Purpose))
+		return
+	}
+	codes := utils.GlobalEmailCodes
+	text, err := codes.Issue(req.Purpose, req.Email, req.ClientIp)
+	if err != nil {
+		abortCodeCooldown(context, err)
+		return
+	}
+	mailConfig := models.RequestMailConfig(context, config.GlobalConfig.Services.Mail)
+	// 邮件进入任务队列，由后台重试投递；没有用户上下文，按 IP 记录
+	mailNotif := notification.NewMailNotificationWithIP(mailConfig, h.db, req.ClientIp)
+	if err := mailNotif.SendVerificationCode(req.Email, text); err != nil {
+		logger.Warn("Failed to send email verification code", zap.String("ip", req.ClientIp), zap.Error(err))
+		codes.Revoke(req.Purpose, req.Email)
+		response.Fail(context, "Failed to send verification code", err)
+		return
+	}
+	response.Success(context, "Send Email Successful, Must be verified within the valid time [5 minutes]", codeLimits(codes))
+}
+
+// abortCodeCooldown 验证码发送过于频繁时返回 429 和剩余等待时间，其他错误返回 400
+func abortCodeCooldown(c *gin.Context, err error) {
+	var cooldown *utils.CodeCooldownError
+	if errors.As(err, &cooldown) {
+		c.Header("Retry-After", strconv.Itoa(cooldown.RetrySeconds()))
+		response.Result(c, http.StatusTooManyRequests, http.StatusTooManyRequests, err.Error(), gin.H{
+			"scope":             cooldown.Scope,
+			"retryAfterSeconds": cooldown.RetrySeconds(),
+		})
+		return
+	}
+	LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
+}
+
+// codeLimits 告知客户端验证码的有效期、重发间隔和可校验次数
+func codeLimits(codes *utils.VerificationCodeService) gin.H {
+	return gin.H{
+		"expiresInSeconds":  int(codes.CodeTTL / time.Second),
+		"cooldownSeconds":   int(codes.RecipientCooldown / time.Second),
+		"maxVerifyAttempts": codes.MaxFailures,
+	}
 }
 
 // handleTwoFactorSetup 设置两步验证
@@ -2254,7 +2501,16 @@ func (h *Handlers) handleTwoFactorEnable(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, "Two-factor authentication enabled successfully", nil)
+	// 生成备用码，明文只返回这一次
+	codes, err := models.GenerateTwoFactorBackupCodes(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "Failed to generate backup codes", err)
+		return
+	}
+
+	response.Success(c, "Two-factor authentication enabled successfully", gin.H{
+		"backupCodes": codes,
+	})
 }
 
 // handleTwoFactorDisable 禁用两步验证
@@ -2273,8 +2529,8 @@ func (h *Handlers) handleTwoFactorDisable(c *gin.Context) {
 		return
 	}
 
-	// 验证TOTP代码
-	valid := totp.Validate(req.Code, user.TwoFactorSecret)
+	// 验证TOTP代码或备用码
+	valid := h.verifyTwoFactorCode(user, req.Code)
 	if !valid {
 		response.Fail(c, "Invalid verification code", errors.New("invalid code"))
 		return
@@ -2289,6 +2545,9 @@ func (h *Handlers) handleTwoFactorDisable(c *gin.Context) {
 		response.Fail(c, "Failed to disable two-factor authentication", err)
 		return
 	}
+	if err := models.DeleteTwoFactorBackupCodes(h.db, user.ID); err != nil {
+		logger.Warn("Failed to delete two-factor backup codes", zap.Uint("userID", user.ID), zap.Error(err))
+	}
 
 	response.Success(c, "Two-factor authentication disabled successfully", nil)
 }
@@ -2301,14 +2560,76 @@ func (h *Handlers) handleTwoFactorStatus(c *gin.Context) {
 		return
 	}
 
+	remaining, _ := models.CountTwoFactorBackupCodes(h.db, user.ID)
 	response.Success(c, "Two-factor status retrieved", gin.H{
-		"enabled":   user.TwoFactorEnabled,
-		"hasSecret": user.TwoFactorSecret != "",
+		"enabled":              user.TwoFactorEnabled,
+		"hasSecret":            user.TwoFactorSecret != "",
+		"backupCodesRemaining": remaining,
 	})
 }
 
-// handleGetCaptcha 获取图形验证码
+// handleTwoFactorRegenerateBackupCodes 重新生成备用码，之前的备用码全部作废
+func (h *Handlers) handleTwoFactorRegenerateBackupCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not found", errors.New("user not found"))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		response.Fail(c, "Two-factor authentication is not enabled", errors.New("two-factor not enabled"))
+		return
+	}
+
+	// 验证TOTP代码或备用码，防止仅凭会话就能生成新的备用码
+	if !h.verifyTwoFactorCode(user, req.Code) {
+		response.Fail(c, "Invalid verification code", errors.New("invalid code"))
+		return
+	}
+
+	codes, err := models.GenerateTwoFactorBackupCodes(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "Failed to generate backup codes", err)
+		return
+	}
+	response.Success(c, "Backup codes regenerated", gin.H{
+		"backupCodes": codes,
+	})
+}
+
+// verifyTwoFactorCode 校验 TOTP 验证码，不匹配时尝试核销一个备用码
+func (h *Handlers) verifyTwoFactorCode(user *models.User, code string) bool {
+	if totp.Validate(code, user.TwoFactorSecret) {
+		return true
+	}
+	used, err := models.UseTwoFactorBackupCode(h.db, user.ID, code, time.Now())
+	if err != nil {
+		logger.Warn("Failed to check two-factor backup code", zap.Uint("userID", user.ID), zap.Error(err))
+		return false
+	}
+	if used {
+		remaining, _ := models.CountTwoFactorBackupCodes(h.db, user.ID)
+		logger.Info("Two-factor backup code used", zap.Uint("userID", user.ID), zap.Int64("remaining", remaining))
+	}
+	return used
+}
+
+// handleGetCaptcha 获取图形验证码，使用 Turnstile 等服务时返回前端组件需要的站点密钥
 func (h *Handlers) handleGetCaptcha(c *gin.Context) {
+	if provider := captcha.GlobalCaptchaProvider; provider != nil && provider.Name() != captcha.ProviderImage {
+		response.Success(c, "Captcha provider", gin.H{
+			"provider": provider.Name(),
+			"siteKey":  provider.SiteKey(),
+		})
+		return
+	}
 	if captcha.GlobalCaptchaManager == nil {
 		response.Fail(c, "Captcha service not available", errors.New("captcha service not initialized"))
 		return
@@ -2320,15 +2641,16 @@ func (h *Handlers) handleGetCaptcha(c *gin.Context) {
 		return
 	}
 	response.Success(c, "Captcha generated", gin.H{
-		"id":    capt.ID,
-		"image": capt.Image,
+		"provider": captcha.ProviderImage,
+		"id":       capt.ID,
+		"image":    capt.Image,
 	})
 }
 
 // handleVerifyCaptcha 验证图形验证码
 func (h *Handlers) handleVerifyCaptcha(c *gin.Context) {
 	var req struct {
-		ID   string `json:"id" binding:"required"`
+		ID   string `json:"id"`
 		Code string `json:"code" binding:"required"`
 	}
 
@@ -2337,12 +2659,12 @@ func (h *Handlers) handleVerifyCaptcha(c *gin.Context) {
 		return
 	}
 
-	if captcha.GlobalCaptchaManager == nil {
+	if !captcha.Enabled() {
 		response.Fail(c, "Captcha service not available", errors.New("captcha service not initialized"))
 		return
 	}
 
-	valid, err := captcha.GlobalCaptchaManager.Verify(req.ID, req.Code)
+	valid, err := captcha.Verify(c.Request.Context(), req.ID, req.Code, c.ClientIP())
 	if err != nil {
 		response.Fail(c, "Failed to verify captcha", err)
 		return
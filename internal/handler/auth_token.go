@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// tokenLifetimes returns how long access tokens and refresh tokens stay valid.
+// AUTH_TOKEN_EXPIRED keeps its meaning as the lifetime of a login, now carried by the refresh token.
+func tokenLifetimes(db *gorm.DB) (time.Duration, time.Duration) {
+	access, err := time.ParseDuration(utils.GetValue(db, constants.KEY_ACCESS_TOKEN_EXPIRED))
+	if err != nil || access <= 0 {
+		access = models.DefaultAccessTokenTTL
+	}
+	refresh, err := time.ParseDuration(utils.GetValue(db, constants.KEY_AUTH_TOKEN_EXPIRED))
+	if err != nil || refresh <= 0 {
+		refresh = models.DefaultRefreshTokenTTL
+	}
+	return access, refresh
+}
+
+// issueLoginTokens signs a short-lived access token and a rotating refresh token for a
+// successful login and sets them on the user. If the refresh token can't be stored the
+// login fails rather than handing out a token that can't be rotated or revoked; the
+// error response is written and false returned.
+func (h *Handlers) issueLoginTokens(c *gin.Context, db *gorm.DB, user *models.User) bool {
+	access, refresh := tokenLifetimes(db)
+	pair, err := models.IssueTokenPair(db, user, access, refresh, c.ClientIP(), c.Request.UserAgent(), time.Now())
+	if err != nil {
+		logger.Error("Failed to issue login tokens", zap.Uint("userID", user.ID), zap.Error(err))
+		models.Logout(c, user)
+		response.Fail(c, "login failed", "failed to issue token")
+		return false
+	}
+	user.AuthToken = pair.AccessToken
+	user.RefreshToken = pair.RefreshToken
+	return true
+}
+
+// requestAccessClaims decodes the bearer token of the request, nil for session logins
+func (h *Handlers) requestAccessClaims(c *gin.Context) *models.AccessClaims {
+	token := c.GetHeader(config.GlobalConfig.Auth.Header)
+	if token == "" {
+		token = c.Query("token")
+	}
+	if token == "" {
+		return nil
+	}
+	_, claims, err := models.DecodeAccessToken(h.db, strings.TrimPrefix(token, constants.AUTHORIZATION_PREFIX), false)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+// revokeTokensAfterPasswordChange revokes the user's refresh tokens, which would otherwise
+// keep issuing access tokens after the password changed
+func (h *Handlers) revokeTokensAfterPasswordChange(user *models.User) {
+	if err := models.RevokeUserTokens(h.db, user.ID, "password_change", time.Now()); err != nil {
+		logger.Warn("Failed to revoke tokens after password change", zap.Uint("userID", user.ID), zap.Error(err))
+	}
+}
+
+// RefreshAuthToken exchanges a refresh token for a new access token and refresh token.
+// The presented refresh token stops working; presenting it again revokes the whole login.
+// POST /auth/token/refresh
+func (h *Handlers) RefreshAuthToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", "refreshToken is required")
+		return
+	}
+	access, refresh := tokenLifetimes(h.db)
+	pair, user, err := models.RotateRefreshToken(h.db, req.RefreshToken, access, refresh, c.ClientIP(), c.Request.UserAgent(), time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrRefreshTokenReused) {
+			logger.Warn("Refresh token reuse detected, login revoked", zap.String("ip", c.ClientIP()))
+		}
+		response.Fail(c, "refresh failed", err.Error())
+		return
+	}
+	if err := models.CheckUserAllowLogin(h.db, user); err != nil {
+		_ = models.RevokeRefreshToken(h.db, user.ID, pair.RefreshToken, "login_disabled", time.Now())
+		response.Fail(c, "refresh failed", err.Error())
+		return
+	}
+	response.Success(c, "success", pair)
+}
+
+// RevokeAuthToken signs out the token of the request. With refreshToken the login it
+// belongs to is revoked too; with all every access and refresh token of the user is.
+// POST /auth/token/revoke
+func (h *Handlers) RevokeAuthToken(c *gin.Context) {
+	user := models.CurrentUser(c)
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+		All          bool   `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	now := time.Now()
+	if req.All {
+		if err := models.RevokeUserTokens(h.db, user.ID, "user_revoke_all", now); err != nil {
+			response.Fail(c, "revoke failed", err.Error())
+			return
+		}
+		response.Success(c, "All tokens revoked", nil)
+		return
+	}
+	if req.RefreshToken != "" {
+		if err := models.RevokeRefreshToken(h.db, user.ID, req.RefreshToken, "user_revoke", now); err != nil {
+			response.Fail(c, "revoke failed", err.Error())
+			return
+		}
+	}
+	if claims := h.requestAccessClaims(c); claims != nil {
+		if err := models.RevokeAccessToken(h.db, user.ID, claims, "user_revoke", now); err != nil {
+			response.Fail(c, "revoke failed", err.Error())
+			return
+		}
+	}
+	response.Success(c, "Token revoked", nil)
+}
+
+// AdminRevokeUserTokens invalidates every access and refresh token of a user at once,
+// e.g. when a token has leaked (admin)
+// POST /auth/token/revoke-user/:id
+func (h *Handlers) AdminRevokeUserTokens(c *gin.Context) {
+	admin := models.CurrentUser(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid request", "invalid user id")
+		return
+	}
+	target, err := models.GetUserByUID(h.db, uint(id))
+	if err != nil {
+		response.Fail(c, "not found", "User does not exist")
+		return
+	}
+	if err := models.RevokeUserTokens(h.db, target.ID, "admin_revoke", time.Now()); err != nil {
+		response.Fail(c, "revoke failed", err.Error())
+		return
+	}
+	logger.Info("Admin revoked all tokens of user", zap.Uint("adminID", admin.ID), zap.Uint("userID", target.ID))
+	response.Success(c, "All tokens of the user revoked", nil)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/policy"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// policyEvaluateRequest dry-run evaluation of an authorization request
+type policyEvaluateRequest struct {
+	UserID     *uint                `json:"userId"`  // Builds the subject from the user profile
+	Subject    *policy.Subject      `json:"subject"` // Used when userId is not set
+	Resource   string               `json:"resource" binding:"required"`
+	Action     string               `json:"action" binding:"required"`
+	Object     map[string]any       `json:"object"`
+	Env        map[string]any       `json:"env"`
+	Candidates []models.AuthzPolicy `json:"candidates"` // Unsaved policies evaluated together with the enabled ones
+}
+
+// ListAuthzPolicies lists the authorization policies
+// GET /authz/policies
+func (h *Handlers) ListAuthzPolicies(c *gin.Context) {
+	var policies []models.AuthzPolicy
+	if err := h.db.Order("priority DESC, id").Find(&policies).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"engine":   h.policyEngineName(),
+		"policies": policies,
+	})
+}
+
+// GetAuthzPolicy gets an authorization policy
+// GET /authz/policies/:id
+func (h *Handlers) GetAuthzPolicy(c *gin.Context) {
+	p, ok := h.loadAuthzPolicy(c)
+	if !ok {
+		return
+	}
+	response.Success(c, "success", p)
+}
+
+// CreateAuthzPolicy creates an authorization policy
+// POST /authz/policies
+func (h *Handlers) CreateAuthzPolicy(c *gin.Context) {
+	var p models.AuthzPolicy
+	if err := c.ShouldBindJSON(&p); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	p.ID = 0
+	p.CreatedBy = models.CurrentUser(c).ID
+	if err := p.Validate(); err != nil {
+		response.Fail(c, "invalid policy", err.Error())
+		return
+	}
+	if err := h.db.Create(&p).Error; err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	models.InvalidatePolicies()
+	response.Success(c, "created", p)
+}
+
+// UpdateAuthzPolicy replaces an authorization policy
+// PUT /authz/policies/:id
+func (h *Handlers) UpdateAuthzPolicy(c *gin.Context) {
+	existing, ok := h.loadAuthzPolicy(c)
+	if !ok {
+		return
+	}
+	var p models.AuthzPolicy
+	if err := c.ShouldBindJSON(&p); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	p.ID, p.CreatedAt, p.CreatedBy = existing.ID, existing.CreatedAt, existing.CreatedBy
+	if err := p.Validate(); err != nil {
+		response.Fail(c, "invalid policy", err.Error())
+		return
+	}
+	if err := h.db.Save(&p).Error; err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	models.InvalidatePolicies()
+	response.Success(c, "updated", p)
+}
+
+// DeleteAuthzPolicy deletes an authorization policy
+// DELETE /authz/policies/:id
+func (h *Handlers) DeleteAuthzPolicy(c *gin.Context) {
+	p, ok := h.loadAuthzPolicy(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(p).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	models.InvalidatePolicies()
+	response.Success(c, "deleted", nil)
+}
+
+// EvaluateAuthzPolicy dry-runs an authorization request without enforcing it.
+// Candidate policies, or the enabled policies when no engine is configured yet,
+// are evaluated by the built-in rule engine; otherwise the configured engine is used.
+// POST /authz/evaluate
+func (h *Handlers) EvaluateAuthzPolicy(c *gin.Context) {
+	var req policyEvaluateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	in := policy.Input{Resource: req.Resource, Action: req.Action, Object: req.Object, Env: req.Env}
+	switch {
+	case req.UserID != nil:
+		user, err := models.GetUserByUID(h.db, *req.UserID)
+		if err != nil {
+			response.Fail(c, "user not found", err.Error())
+			return
+		}
+		in.Subject = models.PolicySubject(h.db, user)
+	case req.Subject != nil:
+		in.Subject = *req.Subject
+	default:
+		response.Fail(c, "invalid request", "userId or subject is required")
+		return
+	}
+
+	engine := models.CurrentPolicyEngine()
+	if engine == nil || len(req.Candidates) > 0 {
+		rules, err := models.LoadAuthzRules(h.db)
+		if err != nil {
+			response.Fail(c, "query failed", err.Error())
+			return
+		}
+		for i := range req.Candidates {
+			candidate := &req.Candidates[i]
+			rule, err := candidate.Rule()
+			if err != nil {
+				response.Fail(c, "invalid candidate policy", gin.H{"index": i, "error": err.Error()})
+				return
+			}
+			rule.ID = "candidate-" + strconv.Itoa(i)
+			rules = append(rules, rule)
+		}
+		engine = policy.NewRuleEngine(rules)
+	}
+
+	decision, err := engine.Evaluate(c.Request.Context(), in)
+	if err != nil {
+		response.Fail(c, "evaluation failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"allowed":  decision.Allowed(),
+		"decision": decision,
+		"input":    in,
+		"enforced": h.policyEngineName(),
+	})
+}
+
+// policyEngineName is the engine enforcing policies, empty when only RBAC applies
+func (h *Handlers) policyEngineName() string {
+	if engine := models.CurrentPolicyEngine(); engine != nil {
+		return engine.Name()
+	}
+	return ""
+}
+
+func (h *Handlers) loadAuthzPolicy(c *gin.Context) (*models.AuthzPolicy, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid policy id", nil)
+		return nil, false
+	}
+	var p models.AuthzPolicy
+	if err := h.db.First(&p, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "policy not found", nil)
+		} else {
+			response.Fail(c, "query failed", err.Error())
+		}
+		return nil, false
+	}
+	return &p, true
+}
@@ -0,0 +1,572 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/calendar"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	calendarOAuthStatePrefix = "calendar_oauth_state:"
+	calendarOAuthStateTTL    = 10 * time.Minute
+	calendarToolTimeout      = 15 * time.Second
+)
+
+// ListCalendarCredentials lists calendar accounts connected by the current user
+// GET /calendar/credentials
+func (h *Handlers) ListCalendarCredentials(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	creds, err := models.ListCalendarCredentials(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", creds)
+}
+
+// CreateCalDAVCredential connects a CalDAV calendar, the credentials are verified first
+// POST /calendar/caldav
+func (h *Handlers) CreateCalDAVCredential(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	var req struct {
+		Name     string `json:"name"`
+		URL      string `json:"url" binding:"required"`
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	if err := webhook.ValidateURL(c.Request.Context(), req.URL); err != nil {
+		response.Fail(c, "invalid calendar url", err.Error())
+		return
+	}
+	provider := calendar.NewCalDAVProvider(req.URL, req.Username, req.Password)
+	now := time.Now()
+	if _, err := provider.FreeBusy(c.Request.Context(), now, now.Add(time.Hour)); err != nil {
+		response.Fail(c, "failed to connect calendar", err.Error())
+		return
+	}
+
+	cred := &models.CalendarCredential{
+		UserID:    user.ID,
+		Provider:  calendar.ProviderCalDAV,
+		Name:      req.Name,
+		CalDAVURL: provider.URL,
+		Username:  req.Username,
+		Password:  req.Password,
+	}
+	if strings.Contains(req.Username, "@") {
+		cred.AccountEmail = req.Username
+	}
+	if cred.Name == "" {
+		cred.Name = req.Username
+	}
+	if err := h.db.Create(cred).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "calendar connected", cred)
+}
+
+// GoogleCalendarAuthorize returns the Google consent URL for the current user
+// GET /calendar/google/authorize
+func (h *Handlers) GoogleCalendarAuthorize(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	cfg, ok := googleCalendarOAuthConfig()
+	if !ok {
+		response.Fail(c, "google calendar is not configured", nil)
+		return
+	}
+
+	state := utils.RandString(32)
+	if err := cache.GetGlobalCache().Set(c.Request.Context(), calendarOAuthStatePrefix+state, strconv.FormatUint(uint64(user.ID), 10), calendarOAuthStateTTL); err != nil {
+		response.Fail(c, "failed to start authorization", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"url": calendar.GoogleAuthURL(cfg, state)})
+}
+
+// GoogleCalendarCallback completes the OAuth flow and stores the tokens.
+// The user is identified by the state issued in GoogleCalendarAuthorize.
+// GET /calendar/google/callback
+func (h *Handlers) GoogleCalendarCallback(c *gin.Context) {
+	if errMsg := c.Query("error"); errMsg != "" {
+		response.Fail(c, "authorization denied", errMsg)
+		return
+	}
+
+	state := c.Query("state")
+	stateCache := cache.GetGlobalCache()
+	value, ok := stateCache.Get(c.Request.Context(), calendarOAuthStatePrefix+state)
+	if state == "" || !ok {
+		response.Fail(c, "invalid or expired state", nil)
+		return
+	}
+	_ = stateCache.Delete(c.Request.Context(), calendarOAuthStatePrefix+state)
+	// Stored as a string so it survives the JSON round trip of the redis cache
+	stateValue, _ := value.(string)
+	parsedID, err := strconv.ParseUint(stateValue, 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid or expired state", nil)
+		return
+	}
+	userID := uint(parsedID)
+
+	cfg, ok := googleCalendarOAuthConfig()
+	if !ok {
+		response.Fail(c, "google calendar is not configured", nil)
+		return
+	}
+	token, err := cfg.Exchange(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		response.Fail(c, "failed to exchange authorization code", err.Error())
+		return
+	}
+
+	email := googleAccountEmail(c.Request.Context(), cfg, token)
+	cred := &models.CalendarCredential{
+		UserID:       userID,
+		Provider:     calendar.ProviderGoogle,
+		Name:         email,
+		AccountEmail: email,
+		CalendarID:   "primary",
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+	}
+	if !token.Expiry.IsZero() {
+		cred.TokenExpiry = &token.Expiry
+	}
+	if cred.Name == "" {
+		cred.Name = "Google Calendar"
+	}
+
+	// Reconnecting the same account replaces its tokens instead of adding a duplicate
+	var existing models.CalendarCredential
+	if email != "" && h.db.Where("user_id = ? AND provider = ? AND account_email = ?", userID, calendar.ProviderGoogle, email).First(&existing).Error == nil {
+		cred.ID = existing.ID
+		cred.CreatedAt = existing.CreatedAt
+		cred.CalendarID = existing.CalendarID
+	}
+	if err := h.db.Save(cred).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "calendar connected", cred)
+}
+
+// DeleteCalendarCredential disconnects a calendar account
+// DELETE /calendar/credentials/:id
+func (h *Handlers) DeleteCalendarCredential(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid id", nil)
+		return
+	}
+	if err := models.DeleteCalendarCredential(h.db, user.ID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "calendar not found", nil)
+		} else {
+			response.Fail(c, "delete failed", err.Error())
+		}
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// GetAssistantCalendar gets the calendar binding and scopes of an assistant
+// GET /assistant/:id/calendar
+func (h *Handlers) GetAssistantCalendar(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+
+	binding, err := models.GetAssistantCalendar(h.db, assistant.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", binding)
+}
+
+// SaveAssistantCalendar binds a calendar to an assistant and sets the tool scopes
+// PUT /assistant/:id/calendar
+func (h *Handlers) SaveAssistantCalendar(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		CredentialID    uint     `json:"credentialId" binding:"required"`
+		Scopes          []string `json:"scopes"`
+		Timezone        string   `json:"timezone"`
+		DefaultDuration int      `json:"defaultDuration"`
+		WorkdayStart    string   `json:"workdayStart"`
+		WorkdayEnd      string   `json:"workdayEnd"`
+		Enabled         *bool    `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	if _, err := models.GetCalendarCredential(h.db, assistant.UserID, req.CredentialID); err != nil {
+		response.Fail(c, "calendar not found", nil)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if scope != calendar.ScopeAvailability && scope != calendar.ScopeCreateEvent && scope != calendar.ScopeSendInvite {
+			response.Fail(c, "invalid scope", scope)
+			return
+		}
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			response.Fail(c, "invalid timezone", req.Timezone)
+			return
+		}
+	}
+	for _, v := range []string{req.WorkdayStart, req.WorkdayEnd} {
+		if v != "" {
+			if _, err := time.Parse("15:04", v); err != nil {
+				response.Fail(c, "invalid workday time, expected HH:MM", v)
+				return
+			}
+		}
+	}
+
+	binding, err := models.GetAssistantCalendar(h.db, assistant.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	if binding == nil {
+		binding = &models.AssistantCalendar{AssistantID: assistant.ID, UserID: assistant.UserID, Enabled: true}
+	}
+	binding.CredentialID = req.CredentialID
+	binding.Scopes = models.StringArray(req.Scopes)
+	binding.Timezone = req.Timezone
+	binding.DefaultDuration = req.DefaultDuration
+	binding.WorkdayStart = req.WorkdayStart
+	binding.WorkdayEnd = req.WorkdayEnd
+	if req.Enabled != nil {
+		binding.Enabled = *req.Enabled
+	}
+
+	if err := h.db.Save(binding).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "saved", binding)
+}
+
+// DeleteAssistantCalendar removes the calendar binding of an assistant
+// DELETE /assistant/:id/calendar
+func (h *Handlers) DeleteAssistantCalendar(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Where("assistant_id = ?", assistant.ID).Delete(&models.AssistantCalendar{}).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// LoadCalendarToolsToHandler registers the built-in calendar tools allowed by the
+// assistant's calendar scopes
+func (h *Handlers) LoadCalendarToolsToHandler(handler *llm.LLMHandler, assistantID int64) error {
+	binding, err := models.GetAssistantCalendar(h.db, assistantID)
+	if err != nil {
+		return fmt.Errorf("failed to load assistant calendar: %w", err)
+	}
+	if binding == nil || !binding.Enabled {
+		return nil
+	}
+
+	if binding.HasScope(calendar.ScopeAvailability) {
+		handler.RegisterFunctionTool("calendar_check_availability",
+			"Check free time slots in the calendar on a given date. Use before proposing or booking a meeting time.",
+			json.RawMessage(`{"type":"object","properties":{
+				"date":{"type":"string","description":"Date to check, format YYYY-MM-DD"},
+				"duration":{"type":"integer","description":"Required meeting length in minutes"}
+			},"required":["date"]}`),
+			func(args map[string]interface{}) (string, error) {
+				return h.calendarCheckAvailability(binding, args)
+			})
+	}
+	if binding.HasScope(calendar.ScopeCreateEvent) {
+		handler.RegisterFunctionTool("calendar_create_event",
+			"Create an event in the calendar after the caller confirmed the time.",
+			json.RawMessage(`{"type":"object","properties":{
+				"title":{"type":"string","description":"Event title"},
+				"start":{"type":"string","description":"Start time, format YYYY-MM-DD HH:MM"},
+				"duration":{"type":"integer","description":"Length in minutes"},
+				"description":{"type":"string","description":"Event notes"},
+				"location":{"type":"string","description":"Event location"}
+			},"required":["title","start"]}`),
+			func(args map[string]interface{}) (string, error) {
+				return h.calendarCreateEvent(binding, args, false)
+			})
+	}
+	if binding.HasScope(calendar.ScopeSendInvite) {
+		handler.RegisterFunctionTool("calendar_send_invite",
+			"Create a meeting and email invitations to the attendees.",
+			json.RawMessage(`{"type":"object","properties":{
+				"title":{"type":"string","description":"Meeting title"},
+				"start":{"type":"string","description":"Start time, format YYYY-MM-DD HH:MM"},
+				"duration":{"type":"integer","description":"Length in minutes"},
+				"attendees":{"type":"array","items":{"type":"string"},"description":"Attendee email addresses"},
+				"description":{"type":"string","description":"Meeting notes"},
+				"location":{"type":"string","description":"Meeting location or link"}
+			},"required":["title","start","attendees"]}`),
+			func(args map[string]interface{}) (string, error) {
+				return h.calendarCreateEvent(binding, args, true)
+			})
+	}
+	return nil
+}
+
+// calendarCheckAvailability lists free slots within the workday of the requested date
+func (h *Handlers) calendarCheckAvailability(binding *models.AssistantCalendar, args map[string]interface{}) (string, error) {
+	loc := binding.Location()
+	dateStr, _ := args["date"].(string)
+	day, err := time.ParseInLocation("2006-01-02", dateStr, loc)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", dateStr)
+	}
+	duration := calendarDuration(binding, args)
+
+	start := calendarDayTime(day, binding.WorkdayStart, 9)
+	end := calendarDayTime(day, binding.WorkdayEnd, 18)
+	if now := time.Now().In(loc); start.Before(now) {
+		start = now.Truncate(15 * time.Minute).Add(15 * time.Minute)
+	}
+	if !end.After(start) {
+		return fmt.Sprintf("No availability left on %s.", dateStr), nil
+	}
+
+	provider, err := h.calendarProvider(binding)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), calendarToolTimeout)
+	defer cancel()
+	busy, err := provider.FreeBusy(ctx, start, end)
+	if err != nil {
+		return "", fmt.Errorf("failed to read calendar: %w", err)
+	}
+
+	slots := calendar.FreeSlots(busy, start, end, duration)
+	if len(slots) == 0 {
+		return fmt.Sprintf("No free %d-minute slot on %s.", int(duration.Minutes()), dateStr), nil
+	}
+	parts := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		parts = append(parts, slot.Start.In(loc).Format("15:04")+"-"+slot.End.In(loc).Format("15:04"))
+	}
+	return fmt.Sprintf("Free time on %s (%s): %s", dateStr, loc.String(), strings.Join(parts, ", ")), nil
+}
+
+// calendarCreateEvent creates an event, checking the slot is still free first
+func (h *Handlers) calendarCreateEvent(binding *models.AssistantCalendar, args map[string]interface{}, sendInvites bool) (string, error) {
+	loc := binding.Location()
+	startStr, _ := args["start"].(string)
+	start, err := time.ParseInLocation("2006-01-02 15:04", startStr, loc)
+	if err != nil {
+		if start, err = time.Parse(time.RFC3339, startStr); err != nil {
+			return "", fmt.Errorf("invalid start %q, expected YYYY-MM-DD HH:MM", startStr)
+		}
+	}
+	if start.Before(time.Now()) {
+		return "", fmt.Errorf("start time %s is in the past", startStr)
+	}
+
+	event := calendar.Event{
+		Start: start,
+		End:   start.Add(calendarDuration(binding, args)),
+	}
+	event.Title, _ = args["title"].(string)
+	event.Description, _ = args["description"].(string)
+	event.Location, _ = args["location"].(string)
+	if event.Title == "" {
+		return "", fmt.Errorf("title is required")
+	}
+	if sendInvites {
+		if list, ok := args["attendees"].([]interface{}); ok {
+			for _, item := range list {
+				if email, ok := item.(string); ok && strings.Contains(email, "@") {
+					event.Attendees = append(event.Attendees, strings.TrimSpace(email))
+				}
+			}
+		}
+		if len(event.Attendees) == 0 {
+			return "", fmt.Errorf("at least one attendee email is required")
+		}
+	}
+
+	provider, err := h.calendarProvider(binding)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), calendarToolTimeout)
+	defer cancel()
+
+	busy, err := provider.FreeBusy(ctx, event.Start, event.End)
+	if err != nil {
+		return "", fmt.Errorf("failed to read calendar: %w", err)
+	}
+	if !calendar.IsFree(busy, event.Start, event.End) {
+		return fmt.Sprintf("The slot %s is no longer free, please choose another time.", event.Start.In(loc).Format("2006-01-02 15:04")), nil
+	}
+
+	created, err := provider.CreateEvent(ctx, event, sendInvites)
+	if err != nil {
+		return "", fmt.Errorf("failed to create event: %w", err)
+	}
+
+	logger.Info("Calendar event created by assistant",
+		zap.Int64("assistantID", binding.AssistantID),
+		zap.String("eventID", created.ID),
+		zap.Bool("sendInvites", sendInvites))
+
+	result := fmt.Sprintf("Event \"%s\" created for %s-%s.", created.Title,
+		created.Start.In(loc).Format("2006-01-02 15:04"), created.End.In(loc).Format("15:04"))
+	if sendInvites {
+		result += " Invitations sent to " + strings.Join(created.Attendees, ", ") + "."
+	}
+	return result, nil
+}
+
+// calendarProvider builds the provider for the bound credential. Refreshed Google
+// tokens are written back so later calls skip the refresh.
+func (h *Handlers) calendarProvider(binding *models.AssistantCalendar) (calendar.Provider, error) {
+	cred, err := models.GetCalendarCredential(h.db, binding.UserID, binding.CredentialID)
+	if err != nil {
+		return nil, fmt.Errorf("calendar credential not found")
+	}
+
+	switch cred.Provider {
+	case calendar.ProviderCalDAV:
+		return calendar.NewCalDAVProvider(cred.CalDAVURL, cred.Username, cred.Password), nil
+	case calendar.ProviderGoogle:
+		cfg, ok := googleCalendarOAuthConfig()
+		if !ok {
+			return nil, fmt.Errorf("google calendar is not configured")
+		}
+		token := &oauth2.Token{
+			AccessToken:  cred.AccessToken,
+			RefreshToken: cred.RefreshToken,
+			TokenType:    cred.TokenType,
+		}
+		if cred.TokenExpiry != nil {
+			token.Expiry = *cred.TokenExpiry
+		}
+		db := h.db
+		return calendar.NewGoogleProvider(context.Background(), cfg, token, cred.CalendarID, func(t *oauth2.Token) {
+			if err := models.UpdateCalendarToken(db, cred.ID, t.AccessToken, t.RefreshToken, t.TokenType, t.Expiry); err != nil {
+				logger.Warn("Failed to persist refreshed calendar token", zap.Uint("credentialID", cred.ID), zap.Error(err))
+			}
+		}), nil
+	}
+	return nil, fmt.Errorf("unsupported calendar provider %s", cred.Provider)
+}
+
+// googleCalendarOAuthConfig returns the OAuth config, false when not configured
+func googleCalendarOAuthConfig() (*oauth2.Config, bool) {
+	gc := config.GlobalConfig.Integrations.GoogleCalendar
+	if gc.ClientID == "" || gc.ClientSecret == "" {
+		return nil, false
+	}
+	redirectURL := gc.RedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimRight(config.GlobalConfig.Server.URL, "/") + config.GlobalConfig.Server.APIPrefix + "/calendar/google/callback"
+	}
+	return calendar.GoogleOAuthConfig(gc.ClientID, gc.ClientSecret, redirectURL), true
+}
+
+// googleAccountEmail looks up the email of the authorized Google account, empty on failure
+func googleAccountEmail(ctx context.Context, cfg *oauth2.Config, token *oauth2.Token) string {
+	client := cfg.Client(ctx, token)
+	client.Timeout = 10 * time.Second
+	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	var info struct {
+		Email string `json:"email"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&info)
+	return info.Email
+}
+
+// calendarDuration reads the duration argument, falling back to the assistant default
+func calendarDuration(binding *models.AssistantCalendar, args map[string]interface{}) time.Duration {
+	minutes := binding.DefaultDuration
+	if v, ok := args["duration"].(float64); ok && v > 0 {
+		minutes = int(v)
+	}
+	if minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// calendarDayTime combines a date with an HH:MM time, defaultHour when unset
+func calendarDayTime(day time.Time, hhmm string, defaultHour int) time.Time {
+	hour, minute := defaultHour, 0
+	if t, err := time.Parse("15:04", hhmm); err == nil {
+		hour, minute = t.Hour(), t.Minute()
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
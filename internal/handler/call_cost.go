@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// maxCDRCostReportDays longest range of a CDR cost report
+const maxCDRCostReportDays = 366
+
+// GetCDRCostReport rolls up the frozen per-call costs of SIP and device calls
+// started in [?from=, ?to=] (YYYY-MM-DD, default the current month), in total
+// and per day. With ?groupId= organization admins get the organization's SIP
+// calls and the device calls of its members; otherwise the current user's calls
+// GET /sip/calls/cdr
+func (h *Handlers) GetCDRCostReport(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := from.AddDate(0, 1, -1)
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			response.Fail(c, "invalid from", "from must be YYYY-MM-DD")
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			response.Fail(c, "invalid to", "to must be YYYY-MM-DD")
+			return
+		}
+	}
+	// to is inclusive
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) || to.Sub(from) > maxCDRCostReportDays*24*time.Hour {
+		response.Fail(c, "invalid range", "from must not be after to and the range is at most 366 days")
+		return
+	}
+
+	userIDs := []uint{user.ID}
+	var groupID *uint
+	if v := c.Query("groupId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.Fail(c, "invalid groupId", nil)
+			return
+		}
+		var group models.Group
+		if err := h.db.First(&group, id).Error; err != nil {
+			response.Fail(c, "organization not found", nil)
+			return
+		}
+		if !models.IsGroupAdmin(h.db, &group, user.ID) {
+			response.Fail(c, "insufficient permissions", "only organization admins can view its call costs")
+			return
+		}
+		if userIDs, err = models.GroupMemberIDs(h.db, &group); err != nil {
+			response.Fail(c, "query failed", err.Error())
+			return
+		}
+		groupID = &group.ID
+	}
+
+	table := models.LoadPriceTable(h.db)
+	report, err := models.BuildCDRCostReport(h.db, userIDs, groupID, from, to, table.Currency)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", report)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/audioquality"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxDiarizationAudio recordings larger than this are not diarized
+const maxDiarizationAudio = 512 << 20
+
+// diarizeCallRecording splits the human turns of the recording by speaker and
+// stores the result in its conversation details. Recordings without stored WAV
+// audio are skipped; nil is returned whenever the details were not updated
+func (h *Handlers) diarizeCallRecording(recording *models.CallRecording) *models.ConversationDetails {
+	audio, err := openRecordingAudio(recording.StorageURL)
+	if err != nil {
+		logger.Debug("Skipping diarization, recording audio unavailable", zap.Uint("recordingID", recording.ID), zap.Error(err))
+		return nil
+	}
+	defer audio.Close()
+	data, err := io.ReadAll(io.LimitReader(audio, maxDiarizationAudio+1))
+	if err != nil || len(data) > maxDiarizationAudio {
+		logger.Warn("Skipping diarization, failed to read recording audio", zap.Uint("recordingID", recording.ID), zap.Error(err))
+		return nil
+	}
+	samples, sampleRate, err := audioquality.DecodeWAV(data)
+	if err != nil {
+		logger.Debug("Skipping diarization, unsupported recording format", zap.Uint("recordingID", recording.ID), zap.String("format", recording.AudioFormat))
+		return nil
+	}
+	details, err := models.DiarizeCallRecording(h.db, recording, samples, sampleRate)
+	if err != nil {
+		logger.Warn("Diarization failed", zap.Uint("recordingID", recording.ID), zap.Error(err))
+		return nil
+	}
+	return details
+}
+
+// RelabelSessionSpeakers corrects the speakers of a call recording's turns:
+// names speakers and moves turns to another, possibly new, speaker. Moved turns
+// are kept when the recording is analyzed again
+// PUT /assistant/:id/sessions/:sessionId/speakers
+func (h *Handlers) RelabelSessionSpeakers(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	var req models.SpeakerRelabel
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	var recording models.CallRecording
+	if err := h.db.Where("assistant_id = ? AND session_id = ? AND is_deleted = ?", assistant.ID, c.Param("sessionId"), false).
+		Order("id DESC").First(&recording).Error; err != nil {
+		response.Fail(c, "call recording not found", nil)
+		return
+	}
+	details, err := models.RelabelCallRecordingSpeakers(h.db, &recording, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownTurn) || errors.Is(err, models.ErrInvalidSpeaker) || errors.Is(err, models.ErrNoConversationDetails) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"recordingId": recording.ID, "speakers": details.Speakers, "turns": details.Turns})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+type reviewSessionPIIRequest struct {
+	Reviews []models.PIIReview `json:"reviews" binding:"required,min=1"`
+}
+
+// sessionRecording loads the latest call recording of the session of a reviewable assistant
+func (h *Handlers) sessionRecording(c *gin.Context) (*models.CallRecording, bool) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return nil, false
+	}
+	var recording models.CallRecording
+	if err := h.db.Where("assistant_id = ? AND session_id = ? AND is_deleted = ?", assistant.ID, c.Param("sessionId"), false).
+		Order("id DESC").First(&recording).Error; err != nil {
+		response.Fail(c, "call recording not found", nil)
+		return nil, false
+	}
+	return &recording, true
+}
+
+// sessionPIIResponse the turns and PII tags of a recording, masked when the
+// organization policy masks them for the current user
+func (h *Handlers) sessionPIIResponse(c *gin.Context, recording *models.CallRecording, details *models.ConversationDetails) gin.H {
+	masked := false
+	if types, mask := models.PIIMaskForViewer(h.db, recording, models.CurrentUser(c)); mask {
+		details.MaskPII(types)
+		masked = true
+	}
+	return gin.H{
+		"recordingId": recording.ID,
+		"detectedAt":  details.PIIDetectedAt,
+		"masked":      masked,
+		"entities":    details.PIIEntities,
+		"turns":       details.Turns,
+	}
+}
+
+// DetectSessionPII tags the names, phone numbers, addresses, ID numbers and
+// other personal data in the session's transcript again. Reviewed tags are kept
+// POST /assistant/:id/sessions/:sessionId/pii/detect
+func (h *Handlers) DetectSessionPII(c *gin.Context) {
+	recording, ok := h.sessionRecording(c)
+	if !ok {
+		return
+	}
+	details, err := models.DetectCallRecordingPII(h.db, recording, time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrNoConversationDetails) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "detection failed", err.Error())
+		return
+	}
+	response.Success(c, "success", h.sessionPIIResponse(c, recording, details))
+}
+
+// ReviewSessionPII confirms or rejects detected PII tags and adds the ones
+// detection missed; the reviews feed the organization's detection metrics
+// PUT /assistant/:id/sessions/:sessionId/pii
+func (h *Handlers) ReviewSessionPII(c *gin.Context) {
+	recording, ok := h.sessionRecording(c)
+	if !ok {
+		return
+	}
+	var req reviewSessionPIIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	details, err := models.ReviewCallRecordingPII(h.db, recording, req.Reviews, models.CurrentUser(c).ID, time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidPIIReview) || errors.Is(err, models.ErrUnknownTurn) || errors.Is(err, models.ErrNoConversationDetails) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "success", h.sessionPIIResponse(c, recording, details))
+}
+
+// GetGroupPIIMetrics reports the precision and recall of PII detection on the
+// organization's call recordings over the last days (default 30, at most 365),
+// measured against reviewer decisions
+// GET /group/:id/pii/metrics
+func (h *Handlers) GetGroupPIIMetrics(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(models.PIIMetricsDefaultDays)))
+	if days < 1 || days > 365 {
+		days = models.PIIMetricsDefaultDays
+	}
+	metrics, err := models.GroupPIIMetrics(h.db, group.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", metrics)
+}
@@ -139,6 +139,9 @@ func (h *CallRecordingHandler) DeleteCallRecording(c *gin.Context) {
 		response.Fail(c, "删除通话记录失败", nil)
 		return
 	}
+	if err := models.RecordSyncTombstone(h.db, userID, models.SyncEntityRecording, recordingIDStr); err != nil {
+		h.logger.Warn("记录同步删除失败", zap.Error(err), zap.Uint64("recordingID", recordingID))
+	}
 
 	response.Success(c, "删除成功", gin.H{"message": "删除成功"})
 }
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSatisfactionReportDays caps the range of a satisfaction report
+const maxSatisfactionReportDays = 366
+
+// GetCallSurveySettings gets the post-call survey settings of the current user
+// GET /surveys/settings
+func (h *Handlers) GetCallSurveySettings(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	settings, err := models.GetCallSurveySettings(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	if settings == nil {
+		// Not configured yet, report the defaults
+		settings = &models.CallSurveySettings{UserID: user.ID}
+		_ = settings.Validate()
+	}
+	response.Success(c, "success", settings)
+}
+
+// UpdateCallSurveySettings enables, disables or changes the post-call survey
+// PUT /surveys/settings
+func (h *Handlers) UpdateCallSurveySettings(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	var settings models.CallSurveySettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	settings.ID = 0
+	settings.UserID = user.ID
+	if err := settings.Validate(); err != nil {
+		response.Fail(c, "invalid survey settings", err.Error())
+		return
+	}
+	if err := models.SaveCallSurveySettings(h.db, &settings); err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+
+	saved, err := models.GetCallSurveySettings(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "updated", saved)
+}
+
+// GetSatisfactionReport aggregates the survey results of the current user by
+// ?groupBy=assistant|agent|day (default day) over [?from=, ?to=] (YYYY-MM-DD,
+// default the last 30 days)
+// GET /surveys/report
+func (h *Handlers) GetSatisfactionReport(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from, to := today.AddDate(0, 0, -29), today
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			response.Fail(c, "invalid from", "from must be YYYY-MM-DD")
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			response.Fail(c, "invalid to", "to must be YYYY-MM-DD")
+			return
+		}
+	}
+	// to is inclusive
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) || to.Sub(from) > maxSatisfactionReportDays*24*time.Hour {
+		response.Fail(c, "invalid range", "from must not be after to and the range is at most 366 days")
+		return
+	}
+
+	groupBy := c.DefaultQuery("groupBy", models.SatisfactionByDay)
+	rows, err := models.SatisfactionReport(h.db, user.ID, groupBy, from, to)
+	if err != nil {
+		response.Fail(c, "invalid report", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"groupBy": groupBy,
+		"from":    from.Format("2006-01-02"),
+		"to":      to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"rows":    rows,
+	})
+}
+
+// GetCallSurvey gets the survey result of a call
+// GET /surveys/calls/:callId
+func (h *Handlers) GetCallSurvey(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	survey, err := models.GetCallSurveyByCallID(h.db, c.Param("callId"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "survey not found", nil)
+		} else {
+			response.Fail(c, "query failed", err.Error())
+		}
+		return
+	}
+	if survey.UserID != user.ID {
+		response.Fail(c, "forbidden", "No permission to access this survey")
+		return
+	}
+	response.Success(c, "success", survey)
+}
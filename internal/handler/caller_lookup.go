@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+type callerLookupRequest struct {
+	URL              string `json:"url" binding:"required"`
+	Secret           string `json:"secret"` // kept when empty
+	TimeoutMs        int    `json:"timeoutMs"`
+	GreetingTemplate string `json:"greetingTemplate"`
+	Enabled          bool   `json:"enabled"`
+}
+
+// GetCallerLookup returns the assistant's CRM caller lookup, null when not configured
+// GET /assistant/:id/caller-lookup
+func (h *Handlers) GetCallerLookup(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	lookup, err := models.GetCallerLookup(h.db, assistant.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", lookup)
+}
+
+// SaveCallerLookup creates or updates the assistant's CRM caller lookup
+// PUT /assistant/:id/caller-lookup
+func (h *Handlers) SaveCallerLookup(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	var req callerLookupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	lookup, err := models.GetCallerLookup(h.db, assistant.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	if lookup == nil {
+		lookup = &models.CallerLookup{AssistantID: assistant.ID, UserID: assistant.UserID}
+	}
+	lookup.URL = req.URL
+	lookup.TimeoutMs = req.TimeoutMs
+	lookup.GreetingTemplate = req.GreetingTemplate
+	lookup.Enabled = req.Enabled
+	if req.Secret != "" {
+		lookup.Secret = req.Secret
+	}
+	if err := lookup.Validate(c.Request.Context()); err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	if err := h.db.Save(lookup).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	lookup.AfterFind(h.db)
+	response.Success(c, "success", lookup)
+}
+
+// DeleteCallerLookup removes the assistant's CRM caller lookup; past lookup logs are kept
+// DELETE /assistant/:id/caller-lookup
+func (h *Handlers) DeleteCallerLookup(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Where("assistant_id = ?", assistant.ID).Delete(&models.CallerLookup{}).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "success", nil)
+}
+
+// TestCallerLookup runs the lookup for a number and returns the prompt section
+// and greeting a call from it would get, without logging
+// POST /assistant/:id/caller-lookup/test
+func (h *Handlers) TestCallerLookup(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Caller string `json:"caller" binding:"required"`
+		Callee string `json:"callee"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	lookup, err := models.GetCallerLookup(h.db, assistant.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	if lookup == nil {
+		response.Fail(c, "caller lookup not configured", nil)
+		return
+	}
+	enrichment := models.LookupCaller(c.Request.Context(), lookup, assistant.ID, "test", req.Caller, req.Callee)
+	response.Success(c, "success", gin.H{
+		"status":     enrichment.Log.Status,
+		"durationMs": enrichment.Log.DurationMs,
+		"fields":     enrichment.Log.Fields,
+		"prompt":     enrichment.Prompt,
+		"greeting":   enrichment.Greeting,
+		"error":      enrichment.Log.Error,
+	})
+}
+
+// ListCallerLookupLogs lists which CRM data personalized each inbound call
+// GET /assistant/:id/caller-lookup/logs
+func (h *Handlers) ListCallerLookupLogs(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	query := h.db.Model(&models.CallerLookupLog{}).Where("assistant_id = ?", assistant.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if caller := c.Query("caller"); caller != "" {
+		query = query.Where("caller = ?", caller)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	var logs []models.CallerLookupLog
+	if err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&logs).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"list": logs, "total": total, "page": page, "size": size})
+}
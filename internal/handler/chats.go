@@ -106,6 +106,7 @@ func (h *Handlers) Chat(c *gin.Context) {
 		response.Fail(c, "Secret or key is invalid or wrong. Please check again.", nil)
 		return
 	}
+	models.SetCurrentCredential(c, cred)
 }
 
 func (h *Handlers) StopChat(c *gin.Context) {
@@ -413,17 +414,20 @@ func (h *Handlers) handleConnection(c *gin.Context) {
 		return
 	}
 
-	// 验证 assistant 是否属于该用户（通过 credential 的 UserID）
-	if assistant.UserID != cred.UserID {
-		// 检查是否是组织共享的助手
-		if assistant.GroupID == nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied: assistant does not belong to you"})
-			c.Abort()
-			return
-		}
-		// TODO: 可以在这里添加组织成员权限检查
+	// 验证 assistant 是否属于该用户（通过 credential 的 UserID），或通过组织共享给该用户
+	if !models.CanUseAssistant(h.db, &assistant, cred.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied: assistant does not belong to you"})
+		c.Abort()
+		return
 	}
 
+	h.serveWebRTCCall(c, cred, &assistant, 0)
+}
+
+// serveWebRTCCall 升级为信令 WebSocket 并以助手配置进行 WebRTC 通话，maxDuration 大于 0 时到时挂断
+func (h *Handlers) serveWebRTCCall(c *gin.Context, cred *models.UserCredential, assistant *models.Assistant, maxDuration time.Duration) {
+	assistantID := assistant.ID
+
 	// 从 assistant 中读取配置
 	knowledgeKey := ""
 	if assistant.KnowledgeBaseID != nil && *assistant.KnowledgeBaseID != "" {
@@ -483,6 +487,10 @@ func (h *Handlers) handleConnection(c *gin.Context) {
 		return
 	}
 	defer conn.Close()
+	if maxDuration > 0 {
+		timer := time.AfterFunc(maxDuration, func() { conn.Close() })
+		defer timer.Stop()
+	}
 	sessionID := fmt.Sprintf("session_%d", time.Now().UnixNano())
 
 	// Create WebRTC transport
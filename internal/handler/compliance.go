@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type complianceArchiveRequest struct {
+	Enabled       bool   `json:"enabled"`
+	Kind          string `json:"kind" binding:"required"` // s3 or api
+	Endpoint      string `json:"endpoint"`
+	Region        string `json:"region"`
+	Bucket        string `json:"bucket"`
+	Prefix        string `json:"prefix"`
+	AccessKey     string `json:"accessKey"`
+	SecretKey     string `json:"secretKey"` // kept when empty
+	Token         string `json:"token"`     // kept when empty
+	Secret        string `json:"secret"`    // kept when empty
+	RetentionDays int    `json:"retentionDays"`
+	// Since archive calls that ended after this time, defaults to when the archive is first enabled
+	Since *time.Time `json:"since"`
+}
+
+// GetComplianceArchive returns the organization's compliance archive and delivery counts
+// GET /group/:id/compliance-archive
+func (h *Handlers) GetComplianceArchive(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	archive, err := models.GetComplianceArchive(h.db, group.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	summary, err := models.ComplianceExportSummary(h.db, group.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"archive": archive, "exports": summary})
+}
+
+// SaveComplianceArchive creates or updates the organization's compliance archive
+// PUT /group/:id/compliance-archive
+func (h *Handlers) SaveComplianceArchive(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	var req complianceArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	archive, err := models.GetComplianceArchive(h.db, group.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	if archive == nil {
+		archive = &models.ComplianceArchive{GroupID: group.ID, CreatedBy: models.CurrentUser(c).ID}
+	}
+	if req.Since != nil {
+		archive.Since = *req.Since
+	} else if archive.Since.IsZero() && req.Enabled {
+		archive.Since = time.Now()
+	}
+	archive.Enabled = req.Enabled
+	archive.Kind = req.Kind
+	archive.Endpoint = req.Endpoint
+	archive.Region = req.Region
+	archive.Bucket = req.Bucket
+	archive.Prefix = req.Prefix
+	archive.AccessKey = req.AccessKey
+	archive.RetentionDays = req.RetentionDays
+	if req.SecretKey != "" {
+		archive.SecretKey = req.SecretKey
+	}
+	if req.Token != "" {
+		archive.Token = req.Token
+	}
+	if req.Secret != "" {
+		archive.Secret = req.Secret
+	}
+	if err := archive.Validate(); err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	// Save writes zero values too, so disabling the archive is persisted
+	if err := h.db.Save(archive).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	archive.AfterFind(h.db)
+	response.Success(c, "success", archive)
+}
+
+// DeleteComplianceArchive removes the organization's compliance archive; the
+// delivery history and receipts are kept
+// DELETE /group/:id/compliance-archive
+func (h *Handlers) DeleteComplianceArchive(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	if err := h.db.Where("group_id = ?", group.ID).Delete(&models.ComplianceArchive{}).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "success", nil)
+}
+
+// ListComplianceExports lists deliveries with their receipts, filtered by status
+// GET /group/:id/compliance-exports
+func (h *Handlers) ListComplianceExports(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	query := h.db.Model(&models.ComplianceExport{}).Where("group_id = ?", group.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	var exports []models.ComplianceExport
+	if err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&exports).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"list": exports, "total": total, "page": page, "size": size})
+}
+
+// RetryComplianceExport queues a failed delivery again
+// POST /group/:id/compliance-exports/:exportId/retry
+func (h *Handlers) RetryComplianceExport(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	exportID, err := strconv.ParseUint(c.Param("exportId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid export id", nil)
+		return
+	}
+	export, err := models.RetryComplianceExport(h.db, group.ID, uint(exportID), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, "export not found", nil)
+		return
+	}
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	response.Success(c, "success", export)
+}
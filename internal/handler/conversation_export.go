@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/transcript"
+	"github.com/gin-gonic/gin"
+)
+
+// conversationExportRequest body of an export request
+type conversationExportRequest struct {
+	Source   string `json:"source" binding:"required"`   // call_recording or chat_session
+	SourceID string `json:"sourceId" binding:"required"` // Recording ID or chat session ID
+	Format   string `json:"format" binding:"required"`   // json, srt, vtt or docx
+}
+
+// CreateConversationExport queues an export of a call recording or chat session.
+// The file is generated in the background and the requester is notified with a
+// download link.
+// POST /conversation-exports
+func (h *Handlers) CreateConversationExport(c *gin.Context) {
+	user := models.CurrentUser(c)
+	var req conversationExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid parameters", err.Error())
+		return
+	}
+	e, err := models.CreateConversationExport(h.db, user.ID, req.Source, req.SourceID, req.Format)
+	if err != nil {
+		if errors.Is(err, models.ErrConversationNotFound) {
+			response.Fail(c, "Conversation not found", nil)
+			return
+		}
+		response.Fail(c, "Failed to queue export", err.Error())
+		return
+	}
+	response.Success(c, "Export queued", e)
+}
+
+// ListConversationExports lists the current user's recent exports
+// GET /conversation-exports
+func (h *Handlers) ListConversationExports(c *gin.Context) {
+	exports, err := models.ListConversationExports(h.db, models.CurrentUser(c).ID, 50)
+	if err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+	response.Success(c, "Query successful", exports)
+}
+
+// GetConversationExport returns the status of an export
+// GET /conversation-exports/:id
+func (h *Handlers) GetConversationExport(c *gin.Context) {
+	var e models.ConversationExport
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), models.CurrentUser(c).ID).First(&e).Error; err != nil {
+		response.Fail(c, "Export not found", nil)
+		return
+	}
+	response.Success(c, "Query successful", e)
+}
+
+// DownloadConversationExport serves a generated export. Access needs the signed
+// token from the notification or the logged-in requester.
+// GET /conversation-exports/:id/download
+func (h *Handlers) DownloadConversationExport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export id"})
+		return
+	}
+	var e models.ConversationExport
+	if err := h.db.First(&e, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+		return
+	}
+
+	authorized := false
+	if token := c.Query("token"); token != "" {
+		authorized = models.VerifyConversationExportLink(config.GlobalConfig.Auth.SessionSecret, token, e.ID, time.Now()) == nil
+	} else if user := models.CurrentUser(c); user != nil {
+		authorized = user.ID == e.UserID
+	}
+	if !authorized {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access to this export is not allowed"})
+		return
+	}
+	if e.Status != models.ConversationExportCompleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export is " + e.Status})
+		return
+	}
+	if _, err := os.Stat(e.FilePath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export file not found"})
+		return
+	}
+
+	c.Header("Content-Type", transcript.ContentType(e.Format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(e.FileName)))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-store")
+	c.File(e.FilePath)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// estimateCostRequest is the configuration being edited; for a saved assistant, empty
+// fields fall back to its stored settings
+type estimateCostRequest struct {
+	LLMModel        string  `json:"llmModel"`
+	TtsProvider     string  `json:"ttsProvider"`
+	AsrProvider     string  `json:"asrProvider"`
+	SystemPrompt    *string `json:"systemPrompt"`
+	MaxTokens       *int    `json:"maxTokens"`
+	KnowledgeBaseID *string `json:"knowledgeBaseId"`
+	KBTopK          int     `json:"kbTopK"`
+	Telephony       bool    `json:"telephony"`
+	AvgCallSeconds  float64 `json:"avgCallSeconds"`
+}
+
+func (req *estimateCostRequest) input(assistant *models.Assistant) models.CostEstimateInput {
+	in := models.CostEstimateInput{
+		LLMModel:       req.LLMModel,
+		TTSProvider:    req.TtsProvider,
+		ASRProvider:    req.AsrProvider,
+		KBTopK:         req.KBTopK,
+		Telephony:      req.Telephony,
+		AvgCallSeconds: req.AvgCallSeconds,
+	}
+	if req.SystemPrompt != nil {
+		in.SystemPrompt = *req.SystemPrompt
+	}
+	if req.MaxTokens != nil {
+		in.MaxTokens = *req.MaxTokens
+	}
+	if req.KnowledgeBaseID != nil {
+		in.KnowledgeBase = *req.KnowledgeBaseID != ""
+	}
+	if assistant == nil {
+		return in
+	}
+
+	assistantID := uint(assistant.ID)
+	in.AssistantID = &assistantID
+	if in.LLMModel == "" {
+		in.LLMModel = assistant.LLMModel
+	}
+	if in.TTSProvider == "" {
+		in.TTSProvider = assistant.TtsProvider
+	}
+	if req.SystemPrompt == nil {
+		in.SystemPrompt = assistant.SystemPrompt
+	}
+	if req.MaxTokens == nil {
+		in.MaxTokens = assistant.MaxTokens
+	}
+	if req.KnowledgeBaseID == nil {
+		in.KnowledgeBase = assistant.KnowledgeBaseID != nil && *assistant.KnowledgeBaseID != ""
+	}
+	return in
+}
+
+// EstimateCost previews the cost per call minute of an assistant configuration that is not saved yet
+// POST /assistant/estimate-cost
+func (h *Handlers) EstimateCost(c *gin.Context) {
+	var req estimateCostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	h.respondCostEstimate(c, req.input(nil))
+}
+
+// EstimateAssistantCost previews the cost of an assistant with pending changes, using its
+// historical average call length and token usage
+// POST /assistant/:id/estimate-cost
+func (h *Handlers) EstimateAssistantCost(c *gin.Context) {
+	assistant, ok := h.customFieldAssistant(c)
+	if !ok {
+		return
+	}
+	var req estimateCostRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "invalid request", err.Error())
+			return
+		}
+	}
+	h.respondCostEstimate(c, req.input(assistant))
+}
+
+func (h *Handlers) respondCostEstimate(c *gin.Context, in models.CostEstimateInput) {
+	estimate, err := models.EstimateAssistantCost(h.db, models.LoadPriceTable(h.db), in)
+	if err != nil {
+		response.Fail(c, "estimation failed", err.Error())
+		return
+	}
+	response.Success(c, "success", estimate)
+}
+
+// GetPriceTable returns the provider price table used for cost estimation
+// GET /system/price-table
+func (h *Handlers) GetPriceTable(c *gin.Context) {
+	response.Success(c, "success", models.LoadPriceTable(h.db))
+}
+
+// UpdatePriceTable replaces the provider price table (admin)
+// PUT /system/price-table
+func (h *Handlers) UpdatePriceTable(c *gin.Context) {
+	var table models.PriceTable
+	if err := c.ShouldBindJSON(&table); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if err := models.SavePriceTable(h.db, table); err != nil {
+		response.Fail(c, "invalid price table", err.Error())
+		return
+	}
+	response.Success(c, "saved", table)
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
 	"github.com/code-100-precent/LingEcho/pkg/response"
@@ -71,3 +73,62 @@ func (h *Handlers) handleDeleteCredential(c *gin.Context) {
 
 	response.Success(c, "Credential deleted successfully", nil)
 }
+
+// credentialUsageWindows selectable windows of the API usage dashboard
+var credentialUsageWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+	"90d": models.APIKeyUsageRetention,
+}
+
+// handleGetCredentialUsage returns request counts, error rates and latency
+// percentiles per API key and endpoint over the selected window (default 24h).
+// Data comes from the hourly usage rollups, so it lags up to one minute.
+// GET /credentials/usage, GET /credentials/:id/usage
+func (h *Handlers) handleGetCredentialUsage(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User is not logged in.", nil)
+		return
+	}
+
+	window := c.DefaultQuery("window", "24h")
+	duration, ok := credentialUsageWindows[window]
+	if !ok {
+		response.Fail(c, "Invalid window, expected one of 1h, 24h, 7d, 30d, 90d", nil)
+		return
+	}
+
+	var credentialID *uint
+	if idStr := c.Param("id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			response.Fail(c, "Invalid credential ID", nil)
+			return
+		}
+		var count int64
+		if err := h.db.Model(&models.UserCredential{}).Where("id = ? AND user_id = ?", id, user.ID).Count(&count).Error; err != nil {
+			response.Fail(c, "Failed to load credential", err.Error())
+			return
+		}
+		if count == 0 {
+			response.Fail(c, "Credential not found", nil)
+			return
+		}
+		cid := uint(id)
+		credentialID = &cid
+	}
+
+	to := time.Now()
+	report, err := models.GetAPIKeyUsageReport(h.db, user.ID, credentialID, to.Add(-duration), to)
+	if err != nil {
+		response.Fail(c, "Failed to load API usage", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"window": window,
+		"usage":  report,
+	})
+}
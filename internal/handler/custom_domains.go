@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho"
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type createCustomDomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+// customDomainView adds the DNS record the organization has to create
+func customDomainView(d *models.CustomDomain) gin.H {
+	return gin.H{
+		"domain": d,
+		"dns": gin.H{
+			"type":  "CNAME",
+			"name":  d.Domain,
+			"value": config.GlobalConfig.Domains.CNAMETarget,
+		},
+		"autoTls": config.GlobalConfig.Domains.AutoTLS,
+	}
+}
+
+// customDomainOf loads the organization from :id (admin rights required) and its domain from :domainId
+func (h *Handlers) customDomainOf(c *gin.Context) (*models.CustomDomain, bool) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return nil, false
+	}
+	domainID, err := strconv.ParseUint(c.Param("domainId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid domain id", nil)
+		return nil, false
+	}
+	d, err := models.GetCustomDomain(h.db, group.ID, uint(domainID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, "domain not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return nil, false
+	}
+	return d, true
+}
+
+// ListCustomDomains lists the organization's custom domains
+// GET /group/:id/domains
+func (h *Handlers) ListCustomDomains(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	domains, err := models.GetCustomDomains(h.db, group.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"domains":     domains,
+		"cnameTarget": config.GlobalConfig.Domains.CNAMETarget,
+		"autoTls":     config.GlobalConfig.Domains.AutoTLS,
+	})
+}
+
+// CreateCustomDomain registers a custom domain; it is served once its CNAME is verified
+// POST /group/:id/domains
+func (h *Handlers) CreateCustomDomain(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	if config.GlobalConfig.Domains.CNAMETarget == "" {
+		response.Fail(c, "custom domains are not enabled", "set CUSTOM_DOMAIN_CNAME_TARGET")
+		return
+	}
+	var req createCustomDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	d, err := models.CreateCustomDomain(h.db, group.ID, req.Domain)
+	if errors.Is(err, models.ErrInvalidCustomDomain) || errors.Is(err, models.ErrCustomDomainTaken) {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "created", customDomainView(d))
+}
+
+// VerifyCustomDomain checks the domain's CNAME record and requests a certificate once verified
+// POST /group/:id/domains/:domainId/verify
+func (h *Handlers) VerifyCustomDomain(c *gin.Context) {
+	d, ok := h.customDomainOf(c)
+	if !ok {
+		return
+	}
+	becameVerified, err := models.VerifyCustomDomainCNAME(h.db, d, config.GlobalConfig.Domains.CNAMETarget, nil)
+	if err != nil && d.Status != models.CustomDomainFailed {
+		response.Fail(c, "verification failed", err.Error())
+		return
+	}
+	if becameVerified {
+		utils.Sig().Emit(constants.SigCustomDomainVerified, d, h.db)
+	}
+	response.Success(c, "checked", customDomainView(d))
+}
+
+// UpdateCustomDomainBranding updates the site branding and mail sender used on the domain
+// PUT /group/:id/domains/:domainId/branding
+func (h *Handlers) UpdateCustomDomainBranding(c *gin.Context) {
+	d, ok := h.customDomainOf(c)
+	if !ok {
+		return
+	}
+	var req models.CustomDomainBranding
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if err := req.Validate(d.Domain); err != nil {
+		response.Fail(c, "invalid branding", err.Error())
+		return
+	}
+	if err := models.UpdateCustomDomainBranding(h.db, d, req); err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "saved", d)
+}
+
+// DeleteCustomDomain removes a custom domain
+// DELETE /group/:id/domains/:domainId
+func (h *Handlers) DeleteCustomDomain(c *gin.Context) {
+	d, ok := h.customDomainOf(c)
+	if !ok {
+		return
+	}
+	if err := models.DeleteCustomDomain(h.db, d); err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// GetSiteBranding returns the branding of the requested host, so SPA pages served on an
+// organization's custom domain can render its name and logo
+// GET /system/branding
+func (h *Handlers) GetSiteBranding(c *gin.Context) {
+	site, _ := LingEcho.GetRenderPageContext(c)["Site"].(map[string]any)
+	branding := gin.H{}
+	for _, key := range []string{"Url", "Name", "LogoUrl", "FaviconUrl", "TermsUrl", "PrivacyUrl"} {
+		branding[key] = site[key]
+	}
+	branding["CustomDomain"] = models.CurrentCustomDomain(c) != nil
+	response.Success(c, "success", branding)
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// customFieldDefinitionRequest request body for creating/updating a custom field definition
+type customFieldDefinitionRequest struct {
+	EntityType models.CustomFieldEntity `json:"entityType"`
+	Key        string                   `json:"key"`
+	Label      string                   `json:"label"`
+	FieldType  models.CustomFieldType   `json:"fieldType"`
+	Required   *bool                    `json:"required"`
+	Options    []string                 `json:"options"`
+}
+
+// ListCustomFieldDefinitions lists custom field definitions of an organization
+// GET /group/:id/custom-fields?entityType=device
+func (h *Handlers) ListCustomFieldDefinitions(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, false)
+	if !ok {
+		return
+	}
+
+	defs, err := models.ListCustomFieldDefinitions(h.db, group.ID, models.CustomFieldEntity(c.Query("entityType")))
+	if err != nil {
+		response.Fail(c, "查询失败", err.Error())
+		return
+	}
+	response.Success(c, "查询成功", defs)
+}
+
+// CreateCustomFieldDefinition creates a custom field definition (creator or admin only)
+// POST /group/:id/custom-fields
+func (h *Handlers) CreateCustomFieldDefinition(c *gin.Context) {
+	user := models.CurrentUser(c)
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+
+	var req customFieldDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误", err.Error())
+		return
+	}
+
+	def := &models.CustomFieldDefinition{
+		GroupID:    group.ID,
+		EntityType: req.EntityType,
+		Key:        req.Key,
+		Label:      req.Label,
+		FieldType:  req.FieldType,
+		Options:    models.StringArray(req.Options),
+		CreateBy:   user.ID,
+	}
+	if req.Required != nil {
+		def.Required = *req.Required
+	}
+	if def.Label == "" {
+		def.Label = def.Key
+	}
+
+	if err := models.CreateCustomFieldDefinition(h.db, def); err != nil {
+		response.Fail(c, "创建失败", err.Error())
+		return
+	}
+	response.Success(c, "创建成功", def)
+}
+
+// UpdateCustomFieldDefinition updates label, required flag and options of a definition.
+// Key, entity type and field type are immutable because stored values depend on them.
+// PUT /group/:id/custom-fields/:fieldId
+func (h *Handlers) UpdateCustomFieldDefinition(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	def, ok := h.customFieldDefinition(c, group.ID)
+	if !ok {
+		return
+	}
+
+	var req customFieldDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误", err.Error())
+		return
+	}
+
+	if req.Label != "" {
+		def.Label = req.Label
+	}
+	if req.Required != nil {
+		def.Required = *req.Required
+	}
+	if req.Options != nil {
+		def.Options = models.StringArray(req.Options)
+	}
+
+	if err := models.UpdateCustomFieldDefinition(h.db, def); err != nil {
+		response.Fail(c, "更新失败", err.Error())
+		return
+	}
+	response.Success(c, "更新成功", def)
+}
+
+// DeleteCustomFieldDefinition deletes a definition and all of its values
+// DELETE /group/:id/custom-fields/:fieldId
+func (h *Handlers) DeleteCustomFieldDefinition(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	def, ok := h.customFieldDefinition(c, group.ID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteCustomFieldDefinition(h.db, def); err != nil {
+		response.Fail(c, "删除失败", err.Error())
+		return
+	}
+	response.Success(c, "删除成功", nil)
+}
+
+// GetDeviceCustomFields gets custom field values of a device
+// GET /device/:deviceId/custom-fields
+func (h *Handlers) GetDeviceCustomFields(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+
+	values, err := models.GetCustomFieldValues(h.db, models.CustomFieldEntityDevice, device.ID)
+	if err != nil {
+		response.Fail(c, "Failed to query custom fields", err.Error())
+		return
+	}
+	response.Success(c, "Query successful", values)
+}
+
+// UpdateDeviceCustomFields sets custom field values of a device, null removes a field
+// PUT /device/:deviceId/custom-fields
+func (h *Handlers) UpdateDeviceCustomFields(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+	if device.GroupID == nil {
+		response.Fail(c, models.ErrCustomFieldsRequireGroup.Error(), nil)
+		return
+	}
+
+	var values map[string]interface{}
+	if err := c.ShouldBindJSON(&values); err != nil {
+		response.Fail(c, "Invalid parameters", err.Error())
+		return
+	}
+
+	result, err := models.SetCustomFieldValues(h.db, *device.GroupID, models.CustomFieldEntityDevice, device.ID, values)
+	if err != nil {
+		response.Fail(c, "Failed to update custom fields", err.Error())
+		return
+	}
+	h.invalidateDeviceCache(device.UserID, device.GroupID)
+	response.Success(c, "Update successful", result)
+}
+
+// GetAssistantCustomFields gets custom field values of an assistant
+// GET /assistant/:id/custom-fields
+func (h *Handlers) GetAssistantCustomFields(c *gin.Context) {
+	assistant, ok := h.customFieldAssistant(c)
+	if !ok {
+		return
+	}
+
+	values, err := models.GetCustomFieldValues(h.db, models.CustomFieldEntityAssistant, strconv.FormatInt(assistant.ID, 10))
+	if err != nil {
+		response.Fail(c, "select custom fields failed", err.Error())
+		return
+	}
+	response.Success(c, "select custom fields successful", values)
+}
+
+// UpdateAssistantCustomFields sets custom field values of an assistant, null removes a field
+// PUT /assistant/:id/custom-fields
+func (h *Handlers) UpdateAssistantCustomFields(c *gin.Context) {
+	assistant, ok := h.customFieldAssistant(c)
+	if !ok {
+		return
+	}
+	if assistant.GroupID == nil {
+		response.Fail(c, models.ErrCustomFieldsRequireGroup.Error(), nil)
+		return
+	}
+
+	var values map[string]interface{}
+	if err := c.ShouldBindJSON(&values); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	result, err := models.SetCustomFieldValues(h.db, *assistant.GroupID, models.CustomFieldEntityAssistant, strconv.FormatInt(assistant.ID, 10), values)
+	if err != nil {
+		response.Fail(c, "update custom fields failed", err.Error())
+		return
+	}
+	h.invalidateAssistantCache(assistant.UserID, assistant.GroupID)
+	response.Success(c, "update custom fields successful", result)
+}
+
+// customFieldFilters extracts cf.<key>=<value> query parameters
+func customFieldFilters(c *gin.Context) map[string]string {
+	filters := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(key, models.CustomFieldFilterPrefix) || len(values) == 0 {
+			continue
+		}
+		fieldKey := strings.TrimPrefix(key, models.CustomFieldFilterPrefix)
+		if fieldKey != "" {
+			filters[fieldKey] = values[0]
+		}
+	}
+	return filters
+}
+
+// customFieldMatches resolves the cf.* query parameters to the set of matching entity IDs.
+// filtered is false when the request has no custom field filters.
+func (h *Handlers) customFieldMatches(c *gin.Context, entityType models.CustomFieldEntity) (ids map[string]struct{}, filtered bool, err error) {
+	filters := customFieldFilters(c)
+	if len(filters) == 0 {
+		return nil, false, nil
+	}
+	matched, err := models.FindEntityIDsByCustomFields(h.db, entityType, filters)
+	if err != nil {
+		return nil, true, err
+	}
+	ids = make(map[string]struct{}, len(matched))
+	for _, id := range matched {
+		ids[id] = struct{}{}
+	}
+	return ids, true, nil
+}
+
+// customFieldGroup loads the organization from :id and checks membership (or admin rights)
+func (h *Handlers) customFieldGroup(c *gin.Context, requireAdmin bool) (*models.Group, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "未授权", "用户未登录")
+		return nil, false
+	}
+
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "参数错误", "无效的组织ID")
+		return nil, false
+	}
+
+	var group models.Group
+	if err := h.db.First(&group, groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "组织不存在", nil)
+		} else {
+			response.Fail(c, "查询失败", err.Error())
+		}
+		return nil, false
+	}
+
+	if requireAdmin {
+		if !models.IsGroupAdmin(h.db, &group, user.ID) {
+			response.Fail(c, "权限不足", "只有创建者或管理员可以管理自定义字段")
+			return nil, false
+		}
+	} else if !models.IsGroupMember(h.db, &group, user.ID) {
+		response.Fail(c, "权限不足", "您不是该组织的成员")
+		return nil, false
+	}
+	return &group, true
+}
+
+// customFieldDefinition loads the definition from :fieldId within the organization
+func (h *Handlers) customFieldDefinition(c *gin.Context, groupID uint) (*models.CustomFieldDefinition, bool) {
+	fieldID, err := strconv.ParseUint(c.Param("fieldId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "参数错误", "无效的字段ID")
+		return nil, false
+	}
+
+	var def models.CustomFieldDefinition
+	if err := h.db.Where("id = ? AND group_id = ?", fieldID, groupID).First(&def).Error; err != nil {
+		response.Fail(c, "字段不存在", nil)
+		return nil, false
+	}
+	return &def, true
+}
+
+// customFieldDevice loads the device from :deviceId and checks the user can access it
+func (h *Handlers) customFieldDevice(c *gin.Context) (*models.Device, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not logged in", nil)
+		return nil, false
+	}
+
+	device, err := models.GetDeviceByID(h.db, c.Param("deviceId"))
+	if err != nil || device == nil {
+		response.Fail(c, "Device not found", nil)
+		return nil, false
+	}
+
+	if device.UserID != user.ID {
+		if device.GroupID == nil {
+			response.Fail(c, "Insufficient permissions", nil)
+			return nil, false
+		}
+		var group models.Group
+		if err := h.db.First(&group, *device.GroupID).Error; err != nil || !models.IsGroupMember(h.db, &group, user.ID) {
+			response.Fail(c, "Insufficient permissions", nil)
+			return nil, false
+		}
+	}
+	return device, true
+}
+
+// customFieldAssistant loads the assistant from :id, accessible to its owner and to the
+// members of the organization it belongs to
+func (h *Handlers) customFieldAssistant(c *gin.Context) (*models.Assistant, bool) {
+	return h.loadAssistantParam(c, func(assistant *models.Assistant, userID uint) bool {
+		if assistant.UserID == userID {
+			return true
+		}
+		if assistant.GroupID == nil {
+			return false
+		}
+		var group models.Group
+		return h.db.First(&group, *assistant.GroupID).Error == nil && models.IsGroupMember(h.db, &group, userID)
+	})
+}
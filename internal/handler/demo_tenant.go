@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SeedDemoTenantRequest demo tenant options, zero values use the defaults
+type SeedDemoTenantRequest struct {
+	Password string `json:"password"`
+	Devices  int    `json:"devices"`
+}
+
+// SeedDemoTenant creates the demo organization with its users, assistant, knowledge
+// base, SIP users and devices, or returns the existing one brought up to date.
+// Safe to call repeatedly; not available in production.
+// POST /demo-tenant
+func (h *Handlers) SeedDemoTenant(c *gin.Context) {
+	if utils.GetEnv("APP_ENV") == "production" {
+		response.Fail(c, "demo tenant is not available in production", nil)
+		return
+	}
+	var req SeedDemoTenantRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "invalid request", err.Error())
+			return
+		}
+	}
+	tenant, err := models.SeedDemoTenant(h.db, models.DemoTenantOptions{
+		Password:    req.Password,
+		Devices:     req.Devices,
+		DocumentDir: knowledgeSourceDir,
+	})
+	if err != nil {
+		response.Fail(c, "failed to seed demo tenant", err.Error())
+		return
+	}
+	response.Success(c, "demo tenant ready", tenant)
+}
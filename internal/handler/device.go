@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -91,20 +92,6 @@ func (h *Handlers) BindDevice(c *gin.Context) {
 
 	logger.Info("设备数据验证成功", zap.Any("deviceData", dataMap))
 
-	// Check if device has already been activated
-	logger.Info("检查设备是否已激活", zap.String("deviceId", deviceId))
-	existingDevice, err := models.GetDeviceByMacAddress(h.db, deviceId)
-	if err != nil {
-		logger.Warn("查询现有设备时出错", zap.Error(err), zap.String("deviceId", deviceId))
-	}
-	if existingDevice != nil {
-		logger.Error("设备绑定失败：设备已被激活",
-			zap.String("deviceId", deviceId),
-			zap.Uint("existingUserId", existingDevice.UserID))
-		response.Fail(c, "Device has already been activated", nil)
-		return
-	}
-
 	// Get current user
 	user := models.CurrentUser(c)
 	if user == nil {
@@ -194,7 +181,13 @@ func (h *Handlers) BindDevice(c *gin.Context) {
 
 	logger.Info("开始创建设备记录", zap.Any("deviceData", newDevice))
 
+	// Creating is atomic on the MAC address, an already activated device is rejected here
 	if err := models.CreateDevice(h.db, newDevice); err != nil {
+		if errors.Is(err, models.ErrDeviceExists) {
+			logger.Error("设备绑定失败：设备已被激活", zap.String("deviceId", deviceId))
+			response.Fail(c, "Device has already been activated", nil)
+			return
+		}
 		logger.Error("创建设备失败",
 			zap.Error(err),
 			zap.String("deviceId", deviceId),
@@ -222,6 +215,7 @@ func (h *Handlers) BindDevice(c *gin.Context) {
 		zap.Uint("userId", user.ID),
 		zap.Uint("assistantID", assistantID))
 
+	h.invalidateDeviceCache(user.ID, nil)
 	response.Success(c, "Device activated successfully", nil)
 }
 
@@ -253,6 +247,26 @@ func (h *Handlers) GetUserDevices(c *gin.Context) {
 		return
 	}
 
+	// Filter by custom fields (?cf.<key>=<value>)
+	matched, filtered, err := h.customFieldMatches(c, models.CustomFieldEntityDevice)
+	if err != nil {
+		logger.Error("Failed to query device custom fields", zap.Error(err))
+		response.Fail(c, "Failed to query devices", nil)
+		return
+	}
+	if filtered {
+		result := make([]models.Device, 0, len(devices))
+		for _, device := range devices {
+			if _, ok := matched[device.ID]; ok {
+				result = append(result, device)
+			}
+		}
+		devices = result
+	}
+	if err := models.AttachDeviceCustomFields(h.db, devices); err != nil {
+		logger.Warn("Failed to load device custom fields", zap.Error(err))
+	}
+
 	response.Success(c, "Query successful", devices)
 }
 
@@ -294,7 +308,11 @@ func (h *Handlers) UnbindDevice(c *gin.Context) {
 		response.Fail(c, "Failed to delete device", nil)
 		return
 	}
+	if err := models.RecordSyncTombstone(h.db, device.UserID, models.SyncEntityDevice, device.ID); err != nil {
+		logger.Warn("Failed to record sync tombstone", zap.String("deviceId", device.ID), zap.Error(err))
+	}
 
+	h.invalidateDeviceCache(device.UserID, device.GroupID)
 	response.Success(c, "Device unbound successfully", nil)
 }
 
@@ -304,9 +322,10 @@ func (h *Handlers) UpdateDeviceInfo(c *gin.Context) {
 	deviceID := c.Param("id")
 
 	var req struct {
-		Alias      string `json:"alias"`
-		AutoUpdate *int   `json:"autoUpdate"`
-		GroupID    *uint  `json:"groupId,omitempty"` // 组织ID，如果设置则表示这是组织共享的设备
+		Alias      string  `json:"alias"`
+		AutoUpdate *int    `json:"autoUpdate"`
+		GroupID    *uint   `json:"groupId,omitempty"` // 组织ID，如果设置则表示这是组织共享的设备
+		Region     *string `json:"region,omitempty"`  // 固定的部署地区，空字符串表示取消固定
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -350,6 +369,7 @@ func (h *Handlers) UpdateDeviceInfo(c *gin.Context) {
 	}
 
 	// 如果更新了 GroupID，验证权限
+	previousGroupID := device.GroupID
 	if req.GroupID != nil {
 		var group models.Group
 		if err := h.db.Where("id = ?", *req.GroupID).First(&group).Error; err != nil {
@@ -373,6 +393,13 @@ func (h *Handlers) UpdateDeviceInfo(c *gin.Context) {
 	if req.AutoUpdate != nil {
 		device.AutoUpdate = *req.AutoUpdate
 	}
+	if req.Region != nil {
+		if err := models.ValidateRegionPin(h.db, *req.Region); err != nil {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		device.Region = *req.Region
+	}
 
 	if err := models.UpdateDevice(h.db, device); err != nil {
 		logger.Error("Failed to update device", zap.Error(err))
@@ -380,6 +407,10 @@ func (h *Handlers) UpdateDeviceInfo(c *gin.Context) {
 		return
 	}
 
+	h.invalidateDeviceCache(device.UserID, device.GroupID)
+	if previousGroupID != nil && (device.GroupID == nil || *previousGroupID != *device.GroupID) {
+		h.invalidateDeviceCache(device.UserID, previousGroupID)
+	}
 	response.Success(c, "Update successful", device)
 }
 
@@ -418,20 +449,6 @@ func (h *Handlers) ManualAddDevice(c *gin.Context) {
 
 	logger.Info("MAC地址格式验证通过", zap.String("macAddress", req.MacAddress))
 
-	// Check if MAC address already exists
-	logger.Info("检查MAC地址是否已存在", zap.String("macAddress", req.MacAddress))
-	existingDevice, err := models.GetDeviceByMacAddress(h.db, req.MacAddress)
-	if err != nil {
-		logger.Warn("查询现有设备时出错", zap.Error(err), zap.String("macAddress", req.MacAddress))
-	}
-	if existingDevice != nil {
-		logger.Error("手动添加设备失败：MAC地址已存在",
-			zap.String("macAddress", req.MacAddress),
-			zap.Uint("existingUserId", existingDevice.UserID))
-		response.Fail(c, "MAC address already exists", nil)
-		return
-	}
-
 	// 获取当前用户
 	user := models.CurrentUser(c)
 	if user == nil {
@@ -536,6 +553,11 @@ func (h *Handlers) ManualAddDevice(c *gin.Context) {
 	logger.Info("开始创建设备记录", zap.Any("deviceData", newDevice))
 
 	if err := models.CreateDevice(h.db, newDevice); err != nil {
+		if errors.Is(err, models.ErrDeviceExists) {
+			logger.Error("手动添加设备失败：MAC地址已存在", zap.String("macAddress", req.MacAddress))
+			response.Fail(c, "MAC address already exists", nil)
+			return
+		}
 		logger.Error("创建设备失败",
 			zap.Error(err),
 			zap.String("macAddress", req.MacAddress),
@@ -551,6 +573,7 @@ func (h *Handlers) ManualAddDevice(c *gin.Context) {
 
 	logger.Info("设备创建成功", zap.String("macAddress", req.MacAddress))
 
+	h.invalidateDeviceCache(newDevice.UserID, newDevice.GroupID)
 	response.Success(c, "Device added successfully", newDevice)
 }
 
@@ -628,6 +651,11 @@ func (h *Handlers) GetDeviceConfig(c *gin.Context) {
 		config["knowledgeBaseId"] = *assistant.KnowledgeBaseID
 	}
 
+	// 固定地区的 SIP/RTP 端点（可选）
+	if region := models.PinnedRegion(h.db, device.Region); region != nil {
+		config["region"] = region.Endpoints()
+	}
+
 	logger.Info("Device config requested",
 		zap.String("deviceID", deviceID),
 		zap.Int64("assistantID", int64(assistantID)))
@@ -649,6 +677,7 @@ func (h *Handlers) UpdateDeviceStatus(c *gin.Context) {
 		NetworkInfo   map[string]interface{} `json:"networkInfo"`
 		AudioStatus   map[string]interface{} `json:"audioStatus"`
 		ServiceStatus map[string]interface{} `json:"serviceStatus"`
+		Location      *deviceLocationPayload `json:"location"` // 可选的地理位置上报
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -716,6 +745,19 @@ func (h *Handlers) UpdateDeviceStatus(c *gin.Context) {
 		return
 	}
 
+	// 位置上报失败不影响状态更新
+	if req.Location != nil || req.IsOnline != nil {
+		if device, err := models.GetDeviceByMacAddress(h.db, req.MacAddress); err == nil && device != nil {
+			if req.Location != nil {
+				if _, err := h.recordDeviceLocation(c, device, req.Location); err != nil {
+					logger.Warn("记录设备位置失败", zap.Error(err), zap.String("mac_address", req.MacAddress))
+				}
+			}
+			// 在线状态变化需要立即反映到设备列表，其余指标由缓存 TTL 兜底
+			h.invalidateDeviceCache(device.UserID, device.GroupID)
+		}
+	}
+
 	response.Success(c, "设备状态更新成功", nil)
 }
 
@@ -1058,6 +1100,23 @@ func (h *Handlers) AnalyzeCallRecording(c *gin.Context) {
 			return
 		}
 
+		// 说话人分离，会议或转接等多人通话按说话人区分用户发言
+		if diarized := h.diarizeCallRecording(&recording); diarized != nil {
+			conversationDetails = diarized
+		}
+
+		// 重新标记个人信息，分离后的轮次或更新过的检测规则都需要重新检测
+		if detected, err := models.DetectCallRecordingPII(h.db, &recording, time.Now()); err != nil {
+			logger.Warn("个人信息检测失败", zap.Error(err), zap.Uint("recordingID", recording.ID))
+		} else {
+			conversationDetails = detected
+		}
+
+		// 分析使用用户修正后的转写
+		if err := models.ApplyTranscriptCorrections(h.db, recording.ID, conversationDetails); err != nil {
+			logger.Warn("应用转写修正失败", zap.Error(err), zap.Uint("recordingID", recording.ID))
+		}
+
 		// 获取助手信息
 		var assistant models.Assistant
 		if err := h.db.Where("id = ?", recording.AssistantID).First(&assistant).Error; err != nil {
@@ -1085,7 +1144,9 @@ func (h *Handlers) AnalyzeCallRecording(c *gin.Context) {
 		// 构建对话文本
 		conversationText := ""
 		for _, turn := range conversationDetails.Turns {
-			if turn.Type == "user" {
+			if turn.Type == "user" && turn.SpeakerID != "" && len(conversationDetails.Speakers) > 1 {
+				conversationText += fmt.Sprintf("用户(%s): %s\n", conversationDetails.SpeakerName(turn.SpeakerID), turn.Content)
+			} else if turn.Type == "user" {
 				conversationText += fmt.Sprintf("用户: %s\n", turn.Content)
 			} else if turn.Type == "ai" {
 				conversationText += fmt.Sprintf("AI: %s\n", turn.Content)
@@ -1121,6 +1182,8 @@ func (h *Handlers) AnalyzeCallRecording(c *gin.Context) {
 7. actionItems: 行动项列表
 8. issues: 问题列表
 9. insights: 深度洞察
+10. resolved: AI是否已解决用户的问题（布尔值）
+11. escalationReason: 未解决时需要人工跟进的原因
 
 对话内容：
 %s
@@ -1175,6 +1238,9 @@ func (h *Handlers) AnalyzeCallRecording(c *gin.Context) {
 		}
 
 		logger.Info("通话记录分析完成", zap.Uint("recordingID", recording.ID))
+
+		// AI未能解决的问题自动创建工单
+		h.autoEscalateCallRecording(&recording, analysisResult)
 	}()
 
 	response.Success(c, "分析已启动", nil)
@@ -1277,8 +1343,8 @@ func (h *Handlers) GetCallRecordingDetail(c *gin.Context) {
 		return
 	}
 
-	// 获取真实的对话详情数据
-	conversationDetails, err := recording.GetConversationDetails()
+	// 获取真实的对话详情数据，已采纳的转写修正覆盖在识别结果上
+	conversationDetails, err := models.CorrectedConversationDetails(h.db, &recording)
 	if err != nil {
 		logger.Error("解析对话详情失败", zap.Error(err), zap.Uint64("recordingID", recordingID))
 	}
@@ -1303,6 +1369,8 @@ func (h *Handlers) GetCallRecordingDetail(c *gin.Context) {
 		"storageUrl":      recording.StorageURL,
 		"audioFormat":     recording.AudioFormat,
 		"audioSize":       recording.AudioSize,
+		"audioSha256":     recording.AudioSHA256,
+		"hashedAt":        recording.HashedAt,
 		"duration":        recording.Duration,
 		"sampleRate":      recording.SampleRate,
 		"channels":        recording.Channels,
@@ -1332,6 +1400,13 @@ func (h *Handlers) GetCallRecordingDetail(c *gin.Context) {
 		"asrProvider": recording.ASRProvider,
 	}
 
+	// 按组织策略遮盖个人信息
+	if conversationDetails != nil {
+		if types, mask := models.PIIMaskForViewer(h.db, &recording, user); mask {
+			conversationDetails.MaskPII(types)
+		}
+	}
+
 	// 添加真实的对话详情数据（如果存在）
 	if conversationDetails != nil {
 		detailResponse["conversationDetailsData"] = conversationDetails
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/actionmanifest"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// actionManifestRequest body of the manifest builder
+type actionManifestRequest struct {
+	Action     string          `json:"action" binding:"required"`
+	Params     json.RawMessage `json:"params"`
+	TTLMinutes int             `json:"ttlMinutes"` // 60 when omitted, at most 7 days
+}
+
+// actionManifestView a manifest with the signed envelope the device receives
+func actionManifestView(m *models.DeviceActionManifest) gin.H {
+	return gin.H{
+		"manifest": m,
+		"signed":   m.Signed(),
+	}
+}
+
+// requestDevice resolves the registered device calling a device-side endpoint from its Device-Id header
+func (h *Handlers) requestDevice(c *gin.Context) (*models.Device, bool) {
+	deviceID := c.GetHeader("Device-Id")
+	if deviceID == "" {
+		deviceID = c.GetHeader("device-id")
+	}
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Device ID is required"})
+		return nil, false
+	}
+	device, err := models.GetDeviceByMacAddress(h.db, deviceID)
+	if err != nil || device == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found or not activated"})
+		return nil, false
+	}
+	return device, true
+}
+
+// ListDeviceActionSpecs lists the actions a manifest may carry and their params
+// GET /device/action-specs
+func (h *Handlers) ListDeviceActionSpecs(c *gin.Context) {
+	response.Success(c, "success", actionmanifest.Specs())
+}
+
+// CreateDeviceActionManifest builds and signs a manifest for one of the
+// predefined actions. Disruptive actions are queued until the fleet's
+// maintenance window opens; the device picks the manifest up afterwards.
+// POST /device/:deviceId/action-manifests
+func (h *Handlers) CreateDeviceActionManifest(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+	var req actionManifestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid parameters", err.Error())
+		return
+	}
+	ttl := time.Duration(req.TTLMinutes) * time.Minute
+	user := models.CurrentUser(c)
+	m, err := models.IssueDeviceActionManifest(h.db, device, req.Action, req.Params, ttl, user.ID, time.Now())
+	if err != nil {
+		if errors.Is(err, actionmanifest.ErrUnknownAction) || errors.Is(err, actionmanifest.ErrInvalidParams) {
+			response.Fail(c, "Invalid action", err.Error())
+			return
+		}
+		response.Fail(c, "Failed to issue action manifest", err.Error())
+		return
+	}
+	logger.Info("Device action manifest issued",
+		zap.String("deviceId", device.ID),
+		zap.String("manifestId", m.ManifestID),
+		zap.String("action", m.Action),
+		zap.Uint("userId", user.ID))
+	response.Success(c, "Action manifest issued", actionManifestView(m))
+}
+
+// ListDeviceActionManifests lists the device's manifests with their receipts
+// GET /device/:deviceId/action-manifests
+func (h *Handlers) ListDeviceActionManifests(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	manifests, err := models.ListDeviceActionManifests(h.db, device.ID, limit)
+	if err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+	response.Success(c, "Query successful", manifests)
+}
+
+// GetDeviceActionKeys publishes the keys devices verify manifests with: the
+// active key and keys rotated out within the grace period
+// GET /device/action-keys
+func (h *Handlers) GetDeviceActionKeys(c *gin.Context) {
+	keys, err := models.PublishedVerificationKeys(h.db, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load verification keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// GetPendingActionManifests returns the signed manifests the calling device
+// should verify and execute
+// GET /device/action-manifests/pending
+func (h *Handlers) GetPendingActionManifests(c *gin.Context) {
+	device, ok := h.requestDevice(c)
+	if !ok {
+		return
+	}
+	manifests, err := models.PendingDeviceActionManifests(h.db, device.ID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load action manifests"})
+		return
+	}
+	signed := make([]*actionmanifest.Signed, 0, len(manifests))
+	for i := range manifests {
+		signed = append(signed, manifests[i].Signed())
+	}
+	c.JSON(http.StatusOK, gin.H{"manifests": signed})
+}
+
+// PostActionManifestReceipt records the device's execution receipt for a manifest:
+// succeeded, failed, or rejected when verification did not pass
+// POST /device/action-manifests/:manifestId/receipt
+func (h *Handlers) PostActionManifestReceipt(c *gin.Context) {
+	device, ok := h.requestDevice(c)
+	if !ok {
+		return
+	}
+	var receipt models.ManifestReceipt
+	if err := c.ShouldBindJSON(&receipt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := models.RecordManifestReceipt(h.db, device.ID, c.Param("manifestId"), &receipt, time.Now())
+	switch {
+	case errors.Is(err, models.ErrManifestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrInvalidManifestReceipt):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrManifestReceiptRecorded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record receipt"})
+		return
+	}
+	if m.Status == models.ManifestRejected {
+		logger.Warn("Device rejected action manifest",
+			zap.String("deviceId", device.ID),
+			zap.String("manifestId", m.ManifestID),
+			zap.String("reason", m.ReceiptError))
+	}
+	c.JSON(http.StatusOK, gin.H{"manifestId": m.ManifestID, "status": m.Status})
+}
+
+// ListDeviceSigningKeys lists the manifest signing keys still published to devices
+// GET /device-signing-keys
+func (h *Handlers) ListDeviceSigningKeys(c *gin.Context) {
+	if _, err := models.ActiveDeviceSigningKey(h.db); err != nil {
+		response.Fail(c, "Failed to load signing keys", err.Error())
+		return
+	}
+	keys, err := models.ListDeviceSigningKeys(h.db, time.Now())
+	if err != nil {
+		response.Fail(c, "Failed to load signing keys", err.Error())
+		return
+	}
+	response.Success(c, "success", keys)
+}
+
+// RotateDeviceSigningKey replaces the manifest signing key. The previous key
+// stays published for the grace period so devices can pick up the new key on
+// their next OTA check before manifests signed with the old key expire.
+// POST /device-signing-keys/rotate
+func (h *Handlers) RotateDeviceSigningKey(c *gin.Context) {
+	key, err := models.RotateDeviceSigningKey(h.db, time.Now())
+	if err != nil {
+		response.Fail(c, "Failed to rotate signing key", err.Error())
+		return
+	}
+	logger.Info("Device signing key rotated", zap.String("keyId", key.KeyID), zap.Uint("userId", models.CurrentUser(c).ID))
+	response.Success(c, "Signing key rotated", key)
+}
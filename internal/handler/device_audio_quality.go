@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/alert"
+	"github.com/code-100-precent/LingEcho/pkg/audioquality"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// audioTestMaxUpload largest test recording accepted, 30s of 48kHz stereo WAV fits
+const audioTestMaxUpload = 8 << 20
+
+// UploadDeviceAudioTest analyzes a short microphone test recording from the
+// device (noise floor, clipping, frequency response), stores the quality score on
+// the device and flags it for replacement below the threshold. The recording is
+// sent as multipart field "file" (16-bit PCM WAV, or raw 16-bit mono PCM with
+// "sampleRate") together with "macAddress"; it is not kept.
+// POST /device/audio-test
+func (h *Handlers) UploadDeviceAudioTest(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, audioTestMaxUpload)
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		response.Fail(c, "Invalid parameters", "file is required")
+		return
+	}
+	defer file.Close()
+	device, ok := h.locationDevice(c, c.PostForm("macAddress"))
+	if !ok {
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		response.Fail(c, "Failed to read recording", err.Error())
+		return
+	}
+
+	samples, sampleRate, err := audioquality.DecodeWAV(data)
+	if errors.Is(err, audioquality.ErrUnsupportedFormat) && c.PostForm("sampleRate") != "" {
+		sampleRate, err = strconv.Atoi(c.PostForm("sampleRate"))
+		if err != nil || sampleRate < 8000 || sampleRate > 48000 {
+			response.Fail(c, "Invalid sampleRate", "expected 8000 to 48000")
+			return
+		}
+		samples = audioquality.DecodePCM(data)
+	} else if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	report, err := audioquality.Analyze(samples, sampleRate)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+
+	threshold := models.AudioQualityThreshold(h.db)
+	test, flagged, err := models.RecordDeviceAudioTest(h.db, device, report, threshold)
+	if err != nil {
+		response.Fail(c, "Failed to save audio test", err.Error())
+		return
+	}
+	if flagged {
+		if err := alert.NewTriggerService(h.db).TriggerAudioQualityAlert(device.UserID, device.ID, device.DeviceName, report.Score, threshold, report.Issues); err != nil {
+			logger.Warn("Failed to trigger audio quality alert", zap.String("deviceId", device.ID), zap.Error(err))
+		}
+	}
+	h.invalidateDeviceCache(device.UserID, device.GroupID)
+
+	response.Success(c, "Audio test analyzed", gin.H{
+		"test":             test,
+		"report":           report,
+		"needsReplacement": device.NeedsReplacement,
+	})
+}
+
+// ListDeviceAudioTests lists the device's microphone tests, newest first
+// GET /device/:deviceId/audio-tests
+func (h *Handlers) ListDeviceAudioTests(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+	var tests []models.DeviceAudioTest
+	if err := h.db.Where("device_id = ?", device.ID).Order("id DESC").Limit(50).Find(&tests).Error; err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+	response.Success(c, "Query successful", tests)
+}
+
+// GetDevicesNeedingReplacement lists the user's and organization devices whose
+// last microphone test scored below the threshold
+// GET /device/audio-quality/flagged
+func (h *Handlers) GetDevicesNeedingReplacement(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not logged in", nil)
+		return
+	}
+	devices, err := models.GetUserDevices(h.db, user.ID, nil)
+	if err != nil {
+		logger.Error("Failed to query devices", zap.Error(err))
+		response.Fail(c, "Failed to query devices", nil)
+		return
+	}
+	flagged := make([]models.Device, 0)
+	for _, device := range devices {
+		if device.NeedsReplacement {
+			flagged = append(flagged, device)
+		}
+	}
+	response.Success(c, "Query successful", gin.H{
+		"devices":   flagged,
+		"threshold": models.AudioQualityThreshold(h.db),
+	})
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// claimDeviceRequest a technician binds a device to one of the organization's assistants
+type claimDeviceRequest struct {
+	ActivationCode string `json:"activationCode" binding:"required"`
+	AssistantID    uint   `json:"assistantId" binding:"required"`
+	DeviceName     string `json:"deviceName"`
+}
+
+// pendingActivation device data cached by the OTA check until the activation code is used
+type pendingActivation struct {
+	DeviceID   string
+	MacAddress string
+	Board      string
+	AppVersion string
+	keys       []string
+}
+
+// lookupActivation resolves an activation code the same way BindDevice does
+func lookupActivation(ctx context.Context, code string) (*pendingActivation, bool) {
+	cacheClient := cache.GetGlobalCache()
+	deviceKey := fmt.Sprintf("ota:activation:code:%s", code)
+	obj, ok := cacheClient.Get(ctx, deviceKey)
+	if !ok {
+		return nil, false
+	}
+	deviceID, ok := obj.(string)
+	if !ok {
+		return nil, false
+	}
+	dataKey := fmt.Sprintf("ota:activation:data:%s", strings.ReplaceAll(strings.ToLower(deviceID), ":", "_"))
+	obj, ok = cacheClient.Get(ctx, dataKey)
+	if !ok {
+		return nil, false
+	}
+	data, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if cached, _ := data["activation_code"].(string); cached != code {
+		return nil, false
+	}
+	activation := &pendingActivation{DeviceID: deviceID, Board: "default", AppVersion: "1.0.0", MacAddress: deviceID, keys: []string{dataKey, deviceKey}}
+	if v, _ := data["mac_address"].(string); v != "" {
+		activation.MacAddress = v
+	}
+	if v, _ := data["board"].(string); v != "" {
+		activation.Board = v
+	}
+	if v, _ := data["app_version"].(string); v != "" {
+		activation.AppVersion = v
+	}
+	return activation, true
+}
+
+// issueTechnicianToken ends a device claim portal SSO login: instead of starting a console session
+// it hands a scoped token to the portal in the URL fragment, which never reaches server logs
+func (h *Handlers) issueTechnicianToken(c *gin.Context, conn *models.SamlConnection, user *models.User, redirect string, fail func(string, error)) {
+	if !conn.TechnicianPortal {
+		fail(user.Email, errors.New("the device claim portal is not enabled for this organization"))
+		return
+	}
+	token, plain, err := models.IssueTechnicianToken(h.db, conn.GroupID, user, time.Now())
+	if err != nil {
+		fail(user.Email, err)
+		return
+	}
+	h.recordSSOEvent(c, &models.SSOEvent{
+		GroupID: conn.GroupID, UserID: user.ID, Email: user.Email, Event: models.SSOEventTechnicianToken, Success: true,
+		Detail: fmt.Sprintf("token=%s expires=%s", token.TokenPrefix, token.ExpiresAt.UTC().Format(time.RFC3339)),
+	})
+	fragment := url.Values{"token": {plain}, "expiresAt": {token.ExpiresAt.UTC().Format(time.RFC3339)}}
+	c.Redirect(http.StatusFound, redirect+"#"+fragment.Encode())
+}
+
+// GetTechnicianSession describes the current technician token
+// GET /device-claim/session
+func (h *Handlers) GetTechnicianSession(c *gin.Context) {
+	token := models.CurrentTechnicianToken(c)
+	var group models.Group
+	h.db.Select("id", "name").First(&group, token.GroupID)
+	response.Success(c, "success", gin.H{
+		"technicianId": token.UserID,
+		"email":        token.Email,
+		"groupId":      token.GroupID,
+		"groupName":    group.Name,
+		"scopes":       strings.Split(token.Scopes, ","),
+		"expiresAt":    token.ExpiresAt,
+	})
+}
+
+// ListClaimAssistants lists the organization's assistants a device can be claimed for
+// GET /device-claim/assistants
+func (h *Handlers) ListClaimAssistants(c *gin.Context) {
+	token := models.CurrentTechnicianToken(c)
+	var assistants []struct {
+		ID          int64  `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := h.db.Model(&models.Assistant{}).Select("id", "name", "description").
+		Where("group_id = ?", token.GroupID).Order("name ASC").Find(&assistants).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", assistants)
+}
+
+// ClaimDevice activates a device for an organization assistant with its activation code
+// POST /device-claim/claims
+func (h *Handlers) ClaimDevice(c *gin.Context) {
+	token := models.CurrentTechnicianToken(c)
+	var req claimDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	var assistant models.Assistant
+	if err := h.db.Where("id = ? AND group_id = ?", req.AssistantID, token.GroupID).First(&assistant).Error; err != nil {
+		response.Fail(c, "Assistant does not exist", nil)
+		return
+	}
+	ctx := c.Request.Context()
+	activation, ok := lookupActivation(ctx, strings.TrimSpace(req.ActivationCode))
+	if !ok {
+		response.Fail(c, "激活码错误", nil)
+		return
+	}
+
+	now := time.Now()
+	groupID := token.GroupID
+	assistantID := uint(assistant.ID)
+	device := &models.Device{
+		ID:            activation.DeviceID,
+		MacAddress:    activation.MacAddress,
+		DeviceName:    strings.TrimSpace(req.DeviceName),
+		Board:         activation.Board,
+		AppVersion:    activation.AppVersion,
+		UserID:        assistant.UserID, // Organization devices stay owned by the assistant owner, not the technician
+		GroupID:       &groupID,
+		AssistantID:   &assistantID,
+		AutoUpdate:    1,
+		LastConnected: &now,
+		LastSeen:      &now,
+	}
+	if err := models.CreateDevice(h.db, device); err != nil {
+		if errors.Is(err, models.ErrDeviceExists) {
+			response.Fail(c, "Device has already been activated", nil)
+			return
+		}
+		response.Fail(c, "Failed to create device", err.Error())
+		return
+	}
+	for _, key := range activation.keys {
+		cache.GetGlobalCache().Delete(ctx, key)
+	}
+
+	claim := &models.DeviceClaim{
+		GroupID:      token.GroupID,
+		TechnicianID: token.UserID,
+		TokenID:      token.ID,
+		DeviceID:     device.ID,
+		MacAddress:   device.MacAddress,
+		AssistantID:  assistantID,
+		Board:        device.Board,
+		IPAddress:    c.ClientIP(),
+	}
+	if err := models.RecordDeviceClaim(h.db, claim); err != nil {
+		logger.Warn("Failed to record device claim", zap.String("deviceId", device.ID), zap.Error(err))
+	}
+	h.invalidateDeviceCache(device.UserID, device.GroupID)
+	response.Success(c, "Device activated successfully", claim)
+}
+
+// ListMyDeviceClaims lists the current technician's claims in the organization
+// GET /device-claim/claims
+func (h *Handlers) ListMyDeviceClaims(c *gin.Context) {
+	token := models.CurrentTechnicianToken(c)
+	page, size := claimPage(c)
+	claims, total, err := models.ListDeviceClaims(h.db, token.GroupID, token.UserID, page, size)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"claims": claims, "total": total, "page": page, "size": size})
+}
+
+// GetClaimDeviceDiagnostics returns the health of an organization device and its recent errors
+// GET /device-claim/devices/:deviceId/diagnostics
+func (h *Handlers) GetClaimDeviceDiagnostics(c *gin.Context) {
+	token := models.CurrentTechnicianToken(c)
+	var device models.Device
+	if err := h.db.Where("id = ? AND group_id = ?", c.Param("deviceId"), token.GroupID).First(&device).Error; err != nil {
+		response.Fail(c, "Device does not exist", nil)
+		return
+	}
+	errorLogs, _, err := models.GetDeviceErrorLogs(h.db, device.MacAddress, 20, 0)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	unresolved, _ := models.GetUnresolvedErrorCount(h.db, device.MacAddress)
+	response.Success(c, "success", gin.H{
+		"device": gin.H{
+			"id":          device.ID,
+			"macAddress":  device.MacAddress,
+			"deviceName":  device.DeviceName,
+			"board":       device.Board,
+			"appVersion":  device.AppVersion,
+			"assistantId": device.AssistantID,
+			"isOnline":    device.IsOnline,
+			"lastSeen":    device.LastSeen,
+			"uptime":      device.Uptime,
+			"errorCount":  device.ErrorCount,
+			"lastError":   device.LastError,
+			"lastErrorAt": device.LastErrorAt,
+			"cpuUsage":    device.CPUUsage,
+			"memoryUsage": device.MemoryUsage,
+			"temperature": device.Temperature,
+			"networkInfo": device.NetworkInfo,
+			"audioStatus": device.AudioStatus,
+		},
+		"unresolvedErrors": unresolved,
+		"errorLogs":        errorLogs,
+	})
+}
+
+func claimPage(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	return page, size
+}
+
+// ListGroupDeviceClaims lists the organization's claim history, optionally for one technician
+// GET /group/:id/device-claims?technicianId=
+func (h *Handlers) ListGroupDeviceClaims(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	technicianID, _ := strconv.ParseUint(c.Query("technicianId"), 10, 32)
+	page, size := claimPage(c)
+	claims, total, err := models.ListDeviceClaims(h.db, group.ID, uint(technicianID), page, size)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"claims": claims, "total": total, "page": page, "size": size})
+}
+
+// RevokeGroupDeviceClaim revokes a claim and unbinds the device it activated
+// DELETE /group/:id/device-claims/:claimId
+func (h *Handlers) RevokeGroupDeviceClaim(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	claimID, err := strconv.ParseUint(c.Param("claimId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid claim id", nil)
+		return
+	}
+	claim, err := models.RevokeDeviceClaim(h.db, group.ID, uint(claimID), models.CurrentUser(c).ID, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, "claim not found", nil)
+		return
+	} else if err != nil {
+		response.Fail(c, "revoke failed", err.Error())
+		return
+	}
+	h.invalidateDeviceCache(0, &group.ID)
+	response.Success(c, "revoked", claim)
+}
+
+// ListTechnicianTokens lists the organization's technician tokens, optionally for one technician
+// GET /group/:id/technician-tokens?technicianId=
+func (h *Handlers) ListTechnicianTokens(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	technicianID, _ := strconv.ParseUint(c.Query("technicianId"), 10, 32)
+	tokens, err := models.ListTechnicianTokens(h.db, group.ID, uint(technicianID))
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", tokens)
+}
+
+// RevokeTechnicianToken revokes a single technician token
+// DELETE /group/:id/technician-tokens/:tokenId
+func (h *Handlers) RevokeTechnicianToken(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	tokenID, err := strconv.ParseUint(c.Param("tokenId"), 10, 32)
+	if err != nil || tokenID == 0 {
+		response.Fail(c, "invalid token id", nil)
+		return
+	}
+	h.revokeTechnicianTokens(c, group, 0, uint(tokenID))
+}
+
+// RevokeTechnicianAccess revokes every active token of a technician in the organization
+// DELETE /group/:id/technicians/:userId/tokens
+func (h *Handlers) RevokeTechnicianAccess(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	technicianID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil || technicianID == 0 {
+		response.Fail(c, "invalid user id", nil)
+		return
+	}
+	h.revokeTechnicianTokens(c, group, uint(technicianID), 0)
+}
+
+func (h *Handlers) revokeTechnicianTokens(c *gin.Context, group *models.Group, technicianID, tokenID uint) {
+	revoked, err := models.RevokeTechnicianTokens(h.db, group.ID, technicianID, tokenID, models.CurrentUser(c).ID, time.Now())
+	if err != nil {
+		response.Fail(c, "revoke failed", err.Error())
+		return
+	}
+	response.Success(c, "revoked", gin.H{"revoked": revoked})
+}
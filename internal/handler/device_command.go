@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/actionmanifest"
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ExecuteDeviceCommand runs a device command given as free text ("turn volume
+// down on the kitchen device") or as an explicit intent. Destructive actions
+// are returned with needsConfirmation and must be confirmed separately
+// POST /device/commands
+func (h *Handlers) ExecuteDeviceCommand(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	var req struct {
+		Text   string `json:"text"`
+		Device string `json:"device"`
+		Intent string `json:"intent"`
+		Value  *int   `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	cmd := models.DeviceCommandRequest{Utterance: req.Text, Device: req.Device, Intent: req.Intent, Value: req.Value}
+	if cmd.Intent == "" {
+		if strings.TrimSpace(req.Text) == "" {
+			response.Fail(c, "invalid request", "text or intent is required")
+			return
+		}
+		devices, err := models.GetUserDevices(h.db, user.ID, nil)
+		if err != nil {
+			response.Fail(c, "query failed", err.Error())
+			return
+		}
+		parsed, err := models.ParseDeviceCommand(req.Text, devices)
+		if err != nil {
+			response.Fail(c, "unrecognized command", err.Error())
+			return
+		}
+		if cmd.Device != "" {
+			parsed.Device = cmd.Device
+		}
+		cmd = parsed
+	}
+	cmd.UserID = user.ID
+	cmd.Source = models.DeviceCommandSourceAPI
+
+	result, err := models.ExecuteDeviceCommand(h.db, cmd, time.Now())
+	if err != nil {
+		response.Fail(c, "command failed", err.Error())
+		return
+	}
+	logDeviceCommand(result)
+	response.Success(c, result.Message, result)
+}
+
+// ConfirmDeviceCommand confirms or cancels a destructive device command
+// POST /device/commands/:id/confirm
+func (h *Handlers) ConfirmDeviceCommand(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid id", nil)
+		return
+	}
+	var req struct {
+		Confirm bool `json:"confirm"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	result, err := models.ConfirmDeviceCommand(h.db, user.ID, uint(id), req.Confirm, time.Now())
+	if err != nil {
+		response.Fail(c, "confirm failed", err.Error())
+		return
+	}
+	logDeviceCommand(result)
+	response.Success(c, result.Message, result)
+}
+
+// ListDeviceCommands lists the device commands executed by the user, optionally
+// for one device
+// GET /device/commands?deviceId=&limit=
+func (h *Handlers) ListDeviceCommands(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	logs, err := models.ListDeviceCommandLogs(h.db, user.ID, c.Query("deviceId"), limit)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"commands": logs, "intents": models.DeviceIntents})
+}
+
+// LoadDeviceControlToolsToHandler registers the device control tools when the
+// assistant is allowed to control its owner's devices
+func (h *Handlers) LoadDeviceControlToolsToHandler(handler *llm.LLMHandler, assistantID int64) error {
+	var assistant models.Assistant
+	if err := h.db.First(&assistant, assistantID).Error; err != nil {
+		return fmt.Errorf("failed to load assistant: %w", err)
+	}
+	if !assistant.EnableDeviceControl {
+		return nil
+	}
+
+	intents, _ := json.Marshal(models.DeviceIntents)
+	handler.RegisterFunctionTool("device_control",
+		"Control one of the user's devices, e.g. \"turn volume down on the kitchen device\". Resolve the device by the name the user said.",
+		json.RawMessage(`{"type":"object","properties":{
+			"device":{"type":"string","description":"Device alias or name as the user said it, e.g. kitchen. Empty if the user has one device"},
+			"intent":{"type":"string","enum":`+string(intents)+`,"description":"What to do with the device"},
+			"value":{"type":"integer","description":"Target volume 0-100 for set_volume, or the step for volume_up/volume_down"},
+			"utterance":{"type":"string","description":"The user's request, verbatim"}
+		},"required":["intent"]}`),
+		func(args map[string]interface{}) (string, error) {
+			req := models.DeviceCommandRequest{
+				UserID:      assistant.UserID,
+				AssistantID: &assistant.ID,
+				Source:      models.DeviceCommandSourceChat,
+			}
+			req.Device, _ = args["device"].(string)
+			req.Intent, _ = args["intent"].(string)
+			req.Utterance, _ = args["utterance"].(string)
+			if v, ok := args["value"].(float64); ok {
+				value := int(v)
+				req.Value = &value
+			}
+			result, err := models.ExecuteDeviceCommand(h.db, req, time.Now())
+			if err != nil {
+				return deviceControlToolError(err)
+			}
+			logDeviceCommand(result)
+			if result.NeedsConfirmation {
+				return fmt.Sprintf("Not executed yet: %s. Ask the user to confirm, then call device_control_confirm with confirmationId %d.",
+					result.Message, result.Log.ID), nil
+			}
+			return deviceControlToolResult(result)
+		})
+
+	handler.RegisterFunctionTool("device_control_confirm",
+		"Confirm or cancel a device action that device_control said needs confirmation. Only confirm after the user explicitly agreed.",
+		json.RawMessage(`{"type":"object","properties":{
+			"confirmationId":{"type":"integer","description":"confirmationId returned by device_control"},
+			"confirm":{"type":"boolean","description":"true if the user agreed, false if the user declined"}
+		},"required":["confirmationId","confirm"]}`),
+		func(args map[string]interface{}) (string, error) {
+			id, _ := args["confirmationId"].(float64)
+			confirm, _ := args["confirm"].(bool)
+			result, err := models.ConfirmDeviceCommand(h.db, assistant.UserID, uint(id), confirm, time.Now())
+			if err != nil {
+				return deviceControlToolError(err)
+			}
+			logDeviceCommand(result)
+			if !confirm {
+				return "The action was cancelled.", nil
+			}
+			return deviceControlToolResult(result)
+		})
+	return nil
+}
+
+// deviceControlToolResult reports an issued command back to the model
+func deviceControlToolResult(result *models.DeviceCommandResult) (string, error) {
+	if result.Log.Status != models.DeviceCommandIssued {
+		return "", fmt.Errorf("failed to send the command to %s: %s", result.Device.DisplayName(), result.Log.Error)
+	}
+	return "Done: " + result.Message + ".", nil
+}
+
+// deviceControlToolError turns lookup errors the user can fix into answers the
+// model can relay, instead of failing the tool call
+func deviceControlToolError(err error) (string, error) {
+	switch {
+	case errors.Is(err, models.ErrDeviceAmbiguous):
+		return "Several devices match: " + strings.TrimPrefix(err.Error(), models.ErrDeviceAmbiguous.Error()+": ") + ". Ask the user which one.", nil
+	case errors.Is(err, models.ErrDeviceNotFound):
+		return "No matching device was found. Ask the user for the device name.", nil
+	case errors.Is(err, models.ErrDeviceCommandConfirmExpired):
+		return "The confirmation expired. Ask the user again and call device_control once more.", nil
+	case errors.Is(err, actionmanifest.ErrInvalidParams):
+		return "Invalid value: " + err.Error(), nil
+	}
+	return "", err
+}
+
+func logDeviceCommand(result *models.DeviceCommandResult) {
+	logger.Info("Device command",
+		zap.Uint("id", result.Log.ID),
+		zap.Uint("userId", result.Log.UserID),
+		zap.String("deviceId", result.Log.DeviceID),
+		zap.String("intent", result.Log.Intent),
+		zap.String("action", result.Log.Action),
+		zap.String("source", result.Log.Source),
+		zap.String("status", result.Log.Status))
+}
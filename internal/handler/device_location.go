@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/alert"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// deviceLocationPayload location reported by a device.
+// Latitude/Longitude come from GPS; when they are absent and UseIP is set,
+// the location is derived from the client IP.
+type deviceLocationPayload struct {
+	Latitude   *float64   `json:"latitude"`
+	Longitude  *float64   `json:"longitude"`
+	Accuracy   float64    `json:"accuracy"`
+	UseIP      bool       `json:"useIp"`
+	ReportedAt *time.Time `json:"reportedAt"`
+}
+
+// ReportDeviceLocation records a geolocation report of a device
+// POST /device/location
+func (h *Handlers) ReportDeviceLocation(c *gin.Context) {
+	var req struct {
+		MacAddress string `json:"macAddress" binding:"required"`
+		deviceLocationPayload
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid parameters", err.Error())
+		return
+	}
+
+	device, ok := h.locationDevice(c, req.MacAddress)
+	if !ok {
+		return
+	}
+
+	loc, err := h.recordDeviceLocation(c, device, &req.deviceLocationPayload)
+	if err != nil {
+		response.Fail(c, "Failed to record location", err.Error())
+		return
+	}
+	h.invalidateDeviceCache(device.UserID, device.GroupID)
+	response.Success(c, "Location recorded", loc)
+}
+
+// GetDeviceLocations gets the location history of a device
+// GET /device/:deviceId/locations?since=&until=&limit=
+func (h *Handlers) GetDeviceLocations(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+
+	var since, until *time.Time
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.Fail(c, "Invalid since, expected RFC3339", nil)
+			return
+		}
+		since = &t
+	}
+	if v := c.Query("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.Fail(c, "Invalid until, expected RFC3339", nil)
+			return
+		}
+		until = &t
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	locations, err := models.GetDeviceLocations(h.db, device.ID, since, until, limit)
+	if err != nil {
+		response.Fail(c, "Failed to query locations", err.Error())
+		return
+	}
+	response.Success(c, "Query successful", locations)
+}
+
+// GetDeviceMap lists located devices for the fleet map view, filtered by
+// bounding box (minLat, maxLat, minLng, maxLng) or radius (lat, lng, radius in meters)
+// GET /device/map
+func (h *Handlers) GetDeviceMap(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not logged in", nil)
+		return
+	}
+
+	devices, err := models.GetUserDevices(h.db, user.ID, nil)
+	if err != nil {
+		logger.Error("Failed to query devices", zap.Error(err))
+		response.Fail(c, "Failed to query devices", nil)
+		return
+	}
+
+	switch {
+	case c.Query("radius") != "":
+		lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+		radius, errRadius := strconv.ParseFloat(c.Query("radius"), 64)
+		if errLat != nil || errLng != nil || errRadius != nil || radius <= 0 || !models.ValidCoordinates(lat, lng) {
+			response.Fail(c, "Invalid radius query", "lat, lng and a positive radius are required")
+			return
+		}
+		devices = models.FilterDevicesWithinRadius(devices, lat, lng, radius)
+	case c.Query("minLat") != "":
+		var bounds models.GeoBounds
+		values := []*float64{&bounds.MinLat, &bounds.MaxLat, &bounds.MinLng, &bounds.MaxLng}
+		for i, key := range []string{"minLat", "maxLat", "minLng", "maxLng"} {
+			v, err := strconv.ParseFloat(c.Query(key), 64)
+			if err != nil {
+				response.Fail(c, "Invalid bounding box", key+" is required")
+				return
+			}
+			*values[i] = v
+		}
+		if bounds.MinLat > bounds.MaxLat || !models.ValidCoordinates(bounds.MinLat, bounds.MinLng) || !models.ValidCoordinates(bounds.MaxLat, bounds.MaxLng) {
+			response.Fail(c, "Invalid bounding box", nil)
+			return
+		}
+		devices = models.FilterDevicesInBounds(devices, bounds)
+	default:
+		located := make([]models.Device, 0, len(devices))
+		for _, d := range devices {
+			if d.Latitude != nil && d.Longitude != nil {
+				located = append(located, d)
+			}
+		}
+		devices = located
+	}
+
+	response.Success(c, "Query successful", devices)
+}
+
+// ListDeviceGeofences lists geofences of the current user
+// GET /device/geofences
+func (h *Handlers) ListDeviceGeofences(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not logged in", nil)
+		return
+	}
+
+	fences, err := models.ListDeviceGeofences(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "Failed to query geofences", err.Error())
+		return
+	}
+	response.Success(c, "Query successful", fences)
+}
+
+// SaveDeviceGeofence creates a geofence, or updates it when :id is present
+// POST /device/geofences, PUT /device/geofences/:id
+func (h *Handlers) SaveDeviceGeofence(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not logged in", nil)
+		return
+	}
+
+	var req struct {
+		DeviceID  *string `json:"deviceId"`
+		Name      string  `json:"name"`
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+		Radius    float64 `json:"radius"`
+		Enabled   *bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid parameters", err.Error())
+		return
+	}
+
+	fence := &models.DeviceGeofence{UserID: user.ID, Enabled: true}
+	if id := c.Param("id"); id != "" {
+		if err := h.db.Where("id = ? AND user_id = ?", id, user.ID).First(fence).Error; err != nil {
+			response.Fail(c, "Geofence not found", nil)
+			return
+		}
+	}
+	if req.DeviceID != nil && *req.DeviceID != "" {
+		device, err := models.GetDeviceByID(h.db, *req.DeviceID)
+		if err != nil || device == nil || device.UserID != user.ID {
+			response.Fail(c, "Device not found", nil)
+			return
+		}
+		fence.DeviceID = req.DeviceID
+	} else {
+		fence.DeviceID = nil
+	}
+	fence.Name = req.Name
+	fence.Latitude = req.Latitude
+	fence.Longitude = req.Longitude
+	fence.Radius = req.Radius
+	if req.Enabled != nil {
+		fence.Enabled = *req.Enabled
+	}
+
+	if err := fence.Validate(); err != nil {
+		response.Fail(c, "Invalid geofence", err.Error())
+		return
+	}
+	if err := h.db.Save(fence).Error; err != nil {
+		response.Fail(c, "Failed to save geofence", err.Error())
+		return
+	}
+	response.Success(c, "Save successful", fence)
+}
+
+// DeleteDeviceGeofence deletes a geofence
+// DELETE /device/geofences/:id
+func (h *Handlers) DeleteDeviceGeofence(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not logged in", nil)
+		return
+	}
+
+	result := h.db.Where("id = ? AND user_id = ?", c.Param("id"), user.ID).Delete(&models.DeviceGeofence{})
+	if result.Error != nil {
+		response.Fail(c, "Failed to delete geofence", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Fail(c, "Geofence not found", nil)
+		return
+	}
+	response.Success(c, "Delete successful", nil)
+}
+
+// locationDevice loads a device by MAC address and checks the user can report for it
+func (h *Handlers) locationDevice(c *gin.Context, macAddress string) (*models.Device, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "User not logged in", nil)
+		return nil, false
+	}
+
+	device, err := models.GetDeviceByMacAddress(h.db, macAddress)
+	if err != nil || device == nil {
+		response.Fail(c, "Device not found", nil)
+		return nil, false
+	}
+	if device.UserID != user.ID {
+		if device.GroupID == nil {
+			response.Fail(c, "Insufficient permissions", nil)
+			return nil, false
+		}
+		var group models.Group
+		if err := h.db.First(&group, *device.GroupID).Error; err != nil || !models.IsGroupMember(h.db, &group, user.ID) {
+			response.Fail(c, "Insufficient permissions", nil)
+			return nil, false
+		}
+	}
+	return device, true
+}
+
+// recordDeviceLocation stores a location report and raises a geofence alert when
+// the device is outside all of its allowed regions
+func (h *Handlers) recordDeviceLocation(c *gin.Context, device *models.Device, payload *deviceLocationPayload) (*models.DeviceLocation, error) {
+	loc := &models.DeviceLocation{
+		Accuracy:  payload.Accuracy,
+		Source:    models.LocationSourceGPS,
+		IPAddress: c.ClientIP(),
+	}
+	if payload.ReportedAt != nil {
+		loc.ReportedAt = *payload.ReportedAt
+	}
+
+	switch {
+	case payload.Latitude != nil && payload.Longitude != nil:
+		loc.Latitude = *payload.Latitude
+		loc.Longitude = *payload.Longitude
+	case payload.UseIP && h.ipLocationService != nil:
+		lat, lng, country, city, err := h.ipLocationService.GetCoordinates(loc.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+		loc.Latitude, loc.Longitude = lat, lng
+		loc.Country, loc.City = country, city
+		loc.Source = models.LocationSourceIP
+	default:
+		return nil, models.ErrInvalidCoordinates
+	}
+
+	if err := models.RecordDeviceLocation(h.db, device, loc); err != nil {
+		return nil, err
+	}
+
+	fences, violated, err := models.CheckDeviceGeofences(h.db, device, loc.Latitude, loc.Longitude)
+	if err != nil {
+		logger.Warn("Failed to check device geofences", zap.String("deviceId", device.ID), zap.Error(err))
+	} else if violated {
+		names := make([]string, 0, len(fences))
+		for _, f := range fences {
+			names = append(names, f.Name)
+		}
+		if err := alert.NewTriggerService(h.db).TriggerGeofenceAlert(device.UserID, device.ID, device.DeviceName, loc.Latitude, loc.Longitude, names); err != nil {
+			logger.Warn("Failed to trigger geofence alert", zap.String("deviceId", device.ID), zap.Error(err))
+		}
+	}
+	return loc, nil
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	diagnosticsUploadTTL = 24 * time.Hour
+	// diagnosticsMaxUpload largest bundle a device may upload
+	diagnosticsMaxUpload = 50 << 20
+)
+
+type diagnosticsRequest struct {
+	Ticket string `json:"ticket"` // support ticket the bundle is gathered for
+}
+
+// diagnosticsDir directory of the uploaded device bundles, DIAGNOSTICS_DIR
+func diagnosticsDir() string {
+	if dir := utils.GetEnv("DIAGNOSTICS_DIR"); dir != "" {
+		return dir
+	}
+	return "./diagnostics"
+}
+
+// RequestDeviceDiagnostics asks the device to upload a diagnostics bundle (recent
+// logs, config snapshot, network info) through a one-time upload link
+// POST /device/:deviceId/diagnostics
+func (h *Handlers) RequestDeviceDiagnostics(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+	var req diagnosticsRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "Invalid parameters", err.Error())
+			return
+		}
+	}
+	user := models.CurrentUser(c)
+	bundle, err := models.CreateDiagnosticsBundle(h.db, device.ID, user.ID, req.Ticket, diagnosticsUploadTTL)
+	if err != nil {
+		response.Fail(c, "Failed to create diagnostics bundle", err.Error())
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]any{
+		"command":   models.DiagnosticsCommand,
+		"bundleId":  bundle.ID,
+		"uploadUrl": config.GlobalConfig.Server.APIPrefix + "/device/diagnostics/upload/" + bundle.UploadToken,
+		"include":   []string{"logs", "config", "network"},
+		"expiresAt": bundle.ExpiresAt,
+	})
+	action := models.DeviceAction{
+		DeviceID:  device.ID,
+		Kind:      models.DeviceActionCommand,
+		Payload:   string(payload),
+		CreatedBy: user.ID,
+	}
+	if device.GroupID != nil {
+		action.GroupID = *device.GroupID
+	}
+	if err := models.SubmitDeviceAction(h.db, &action, time.Now()); err != nil {
+		response.Fail(c, "Failed to notify the device", err.Error())
+		return
+	}
+	h.db.Model(bundle).Update("action_id", action.ID)
+	bundle.ActionID = action.ID
+
+	response.Success(c, "Diagnostics requested", bundle)
+}
+
+// ListDeviceDiagnostics lists the device's diagnostics bundles
+// GET /device/:deviceId/diagnostics
+func (h *Handlers) ListDeviceDiagnostics(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+	var bundles []models.DiagnosticsBundle
+	if err := h.db.Where("device_id = ?", device.ID).Order("id DESC").Limit(50).Find(&bundles).Error; err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+	response.Success(c, "Query successful", bundles)
+}
+
+// UploadDeviceDiagnostics receives the bundle from the device, authorized by the
+// upload token; the file is sent as multipart field "file" or as the raw body
+// POST /device/diagnostics/upload/:token
+func (h *Handlers) UploadDeviceDiagnostics(c *gin.Context) {
+	now := time.Now()
+	bundle, err := models.GetDiagnosticsBundleForUpload(h.db, c.Param("token"), now)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, diagnosticsMaxUpload)
+	var src io.Reader = c.Request.Body
+	name := "bundle.bin"
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		src = file
+		name = filepath.Base(header.Filename)
+	}
+
+	dir := filepath.Join(diagnosticsDir(), strconv.FormatUint(uint64(bundle.ID), 10))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		response.Fail(c, "Failed to store bundle", err.Error())
+		return
+	}
+	dst := filepath.Join(dir, "upload")
+	f, err := os.Create(dst)
+	if err != nil {
+		response.Fail(c, "Failed to store bundle", err.Error())
+		return
+	}
+	size, err := io.Copy(f, src)
+	f.Close()
+	if err != nil {
+		os.Remove(dst)
+		response.Fail(c, "Failed to store bundle", err.Error())
+		return
+	}
+	if err := models.MarkDiagnosticsUploaded(h.db, bundle, dst, name, size, now); err != nil {
+		response.Fail(c, "Failed to store bundle", err.Error())
+		return
+	}
+	logger.Info("Diagnostics bundle uploaded",
+		zap.String("deviceID", bundle.DeviceID),
+		zap.Uint("bundleID", bundle.ID),
+		zap.Int64("size", size))
+	response.Success(c, "Uploaded", nil)
+}
+
+// DownloadDeviceDiagnostics packages the device's upload with the server-side
+// context (device record, recent errors and call records) into a zip archive.
+// Before the device uploads, the archive holds the server-side part only.
+// GET /device/:deviceId/diagnostics/:bundleId/download
+func (h *Handlers) DownloadDeviceDiagnostics(c *gin.Context) {
+	device, ok := h.customFieldDevice(c)
+	if !ok {
+		return
+	}
+	var bundle models.DiagnosticsBundle
+	if err := h.db.Where("id = ? AND device_id = ?", c.Param("bundleId"), device.ID).First(&bundle).Error; err != nil {
+		response.Fail(c, "Diagnostics bundle not found", nil)
+		return
+	}
+	diag, err := models.LoadDiagnosticsContext(h.db, device)
+	if err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+
+	var upload io.Reader
+	if bundle.Status == models.DiagnosticsUploaded {
+		f, err := os.Open(bundle.UploadPath)
+		if err != nil {
+			response.Fail(c, "Device upload is missing", err.Error())
+			return
+		}
+		defer f.Close()
+		upload = f
+	}
+
+	filename := fmt.Sprintf("diagnostics-%s-%d.zip", device.ID, bundle.ID)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := models.WriteDiagnosticsArchive(c.Writer, &bundle, diag, upload); err != nil {
+		logger.Warn("Failed to write diagnostics archive", zap.Uint("bundleID", bundle.ID), zap.Error(err))
+	}
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxDIDImportRows caps a single bulk import
+const maxDIDImportRows = 5000
+
+// didRequest request body for creating/updating a DID
+type didRequest struct {
+	Number      string               `json:"number"`
+	Label       string               `json:"label"`
+	TargetType  models.DIDTargetType `json:"targetType"`
+	AssistantID *int64               `json:"assistantId"`
+	WorkflowID  *uint                `json:"workflowId"`
+	QueueAgents []string             `json:"queueAgents"`
+	Greeting    string               `json:"greeting"`
+	GroupID     *uint                `json:"groupId"`
+	Enabled     *bool                `json:"enabled"` // Defaults to true
+}
+
+// toDID converts the request, keeping the ID and owner of base
+func (r *didRequest) toDID(base models.DIDNumber) models.DIDNumber {
+	base.Number = r.Number
+	base.Label = r.Label
+	base.TargetType = r.TargetType
+	base.AssistantID = r.AssistantID
+	base.WorkflowID = r.WorkflowID
+	base.QueueAgents = strings.Join(r.QueueAgents, ",")
+	base.Greeting = r.Greeting
+	base.Enabled = r.Enabled == nil || *r.Enabled
+	return base
+}
+
+// ListDIDs lists the dial-in numbers of the current user
+// GET /dids
+func (h *Handlers) ListDIDs(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	var dids []models.DIDNumber
+	if err := h.db.Where("user_id = ?", user.ID).Order("number").Find(&dids).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", dids)
+}
+
+// GetDID gets a dial-in number
+// GET /dids/:id
+func (h *Handlers) GetDID(c *gin.Context) {
+	did, ok := h.ownedDID(c)
+	if !ok {
+		return
+	}
+	response.Success(c, "success", did)
+}
+
+// CreateDID registers a dial-in number or SIP alias
+// POST /dids
+func (h *Handlers) CreateDID(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	var req didRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	did := req.toDID(models.DIDNumber{UserID: user.ID, GroupID: req.GroupID})
+	if err := h.validateDID(user.ID, &did); err != nil {
+		response.Fail(c, "invalid DID", err.Error())
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.DIDNumber{}).Where("number = ?", did.Number).Count(&count)
+	if count > 0 {
+		response.Fail(c, "number already registered", did.Number)
+		return
+	}
+	if err := h.db.Create(&did).Error; err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	response.Success(c, "created", did)
+}
+
+// UpdateDID changes the number or the target of a dial-in number
+// PUT /dids/:id
+func (h *Handlers) UpdateDID(c *gin.Context) {
+	user := models.CurrentUser(c)
+	existing, ok := h.ownedDID(c)
+	if !ok {
+		return
+	}
+
+	var req didRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if req.GroupID != nil {
+		existing.GroupID = req.GroupID
+	}
+	did := req.toDID(*existing)
+	if err := h.validateDID(user.ID, &did); err != nil {
+		response.Fail(c, "invalid DID", err.Error())
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.DIDNumber{}).Where("number = ? AND id <> ?", did.Number, did.ID).Count(&count)
+	if count > 0 {
+		response.Fail(c, "number already registered", did.Number)
+		return
+	}
+	if err := h.db.Save(&did).Error; err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "updated", did)
+}
+
+// DeleteDID removes a dial-in number, its calls keep their statistics
+// DELETE /dids/:id
+func (h *Handlers) DeleteDID(c *gin.Context) {
+	did, ok := h.ownedDID(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(did).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// ImportDIDs registers or updates dial-in numbers in bulk. The body is either a
+// JSON array of DIDs or a CSV file (Content-Type text/csv, or multipart field
+// "file") with the header number,label,targetType,assistantId,workflowId,queueAgents,greeting,enabled
+// where queueAgents are separated by ";"
+// POST /dids/import
+func (h *Handlers) ImportDIDs(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	var reqs []didRequest
+	var err error
+	switch {
+	case strings.HasPrefix(c.ContentType(), "multipart/"):
+		file, ferr := c.FormFile("file")
+		if ferr != nil {
+			response.Fail(c, "missing file", ferr.Error())
+			return
+		}
+		f, ferr := file.Open()
+		if ferr != nil {
+			response.Fail(c, "failed to open file", ferr.Error())
+			return
+		}
+		defer f.Close()
+		reqs, err = parseDIDCSV(f)
+	case c.ContentType() == "text/csv":
+		reqs, err = parseDIDCSV(c.Request.Body)
+	default:
+		err = c.ShouldBindJSON(&reqs)
+	}
+	if err != nil {
+		response.Fail(c, "invalid import", err.Error())
+		return
+	}
+	if len(reqs) == 0 {
+		response.Fail(c, "nothing to import", nil)
+		return
+	}
+	if len(reqs) > maxDIDImportRows {
+		response.Fail(c, "too many rows", fmt.Sprintf("at most %d rows per import", maxDIDImportRows))
+		return
+	}
+
+	// Rows with foreign targets are rejected here, rowNumbers maps the rows
+	// passed to the model back to the rows of the import
+	rows := make([]models.DIDNumber, 0, len(reqs))
+	rowNumbers := make([]int, 0, len(reqs))
+	var rejected []models.DIDImportError
+	for i := range reqs {
+		did := reqs[i].toDID(models.DIDNumber{})
+		if err := h.validateDIDTargets(user.ID, &did); err != nil {
+			rejected = append(rejected, models.DIDImportError{Row: i + 1, Number: did.Number, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, did)
+		rowNumbers = append(rowNumbers, i+1)
+	}
+
+	result, err := models.ImportDIDNumbers(h.db, user.ID, nil, rows)
+	if err != nil {
+		response.Fail(c, "import failed", err.Error())
+		return
+	}
+	for i := range result.Errors {
+		result.Errors[i].Row = rowNumbers[result.Errors[i].Row-1]
+	}
+	result.Errors = append(result.Errors, rejected...)
+	sort.Slice(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	response.Success(c, "imported", result)
+}
+
+// GetDIDStats gets the call analytics of a dial-in number over the last ?days= days (default 30)
+// GET /dids/:id/stats
+func (h *Handlers) GetDIDStats(c *gin.Context) {
+	did, ok := h.ownedDID(c)
+	if !ok {
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 || days > 366 {
+		response.Fail(c, "invalid days", "days must be between 1 and 366")
+		return
+	}
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+	stats, err := models.GetDIDStats(h.db, did.ID, since)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", stats)
+}
+
+// ownedDID loads the DID from :id, owned by the current user
+func (h *Handlers) ownedDID(c *gin.Context) (*models.DIDNumber, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid DID id", nil)
+		return nil, false
+	}
+	var did models.DIDNumber
+	if err := h.db.First(&did, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "DID not found", nil)
+		} else {
+			response.Fail(c, "query failed", err.Error())
+		}
+		return nil, false
+	}
+	if did.UserID != user.ID {
+		response.Fail(c, "forbidden", "No permission to access this DID")
+		return nil, false
+	}
+	return &did, true
+}
+
+// validateDID checks the DID and that its targets belong to the user
+func (h *Handlers) validateDID(userID uint, did *models.DIDNumber) error {
+	if err := did.Validate(); err != nil {
+		return err
+	}
+	return h.validateDIDTargets(userID, did)
+}
+
+// validateDIDTargets checks that the assistant, workflow and organization of the DID belong to the user
+func (h *Handlers) validateDIDTargets(userID uint, did *models.DIDNumber) error {
+	if did.GroupID != nil {
+		var group models.Group
+		if err := h.db.First(&group, *did.GroupID).Error; err != nil || !models.IsGroupMember(h.db, &group, userID) {
+			return fmt.Errorf("organization %d not found", *did.GroupID)
+		}
+	}
+	if did.AssistantID != nil && *did.AssistantID != 0 {
+		var assistant models.Assistant
+		if err := h.db.Select("id", "user_id").First(&assistant, *did.AssistantID).Error; err != nil || assistant.UserID != userID {
+			return fmt.Errorf("assistant %d not found", *did.AssistantID)
+		}
+	}
+	if did.WorkflowID != nil && *did.WorkflowID != 0 {
+		var workflow models.WorkflowDefinition
+		if err := h.db.Select("id", "user_id").First(&workflow, *did.WorkflowID).Error; err != nil || workflow.UserID != userID {
+			return fmt.Errorf("workflow %d not found", *did.WorkflowID)
+		}
+	}
+	return nil
+}
+
+// parseDIDCSV reads DIDs from CSV, columns are matched by header name
+func parseDIDCSV(r io.Reader) ([]didRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["number"]; !ok {
+		return nil, errors.New("CSV header must contain a number column")
+	}
+
+	var reqs []didRequest
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[strings.ToLower(name)]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		req := didRequest{
+			Number:     field("number"),
+			Label:      field("label"),
+			TargetType: models.DIDTargetType(field("targetType")),
+			Greeting:   field("greeting"),
+		}
+		if v := field("assistantId"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid assistantId %q", line, v)
+			}
+			req.AssistantID = &id
+		}
+		if v := field("workflowId"); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid workflowId %q", line, v)
+			}
+			workflowID := uint(id)
+			req.WorkflowID = &workflowID
+		}
+		if v := field("queueAgents"); v != "" {
+			req.QueueAgents = strings.Split(v, ";")
+		}
+		if v := field("enabled"); v != "" {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid enabled %q", line, v)
+			}
+			req.Enabled = &enabled
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
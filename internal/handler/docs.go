@@ -130,6 +130,12 @@ func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
 				"KnowledgeBaseID",
 				"JsSourceID",
 				"EnableGraphMemory",
+				"CallbackOfferAfter",
+				"CallbackPromptFile",
+				"InterpreterMode",
+				"InterpreterLanguage",
+				"InterpreterTarget",
+				"InterpreterSpeaker",
 			},
 			Orderables:  []string{"CreatedAt", "Name"},
 			Searchables: []string{"Name", "Description"},
@@ -382,7 +388,7 @@ func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
 			Group:       "Communication",
 			Name:        "SIP Calls",
 			Desc:        "SIP call records and history.",
-			Shows:       []string{"ID", "CallID", "Direction", "Status", "FromUsername", "ToUsername", "CreatedAt"},
+			Shows:       []string{"ID", "CallID", "Direction", "Status", "FromUsername", "ToUsername", "ConsentStatus", "CreatedAt"},
 			Editables:   []string{"Status"},
 			Orderables:  []string{"CreatedAt"},
 			Searchables: []string{"CallID", "FromUsername", "ToUsername", "Status"},
@@ -410,6 +416,194 @@ func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
 			Searchables: []string{"Username", "Status"},
 			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
 		},
+		{
+			Model:       &models.RecordingConsentPolicy{},
+			Group:       "Communication",
+			Name:        "Recording Consent Policies",
+			Desc:        "Per-region call recording consent rules, matched on the caller and callee number prefixes.",
+			Shows:       []string{"ID", "Region", "Name", "NumberPrefixes", "Mode", "Enabled", "UpdatedAt"},
+			Editables:   []string{"Region", "Name", "NumberPrefixes", "Mode", "PromptFile", "AcceptDigit", "DeclineDigit", "TimeoutSeconds", "Enabled"},
+			Orderables:  []string{"UpdatedAt", "Region"},
+			Searchables: []string{"Region", "Name", "NumberPrefixes"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.DIDNumber{},
+			Group:       "Communication",
+			Name:        "Dial-in Numbers",
+			Desc:        "External numbers and SIP aliases routed to assistants, IVR workflows or agent queues.",
+			Shows:       []string{"ID", "UserID", "Number", "Label", "TargetType", "Enabled", "UpdatedAt"},
+			Editables:   []string{"Label", "TargetType", "AssistantID", "WorkflowID", "QueueAgents", "Greeting", "Enabled"},
+			Orderables:  []string{"UpdatedAt", "Number"},
+			Searchables: []string{"Number", "Label", "QueueAgents"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.SipCallback{},
+			Group:       "Communication",
+			Name:        "SIP Callbacks",
+			Desc:        "Callbacks offered to queued callers and their outcomes.",
+			Shows:       []string{"ID", "CallID", "AssistantID", "CallbackNumber", "Status", "QueueWait", "OfferedAt", "EndedAt"},
+			Editables:   []string{"Status"},
+			Orderables:  []string{"OfferedAt"},
+			Searchables: []string{"CallID", "CallbackNumber", "Status"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.CallSurveySettings{},
+			Group:       "Communication",
+			Name:        "Call Survey Settings",
+			Desc:        "Post-call DTMF satisfaction survey configuration per user.",
+			Shows:       []string{"ID", "UserID", "Enabled", "Scale", "TimeoutSeconds", "Channels", "UpdatedAt"},
+			Editables:   []string{"Enabled", "Prompt", "PromptFile", "Thanks", "Scale", "TimeoutSeconds", "Channels"},
+			Orderables:  []string{"UpdatedAt"},
+			Searchables: []string{"Channels"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.CallSurvey{},
+			Group:       "Communication",
+			Name:        "Call Surveys",
+			Desc:        "Satisfaction ratings collected after calls.",
+			Shows:       []string{"ID", "CallID", "UserID", "AssistantID", "AgentUserID", "Channel", "Status", "Score", "Scale", "PromptedAt"},
+			Editables:   []string{"Status"},
+			Orderables:  []string{"PromptedAt"},
+			Searchables: []string{"CallID", "Channel", "Status"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.AuthzPolicy{},
+			Group:       "System",
+			Name:        "Authorization Policies",
+			Desc:        "Attribute-based policies evaluated after RBAC when POLICY_ENGINE=rules.",
+			Shows:       []string{"ID", "Name", "Subject", "Resource", "Actions", "Effect", "Priority", "Enabled", "UpdatedAt"},
+			Editables:   []string{"Name", "Description", "Subject", "Resource", "Actions", "Effect", "Conditions", "Priority", "Enabled"},
+			Orderables:  []string{"Priority", "UpdatedAt"},
+			Searchables: []string{"Name", "Subject", "Resource"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.ScimToken{},
+			Group:       "System",
+			Name:        "SCIM Tokens",
+			Desc:        "Bearer tokens used by identity providers to provision users and organizations.",
+			Shows:       []string{"ID", "Name", "TokenPrefix", "GroupID", "CreatedBy", "ExpiresAt", "LastUsedAt", "Revoked"},
+			Editables:   []string{"Name", "ExpiresAt", "Revoked"},
+			Orderables:  []string{"LastUsedAt"},
+			Searchables: []string{"Name", "TokenPrefix"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.SamlConnection{},
+			Group:       "System",
+			Name:        "SAML Connections",
+			Desc:        "Per-organization SAML identity providers and the email domains routed to them.",
+			Shows:       []string{"ID", "GroupID", "Enabled", "IdPEntityID", "Domains", "JITProvisioning", "EnforceSSO", "TechnicianPortal", "UpdatedAt"},
+			Editables:   []string{"Enabled", "Domains", "JITProvisioning", "EnforceSSO", "TechnicianPortal"},
+			Orderables:  []string{"UpdatedAt"},
+			Searchables: []string{"IdPEntityID", "Domains"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.SSOEvent{},
+			Group:       "System",
+			Name:        "SSO Events",
+			Desc:        "Audit trail of single sign-on logins, provisioning and configuration changes.",
+			Shows:       []string{"ID", "GroupID", "UserID", "Email", "Event", "Success", "IPAddress", "CreatedAt"},
+			Orderables:  []string{"CreatedAt"},
+			Searchables: []string{"Email", "Event"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.TechnicianToken{},
+			Group:       "System",
+			Name:        "Technician Tokens",
+			Desc:        "Device claim portal tokens issued to field technicians after organization SSO login.",
+			Shows:       []string{"ID", "GroupID", "UserID", "Email", "TokenPrefix", "ExpiresAt", "LastUsedAt", "RevokedAt"},
+			Orderables:  []string{"ExpiresAt", "LastUsedAt"},
+			Searchables: []string{"Email", "TokenPrefix"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.DeviceClaim{},
+			Group:       "System",
+			Name:        "Device Claims",
+			Desc:        "Devices activated by field technicians through the claim portal.",
+			Shows:       []string{"ID", "GroupID", "TechnicianID", "DeviceID", "AssistantID", "IPAddress", "CreatedAt", "RevokedAt"},
+			Orderables:  []string{"CreatedAt"},
+			Searchables: []string{"DeviceID", "MacAddress"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.SipHANode{},
+			Group:       "System",
+			Name:        "SIP HA Nodes",
+			Desc:        "Active and standby SIP media instances with their last heartbeat.",
+			Shows:       []string{"ID", "Cluster", "Instance", "Role", "VIP", "HeartbeatAt", "MirroredUsers", "ActiveCalls"},
+			Orderables:  []string{"HeartbeatAt"},
+			Searchables: []string{"Cluster", "Instance"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.SipFailoverEvent{},
+			Group:       "System",
+			Name:        "SIP Failover Events",
+			Desc:        "Failovers, drills and step downs of the SIP media path.",
+			Shows:       []string{"ID", "Cluster", "Kind", "FromInstance", "ToInstance", "ResumedCalls", "TerminatedCalls", "TakeoverMs", "CreatedAt"},
+			Orderables:  []string{"CreatedAt"},
+			Searchables: []string{"Cluster", "Kind", "FromInstance", "ToInstance"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.ProviderIncident{},
+			Group:       "System",
+			Name:        "Provider Incidents",
+			Desc:        "Daily LLM and TTS timeouts, errors and failovers during AI calls.",
+			Shows:       []string{"ID", "Day", "Provider", "Stage", "Timeouts", "Errors", "Failovers", "LastIncidentAt"},
+			Orderables:  []string{"Day", "LastIncidentAt"},
+			Searchables: []string{"Provider", "Stage"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.OAuthIdentity{},
+			Group:       "System",
+			Name:        "OAuth Identities",
+			Desc:        "Social login accounts linked to users.",
+			Shows:       []string{"ID", "UserID", "Provider", "Subject", "Email", "Name", "LastLoginAt", "CreatedAt"},
+			Orderables:  []string{"CreatedAt", "LastLoginAt"},
+			Searchables: []string{"Provider", "Subject", "Email"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.TwoFactorBackupCode{},
+			Group:       "System",
+			Name:        "2FA Backup Codes",
+			Desc:        "Hashed one-time backup codes for two-factor authentication.",
+			Shows:       []string{"ID", "UserID", "UsedAt", "CreatedAt"},
+			Orderables:  []string{"CreatedAt"},
+			Searchables: []string{"UserID"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.RefreshToken{},
+			Group:       "System",
+			Name:        "Refresh Tokens",
+			Desc:        "Server-side refresh tokens; tokens of one login share a family and rotate on every refresh.",
+			Shows:       []string{"ID", "UserID", "FamilyID", "IP", "ExpiresAt", "RotatedAt", "RevokedAt", "CreatedAt"},
+			Orderables:  []string{"CreatedAt", "ExpiresAt"},
+			Searchables: []string{"FamilyID", "IP"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
+		{
+			Model:       &models.RevokedToken{},
+			Group:       "System",
+			Name:        "Revoked Tokens",
+			Desc:        "Access tokens rejected before their expiry; an empty JTI revokes every token the user was issued before RevokedAt.",
+			Shows:       []string{"ID", "UserID", "JTI", "Reason", "RevokedAt", "ExpiresAt"},
+			Orderables:  []string{"RevokedAt"},
+			Searchables: []string{"JTI", "Reason"},
+			Icon:        &models.AdminIcon{SVG: string(iconChatSessionLog)},
+		},
 		// AI Call Sessions
 		{
 			Model:       &models.AICallSession{},
@@ -488,9 +682,9 @@ func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
 			Group:       "System",
 			Name:        "Login History",
 			Desc:        "User login history and security monitoring.",
-			Shows:       []string{"ID", "UserID", "Email", "IPAddress", "Location", "Country", "City", "LoginType", "Success", "IsSuspicious", "CreatedAt"},
+			Shows:       []string{"ID", "UserID", "Email", "IPAddress", "Location", "Country", "City", "LoginType", "Success", "IsSuspicious", "AnomalyScore", "RiskDecision", "RiskReasons", "CreatedAt"},
 			Editables:   []string{"IsSuspicious", "FailureReason"},
-			Orderables:  []string{"CreatedAt"},
+			Orderables:  []string{"CreatedAt", "AnomalyScore"},
 			Searchables: []string{"Email", "IPAddress", "Location", "Country", "City", "LoginType"},
 			Icon:        &models.AdminIcon{SVG: string(iconOperatorLog)},
 		},
@@ -668,6 +862,44 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			Desc:         "Confirm password reset with token",
 			Request:      apidocs.GetDocDefine(models.ResetPasswordDoneForm{}),
 		},
+		{
+			Group:  "User Authorization",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/auth/unlock-account",
+			Method: http.MethodPost,
+			Desc:   "Email a one-time unlock link (valid 1 hour, at most one per minute) to an account locked by failed logins. The response is the same whether or not the account is locked",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "email", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:  "User Authorization",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/auth/unlock-account/confirm",
+			Method: http.MethodPost,
+			Desc:   "Lift the account lock with the token from the unlock email",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "token", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/account-locks",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Accounts currently locked by failed logins (admin), newest first, filtered by ?email= and paginated by ?page=&size=",
+		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/account-locks/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Clear an account lock before it expires (admin); the user is notified in-app and by email, as when a lock expires",
+		},
 		{
 			Group:        "User Authorization",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/change-password",
@@ -685,12 +917,14 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/send/email",
 			Method:       http.MethodPost,
 			AuthRequired: false,
-			Desc:         "Send email verification code",
+			Desc:         "Send email verification code. Requests within the per-email or per-IP cooldown get HTTP 429 with a Retry-After header and data {scope, retryAfterSeconds}; a code is invalidated after maxVerifyAttempts failed verifications",
 			Request:      apidocs.GetDocDefine(models.SendEmailVerifyEmail{}),
 			Response: &apidocs.DocField{
 				Type: "object",
 				Fields: []apidocs.DocField{
-					{Name: "expired", Type: apidocs.TYPE_STRING, Default: "30m", Desc: "Must be verified within the valid time"},
+					{Name: "expiresInSeconds", Type: apidocs.TYPE_INT, Default: "300", Desc: "Must be verified within the valid time"},
+					{Name: "cooldownSeconds", Type: apidocs.TYPE_INT, Default: "60", Desc: "Wait before requesting another code for the same email"},
+					{Name: "maxVerifyAttempts", Type: apidocs.TYPE_INT, Default: "5"},
 				},
 			},
 		},
@@ -708,6 +942,13 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			AuthRequired: true,
 			Desc:         "Send email verification code to current user",
 		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/email-verification-policy",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Actions that require a verified email (system config EMAIL_VERIFICATION_REQUIRED, comma separated or *: assistant.create, device.bind, call.outbound, credential.create, group.create) and those the current user is blocked from. Blocked requests get 403 with error EMAIL_NOT_VERIFIED, the action and the endpoint to send the verification email",
+		},
 		{
 			Group:        "User Authorization",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/verify-phone",
@@ -752,6 +993,63 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			AuthRequired: true,
 			Desc:         "Update notification settings",
 		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/notification-preferences",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get structured notification preferences: channels per event type, quiet hours and critical-event override",
+		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/notification-preferences",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Update structured notification preferences, enforced for alerts, security, account, group and assistant notifications",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "channels", Type: "object", CanNull: true, Desc: "Event type (alert, security, account, group, assistant, system, device, call, inbox) to channels (email, internal, sms, webhook, push); unset events use the default channels"},
+					{Name: "quietHoursEnabled", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "quietStart", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "HH:MM"},
+					{Name: "quietEnd", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "HH:MM, earlier than quietStart wraps past midnight"},
+					{Name: "quietTimezone", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "IANA timezone, defaults to the user's timezone"},
+					{Name: "criticalOverride", Type: apidocs.TYPE_BOOLEAN, Desc: "Critical events (critical alerts, suspicious logins) are delivered during quiet hours"},
+					{Name: "webhookUrl", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "webhookSecret", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Signs webhook deliveries, kept when omitted"},
+				},
+			},
+		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/notification/push-devices",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Register the FCM (android) or APNs (ios) token of the mobile app for push notifications: suspicious logins, devices going offline, missed calls and alerts with the push channel. Registering a known token moves it to the current user; at most 10 devices are kept. Pushes honour the pushNotifications preference, per-event channels and quiet hours",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "platform", Type: apidocs.TYPE_STRING, Required: true, Desc: "android or ios"},
+					{Name: "token", Type: apidocs.TYPE_STRING, Required: true, Desc: "FCM registration token or APNs device token"},
+					{Name: "deviceName", Type: apidocs.TYPE_STRING},
+					{Name: "appVersion", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/notification/push-devices",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the registered push devices; tokens are not returned, tokenTail tells them apart",
+		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/notification/push-devices/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Unregister a push device. The app unregisters its own token on logout with DELETE /notification/push-devices?token=",
+		},
 		{
 			Group:        "User Authorization",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/user-preferences",
@@ -785,21 +1083,34 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/two-factor/enable",
 			Method:       http.MethodPost,
 			AuthRequired: true,
-			Desc:         "Enable two-factor authentication",
+			Desc:         "Enable two-factor authentication with a code from the authenticator. Returns 10 one-time backup codes, shown only once",
 		},
 		{
 			Group:        "User Authorization",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/two-factor/disable",
 			Method:       http.MethodPost,
 			AuthRequired: true,
-			Desc:         "Disable two-factor authentication",
+			Desc:         "Disable two-factor authentication with an authenticator code or a backup code",
 		},
 		{
 			Group:        "User Authorization",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/two-factor/status",
 			Method:       http.MethodGet,
 			AuthRequired: true,
-			Desc:         "Get two-factor authentication status",
+			Desc:         "Get two-factor authentication status, including backupCodesRemaining",
+		},
+		{
+			Group:        "User Authorization",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/auth/two-factor/backup-codes/regenerate",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Replace the backup codes with 10 new ones, shown only once. Requires an authenticator code or an unused backup code. Backup codes are accepted wherever a two-factor code is asked at login, each one once",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "code", Type: apidocs.TYPE_STRING},
+				},
+			},
 		},
 		{
 			Group:        "User Authorization",
@@ -858,6 +1169,20 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			AuthRequired: false,
 			Desc:         "Update rate limiter configuration",
 		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/upload/progress/:id",
+			Method:       http.MethodGet,
+			AuthRequired: false,
+			Desc:         "Get the progress of an upload sent with the X-Upload-Id header. Uploads are limited per route (BODY_LIMIT_UPLOAD_MB, default BODY_LIMIT_DEFAULT_MB) and rejected with 413 when larger",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "progress", Type: "object", Desc: "id, path, received, total (-1 when unknown), done, error, startedAt, updatedAt"},
+					{Name: "percent", Type: apidocs.TYPE_FLOAT, Desc: "Percentage received, -1 when the total size is unknown"},
+				},
+			},
+		},
 		{
 			Group:        "System Module",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/system/search/status",
@@ -1078,6 +1403,13 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			AuthRequired: true,
 			Desc:         "Delete an assistant",
 		},
+		{
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Concurrency limit of an assistant (maxConcurrentSessions, sessionQueueSize, sessionQueueTimeout, callbackOfferAfter and whether callbacks are enabled, which also needs the admin-configured callback prompt) with live active/waiting sessions, admitted, queued, rejected and timed-out counts and queue wait times since the server started",
+		},
 		{
 			Group:        "Assistants",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/js",
@@ -1174,95 +1506,2424 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 				},
 			},
 		},
-
-		// ==================== JS Templates ====================
-		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates",
-			Method:       http.MethodPost,
-			AuthRequired: true,
-			Desc:         "Create a new JS template",
-			Request:      apidocs.GetDocDefine(models.JSTemplate{}),
-		},
 		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/:id",
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/escalation-connectors",
 			Method:       http.MethodGet,
 			AuthRequired: true,
-			Desc:         "Get a JS template by ID",
-			Response: &apidocs.DocField{
-				Type:   "object",
-				Fields: apidocs.GetDocDefine(models.JSTemplate{}).Fields,
-			},
+			Desc:         "List ticketing connectors used to escalate unresolved conversations (secrets are masked)",
 		},
 		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/name/:name",
-			Method:       http.MethodGet,
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/escalation-connectors",
+			Method:       http.MethodPost,
 			AuthRequired: true,
-			Desc:         "Get JS templates by name",
-			Response: &apidocs.DocField{
-				Type:   "array",
-				Fields: apidocs.GetDocDefine(models.JSTemplate{}).Fields,
+			Desc:         "Add a Jira, Zendesk or generic webhook escalation connector",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "provider", Type: apidocs.TYPE_STRING, Required: true, Desc: "jira, zendesk or webhook"},
+					{Name: "name", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Display name"},
+					{Name: "config", Type: "object", Required: true, Desc: "jira: baseUrl, email, apiToken, projectKey, issueType; zendesk: subdomain or baseUrl, email, apiToken; webhook: url, secret"},
+					{Name: "titleTemplate", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Go text/template for the ticket title"},
+					{Name: "bodyTemplate", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Go text/template for the ticket body (Summary, Reason, Issues, Transcript, SessionID, RecordingID...)"},
+					{Name: "priority", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Ticket priority"},
+					{Name: "labels", Type: "array", CanNull: true, Desc: "Ticket labels / tags"},
+					{Name: "autoEscalate", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Create tickets when call analysis marks a conversation unresolved (default true)"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Whether the connector is enabled"},
+				},
 			},
 		},
 		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates",
-			Method:       http.MethodGet,
-			AuthRequired: true,
-			Desc:         "List JS templates",
-		},
-		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/:id",
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/escalation-connectors/:connectorId",
 			Method:       http.MethodPut,
 			AuthRequired: true,
-			Desc:         "Update a JS template",
+			Desc:         "Update an escalation connector, omitted secrets keep their stored value",
 		},
 		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/:id",
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/escalation-connectors/:connectorId",
 			Method:       http.MethodDelete,
 			AuthRequired: true,
-			Desc:         "Delete a JS template",
+			Desc:         "Delete an escalation connector",
 		},
 		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/default",
+			Group:        "Team Inbox",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/inbox/conversations",
 			Method:       http.MethodGet,
 			AuthRequired: true,
-			Desc:         "List default JS templates",
+			Desc:         "Escalated and flagged conversations of the assistants you own, of your organizations' assistants and of assistants shared with your organizations. Open conversations come first, closest SLA deadline first. Each item has the conversation, slaState (first_response, resolve, or empty when pending/resolved) and breached. counts has the number per status for the same assistant, source and assignee filters",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "assistantId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Only this assistant"},
+					{Name: "status", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "open, pending or resolved"},
+					{Name: "source", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "escalation or flag"},
+					{Name: "assigneeId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Assigned to this user"},
+					{Name: "mine", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Assigned to you"},
+					{Name: "unassigned", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Not assigned to anyone"},
+					{Name: "breached", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Open conversations past their current SLA target"},
+					{Name: "page", Type: apidocs.TYPE_INT, CanNull: true},
+					{Name: "pageSize", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Default 20, at most 100"},
+				},
+			},
 		},
 		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/custom",
-			Method:       http.MethodGet,
+			Group:        "Team Inbox",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/inbox/conversations",
+			Method:       http.MethodPost,
 			AuthRequired: true,
-			Desc:         "List custom JS templates",
+			Desc:         "Flag a chat session or call recording for the assistant's team. A conversation already in the inbox is returned instead of a duplicate, a resolved one is reopened. The team is notified in-app and by push (notification event inbox). Escalating a call recording, manually or when analysis marks it unresolved, also puts it in the inbox",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "assistantId", Type: apidocs.TYPE_INT, Required: true},
+					{Name: "sessionId", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Chat session, required without callRecordingId"},
+					{Name: "callRecordingId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Call recording of the assistant"},
+					{Name: "subject", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "reason", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Why the conversation needs attention"},
+					{Name: "priority", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "urgent (SLA 15 min first response / 4 h resolve), high (1 h / 8 h), normal (4 h / 24 h, default) or low (24 h / 72 h)"},
+				},
+			},
 		},
 		{
-			Group:        "JS Templates",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/search",
+			Group:        "Team Inbox",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/inbox/conversations/:id",
 			Method:       http.MethodGet,
 			AuthRequired: true,
-			Desc:         "Search JS templates",
+			Desc:         "Conversation with its internal notes, the SLA targets of its priority and the team members it can be assigned to",
 		},
-
-		// ==================== Chat ====================
 		{
-			Group:        "Chat",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/chat/chat-session-log",
-			Method:       http.MethodGet,
+			Group:        "Team Inbox",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/inbox/conversations/:id",
+			Method:       http.MethodPut,
 			AuthRequired: true,
-			Desc:         "Get chat session logs",
+			Desc:         "Assign, change status or priority. The first assignment, note or status change by a teammate stops the first response timer; resolving stops the resolve timer and reopening starts a new one. Pending conversations are not checked against the SLA. New assignees are notified",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "assigneeId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Team member, 0 to unassign"},
+					{Name: "status", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "open, pending or resolved"},
+					{Name: "priority", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "urgent, high, normal or low; SLA targets are recomputed from when the conversation was opened"},
+				},
+			},
 		},
 		{
-			Group:        "Chat",
-			Path:         config.GlobalConfig.Server.APIPrefix + "/chat/chat-session-log/:id",
-			Method:       http.MethodGet,
+			Group:        "Team Inbox",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/inbox/conversations/:id/notes",
+			Method:       http.MethodPost,
 			AuthRequired: true,
-			Desc:         "Get chat session log detail",
+			Desc:         "Add an internal note (at most 4000 characters), visible to the team only. The assignee is notified",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "body", Type: apidocs.TYPE_STRING, Required: true},
+				},
+			},
+		},
+		{
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/calendar",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the calendar bound to the assistant and the scopes of its calendar tools",
+		},
+		{
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/calendar",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Bind a connected calendar to the assistant",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "credentialId", Type: apidocs.TYPE_INT, Required: true, Desc: "Connected calendar ID"},
+					{Name: "scopes", Type: "array", CanNull: true, Desc: "Granted tools: availability, create_event, send_invite"},
+					{Name: "timezone", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "IANA timezone used to interpret times, default UTC"},
+					{Name: "defaultDuration", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Default meeting length in minutes (default 30)"},
+					{Name: "workdayStart", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "HH:MM, start of the availability window (default 09:00)"},
+					{Name: "workdayEnd", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "HH:MM, end of the availability window (default 18:00)"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Whether the calendar tools are enabled"},
+				},
+			},
+		},
+		{
+			Group:        "Assistants",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/calendar",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Unbind the assistant calendar",
+		},
+		// ==================== Calendar ====================
+		{
+			Group:        "Calendar",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/calendar/credentials",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List connected calendar accounts (tokens and passwords are never returned)",
+		},
+		{
+			Group:        "Calendar",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/calendar/credentials/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Disconnect a calendar account and unbind it from assistants",
+		},
+		{
+			Group:        "Calendar",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/calendar/caldav",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Connect a CalDAV calendar collection, credentials are verified before saving",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "name", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Display name"},
+					{Name: "url", Type: apidocs.TYPE_STRING, Required: true, Desc: "Calendar collection URL"},
+					{Name: "username", Type: apidocs.TYPE_STRING, Required: true, Desc: "CalDAV username"},
+					{Name: "password", Type: apidocs.TYPE_STRING, Required: true, Desc: "CalDAV password or app password"},
+				},
+			},
+		},
+		{
+			Group:        "Calendar",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/calendar/google/authorize",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the Google consent URL to connect a Google Calendar",
+		},
+		{
+			Group:  "Calendar",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/calendar/google/callback",
+			Method: http.MethodGet,
+			Desc:   "Google OAuth redirect target, stores the tokens for the user that started the authorization",
+		},
+		// ==================== Live Captions ====================
+		{
+			Group:        "Live Captions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/live/captions/:bucket/:stream",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Start automatic captions for a live stream played through a whep domain",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "sourceUrl", Type: apidocs.TYPE_STRING, Required: true, Desc: "Pullable rtmp/flv/hls URL of the stream, used to read its audio"},
+					{Name: "credentialId", Type: apidocs.TYPE_INT, Required: true, Desc: "Credential providing the streaming ASR"},
+					{Name: "language", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Recognition language, defaults to zh-CN"},
+				},
+			},
+		},
+		{
+			Group:        "Live Captions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/live/captions/:bucket/:stream",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the captioning status of a live stream",
+		},
+		{
+			Group:        "Live Captions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/live/captions/:bucket/:stream",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Stop captioning a live stream",
+		},
+		{
+			Group:  "Live Captions",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/live/captions/:bucket/:stream/captions.vtt",
+			Method: http.MethodGet,
+			Desc:   "WebVTT segment of the stream captions, pass ?since= with the X-Caption-Last-Id header of the previous segment to get newer cues only",
+		},
+		{
+			Group:  "Live Captions",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/live/captions/:bucket/:stream/ws",
+			Method: http.MethodGet,
+			Desc:   "WebSocket feed of caption cues, including interim results, for players rendering subtitles",
+		},
+		// ==================== Dial-in Numbers ====================
+		{
+			Group:        "Dial-in Numbers",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/dids",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the dial-in numbers (DIDs) and SIP aliases of the current user",
+		},
+		{
+			Group:        "Dial-in Numbers",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/dids",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Register a dial-in number and route its inbound calls to an assistant, IVR workflow or agent queue",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "number", Type: apidocs.TYPE_STRING, Required: true, Desc: "E.164 number (e.g. +8613800138000) or SIP alias, normalized on save"},
+					{Name: "label", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "targetType", Type: apidocs.TYPE_STRING, Required: true, Desc: "assistant, ivr or queue"},
+					{Name: "assistantId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Assistant answering the call; fallback when an ivr or queue DID cannot transfer"},
+					{Name: "workflowId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Workflow of an ivr DID, its transferTo or assistantId output decides where the call goes"},
+					{Name: "queueAgents", Type: "array", CanNull: true, Desc: "SIP usernames of a queue DID, the first available registered agent gets the call"},
+					{Name: "greeting", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Overrides the opening message of the assistant"},
+					{Name: "groupId", Type: apidocs.TYPE_INT, CanNull: true},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Defaults to true"},
+				},
+			},
+		},
+		{
+			Group:        "Dial-in Numbers",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/dids/import",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Bulk register or update dial-in numbers from a JSON array or a CSV file (text/csv body or multipart field file, header number,label,targetType,assistantId,workflowId,queueAgents,greeting,enabled with queueAgents separated by ;)",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "created", Type: apidocs.TYPE_INT},
+					{Name: "updated", Type: apidocs.TYPE_INT},
+					{Name: "errors", Type: "array", Fields: []apidocs.DocField{
+						{Name: "row", Type: apidocs.TYPE_INT},
+						{Name: "number", Type: apidocs.TYPE_STRING},
+						{Name: "error", Type: apidocs.TYPE_STRING},
+					}},
+				},
+			},
+		},
+		{
+			Group:        "Dial-in Numbers",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/dids/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get a dial-in number",
+		},
+		{
+			Group:        "Dial-in Numbers",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/dids/:id",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Update the number or the routing of a dial-in number",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "number", Type: apidocs.TYPE_STRING, Required: true, Desc: "E.164 number (e.g. +8613800138000) or SIP alias, normalized on save"},
+					{Name: "label", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "targetType", Type: apidocs.TYPE_STRING, Required: true, Desc: "assistant, ivr or queue"},
+					{Name: "assistantId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Assistant answering the call; fallback when an ivr or queue DID cannot transfer"},
+					{Name: "workflowId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Workflow of an ivr DID, its transferTo or assistantId output decides where the call goes"},
+					{Name: "queueAgents", Type: "array", CanNull: true, Desc: "SIP usernames of a queue DID, the first available registered agent gets the call"},
+					{Name: "greeting", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Overrides the opening message of the assistant"},
+					{Name: "groupId", Type: apidocs.TYPE_INT, CanNull: true},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Defaults to true"},
+				},
+			},
+		},
+		{
+			Group:        "Dial-in Numbers",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/dids/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete a dial-in number",
+		},
+		{
+			Group:        "Dial-in Numbers",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/dids/:id/stats",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Call analytics of a dial-in number over the last ?days= days (default 30): totals, answer rate, unique callers, routes and a daily series",
+		},
+		// ==================== Call Surveys ====================
+		{
+			Group:        "Call Surveys",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/surveys/settings",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the post-call satisfaction survey settings of the current user, defaults when not configured",
+		},
+		{
+			Group:        "Call Surveys",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/surveys/settings",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Configure the DTMF survey played after the assistant ends an AI call or an agent hangs up an outgoing call with survey=true",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Required: true},
+					{Name: "prompt", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Prompt spoken by TTS on AI calls"},
+					{Name: "promptFile", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "WAV prompt (8kHz 16bit mono), preferred over prompt and required for agent calls"},
+					{Name: "thanks", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Spoken after a rating on AI calls"},
+					{Name: "scale", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Highest score, keys 1..scale are valid (2-9, default 5)"},
+					{Name: "timeoutSeconds", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Time to wait for a key (3-60, default 10)"},
+					{Name: "channels", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "ai, agent or both comma separated, empty for all"},
+				},
+			},
+		},
+		{
+			Group:        "Call Surveys",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/surveys/report",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Satisfaction report over ?from=&to= (YYYY-MM-DD, default the last 30 days) grouped by ?groupBy=assistant|agent|day: surveys, responses, response rate, average score on a 5-point scale, CSAT and score distribution",
+		},
+		{
+			Group:        "Call Surveys",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/surveys/calls/:callId",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the survey result of a call",
+		},
+		// ==================== Authorization Policies ====================
+		{
+			Group:        "Authorization Policies",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/authz/policies",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the attribute-based authorization policies (admin only) and the engine enforcing them (POLICY_ENGINE: rules, opa or empty for RBAC only)",
+		},
+		{
+			Group:        "Authorization Policies",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/authz/policies",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create a policy evaluated after RBAC by the built-in rule engine",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "name", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "description", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "subject", Type: apidocs.TYPE_STRING, Required: true, Desc: "*, role:<role>, user:<id> or group:<id>"},
+					{Name: "resource", Type: apidocs.TYPE_STRING, Required: true, Desc: "Resource name, * matches any characters, e.g. call_recordings*"},
+					{Name: "actions", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Comma separated actions (list, read, analyze, escalate...), empty for all"},
+					{Name: "effect", Type: apidocs.TYPE_STRING, Required: true, Desc: "allow or deny"},
+					{Name: "conditions", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "JSON array of {attr, op, value}; op is eq, ne, in, not_in, prefix or exists; attr and $-prefixed values are paths like subject.region, object.id, env.ip"},
+					{Name: "priority", Type: apidocs.TYPE_INT, CanNull: true, Desc: "The highest matching priority decides, deny wins ties"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN},
+				},
+			},
+		},
+		{
+			Group:        "Authorization Policies",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/authz/policies/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get an authorization policy",
+		},
+		{
+			Group:        "Authorization Policies",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/authz/policies/:id",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Replace an authorization policy",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "name", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "description", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "subject", Type: apidocs.TYPE_STRING, Required: true, Desc: "*, role:<role>, user:<id> or group:<id>"},
+					{Name: "resource", Type: apidocs.TYPE_STRING, Required: true, Desc: "Resource name, * matches any characters, e.g. call_recordings*"},
+					{Name: "actions", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "Comma separated actions (list, read, analyze, escalate...), empty for all"},
+					{Name: "effect", Type: apidocs.TYPE_STRING, Required: true, Desc: "allow or deny"},
+					{Name: "conditions", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "JSON array of {attr, op, value}; op is eq, ne, in, not_in, prefix or exists; attr and $-prefixed values are paths like subject.region, object.id, env.ip"},
+					{Name: "priority", Type: apidocs.TYPE_INT, CanNull: true, Desc: "The highest matching priority decides, deny wins ties"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN},
+				},
+			},
+		},
+		{
+			Group:        "Authorization Policies",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/authz/policies/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete an authorization policy",
+		},
+		{
+			Group:        "Authorization Policies",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/authz/evaluate",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Dry-run an authorization request without enforcing it; candidate policies are evaluated together with the enabled ones",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "userId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Build the subject (role, groups, region...) from this user"},
+					{Name: "subject", Type: "object", CanNull: true, Desc: "{id, role, groups, attrs} used when userId is not set"},
+					{Name: "resource", Type: apidocs.TYPE_STRING, Required: true, Desc: "e.g. call_recordings/12"},
+					{Name: "action", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "object", Type: "object", CanNull: true, Desc: "Resource attributes"},
+					{Name: "env", Type: "object", CanNull: true},
+					{Name: "candidates", Type: "array", CanNull: true, Desc: "Unsaved policies to try"},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "allowed", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "decision", Type: "object", Desc: "{effect: allow|deny|none, ruleId, reason, engine}"},
+					{Name: "enforced", Type: apidocs.TYPE_STRING, Desc: "Engine currently enforcing policies"},
+				},
+			},
+		},
+		// ==================== SCIM Provisioning ====================
+		{
+			Group:        "SCIM Provisioning",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/scim/tokens",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the SCIM bearer tokens issued to identity providers (admin only) with their attribute mappings",
+		},
+		{
+			Group:        "SCIM Provisioning",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/scim/tokens",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Issue a SCIM bearer token; the plaintext token is only returned once",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "name", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "groupId", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Scope the token to an organization; provisioned users join it"},
+					{Name: "expiresIn", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Days until expiry, 0 for never"},
+					{Name: "mapping", Type: "object", CanNull: true, Desc: "User field to SCIM path, e.g. {\"email\": \"userName\", \"phone\": \"phoneNumbers[primary eq true].value\"}"},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "token", Type: apidocs.TYPE_STRING},
+					{Name: "scimUrl", Type: apidocs.TYPE_STRING, Desc: "Base URL to configure in the identity provider"},
+					{Name: "info", Type: "object"},
+				},
+			},
+		},
+		{
+			Group:        "SCIM Provisioning",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/scim/tokens/:id/mapping",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Replace the attribute mapping of a token; an empty object restores the default mapping. Supported fields: email, displayName, firstName, lastName, phone, locale, timezone, city, region",
+		},
+		{
+			Group:        "SCIM Provisioning",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/scim/tokens/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Revoke a SCIM token",
+		},
+		{
+			Group:  "SCIM Provisioning",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/scim/v2/Users",
+			Method: http.MethodGet,
+			Desc:   "SCIM 2.0 user list (Authorization: Bearer <SCIM token>); filter supports userName, externalId, emails.value, displayName, id and active joined with and",
+		},
+		{
+			Group:  "SCIM Provisioning",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/scim/v2/Users",
+			Method: http.MethodPost,
+			Desc:   "Provision a user; an existing user with the same email is linked when the token is not scoped to an organization",
+		},
+		{
+			Group:  "SCIM Provisioning",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/scim/v2/Users/:id",
+			Method: http.MethodPatch,
+			Desc:   "Update a user with SCIM PatchOp operations (add, replace, remove); GET and PUT are also supported",
+		},
+		{
+			Group:  "SCIM Provisioning",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/scim/v2/Users/:id",
+			Method: http.MethodDelete,
+			Desc:   "Deprovision a user; the account is deactivated, not deleted",
+		},
+		{
+			Group:  "SCIM Provisioning",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/scim/v2/Groups",
+			Method: http.MethodGet,
+			Desc:   "SCIM 2.0 group list mapped onto organizations; POST, GET/PUT/PATCH/DELETE /Groups/:id manage names and members",
+		},
+		{
+			Group:  "SCIM Provisioning",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/scim/v2/Bulk",
+			Method: http.MethodPost,
+			Desc:   "Apply up to 1000 user and group operations; bulkId:<id> references resources created earlier in the request",
+		},
+		{
+			Group:  "SCIM Provisioning",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/scim/v2/ServiceProviderConfig",
+			Method: http.MethodGet,
+			Desc:   "SCIM discovery; /ResourceTypes and /Schemas are also available",
+		},
+		// ==================== Token Refresh ====================
+		{
+			Group:  "Token Refresh",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/token/refresh",
+			Method: http.MethodPost,
+			Desc:   "Exchange a refresh token for a new access token (ACCESS_TOKEN_EXPIRED, default 15m) and a new refresh token (AUTH_TOKEN_EXPIRED, default 7 days). The presented refresh token stops working; presenting it again revokes every token of that login. Logins that return a token also return refreshToken",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "refreshToken", Type: apidocs.TYPE_STRING},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "token", Type: apidocs.TYPE_STRING, Desc: "Access token"},
+					{Name: "refreshToken", Type: apidocs.TYPE_STRING},
+					{Name: "expiresAt", Type: apidocs.TYPE_DATE, Desc: "Expiry of the access token"},
+				},
+			},
+		},
+		{
+			Group:        "Token Refresh",
+			Path:         config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/token/revoke",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Revoke the access token of the request right away. With refreshToken the login it belongs to is revoked as well; with all=true every access and refresh token of the user is. Logout and password changes revoke tokens the same way",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "refreshToken", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "all", Type: apidocs.TYPE_BOOLEAN, CanNull: true},
+				},
+			},
+		},
+		{
+			Group:        "Token Refresh",
+			Path:         config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/token/revoke-user/:id",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Revoke every access and refresh token of a user at once (admin), e.g. after a token leaked",
+		},
+		// ==================== Captcha ====================
+		{
+			Group:  "Captcha",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/captcha",
+			Method: http.MethodGet,
+			Desc:   "Captcha challenge for login and signup, chosen by CAPTCHA_PROVIDER. The image captcha returns id and image; submit them as captchaId and captchaCode. Turnstile, hCaptcha and reCAPTCHA return the siteKey of the widget; submit its token as captchaCode",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "provider", Type: apidocs.TYPE_STRING, Desc: "image, turnstile, hcaptcha or recaptcha"},
+					{Name: "id", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "image", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "data:image/png;base64,..."},
+					{Name: "siteKey", Type: apidocs.TYPE_STRING, CanNull: true},
+				},
+			},
+		},
+		// ==================== Phone Login ====================
+		{
+			Group:  "Phone Login",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/login/phone/send",
+			Method: http.MethodPost,
+			Desc:   "Send a login code by SMS (SMS_PROVIDER: aliyun or twilio) to a phone number verified by an account. The answer is the same for numbers without an account; fails when no SMS provider is configured. Codes live 5 minutes; the same number may ask again after 60 seconds, the same IP after 30 seconds, 429 with Retry-After otherwise",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "phone", Type: apidocs.TYPE_STRING, Required: true, Desc: "E.164, e.g. +8613800138000"},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "expiresInSeconds", Type: apidocs.TYPE_INT},
+					{Name: "cooldownSeconds", Type: apidocs.TYPE_INT},
+					{Name: "maxVerifyAttempts", Type: apidocs.TYPE_INT},
+				},
+			},
+		},
+		{
+			Group:  "Phone Login",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/login/phone",
+			Method: http.MethodPost,
+			Desc:   "Log in with a verified phone number and the SMS code, with the same account lock, captcha, device and two-factor checks as password login. Accounts with two-factor authentication get requiresTwoFactor first and resubmit the same SMS code with twoFactorCode",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "phone", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "code", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "twoFactorCode", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "TOTP or backup code, required when two-factor authentication is enabled"},
+					{Name: "AuthToken", Type: apidocs.TYPE_BOOLEAN, CanNull: true, Desc: "Return token and refreshToken"},
+					{Name: "timezone", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "captchaId", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "captchaCode", Type: apidocs.TYPE_STRING, CanNull: true},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "user", Type: apidocs.TYPE_OBJECT},
+					{Name: "token", Type: apidocs.TYPE_STRING},
+					{Name: "refreshToken", Type: apidocs.TYPE_STRING},
+					{Name: "suspiciousLogin", Type: apidocs.TYPE_BOOLEAN, CanNull: true},
+					{Name: "requiresTwoFactor", Type: apidocs.TYPE_BOOLEAN, CanNull: true},
+				},
+			},
+		},
+		{
+			Group:        "Phone Login",
+			Path:         config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/send-phone-verification",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Send a code by SMS to the current user's phone number; verify it with /verify-phone. A number can be verified by one account only, and changing the number clears the verification",
+		},
+		// ==================== Social Login ====================
+		{
+			Group:  "Social Login",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/oauth/providers",
+			Method: http.MethodGet,
+			Desc:   "Enabled OAuth2 login providers (google, github, wechat) with their login URLs",
+		},
+		{
+			Group:  "Social Login",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/oauth/:provider/login",
+			Method: http.MethodGet,
+			Desc:   "Redirect to the provider's consent page and return to the relative path in ?redirect= afterwards. With ?link=1 the signed-in user links the account instead of signing in",
+		},
+		{
+			Group:  "Social Login",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/oauth/:provider/callback",
+			Method: http.MethodGet,
+			Desc:   "OAuth2 redirect URI to register at the provider. Signs in the linked user, links the user with the same verified email, or creates a user when OAUTH_AUTO_PROVISION is on. Accounts with two-factor authentication get #twoFactorTicket=... in the redirect instead of a session",
+		},
+		{
+			Group:  "Social Login",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/oauth/two-factor",
+			Method: http.MethodPost,
+			Desc:   "Complete a social login with the account's TOTP code; the ticket is valid for 5 minutes",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "ticket", Type: apidocs.TYPE_STRING, Required: true, Desc: "twoFactorTicket from the callback redirect"},
+					{Name: "code", Type: apidocs.TYPE_STRING, Required: true},
+				},
+			},
+		},
+		{
+			Group:        "Social Login",
+			Path:         config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/oauth/identities",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Social accounts linked to the current user",
+		},
+		{
+			Group:        "Social Login",
+			Path:         config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/oauth/identities/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Unlink a social account from the current user",
+		},
+		// ==================== SAML SSO ====================
+		{
+			Group:  "SAML SSO",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/saml/discover",
+			Method: http.MethodGet,
+			Desc:   "Check whether ?email= must sign in through its organization's identity provider",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "sso", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "enforced", Type: apidocs.TYPE_BOOLEAN, Desc: "Password and code login are rejected for this email"},
+					{Name: "groupId", Type: apidocs.TYPE_INT},
+					{Name: "loginUrl", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:  "SAML SSO",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/saml/:groupId/metadata",
+			Method: http.MethodGet,
+			Desc:   "Service provider metadata XML to import into the identity provider",
+		},
+		{
+			Group:  "SAML SSO",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/saml/:groupId/login",
+			Method: http.MethodGet,
+			Desc:   "Start SP-initiated login; redirects to the identity provider and returns to the relative path in ?redirect= afterwards. With ?portal=device-claim no console session is started, the redirect carries a technician token in the fragment (#token=...&expiresAt=...)",
+		},
+		{
+			Group:  "SAML SSO",
+			Path:   config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/saml/:groupId/acs",
+			Method: http.MethodPost,
+			Desc:   "Assertion consumer service (HTTP-POST binding); validates the signed response, provisions the user and starts a session",
+		},
+		{
+			Group:        "SAML SSO",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/saml",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the organization's SAML configuration and the SP values to enter in the identity provider (organization admin)",
+		},
+		{
+			Group:        "SAML SSO",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/saml",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Create or replace the organization's SAML configuration (organization admin)",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "metadataXml", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "IdP metadata; overrides the IdP fields below"},
+					{Name: "idpEntityId", Type: apidocs.TYPE_STRING, CanNull: true},
+					{Name: "idpSsoUrl", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "HTTP-Redirect SSO endpoint"},
+					{Name: "idpCertificate", Type: apidocs.TYPE_STRING, CanNull: true, Desc: "PEM signing certificate(s)"},
+					{Name: "mapping", Type: "object", CanNull: true, Desc: "User field to assertion attribute, e.g. {\"email\": \"mail\", \"firstName\": \"givenName\"}"},
+					{Name: "jitProvisioning", Type: apidocs.TYPE_BOOLEAN, Desc: "Create unknown users on first login and add them to the organization"},
+					{Name: "enforceSso", Type: apidocs.TYPE_BOOLEAN, Desc: "Reject password and code login for the claimed domains"},
+					{Name: "technicianPortal", Type: apidocs.TYPE_BOOLEAN, Desc: "Allow members to sign in to the device claim portal"},
+				},
+			},
+		},
+		{
+			Group:        "SAML SSO",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/saml",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Remove the organization's SAML configuration (organization admin)",
+		},
+		{
+			Group:        "SAML SSO",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/saml/domains",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Claim the email domains routed to the organization's identity provider (system admin only); a domain can belong to one organization",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "domains", Type: "array", Required: true},
+				},
+			},
+		},
+		{
+			Group:        "SAML SSO",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/saml/events",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List SSO audit events of the organization (organization admin), filtered by ?event= (login_started, login_succeeded, login_failed, user_provisioned, password_login_blocked, config_updated, config_deleted, domains_updated, technician_token_issued) and ?email=, paginated by ?page=&size=",
+		},
+		// ==================== Device Claim Portal ====================
+		{
+			Group:  "Device Claim Portal",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device-claim/session",
+			Method: http.MethodGet,
+			Desc:   "Describe the technician token sent as Authorization: Bearer tech_...; portal tokens only work on /device-claim and expire after 12 hours",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "technicianId", Type: apidocs.TYPE_INT},
+					{Name: "email", Type: apidocs.TYPE_STRING},
+					{Name: "groupId", Type: apidocs.TYPE_INT},
+					{Name: "groupName", Type: apidocs.TYPE_STRING},
+					{Name: "scopes", Type: "array", Desc: "device:claim, device:diagnostics"},
+					{Name: "expiresAt", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:  "Device Claim Portal",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device-claim/assistants",
+			Method: http.MethodGet,
+			Desc:   "Organization assistants a device can be claimed for",
+		},
+		{
+			Group:  "Device Claim Portal",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device-claim/claims",
+			Method: http.MethodPost,
+			Desc:   "Activate a device with the code it displays and bind it to an organization assistant; the device belongs to the organization",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "activationCode", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "assistantId", Type: apidocs.TYPE_INT, Required: true},
+					{Name: "deviceName", Type: apidocs.TYPE_STRING, CanNull: true},
+				},
+			},
+		},
+		{
+			Group:  "Device Claim Portal",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device-claim/claims",
+			Method: http.MethodGet,
+			Desc:   "The technician's own claim history in the organization, paginated by ?page=&size=",
+		},
+		{
+			Group:  "Device Claim Portal",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device-claim/devices/:deviceId/diagnostics",
+			Method: http.MethodGet,
+			Desc:   "Status, resource usage and the 20 most recent error logs of an organization device",
+		},
+		{
+			Group:        "Device Claim Portal",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/device-claims",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Claim history of the organization (organization admin), filtered by ?technicianId= and paginated by ?page=&size=",
+		},
+		{
+			Group:        "Device Claim Portal",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/device-claims/:claimId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Revoke a claim and unbind its device unless it was claimed again since (organization admin)",
+		},
+		{
+			Group:        "Device Claim Portal",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/technician-tokens",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Technician tokens issued by the organization, newest first, filtered by ?technicianId= (organization admin)",
+		},
+		{
+			Group:        "Device Claim Portal",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/technician-tokens/:tokenId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Revoke a technician token (organization admin)",
+		},
+		{
+			Group:        "Device Claim Portal",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/technicians/:userId/tokens",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Revoke every active token of a technician (organization admin)",
+		},
+		// ==================== Differential Sync ====================
+		{
+			Group:        "Differential Sync",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sync",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Changes of the user's devices, assistants and recordings since ?cursor= (or ?since= as millisecond timestamp or RFC3339), at most ?limit= per entity (default 200, max 1000). Without a cursor, or with one older than 30 days, a full snapshot is returned. Changes may repeat across pages, apply them by ID",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "devices", Type: "array", Desc: "Created or updated devices"},
+					{Name: "assistants", Type: "array", Desc: "Created or updated assistants"},
+					{Name: "recordings", Type: "array", Desc: "Created or updated call recordings"},
+					{Name: "deleted", Type: "array", Desc: "Tombstones: entity (device, assistant, recording), id, deletedAt"},
+					{Name: "cursor", Type: apidocs.TYPE_STRING, Desc: "Pass as ?cursor= on the next sync"},
+					{Name: "hasMore", Type: apidocs.TYPE_BOOLEAN, Desc: "More changes are pending, sync again right away"},
+					{Name: "full", Type: apidocs.TYPE_BOOLEAN, Desc: "Full snapshot, clear local data before applying"},
+				},
+			},
+		},
+		// ==================== SIP Load Test ====================
+		{
+			Group:        "SIP Load Test",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/loadtest",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Start a load/soak test (admin only): simulated UAs register, call the callee, exchange RTP from a PCM fixture and hang up. Durations are in seconds; only one run at a time",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "target", Type: apidocs.TYPE_STRING, Desc: "Target SIP server host:port, defaults to the local SIP server"},
+					{Name: "localIp", Type: apidocs.TYPE_STRING, Desc: "Local bind address for SIP and RTP"},
+					{Name: "users", Type: apidocs.TYPE_INT, Desc: "Number of simulated UAs (default 10, max 2000)"},
+					{Name: "callsPerUser", Type: apidocs.TYPE_INT, Desc: "Calls per UA (default 1), -1 keeps calling until duration ends"},
+					{Name: "duration", Type: apidocs.TYPE_FLOAT, Desc: "Maximum run time (default 300, max 86400)"},
+					{Name: "rampUp", Type: apidocs.TYPE_FLOAT, Desc: "Time to start all UAs"},
+					{Name: "callHold", Type: apidocs.TYPE_FLOAT, Desc: "Time each answered call is held (default 10)"},
+					{Name: "pause", Type: apidocs.TYPE_FLOAT, Desc: "Pause between calls of the same UA"},
+					{Name: "callTimeout", Type: apidocs.TYPE_FLOAT, Desc: "Answer timeout (default 30)"},
+					{Name: "register", Type: apidocs.TYPE_BOOLEAN, Desc: "REGISTER each UA before calling"},
+					{Name: "userPrefix", Type: apidocs.TYPE_STRING, Desc: "UA username prefix (default loadtest)"},
+					{Name: "callee", Type: apidocs.TYPE_STRING, Required: true, Desc: "Called user, e.g. a DID or an assistant's SIP user"},
+					{Name: "pcmFile", Type: apidocs.TYPE_STRING, Desc: "8kHz 16-bit mono PCM or WAV fixture on the server, silence if empty"},
+				},
+			},
+		},
+		{
+			Group:        "SIP Load Test",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/loadtest",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Progress of the running load test, or the report of the last run: setup and register latency percentiles, failure rate and reasons, RTP packet counts and peak resource usage",
+		},
+		{
+			Group:        "SIP Load Test",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/loadtest",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Stop the running load test, in-flight calls are hung up",
+		},
+		// ==================== Log Control ====================
+		{
+			Group:        "Log Control",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/log-control",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Runtime log levels of this instance (admin): global level, per-module overrides (auth, sip, knowledge), debug sampling and the active elevation window",
+		},
+		{
+			Group:        "Log Control",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/log-control",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Change levels without a restart (admin). Applies to this instance until it restarts",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "level", Type: apidocs.TYPE_STRING, Desc: "Global level: debug, info, warn or error"},
+					{Name: "modules", Type: apidocs.TYPE_OBJECT, Desc: "Module to level, e.g. {\"auth\":\"warn\",\"sip\":\"debug\"}; an empty level removes the override"},
+					{Name: "sampling", Type: apidocs.TYPE_OBJECT, Desc: "{first, thereafter, tickSeconds}: per tick, log the first N debug entries with the same message, then every Mth; first 0 disables sampling"},
+				},
+			},
+		},
+		{
+			Group:        "Log Control",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/log-control/elevate",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Lower the level for a limited time (admin); the previous levels apply again when the window ends",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "level", Type: apidocs.TYPE_STRING, Desc: "Defaults to debug"},
+					{Name: "modules", Type: apidocs.TYPE_STRING, Desc: "Array of modules, empty for all"},
+					{Name: "durationSeconds", Type: apidocs.TYPE_INT, Required: true, Desc: "At most 14400"},
+				},
+			},
+		},
+		{
+			Group:        "Log Control",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/log-control/elevate",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "End the elevated logging window early (admin)",
+		},
+		// ==================== SIP Hot Standby ====================
+		{
+			Group:        "SIP Hot Standby",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/ha",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Hot standby status (admin): lease holder, instances with their role and heartbeat, whether a standby is ready, and the last failover events. ?cluster= defaults to SIP_HA_CLUSTER",
+		},
+		{
+			Group:        "SIP Hot Standby",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/ha/drill",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Start a failover drill (admin): the active instance's lease expires and the standby takes over the VIP, registrations and in-flight AI calls. The old instance can't take the lease back for 2 minutes. Refused when no standby has a recent heartbeat",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "cluster", Type: apidocs.TYPE_STRING, Desc: "Cluster name, defaults to SIP_HA_CLUSTER"},
+					{Name: "reason", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		// ==================== Provider Guard ====================
+		{
+			Group:        "Provider Guard",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/provider-incidents",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Daily incident counters of the LLM and TTS providers used by AI calls (admin), newest day first. A provider is counted when it exceeds the assistant's stage budget (timeouts) or fails (errors), and when it serves a turn as the failover provider (failovers). ?days= 1-90, default 7",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "days", Type: apidocs.TYPE_INT},
+					{Name: "incidents", Type: "array", Desc: "day, provider, stage (llm/tts), timeouts, errors, failovers, lastError, lastIncidentAt"},
+				},
+			},
+		},
+		// ==================== Recording Custody ====================
+		{
+			Group:        "Recording Custody",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/call-recordings/:id/verify",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Hash the stored recording file with SHA-256 and compare it with the hash taken at finalization. Includes the daily digest the recording belongs to, if created",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "recordingId", Type: apidocs.TYPE_INT},
+					{Name: "storedHash", Type: apidocs.TYPE_STRING, Desc: "SHA-256 stored at finalization"},
+					{Name: "currentHash", Type: apidocs.TYPE_STRING, Desc: "SHA-256 of the file now"},
+					{Name: "size", Type: apidocs.TYPE_INT, Desc: "Bytes hashed"},
+					{Name: "match", Type: apidocs.TYPE_BOOLEAN, Desc: "The recording is unaltered"},
+					{Name: "hashedAt", Type: apidocs.TYPE_STRING},
+					{Name: "checkedAt", Type: apidocs.TYPE_STRING},
+					{Name: "digest", Type: apidocs.TYPE_OBJECT, Desc: "Daily digest containing the hash, with anchoredAt and anchorReceipt once notarized"},
+				},
+			},
+		},
+		{
+			Group:        "Recording Custody",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/recording-digests",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List daily recording digests (admin), newest first, paginated by ?page=&size=. Each digest chains the previous day's digest with the hashes of that day's recordings",
+		},
+		{
+			Group:        "Recording Custody",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/recording-digests/:date",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the digest of a day (YYYY-MM-DD, admin) and recompute it from the stored recording hashes; consistent is false if any hash of that day changed",
+		},
+		{
+			Group:        "Recording Custody",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/recording-digests/:date/anchor",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create the digest of a past day if missing and anchor it in the notary service configured by RECORDING_NOTARY_URL (admin). Digests are also created and anchored daily at 00:30",
+		},
+		// ==================== Login Anomaly ====================
+		{
+			Group:        "Login Anomaly",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/login-anomaly/report",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Compare the login anomaly model with the rule-based score per model version over the last ?days= (default 7, admin). Logins are scored by the model at LOGIN_ANOMALY_MODEL_URL in LOGIN_ANOMALY_MODE shadow (recorded only) or enforce (decides, falling back to the rules on timeout or error); agreement is the share of logins both flag or clear at ?threshold= (default LOGIN_ANOMALY_THRESHOLD)",
+		},
+		// ==================== API Versions ====================
+		{
+			Group:  "API Versions",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/versions",
+			Method: http.MethodGet,
+			Desc:   "List the supported API versions and the versions each resource is served under. Every route is served under /v1 (e.g. " + config.GlobalConfig.Server.APIPrefix + "/v1/auth/login); the unversioned paths keep working but are deprecated and answer with Deprecation, Sunset (LEGACY_API_SUNSET) and Link rel=\"successor-version\" headers",
+		},
+		// ==================== Wallboard ====================
+		{
+			Group:        "Wallboard",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/wallboard",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Active calls, assistant queue depths, registered agents with their presence and today's KPIs in one response (admin). Served from in-memory aggregates fed by the call events of the event bus (call.offered, call.ringing, call.queued, call.answered, call.ended, agent.registered), no database queries",
+		},
+		{
+			Group:        "Wallboard",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/wallboard/calls",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Active calls with their state (offered, ringing, queued, answered), direction, parties and answer time (admin)",
+		},
+		{
+			Group:        "Wallboard",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/wallboard/queues",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Waiting and active sessions per assistant session queue, with the average wait (admin)",
+		},
+		{
+			Group:        "Wallboard",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/wallboard/agents",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Presence of the registered SIP agents: effective status, manual status and active calls (admin)",
+		},
+		{
+			Group:        "Wallboard",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/wallboard/kpis",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Today's offered, answered, abandoned and missed calls, answer rate, average and longest time to answer, and average talk time (admin). Resets at local midnight and on restart",
+		},
+		// ==================== Isolation Audit ====================
+		{
+			Group:        "Isolation Audit",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/isolation-audit",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Seed two synthetic organizations, call the assistant, knowledge base and device list/detail endpoints as each one's user and report records visible across organizations (admin). Seeded data is removed afterwards; passed is false when there are findings or inconclusive probes",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "run", Type: apidocs.TYPE_STRING},
+					{Name: "passed", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "report", Type: "object"},
+				},
+			},
+		},
+		// ==================== Subscriptions ====================
+		{
+			Group:        "Subscriptions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/plans",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the subscription plans (trial, pro, enterprise) with their features, monthly AI minutes (0 for unlimited), knowledge base providers, trial and grace days",
+		},
+		{
+			Group:        "Subscriptions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/subscription",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the organization's subscription (members); null when the organization is not on a plan. Members of subscribed organizations get the best writable plan among them: features such as SIP trunking and knowledge base providers outside the plan are refused with upgradeRequired, and writes are refused with readOnly once the grace period ended",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "plan", Type: apidocs.TYPE_STRING},
+					{Name: "status", Type: apidocs.TYPE_STRING, Desc: "trialing, active, grace or read_only as last stored"},
+					{Name: "effectiveStatus", Type: apidocs.TYPE_STRING, Desc: "Status in effect now"},
+					{Name: "writable", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "endsAt", Type: apidocs.TYPE_DATE, Desc: "End of the trial or paid term, null for no end"},
+					{Name: "graceEndsAt", Type: apidocs.TYPE_DATE},
+					{Name: "planDetail", Type: "object"},
+				},
+			},
+		},
+		{
+			Group:        "Subscriptions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/subscription/trial",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Start the organization's trial (creator or admins). Only organizations without a subscription can start one; the plan's AI minutes are applied to the organization's call duration quota",
+		},
+		{
+			Group:        "Subscriptions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/subscription",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Change the organization's plan and term (admin). Publishes billing.plan_changed; the term ends at paidUntil followed by the plan's grace period",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "plan", Type: apidocs.TYPE_STRING, Required: true, Desc: "trial, pro or enterprise"},
+					{Name: "paidUntil", Type: apidocs.TYPE_DATE, Desc: "End of the paid term, empty for no end"},
+				},
+			},
+		},
+		{
+			Group:        "Subscriptions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/subscription/events",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the organization's billing events, newest first (creator or admins). Query limit caps the result, default 50 and at most 200",
+		},
+		// ==================== Demo Tenant ====================
+		{
+			Group:        "Demo Tenant",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/demo-tenant",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create the demo organization with owner, admin, agent and viewer users, an assistant with a knowledge base, SIP users and devices with telemetry (admin only, not available in production). Existing records are reused so it is safe to call again; the same runs from the command line with -seed-demo",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "password", Type: apidocs.TYPE_STRING, Desc: "Password of newly created demo users, default demo123"},
+					{Name: "devices", Type: apidocs.TYPE_INT, Desc: "Number of demo devices, default 3, at most 50"},
+				},
+			},
+		},
+		// ==================== Custom Domains ====================
+		{
+			Group:        "Custom Domains",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/domains",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the organization's custom domains with verification and certificate status (organization admins)",
+		},
+		{
+			Group:        "Custom Domains",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/domains",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Register a custom domain. The response contains the CNAME record to create; it must point to CUSTOM_DOMAIN_CNAME_TARGET",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "domain", Type: apidocs.TYPE_STRING, Required: true, Desc: "Host name, e.g. voice.example.com"},
+				},
+			},
+		},
+		{
+			Group:        "Custom Domains",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/domains/:domainId/verify",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Check the domain's CNAME record. Once verified, the domain serves the console with the organization's branding and, with CUSTOM_DOMAIN_AUTO_TLS, a certificate is requested automatically",
+		},
+		{
+			Group:        "Custom Domains",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/domains/:domainId/branding",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Set the site branding and mail sender used on the domain. Empty values fall back to the site defaults",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "siteName", Type: apidocs.TYPE_STRING},
+					{Name: "logoUrl", Type: apidocs.TYPE_STRING},
+					{Name: "faviconUrl", Type: apidocs.TYPE_STRING},
+					{Name: "termsUrl", Type: apidocs.TYPE_STRING},
+					{Name: "privacyUrl", Type: apidocs.TYPE_STRING},
+					{Name: "mailFrom", Type: apidocs.TYPE_STRING, Desc: "Sender address on the domain or one of its subdomains"},
+					{Name: "mailFromName", Type: apidocs.TYPE_STRING, Desc: "Sender display name"},
+				},
+			},
+		},
+		{
+			Group:        "Custom Domains",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/domains/:domainId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Remove a custom domain",
+		},
+		{
+			Group:  "Custom Domains",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/system/branding",
+			Method: http.MethodGet,
+			Desc:   "Branding of the requested host: the organization's branding on a verified custom domain, the site defaults otherwise",
+		},
+		// ==================== Cost Estimation ====================
+		{
+			Group:        "Cost Estimation",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/estimate-cost",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Preview the projected cost per call minute and per call of an unsaved assistant configuration, using the provider price table",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "llmModel", Type: apidocs.TYPE_STRING},
+					{Name: "ttsProvider", Type: apidocs.TYPE_STRING},
+					{Name: "asrProvider", Type: apidocs.TYPE_STRING},
+					{Name: "systemPrompt", Type: apidocs.TYPE_STRING, Desc: "Counted into the prompt tokens of every turn"},
+					{Name: "maxTokens", Type: apidocs.TYPE_INT},
+					{Name: "knowledgeBaseId", Type: apidocs.TYPE_STRING, Desc: "Adds retrieval queries and retrieved chunks to the prompt"},
+					{Name: "kbTopK", Type: apidocs.TYPE_INT, Desc: "Chunks retrieved per turn, default 5"},
+					{Name: "telephony", Type: apidocs.TYPE_BOOLEAN, Desc: "Include the telephony minute price"},
+					{Name: "avgCallSeconds", Type: apidocs.TYPE_FLOAT, Desc: "Override the average call length"},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "currency", Type: apidocs.TYPE_STRING},
+					{Name: "costPerMinute", Type: apidocs.TYPE_FLOAT},
+					{Name: "costPerCall", Type: apidocs.TYPE_FLOAT},
+					{Name: "items", Type: apidocs.TYPE_OBJECT, Desc: "Breakdown by component (llm_input, llm_output, asr, tts, kb_retrieval, telephony) with quantity per minute and unit price"},
+					{Name: "assumptions", Type: apidocs.TYPE_OBJECT, Desc: "Call length, turns and tokens per minute used, and whether they come from history"},
+					{Name: "warnings", Type: apidocs.TYPE_STRING, Desc: "Models or providers priced with the default entry"},
+				},
+			},
+		},
+		{
+			Group:        "Cost Estimation",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/estimate-cost",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Same as /assistant/estimate-cost for a saved assistant: omitted fields use its stored settings, and the average call length and token usage come from its usage history when available",
+		},
+		{
+			Group:        "Cost Estimation",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/price-table",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the provider price table: LLM prices per 1k input/output tokens by model (prefix match), ASR/TTS prices per minute by provider, knowledge base retrieval per query and telephony per minute. A \"default\" entry prices unknown models and providers. ttsPerKChars optionally prices TTS per 1k characters by provider for call costs",
+		},
+		{
+			Group:        "Cost Estimation",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/price-table",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Replace the provider price table (admin)",
+		},
+		{
+			Group:        "Cost Estimation",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/calls/cdr",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "CDR cost report: telephony minutes, ASR seconds, LLM tokens and TTS characters with their costs, summed over SIP and device calls started in ?from=&to= (YYYY-MM-DD, default the current month) and per day. Costs are priced from the price table shortly after each call ends and then frozen; call history and call detail carry the per-call breakdown. ?groupId= reports an organization (admins); unrated counts calls not priced yet",
+		},
+		// ==================== Media Streams ====================
+		{
+			Group:        "Media Streams",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/media-streams/calls",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the live AI calls an external media service can attach to (admin)",
+		},
+		{
+			Group:        "Media Streams",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/media-streams/tokens",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Issue a single-use token to open a media stream on a live call (admin)",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "callId", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "mode", Type: apidocs.TYPE_STRING, Desc: "listen (default) receives the caller's audio, duplex also injects audio into the call"},
+					{Name: "ttlSeconds", Type: apidocs.TYPE_INT, Desc: "Token lifetime, default 60, at most 600"},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "token", Type: apidocs.TYPE_STRING},
+					{Name: "grant", Type: apidocs.TYPE_OBJECT},
+					{Name: "streamUrl", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:        "Media Streams",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/media-streams/ws",
+			Method:       http.MethodGet,
+			AuthRequired: false,
+			Desc:         "WebSocket media stream, authorized by the token query parameter. Binary messages carry 16-bit little-endian mono PCM at 8kHz in both directions; text messages are JSON events (start, dropped when the client reads too slowly, end). Injected audio is played in real time and reading pauses while the call's buffer is full",
+		},
+		// ==================== Maintenance Windows ====================
+		{
+			Group:        "Maintenance Windows",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/maintenance-windows",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the fleet's maintenance windows with whether each is open, when it closes or opens next, and the queued device actions it will execute when it opens. Fleets without enabled windows are never gated",
+		},
+		{
+			Group:        "Maintenance Windows",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/maintenance-windows",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Add a maintenance window (organization admin)",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "name", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "schedule", Type: apidocs.TYPE_STRING, Required: true, Desc: "Window starts as a 5-field cron expression, e.g. \"0 2 * * 1-5\""},
+					{Name: "timezone", Type: apidocs.TYPE_STRING, Desc: "IANA time zone of the schedule, default UTC"},
+					{Name: "durationMinutes", Type: apidocs.TYPE_INT, Required: true, Desc: "1 to 1440"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Desc: "Default true"},
+				},
+			},
+		},
+		{
+			Group:        "Maintenance Windows",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/maintenance-windows/:windowId",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Update a maintenance window (organization admin)",
+		},
+		{
+			Group:        "Maintenance Windows",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/maintenance-windows/:windowId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete a maintenance window (organization admin)",
+		},
+		{
+			Group:        "Maintenance Windows",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/device-actions",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the fleet's device actions, filtered by status (queued, executed, cancelled) and deviceId. Firmware updates withheld by the OTA check outside a window are listed as queued firmware actions",
+		},
+		{
+			Group:        "Maintenance Windows",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/device-actions",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Submit a device action (organization admin). Non-disruptive actions, and disruptive ones while a window is open, execute right away; others are queued and execute automatically when a window opens",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "deviceId", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "kind", Type: apidocs.TYPE_STRING, Required: true, Desc: "firmware, config or command"},
+					{Name: "disruptive", Type: apidocs.TYPE_BOOLEAN, Desc: "Gate by maintenance windows, always true for firmware"},
+					{Name: "payload", Type: apidocs.TYPE_STRING, Desc: "Action content, the target version for firmware"},
+				},
+			},
+		},
+		{
+			Group:        "Maintenance Windows",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/device-actions/:actionId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Cancel a queued device action (organization admin)",
+		},
+		// ==================== Device Action Manifests ====================
+		{
+			Group:        "Device Action Manifests",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/action-specs",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the actions a manifest may carry (reboot, factory_reset, firmware_update, set_volume, set_config, upload_diagnostics, clear_cache) with their params and whether they are disruptive",
+		},
+		{
+			Group:        "Device Commands",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/commands",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Run a device command on one of your own or organization-shared devices, either as free text (\"turn volume down on the kitchen device\", \"把客厅音箱音量调到30\") or as an explicit intent. The device is resolved by alias, name or MAC address and the intent is issued as a signed action manifest. Destructive intents (reboot, factory_reset) are only logged and returned with needsConfirmation until confirmed",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "text", Type: apidocs.TYPE_STRING, Desc: "Command text, required when intent is empty"},
+					{Name: "device", Type: apidocs.TYPE_STRING, Desc: "Device alias, name or MAC; may be omitted when you have one device"},
+					{Name: "intent", Type: apidocs.TYPE_STRING, Desc: "volume_up, volume_down, set_volume, mute, reboot, factory_reset or clear_cache"},
+					{Name: "value", Type: apidocs.TYPE_INT, Desc: "Target volume 0-100 for set_volume, or the step for volume_up/volume_down (default 20)"},
+				},
+			},
+		},
+		{
+			Group:        "Device Commands",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/commands/:id/confirm",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Confirm or cancel a command awaiting confirmation. Confirmations expire after 2 minutes",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "confirm", Type: apidocs.TYPE_BOOLEAN, Desc: "true to issue the command, false to cancel it"},
+				},
+			},
+		},
+		{
+			Group:        "Device Commands",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/commands",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Log of device commands run through the API or by assistants with device control enabled (status pending_confirmation, issued, cancelled, expired or failed). Optional deviceId and limit query params",
+		},
+		{
+			Group:        "Device Action Manifests",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/:deviceId/action-manifests",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Build a signed action manifest for the device. Only predefined actions are accepted, there is no remote shell. The manifest is bound to the device, expires after ttlMinutes and is signed with the active Ed25519 key; disruptive actions are held until the fleet's maintenance window opens. Returns the manifest and the signed envelope {kid, payload, signature}",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "action", Type: apidocs.TYPE_STRING, Required: true, Desc: "One of the actions from /device/action-specs"},
+					{Name: "params", Type: "object", Desc: "Action params, validated against the action spec"},
+					{Name: "ttlMinutes", Type: apidocs.TYPE_INT, Desc: "Validity, default 60, at most 10080 (7 days)"},
+				},
+			},
+		},
+		{
+			Group:        "Device Action Manifests",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/:deviceId/action-manifests",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the device's manifests with their status (issued, delivered, succeeded, failed, rejected) and execution receipts",
+		},
+		{
+			Group:  "Device Action Manifests",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device/action-keys",
+			Method: http.MethodGet,
+			Desc:   "Public verification keys {kid, alg, publicKey, notAfter}: the active key and keys rotated out within the 14 day grace period. The same list is returned as action_keys by the OTA check",
+		},
+		{
+			Group:  "Device Action Manifests",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device/action-manifests/pending",
+			Method: http.MethodGet,
+			Desc:   "Signed manifests the device identified by the Device-Id header should run. The device verifies the Ed25519 signature over the base64url payload with the key named by kid, then checks deviceId, exp and that the manifest id was not executed before",
+		},
+		{
+			Group:  "Device Action Manifests",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/device/action-manifests/:manifestId/receipt",
+			Method: http.MethodPost,
+			Desc:   "Execution receipt from the device identified by the Device-Id header, accepted once per manifest",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "status", Type: apidocs.TYPE_STRING, Required: true, Desc: "succeeded, failed, or rejected when verification did not pass"},
+					{Name: "error", Type: apidocs.TYPE_STRING, Desc: "Failure or rejection reason"},
+					{Name: "result", Type: "object", Desc: "Action result"},
+					{Name: "executedAt", Type: apidocs.TYPE_INT, Desc: "Execution time, Unix seconds"},
+				},
+			},
+		},
+		{
+			Group:        "Device Action Manifests",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device-signing-keys",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the manifest signing keys still published to devices (admin only)",
+		},
+		{
+			Group:        "Device Action Manifests",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device-signing-keys/rotate",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Rotate the manifest signing key (admin only). The previous key keeps verifying for 14 days so devices pick up the new key on their next OTA check",
+		},
+		// ==================== Conversation Exports ====================
+		{
+			Group:        "Conversation Exports",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/conversation-exports",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Queue an export of one of your call recordings or chat sessions. json carries per utterance offsets in milliseconds, srt and vtt are subtitles aligned to the recording start, docx is a readable document with the call facts, summary and transcript. The file is generated in the background and a notification with a download link valid for 7 days is sent when it is ready",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "source", Type: apidocs.TYPE_STRING, Required: true, Desc: "call_recording or chat_session"},
+					{Name: "sourceId", Type: apidocs.TYPE_STRING, Required: true, Desc: "Recording ID or chat session ID"},
+					{Name: "format", Type: apidocs.TYPE_STRING, Required: true, Desc: "json, srt, vtt or docx"},
+				},
+			},
+		},
+		{
+			Group:        "Conversation Exports",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/conversation-exports",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List your recent exports with their status (pending, running, completed, failed, expired)",
+		},
+		{
+			Group:        "Conversation Exports",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/conversation-exports/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the status of an export",
+		},
+		{
+			Group:  "Conversation Exports",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/conversation-exports/:id/download",
+			Method: http.MethodGet,
+			Desc:   "Download a completed export with the signed token from the notification (?token=) or as the logged-in requester",
+		},
+		// ==================== Organization Security Policy ====================
+		{
+			Group:        "Organization Security Policy",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/security-policy",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the organization's security policy (members)",
+		},
+		{
+			Group:        "Organization Security Policy",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/security-policy",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Replace the organization's security policy (creator or admins). A member of several organizations is held to the strictest combination: two-factor is required if any organization requires it, the shortest session and password ages apply, and console access must come from an address every allowlist permits. Members without two-factor can only reach the two-factor setup endpoints until they enable it; sessions older than the maximum age are signed out. System admins are exempt",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "requireTwoFactor", Type: apidocs.TYPE_BOOLEAN, Desc: "Members must enable two-factor authentication"},
+					{Name: "sessionMaxAgeMinutes", Type: apidocs.TYPE_INT, Desc: "Sign members out this long after login, 0 for no limit"},
+					{Name: "ipAllowlist", Type: apidocs.TYPE_STRING, Desc: "IP addresses or CIDRs allowed to log in and use the console, separated by commas or newlines; must include your current address. Empty allows all"},
+					{Name: "passwordMinLength", Type: apidocs.TYPE_INT, Desc: "Minimum password length (8-128), 0 for no rule"},
+					{Name: "passwordRequireUpper", Type: apidocs.TYPE_BOOLEAN, Desc: "Passwords need an uppercase letter"},
+					{Name: "passwordRequireLower", Type: apidocs.TYPE_BOOLEAN, Desc: "Passwords need a lowercase letter"},
+					{Name: "passwordRequireDigit", Type: apidocs.TYPE_BOOLEAN, Desc: "Passwords need a digit"},
+					{Name: "passwordRequireSymbol", Type: apidocs.TYPE_BOOLEAN, Desc: "Passwords need a symbol"},
+					{Name: "passwordMaxAgeDays", Type: apidocs.TYPE_INT, Desc: "Passwords older than this are reported in the compliance report, 0 for no limit"},
+					{Name: "maskPii", Type: apidocs.TYPE_BOOLEAN, Desc: "Mask tagged personal data in call transcripts returned to members; organization admins still see the original text"},
+					{Name: "maskPiiTypes", Type: apidocs.TYPE_STRING, Desc: "Comma separated PII types to mask: name, phone, email, address, id_number, bank_card. Empty masks all"},
+				},
+			},
+		},
+		{
+			Group:        "Organization Security Policy",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/security-policy/report",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Compliance report of the members violating the policy (creator or admins). Violations are two_factor_disabled, password_expired and login_ip_outside",
+		},
+		// ==================== Device Diagnostics ====================
+		{
+			Group:        "Device Diagnostics",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/:deviceId/diagnostics",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Ask the device to upload a diagnostics bundle (recent logs, config snapshot, network info). The device receives an upload_diagnostics command with a one-time upload URL valid for 24 hours",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "ticket", Type: apidocs.TYPE_STRING, Desc: "Support ticket the bundle is gathered for"},
+				},
+			},
+		},
+		{
+			Group:        "Device Diagnostics",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/:deviceId/diagnostics",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the device's diagnostics bundles and whether the device has uploaded them",
+		},
+		{
+			Group:        "Device Diagnostics",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/:deviceId/diagnostics/:bundleId/download",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Download a zip archive with the device upload under device/ and the server-side context under server/ (device record, recent error logs and call records)",
+		},
+		{
+			Group:        "Device Diagnostics",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/diagnostics/upload/:token",
+			Method:       http.MethodPost,
+			AuthRequired: false,
+			Desc:         "Device upload of the bundle, as multipart field file or as the raw body, at most 50 MB. The token is the one sent with the command and works once",
+		},
+		{
+			Group:        "Device Diagnostics",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/audio-test",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Device upload of a 1-30 second microphone test recording (multipart, at most 8 MB). The server measures noise floor, clipping and the response of the speech bands, stores the 0-100 score on the device and flags it for replacement below AUDIO_QUALITY_THRESHOLD (default 60), raising an audio_quality alert when newly flagged. The recording is not kept",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "macAddress", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "file", Type: apidocs.TYPE_STRING, Required: true, Desc: "16-bit PCM WAV, or raw 16-bit mono PCM with sampleRate"},
+					{Name: "sampleRate", Type: apidocs.TYPE_INT, Desc: "Sample rate of raw PCM uploads, 8000-48000"},
+				},
+			},
+		},
+		{
+			Group:        "Device Diagnostics",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/:deviceId/audio-tests",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the device's last 50 microphone tests with score, issues (too_short, no_signal, clipping, high_noise, low_snr, weak_band) and the full report",
+		},
+		{
+			Group:        "Device Diagnostics",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/device/audio-quality/flagged",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the user's and organization devices whose last microphone test scored below the threshold",
+		},
+		// ==================== LLM Cache ====================
+		// ==================== Job Queue ====================
+		{
+			Group:        "Job Queue",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/jobs/dead",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List dead email and SMS jobs (admin), newest first, limit up to 200 (default 50). Jobs are dead lettered after QUEUE_MAX_ATTEMPTS attempts or a permanent failure; SMS verification codes are removed from the payload once delivered or dead lettered",
+		},
+		{
+			Group:        "Job Queue",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/jobs/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get a job (admin) with its status (pending, running, retrying, succeeded, dead), attempts and last error",
+		},
+		{
+			Group:        "Job Queue",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/system/jobs/:id/requeue",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Requeue a dead job with a fresh set of attempts (admin). Requeued SMS jobs fail again since their code was removed, the user has to request a new one",
+		},
+		{
+			Group:        "LLM Cache",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/llm-cache/stats",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Semantic LLM cache hit rates (admin), in total and per assistant and knowledge base version. Enabled by the LLM_CACHE_ENABLED setting; LLM_CACHE_THRESHOLD (similarity percent, default 92) and LLM_CACHE_TTL (seconds, default 86400) tune it",
+		},
+		{
+			Group:        "LLM Cache",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/llm-cache",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Drop cached answers (admin), of one assistant when assistantId is given. Uploading to a knowledge base starts a new version, so answers based on older documents stop matching without clearing",
+		},
+		// ==================== Compliance Archive ====================
+		{
+			Group:        "Compliance Archive",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/compliance-archive",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the organization's compliance archive (organization admin) and the number of pending, delivered and failed deliveries. Secrets are never returned, hasSecretKey and hasToken tell whether they are set",
+		},
+		{
+			Group:        "Compliance Archive",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/compliance-archive",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Create or update the compliance archive (organization admin). While enabled, recordings and transcripts of finished calls of the organization's assistants and SIP lines are delivered about five minutes after the call ends, retried with backoff on failures",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "kind", Type: apidocs.TYPE_STRING, Required: true, Desc: "s3: WORM bucket with Object Lock; api: vendor archive API receiving multipart posts with an Idempotency-Key"},
+					{Name: "endpoint", Type: apidocs.TYPE_STRING, Desc: "S3-compatible endpoint (empty for AWS) or the archive API URL"},
+					{Name: "region", Type: apidocs.TYPE_STRING},
+					{Name: "bucket", Type: apidocs.TYPE_STRING},
+					{Name: "prefix", Type: apidocs.TYPE_STRING, Desc: "Object key prefix"},
+					{Name: "accessKey", Type: apidocs.TYPE_STRING},
+					{Name: "secretKey", Type: apidocs.TYPE_STRING, Desc: "Kept when empty"},
+					{Name: "token", Type: apidocs.TYPE_STRING, Desc: "Bearer token of the archive API, kept when empty"},
+					{Name: "secret", Type: apidocs.TYPE_STRING, Desc: "Signs API deliveries like outbound webhooks, kept when empty"},
+					{Name: "retentionDays", Type: apidocs.TYPE_INT, Desc: "Object Lock compliance retention of S3 objects, 0 disables locking"},
+					{Name: "since", Type: apidocs.TYPE_STRING, Desc: "Archive calls that ended after this time (RFC 3339), defaults to when the archive is first enabled"},
+				},
+			},
+		},
+		{
+			Group:        "Compliance Archive",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/compliance-archive",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Remove the compliance archive (organization admin); deliveries and receipts are kept",
+		},
+		{
+			Group:        "Compliance Archive",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/compliance-exports",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List deliveries with the receipt the archive returned (object version IDs or vendor receipt) and the delivered checksum, filtered by status (pending, delivered, failed); paginated with page and size",
+		},
+		{
+			Group:        "Compliance Archive",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/compliance-exports/:exportId/retry",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Queue a failed delivery again (organization admin). Deliveries fail after the archive rejects them or after 8 attempts",
+		},
+		// ==================== Caller Lookup ====================
+		{
+			Group:        "Caller Lookup",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/caller-lookup",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the assistant's CRM caller lookup, null when not configured. The secret is never returned, hasSecret tells whether it is set",
+		},
+		{
+			Group:        "Caller Lookup",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/caller-lookup",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Create or update the caller lookup. Before answering an inbound SIP call the caller number is posted to the URL as a call.inbound.lookup webhook; the returned name, accountTier, openTickets, notes and attributes are added to the system prompt and can personalize the greeting. A 404 or {\"found\": false} means an unknown caller; errors and timeouts leave the call unchanged",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "url", Type: apidocs.TYPE_STRING, Required: true, Desc: "CRM lookup webhook"},
+					{Name: "secret", Type: apidocs.TYPE_STRING, Desc: "Signs lookups like outbound webhooks, kept when empty"},
+					{Name: "timeoutMs", Type: apidocs.TYPE_INT, Desc: "How long the caller waits for the CRM, default 1500, at most 5000"},
+					{Name: "greetingTemplate", Type: apidocs.TYPE_STRING, Desc: "Go template over the CRM answer, e.g. \"Hi {{.Name}}\"; a greeting returned by the CRM wins, empty keeps the opening message"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN},
+				},
+			},
+		},
+		{
+			Group:        "Caller Lookup",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/caller-lookup",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Remove the caller lookup; lookup logs are kept",
+		},
+		{
+			Group:        "Caller Lookup",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/caller-lookup/test",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Look up a number and return the prompt section and greeting a call from it would get, without logging",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "caller", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "callee", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:        "Caller Lookup",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/caller-lookup/logs",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List lookups of inbound calls with their outcome (matched, unknown, failed), the CRM fields and data used and the greeting played; filtered by status and caller, paginated with page and size",
+		},
+		// ==================== Feature Flags ====================
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/features",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Every feature flag evaluated for the current user as {key: enabled}, for client-side gating. Undefined flags are off",
+		},
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/feature-flags",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List feature flags (admin only)",
+		},
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/feature-flags",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create a feature flag (admin only). Changes apply at once on this instance and within 30 seconds on the others, and are audited",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "key", Type: apidocs.TYPE_STRING, Required: true, Desc: "Lowercase letters, digits, dot, dash and underscore, e.g. voice.pipeline-v2"},
+					{Name: "description", Type: apidocs.TYPE_STRING},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Desc: "Kill switch, off for everyone while false"},
+					{Name: "default", Type: apidocs.TYPE_BOOLEAN, Desc: "Value when no rule matches"},
+					{Name: "rules", Type: apidocs.TYPE_OBJECT, Desc: "Targeting rules checked in order, the first match decides: {users: [ids], groups: [organization ids], percentage: 0-100, value: bool}. All criteria set on a rule must hold; percentage buckets are stable per user and flag"},
+				},
+			},
+		},
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/feature-flags/:key",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get a feature flag (admin only)",
+		},
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/feature-flags/:key",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Replace the definition of a feature flag (admin only), audited with the previous definition",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "description", Type: apidocs.TYPE_STRING},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Desc: "Kill switch, off for everyone while false"},
+					{Name: "default", Type: apidocs.TYPE_BOOLEAN, Desc: "Value when no rule matches"},
+					{Name: "rules", Type: apidocs.TYPE_OBJECT, Desc: "Targeting rules checked in order, the first match decides: {users: [ids], groups: [organization ids], percentage: 0-100, value: bool}. All criteria set on a rule must hold; percentage buckets are stable per user and flag"},
+				},
+			},
+		},
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/feature-flags/:key",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete a feature flag (admin only); it then evaluates as off. The audit history is kept",
+		},
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/feature-flags/:key/audits",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Changes of a flag with the acting user and the definition before and after, newest first; paginated with page and size",
+		},
+		{
+			Group:        "Feature Flags",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/feature-flags/:key/evaluate",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Show how a flag evaluates for a user: result, reason (disabled, rule, default, unknown), the matching rule index, the user's organizations and percentage bucket",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "userId", Type: apidocs.TYPE_INT, Required: true},
+				},
+			},
+		},
+		// ==================== Assistant Sharing ====================
+		{
+			Group:        "Assistant Sharing",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/shares",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the organizations the owner's personal assistant is shared with and their permission level",
+		},
+		{
+			Group:        "Assistant Sharing",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/shares",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Share a personal assistant with an organization the owner belongs to, or change the permission of an existing share. Members see it in their assistant list and can call it (use) or also change its configuration (edit); their usage is recorded under their own account and attributed to the organization. Organization assistants cannot be shared",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "groupId", Type: apidocs.TYPE_INT, Required: true, Desc: "Organization ID"},
+					{Name: "permission", Type: apidocs.TYPE_STRING, Desc: "use (default) or edit"},
+				},
+			},
+		},
+		{
+			Group:        "Assistant Sharing",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/shares/:groupId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Stop sharing the assistant with the organization; recorded usage stays attributed to it",
+		},
+		{
+			Group:        "Assistant Sharing",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/shares/usage",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Usage of the assistant attributed to organizations, summed per member and usage type (records, tokens, call and audio seconds, API calls) over the last days (default 30); filter by groupId",
+		},
+		// ==================== Session QA ====================
+		{
+			Group:        "Session QA",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/replay",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Replay a conversation for QA: turns in order with their offset from the session start and, for SIP calls, into the call recording; LLM latency and tokens; tool calls with arguments, outputs, errors and duration; knowledge base chunks retrieved for each turn; and the reviews of the session. Available to the owner and to organizations the assistant is shared with for editing",
+		},
+		{
+			Group:        "Session QA",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/reviews",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the reviews of a session",
+		},
+		{
+			Group:        "Session QA",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/reviews",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Review a session, or one of its turns, with a score, tags and a comment",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "score", Type: apidocs.TYPE_INT, Required: true, Desc: "1 to 5"},
+					{Name: "tags", Type: apidocs.TYPE_OBJECT, Desc: "Up to 10 tags, stored lowercase"},
+					{Name: "comment", Type: apidocs.TYPE_STRING},
+					{Name: "turnIndex", Type: apidocs.TYPE_INT, Desc: "1-based turn of the replay; omit to review the whole session"},
+				},
+			},
+		},
+		{
+			Group:        "Session QA",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/reviews/:reviewId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete a review; reviewers delete their own, the assistant owner can delete any",
+		},
+		{
+			Group:        "Session QA",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/quality-report",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Quality report of the assistant over the last days (default 30): review count, average score and distribution, tag counts, low-scoring sessions, session and turn counts, average LLM latency and tool call errors",
+		},
+		// ==================== Regions ====================
+		{
+			Group:        "Regions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/regions",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the enabled deployment regions and the region of this instance (local). Devices are pinned with region on PUT /device/update/:id and SIP users with region on PUT /schemes/:id; an empty string unpins. Admins can pass all=true to include disabled regions",
+		},
+		{
+			Group:        "Regions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/regions",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create a region (admin). Pinned devices receive its endpoints in the OTA and device config responses; INVITEs and REGISTERs for SIP users pinned to a region other than the instance's REGION are redirected (302) to its SIP endpoint",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "code", Type: apidocs.TYPE_STRING, Required: true, Desc: "Lowercase letters, digits and dashes; matches the REGION setting of the instances serving it"},
+					{Name: "name", Type: apidocs.TYPE_STRING},
+					{Name: "sipHost", Type: apidocs.TYPE_STRING, Required: true, Desc: "SIP signalling host"},
+					{Name: "sipPort", Type: apidocs.TYPE_INT, Desc: "Default 5060"},
+					{Name: "rtpHost", Type: apidocs.TYPE_STRING, Desc: "Media address advertised in SDP, defaults to sipHost"},
+					{Name: "websocketUrl", Type: apidocs.TYPE_STRING, Desc: "Device voice WebSocket, defaults to server.websocket"},
+					{Name: "mqttGateway", Type: apidocs.TYPE_STRING, Desc: "Device MQTT gateway, defaults to server.mqtt_gateway"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Desc: "Default true"},
+				},
+			},
+		},
+		{
+			Group:        "Regions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/regions/:code",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Replace the endpoints of a region (admin); pinned devices and SIP users of a disabled region are served by the instance they reach",
+		},
+		{
+			Group:        "Regions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/regions/:code",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete a region (admin); fails while devices or SIP users are pinned to it",
+		},
+		{
+			Group:        "Regions",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/calls/regions",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "SIP call counts and durations per handling region and peer region (calls handed off to another region) over the last days (default 7). Call history accepts a region filter",
+		},
+		// ==================== Web Widgets ====================
+		{
+			Group:        "Web Widgets",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/widgets",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create an embeddable web-call widget for an assistant you own. The returned publicKey goes into the page; calls are billed to the credential. GET lists the widgets, PUT/DELETE /assistant/:id/widgets/:widgetId update or remove one, GET /assistant/:id/widgets/:widgetId/usage counts its sessions",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "credentialId", Type: apidocs.TYPE_INT, Required: true, Desc: "Credential of the assistant owner used for the calls"},
+					{Name: "allowedOrigins", Type: "array", Required: true, Desc: "Page origins allowed to start calls, e.g. https://example.com or https://*.example.com (at most 20)"},
+					{Name: "name", Type: apidocs.TYPE_STRING},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Desc: "Default true"},
+					{Name: "maxSessionSeconds", Type: apidocs.TYPE_INT, Desc: "Longest call, default 300, at most 3600"},
+					{Name: "sessionsPerIpHour", Type: apidocs.TYPE_INT, Desc: "Sessions a visitor IP may start per hour, default 10"},
+					{Name: "sessionsPerDay", Type: apidocs.TYPE_INT, Desc: "Sessions the widget may start per day, default 1000"},
+					{Name: "maxConcurrentSessions", Type: apidocs.TYPE_INT, Desc: "Calls in progress at once, default 20"},
+				},
+			},
+		},
+		{
+			Group:  "Web Widgets",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/widget/:key/session",
+			Method: http.MethodPost,
+			Desc:   "Public. Issues an anonymous session token scoped to the widget's assistant, valid for 2 minutes and usable once. The browser's Origin must be in the allowlist (403 otherwise); 429 when the IP, daily or concurrency limit is reached. Returns token, callUrl and maxSessionSeconds",
+		},
+		{
+			Group:  "Web Widgets",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/widget/:key/call",
+			Method: http.MethodGet,
+			Desc:   "Public WebSocket. Redeems the token query parameter from the same origin and connects to the assistant. transport=websocket (default) carries audio like /voice/websocket; transport=webrtc carries signaling like /chat/call. The call ends after maxSessionSeconds",
+		},
+		// ==================== Prompt Releases ====================
+		{
+			Group:        "Prompt Releases",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/prompt-releases",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Start a canary release of a new system prompt. Device calls are split by device (trafficPercent to the candidate), and every 5 minutes the interruption rate, average handle time and sentiment (from analyzed recordings) of both arms are compared. A regression beyond the thresholds rolls the release back at once; after the window the candidate is promoted, or the release ends inconclusive when an arm has fewer than minCalls calls. The owner is notified of each outcome. GET lists the releases",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "candidatePrompt", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "note", Type: apidocs.TYPE_STRING},
+					{Name: "trafficPercent", Type: apidocs.TYPE_INT, Desc: "Share of calls for the candidate, 1-99, default 10"},
+					{Name: "windowMinutes", Type: apidocs.TYPE_INT, Desc: "Comparison window, default 1440, at most 14 days"},
+					{Name: "minCalls", Type: apidocs.TYPE_INT, Desc: "Calls needed per arm before comparing, default 20"},
+					{Name: "maxInterruptionRateIncrease", Type: apidocs.TYPE_FLOAT, Desc: "Allowed rise of interruptions per user turn, default 0.10"},
+					{Name: "maxHandleTimeIncrease", Type: apidocs.TYPE_FLOAT, Desc: "Allowed relative rise of the average call duration, default 0.20"},
+					{Name: "maxSentimentDrop", Type: apidocs.TYPE_FLOAT, Desc: "Allowed drop of the average sentiment (-1 to 1), default 0.15"},
+				},
+			},
+		},
+		{
+			Group:        "Prompt Releases",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/prompt-releases/:releaseId",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "A release with the metrics of both arms and the regressions found; analyzed live while the release runs",
+		},
+		{
+			Group:        "Prompt Releases",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/prompt-releases/:releaseId/promote",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Promote a running release now, the candidate becomes the assistant prompt. POST .../rollback ends it and keeps the baseline prompt",
+		},
+		// ==================== Login Locations ====================
+		{
+			Group:        "Login Locations",
+			Path:         config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix + "/login-locations",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Login counts of the current user bucketed by day and country or city for the security dashboard map. Query: days (default 30, at most 365), granularity (city or country). Counts come from a rollup refreshed every 15 minutes",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "buckets", Type: "array", Desc: "day, country, city, logins, failed, suspicious"},
+					{Name: "from", Type: apidocs.TYPE_STRING},
+					{Name: "to", Type: apidocs.TYPE_STRING},
+					{Name: "granularity", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:        "Login Locations",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/login-locations",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Login counts of all organization members bucketed by day and country or city (organization admins). Same query and response as /login-locations, plus the member count",
+		},
+		// ==================== Session Annotations ====================
+		{
+			Group:        "Session Annotations",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/annotations",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Annotate a turn of the transcript and/or a moment of the recording. Mentioned users must be able to review the assistant and receive a notification with a deep link to the moment. GET lists the annotations in playback order; they are also part of the session replay",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "body", Type: apidocs.TYPE_STRING, Required: true, Desc: "At most 4000 characters"},
+					{Name: "turnIndex", Type: apidocs.TYPE_INT, CanNull: true, Desc: "1-based turn of the replay"},
+					{Name: "offsetMs", Type: apidocs.TYPE_INT, CanNull: true, Desc: "Position in the call recording; one of turnIndex and offsetMs is required"},
+					{Name: "mentions", Type: "array", CanNull: true, Desc: "User IDs to notify, at most 20"},
+				},
+			},
+		},
+		{
+			Group:        "Session Annotations",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/speakers",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Correct the speakers of the session's call recording. Analysis splits the user turns of multi-party calls by voice into speaker_1, speaker_2, ... in order of first appearance and stores them as speakerId on the turns of the conversation details; this names speakers and moves turns to another, possibly new, speaker. Moved turns are kept when the recording is analyzed again",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "labels", Type: apidocs.TYPE_MAP, CanNull: true, Desc: "Speaker ID to name, at most 64 characters; empty clears the name"},
+					{Name: "turns", Type: apidocs.TYPE_MAP, CanNull: true, Desc: "User turn ID to speaker ID"},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "recordingId", Type: apidocs.TYPE_INT},
+					{Name: "speakers", Type: "array", Desc: "id, label and number of turns of each speaker"},
+					{Name: "turns", Type: "array"},
+				},
+			},
+		},
+		{
+			Group:        "PII Tagging",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/pii/detect",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Tag the personal data (name, phone, email, address, id_number, bank_card) in the transcript of the session's call recording again. Transcripts are tagged automatically when a call ends and when it is analyzed; reviewed tags are kept. Entities carry turnId, type, start/end character offsets in the turn, text, confidence and status. Text is masked when the organization's security policy masks PII for the current user",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "recordingId", Type: apidocs.TYPE_INT},
+					{Name: "detectedAt", Type: apidocs.TYPE_DATE},
+					{Name: "masked", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "entities", Type: "array"},
+					{Name: "turns", Type: "array"},
+				},
+			},
+		},
+		{
+			Group:        "PII Tagging",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/pii",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Review PII tags: confirm or reject a detected entity, or add one detection missed. Reviews are applied together or not at all and feed the organization's detection metrics",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "reviews", Type: "array", Required: true, Desc: "turnId, start, end, status (confirmed, rejected or added) and type, required when adding"},
+				},
+			},
+		},
+		{
+			Group:        "PII Tagging",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/group/:id/pii/metrics",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "PII detection quality of the organization's call recordings over the last days (query days, default 30, at most 365; organization admins only). Precision and recall are measured against reviewer decisions, overall and by type, and are null until something was reviewed",
+		},
+		{
+			Group:        "Transcript Corrections",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/corrections",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Transcript corrections of the session's call recording and its turns with the accepted ones applied. Corrected turns have corrected=true and keep the recognized text in originalContent; PII is masked per the organization policy",
+		},
+		{
+			Group:        "Transcript Corrections",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/corrections",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Correct a mis-recognized user turn; the original transcript is preserved and the correction is pending until reviewed. Correcting the turn again replaces the previous correction",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "turnId", Type: apidocs.TYPE_INT, Required: true},
+					{Name: "text", Type: apidocs.TYPE_STRING, Required: true, Desc: "Corrected text, at most 2000 characters"},
+				},
+			},
+		},
+		{
+			Group:        "Transcript Corrections",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/sessions/:sessionId/corrections/:correctionId",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Accept or reject a pending correction. Accepted corrections are applied in the recording detail, exports (marked as corrected) and analysis, and the corrected words are added to the assistant's vocabulary",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "status", Type: apidocs.TYPE_STRING, Required: true, Desc: "accepted or rejected"},
+				},
+			},
+		},
+		{
+			Group:        "Transcript Corrections",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/vocabulary",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Words the assistant's speech recognition is boosted for (hot words, supported by the Tencent Cloud and Volcengine LLM recognizers), from accepted corrections or added manually, most confirmed first. POST with word and weight (1-10, default 10) adds one, DELETE /assistant/:id/vocabulary/:wordId removes one",
+		},
+		{
+			Group:        "SIP Callbacks",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip-callbacks",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Callbacks offered to callers queued for the current user's assistants, newest first (query status, page, pageSize). Callers queued longer than the assistant's callbackOfferAfter press # to be called back at their number, or enter another number followed by #, and * to keep waiting; their place in the queue is kept and they are called back when it comes up",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "callbacks", Type: "array", Desc: "id, callId, callbackCallId, assistantId, callerNumber, callbackNumber, status (declined, no_response, waiting, dialing, connected, completed, failed, expired), queueWait, offeredAt, acceptedAt, dialedAt, connectedAt, endedAt, error"},
+					{Name: "total", Type: apidocs.TYPE_INT},
+					{Name: "page", Type: apidocs.TYPE_INT},
+					{Name: "pageSize", Type: apidocs.TYPE_INT},
+				},
+			},
+		},
+		{
+			Group:        "SIP Callbacks",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip-callbacks/report",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Callback outcomes over the last days (query days, default 30, at most 365)",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "offered", Type: apidocs.TYPE_INT},
+					{Name: "accepted", Type: apidocs.TYPE_INT},
+					{Name: "connected", Type: apidocs.TYPE_INT, Desc: "Callbacks answered by the caller"},
+					{Name: "byStatus", Type: apidocs.TYPE_MAP},
+					{Name: "acceptRate", Type: apidocs.TYPE_FLOAT, Desc: "accepted / offered"},
+					{Name: "connectRate", Type: apidocs.TYPE_FLOAT, Desc: "connected / accepted callbacks no longer waiting or dialing"},
+					{Name: "avgHold", Type: apidocs.TYPE_FLOAT, Desc: "Average seconds between accepting and being called back"},
+				},
+			},
+		},
+		{
+			Group:        "SIP Interpretation",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/calls/:callId/interpretation",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Transcript of a call interpreted by an assistant (interpreterMode), in both languages; callId may be either party's call. The caller speaks the assistant's language, the party dialed at interpreterTarget speaks interpreterLanguage. Only the assistant owner can read it",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "live", Type: apidocs.TYPE_BOOLEAN, Desc: "The call is still in progress"},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Desc: "Interpretation is on"},
+					{Name: "languageA", Type: apidocs.TYPE_STRING, Desc: "The caller's language"},
+					{Name: "languageB", Type: apidocs.TYPE_STRING, Desc: "The dialed party's language"},
+					{Name: "segments", Type: "array", Desc: "seq, leg (a caller, b dialed party), sourceLanguage, targetLanguage, sourceText, translatedText, recognizedAt, latencyMs (from recognition until the translation started playing), error"},
+					{Name: "transcripts", Type: apidocs.TYPE_MAP, Desc: "The whole call per language: seq, leg, text, translated"},
+				},
+			},
+		},
+		{
+			Group:        "SIP Interpretation",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/sip/calls/:callId/interpretation",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Turn interpretation of a live call on or off; while off both parties hear each other directly",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN, Required: true},
+				},
+			},
+		},
+		{
+			Group:        "Session Annotations",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/annotations/:annotationId",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete an annotation (its author or the assistant owner); its share links stop working",
+		},
+		{
+			Group:        "Session Annotations",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/assistant/:id/annotations/:annotationId/share",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create a signed share link opening the replay at the annotated moment without logging in. Body: ttlHours (default 168, at most 720). POST .../share/revoke invalidates every link of the annotation",
+		},
+		{
+			Group:        "Session Annotations",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/annotations/mentions",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Latest annotations mentioning the current user with their deep links",
+		},
+		{
+			Group:  "Session Annotations",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/annotations/shared",
+			Method: http.MethodGet,
+			Desc:   "Public. Resolves the token of a share link to the annotation, its recording offset (recordingOffsetMs) and the session replay without QA reviews or other annotations; 403 when the link is invalid, expired or revoked",
+		},
+		// ==================== JS Templates ====================
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Create a new JS template",
+			Request:      apidocs.GetDocDefine(models.JSTemplate{}),
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get a JS template by ID",
+			Response: &apidocs.DocField{
+				Type:   "object",
+				Fields: apidocs.GetDocDefine(models.JSTemplate{}).Fields,
+			},
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/name/:name",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get JS templates by name",
+			Response: &apidocs.DocField{
+				Type:   "array",
+				Fields: apidocs.GetDocDefine(models.JSTemplate{}).Fields,
+			},
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List JS templates",
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/:id",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Update a JS template",
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete a JS template",
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/default",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List default JS templates",
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/custom",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List custom JS templates",
+		},
+		{
+			Group:        "JS Templates",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/js-templates/search",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Search JS templates",
+		},
+
+		// ==================== Chat ====================
+		{
+			Group:        "Chat",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/chat/chat-session-log",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get chat session logs",
+		},
+		{
+			Group:        "Chat",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/chat/chat-session-log/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get chat session log detail",
 			Response: &apidocs.DocField{
 				Type:   "object",
 				Fields: apidocs.GetDocDefine(models.ChatSessionLogDetail{}).Fields,
@@ -1329,6 +3990,28 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			AuthRequired: true,
 			Desc:         "Delete a credential",
 		},
+		{
+			Group:        "Credentials",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/credentials/usage",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "API usage of all API keys (query: window = 1h, 24h, 7d, 30d or 90d, default 24h): totals, per key, per key and endpoint, and an hourly series with requests, errors, error rate, average and p50/p90/p99 latency. Built from hourly rollups that lag up to one minute",
+			Response: &apidocs.DocField{
+				Type:   "object",
+				Fields: apidocs.GetDocDefine(models.APIKeyUsageReport{}).Fields,
+			},
+		},
+		{
+			Group:        "Credentials",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/credentials/:id/usage",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "API usage of one API key with its endpoint breakdown (query: window)",
+			Response: &apidocs.DocField{
+				Type:   "object",
+				Fields: apidocs.GetDocDefine(models.APIKeyUsageReport{}).Fields,
+			},
+		},
 
 		// ==================== Knowledge Base ====================
 		{
@@ -1376,6 +4059,182 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			AuthRequired: true,
 			Desc:         "Upload file to knowledge base",
 		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/webhook",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get the ingestion webhook of a knowledge base (query: knowledgeKey)",
+			Response: &apidocs.DocField{
+				Type:   "object",
+				Fields: apidocs.GetDocDefine(models.KnowledgeWebhook{}).Fields,
+			},
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/webhook",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc:         "Configure the webhook fired when document ingestion succeeds or fails (query: knowledgeKey)",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "url", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "method", Type: apidocs.TYPE_STRING},
+					{Name: "secret", Type: apidocs.TYPE_STRING},
+					{Name: "onSuccess", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "onFailure", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "enabled", Type: apidocs.TYPE_BOOLEAN},
+				},
+			},
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/webhook",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Remove the ingestion webhook of a knowledge base (query: knowledgeKey)",
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/ingestion-jobs",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Latest indexing jobs of a knowledge base (query: knowledgeKey, limit). With BAILIAN_CALLBACK_SECRET set, Aliyun uploads return a pending job that the Bailian callback completes; otherwise uploads poll the job and no jobs are recorded",
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/files",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Source documents kept for citations (query: knowledgeKey). Every upload stores a copy; re-uploading a file with the same name replaces it",
+			Response: &apidocs.DocField{
+				Type:   "array",
+				Fields: apidocs.GetDocDefine(models.KnowledgeSourceFile{}).Fields,
+			},
+		},
+		{
+			Group:  "Knowledge Base",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/knowledge/files/:id/content",
+			Method: http.MethodGet,
+			Desc:   "Serve a source document with Range support. Access with the signed token of a chat citation (query: token, valid for 1 hour) or as the logged-in owner or organization member. PDF, images and plain text are inline, other types are downloaded. Text chat responses carry citations: index, source, page, snippet, score, fileId, contentType and url (PDF links end with #page=N)",
+		},
+		{
+			Group:  "Knowledge Base",
+			Path:   config.GlobalConfig.Server.APIPrefix + "/knowledge/callbacks/bailian",
+			Method: http.MethodPost,
+			Desc:   "Receiver for Aliyun Bailian indexing job callbacks. Signed with BAILIAN_CALLBACK_SECRET: X-Webhook-Signature is the hex HMAC-SHA256 of X-Timestamp + body, timestamps older than 5 minutes are rejected. Finished jobs update the ingestion job and fire the knowledge base webhook",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "JobId", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "IndexId", Type: apidocs.TYPE_STRING},
+					{Name: "Status", Type: apidocs.TYPE_STRING, Required: true, Desc: "SUCCEEDED or FAILED, other statuses are ignored"},
+					{Name: "Message", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/snapshots",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Queue a snapshot of a knowledge base (query: knowledgeKey): all chunks, embeddings and metadata are exported as the next version to object storage, a notification is sent when it is ready. Supported for Qdrant and Elasticsearch; one snapshot per knowledge base runs at a time",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "note", Type: apidocs.TYPE_STRING},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type:   "object",
+				Fields: apidocs.GetDocDefine(models.KnowledgeSnapshot{}).Fields,
+			},
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/snapshots",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Snapshot versions of a knowledge base, newest first (query: knowledgeKey)",
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/snapshots/:version",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "A snapshot version with its export progress: processed and total chunks, progress in percent (query: knowledgeKey)",
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/snapshots/:version/restore",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Queue restoring a completed snapshot (query: knowledgeKey). Without newName chunks are written back into the original index, overwriting by ID; replace empties the index first. With newName a new knowledge base is created and restored into",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "newName", Type: apidocs.TYPE_STRING},
+					{Name: "replace", Type: apidocs.TYPE_BOOLEAN},
+				},
+			},
+			Response: &apidocs.DocField{
+				Type:   "object",
+				Fields: apidocs.GetDocDefine(models.KnowledgeSnapshotRestore{}).Fields,
+			},
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/restores",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Latest snapshot restores of a knowledge base (query: knowledgeKey)",
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/restores/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "A snapshot restore with its progress and the target knowledge base (query: knowledgeKey)",
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/faq-proposals",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "FAQs mined daily from the last week's call transcripts of assistants using the knowledge base, most asked first (query: knowledgeKey, status pending/approved/rejected, page, size)",
+			Response: &apidocs.DocField{
+				Type:   "object",
+				Fields: apidocs.GetDocDefine(models.KnowledgeFAQProposal{}).Fields,
+			},
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/faq-proposals/:id/approve",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Approve a pending FAQ proposal and add it to the knowledge base as a document (query: knowledgeKey). question and answer, when given, replace the drafted ones",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "question", Type: apidocs.TYPE_STRING},
+					{Name: "answer", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
+		{
+			Group:        "Knowledge Base",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/knowledge/faq-proposals/:id/reject",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Reject a pending FAQ proposal (query: knowledgeKey); the question is not proposed again",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "reason", Type: apidocs.TYPE_STRING},
+				},
+			},
+		},
 
 		// ==================== Xunfei TTS ====================
 		{
@@ -1472,7 +4331,19 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			Path:         config.GlobalConfig.Server.APIPrefix + "/voice/training/submit-audio",
 			Method:       http.MethodPost,
 			AuthRequired: true,
-			Desc:         "Submit audio file for voice training",
+			Desc:         "Submit audio file for voice training (multipart). The upload must carry explicit consent to the current voice cloning statement; it is recorded with the sample hash, and a voice is only created for tasks with consent",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "taskId", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "textSegId", Type: apidocs.TYPE_INT, Required: true},
+					{Name: "audio", Type: apidocs.TYPE_STRING, Required: true, Desc: "Audio file"},
+					{Name: "consent", Type: apidocs.TYPE_BOOLEAN, Required: true, Desc: "Must be true"},
+					{Name: "consentVersion", Type: apidocs.TYPE_STRING, Required: true, Desc: "Version of the statement shown, see /voice/clones/consent-statement"},
+					{Name: "speakerName", Type: apidocs.TYPE_STRING, Required: true, Desc: "Whose voice is recorded"},
+					{Name: "speakerIsSelf", Type: apidocs.TYPE_BOOLEAN, Desc: "The speaker is the account holder"},
+				},
+			},
 		},
 		{
 			Group:        "Voice Training",
@@ -1504,6 +4375,20 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			AuthRequired: true,
 			Desc:         "Get a voice clone by ID",
 		},
+		{
+			Group:        "Voice Training",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/voice/clones/consent-statement",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "The consent statement users must accept before uploading voice samples, with its version",
+		},
+		{
+			Group:        "Voice Training",
+			Path:         config.GlobalConfig.Server.APIPrefix + "/voice/clones/:id/consents",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Consent records of a voice clone: speaker, statement version, sample hash, IP and time, and when it was revoked",
+		},
 		{
 			Group:        "Voice Training",
 			Path:         config.GlobalConfig.Server.APIPrefix + "/voice/clones/update",
@@ -1524,7 +4409,7 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			Path:         config.GlobalConfig.Server.APIPrefix + "/voice/clones/delete",
 			Method:       http.MethodPost,
 			AuthRequired: true,
-			Desc:         "Delete a voice clone",
+			Desc:         "Delete a voice clone; its consent records are marked revoked. Cloned voices can only be used by their account, or by assistants of their organization",
 		},
 		{
 			Group:        "Voice Training",
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/escalation"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// escalationConnectorRequest request body for creating/updating an escalation connector
+type escalationConnectorRequest struct {
+	Name          string                 `json:"name"`
+	Provider      string                 `json:"provider"`
+	Config        map[string]interface{} `json:"config"` // Omitted secrets keep their stored value
+	TitleTemplate *string                `json:"titleTemplate"`
+	BodyTemplate  *string                `json:"bodyTemplate"`
+	Priority      *string                `json:"priority"`
+	Labels        []string               `json:"labels"`
+	AutoEscalate  *bool                  `json:"autoEscalate"`
+	Enabled       *bool                  `json:"enabled"`
+}
+
+// ListEscalationConnectors lists ticketing connectors of an assistant
+// GET /assistant/:id/escalation-connectors
+func (h *Handlers) ListEscalationConnectors(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+
+	connectors, err := models.GetEscalationConnectors(h.db, assistant.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	result := make([]gin.H, 0, len(connectors))
+	for i := range connectors {
+		result = append(result, escalationConnectorView(&connectors[i]))
+	}
+	response.Success(c, "success", result)
+}
+
+// CreateEscalationConnector adds a ticketing connector to an assistant
+// POST /assistant/:id/escalation-connectors
+func (h *Handlers) CreateEscalationConnector(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+
+	var req escalationConnectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	connector := &models.EscalationConnector{
+		AssistantID:  assistant.ID,
+		UserID:       assistant.UserID,
+		Provider:     req.Provider,
+		AutoEscalate: true,
+		Enabled:      true,
+	}
+	if err := applyEscalationConnectorRequest(c.Request.Context(), connector, &req); err != nil {
+		response.Fail(c, "invalid connector", err.Error())
+		return
+	}
+	if err := h.db.Create(connector).Error; err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	response.Success(c, "created", escalationConnectorView(connector))
+}
+
+// UpdateEscalationConnector updates a ticketing connector, the provider cannot change
+// PUT /assistant/:id/escalation-connectors/:connectorId
+func (h *Handlers) UpdateEscalationConnector(c *gin.Context) {
+	connector, ok := h.ownedEscalationConnector(c)
+	if !ok {
+		return
+	}
+
+	var req escalationConnectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if err := applyEscalationConnectorRequest(c.Request.Context(), connector, &req); err != nil {
+		response.Fail(c, "invalid connector", err.Error())
+		return
+	}
+	if err := h.db.Save(connector).Error; err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "updated", escalationConnectorView(connector))
+}
+
+// DeleteEscalationConnector removes a ticketing connector
+// DELETE /assistant/:id/escalation-connectors/:connectorId
+func (h *Handlers) DeleteEscalationConnector(c *gin.Context) {
+	connector, ok := h.ownedEscalationConnector(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(connector).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// EscalateCallRecording creates tickets for a call record manually. Without
+// connectorId every enabled connector of the assistant is used.
+// POST /device/call-recordings/:id/escalate
+func (h *Handlers) EscalateCallRecording(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	recordingID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid recording id", nil)
+		return
+	}
+	recording, err := models.GetCallRecordingByID(h.db, user.ID, uint(recordingID))
+	if err != nil {
+		response.Fail(c, "recording not found", nil)
+		return
+	}
+
+	var req struct {
+		ConnectorID uint   `json:"connectorId"`
+		Reason      string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	query := h.db.Where("assistant_id = ? AND enabled = ?", recording.AssistantID, true)
+	if req.ConnectorID != 0 {
+		query = query.Where("id = ?", req.ConnectorID)
+	}
+	var connectors []models.EscalationConnector
+	if err := query.Find(&connectors).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	// The conversation always lands in the team inbox; connectors additionally
+	// open tickets in external systems
+	h.openEscalationInbox(recording, req.Reason, user.ID)
+	if len(connectors) == 0 {
+		if req.ConnectorID != 0 {
+			response.Fail(c, "escalation connector not found or disabled", nil)
+			return
+		}
+		response.Success(c, "escalated to the team inbox", []models.EscalationTicket{})
+		return
+	}
+
+	tickets := h.escalateCallRecording(c.Request.Context(), recording, connectors, req.Reason, nil)
+	response.Success(c, "escalation finished", tickets)
+}
+
+// GetCallRecordingEscalations lists tickets created for a call record
+// GET /device/call-recordings/:id/escalations
+func (h *Handlers) GetCallRecordingEscalations(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	recordingID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid recording id", nil)
+		return
+	}
+	recording, err := models.GetCallRecordingByID(h.db, user.ID, uint(recordingID))
+	if err != nil {
+		response.Fail(c, "recording not found", nil)
+		return
+	}
+
+	tickets, err := models.GetEscalationTicketsByRecording(h.db, recording.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", tickets)
+}
+
+// autoEscalateCallRecording creates tickets through auto-escalating connectors when
+// the conversation analysis reports the issue as unresolved
+func (h *Handlers) autoEscalateCallRecording(recording *models.CallRecording, analysis map[string]interface{}) {
+	resolved, ok := analysis["resolved"].(bool)
+	if !ok || resolved {
+		return
+	}
+	reason, _ := analysis["escalationReason"].(string)
+	h.openEscalationInbox(recording, reason, 0)
+
+	connectors, err := models.GetAutoEscalationConnectors(h.db, int64(recording.AssistantID))
+	if err != nil {
+		logger.Error("Failed to load escalation connectors", zap.Error(err), zap.Uint("recordingID", recording.ID))
+		return
+	}
+
+	pending := connectors[:0]
+	for _, connector := range connectors {
+		// Re-analysis must not open duplicate tickets
+		if exists, err := models.HasEscalationTicket(h.db, connector.ID, recording.ID); err == nil && !exists {
+			pending = append(pending, connector)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	h.escalateCallRecording(context.Background(), recording, pending, reason, analysis)
+}
+
+// escalateCallRecording renders and creates a ticket per connector, recording each outcome
+func (h *Handlers) escalateCallRecording(ctx context.Context, recording *models.CallRecording, connectors []models.EscalationConnector, reason string, analysis map[string]interface{}) []models.EscalationTicket {
+	conv := h.escalationContext(recording, reason, analysis)
+
+	tickets := make([]models.EscalationTicket, 0, len(connectors))
+	for _, connector := range connectors {
+		ticket := models.EscalationTicket{
+			ConnectorID:     connector.ID,
+			AssistantID:     connector.AssistantID,
+			UserID:          recording.UserID,
+			CallRecordingID: recording.ID,
+			Provider:        connector.Provider,
+			Reason:          reason,
+			Status:          models.EscalationTicketCreated,
+		}
+
+		result, title, err := createEscalationTicket(ctx, &connector, conv)
+		ticket.Title = title
+		if err != nil {
+			ticket.Status = models.EscalationTicketFailed
+			ticket.Error = err.Error()
+			logger.Warn("Failed to create escalation ticket",
+				zap.Uint("connectorID", connector.ID),
+				zap.Uint("recordingID", recording.ID),
+				zap.Error(err))
+		} else {
+			ticket.ExternalID = result.ExternalID
+			ticket.ExternalURL = result.URL
+		}
+
+		if err := models.SaveEscalationTicket(h.db, &ticket); err != nil {
+			logger.Error("Failed to save escalation ticket", zap.Error(err), zap.Uint("recordingID", recording.ID))
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets
+}
+
+// createEscalationTicket renders the connector templates and creates the ticket
+func createEscalationTicket(ctx context.Context, connector *models.EscalationConnector, conv escalation.Context) (*escalation.Result, string, error) {
+	client, err := escalation.New(ctx, connector.Provider, connector.Config)
+	if err != nil {
+		return nil, "", err
+	}
+	ticket, err := escalation.Render(connector.TitleTemplate, connector.BodyTemplate, conv)
+	if err != nil {
+		return nil, "", err
+	}
+	ticket.Priority = connector.Priority
+	if connector.Labels != "" {
+		ticket.Labels = strings.Split(connector.Labels, ",")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	result, err := client.CreateTicket(ctx, ticket, conv)
+	return result, ticket.Title, err
+}
+
+// escalationContext builds template data from the call record and its analysis
+func (h *Handlers) escalationContext(recording *models.CallRecording, reason string, analysis map[string]interface{}) escalation.Context {
+	conv := escalation.Context{
+		AssistantID: int64(recording.AssistantID),
+		RecordingID: recording.ID,
+		SessionID:   recording.SessionID,
+		Summary:     recording.Summary,
+		Reason:      reason,
+		StartTime:   recording.StartTime,
+		Duration:    recording.Duration,
+	}
+
+	var assistant models.Assistant
+	if err := h.db.Select("id", "name").First(&assistant, recording.AssistantID).Error; err == nil {
+		conv.AssistantName = assistant.Name
+	}
+
+	if analysis == nil && recording.AIAnalysis != "" {
+		_ = json.Unmarshal([]byte(recording.AIAnalysis), &analysis)
+	}
+	if summary, ok := analysis["summary"].(string); ok && summary != "" {
+		conv.Summary = summary
+	}
+	if issues, ok := analysis["issues"].([]interface{}); ok {
+		for _, issue := range issues {
+			conv.Issues = append(conv.Issues, fmt.Sprint(issue))
+		}
+	}
+	if conv.Reason == "" {
+		conv.Reason, _ = analysis["escalationReason"].(string)
+	}
+
+	if details, err := recording.GetConversationDetails(); err == nil && details != nil {
+		var transcript strings.Builder
+		for _, turn := range details.Turns {
+			switch turn.Type {
+			case "user":
+				fmt.Fprintf(&transcript, "用户: %s\n", turn.Content)
+			case "ai":
+				fmt.Fprintf(&transcript, "AI: %s\n", turn.Content)
+			}
+		}
+		conv.Transcript = strings.TrimSpace(transcript.String())
+	}
+	return conv
+}
+
+// applyEscalationConnectorRequest validates the request and copies it onto the connector
+func applyEscalationConnectorRequest(ctx context.Context, connector *models.EscalationConnector, req *escalationConnectorRequest) error {
+	config := models.JSONMap{}
+	for k, v := range connector.Config {
+		config[k] = v
+	}
+	for k, v := range req.Config {
+		config[k] = v
+	}
+
+	if _, err := escalation.New(ctx, connector.Provider, config); err != nil {
+		return err
+	}
+	connector.Config = config
+
+	if req.Name != "" {
+		connector.Name = req.Name
+	}
+	if connector.Name == "" {
+		connector.Name = connector.Provider
+	}
+	if req.TitleTemplate != nil {
+		if err := escalation.ValidateTemplate(*req.TitleTemplate); err != nil {
+			return fmt.Errorf("titleTemplate: %w", err)
+		}
+		connector.TitleTemplate = *req.TitleTemplate
+	}
+	if req.BodyTemplate != nil {
+		if err := escalation.ValidateTemplate(*req.BodyTemplate); err != nil {
+			return fmt.Errorf("bodyTemplate: %w", err)
+		}
+		connector.BodyTemplate = *req.BodyTemplate
+	}
+	if req.Priority != nil {
+		connector.Priority = *req.Priority
+	}
+	if req.Labels != nil {
+		connector.Labels = strings.Join(req.Labels, ",")
+	}
+	if req.AutoEscalate != nil {
+		connector.AutoEscalate = *req.AutoEscalate
+	}
+	if req.Enabled != nil {
+		connector.Enabled = *req.Enabled
+	}
+	return nil
+}
+
+// escalationConnectorView hides credentials from connector responses
+func escalationConnectorView(connector *models.EscalationConnector) gin.H {
+	config := gin.H{}
+	for k, v := range connector.Config {
+		config[k] = v
+	}
+	for _, key := range escalation.SensitiveConfigKeys {
+		if v, ok := config[key]; ok {
+			delete(config, key)
+			config["has"+strings.ToUpper(key[:1])+key[1:]] = v != ""
+		}
+	}
+	return gin.H{
+		"connector": connector,
+		"config":    config,
+	}
+}
+
+// ownedAssistant loads the assistant from :id and checks it belongs to the current user
+func (h *Handlers) ownedAssistant(c *gin.Context) (*models.Assistant, bool) {
+	return h.loadAssistantParam(c, func(assistant *models.Assistant, userID uint) bool {
+		return assistant.UserID == userID
+	})
+}
+
+// ownedEscalationConnector loads the connector from :connectorId within the assistant
+func (h *Handlers) ownedEscalationConnector(c *gin.Context) (*models.EscalationConnector, bool) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return nil, false
+	}
+
+	var connector models.EscalationConnector
+	if err := h.db.Where("id = ? AND assistant_id = ?", c.Param("connectorId"), assistant.ID).First(&connector).Error; err != nil {
+		response.Fail(c, "not found", "Escalation connector does not exist")
+		return nil, false
+	}
+	return &connector, true
+}
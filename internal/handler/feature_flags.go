@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/featureflag"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// featureFlagRequest body of flag create and update; the key comes from the path on update
+type featureFlagRequest struct {
+	Key         string             `json:"key"`
+	Description string             `json:"description"`
+	Enabled     bool               `json:"enabled"`
+	Default     bool               `json:"default"`
+	Rules       []featureflag.Rule `json:"rules"`
+}
+
+// ListFeatureFlags lists the feature flags
+// GET /feature-flags
+func (h *Handlers) ListFeatureFlags(c *gin.Context) {
+	var flags []models.FeatureFlag
+	if err := h.db.Order("flag_key").Find(&flags).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", flags)
+}
+
+// GetFeatureFlag gets a feature flag
+// GET /feature-flags/:key
+func (h *Handlers) GetFeatureFlag(c *gin.Context) {
+	flag, ok := h.loadFeatureFlag(c)
+	if !ok {
+		return
+	}
+	response.Success(c, "success", flag)
+}
+
+// CreateFeatureFlag creates a feature flag
+// POST /feature-flags
+func (h *Handlers) CreateFeatureFlag(c *gin.Context) {
+	var req featureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	flag := &models.FeatureFlag{Key: req.Key, Description: req.Description, Enabled: req.Enabled, Default: req.Default, Rules: req.Rules}
+	if err := flag.Validate(); err != nil {
+		response.Fail(c, "invalid flag", err.Error())
+		return
+	}
+	if _, err := models.GetFeatureFlag(h.db, flag.Key); err == nil {
+		response.Fail(c, "flag already exists", flag.Key)
+		return
+	}
+	if err := models.SaveFeatureFlag(h.db, flag, nil, models.CurrentUser(c).ID); err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	response.Success(c, "created", flag)
+}
+
+// UpdateFeatureFlag replaces the definition of a feature flag
+// PUT /feature-flags/:key
+func (h *Handlers) UpdateFeatureFlag(c *gin.Context) {
+	existing, ok := h.loadFeatureFlag(c)
+	if !ok {
+		return
+	}
+	var req featureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	before := *existing
+	flag := existing
+	flag.Description, flag.Enabled, flag.Default, flag.Rules = req.Description, req.Enabled, req.Default, req.Rules
+	if err := flag.Validate(); err != nil {
+		response.Fail(c, "invalid flag", err.Error())
+		return
+	}
+	if err := models.SaveFeatureFlag(h.db, flag, &before, models.CurrentUser(c).ID); err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "updated", flag)
+}
+
+// DeleteFeatureFlag deletes a feature flag; handlers checking it treat it as off
+// DELETE /feature-flags/:key
+func (h *Handlers) DeleteFeatureFlag(c *gin.Context) {
+	flag, ok := h.loadFeatureFlag(c)
+	if !ok {
+		return
+	}
+	if err := models.DeleteFeatureFlag(h.db, flag, models.CurrentUser(c).ID); err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// ListFeatureFlagAudits lists the changes of a flag, newest first; deleted flags keep their history
+// GET /feature-flags/:key/audits
+func (h *Handlers) ListFeatureFlagAudits(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	query := h.db.Model(&models.FeatureFlagAudit{}).Where("flag_key = ?", c.Param("key"))
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	var audits []models.FeatureFlagAudit
+	if err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&audits).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"list": audits, "total": total, "page": page, "size": size})
+}
+
+// EvaluateFeatureFlag shows how a flag evaluates for a user and which rule decided
+// POST /feature-flags/:key/evaluate
+func (h *Handlers) EvaluateFeatureFlag(c *gin.Context) {
+	var req struct {
+		UserID uint `json:"userId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	user, err := models.GetUserByUID(h.db, req.UserID)
+	if err != nil {
+		response.Fail(c, "user not found", err.Error())
+		return
+	}
+	subject := models.FeatureSubject(h.db, user)
+	response.Success(c, "success", gin.H{
+		"evaluation": models.LoadFeatureFlags(h.db).Evaluate(c.Param("key"), subject),
+		"subject":    subject,
+		"bucket":     featureflag.Bucket(c.Param("key"), user.ID),
+	})
+}
+
+// GetMyFeatures returns every flag evaluated for the current user, for client-side gating
+// GET /features
+func (h *Handlers) GetMyFeatures(c *gin.Context) {
+	user := models.CurrentUser(c)
+	response.Success(c, "success", models.LoadFeatureFlags(h.db).EvaluateAll(models.FeatureSubject(h.db, user)))
+}
+
+func (h *Handlers) loadFeatureFlag(c *gin.Context) (*models.FeatureFlag, bool) {
+	flag, err := models.GetFeatureFlag(h.db, c.Param("key"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "flag not found", nil)
+		} else {
+			response.Fail(c, "query failed", err.Error())
+		}
+		return nil, false
+	}
+	return flag, true
+}
@@ -369,6 +369,7 @@ func (h *Handlers) DeleteGroup(c *gin.Context) {
 	}
 
 	// 删除组织成员
+	affectedUsers := h.responseCacheUsers(group.CreatorID, &group.ID)
 	h.db.Where("group_id = ?", group.ID).Delete(&models.GroupMember{})
 	// 删除组织邀请
 	h.db.Where("group_id = ?", group.ID).Delete(&models.GroupInvitation{})
@@ -378,6 +379,7 @@ func (h *Handlers) DeleteGroup(c *gin.Context) {
 		return
 	}
 
+	h.invalidateSharedListCaches(affectedUsers...)
 	response.Success(c, "删除成功", nil)
 }
 
@@ -525,22 +527,18 @@ func (h *Handlers) InviteUser(c *gin.Context) {
 	// 加载关联信息
 	h.db.Preload("Group").Preload("Inviter").Preload("Invitee").First(&invitation, invitation.ID)
 
-	// 发送站内通知
-	go func() {
-		notificationService := notification.NewInternalNotificationService(h.db)
-		title := "组织邀请"
-		content := fmt.Sprintf("%s 邀请您加入组织「%s」",
-			user.DisplayName,
-			group.Name)
-		if err := notificationService.Send(invitee.ID, title, content); err != nil {
-			logger.Warn("发送站内通知失败", zap.Error(err), zap.Uint("userId", invitee.ID))
-		}
-	}()
-
-	// 发送邮件通知（如果用户启用了邮件通知）
-	go func() {
-		if invitee.EmailNotifications && config.GlobalConfig.Services.Mail.APIUser != "" {
-			mailer := notification.NewMailNotification(config.GlobalConfig.Services.Mail)
+	// 按被邀请人的通知偏好发送站内通知和邮件，通过组织自定义域名访问时使用该域名的发件人
+	mailConfig := models.RequestMailConfig(c, config.GlobalConfig.Services.Mail)
+	go models.DispatchNotification(h.db, &invitee, models.Notice{
+		Event:    models.NotificationEventGroup,
+		Title:    "组织邀请",
+		Content:  fmt.Sprintf("%s 邀请您加入组织「%s」", user.DisplayName, group.Name),
+		Channels: []models.NotificationChannel{models.NotificationChannelInternal, models.NotificationChannelEmail},
+		SendEmail: func(invitee *models.User) error {
+			if config.GlobalConfig.Services.Mail.APIUser == "" {
+				return nil
+			}
+			mailer := notification.NewMailNotification(mailConfig)
 
 			// 构建接受邀请的URL
 			siteURL := utils.GetValue(h.db, constants.KEY_SITE_URL)
@@ -564,14 +562,14 @@ func (h *Handlers) InviteUser(c *gin.Context) {
 				groupDesc,
 				acceptURL,
 			)
-
 			if err != nil {
 				logger.Error("发送组织邀请邮件失败", zap.Error(err), zap.String("email", invitee.Email))
-			} else {
-				logger.Info("组织邀请邮件发送成功", zap.String("email", invitee.Email))
+				return err
 			}
-		}
-	}()
+			logger.Info("组织邀请邮件发送成功", zap.String("email", invitee.Email))
+			return nil
+		},
+	})
 
 	response.Success(c, "邀请已发送", invitation)
 }
@@ -690,6 +688,7 @@ func (h *Handlers) AcceptInvitation(c *gin.Context) {
 	// 更新邀请状态
 	invitation.Status = "accepted"
 	h.db.Save(&invitation)
+	h.invalidateSharedListCaches(user.ID)
 
 	response.Success(c, "成功加入组织", nil)
 }
@@ -772,6 +771,7 @@ func (h *Handlers) LeaveGroup(c *gin.Context) {
 		response.Fail(c, "离开组织失败", err.Error())
 		return
 	}
+	h.invalidateSharedListCaches(user.ID)
 
 	response.Success(c, "已离开组织", nil)
 }
@@ -826,6 +826,7 @@ func (h *Handlers) RemoveMember(c *gin.Context) {
 		response.Fail(c, "移除成员失败", err.Error())
 		return
 	}
+	h.invalidateSharedListCaches(uint(memberID))
 
 	response.Success(c, "已移除成员", nil)
 }
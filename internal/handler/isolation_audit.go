@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/isolation"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/middleware"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// isolationAuditTimeout bounds one audit run, the probes go through the full router
+const isolationAuditTimeout = 2 * time.Minute
+
+// isolationAuditProbes list and detail endpoints of the org-shared resources.
+// Placeholders are filled with the records seeded for the tenant owning them.
+var isolationAuditProbes = []isolation.Probe{
+	{Name: "assistant.list", Path: "/assistant"},
+	{Name: "assistant.detail", Path: "/assistant/{assistant}", Detail: true},
+	{Name: "knowledge.list", Path: "/knowledge/get"},
+	{Name: "knowledge.files", Path: "/knowledge/files?knowledgeKey={knowledgeKey}", Detail: true},
+	{Name: "knowledge.snapshots", Path: "/knowledge/snapshots?knowledgeKey={knowledgeKey}", Detail: true},
+	{Name: "knowledge.faqProposals", Path: "/knowledge/faq-proposals?knowledgeKey={knowledgeKey}", Detail: true},
+	{Name: "device.list", Path: "/device/bind/{assistant}"},
+	{Name: "device.detail", Path: "/device/{device}", Detail: true},
+	{Name: "device.locations", Path: "/device/{device}/locations", Detail: true},
+	{Name: "device.errorLogs", Path: "/device/{device}/error-logs", Detail: true},
+}
+
+// isolationAuditTenant records seeded for one synthetic organization
+type isolationAuditTenant struct {
+	group     models.Group
+	user      models.User
+	member    models.GroupMember
+	assistant models.Assistant
+	knowledge models.Knowledge
+	device    models.Device
+}
+
+// RunIsolationAudit seeds two synthetic organizations with an assistant, a knowledge
+// base and a device each, exercises the list/detail endpoints as each org's user and
+// reports any record visible across the org boundary. The seeded data is removed
+// before returning.
+func (h *Handlers) RunIsolationAudit(c *gin.Context) {
+	if h.engine == nil {
+		response.Fail(c, "isolation audit unavailable", "routes are not registered")
+		return
+	}
+
+	run := randomAuditID()
+	var tenants []*isolationAuditTenant
+	defer func() {
+		for _, t := range tenants {
+			h.cleanupIsolationAuditTenant(t)
+		}
+	}()
+	for _, name := range []string{"a", "b"} {
+		t, err := h.seedIsolationAuditTenant(run, name)
+		if t != nil {
+			tenants = append(tenants, t)
+		}
+		if err != nil {
+			response.Fail(c, "failed to seed isolation audit tenants", err.Error())
+			return
+		}
+	}
+
+	auditTenants := make([]isolation.Tenant, 0, len(tenants))
+	for _, t := range tenants {
+		// The group is named after the marker the tenant's records carry
+		auditTenants = append(auditTenants, isolation.Tenant{
+			Name:   t.group.Name,
+			Token:  models.BuildAuthToken(&t.user, isolationAuditTimeout+time.Minute, false),
+			Marker: t.group.Name,
+			Resources: map[string]string{
+				"assistant":    strconv.FormatInt(t.assistant.ID, 10),
+				"knowledgeKey": t.knowledge.KnowledgeKey,
+				"device":       t.device.ID,
+			},
+		})
+	}
+
+	auditor := &isolation.Auditor{
+		Handler:    h.engine,
+		Prefix:     config.GlobalConfig.Server.APIPrefix + "/" + middleware.APIVersionV1,
+		AuthHeader: config.GlobalConfig.Auth.Header,
+		AuthPrefix: constants.AUTHORIZATION_PREFIX,
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), isolationAuditTimeout)
+	defer cancel()
+	report := auditor.Run(ctx, auditTenants, isolationAuditProbes)
+
+	if len(report.Findings) > 0 {
+		logger.Warn("Tenant isolation audit found cross-tenant records",
+			zap.String("run", run), zap.Int("findings", len(report.Findings)))
+	}
+	response.Success(c, "success", gin.H{
+		"run":    run,
+		"passed": report.Passed(),
+		"report": report,
+	})
+}
+
+// seedIsolationAuditTenant creates one synthetic organization. The partially seeded
+// tenant is returned with the error so that the caller can still clean it up.
+func (h *Handlers) seedIsolationAuditTenant(run, name string) (*isolationAuditTenant, error) {
+	marker := isolationAuditMarker(run, name)
+	t := &isolationAuditTenant{}
+
+	t.user = models.User{
+		Email:       fmt.Sprintf("isolation-audit-%s-%s@audit.invalid", run, name),
+		Password:    randomAuditID() + randomAuditID(), // Never a valid hash, the user cannot log in
+		DisplayName: marker,
+		Enabled:     true,
+		Activated:   true,
+		Source:      "isolation_audit",
+		Role:        models.RoleUser,
+	}
+	if err := h.db.Create(&t.user).Error; err != nil {
+		return nil, err
+	}
+
+	t.group = models.Group{Name: marker, Type: "isolation_audit", CreatorID: t.user.ID}
+	if err := h.db.Create(&t.group).Error; err != nil {
+		return t, err
+	}
+	t.member = models.GroupMember{UserID: t.user.ID, GroupID: t.group.ID, Role: models.GroupRoleAdmin}
+	if err := h.db.Create(&t.member).Error; err != nil {
+		return t, err
+	}
+
+	groupID := t.group.ID
+	t.assistant = models.Assistant{UserID: t.user.ID, GroupID: &groupID, Name: marker, Description: marker}
+	if err := h.db.Create(&t.assistant).Error; err != nil {
+		return t, err
+	}
+
+	now := time.Now()
+	t.knowledge = models.Knowledge{
+		UserID:        int(t.user.ID),
+		GroupID:       &groupID,
+		KnowledgeKey:  marker,
+		KnowledgeName: marker,
+		IndexId:       marker,
+		CreatedAt:     now,
+		UpdateAt:      now,
+		UpdatedAt:     now,
+	}
+	if err := h.db.Create(&t.knowledge).Error; err != nil {
+		return t, err
+	}
+
+	assistantID := uint(t.assistant.ID)
+	mac := auditMacAddress()
+	t.device = models.Device{
+		ID:          mac,
+		UserID:      t.user.ID,
+		GroupID:     &groupID,
+		MacAddress:  mac,
+		DeviceName:  marker,
+		AssistantID: &assistantID,
+	}
+	if err := h.db.Create(&t.device).Error; err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// cleanupIsolationAuditTenant removes everything seeded for the tenant, skipping the
+// records that were never created
+func (h *Handlers) cleanupIsolationAuditTenant(t *isolationAuditTenant) {
+	steps := []func(tx *gorm.DB) error{
+		func(tx *gorm.DB) error {
+			if t.device.ID == "" {
+				return nil
+			}
+			return tx.Unscoped().Where("id = ?", t.device.ID).Delete(&models.Device{}).Error
+		},
+		func(tx *gorm.DB) error {
+			if t.knowledge.ID == 0 {
+				return nil
+			}
+			return tx.Unscoped().Where("id = ?", t.knowledge.ID).Delete(&models.Knowledge{}).Error
+		},
+		func(tx *gorm.DB) error {
+			if t.assistant.ID == 0 {
+				return nil
+			}
+			return tx.Unscoped().Where("id = ?", t.assistant.ID).Delete(&models.Assistant{}).Error
+		},
+		func(tx *gorm.DB) error {
+			if t.member.ID == 0 {
+				return nil
+			}
+			return tx.Unscoped().Where("id = ?", t.member.ID).Delete(&models.GroupMember{}).Error
+		},
+		func(tx *gorm.DB) error {
+			if t.group.ID == 0 {
+				return nil
+			}
+			return tx.Unscoped().Where("id = ?", t.group.ID).Delete(&models.Group{}).Error
+		},
+		func(tx *gorm.DB) error {
+			if t.user.ID == 0 {
+				return nil
+			}
+			return tx.Unscoped().Where("id = ?", t.user.ID).Delete(&models.User{}).Error
+		},
+	}
+	for _, step := range steps {
+		if err := step(h.db); err != nil {
+			logger.Warn("Failed to clean up isolation audit data", zap.String("user", t.user.Email), zap.Error(err))
+		}
+	}
+}
+
+func isolationAuditMarker(run, name string) string {
+	return "isolation-audit-" + run + "-" + name
+}
+
+func randomAuditID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// auditMacAddress a random locally administered MAC so it never collides with real devices
+func auditMacAddress() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	b[0] = (b[0] | 0x02) &^ 0x01
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02x", v)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/queue"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+const maxDeadLetters = 200
+
+// jobQueue returns the notification job queue, writing the error response when it isn't running
+func jobQueue(c *gin.Context) *queue.Queue {
+	q := notification.JobQueue()
+	if q == nil {
+		response.Fail(c, "job queue is not running", nil)
+	}
+	return q
+}
+
+// ListDeadJobs lists the jobs that ran out of attempts or failed permanently, newest first (admin)
+// GET /system/jobs/dead?limit=
+func (h *Handlers) ListDeadJobs(c *gin.Context) {
+	q := jobQueue(c)
+	if q == nil {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > maxDeadLetters {
+		limit = 50
+	}
+	jobs, err := q.DeadLetters(c.Request.Context(), limit)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"jobs": jobs, "limit": limit})
+}
+
+// GetJob returns a job with its delivery status, attempts and last error (admin)
+// GET /system/jobs/:id
+func (h *Handlers) GetJob(c *gin.Context) {
+	q := jobQueue(c)
+	if q == nil {
+		return
+	}
+	job, err := q.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, queue.ErrJobNotFound) {
+		response.Fail(c, "not found", "Job does not exist or its record has expired")
+		return
+	}
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", job)
+}
+
+// RequeueJob gives a dead job a fresh set of attempts (admin)
+// POST /system/jobs/:id/requeue
+func (h *Handlers) RequeueJob(c *gin.Context) {
+	q := jobQueue(c)
+	if q == nil {
+		return
+	}
+	job, err := q.Requeue(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, queue.ErrJobNotFound) {
+		response.Fail(c, "not found", "Job does not exist or its record has expired")
+		return
+	}
+	if err != nil {
+		response.Fail(c, "requeue failed", err.Error())
+		return
+	}
+	response.Success(c, "Job requeued", job)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -175,6 +176,12 @@ func (h *Handlers) CreateKnowledgeBase(c *gin.Context) {
 	user := models.CurrentUser(c)
 	userId := int(user.ID)
 
+	// The subscription plan limits which vector stores may be used
+	if !models.PlanAllowsProvider(h.db, user, provider) {
+		response.Fail(c, "Knowledge base provider not available on the current plan, please upgrade", gin.H{"upgradeRequired": true, "provider": provider})
+		return
+	}
+
 	// If organization ID is specified, verify user has permission to create shared knowledge base in that organization
 	if groupID != nil {
 		var group models.Group
@@ -336,26 +343,12 @@ func (h *Handlers) CreateKnowledgeBase(c *gin.Context) {
 	// 8. Call models layer to create knowledge base record (use original knowledgeName, not generated name)
 	log.Printf("DEBUG: About to create knowledge base record - indexId: %s, knowledgeName: %s, provider: %s", indexId, knowledgeName, provider)
 
-	// Check if knowledge base with same key already exists (idempotency check)
-	var existingKB models.Knowledge
-	if err := h.db.Where("knowledge_key = ? AND user_id = ?", knowledgeKey, userId).First(&existingKB).Error; err == nil {
-		// Knowledge base already exists, return it
-		log.Printf("INFO: Knowledge base already exists - ID: %d, Key: %s", existingKB.ID, existingKB.KnowledgeKey)
-		responseData := map[string]interface{}{
-			"id":             existingKB.ID,
-			"user_id":        existingKB.UserID,
-			"group_id":       existingKB.GroupID,
-			"knowledge_key":  existingKB.KnowledgeKey,
-			"knowledge_name": existingKB.KnowledgeName,
-			"provider":       existingKB.Provider,
-			"created_at":     existingKB.CreatedAt,
-			"updated_at":     existingKB.UpdateAt,
-		}
-		response.Success(c, "created successfully", responseData)
-		return
-	}
-
+	// Creating is idempotent: an existing knowledge base with the same key is returned
 	knowledgeRecord, err := models.CreateKnowledgeWithIndexId(h.db, int(userId), knowledgeKey, knowledgeName, provider, config, groupID, indexId)
+	if errors.Is(err, models.ErrKnowledgeExists) {
+		log.Printf("INFO: Knowledge base already exists - ID: %d, Key: %s", knowledgeRecord.ID, knowledgeRecord.KnowledgeKey)
+		err = nil
+	}
 	if err != nil {
 		log.Printf("ERROR: Failed to create knowledge base record - error: %v", err)
 		response.Fail(c, err.Error(), nil)
@@ -363,6 +356,9 @@ func (h *Handlers) CreateKnowledgeBase(c *gin.Context) {
 	}
 
 	log.Printf("DEBUG: Knowledge base record created - ID: %d, Key: %s", knowledgeRecord.ID, knowledgeRecord.KnowledgeKey)
+	if file != nil {
+		h.storeKnowledgeSourceFile(&knowledgeRecord, file, header)
+	}
 
 	// 9. Return success response
 	// 构建返回数据，确保所有字段都有效
@@ -378,6 +374,7 @@ func (h *Handlers) CreateKnowledgeBase(c *gin.Context) {
 	}
 
 	log.Printf("SUCCESS: Knowledge base created successfully - ID: %d, Name: %s", knowledgeRecord.ID, knowledgeRecord.KnowledgeName)
+	h.invalidateKnowledgeCache(uint(knowledgeRecord.UserID), knowledgeRecord.GroupID)
 	response.Success(c, "created successfully", responseData)
 }
 
@@ -561,7 +558,17 @@ func (h *Handlers) UploadFileToKnowledgeBase(c *gin.Context) {
 		uploadKey = k.IndexId
 	}
 
+	// Keep the original so answers can cite its pages and images
+	h.storeKnowledgeSourceFile(k, file, header)
+
+	// With Bailian callbacks configured the indexing job is not polled, its callback completes the upload
+	if ingester, ok := kb.(knowledge.AsyncIngester); ok && bailianCallbacksEnabled(k) {
+		h.submitKnowledgeDocument(c, k, kb, ingester, uploadKey, file, header, metadata)
+		return
+	}
+
 	err = kb.UploadDocument(context.Background(), uploadKey, file, header, metadata)
+	h.finishKnowledgeIngestion(k, kb, uploadKey, documentFromHeader(header), err)
 	if err != nil {
 		log.Printf("ERROR: Failed to upload file - error: %v", err)
 		response.Fail(c, knowledge.ErrFileUploadFailed, err)
@@ -653,7 +660,9 @@ func (h *Handlers) DeleteKnowledgeBase(c *gin.Context) {
 		response.Fail(c, knowledge.ErrDatabaseDeleteFailed, err)
 		return
 	}
+	h.removeKnowledgeSourceFiles(k)
 
+	h.invalidateKnowledgeCache(uint(k.UserID), k.GroupID)
 	response.Success(c, "deleted successfully", nil)
 }
 
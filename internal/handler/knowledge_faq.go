@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// memoryFile serves an in-memory document as an uploaded file
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
+
+// ListKnowledgeFAQProposals lists the FAQs mined from call transcripts for a
+// knowledge base, most asked first (status: pending, approved, rejected; page, size)
+// GET /knowledge/faq-proposals?knowledgeKey=
+func (h *Handlers) ListKnowledgeFAQProposals(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.KnowledgeFAQPending, models.KnowledgeFAQApproved, models.KnowledgeFAQRejected:
+	default:
+		response.Fail(c, "invalid status", nil)
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	proposals, total, err := models.ListKnowledgeFAQProposals(h.db, k.ID, status, page, size)
+	if err != nil {
+		response.Fail(c, "failed to list FAQ proposals", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"proposals": proposals,
+		"total":     total,
+		"page":      page,
+		"size":      size,
+	})
+}
+
+// ApproveKnowledgeFAQProposal adds a proposed FAQ to the knowledge base as a
+// document. question and answer, when given, replace the drafted ones.
+// POST /knowledge/faq-proposals/:id/approve?knowledgeKey=
+func (h *Handlers) ApproveKnowledgeFAQProposal(c *gin.Context) {
+	k, proposal, ok := h.knowledgeFAQProposalFromPath(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Question string `json:"question"`
+		Answer   string `json:"answer"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		response.Fail(c, "invalid request parameters", err.Error())
+		return
+	}
+	question, answer := proposal.Question, proposal.Answer
+	if q := strings.TrimSpace(req.Question); q != "" {
+		question = q
+	}
+	if a := strings.TrimSpace(req.Answer); a != "" {
+		answer = a
+	}
+
+	cfg, err := models.GetKnowledgeConfigOrDefault(k.Provider, k.Config, getKnowledgeBaseConfig)
+	if err != nil {
+		response.Fail(c, knowledge.ErrConfigParseFailed, err)
+		return
+	}
+	kb, err := knowledge.GetKnowledgeBaseByProvider(k.Provider, cfg)
+	if err != nil {
+		response.Fail(c, knowledge.ErrKnowledgeBaseInitFailed, err)
+		return
+	}
+
+	// Claim the review first so concurrent approvals upload the document once
+	user := models.CurrentUser(c)
+	filename := fmt.Sprintf("faq-%d.txt", proposal.ID)
+	if err := models.ApproveKnowledgeFAQProposal(h.db, proposal, user.ID, question, answer, filename, time.Now()); err != nil {
+		if errors.Is(err, models.ErrKnowledgeFAQReviewed) {
+			response.Fail(c, "FAQ proposal has already been reviewed", nil)
+			return
+		}
+		response.Fail(c, "failed to approve FAQ proposal", err.Error())
+		return
+	}
+
+	content := []byte(proposal.Document())
+	header := &multipart.FileHeader{
+		Filename: filename,
+		Size:     int64(len(content)),
+		Header:   textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}},
+	}
+	metadata := map[string]interface{}{
+		knowledge.MetadataKeyUserID: k.UserID,
+		knowledge.MetadataKeyName:   k.KnowledgeName,
+		knowledge.MetadataKeySource: knowledge.MetadataSourceFAQReview,
+	}
+	uploadKey := k.KnowledgeKey
+	if k.Provider == knowledge.ProviderAliyun && k.IndexId != "" {
+		uploadKey = k.IndexId
+	}
+
+	err = kb.UploadDocument(context.Background(), uploadKey, memoryFile{bytes.NewReader(content)}, header, metadata)
+	h.finishKnowledgeIngestion(k, kb, uploadKey, documentFromHeader(header), err)
+	if err != nil {
+		log.Printf("ERROR: Failed to upload FAQ document - knowledgeId: %d, proposalId: %d, error: %v", k.ID, proposal.ID, err)
+		if reopenErr := models.ReopenKnowledgeFAQProposal(h.db, proposal); reopenErr != nil {
+			log.Printf("ERROR: Failed to reopen FAQ proposal - proposalId: %d, error: %v", proposal.ID, reopenErr)
+		}
+		response.Fail(c, knowledge.ErrFileUploadFailed, err)
+		return
+	}
+
+	response.Success(c, "approved successfully", proposal)
+}
+
+// RejectKnowledgeFAQProposal rejects a proposed FAQ; it is kept so the question
+// is not proposed again
+// POST /knowledge/faq-proposals/:id/reject?knowledgeKey=
+func (h *Handlers) RejectKnowledgeFAQProposal(c *gin.Context) {
+	_, proposal, ok := h.knowledgeFAQProposalFromPath(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		response.Fail(c, "invalid request parameters", err.Error())
+		return
+	}
+
+	user := models.CurrentUser(c)
+	if err := models.RejectKnowledgeFAQProposal(h.db, proposal, user.ID, strings.TrimSpace(req.Reason), time.Now()); err != nil {
+		if errors.Is(err, models.ErrKnowledgeFAQReviewed) {
+			response.Fail(c, "FAQ proposal has already been reviewed", nil)
+			return
+		}
+		response.Fail(c, "failed to reject FAQ proposal", err.Error())
+		return
+	}
+	response.Success(c, "rejected successfully", proposal)
+}
+
+func (h *Handlers) knowledgeFAQProposalFromPath(c *gin.Context) (*models.Knowledge, *models.KnowledgeFAQProposal, bool) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid proposal id", nil)
+		return nil, nil, false
+	}
+	proposal, err := models.GetKnowledgeFAQProposal(h.db, uint(id))
+	if err != nil || proposal.KnowledgeID != k.ID {
+		response.Fail(c, "FAQ proposal not found", nil)
+		return nil, nil, false
+	}
+	return k, proposal, true
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// knowledgeSourceDir keeps a copy of every uploaded knowledge base document,
+// next to the call recordings stored under ./lingstorage
+const knowledgeSourceDir = "./lingstorage/knowledge"
+
+// inlineKnowledgeTypes content types displayed in the browser, anything else
+// (HTML, SVG, office documents) is served as an attachment
+var inlineKnowledgeTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+}
+
+// storeKnowledgeSourceFile keeps the uploaded document so citations can link to
+// its pages and images. Failures are logged only, the upload itself goes on.
+func (h *Handlers) storeKnowledgeSourceFile(k *models.Knowledge, file multipart.File, header *multipart.FileHeader) {
+	defer file.Seek(0, io.SeekStart)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Printf("WARN: Failed to rewind knowledge file - filename: %s, error: %v", header.Filename, err)
+		return
+	}
+
+	dir := filepath.Join(knowledgeSourceDir, strconv.Itoa(k.ID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("WARN: Failed to create knowledge file directory - dir: %s, error: %v", dir, err)
+		return
+	}
+	tmp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		log.Printf("WARN: Failed to store knowledge file - filename: %s, error: %v", header.Filename, err)
+		return
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), file)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("WARN: Failed to store knowledge file - filename: %s, error: %v", header.Filename, err)
+		return
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	path := filepath.Join(dir, sum+ext)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("WARN: Failed to store knowledge file - filename: %s, error: %v", header.Filename, err)
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(ext); byExt != "" {
+			contentType = byExt
+		}
+	}
+	oldPath, err := models.SaveKnowledgeSourceFile(h.db, &models.KnowledgeSourceFile{
+		KnowledgeID: k.ID,
+		UserID:      uint(k.UserID),
+		Filename:    header.Filename,
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      sum,
+		Path:        path,
+	})
+	if err != nil {
+		log.Printf("WARN: Failed to record knowledge file - filename: %s, error: %v", header.Filename, err)
+		return
+	}
+	if oldPath != "" {
+		os.Remove(oldPath)
+	}
+}
+
+// removeKnowledgeSourceFiles deletes the stored documents of a deleted knowledge base
+func (h *Handlers) removeKnowledgeSourceFiles(k *models.Knowledge) {
+	paths, err := models.DeleteKnowledgeSourceFiles(h.db, k.ID)
+	if err != nil {
+		log.Printf("WARN: Failed to delete knowledge files - knowledgeId: %d, error: %v", k.ID, err)
+		return
+	}
+	for _, path := range paths {
+		os.Remove(path)
+	}
+	os.Remove(filepath.Join(knowledgeSourceDir, strconv.Itoa(k.ID)))
+}
+
+// knowledgeCitations maps retrieved chunks to their source documents with
+// short-lived signed links, nil when the mapping fails
+func (h *Handlers) knowledgeCitations(knowledgeKey string, results []knowledge.SearchResult) []models.KnowledgeCitation {
+	citations, err := models.BuildKnowledgeCitations(h.db, knowledgeKey, results)
+	if err != nil {
+		log.Printf("WARN: Failed to build knowledge citations - key: %s, error: %v", knowledgeKey, err)
+		return nil
+	}
+	expiresAt := time.Now().Add(models.KnowledgeFileLinkTTL)
+	for i := range citations {
+		if citations[i].FileID == 0 {
+			continue
+		}
+		token, err := models.SignKnowledgeFileLink(config.GlobalConfig.Auth.SessionSecret, citations[i].FileID, expiresAt)
+		if err != nil {
+			continue
+		}
+		link := fmt.Sprintf("%s/knowledge/files/%d/content?token=%s", config.GlobalConfig.Server.APIPrefix, citations[i].FileID, url.QueryEscape(token))
+		if citations[i].Page > 0 && citations[i].ContentType == "application/pdf" {
+			link += fmt.Sprintf("#page=%d", citations[i].Page)
+		}
+		citations[i].URL = link
+	}
+	return citations
+}
+
+// canReadKnowledge reports whether the user owns the knowledge base or belongs to its organization
+func (h *Handlers) canReadKnowledge(user *models.User, knowledgeID int) bool {
+	if user == nil {
+		return false
+	}
+	var k models.Knowledge
+	if err := h.db.First(&k, knowledgeID).Error; err != nil {
+		return false
+	}
+	if k.UserID == int(user.ID) {
+		return true
+	}
+	if k.GroupID == nil {
+		return false
+	}
+	var group models.Group
+	if err := h.db.First(&group, *k.GroupID).Error; err != nil {
+		return false
+	}
+	return models.IsGroupMember(h.db, &group, user.ID)
+}
+
+// ServeKnowledgeFile serves a stored knowledge base document, supporting Range
+// requests for PDF viewers. Access needs the signed token of a citation or a
+// logged-in owner or organization member.
+// GET /knowledge/files/:id/content
+func (h *Handlers) ServeKnowledgeFile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		return
+	}
+	file, err := models.GetKnowledgeSourceFile(h.db, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	authorized := false
+	if token := c.Query("token"); token != "" {
+		authorized = models.VerifyKnowledgeFileLink(config.GlobalConfig.Auth.SessionSecret, token, file.ID, time.Now()) == nil
+	} else {
+		authorized = h.canReadKnowledge(models.CurrentUser(c), file.KnowledgeID)
+	}
+	if !authorized {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access to this file is not allowed"})
+		return
+	}
+	if _, err := os.Stat(file.Path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && inlineKnowledgeTypes[mediaType] {
+		disposition = "inline"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename*=UTF-8''%s", disposition, url.PathEscape(file.Filename)))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=3600")
+
+	// c.File answers Range requests with 206 partial content
+	c.File(file.Path)
+}
+
+// ListKnowledgeFiles lists the stored source documents of a knowledge base
+// GET /knowledge/files
+func (h *Handlers) ListKnowledgeFiles(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+	files, err := models.ListKnowledgeSourceFiles(h.db, k.ID)
+	if err != nil {
+		response.Fail(c, "failed to list knowledge files", err.Error())
+		return
+	}
+	response.Success(c, "success", files)
+}
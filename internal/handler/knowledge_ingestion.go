@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBailianCallbackBody caps the size of a Bailian callback request
+const maxBailianCallbackBody = 1 << 20
+
+// bailianCallbacksEnabled reports whether uploads to k can rely on Bailian job callbacks.
+// Knowledge bases with their own credentials live in other workspaces, whose callbacks
+// are not signed with our secret, so they keep polling the job status.
+func bailianCallbacksEnabled(k *models.Knowledge) bool {
+	return k.Provider == knowledge.ProviderAliyun &&
+		k.Config == "" &&
+		config.GlobalConfig.Services.KnowledgeBase.Bailian.CallbackSecret != ""
+}
+
+// submitKnowledgeDocument submits the document to the provider indexing job and
+// records it, the outcome is applied by HandleBailianCallback
+func (h *Handlers) submitKnowledgeDocument(c *gin.Context, k *models.Knowledge, kb knowledge.KnowledgeBase, ingester knowledge.AsyncIngester, uploadKey string, file multipart.File, header *multipart.FileHeader, metadata map[string]interface{}) {
+	doc := documentFromHeader(header)
+	jobID, err := ingester.SubmitDocument(context.Background(), uploadKey, file, header, metadata)
+	if err == nil && jobID == "" {
+		// Nothing to wait for: the provider accepted the document without a job
+		h.finishKnowledgeIngestion(k, kb, uploadKey, doc, nil)
+		response.Success(c, "uploaded successfully", nil)
+		return
+	}
+	if err != nil {
+		h.notifyKnowledgeIngestion(k, kb, uploadKey, doc, err)
+		log.Printf("ERROR: Failed to submit file - error: %v", err)
+		response.Fail(c, knowledge.ErrFileUploadFailed, err)
+		return
+	}
+
+	job := &models.KnowledgeIngestionJob{
+		KnowledgeID:   k.ID,
+		UserID:        uint(k.UserID),
+		Provider:      k.Provider,
+		ProviderJobID: jobID,
+		Filename:      doc.Filename,
+		Size:          doc.Size,
+		ContentType:   doc.ContentType,
+	}
+	if err := models.CreateKnowledgeIngestionJob(h.db, job); err != nil {
+		response.Fail(c, "failed to record ingestion job", err.Error())
+		return
+	}
+
+	log.Printf("File submitted for indexing - key: %s, filename: %s, jobId: %s", k.KnowledgeKey, doc.Filename, jobID)
+	response.Success(c, "uploaded successfully, indexing in progress", gin.H{"job": job})
+}
+
+// finishKnowledgeIngestion bumps the knowledge base version on success and fires its webhook
+func (h *Handlers) finishKnowledgeIngestion(k *models.Knowledge, kb knowledge.KnowledgeBase, searchKey string, doc ingestedDocument, ingestErr error) {
+	h.notifyKnowledgeIngestion(k, kb, searchKey, doc, ingestErr)
+	if ingestErr != nil {
+		return
+	}
+	if err := models.TouchKnowledge(h.db, k.KnowledgeKey); err != nil {
+		log.Printf("WARN: Failed to bump knowledge base version - key: %s, error: %v", k.KnowledgeKey, err)
+	}
+	h.invalidateKnowledgeCache(uint(k.UserID), k.GroupID)
+}
+
+// HandleBailianCallback receives Aliyun Bailian indexing job callbacks.
+// Requests are signed like outgoing webhooks (X-Timestamp and X-Webhook-Signature,
+// HMAC-SHA256 of timestamp + body with BAILIAN_CALLBACK_SECRET).
+// POST /knowledge/callbacks/bailian
+func (h *Handlers) HandleBailianCallback(c *gin.Context) {
+	secret := config.GlobalConfig.Services.KnowledgeBase.Bailian.CallbackSecret
+	if secret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "callbacks are not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBailianCallbackBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	if err := webhook.Verify(secret, c.GetHeader(webhook.HeaderTimestamp), c.GetHeader(webhook.HeaderSignature), body, time.Now(), webhook.DefaultSignatureTolerance); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event knowledge.IndexJobEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.JobID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid callback payload"})
+		return
+	}
+	if !event.Finished() {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	job, err := models.GetKnowledgeIngestionJobByProviderJob(h.db, knowledge.ProviderAliyun, event.JobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load job"})
+		return
+	}
+	if job == nil {
+		log.Printf("WARN: Bailian callback for unknown job - jobId: %s, indexId: %s", event.JobID, event.IndexID)
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	succeeded := event.Status == knowledge.IndexJobStatusSucceeded
+	message := ""
+	if !succeeded {
+		message = event.Message
+		if message == "" {
+			message = "indexing job failed"
+		}
+	}
+	changed, err := models.CompleteKnowledgeIngestionJob(h.db, job, succeeded, message)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update job"})
+		return
+	}
+	if !changed {
+		// Redelivered callback, the job is already finished
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+
+	var k models.Knowledge
+	if err := h.db.First(&k, job.KnowledgeID).Error; err != nil {
+		log.Printf("WARN: Knowledge base of ingestion job not found - jobId: %s, knowledgeId: %d", event.JobID, job.KnowledgeID)
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+	searchKey := k.KnowledgeKey
+	if k.IndexId != "" {
+		searchKey = k.IndexId
+	}
+	var kb knowledge.KnowledgeBase
+	if cfg, err := models.GetKnowledgeConfigOrDefault(k.Provider, k.Config, getKnowledgeBaseConfig); err == nil {
+		kb, _ = knowledge.GetKnowledgeBaseByProvider(k.Provider, cfg)
+	}
+
+	var ingestErr error
+	if !succeeded {
+		ingestErr = errors.New(message)
+	}
+	h.finishKnowledgeIngestion(&k, kb, searchKey, ingestedDocument{
+		Filename:    job.Filename,
+		Size:        job.Size,
+		ContentType: job.ContentType,
+	}, ingestErr)
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ListKnowledgeIngestionJobs lists the latest indexing jobs of a knowledge base (limit, default 20, at most 100)
+// GET /knowledge/ingestion-jobs
+func (h *Handlers) ListKnowledgeIngestionJobs(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	jobs, err := models.ListKnowledgeIngestionJobs(h.db, k.ID, limit)
+	if err != nil {
+		response.Fail(c, "failed to list ingestion jobs", err.Error())
+		return
+	}
+
+	response.Success(c, "success", gin.H{
+		"jobs":      jobs,
+		"callbacks": bailianCallbacksEnabled(k),
+	})
+}
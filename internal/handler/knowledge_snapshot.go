@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LingByte/lingstorage-sdk-go"
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// knowledgeSnapshotBatchSize chunks read from the provider or the snapshot file at a time
+	knowledgeSnapshotBatchSize = 256
+	// knowledgeSnapshotJobsPerRun snapshot and restore jobs started per worker run
+	knowledgeSnapshotJobsPerRun = 2
+)
+
+// knowledgeSnapshotClient downloads snapshot files back from object storage
+var knowledgeSnapshotClient = &http.Client{Timeout: 30 * time.Minute}
+
+// knowledgeSnapshotter returns the knowledge base instance for k and its snapshot support
+func knowledgeSnapshotter(k *models.Knowledge) (knowledge.KnowledgeBase, knowledge.Snapshotter, error) {
+	cfg, err := models.GetKnowledgeConfigOrDefault(k.Provider, k.Config, getKnowledgeBaseConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	kb, err := knowledge.GetKnowledgeBaseByProvider(k.Provider, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshotter, ok := kb.(knowledge.Snapshotter)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", knowledge.ErrSnapshotUnsupported, k.Provider)
+	}
+	return kb, snapshotter, nil
+}
+
+// CreateKnowledgeSnapshot queues a snapshot of all chunks, embeddings and metadata
+// of the knowledge base as its next version
+// POST /knowledge/snapshots?knowledgeKey=
+func (h *Handlers) CreateKnowledgeSnapshot(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if _, _, err := knowledgeSnapshotter(k); err != nil {
+		response.Fail(c, "snapshots are not available for this knowledge base", err.Error())
+		return
+	}
+
+	snapshot, err := models.CreateKnowledgeSnapshot(h.db, k, models.CurrentUser(c).ID, strings.TrimSpace(req.Note))
+	if err != nil {
+		response.Fail(c, "failed to create snapshot", err.Error())
+		return
+	}
+	response.Success(c, "snapshot queued", snapshot)
+}
+
+// ListKnowledgeSnapshots lists the snapshot versions of a knowledge base, newest first
+// GET /knowledge/snapshots?knowledgeKey=
+func (h *Handlers) ListKnowledgeSnapshots(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+	snapshots, err := models.ListKnowledgeSnapshots(h.db, k.ID, 100)
+	if err != nil {
+		response.Fail(c, "failed to list snapshots", err.Error())
+		return
+	}
+	response.Success(c, "success", snapshots)
+}
+
+// GetKnowledgeSnapshot returns a snapshot version with its export progress
+// GET /knowledge/snapshots/:version?knowledgeKey=
+func (h *Handlers) GetKnowledgeSnapshot(c *gin.Context) {
+	_, snapshot, ok := h.knowledgeSnapshotFromPath(c)
+	if !ok {
+		return
+	}
+	response.Success(c, "success", snapshot)
+}
+
+// RestoreKnowledgeSnapshot queues restoring a snapshot, into the original index or,
+// with newName, into a new knowledge base
+// POST /knowledge/snapshots/:version/restore?knowledgeKey=
+func (h *Handlers) RestoreKnowledgeSnapshot(c *gin.Context) {
+	k, snapshot, ok := h.knowledgeSnapshotFromPath(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		NewName string `json:"newName"`
+		Replace bool   `json:"replace"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	req.NewName = strings.TrimSpace(req.NewName)
+	user := models.CurrentUser(c)
+	if req.NewName != "" {
+		key := models.GenerateKnowledgeKey(int(user.ID), models.GenerateKnowledgeName(int(user.ID), req.NewName))
+		if _, err := models.GetKnowledge(h.db, key); err == nil {
+			response.Fail(c, "a knowledge base with this name already exists", nil)
+			return
+		}
+	}
+	if _, _, err := knowledgeSnapshotter(k); err != nil {
+		response.Fail(c, "snapshots are not available for this knowledge base", err.Error())
+		return
+	}
+
+	restore, err := models.CreateKnowledgeSnapshotRestore(h.db, snapshot, user.ID, req.NewName, req.Replace)
+	if err != nil {
+		response.Fail(c, "failed to restore snapshot", err.Error())
+		return
+	}
+	response.Success(c, "restore queued", restore)
+}
+
+// ListKnowledgeSnapshotRestores lists the latest restores of a knowledge base's snapshots
+// GET /knowledge/restores?knowledgeKey=
+func (h *Handlers) ListKnowledgeSnapshotRestores(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+	restores, err := models.ListKnowledgeSnapshotRestores(h.db, k.ID, 100)
+	if err != nil {
+		response.Fail(c, "failed to list restores", err.Error())
+		return
+	}
+	response.Success(c, "success", restores)
+}
+
+// GetKnowledgeSnapshotRestore returns a restore with its progress
+// GET /knowledge/restores/:id?knowledgeKey=
+func (h *Handlers) GetKnowledgeSnapshotRestore(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid restore id", nil)
+		return
+	}
+	restore, err := models.GetKnowledgeSnapshotRestore(h.db, k.ID, uint(id))
+	if err != nil {
+		response.Fail(c, "restore not found", nil)
+		return
+	}
+	response.Success(c, "success", restore)
+}
+
+func (h *Handlers) knowledgeSnapshotFromPath(c *gin.Context) (*models.Knowledge, *models.KnowledgeSnapshot, bool) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return nil, nil, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		response.Fail(c, "invalid snapshot version", nil)
+		return nil, nil, false
+	}
+	snapshot, err := models.GetKnowledgeSnapshot(h.db, k.ID, version)
+	if err != nil {
+		response.Fail(c, "snapshot not found", nil)
+		return nil, nil, false
+	}
+	return k, snapshot, true
+}
+
+// RunKnowledgeSnapshotJobs exports queued snapshots to object storage and runs
+// queued restores, called by the snapshot worker
+func (h *Handlers) RunKnowledgeSnapshotJobs(now time.Time) {
+	snapshots, err := models.ClaimKnowledgeSnapshots(h.db, now, knowledgeSnapshotJobsPerRun)
+	if err != nil {
+		logger.Error("Failed to claim knowledge snapshots", zap.Error(err))
+	}
+	for i := range snapshots {
+		s := &snapshots[i]
+		if err := h.exportKnowledgeSnapshot(s); err != nil {
+			final, ferr := models.FailKnowledgeSnapshot(h.db, s, err)
+			if ferr != nil {
+				logger.Error("Failed to record knowledge snapshot failure", zap.Uint("snapshotId", s.ID), zap.Error(ferr))
+			}
+			logger.Warn("Knowledge snapshot failed", zap.Uint("snapshotId", s.ID), zap.Int("attempts", s.Attempts), zap.Error(err))
+			if final {
+				h.notifyKnowledgeSnapshot(s.UserID, "Knowledge base snapshot failed",
+					fmt.Sprintf("Snapshot v%d of %s could not be created: %s", s.Version, s.KnowledgeKey, s.Error))
+			}
+			continue
+		}
+		h.notifyKnowledgeSnapshot(s.UserID, "Knowledge base snapshot ready",
+			fmt.Sprintf("Snapshot v%d of %s holds %d chunks", s.Version, s.KnowledgeKey, s.Total))
+	}
+
+	restores, err := models.ClaimKnowledgeSnapshotRestores(h.db, now, knowledgeSnapshotJobsPerRun)
+	if err != nil {
+		logger.Error("Failed to claim knowledge snapshot restores", zap.Error(err))
+	}
+	for i := range restores {
+		r := &restores[i]
+		if err := h.restoreKnowledgeSnapshot(r); err != nil {
+			final, ferr := models.FailKnowledgeSnapshotRestore(h.db, r, err)
+			if ferr != nil {
+				logger.Error("Failed to record knowledge snapshot restore failure", zap.Uint("restoreId", r.ID), zap.Error(ferr))
+			}
+			logger.Warn("Knowledge snapshot restore failed", zap.Uint("restoreId", r.ID), zap.Int("attempts", r.Attempts), zap.Error(err))
+			if final {
+				h.notifyKnowledgeSnapshot(r.UserID, "Knowledge base restore failed",
+					fmt.Sprintf("Restore %d could not be completed: %s", r.ID, r.Error))
+			}
+			continue
+		}
+		h.notifyKnowledgeSnapshot(r.UserID, "Knowledge base restore completed",
+			fmt.Sprintf("%d chunks were restored into %s", r.Processed, r.TargetKey))
+	}
+}
+
+// exportKnowledgeSnapshot pages all chunks of the index into a temporary snapshot
+// file and uploads it to object storage
+func (h *Handlers) exportKnowledgeSnapshot(s *models.KnowledgeSnapshot) error {
+	if config.GlobalStore == nil {
+		return errors.New("object storage is not configured")
+	}
+	var k models.Knowledge
+	if err := h.db.First(&k, s.KnowledgeID).Error; err != nil {
+		return fmt.Errorf("knowledge base %d: %w", s.KnowledgeID, err)
+	}
+	_, snapshotter, err := knowledgeSnapshotter(&k)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	total, err := snapshotter.CountChunks(ctx, s.KnowledgeKey)
+	if err != nil {
+		return fmt.Errorf("count chunks: %w", err)
+	}
+	if err := models.UpdateKnowledgeSnapshotProgress(h.db, s, 0, total); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "knowledge-snapshot-*.jsonl.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w, err := knowledge.NewSnapshotWriter(f, knowledge.SnapshotHeader{
+		Provider:     s.Provider,
+		KnowledgeKey: s.KnowledgeKey,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	err = snapshotter.ExportChunks(ctx, s.KnowledgeKey, knowledgeSnapshotBatchSize, func(chunks []knowledge.Chunk) error {
+		if err := w.Write(chunks...); err != nil {
+			return err
+		}
+		return models.UpdateKnowledgeSnapshotProgress(h.db, s, w.Count(), total)
+	})
+	if err != nil {
+		return fmt.Errorf("export chunks: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("knowledge-snapshots/%d/v%d-%d.jsonl.gz", s.KnowledgeID, s.Version, time.Now().Unix())
+	result, err := config.GlobalStore.UploadFromReader(&lingstorage.UploadFromReaderRequest{
+		Reader:   f,
+		Filename: key,
+		Size:     info.Size(),
+		Bucket:   config.GlobalConfig.Services.Storage.Bucket,
+		Key:      key,
+	})
+	if err != nil {
+		return fmt.Errorf("upload snapshot: %w", err)
+	}
+	return models.CompleteKnowledgeSnapshot(h.db, s, result.Key, result.URL, info.Size(), w.Count(), time.Now())
+}
+
+// restoreKnowledgeSnapshot streams the snapshot file back from object storage into
+// the target index, creating the target knowledge base first when restoring into a new one
+func (h *Handlers) restoreKnowledgeSnapshot(r *models.KnowledgeSnapshotRestore) error {
+	var snapshot models.KnowledgeSnapshot
+	if err := h.db.First(&snapshot, r.SnapshotID).Error; err != nil {
+		return fmt.Errorf("snapshot %d: %w", r.SnapshotID, err)
+	}
+	var source models.Knowledge
+	if err := h.db.First(&source, r.KnowledgeID).Error; err != nil {
+		return fmt.Errorf("knowledge base %d: %w", r.KnowledgeID, err)
+	}
+	kb, snapshotter, err := knowledgeSnapshotter(&source)
+	if err != nil {
+		return err
+	}
+	cfg, err := models.GetKnowledgeConfigOrDefault(source.Provider, source.Config, getKnowledgeBaseConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, snapshot.StorageURL, nil)
+	if err != nil {
+		return fmt.Errorf("snapshot url: %w", err)
+	}
+	resp, err := knowledgeSnapshotClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download snapshot failed with status %d", resp.StatusCode)
+	}
+	reader, err := knowledge.NewSnapshotReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if reader.Header.Provider != source.Provider {
+		return fmt.Errorf("snapshot of provider %s cannot be restored into %s", reader.Header.Provider, source.Provider)
+	}
+
+	first, err := reader.Next(knowledgeSnapshotBatchSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	fresh := r.NewName != ""
+	if fresh && r.TargetKnowledgeID == 0 {
+		target, err := h.createRestoredKnowledge(&source, r, cfg)
+		if err != nil {
+			return err
+		}
+		if err := models.SetKnowledgeSnapshotRestoreTarget(h.db, r, target.ID, target.KnowledgeKey); err != nil {
+			return err
+		}
+	}
+	if r.Replace {
+		if err := kb.DeleteIndex(ctx, r.TargetKey); err != nil {
+			return fmt.Errorf("empty index: %w", err)
+		}
+	}
+	if fresh || r.Replace {
+		createConfig := make(map[string]interface{}, len(cfg)+1)
+		for k, v := range cfg {
+			createConfig[k] = v
+		}
+		// The index is named after the knowledge key, as uploads and searches address it
+		delete(createConfig, knowledge.ConfigKeyElasticsearchIndexName)
+		if len(first) > 0 && len(first[0].Vector) > 0 {
+			createConfig[knowledge.ConfigKeyQdrantDimension] = len(first[0].Vector)
+		}
+		if _, err := kb.CreateIndex(ctx, r.TargetKey, createConfig); err != nil {
+			return fmt.Errorf("create index: %w", err)
+		}
+	}
+
+	processed := 0
+	for chunks := first; len(chunks) > 0; {
+		if err := snapshotter.ImportChunks(ctx, r.TargetKey, chunks); err != nil {
+			return fmt.Errorf("import chunks: %w", err)
+		}
+		processed += len(chunks)
+		if err := models.UpdateKnowledgeSnapshotRestoreProgress(h.db, r, processed); err != nil {
+			return err
+		}
+		chunks, err = reader.Next(knowledgeSnapshotBatchSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := models.CompleteKnowledgeSnapshotRestore(h.db, r, time.Now()); err != nil {
+		return err
+	}
+	var target models.Knowledge
+	if h.db.First(&target, r.TargetKnowledgeID).Error == nil {
+		h.invalidateKnowledgeCache(uint(target.UserID), target.GroupID)
+	}
+	return nil
+}
+
+// createRestoredKnowledge creates the knowledge base a snapshot is restored into,
+// owned by the restoring user and shared with the source's organization
+func (h *Handlers) createRestoredKnowledge(source *models.Knowledge, r *models.KnowledgeSnapshotRestore, cfg map[string]interface{}) (*models.Knowledge, error) {
+	userID := int(r.UserID)
+	generatedName := models.GenerateKnowledgeName(userID, r.NewName)
+	key := models.GenerateKnowledgeKey(userID, generatedName)
+	target, err := models.CreateKnowledgeWithIndexId(h.db, userID, key, r.NewName, source.Provider, cfg, source.GroupID, key)
+	if errors.Is(err, models.ErrKnowledgeExists) {
+		return nil, fmt.Errorf("knowledge base %q already exists", r.NewName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+func (h *Handlers) notifyKnowledgeSnapshot(userID uint, title, content string) {
+	if err := notification.NewInternalNotificationService(h.db).Send(userID, title, content); err != nil {
+		logger.Warn("Failed to send knowledge snapshot notification", zap.Uint("userId", userID), zap.Error(err))
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"mime/multipart"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetKnowledgeWebhook gets the ingestion webhook of a knowledge base
+func (h *Handlers) GetKnowledgeWebhook(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+
+	hook, err := models.GetKnowledgeWebhook(h.db, k.ID)
+	if err != nil {
+		response.Fail(c, "failed to get webhook", err.Error())
+		return
+	}
+	if hook == nil {
+		response.Success(c, "success", nil)
+		return
+	}
+
+	response.Success(c, "success", gin.H{
+		"webhook":   hook,
+		"hasSecret": hook.HasSecret(),
+	})
+}
+
+// SaveKnowledgeWebhook creates or updates the ingestion webhook of a knowledge base
+func (h *Handlers) SaveKnowledgeWebhook(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		URL       string  `json:"url" binding:"required"`
+		Method    string  `json:"method"`
+		Secret    *string `json:"secret"` // nil keeps the current secret, "" clears it
+		OnSuccess *bool   `json:"onSuccess"`
+		OnFailure *bool   `json:"onFailure"`
+		Enabled   *bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters", err.Error())
+		return
+	}
+
+	if err := webhook.ValidateURL(c.Request.Context(), req.URL); err != nil {
+		response.Fail(c, "invalid webhook url", err.Error())
+		return
+	}
+
+	existing, err := models.GetKnowledgeWebhook(h.db, k.ID)
+	if err != nil {
+		response.Fail(c, "failed to get webhook", err.Error())
+		return
+	}
+
+	hook := &models.KnowledgeWebhook{
+		KnowledgeID: k.ID,
+		UserID:      uint(k.UserID),
+		URL:         req.URL,
+		Method:      req.Method,
+		OnSuccess:   true,
+		OnFailure:   true,
+		Enabled:     true,
+	}
+	if existing != nil {
+		hook.Secret = existing.Secret
+		hook.OnSuccess = existing.OnSuccess
+		hook.OnFailure = existing.OnFailure
+		hook.Enabled = existing.Enabled
+	}
+	if hook.Method == "" {
+		hook.Method = "POST"
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+	}
+	if req.OnSuccess != nil {
+		hook.OnSuccess = *req.OnSuccess
+	}
+	if req.OnFailure != nil {
+		hook.OnFailure = *req.OnFailure
+	}
+	if req.Enabled != nil {
+		hook.Enabled = *req.Enabled
+	}
+
+	if err := models.SaveKnowledgeWebhook(h.db, hook); err != nil {
+		response.Fail(c, "failed to save webhook", err.Error())
+		return
+	}
+
+	response.Success(c, "saved successfully", gin.H{
+		"webhook":   hook,
+		"hasSecret": hook.HasSecret(),
+	})
+}
+
+// DeleteKnowledgeWebhook removes the ingestion webhook of a knowledge base
+func (h *Handlers) DeleteKnowledgeWebhook(c *gin.Context) {
+	k, ok := h.ownedKnowledgeFromQuery(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteKnowledgeWebhook(h.db, k.ID); err != nil {
+		response.Fail(c, "failed to delete webhook", err.Error())
+		return
+	}
+
+	response.Success(c, "deleted successfully", nil)
+}
+
+// ownedKnowledgeFromQuery resolves the knowledgeKey query parameter and checks ownership
+func (h *Handlers) ownedKnowledgeFromQuery(c *gin.Context) (*models.Knowledge, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return nil, false
+	}
+
+	knowledgeKey := c.Query(constants.QueryParamKnowledgeKey)
+	if knowledgeKey == "" {
+		response.Fail(c, knowledge.ErrKnowledgeKeyRequired, nil)
+		return nil, false
+	}
+
+	k, err := resolveKnowledge(h.db, knowledgeKey)
+	if err != nil {
+		response.Fail(c, knowledge.ErrKnowledgeNotFound, err)
+		return nil, false
+	}
+	if k.UserID != int(user.ID) {
+		response.Fail(c, "unauthorized access to knowledge base", nil)
+		return nil, false
+	}
+	return k, true
+}
+
+// resolveKnowledge finds a knowledge base by key, IndexId or key prefix (for truncated keys)
+func resolveKnowledge(db *gorm.DB, knowledgeKey string) (*models.Knowledge, error) {
+	if k, err := models.GetKnowledge(db, knowledgeKey); err == nil {
+		return k, nil
+	}
+	var k models.Knowledge
+	if err := db.Where("index_id = ?", knowledgeKey).First(&k).Error; err == nil {
+		return &k, nil
+	}
+	if err := db.Where("knowledge_key LIKE ?", knowledgeKey+"%").First(&k).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// ingestedDocument describes the document of an ingestion outcome
+type ingestedDocument struct {
+	Filename    string
+	Size        int64
+	ContentType string
+}
+
+func documentFromHeader(header *multipart.FileHeader) ingestedDocument {
+	return ingestedDocument{
+		Filename:    header.Filename,
+		Size:        header.Size,
+		ContentType: header.Header.Get("Content-Type"),
+	}
+}
+
+// notifyKnowledgeIngestion fires the knowledge base webhook for an ingestion outcome.
+// Chunk counting and delivery run in the background so uploads are not slowed down.
+func (h *Handlers) notifyKnowledgeIngestion(k *models.Knowledge, kb knowledge.KnowledgeBase, searchKey string, doc ingestedDocument, ingestErr error) {
+	hook, err := models.GetKnowledgeWebhook(h.db, k.ID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load knowledge webhook - knowledgeId: %d, error: %v", k.ID, err)
+		return
+	}
+
+	event := models.KnowledgeEventIngestionSucceeded
+	if ingestErr != nil {
+		event = models.KnowledgeEventIngestionFailed
+	}
+	if hook == nil || !hook.ShouldFire(event) {
+		return
+	}
+
+	document := gin.H{
+		"filename": doc.Filename,
+		"size":     doc.Size,
+	}
+	if doc.ContentType != "" {
+		document["contentType"] = doc.ContentType
+	}
+
+	payload := gin.H{
+		"event": event,
+		"knowledge": gin.H{
+			"id":            k.ID,
+			"knowledgeKey":  k.KnowledgeKey,
+			"knowledgeName": k.KnowledgeName,
+			"provider":      k.Provider,
+		},
+		"document":   document,
+		"occurredAt": time.Now(),
+	}
+	if ingestErr != nil {
+		payload["error"] = ingestErr.Error()
+	}
+
+	db := h.db
+	go func() {
+		if ingestErr == nil && kb != nil {
+			documentChunks, totalChunks := countKnowledgeChunks(kb, k.Provider, searchKey, doc.Filename)
+			document["chunkCount"] = documentChunks
+			payload["totalChunks"] = totalChunks
+		}
+
+		result, err := webhook.DeliverPublic(context.Background(), webhook.Request{
+			URL:         hook.URL,
+			Method:      hook.Method,
+			Event:       event,
+			Secret:      hook.Secret,
+			Payload:     payload,
+			MaxAttempts: 3,
+		})
+		statusCode := 0
+		if result != nil {
+			statusCode = result.StatusCode
+		}
+		if err != nil {
+			log.Printf("WARN: Knowledge webhook delivery failed - knowledgeId: %d, event: %s, error: %v", k.ID, event, err)
+		}
+		if err := models.RecordKnowledgeWebhookDelivery(db, hook.ID, event, statusCode, err); err != nil {
+			log.Printf("ERROR: Failed to record knowledge webhook delivery - webhookId: %d, error: %v", hook.ID, err)
+		}
+	}()
+}
+
+// countKnowledgeChunks counts chunks of a document and of the whole knowledge base.
+// Providers that index asynchronously (e.g. Aliyun) may report 0 until indexing is done.
+func countKnowledgeChunks(kb knowledge.KnowledgeBase, provider, searchKey, filename string) (int, int) {
+	query := "*"
+	if provider == knowledge.ProviderAliyun {
+		query = "content"
+	}
+	results, err := kb.Search(context.Background(), searchKey, knowledge.SearchOptions{
+		Query: query,
+		TopK:  1000,
+	})
+	if err != nil {
+		return 0, 0
+	}
+
+	documentChunks := 0
+	for _, result := range results {
+		if result.Source == filename {
+			documentChunks++
+		}
+	}
+	return documentChunks, len(results)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/live"
+	"github.com/code-100-precent/LingEcho/pkg/livecaption"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/recognizer"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	liveCaptionPingInterval = 30 * time.Second
+	// liveCaptionLastIDHeader ID of the last cue in a WebVTT segment, pass it
+	// back as ?since= to fetch only newer cues
+	liveCaptionLastIDHeader = "X-Caption-Last-Id"
+)
+
+// StartLiveCaptions starts captioning a live stream played through a whep domain.
+// Audio is pulled from sourceUrl, any pullable rendition of the same stream.
+// POST /live/captions/:bucket/:stream
+func (h *Handlers) StartLiveCaptions(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	var req struct {
+		SourceURL    string `json:"sourceUrl" binding:"required"`
+		CredentialID uint   `json:"credentialId" binding:"required"`
+		Language     string `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if req.Language == "" {
+		req.Language = "zh-CN"
+	}
+	bucket, stream := c.Param("bucket"), c.Param("stream")
+
+	whepDomains, err := liveWhepDomains(bucket)
+	if err != nil {
+		response.Fail(c, "failed to query play domains", err.Error())
+		return
+	}
+	if len(whepDomains) == 0 {
+		response.Fail(c, "no whep play domain", "Bind a whep play domain to the bucket first")
+		return
+	}
+
+	var credential models.UserCredential
+	if err := h.db.Where("id = ? AND user_id = ?", req.CredentialID, user.ID).First(&credential).Error; err != nil {
+		response.Fail(c, "credential not found", nil)
+		return
+	}
+	provider := credential.GetASRProvider()
+	if provider == "" {
+		response.Fail(c, "ASR provider not configured", nil)
+		return
+	}
+	asrConfig, err := recognizer.NewTranscriberConfigFromMap(provider, credential.AsrConfig, req.Language)
+	if err != nil {
+		response.Fail(c, "invalid ASR config", err.Error())
+		return
+	}
+	asr, err := recognizer.GetGlobalFactory().CreateTranscriber(asrConfig)
+	if err != nil {
+		response.Fail(c, "failed to create ASR service", err.Error())
+		return
+	}
+
+	session, err := livecaption.DefaultManager().Start(livecaption.Config{
+		Bucket:    bucket,
+		Stream:    stream,
+		SourceURL: req.SourceURL,
+		Language:  req.Language,
+		Owner:     user.ID,
+		ASR:       asr,
+	})
+	if err != nil {
+		response.Fail(c, "failed to start captions", err.Error())
+		return
+	}
+
+	logger.Info("Live captions started", zap.String("bucket", bucket), zap.String("stream", stream), zap.Uint("userId", user.ID))
+	response.Success(c, "captions started", gin.H{
+		"status":      session.Status(),
+		"whepDomains": whepDomains,
+		"webvtt":      fmt.Sprintf("live/captions/%s/%s/captions.vtt", bucket, stream),
+		"websocket":   fmt.Sprintf("live/captions/%s/%s/ws", bucket, stream),
+	})
+}
+
+// GetLiveCaptionsStatus gets the captioning status of a live stream
+// GET /live/captions/:bucket/:stream
+func (h *Handlers) GetLiveCaptionsStatus(c *gin.Context) {
+	session, ok := h.ownedCaptionSession(c)
+	if !ok {
+		return
+	}
+	response.Success(c, "success", session.Status())
+}
+
+// StopLiveCaptions stops captioning a live stream
+// DELETE /live/captions/:bucket/:stream
+func (h *Handlers) StopLiveCaptions(c *gin.Context) {
+	if _, ok := h.ownedCaptionSession(c); !ok {
+		return
+	}
+	if err := livecaption.DefaultManager().Stop(c.Param("bucket"), c.Param("stream")); err != nil {
+		response.Fail(c, "failed to stop captions", err.Error())
+		return
+	}
+	response.Success(c, "captions stopped", nil)
+}
+
+// GetLiveCaptionsWebVTT serves the captions of a live stream as a WebVTT segment
+// for players. Pass ?since=<last cue id> to only get newer cues.
+// GET /live/captions/:bucket/:stream/captions.vtt
+func (h *Handlers) GetLiveCaptionsWebVTT(c *gin.Context) {
+	session, ok := livecaption.DefaultManager().Get(c.Param("bucket"), c.Param("stream"))
+	if !ok {
+		c.String(http.StatusNotFound, "captions not running for this stream")
+		return
+	}
+	since, _ := strconv.Atoi(c.Query("since"))
+
+	cues := session.Cues(since)
+	lastID := since
+	if len(cues) > 0 {
+		lastID = cues[len(cues)-1].ID
+	}
+	c.Header(liveCaptionLastIDHeader, strconv.Itoa(lastID))
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(livecaption.RenderWebVTT(cues)))
+}
+
+// HandleLiveCaptionsWebSocket pushes caption cues of a live stream to players,
+// including interim results, as JSON {"type":"cue","cue":{...}}. Recent final cues
+// are replayed on connect.
+// GET /live/captions/:bucket/:stream/ws
+func (h *Handlers) HandleLiveCaptionsWebSocket(c *gin.Context) {
+	session, ok := livecaption.DefaultManager().Get(c.Param("bucket"), c.Param("stream"))
+	if !ok {
+		response.Fail(c, "captions not running for this stream", nil)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Warn("Live captions websocket upgrade failed", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	cues, unsubscribe := session.Subscribe()
+	defer unsubscribe()
+
+	// Detect the player going away, nothing is expected from it
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	since, _ := strconv.Atoi(c.Query("since"))
+	for _, cue := range session.Cues(since) {
+		if err := conn.WriteJSON(gin.H{"type": "cue", "cue": cue}); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(liveCaptionPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case cue, ok := <-cues:
+			if !ok {
+				_ = conn.WriteJSON(gin.H{"type": "end"})
+				return
+			}
+			if err := conn.WriteJSON(gin.H{"type": "cue", "cue": cue}); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// ownedCaptionSession loads the caption session of the stream in the path,
+// only the user who started it may manage it
+func (h *Handlers) ownedCaptionSession(c *gin.Context) (*livecaption.Session, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return nil, false
+	}
+	session, ok := livecaption.DefaultManager().Get(c.Param("bucket"), c.Param("stream"))
+	if !ok {
+		response.Fail(c, "captions not running", livecaption.ErrNotRunning.Error())
+		return nil, false
+	}
+	if session.Owner != user.ID {
+		response.Fail(c, "forbidden", "Captions were started by another user")
+		return nil, false
+	}
+	return session, true
+}
+
+// liveWhepDomains lists the enabled whep play domains of a bucket
+func liveWhepDomains(bucket string) ([]string, error) {
+	client, err := live.NewBucketClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.ListPlayDomains(bucket)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errors.New("empty play domain list")
+	}
+	var domains []string
+	for _, d := range resp.Domains {
+		if d.Type == "whep" && d.Enable {
+			domains = append(domains, d.Domain)
+		}
+	}
+	return domains, nil
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/semcache"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetLLMCacheStats reports the semantic cache hit rates, in total and per
+// assistant / knowledge base version scope
+// GET /llm-cache/stats
+func (h *Handlers) GetLLMCacheStats(c *gin.Context) {
+	cache := semcache.Default()
+	cfg := cache.Config()
+	total, scopes := cache.Stats()
+	response.Success(c, "Query successful", gin.H{
+		"enabled":   utils.GetBoolValue(h.db, constants.KEY_LLM_CACHE_ENABLED),
+		"threshold": cfg.Threshold,
+		"ttl":       int(cfg.TTL.Seconds()),
+		"total":     total,
+		"scopes":    scopes,
+	})
+}
+
+// ClearLLMCache drops cached answers, of one assistant when assistantId is given
+// DELETE /llm-cache?assistantId=
+func (h *Handlers) ClearLLMCache(c *gin.Context) {
+	prefix := ""
+	if raw := c.Query("assistantId"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			response.Fail(c, "Invalid assistantId", err.Error())
+			return
+		}
+		prefix = semcache.AssistantPrefix(uint(id))
+	}
+	dropped := semcache.Default().Invalidate(prefix)
+	response.Success(c, "Cache cleared", gin.H{"dropped": dropped})
+}
+
+// invalidateLLMCache drops an assistant's cached answers after its prompt or model changed
+func (h *Handlers) invalidateLLMCache(assistantID uint) {
+	semcache.Default().Invalidate(semcache.AssistantPrefix(assistantID))
+}
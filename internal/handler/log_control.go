@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxLogElevation bounds how long debug logging can stay on without renewing
+const maxLogElevation = 4 * time.Hour
+
+// UpdateLogControlRequest changes the levels; omitted fields are left unchanged.
+// A module set to "" falls back to the global level
+type UpdateLogControlRequest struct {
+	Level    string            `json:"level"`
+	Modules  map[string]string `json:"modules"`
+	Sampling *struct {
+		First       int `json:"first"`
+		Thereafter  int `json:"thereafter"`
+		TickSeconds int `json:"tickSeconds"`
+	} `json:"sampling"`
+}
+
+// ElevateLogRequest temporarily lowers the level for some or all modules
+type ElevateLogRequest struct {
+	Level           string   `json:"level"`   // defaults to debug
+	Modules         []string `json:"modules"` // empty elevates every module
+	DurationSeconds int      `json:"durationSeconds" binding:"required"`
+}
+
+// GetLogControl returns the runtime log levels, sampling and elevation window of this instance
+// GET /system/log-control
+func (h *Handlers) GetLogControl(c *gin.Context) {
+	response.Success(c, "success", logger.Control.State())
+}
+
+// UpdateLogControl changes the global and per-module levels and the debug sampling.
+// Changes apply to this instance only and are lost on restart
+// PUT /system/log-control
+func (h *Handlers) UpdateLogControl(c *gin.Context) {
+	var req UpdateLogControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	// Validate everything before applying anything
+	var global *zapcore.Level
+	if req.Level != "" {
+		level, err := logger.ParseLevel(req.Level)
+		if err != nil {
+			response.Fail(c, "invalid level", err.Error())
+			return
+		}
+		global = &level
+	}
+	known := map[string]bool{}
+	for _, m := range logger.Control.State().KnownModules {
+		known[m] = true
+	}
+	modules := make(map[string]*zapcore.Level, len(req.Modules))
+	for module, value := range req.Modules {
+		if !known[module] {
+			response.Fail(c, "unknown module", module)
+			return
+		}
+		if value == "" {
+			modules[module] = nil
+			continue
+		}
+		level, err := logger.ParseLevel(value)
+		if err != nil {
+			response.Fail(c, "invalid level", err.Error())
+			return
+		}
+		modules[module] = &level
+	}
+
+	if global != nil {
+		logger.Control.SetLevel(*global)
+	}
+	for module, level := range modules {
+		if level == nil {
+			_ = logger.Control.SetModuleLevel(module, 0, true)
+		} else {
+			_ = logger.Control.SetModuleLevel(module, *level, false)
+		}
+	}
+	if s := req.Sampling; s != nil {
+		logger.Control.SetSampling(logger.SamplingConfig{
+			First:      s.First,
+			Thereafter: s.Thereafter,
+			Tick:       time.Duration(s.TickSeconds) * time.Second,
+		})
+	}
+	state := logger.Control.State()
+	logger.Warn("Log control updated", zap.Uint("userID", models.CurrentUser(c).ID),
+		zap.String("level", state.Level), zap.Any("modules", state.Modules), zap.Int("sampleFirst", state.Sampling.First))
+	response.Success(c, "success", state)
+}
+
+// ElevateLogLevel lowers the level for a limited time, after which the previous levels
+// apply again without another call
+// POST /system/log-control/elevate
+func (h *Handlers) ElevateLogLevel(c *gin.Context) {
+	var req ElevateLogRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if req.Level == "" {
+		req.Level = "debug"
+	}
+	level, err := logger.ParseLevel(req.Level)
+	if err != nil {
+		response.Fail(c, "invalid level", err.Error())
+		return
+	}
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if duration <= 0 || duration > maxLogElevation {
+		response.Fail(c, "durationSeconds must be between 1 and 14400", nil)
+		return
+	}
+	elevation, err := logger.Control.Elevate(level, req.Modules, duration)
+	if err != nil {
+		response.Fail(c, "unknown module", err.Error())
+		return
+	}
+	logger.Warn("Elevated logging window started", zap.Uint("userID", models.CurrentUser(c).ID),
+		zap.String("level", elevation.Level), zap.Strings("modules", elevation.Modules), zap.Time("until", elevation.Until))
+	response.Success(c, "success", elevation)
+}
+
+// CancelLogElevation ends the elevated logging window early
+// DELETE /system/log-control/elevate
+func (h *Handlers) CancelLogElevation(c *gin.Context) {
+	logger.Control.CancelElevation()
+	response.Success(c, "success", logger.Control.State())
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// scoreLoginAnomaly scores a login against the user's recent activity with the
+// login anomaly model, or the rules when no model is configured. Returns nil
+// when scoring is unavailable
+func (h *Handlers) scoreLoginAnomaly(c *gin.Context, db *gorm.DB, userID uint, clientIP, deviceID, country, city string) *utils.LoginAnomalyResult {
+	if utils.GlobalLoginSecurityManager == nil {
+		return nil
+	}
+	now := time.Now()
+	history, err := models.RecentLoginAttempts(db, userID, now)
+	if err != nil {
+		logger.Warn("Failed to load login activity for anomaly scoring", zap.Uint("userID", userID), zap.Error(err))
+		return nil
+	}
+	attempt := utils.LoginAttempt{
+		At:       now,
+		IP:       clientIP,
+		DeviceID: deviceID,
+		Country:  country,
+		City:     city,
+		Success:  true,
+	}
+	if h.ipLocationService != nil && !utils.IsInternalIP(clientIP) {
+		if info, err := h.ipLocationService.LookupIPRisk(clientIP); err == nil {
+			attempt.Latitude, attempt.Longitude = info.Latitude, info.Longitude
+			attempt.Proxy, attempt.Hosting = info.Proxy, info.Hosting
+		} else {
+			logger.Debug("IP risk lookup failed, scoring without it", zap.String("ip", clientIP), zap.Error(err))
+		}
+	}
+	features := utils.BuildLoginFeatures(userID, attempt, history)
+	utils.GlobalLoginSecurityManager.EnrichLoginFeatures(c.Request.Context(), &features, clientIP)
+	result := utils.GlobalLoginSecurityManager.ScoreLoginAnomaly(c.Request.Context(), features)
+	result.Latitude, result.Longitude = attempt.Latitude, attempt.Longitude
+	if result.Suspicious || result.ModelScore != nil || result.Decision != utils.LoginRiskAllow {
+		fields := []zap.Field{
+			zap.Uint("userID", userID),
+			zap.String("mode", result.Mode),
+			zap.String("source", result.Source),
+			zap.Float64("score", result.Score),
+			zap.Float64("ruleScore", result.RuleScore),
+			zap.String("modelVersion", result.ModelVersion),
+			zap.Bool("suspicious", result.Suspicious),
+			zap.String("decision", result.Decision),
+			zap.Strings("reasons", result.Reasons),
+		}
+		if result.ModelScore != nil {
+			fields = append(fields, zap.Float64("modelScore", *result.ModelScore))
+		}
+		logger.Info("Login anomaly scored", fields...)
+	}
+	return result
+}
+
+// rejectForLoginRisk stops a login whose risk score reached the block threshold,
+// recording it as a failed attempt with the score
+func (h *Handlers) rejectForLoginRisk(c *gin.Context, db *gorm.DB, user *models.User, anomaly *utils.LoginAnomalyResult, clientIP, location, country, city, userAgent, deviceID, loginType string) bool {
+	if anomaly == nil || anomaly.Decision != utils.LoginRiskBlock {
+		return false
+	}
+	if err := models.RecordLoginHistoryWithAnomaly(db, user.ID, user.Email, clientIP, location, country, city, userAgent, deviceID, loginType, false, "blocked by login risk", true, anomaly); err != nil {
+		logger.Warn("Failed to record blocked login", zap.Error(err))
+	}
+	logger.Warn("Login blocked by risk score",
+		zap.Uint("userID", user.ID),
+		zap.String("ip", clientIP),
+		zap.String("loginType", loginType),
+		zap.Float64("score", anomaly.Score),
+		zap.Strings("reasons", anomaly.Reasons))
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": "login blocked for security reasons, please try again later or contact support"})
+	return true
+}
+
+// GetLoginAnomalyShadowReport compares the model with the rules over the recent
+// logins, per model version, to evaluate a model in shadow mode before enforcing it
+// GET /login-anomaly/report?days=7&threshold=
+func (h *Handlers) GetLoginAnomalyShadowReport(c *gin.Context) {
+	mode, threshold := utils.LoginAnomalyModeOff, 0.0
+	if utils.GlobalLoginSecurityManager != nil {
+		mode, threshold = utils.GlobalLoginSecurityManager.LoginAnomalySettings()
+	}
+	if t, err := strconv.ParseFloat(c.Query("threshold"), 64); err == nil && t > 0 && t <= 1 {
+		threshold = t
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days <= 0 || days > 90 {
+		days = 7
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	stats, fallbacks, err := models.LoginAnomalyShadowReport(h.db, since, threshold)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"mode":      mode,
+		"threshold": threshold,
+		"since":     since,
+		"versions":  stats,
+		"fallbacks": fallbacks,
+	})
+}
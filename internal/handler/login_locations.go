@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLoginLocationDays = 30
+	maxLoginLocationDays     = 365
+)
+
+// loginLocationQuery reads the day range (days, default 30, at most 365) and
+// the granularity (country or city, default city) of a heatmap request
+func loginLocationQuery(c *gin.Context) (from, to time.Time, granularity string) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultLoginLocationDays)))
+	if days < 1 || days > maxLoginLocationDays {
+		days = defaultLoginLocationDays
+	}
+	granularity = c.DefaultQuery("granularity", models.LoginLocationByCity)
+	if granularity != models.LoginLocationByCountry {
+		granularity = models.LoginLocationByCity
+	}
+	to = time.Now()
+	return to.AddDate(0, 0, 1-days), to, granularity
+}
+
+// handleGetLoginLocationHeatmap login counts of the current user bucketed by
+// day and country or city, read from the rollup of the login history
+// GET /auth/login-locations
+func (h *Handlers) handleGetLoginLocationHeatmap(c *gin.Context) {
+	user := models.CurrentUser(c)
+	from, to, granularity := loginLocationQuery(c)
+	buckets, err := models.GetLoginLocationHeatmap(h.db, []uint{user.ID}, from, to, granularity)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"buckets":     buckets,
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+		"granularity": granularity,
+	})
+}
+
+// GetGroupLoginLocationHeatmap login counts of all organization members
+// bucketed by day and country or city; organization admins only
+// GET /group/:id/login-locations
+func (h *Handlers) GetGroupLoginLocationHeatmap(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	memberIDs, err := models.GroupMemberIDs(h.db, group)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	from, to, granularity := loginLocationQuery(c)
+	buckets, err := models.GetLoginLocationHeatmap(h.db, memberIDs, from, to, granularity)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"buckets":     buckets,
+		"members":     len(memberIDs),
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+		"granularity": granularity,
+	})
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type maintenanceWindowRequest struct {
+	Name            string `json:"name" binding:"required"`
+	Schedule        string `json:"schedule" binding:"required"` // 5-field cron expression of the window starts
+	Timezone        string `json:"timezone"`
+	DurationMinutes int    `json:"durationMinutes" binding:"required"`
+	Enabled         *bool  `json:"enabled"` // defaults to true
+}
+
+type deviceActionRequest struct {
+	DeviceID   string `json:"deviceId" binding:"required"`
+	Kind       string `json:"kind" binding:"required"` // firmware, config or command
+	Disruptive bool   `json:"disruptive"`              // firmware is always disruptive
+	Payload    string `json:"payload"`
+}
+
+// maintenanceWindowOf loads the organization from :id (admin rights required) and its window from :windowId
+func (h *Handlers) maintenanceWindowOf(c *gin.Context) (*models.MaintenanceWindow, bool) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return nil, false
+	}
+	windowID, err := strconv.ParseUint(c.Param("windowId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid window id", nil)
+		return nil, false
+	}
+	var w models.MaintenanceWindow
+	err = h.db.Where("id = ? AND group_id = ?", windowID, group.ID).First(&w).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, "maintenance window not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return nil, false
+	}
+	return &w, true
+}
+
+// ListMaintenanceWindows lists the fleet's maintenance windows with their state
+// and the queued actions each window will run when it opens next
+// GET /group/:id/maintenance-windows
+func (h *Handlers) ListMaintenanceWindows(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, false)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	previews, err := models.PreviewMaintenanceWindows(h.db, group.ID, now)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	open, nextOpenAt, err := models.FleetMaintenanceOpen(h.db, &group.ID, now)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	result := gin.H{"windows": previews, "open": open}
+	if !nextOpenAt.IsZero() {
+		result["nextOpenAt"] = nextOpenAt
+	}
+	response.Success(c, "success", result)
+}
+
+// CreateMaintenanceWindow adds a maintenance window to the fleet
+// POST /group/:id/maintenance-windows
+func (h *Handlers) CreateMaintenanceWindow(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	var req maintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	w := models.MaintenanceWindow{GroupID: group.ID, CreatedBy: models.CurrentUser(c).ID}
+	h.applyMaintenanceWindow(c, &w, &req)
+}
+
+// UpdateMaintenanceWindow replaces a maintenance window's schedule
+// PUT /group/:id/maintenance-windows/:windowId
+func (h *Handlers) UpdateMaintenanceWindow(c *gin.Context) {
+	w, ok := h.maintenanceWindowOf(c)
+	if !ok {
+		return
+	}
+	var req maintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	h.applyMaintenanceWindow(c, w, &req)
+}
+
+func (h *Handlers) applyMaintenanceWindow(c *gin.Context, w *models.MaintenanceWindow, req *maintenanceWindowRequest) {
+	w.Name = req.Name
+	w.Schedule = req.Schedule
+	w.Timezone = req.Timezone
+	w.DurationMinutes = req.DurationMinutes
+	w.Enabled = req.Enabled == nil || *req.Enabled
+	if err := w.Validate(); err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	if err := h.db.Save(w).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "saved", w)
+}
+
+// DeleteMaintenanceWindow removes a maintenance window; queued actions stay queued
+// until another window opens, or run at the next dispatch if none is left
+// DELETE /group/:id/maintenance-windows/:windowId
+func (h *Handlers) DeleteMaintenanceWindow(c *gin.Context) {
+	w, ok := h.maintenanceWindowOf(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(w).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// ListDeviceActions lists the fleet's device actions, optionally filtered by status
+// GET /group/:id/device-actions
+func (h *Handlers) ListDeviceActions(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, false)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	query := h.db.Model(&models.DeviceAction{}).Where("group_id = ?", group.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if deviceID := c.Query("deviceId"); deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	var actions []models.DeviceAction
+	if err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&actions).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"list":  actions,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// CreateDeviceAction submits a firmware rollout, config push or command to a
+// device of the fleet. Disruptive actions outside the maintenance windows are
+// queued and executed when a window opens.
+// POST /group/:id/device-actions
+func (h *Handlers) CreateDeviceAction(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	var req deviceActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if !models.ValidDeviceActionKind(req.Kind) {
+		response.Fail(c, "invalid kind", "kind must be firmware, config or command")
+		return
+	}
+	var device models.Device
+	if err := h.db.Where("id = ? AND group_id = ?", req.DeviceID, group.ID).First(&device).Error; err != nil {
+		response.Fail(c, "device not found in this organization", nil)
+		return
+	}
+	action := models.DeviceAction{
+		GroupID:    group.ID,
+		DeviceID:   device.ID,
+		Kind:       req.Kind,
+		Disruptive: req.Disruptive,
+		Payload:    req.Payload,
+		CreatedBy:  models.CurrentUser(c).ID,
+	}
+	if err := models.SubmitDeviceAction(h.db, &action, time.Now()); err != nil {
+		response.Fail(c, "submit failed", err.Error())
+		return
+	}
+	msg := "executed"
+	if action.Status == models.DeviceActionQueued {
+		msg = "queued until the next maintenance window"
+	}
+	response.Success(c, msg, action)
+}
+
+// CancelDeviceAction cancels a queued device action
+// DELETE /group/:id/device-actions/:actionId
+func (h *Handlers) CancelDeviceAction(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	actionID, err := strconv.ParseUint(c.Param("actionId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid action id", nil)
+		return
+	}
+	err = models.CancelDeviceAction(h.db, group.ID, uint(actionID))
+	if errors.Is(err, models.ErrDeviceActionNotQueued) {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		response.Fail(c, "cancel failed", err.Error())
+		return
+	}
+	response.Success(c, "cancelled", nil)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/mediastream"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMediaStreamTokenTTL = time.Minute
+	maxMediaStreamTokenTTL     = 10 * time.Minute
+	mediaStreamWriteTimeout    = 5 * time.Second
+	// mediaStreamMaxFrame largest binary message accepted from a client (1s of audio)
+	mediaStreamMaxFrame = mediastream.SampleRate * 2
+)
+
+// mediaStreamUpgrader the media stream is authorized by its token, not by cookies
+var mediaStreamUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// MediaStreamTokenRequest request of a media stream token
+type MediaStreamTokenRequest struct {
+	CallID     string           `json:"callId" binding:"required"`
+	Mode       mediastream.Mode `json:"mode"`       // listen (default) or duplex
+	TTLSeconds int              `json:"ttlSeconds"` // token lifetime, at most 600
+}
+
+// mediaStreamControl text message sent next to the binary audio frames
+type mediaStreamControl struct {
+	Type       string           `json:"type"` // start, dropped or end
+	CallID     string           `json:"callId,omitempty"`
+	Mode       mediastream.Mode `json:"mode,omitempty"`
+	Encoding   string           `json:"encoding,omitempty"`
+	SampleRate int              `json:"sampleRate,omitempty"`
+	Dropped    int64            `json:"dropped,omitempty"`
+}
+
+// ListMediaStreamCalls lists the calls available for media streaming
+func (h *Handlers) ListMediaStreamCalls(c *gin.Context) {
+	response.Success(c, "success", mediastream.Default().Calls())
+}
+
+// CreateMediaStreamToken issues a single-use token to open a media stream on a call
+func (h *Handlers) CreateMediaStreamToken(c *gin.Context) {
+	var req MediaStreamTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err.Error())
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = mediastream.ModeListen
+	}
+	if !req.Mode.Valid() {
+		response.Fail(c, "Invalid mode", "mode must be listen or duplex")
+		return
+	}
+	ttl := defaultMediaStreamTokenTTL
+	if req.TTLSeconds > 0 {
+		ttl = min(time.Duration(req.TTLSeconds)*time.Second, maxMediaStreamTokenTTL)
+	}
+
+	user := models.CurrentUser(c)
+	token, grant, err := mediastream.Default().Issue(req.CallID, req.Mode, user.Email, ttl)
+	if err != nil {
+		response.Fail(c, "Failed to create media stream token", err.Error())
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"call_id": req.CallID,
+		"mode":    req.Mode,
+		"user_id": user.ID,
+	}).Info("Media stream token issued")
+	response.Success(c, "success", gin.H{
+		"token":     token,
+		"grant":     grant,
+		"streamUrl": config.GlobalConfig.Server.APIPrefix + "/media-streams/ws?token=" + token,
+	})
+}
+
+// StreamCallMedia WebSocket media stream of a call. Binary messages from the
+// server carry the caller's audio, binary messages from a duplex client are
+// played into the call; both are 16-bit little-endian mono PCM at 8kHz. Text
+// messages carry JSON control events. A client that reads too slowly loses
+// frames and is told so; one that sends faster than real time is slowed down.
+func (h *Handlers) StreamCallMedia(c *gin.Context) {
+	hub := mediastream.Default()
+	grant, err := hub.Redeem(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	stream, err := hub.Attach(grant)
+	if err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, mediastream.ErrCallNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	defer stream.Close()
+
+	conn, err := mediaStreamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(mediaStreamMaxFrame)
+
+	log := logrus.WithFields(logrus.Fields{
+		"call_id": grant.CallID,
+		"mode":    grant.Mode,
+		"subject": grant.Subject,
+	})
+	log.Info("Media stream attached")
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go h.readMediaStream(ctx, cancel, conn, stream, log)
+
+	write := func(messageType int, data []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(mediaStreamWriteTimeout))
+		return conn.WriteMessage(messageType, data)
+	}
+	writeControl := func(msg mediaStreamControl) error {
+		conn.SetWriteDeadline(time.Now().Add(mediaStreamWriteTimeout))
+		return conn.WriteJSON(msg)
+	}
+
+	if err := writeControl(mediaStreamControl{
+		Type:       "start",
+		CallID:     grant.CallID,
+		Mode:       grant.Mode,
+		Encoding:   "pcm_s16le",
+		SampleRate: mediastream.SampleRate,
+	}); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var reported int64
+	for {
+		select {
+		case <-ctx.Done():
+			log.WithField("dropped", stream.Dropped()).Info("Media stream detached")
+			return
+		case <-ticker.C:
+			if dropped := stream.Dropped(); dropped > reported {
+				reported = dropped
+				if err := writeControl(mediaStreamControl{Type: "dropped", Dropped: dropped}); err != nil {
+					return
+				}
+			}
+		case frame, ok := <-stream.Frames():
+			if !ok {
+				writeControl(mediaStreamControl{Type: "end", Dropped: stream.Dropped()})
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, "call ended"),
+					time.Now().Add(time.Second))
+				log.WithField("dropped", stream.Dropped()).Info("Media stream ended with the call")
+				return
+			}
+			if err := write(websocket.BinaryMessage, frame); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readMediaStream injects the client's audio until the connection closes
+func (h *Handlers) readMediaStream(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, stream *mediastream.Stream, log *logrus.Entry) {
+	defer cancel()
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		if err := stream.Inject(ctx, data); err != nil {
+			if errors.Is(err, mediastream.ErrReadOnly) {
+				log.Warn("Audio received on a listen-only media stream, closing")
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/oauth"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	oauthStatePrefix     = "oauth_state:"
+	oauthTwoFactorPrefix = "oauth_2fa:"
+	oauthStateTTL        = 10 * time.Minute
+	oauthTwoFactorTTL    = 5 * time.Minute
+	oauthExchangeTimeout = 15 * time.Second
+)
+
+// oauthPendingLogin state kept between the redirect to the provider and the callback
+type oauthPendingLogin struct {
+	Provider   string `json:"provider"`
+	Redirect   string `json:"redirect"`
+	LinkUserID uint   `json:"linkUserId,omitempty"` // links the account to this signed-in user instead of signing in
+}
+
+// oauthTwoFactorTicket a login waiting for the TOTP code. Groups carries the OIDC group
+// claim so memberships are only synced once the login completes
+type oauthTwoFactorTicket struct {
+	Provider string   `json:"provider"`
+	UserID   uint     `json:"userId"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// oauthTwoFactorRequest completes a social login for accounts with two-factor authentication
+type oauthTwoFactorRequest struct {
+	Ticket string `json:"ticket" binding:"required"`
+	Code   string `json:"code" binding:"required"`
+}
+
+// oauthProviders builds the registry from the configured clients
+func oauthProviders() *oauth.Registry {
+	registry := oauth.NewRegistry()
+	if config.GlobalConfig == nil {
+		return registry
+	}
+	o := config.GlobalConfig.Integrations.OAuthLogin
+	if o.GoogleClientID != "" && o.GoogleClientSecret != "" {
+		registry.Register(oauth.NewGoogleProvider(o.GoogleClientID, o.GoogleClientSecret))
+	}
+	if o.GitHubClientID != "" && o.GitHubClientSecret != "" {
+		registry.Register(oauth.NewGitHubProvider(o.GitHubClientID, o.GitHubClientSecret))
+	}
+	if o.WeChatAppID != "" && o.WeChatAppSecret != "" {
+		registry.Register(oauth.NewWeChatProvider(o.WeChatAppID, o.WeChatAppSecret))
+	}
+	return registry
+}
+
+func oauthLoginPath(provider string) string {
+	return samlAuthPath() + "/oauth/" + provider + "/login"
+}
+
+// oauthCallbackURL is the redirect URI registered at the provider
+func oauthCallbackURL(c *gin.Context, provider string) string {
+	return serverBaseURL(c) + samlAuthPath() + "/oauth/" + provider + "/callback"
+}
+
+func randomTicket() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ListOAuthProviders lists the enabled social login providers and the OIDC single sign-on for the login page
+// GET /auth/oauth/providers
+func (h *Handlers) ListOAuthProviders(c *gin.Context) {
+	names := oauthProviders().Names()
+	providers := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		providers = append(providers, gin.H{"provider": name, "loginUrl": oauthLoginPath(name)})
+	}
+	if currentOIDCProvider() != nil {
+		providers = append(providers, gin.H{
+			"provider": oauth.ProviderOIDC,
+			"name":     config.GlobalConfig.Integrations.OIDC.DisplayName,
+			"loginUrl": oidcLoginPath(),
+		})
+	}
+	response.Success(c, "success", providers)
+}
+
+// OAuthLogin redirects to the provider's consent page. With ?link=1 the signed-in user
+// links the account instead of signing in, which is the only way to use WeChat since
+// it doesn't disclose an email
+// GET /auth/oauth/:provider/login?redirect=/path&link=1
+func (h *Handlers) OAuthLogin(c *gin.Context) {
+	provider, err := oauthProviders().Get(c.Param("provider"))
+	if err != nil {
+		response.Fail(c, "login provider is not enabled", err.Error())
+		return
+	}
+	pending := oauthPendingLogin{Provider: provider.Name(), Redirect: safeRedirect(c.Query("redirect"))}
+	if c.Query("link") != "" {
+		user := models.CurrentUser(c)
+		if user == nil {
+			response.Fail(c, "sign in to link an account", nil)
+			return
+		}
+		pending.LinkUserID = user.ID
+	}
+
+	state, err := randomTicket()
+	if err != nil {
+		response.Fail(c, "failed to start login", err.Error())
+		return
+	}
+	value, _ := json.Marshal(pending)
+	if err := cache.GetGlobalCache().Set(c.Request.Context(), oauthStatePrefix+state, string(value), oauthStateTTL); err != nil {
+		response.Fail(c, "failed to start login", err.Error())
+		return
+	}
+	c.Redirect(http.StatusFound, provider.AuthURL(state, oauthCallbackURL(c, provider.Name())))
+}
+
+// OAuthCallback exchanges the code, then links the account or signs the user in. Unlinked
+// accounts are linked to the user with the same verified email, or a new user is created
+// when OAUTH_AUTO_PROVISION is on
+// GET /auth/oauth/:provider/callback?code=&state=
+func (h *Handlers) OAuthCallback(c *gin.Context) {
+	providerName := c.Param("provider")
+	fail := func(email string, err error) {
+		logger.Warn("OAuth login failed", zap.String("provider", providerName), zap.String("email", email), zap.Error(err))
+		response.Fail(c, "login failed", err.Error())
+	}
+
+	// Each state can only be consumed once
+	state := c.Query("state")
+	stateCache := cache.GetGlobalCache()
+	value, found := stateCache.Get(c.Request.Context(), oauthStatePrefix+state)
+	if state == "" || !found {
+		fail("", errors.New("unknown or expired login request"))
+		return
+	}
+	_ = stateCache.Delete(c.Request.Context(), oauthStatePrefix+state)
+	var pending oauthPendingLogin
+	raw, _ := value.(string)
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil || pending.Provider != providerName {
+		fail("", errors.New("login request does not belong to this provider"))
+		return
+	}
+	if errMsg := c.Query("error"); errMsg != "" {
+		fail("", errors.New("authorization denied: "+errMsg))
+		return
+	}
+	provider, err := oauthProviders().Get(providerName)
+	if err != nil {
+		fail("", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), oauthExchangeTimeout)
+	defer cancel()
+	identity, err := provider.Exchange(ctx, c.Query("code"), oauthCallbackURL(c, providerName))
+	if err != nil {
+		fail("", err)
+		return
+	}
+	now := time.Now()
+
+	if pending.LinkUserID != 0 {
+		if err := models.LinkOAuthIdentity(h.db, pending.LinkUserID, identity, now); err != nil {
+			fail(identity.Email, err)
+			return
+		}
+		logger.Info("OAuth account linked", zap.String("provider", providerName), zap.Uint("userID", pending.LinkUserID))
+		c.Redirect(http.StatusFound, pending.Redirect)
+		return
+	}
+
+	user, created, err := models.ResolveOAuthUser(h.db, identity, config.GlobalConfig.Integrations.OAuthLogin.AutoProvision, now)
+	if err != nil {
+		fail(identity.Email, err)
+		return
+	}
+	if created {
+		logger.Info("User provisioned by OAuth login", zap.String("provider", providerName), zap.Uint("userID", user.ID), zap.String("email", user.Email))
+	}
+	if !h.checkOAuthLoginAllowed(c, user, fail) {
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		h.redirectForTwoFactor(c, oauthTwoFactorTicket{Provider: providerName, UserID: user.ID}, user.Email, pending.Redirect, fail)
+		return
+	}
+
+	if h.finishOAuthLogin(c, user, providerName) {
+		c.Redirect(http.StatusFound, pending.Redirect)
+	}
+}
+
+// redirectForTwoFactor sends the user back to the page with a ticket to complete the login
+// with the TOTP code through OAuthTwoFactor
+func (h *Handlers) redirectForTwoFactor(c *gin.Context, pending oauthTwoFactorTicket, email, redirect string, fail func(string, error)) {
+	ticket, err := randomTicket()
+	if err == nil {
+		value, _ := json.Marshal(pending)
+		err = cache.GetGlobalCache().Set(c.Request.Context(), oauthTwoFactorPrefix+ticket, string(value), oauthTwoFactorTTL)
+	}
+	if err != nil {
+		fail(email, err)
+		return
+	}
+	fragment := url.Values{"twoFactorTicket": {ticket}}
+	c.Redirect(http.StatusFound, redirect+"#"+fragment.Encode())
+}
+
+// OAuthTwoFactor completes a social login with the TOTP code of the account
+// POST /auth/oauth/two-factor
+func (h *Handlers) OAuthTwoFactor(c *gin.Context) {
+	var req oauthTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	stateCache := cache.GetGlobalCache()
+	value, found := stateCache.Get(c.Request.Context(), oauthTwoFactorPrefix+req.Ticket)
+	var pending oauthTwoFactorTicket
+	raw, _ := value.(string)
+	if !found || json.Unmarshal([]byte(raw), &pending) != nil || pending.UserID == 0 {
+		response.Fail(c, "login expired, please sign in again", nil)
+		return
+	}
+	user, err := models.GetUserByUID(h.db, pending.UserID)
+	if err != nil {
+		response.Fail(c, "login expired, please sign in again", nil)
+		return
+	}
+	if !h.verifyTwoFactorCode(user, req.Code) {
+		response.Fail(c, "Invalid two-factor authentication code", errors.New("invalid 2fa code"))
+		return
+	}
+	_ = stateCache.Delete(c.Request.Context(), oauthTwoFactorPrefix+req.Ticket)
+
+	fail := func(email string, err error) { response.Fail(c, "login failed", err.Error()) }
+	if !h.checkOAuthLoginAllowed(c, user, fail) || !h.finishOAuthLogin(c, user, pending.Provider) {
+		return
+	}
+	if pending.Provider == oauth.ProviderOIDC {
+		h.syncOIDCGroups(user, pending.Groups)
+	}
+	if !h.issueLoginTokens(c, h.db, user) {
+		return
+	}
+	response.Success(c, "login successful", gin.H{"user": user, "token": user.AuthToken, "refreshToken": user.RefreshToken})
+}
+
+// checkOAuthLoginAllowed applies the same account checks as password login
+func (h *Handlers) checkOAuthLoginAllowed(c *gin.Context, user *models.User, fail func(string, error)) bool {
+	if err := models.CheckUserAllowLogin(h.db, user); err != nil {
+		fail(user.Email, err)
+		return false
+	}
+	if lock, err := models.GetAccountLock(h.db, user.Email, user.ID); err == nil && lock != nil && lock.IsLocked() {
+		fail(user.Email, errors.New("account is locked"))
+		return false
+	}
+	if h.rejectForEnforcedSSO(c, user.Email, user) || h.rejectForSecurityPolicy(c, user) {
+		return false
+	}
+	return true
+}
+
+// finishOAuthLogin scores the login risk, starts the session and records the login, false
+// when aborted. The identity provider's sign-in already satisfies a risk challenge, only
+// blocking applies here
+func (h *Handlers) finishOAuthLogin(c *gin.Context, user *models.User, provider string) bool {
+	clientIP := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	country, city, location := "Unknown", "Unknown", "Unknown"
+	if h.ipLocationService != nil {
+		country, city, location, _ = h.ipLocationService.GetLocation(clientIP)
+	}
+	deviceID := utils.GetDeviceID(userAgent, clientIP)
+	loginType := "oauth_" + provider
+	anomaly := h.scoreLoginAnomaly(c, h.db, user.ID, clientIP, deviceID, country, city)
+	if h.rejectForLoginRisk(c, h.db, user, anomaly, clientIP, location, country, city, userAgent, deviceID, loginType) {
+		return false
+	}
+
+	models.Login(c, user)
+	if c.IsAborted() {
+		return false
+	}
+	isSuspicious := anomaly != nil && anomaly.Suspicious
+	if err := models.RecordLoginHistoryWithAnomaly(h.db, user.ID, user.Email, clientIP, location, country, city, userAgent, deviceID, loginType, true, "", isSuspicious, anomaly); err != nil {
+		logger.Warn("Failed to record login history", zap.Error(err))
+	}
+	return true
+}
+
+// ListMyOAuthIdentities lists the social accounts linked to the current user
+// GET /auth/oauth/identities
+func (h *Handlers) ListMyOAuthIdentities(c *gin.Context) {
+	identities, err := models.ListOAuthIdentities(h.db, models.CurrentUser(c).ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", identities)
+}
+
+// UnlinkMyOAuthIdentity removes a linked social account of the current user
+// DELETE /auth/oauth/identities/:id
+func (h *Handlers) UnlinkMyOAuthIdentity(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid id", nil)
+		return
+	}
+	if err := models.UnlinkOAuthIdentity(h.db, models.CurrentUser(c).ID, uint(id)); err != nil {
+		response.Fail(c, "linked account not found", nil)
+		return
+	}
+	response.Success(c, "unlinked", nil)
+}
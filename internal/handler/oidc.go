@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/oauth"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+const oidcStatePrefix = "oidc_state:"
+
+// oidcPendingLogin state kept between the redirect to the IdP and the callback
+type oidcPendingLogin struct {
+	Redirect string `json:"redirect"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oauth.OIDCProvider
+	oidcConfig   config.OIDCConfig
+)
+
+// currentOIDCProvider returns the configured provider, nil when OIDC is not enabled. The
+// instance is kept across requests so the discovery document and signing keys stay cached
+func currentOIDCProvider() *oauth.OIDCProvider {
+	if config.GlobalConfig == nil || !config.GlobalConfig.Integrations.OIDC.Enabled() {
+		return nil
+	}
+	o := config.GlobalConfig.Integrations.OIDC
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider == nil || oidcConfig != o {
+		p := oauth.NewOIDCProvider(o.Issuer, o.ClientID, o.ClientSecret)
+		if scopes := strings.Fields(o.Scopes); len(scopes) > 0 {
+			p.Scopes = scopes
+		}
+		if o.GroupsClaim != "" {
+			p.GroupsClaim = o.GroupsClaim
+		}
+		p.TrustEmail = o.TrustEmail
+		oidcProvider, oidcConfig = p, o
+	}
+	return oidcProvider
+}
+
+func oidcLoginPath() string {
+	return samlAuthPath() + "/oidc/login"
+}
+
+// oidcCallbackURL is the redirect URI registered at the IdP
+func oidcCallbackURL(c *gin.Context) string {
+	return serverBaseURL(c) + samlAuthPath() + "/oidc/callback"
+}
+
+// OIDCLogin redirects to the IdP's login page
+// GET /auth/oidc/login?redirect=/path
+func (h *Handlers) OIDCLogin(c *gin.Context) {
+	provider := currentOIDCProvider()
+	if provider == nil {
+		response.Fail(c, "single sign-on is not enabled", nil)
+		return
+	}
+	state, err := randomTicket()
+	var nonce string
+	if err == nil {
+		nonce, err = randomTicket()
+	}
+	if err != nil {
+		response.Fail(c, "failed to start login", err.Error())
+		return
+	}
+	pending := oidcPendingLogin{
+		Redirect: safeRedirect(c.Query("redirect")),
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), oauthExchangeTimeout)
+	defer cancel()
+	authURL, err := provider.AuthURL(ctx, state, pending.Nonce, pending.Verifier, oidcCallbackURL(c))
+	if err != nil {
+		logger.Warn("OIDC discovery failed", zap.String("issuer", provider.Issuer), zap.Error(err))
+		response.Fail(c, "single sign-on is unavailable", err.Error())
+		return
+	}
+	value, _ := json.Marshal(pending)
+	if err := cache.GetGlobalCache().Set(c.Request.Context(), oidcStatePrefix+state, string(value), oauthStateTTL); err != nil {
+		response.Fail(c, "failed to start login", err.Error())
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback verifies the IdP's response, provisions the user just in time when enabled,
+// signs the user in and then syncs the organization memberships from the group claim
+// GET /auth/oidc/callback?code=&state=
+func (h *Handlers) OIDCCallback(c *gin.Context) {
+	fail := func(email string, err error) {
+		logger.Warn("OIDC login failed", zap.String("email", email), zap.Error(err))
+		response.Fail(c, "SSO login failed", err.Error())
+	}
+	provider := currentOIDCProvider()
+	if provider == nil {
+		fail("", errors.New("single sign-on is not enabled"))
+		return
+	}
+
+	// Each state can only be consumed once
+	state := c.Query("state")
+	stateCache := cache.GetGlobalCache()
+	value, found := stateCache.Get(c.Request.Context(), oidcStatePrefix+state)
+	if state == "" || !found {
+		fail("", errors.New("unknown or expired login request"))
+		return
+	}
+	_ = stateCache.Delete(c.Request.Context(), oidcStatePrefix+state)
+	var pending oidcPendingLogin
+	raw, _ := value.(string)
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil {
+		fail("", errors.New("invalid login request"))
+		return
+	}
+	if errMsg := c.Query("error"); errMsg != "" {
+		fail("", errors.New("authorization denied: "+errMsg+" "+c.Query("error_description")))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), oauthExchangeTimeout)
+	defer cancel()
+	identity, err := provider.Exchange(ctx, c.Query("code"), oidcCallbackURL(c), pending.Verifier, pending.Nonce)
+	if err != nil {
+		fail("", err)
+		return
+	}
+
+	o := config.GlobalConfig.Integrations.OIDC
+	user, created, err := models.ResolveOAuthUser(h.db, identity, o.JITProvisioning, time.Now())
+	if err != nil {
+		fail(identity.Email, err)
+		return
+	}
+	if created {
+		logger.Info("User provisioned by OIDC login", zap.Uint("userID", user.ID), zap.String("email", user.Email))
+	}
+	if !h.checkOAuthLoginAllowed(c, user, fail) {
+		return
+	}
+
+	// Memberships are only synced once the login has completed, for accounts with
+	// two-factor authentication after OAuthTwoFactor
+	if user.TwoFactorEnabled {
+		ticket := oauthTwoFactorTicket{Provider: oauth.ProviderOIDC, UserID: user.ID, Groups: identity.Groups}
+		h.redirectForTwoFactor(c, ticket, user.Email, pending.Redirect, fail)
+		return
+	}
+	if h.finishOAuthLogin(c, user, oauth.ProviderOIDC) {
+		h.syncOIDCGroups(user, identity.Groups)
+		c.Redirect(http.StatusFound, pending.Redirect)
+	}
+}
+
+// syncOIDCGroups syncs the organization memberships from the group claim of a completed
+// login. The user is already signed in, so failures are only logged
+func (h *Handlers) syncOIDCGroups(user *models.User, groups []string) {
+	if config.GlobalConfig == nil {
+		return
+	}
+	o := config.GlobalConfig.Integrations.OIDC
+	mapping, err := oauth.ParseGroupMapping(o.GroupMapping)
+	if err != nil {
+		logger.Warn("Invalid OIDC group mapping, memberships not synced", zap.Error(err))
+		return
+	}
+	if len(mapping) == 0 {
+		return
+	}
+	if err := models.SyncOIDCGroupMemberships(h.db, user.ID, groups, mapping, o.SyncGroups); err != nil {
+		logger.Error("Failed to sync OIDC group memberships", zap.Uint("userID", user.ID), zap.Error(err))
+	}
+}
@@ -186,6 +186,15 @@ func (h *Handlers) buildOTAResponse(deviceID, clientID string, req *models.Devic
 				appVersion = req.Application.Version
 			}
 			firmware := h.getLatestFirmware(boardType, appVersion)
+			// Firmware rollouts wait for the fleet's maintenance window
+			if firmware.URL != "" {
+				if open, _, err := models.FleetMaintenanceOpen(h.db, device.GroupID, now); err == nil && !open {
+					if err := models.DeferFirmwareUpdate(h.db, device, firmware.Version); err != nil {
+						logger.Warn("Failed to queue deferred firmware update", zap.String("deviceID", deviceID), zap.Error(err))
+					}
+					firmware = &models.Firmware{Version: appVersion, URL: ""}
+				}
+			}
 			resp.Firmware = firmware
 		} else {
 			appVersion := "1.0.0"
@@ -233,6 +242,18 @@ func (h *Handlers) buildOTAResponse(deviceID, clientID string, req *models.Devic
 		// Build MQTT configuration (if configured)
 		// According to xiaozhi-esp32 logic: if MQTT is configured, return MQTT only; otherwise return WebSocket
 		mqttGateway := utils.GetValue(h.db, constants.KEY_SERVER_MQTT_GATEWAY)
+
+		// Devices pinned to a region connect to its endpoints
+		if region := models.PinnedRegion(h.db, device.Region); region != nil {
+			if region.WebsocketURL != "" {
+				wsURL = region.WebsocketURL
+			}
+			if region.MQTTGateway != "" {
+				mqttGateway = region.MQTTGateway
+			}
+			resp.Region = region.Endpoints()
+		}
+
 		if mqttGateway != "" && mqttGateway != "null" {
 			// MQTT is configured, return MQTT configuration (xiaozhi-esp32 behavior)
 			boardType := device.Board
@@ -256,6 +277,13 @@ func (h *Handlers) buildOTAResponse(deviceID, clientID string, req *models.Devic
 				zap.String("deviceID", deviceID),
 				zap.String("websocketURL", wsURL))
 		}
+
+		// Verification keys for signed action manifests, refreshed on every check so rotations reach the device
+		if keys, err := models.PublishedVerificationKeys(h.db, now); err == nil {
+			resp.ActionKeys = keys
+		} else {
+			logger.Warn("Failed to load action manifest verification keys", zap.String("deviceID", deviceID), zap.Error(err))
+		}
 	}
 
 	return resp
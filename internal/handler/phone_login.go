@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho"
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/captcha"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	smsMu       sync.Mutex
+	smsProvider notification.SMSProvider
+)
+
+// smsSender returns the configured SMS provider, created on first use
+func smsSender() (notification.SMSProvider, error) {
+	smsMu.Lock()
+	defer smsMu.Unlock()
+	if smsProvider != nil {
+		return smsProvider, nil
+	}
+	if config.GlobalConfig == nil {
+		return nil, notification.ErrSMSNotConfigured
+	}
+	p, err := notification.NewSMSProvider(config.GlobalConfig.Services.SMS)
+	if err != nil {
+		return nil, err
+	}
+	smsProvider = p
+	return p, nil
+}
+
+// requireSMS writes the error response and returns false when no SMS provider is
+// configured, so SMS codes are never issued that nobody can receive
+func requireSMS(c *gin.Context) bool {
+	if _, err := smsSender(); err != nil {
+		if !errors.Is(err, notification.ErrSMSNotConfigured) {
+			logger.Warn("Failed to create SMS provider", zap.Error(err))
+		}
+		response.Fail(c, "SMS verification is not available", notification.ErrSMSNotConfigured)
+		return false
+	}
+	return true
+}
+
+// issueSMSCode issues a code for the purpose and queues it for SMS delivery. Writes the
+// error response and returns false when the code can't be issued or sent, including when
+// no SMS provider is configured.
+func (h *Handlers) issueSMSCode(c *gin.Context, purpose, phone string) bool {
+	if !requireSMS(c) {
+		return false
+	}
+	codes := utils.GlobalSMSCodes
+	code, err := codes.Issue(purpose, phone, c.ClientIP())
+	if err != nil {
+		abortCodeCooldown(c, err)
+		return false
+	}
+
+	provider, err := smsSender()
+	if err == nil {
+		err = notification.DeliverSMSCode(context.Background(), provider, phone, code)
+	}
+	if err != nil {
+		codes.Revoke(purpose, phone)
+		logger.Warn("Failed to send SMS verification code", zap.String("phone", phone), zap.String("ip", c.ClientIP()), zap.Error(err))
+		if errors.Is(err, notification.ErrSMSInvalidPhone) {
+			response.Fail(c, "Invalid phone number", err)
+		} else {
+			response.Fail(c, "Failed to send verification code", errors.New("failed to send SMS"))
+		}
+		return false
+	}
+	return true
+}
+
+// SendPhoneLoginCode sends a login code to a verified phone number. The response is
+// the same whether or not an account uses the number, so it can't be used to probe
+// for accounts; codes are only sent to numbers that can log in.
+// POST /auth/login/phone/send
+func (h *Handlers) SendPhoneLoginCode(c *gin.Context) {
+	var req struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !requireSMS(c) {
+		return
+	}
+	phone := models.NormalizePhone(req.Phone)
+	if _, err := models.GetUserByPhone(h.db, phone); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "Failed to send verification code", err)
+			return
+		}
+		logger.Info("Phone login code requested for unknown phone", zap.String("phone", phone), zap.String("ip", c.ClientIP()))
+		response.Success(c, "Verification code sent", codeLimits(utils.GlobalSMSCodes))
+		return
+	}
+	if !h.issueSMSCode(c, utils.CodePurposeLogin, phone) {
+		return
+	}
+	response.Success(c, "Verification code sent", codeLimits(utils.GlobalSMSCodes))
+}
+
+// SignInByPhone logs in with a verified phone number and the code sent to it,
+// with the same account checks and two-factor step as password login
+// POST /auth/login/phone
+func (h *Handlers) SignInByPhone(c *gin.Context) {
+	var form models.PhoneLoginForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !requireSMS(c) {
+		return
+	}
+	db := h.db
+	clientIP := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	phone := models.NormalizePhone(form.Phone)
+	security := utils.GlobalLoginSecurityManager
+	recordFailure := func(email string, userID uint) {
+		if security == nil {
+			return
+		}
+		security.RecordFailedLogin(db, email, userID, clientIP, func(db *gorm.DB, email string, userID uint, ipAddress string, failedCount int) error {
+			_, err := models.CreateOrUpdateAccountLock(db, email, userID, ipAddress, failedCount)
+			return err
+		})
+	}
+
+	if security != nil {
+		if err := security.CheckIPRateLimit(clientIP); err != nil {
+			LingEcho.AbortWithJSONError(c, http.StatusTooManyRequests, err)
+			return
+		}
+	}
+	if captcha.Enabled() {
+		if valid, err := captcha.Verify(c.Request.Context(), form.CaptchaID, form.CaptchaCode, clientIP); err != nil || !valid {
+			LingEcho.AbortWithJSONError(c, http.StatusBadRequest, errors.New("invalid captcha code"))
+			return
+		}
+	}
+
+	user, err := models.GetUserByPhone(db, phone)
+	if err != nil {
+		// Unknown numbers get the same answer as wrong codes
+		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, utils.ErrCodeInvalid)
+		return
+	}
+	if security != nil {
+		checkLock := func(db *gorm.DB, email string, userID uint) (*utils.AccountLockInfo, error) {
+			lock, err := models.GetAccountLock(db, email, userID)
+			if err != nil || lock == nil {
+				return nil, err
+			}
+			return &utils.AccountLockInfo{IsLocked: lock.IsLocked(), UnlockAt: lock.UnlockAt}, nil
+		}
+		if err := security.CheckAccountLock(db, user.Email, user.ID, checkLock); err != nil {
+			LingEcho.AbortWithJSONError(c, http.StatusForbidden, err)
+			return
+		}
+	}
+	// The code is only consumed once the two-factor step passes, so a client asked for the
+	// two-factor code can resubmit the same SMS code with it
+	if err := utils.GlobalSMSCodes.Check(utils.CodePurposeLogin, phone, form.Code); err != nil {
+		recordFailure(user.Email, user.ID)
+		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := models.CheckUserAllowLogin(db, user); err != nil {
+		recordFailure(user.Email, user.ID)
+		LingEcho.AbortWithJSONError(c, http.StatusForbidden, err)
+		return
+	}
+	if h.rejectForEnforcedSSO(c, user.Email, user) || h.rejectForSecurityPolicy(c, user) {
+		return
+	}
+	if user.TwoFactorEnabled {
+		if form.TwoFactorCode == "" {
+			response.Success(c, "Two-factor authentication required", gin.H{
+				"requiresTwoFactor": true,
+				"message":           "Please enter your two-factor authentication code",
+			})
+			return
+		}
+		if !h.verifyTwoFactorCode(user, form.TwoFactorCode) {
+			recordFailure(user.Email, user.ID)
+			response.Fail(c, "Invalid two-factor authentication code", errors.New("invalid 2fa code"))
+			return
+		}
+	}
+	if err := utils.GlobalSMSCodes.Verify(utils.CodePurposeLogin, phone, form.Code); err != nil {
+		LingEcho.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	country, city, location := "Unknown", "Unknown", "Unknown"
+	if h.ipLocationService != nil {
+		country, city, location, _ = h.ipLocationService.GetLocation(clientIP)
+	}
+	deviceType, os, browser := utils.ParseUserAgent(userAgent)
+	deviceID := utils.GetDeviceID(userAgent, clientIP)
+	// The SMS code already satisfies a risk challenge, only blocking applies here
+	anomaly := h.scoreLoginAnomaly(c, db, user.ID, clientIP, deviceID, country, city)
+	if h.rejectForLoginRisk(c, db, user, anomaly, clientIP, location, country, city, userAgent, deviceID, "phone") {
+		return
+	}
+	isSuspicious := anomaly != nil && anomaly.Suspicious
+	isTrusted, err := models.CheckDeviceTrust(db, user.ID, deviceID)
+	if err != nil {
+		logger.Warn("Failed to check device trust", zap.Error(err))
+	}
+	if _, err := models.CreateOrUpdateUserDevice(db, user.ID, deviceID, fmt.Sprintf("%s on %s", browser, os), deviceType, os, browser, userAgent, clientIP, location); err != nil {
+		logger.Warn("Failed to create/update user device", zap.Error(err))
+	}
+	if err := models.RecordLoginHistoryWithAnomaly(db, user.ID, user.Email, clientIP, location, country, city, userAgent, deviceID, "phone", true, "", isSuspicious, anomaly); err != nil {
+		logger.Warn("Failed to record login history", zap.Error(err))
+	}
+	if !isTrusted || isSuspicious {
+		utils.Sig().Emit(constants.SigUserNewDeviceLogin, user, map[string]interface{}{
+			"deviceID":     deviceID,
+			"clientIP":     clientIP,
+			"location":     location,
+			"deviceType":   deviceType,
+			"os":           os,
+			"browser":      browser,
+			"isSuspicious": isSuspicious,
+			"loginTime":    time.Now().Format("2006-01-02 15:04:05"),
+		}, db)
+	}
+	if security != nil {
+		security.ClearFailedLoginCount(user.Email)
+	}
+
+	if form.Timezone != "" {
+		models.InTimezone(c, form.Timezone)
+	}
+	models.Login(c, user)
+	if c.IsAborted() {
+		return
+	}
+	if updated, err := models.GetUserByUID(db, user.ID); err == nil {
+		user = updated
+	}
+	if form.AuthToken && !h.issueLoginTokens(c, db, user) {
+		return
+	}
+
+	data := gin.H{
+		"user":         user,
+		"token":        user.AuthToken,
+		"refreshToken": user.RefreshToken,
+	}
+	if isSuspicious {
+		data["suspiciousLogin"] = true
+		data["message"] = "Login from new location or untrusted device detected. Please verify your identity."
+	}
+	response.Success(c, "login success", data)
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/presence"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/websocket"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// MessageTypePresence WebSocket message pushed on presence changes
+const MessageTypePresence = "presence"
+
+var presenceInitOnce sync.Once
+
+// presenceRequest manual presence update
+type presenceRequest struct {
+	Status          presence.Status `json:"status" binding:"required"`
+	Note            string          `json:"note"`
+	DurationMinutes int             `json:"durationMinutes"` // Reverts to available afterwards, 0 keeps it until changed
+}
+
+// initPresence restores manual statuses saved before a restart and pushes every
+// change to the WebSocket connections of the users who can see the subject
+func (h *Handlers) initPresence() {
+	presenceInitOnce.Do(func() {
+		statuses, err := models.ListActivePresenceStatuses(h.db)
+		if err != nil {
+			logger.Warn("Failed to restore presence statuses", zap.Error(err))
+		}
+		for _, s := range statuses {
+			if _, err := presence.Default().Set(s.Kind, s.SubjectID, presence.Status(s.Status), s.Note, s.Until); err != nil {
+				logger.Warn("Skipping invalid presence status", zap.String("kind", s.Kind), zap.String("subject", s.SubjectID), zap.Error(err))
+			}
+		}
+
+		presence.Default().Subscribe(func(state presence.State) {
+			// Changes may come from the SIP signalling path, keep it unblocked
+			go h.pushPresence(state)
+		})
+	})
+}
+
+// pushPresence sends a presence change to the audience of the subject
+func (h *Handlers) pushPresence(state presence.State) {
+	if h.wsHub == nil {
+		return
+	}
+	userIDs, err := h.presenceAudience(state.Kind, state.ID)
+	if err != nil {
+		logger.Warn("Failed to resolve presence audience", zap.String("kind", state.Kind), zap.String("subject", state.ID), zap.Error(err))
+		return
+	}
+	for _, userID := range userIDs {
+		message := &websocket.Message{
+			Type:      MessageTypePresence,
+			Data:      state,
+			Timestamp: time.Now().Unix(),
+			To:        strconv.FormatUint(uint64(userID), 10),
+		}
+		select {
+		case h.wsHub.GetBroadcastChannel() <- message:
+		default:
+			// Presence is soft real-time, drop when the hub is saturated
+		}
+	}
+}
+
+// presenceAudience lists users allowed to see the subject: the subject (or the
+// owner of the SIP agent) and everyone sharing an organization with it
+func (h *Handlers) presenceAudience(kind, id string) ([]uint, error) {
+	switch kind {
+	case presence.KindUser:
+		userID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		return models.GroupPeerUserIDs(h.db, uint(userID))
+	case presence.KindSIP:
+		sipUser, err := models.GetSipUserByUsername(h.db, id)
+		if err != nil {
+			return nil, err
+		}
+		var groupIDs, extra []uint
+		if sipUser.GroupID != nil {
+			groupIDs = append(groupIDs, *sipUser.GroupID)
+		}
+		if sipUser.UserID != nil {
+			extra = append(extra, *sipUser.UserID)
+		}
+		return models.GroupUserIDs(h.db, groupIDs, extra...)
+	}
+	return nil, presence.ErrInvalidKind
+}
+
+// GetMyPresence gets the presence of the current user and of the SIP agents they own
+// GET /presence/me
+func (h *Handlers) GetMyPresence(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	sipUsers, err := models.GetSipUsersByUserID(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	usernames := make([]string, 0, len(sipUsers))
+	for _, su := range sipUsers {
+		usernames = append(usernames, su.Username)
+	}
+
+	response.Success(c, "success", gin.H{
+		"user":   presence.Default().Get(presence.KindUser, strconv.FormatUint(uint64(user.ID), 10)),
+		"agents": presence.Default().List(presence.KindSIP, usernames),
+	})
+}
+
+// SetMyPresence sets the presence of the current user
+// PUT /presence/me
+func (h *Handlers) SetMyPresence(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	h.setPresence(c, presence.KindUser, strconv.FormatUint(uint64(user.ID), 10))
+}
+
+// SetAgentPresence sets the presence of a SIP agent, allowed for its owner and
+// the admins of its organization
+// PUT /presence/sip/:username
+func (h *Handlers) SetAgentPresence(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	sipUser, err := models.GetSipUserByUsername(h.db, c.Param("username"))
+	if err != nil {
+		response.Fail(c, "SIP agent not found", nil)
+		return
+	}
+	allowed := sipUser.UserID != nil && *sipUser.UserID == user.ID
+	if !allowed && sipUser.GroupID != nil {
+		var group models.Group
+		if h.db.First(&group, *sipUser.GroupID).Error == nil {
+			allowed = models.IsGroupAdmin(h.db, &group, user.ID)
+		}
+	}
+	if !allowed {
+		response.Fail(c, "forbidden", "No permission to change this agent's presence")
+		return
+	}
+	h.setPresence(c, presence.KindSIP, sipUser.Username)
+}
+
+// GetGroupPresence lists the presence of the members and SIP agents of an organization
+// GET /presence/group/:id
+func (h *Handlers) GetGroupPresence(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+
+	var group models.Group
+	if err := h.db.First(&group, c.Param("id")).Error; err != nil {
+		response.Fail(c, "organization not found", nil)
+		return
+	}
+	if !models.IsGroupMember(h.db, &group, user.ID) {
+		response.Fail(c, "forbidden", "Not a member of this organization")
+		return
+	}
+
+	userIDs, err := models.GroupUserIDs(h.db, []uint{group.ID})
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	ids := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+
+	sipUsers, err := models.GetSipUsersByGroupID(h.db, group.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	usernames := make([]string, 0, len(sipUsers))
+	for _, su := range sipUsers {
+		usernames = append(usernames, su.Username)
+	}
+
+	response.Success(c, "success", gin.H{
+		"users":  presence.Default().List(presence.KindUser, ids),
+		"agents": presence.Default().List(presence.KindSIP, usernames),
+	})
+}
+
+// setPresence applies and persists a manual presence update
+func (h *Handlers) setPresence(c *gin.Context, kind, id string) {
+	var req presenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if !presence.ValidStatus(req.Status) {
+		response.Fail(c, "invalid status", "status must be available, busy, dnd or offline")
+		return
+	}
+	if req.DurationMinutes < 0 {
+		response.Fail(c, "invalid duration", nil)
+		return
+	}
+
+	var until *time.Time
+	if req.DurationMinutes > 0 {
+		t := time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+		until = &t
+	}
+
+	if err := models.SavePresenceStatus(h.db, &models.PresenceStatus{
+		Kind:      kind,
+		SubjectID: id,
+		Status:    string(req.Status),
+		Note:      req.Note,
+		Until:     until,
+	}); err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+
+	state, err := presence.Default().Set(kind, id, req.Status, req.Note, until)
+	if err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	response.Success(c, "presence updated", state)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultProviderIncidentDays = 7
+	maxProviderIncidentDays     = 90
+)
+
+// ListProviderIncidents returns the daily timeout, error and failover counters of the
+// LLM and TTS providers used by AI calls
+// GET /sip/provider-incidents?days=7
+func (h *Handlers) ListProviderIncidents(c *gin.Context) {
+	days := defaultProviderIncidentDays
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxProviderIncidentDays {
+			response.Fail(c, "invalid request", "days must be between 1 and 90")
+			return
+		}
+		days = n
+	}
+	since := time.Now().AddDate(0, 0, -(days - 1))
+	incidents, err := models.ListProviderIncidents(h.db, since)
+	if err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"days":      days,
+		"incidents": incidents,
+	})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPushDevice registers the FCM or APNs token of the mobile app. The
+// app calls it after login and whenever the platform rotates the token
+// POST /notification/push-devices
+func (h *Handlers) RegisterPushDevice(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	var req struct {
+		Platform   string `json:"platform" binding:"required"` // android, ios
+		Token      string `json:"token" binding:"required"`
+		DeviceName string `json:"deviceName"`
+		AppVersion string `json:"appVersion"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	device, err := models.RegisterPushDeviceToken(h.db, user.ID, req.Platform, req.Token, req.DeviceName, req.AppVersion)
+	if err != nil {
+		response.Fail(c, "register failed", err.Error())
+		return
+	}
+	response.Success(c, "registered", device)
+}
+
+// ListPushDevices lists the mobile devices registered for push notifications
+// GET /notification/push-devices
+func (h *Handlers) ListPushDevices(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	devices, err := models.ListPushDeviceTokens(h.db, user.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"devices": devices, "pushNotifications": user.PushNotifications})
+}
+
+// DeletePushDevice unregisters a push device, by ID from the device list or by
+// ?token= when the app logs out
+// DELETE /notification/push-devices/:id
+// DELETE /notification/push-devices?token=
+func (h *Handlers) DeletePushDevice(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	token := c.Query("token")
+	var id uint
+	if token == "" {
+		parsed, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			response.Fail(c, "invalid id", nil)
+			return
+		}
+		id = uint(parsed)
+	}
+	deleted, err := models.DeletePushDeviceToken(h.db, user.ID, id, token)
+	if err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	if !deleted {
+		response.Fail(c, "push device not found", nil)
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordingFetchClient downloads remote recordings for hash verification
+var recordingFetchClient = &http.Client{Timeout: 5 * time.Minute}
+
+// openRecordingAudio opens the stored recording, either a remote storage URL or a local file
+func openRecordingAudio(storageURL string) (io.ReadCloser, error) {
+	if storageURL == "" {
+		return nil, errors.New("recording has no stored audio")
+	}
+	if !strings.HasPrefix(storageURL, "http://") && !strings.HasPrefix(storageURL, "https://") {
+		return os.Open(storageURL)
+	}
+	resp, err := recordingFetchClient.Get(storageURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("storage returned status code %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// VerifyCallRecording compares the current recording file hash with the one stored at finalization
+// POST /device/call-recordings/:id/verify
+func (h *Handlers) VerifyCallRecording(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	recordingID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid recording id", nil)
+		return
+	}
+	recording, err := models.GetCallRecordingByID(h.db, user.ID, uint(recordingID))
+	if err != nil {
+		response.Fail(c, "recording not found", nil)
+		return
+	}
+	if recording.AudioSHA256 == "" {
+		response.Fail(c, "recording has no stored hash", "recordings finalized before hashing was enabled cannot be verified")
+		return
+	}
+
+	audio, err := openRecordingAudio(recording.StorageURL)
+	if err != nil {
+		response.Fail(c, "failed to read recording audio", err.Error())
+		return
+	}
+	defer audio.Close()
+
+	check, err := models.CheckCallRecordingHash(h.db, recording, audio)
+	if err != nil {
+		response.Fail(c, "verification failed", err.Error())
+		return
+	}
+	response.Success(c, "success", check)
+}
+
+// ListRecordingDigests lists daily recording digests (admin)
+// GET /recording-digests
+func (h *Handlers) ListRecordingDigests(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	digests, total, err := models.ListRecordingDigests(h.db, size, (page-1)*size)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"digests": digests, "total": total, "page": page, "size": size})
+}
+
+// GetRecordingDigest returns the stored digest of a day and whether it still matches
+// the recording hashes currently in the database (admin)
+// GET /recording-digests/:date
+func (h *Handlers) GetRecordingDigest(c *gin.Context) {
+	digest, err := models.GetRecordingDigest(h.db, c.Param("date"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, "digest not found", nil)
+		return
+	}
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	current, err := models.ComputeRecordingDigest(h.db, digest.Date)
+	if err != nil {
+		response.Fail(c, "failed to recompute digest", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"digest":        digest,
+		"currentDigest": current.Digest,
+		"consistent":    current.Digest == digest.Digest && current.PrevDigest == digest.PrevDigest,
+	})
+}
+
+// AnchorRecordingDigest creates the digest of a past day if missing and anchors it
+// in the configured notary service (admin)
+// POST /recording-digests/:date/anchor
+func (h *Handlers) AnchorRecordingDigest(c *gin.Context) {
+	date := c.Param("date")
+	day, err := time.ParseInLocation(models.RecordingDigestDateLayout, date, time.Local)
+	if err != nil {
+		response.Fail(c, "invalid date", "use YYYY-MM-DD")
+		return
+	}
+	if !day.AddDate(0, 0, 1).Before(time.Now()) {
+		response.Fail(c, "the day is not over yet", nil)
+		return
+	}
+	notary := config.GlobalConfig.Services.Notary
+	if notary.URL == "" {
+		response.Fail(c, "notary service is not configured", "set RECORDING_NOTARY_URL")
+		return
+	}
+
+	digest, err := models.CreateRecordingDigest(h.db, date)
+	if err != nil {
+		response.Fail(c, "failed to create digest", err.Error())
+		return
+	}
+	if err := models.AnchorRecordingDigest(c.Request.Context(), h.db, digest, notary.URL, notary.Secret); err != nil {
+		response.Fail(c, "anchoring failed", err.Error())
+		return
+	}
+	digest, err = models.GetRecordingDigest(h.db, date)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", digest)
+}
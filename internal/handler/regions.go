@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// regionRequest body of region create and update; the code comes from the path on update
+type regionRequest struct {
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	SIPHost      string `json:"sipHost"`
+	SIPPort      int    `json:"sipPort"`
+	RTPHost      string `json:"rtpHost"`
+	WebsocketURL string `json:"websocketUrl"`
+	MQTTGateway  string `json:"mqttGateway"`
+	Enabled      *bool  `json:"enabled"`
+}
+
+// apply copies the request onto the region
+func (req *regionRequest) apply(region *models.Region) {
+	region.Name = req.Name
+	region.SIPHost = req.SIPHost
+	region.SIPPort = req.SIPPort
+	region.RTPHost = req.RTPHost
+	region.WebsocketURL = req.WebsocketURL
+	region.MQTTGateway = req.MQTTGateway
+	if req.Enabled != nil {
+		region.Enabled = *req.Enabled
+	}
+}
+
+// ListRegions lists the regions devices and SIP users can be pinned to, with
+// the region of this instance; admins also see disabled regions with all=true
+// GET /regions
+func (h *Handlers) ListRegions(c *gin.Context) {
+	enabledOnly := !(c.Query("all") == "true" && models.CurrentUser(c).IsAdmin())
+	regions, err := models.ListRegions(h.db, enabledOnly)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"regions": regions, "local": models.LocalRegion()})
+}
+
+// CreateRegion creates a region
+// POST /regions
+func (h *Handlers) CreateRegion(c *gin.Context) {
+	var req regionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	region := &models.Region{Code: req.Code, Enabled: true}
+	req.apply(region)
+	if err := region.Validate(); err != nil {
+		response.Fail(c, "invalid region", err.Error())
+		return
+	}
+	var count int64
+	h.db.Model(&models.Region{}).Where("code = ?", region.Code).Count(&count)
+	if count > 0 {
+		response.Fail(c, "region already exists", region.Code)
+		return
+	}
+	if err := h.db.Create(region).Error; err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	response.Success(c, "created", region)
+}
+
+// UpdateRegion replaces the endpoints of a region; disabling it makes pinned
+// devices and SIP users fall back to the instance they reach
+// PUT /regions/:code
+func (h *Handlers) UpdateRegion(c *gin.Context) {
+	var region models.Region
+	if err := h.db.Where("code = ?", c.Param("code")).First(&region).Error; err != nil {
+		response.Fail(c, "region not found", nil)
+		return
+	}
+	var req regionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	req.apply(&region)
+	if err := region.Validate(); err != nil {
+		response.Fail(c, "invalid region", err.Error())
+		return
+	}
+	if err := h.db.Save(&region).Error; err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "updated", region)
+}
+
+// DeleteRegion deletes a region nothing is pinned to
+// DELETE /regions/:code
+func (h *Handlers) DeleteRegion(c *gin.Context) {
+	code := c.Param("code")
+	if pinned := models.RegionPinCount(h.db, code); pinned > 0 {
+		response.Fail(c, "region in use", fmt.Sprintf("%d devices or SIP users are pinned to it", pinned))
+		return
+	}
+	result := h.db.Where("code = ?", code).Delete(&models.Region{})
+	if result.Error != nil {
+		response.Fail(c, "delete failed", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Fail(c, "region not found", nil)
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// GetRegionCallStats counts the SIP calls handled by each region and handed
+// off between regions over the last days (default 7, at most 90); admins see
+// all calls, other users their own
+// GET /sip/calls/regions
+func (h *Handlers) GetRegionCallStats(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days < 1 || days > 90 {
+		days = 7
+	}
+	user := models.CurrentUser(c)
+	var userID *uint
+	if !user.IsAdmin() {
+		userID = &user.ID
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	stats, err := models.GetRegionCallStats(h.db, userID, since)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"stats": stats, "since": since, "days": days})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/middleware"
+)
+
+// Response cache namespaces of the heavy read endpoints, see registerXxxRoutes
+const (
+	responseCacheDevices    = "devices"
+	responseCacheAssistants = "assistants"
+	responseCacheKnowledge  = "knowledge"
+
+	responseCacheDevicesTTL    = 30 * time.Second // Lists carry online status, keep short
+	responseCacheAssistantsTTL = 5 * time.Minute
+	responseCacheKnowledgeTTL  = 5 * time.Minute
+)
+
+// invalidateDeviceCache drops cached device lists of the owner and, for
+// organization-shared devices, of every member of the organization
+func (h *Handlers) invalidateDeviceCache(userID uint, groupID *uint) {
+	middleware.InvalidateResponseCache(context.Background(), responseCacheDevices, h.responseCacheUsers(userID, groupID)...)
+}
+
+// invalidateKnowledgeCache drops cached knowledge base lists and contents of the
+// owner and, for organization-shared knowledge bases, of the organization members
+func (h *Handlers) invalidateKnowledgeCache(userID uint, groupID *uint) {
+	middleware.InvalidateResponseCache(context.Background(), responseCacheKnowledge, h.responseCacheUsers(userID, groupID)...)
+}
+
+// invalidateAssistantCache drops cached assistant lists of the owner and, for
+// organization-shared assistants, of every member of the organization
+func (h *Handlers) invalidateAssistantCache(userID uint, groupID *uint) {
+	middleware.InvalidateResponseCache(context.Background(), responseCacheAssistants, h.responseCacheUsers(userID, groupID)...)
+}
+
+// invalidateSharedListCaches drops every cached list that includes organization-shared
+// resources, used when users join or leave an organization
+func (h *Handlers) invalidateSharedListCaches(userIDs ...uint) {
+	for _, namespace := range []string{responseCacheDevices, responseCacheAssistants, responseCacheKnowledge} {
+		middleware.InvalidateResponseCache(context.Background(), namespace, userIDs...)
+	}
+}
+
+// responseCacheUsers lists the users whose lists include a resource of the owner
+// shared with the organization
+func (h *Handlers) responseCacheUsers(userID uint, groupID *uint) []uint {
+	userIDs := []uint{userID}
+	if groupID == nil {
+		return userIDs
+	}
+	var memberIDs []uint
+	h.db.Model(&models.GroupMember{}).Where("group_id = ?", *groupID).Pluck("user_id", &memberIDs)
+	userIDs = append(userIDs, memberIDs...)
+	var group models.Group
+	if h.db.Select("creator_id").First(&group, *groupID).Error == nil {
+		userIDs = append(userIDs, group.CreatorID)
+	}
+	return userIDs
+}
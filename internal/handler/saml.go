@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/saml"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	samlRequestPrefix   = "saml_request:"
+	samlAssertionPrefix = "saml_assertion:"
+	samlRequestTTL      = 10 * time.Minute
+
+	// samlPortalDeviceClaim signs the user in to the device claim portal instead of the console
+	samlPortalDeviceClaim = "device-claim"
+)
+
+// samlPendingRequest state kept between the AuthnRequest and the ACS callback
+type samlPendingRequest struct {
+	GroupID  uint   `json:"groupId"`
+	Redirect string `json:"redirect"`
+	Portal   string `json:"portal,omitempty"`
+}
+
+// updateSamlConnectionRequest organization admins configure the IdP
+type updateSamlConnectionRequest struct {
+	Enabled          bool                        `json:"enabled"`
+	MetadataXML      string                      `json:"metadataXml"` // Fills the IdP fields below when set
+	IdPEntityID      string                      `json:"idpEntityId"`
+	IdPSSOURL        string                      `json:"idpSsoUrl"`
+	IdPCertificate   string                      `json:"idpCertificate"`
+	Mapping          models.SamlAttributeMapping `json:"mapping"`
+	JITProvisioning  bool                        `json:"jitProvisioning"`
+	EnforceSSO       bool                        `json:"enforceSso"`
+	TechnicianPortal bool                        `json:"technicianPortal"`
+}
+
+// updateSamlDomainsRequest system admins claim email domains for an organization
+type updateSamlDomainsRequest struct {
+	Domains []string `json:"domains"`
+}
+
+// serverBaseURL is SERVER_URL, or the scheme and host of the request when unset
+func serverBaseURL(c *gin.Context) string {
+	base := strings.TrimRight(config.GlobalConfig.Server.URL, "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base
+}
+
+// samlSPBase is the per-organization SAML prefix; the entity ID and ACS URL derive from it,
+// so SERVER_URL should be set when the API is reachable under several hosts
+func samlSPBase(c *gin.Context, groupID uint) string {
+	return fmt.Sprintf("%s%s/saml/%d", serverBaseURL(c), samlAuthPath(), groupID)
+}
+
+func samlAuthPath() string {
+	return config.GlobalConfig.Server.APIPrefix + config.GlobalConfig.Server.AuthPrefix
+}
+
+// samlLoginPath is the relative URL that starts SP-initiated login for an organization
+func samlLoginPath(groupID uint) string {
+	return fmt.Sprintf("%s/saml/%d/login", samlAuthPath(), groupID)
+}
+
+// safeRedirect only allows same-site relative paths
+func safeRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+func (h *Handlers) recordSSOEvent(c *gin.Context, event *models.SSOEvent) {
+	event.IPAddress = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	if err := models.RecordSSOEvent(h.db, event); err != nil {
+		logger.Warn("Failed to record SSO event", zap.String("event", event.Event), zap.Error(err))
+	}
+}
+
+// rejectForEnforcedSSO stops password and code logins for domains that must use SSO
+func (h *Handlers) rejectForEnforcedSSO(c *gin.Context, email string, user *models.User) bool {
+	conn := models.SamlEnforcedFor(h.db, email, user)
+	if conn == nil {
+		return false
+	}
+	event := &models.SSOEvent{GroupID: conn.GroupID, Email: strings.ToLower(email), Event: models.SSOEventPasswordBlocked, Detail: c.FullPath()}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	h.recordSSOEvent(c, event)
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"code":        http.StatusForbidden,
+		"msg":         "single sign-on is required for this account",
+		"requiresSSO": true,
+		"loginUrl":    samlLoginPath(conn.GroupID),
+	})
+	return true
+}
+
+// SamlDiscover tells the login page whether an email must or can use SSO
+// GET /auth/saml/discover?email=
+func (h *Handlers) SamlDiscover(c *gin.Context) {
+	conn := models.FindSamlConnectionForEmail(h.db, c.Query("email"))
+	if conn == nil {
+		response.Success(c, "success", gin.H{"sso": false})
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"sso":      true,
+		"enforced": conn.EnforceSSO,
+		"groupId":  conn.GroupID,
+		"loginUrl": samlLoginPath(conn.GroupID),
+	})
+}
+
+func (h *Handlers) loadEnabledSamlConnection(c *gin.Context) (*models.SamlConnection, bool) {
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid organization id", nil)
+		return nil, false
+	}
+	conn, err := models.GetSamlConnection(h.db, uint(groupID))
+	if err != nil || !conn.Enabled {
+		response.Fail(c, "SSO is not enabled for this organization", nil)
+		return nil, false
+	}
+	return conn, true
+}
+
+// SamlMetadata serves the service provider metadata to import into the IdP
+// GET /auth/saml/:groupId/metadata
+func (h *Handlers) SamlMetadata(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("groupId"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid organization id", nil)
+		return
+	}
+	spBase := samlSPBase(c, uint(groupID))
+	c.Data(http.StatusOK, "application/samlmetadata+xml", saml.SPMetadata(spBase+"/metadata", spBase+"/acs"))
+}
+
+// SamlLogin starts SP-initiated login by redirecting to the IdP; with ?portal=device-claim the
+// user gets a technician token for the device claim portal instead of a console session
+// GET /auth/saml/:groupId/login?redirect=/path
+func (h *Handlers) SamlLogin(c *gin.Context) {
+	conn, ok := h.loadEnabledSamlConnection(c)
+	if !ok {
+		return
+	}
+	portal := c.Query("portal")
+	if portal != "" && (portal != samlPortalDeviceClaim || !conn.TechnicianPortal) {
+		response.Fail(c, "the device claim portal is not enabled for this organization", nil)
+		return
+	}
+	sp, err := conn.ServiceProvider(samlSPBase(c, conn.GroupID))
+	if err != nil {
+		response.Fail(c, "invalid SSO configuration", err.Error())
+		return
+	}
+
+	requestID := saml.NewRequestID()
+	state, _ := json.Marshal(samlPendingRequest{GroupID: conn.GroupID, Redirect: safeRedirect(c.Query("redirect")), Portal: portal})
+	if err := cache.GetGlobalCache().Set(c.Request.Context(), samlRequestPrefix+requestID, string(state), samlRequestTTL); err != nil {
+		response.Fail(c, "failed to start SSO login", err.Error())
+		return
+	}
+	target, err := sp.AuthnRequestURL(requestID, requestID)
+	if err != nil {
+		response.Fail(c, "failed to start SSO login", err.Error())
+		return
+	}
+	h.recordSSOEvent(c, &models.SSOEvent{GroupID: conn.GroupID, Event: models.SSOEventLoginStarted, Success: true})
+	c.Redirect(http.StatusFound, target)
+}
+
+// SamlACS consumes the IdP response (HTTP-POST binding), signs the user in and redirects
+// POST /auth/saml/:groupId/acs
+func (h *Handlers) SamlACS(c *gin.Context) {
+	conn, ok := h.loadEnabledSamlConnection(c)
+	if !ok {
+		return
+	}
+	fail := func(email string, err error) {
+		h.recordSSOEvent(c, &models.SSOEvent{GroupID: conn.GroupID, Email: email, Event: models.SSOEventLoginFailed, Detail: err.Error()})
+		logger.Warn("SAML login failed", zap.Uint("groupID", conn.GroupID), zap.String("email", email), zap.Error(err))
+		response.Fail(c, "SSO login failed", err.Error())
+	}
+
+	// RelayState carries the request ID; each request can only be consumed once
+	requestID := c.PostForm("RelayState")
+	stateCache := cache.GetGlobalCache()
+	value, found := stateCache.Get(c.Request.Context(), samlRequestPrefix+requestID)
+	if requestID == "" || !found {
+		fail("", errors.New("unknown or expired SSO request"))
+		return
+	}
+	_ = stateCache.Delete(c.Request.Context(), samlRequestPrefix+requestID)
+	var pending samlPendingRequest
+	raw, _ := value.(string)
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil || pending.GroupID != conn.GroupID {
+		fail("", errors.New("SSO request does not belong to this organization"))
+		return
+	}
+
+	sp, err := conn.ServiceProvider(samlSPBase(c, conn.GroupID))
+	if err != nil {
+		fail("", err)
+		return
+	}
+	assertion, err := sp.ParseResponse(c.PostForm("SAMLResponse"), requestID)
+	if err != nil {
+		fail("", err)
+		return
+	}
+	email := conn.SamlAssertionEmail(assertion)
+	replayKey := samlAssertionPrefix + conn.IdPEntityID + ":" + assertion.ID
+	if stateCache.Exists(c.Request.Context(), replayKey) {
+		fail(email, errors.New("assertion has already been used"))
+		return
+	}
+	_ = stateCache.Set(c.Request.Context(), replayKey, "1", time.Until(assertion.NotOnOrAfter)+saml.DefaultClockSkew)
+
+	user, created, err := models.ProvisionSamlUser(h.db, conn, assertion)
+	if err != nil {
+		fail(email, err)
+		return
+	}
+	if created {
+		h.recordSSOEvent(c, &models.SSOEvent{GroupID: conn.GroupID, UserID: user.ID, Email: user.Email, Event: models.SSOEventUserProvisioned, Success: true})
+	}
+	if err := models.CheckUserAllowLogin(h.db, user); err != nil {
+		fail(email, err)
+		return
+	}
+	if pending.Portal == samlPortalDeviceClaim {
+		h.issueTechnicianToken(c, conn, user, pending.Redirect, fail)
+		return
+	}
+
+	clientIP := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	country, city, location := "Unknown", "Unknown", "Unknown"
+	if h.ipLocationService != nil {
+		country, city, location, _ = h.ipLocationService.GetLocation(clientIP)
+	}
+	deviceID := utils.GetDeviceID(userAgent, clientIP)
+	// The IdP's sign-in already satisfies a risk challenge, only blocking applies here
+	anomaly := h.scoreLoginAnomaly(c, h.db, user.ID, clientIP, deviceID, country, city)
+	if h.rejectForLoginRisk(c, h.db, user, anomaly, clientIP, location, country, city, userAgent, deviceID, "saml") {
+		h.recordSSOEvent(c, &models.SSOEvent{GroupID: conn.GroupID, UserID: user.ID, Email: user.Email, Event: models.SSOEventLoginFailed, Detail: "blocked by login risk"})
+		return
+	}
+
+	models.Login(c, user)
+	if c.IsAborted() {
+		return
+	}
+	isSuspicious := anomaly != nil && anomaly.Suspicious
+	if err := models.RecordLoginHistoryWithAnomaly(h.db, user.ID, user.Email, clientIP, location, country, city, userAgent, deviceID, "saml", true, "", isSuspicious, anomaly); err != nil {
+		logger.Warn("Failed to record login history", zap.Error(err))
+	}
+	h.recordSSOEvent(c, &models.SSOEvent{GroupID: conn.GroupID, UserID: user.ID, Email: user.Email, Event: models.SSOEventLoginSucceeded, Success: true, Detail: assertion.SessionIndex})
+	c.Redirect(http.StatusFound, pending.Redirect)
+}
+
+// samlConnectionView connection with the values to enter in the IdP
+func (h *Handlers) samlConnectionView(c *gin.Context, group *models.Group, conn *models.SamlConnection) gin.H {
+	spBase := samlSPBase(c, group.ID)
+	view := gin.H{
+		"sp": gin.H{
+			"entityId":    spBase + "/metadata",
+			"acsUrl":      spBase + "/acs",
+			"metadataUrl": spBase + "/metadata",
+			"loginUrl":    samlLoginPath(group.ID),
+		},
+		"defaultMapping": models.DefaultSamlAttributeMapping,
+		"connection":     nil,
+	}
+	if conn != nil {
+		view["connection"] = conn
+		view["mapping"] = conn.Mapping()
+	}
+	return view
+}
+
+// GetSamlConnection returns the organization's SAML configuration
+// GET /group/:id/saml
+func (h *Handlers) GetSamlConnection(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	conn, err := models.GetSamlConnection(h.db, group.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", h.samlConnectionView(c, group, conn))
+}
+
+// UpdateSamlConnection creates or replaces the organization's IdP configuration
+// PUT /group/:id/saml
+func (h *Handlers) UpdateSamlConnection(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	var req updateSamlConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+
+	conn, err := models.GetSamlConnection(h.db, group.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		conn = &models.SamlConnection{GroupID: group.ID}
+	} else if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	conn.IdPEntityID = strings.TrimSpace(req.IdPEntityID)
+	conn.IdPSSOURL = strings.TrimSpace(req.IdPSSOURL)
+	conn.IdPCertificate = strings.TrimSpace(req.IdPCertificate)
+	if req.MetadataXML != "" {
+		if err := conn.ApplyMetadata([]byte(req.MetadataXML)); err != nil {
+			response.Fail(c, "invalid IdP metadata", err.Error())
+			return
+		}
+	}
+	if err := conn.Validate(); err != nil {
+		response.Fail(c, "invalid SSO configuration", err.Error())
+		return
+	}
+	if err := conn.SetMapping(req.Mapping); err != nil {
+		response.Fail(c, "invalid attribute mapping", err.Error())
+		return
+	}
+	conn.Enabled = req.Enabled
+	conn.JITProvisioning = req.JITProvisioning
+	conn.EnforceSSO = req.EnforceSSO
+	conn.TechnicianPortal = req.TechnicianPortal
+	conn.UpdatedBy = models.CurrentUser(c).ID
+	if err := h.db.Save(conn).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+
+	h.recordSSOEvent(c, &models.SSOEvent{
+		GroupID: group.ID, UserID: conn.UpdatedBy, Event: models.SSOEventConfigUpdated, Success: true,
+		Detail: fmt.Sprintf("enabled=%t jit=%t enforce=%t portal=%t idp=%s", conn.Enabled, conn.JITProvisioning, conn.EnforceSSO, conn.TechnicianPortal, conn.IdPEntityID),
+	})
+	response.Success(c, "saved", h.samlConnectionView(c, group, conn))
+}
+
+// DeleteSamlConnection removes the organization's SAML configuration
+// DELETE /group/:id/saml
+func (h *Handlers) DeleteSamlConnection(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	if err := h.db.Where("group_id = ?", group.ID).Delete(&models.SamlConnection{}).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	h.recordSSOEvent(c, &models.SSOEvent{GroupID: group.ID, UserID: models.CurrentUser(c).ID, Event: models.SSOEventConfigDeleted, Success: true})
+	response.Success(c, "deleted", nil)
+}
+
+// UpdateSamlDomains claims the email domains routed to the organization's IdP (system admins only,
+// since a claimed domain lets the organization's IdP sign in every user of that domain)
+// PUT /group/:id/saml/domains
+func (h *Handlers) UpdateSamlDomains(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid organization id", nil)
+		return
+	}
+	var req updateSamlDomainsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	conn, err := models.GetSamlConnection(h.db, uint(groupID))
+	if err != nil {
+		response.Fail(c, "configure the organization's IdP first", nil)
+		return
+	}
+	if err := conn.SetDomains(req.Domains); err != nil {
+		response.Fail(c, "invalid domains", err.Error())
+		return
+	}
+	if err := models.CheckSamlDomainsAvailable(h.db, conn.GroupID, conn.DomainList()); err != nil {
+		response.Fail(c, "domain conflict", err.Error())
+		return
+	}
+	if err := h.db.Model(conn).Update("domains", conn.Domains).Error; err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	h.recordSSOEvent(c, &models.SSOEvent{GroupID: conn.GroupID, UserID: models.CurrentUser(c).ID, Event: models.SSOEventDomainsUpdated, Success: true, Detail: conn.Domains})
+	response.Success(c, "saved", conn)
+}
+
+// ListSSOEvents lists the organization's SSO audit events
+// GET /group/:id/saml/events
+func (h *Handlers) ListSSOEvents(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	q := h.db.Model(&models.SSOEvent{}).Where("group_id = ?", group.ID)
+	if event := c.Query("event"); event != "" {
+		q = q.Where("event = ?", event)
+	}
+	if email := c.Query("email"); email != "" {
+		q = q.Where("email = ?", strings.ToLower(email))
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	var events []models.SSOEvent
+	if err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&events).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"events": events, "total": total, "page": page, "size": size})
+}
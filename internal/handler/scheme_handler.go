@@ -38,6 +38,10 @@ type CreateSchemeRequest struct {
 	MessageDuration  int                   `json:"messageDuration"`
 	MessagePrompt    string                `json:"messagePrompt"`
 	BoundPhoneNumber string                `json:"boundPhoneNumber"`
+
+	// 静音抑制，阈值为 dBov（-90 ~ -20），0 使用默认值 -50
+	SilenceSuppression bool `json:"silenceSuppression"`
+	SilenceThreshold   int  `json:"silenceThreshold"`
 }
 
 // UpdateSchemeRequest 更新方案请求
@@ -58,6 +62,10 @@ type UpdateSchemeRequest struct {
 	MessagePrompt    *string                `json:"messagePrompt"`
 	BoundPhoneNumber *string                `json:"boundPhoneNumber"`
 	Enabled          *bool                  `json:"enabled"`
+	Region           *string                `json:"region"` // 固定的部署地区，空字符串表示取消固定
+
+	SilenceSuppression *bool `json:"silenceSuppression"`
+	SilenceThreshold   *int  `json:"silenceThreshold"`
 }
 
 // ListSchemes 获取方案列表
@@ -167,6 +175,9 @@ func (h *SchemeHandler) CreateScheme(c *gin.Context) {
 		Enabled:          true,
 	}
 
+	scheme.SilenceSuppression = req.SilenceSuppression
+	scheme.SilenceThreshold = req.SilenceThreshold
+
 	// 设置默认值
 	if scheme.MessageDuration == 0 {
 		scheme.MessageDuration = 20
@@ -174,6 +185,10 @@ func (h *SchemeHandler) CreateScheme(c *gin.Context) {
 	if scheme.RecordingMode == "" {
 		scheme.RecordingMode = models.RecordingModeFull
 	}
+	if err := scheme.NormalizeSilenceThreshold(); err != nil {
+		response.Fail(c, "静音阈值无效", err.Error())
+		return
+	}
 
 	if err := models.CreateSipUser(h.db, scheme); err != nil {
 		response.Fail(c, "创建方案失败", err.Error())
@@ -270,9 +285,26 @@ func (h *SchemeHandler) UpdateScheme(c *gin.Context) {
 	if req.BoundPhoneNumber != nil {
 		scheme.BoundPhoneNumber = *req.BoundPhoneNumber
 	}
+	if req.SilenceSuppression != nil {
+		scheme.SilenceSuppression = *req.SilenceSuppression
+	}
+	if req.SilenceThreshold != nil {
+		scheme.SilenceThreshold = *req.SilenceThreshold
+		if err := scheme.NormalizeSilenceThreshold(); err != nil {
+			response.Fail(c, "静音阈值无效", err.Error())
+			return
+		}
+	}
 	if req.Enabled != nil {
 		scheme.Enabled = *req.Enabled
 	}
+	if req.Region != nil {
+		if err := models.ValidateRegionPin(h.db, *req.Region); err != nil {
+			response.Fail(c, "地区不存在或已停用", err.Error())
+			return
+		}
+		scheme.Region = *req.Region
+	}
 
 	if err := models.UpdateSipUser(h.db, scheme); err != nil {
 		response.Fail(c, "更新方案失败", err.Error())
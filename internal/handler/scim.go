@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/scim"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// createScimTokenRequest creates a bearer token for an identity provider
+type createScimTokenRequest struct {
+	Name      string                      `json:"name" binding:"required"`
+	GroupID   *uint                       `json:"groupId"`   // Scope the token to an organization
+	ExpiresIn int                         `json:"expiresIn"` // Days until expiry, 0 = never
+	Mapping   models.ScimAttributeMapping `json:"mapping"`   // Empty = default mapping
+}
+
+// scimTokenView token with its effective attribute mapping
+type scimTokenView struct {
+	models.ScimToken
+	Mapping models.ScimAttributeMapping `json:"mapping"`
+}
+
+// ListScimTokens lists SCIM tokens
+// GET /scim/tokens
+func (h *Handlers) ListScimTokens(c *gin.Context) {
+	var tokens []models.ScimToken
+	if err := h.db.Order("id DESC").Find(&tokens).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	views := make([]scimTokenView, 0, len(tokens))
+	for _, t := range tokens {
+		views = append(views, scimTokenView{ScimToken: t, Mapping: t.AttributeMapping()})
+	}
+	response.Success(c, "success", gin.H{
+		"tokens":         views,
+		"defaultMapping": models.DefaultScimAttributeMapping,
+	})
+}
+
+// CreateScimToken creates a SCIM token, the plaintext token is only returned once
+// POST /scim/tokens
+func (h *Handlers) CreateScimToken(c *gin.Context) {
+	var req createScimTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	token := models.ScimToken{Name: req.Name, GroupID: req.GroupID, CreatedBy: models.CurrentUser(c).ID}
+	if req.GroupID != nil {
+		if err := h.db.First(&models.Group{}, *req.GroupID).Error; err != nil {
+			response.Fail(c, "organization not found", nil)
+			return
+		}
+	}
+	if req.ExpiresIn > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresIn)
+		token.ExpiresAt = &expires
+	}
+	if len(req.Mapping) > 0 {
+		if err := token.SetAttributeMapping(req.Mapping); err != nil {
+			response.Fail(c, "invalid attribute mapping", err.Error())
+			return
+		}
+	}
+	plain, err := models.CreateScimToken(h.db, &token)
+	if err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	response.Success(c, "created", gin.H{
+		"token":   plain,
+		"scimUrl": h.scimBaseURL(c),
+		"info":    scimTokenView{ScimToken: token, Mapping: token.AttributeMapping()},
+		"warning": "the token is only shown once",
+	})
+}
+
+// UpdateScimTokenMapping updates the attribute mapping of a SCIM token
+// PUT /scim/tokens/:id/mapping
+func (h *Handlers) UpdateScimTokenMapping(c *gin.Context) {
+	token, ok := h.loadScimToken(c)
+	if !ok {
+		return
+	}
+	var mapping models.ScimAttributeMapping
+	if err := c.ShouldBindJSON(&mapping); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if len(mapping) == 0 {
+		mapping = nil
+	}
+	if err := token.SetAttributeMapping(mapping); err != nil {
+		response.Fail(c, "invalid attribute mapping", err.Error())
+		return
+	}
+	if err := h.db.Model(token).Update("mapping", token.Mapping).Error; err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "updated", scimTokenView{ScimToken: *token, Mapping: token.AttributeMapping()})
+}
+
+// RevokeScimToken revokes a SCIM token
+// DELETE /scim/tokens/:id
+func (h *Handlers) RevokeScimToken(c *gin.Context) {
+	token, ok := h.loadScimToken(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Model(token).Update("revoked", true).Error; err != nil {
+		response.Fail(c, "revoke failed", err.Error())
+		return
+	}
+	response.Success(c, "revoked", nil)
+}
+
+func (h *Handlers) loadScimToken(c *gin.Context) (*models.ScimToken, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid token id", nil)
+		return nil, false
+	}
+	var token models.ScimToken
+	if err := h.db.First(&token, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "token not found", nil)
+		} else {
+			response.Fail(c, "query failed", err.Error())
+		}
+		return nil, false
+	}
+	return &token, true
+}
+
+func (h *Handlers) scimBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s/scim/v2", scheme, c.Request.Host, config.GlobalConfig.Server.APIPrefix)
+}
+
+func (h *Handlers) scimProvisioner(c *gin.Context) *models.ScimProvisioner {
+	token := c.MustGet(models.ScimTokenField).(*models.ScimToken)
+	return models.NewScimProvisioner(h.db, token, h.scimBaseURL(c))
+}
+
+// scimJSON writes a SCIM response with the application/scim+json content type
+func scimJSON(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", scim.ContentType)
+	if body == nil {
+		c.Status(status)
+		return
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	c.Data(status, scim.ContentType, data)
+}
+
+func scimError(c *gin.Context, err error) {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		scimErr = scim.NewError(http.StatusInternalServerError, "", "%s", err.Error())
+	}
+	scimJSON(c, scimErr.StatusCode(), scimErr)
+}
+
+// bindScim decodes a SCIM request body, which is sent as application/scim+json
+func bindScim(c *gin.Context, v any) bool {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, scim.MaxBulkPayloadSize+1))
+	if err == nil && int64(len(body)) > scim.MaxBulkPayloadSize {
+		scimError(c, scim.NewError(http.StatusRequestEntityTooLarge, scim.ErrTypeTooMany, "payload exceeds %d bytes", scim.MaxBulkPayloadSize))
+		return false
+	}
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	if err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidSyntax, "invalid request body: %s", err.Error()))
+		return false
+	}
+	return true
+}
+
+func scimListParams(c *gin.Context) (string, int, int) {
+	startIndex, _ := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	count, _ := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scim.DefaultPageSize)))
+	return c.Query("filter"), startIndex, count
+}
+
+// ScimServiceProviderConfig describes the supported SCIM features
+// GET /scim/v2/ServiceProviderConfig
+func (h *Handlers) ScimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{scim.SchemaServiceProvider},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": true, "maxOperations": scim.MaxBulkOperations, "maxPayloadSize": scim.MaxBulkPayloadSize},
+		"filter":         gin.H{"supported": true, "maxResults": scim.MaxPageSize},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Authentication with a SCIM token created by an administrator",
+			"primary":     true,
+		}},
+	})
+}
+
+// ScimResourceTypes lists the supported resource types
+// GET /scim/v2/ResourceTypes
+func (h *Handlers) ScimResourceTypes(c *gin.Context) {
+	types := []any{
+		gin.H{
+			"schemas": []string{scim.SchemaResourceType}, "id": models.ScimResourceUser, "name": models.ScimResourceUser,
+			"endpoint": "/Users", "schema": scim.SchemaUser,
+			"schemaExtensions": []gin.H{{"schema": scim.SchemaEnterpriseUser, "required": false}},
+		},
+		gin.H{
+			"schemas": []string{scim.SchemaResourceType}, "id": models.ScimResourceGroup, "name": models.ScimResourceGroup,
+			"endpoint": "/Groups", "schema": scim.SchemaGroup,
+		},
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(types, int64(len(types)), 1))
+}
+
+// ScimSchemas lists the attributes understood by this service provider
+// GET /scim/v2/Schemas
+func (h *Handlers) ScimSchemas(c *gin.Context) {
+	attr := func(name, typ string, multi bool) gin.H {
+		return gin.H{"name": name, "type": typ, "multiValued": multi, "required": name == "userName" || name == "displayName"}
+	}
+	schemas := []any{
+		gin.H{
+			"schemas": []string{scim.SchemaSchema}, "id": scim.SchemaUser, "name": models.ScimResourceUser,
+			"attributes": []gin.H{
+				attr("userName", "string", false), attr("name", "complex", false), attr("displayName", "string", false),
+				attr("emails", "complex", true), attr("phoneNumbers", "complex", true), attr("addresses", "complex", true),
+				attr("locale", "string", false), attr("timezone", "string", false), attr("active", "boolean", false),
+				attr("externalId", "string", false),
+			},
+		},
+		gin.H{
+			"schemas": []string{scim.SchemaSchema}, "id": scim.SchemaGroup, "name": models.ScimResourceGroup,
+			"attributes": []gin.H{
+				attr("displayName", "string", false), attr("members", "complex", true), attr("externalId", "string", false),
+			},
+		},
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(schemas, int64(len(schemas)), 1))
+}
+
+// ScimListUsers GET /scim/v2/Users
+func (h *Handlers) ScimListUsers(c *gin.Context) {
+	filter, startIndex, count := scimListParams(c)
+	list, err := h.scimProvisioner(c).ListUsers(filter, startIndex, count)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, list)
+}
+
+// ScimGetUser GET /scim/v2/Users/:id
+func (h *Handlers) ScimGetUser(c *gin.Context) {
+	res, err := h.scimProvisioner(c).GetUser(c.Param("id"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, res)
+}
+
+// ScimCreateUser POST /scim/v2/Users
+func (h *Handlers) ScimCreateUser(c *gin.Context) {
+	var body map[string]any
+	if !bindScim(c, &body) {
+		return
+	}
+	res, err := h.scimProvisioner(c).CreateUser(body)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusCreated, res)
+}
+
+// ScimReplaceUser PUT /scim/v2/Users/:id
+func (h *Handlers) ScimReplaceUser(c *gin.Context) {
+	var body map[string]any
+	if !bindScim(c, &body) {
+		return
+	}
+	res, err := h.scimProvisioner(c).ReplaceUser(c.Param("id"), body)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, res)
+}
+
+// ScimPatchUser PATCH /scim/v2/Users/:id
+func (h *Handlers) ScimPatchUser(c *gin.Context) {
+	var req scim.PatchRequest
+	if !bindScim(c, &req) {
+		return
+	}
+	res, err := h.scimProvisioner(c).PatchUser(c.Param("id"), req.Operations)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, res)
+}
+
+// ScimDeleteUser deactivates the user instead of deleting it
+// DELETE /scim/v2/Users/:id
+func (h *Handlers) ScimDeleteUser(c *gin.Context) {
+	if err := h.scimProvisioner(c).DeactivateUser(c.Param("id")); err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusNoContent, nil)
+}
+
+// ScimListGroups GET /scim/v2/Groups
+func (h *Handlers) ScimListGroups(c *gin.Context) {
+	filter, startIndex, count := scimListParams(c)
+	list, err := h.scimProvisioner(c).ListGroups(filter, startIndex, count)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, list)
+}
+
+// ScimGetGroup GET /scim/v2/Groups/:id
+func (h *Handlers) ScimGetGroup(c *gin.Context) {
+	res, err := h.scimProvisioner(c).GetGroup(c.Param("id"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, res)
+}
+
+// ScimCreateGroup POST /scim/v2/Groups
+func (h *Handlers) ScimCreateGroup(c *gin.Context) {
+	var body map[string]any
+	if !bindScim(c, &body) {
+		return
+	}
+	res, err := h.scimProvisioner(c).CreateGroup(body)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusCreated, res)
+}
+
+// ScimReplaceGroup PUT /scim/v2/Groups/:id
+func (h *Handlers) ScimReplaceGroup(c *gin.Context) {
+	var body map[string]any
+	if !bindScim(c, &body) {
+		return
+	}
+	res, err := h.scimProvisioner(c).ReplaceGroup(c.Param("id"), body)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, res)
+}
+
+// ScimPatchGroup PATCH /scim/v2/Groups/:id
+func (h *Handlers) ScimPatchGroup(c *gin.Context) {
+	var req scim.PatchRequest
+	if !bindScim(c, &req) {
+		return
+	}
+	res, err := h.scimProvisioner(c).PatchGroup(c.Param("id"), req.Operations)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, res)
+}
+
+// ScimDeleteGroup DELETE /scim/v2/Groups/:id
+func (h *Handlers) ScimDeleteGroup(c *gin.Context) {
+	if err := h.scimProvisioner(c).DeleteGroup(c.Param("id")); err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusNoContent, nil)
+}
+
+// ScimBulk applies a batch of user and group operations
+// POST /scim/v2/Bulk
+func (h *Handlers) ScimBulk(c *gin.Context) {
+	var req scim.BulkRequest
+	if !bindScim(c, &req) {
+		return
+	}
+	resp, err := h.scimProvisioner(c).Bulk(&req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// rejectForSecurityPolicy stops logins from IP addresses outside the allowlists
+// of the user's organizations
+func (h *Handlers) rejectForSecurityPolicy(c *gin.Context, user *models.User) bool {
+	err := models.CheckLoginSecurityPolicy(h.db, user, c.ClientIP())
+	if err == nil {
+		return false
+	}
+	if !errors.Is(err, models.ErrPolicyIPNotAllowed) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "msg": err.Error()})
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": err.Error()})
+	return true
+}
+
+// GetSecurityPolicy returns the organization's security policy, members may read
+// it to know the requirements
+// GET /group/:id/security-policy
+func (h *Handlers) GetSecurityPolicy(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, false)
+	if !ok {
+		return
+	}
+	policy, err := models.GetGroupSecurityPolicy(h.db, group.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", policy)
+}
+
+// UpdateSecurityPolicy replaces the organization's security policy
+// PUT /group/:id/security-policy
+func (h *Handlers) UpdateSecurityPolicy(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	var policy models.GroupSecurityPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	user := models.CurrentUser(c)
+	policy.GroupID = group.ID
+	policy.UpdatedBy = user.ID
+	if err := policy.Validate(); err != nil {
+		response.Fail(c, "invalid security policy", err.Error())
+		return
+	}
+
+	// Refuse an allowlist that would lock out the admin saving it
+	if !user.IsAdmin() && !policy.AllowsIP(c.ClientIP()) {
+		response.Fail(c, "invalid security policy", "the IP allowlist must include your current address "+c.ClientIP())
+		return
+	}
+	if err := models.SaveGroupSecurityPolicy(h.db, &policy); err != nil {
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "saved", policy)
+}
+
+// GetSecurityPolicyReport lists members that violate the organization's policy
+// GET /group/:id/security-policy/report
+func (h *Handlers) GetSecurityPolicyReport(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	report, err := models.BuildSecurityPolicyReport(h.db, group, time.Now())
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", report)
+}
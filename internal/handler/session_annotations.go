@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const defaultAnnotationLinkTTL = 7 * 24 * time.Hour
+
+// createAnnotationRequest body of annotating a moment of a session
+type createAnnotationRequest struct {
+	Body      string `json:"body" binding:"required"`
+	TurnIndex *int   `json:"turnIndex"` // 1-based turn of the transcript
+	OffsetMs  *int64 `json:"offsetMs"`  // position in the call recording
+	Mentions  []uint `json:"mentions"`  // user IDs of teammates to notify
+}
+
+// shareAnnotationRequest body of creating a signed share link
+type shareAnnotationRequest struct {
+	TTLHours int `json:"ttlHours"` // default 168, at most 720
+}
+
+// annotationSiteURL base URL of the web console
+func (h *Handlers) annotationSiteURL() string {
+	siteURL := utils.GetValue(h.db, constants.KEY_SITE_URL)
+	if siteURL == "" {
+		siteURL = "http://localhost:3000"
+	}
+	return siteURL
+}
+
+// annotationLink console deep link opening the session replay at the annotation,
+// for users who can access the assistant
+func (h *Handlers) annotationLink(annotation *models.SessionAnnotation, offsetMs *int64) string {
+	query := url.Values{"annotation": {strconv.FormatUint(uint64(annotation.ID), 10)}}
+	if offsetMs != nil {
+		query.Set("t", strconv.FormatInt(*offsetMs, 10))
+	}
+	return fmt.Sprintf("%s/assistants/%d/sessions/%s?%s",
+		h.annotationSiteURL(), annotation.AssistantID, url.PathEscape(annotation.SessionID), query.Encode())
+}
+
+// annotationSession checks the session exists and the optional turn is within it
+func (h *Handlers) annotationSession(c *gin.Context, assistant *models.Assistant, turnIndex *int) (string, bool) {
+	sessionID := c.Param("sessionId")
+	var turns int64
+	h.db.Model(&models.ChatSessionLog{}).
+		Where("session_id = ? AND assistant_id = ?", sessionID, assistant.ID).
+		Count(&turns)
+	if turns == 0 {
+		response.Fail(c, "session not found", nil)
+		return "", false
+	}
+	if turnIndex != nil && int64(*turnIndex) > turns {
+		response.Fail(c, "invalid turn index", nil)
+		return "", false
+	}
+	return sessionID, true
+}
+
+// ownedAnnotation loads the annotation from :annotationId within the reviewable assistant
+func (h *Handlers) ownedAnnotation(c *gin.Context) (*models.Assistant, *models.SessionAnnotation, bool) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return nil, nil, false
+	}
+	annotationID, err := strconv.ParseUint(c.Param("annotationId"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid annotation id", nil)
+		return nil, nil, false
+	}
+	annotation, err := models.GetSessionAnnotation(h.db, assistant.ID, uint(annotationID))
+	if err != nil {
+		response.Fail(c, "annotation not found", nil)
+		return nil, nil, false
+	}
+	return assistant, annotation, true
+}
+
+// ListSessionAnnotations lists the annotations of a session in playback order
+// GET /assistant/:id/sessions/:sessionId/annotations
+func (h *Handlers) ListSessionAnnotations(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	annotations, err := models.ListSessionAnnotations(h.db, assistant.ID, c.Param("sessionId"))
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", annotations)
+}
+
+// CreateSessionAnnotation annotates a turn of the transcript or a moment of the
+// recording; mentioned teammates must be able to review the assistant and are
+// notified with a deep link to the annotated moment
+// POST /assistant/:id/sessions/:sessionId/annotations
+func (h *Handlers) CreateSessionAnnotation(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	var req createAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	sessionID, ok := h.annotationSession(c, assistant, req.TurnIndex)
+	if !ok {
+		return
+	}
+
+	var mentioned []models.User
+	if len(req.Mentions) > 0 {
+		if err := h.db.Where("id IN ?", req.Mentions).Find(&mentioned).Error; err != nil {
+			response.Fail(c, "query failed", err.Error())
+			return
+		}
+		for i := range mentioned {
+			if !models.CanEditAssistant(h.db, assistant, mentioned[i].ID) {
+				response.Fail(c, "cannot mention users without access to this assistant", mentioned[i].Email)
+				return
+			}
+		}
+	}
+
+	author := models.CurrentUser(c)
+	annotation := &models.SessionAnnotation{
+		AssistantID: assistant.ID,
+		SessionID:   sessionID,
+		AuthorID:    author.ID,
+		TurnIndex:   req.TurnIndex,
+		OffsetMs:    req.OffsetMs,
+		Body:        req.Body,
+		MentionIDs:  make([]uint, 0, len(mentioned)),
+	}
+	for i := range mentioned {
+		annotation.MentionIDs = append(annotation.MentionIDs, mentioned[i].ID)
+	}
+	if err := models.CreateSessionAnnotation(h.db, annotation); err != nil {
+		if errors.Is(err, models.ErrAnnotationTarget) || errors.Is(err, models.ErrAnnotationBody) || errors.Is(err, models.ErrTooManyMentions) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+
+	link := h.annotationLink(annotation, h.annotationOffset(annotation))
+	authorName := author.DisplayName
+	if authorName == "" {
+		authorName = author.Email
+	}
+	for i := range mentioned {
+		if mentioned[i].ID == author.ID {
+			continue
+		}
+		models.DispatchNotification(h.db, &mentioned[i], models.Notice{
+			Event:    models.NotificationEventAssistant,
+			Title:    fmt.Sprintf("%s mentioned you on %s", authorName, assistant.Name),
+			Content:  fmt.Sprintf("%s<br/><a href=\"%s\">Open the conversation</a>", html.EscapeString(annotation.Body), html.EscapeString(link)),
+			Channels: []models.NotificationChannel{models.NotificationChannelInternal},
+			Data:     map[string]any{"annotationId": annotation.ID, "link": link},
+		})
+	}
+	response.Success(c, "created", gin.H{"annotation": annotation, "link": link})
+}
+
+// annotationOffset resolves the recording position of a turn annotation; a
+// missing replay only drops the timestamp from the link
+func (h *Handlers) annotationOffset(annotation *models.SessionAnnotation) *int64 {
+	if annotation.OffsetMs != nil {
+		return annotation.OffsetMs
+	}
+	replay, err := models.BuildSessionReplay(h.db, annotation.AssistantID, annotation.SessionID)
+	if err != nil {
+		return nil
+	}
+	return models.AnnotationRecordingOffset(annotation, replay)
+}
+
+// DeleteSessionAnnotation deletes an annotation and invalidates its share links;
+// authors delete their own, the assistant owner can delete any
+// DELETE /assistant/:id/annotations/:annotationId
+func (h *Handlers) DeleteSessionAnnotation(c *gin.Context) {
+	assistant, annotation, ok := h.ownedAnnotation(c)
+	if !ok {
+		return
+	}
+	user := models.CurrentUser(c)
+	if annotation.AuthorID != user.ID && assistant.UserID != user.ID {
+		response.Fail(c, "forbidden", "You can only delete your own annotations")
+		return
+	}
+	if err := models.DeleteSessionAnnotation(h.db, annotation); err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// ShareSessionAnnotation signs a link that opens the replay at the annotated
+// moment without logging in, until it expires or the links are revoked
+// POST /assistant/:id/annotations/:annotationId/share
+func (h *Handlers) ShareSessionAnnotation(c *gin.Context) {
+	_, annotation, ok := h.ownedAnnotation(c)
+	if !ok {
+		return
+	}
+	var req shareAnnotationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "invalid request", err.Error())
+			return
+		}
+	}
+	ttl := defaultAnnotationLinkTTL
+	if req.TTLHours > 0 {
+		ttl = min(time.Duration(req.TTLHours)*time.Hour, models.AnnotationLinkMaxTTL)
+	}
+	expiresAt := time.Now().Add(ttl)
+	token, err := models.SignAnnotationLink(config.GlobalConfig.Auth.SessionSecret, annotation, expiresAt)
+	if err != nil {
+		response.Fail(c, "share failed", err.Error())
+		return
+	}
+	offset := h.annotationOffset(annotation)
+	query := url.Values{"token": {token}}
+	if offset != nil {
+		query.Set("t", strconv.FormatInt(*offset, 10))
+	}
+	response.Success(c, "success", gin.H{
+		"token":     token,
+		"shareUrl":  h.annotationSiteURL() + "/shared/annotations?" + query.Encode(),
+		"apiUrl":    config.GlobalConfig.Server.APIPrefix + "/annotations/shared?token=" + token,
+		"expiresAt": expiresAt,
+	})
+}
+
+// RevokeSessionAnnotationLinks invalidates all share links of an annotation
+// POST /assistant/:id/annotations/:annotationId/share/revoke
+func (h *Handlers) RevokeSessionAnnotationLinks(c *gin.Context) {
+	_, annotation, ok := h.ownedAnnotation(c)
+	if !ok {
+		return
+	}
+	if err := models.RevokeAnnotationLinks(h.db, annotation); err != nil {
+		response.Fail(c, "revoke failed", err.Error())
+		return
+	}
+	response.Success(c, "revoked", nil)
+}
+
+// ListMentionedAnnotations lists the latest annotations mentioning the current
+// user with their deep links
+// GET /annotations/mentions
+func (h *Handlers) ListMentionedAnnotations(c *gin.Context) {
+	user := models.CurrentUser(c)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	annotations, err := models.ListMentionedAnnotations(h.db, user.ID, limit)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	result := make([]gin.H, 0, len(annotations))
+	for i := range annotations {
+		result = append(result, gin.H{
+			"annotation": annotations[i],
+			"link":       h.annotationLink(&annotations[i], annotations[i].OffsetMs),
+		})
+	}
+	response.Success(c, "success", result)
+}
+
+// GetSharedAnnotation resolves a signed share link: the annotation, its
+// position in the recording and the session replay without QA reviews or
+// other annotations
+// GET /annotations/shared?token=...
+func (h *Handlers) GetSharedAnnotation(c *gin.Context) {
+	annotation, err := models.VerifyAnnotationLink(h.db, config.GlobalConfig.Auth.SessionSecret, c.Query("token"), time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidAnnotationLink) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Failed to verify annotation link", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "verification failed"})
+		return
+	}
+	replay, err := models.BuildSessionReplay(h.db, annotation.AssistantID, annotation.SessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		response.Fail(c, "replay failed", err.Error())
+		return
+	}
+	replay.Reviews = []models.QAReview{}
+	replay.Annotations = []models.SessionAnnotation{*annotation}
+	response.Success(c, "success", gin.H{
+		"annotation":        annotation,
+		"recordingOffsetMs": models.AnnotationRecordingOffset(annotation, replay),
+		"replay":            replay,
+	})
+}
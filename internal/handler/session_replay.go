@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// createQAReviewRequest body of annotating a session
+type createQAReviewRequest struct {
+	Score     int      `json:"score" binding:"required"`
+	Tags      []string `json:"tags"`
+	Comment   string   `json:"comment"`
+	TurnIndex *int     `json:"turnIndex"` // optional, 1-based turn of the replay
+}
+
+// recordKnowledgeRetrieval keeps the chunks retrieved for a turn so the session
+// can be replayed; failures only affect QA and are logged
+func (h *Handlers) recordKnowledgeRetrieval(sessionID string, assistantID int64, knowledgeKey, query string, results []knowledge.SearchResult, started time.Time) {
+	if err := models.RecordKnowledgeRetrieval(h.db, sessionID, assistantID, knowledgeKey, query, results, started); err != nil {
+		logger.Warn("Failed to record knowledge retrieval", zap.String("sessionId", sessionID), zap.Error(err))
+	}
+}
+
+// reviewableAssistant loads the assistant from :id for QA; owners and members of
+// organizations it is shared with for editing can review its sessions
+func (h *Handlers) reviewableAssistant(c *gin.Context) (*models.Assistant, bool) {
+	return h.loadAssistantParam(c, func(assistant *models.Assistant, userID uint) bool {
+		return models.CanEditAssistant(h.db, assistant, userID)
+	})
+}
+
+// GetSessionReplay reconstructs a conversation for QA: turns in order with
+// offsets from the session start and into the call recording, LLM latency,
+// tool calls with their outputs, retrieved knowledge chunks and the reviews
+// GET /assistant/:id/sessions/:sessionId/replay
+func (h *Handlers) GetSessionReplay(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	replay, err := models.BuildSessionReplay(h.db, assistant.ID, c.Param("sessionId"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "session not found", nil)
+			return
+		}
+		response.Fail(c, "replay failed", err.Error())
+		return
+	}
+	response.Success(c, "success", replay)
+}
+
+// ListQAReviews lists the reviews of a session
+// GET /assistant/:id/sessions/:sessionId/reviews
+func (h *Handlers) ListQAReviews(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	reviews, err := models.ListQAReviews(h.db, assistant.ID, c.Param("sessionId"))
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", reviews)
+}
+
+// CreateQAReview annotates a session, or one of its turns, with a 1-5 score,
+// tags and a comment
+// POST /assistant/:id/sessions/:sessionId/reviews
+func (h *Handlers) CreateQAReview(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	var req createQAReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	sessionID := c.Param("sessionId")
+	var turns int64
+	h.db.Model(&models.ChatSessionLog{}).
+		Where("session_id = ? AND assistant_id = ?", sessionID, assistant.ID).
+		Count(&turns)
+	if turns == 0 {
+		response.Fail(c, "session not found", nil)
+		return
+	}
+	if req.TurnIndex != nil && (*req.TurnIndex < 1 || int64(*req.TurnIndex) > turns) {
+		response.Fail(c, "invalid turn index", nil)
+		return
+	}
+
+	review := &models.QAReview{
+		AssistantID: assistant.ID,
+		SessionID:   sessionID,
+		TurnIndex:   req.TurnIndex,
+		ReviewerID:  models.CurrentUser(c).ID,
+		Score:       req.Score,
+		Tags:        req.Tags,
+		Comment:     req.Comment,
+	}
+	if err := models.CreateQAReview(h.db, review); err != nil {
+		if errors.Is(err, models.ErrInvalidQAScore) || errors.Is(err, models.ErrTooManyQATags) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	response.Success(c, "created", review)
+}
+
+// DeleteQAReview deletes a review; reviewers delete their own, the assistant
+// owner can delete any
+// DELETE /assistant/:id/reviews/:reviewId
+func (h *Handlers) DeleteQAReview(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	reviewID, err := strconv.ParseUint(c.Param("reviewId"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid review id", nil)
+		return
+	}
+	review, err := models.GetQAReview(h.db, assistant.ID, uint(reviewID))
+	if err != nil {
+		response.Fail(c, "review not found", nil)
+		return
+	}
+	user := models.CurrentUser(c)
+	if review.ReviewerID != user.ID && assistant.UserID != user.ID {
+		response.Fail(c, "forbidden", "You can only delete your own reviews")
+		return
+	}
+	if err := h.db.Delete(review).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// GetAssistantQualityReport aggregates reviews and latencies of the assistant
+// over the last days (default 30, at most 365)
+// GET /assistant/:id/quality-report
+func (h *Handlers) GetAssistantQualityReport(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	report, err := models.GetAssistantQualityReport(h.db, assistant.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", report)
+}
@@ -84,6 +84,11 @@ type SipServerInterface interface {
 	GetOutgoingSession(callID string) (interface{}, bool) // 返回sip包的OutgoingSession
 	CancelOutgoingCall(callID string) error
 	HangupOutgoingCall(callID string) error // 挂断已接通的通话
+	// SurveyAndHangupOutgoingCall 先对被叫进行满意度调查再挂断，surveying 表示调查已在后台开始
+	SurveyAndHangupOutgoingCall(callID string) (surveying bool, err error)
+	// Interpretation 正在传译该通话的助手及传译是否开启，callID 可以是任一方的通话
+	Interpretation(callID string) (assistantID int64, enabled bool, ok bool)
+	SetInterpretation(callID string, enabled bool) error
 }
 
 // OutgoingSession 呼出会话信息（与sip包中的结构对应）
@@ -169,6 +174,7 @@ func (h *SipHandler) MakeOutgoingCall(c *gin.Context) {
 		UserID:    req.UserID,
 		GroupID:   req.GroupID,
 		Notes:     req.Notes,
+		Region:    models.LocalRegion(),
 	}
 
 	if err := models.CreateSipCall(h.db, sipCall); err != nil {
@@ -302,12 +308,19 @@ func (h *SipHandler) CancelOutgoingCall(c *gin.Context) {
 	response.Success(c, "Call cancelled successfully", nil)
 }
 
+// HangupOutgoingCallRequest 挂断呼出请求
+type HangupOutgoingCallRequest struct {
+	Survey bool `json:"survey,omitempty"` // 挂断前进行满意度调查
+}
+
 // HangupOutgoingCall 挂断呼出呼叫
 // @Summary 挂断呼出呼叫
-// @Description 挂断一个已接通的呼出呼叫
+// @Description 挂断一个已接通的呼出呼叫，survey 为 true 时按满意度调查配置先请被叫评分再挂断
 // @Tags SIP
+// @Accept json
 // @Produce json
 // @Param callId path string true "通话ID"
+// @Param request body HangupOutgoingCallRequest false "挂断选项"
 // @Success 200 {object} response.Response
 // @Failure 400 {object} response.Response
 // @Failure 404 {object} response.Response
@@ -324,7 +337,26 @@ func (h *SipHandler) HangupOutgoingCall(c *gin.Context) {
 		return
 	}
 
-	err := h.sipServer.HangupOutgoingCall(callID)
+	var req HangupOutgoingCallRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "Invalid request", err.Error())
+			return
+		}
+	}
+
+	var err error
+	if req.Survey {
+		var surveying bool
+		surveying, err = h.sipServer.SurveyAndHangupOutgoingCall(callID)
+		if err == nil && surveying {
+			// 调查结束后由 SIP 服务器挂断并更新通话记录
+			response.Success(c, "Call survey started, the call will be hung up afterwards", gin.H{"survey": true})
+			return
+		}
+	} else {
+		err = h.sipServer.HangupOutgoingCall(callID)
+	}
 	if err != nil {
 		if err.Error() == "call not found" {
 			response.Fail(c, "Call not found", err.Error())
@@ -404,6 +436,9 @@ func (h *SipHandler) GetCallHistory(c *gin.Context) {
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
+	if region := c.Query("region"); region != "" {
+		query = query.Where("region = ? OR peer_region = ?", region, region)
+	}
 
 	// 获取总数
 	query.Count(&total)
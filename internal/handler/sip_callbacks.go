@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultSipCallbackReportDays = 30
+
+// ListSipCallbacks lists the callbacks offered to callers queued on the current
+// user's SIP lines, newest first, optionally filtered by ?status=
+// GET /sip-callbacks
+func (h *Handlers) ListSipCallbacks(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	callbacks, total, err := models.ListSipCallbacks(h.db, user.ID, c.Query("status"), page, pageSize)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"callbacks": callbacks,
+		"total":     total,
+		"page":      page,
+		"pageSize":  pageSize,
+	})
+}
+
+// GetSipCallbackReport reports how many queued callers were offered a callback
+// over the last ?days= (default 30, at most 365), how many accepted and how many
+// were reached when their turn came
+// GET /sip-callbacks/report
+func (h *Handlers) GetSipCallbackReport(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultSipCallbackReportDays)))
+	if days < 1 || days > 365 {
+		days = defaultSipCallbackReportDays
+	}
+	report, err := models.BuildSipCallbackReport(h.db, user.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", report)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// sipHAStandbyFresh a standby whose heartbeat is older than this can't be relied on for a drill
+	sipHAStandbyFresh = 30 * time.Second
+	// sipHADrillDrain keeps the drilled instance from taking the lease straight back
+	sipHADrillDrain = 2 * time.Minute
+)
+
+// SipFailoverDrillRequest hands the media path over to the standby instance
+type SipFailoverDrillRequest struct {
+	Cluster string `json:"cluster"` // defaults to SIP_HA_CLUSTER
+	Reason  string `json:"reason"`
+}
+
+func sipHACluster(cluster string) string {
+	if cluster != "" {
+		return cluster
+	}
+	return utils.GetEnv("SIP_HA_CLUSTER")
+}
+
+// GetSipHAStatus returns the lease, instances and recent failovers of a hot standby cluster
+// GET /sip/ha?cluster=
+func (h *Handlers) GetSipHAStatus(c *gin.Context) {
+	cluster := sipHACluster(c.Query("cluster"))
+	if cluster == "" {
+		response.Fail(c, "Hot standby is not configured", "set SIP_HA_CLUSTER or pass ?cluster=")
+		return
+	}
+	lease, err := models.GetSipHALease(h.db, cluster)
+	if err != nil {
+		lease = nil
+	}
+	nodes, err := models.ListSipHANodes(h.db, cluster)
+	if err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+	events, err := models.ListSipFailoverEvents(h.db, cluster, 20)
+	if err != nil {
+		response.Fail(c, "Query failed", err.Error())
+		return
+	}
+	now := time.Now()
+	active := ""
+	if lease != nil && now.Before(lease.ExpiresAt) {
+		active = lease.Holder
+	}
+	response.Success(c, "success", gin.H{
+		"cluster":      cluster,
+		"active":       active,
+		"lease":        lease,
+		"nodes":        nodes,
+		"standbyReady": models.HealthyStandby(nodes, now.Add(-sipHAStandbyFresh)) != nil,
+		"events":       events,
+	})
+}
+
+// StartSipFailoverDrill expires the active instance's lease so the standby takes over the VIP
+// and the in-flight calls; refused when no standby is healthy
+// POST /sip/ha/drill
+func (h *Handlers) StartSipFailoverDrill(c *gin.Context) {
+	var req SipFailoverDrillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err.Error())
+		return
+	}
+	cluster := sipHACluster(req.Cluster)
+	if cluster == "" {
+		response.Fail(c, "Hot standby is not configured", "set SIP_HA_CLUSTER or pass cluster")
+		return
+	}
+	user := models.CurrentUser(c)
+	now := time.Now()
+	event, err := models.RequestSipFailoverDrill(h.db, cluster, now.Add(-sipHAStandbyFresh), sipHADrillDrain, user.ID, req.Reason, now)
+	if err != nil {
+		if errors.Is(err, models.ErrNoHealthyStandby) || errors.Is(err, models.ErrNoActiveInstance) {
+			response.Fail(c, "Failover drill refused", err.Error())
+			return
+		}
+		response.Fail(c, "Failover drill failed", err.Error())
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"cluster": cluster,
+		"from":    event.FromInstance,
+		"to":      event.ToInstance,
+		"user":    user.ID,
+	}).Warn("SIP failover drill requested")
+	response.Success(c, "Failover drill started", event)
+}
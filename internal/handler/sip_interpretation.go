@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// interpretedCallAssistant the assistant interpreting a live or past call,
+// only its owner may see or control the interpretation
+func (h *SipHandler) interpretedCallAssistant(c *gin.Context, callID string) (*models.Assistant, []models.InterpretationSegment, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", "User not logged in")
+		return nil, nil, false
+	}
+	segments, err := models.ListInterpretationSegments(h.db, callID)
+	if err != nil {
+		response.Fail(c, "Failed to load interpretation", err.Error())
+		return nil, nil, false
+	}
+	var assistantID int64
+	if len(segments) > 0 {
+		assistantID = segments[0].AssistantID
+	} else if h.sipServer != nil {
+		assistantID, _, _ = h.sipServer.Interpretation(callID)
+	}
+	var assistant models.Assistant
+	if assistantID == 0 || h.db.First(&assistant, assistantID).Error != nil || assistant.UserID != user.ID {
+		response.Fail(c, "Interpreted call not found", nil)
+		return nil, nil, false
+	}
+	return &assistant, segments, true
+}
+
+// GetCallInterpretation returns the transcript of an interpreted call in both languages
+func (h *SipHandler) GetCallInterpretation(c *gin.Context) {
+	callID := c.Param("callId")
+	assistant, segments, ok := h.interpretedCallAssistant(c, callID)
+	if !ok {
+		return
+	}
+	live, enabled := false, false
+	if h.sipServer != nil {
+		_, enabled, live = h.sipServer.Interpretation(callID)
+	}
+	response.Success(c, "success", gin.H{
+		"callId":    callID,
+		"live":      live,
+		"enabled":   enabled,
+		"languageA": assistant.Language,
+		"languageB": assistant.InterpreterLanguage,
+		"segments":  segments,
+		"transcripts": gin.H{
+			assistant.Language:            models.InterpretationTranscript(segments, assistant.Language),
+			assistant.InterpreterLanguage: models.InterpretationTranscript(segments, assistant.InterpreterLanguage),
+		},
+	})
+}
+
+// SetCallInterpretation turns interpretation of a live call on or off, while
+// off the parties hear each other directly
+func (h *SipHandler) SetCallInterpretation(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err.Error())
+		return
+	}
+	if h.sipServer == nil {
+		response.Fail(c, "SIP server is not available", nil)
+		return
+	}
+	callID := c.Param("callId")
+	if _, _, ok := h.interpretedCallAssistant(c, callID); !ok {
+		return
+	}
+	if err := h.sipServer.SetInterpretation(callID, *req.Enabled); err != nil {
+		response.Fail(c, "Failed to change interpretation", err.Error())
+		return
+	}
+	response.Success(c, "success", gin.H{"callId": callID, "enabled": *req.Enabled})
+}
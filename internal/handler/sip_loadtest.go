@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/sip/loadtest"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// sipLoadTestState holds the single admin-triggered load test run.
+// Only one run is allowed at a time; the last report is kept until the next run.
+var sipLoadTestState struct {
+	mu     sync.Mutex
+	runner *loadtest.Runner
+	cancel context.CancelFunc
+	last   *loadtest.Report
+}
+
+// SipLoadTestRequest load test parameters, durations are in seconds
+type SipLoadTestRequest struct {
+	Target       string  `json:"target"` // defaults to the local SIP server
+	LocalIP      string  `json:"localIp"`
+	Users        int     `json:"users"`
+	CallsPerUser int     `json:"callsPerUser"` // -1 keeps calling until duration ends (soak)
+	Duration     float64 `json:"duration"`
+	RampUp       float64 `json:"rampUp"`
+	CallHold     float64 `json:"callHold"`
+	Pause        float64 `json:"pause"`
+	CallTimeout  float64 `json:"callTimeout"`
+	Register     bool    `json:"register"`
+	UserPrefix   string  `json:"userPrefix"`
+	Callee       string  `json:"callee" binding:"required"`
+	PCMFile      string  `json:"pcmFile"`
+}
+
+func seconds(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second))
+}
+
+// StartSipLoadTest starts a SIP load/soak test against the target server
+func (h *Handlers) StartSipLoadTest(c *gin.Context) {
+	var req SipLoadTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err.Error())
+		return
+	}
+	if req.Target == "" {
+		port := utils.GetIntEnv("SIP_PORT")
+		if port == 0 {
+			port = 5060
+		}
+		req.Target = net.JoinHostPort("127.0.0.1", strconv.FormatInt(port, 10))
+	}
+
+	runner, err := loadtest.New(loadtest.Config{
+		Target:       req.Target,
+		LocalIP:      req.LocalIP,
+		Users:        req.Users,
+		CallsPerUser: req.CallsPerUser,
+		Duration:     seconds(req.Duration),
+		RampUp:       seconds(req.RampUp),
+		CallHold:     seconds(req.CallHold),
+		Pause:        seconds(req.Pause),
+		CallTimeout:  seconds(req.CallTimeout),
+		Register:     req.Register,
+		UserPrefix:   req.UserPrefix,
+		Callee:       req.Callee,
+		PCMFile:      req.PCMFile,
+	})
+	if err != nil {
+		response.Fail(c, "Invalid load test configuration", err.Error())
+		return
+	}
+
+	state := &sipLoadTestState
+	state.mu.Lock()
+	if state.runner != nil {
+		state.mu.Unlock()
+		response.Fail(c, "A load test is already running", nil)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	state.runner = runner
+	state.cancel = cancel
+	state.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		report, err := runner.Run(ctx)
+		if err != nil {
+			logrus.WithError(err).Error("SIP load test failed")
+		}
+		state.mu.Lock()
+		state.runner = nil
+		state.cancel = nil
+		state.last = report
+		state.mu.Unlock()
+	}()
+
+	response.Success(c, "Load test started", runner.Snapshot())
+}
+
+// GetSipLoadTest returns the running load test progress or the last report
+func (h *Handlers) GetSipLoadTest(c *gin.Context) {
+	state := &sipLoadTestState
+	state.mu.Lock()
+	runner, last := state.runner, state.last
+	state.mu.Unlock()
+
+	if runner != nil {
+		response.Success(c, "Load test running", runner.Snapshot())
+		return
+	}
+	if last == nil {
+		response.Fail(c, "No load test has been run", nil)
+		return
+	}
+	response.Success(c, "Load test finished", last)
+}
+
+// StopSipLoadTest cancels the running load test, in-flight calls are hung up
+func (h *Handlers) StopSipLoadTest(c *gin.Context) {
+	state := &sipLoadTestState
+	state.mu.Lock()
+	cancel := state.cancel
+	state.mu.Unlock()
+
+	if cancel == nil {
+		response.Fail(c, "No load test is running", nil)
+		return
+	}
+	cancel()
+	response.Success(c, "Load test stopping", nil)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/plans"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ChangePlanRequest plan change of an organization. PaidUntil ends the paid term,
+// leave it empty for contract billed plans that do not expire.
+type ChangePlanRequest struct {
+	Plan      string     `json:"plan" binding:"required"`
+	PaidUntil *time.Time `json:"paidUntil"`
+}
+
+// subscriptionView subscription with the status in effect now
+type subscriptionView struct {
+	*models.GroupSubscription
+	EffectiveStatus string      `json:"effectiveStatus"`
+	Writable        bool        `json:"writable"`
+	PlanDetail      *plans.Plan `json:"planDetail"`
+}
+
+func newSubscriptionView(sub *models.GroupSubscription) subscriptionView {
+	status := sub.EffectiveStatus(time.Now())
+	return subscriptionView{GroupSubscription: sub, EffectiveStatus: status, Writable: plans.Writable(status), PlanDetail: sub.Plan()}
+}
+
+// ListPlans lists the subscription plans with their features and limits
+// GET /plans
+func (h *Handlers) ListPlans(c *gin.Context) {
+	response.Success(c, "success", plans.List())
+}
+
+// GetGroupSubscription returns the organization's subscription, null when the
+// organization is not on a plan and therefore not limited
+// GET /group/:id/subscription
+func (h *Handlers) GetGroupSubscription(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, false)
+	if !ok {
+		return
+	}
+	sub, err := models.GetGroupSubscription(h.db, group.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Success(c, "success", nil)
+		return
+	}
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", newSubscriptionView(sub))
+}
+
+// StartGroupTrial starts the trial of an organization without a subscription
+// POST /group/:id/subscription/trial
+func (h *Handlers) StartGroupTrial(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	sub, err := models.StartGroupTrial(h.db, group.ID, models.CurrentUser(c).ID, time.Now())
+	if err != nil {
+		response.Fail(c, "failed to start trial", err.Error())
+		return
+	}
+	response.Success(c, "trial started", newSubscriptionView(sub))
+}
+
+// ChangeGroupPlan changes the organization's plan and term (system admins, called
+// by billing once a payment or contract is confirmed)
+// PUT /group/:id/subscription
+func (h *Handlers) ChangeGroupPlan(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid organization id", nil)
+		return
+	}
+	var req ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	var group models.Group
+	if err := h.db.First(&group, groupID).Error; err != nil {
+		response.Fail(c, "organization not found", nil)
+		return
+	}
+	sub, err := models.ChangeGroupPlan(h.db, group.ID, req.Plan, req.PaidUntil, models.CurrentUser(c).ID, time.Now())
+	if err != nil {
+		response.Fail(c, "failed to change plan", err.Error())
+		return
+	}
+	response.Success(c, "plan changed", newSubscriptionView(sub))
+}
+
+// ListSubscriptionEvents lists the organization's billing events, newest first
+// GET /group/:id/subscription/events
+func (h *Handlers) ListSubscriptionEvents(c *gin.Context) {
+	group, ok := h.customFieldGroup(c, true)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	list, err := models.ListSubscriptionEvents(h.db, group.ID, limit)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", list)
+}
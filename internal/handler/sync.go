@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSyncChanges returns the devices, assistants and recordings of the current user
+// created, updated or deleted since ?cursor= (or ?since=), for offline-capable clients
+func (h *Handlers) GetSyncChanges(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", "User not logged in")
+		return
+	}
+
+	cursor := c.Query("cursor")
+	if cursor == "" {
+		cursor = c.Query("since")
+	}
+	since, err := models.ParseSyncCursor(cursor)
+	if err != nil {
+		response.Fail(c, "invalid cursor", "Use the cursor of the previous sync, a millisecond timestamp or an RFC3339 time")
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	changes, err := models.GetSyncChanges(h.db, user.ID, since, limit)
+	if err != nil {
+		response.Fail(c, "sync failed", err.Error())
+		return
+	}
+	response.Success(c, "success", changes)
+}
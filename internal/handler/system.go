@@ -14,6 +14,7 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/cache"
 	"github.com/code-100-precent/LingEcho/pkg/config"
 	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/middleware"
 	"github.com/code-100-precent/LingEcho/pkg/response"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"github.com/code-100-precent/LingEcho/pkg/voiceprint"
@@ -525,3 +526,16 @@ func (h *Handlers) UploadAudio(c *gin.Context) {
 		"url":        reader.URL,
 	})
 }
+
+// GetUploadProgress returns the progress of an upload sent with the X-Upload-Id header
+func (h *Handlers) GetUploadProgress(c *gin.Context) {
+	progress, ok := middleware.GetUploadProgress(c.Param("id"))
+	if !ok {
+		response.Fail(c, "upload not found", nil)
+		return
+	}
+	response.Success(c, "Get upload progress", gin.H{
+		"progress": progress,
+		"percent":  progress.Percent(),
+	})
+}
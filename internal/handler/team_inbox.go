@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// inboxConversationView adds the live SLA state to a conversation
+func inboxConversationView(conv *models.InboxConversation, now time.Time) gin.H {
+	return gin.H{
+		"conversation": conv,
+		"slaState":     conv.SLAState(),
+		"breached":     conv.Breached(now),
+	}
+}
+
+// ListInboxConversations lists the team inbox across the assistants the user
+// works on, open conversations with the closest SLA deadline first
+// GET /inbox/conversations?assistantId=&status=&source=&assigneeId=&unassigned=&breached=&mine=&page=&pageSize=
+func (h *Handlers) ListInboxConversations(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	filter := models.InboxFilter{
+		Status:     c.Query("status"),
+		Source:     c.Query("source"),
+		Unassigned: c.Query("unassigned") == "true",
+		Breached:   c.Query("breached") == "true",
+	}
+	filter.AssistantID, _ = strconv.ParseInt(c.Query("assistantId"), 10, 64)
+	if assignee, err := strconv.ParseUint(c.Query("assigneeId"), 10, 32); err == nil {
+		filter.AssigneeID = uint(assignee)
+	}
+	if c.Query("mine") == "true" {
+		filter.AssigneeID = user.ID
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	now := time.Now()
+	list, total, counts, err := models.ListInboxConversations(h.db, user.ID, filter, now)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	views := make([]gin.H, 0, len(list))
+	for i := range list {
+		views = append(views, inboxConversationView(&list[i], now))
+	}
+	response.Success(c, "success", gin.H{
+		"list":     views,
+		"total":    total,
+		"counts":   counts,
+		"page":     filter.Page,
+		"pageSize": filter.PageSize,
+	})
+}
+
+// FlagInboxConversation flags a chat session or call recording for the team
+// of its assistant
+// POST /inbox/conversations
+func (h *Handlers) FlagInboxConversation(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return
+	}
+	var req struct {
+		AssistantID     int64  `json:"assistantId" binding:"required"`
+		SessionID       string `json:"sessionId"`
+		CallRecordingID uint   `json:"callRecordingId"`
+		Subject         string `json:"subject"`
+		Reason          string `json:"reason"`
+		Priority        string `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	var assistant models.Assistant
+	if err := h.db.First(&assistant, req.AssistantID).Error; err != nil || !models.CanUseAssistant(h.db, &assistant, user.ID) {
+		response.Fail(c, "assistant not found", nil)
+		return
+	}
+
+	conv := &models.InboxConversation{
+		AssistantID: assistant.ID,
+		UserID:      assistant.UserID,
+		Source:      models.InboxSourceFlag,
+		SessionID:   req.SessionID,
+		Subject:     req.Subject,
+		Reason:      req.Reason,
+		Priority:    req.Priority,
+		CreatedBy:   user.ID,
+	}
+	switch {
+	case req.CallRecordingID != 0:
+		var recording models.CallRecording
+		if err := h.db.Where("id = ? AND assistant_id = ? AND is_deleted = ?", req.CallRecordingID, assistant.ID, false).First(&recording).Error; err != nil {
+			response.Fail(c, "call recording not found", nil)
+			return
+		}
+		conv.CallRecordingID = recording.ID
+		conv.SessionID = recording.SessionID
+		conv.Summary = recording.Summary
+	case req.SessionID != "":
+		var turns int64
+		h.db.Model(&models.ChatSessionLog{}).Where("session_id = ? AND assistant_id = ?", req.SessionID, assistant.ID).Count(&turns)
+		if turns == 0 {
+			response.Fail(c, "session not found", nil)
+			return
+		}
+	}
+
+	conv, created, err := models.OpenInboxConversation(h.db, conv, time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrInboxTarget) || errors.Is(err, models.ErrInboxPriority) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "flag failed", err.Error())
+		return
+	}
+	if created {
+		go h.notifyInboxTeam(conv, &assistant, user.ID, "New conversation in the inbox")
+	}
+	response.Success(c, "flagged", inboxConversationView(conv, time.Now()))
+}
+
+// GetInboxConversation returns a conversation with its notes and the teammates
+// it can be assigned to
+// GET /inbox/conversations/:id
+func (h *Handlers) GetInboxConversation(c *gin.Context) {
+	conv, assistant, ok := h.inboxConversation(c)
+	if !ok {
+		return
+	}
+	notes, err := models.ListInboxNotes(h.db, conv.ID)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	team, err := models.InboxTeamIDs(h.db, assistant)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	var members []models.User
+	h.db.Select("id", "email", "display_name").Where("id IN ?", team).Find(&members)
+
+	view := inboxConversationView(conv, time.Now())
+	view["notes"] = notes
+	view["team"] = members
+	view["sla"] = models.InboxSLAPolicies[conv.Priority]
+	response.Success(c, "success", view)
+}
+
+// UpdateInboxConversation assigns the conversation, changes its status or its
+// priority. Every field is optional; assigneeId 0 unassigns
+// PUT /inbox/conversations/:id
+func (h *Handlers) UpdateInboxConversation(c *gin.Context) {
+	conv, assistant, ok := h.inboxConversation(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		AssigneeID *uint   `json:"assigneeId"`
+		Status     *string `json:"status"`
+		Priority   *string `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	user := models.CurrentUser(c)
+	now := time.Now()
+
+	if req.Priority != nil && *req.Priority != conv.Priority {
+		if err := models.SetInboxPriority(h.db, conv, *req.Priority); err != nil {
+			response.Fail(c, "update failed", err.Error())
+			return
+		}
+	}
+	if req.AssigneeID != nil {
+		previous := conv.AssigneeID
+		if err := models.AssignInboxConversation(h.db, conv, assistant, *req.AssigneeID, now); err != nil {
+			response.Fail(c, "assign failed", err.Error())
+			return
+		}
+		if *req.AssigneeID != 0 && *req.AssigneeID != user.ID && (previous == nil || *previous != *req.AssigneeID) {
+			go models.NotifyInbox(h.db, conv, []uint{*req.AssigneeID},
+				"Conversation assigned to you: "+conv.Subject,
+				fmt.Sprintf("%s assigned you a conversation of %s.", inboxActorName(user), html.EscapeString(assistant.Name)))
+		}
+	}
+	if req.Status != nil && *req.Status != conv.Status {
+		if err := models.SetInboxStatus(h.db, conv, *req.Status, now); err != nil {
+			response.Fail(c, "update failed", err.Error())
+			return
+		}
+	}
+	logger.Info("Inbox conversation updated",
+		zap.Uint("conversationId", conv.ID),
+		zap.Uint("userId", user.ID),
+		zap.String("status", conv.Status))
+	response.Success(c, "updated", inboxConversationView(conv, now))
+}
+
+// AddInboxNote adds an internal note; the assignee is notified
+// POST /inbox/conversations/:id/notes
+func (h *Handlers) AddInboxNote(c *gin.Context) {
+	conv, assistant, ok := h.inboxConversation(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	user := models.CurrentUser(c)
+	note, err := models.AddInboxNote(h.db, conv, user.ID, req.Body, time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrInboxNoteBody) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	if conv.AssigneeID != nil && *conv.AssigneeID != user.ID {
+		go models.NotifyInbox(h.db, conv, []uint{*conv.AssigneeID},
+			"New note on "+conv.Subject,
+			fmt.Sprintf("%s on %s: %s", inboxActorName(user), html.EscapeString(assistant.Name), html.EscapeString(note.Body)))
+	}
+	response.Success(c, "created", note)
+}
+
+// openEscalationInbox puts an escalated call recording in the team inbox and
+// notifies the team when it is new
+func (h *Handlers) openEscalationInbox(recording *models.CallRecording, reason string, createdBy uint) {
+	var assistant models.Assistant
+	if err := h.db.First(&assistant, recording.AssistantID).Error; err != nil {
+		logger.Warn("Failed to load assistant for inbox", zap.Uint("recordingID", recording.ID), zap.Error(err))
+		return
+	}
+	conv, created, err := models.OpenInboxConversation(h.db, &models.InboxConversation{
+		AssistantID:     assistant.ID,
+		UserID:          assistant.UserID,
+		Source:          models.InboxSourceEscalation,
+		CallRecordingID: recording.ID,
+		SessionID:       recording.SessionID,
+		Summary:         recording.Summary,
+		Reason:          reason,
+		CreatedBy:       createdBy,
+	}, time.Now())
+	if err != nil {
+		logger.Error("Failed to open inbox conversation", zap.Uint("recordingID", recording.ID), zap.Error(err))
+		return
+	}
+	if created {
+		h.notifyInboxTeam(conv, &assistant, createdBy, "Conversation escalated to the inbox")
+	}
+}
+
+// notifyInboxTeam notifies the assistant's team about a new conversation, except the user who opened it
+func (h *Handlers) notifyInboxTeam(conv *models.InboxConversation, assistant *models.Assistant, except uint, title string) {
+	team, err := models.InboxTeamIDs(h.db, assistant)
+	if err != nil {
+		logger.Warn("Failed to load inbox team", zap.Int64("assistantID", assistant.ID), zap.Error(err))
+		return
+	}
+	recipients := team[:0]
+	for _, id := range team {
+		if id != except {
+			recipients = append(recipients, id)
+		}
+	}
+	content := html.EscapeString(assistant.Name + ": " + conv.Subject)
+	if conv.Reason != "" {
+		content += "<br/>" + html.EscapeString(conv.Reason)
+	}
+	models.NotifyInbox(h.db, conv, recipients, title, content)
+}
+
+// inboxConversation loads the conversation from :id within the user's inbox
+func (h *Handlers) inboxConversation(c *gin.Context) (*models.InboxConversation, *models.Assistant, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "unauthorized", nil)
+		return nil, nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "invalid id", nil)
+		return nil, nil, false
+	}
+	conv, assistant, err := models.GetInboxConversation(h.db, user.ID, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "conversation not found", nil)
+			return nil, nil, false
+		}
+		response.Fail(c, "query failed", err.Error())
+		return nil, nil, false
+	}
+	return conv, assistant, true
+}
+
+func inboxActorName(user *models.User) string {
+	if user.DisplayName != "" {
+		return html.EscapeString(user.DisplayName)
+	}
+	return html.EscapeString(user.Email)
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type submitCorrectionRequest struct {
+	TurnID int    `json:"turnId" binding:"required"`
+	Text   string `json:"text" binding:"required"`
+}
+
+type reviewCorrectionRequest struct {
+	Status string `json:"status" binding:"required"` // accepted or rejected
+}
+
+type vocabularyRequest struct {
+	Word   string `json:"word" binding:"required"`
+	Weight int    `json:"weight"` // 1-10, default 10
+}
+
+// sessionCorrectionsResponse the corrections of a recording and its turns with
+// the accepted ones applied, masked when the organization policy masks PII
+func (h *Handlers) sessionCorrectionsResponse(c *gin.Context, recording *models.CallRecording) (gin.H, error) {
+	corrections, err := models.ListTranscriptCorrections(h.db, recording.ID)
+	if err != nil {
+		return nil, err
+	}
+	details, err := models.CorrectedConversationDetails(h.db, recording)
+	if err != nil {
+		return nil, err
+	}
+	turns := []models.ConversationTurn{}
+	if details != nil {
+		if types, mask := models.PIIMaskForViewer(h.db, recording, models.CurrentUser(c)); mask {
+			details.MaskPII(types)
+		}
+		turns = details.Turns
+	}
+	return gin.H{
+		"recordingId": recording.ID,
+		"corrections": corrections,
+		"turns":       turns,
+	}, nil
+}
+
+// ListSessionCorrections lists the transcript corrections of the session and
+// its turns with the accepted corrections applied (corrected turns are marked
+// and keep their original text)
+// GET /assistant/:id/sessions/:sessionId/corrections
+func (h *Handlers) ListSessionCorrections(c *gin.Context) {
+	recording, ok := h.sessionRecording(c)
+	if !ok {
+		return
+	}
+	resp, err := h.sessionCorrectionsResponse(c, recording)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", resp)
+}
+
+// SubmitSessionCorrection corrects the transcript of a user turn. The original
+// transcript is kept, the correction applies once accepted; correcting the turn
+// again replaces the previous correction
+// PUT /assistant/:id/sessions/:sessionId/corrections
+func (h *Handlers) SubmitSessionCorrection(c *gin.Context) {
+	recording, ok := h.sessionRecording(c)
+	if !ok {
+		return
+	}
+	var req submitCorrectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	correction, err := models.SubmitTranscriptCorrection(h.db, recording, req.TurnID, req.Text, models.CurrentUser(c).ID)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCorrection) || errors.Is(err, models.ErrUnknownTurn) || errors.Is(err, models.ErrNoConversationDetails) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "success", correction)
+}
+
+// ReviewSessionCorrection accepts or rejects a pending correction. Accepted
+// corrections replace the turn in replays, exports and analysis, and the
+// corrected words are added to the assistant's recognition vocabulary
+// PUT /assistant/:id/sessions/:sessionId/corrections/:correctionId
+func (h *Handlers) ReviewSessionCorrection(c *gin.Context) {
+	recording, ok := h.sessionRecording(c)
+	if !ok {
+		return
+	}
+	var req reviewCorrectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	var correction models.TranscriptCorrection
+	if err := h.db.Where("id = ? AND recording_id = ?", c.Param("correctionId"), recording.ID).First(&correction).Error; err != nil {
+		response.Fail(c, "correction not found", nil)
+		return
+	}
+	if err := models.ReviewTranscriptCorrection(h.db, &correction, req.Status, models.CurrentUser(c).ID, time.Now()); err != nil {
+		if errors.Is(err, models.ErrInvalidReviewStatus) || errors.Is(err, models.ErrCorrectionReviewed) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "success", correction)
+}
+
+// ListAssistantVocabulary lists the words the assistant's speech recognition
+// is boosted for, most confirmed first; at most the first 100 are sent to the
+// recognizer
+// GET /assistant/:id/vocabulary
+func (h *Handlers) ListAssistantVocabulary(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	words, err := models.ListAssistantVocabulary(h.db, assistant.ID, 0)
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", words)
+}
+
+// SaveAssistantVocabulary adds a word to the assistant's vocabulary or changes its weight
+// POST /assistant/:id/vocabulary
+func (h *Handlers) SaveAssistantVocabulary(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	var req vocabularyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if req.Weight == 0 {
+		req.Weight = models.DefaultVocabularyWeight
+	}
+	word, err := models.SaveManualVocabulary(h.db, assistant.ID, req.Word, req.Weight)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidVocabulary) {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		response.Fail(c, "save failed", err.Error())
+		return
+	}
+	response.Success(c, "success", word)
+}
+
+// DeleteAssistantVocabulary removes a word from the assistant's vocabulary
+// DELETE /assistant/:id/vocabulary/:wordId
+func (h *Handlers) DeleteAssistantVocabulary(c *gin.Context) {
+	assistant, ok := h.reviewableAssistant(c)
+	if !ok {
+		return
+	}
+	wordID, err := strconv.ParseUint(c.Param("wordId"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid word id", nil)
+		return
+	}
+	if err := models.DeleteAssistantVocabulary(h.db, assistant.ID, uint(wordID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, "word not found", nil)
+			return
+		}
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "success", nil)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/constants"
 	"github.com/code-100-precent/LingEcho/pkg/logger"
 	"github.com/code-100-precent/LingEcho/pkg/middleware"
+	"github.com/code-100-precent/LingEcho/pkg/plans"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"github.com/code-100-precent/LingEcho/pkg/utils/search"
 	"github.com/code-100-precent/LingEcho/pkg/websocket"
@@ -27,6 +28,8 @@ type Handlers struct {
 	searchHandler     *search.SearchHandlers
 	ipLocationService *utils.IPLocationService
 	sipHandler        *SipHandler
+
+	engine *gin.Engine // set by Register, used to list the routes per API version
 }
 
 // GetSearchHandler gets the search handler (for scheduled tasks)
@@ -96,13 +99,15 @@ func NewHandlers(db *gorm.DB) *Handlers {
 	// Initialize SIP handler (SipServer can be set via SetSipServer method)
 	sipHandler := NewSipHandler(db, nil)
 
-	return &Handlers{
+	h := &Handlers{
 		db:                db,
 		wsHub:             wsHub,
 		searchHandler:     searchHandler,
 		ipLocationService: ipLocationService,
 		sipHandler:        sipHandler,
 	}
+	h.initPresence()
+	return h
 }
 
 // SetSipServer sets SIP server (for dependency injection)
@@ -114,13 +119,8 @@ func (h *Handlers) SetSipServer(sipServer SipServerInterface) {
 
 func (h *Handlers) Register(engine *gin.Engine) {
 
-	r := engine.Group(config.GlobalConfig.Server.APIPrefix)
-
-	// Register Global Singleton DB
-	r.Use(middleware.InjectDB(h.db))
-
-	// Apply global middlewares (rate limiting, timeout, circuit breaker, operation log)
-	middleware.ApplyGlobalMiddlewares(r)
+	// Resolve organization custom domains by Host for branding and mail senders
+	engine.Use(models.WithCustomDomain(h.db))
 
 	// Register routes regardless of whether search is enabled, check in handler methods
 	// If handler is nil, try to initialize
@@ -168,6 +168,62 @@ func (h *Handlers) Register(engine *gin.Engine) {
 	// Set database connection for configuration checking
 	if h.searchHandler != nil {
 		h.searchHandler.SetDB(h.db)
+	}
+
+	apiPrefix := config.GlobalConfig.Server.APIPrefix
+	api := engine.Group(apiPrefix)
+	api.GET("/versions", h.GetAPIVersions)
+
+	// The same routes are served under /v1 and, for existing clients, under the bare
+	// prefix with Deprecation/Sunset headers pointing at their /v1 successor
+	sunset, err := config.GlobalConfig.Server.LegacyAPISunsetTime()
+	if err != nil {
+		logger.Warn("Invalid LEGACY_API_SUNSET, Sunset header disabled", zap.Error(err))
+	}
+	v1 := api.Group("/"+middleware.APIVersionV1, middleware.APIVersion(apiPrefix, middleware.APIVersionV1))
+	legacy := api.Group("", middleware.DeprecatedAPI(middleware.DeprecationConfig{
+		Root:        apiPrefix,
+		Successor:   middleware.APIVersionV1,
+		Sunset:      sunset,
+		VersionsURL: apiPrefix + "/versions",
+	}))
+	for _, r := range []*gin.RouterGroup{v1, legacy} {
+		h.useAPIMiddlewares(r)
+		h.registerAPIRoutes(r)
+	}
+
+	if config.GlobalConfig.Server.DocsPrefix != "" {
+		var objDocs []apidocs.WebObjectDoc
+		for _, obj := range h.GetObjs() {
+			objDocs = append(objDocs, apidocs.GetWebObjectDocDefine(apiPrefix, obj))
+		}
+		apidocs.RegisterHandler(config.GlobalConfig.Server.DocsPrefix, engine, h.GetDocs(), objDocs, h.db)
+	}
+	// The admin console is not part of the public API and stays unversioned
+	if config.GlobalConfig.Server.AdminPrefix != "" {
+		r := api.Group("")
+		h.useAPIMiddlewares(r)
+		admin := r.Group(config.GlobalConfig.Server.AdminPrefix)
+		h.RegisterAdmin(admin)
+	}
+	h.engine = engine
+}
+
+// useAPIMiddlewares applies the middlewares shared by every API route group
+func (h *Handlers) useAPIMiddlewares(r *gin.RouterGroup) {
+	// Register Global Singleton DB
+	r.Use(middleware.InjectDB(h.db))
+
+	// Aggregate requests authenticated with API keys for the usage dashboard
+	r.Use(models.TrackAPIKeyUsage)
+
+	// Apply global middlewares (rate limiting, timeout, circuit breaker, operation log)
+	middleware.ApplyGlobalMiddlewares(r)
+}
+
+// registerAPIRoutes registers the API routes of one version group
+func (h *Handlers) registerAPIRoutes(r *gin.RouterGroup) {
+	if h.searchHandler != nil {
 		logger.Info("Registering search routes")
 		h.searchHandler.RegisterSearchRoutes(r)
 		logger.Info("Search routes registered successfully")
@@ -212,21 +268,44 @@ func (h *Handlers) Register(engine *gin.Engine) {
 	h.registerPhoneNumberRoutes(r)    // Add phone number routes
 	h.registerMCPRoutes(r)            // Add MCP routes
 	h.registerMCPMarketplaceRoutes(r) // Add MCP marketplace routes
+	h.registerCalendarRoutes(r)       // Add calendar integration routes
+	h.registerPresenceRoutes(r)       // Add presence routes
+	h.registerLiveCaptionRoutes(r)    // Add live stream caption routes
+	h.registerDIDRoutes(r)            // Add dial-in number routes
+	h.registerCallSurveyRoutes(r)     // Add post-call survey routes
+	h.registerAuthzPolicyRoutes(r)    // Add authorization policy routes
+	h.registerScimRoutes(r)           // Add SCIM provisioning routes
+	h.registerSamlRoutes(r)           // Add SAML SSO routes
+	h.registerDeviceClaimRoutes(r)    // Add device claim portal routes
+	h.registerSyncRoutes(r)           // Add differential sync routes
+	h.registerSipLoadTestRoutes(r)    // Add SIP load test routes
+	h.registerRecordingHashRoutes(r)  // Add recording digest routes
+	h.registerCustomDomainRoutes(r)   // Add organization custom domain routes
+	h.registerMediaStreamRoutes(r)    // Add external media stream routes
+	h.registerMaintenanceRoutes(r)    // Add fleet maintenance window routes
+	h.registerLLMCacheRoutes(r)       // Add semantic LLM cache routes
+	h.registerLogControlRoutes(r)     // Add runtime log control routes
+	h.registerJobQueueRoutes(r)       // Add job queue dead letter routes
+	h.registerComplianceRoutes(r)     // Add compliance archive export routes
+	h.registerFeatureFlagRoutes(r)    // Add feature flag routes
+	h.registerRegionRoutes(r)         // Add deployment region routes
+	h.registerWebWidgetRoutes(r)      // Add public web-call widget routes
+	h.registerAnnotationRoutes(r)     // Add session annotation routes
+	h.registerSigningKeyRoutes(r)     // Add device action manifest signing key routes
+	h.registerExportRoutes(r)         // Add conversation export routes
+	h.registerSecurityPolicyRoutes(r) // Add organization security policy routes
+	h.registerInboxRoutes(r)          // Add team inbox routes
+	h.registerLoginAnomalyRoutes(r)   // Add login anomaly evaluation routes
+	h.registerWallboardRoutes(r)      // Add call-center wallboard routes
+	h.registerIsolationAuditRoutes(r) // Add tenant isolation audit routes
+	h.registerSubscriptionRoutes(r)   // Add subscription plan routes
+	h.registerDemoTenantRoutes(r)     // Add demo tenant bootstrap routes
+	h.registerPIIRoutes(r)            // Add transcript PII tagging routes
+	h.registerSipCallbackRoutes(r)    // Add queued-call callback routes
+	h.registerCorrectionRoutes(r)     // Add transcript correction and vocabulary routes
 	// Register public workflow routes (no auth required)
 	h.RegisterPublicWorkflowRoutes(r)
-	objs := h.GetObjs()
-	LingEcho.RegisterObjects(r, objs)
-	if config.GlobalConfig.Server.DocsPrefix != "" {
-		var objDocs []apidocs.WebObjectDoc
-		for _, obj := range objs {
-			objDocs = append(objDocs, apidocs.GetWebObjectDocDefine(config.GlobalConfig.Server.APIPrefix, obj))
-		}
-		apidocs.RegisterHandler(config.GlobalConfig.Server.DocsPrefix, engine, h.GetDocs(), objDocs, h.db)
-	}
-	if config.GlobalConfig.Server.AdminPrefix != "" {
-		admin := r.Group(config.GlobalConfig.Server.AdminPrefix)
-		h.RegisterAdmin(admin)
-	}
+	LingEcho.RegisterObjects(r, h.GetObjs())
 }
 
 // registerNotificationRoutes Notification Module
@@ -248,6 +327,12 @@ func (h *Handlers) registerNotificationRoutes(r *gin.RouterGroup) {
 
 		// Get all notification IDs (for select all functionality)
 		notificationGroup.GET("/all-ids", models.AuthRequired, h.handleGetAllNotificationIds)
+
+		// Mobile app push devices
+		notificationGroup.POST("/push-devices", models.AuthRequired, h.RegisterPushDevice)
+		notificationGroup.GET("/push-devices", models.AuthRequired, h.ListPushDevices)
+		notificationGroup.DELETE("/push-devices", models.AuthRequired, h.DeletePushDevice)
+		notificationGroup.DELETE("/push-devices/:id", models.AuthRequired, h.DeletePushDevice)
 	}
 }
 
@@ -263,6 +348,11 @@ func (h *Handlers) registerSystemRoutes(r *gin.RouterGroup) {
 
 		// System initialization route (no auth required)
 		system.GET("/init", h.SystemInit)
+		system.GET("/branding", h.GetSiteBranding)
+
+		// Provider price table for assistant cost estimation
+		system.GET("/price-table", models.AuthRequired, h.GetPriceTable)
+		system.PUT("/price-table", models.AuthRequired, models.WithAdminAuth(), h.UpdatePriceTable)
 
 		// Voice clone configuration routes
 		system.POST("/voice-clone/config", models.AuthRequired, h.SaveVoiceCloneConfig)
@@ -272,6 +362,7 @@ func (h *Handlers) registerSystemRoutes(r *gin.RouterGroup) {
 
 		// Audio file upload route
 		system.POST("/upload/audio", h.UploadAudio)
+		system.GET("/upload/progress/:id", h.GetUploadProgress)
 
 		// Search configuration routes
 		system.GET("/search/status", h.GetSearchStatus)
@@ -315,13 +406,21 @@ func (h *Handlers) registerDeviceRoutes(r *gin.RouterGroup) {
 	// Get device configuration interface (no authentication required, for xiaozhi-server calls)
 	device.GET("/config/:deviceId", h.GetDeviceConfig)
 
+	// Diagnostics bundle upload from the device, authorized by the one-time token in the path
+	device.POST("/diagnostics/upload/:token", h.UploadDeviceDiagnostics)
+
+	// Signed action manifests, identified by the Device-Id header like the OTA check
+	device.GET("/action-keys", h.GetDeviceActionKeys)
+	device.GET("/action-manifests/pending", h.GetPendingActionManifests)
+	device.POST("/action-manifests/:manifestId/receipt", h.PostActionManifestReceipt)
+
 	device.Use(models.AuthRequired) // Requires user login
 	{
 		// Bind device (activate device) - completely consistent with xiaozhi-esp32 path
-		device.POST("/bind/:agentId/:deviceCode", h.BindDevice)
+		device.POST("/bind/:agentId/:deviceCode", models.EmailVerificationRequired(models.EmailActionBindDevice), h.BindDevice)
 
 		// Get bound devices
-		device.GET("/bind/:agentId", h.GetUserDevices)
+		device.GET("/bind/:agentId", middleware.ResponseCache(responseCacheDevices, responseCacheDevicesTTL), h.GetUserDevices)
 
 		// Unbind device
 		device.POST("/unbind", h.UnbindDevice)
@@ -330,26 +429,57 @@ func (h *Handlers) registerDeviceRoutes(r *gin.RouterGroup) {
 		device.PUT("/update/:id", h.UpdateDeviceInfo)
 
 		// Manually add device
-		device.POST("/manual-add", h.ManualAddDevice)
+		device.POST("/manual-add", models.EmailVerificationRequired(models.EmailActionBindDevice), h.ManualAddDevice)
 
 		// Device monitoring and management
-		device.GET("/:deviceId", h.GetDeviceDetail)                       // Get device detail
-		device.GET("/:deviceId/error-logs", h.GetDeviceErrorLogs)         // Get device error logs
-		device.POST("/error-logs/:errorId/resolve", h.ResolveDeviceError) // Resolve device error
-		device.GET("/call-recordings", h.GetCallRecordings)               // Get call recordings
-		device.GET("/call-recordings/:id", h.GetCallRecordingDetail)      // Get call recording detail
+		// Device geolocation
+		device.POST("/location", h.ReportDeviceLocation)         // Report device location
+		device.GET("/map", h.GetDeviceMap)                       // Located devices for map view
+		device.GET("/geofences", h.ListDeviceGeofences)          // List geofences
+		device.POST("/geofences", h.SaveDeviceGeofence)          // Create geofence
+		device.PUT("/geofences/:id", h.SaveDeviceGeofence)       // Update geofence
+		device.DELETE("/geofences/:id", h.DeleteDeviceGeofence)  // Delete geofence
+		device.GET("/:deviceId/locations", h.GetDeviceLocations) // Device location history
+
+		device.POST("/:deviceId/diagnostics", h.RequestDeviceDiagnostics)                    // Ask the device for a diagnostics bundle
+		device.GET("/:deviceId/diagnostics", h.ListDeviceDiagnostics)                        // List diagnostics bundles
+		device.GET("/:deviceId/diagnostics/:bundleId/download", h.DownloadDeviceDiagnostics) // Download diagnostics archive
+
+		device.POST("/commands", h.ExecuteDeviceCommand)             // Run a text or intent device command
+		device.POST("/commands/:id/confirm", h.ConfirmDeviceCommand) // Confirm or cancel a destructive command
+		device.GET("/commands", h.ListDeviceCommands)                // Device command log
+
+		device.GET("/action-specs", h.ListDeviceActionSpecs)                     // Actions a manifest may carry
+		device.POST("/:deviceId/action-manifests", h.CreateDeviceActionManifest) // Build and sign an action manifest
+		device.GET("/:deviceId/action-manifests", h.ListDeviceActionManifests)   // Manifests and execution receipts
+
+		// Microphone quality tests
+		device.POST("/audio-test", h.UploadDeviceAudioTest)                  // Upload and analyze a test recording
+		device.GET("/audio-quality/flagged", h.GetDevicesNeedingReplacement) // Devices flagged for replacement
+		device.GET("/:deviceId/audio-tests", h.ListDeviceAudioTests)         // Microphone test history
+
+		device.GET("/:deviceId", h.GetDeviceDetail)                                                                    // Get device detail
+		device.GET("/:deviceId/error-logs", h.GetDeviceErrorLogs)                                                      // Get device error logs
+		device.GET("/:deviceId/custom-fields", h.GetDeviceCustomFields)                                                // Get device custom fields
+		device.PUT("/:deviceId/custom-fields", h.UpdateDeviceCustomFields)                                             // Update device custom fields
+		device.POST("/error-logs/:errorId/resolve", h.ResolveDeviceError)                                              // Resolve device error
+		device.GET("/call-recordings", models.PolicyRequired("call_recordings", "list"), h.GetCallRecordings)          // Get call recordings
+		device.GET("/call-recordings/:id", models.PolicyRequired("call_recordings", "read"), h.GetCallRecordingDetail) // Get call recording detail
 
 		// AI分析相关路由
-		device.POST("/call-recordings/:id/analyze", h.AnalyzeCallRecording)         // 分析单个录音
-		device.POST("/call-recordings/batch-analyze", h.BatchAnalyzeCallRecordings) // 批量分析录音
-		device.GET("/call-recordings/:id/analysis", h.GetCallRecordingAnalysis)     // 获取分析结果
+		device.POST("/call-recordings/:id/analyze", models.PolicyRequired("call_recordings", "analyze"), h.AnalyzeCallRecording)         // 分析单个录音
+		device.POST("/call-recordings/batch-analyze", models.PolicyRequired("call_recordings", "analyze"), h.BatchAnalyzeCallRecordings) // 批量分析录音
+		device.GET("/call-recordings/:id/analysis", models.PolicyRequired("call_recordings", "read"), h.GetCallRecordingAnalysis)        // 获取分析结果
+		device.POST("/call-recordings/:id/escalate", models.PolicyRequired("call_recordings", "escalate"), h.EscalateCallRecording)      // 创建升级工单
+		device.GET("/call-recordings/:id/escalations", models.PolicyRequired("call_recordings", "read"), h.GetCallRecordingEscalations)  // 获取升级工单
+		device.POST("/call-recordings/:id/verify", models.PolicyRequired("call_recordings", "read"), h.VerifyCallRecording)              // 校验录音哈希
 
 		// Device status updates (for hardware devices to report status)
 		device.POST("/status", h.UpdateDeviceStatus) // Update device status
 		device.POST("/error", h.LogDeviceError)      // Log device error
 
 		// Recording file access
-		device.GET("/recordings/*filepath", h.ServeRecordingFile) // Serve recording files
+		device.GET("/recordings/*filepath", models.PolicyRequired("recording_files", "read"), h.ServeRecordingFile) // Serve recording files
 	}
 }
 
@@ -359,7 +489,7 @@ func (h *Handlers) registerGroupRoutes(r *gin.RouterGroup) {
 	group.Use(models.AuthRequired)
 	{
 		// Organization management
-		group.POST("", h.CreateGroup)
+		group.POST("", models.EmailVerificationRequired(models.EmailActionCreateGroup), h.CreateGroup)
 		group.GET("", h.ListGroups)
 
 		// Search users - must be before /:id
@@ -378,6 +508,7 @@ func (h *Handlers) registerGroupRoutes(r *gin.RouterGroup) {
 
 		// Organization statistics - must be registered before /:id
 		group.GET("/:id/statistics", h.GetGroupStatistics)
+		group.GET("/:id/login-locations", h.GetGroupLoginLocationHeatmap)
 
 		// Organization member management - must be registered before /:id
 		group.POST("/:id/leave", h.LeaveGroup)
@@ -387,6 +518,12 @@ func (h *Handlers) registerGroupRoutes(r *gin.RouterGroup) {
 		// Invite users - must be registered before /:id
 		group.POST("/:id/invite", h.InviteUser)
 
+		// Custom field definitions - must be registered before /:id
+		group.GET("/:id/custom-fields", h.ListCustomFieldDefinitions)
+		group.POST("/:id/custom-fields", h.CreateCustomFieldDefinition)
+		group.PUT("/:id/custom-fields/:fieldId", h.UpdateCustomFieldDefinition)
+		group.DELETE("/:id/custom-fields/:fieldId", h.DeleteCustomFieldDefinition)
+
 		// Get organization shared resources - must be registered before /:id
 		group.GET("/:id/resources", h.GetGroupSharedResources)
 
@@ -445,20 +582,30 @@ func (h *Handlers) registerAlertRoutes(r *gin.RouterGroup) {
 func (h *Handlers) registerAssistantRoutes(r *gin.RouterGroup) {
 	assistant := r.Group("assistant")
 	{
-		assistant.POST("add", models.AuthRequired, h.CreateAssistant)
+		assistant.POST("add", models.AuthRequired, models.EmailVerificationRequired(models.EmailActionCreateAssistant), h.CreateAssistant)
+
+		assistant.GET("", models.AuthRequired, middleware.ResponseCache(responseCacheAssistants, responseCacheAssistantsTTL), h.ListAssistants)
 
-		assistant.GET("", models.AuthRequired, h.ListAssistants)
+		assistant.POST("/estimate-cost", models.AuthRequired, h.EstimateCost)
+
+		assistant.POST("/:id/estimate-cost", models.AuthRequired, h.EstimateAssistantCost)
 
 		assistant.GET("/:id", models.AuthRequired, h.GetAssistant)
 
 		assistant.GET("/:id/graph", models.AuthRequired, h.GetAssistantGraphData)
 
+		assistant.GET("/:id/sessions", models.AuthRequired, h.GetAssistantSessionStats)
+
 		assistant.PUT("/:id", models.AuthRequired, h.UpdateAssistant)
 
 		assistant.DELETE("/:id", models.AuthRequired, h.DeleteAssistant)
 
 		assistant.PUT("/:id/js", models.AuthRequired, h.UpdateAssistantJS)
 
+		assistant.GET("/:id/custom-fields", models.AuthRequired, h.GetAssistantCustomFields)
+
+		assistant.PUT("/:id/custom-fields", models.AuthRequired, h.UpdateAssistantCustomFields)
+
 		assistant.GET("/lingecho/client/:id/loader.js", h.ServeVoiceSculptorLoaderJS)
 
 		// Assistant Tools management routes
@@ -471,6 +618,57 @@ func (h *Handlers) registerAssistantRoutes(r *gin.RouterGroup) {
 		assistant.DELETE("/:id/tools/:toolId", models.AuthRequired, h.DeleteAssistantTool)
 
 		assistant.POST("/:id/tools/:toolId/test", models.AuthRequired, h.TestAssistantTool)
+
+		// Escalation connectors (ticketing integrations)
+		assistant.GET("/:id/escalation-connectors", models.AuthRequired, h.ListEscalationConnectors)
+		assistant.POST("/:id/escalation-connectors", models.AuthRequired, h.CreateEscalationConnector)
+		assistant.PUT("/:id/escalation-connectors/:connectorId", models.AuthRequired, h.UpdateEscalationConnector)
+		assistant.DELETE("/:id/escalation-connectors/:connectorId", models.AuthRequired, h.DeleteEscalationConnector)
+
+		// Calendar binding and tool scopes
+		assistant.GET("/:id/calendar", models.AuthRequired, h.GetAssistantCalendar)
+		assistant.PUT("/:id/calendar", models.AuthRequired, h.SaveAssistantCalendar)
+		assistant.DELETE("/:id/calendar", models.AuthRequired, h.DeleteAssistantCalendar)
+
+		// CRM caller lookup for personalized inbound greetings
+		assistant.GET("/:id/caller-lookup", models.AuthRequired, h.GetCallerLookup)
+		assistant.PUT("/:id/caller-lookup", models.AuthRequired, h.SaveCallerLookup)
+		assistant.DELETE("/:id/caller-lookup", models.AuthRequired, h.DeleteCallerLookup)
+		assistant.POST("/:id/caller-lookup/test", models.AuthRequired, h.TestCallerLookup)
+		assistant.GET("/:id/caller-lookup/logs", models.AuthRequired, h.ListCallerLookupLogs)
+
+		// Sharing with organizations and per-member usage
+		assistant.GET("/:id/shares", models.AuthRequired, h.ListAssistantShares)
+		assistant.PUT("/:id/shares", models.AuthRequired, h.ShareAssistant)
+		assistant.DELETE("/:id/shares/:groupId", models.AuthRequired, h.UnshareAssistant)
+		assistant.GET("/:id/shares/usage", models.AuthRequired, h.GetAssistantShareUsage)
+
+		// QA: session replay, reviewer annotations and quality reports
+		assistant.GET("/:id/sessions/:sessionId/replay", models.AuthRequired, h.GetSessionReplay)
+		assistant.GET("/:id/sessions/:sessionId/reviews", models.AuthRequired, h.ListQAReviews)
+		assistant.POST("/:id/sessions/:sessionId/reviews", models.AuthRequired, h.CreateQAReview)
+		assistant.DELETE("/:id/reviews/:reviewId", models.AuthRequired, h.DeleteQAReview)
+		assistant.GET("/:id/quality-report", models.AuthRequired, h.GetAssistantQualityReport)
+		assistant.GET("/:id/sessions/:sessionId/annotations", models.AuthRequired, h.ListSessionAnnotations)
+		assistant.POST("/:id/sessions/:sessionId/annotations", models.AuthRequired, h.CreateSessionAnnotation)
+		assistant.PUT("/:id/sessions/:sessionId/speakers", models.AuthRequired, h.RelabelSessionSpeakers)
+		assistant.DELETE("/:id/annotations/:annotationId", models.AuthRequired, h.DeleteSessionAnnotation)
+		assistant.POST("/:id/annotations/:annotationId/share", models.AuthRequired, h.ShareSessionAnnotation)
+		assistant.POST("/:id/annotations/:annotationId/share/revoke", models.AuthRequired, h.RevokeSessionAnnotationLinks)
+
+		// Embeddable web-call widgets
+		assistant.GET("/:id/widgets", models.AuthRequired, h.ListWebWidgets)
+		assistant.POST("/:id/widgets", models.AuthRequired, h.CreateWebWidget)
+		assistant.PUT("/:id/widgets/:widgetId", models.AuthRequired, h.UpdateWebWidget)
+		assistant.DELETE("/:id/widgets/:widgetId", models.AuthRequired, h.DeleteWebWidget)
+		assistant.GET("/:id/widgets/:widgetId/usage", models.AuthRequired, h.GetWebWidgetUsage)
+
+		// Prompt canary releases with automatic rollback
+		assistant.GET("/:id/prompt-releases", models.AuthRequired, h.ListPromptReleases)
+		assistant.POST("/:id/prompt-releases", models.AuthRequired, h.StartPromptRelease)
+		assistant.GET("/:id/prompt-releases/:releaseId", models.AuthRequired, h.GetPromptRelease)
+		assistant.POST("/:id/prompt-releases/:releaseId/promote", models.AuthRequired, h.PromotePromptRelease)
+		assistant.POST("/:id/prompt-releases/:releaseId/rollback", models.AuthRequired, h.RollbackPromptRelease)
 	}
 }
 
@@ -527,11 +725,467 @@ func (h *Handlers) registerChatRoutes(r *gin.RouterGroup) {
 func (h *Handlers) registerCredentialsRoutes(r *gin.RouterGroup) {
 	credential := r.Group("credentials")
 	{
-		credential.POST("/", models.AuthRequired, h.handleCreateCredential)
+		credential.POST("/", models.AuthRequired, models.EmailVerificationRequired(models.EmailActionCreateCredential), h.handleCreateCredential)
 
 		credential.GET("/", models.AuthRequired, h.handleGetCredential)
 
 		credential.DELETE("/:id", models.AuthRequired, h.handleDeleteCredential)
+
+		credential.GET("/usage", models.AuthRequired, h.handleGetCredentialUsage)
+		credential.GET("/:id/usage", models.AuthRequired, h.handleGetCredentialUsage)
+	}
+}
+
+// registerCalendarRoutes Calendar integration Module
+func (h *Handlers) registerCalendarRoutes(r *gin.RouterGroup) {
+	cal := r.Group("calendar")
+	{
+		cal.GET("/credentials", models.AuthRequired, h.ListCalendarCredentials)
+		cal.DELETE("/credentials/:id", models.AuthRequired, h.DeleteCalendarCredential)
+		cal.POST("/caldav", models.AuthRequired, h.CreateCalDAVCredential)
+		cal.GET("/google/authorize", models.AuthRequired, h.GoogleCalendarAuthorize)
+		// OAuth redirect target, the user is resolved from the state parameter
+		cal.GET("/google/callback", h.GoogleCalendarCallback)
+	}
+}
+
+// registerPresenceRoutes Presence Module, changes are pushed over /ws as "presence" messages
+func (h *Handlers) registerPresenceRoutes(r *gin.RouterGroup) {
+	p := r.Group("presence")
+	p.Use(models.AuthRequired)
+	{
+		p.GET("/me", h.GetMyPresence)
+		p.PUT("/me", h.SetMyPresence)
+		p.PUT("/sip/:username", h.SetAgentPresence)
+		p.GET("/group/:id", h.GetGroupPresence)
+	}
+}
+
+// registerLiveCaptionRoutes Live stream captions Module
+func (h *Handlers) registerLiveCaptionRoutes(r *gin.RouterGroup) {
+	captions := r.Group("live/captions")
+	{
+		captions.POST("/:bucket/:stream", models.AuthRequired, h.StartLiveCaptions)
+		captions.GET("/:bucket/:stream", models.AuthRequired, h.GetLiveCaptionsStatus)
+		captions.DELETE("/:bucket/:stream", models.AuthRequired, h.StopLiveCaptions)
+		// Read by players, as public as the whep playback itself
+		captions.GET("/:bucket/:stream/captions.vtt", h.GetLiveCaptionsWebVTT)
+		captions.GET("/:bucket/:stream/ws", h.HandleLiveCaptionsWebSocket)
+	}
+}
+
+// registerDIDRoutes Dial-in number (DID) Module
+func (h *Handlers) registerDIDRoutes(r *gin.RouterGroup) {
+	dids := r.Group("dids")
+	dids.Use(models.AuthRequired)
+	{
+		dids.GET("", h.ListDIDs)
+		dids.POST("", models.PlanFeatureRequired(plans.FeatureSIPTrunking), h.CreateDID)
+		dids.POST("/import", models.PlanFeatureRequired(plans.FeatureSIPTrunking), h.ImportDIDs)
+		dids.GET("/:id", h.GetDID)
+		dids.PUT("/:id", h.UpdateDID)
+		dids.DELETE("/:id", h.DeleteDID)
+		dids.GET("/:id/stats", h.GetDIDStats)
+	}
+}
+
+// registerCallSurveyRoutes Post-call satisfaction survey Module
+func (h *Handlers) registerCallSurveyRoutes(r *gin.RouterGroup) {
+	surveys := r.Group("surveys")
+	surveys.Use(models.AuthRequired)
+	{
+		surveys.GET("/settings", h.GetCallSurveySettings)
+		surveys.PUT("/settings", h.UpdateCallSurveySettings)
+		surveys.GET("/report", h.GetSatisfactionReport)
+		surveys.GET("/calls/:callId", h.GetCallSurvey)
+	}
+}
+
+// registerAuthzPolicyRoutes Authorization policy Module (admin only)
+func (h *Handlers) registerAuthzPolicyRoutes(r *gin.RouterGroup) {
+	authz := r.Group("authz")
+	authz.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		authz.GET("/policies", h.ListAuthzPolicies)
+		authz.POST("/policies", h.CreateAuthzPolicy)
+		authz.GET("/policies/:id", h.GetAuthzPolicy)
+		authz.PUT("/policies/:id", h.UpdateAuthzPolicy)
+		authz.DELETE("/policies/:id", h.DeleteAuthzPolicy)
+		authz.POST("/evaluate", h.EvaluateAuthzPolicy)
+	}
+}
+
+// registerScimRoutes SCIM 2.0 provisioning Module
+func (h *Handlers) registerScimRoutes(r *gin.RouterGroup) {
+	tokens := r.Group("scim/tokens")
+	tokens.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		tokens.GET("", h.ListScimTokens)
+		tokens.POST("", h.CreateScimToken)
+		tokens.PUT("/:id/mapping", h.UpdateScimTokenMapping)
+		tokens.DELETE("/:id", h.RevokeScimToken)
+	}
+
+	v2 := r.Group("scim/v2")
+	v2.Use(models.ScimAuthRequired)
+	{
+		v2.GET("/ServiceProviderConfig", h.ScimServiceProviderConfig)
+		v2.GET("/ResourceTypes", h.ScimResourceTypes)
+		v2.GET("/Schemas", h.ScimSchemas)
+
+		v2.GET("/Users", h.ScimListUsers)
+		v2.POST("/Users", h.ScimCreateUser)
+		v2.GET("/Users/:id", h.ScimGetUser)
+		v2.PUT("/Users/:id", h.ScimReplaceUser)
+		v2.PATCH("/Users/:id", h.ScimPatchUser)
+		v2.DELETE("/Users/:id", h.ScimDeleteUser)
+
+		v2.GET("/Groups", h.ScimListGroups)
+		v2.POST("/Groups", h.ScimCreateGroup)
+		v2.GET("/Groups/:id", h.ScimGetGroup)
+		v2.PUT("/Groups/:id", h.ScimReplaceGroup)
+		v2.PATCH("/Groups/:id", h.ScimPatchGroup)
+		v2.DELETE("/Groups/:id", h.ScimDeleteGroup)
+
+		v2.POST("/Bulk", h.ScimBulk)
+	}
+}
+
+// registerSamlRoutes SAML 2.0 single sign-on Module
+func (h *Handlers) registerSamlRoutes(r *gin.RouterGroup) {
+	sso := r.Group(config.GlobalConfig.Server.AuthPrefix + "/saml")
+	{
+		sso.GET("/discover", h.SamlDiscover)
+		sso.GET("/:groupId/metadata", h.SamlMetadata)
+		sso.GET("/:groupId/login", h.SamlLogin)
+		sso.POST("/:groupId/acs", h.SamlACS)
+	}
+
+	group := r.Group("group")
+	group.Use(models.AuthRequired)
+	{
+		group.GET("/:id/saml", h.GetSamlConnection)
+		group.PUT("/:id/saml", h.UpdateSamlConnection)
+		group.DELETE("/:id/saml", h.DeleteSamlConnection)
+		group.PUT("/:id/saml/domains", models.WithAdminAuth(), h.UpdateSamlDomains)
+		group.GET("/:id/saml/events", h.ListSSOEvents)
+	}
+}
+
+// registerDeviceClaimRoutes Device claim portal Module for field technicians signed in through organization SSO
+func (h *Handlers) registerDeviceClaimRoutes(r *gin.RouterGroup) {
+	portal := r.Group("device-claim")
+	{
+		portal.GET("/session", models.TechnicianAuthRequired(""), h.GetTechnicianSession)
+		portal.GET("/assistants", models.TechnicianAuthRequired(models.TechnicianScopeClaim), h.ListClaimAssistants)
+		portal.POST("/claims", models.TechnicianAuthRequired(models.TechnicianScopeClaim), h.ClaimDevice)
+		portal.GET("/claims", models.TechnicianAuthRequired(models.TechnicianScopeClaim), h.ListMyDeviceClaims)
+		portal.GET("/devices/:deviceId/diagnostics", models.TechnicianAuthRequired(models.TechnicianScopeDiagnostics), h.GetClaimDeviceDiagnostics)
+	}
+
+	group := r.Group("group")
+	group.Use(models.AuthRequired)
+	{
+		group.GET("/:id/device-claims", h.ListGroupDeviceClaims)
+		group.DELETE("/:id/device-claims/:claimId", h.RevokeGroupDeviceClaim)
+		group.GET("/:id/technician-tokens", h.ListTechnicianTokens)
+		group.DELETE("/:id/technician-tokens/:tokenId", h.RevokeTechnicianToken)
+		group.DELETE("/:id/technicians/:userId/tokens", h.RevokeTechnicianAccess)
+	}
+}
+
+// registerSyncRoutes Differential sync Module for mobile and edge clients
+func (h *Handlers) registerSyncRoutes(r *gin.RouterGroup) {
+	r.GET("/sync", models.AuthRequired, h.GetSyncChanges)
+}
+
+// registerSipLoadTestRoutes SIP load/soak test and hot standby failover drills (admin only)
+func (h *Handlers) registerSipLoadTestRoutes(r *gin.RouterGroup) {
+	loadTest := r.Group("sip/loadtest")
+	loadTest.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		loadTest.POST("", h.StartSipLoadTest)
+		loadTest.GET("", h.GetSipLoadTest)
+		loadTest.DELETE("", h.StopSipLoadTest)
+	}
+
+	ha := r.Group("sip/ha")
+	ha.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		ha.GET("", h.GetSipHAStatus)
+		ha.POST("/drill", h.StartSipFailoverDrill)
+	}
+
+	incidents := r.Group("sip/provider-incidents")
+	incidents.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		incidents.GET("", h.ListProviderIncidents)
+	}
+}
+
+// registerLoginAnomalyRoutes login anomaly model shadow evaluation (admin only)
+func (h *Handlers) registerLoginAnomalyRoutes(r *gin.RouterGroup) {
+	anomaly := r.Group("login-anomaly")
+	anomaly.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		anomaly.GET("/report", h.GetLoginAnomalyShadowReport)
+	}
+}
+
+// registerWallboardRoutes live call-center wallboard for supervisors (admin only)
+func (h *Handlers) registerWallboardRoutes(r *gin.RouterGroup) {
+	board := r.Group("wallboard")
+	board.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		board.GET("", h.GetWallboard)
+		board.GET("/calls", h.GetWallboardCalls)
+		board.GET("/queues", h.GetWallboardQueues)
+		board.GET("/agents", h.GetWallboardAgents)
+		board.GET("/kpis", h.GetWallboardKPIs)
+	}
+}
+
+// registerIsolationAuditRoutes cross-tenant data isolation audit (admin only)
+func (h *Handlers) registerIsolationAuditRoutes(r *gin.RouterGroup) {
+	audit := r.Group("isolation-audit")
+	audit.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		audit.POST("", h.RunIsolationAudit)
+	}
+}
+
+// registerSubscriptionRoutes Subscription plans and organization subscriptions Module
+func (h *Handlers) registerSubscriptionRoutes(r *gin.RouterGroup) {
+	r.GET("/plans", models.AuthRequired, h.ListPlans)
+
+	group := r.Group("group")
+	group.Use(models.AuthRequired)
+	{
+		group.GET("/:id/subscription", h.GetGroupSubscription)
+		group.PUT("/:id/subscription", models.WithAdminAuth(), h.ChangeGroupPlan)
+		group.POST("/:id/subscription/trial", h.StartGroupTrial)
+		group.GET("/:id/subscription/events", h.ListSubscriptionEvents)
+	}
+}
+
+// registerDemoTenantRoutes demo tenant bootstrap for non-production environments (admin only)
+func (h *Handlers) registerDemoTenantRoutes(r *gin.RouterGroup) {
+	r.POST("/demo-tenant", models.AuthRequired, models.WithAdminAuth(), h.SeedDemoTenant)
+}
+
+// registerPIIRoutes transcript PII tagging and review, and organization detection metrics
+func (h *Handlers) registerPIIRoutes(r *gin.RouterGroup) {
+	r.POST("/assistant/:id/sessions/:sessionId/pii/detect", models.AuthRequired, h.DetectSessionPII)
+	r.PUT("/assistant/:id/sessions/:sessionId/pii", models.AuthRequired, h.ReviewSessionPII)
+	r.GET("/group/:id/pii/metrics", models.AuthRequired, h.GetGroupPIIMetrics)
+}
+
+// registerSipCallbackRoutes callbacks offered to queued SIP callers and their outcomes
+func (h *Handlers) registerSipCallbackRoutes(r *gin.RouterGroup) {
+	callbacks := r.Group("sip-callbacks")
+	callbacks.Use(models.AuthRequired)
+	{
+		callbacks.GET("", h.ListSipCallbacks)
+		callbacks.GET("/report", h.GetSipCallbackReport)
+	}
+}
+
+// registerCorrectionRoutes transcript corrections and the assistant recognition vocabulary they feed
+func (h *Handlers) registerCorrectionRoutes(r *gin.RouterGroup) {
+	r.GET("/assistant/:id/sessions/:sessionId/corrections", models.AuthRequired, h.ListSessionCorrections)
+	r.PUT("/assistant/:id/sessions/:sessionId/corrections", models.AuthRequired, h.SubmitSessionCorrection)
+	r.PUT("/assistant/:id/sessions/:sessionId/corrections/:correctionId", models.AuthRequired, h.ReviewSessionCorrection)
+	r.GET("/assistant/:id/vocabulary", models.AuthRequired, h.ListAssistantVocabulary)
+	r.POST("/assistant/:id/vocabulary", models.AuthRequired, h.SaveAssistantVocabulary)
+	r.DELETE("/assistant/:id/vocabulary/:wordId", models.AuthRequired, h.DeleteAssistantVocabulary)
+}
+
+// registerRecordingHashRoutes daily recording hash digests (admin only)
+func (h *Handlers) registerRecordingHashRoutes(r *gin.RouterGroup) {
+	digests := r.Group("recording-digests")
+	digests.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		digests.GET("", h.ListRecordingDigests)
+		digests.GET("/:date", h.GetRecordingDigest)
+		digests.POST("/:date/anchor", h.AnchorRecordingDigest)
+	}
+}
+
+// registerSigningKeyRoutes device action manifest signing keys (admin only)
+func (h *Handlers) registerSigningKeyRoutes(r *gin.RouterGroup) {
+	keys := r.Group("device-signing-keys")
+	keys.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		keys.GET("", h.ListDeviceSigningKeys)
+		keys.POST("/rotate", h.RotateDeviceSigningKey)
+	}
+}
+
+// registerExportRoutes conversation exports in JSON, SRT, VTT and DOCX
+func (h *Handlers) registerExportRoutes(r *gin.RouterGroup) {
+	exports := r.Group("conversation-exports")
+	{
+		exports.POST("", models.AuthRequired, h.CreateConversationExport)
+		exports.GET("", models.AuthRequired, h.ListConversationExports)
+		exports.GET("/:id", models.AuthRequired, h.GetConversationExport)
+		// Signed link from the notification or the logged-in requester
+		exports.GET("/:id/download", h.DownloadConversationExport)
+	}
+}
+
+// registerSecurityPolicyRoutes Organization security policy Module
+func (h *Handlers) registerSecurityPolicyRoutes(r *gin.RouterGroup) {
+	group := r.Group("group")
+	group.Use(models.AuthRequired)
+	{
+		group.GET("/:id/security-policy", h.GetSecurityPolicy)
+		group.PUT("/:id/security-policy", h.UpdateSecurityPolicy)
+		group.GET("/:id/security-policy/report", h.GetSecurityPolicyReport)
+	}
+}
+
+// registerInboxRoutes Team inbox Module
+func (h *Handlers) registerInboxRoutes(r *gin.RouterGroup) {
+	inbox := r.Group("inbox")
+	inbox.Use(models.AuthRequired)
+	{
+		inbox.GET("/conversations", h.ListInboxConversations)
+		inbox.POST("/conversations", h.FlagInboxConversation)
+		inbox.GET("/conversations/:id", h.GetInboxConversation)
+		inbox.PUT("/conversations/:id", h.UpdateInboxConversation)
+		inbox.POST("/conversations/:id/notes", h.AddInboxNote)
+	}
+}
+
+// registerCustomDomainRoutes organization custom domains and white-labeling
+func (h *Handlers) registerCustomDomainRoutes(r *gin.RouterGroup) {
+	group := r.Group("group")
+	group.Use(models.AuthRequired)
+	{
+		group.GET("/:id/domains", h.ListCustomDomains)
+		group.POST("/:id/domains", h.CreateCustomDomain)
+		group.POST("/:id/domains/:domainId/verify", h.VerifyCustomDomain)
+		group.PUT("/:id/domains/:domainId/branding", h.UpdateCustomDomainBranding)
+		group.DELETE("/:id/domains/:domainId", h.DeleteCustomDomain)
+	}
+}
+
+// registerMediaStreamRoutes two-way call audio for external media services
+func (h *Handlers) registerMediaStreamRoutes(r *gin.RouterGroup) {
+	streams := r.Group("media-streams")
+	// Authorized by the single-use token issued below
+	streams.GET("/ws", h.StreamCallMedia)
+	streams.GET("/calls", models.AuthRequired, models.WithAdminAuth(), h.ListMediaStreamCalls)
+	streams.POST("/tokens", models.AuthRequired, models.WithAdminAuth(), h.CreateMediaStreamToken)
+}
+
+// registerMaintenanceRoutes fleet maintenance windows and gated device actions
+func (h *Handlers) registerMaintenanceRoutes(r *gin.RouterGroup) {
+	group := r.Group("group")
+	group.Use(models.AuthRequired)
+	{
+		group.GET("/:id/maintenance-windows", h.ListMaintenanceWindows)
+		group.POST("/:id/maintenance-windows", h.CreateMaintenanceWindow)
+		group.PUT("/:id/maintenance-windows/:windowId", h.UpdateMaintenanceWindow)
+		group.DELETE("/:id/maintenance-windows/:windowId", h.DeleteMaintenanceWindow)
+		group.GET("/:id/device-actions", h.ListDeviceActions)
+		group.POST("/:id/device-actions", h.CreateDeviceAction)
+		group.DELETE("/:id/device-actions/:actionId", h.CancelDeviceAction)
+	}
+}
+
+// registerLogControlRoutes runtime log levels, sampling and elevated logging windows (admin only)
+func (h *Handlers) registerLogControlRoutes(r *gin.RouterGroup) {
+	logs := r.Group("system/log-control")
+	logs.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		logs.GET("", h.GetLogControl)
+		logs.PUT("", h.UpdateLogControl)
+		logs.POST("/elevate", h.ElevateLogLevel)
+		logs.DELETE("/elevate", h.CancelLogElevation)
+	}
+}
+
+// registerJobQueueRoutes dead letter inspection and requeueing of notification jobs (admin only)
+func (h *Handlers) registerJobQueueRoutes(r *gin.RouterGroup) {
+	jobs := r.Group("system/jobs")
+	jobs.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		jobs.GET("/dead", h.ListDeadJobs)
+		jobs.GET("/:id", h.GetJob)
+		jobs.POST("/:id/requeue", h.RequeueJob)
+	}
+}
+
+// registerLLMCacheRoutes semantic LLM cache statistics and invalidation
+func (h *Handlers) registerLLMCacheRoutes(r *gin.RouterGroup) {
+	cache := r.Group("llm-cache")
+	cache.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		cache.GET("/stats", h.GetLLMCacheStats)
+		cache.DELETE("", h.ClearLLMCache)
+	}
+}
+
+// registerComplianceRoutes organization compliance archives of recordings and transcripts
+func (h *Handlers) registerComplianceRoutes(r *gin.RouterGroup) {
+	group := r.Group("group")
+	group.Use(models.AuthRequired)
+	{
+		group.GET("/:id/compliance-archive", h.GetComplianceArchive)
+		group.PUT("/:id/compliance-archive", h.SaveComplianceArchive)
+		group.DELETE("/:id/compliance-archive", h.DeleteComplianceArchive)
+		group.GET("/:id/compliance-exports", h.ListComplianceExports)
+		group.POST("/:id/compliance-exports/:exportId/retry", h.RetryComplianceExport)
+	}
+}
+
+// registerFeatureFlagRoutes feature flag management (admin only) and evaluation for the current user
+func (h *Handlers) registerFeatureFlagRoutes(r *gin.RouterGroup) {
+	r.GET("/features", models.AuthRequired, h.GetMyFeatures)
+
+	flags := r.Group("feature-flags")
+	flags.Use(models.AuthRequired, models.WithAdminAuth())
+	{
+		flags.GET("", h.ListFeatureFlags)
+		flags.POST("", h.CreateFeatureFlag)
+		flags.GET("/:key", h.GetFeatureFlag)
+		flags.PUT("/:key", h.UpdateFeatureFlag)
+		flags.DELETE("/:key", h.DeleteFeatureFlag)
+		flags.GET("/:key/audits", h.ListFeatureFlagAudits)
+		flags.POST("/:key/evaluate", h.EvaluateFeatureFlag)
+	}
+}
+
+// registerRegionRoutes deployment regions devices and SIP users can be pinned to
+func (h *Handlers) registerRegionRoutes(r *gin.RouterGroup) {
+	regions := r.Group("regions")
+	regions.Use(models.AuthRequired)
+	{
+		regions.GET("", h.ListRegions)
+		regions.POST("", models.WithAdminAuth(), h.CreateRegion)
+		regions.PUT("/:code", models.WithAdminAuth(), h.UpdateRegion)
+		regions.DELETE("/:code", models.WithAdminAuth(), h.DeleteRegion)
+	}
+}
+
+// registerWebWidgetRoutes registers the public endpoints of embeddable widgets,
+// authorized by the widget key, the page origin and the session token
+func (h *Handlers) registerWebWidgetRoutes(r *gin.RouterGroup) {
+	widget := r.Group("/widget")
+	{
+		widget.POST("/:key/session", h.CreateWebWidgetSession)
+		widget.GET("/:key/call", h.WebWidgetCall)
+	}
+}
+
+// registerAnnotationRoutes annotations across assistants; shared links are
+// authorized by their signature
+func (h *Handlers) registerAnnotationRoutes(r *gin.RouterGroup) {
+	annotations := r.Group("annotations")
+	{
+		annotations.GET("/mentions", models.AuthRequired, h.ListMentionedAnnotations)
+		annotations.GET("/shared", h.GetSharedAnnotation)
 	}
 }
 
@@ -570,14 +1224,37 @@ func (h *Handlers) registerKnowledgeRoutes(r *gin.RouterGroup) {
 		//阿里删除知识库
 		knowledge.DELETE("/delete", models.AuthRequired, h.DeleteKnowledgeBase)
 		//阿里获取知识库用户
-		knowledge.GET("/get", models.AuthApiRequired, h.GetKnowledgeBase)
+		knowledge.GET("/get", models.AuthApiRequired, middleware.ResponseCache(responseCacheKnowledge, responseCacheKnowledgeTTL), h.GetKnowledgeBase)
 		//上传文件到知识库（支持多 provider）
 		knowledge.POST("/upload", models.AuthRequired, h.UploadFileToKnowledgeBase)
 		//搜索/召回知识库文档
 		knowledge.GET("/search", models.AuthRequired, h.SearchKnowledgeBase)
 		//列出知识库中的所有内容（文档和段落）
-		knowledge.GET("/list", models.AuthRequired, h.ListKnowledgeBaseContent)
+		knowledge.GET("/list", models.AuthRequired, middleware.ResponseCache(responseCacheKnowledge, responseCacheKnowledgeTTL), h.ListKnowledgeBaseContent)
+		//知识库入库完成 Webhook 配置
+		knowledge.GET("/webhook", h.GetKnowledgeWebhook)
+		knowledge.PUT("/webhook", h.SaveKnowledgeWebhook)
+		knowledge.DELETE("/webhook", h.DeleteKnowledgeWebhook)
+		//入库任务状态（百炼回调模式）
+		knowledge.GET("/ingestion-jobs", h.ListKnowledgeIngestionJobs)
+		//知识库源文件
+		knowledge.GET("/files", h.ListKnowledgeFiles)
+		//知识库快照与恢复
+		knowledge.POST("/snapshots", h.CreateKnowledgeSnapshot)
+		knowledge.GET("/snapshots", h.ListKnowledgeSnapshots)
+		knowledge.GET("/snapshots/:version", h.GetKnowledgeSnapshot)
+		knowledge.POST("/snapshots/:version/restore", h.RestoreKnowledgeSnapshot)
+		knowledge.GET("/restores", h.ListKnowledgeSnapshotRestores)
+		knowledge.GET("/restores/:id", h.GetKnowledgeSnapshotRestore)
+		//通话记录生成的常见问题审核
+		knowledge.GET("/faq-proposals", h.ListKnowledgeFAQProposals)
+		knowledge.POST("/faq-proposals/:id/approve", h.ApproveKnowledgeFAQProposal)
+		knowledge.POST("/faq-proposals/:id/reject", h.RejectKnowledgeFAQProposal)
 	}
+	// 知识库源文件内容，引用中的签名链接或知识库所有者可访问
+	r.GET("/knowledge/files/:id/content", h.ServeKnowledgeFile)
+	// 百炼索引任务回调，使用签名校验而非登录
+	r.POST("/knowledge/callbacks/bailian", h.HandleBailianCallback)
 }
 
 // registerXunfeiTTSRoutes 注册讯飞TTS路由
@@ -632,7 +1309,9 @@ func (h *Handlers) registerVoiceTrainingRoutes(r *gin.RouterGroup) {
 
 		// 音色管理
 		voice.GET("/clones", h.GetUserVoiceClones)
+		voice.GET("/clones/consent-statement", h.GetVoiceCloneConsentStatement)
 		voice.GET("/clones/:id", h.GetVoiceClone)
+		voice.GET("/clones/:id/consents", h.GetVoiceCloneConsents)
 		voice.POST("/clones/update", h.UpdateVoiceClone)
 		voice.POST("/clones/delete", h.DeleteVoiceClone)
 
@@ -693,15 +1372,19 @@ func (h *Handlers) registerSipRoutes(r *gin.RouterGroup) {
 		sip.GET("/users", models.AuthRequired, h.sipHandler.GetSipUsers)
 
 		// 呼出相关
-		sip.POST("/calls/outgoing", models.AuthRequired, h.sipHandler.MakeOutgoingCall)
+		sip.POST("/calls/outgoing", models.AuthRequired, models.EmailVerificationRequired(models.EmailActionOutboundCall), models.PlanFeatureRequired(plans.FeatureSIPTrunking), h.sipHandler.MakeOutgoingCall)
 		sip.GET("/calls/outgoing/:callId", models.AuthRequired, h.sipHandler.GetOutgoingCallStatus)
 		sip.POST("/calls/outgoing/:callId/cancel", models.AuthRequired, h.sipHandler.CancelOutgoingCall)
 		sip.POST("/calls/outgoing/:callId/hangup", models.AuthRequired, h.sipHandler.HangupOutgoingCall)
 
 		// 通话历史
 		sip.GET("/calls", models.AuthRequired, h.sipHandler.GetCallHistory)
+		sip.GET("/calls/regions", models.AuthRequired, h.GetRegionCallStats)
+		sip.GET("/calls/cdr", models.AuthRequired, h.GetCDRCostReport)
 		sip.GET("/calls/:callId/detail", models.AuthRequired, h.sipHandler.GetCallDetail)
 		sip.POST("/calls/:callId/transcribe", models.AuthRequired, h.sipHandler.RequestTranscription)
+		sip.GET("/calls/:callId/interpretation", models.AuthRequired, h.sipHandler.GetCallInterpretation)
+		sip.POST("/calls/:callId/interpretation", models.AuthRequired, h.sipHandler.SetCallInterpretation)
 	}
 }
 
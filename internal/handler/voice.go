@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -23,11 +24,14 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/graph"
 	v2 "github.com/code-100-precent/LingEcho/pkg/llm"
 	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/semcache"
 	"github.com/code-100-precent/LingEcho/pkg/synthesizer"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer/textnorm"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"github.com/code-100-precent/LingEcho/pkg/voiceclone"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // Audio processing status cache
@@ -117,6 +121,7 @@ type CreateTrainingTaskRequest struct {
 type SubmitAudioRequest struct {
 	TaskID    string `form:"taskId" binding:"required"`
 	TextSegID int64  `form:"textSegId" binding:"required"`
+	models.VoiceConsentInput
 }
 
 // QueryTaskStatusRequest Query task status request
@@ -344,6 +349,16 @@ func (h *Handlers) SubmitAudio(c *gin.Context) {
 		return
 	}
 
+	// 记录用户对克隆该声音的明确同意，未同意当前声明时拒绝上传
+	if _, err := models.RecordVoiceCloneConsent(h.db, user.ID, "xunfei", task.TaskID, &req.VoiceConsentInput, audioData, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		if errors.Is(err, models.ErrVoiceConsentRequired) {
+			response.Fail(c, "需要同意音色克隆声明", err.Error())
+		} else {
+			response.Fail(c, "保存同意记录失败", err.Error())
+		}
+		return
+	}
+
 	// 2) 调用讯飞提交音频（使用 voiceclone）
 	factory := voiceclone.NewFactory()
 	service, err := factory.CreateServiceFromEnv(voiceclone.ProviderXunfei)
@@ -519,7 +534,7 @@ func (h *Handlers) SynthesizeWithVoice(c *gin.Context) {
 		req.StorageKey = "voice_synthesis/" + strconv.FormatUint(uint64(req.VoiceCloneID), 10) + "_" + timestamp + "_" + strconv.FormatInt(int64(len(req.Text)), 10) + ".mp3"
 	}
 
-	// 1) 获取音色
+	// 1) 获取音色（只能使用自己账号的音色）
 	var clone models.VoiceClone
 	if err := h.db.Where("user_id = ? AND id = ? AND is_active = ?", user.ID, req.VoiceCloneID, true).
 		First(&clone).Error; err != nil {
@@ -1174,14 +1189,54 @@ func (h *Handlers) DeleteVoiceClone(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Where("user_id = ? AND id = ?", user.ID, req.ID).
-		Delete(&models.VoiceClone{}).Error; err != nil {
+	var clone models.VoiceClone
+	if err := h.db.Where("user_id = ? AND id = ?", user.ID, req.ID).First(&clone).Error; err != nil {
+		response.Fail(c, "音色不存在", err.Error())
+		return
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&clone).Error; err != nil {
+			return err
+		}
+		// 删除音色即撤销同意，同意记录保留用于审计
+		return models.RevokeVoiceCloneConsents(tx, clone.ID)
+	})
+	if err != nil {
 		response.Fail(c, "删除音色失败", err.Error())
 		return
 	}
 	response.Success(c, "删除音色成功", nil)
 }
 
+// GetVoiceCloneConsentStatement 返回上传样本前需要用户同意的声明及其版本
+func (h *Handlers) GetVoiceCloneConsentStatement(c *gin.Context) {
+	response.Success(c, "获取同意声明成功", gin.H{
+		"version":   models.VoiceCloneConsentVersion,
+		"statement": models.VoiceCloneConsentStatement,
+	})
+}
+
+// GetVoiceCloneConsents 获取音色的同意记录
+func (h *Handlers) GetVoiceCloneConsents(c *gin.Context) {
+	user := models.CurrentUser(c)
+	cloneID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "音色ID格式错误", err.Error())
+		return
+	}
+	var clone models.VoiceClone
+	if err := h.db.Where("user_id = ? AND id = ?", user.ID, uint(cloneID)).First(&clone).Error; err != nil {
+		response.Fail(c, "音色不存在", err.Error())
+		return
+	}
+	consents, err := models.GetVoiceCloneConsents(h.db, clone.ID)
+	if err != nil {
+		response.Fail(c, "获取同意记录失败", err.Error())
+		return
+	}
+	response.Success(c, "获取同意记录成功", consents)
+}
+
 // GetTrainingTexts 获取训练文本
 func (h *Handlers) GetTrainingTexts(c *gin.Context) {
 	// 获取文本ID参数
@@ -1255,26 +1310,10 @@ func (h *Handlers) GetTrainingTexts(c *gin.Context) {
 	response.Success(c, "获取训练文本成功", text)
 }
 
-// upsertVoiceClone 如果不存在则创建，存在则更新
+// upsertVoiceClone 如果不存在则创建，存在则更新；新建需要样本上传时的同意记录
 func (h *Handlers) upsertVoiceClone(ctx context.Context, userID uint, task *models.VoiceTrainingTask, assetID, trainVID, provider string) error {
-	var existing models.VoiceClone
-	if err := h.db.Where("user_id = ? AND asset_id = ? AND provider = ?", userID, assetID, provider).First(&existing).Error; err == nil {
-		existing.TrainVID = trainVID
-		existing.IsActive = true
-		return h.db.Save(&existing).Error
-	}
-	clone := &models.VoiceClone{
-		UserID:           userID,
-		TrainingTaskID:   task.ID,
-		Provider:         provider,
-		AssetID:          assetID,
-		TrainVID:         trainVID,
-		VoiceName:        task.TaskName,
-		VoiceDescription: fmt.Sprintf("基于任务 %s 训练的音色", task.TaskName),
-		IsActive:         true,
-		UsageCount:       0,
-	}
-	return h.db.Create(clone).Error
+	_, err := models.SaveTrainedVoiceClone(h.db.WithContext(ctx), userID, task, assetID, trainVID, provider)
+	return err
 }
 
 // GetAudioStatus 获取音频处理状态
@@ -1346,6 +1385,7 @@ func (h *Handlers) OneShotText(c *gin.Context) {
 		response.Fail(c, "凭证不存在", "无效的 apiKey 或 apiSecret")
 		return
 	}
+	models.SetCurrentCredential(c, credential)
 
 	// 获取用户信息
 	var user models.User
@@ -1362,24 +1402,22 @@ func (h *Handlers) OneShotText(c *gin.Context) {
 		}
 	}
 
+	var assistant models.Assistant
+	if req.AssistantID > 0 {
+		h.db.First(&assistant, req.AssistantID)
+	}
+
 	// 2. 调用LLM处理文本
 	var llmResponse string
 	var errLLM error
+	var citations []models.KnowledgeCitation // 回答引用的知识库片段
 	if credential.LLMProvider != "" && credential.LLMApiKey != "" {
 		llmBaseURL := credential.LLMApiURL
 		if llmBaseURL == "" {
 			llmBaseURL = utils.GetEnv("LLM_BASE_URL")
 		}
 		// 获取模型和参数，优先级：Assistant配置 > 环境变量 > 默认值
-		var assistant models.Assistant
-		llmModel := ""
-		if req.AssistantID > 0 {
-			if err := h.db.First(&assistant, req.AssistantID).Error; err == nil {
-				if assistant.LLMModel != "" {
-					llmModel = assistant.LLMModel
-				}
-			}
-		}
+		llmModel := assistant.LLMModel
 
 		// 如果Assistant没有配置模型，使用环境变量
 		if llmModel == "" {
@@ -1473,10 +1511,34 @@ func (h *Handlers) OneShotText(c *gin.Context) {
 			}
 		}
 
+		// 语义缓存：同一助手、同一知识库版本下相近的问题直接复用之前的回答。
+		// 自定义系统提示词和图记忆会让回答因请求而异，不走缓存
+		var llmCache *semcache.Cache
+		var cacheScope string
+		cached := false
+		if req.AssistantID > 0 && req.SystemPrompt == "" && !assistant.EnableGraphMemory {
+			if llmCache = models.LLMCache(h.db); llmCache != nil {
+				cacheScope = models.LLMCacheScope(h.db, uint(req.AssistantID), knowledgeKey)
+				if hit, ok := llmCache.Lookup(cacheScope, req.Text); ok {
+					llmResponse, cached = hit.Answer, true
+					logrus.Infof("LLM cache hit (scope: %s, similarity: %.3f)", cacheScope, hit.Similarity)
+				}
+			}
+		}
+
+		sessionID := req.SessionID
+		if sessionID == "" {
+			sessionID = fmt.Sprintf("text_v2_%d_%d", user.ID, time.Now().Unix())
+		}
+
 		// 如果找到了 knowledgeKey，检索知识库
-		if knowledgeKey != "" {
+		if knowledgeKey != "" && !cached {
 			// 检索知识库
+			searchStarted := time.Now()
 			knowledgeResults, err := models.SearchKnowledgeBase(h.db, knowledgeKey, req.Text, 5)
+			if err == nil {
+				h.recordKnowledgeRetrieval(sessionID, int64(req.AssistantID), knowledgeKey, req.Text, knowledgeResults, searchStarted)
+			}
 			if err != nil {
 				logrus.Warnf("Failed to search knowledge base: %v", err)
 				// 搜索失败时使用原始查询
@@ -1494,6 +1556,7 @@ func (h *Handlers) OneShotText(c *gin.Context) {
 				}
 				contextBuilder.WriteString("\n\n请基于以上信息回答用户问题，回答要自然流畅，不要提及信息来源。")
 				queryText = contextBuilder.String()
+				citations = h.knowledgeCitations(knowledgeKey, knowledgeResults)
 				logrus.Infof("Retrieved %d relevant documents from knowledge base (key: %s)", len(knowledgeResults), knowledgeKey)
 			} else {
 				// 没有找到相关内容，使用原始查询
@@ -1503,32 +1566,33 @@ func (h *Handlers) OneShotText(c *gin.Context) {
 
 		userID := user.ID
 		assistantID := int64(req.AssistantID)
-		sessionID := req.SessionID
-		if sessionID == "" {
-			sessionID = fmt.Sprintf("text_v2_%d_%d", user.ID, time.Now().Unix())
-		}
-		credentialID := credential.ID
-		llmResponse, errLLM = llmHandler.QueryWithOptions(queryText, v2.QueryOptions{
-			Model:        llmModel,
-			Temperature:  temp,
-			MaxTokens:    maxTokens,
-			UserID:       &userID,
-			AssistantID:  &assistantID,
-			CredentialID: &credentialID,
-			SessionID:    sessionID,
-			ChatType:     models.ChatTypeText,
-		})
-		if errLLM != nil {
-			// 提取更友好的错误信息
-			errMsg := errLLM.Error()
+		if !cached {
+			credentialID := credential.ID
+			llmResponse, errLLM = llmHandler.QueryWithOptions(queryText, v2.QueryOptions{
+				Model:        llmModel,
+				Temperature:  temp,
+				MaxTokens:    maxTokens,
+				UserID:       &userID,
+				AssistantID:  &assistantID,
+				CredentialID: &credentialID,
+				SessionID:    sessionID,
+				ChatType:     models.ChatTypeText,
+			})
+			if errLLM != nil {
+				// 提取更友好的错误信息
+				errMsg := errLLM.Error()
 
-			// 检查是否是模型不可用的错误
-			if strings.Contains(errMsg, "no available channels") || strings.Contains(errMsg, "model") {
-				response.Fail(c, "模型不可用", fmt.Sprintf("模型 %s 当前不可用，请检查模型配置或尝试其他模型。错误详情：%s", llmModel, errMsg))
-			} else {
-				response.Fail(c, "LLM处理失败", errMsg)
+				// 检查是否是模型不可用的错误
+				if strings.Contains(errMsg, "no available channels") || strings.Contains(errMsg, "model") {
+					response.Fail(c, "模型不可用", fmt.Sprintf("模型 %s 当前不可用，请检查模型配置或尝试其他模型。错误详情：%s", llmModel, errMsg))
+				} else {
+					response.Fail(c, "LLM处理失败", errMsg)
+				}
+				return
+			}
+			if llmCache != nil {
+				llmCache.Store(cacheScope, req.Text, llmResponse)
 			}
-			return
 		}
 	} else {
 		// 如果没有配置LLM，直接返回原文本
@@ -1541,13 +1605,18 @@ func (h *Handlers) OneShotText(c *gin.Context) {
 		"text":      llmResponse,
 		"audioUrl":  "",        // 先返回空，后续通过轮询获取
 		"requestId": requestId, // 用于轮询
+		"citations": citations,
 	})
 
 	// 4. 聊天记录已通过 LLMListener 自动保存（如果提供了 UserID 和 AssistantID）
 	// 这里不再需要手动保存，避免重复记录
 
 	// 5. 异步处理音频合成（使用pkg/synthesis）
-	go h.processAudioAsyncV2(context.Background(), credential, user.ID, llmResponse, req.Language, req.Speaker, req.VoiceCloneID, requestId)
+	normalization := assistant.TextNormalization()
+	if normalization.Language == "" {
+		normalization.Language = req.Language
+	}
+	go h.processAudioAsyncV2(context.Background(), credential, user.ID, llmResponse, req.Language, req.Speaker, req.VoiceCloneID, normalization, requestId)
 }
 
 // SimpleTextChatRequest 简单文本对话请求结构（无需token）
@@ -1564,8 +1633,9 @@ type SimpleTextChatRequest struct {
 
 // SimpleTextChatResponse 简单文本对话响应
 type SimpleTextChatResponse struct {
-	Text      string `json:"text"`
-	SessionID string `json:"sessionId"`
+	Text      string                     `json:"text"`
+	SessionID string                     `json:"sessionId"`
+	Citations []models.KnowledgeCitation `json:"citations,omitempty"` // 引用的知识库片段及源文件链接
 }
 
 // SimpleTextChat 处理简单文本对话（无需token验证，仅返回文本）
@@ -1586,6 +1656,7 @@ func (h *Handlers) SimpleTextChat(c *gin.Context) {
 		response.Fail(c, "凭证不存在", "无效的 apiKey 或 apiSecret")
 		return
 	}
+	models.SetCurrentCredential(c, credential)
 
 	// 2. 获取用户信息
 	var user models.User
@@ -1601,8 +1672,8 @@ func (h *Handlers) SimpleTextChat(c *gin.Context) {
 		return
 	}
 
-	// 验证助手是否属于该用户
-	if assistant.UserID != user.ID {
+	// 验证助手是否属于该用户，或通过组织共享给该用户
+	if !models.CanUseAssistant(h.db, &assistant, user.ID) {
 		response.Fail(c, "无权访问该助手", "助手不属于当前用户")
 		return
 	}
@@ -1682,12 +1753,23 @@ func (h *Handlers) SimpleTextChat(c *gin.Context) {
 	// 11. 构建查询文本（如果有知识库，先检索）
 	queryText := req.Text
 	var knowledgeKey string
+	var citations []models.KnowledgeCitation
 	if assistant.KnowledgeBaseID != nil && *assistant.KnowledgeBaseID != "" {
 		knowledgeKey = *assistant.KnowledgeBaseID
 	}
 
+	// 12. 生成会话ID
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = fmt.Sprintf("simple_text_%d_%d", user.ID, time.Now().Unix())
+	}
+
 	if knowledgeKey != "" {
+		searchStarted := time.Now()
 		knowledgeResults, err := models.SearchKnowledgeBase(h.db, knowledgeKey, req.Text, 5)
+		if err == nil {
+			h.recordKnowledgeRetrieval(sessionID, int64(req.AssistantID), knowledgeKey, req.Text, knowledgeResults, searchStarted)
+		}
 		if err != nil {
 			logrus.Warnf("Failed to search knowledge base: %v", err)
 		} else if len(knowledgeResults) > 0 {
@@ -1701,16 +1783,11 @@ func (h *Handlers) SimpleTextChat(c *gin.Context) {
 			}
 			contextBuilder.WriteString("\n\n请基于以上信息回答用户问题，回答要自然流畅，不要提及信息来源。")
 			queryText = contextBuilder.String()
+			citations = h.knowledgeCitations(knowledgeKey, knowledgeResults)
 			logrus.Infof("Retrieved %d relevant documents from knowledge base", len(knowledgeResults))
 		}
 	}
 
-	// 12. 生成会话ID
-	sessionID := req.SessionID
-	if sessionID == "" {
-		sessionID = fmt.Sprintf("simple_text_%d_%d", user.ID, time.Now().Unix())
-	}
-
 	// 13. 调用LLM
 	userID := user.ID
 	assistantID := int64(req.AssistantID)
@@ -1739,6 +1816,7 @@ func (h *Handlers) SimpleTextChat(c *gin.Context) {
 	response.Success(c, "对话成功", SimpleTextChatResponse{
 		Text:      llmResponse,
 		SessionID: sessionID,
+		Citations: citations,
 	})
 }
 
@@ -1767,6 +1845,7 @@ func (h *Handlers) PlainText(c *gin.Context) {
 		response.Fail(c, "凭证不存在", "无效的 apiKey 或 apiSecret")
 		return
 	}
+	models.SetCurrentCredential(c, credential)
 
 	// 3. 获取用户信息
 	var user models.User
@@ -1775,8 +1854,8 @@ func (h *Handlers) PlainText(c *gin.Context) {
 		return
 	}
 
-	// 4. 判断是否是该用户的助手
-	if assistant.UserID != user.ID {
+	// 4. 判断是否是该用户的助手，或通过组织共享给该用户
+	if !models.CanUseAssistant(h.db, &assistant, user.ID) {
 		response.Fail(c, "无权限", "请检查助手ID是否正确")
 		return
 	}
@@ -1784,6 +1863,7 @@ func (h *Handlers) PlainText(c *gin.Context) {
 	// 5. 调用LLM处理文本
 	var llmResponse string
 	var errLLM error
+	var citations []models.KnowledgeCitation // 回答引用的知识库片段
 	if credential.LLMProvider != "" && credential.LLMApiKey != "" {
 		llmBaseURL := credential.LLMApiURL
 		if llmBaseURL == "" {
@@ -1840,10 +1920,19 @@ func (h *Handlers) PlainText(c *gin.Context) {
 			}
 		}
 
+		sessionID := req.SessionID
+		if sessionID == "" {
+			sessionID = fmt.Sprintf("plain_text_%d_%d", user.ID, time.Now().Unix())
+		}
+
 		// 如果找到了 knowledgeKey，检索知识库
 		if knowledgeKey != "" {
 			// 检索知识库
+			searchStarted := time.Now()
 			knowledgeResults, err := models.SearchKnowledgeBase(h.db, knowledgeKey, req.Text, 5)
+			if err == nil {
+				h.recordKnowledgeRetrieval(sessionID, int64(req.AssistantID), knowledgeKey, req.Text, knowledgeResults, searchStarted)
+			}
 			if err != nil {
 				logrus.Warnf("Failed to search knowledge base: %v", err)
 				// 搜索失败时使用原始查询
@@ -1861,6 +1950,7 @@ func (h *Handlers) PlainText(c *gin.Context) {
 				}
 				contextBuilder.WriteString("\n\n请基于以上信息回答用户问题，回答要自然流畅，不要提及信息来源。")
 				queryText = contextBuilder.String()
+				citations = h.knowledgeCitations(knowledgeKey, knowledgeResults)
 				logrus.Infof("Retrieved %d relevant documents from knowledge base (key: %s)", len(knowledgeResults), knowledgeKey)
 			} else {
 				// 没有找到相关内容，使用原始查询
@@ -1906,10 +1996,6 @@ func (h *Handlers) PlainText(c *gin.Context) {
 
 		userID := user.ID
 		assistantID := int64(req.AssistantID)
-		sessionID := req.SessionID
-		if sessionID == "" {
-			sessionID = fmt.Sprintf("plain_text_%d_%d", user.ID, time.Now().Unix())
-		}
 		credentialID := credential.ID
 		llmResponse, errLLM = llmHandler.QueryWithOptions(queryText, v2.QueryOptions{
 			Model:        llmModel,
@@ -1939,13 +2025,14 @@ func (h *Handlers) PlainText(c *gin.Context) {
 	}
 
 	// 返回成功响应
-	response.Success(c, "处理成功", map[string]string{
-		"text": llmResponse,
+	response.Success(c, "处理成功", gin.H{
+		"text":      llmResponse,
+		"citations": citations,
 	})
 }
 
 // processAudioAsyncV2 异步处理音频合成（V2版本，使用用户凭证配置）
-func (h *Handlers) processAudioAsyncV2(ctx context.Context, credential *models.UserCredential, userID uint, text, language, speaker string, voiceCloneID int, requestID string) {
+func (h *Handlers) processAudioAsyncV2(ctx context.Context, credential *models.UserCredential, userID uint, text, language, speaker string, voiceCloneID int, normalization textnorm.Options, requestID string) {
 	// 清理文本，移除Markdown格式符号
 	text = cleanTextForTTS(text)
 
@@ -2215,6 +2302,8 @@ func (h *Handlers) processAudioAsyncV2(ctx context.Context, credential *models.U
 		return
 	}
 
+	ttsService = synthesizer.WithTextNormalization(ttsService, normalization)
+
 	// 创建音频收集器
 	var audioData []byte
 	audioMu := sync.Mutex{}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
@@ -28,6 +31,7 @@ type VolcengineTTSResponse struct {
 type VolcengineSubmitAudioRequest struct {
 	SpeakerID string `form:"speakerId" binding:"required"` // speaker_id from console
 	Language  string `form:"language" binding:"required"`
+	models.VoiceConsentInput
 }
 
 // VolcengineQueryTaskRequest represents query task request
@@ -63,6 +67,11 @@ func (h *Handlers) VolcengineSynthesize(c *gin.Context) {
 	var clone models.VoiceClone
 	if err := h.db.Where("user_id = ? AND asset_id = ? AND provider = ? AND is_active = ?",
 		user.ID, req.AssetID, "volcengine", true).First(&clone).Error; err != nil {
+		// Cloned voices of other accounts cannot be used
+		if models.VoiceAssetOwnedByOther(h.db, "volcengine", req.AssetID, user.ID) {
+			response.Fail(c, "Voice not available", models.ErrVoiceCloneNotAllowed.Error())
+			return
+		}
 		// If voice clone not found, still allow synthesis but don't save history
 		logrus.WithError(err).Warn("volcengine: voice clone not found, synthesis will proceed without history")
 	}
@@ -128,6 +137,12 @@ func (h *Handlers) VolcengineSubmitAudio(c *gin.Context) {
 		return
 	}
 
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.Fail(c, "Unauthorized", "User not logged in")
+		return
+	}
+
 	// Get uploaded file
 	file, err := c.FormFile("audio")
 	if err != nil {
@@ -142,6 +157,26 @@ func (h *Handlers) VolcengineSubmitAudio(c *gin.Context) {
 		return
 	}
 	defer src.Close()
+	audioData, err := io.ReadAll(src)
+	if err != nil {
+		response.Fail(c, "Failed to read audio file", err.Error())
+		return
+	}
+
+	// A speaker already cloned by another account cannot be trained again
+	if models.VoiceAssetOwnedByOther(h.db, "volcengine", req.SpeakerID, user.ID) {
+		response.Fail(c, "Voice not available", models.ErrVoiceCloneNotAllowed.Error())
+		return
+	}
+	// Capture explicit consent to clone this voice before the sample leaves the server
+	if _, err := models.RecordVoiceCloneConsent(h.db, user.ID, "volcengine", req.SpeakerID, &req.VoiceConsentInput, audioData, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		if errors.Is(err, models.ErrVoiceConsentRequired) {
+			response.Fail(c, "Voice cloning consent required", err.Error())
+		} else {
+			response.Fail(c, "Failed to save consent", err.Error())
+		}
+		return
+	}
 
 	// Submit audio (using voiceclone)
 	factory := voiceclone.NewFactory()
@@ -155,7 +190,7 @@ func (h *Handlers) VolcengineSubmitAudio(c *gin.Context) {
 		TaskID:    req.SpeakerID, // Use speaker_id as TaskID
 		TextID:    0,             // Not needed for Volcengine
 		TextSegID: 0,             // Not needed for Volcengine
-		AudioFile: src,
+		AudioFile: bytes.NewReader(audioData),
 		Language:  req.Language,
 	}
 	err = service.SubmitAudio(c.Request.Context(), submitReq)
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/wallboard"
+	"github.com/gin-gonic/gin"
+)
+
+// GetWallboard returns the whole supervisor wallboard: active calls, queue
+// depths, agent presence and today's KPIs
+// GET /wallboard
+func (h *Handlers) GetWallboard(c *gin.Context) {
+	response.Success(c, "success", wallboard.Default().Snapshot())
+}
+
+// GetWallboardCalls returns the active calls with their state
+// GET /wallboard/calls
+func (h *Handlers) GetWallboardCalls(c *gin.Context) {
+	response.Success(c, "success", wallboard.Default().Calls())
+}
+
+// GetWallboardQueues returns the depth of every assistant session queue
+// GET /wallboard/queues
+func (h *Handlers) GetWallboardQueues(c *gin.Context) {
+	response.Success(c, "success", wallboard.Default().Queues())
+}
+
+// GetWallboardAgents returns the presence of the registered SIP agents
+// GET /wallboard/agents
+func (h *Handlers) GetWallboardAgents(c *gin.Context) {
+	response.Success(c, "success", wallboard.Default().Agents())
+}
+
+// GetWallboardKPIs returns today's answer rate, waits and call counts
+// GET /wallboard/kpis
+func (h *Handlers) GetWallboardKPIs(c *gin.Context) {
+	response.Success(c, "success", wallboard.Default().KPIs())
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/code-100-precent/LingEcho/pkg/voice"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Transports a widget call can use
+const (
+	widgetTransportWebSocket = "websocket"
+	widgetTransportWebRTC    = "webrtc"
+)
+
+// widgetUpgrader the origin is checked against the session before upgrading
+var widgetUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024 * 1024,
+	WriteBufferSize: 1024 * 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// webWidgetRequest body of widget create and update
+type webWidgetRequest struct {
+	Name                  string   `json:"name"`
+	CredentialID          uint     `json:"credentialId" binding:"required"`
+	AllowedOrigins        []string `json:"allowedOrigins"`
+	Enabled               *bool    `json:"enabled"`
+	MaxSessionSeconds     int      `json:"maxSessionSeconds"`
+	SessionsPerIPHour     int      `json:"sessionsPerIpHour"`
+	SessionsPerDay        int      `json:"sessionsPerDay"`
+	MaxConcurrentSessions int      `json:"maxConcurrentSessions"`
+}
+
+// applyWebWidgetRequest copies the request onto the widget after checking the
+// credential belongs to the widget owner
+func (h *Handlers) applyWebWidgetRequest(widget *models.WebWidget, req *webWidgetRequest) error {
+	var count int64
+	h.db.Model(&models.UserCredential{}).Where("id = ? AND user_id = ?", req.CredentialID, widget.UserID).Count(&count)
+	if count == 0 {
+		return errors.New("credential not found")
+	}
+	widget.Name = req.Name
+	widget.CredentialID = req.CredentialID
+	widget.AllowedOrigins = req.AllowedOrigins
+	widget.MaxSessionSeconds = req.MaxSessionSeconds
+	widget.SessionsPerIPHour = req.SessionsPerIPHour
+	widget.SessionsPerDay = req.SessionsPerDay
+	widget.MaxConcurrentSessions = req.MaxConcurrentSessions
+	if req.Enabled != nil {
+		widget.Enabled = *req.Enabled
+	}
+	return widget.Validate()
+}
+
+// ownedWebWidget loads the widget from :widgetId within the owned assistant
+func (h *Handlers) ownedWebWidget(c *gin.Context) (*models.WebWidget, bool) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return nil, false
+	}
+	var widget models.WebWidget
+	if err := h.db.Where("id = ? AND assistant_id = ?", c.Param("widgetId"), assistant.ID).First(&widget).Error; err != nil {
+		response.Fail(c, "widget not found", nil)
+		return nil, false
+	}
+	return &widget, true
+}
+
+// ListWebWidgets lists the embeddable widgets of an assistant
+// GET /assistant/:id/widgets
+func (h *Handlers) ListWebWidgets(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	var widgets []models.WebWidget
+	if err := h.db.Where("assistant_id = ?", assistant.ID).Order("id").Find(&widgets).Error; err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", widgets)
+}
+
+// CreateWebWidget creates an embeddable widget for an assistant; its public
+// key goes into the page, the credential pays for the calls
+// POST /assistant/:id/widgets
+func (h *Handlers) CreateWebWidget(c *gin.Context) {
+	assistant, ok := h.ownedAssistant(c)
+	if !ok {
+		return
+	}
+	var req webWidgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	key, err := models.GenerateWidgetKey()
+	if err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	widget := &models.WebWidget{
+		UserID:      assistant.UserID,
+		AssistantID: assistant.ID,
+		PublicKey:   key,
+		Enabled:     true,
+	}
+	if err := h.applyWebWidgetRequest(widget, &req); err != nil {
+		response.Fail(c, "invalid widget", err.Error())
+		return
+	}
+	if err := h.db.Create(widget).Error; err != nil {
+		response.Fail(c, "create failed", err.Error())
+		return
+	}
+	response.Success(c, "created", widget)
+}
+
+// UpdateWebWidget replaces the settings of a widget, the public key is kept
+// PUT /assistant/:id/widgets/:widgetId
+func (h *Handlers) UpdateWebWidget(c *gin.Context) {
+	widget, ok := h.ownedWebWidget(c)
+	if !ok {
+		return
+	}
+	var req webWidgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", err.Error())
+		return
+	}
+	if err := h.applyWebWidgetRequest(widget, &req); err != nil {
+		response.Fail(c, "invalid widget", err.Error())
+		return
+	}
+	if err := h.db.Save(widget).Error; err != nil {
+		response.Fail(c, "update failed", err.Error())
+		return
+	}
+	response.Success(c, "updated", widget)
+}
+
+// DeleteWebWidget deletes a widget, pages embedding it stop working
+// DELETE /assistant/:id/widgets/:widgetId
+func (h *Handlers) DeleteWebWidget(c *gin.Context) {
+	widget, ok := h.ownedWebWidget(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(widget).Error; err != nil {
+		response.Fail(c, "delete failed", err.Error())
+		return
+	}
+	response.Success(c, "deleted", nil)
+}
+
+// GetWebWidgetUsage counts the sessions of a widget over the last days
+// (default 7, at most 90)
+// GET /assistant/:id/widgets/:widgetId/usage
+func (h *Handlers) GetWebWidgetUsage(c *gin.Context) {
+	widget, ok := h.ownedWebWidget(c)
+	if !ok {
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days < 1 || days > 90 {
+		days = 7
+	}
+	usage, err := models.GetWebWidgetUsage(h.db, widget.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		response.Fail(c, "query failed", err.Error())
+		return
+	}
+	response.Success(c, "success", usage)
+}
+
+// widgetErrorStatus maps widget errors to HTTP statuses for the public endpoints
+func widgetErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrWidgetOriginNotAllowed), errors.Is(err, models.ErrWidgetDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrWidgetRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, models.ErrWidgetTokenInvalid):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateWebWidgetSession issues an anonymous session token to a visitor of an
+// allowed page. The browser's Origin header is checked against the widget
+// allowlist and the session is counted against the widget's rate limits
+// POST /widget/:key/session
+func (h *Handlers) CreateWebWidgetSession(c *gin.Context) {
+	widget, err := models.GetWebWidgetByKey(h.db, c.Param("key"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "widget not found"})
+		return
+	}
+	origin := c.GetHeader("Origin")
+	token, session, err := models.IssueWebWidgetSession(h.db, widget, origin, c.ClientIP(), time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrWidgetRateLimited) {
+			logger.Warn("Widget session rate limited",
+				zap.Uint("widgetId", widget.ID), zap.String("ip", c.ClientIP()), zap.String("origin", origin))
+		}
+		c.JSON(widgetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var assistant models.Assistant
+	h.db.Select("id", "name").First(&assistant, widget.AssistantID)
+	callURL := config.GlobalConfig.Server.APIPrefix + "/widget/" + widget.PublicKey + "/call?token=" + token
+	response.Success(c, "success", gin.H{
+		"token":             token,
+		"expiresAt":         session.ExpiresAt,
+		"callUrl":           callURL,
+		"transports":        []string{widgetTransportWebSocket, widgetTransportWebRTC},
+		"maxSessionSeconds": widget.MaxSessionSeconds,
+		"assistant":         gin.H{"id": assistant.ID, "name": assistant.Name},
+	})
+}
+
+// WebWidgetCall redeems a session token and connects the visitor to the
+// assistant. transport=websocket (default) streams audio over the WebSocket
+// like /voice/websocket; transport=webrtc uses the WebSocket for signaling like
+// /chat/call. The call is hung up after the widget's maximum session length
+// GET /widget/:key/call?token=...&transport=websocket|webrtc
+func (h *Handlers) WebWidgetCall(c *gin.Context) {
+	transport := c.DefaultQuery("transport", widgetTransportWebSocket)
+	if transport != widgetTransportWebSocket && transport != widgetTransportWebRTC {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "transport must be websocket or webrtc"})
+		return
+	}
+	widget, err := models.GetWebWidgetByKey(h.db, c.Param("key"))
+	if err != nil || !widget.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "widget not found"})
+		return
+	}
+	session, err := models.RedeemWebWidgetSession(h.db, c.Query("token"), c.GetHeader("Origin"), time.Now())
+	if err != nil || session.WidgetID != widget.ID {
+		if err == nil {
+			err = models.ErrWidgetTokenInvalid
+		}
+		c.JSON(widgetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer func() {
+		if err := models.EndWebWidgetSession(h.db, session.ID, time.Now()); err != nil {
+			logger.Warn("Failed to end widget session", zap.Uint("sessionId", session.ID), zap.Error(err))
+		}
+	}()
+
+	var assistant models.Assistant
+	if err := h.db.First(&assistant, widget.AssistantID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "assistant not found"})
+		return
+	}
+	var cred models.UserCredential
+	if err := h.db.Where("id = ? AND user_id = ?", widget.CredentialID, widget.UserID).First(&cred).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("Failed to load widget credential", zap.Uint("widgetId", widget.ID), zap.Error(err))
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "widget is not configured"})
+		return
+	}
+
+	logger.Info("Widget call started",
+		zap.Uint("widgetId", widget.ID),
+		zap.Int64("assistantId", assistant.ID),
+		zap.String("transport", transport),
+		zap.String("origin", session.Origin),
+		zap.String("ip", session.ClientIP))
+
+	if transport == widgetTransportWebRTC {
+		h.serveWebRTCCall(c, &cred, &assistant, widget.MaxSessionDuration())
+		return
+	}
+
+	conn, err := widgetUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	timer := time.AfterFunc(widget.MaxSessionDuration(), func() { conn.Close() })
+	defer timer.Stop()
+	ctx, cancel := context.WithTimeout(c.Request.Context(), widget.MaxSessionDuration())
+	defer cancel()
+
+	language := assistant.Language
+	if language == "" {
+		language = "zh-cn"
+	}
+	speaker := assistant.Speaker
+	if speaker == "" {
+		speaker = "101016"
+	}
+	knowledgeKey := ""
+	if assistant.KnowledgeBaseID != nil {
+		knowledgeKey = *assistant.KnowledgeBaseID
+	}
+	voice.NewHandler(logger.Lg).HandleWebSocket(
+		ctx,
+		conn,
+		&cred,
+		int(assistant.ID),
+		language,
+		speaker,
+		float64(assistant.Temperature),
+		assistant.SystemPrompt,
+		knowledgeKey,
+		h.db,
+	)
+}
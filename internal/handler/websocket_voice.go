@@ -276,7 +276,8 @@ func (h *Handlers) HandleHardwareWebSocketVoice(c *gin.Context) {
 	if speaker == "" {
 		speaker = "502007"
 	}
-	systemPrompt := assistant.SystemPrompt
+	// A running prompt release sends a share of the devices to the candidate prompt
+	prompt := models.AssignAssistantPrompt(h.db, &assistant, device.MacAddress)
 	temperature := assistant.Temperature
 
 	// Get LLM model from assistant, fallback to default
@@ -308,7 +309,7 @@ func (h *Handlers) HandleHardwareWebSocketVoice(c *gin.Context) {
 		Language:             language,
 		Speaker:              speaker,
 		Temperature:          float64(temperature),
-		SystemPrompt:         systemPrompt,
+		SystemPrompt:         prompt.SystemPrompt,
 		KnowledgeKey:         knowledgeKey,
 		UserID:               device.UserID,
 		MacAddress:           device.MacAddress,
@@ -318,5 +319,7 @@ func (h *Handlers) HandleHardwareWebSocketVoice(c *gin.Context) {
 		VADThreshold:         vadThreshold,
 		VADConsecutiveFrames: vadConsecutiveFrames,
 		VoiceCloneID:         assistant.VoiceCloneID,
+		PromptReleaseID:      prompt.ReleaseID,
+		PromptArm:            prompt.Arm,
 	})
 }
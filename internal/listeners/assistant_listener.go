@@ -6,7 +6,6 @@ import (
 	"github.com/code-100-precent/LingEcho/internal/models"
 	"github.com/code-100-precent/LingEcho/pkg/constants"
 	"github.com/code-100-precent/LingEcho/pkg/logger"
-	"github.com/code-100-precent/LingEcho/pkg/notification"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -39,6 +38,11 @@ func InitAssistantListener() {
 			"Go to the assistant management page now to start using it!",
 			user.DisplayName, assistant.Name, assistant.Description)
 
-		notification.NewInternalNotificationService(db).Send(user.ID, title, content)
+		models.DispatchNotification(db, user, models.Notice{
+			Event:    models.NotificationEventAssistant,
+			Title:    title,
+			Content:  content,
+			Channels: []models.NotificationChannel{models.NotificationChannelInternal},
+		})
 	})
 }
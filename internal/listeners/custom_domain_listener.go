@@ -0,0 +1,112 @@
+package listeners
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"gorm.io/gorm"
+)
+
+// customDomainCerts issues certificates for verified organization custom domains
+var (
+	customDomainCerts *autocert.Manager
+	customDomainDB    *gorm.DB
+)
+
+// InitCustomDomainCertificates sets up automatic certificate issuance for custom domains.
+// Certificates are obtained with the TLS-ALPN-01 challenge on the HTTPS listener, so the
+// server must be reachable on port 443 of each custom domain.
+func InitCustomDomainCertificates(db *gorm.DB) {
+	cfg := config.GlobalConfig.Domains
+	if !cfg.AutoTLS {
+		return
+	}
+
+	m := &autocert.Manager{
+		Prompt: autocert.AcceptTOS,
+		Cache:  autocert.DirCache(cfg.CertCacheDir),
+		Email:  cfg.ACMEEmail,
+		HostPolicy: func(ctx context.Context, host string) error {
+			if !models.IsVerifiedCustomDomain(db, host) {
+				return models.ErrCustomDomainCNAME
+			}
+			return nil
+		},
+	}
+	if cfg.ACMEDirectory != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectory}
+	}
+	customDomainCerts = m
+	customDomainDB = db
+
+	// Request the certificate as soon as a domain is verified instead of on its first visit
+	utils.Sig().Connect(constants.SigCustomDomainVerified, func(sender any, params ...any) {
+		d, ok := sender.(*models.CustomDomain)
+		if !ok || len(params) < 1 {
+			return
+		}
+		db, ok := params[0].(*gorm.DB)
+		if !ok {
+			return
+		}
+		go issueCustomDomainCertificate(db, d.Domain)
+	})
+
+	logger.Info("Custom domain certificates enabled", zap.String("cacheDir", cfg.CertCacheDir))
+}
+
+// CustomDomainCertificatesEnabled reports whether custom domain certificates are issued automatically
+func CustomDomainCertificatesEnabled() bool {
+	return customDomainCerts != nil
+}
+
+// WithCustomDomainCertificates serves issued certificates to verified custom domains and
+// the static certificate (base) to every other host
+func WithCustomDomainCertificates(base *tls.Config) *tls.Config {
+	if customDomainCerts == nil {
+		return base
+	}
+	if base == nil {
+		base = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	tlsConfig := base.Clone()
+	tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto)
+	tlsConfig.Certificates = nil
+	tlsConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		// autocert answers TLS-ALPN-01 challenges itself and enforces the host policy
+		if len(base.Certificates) == 0 || models.IsVerifiedCustomDomain(customDomainDB, hello.ServerName) {
+			return customDomainCerts.GetCertificate(hello)
+		}
+		return &base.Certificates[0], nil
+	}
+	return tlsConfig
+}
+
+// issueCustomDomainCertificate obtains (or loads from cache) the domain's certificate and records the result
+func issueCustomDomainCertificate(db *gorm.DB, domain string) {
+	if customDomainCerts == nil {
+		return
+	}
+	cert, err := customDomainCerts.GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+	var expiresAt *time.Time
+	if err == nil && cert.Leaf != nil {
+		expiresAt = &cert.Leaf.NotAfter
+	}
+	if err != nil {
+		logger.Warn("custom domain certificate issuance failed", zap.String("domain", domain), zap.Error(err))
+	} else {
+		logger.Info("custom domain certificate issued", zap.String("domain", domain))
+	}
+	if err := models.UpdateCustomDomainCert(db, domain, expiresAt, err); err != nil {
+		logger.Warn("failed to record custom domain certificate", zap.String("domain", domain), zap.Error(err))
+	}
+}
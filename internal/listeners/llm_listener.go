@@ -149,14 +149,13 @@ func InitLLMListenerWithDB(db *gorm.DB) {
 
 					// Get credential ID and group ID from assistant (if assistant is associated with a credential)
 					var assistant models.Assistant
-					if err := llmListenerDB.Where("id = ? AND user_id = ?", *assistantID, *usageInfo.UserID).
+					if err := llmListenerDB.Where("id = ?", *assistantID).
 						First(&assistant).Error; err == nil {
 						// Assistant may be associated with credentials in other ways, temporarily use 0 here
 						// Subsequently, it can be obtained according to actual business logic
-						// Get group ID from assistant if it's organization-shared
-						if assistant.GroupID != nil {
-							groupID = assistant.GroupID
-						}
+						// Attribute usage to the assistant's organization, or to the organization
+						// that shared the assistant with the consuming member
+						groupID = models.AssistantUsageGroupID(llmListenerDB, &assistant, *usageInfo.UserID)
 					}
 				}
 
@@ -1,6 +1,8 @@
 package listeners
 
 import (
+	"time"
+
 	"github.com/code-100-precent/LingEcho/internal/models"
 	"github.com/code-100-precent/LingEcho/pkg/config"
 	"github.com/code-100-precent/LingEcho/pkg/constants"
@@ -47,12 +49,15 @@ func InitUserListeners() {
 
 		logger.Info("User logged in", zap.Uint("userId", user.ID), zap.String("email", user.Email))
 
-		// Send login notification
-		go sendWelcomeEmail(user, params[0].(*gorm.DB))
-
-		notification.NewInternalNotificationService(params[0].(*gorm.DB)).Send(user.ID,
-			"Welcome back",
-			"Dear "+user.DisplayName+", welcome back to LingEcho AI voice platform! You have successfully logged into the system.")
+		// Send login notification, subject to the user's notification preferences
+		db := params[0].(*gorm.DB)
+		go models.DispatchNotification(db, user, models.Notice{
+			Event:     models.NotificationEventAccount,
+			Title:     "Welcome back",
+			Content:   "Dear " + user.DisplayName + ", welcome back to LingEcho AI voice platform! You have successfully logged into the system.",
+			Channels:  []models.NotificationChannel{models.NotificationChannelInternal, models.NotificationChannelEmail},
+			SendEmail: func(u *models.User) error { return sendWelcomeEmail(u, db) },
+		})
 
 		// Log login event
 		logUserEvent(user, "user_login", "User login")
@@ -172,20 +177,78 @@ func InitUserListeners() {
 
 		// Send new device login alert email
 		go sendNewDeviceLoginAlert(user, deviceInfo, db)
+
+		// Suspicious logins are also pushed to the mobile app
+		if isSuspicious, _ := deviceInfo["isSuspicious"].(bool); isSuspicious {
+			go pushSuspiciousLogin(user, deviceInfo, db)
+		}
+	})
+
+	// Self-service unlock of an account locked by failed logins
+	utils.Sig().Connect(constants.SigUserAccountUnlockRequest, func(sender any, params ...any) {
+		if len(params) < 3 {
+			return
+		}
+		user, ok := sender.(*models.User)
+		if !ok {
+			return
+		}
+		lock, ok := params[0].(*models.AccountLock)
+		if !ok {
+			return
+		}
+		token, ok := params[1].(string)
+		if !ok {
+			return
+		}
+		db, ok := params[2].(*gorm.DB)
+		if !ok {
+			return
+		}
+
+		logger.Info("Sending account unlock email", zap.Uint("userId", user.ID), zap.String("email", user.Email))
+
+		go sendAccountUnlockEmail(user, lock, token, db)
+	})
+
+	// A login lock was lifted by an admin or expired
+	utils.Sig().Connect(constants.SigUserAccountUnlocked, func(sender any, params ...any) {
+		if len(params) < 2 {
+			return
+		}
+		user, ok := sender.(*models.User)
+		if !ok {
+			return
+		}
+		lock, ok := params[0].(*models.AccountLock)
+		if !ok {
+			return
+		}
+		db, ok := params[1].(*gorm.DB)
+		if !ok {
+			return
+		}
+
+		logger.Info("Account unlocked",
+			zap.Uint("userId", user.ID),
+			zap.String("email", user.Email),
+			zap.String("method", lock.UnlockMethod))
+
+		go notifyAccountUnlocked(user, lock, db)
 	})
 
 	logger.Info("User module listeners initialized successfully")
 }
 
 // sendWelcomeEmail sends welcome email
-func sendWelcomeEmail(user *models.User, db *gorm.DB) {
+func sendWelcomeEmail(user *models.User, db *gorm.DB) error {
 	if config.GlobalConfig.Services.Mail.APIUser == "" || config.GlobalConfig.Services.Mail.From == "" || config.GlobalConfig.Services.Mail.APIKey == "" {
 		logger.Warn("Mail configuration not set, skipping sending login notification")
-		return
+		return nil
 	}
 
 	if user.EmailNotifications {
-		mailer := notification.NewMailNotificationWithDB(config.GlobalConfig.Services.Mail, db, user.ID)
+		mailer := notification.NewMailNotificationWithDB(models.UserMailConfig(db, user.ID, config.GlobalConfig.Services.Mail), db, user.ID)
 		err := mailer.SendWelcomeEmail(
 			user.Email,
 			user.DisplayName,
@@ -194,10 +257,11 @@ func sendWelcomeEmail(user *models.User, db *gorm.DB) {
 
 		if err != nil {
 			logger.Error("Failed to send welcome email", zap.Error(err), zap.String("email", user.Email))
-		} else {
-			logger.Info("Welcome email sent successfully", zap.String("email", user.Email))
+			return err
 		}
+		logger.Info("Welcome email sent successfully", zap.String("email", user.Email))
 	}
+	return nil
 }
 
 // sendEmailVerification sends email verification
@@ -225,7 +289,7 @@ func sendEmailVerification(user *models.User, hash, clientIp, userAgent string,
 		zap.String("verifyUrl", verifyUrl),
 		zap.String("mailAPIUser", config.GlobalConfig.Services.Mail.APIUser))
 
-	mailer := notification.NewMailNotificationWithDB(config.GlobalConfig.Services.Mail, db, user.ID)
+	mailer := notification.NewMailNotificationWithDB(models.UserMailConfig(db, user.ID, config.GlobalConfig.Services.Mail), db, user.ID)
 	err := mailer.SendVerificationEmail(user.Email, user.DisplayName, verifyUrl)
 	if err != nil {
 		logger.Error("Failed to send email verification", zap.Error(err), zap.String("email", user.Email))
@@ -250,7 +314,7 @@ func sendPasswordResetEmail(user *models.User, hash, clientIp, userAgent string,
 	// Build password reset URL
 	resetUrl := siteURL + "/reset-password?token=" + hash
 
-	mailer := notification.NewMailNotificationWithDB(config.GlobalConfig.Services.Mail, db, user.ID)
+	mailer := notification.NewMailNotificationWithDB(models.UserMailConfig(db, user.ID, config.GlobalConfig.Services.Mail), db, user.ID)
 	err := mailer.SendPasswordResetEmail(user.Email, user.DisplayName, resetUrl)
 	if err != nil {
 		logger.Error("Failed to send password reset email", zap.Error(err), zap.String("email", user.Email))
@@ -259,6 +323,76 @@ func sendPasswordResetEmail(user *models.User, hash, clientIp, userAgent string,
 	}
 }
 
+// sendAccountUnlockEmail sends the self-service unlock link
+func sendAccountUnlockEmail(user *models.User, lock *models.AccountLock, token string, db *gorm.DB) {
+	if config.GlobalConfig.Services.Mail.APIUser == "" {
+		logger.Warn("Mail configuration not set, skipping sending account unlock email")
+		return
+	}
+
+	// Get site URL
+	siteURL := utils.GetValue(db, constants.KEY_SITE_URL)
+	if siteURL == "" {
+		siteURL = "http://localhost:3000" // Default value
+	}
+
+	unlockUrl := siteURL + "/unlock-account?token=" + token
+
+	mailer := notification.NewMailNotificationWithDB(models.UserMailConfig(db, user.ID, config.GlobalConfig.Services.Mail), db, user.ID)
+	err := mailer.SendAccountUnlockEmail(user.Email, user.DisplayName, unlockUrl, lock.UnlockAt.Format("2006-01-02 15:04"))
+	if err != nil {
+		logger.Error("Failed to send account unlock email", zap.Error(err), zap.String("email", user.Email))
+	} else {
+		logger.Info("Account unlock email sent successfully", zap.String("email", user.Email))
+	}
+}
+
+// notifyAccountUnlocked tells the user their account can sign in again, in-app and by email
+// subject to their notification preferences
+func notifyAccountUnlocked(user *models.User, lock *models.AccountLock, db *gorm.DB) {
+	byAdmin := lock.UnlockMethod == models.AccountUnlockAdmin
+	content := "The login lock on your account has expired, you can sign in again."
+	if byAdmin {
+		content = "An administrator lifted the login lock on your account, you can sign in again."
+	}
+	unlockedAt := time.Now()
+	if lock.UnlockedAt != nil {
+		unlockedAt = *lock.UnlockedAt
+	}
+
+	models.DispatchNotification(db, user, models.Notice{
+		Event:    models.NotificationEventSecurity,
+		Title:    "Account unlocked",
+		Content:  content + " If the failed logins were not you, change your password now.",
+		Channels: []models.NotificationChannel{models.NotificationChannelInternal, models.NotificationChannelEmail},
+		SendEmail: func(u *models.User) error {
+			if config.GlobalConfig.Services.Mail.APIUser == "" {
+				logger.Warn("Mail configuration not set, skipping sending account unlocked email")
+				return nil
+			}
+			siteURL := utils.GetValue(db, constants.KEY_SITE_URL)
+			if siteURL == "" {
+				siteURL = "http://localhost:3000" // Default value
+			}
+			displayName := u.DisplayName
+			if displayName == "" {
+				displayName = u.Email
+			}
+			mailer := notification.NewMailNotificationWithDB(models.UserMailConfig(db, u.ID, config.GlobalConfig.Services.Mail), db, u.ID)
+			return mailer.SendAccountUnlockedEmail(
+				u.Email,
+				displayName,
+				lock.LockedAt.Format("2006-01-02 15:04"),
+				unlockedAt.Format("2006-01-02 15:04"),
+				lock.IPAddress,
+				lock.FailedAttempts,
+				byAdmin,
+				siteURL+"/password",
+			)
+		},
+	})
+}
+
 // logUserEvent logs user events
 func logUserEvent(user *models.User, eventType, description string) {
 	// Here you can log user events to database or logging system
@@ -269,6 +403,22 @@ func logUserEvent(user *models.User, eventType, description string) {
 	)
 }
 
+// pushSuspiciousLogin pushes a suspicious login alert to the user's mobile devices
+func pushSuspiciousLogin(user *models.User, deviceInfo map[string]interface{}, db *gorm.DB) {
+	data := map[string]string{}
+	for key, field := range map[string]string{"clientIP": "clientIp", "location": "location", "loginTime": "loginTime", "deviceID": "deviceId"} {
+		data[field], _ = deviceInfo[key].(string)
+	}
+	notice, err := models.NoticeFromPushTemplate(models.NotificationEventSecurity, notification.PushTemplateSuspiciousLogin, data)
+	if err != nil {
+		logger.Error("Failed to render suspicious login push", zap.Error(err))
+		return
+	}
+	notice.Critical = true
+	notice.Channels = []models.NotificationChannel{models.NotificationChannelPush}
+	models.DispatchNotification(db, user, notice)
+}
+
 // sendNewDeviceLoginAlert sends new device login alert email
 func sendNewDeviceLoginAlert(user *models.User, deviceInfo map[string]interface{}, db *gorm.DB) {
 	if config.GlobalConfig.Services.Mail.APIUser == "" {
@@ -285,6 +435,14 @@ func sendNewDeviceLoginAlert(user *models.User, deviceInfo map[string]interface{
 	isSuspicious, _ := deviceInfo["isSuspicious"].(bool)
 	loginTime, _ := deviceInfo["loginTime"].(string)
 
+	// Suspicious logins are critical and may bypass quiet hours
+	if ok, reason := models.NotificationAllowed(db, user, models.NotificationEventSecurity, models.NotificationChannelEmail, isSuspicious); !ok {
+		logger.Info("New device login alert suppressed by notification preferences",
+			zap.Uint("userId", user.ID),
+			zap.String("reason", reason))
+		return
+	}
+
 	// Get display name
 	displayName := user.DisplayName
 	if displayName == "" {
@@ -301,7 +459,7 @@ func sendNewDeviceLoginAlert(user *models.User, deviceInfo map[string]interface{
 	changePasswordURL := siteURL + "/password" // Change password page
 
 	// Send the alert email
-	mailer := notification.NewMailNotificationWithDB(config.GlobalConfig.Services.Mail, db, user.ID)
+	mailer := notification.NewMailNotificationWithDB(models.UserMailConfig(db, user.ID, config.GlobalConfig.Services.Mail), db, user.ID)
 	err := mailer.SendNewDeviceLoginAlert(
 		user.Email,
 		displayName,
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AICallState AI 代接通话的对话阶段
+type AICallState string
+
+const (
+	AICallStateListening AICallState = "listening" // 等待对方说话
+	AICallStateThinking  AICallState = "thinking"  // 已识别一轮对话，回答（LLM、工具调用、TTS）进行中
+	AICallStateSpeaking  AICallState = "speaking"  // 正在播放回答
+	AICallStateMessage   AICallState = "message"   // 留言阶段
+)
+
+// AICallCheckpoint AI 代接通话的轻量检查点。
+// 进程重启或实例宕机后，重启的实例或 HA 对端据此恢复会话，或正常挂断并补全通话记录。
+type AICallCheckpoint struct {
+	ID            uint        `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time   `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time   `json:"updatedAt" gorm:"autoUpdateTime"`
+	CallID        string      `json:"callId" gorm:"size:128;uniqueIndex;not null"`
+	Instance      string      `json:"instance" gorm:"size:128;index"` // 持有通话的实例
+	SipUserID     uint        `json:"sipUserId"`
+	AssistantID   int64       `json:"assistantId"`
+	ClientRTPAddr string      `json:"clientRtpAddr" gorm:"size:64"`
+	State         AICallState `json:"state" gorm:"size:16"`
+	Turns         int         `json:"turns"`                                   // 已完成的对话轮次
+	PendingTurn   string      `json:"pendingTurn,omitempty" gorm:"type:text"`  // 尚未回答完成的用户话语
+	LastUserText  string      `json:"lastUserText,omitempty" gorm:"type:text"` // 上一轮用户话语
+	LastAIText    string      `json:"lastAiText,omitempty" gorm:"type:text"`   // 上一轮回答
+	MessageAt     *time.Time  `json:"messageAt,omitempty"`                     // 进入留言阶段的时间
+	Resumes       int         `json:"resumes"`                                 // 已被恢复的次数
+	Heartbeat     time.Time   `json:"heartbeat" gorm:"index"`                  // 持有实例最后一次确认通话仍在进行
+
+	// 对话标识，恢复失败时用于发送 BYE
+	LocalURI     string `json:"localUri,omitempty" gorm:"size:256"`
+	LocalTag     string `json:"localTag,omitempty" gorm:"size:128"`
+	RemoteURI    string `json:"remoteUri,omitempty" gorm:"size:256"`
+	RemoteTag    string `json:"remoteTag,omitempty" gorm:"size:128"`
+	RemoteTarget string `json:"remoteTarget,omitempty" gorm:"size:256"` // 对方 Contact
+}
+
+// TableName 指定表名
+func (AICallCheckpoint) TableName() string {
+	return "ai_call_checkpoints"
+}
+
+// SaveAICallCheckpoint 写入通话的检查点并刷新心跳
+func SaveAICallCheckpoint(db *gorm.DB, cp *AICallCheckpoint) error {
+	cp.Heartbeat = time.Now()
+	return db.Save(cp).Error
+}
+
+// DeleteAICallCheckpoint 通话正常结束后删除检查点
+func DeleteAICallCheckpoint(db *gorm.DB, callID string) error {
+	return db.Where("call_id = ?", callID).Delete(&AICallCheckpoint{}).Error
+}
+
+// TouchAICallCheckpoints 刷新实例持有的通话的心跳
+func TouchAICallCheckpoints(db *gorm.DB, instance string, callIDs []string) error {
+	if len(callIDs) == 0 {
+		return nil
+	}
+	return db.Model(&AICallCheckpoint{}).
+		Where("instance = ? AND call_id IN ?", instance, callIDs).
+		Update("heartbeat", time.Now()).Error
+}
+
+// ListOrphanedAICallCheckpoints 列出无人持有的检查点：
+// 属于本实例但不在 active 中的（进程重启前遗留），以及心跳早于 staleBefore 的（对端宕机）
+func ListOrphanedAICallCheckpoints(db *gorm.DB, instance string, active []string, staleBefore time.Time) ([]AICallCheckpoint, error) {
+	query := db.Where("instance = ? OR heartbeat < ?", instance, staleBefore)
+	if len(active) > 0 {
+		query = query.Where("call_id NOT IN ?", active)
+	}
+	var checkpoints []AICallCheckpoint
+	err := query.Order("heartbeat").Find(&checkpoints).Error
+	return checkpoints, err
+}
+
+// ClaimAICallCheckpoint 将检查点转到本实例，检查点已被其他实例接管时返回 false
+func ClaimAICallCheckpoint(db *gorm.DB, cp *AICallCheckpoint, instance string) (bool, error) {
+	now := time.Now()
+	result := db.Model(&AICallCheckpoint{}).
+		Where("id = ? AND instance = ? AND heartbeat = ?", cp.ID, cp.Instance, cp.Heartbeat).
+		Updates(map[string]interface{}{"instance": instance, "heartbeat": now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	cp.Instance, cp.Heartbeat = instance, now
+	return true, nil
+}
+
+// EndInterruptedSipCall 结束一个因实例中断而未正常挂断的通话记录，
+// 以 endTime（最后一次心跳）为结束时间；已有结束时间的通话不做修改
+func EndInterruptedSipCall(db *gorm.DB, cp *AICallCheckpoint, endTime time.Time, reason string) error {
+	var call SipCall
+	if err := db.Where("call_id = ?", cp.CallID).First(&call).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if call.EndTime != nil && call.Status == SipCallStatusEnded {
+		return nil
+	}
+	answerTime := call.AnswerTime
+	if answerTime == nil {
+		answerTime = &cp.CreatedAt
+	}
+	duration := int(endTime.Sub(*answerTime).Seconds())
+	if duration < 0 {
+		duration = 0
+	}
+	return db.Model(&call).Updates(map[string]interface{}{
+		"status":        SipCallStatusEnded,
+		"answer_time":   answerTime,
+		"end_time":      &endTime,
+		"duration":      duration,
+		"error_message": reason,
+	}).Error
+}
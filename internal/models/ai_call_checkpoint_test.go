@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAICallCheckpointLifecycle(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &AICallCheckpoint{})
+
+	cp := &AICallCheckpoint{CallID: "call-1", Instance: "node-a", State: AICallStateListening}
+	require.NoError(t, SaveAICallCheckpoint(db, cp))
+	cp.State = AICallStateThinking
+	cp.PendingTurn = "我想预约明天下午"
+	require.NoError(t, SaveAICallCheckpoint(db, cp))
+
+	var count int64
+	db.Model(&AICallCheckpoint{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	stale := &AICallCheckpoint{CallID: "call-2", Instance: "node-b", State: AICallStateSpeaking}
+	require.NoError(t, SaveAICallCheckpoint(db, stale))
+	require.NoError(t, db.Model(stale).Update("heartbeat", time.Now().Add(-time.Minute)).Error)
+	live := &AICallCheckpoint{CallID: "call-3", Instance: "node-b", State: AICallStateListening}
+	require.NoError(t, SaveAICallCheckpoint(db, live))
+
+	// node-a restarted: its own leftover and node-b's stale call are orphans
+	orphans, err := ListOrphanedAICallCheckpoints(db, "node-a", nil, time.Now().Add(-30*time.Second))
+	require.NoError(t, err)
+	require.Len(t, orphans, 2)
+	assert.Equal(t, "call-2", orphans[0].CallID)
+	assert.Equal(t, "call-1", orphans[1].CallID)
+	assert.Equal(t, "我想预约明天下午", orphans[1].PendingTurn)
+
+	orphans, err = ListOrphanedAICallCheckpoints(db, "node-a", []string{"call-1"}, time.Now().Add(-30*time.Second))
+	require.NoError(t, err)
+	require.Len(t, orphans, 1)
+
+	// Only one instance wins the claim
+	first, second := orphans[0], orphans[0]
+	ok, err := ClaimAICallCheckpoint(db, &first, "node-a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = ClaimAICallCheckpoint(db, &second, "node-c")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, DeleteAICallCheckpoint(db, "call-2"))
+	db.Model(&AICallCheckpoint{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestEndInterruptedSipCall(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{}, &AICallCheckpoint{})
+	start := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, db.Create(&SipCall{CallID: "call-1", Status: SipCallStatusRinging, StartTime: start}).Error)
+
+	cp := &AICallCheckpoint{CallID: "call-1", Instance: "node-a"}
+	require.NoError(t, SaveAICallCheckpoint(db, cp))
+	cp.CreatedAt = start
+	lastSeen := start.Add(90 * time.Second)
+
+	require.NoError(t, EndInterruptedSipCall(db, cp, lastSeen, "instance restarted"))
+	call, err := GetSipCallByCallID(db, "call-1")
+	require.NoError(t, err)
+	assert.Equal(t, SipCallStatusEnded, call.Status)
+	assert.Equal(t, 90, call.Duration)
+	assert.Equal(t, "instance restarted", call.ErrorMessage)
+	require.NotNil(t, call.EndTime)
+
+	// Calls ended normally meanwhile are left alone
+	require.NoError(t, EndInterruptedSipCall(db, cp, lastSeen.Add(time.Hour), "again"))
+	call, err = GetSipCallByCallID(db, "call-1")
+	require.NoError(t, err)
+	assert.Equal(t, "instance restarted", call.ErrorMessage)
+
+	assert.NoError(t, EndInterruptedSipCall(db, &AICallCheckpoint{CallID: "missing"}, lastSeen, "gone"))
+}
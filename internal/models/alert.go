@@ -13,6 +13,8 @@ const (
 	AlertTypeQuotaExceeded AlertType = "quota_exceeded" // Quota exceeded alert
 	AlertTypeServiceError  AlertType = "service_error"  // Service error alert
 	AlertTypeCustom        AlertType = "custom"         // Custom alert
+	AlertTypeGeofence      AlertType = "geofence"       // Device left its allowed region
+	AlertTypeAudioQuality  AlertType = "audio_quality"  // Device microphone test scored below threshold
 )
 
 // AlertSeverity defines the severity level of alert
@@ -42,6 +44,7 @@ const (
 	NotificationChannelInternal NotificationChannel = "internal" // Internal notification
 	NotificationChannelWebhook  NotificationChannel = "webhook"  // Webhook
 	NotificationChannelSMS      NotificationChannel = "sms"      // SMS (reserved)
+	NotificationChannelPush     NotificationChannel = "push"     // Mobile app push (FCM/APNs)
 )
 
 // AlertRule defines alert rule configuration
@@ -140,7 +143,7 @@ type AlertNotification struct {
 	Alert   Alert `json:"alert,omitempty" gorm:"foreignKey:AlertID"`
 
 	Channel NotificationChannel `json:"channel" gorm:"size:20"`             // Notification channel
-	Status  string              `json:"status" gorm:"size:20"`              // Notification status: success, failed
+	Status  string              `json:"status" gorm:"size:20"`              // Notification status: success, failed, suppressed
 	Message string              `json:"message,omitempty" gorm:"type:text"` // Notification message or error message
 
 	SentAt *time.Time `json:"sentAt,omitempty"` // Send time
@@ -0,0 +1,334 @@
+package models
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// apiKeyLatencyBounds 延迟直方图各桶的上限（毫秒），最后一个桶收集超过 10 秒的请求
+var apiKeyLatencyBounds = []float64{25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// APIKeyUsageRetention 用量汇总的保留时间
+const APIKeyUsageRetention = 90 * 24 * time.Hour
+
+// APIKeyUsageRollup 按小时、API Key 和接口汇总的请求数、错误数与延迟直方图，
+// 由请求中间件在内存中累加后定时写入，用量看板直接查询本表
+type APIKeyUsageRollup struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt    time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	Hour         time.Time `json:"hour" gorm:"uniqueIndex:idx_api_key_usage_rollup;index"` // 小时起点（UTC）
+	UserID       uint      `json:"userId" gorm:"index"`
+	CredentialID uint      `json:"credentialId" gorm:"uniqueIndex:idx_api_key_usage_rollup"`
+	Endpoint     string    `json:"endpoint" gorm:"size:255;uniqueIndex:idx_api_key_usage_rollup"` // 如 POST /api/voice/oneshot_text
+	Requests     int64     `json:"requests"`
+	Errors       int64     `json:"errors"`       // HTTP 状态码 >= 400 或以 response.Fail 返回
+	LatencySumMs int64     `json:"latencySumMs"` // 延迟总和，用于计算平均值
+	Le25         int64     `json:"le25" gorm:"column:le_25"`
+	Le50         int64     `json:"le50" gorm:"column:le_50"`
+	Le100        int64     `json:"le100" gorm:"column:le_100"`
+	Le250        int64     `json:"le250" gorm:"column:le_250"`
+	Le500        int64     `json:"le500" gorm:"column:le_500"`
+	Le1000       int64     `json:"le1000" gorm:"column:le_1000"`
+	Le2500       int64     `json:"le2500" gorm:"column:le_2500"`
+	Le5000       int64     `json:"le5000" gorm:"column:le_5000"`
+	Le10000      int64     `json:"le10000" gorm:"column:le_10000"`
+	Over10000    int64     `json:"over10000" gorm:"column:over_10000"`
+}
+
+// TableName 指定表名
+func (APIKeyUsageRollup) TableName() string {
+	return "api_key_usage_rollups"
+}
+
+// apiKeyHistogramColumns 直方图各桶对应的列，与 apiKeyLatencyBounds 顺序一致
+var apiKeyHistogramColumns = []string{"le_25", "le_50", "le_100", "le_250", "le_500", "le_1000", "le_2500", "le_5000", "le_10000", "over_10000"}
+
+// histogram 按桶顺序返回计数字段的指针
+func (r *APIKeyUsageRollup) histogram() []*int64 {
+	return []*int64{&r.Le25, &r.Le50, &r.Le100, &r.Le250, &r.Le500, &r.Le1000, &r.Le2500, &r.Le5000, &r.Le10000, &r.Over10000}
+}
+
+// observe 累加一次请求
+func (r *APIKeyUsageRollup) observe(failed bool, latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+	r.Requests++
+	if failed {
+		r.Errors++
+	}
+	r.LatencySumMs += latency.Milliseconds()
+	buckets := r.histogram()
+	for i, bound := range apiKeyLatencyBounds {
+		if ms <= bound {
+			*buckets[i]++
+			return
+		}
+	}
+	*buckets[len(buckets)-1]++
+}
+
+// merge 合并另一条汇总的计数
+func (r *APIKeyUsageRollup) merge(other *APIKeyUsageRollup) {
+	r.Requests += other.Requests
+	r.Errors += other.Errors
+	r.LatencySumMs += other.LatencySumMs
+	dst, src := r.histogram(), other.histogram()
+	for i := range dst {
+		*dst[i] += *src[i]
+	}
+}
+
+// percentile 根据直方图估算延迟分位数（毫秒），在桶内线性插值；超过 10 秒的桶按 10 秒计
+func (r *APIKeyUsageRollup) percentile(p float64) float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	rank := p * float64(r.Requests)
+	var seen float64
+	lower := 0.0
+	for i, count := range r.histogram() {
+		if i == len(apiKeyLatencyBounds) {
+			return lower
+		}
+		upper := apiKeyLatencyBounds[i]
+		if c := float64(*count); c > 0 && seen+c >= rank {
+			return lower + (upper-lower)*(rank-seen)/c
+		}
+		seen += float64(*count)
+		lower = upper
+	}
+	return lower
+}
+
+// APIKeyUsageSummary 一个 API Key（或其某个接口、某个小时）在统计窗口内的用量
+type APIKeyUsageSummary struct {
+	CredentialID uint       `json:"credentialId"`
+	Name         string     `json:"name,omitempty"`
+	Endpoint     string     `json:"endpoint,omitempty"`
+	Hour         *time.Time `json:"hour,omitempty"`
+	Requests     int64      `json:"requests"`
+	Errors       int64      `json:"errors"`
+	ErrorRate    float64    `json:"errorRate"` // 0-1
+	AvgLatencyMs float64    `json:"avgLatencyMs"`
+	P50Ms        float64    `json:"p50Ms"`
+	P90Ms        float64    `json:"p90Ms"`
+	P99Ms        float64    `json:"p99Ms"`
+}
+
+func (r *APIKeyUsageRollup) summary() APIKeyUsageSummary {
+	s := APIKeyUsageSummary{
+		CredentialID: r.CredentialID,
+		Endpoint:     r.Endpoint,
+		Requests:     r.Requests,
+		Errors:       r.Errors,
+		P50Ms:        r.percentile(0.50),
+		P90Ms:        r.percentile(0.90),
+		P99Ms:        r.percentile(0.99),
+	}
+	if r.Requests > 0 {
+		s.ErrorRate = float64(r.Errors) / float64(r.Requests)
+		s.AvgLatencyMs = float64(r.LatencySumMs) / float64(r.Requests)
+	}
+	return s
+}
+
+type apiKeyUsageKey struct {
+	Hour         time.Time
+	UserID       uint
+	CredentialID uint
+	Endpoint     string
+}
+
+// APIKeyUsageCollector 在内存中累加 API Key 请求，Flush 时写入 api_key_usage_rollups
+type APIKeyUsageCollector struct {
+	mu      sync.Mutex
+	pending map[apiKeyUsageKey]*APIKeyUsageRollup
+}
+
+// NewAPIKeyUsageCollector 创建用量收集器
+func NewAPIKeyUsageCollector() *APIKeyUsageCollector {
+	return &APIKeyUsageCollector{pending: map[apiKeyUsageKey]*APIKeyUsageRollup{}}
+}
+
+// DefaultAPIKeyUsage 请求中间件使用的收集器，由定时任务刷新
+var DefaultAPIKeyUsage = NewAPIKeyUsageCollector()
+
+// Record 记录一次 API Key 请求
+func (u *APIKeyUsageCollector) Record(userID, credentialID uint, endpoint string, failed bool, latency time.Duration, at time.Time) {
+	key := apiKeyUsageKey{
+		Hour:         at.UTC().Truncate(time.Hour),
+		UserID:       userID,
+		CredentialID: credentialID,
+		Endpoint:     endpoint,
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	rollup, ok := u.pending[key]
+	if !ok {
+		rollup = &APIKeyUsageRollup{Hour: key.Hour, UserID: userID, CredentialID: credentialID, Endpoint: endpoint}
+		u.pending[key] = rollup
+	}
+	rollup.observe(failed, latency)
+}
+
+// Flush 把累加的用量写入数据库，写入失败的部分留到下次刷新
+func (u *APIKeyUsageCollector) Flush(db *gorm.DB) (int, error) {
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = map[apiKeyUsageKey]*APIKeyUsageRollup{}
+	u.mu.Unlock()
+
+	flushed := 0
+	var flushErr error
+	for key, delta := range pending {
+		if flushErr == nil {
+			if flushErr = addAPIKeyUsage(db, delta); flushErr == nil {
+				flushed++
+				continue
+			}
+		}
+		u.mu.Lock()
+		if rollup, ok := u.pending[key]; ok {
+			rollup.merge(delta)
+		} else {
+			u.pending[key] = delta
+		}
+		u.mu.Unlock()
+	}
+	return flushed, flushErr
+}
+
+// addAPIKeyUsage 把增量累加到对应小时的汇总行，多个实例同时写入也不会丢失计数
+func addAPIKeyUsage(db *gorm.DB, delta *APIKeyUsageRollup) error {
+	row := &APIKeyUsageRollup{Hour: delta.Hour, UserID: delta.UserID, CredentialID: delta.CredentialID, Endpoint: delta.Endpoint}
+	if _, err := CreateOrGet(db, row, "Hour", "CredentialID", "Endpoint"); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"requests":       gorm.Expr("requests + ?", delta.Requests),
+		"errors":         gorm.Expr("errors + ?", delta.Errors),
+		"latency_sum_ms": gorm.Expr("latency_sum_ms + ?", delta.LatencySumMs),
+	}
+	for i, count := range delta.histogram() {
+		if *count > 0 {
+			column := apiKeyHistogramColumns[i]
+			updates[column] = gorm.Expr(column+" + ?", *count)
+		}
+	}
+	return db.Model(&APIKeyUsageRollup{}).Where("id = ?", row.ID).Updates(updates).Error
+}
+
+// TrackAPIKeyUsage 记录通过 API Key 认证的请求，WebSocket 连接时长不计入延迟统计
+func TrackAPIKeyUsage(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	credential := CurrentCredential(c)
+	if credential == nil || c.FullPath() == "" || c.IsWebsocket() {
+		return
+	}
+	failed := c.Writer.Status() >= http.StatusBadRequest || response.Failed(c)
+	DefaultAPIKeyUsage.Record(credential.UserID, credential.ID, c.Request.Method+" "+c.FullPath(), failed, time.Since(start), start)
+}
+
+// CleanupAPIKeyUsage 删除超过保留期的用量汇总
+func CleanupAPIKeyUsage(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Where("hour < ?", now.UTC().Add(-APIKeyUsageRetention)).Delete(&APIKeyUsageRollup{})
+	return result.RowsAffected, result.Error
+}
+
+// APIKeyUsageReport 用户在统计窗口内的 API 用量
+type APIKeyUsageReport struct {
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Total     APIKeyUsageSummary   `json:"total"`
+	Keys      []APIKeyUsageSummary `json:"keys"`      // 按 API Key 汇总，请求数降序
+	Endpoints []APIKeyUsageSummary `json:"endpoints"` // 按 API Key 和接口汇总，请求数降序
+	Series    []APIKeyUsageSummary `json:"series"`    // 按小时汇总，时间升序
+}
+
+// GetAPIKeyUsageReport 汇总用户在 [from, to) 内的 API 用量，credentialID 非空时只统计该 Key
+func GetAPIKeyUsageReport(db *gorm.DB, userID uint, credentialID *uint, from, to time.Time) (*APIKeyUsageReport, error) {
+	query := db.Where("user_id = ? AND hour >= ? AND hour < ?", userID, from.UTC().Truncate(time.Hour), to.UTC())
+	if credentialID != nil {
+		query = query.Where("credential_id = ?", *credentialID)
+	}
+	var rows []APIKeyUsageRollup
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var names []struct {
+		ID   uint
+		Name string
+	}
+	if err := db.Model(&UserCredential{}).Where("user_id = ?", userID).Select("id, name").Scan(&names).Error; err != nil {
+		return nil, err
+	}
+	nameOf := make(map[uint]string, len(names))
+	for _, n := range names {
+		nameOf[n.ID] = n.Name
+	}
+
+	total := &APIKeyUsageRollup{}
+	keys := map[uint]*APIKeyUsageRollup{}
+	endpoints := map[apiKeyUsageKey]*APIKeyUsageRollup{}
+	hours := map[time.Time]*APIKeyUsageRollup{}
+	for i := range rows {
+		row := &rows[i]
+		total.merge(row)
+		mergeAPIKeyUsage(keys, row.CredentialID, &APIKeyUsageRollup{CredentialID: row.CredentialID}, row)
+		mergeAPIKeyUsage(endpoints, apiKeyUsageKey{CredentialID: row.CredentialID, Endpoint: row.Endpoint},
+			&APIKeyUsageRollup{CredentialID: row.CredentialID, Endpoint: row.Endpoint}, row)
+		mergeAPIKeyUsage(hours, row.Hour.UTC(), &APIKeyUsageRollup{Hour: row.Hour.UTC()}, row)
+	}
+
+	report := &APIKeyUsageReport{
+		From:      from,
+		To:        to,
+		Total:     total.summary(),
+		Keys:      summarizeByRequests(keys, nameOf),
+		Endpoints: summarizeByRequests(endpoints, nameOf),
+		Series:    make([]APIKeyUsageSummary, 0, len(hours)),
+	}
+	for hour, r := range hours {
+		s := r.summary()
+		s.Hour = &hour
+		report.Series = append(report.Series, s)
+	}
+	sort.Slice(report.Series, func(i, j int) bool { return report.Series[i].Hour.Before(*report.Series[j].Hour) })
+	return report, nil
+}
+
+func mergeAPIKeyUsage[K comparable](groups map[K]*APIKeyUsageRollup, key K, empty, row *APIKeyUsageRollup) {
+	group, ok := groups[key]
+	if !ok {
+		group = empty
+		groups[key] = group
+	}
+	group.merge(row)
+}
+
+func summarizeByRequests[K comparable](groups map[K]*APIKeyUsageRollup, nameOf map[uint]string) []APIKeyUsageSummary {
+	list := make([]APIKeyUsageSummary, 0, len(groups))
+	for _, r := range groups {
+		s := r.summary()
+		s.Name = nameOf[r.CredentialID]
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Requests != list[j].Requests {
+			return list[i].Requests > list[j].Requests
+		}
+		if list[i].CredentialID != list[j].CredentialID {
+			return list[i].CredentialID < list[j].CredentialID
+		}
+		return list[i].Endpoint < list[j].Endpoint
+	})
+	return list
+}
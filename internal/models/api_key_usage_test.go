@@ -0,0 +1,129 @@
+package models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyUsageRollup_Percentile(t *testing.T) {
+	r := &APIKeyUsageRollup{}
+	for i := 0; i < 90; i++ {
+		r.observe(false, 20*time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		r.observe(true, 800*time.Millisecond)
+	}
+
+	s := r.summary()
+	assert.Equal(t, int64(100), s.Requests)
+	assert.Equal(t, int64(10), s.Errors)
+	assert.InDelta(t, 0.1, s.ErrorRate, 1e-9)
+	assert.InDelta(t, 98, s.AvgLatencyMs, 1e-9)
+	assert.LessOrEqual(t, s.P50Ms, 25.0)
+	assert.LessOrEqual(t, s.P90Ms, 25.0)
+	assert.Greater(t, s.P99Ms, 500.0)
+	assert.LessOrEqual(t, s.P99Ms, 1000.0)
+
+	slow := &APIKeyUsageRollup{}
+	slow.observe(false, 30*time.Second)
+	assert.Equal(t, 10000.0, slow.percentile(0.99))
+	assert.Zero(t, (&APIKeyUsageRollup{}).percentile(0.5))
+}
+
+func TestAPIKeyUsageCollector_Flush(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &APIKeyUsageRollup{}, &UserCredential{})
+	require.NoError(t, db.Create(&UserCredential{UserID: 1, Name: "prod", APIKey: "k1", APISecret: "s1"}).Error)
+	require.NoError(t, db.Create(&UserCredential{UserID: 1, Name: "staging", APIKey: "k2", APISecret: "s2"}).Error)
+
+	now := time.Now()
+	collector := NewAPIKeyUsageCollector()
+	collector.Record(1, 1, "POST /api/voice/oneshot_text", false, 100*time.Millisecond, now)
+	collector.Record(1, 1, "POST /api/voice/oneshot_text", true, 300*time.Millisecond, now)
+	collector.Record(1, 1, "GET /api/chat/chat-session-log", false, 10*time.Millisecond, now)
+	collector.Record(1, 2, "POST /api/voice/oneshot_text", false, 40*time.Millisecond, now)
+	collector.Record(2, 3, "POST /api/voice/oneshot_text", false, 40*time.Millisecond, now)
+
+	flushed, err := collector.Flush(db)
+	require.NoError(t, err)
+	assert.Equal(t, 4, flushed)
+
+	// A second flush adds to the same hourly rows
+	collector.Record(1, 1, "POST /api/voice/oneshot_text", false, 100*time.Millisecond, now)
+	_, err = collector.Flush(db)
+	require.NoError(t, err)
+	var rows int64
+	require.NoError(t, db.Model(&APIKeyUsageRollup{}).Count(&rows).Error)
+	assert.Equal(t, int64(4), rows)
+
+	report, err := GetAPIKeyUsageReport(db, 1, nil, now.Add(-time.Hour), now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), report.Total.Requests)
+	assert.Equal(t, int64(1), report.Total.Errors)
+	require.Len(t, report.Keys, 2)
+	assert.Equal(t, uint(1), report.Keys[0].CredentialID)
+	assert.Equal(t, "prod", report.Keys[0].Name)
+	assert.Equal(t, int64(4), report.Keys[0].Requests)
+	assert.InDelta(t, 0.25, report.Keys[0].ErrorRate, 1e-9)
+	require.Len(t, report.Endpoints, 3)
+	assert.Equal(t, "POST /api/voice/oneshot_text", report.Endpoints[0].Endpoint)
+	assert.Equal(t, int64(3), report.Endpoints[0].Requests)
+	require.Len(t, report.Series, 1)
+	assert.Equal(t, int64(5), report.Series[0].Requests)
+
+	credentialID := uint(2)
+	report, err = GetAPIKeyUsageReport(db, 1, &credentialID, now.Add(-time.Hour), now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.Total.Requests)
+	require.Len(t, report.Keys, 1)
+	assert.Equal(t, "staging", report.Keys[0].Name)
+}
+
+func TestAPIKeyUsageCollector_FlushFailureKeepsPending(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t)
+	collector := NewAPIKeyUsageCollector()
+	collector.Record(1, 1, "GET /api/x", false, time.Millisecond, time.Now())
+
+	_, err := collector.Flush(db)
+	require.Error(t, err)
+
+	require.NoError(t, db.AutoMigrate(&APIKeyUsageRollup{}))
+	flushed, err := collector.Flush(db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+}
+
+func TestTrackAPIKeyUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := DefaultAPIKeyUsage
+	DefaultAPIKeyUsage = NewAPIKeyUsageCollector()
+	defer func() { DefaultAPIKeyUsage = saved }()
+
+	r := gin.New()
+	r.Use(TrackAPIKeyUsage)
+	r.GET("/keyed/:id", func(c *gin.Context) {
+		SetCurrentCredential(c, &UserCredential{BaseModel: BaseModel{ID: 7}, UserID: 3})
+		response.Fail(c, "failed", nil)
+	})
+	r.GET("/session", func(c *gin.Context) {
+		response.Success(c, "ok", nil)
+	})
+	for _, path := range []string{"/keyed/1", "/keyed/2", "/session"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	require.Len(t, DefaultAPIKeyUsage.pending, 1)
+	for key, rollup := range DefaultAPIKeyUsage.pending {
+		assert.Equal(t, "GET /keyed/:id", key.Endpoint)
+		assert.Equal(t, uint(7), key.CredentialID)
+		assert.Equal(t, uint(3), key.UserID)
+		assert.Equal(t, int64(2), rollup.Requests)
+		assert.Equal(t, int64(2), rollup.Errors)
+	}
+}
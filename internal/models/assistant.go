@@ -2,41 +2,121 @@ package models
 
 import (
 	"crypto/rand"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/code-100-precent/LingEcho/pkg/sessionlimit"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer/textnorm"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"gorm.io/gorm"
 )
 
 // Assistant 表示一个自定义的 AI 助手
 type Assistant struct {
-	ID                   int64     `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID               uint      `json:"userId" gorm:"index"`
-	GroupID              *uint     `json:"groupId,omitempty" gorm:"index"` // 组织ID，如果设置则表示这是组织共享的助手
-	Name                 string    `json:"name" gorm:"index"`
-	Description          string    `json:"description"`
-	Icon                 string    `json:"icon"`
-	SystemPrompt         string    `json:"systemPrompt"`
-	PersonaTag           string    `json:"personaTag"`
-	Temperature          float32   `json:"temperature"`
-	JsSourceID           string    `json:"jsSourceId" gorm:"index:idx_assistant_js_source"` // 关联的JS模板ID
-	MaxTokens            int       `json:"maxTokens"`
-	Language             string    `json:"language" gorm:"column:language"`                                     // 语言设置
-	Speaker              string    `json:"speaker" gorm:"column:speaker"`                                       // 发音人ID
-	VoiceCloneID         *int      `json:"voiceCloneId" gorm:"column:voice_clone_id"`                           // 训练音色ID（可选）
-	KnowledgeBaseID      *string   `json:"knowledgeBaseId" gorm:"column:knowledge_base_id"`                     // 知识库ID（可选）
-	TtsProvider          string    `json:"ttsProvider" gorm:"column:tts_provider"`                              // TTS提供商
-	ApiKey               string    `json:"apiKey" gorm:"column:api_key"`                                        // API密钥
-	ApiSecret            string    `json:"apiSecret" gorm:"column:api_secret"`                                  // API密钥
-	LLMModel             string    `json:"llmModel" gorm:"column:llm_model"`                                    // LLM模型名称
-	EnableGraphMemory    bool      `json:"enableGraphMemory" gorm:"column:enable_graph_memory;default:false"`   // 是否启用基于图数据库的长期记忆
-	EnableVAD            bool      `json:"enableVAD" gorm:"column:enable_vad;default:true"`                     // 是否启用VAD（语音活动检测）用于打断TTS
-	VADThreshold         float64   `json:"vadThreshold" gorm:"column:vad_threshold;default:500"`                // VAD阈值（RMS值，范围0-32768，默认500）
-	VADConsecutiveFrames int       `json:"vadConsecutiveFrames" gorm:"column:vad_consecutive_frames;default:2"` // 需要连续超过阈值的帧数（默认2帧，约40ms）
-	CreatedAt            time.Time `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt            time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	ID                    int64             `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID                uint              `json:"userId" gorm:"index"`
+	GroupID               *uint             `json:"groupId,omitempty" gorm:"index"` // 组织ID，如果设置则表示这是组织共享的助手
+	Name                  string            `json:"name" gorm:"index"`
+	Description           string            `json:"description"`
+	Icon                  string            `json:"icon"`
+	SystemPrompt          string            `json:"systemPrompt"`
+	PersonaTag            string            `json:"personaTag"`
+	Temperature           float32           `json:"temperature"`
+	JsSourceID            string            `json:"jsSourceId" gorm:"index:idx_assistant_js_source"` // 关联的JS模板ID
+	MaxTokens             int               `json:"maxTokens"`
+	Language              string            `json:"language" gorm:"column:language"`                                       // 语言设置
+	Speaker               string            `json:"speaker" gorm:"column:speaker"`                                         // 发音人ID
+	VoiceCloneID          *int              `json:"voiceCloneId" gorm:"column:voice_clone_id"`                             // 训练音色ID（可选）
+	KnowledgeBaseID       *string           `json:"knowledgeBaseId" gorm:"column:knowledge_base_id"`                       // 知识库ID（可选）
+	TtsProvider           string            `json:"ttsProvider" gorm:"column:tts_provider"`                                // TTS提供商
+	ApiKey                string            `json:"apiKey" gorm:"column:api_key"`                                          // API密钥
+	ApiSecret             string            `json:"apiSecret" gorm:"column:api_secret"`                                    // API密钥
+	LLMModel              string            `json:"llmModel" gorm:"column:llm_model"`                                      // LLM模型名称
+	EnableGraphMemory     bool              `json:"enableGraphMemory" gorm:"column:enable_graph_memory;default:false"`     // 是否启用基于图数据库的长期记忆
+	EnableVAD             bool              `json:"enableVAD" gorm:"column:enable_vad;default:true"`                       // 是否启用VAD（语音活动检测）用于打断TTS
+	VADThreshold          float64           `json:"vadThreshold" gorm:"column:vad_threshold;default:500"`                  // VAD阈值（RMS值，范围0-32768，默认500）
+	VADConsecutiveFrames  int               `json:"vadConsecutiveFrames" gorm:"column:vad_consecutive_frames;default:2"`   // 需要连续超过阈值的帧数（默认2帧，约40ms）
+	TTSNormalization      *TTSNormalization `json:"ttsNormalization,omitempty" gorm:"column:tts_normalization;type:json"`  // TTS 文本规范化配置（数字、金额、日期、电话的读法）
+	MaxConcurrentSessions int               `json:"maxConcurrentSessions" gorm:"column:max_concurrent_sessions;default:0"` // 最大并发 AI 会话数，0 表示不限制
+	SessionQueueSize      int               `json:"sessionQueueSize" gorm:"column:session_queue_size;default:0"`           // 并发已满时的等待队列长度
+	SessionQueueTimeout   int               `json:"sessionQueueTimeout" gorm:"column:session_queue_timeout;default:0"`     // 排队最长等待时间（秒），0 使用默认值
+	CallbackOfferAfter    int               `json:"callbackOfferAfter" gorm:"column:callback_offer_after;default:0"`       // SIP 呼入排队超过该秒数时提供回拨，0 表示不提供
+	CallbackPromptFile    string            `json:"callbackPromptFile" gorm:"column:callback_prompt_file;size:256"`        // 回拨提示音 WAV（8kHz 16bit 单声道），未配置时不提供回拨
+	InterpreterMode       bool              `json:"interpreterMode" gorm:"column:interpreter_mode;default:false"`          // SIP 呼入时作为译员拨打被叫，双向翻译主叫和被叫的通话
+	InterpreterLanguage   string            `json:"interpreterLanguage" gorm:"column:interpreter_language;size:16"`        // 被叫的语言，主叫使用 Language
+	InterpreterTarget     string            `json:"interpreterTarget" gorm:"column:interpreter_target;size:256"`           // 传译模式拨打的被叫 SIP URI
+	InterpreterSpeaker    string            `json:"interpreterSpeaker" gorm:"column:interpreter_speaker;size:64"`          // 被叫语言的发音人，为空时使用凭证的默认发音人
+	ProviderGuard         *ProviderGuard    `json:"providerGuard,omitempty" gorm:"column:provider_guard;type:json"`        // LLM/TTS 超时保护、垫话和备用服务商
+	CreatedAt             time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt             time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	// 允许助手在对话和通话中控制所有者的设备（调节音量、重启等）
+	EnableDeviceControl bool `json:"enableDeviceControl" gorm:"column:enable_device_control;default:false"`
+
+	CustomFields map[string]string `json:"customFields,omitempty" gorm:"-"` // 组织自定义字段，列表接口填充
+}
+
+// TTSNormalization 助手的 TTS 文本规范化配置（用于 JSON 存储）
+type TTSNormalization textnorm.Options
+
+// Value 实现 driver.Valuer 接口
+func (n *TTSNormalization) Value() (driver.Value, error) {
+	if n == nil {
+		return nil, nil
+	}
+	return json.Marshal(n)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (n *TTSNormalization) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	}
+	if len(bytes) == 0 {
+		*n = TTSNormalization{}
+		return nil
+	}
+	return json.Unmarshal(bytes, n)
+}
+
+// TextNormalization 返回 TTS 文本规范化选项，未单独配置语言时使用助手语言
+func (a *Assistant) TextNormalization() textnorm.Options {
+	var opts textnorm.Options
+	if a.TTSNormalization != nil {
+		opts = textnorm.Options(*a.TTSNormalization)
+	}
+	if opts.Language == "" {
+		opts.Language = a.Language
+	}
+	return opts
+}
+
+// SessionLimit 返回助手的并发会话限制
+func (a *Assistant) SessionLimit() sessionlimit.Limit {
+	return sessionlimit.Limit{
+		MaxConcurrent: a.MaxConcurrentSessions,
+		QueueSize:     a.SessionQueueSize,
+		QueueTimeout:  time.Duration(a.SessionQueueTimeout) * time.Second,
+	}
+}
+
+// CallbackOffer 排队多久后提供回拨，未启用时返回 0
+func (a *Assistant) CallbackOffer() time.Duration {
+	if a.CallbackOfferAfter <= 0 || a.CallbackPromptFile == "" {
+		return 0
+	}
+	return time.Duration(a.CallbackOfferAfter) * time.Second
+}
+
+// AssistantSessionKey 助手在并发限制器中的键
+func AssistantSessionKey(assistantID int64) string {
+	return fmt.Sprintf("assistant:%d", assistantID)
 }
 
 // AssistantTool 表示助手自定义的Function Tool
@@ -79,9 +159,13 @@ type ChatSessionLog struct {
 
 // ToolCallInfo 工具调用信息
 type ToolCallInfo struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Arguments string `json:"arguments"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Arguments string    `json:"arguments"`
+	Output    string    `json:"output,omitempty"`    // 工具返回结果
+	Error     string    `json:"error,omitempty"`     // 工具执行错误
+	StartTime time.Time `json:"startTime,omitempty"` // 工具开始执行时间
+	Duration  int64     `json:"duration,omitempty"`  // 工具执行耗时（毫秒）
 }
 
 // LLMUsage 记录LLM调用的详细信息
@@ -0,0 +1,379 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 提示词发布的状态
+const (
+	PromptReleaseRunning      = "running"      // 金丝雀进行中，按比例分流
+	PromptReleasePromoted     = "promoted"     // 新提示词已全量
+	PromptReleaseRolledBack   = "rolled_back"  // 指标劣化或手动回滚，全部回到基线
+	PromptReleaseInconclusive = "inconclusive" // 窗口结束时样本不足，保持基线
+)
+
+// 金丝雀分流的两组
+const (
+	PromptArmBaseline  = "baseline"
+	PromptArmCandidate = "candidate"
+)
+
+// 金丝雀的默认参数
+const (
+	DefaultPromptCanaryPercent          = 10
+	DefaultPromptCanaryWindow           = 24 * time.Hour
+	DefaultPromptCanaryMinCalls         = 20
+	DefaultMaxInterruptionRateIncrease  = 0.10 // 打断率最多上升 10 个百分点
+	DefaultMaxHandleTimeIncrease        = 0.20 // 平均通话时长最多增加 20%
+	DefaultMaxSentimentDrop             = 0.15 // 平均情感分数（-1 到 1）最多下降 0.15
+	maxPromptCanaryWindow               = 14 * 24 * time.Hour
+	promptCanaryAnalysisRecordingsLimit = 10000
+)
+
+var (
+	ErrPromptReleaseRunning    = errors.New("assistant already has a running prompt release")
+	ErrPromptReleaseNotRunning = errors.New("prompt release is not running")
+)
+
+// AssistantPromptRelease 助手提示词的金丝雀发布：按比例把通话分给新提示词，
+// 在窗口内对比打断率、平均通话时长和情感分数，劣化超过阈值时自动回滚
+type AssistantPromptRelease struct {
+	ID                          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt                   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt                   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID                 int64      `json:"assistantId" gorm:"index;not null"`
+	UserID                      uint       `json:"userId" gorm:"index;not null"`
+	BaselinePrompt              string     `json:"baselinePrompt" gorm:"type:text"` // 发布开始时的提示词
+	CandidatePrompt             string     `json:"candidatePrompt" gorm:"type:text"`
+	Note                        string     `json:"note,omitempty" gorm:"size:255"`
+	TrafficPercent              int        `json:"trafficPercent"`              // 分给新提示词的通话比例 1-99
+	WindowSeconds               int64      `json:"windowSeconds"`               // 对比窗口
+	MinCalls                    int        `json:"minCalls"`                    // 每组至少需要的通话数
+	MaxInterruptionRateIncrease float64    `json:"maxInterruptionRateIncrease"` // 打断率（打断次数/用户轮次）允许上升的绝对值
+	MaxHandleTimeIncrease       float64    `json:"maxHandleTimeIncrease"`       // 平均通话时长允许增加的比例
+	MaxSentimentDrop            float64    `json:"maxSentimentDrop"`            // 平均情感分数允许下降的绝对值
+	Status                      string     `json:"status" gorm:"size:20;index"` // running / promoted / rolled_back / inconclusive
+	StartedAt                   time.Time  `json:"startedAt"`                   // 开始分流的时间
+	EndsAt                      time.Time  `json:"endsAt" gorm:"index"`         // 窗口结束时间
+	DecidedAt                   *time.Time `json:"decidedAt,omitempty"`         // 结束时间
+	DecisionReason              string     `json:"decisionReason,omitempty" gorm:"type:text"`
+	LastAnalysis                string     `json:"-" gorm:"type:text"` // 最近一次分析结果 JSON
+}
+
+// TableName 指定表名
+func (AssistantPromptRelease) TableName() string {
+	return "assistant_prompt_releases"
+}
+
+// ApplyDefaults 补全未设置的参数并校验范围
+func (r *AssistantPromptRelease) ApplyDefaults() error {
+	if strings.TrimSpace(r.CandidatePrompt) == "" {
+		return errors.New("candidate prompt is required")
+	}
+	if r.TrafficPercent == 0 {
+		r.TrafficPercent = DefaultPromptCanaryPercent
+	}
+	if r.TrafficPercent < 1 || r.TrafficPercent > 99 {
+		return errors.New("traffic percent must be between 1 and 99")
+	}
+	if r.WindowSeconds == 0 {
+		r.WindowSeconds = int64(DefaultPromptCanaryWindow / time.Second)
+	}
+	if r.WindowSeconds < 60 || r.WindowSeconds > int64(maxPromptCanaryWindow/time.Second) {
+		return errors.New("window must be between 1 minute and 14 days")
+	}
+	if r.MinCalls <= 0 {
+		r.MinCalls = DefaultPromptCanaryMinCalls
+	}
+	if r.MaxInterruptionRateIncrease <= 0 {
+		r.MaxInterruptionRateIncrease = DefaultMaxInterruptionRateIncrease
+	}
+	if r.MaxHandleTimeIncrease <= 0 {
+		r.MaxHandleTimeIncrease = DefaultMaxHandleTimeIncrease
+	}
+	if r.MaxSentimentDrop <= 0 {
+		r.MaxSentimentDrop = DefaultMaxSentimentDrop
+	}
+	return nil
+}
+
+// StartPromptRelease 开始金丝雀发布，同一助手同时只能有一个进行中的发布
+func StartPromptRelease(db *gorm.DB, assistant *Assistant, release *AssistantPromptRelease, now time.Time) error {
+	if err := release.ApplyDefaults(); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var running int64
+		if err := tx.Model(&AssistantPromptRelease{}).
+			Where("assistant_id = ? AND status = ?", assistant.ID, PromptReleaseRunning).
+			Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return ErrPromptReleaseRunning
+		}
+		release.AssistantID = assistant.ID
+		release.UserID = assistant.UserID
+		release.BaselinePrompt = assistant.SystemPrompt
+		release.Status = PromptReleaseRunning
+		release.StartedAt = now
+		release.EndsAt = now.Add(time.Duration(release.WindowSeconds) * time.Second)
+		return tx.Create(release).Error
+	})
+}
+
+// GetRunningPromptRelease 获取助手进行中的发布，没有时返回 nil
+func GetRunningPromptRelease(db *gorm.DB, assistantID int64) (*AssistantPromptRelease, error) {
+	var release AssistantPromptRelease
+	err := db.Where("assistant_id = ? AND status = ?", assistantID, PromptReleaseRunning).First(&release).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// Arm 按调用方（设备 MAC 等）分组，同一调用方在发布期间始终落在同一组
+func (r *AssistantPromptRelease) Arm(callerKey string) string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%s", r.ID, callerKey)
+	if int(h.Sum32()%100) < r.TrafficPercent {
+		return PromptArmCandidate
+	}
+	return PromptArmBaseline
+}
+
+// PromptAssignment 一次通话使用的提示词及其所属的发布分组
+type PromptAssignment struct {
+	SystemPrompt string
+	ReleaseID    *uint
+	Arm          string
+}
+
+// AssignAssistantPrompt 为一次通话选择提示词：有进行中的发布时按比例分流，否则使用助手当前提示词
+func AssignAssistantPrompt(db *gorm.DB, assistant *Assistant, callerKey string) PromptAssignment {
+	assignment := PromptAssignment{SystemPrompt: assistant.SystemPrompt}
+	release, err := GetRunningPromptRelease(db, assistant.ID)
+	if err != nil || release == nil {
+		return assignment
+	}
+	assignment.ReleaseID = &release.ID
+	assignment.Arm = release.Arm(callerKey)
+	if assignment.Arm == PromptArmCandidate {
+		assignment.SystemPrompt = release.CandidatePrompt
+	}
+	return assignment
+}
+
+// PromptArmMetrics 一组通话的指标
+type PromptArmMetrics struct {
+	Calls            int     `json:"calls"`
+	UserTurns        int     `json:"userTurns"`
+	Interruptions    int     `json:"interruptions"`
+	InterruptionRate float64 `json:"interruptionRate"` // 打断次数 / 用户轮次
+	AvgHandleSeconds float64 `json:"avgHandleSeconds"`
+	SentimentCalls   int     `json:"sentimentCalls"` // 已完成 AI 分析、带情感分数的通话数
+	AvgSentiment     float64 `json:"avgSentiment"`
+}
+
+// PromptCanaryAnalysis 基线与新提示词的对比结果
+type PromptCanaryAnalysis struct {
+	AnalyzedAt  time.Time        `json:"analyzedAt"`
+	Baseline    PromptArmMetrics `json:"baseline"`
+	Candidate   PromptArmMetrics `json:"candidate"`
+	Regressions []string         `json:"regressions"`
+	Sufficient  bool             `json:"sufficient"` // 两组通话数都达到 MinCalls
+	WindowEnded bool             `json:"windowEnded"`
+}
+
+// AnalyzePromptRelease 汇总发布期间已结束的通话，对比两组指标
+func AnalyzePromptRelease(db *gorm.DB, release *AssistantPromptRelease, now time.Time) (*PromptCanaryAnalysis, error) {
+	var recordings []CallRecording
+	err := db.Select("id", "prompt_arm", "duration", "conversation_details", "ai_analysis", "analysis_status").
+		Where("prompt_release_id = ? AND call_status <> ?", release.ID, "ongoing").
+		Order("id").
+		Limit(promptCanaryAnalysisRecordingsLimit).
+		Find(&recordings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type totals struct {
+		metrics      PromptArmMetrics
+		handle       int
+		sentimentSum float64
+	}
+	arms := map[string]*totals{PromptArmBaseline: {}, PromptArmCandidate: {}}
+	for i := range recordings {
+		rec := &recordings[i]
+		t, ok := arms[rec.PromptArm]
+		if !ok {
+			continue
+		}
+		t.metrics.Calls++
+		t.handle += rec.Duration
+		if details, err := rec.GetConversationDetails(); err == nil && details != nil {
+			t.metrics.UserTurns += details.UserTurns
+			t.metrics.Interruptions += details.Interruptions
+		}
+		if sentiment, ok := recordingSentiment(rec); ok {
+			t.metrics.SentimentCalls++
+			t.sentimentSum += sentiment
+		}
+	}
+	for _, t := range arms {
+		if t.metrics.Calls > 0 {
+			t.metrics.AvgHandleSeconds = float64(t.handle) / float64(t.metrics.Calls)
+		}
+		if t.metrics.UserTurns > 0 {
+			t.metrics.InterruptionRate = float64(t.metrics.Interruptions) / float64(t.metrics.UserTurns)
+		}
+		if t.metrics.SentimentCalls > 0 {
+			t.metrics.AvgSentiment = t.sentimentSum / float64(t.metrics.SentimentCalls)
+		}
+	}
+
+	analysis := &PromptCanaryAnalysis{
+		AnalyzedAt:  now,
+		Baseline:    arms[PromptArmBaseline].metrics,
+		Candidate:   arms[PromptArmCandidate].metrics,
+		Regressions: []string{},
+		WindowEnded: !now.Before(release.EndsAt),
+	}
+	analysis.Sufficient = analysis.Baseline.Calls >= release.MinCalls && analysis.Candidate.Calls >= release.MinCalls
+	if analysis.Sufficient {
+		analysis.Regressions = release.regressions(&analysis.Baseline, &analysis.Candidate)
+	}
+	return analysis, nil
+}
+
+// regressions 列出超过阈值的劣化
+func (r *AssistantPromptRelease) regressions(baseline, candidate *PromptArmMetrics) []string {
+	regressions := []string{}
+	if candidate.InterruptionRate-baseline.InterruptionRate > r.MaxInterruptionRateIncrease {
+		regressions = append(regressions, fmt.Sprintf("interruption rate %.1f%% vs baseline %.1f%%",
+			candidate.InterruptionRate*100, baseline.InterruptionRate*100))
+	}
+	if baseline.AvgHandleSeconds > 0 && candidate.AvgHandleSeconds > baseline.AvgHandleSeconds*(1+r.MaxHandleTimeIncrease) {
+		regressions = append(regressions, fmt.Sprintf("average handle time %.0fs vs baseline %.0fs",
+			candidate.AvgHandleSeconds, baseline.AvgHandleSeconds))
+	}
+	// 情感分数依赖通话分析，两组都有足够的已分析通话时才比较
+	if baseline.SentimentCalls >= r.MinCalls && candidate.SentimentCalls >= r.MinCalls &&
+		baseline.AvgSentiment-candidate.AvgSentiment > r.MaxSentimentDrop {
+		regressions = append(regressions, fmt.Sprintf("average sentiment %.2f vs baseline %.2f",
+			candidate.AvgSentiment, baseline.AvgSentiment))
+	}
+	return regressions
+}
+
+// recordingSentiment 从 AI 分析结果中读取情感分数
+func recordingSentiment(rec *CallRecording) (float64, bool) {
+	if rec.AnalysisStatus != "completed" || rec.AIAnalysis == "" {
+		return 0, false
+	}
+	var analysis struct {
+		Sentiment *float64 `json:"sentiment"`
+	}
+	if err := json.Unmarshal([]byte(rec.AIAnalysis), &analysis); err != nil || analysis.Sentiment == nil {
+		return 0, false
+	}
+	return *analysis.Sentiment, true
+}
+
+// PromptReleaseDecision 评估后对发布的处理
+type PromptReleaseDecision string
+
+const (
+	PromptReleaseContinue PromptReleaseDecision = "continue"
+	PromptReleasePromote  PromptReleaseDecision = "promote"
+	PromptReleaseRollback PromptReleaseDecision = "rollback"
+	PromptReleaseExpire   PromptReleaseDecision = "inconclusive"
+)
+
+// Decide 出现劣化立即回滚；窗口结束且无劣化时全量；窗口结束但样本不足时保持基线
+func (a *PromptCanaryAnalysis) Decide() PromptReleaseDecision {
+	switch {
+	case len(a.Regressions) > 0:
+		return PromptReleaseRollback
+	case !a.WindowEnded:
+		return PromptReleaseContinue
+	case a.Sufficient:
+		return PromptReleasePromote
+	default:
+		return PromptReleaseExpire
+	}
+}
+
+// SavePromptAnalysis 保存最近一次分析结果
+func SavePromptAnalysis(db *gorm.DB, release *AssistantPromptRelease, analysis *PromptCanaryAnalysis) error {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return err
+	}
+	release.LastAnalysis = string(data)
+	return db.Model(release).Update("last_analysis", release.LastAnalysis).Error
+}
+
+// Analysis 读取最近一次保存的分析结果
+func (r *AssistantPromptRelease) Analysis() *PromptCanaryAnalysis {
+	if r.LastAnalysis == "" {
+		return nil
+	}
+	var analysis PromptCanaryAnalysis
+	if err := json.Unmarshal([]byte(r.LastAnalysis), &analysis); err != nil {
+		return nil
+	}
+	return &analysis
+}
+
+// FinishPromptRelease 结束发布：promoted 时把新提示词写回助手，其余状态保持基线。
+// 只有进行中的发布可以结束，并发评估时只有一次生效
+func FinishPromptRelease(db *gorm.DB, release *AssistantPromptRelease, status, reason string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AssistantPromptRelease{}).
+			Where("id = ? AND status = ?", release.ID, PromptReleaseRunning).
+			Updates(map[string]interface{}{
+				"status":          status,
+				"decision_reason": reason,
+				"decided_at":      now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPromptReleaseNotRunning
+		}
+		release.Status = status
+		release.DecisionReason = reason
+		release.DecidedAt = &now
+		if status != PromptReleasePromoted {
+			return nil
+		}
+		return tx.Model(&Assistant{}).Where("id = ?", release.AssistantID).
+			Update("system_prompt", release.CandidatePrompt).Error
+	})
+}
+
+// ListPromptReleases 获取助手的发布记录，最新的在前
+func ListPromptReleases(db *gorm.DB, assistantID int64, limit int) ([]AssistantPromptRelease, error) {
+	releases := []AssistantPromptRelease{}
+	err := db.Where("assistant_id = ?", assistantID).Order("id DESC").Limit(limit).Find(&releases).Error
+	return releases, err
+}
+
+// ListRunningPromptReleases 获取所有进行中的发布，供定时评估
+func ListRunningPromptReleases(db *gorm.DB) ([]AssistantPromptRelease, error) {
+	var releases []AssistantPromptRelease
+	err := db.Where("status = ?", PromptReleaseRunning).Find(&releases).Error
+	return releases, err
+}
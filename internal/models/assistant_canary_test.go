@@ -0,0 +1,153 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartPromptRelease(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Assistant{}, &AssistantPromptRelease{})
+	assistant := &Assistant{UserID: 1, SystemPrompt: "old prompt"}
+	require.NoError(t, db.Create(assistant).Error)
+
+	now := time.Now()
+	release := &AssistantPromptRelease{CandidatePrompt: "new prompt"}
+	require.NoError(t, StartPromptRelease(db, assistant, release, now))
+	assert.Equal(t, PromptReleaseRunning, release.Status)
+	assert.Equal(t, "old prompt", release.BaselinePrompt)
+	assert.Equal(t, DefaultPromptCanaryPercent, release.TrafficPercent)
+	assert.Equal(t, DefaultPromptCanaryMinCalls, release.MinCalls)
+	assert.WithinDuration(t, now.Add(DefaultPromptCanaryWindow), release.EndsAt, time.Second)
+
+	err := StartPromptRelease(db, assistant, &AssistantPromptRelease{CandidatePrompt: "other"}, now)
+	assert.ErrorIs(t, err, ErrPromptReleaseRunning)
+	assert.Error(t, StartPromptRelease(db, &Assistant{ID: 99}, &AssistantPromptRelease{CandidatePrompt: "x", TrafficPercent: 100}, now))
+	assert.Error(t, StartPromptRelease(db, &Assistant{ID: 99}, &AssistantPromptRelease{CandidatePrompt: " "}, now))
+}
+
+func TestAssignAssistantPrompt(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Assistant{}, &AssistantPromptRelease{})
+	assistant := &Assistant{UserID: 1, SystemPrompt: "old prompt"}
+	require.NoError(t, db.Create(assistant).Error)
+
+	assignment := AssignAssistantPrompt(db, assistant, "aa:bb")
+	assert.Equal(t, "old prompt", assignment.SystemPrompt)
+	assert.Nil(t, assignment.ReleaseID)
+
+	release := &AssistantPromptRelease{CandidatePrompt: "new prompt", TrafficPercent: 30}
+	require.NoError(t, StartPromptRelease(db, assistant, release, time.Now()))
+
+	candidates := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("device-%d", i)
+		a := AssignAssistantPrompt(db, assistant, key)
+		require.NotNil(t, a.ReleaseID)
+		assert.Equal(t, a.Arm, AssignAssistantPrompt(db, assistant, key).Arm, "a device keeps its arm")
+		if a.Arm == PromptArmCandidate {
+			assert.Equal(t, "new prompt", a.SystemPrompt)
+			candidates++
+		} else {
+			assert.Equal(t, "old prompt", a.SystemPrompt)
+		}
+	}
+	assert.InDelta(t, 300, candidates, 60)
+}
+
+func TestAnalyzePromptRelease(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Assistant{}, &AssistantPromptRelease{}, &CallRecording{})
+	assistant := &Assistant{UserID: 1, Name: "support", SystemPrompt: "old prompt"}
+	require.NoError(t, db.Create(assistant).Error)
+	start := time.Now()
+	release := &AssistantPromptRelease{CandidatePrompt: "new prompt", MinCalls: 2, WindowSeconds: 3600}
+	require.NoError(t, StartPromptRelease(db, assistant, release, start))
+
+	addCall := func(arm string, duration, userTurns, interruptions int, sentiment float64) {
+		details, _ := json.Marshal(ConversationDetails{UserTurns: userTurns, Interruptions: interruptions})
+		require.NoError(t, db.Create(&CallRecording{
+			UserID:                  1,
+			AssistantID:             uint(assistant.ID),
+			CallStatus:              "completed",
+			Duration:                duration,
+			ConversationDetailsJSON: string(details),
+			AnalysisStatus:          "completed",
+			AIAnalysis:              fmt.Sprintf(`{"sentiment": %v}`, sentiment),
+			PromptReleaseID:         &release.ID,
+			PromptArm:               arm,
+		}).Error)
+	}
+
+	addCall(PromptArmBaseline, 100, 10, 1, 0.5)
+	addCall(PromptArmBaseline, 120, 10, 1, 0.5)
+	addCall(PromptArmCandidate, 110, 10, 1, 0.5)
+
+	analysis, err := AnalyzePromptRelease(db, release, start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, analysis.Sufficient)
+	assert.Equal(t, PromptReleaseContinue, analysis.Decide())
+	assert.Equal(t, 2, analysis.Baseline.Calls)
+	assert.InDelta(t, 110, analysis.Baseline.AvgHandleSeconds, 1e-9)
+	assert.InDelta(t, 0.1, analysis.Baseline.InterruptionRate, 1e-9)
+
+	// The window ends without enough candidate calls
+	analysis, err = AnalyzePromptRelease(db, release, release.EndsAt)
+	require.NoError(t, err)
+	assert.Equal(t, PromptReleaseExpire, analysis.Decide())
+
+	// A candidate call with many interruptions and a long duration regresses
+	addCall(PromptArmCandidate, 300, 10, 6, -0.5)
+	analysis, err = AnalyzePromptRelease(db, release, start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, analysis.Sufficient)
+	assert.Len(t, analysis.Regressions, 3)
+	assert.Equal(t, PromptReleaseRollback, analysis.Decide())
+
+	require.NoError(t, SavePromptAnalysis(db, release, analysis))
+	var stored AssistantPromptRelease
+	require.NoError(t, db.First(&stored, release.ID).Error)
+	require.NotNil(t, stored.Analysis())
+	assert.Len(t, stored.Analysis().Regressions, 3)
+}
+
+func TestPromptCanaryAnalysis_PromoteAfterWindow(t *testing.T) {
+	release := &AssistantPromptRelease{MinCalls: 1, MaxInterruptionRateIncrease: 0.1, MaxHandleTimeIncrease: 0.2, MaxSentimentDrop: 0.15}
+	baseline := &PromptArmMetrics{Calls: 5, InterruptionRate: 0.2, AvgHandleSeconds: 100, SentimentCalls: 5, AvgSentiment: 0.3}
+	candidate := &PromptArmMetrics{Calls: 5, InterruptionRate: 0.25, AvgHandleSeconds: 110, SentimentCalls: 5, AvgSentiment: 0.2}
+	assert.Empty(t, release.regressions(baseline, candidate))
+
+	analysis := &PromptCanaryAnalysis{Sufficient: true, WindowEnded: true, Regressions: release.regressions(baseline, candidate)}
+	assert.Equal(t, PromptReleasePromote, analysis.Decide())
+}
+
+func TestFinishPromptRelease(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Assistant{}, &AssistantPromptRelease{})
+	assistant := &Assistant{UserID: 1, SystemPrompt: "old prompt"}
+	require.NoError(t, db.Create(assistant).Error)
+	now := time.Now()
+
+	release := &AssistantPromptRelease{CandidatePrompt: "new prompt"}
+	require.NoError(t, StartPromptRelease(db, assistant, release, now))
+	require.NoError(t, FinishPromptRelease(db, release, PromptReleaseRolledBack, "regression", now))
+	assert.ErrorIs(t, FinishPromptRelease(db, release, PromptReleasePromoted, "late", now), ErrPromptReleaseNotRunning)
+	var stored Assistant
+	require.NoError(t, db.First(&stored, assistant.ID).Error)
+	assert.Equal(t, "old prompt", stored.SystemPrompt)
+
+	release = &AssistantPromptRelease{CandidatePrompt: "new prompt"}
+	require.NoError(t, StartPromptRelease(db, assistant, release, now))
+	require.NoError(t, FinishPromptRelease(db, release, PromptReleasePromoted, "healthy", now))
+	require.NoError(t, db.First(&stored, assistant.ID).Error)
+	assert.Equal(t, "new prompt", stored.SystemPrompt)
+
+	releases, err := ListPromptReleases(db, assistant.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, releases, 2)
+	assert.Equal(t, PromptReleasePromoted, releases[0].Status)
+	running, err := ListRunningPromptReleases(db)
+	require.NoError(t, err)
+	assert.Empty(t, running)
+}
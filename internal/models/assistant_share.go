@@ -0,0 +1,210 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 助手共享的权限级别
+const (
+	AssistantSharePermissionUse  = "use"  // 组织成员可查看并调用助手
+	AssistantSharePermissionEdit = "edit" // 另外可修改助手配置
+)
+
+// 用户对助手的访问级别，由 AssistantAccess 返回
+const (
+	AssistantAccessNone  = ""
+	AssistantAccessUse   = AssistantSharePermissionUse
+	AssistantAccessEdit  = AssistantSharePermissionEdit
+	AssistantAccessOwner = "owner"
+)
+
+var (
+	ErrInvalidSharePermission = errors.New("permission must be use or edit")
+	ErrShareOrgAssistant      = errors.New("organization assistants are already shared with their organization")
+)
+
+// AssistantShare 个人助手共享给组织的记录，组织成员按权限级别使用助手，
+// 使用量记在调用的成员名下并归属到该组织
+type AssistantShare struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID int64     `json:"assistantId" gorm:"uniqueIndex:idx_assistant_share"`
+	GroupID     uint      `json:"groupId" gorm:"uniqueIndex:idx_assistant_share;index"`
+	Permission  string    `json:"permission" gorm:"size:16"`
+	SharedBy    uint      `json:"sharedBy"`
+	Group       *Group    `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+}
+
+// TableName 指定表名
+func (AssistantShare) TableName() string {
+	return "assistant_shares"
+}
+
+// ShareAssistant 将个人助手共享给组织，已共享时更新权限级别
+func ShareAssistant(db *gorm.DB, assistant *Assistant, groupID uint, permission string, sharedBy uint) (*AssistantShare, error) {
+	if permission != AssistantSharePermissionUse && permission != AssistantSharePermissionEdit {
+		return nil, ErrInvalidSharePermission
+	}
+	if assistant.GroupID != nil {
+		return nil, ErrShareOrgAssistant
+	}
+	share := &AssistantShare{AssistantID: assistant.ID, GroupID: groupID, Permission: permission, SharedBy: sharedBy}
+	if err := Upsert(db, share, []string{"AssistantID", "GroupID"}, "Permission", "SharedBy", "UpdatedAt"); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// UnshareAssistant 取消助手对组织的共享
+func UnshareAssistant(db *gorm.DB, assistantID int64, groupID uint) error {
+	result := db.Where("assistant_id = ? AND group_id = ?", assistantID, groupID).Delete(&AssistantShare{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListAssistantShares 获取助手的共享记录
+func ListAssistantShares(db *gorm.DB, assistantID int64) ([]AssistantShare, error) {
+	var shares []AssistantShare
+	err := db.Preload("Group").Where("assistant_id = ?", assistantID).Order("id").Find(&shares).Error
+	return shares, err
+}
+
+// DeleteAssistantShares 删除助手时清理其共享记录
+func DeleteAssistantShares(db *gorm.DB, assistantID int64) error {
+	return db.Where("assistant_id = ?", assistantID).Delete(&AssistantShare{}).Error
+}
+
+// SharedAssistantIDs 获取共享给用户所在组织的助手ID
+func SharedAssistantIDs(db *gorm.DB, userID uint) []int64 {
+	groupIDs := userGroupIDs(db, userID)
+	if len(groupIDs) == 0 {
+		return nil
+	}
+	var ids []int64
+	db.Model(&AssistantShare{}).Where("group_id IN ?", groupIDs).Distinct().Pluck("assistant_id", &ids)
+	return ids
+}
+
+// memberShares 获取用户通过所在组织获得的助手共享
+func memberShares(db *gorm.DB, assistantID int64, userID uint) []AssistantShare {
+	groupIDs := userGroupIDs(db, userID)
+	if len(groupIDs) == 0 {
+		return nil
+	}
+	var shares []AssistantShare
+	db.Where("assistant_id = ? AND group_id IN ?", assistantID, groupIDs).Order("group_id").Find(&shares)
+	return shares
+}
+
+// AssistantAccess 返回用户对助手的访问级别：所有者、组织助手的成员（use），
+// 或者通过共享获得的最高权限
+func AssistantAccess(db *gorm.DB, assistant *Assistant, userID uint) string {
+	if assistant.UserID == userID {
+		return AssistantAccessOwner
+	}
+	if assistant.GroupID != nil {
+		for _, id := range userGroupIDs(db, userID) {
+			if id == *assistant.GroupID {
+				return AssistantAccessUse
+			}
+		}
+		return AssistantAccessNone
+	}
+	access := AssistantAccessNone
+	for _, share := range memberShares(db, assistant.ID, userID) {
+		if share.Permission == AssistantSharePermissionEdit {
+			return AssistantAccessEdit
+		}
+		access = AssistantAccessUse
+	}
+	return access
+}
+
+// CanUseAssistant 判断用户能否查看并调用助手
+func CanUseAssistant(db *gorm.DB, assistant *Assistant, userID uint) bool {
+	return AssistantAccess(db, assistant, userID) != AssistantAccessNone
+}
+
+// CanEditAssistant 判断用户能否修改助手配置
+func CanEditAssistant(db *gorm.DB, assistant *Assistant, userID uint) bool {
+	access := AssistantAccess(db, assistant, userID)
+	return access == AssistantAccessOwner || access == AssistantAccessEdit
+}
+
+// AssistantUsageGroupID 返回使用量记录归属的组织：组织助手归属其组织；
+// 共享助手被组织成员调用时归属共享给该成员的组织（多个时取ID最小的）；
+// 所有者自己调用个人助手时不归属组织
+func AssistantUsageGroupID(db *gorm.DB, assistant *Assistant, userID uint) *uint {
+	if assistant.GroupID != nil {
+		return assistant.GroupID
+	}
+	if assistant.UserID == userID {
+		return nil
+	}
+	if shares := memberShares(db, assistant.ID, userID); len(shares) > 0 {
+		groupID := shares[0].GroupID
+		return &groupID
+	}
+	return nil
+}
+
+// AssistantUsageGroupIDByID 按助手ID查询使用量归属的组织（助手属于组织，或通过组织共享给调用的成员），
+// 助手ID为空或助手不存在时返回 nil
+func AssistantUsageGroupIDByID(db *gorm.DB, assistantID *uint, userID uint) (*uint, error) {
+	if assistantID == nil || db == nil {
+		return nil, nil
+	}
+	var assistant Assistant
+	if err := db.Where("id = ?", *assistantID).First(&assistant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return AssistantUsageGroupID(db, &assistant, userID), nil
+}
+
+// AssistantMemberUsage 共享助手按成员和类型汇总的使用量
+type AssistantMemberUsage struct {
+	UserID           uint      `json:"userId"`
+	UsageType        UsageType `json:"usageType"`
+	Records          int64     `json:"records"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	TotalTokens      int64     `json:"totalTokens"`
+	CallDuration     int64     `json:"callDuration"`
+	CallCount        int64     `json:"callCount"`
+	AudioDuration    int64     `json:"audioDuration"`
+	APICallCount     int64     `json:"apiCallCount"`
+}
+
+// GetAssistantMemberUsage 汇总助手在组织内各成员的使用量，groupID 为空时汇总所有组织
+func GetAssistantMemberUsage(db *gorm.DB, assistantID int64, groupID *uint, since time.Time) ([]AssistantMemberUsage, error) {
+	query := db.Model(&UsageRecord{}).
+		Select("user_id, usage_type, COUNT(*) AS records, "+
+			"COALESCE(SUM(total_tokens), 0) AS total_tokens, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, "+
+			"COALESCE(SUM(call_duration), 0) AS call_duration, "+
+			"COALESCE(SUM(call_count), 0) AS call_count, "+
+			"COALESCE(SUM(audio_duration), 0) AS audio_duration, "+
+			"COALESCE(SUM(api_call_count), 0) AS api_call_count").
+		Where("assistant_id = ? AND usage_time >= ?", assistantID, since)
+	if groupID != nil {
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("group_id IS NOT NULL")
+	}
+	var rows []AssistantMemberUsage
+	err := query.Group("user_id, usage_type").Order("user_id, usage_type").Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssistantShares(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Assistant{}, &AssistantShare{}, &Group{}, &GroupMember{}, &UsageRecord{})
+
+	owner, member, outsider := uint(1), uint(2), uint(3)
+	require.NoError(t, db.Create(&Group{ID: 10, Name: "support", CreatorID: owner}).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: member, GroupID: 10, Role: GroupRoleMember}).Error)
+	assistant := &Assistant{UserID: owner, Name: "receptionist"}
+	require.NoError(t, db.Create(assistant).Error)
+
+	assert.Equal(t, AssistantAccessOwner, AssistantAccess(db, assistant, owner))
+	assert.False(t, CanUseAssistant(db, assistant, member))
+	assert.Nil(t, AssistantUsageGroupID(db, assistant, owner))
+
+	_, err := ShareAssistant(db, assistant, 10, "admin", owner)
+	assert.ErrorIs(t, err, ErrInvalidSharePermission)
+
+	share, err := ShareAssistant(db, assistant, 10, AssistantSharePermissionUse, owner)
+	require.NoError(t, err)
+	assert.NotZero(t, share.ID)
+	assert.Equal(t, []int64{assistant.ID}, SharedAssistantIDs(db, member))
+	assert.Empty(t, SharedAssistantIDs(db, outsider))
+	assert.True(t, CanUseAssistant(db, assistant, member))
+	assert.False(t, CanEditAssistant(db, assistant, member))
+	assert.False(t, CanUseAssistant(db, assistant, outsider))
+
+	// Sharing again updates the permission instead of adding a record
+	again, err := ShareAssistant(db, assistant, 10, AssistantSharePermissionEdit, owner)
+	require.NoError(t, err)
+	assert.Equal(t, share.ID, again.ID)
+	assert.True(t, CanEditAssistant(db, assistant, member))
+	shares, err := ListAssistantShares(db, assistant.ID)
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, "support", shares[0].Group.Name)
+
+	// Usage of the member is attributed to the organization, the owner's own use is not
+	groupID := AssistantUsageGroupID(db, assistant, member)
+	require.NotNil(t, groupID)
+	assert.Equal(t, uint(10), *groupID)
+	aid := uint(assistant.ID)
+	require.NoError(t, RecordLLMUsage(db, member, 0, &aid, groupID, "s1", "gpt", 10, 20, 30))
+	require.NoError(t, RecordLLMUsage(db, member, 0, &aid, groupID, "s2", "gpt", 1, 2, 3))
+	require.NoError(t, RecordLLMUsage(db, owner, 0, &aid, AssistantUsageGroupID(db, assistant, owner), "s3", "gpt", 5, 5, 10))
+	usage, err := GetAssistantMemberUsage(db, assistant.ID, groupID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, member, usage[0].UserID)
+	assert.Equal(t, int64(2), usage[0].Records)
+	assert.Equal(t, int64(33), usage[0].TotalTokens)
+
+	require.NoError(t, UnshareAssistant(db, assistant.ID, 10))
+	assert.False(t, CanUseAssistant(db, assistant, member))
+	assert.Error(t, UnshareAssistant(db, assistant.ID, 10))
+
+	// Organization assistants are visible to their members and cannot be shared further
+	orgAssistant := &Assistant{UserID: owner, GroupID: groupID, Name: "org"}
+	require.NoError(t, db.Create(orgAssistant).Error)
+	assert.Equal(t, AssistantAccessUse, AssistantAccess(db, orgAssistant, member))
+	_, err = ShareAssistant(db, orgAssistant, 10, AssistantSharePermissionUse, owner)
+	assert.ErrorIs(t, err, ErrShareOrgAssistant)
+}
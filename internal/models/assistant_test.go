@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -726,3 +727,38 @@ func TestGetJSTemplatesCount_WithType(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int64(1), count)
 }
+
+func TestAssistantTextNormalization(t *testing.T) {
+	db := setupAssistantTestDB(t)
+
+	plain := Assistant{Name: "plain", Language: "zh-CN"}
+	require.NoError(t, db.Create(&plain).Error)
+	tuned := Assistant{Name: "tuned", Language: "zh-CN", TTSNormalization: &TTSNormalization{
+		Language:     "en",
+		SkipRules:    []string{"phone"},
+		Replacements: map[string]string{"VIP": "V I P"},
+	}}
+	require.NoError(t, db.Create(&tuned).Error)
+
+	var loaded Assistant
+	require.NoError(t, db.First(&loaded, plain.ID).Error)
+	assert.Nil(t, loaded.TTSNormalization)
+	assert.Equal(t, "zh-CN", loaded.TextNormalization().Language, "defaults to the assistant language")
+
+	var loadedTuned Assistant
+	require.NoError(t, db.First(&loadedTuned, tuned.ID).Error)
+	opts := loadedTuned.TextNormalization()
+	assert.Equal(t, "en", opts.Language)
+	assert.Equal(t, []string{"phone"}, opts.SkipRules)
+	assert.Equal(t, "V I P", opts.Replacements["VIP"])
+}
+
+func TestAssistantSessionLimit(t *testing.T) {
+	a := Assistant{ID: 42, MaxConcurrentSessions: 3, SessionQueueSize: 2, SessionQueueTimeout: 15}
+	limit := a.SessionLimit()
+	assert.Equal(t, 3, limit.MaxConcurrent)
+	assert.Equal(t, 2, limit.QueueSize)
+	assert.Equal(t, 15*time.Second, limit.QueueTimeout)
+	assert.True(t, (&Assistant{}).SessionLimit().Unlimited())
+	assert.Equal(t, "assistant:42", AssistantSessionKey(a.ID))
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// revokeAllRetention 撤销用户全部令牌的记录保留时长，需覆盖最长的访问令牌有效期
+	revokeAllRetention = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token already used, the login has been revoked")
+	ErrTokenRevoked        = errors.New("token revoked")
+)
+
+// AccessClaims 访问令牌的签发信息，旧格式令牌没有 JTI 和 IssuedAt
+type AccessClaims struct {
+	JTI       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// RefreshToken 服务端保存的刷新令牌，只保存哈希。每次刷新都换发新令牌，
+// 同一次登录换发出的令牌属于同一个家族，旧令牌被再次使用时整个家族作废
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UserID    uint       `json:"userId" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	FamilyID  string     `json:"familyId" gorm:"size:32;index;not null"`
+	AccessJTI string     `json:"-" gorm:"size:32;index"` // 与该刷新令牌一同签发的访问令牌
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"index"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"` // 已换发新令牌
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	IP        string     `json:"ip" gorm:"size:64"`
+	UserAgent string     `json:"userAgent" gorm:"size:256"`
+}
+
+// TableName 指定表名
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RevokedToken 访问令牌撤销列表：JTI 不为空时作废单个令牌，
+// 为空时作废该用户在 RevokedAt 之前签发的全部令牌
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	JTI       string    `json:"jti" gorm:"size:32;index"`
+	UserID    uint      `json:"userId" gorm:"index;not null"`
+	Reason    string    `json:"reason" gorm:"size:64"`
+	RevokedAt time.Time `json:"revokedAt"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"index"` // 令牌过期后记录可以清理
+}
+
+// TableName 指定表名
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// TokenPair 登录或刷新时签发的访问令牌和刷新令牌
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"` // 访问令牌过期时间
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokenPair 在指定家族中签发一对新令牌
+func issueTokenPair(db *gorm.DB, user *User, familyID string, accessTTL, refreshTTL time.Duration, ip, userAgent string, now time.Time) (*TokenPair, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+	claims := AccessClaims{JTI: newTokenID(), IssuedAt: now, ExpiresAt: now.Add(accessTTL)}
+	if len(userAgent) > 256 {
+		userAgent = userAgent[:256]
+	}
+	rt := &RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashRefreshToken(raw),
+		FamilyID:  familyID,
+		AccessJTI: claims.JTI,
+		ExpiresAt: now.Add(refreshTTL),
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+	if err := db.Create(rt).Error; err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  EncodeAccessToken(user, claims, false),
+		RefreshToken: raw,
+		ExpiresAt:    claims.ExpiresAt,
+	}, nil
+}
+
+// IssueTokenPair 登录成功后签发短期访问令牌和刷新令牌，开启新的令牌家族
+func IssueTokenPair(db *gorm.DB, user *User, accessTTL, refreshTTL time.Duration, ip, userAgent string, now time.Time) (*TokenPair, error) {
+	return issueTokenPair(db, user, newTokenID(), accessTTL, refreshTTL, ip, userAgent, now)
+}
+
+// RotateRefreshToken 用刷新令牌换发新的一对令牌，旧刷新令牌随即失效。
+// 已换发或已撤销的刷新令牌再次出现说明令牌泄露，整个家族及其访问令牌都会作废
+func RotateRefreshToken(db *gorm.DB, raw string, accessTTL, refreshTTL time.Duration, ip, userAgent string, now time.Time) (*TokenPair, *User, error) {
+	var rt RefreshToken
+	if err := db.Where("token_hash = ?", hashRefreshToken(raw)).First(&rt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	if rt.RotatedAt != nil || rt.RevokedAt != nil {
+		if err := RevokeTokenFamily(db, rt.FamilyID, "refresh_reuse", now); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if now.After(rt.ExpiresAt) {
+		return nil, nil, ErrRefreshTokenExpired
+	}
+	user, err := GetUserByUID(db, rt.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var pair *TokenPair
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发刷新时只有一个请求能换发
+		res := tx.Model(&RefreshToken{}).Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", rt.ID).Update("rotated_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		var err error
+		pair, err = issueTokenPair(tx, user, rt.FamilyID, accessTTL, refreshTTL, ip, userAgent, now)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, user, nil
+}
+
+// RevokeTokenFamily 作废一次登录换发出的全部刷新令牌和最近签发的访问令牌
+func RevokeTokenFamily(db *gorm.DB, familyID, reason string, now time.Time) error {
+	var tokens []RefreshToken
+	if err := db.Where("family_id = ?", familyID).Find(&tokens).Error; err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if t.AccessJTI == "" {
+				continue
+			}
+			if err := tx.Create(&RevokedToken{JTI: t.AccessJTI, UserID: t.UserID, Reason: reason, RevokedAt: now, ExpiresAt: t.ExpiresAt}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RevokeRefreshToken 退出登录时作废刷新令牌所在的家族，令牌不属于该用户时返回 ErrInvalidRefreshToken
+func RevokeRefreshToken(db *gorm.DB, userID uint, raw, reason string, now time.Time) error {
+	var rt RefreshToken
+	if err := db.Where("token_hash = ? AND user_id = ?", hashRefreshToken(raw), userID).First(&rt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+	return RevokeTokenFamily(db, rt.FamilyID, reason, now)
+}
+
+// RevokeAccessToken 把单个访问令牌加入撤销列表，与它同一次登录的刷新令牌家族一并作废，
+// 退出登录后被盗的刷新令牌也无法再换发
+func RevokeAccessToken(db *gorm.DB, userID uint, claims *AccessClaims, reason string, now time.Time) error {
+	if claims == nil || claims.JTI == "" {
+		return RevokeUserTokens(db, userID, reason, now)
+	}
+	var rt RefreshToken
+	err := db.Where("access_jti = ? AND user_id = ?", claims.JTI, userID).First(&rt).Error
+	if err == nil {
+		return RevokeTokenFamily(db, rt.FamilyID, reason, now)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return db.Create(&RevokedToken{JTI: claims.JTI, UserID: userID, Reason: reason, RevokedAt: now, ExpiresAt: claims.ExpiresAt}).Error
+}
+
+// RevokeUserTokens 作废用户当前所有的访问令牌和刷新令牌，用于令牌泄露或账号被盗
+func RevokeUserTokens(db *gorm.DB, userID uint, reason string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&RevokedToken{UserID: userID, Reason: reason, RevokedAt: now, ExpiresAt: now.Add(revokeAllRetention)}).Error
+	})
+}
+
+// IsAccessTokenRevoked 检查访问令牌是否在撤销列表中，旧格式令牌只受撤销全部令牌影响。
+// 查询失败时按已撤销处理，宁可让用户重新登录也不放行被撤销的令牌
+func IsAccessTokenRevoked(db *gorm.DB, userID uint, claims *AccessClaims) bool {
+	q := db.Model(&RevokedToken{}).Where("user_id = ? AND expires_at > ?", userID, time.Now())
+	if claims != nil && claims.JTI != "" {
+		q = q.Where("jti = ? OR (jti = '' AND revoked_at > ?)", claims.JTI, claims.IssuedAt)
+	} else {
+		q = q.Where("jti = ''")
+	}
+	var n int64
+	if err := q.Count(&n).Error; err != nil {
+		logger.Error("Failed to check token revocation", zap.Uint("userId", userID), zap.Error(err))
+		return true
+	}
+	return n > 0
+}
+
+// PruneExpiredTokens 清理已过期的刷新令牌和撤销记录
+func PruneExpiredTokens(db *gorm.DB, now time.Time) (int64, error) {
+	refresh := db.Where("expires_at < ?", now).Delete(&RefreshToken{})
+	if refresh.Error != nil {
+		return 0, refresh.Error
+	}
+	revoked := db.Where("expires_at < ?", now).Delete(&RevokedToken{})
+	return refresh.RowsAffected + revoked.RowsAffected, revoked.Error
+}
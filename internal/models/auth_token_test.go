@@ -0,0 +1,136 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTokenClaimsRoundTrip(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{})
+	user, err := CreateUser(db, "token@example.com", "password123")
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := AccessClaims{JTI: newTokenID(), IssuedAt: now, ExpiresAt: now.Add(time.Hour)}
+	token := EncodeAccessToken(user, claims, false)
+
+	decoded, got, err := DecodeAccessToken(db, token, false)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, decoded.ID)
+	assert.Equal(t, claims.JTI, got.JTI)
+	assert.Equal(t, now.UnixMilli(), got.IssuedAt.UnixMilli())
+
+	// Legacy tokens still decode, without a token ID
+	legacy := EncodeHashToken(user, now.Add(time.Hour).Unix(), false)
+	_, got, err = DecodeAccessToken(db, legacy, false)
+	require.NoError(t, err)
+	assert.Empty(t, got.JTI)
+
+	// A tampered token ID breaks the signature
+	claims.JTI = newTokenID()
+	forged := EncodeAccessToken(&User{Email: user.Email, Password: "other"}, claims, false)
+	_, _, err = DecodeAccessToken(db, forged, false)
+	assert.Error(t, err)
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &RefreshToken{}, &RevokedToken{})
+	user, err := CreateUser(db, "rotate@example.com", "password123")
+	require.NoError(t, err)
+	now := time.Now()
+
+	first, err := IssueTokenPair(db, user, 15*time.Minute, 24*time.Hour, "10.0.0.1", "test", now)
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.AccessToken)
+	assert.NotEmpty(t, first.RefreshToken)
+
+	second, got, err := RotateRefreshToken(db, first.RefreshToken, 15*time.Minute, 24*time.Hour, "10.0.0.1", "test", now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	_, claims, err := DecodeAccessToken(db, second.AccessToken, false)
+	require.NoError(t, err)
+	assert.False(t, IsAccessTokenRevoked(db, user.ID, claims))
+
+	// Reusing the rotated token revokes the whole login, including the newest tokens
+	_, _, err = RotateRefreshToken(db, first.RefreshToken, 15*time.Minute, 24*time.Hour, "10.0.0.2", "attacker", now.Add(2*time.Minute))
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	_, _, err = RotateRefreshToken(db, second.RefreshToken, 15*time.Minute, 24*time.Hour, "10.0.0.1", "test", now.Add(3*time.Minute))
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	assert.True(t, IsAccessTokenRevoked(db, user.ID, claims))
+
+	_, _, err = RotateRefreshToken(db, "unknown", 15*time.Minute, 24*time.Hour, "", "", now)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	expired, err := IssueTokenPair(db, user, 15*time.Minute, time.Hour, "", "", now)
+	require.NoError(t, err)
+	_, _, err = RotateRefreshToken(db, expired.RefreshToken, 15*time.Minute, time.Hour, "", "", now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrRefreshTokenExpired)
+}
+
+func TestRevokeTokens(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &RefreshToken{}, &RevokedToken{})
+	user, err := CreateUser(db, "revoke@example.com", "password123")
+	require.NoError(t, err)
+	now := time.Now()
+
+	a := &AccessClaims{JTI: newTokenID(), IssuedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)}
+	b := &AccessClaims{JTI: newTokenID(), IssuedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, RevokeAccessToken(db, user.ID, a, "logout", now))
+	assert.True(t, IsAccessTokenRevoked(db, user.ID, a))
+	assert.False(t, IsAccessTokenRevoked(db, user.ID, b))
+	assert.False(t, IsAccessTokenRevoked(db, user.ID, &AccessClaims{}), "legacy tokens survive a single revocation")
+
+	pair, err := IssueTokenPair(db, user, 15*time.Minute, 24*time.Hour, "", "", now)
+	require.NoError(t, err)
+	require.NoError(t, RevokeUserTokens(db, user.ID, "admin_revoke", now))
+	assert.True(t, IsAccessTokenRevoked(db, user.ID, b))
+	assert.True(t, IsAccessTokenRevoked(db, user.ID, &AccessClaims{}))
+	later := &AccessClaims{JTI: newTokenID(), IssuedAt: now.Add(time.Second), ExpiresAt: now.Add(time.Hour)}
+	assert.False(t, IsAccessTokenRevoked(db, user.ID, later), "tokens issued afterwards are valid")
+	_, _, err = RotateRefreshToken(db, pair.RefreshToken, 15*time.Minute, 24*time.Hour, "", "", now)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	other, err := IssueTokenPair(db, user, 15*time.Minute, 24*time.Hour, "", "", now)
+	require.NoError(t, err)
+	assert.ErrorIs(t, RevokeRefreshToken(db, user.ID+1, other.RefreshToken, "user_revoke", now), ErrInvalidRefreshToken)
+	require.NoError(t, RevokeRefreshToken(db, user.ID, other.RefreshToken, "user_revoke", now))
+
+	deleted, err := PruneExpiredTokens(db, now.Add(60*24*time.Hour))
+	require.NoError(t, err)
+	assert.Positive(t, deleted)
+	var left int64
+	db.Model(&RevokedToken{}).Count(&left)
+	assert.Zero(t, left)
+}
+
+func TestRevokeAccessToken_RevokesLogin(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &RefreshToken{}, &RevokedToken{})
+	user, err := CreateUser(db, "logout@example.com", "password123")
+	require.NoError(t, err)
+	now := time.Now()
+
+	pair, err := IssueTokenPair(db, user, 15*time.Minute, 24*time.Hour, "", "", now)
+	require.NoError(t, err)
+	_, err = DecodeHashToken(db, pair.AccessToken, false)
+	require.NoError(t, err)
+
+	// Logging out with the access token also ends the refresh token issued with it
+	_, claims, err := DecodeAccessToken(db, pair.AccessToken, false)
+	require.NoError(t, err)
+	require.NoError(t, RevokeAccessToken(db, user.ID, claims, "logout", now))
+	_, err = DecodeHashToken(db, pair.AccessToken, false)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, _, err = RotateRefreshToken(db, pair.RefreshToken, 15*time.Minute, 24*time.Hour, "", "", now)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+}
+
+func TestIsAccessTokenRevoked_DeniesOnError(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{})
+	claims := &AccessClaims{JTI: newTokenID(), IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	assert.True(t, IsAccessTokenRevoked(db, 1, claims), "the revocation list can't be read")
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/policy"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 可选的策略引擎
+const (
+	PolicyEngineRules = "rules" // 使用 authz_policies 表中的规则
+	PolicyEngineOPA   = "opa"   // 交由 OPA 服务评估
+)
+
+// policyReloadInterval 内置规则的重新加载间隔，后台直接修改规则后最迟在该时间后生效
+const policyReloadInterval = 30 * time.Second
+
+// AuthzPolicy 授权策略规则，在 RBAC 放行后进一步按属性判断，POLICY_ENGINE=rules 时生效
+type AuthzPolicy struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time     `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time     `json:"updatedAt" gorm:"autoUpdateTime"`
+	Name        string        `json:"name" gorm:"size:128"`
+	Description string        `json:"description,omitempty" gorm:"type:text"`
+	Subject     string        `json:"subject" gorm:"size:128;index"`         // *、role:<role>、user:<id>、group:<id>
+	Resource    string        `json:"resource" gorm:"size:256"`              // 资源名，* 为通配符，如 call_recordings*
+	Actions     string        `json:"actions" gorm:"size:256"`               // 逗号分隔，为空表示全部
+	Effect      policy.Effect `json:"effect" gorm:"size:8"`                  // allow, deny
+	Conditions  string        `json:"conditions,omitempty" gorm:"type:text"` // JSON 数组，见 policy.Condition
+	Priority    int           `json:"priority"`                              // 越大越优先
+	Enabled     bool          `json:"enabled"`
+	CreatedBy   uint          `json:"createdBy" gorm:"index"`
+}
+
+// TableName 指定表名
+func (AuthzPolicy) TableName() string {
+	return "authz_policies"
+}
+
+// Rule 转换为引擎规则
+func (p *AuthzPolicy) Rule() (policy.Rule, error) {
+	rule := policy.Rule{
+		ID:       strconv.FormatUint(uint64(p.ID), 10),
+		Subject:  p.Subject,
+		Resource: p.Resource,
+		Actions:  splitCSV(p.Actions),
+		Effect:   p.Effect,
+		Priority: p.Priority,
+	}
+	if strings.TrimSpace(p.Conditions) != "" {
+		if err := json.Unmarshal([]byte(p.Conditions), &rule.Conditions); err != nil {
+			return rule, fmt.Errorf("invalid conditions: %w", err)
+		}
+	}
+	return rule, rule.Validate()
+}
+
+// Validate 检查规则能否被引擎使用
+func (p *AuthzPolicy) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name is required")
+	}
+	_, err := p.Rule()
+	return err
+}
+
+// LoadAuthzRules 加载已启用的策略规则，格式错误的规则跳过
+func LoadAuthzRules(db *gorm.DB) ([]policy.Rule, error) {
+	var policies []AuthzPolicy
+	if err := db.Where("enabled = ?", true).Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	rules := make([]policy.Rule, 0, len(policies))
+	for i := range policies {
+		rule, err := policies[i].Rule()
+		if err != nil {
+			logger.Warn("Skip invalid authz policy", zap.Uint("id", policies[i].ID), zap.Error(err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// dbRuleEngine 定期从数据库重新加载规则的内置引擎
+type dbRuleEngine struct {
+	db       *gorm.DB
+	mu       sync.Mutex
+	engine   *policy.RuleEngine
+	loadedAt time.Time
+}
+
+func (e *dbRuleEngine) Name() string {
+	return PolicyEngineRules
+}
+
+func (e *dbRuleEngine) Evaluate(ctx context.Context, in policy.Input) (policy.Decision, error) {
+	e.mu.Lock()
+	if e.engine == nil || time.Since(e.loadedAt) >= policyReloadInterval {
+		rules, err := LoadAuthzRules(e.db)
+		if err != nil && e.engine == nil {
+			e.mu.Unlock()
+			return policy.Decision{Effect: policy.EffectNone, Engine: e.Name()}, err
+		}
+		if err == nil {
+			e.engine, e.loadedAt = policy.NewRuleEngine(rules), time.Now()
+		} else {
+			logger.Warn("Failed to reload authz policies, keep previous rules", zap.Error(err))
+		}
+	}
+	engine := e.engine
+	e.mu.Unlock()
+	return engine.Evaluate(ctx, in)
+}
+
+func (e *dbRuleEngine) invalidate() {
+	e.mu.Lock()
+	e.engine = nil
+	e.mu.Unlock()
+}
+
+var (
+	policyEngine   policy.Engine
+	policyEngineMu sync.RWMutex
+)
+
+// InitPolicyEngine 按配置启用策略引擎，未配置时只使用 RBAC
+func InitPolicyEngine(db *gorm.DB, cfg config.AuthConfig) error {
+	var engine policy.Engine
+	switch cfg.PolicyEngine {
+	case "":
+	case PolicyEngineRules:
+		engine = &dbRuleEngine{db: db}
+	case PolicyEngineOPA:
+		if cfg.OPAURL == "" {
+			return errors.New("OPA_URL is required for the opa policy engine")
+		}
+		engine = policy.NewOPAEngine(cfg.OPAURL, cfg.OPAPolicyPath)
+	default:
+		return fmt.Errorf("unsupported policy engine: %q", cfg.PolicyEngine)
+	}
+	SetPolicyEngine(engine)
+	return nil
+}
+
+// SetPolicyEngine 替换当前策略引擎，nil 表示关闭
+func SetPolicyEngine(engine policy.Engine) {
+	policyEngineMu.Lock()
+	policyEngine = engine
+	policyEngineMu.Unlock()
+}
+
+// CurrentPolicyEngine 当前策略引擎，未启用时为 nil
+func CurrentPolicyEngine() policy.Engine {
+	policyEngineMu.RLock()
+	defer policyEngineMu.RUnlock()
+	return policyEngine
+}
+
+// InvalidatePolicies 规则变更后让内置引擎在下次评估时重新加载
+func InvalidatePolicies() {
+	if e, ok := CurrentPolicyEngine().(*dbRuleEngine); ok {
+		e.invalidate()
+	}
+}
+
+// PolicySubject 构造用户的策略主体，属性包括所属组织与地区等资料
+func PolicySubject(db *gorm.DB, user *User) policy.Subject {
+	subject := policy.Subject{
+		ID:   user.ID,
+		Role: user.Role,
+		Attrs: map[string]any{
+			"email":    user.Email,
+			"region":   user.Region,
+			"city":     user.City,
+			"locale":   user.Locale,
+			"timezone": user.Timezone,
+			"isStaff":  user.IsStaff,
+		},
+	}
+	if _, domain, ok := strings.Cut(user.Email, "@"); ok {
+		subject.Attrs["emailDomain"] = domain
+	}
+	if db != nil {
+		subject.Groups = userGroupIDs(db, user.ID)
+	}
+	return subject
+}
+
+// userGroupIDs 用户加入和创建的组织
+func userGroupIDs(db *gorm.DB, userID uint) []uint {
+	var groupIDs []uint
+	db.Model(&GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs)
+	var created []uint
+	db.Model(&Group{}).Where("creator_id = ?", userID).Pluck("id", &created)
+	return append(groupIDs, created...)
+}
+
+// AuthorizePolicy 用当前策略引擎评估用户对资源的操作，object 为资源属性。
+// 未启用策略引擎或用户为超级管理员时直接放行
+func AuthorizePolicy(c *gin.Context, user *User, resource, action string, object map[string]any) (policy.Decision, error) {
+	engine := CurrentPolicyEngine()
+	if engine == nil || user == nil || user.IsSuperAdmin() {
+		return policy.Decision{Effect: policy.EffectNone, Reason: "policy engine not applied"}, nil
+	}
+	var db *gorm.DB
+	if v, ok := c.Get(constants.DbField); ok {
+		db, _ = v.(*gorm.DB)
+	}
+	in := policy.Input{
+		Subject:  PolicySubject(db, user),
+		Resource: resource,
+		Action:   action,
+		Object:   object,
+		Env: map[string]any{
+			"ip":     c.ClientIP(),
+			"method": c.Request.Method,
+			"path":   c.FullPath(),
+			"time":   time.Now().Format(time.RFC3339),
+		},
+	}
+	return engine.Evaluate(c.Request.Context(), in)
+}
+
+// PolicyRequired 在 RBAC 之后按策略判断用户能否对资源执行操作，需放在 AuthRequired 之后。
+// 资源名为 resource，带 :id 参数时为 resource/<id>，路由参数作为资源属性。
+// 策略评估出错时拒绝访问
+func PolicyRequired(resource, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentPolicyEngine() == nil {
+			c.Next()
+			return
+		}
+		name := resource
+		object := make(map[string]any, len(c.Params))
+		for _, p := range c.Params {
+			object[p.Key] = p.Value
+		}
+		if id := c.Param("id"); id != "" {
+			name = resource + "/" + id
+		}
+
+		decision, err := AuthorizePolicy(c, CurrentUser(c), name, action, object)
+		if err != nil {
+			logger.Error("Policy evaluation failed", zap.String("resource", name), zap.String("action", action), zap.Error(err))
+			LingEcho.AbortWithJSONError(c, http.StatusForbidden, errors.New("authorization policy unavailable"))
+			return
+		}
+		if !decision.Allowed() {
+			LingEcho.AbortWithJSONError(c, http.StatusForbidden, fmt.Errorf("denied by policy: %s", decision.Reason))
+			return
+		}
+		c.Next()
+	}
+}
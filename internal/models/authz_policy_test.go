@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthzPolicyRule(t *testing.T) {
+	p := AuthzPolicy{
+		ID:         3,
+		Name:       "support region",
+		Subject:    "role:support",
+		Resource:   "call_recordings*",
+		Actions:    "read, analyze",
+		Effect:     policy.EffectDeny,
+		Conditions: `[{"attr":"object.region","op":"ne","value":"$subject.region"}]`,
+	}
+	require.NoError(t, p.Validate())
+	rule, err := p.Rule()
+	require.NoError(t, err)
+	assert.Equal(t, "3", rule.ID)
+	assert.Equal(t, []string{"read", "analyze"}, rule.Actions)
+	require.Len(t, rule.Conditions, 1)
+	assert.Equal(t, policy.OpNe, rule.Conditions[0].Op)
+
+	bad := p
+	bad.Conditions = "{"
+	assert.Error(t, bad.Validate())
+	bad = p
+	bad.Name = ""
+	assert.Error(t, bad.Validate())
+}
+
+func TestDBRuleEngine(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &AuthzPolicy{})
+	policies := []AuthzPolicy{
+		{Name: "deny support writes", Subject: "role:support", Resource: "call_recordings*", Actions: "write", Effect: policy.EffectDeny, Enabled: true},
+		{Name: "disabled", Subject: "*", Resource: "*", Effect: policy.EffectDeny, Enabled: false},
+		{Name: "broken", Subject: "team:1", Resource: "*", Effect: policy.EffectDeny, Enabled: true},
+	}
+	require.NoError(t, db.Create(&policies).Error)
+
+	rules, err := LoadAuthzRules(db)
+	require.NoError(t, err)
+	assert.Len(t, rules, 1, "disabled and invalid policies are skipped")
+
+	require.NoError(t, InitPolicyEngine(db, config.AuthConfig{PolicyEngine: PolicyEngineRules}))
+	defer SetPolicyEngine(nil)
+	engine := CurrentPolicyEngine()
+	require.NotNil(t, engine)
+
+	in := policy.Input{Subject: policy.Subject{ID: 1, Role: "support"}, Resource: "call_recordings/5", Action: "write"}
+	d, err := engine.Evaluate(context.Background(), in)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed())
+
+	// Changes are picked up after invalidation
+	require.NoError(t, db.Model(&AuthzPolicy{}).Where("id = ?", policies[0].ID).Update("enabled", false).Error)
+	InvalidatePolicies()
+	d, err = engine.Evaluate(context.Background(), in)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed())
+
+	assert.Error(t, InitPolicyEngine(db, config.AuthConfig{PolicyEngine: "xacml"}))
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarCredential calendar account connected by a user.
+// Google accounts store OAuth tokens, CalDAV accounts store basic auth credentials.
+type CalendarCredential struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID       uint       `json:"userId" gorm:"index;not null"`
+	Provider     string     `json:"provider" gorm:"size:16;not null"` // google, caldav
+	Name         string     `json:"name" gorm:"size:128"`
+	AccountEmail string     `json:"accountEmail,omitempty" gorm:"size:255"`
+	CalendarID   string     `json:"calendarId,omitempty" gorm:"size:255"` // Google calendar ID, defaults to primary
+	AccessToken  string     `json:"-" gorm:"type:text"`
+	RefreshToken string     `json:"-" gorm:"type:text"`
+	TokenType    string     `json:"-" gorm:"size:32"`
+	TokenExpiry  *time.Time `json:"tokenExpiry,omitempty"`
+	CalDAVURL    string     `json:"caldavUrl,omitempty" gorm:"column:caldav_url;size:512"` // Calendar collection URL
+	Username     string     `json:"username,omitempty" gorm:"size:255"`
+	Password     string     `json:"-" gorm:"size:255"`
+	LastError    string     `json:"lastError,omitempty" gorm:"type:text"`
+}
+
+// TableName 指定表名
+func (CalendarCredential) TableName() string {
+	return "calendar_credentials"
+}
+
+// AssistantCalendar calendar bound to an assistant with the scopes its tools may use
+type AssistantCalendar struct {
+	ID              uint        `json:"id" gorm:"primaryKey"`
+	CreatedAt       time.Time   `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time   `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID     int64       `json:"assistantId" gorm:"uniqueIndex;not null"`
+	UserID          uint        `json:"userId" gorm:"index;not null"`
+	CredentialID    uint        `json:"credentialId" gorm:"index;not null"`
+	Scopes          StringArray `json:"scopes" gorm:"type:json"`    // availability, create_event, send_invite
+	Timezone        string      `json:"timezone" gorm:"size:64"`    // Used to interpret times spoken by callers
+	DefaultDuration int         `json:"defaultDuration"`            // Default meeting length in minutes
+	WorkdayStart    string      `json:"workdayStart" gorm:"size:5"` // HH:MM, availability search window
+	WorkdayEnd      string      `json:"workdayEnd" gorm:"size:5"`   // HH:MM
+	Enabled         bool        `json:"enabled"`
+}
+
+// TableName 指定表名
+func (AssistantCalendar) TableName() string {
+	return "assistant_calendars"
+}
+
+// HasScope reports whether the assistant was granted the scope
+func (a *AssistantCalendar) HasScope(scope string) bool {
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Location returns the configured timezone, UTC when unset or invalid
+func (a *AssistantCalendar) Location() *time.Location {
+	if a.Timezone != "" {
+		if loc, err := time.LoadLocation(a.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// GetAssistantCalendar gets the calendar binding of an assistant, nil if none
+func GetAssistantCalendar(db *gorm.DB, assistantID int64) (*AssistantCalendar, error) {
+	var binding AssistantCalendar
+	err := db.Where("assistant_id = ?", assistantID).First(&binding).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &binding, nil
+}
+
+// GetCalendarCredential gets a credential owned by the user
+func GetCalendarCredential(db *gorm.DB, userID, id uint) (*CalendarCredential, error) {
+	var cred CalendarCredential
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&cred).Error; err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// ListCalendarCredentials lists calendar accounts of a user
+func ListCalendarCredentials(db *gorm.DB, userID uint) ([]CalendarCredential, error) {
+	var creds []CalendarCredential
+	err := db.Where("user_id = ?", userID).Order("id ASC").Find(&creds).Error
+	return creds, err
+}
+
+// UpdateCalendarToken persists a refreshed OAuth token. Google omits the refresh
+// token on refresh responses, so an empty one keeps the stored value.
+func UpdateCalendarToken(db *gorm.DB, id uint, accessToken, refreshToken, tokenType string, expiry time.Time) error {
+	updates := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   tokenType,
+		"token_expiry": expiry,
+		"last_error":   "",
+	}
+	if refreshToken != "" {
+		updates["refresh_token"] = refreshToken
+	}
+	return db.Model(&CalendarCredential{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteCalendarCredential deletes a credential and unbinds it from assistants
+func DeleteCalendarCredential(db *gorm.DB, userID, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&CalendarCredential{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("credential_id = ?", id).Delete(&AssistantCalendar{}).Error
+	})
+}
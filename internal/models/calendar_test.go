@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssistantCalendar_Scopes(t *testing.T) {
+	binding := &AssistantCalendar{Scopes: StringArray{"availability"}, Timezone: "Asia/Shanghai"}
+	assert.True(t, binding.HasScope("availability"))
+	assert.False(t, binding.HasScope("send_invite"))
+	assert.Equal(t, "Asia/Shanghai", binding.Location().String())
+
+	binding.Timezone = "Mars/Olympus"
+	assert.Equal(t, time.UTC, binding.Location())
+}
+
+func TestUpdateCalendarToken_KeepsRefreshToken(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CalendarCredential{})
+	cred := &CalendarCredential{UserID: 1, Provider: "google", AccessToken: "a1", RefreshToken: "r1"}
+	require.NoError(t, db.Create(cred).Error)
+
+	expiry := time.Now().Add(time.Hour)
+	require.NoError(t, UpdateCalendarToken(db, cred.ID, "a2", "", "Bearer", expiry))
+
+	stored, err := GetCalendarCredential(db, 1, cred.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "a2", stored.AccessToken)
+	assert.Equal(t, "r1", stored.RefreshToken)
+
+	_, err = GetCalendarCredential(db, 2, cred.ID)
+	assert.Error(t, err)
+}
+
+func TestDeleteCalendarCredential_UnbindsAssistants(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CalendarCredential{}, &AssistantCalendar{})
+	cred := &CalendarCredential{UserID: 1, Provider: "caldav"}
+	require.NoError(t, db.Create(cred).Error)
+	require.NoError(t, db.Create(&AssistantCalendar{AssistantID: 9, UserID: 1, CredentialID: cred.ID, Enabled: true}).Error)
+
+	assert.Error(t, DeleteCalendarCredential(db, 2, cred.ID))
+	require.NoError(t, DeleteCalendarCredential(db, 1, cred.ID))
+
+	binding, err := GetAssistantCalendar(db, 9)
+	require.NoError(t, err)
+	assert.Nil(t, binding)
+}
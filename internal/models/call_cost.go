@@ -0,0 +1,346 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ttsCharactersPerMinute 价格表只有 TTS 每分钟单价时，按该语速把字符数折算为合成时长
+	ttsCharactersPerMinute = 250.0
+	// callCostSettleDelay 通话结束后等待用量记录写入的时间，之后再计算成本
+	callCostSettleDelay = 2 * time.Minute
+)
+
+// CallCost 单通通话的用量和按价格表计算的成本，计算后固定，不随价格表修改变化，
+// 便于与服务商账单对账
+type CallCost struct {
+	TelephonySeconds    int        `json:"telephonySeconds" gorm:"default:0"`    // 计费的线路时长（秒）
+	ASRSeconds          int        `json:"asrSeconds" gorm:"default:0"`          // 识别的音频时长（秒）
+	LLMPromptTokens     int        `json:"llmPromptTokens" gorm:"default:0"`     // LLM 输入 token
+	LLMCompletionTokens int        `json:"llmCompletionTokens" gorm:"default:0"` // LLM 输出 token
+	TTSCharacters       int        `json:"ttsCharacters" gorm:"default:0"`       // 合成的字符数
+	TelephonyCost       float64    `json:"telephonyCost" gorm:"default:0"`
+	ASRCost             float64    `json:"asrCost" gorm:"default:0"`
+	LLMCost             float64    `json:"llmCost" gorm:"default:0"`
+	TTSCost             float64    `json:"ttsCost" gorm:"default:0"`
+	TotalCost           float64    `json:"totalCost" gorm:"default:0;index"`
+	CostCurrency        string     `json:"costCurrency,omitempty" gorm:"size:8"`
+	CostedAt            *time.Time `json:"costedAt,omitempty" gorm:"index"` // 成本计算时间，为空表示尚未计算
+}
+
+// CallUsage 计算成本所需的用量和服务商
+type CallUsage struct {
+	TelephonySeconds    int
+	ASRSeconds          int
+	LLMPromptTokens     int
+	LLMCompletionTokens int
+	TTSCharacters       int
+	LLMModel            string
+	ASRProvider         string
+	TTSProvider         string
+}
+
+// PriceCall 按价格表计算一通通话的成本：线路和 ASR 按分钟，LLM 按千 token，
+// TTS 优先按千字符，未配置字符单价时按字符折算的合成时长计价
+func (p PriceTable) PriceCall(u CallUsage, now time.Time) CallCost {
+	c := CallCost{
+		TelephonySeconds:    u.TelephonySeconds,
+		ASRSeconds:          u.ASRSeconds,
+		LLMPromptTokens:     u.LLMPromptTokens,
+		LLMCompletionTokens: u.LLMCompletionTokens,
+		TTSCharacters:       u.TTSCharacters,
+		CostCurrency:        p.Currency,
+		CostedAt:            &now,
+	}
+	c.TelephonyCost = roundCost(float64(u.TelephonySeconds) / 60 * p.TelephonyPerMinute)
+	if price, _, ok := minutePriceFor(p.ASR, u.ASRProvider); ok {
+		c.ASRCost = roundCost(float64(u.ASRSeconds) / 60 * price)
+	}
+	if price, _, ok := p.LLMPriceFor(u.LLMModel); ok {
+		c.LLMCost = roundCost(float64(u.LLMPromptTokens)/1000*price.InputPer1K + float64(u.LLMCompletionTokens)/1000*price.OutputPer1K)
+	}
+	if price, _, ok := minutePriceFor(p.TTSPerKChars, u.TTSProvider); ok {
+		c.TTSCost = roundCost(float64(u.TTSCharacters) / 1000 * price)
+	} else if price, _, ok := minutePriceFor(p.TTS, u.TTSProvider); ok {
+		c.TTSCost = roundCost(float64(u.TTSCharacters) / ttsCharactersPerMinute * price)
+	}
+	c.TotalCost = roundCost(c.TelephonyCost + c.ASRCost + c.LLMCost + c.TTSCost)
+	return c
+}
+
+// sessionUsage 会话记录的 LLM token 和 ASR 时长，以及使用的 LLM 模型
+type sessionUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	ASRSeconds       int
+	Model            string
+}
+
+func getSessionUsage(db *gorm.DB, sessionID string) (sessionUsage, error) {
+	var u sessionUsage
+	if sessionID == "" {
+		return u, nil
+	}
+	err := db.Model(&UsageRecord{}).
+		Select("COALESCE(SUM(CASE WHEN usage_type = ? THEN prompt_tokens ELSE 0 END), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(CASE WHEN usage_type = ? THEN completion_tokens ELSE 0 END), 0) AS completion_tokens, "+
+			"COALESCE(SUM(CASE WHEN usage_type = ? THEN audio_duration ELSE 0 END), 0) AS asr_seconds",
+			UsageTypeLLM, UsageTypeLLM, UsageTypeASR).
+		Where("session_id = ?", sessionID).
+		Scan(&u).Error
+	if err != nil {
+		return u, err
+	}
+	var names []string
+	db.Model(&UsageRecord{}).Where("session_id = ? AND usage_type = ? AND model <> ''", sessionID, UsageTypeLLM).
+		Limit(1).Pluck("model", &names)
+	if len(names) > 0 {
+		u.Model = names[0]
+	}
+	return u, nil
+}
+
+// CallRecordingUsage 设备通话的用量：LLM 和 ASR 取会话的用量记录，TTS 字符取对话中助手的回复
+func CallRecordingUsage(db *gorm.DB, rec *CallRecording) (CallUsage, error) {
+	session, err := getSessionUsage(db, rec.SessionID)
+	if err != nil {
+		return CallUsage{}, err
+	}
+	u := CallUsage{
+		ASRSeconds:          session.ASRSeconds,
+		LLMPromptTokens:     session.PromptTokens,
+		LLMCompletionTokens: session.CompletionTokens,
+		LLMModel:            rec.LLMModel,
+		ASRProvider:         rec.ASRProvider,
+		TTSProvider:         rec.TTSProvider,
+	}
+	if u.LLMModel == "" {
+		u.LLMModel = session.Model
+	}
+	if details, err := rec.GetConversationDetails(); err == nil && details != nil {
+		for _, turn := range details.Turns {
+			if turn.Type != "user" {
+				u.TTSCharacters += utf8.RuneCountInString(turn.Content)
+			}
+		}
+	}
+	return u, nil
+}
+
+// SipCallUsage SIP 通话的用量：线路按接通时长，AI 接听的 LLM 和 ASR 取以 Call-ID 为会话的用量记录，
+// TTS 字符取 AI 会话中助手的消息
+func SipCallUsage(db *gorm.DB, call *SipCall) (CallUsage, error) {
+	session, err := getSessionUsage(db, call.CallID)
+	if err != nil {
+		return CallUsage{}, err
+	}
+	u := CallUsage{
+		TelephonySeconds:    call.Duration,
+		ASRSeconds:          session.ASRSeconds,
+		LLMPromptTokens:     session.PromptTokens,
+		LLMCompletionTokens: session.CompletionTokens,
+		LLMModel:            session.Model,
+	}
+	aiSession, err := GetAICallSessionByCallID(db, call.CallID)
+	if err != nil {
+		return u, nil
+	}
+	var assistant Assistant
+	if db.Select("id", "llm_model", "tts_provider").First(&assistant, aiSession.AssistantID).Error == nil {
+		u.TTSProvider = assistant.TtsProvider
+		if u.LLMModel == "" {
+			u.LLMModel = assistant.LLMModel
+		}
+	}
+	var messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	if json.Unmarshal([]byte(aiSession.Messages), &messages) == nil {
+		for _, m := range messages {
+			if m.Role == "assistant" {
+				u.TTSCharacters += utf8.RuneCountInString(m.Content)
+			}
+		}
+	}
+	return u, nil
+}
+
+// RateEndedCalls 为结束超过等待时间且尚未计算成本的 SIP 通话和设备通话计算成本，返回计算的通话数
+func RateEndedCalls(db *gorm.DB, table PriceTable, now time.Time, limit int) (int, error) {
+	settled := now.Add(-callCostSettleDelay)
+	rated := 0
+
+	var calls []SipCall
+	err := db.Where("costed_at IS NULL AND status IN ? AND end_time IS NOT NULL AND end_time < ?",
+		[]SipCallStatus{SipCallStatusEnded, SipCallStatusFailed, SipCallStatusCancelled}, settled).
+		Order("id").Limit(limit).Find(&calls).Error
+	if err != nil {
+		return rated, err
+	}
+	for i := range calls {
+		usage, err := SipCallUsage(db, &calls[i])
+		if err != nil {
+			return rated, err
+		}
+		cost := table.PriceCall(usage, now)
+		if err := db.Model(&calls[i]).Select(callCostColumns).Updates(&SipCall{CallCost: cost}).Error; err != nil {
+			return rated, err
+		}
+		rated++
+	}
+
+	var recordings []CallRecording
+	err = db.Where("costed_at IS NULL AND end_time > ? AND end_time < ?", time.Time{}, settled).
+		Order("id").Limit(limit).Find(&recordings).Error
+	if err != nil {
+		return rated, err
+	}
+	for i := range recordings {
+		usage, err := CallRecordingUsage(db, &recordings[i])
+		if err != nil {
+			return rated, err
+		}
+		cost := table.PriceCall(usage, now)
+		if err := db.Model(&recordings[i]).Select(callCostColumns).Updates(&CallRecording{CallCost: cost}).Error; err != nil {
+			return rated, err
+		}
+		rated++
+	}
+	return rated, nil
+}
+
+// callCostColumns 成本字段，写入时包括零值
+var callCostColumns = []string{
+	"telephony_seconds", "asr_seconds", "llm_prompt_tokens", "llm_completion_tokens", "tts_characters",
+	"telephony_cost", "asr_cost", "llm_cost", "tts_cost", "total_cost", "cost_currency", "costed_at",
+}
+
+// CDRCostTotals 一组通话的用量和成本合计
+type CDRCostTotals struct {
+	Calls               int64   `json:"calls"`
+	TelephonySeconds    int64   `json:"telephonySeconds"`
+	ASRSeconds          int64   `json:"asrSeconds"`
+	LLMPromptTokens     int64   `json:"llmPromptTokens"`
+	LLMCompletionTokens int64   `json:"llmCompletionTokens"`
+	TTSCharacters       int64   `json:"ttsCharacters"`
+	TelephonyCost       float64 `json:"telephonyCost"`
+	ASRCost             float64 `json:"asrCost"`
+	LLMCost             float64 `json:"llmCost"`
+	TTSCost             float64 `json:"ttsCost"`
+	TotalCost           float64 `json:"totalCost"`
+}
+
+func (t *CDRCostTotals) add(o CDRCostTotals) {
+	t.Calls += o.Calls
+	t.TelephonySeconds += o.TelephonySeconds
+	t.ASRSeconds += o.ASRSeconds
+	t.LLMPromptTokens += o.LLMPromptTokens
+	t.LLMCompletionTokens += o.LLMCompletionTokens
+	t.TTSCharacters += o.TTSCharacters
+	t.TelephonyCost = roundCost(t.TelephonyCost + o.TelephonyCost)
+	t.ASRCost = roundCost(t.ASRCost + o.ASRCost)
+	t.LLMCost = roundCost(t.LLMCost + o.LLMCost)
+	t.TTSCost = roundCost(t.TTSCost + o.TTSCost)
+	t.TotalCost = roundCost(t.TotalCost + o.TotalCost)
+}
+
+// CDRDayTotals 按天汇总
+type CDRDayTotals struct {
+	Day string `json:"day"` // YYYY-MM-DD
+	CDRCostTotals
+}
+
+// CDRCostReport CDR 成本报表：SIP 通话和设备通话分别汇总，并按天合计
+type CDRCostReport struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Currency string         `json:"currency"`
+	Sip      CDRCostTotals  `json:"sip"`
+	Device   CDRCostTotals  `json:"device"`
+	Total    CDRCostTotals  `json:"total"`
+	Days     []CDRDayTotals `json:"days"`
+	Unrated  int64          `json:"unrated"` // 时间范围内尚未计算成本的通话
+}
+
+const cdrTotalsSelect = "COUNT(*) AS calls, " +
+	"COALESCE(SUM(telephony_seconds), 0) AS telephony_seconds, COALESCE(SUM(asr_seconds), 0) AS asr_seconds, " +
+	"COALESCE(SUM(llm_prompt_tokens), 0) AS llm_prompt_tokens, COALESCE(SUM(llm_completion_tokens), 0) AS llm_completion_tokens, " +
+	"COALESCE(SUM(tts_characters), 0) AS tts_characters, " +
+	"COALESCE(SUM(telephony_cost), 0) AS telephony_cost, COALESCE(SUM(asr_cost), 0) AS asr_cost, " +
+	"COALESCE(SUM(llm_cost), 0) AS llm_cost, COALESCE(SUM(tts_cost), 0) AS tts_cost, COALESCE(SUM(total_cost), 0) AS total_cost"
+
+// BuildCDRCostReport 汇总用户（或组织）在时间范围内已计算成本的通话，按通话开始时间归属
+func BuildCDRCostReport(db *gorm.DB, userIDs []uint, groupID *uint, from, to time.Time, currency string) (*CDRCostReport, error) {
+	report := &CDRCostReport{From: from, To: to, Currency: currency}
+	scope := func(q *gorm.DB) *gorm.DB {
+		if groupID != nil {
+			return q.Where("group_id = ?", *groupID)
+		}
+		return q.Where("user_id IN ?", userIDs)
+	}
+
+	days := map[string]*CDRDayTotals{}
+	var order []string
+	collect := func(model any, startColumn string, scoped bool, into *CDRCostTotals) error {
+		q := db.Model(model).Where(startColumn+" >= ? AND "+startColumn+" < ?", from, to)
+		if scoped {
+			q = scope(q)
+		} else {
+			q = q.Where("user_id IN ?", userIDs)
+		}
+		var unrated int64
+		if err := q.Session(&gorm.Session{}).Where("costed_at IS NULL").Count(&unrated).Error; err != nil {
+			return err
+		}
+		report.Unrated += unrated
+
+		var rows []struct {
+			Day string
+			CDRCostTotals
+		}
+		if err := q.Where("costed_at IS NOT NULL").
+			Select("DATE(" + startColumn + ") AS day, " + cdrTotalsSelect).
+			Group("DATE(" + startColumn + ")").Order("day").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			day := row.Day
+			if len(day) > 10 {
+				day = day[:10]
+			}
+			into.add(row.CDRCostTotals)
+			d, ok := days[day]
+			if !ok {
+				d = &CDRDayTotals{Day: day}
+				days[day] = d
+				order = append(order, day)
+			}
+			d.add(row.CDRCostTotals)
+		}
+		return nil
+	}
+
+	if err := collect(&SipCall{}, "start_time", true, &report.Sip); err != nil {
+		return nil, err
+	}
+	// 设备通话录音没有组织字段，组织报表按成员汇总
+	if err := collect(&CallRecording{}, "start_time", false, &report.Device); err != nil {
+		return nil, err
+	}
+	report.Total.add(report.Sip)
+	report.Total.add(report.Device)
+
+	sort.Strings(order)
+	report.Days = make([]CDRDayTotals, 0, len(order))
+	for _, day := range order {
+		report.Days = append(report.Days, *days[day])
+	}
+	return report, nil
+}
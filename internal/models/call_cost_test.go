@@ -0,0 +1,144 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callCostPriceTable() PriceTable {
+	return PriceTable{
+		Currency:           "CNY",
+		LLM:                map[string]LLMPrice{"gpt-4o": {InputPer1K: 0.01, OutputPer1K: 0.03}},
+		ASR:                map[string]float64{"default": 0.06},
+		TTS:                map[string]float64{"default": 0.5, "qcloud": 0.25},
+		TTSPerKChars:       map[string]float64{"qcloud": 0.2},
+		TelephonyPerMinute: 0.12,
+	}
+}
+
+func TestPriceCall(t *testing.T) {
+	now := time.Now()
+	c := callCostPriceTable().PriceCall(CallUsage{
+		TelephonySeconds:    90,
+		ASRSeconds:          60,
+		LLMPromptTokens:     2000,
+		LLMCompletionTokens: 500,
+		TTSCharacters:       500,
+		LLMModel:            "gpt-4o-mini",
+		ASRProvider:         "funasr",
+		TTSProvider:         "qcloud",
+	}, now)
+
+	assert.Equal(t, 0.18, c.TelephonyCost)
+	assert.Equal(t, 0.06, c.ASRCost, "falls back to the default ASR price")
+	assert.Equal(t, 0.035, c.LLMCost, "model prefix matches")
+	assert.Equal(t, 0.1, c.TTSCost, "per-character price wins over per-minute")
+	assert.Equal(t, 0.375, c.TotalCost)
+	assert.Equal(t, "CNY", c.CostCurrency)
+	require.NotNil(t, c.CostedAt)
+
+	c = callCostPriceTable().PriceCall(CallUsage{TTSCharacters: 500, TTSProvider: "xunfei"}, now)
+	assert.Equal(t, 1.0, c.TTSCost, "characters converted to minutes of speech")
+	assert.Zero(t, c.LLMCost)
+}
+
+func TestRateEndedCalls(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{}, &CallRecording{}, &UsageRecord{}, &AICallSession{}, &Assistant{})
+	now := time.Now()
+	ended := now.Add(-10 * time.Minute)
+	recent := now.Add(-30 * time.Second)
+
+	require.NoError(t, db.Create(&Assistant{ID: 5, Name: "agent", TtsProvider: "qcloud", LLMModel: "gpt-4o"}).Error)
+	messages, _ := json.Marshal([]map[string]string{
+		{"role": "user", "content": "你好"},
+		{"role": "assistant", "content": "您好，请问有什么可以帮您"},
+	})
+	require.NoError(t, db.Create(&AICallSession{CallID: "c1", AssistantID: 5, Messages: string(messages)}).Error)
+	require.NoError(t, db.Create(&[]UsageRecord{
+		{UserID: 1, SessionID: "c1", UsageType: UsageTypeLLM, Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 100},
+		{UserID: 1, SessionID: "c1", UsageType: UsageTypeLLM, PromptTokens: 1000, CompletionTokens: 100},
+		{UserID: 1, SessionID: "c1", UsageType: UsageTypeASR, AudioDuration: 30},
+		{UserID: 1, SessionID: "dev-1", UsageType: UsageTypeASR, AudioDuration: 120},
+	}).Error)
+
+	require.NoError(t, db.Create(&[]SipCall{
+		{CallID: "c1", Status: SipCallStatusEnded, StartTime: ended.Add(-time.Minute), EndTime: &ended, Duration: 60},
+		{CallID: "c2", Status: SipCallStatusEnded, StartTime: recent, EndTime: &recent, Duration: 60},
+		{CallID: "c3", Status: SipCallStatusAnswered, StartTime: ended},
+	}).Error)
+	rec := &CallRecording{UserID: 1, AssistantID: 5, SessionID: "dev-1", StartTime: ended.Add(-time.Minute), EndTime: ended, TTSProvider: "qcloud"}
+	require.NoError(t, rec.SetConversationDetails(&ConversationDetails{Turns: []ConversationTurn{
+		{Type: "user", Content: "今天天气怎么样"},
+		{Type: "ai", Content: "晴天"},
+	}}))
+	require.NoError(t, db.Create(rec).Error)
+
+	rated, err := RateEndedCalls(db, callCostPriceTable(), now, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rated, "calls still settling or in progress are skipped")
+
+	call, err := GetSipCallByCallID(db, "c1")
+	require.NoError(t, err)
+	require.NotNil(t, call.CostedAt)
+	assert.Equal(t, 60, call.TelephonySeconds)
+	assert.Equal(t, 30, call.ASRSeconds)
+	assert.Equal(t, 2000, call.LLMPromptTokens)
+	assert.Equal(t, 12, call.TTSCharacters, "only assistant messages are synthesized")
+	assert.Equal(t, 0.12, call.TelephonyCost)
+	assert.Equal(t, 0.026, call.LLMCost)
+
+	var got CallRecording
+	require.NoError(t, db.First(&got, rec.ID).Error)
+	require.NotNil(t, got.CostedAt)
+	assert.Equal(t, 120, got.ASRSeconds)
+	assert.Equal(t, 2, got.TTSCharacters)
+	assert.Equal(t, 0.12, got.ASRCost)
+	assert.Zero(t, got.TelephonyCost, "device calls have no telephony leg")
+
+	rated, err = RateEndedCalls(db, callCostPriceTable(), now, 100)
+	require.NoError(t, err)
+	assert.Zero(t, rated, "costs are frozen once computed")
+}
+
+func TestBuildCDRCostReport(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{}, &CallRecording{})
+	userID, otherID, groupID := uint(1), uint(2), uint(9)
+	day1 := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 5, 2, 10, 0, 0, 0, time.UTC)
+	costed := day2.Add(time.Hour)
+
+	require.NoError(t, db.Create(&[]SipCall{
+		{CallID: "a", UserID: &userID, GroupID: &groupID, StartTime: day1,
+			CallCost: CallCost{TelephonySeconds: 60, TelephonyCost: 0.12, TotalCost: 0.12, CostedAt: &costed}},
+		{CallID: "b", UserID: &otherID, GroupID: &groupID, StartTime: day2,
+			CallCost: CallCost{TelephonySeconds: 120, TelephonyCost: 0.24, LLMCost: 0.01, TotalCost: 0.25, CostedAt: &costed}},
+		{CallID: "c", UserID: &userID, StartTime: day2},
+		{CallID: "d", UserID: &userID, StartTime: day2.AddDate(0, 1, 0),
+			CallCost: CallCost{TotalCost: 5, CostedAt: &costed}},
+	}).Error)
+	require.NoError(t, db.Create(&CallRecording{UserID: userID, StartTime: day2,
+		CallCost: CallCost{ASRSeconds: 30, ASRCost: 0.03, TotalCost: 0.03, CostedAt: &costed}}).Error)
+
+	from, to := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	report, err := BuildCDRCostReport(db, []uint{userID}, nil, from, to, "CNY")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.Sip.Calls)
+	assert.Equal(t, 0.12, report.Sip.TotalCost)
+	assert.Equal(t, int64(1), report.Device.Calls)
+	assert.Equal(t, 0.15, report.Total.TotalCost)
+	assert.Equal(t, int64(1), report.Unrated)
+	require.Len(t, report.Days, 2)
+	assert.Equal(t, "2026-05-01", report.Days[0].Day)
+	assert.Equal(t, 0.03, report.Days[1].TotalCost)
+
+	report, err = BuildCDRCostReport(db, []uint{userID, otherID}, &groupID, from, to, "CNY")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), report.Sip.Calls, "organization calls of every member")
+	assert.Equal(t, int64(180), report.Sip.TelephonySeconds)
+	assert.Equal(t, 0.4, report.Total.TotalCost)
+	assert.Zero(t, report.Unrated)
+}
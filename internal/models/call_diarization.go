@@ -0,0 +1,211 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/diarization"
+	"gorm.io/gorm"
+)
+
+const (
+	// SpeakerIDPrefix 说话人ID前缀，说话人按首次发言顺序编号
+	SpeakerIDPrefix = "speaker_"
+	// SpeakerLabelMaxLength 说话人名称的最大长度
+	SpeakerLabelMaxLength = 64
+)
+
+var (
+	ErrNoConversationDetails = errors.New("recording has no conversation details")
+	ErrUnknownTurn           = errors.New("turn does not exist or is not a user turn")
+	ErrInvalidSpeaker        = errors.New("invalid speaker id or label")
+)
+
+// ConversationSpeaker 通话中的一位说话人
+type ConversationSpeaker struct {
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"` // 手动设置的名称，如 "客户"、"主管"
+	Turns int    `json:"turns"`
+}
+
+// SpeakerRelabel 手动修正说话人：改名称或把轮次改到另一位说话人
+type SpeakerRelabel struct {
+	Labels map[string]string `json:"labels"` // 说话人ID -> 名称，空字符串清除名称
+	Turns  map[int]string    `json:"turns"`  // 轮次ID -> 说话人ID，可以是新的说话人
+}
+
+// SpeakerID 第 n 位说话人的ID，从 1 开始
+func SpeakerID(n int) string {
+	return fmt.Sprintf("%s%d", SpeakerIDPrefix, n)
+}
+
+// validSpeakerID 检查说话人ID格式
+func validSpeakerID(id string) bool {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, SpeakerIDPrefix))
+	return strings.HasPrefix(id, SpeakerIDPrefix) && err == nil && n > 0
+}
+
+// SpeakerName 说话人的显示名称，没有名称时为ID
+func (d *ConversationDetails) SpeakerName(id string) string {
+	for _, s := range d.Speakers {
+		if s.ID == id && s.Label != "" {
+			return s.Label
+		}
+	}
+	return id
+}
+
+// DiarizationSpans 用户轮次在录音中的位置，base 为录音开始时间；返回对应的轮次下标
+func (d *ConversationDetails) DiarizationSpans(base time.Time) ([]diarization.Span, []int) {
+	var spans []diarization.Span
+	var turns []int
+	for i, turn := range d.Turns {
+		if turn.Type != "user" || turn.StartTime.IsZero() || turn.EndTime.IsZero() {
+			continue
+		}
+		spans = append(spans, diarization.Span{Start: turn.StartTime.Sub(base), End: turn.EndTime.Sub(base)})
+		turns = append(turns, i)
+	}
+	return spans, turns
+}
+
+// ApplyDiarization 把分离结果写入用户轮次，已有的说话人名称按ID保留
+func (d *ConversationDetails) ApplyDiarization(turns []int, result *diarization.Result) {
+	for i, idx := range turns {
+		d.Turns[idx].SpeakerID = ""
+		if result.Labels[i] >= 0 {
+			d.Turns[idx].SpeakerID = SpeakerID(result.Labels[i] + 1)
+		}
+	}
+	d.countSpeakers()
+}
+
+// countSpeakers 按轮次重新统计说话人，没有轮次且没有名称的说话人被移除
+func (d *ConversationDetails) countSpeakers() {
+	labels := map[string]string{}
+	for _, s := range d.Speakers {
+		labels[s.ID] = s.Label
+	}
+	counts := map[string]int{}
+	for _, turn := range d.Turns {
+		if turn.SpeakerID != "" {
+			counts[turn.SpeakerID]++
+		}
+	}
+	speakers := []ConversationSpeaker{}
+	for id, label := range labels {
+		if counts[id] == 0 && label != "" {
+			speakers = append(speakers, ConversationSpeaker{ID: id, Label: label})
+		}
+	}
+	for id, n := range counts {
+		speakers = append(speakers, ConversationSpeaker{ID: id, Label: labels[id], Turns: n})
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(speakers[i].ID, SpeakerIDPrefix))
+		b, _ := strconv.Atoi(strings.TrimPrefix(speakers[j].ID, SpeakerIDPrefix))
+		return a < b
+	})
+	d.Speakers = speakers
+}
+
+// Relabel 应用手动修正，改过轮次的对话不再被自动分离覆盖
+func (d *ConversationDetails) Relabel(req *SpeakerRelabel) error {
+	for id, label := range req.Labels {
+		label = strings.TrimSpace(label)
+		if !validSpeakerID(id) || len([]rune(label)) > SpeakerLabelMaxLength {
+			return ErrInvalidSpeaker
+		}
+		req.Labels[id] = label
+	}
+	index := make(map[int]int, len(d.Turns))
+	for i, turn := range d.Turns {
+		if turn.Type == "user" {
+			index[turn.TurnID] = i
+		}
+	}
+	for turnID, speakerID := range req.Turns {
+		if _, ok := index[turnID]; !ok {
+			return ErrUnknownTurn
+		}
+		if !validSpeakerID(speakerID) {
+			return ErrInvalidSpeaker
+		}
+	}
+
+	for turnID, speakerID := range req.Turns {
+		d.Turns[index[turnID]].SpeakerID = speakerID
+	}
+	if len(req.Turns) > 0 {
+		d.SpeakersEdited = true
+	}
+	// 新说话人先登记，名称才能落到上面
+	for id := range req.Labels {
+		found := false
+		for _, s := range d.Speakers {
+			found = found || s.ID == id
+		}
+		if !found {
+			d.Speakers = append(d.Speakers, ConversationSpeaker{ID: id})
+		}
+	}
+	for i := range d.Speakers {
+		if label, ok := req.Labels[d.Speakers[i].ID]; ok {
+			d.Speakers[i].Label = label
+		}
+	}
+	d.countSpeakers()
+	return nil
+}
+
+// DiarizeCallRecording 对录音做说话人分离并保存到对话详情；手动改过轮次的录音跳过。
+// samples 为录音的 16 位 PCM 单声道采样
+func DiarizeCallRecording(db *gorm.DB, recording *CallRecording, samples []int16, sampleRate int) (*ConversationDetails, error) {
+	details, err := recording.GetConversationDetails()
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, ErrNoConversationDetails
+	}
+	if details.SpeakersEdited {
+		return details, nil
+	}
+	base := recording.StartTime
+	if base.IsZero() {
+		base = details.StartTime
+	}
+	spans, turns := details.DiarizationSpans(base)
+	result, err := diarization.Diarize(samples, sampleRate, spans, diarization.Options{})
+	if err != nil {
+		return nil, err
+	}
+	details.ApplyDiarization(turns, result)
+	return details, saveConversationDetails(db, recording, details)
+}
+
+// RelabelCallRecordingSpeakers 手动修正录音的说话人
+func RelabelCallRecordingSpeakers(db *gorm.DB, recording *CallRecording, req *SpeakerRelabel) (*ConversationDetails, error) {
+	details, err := recording.GetConversationDetails()
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, ErrNoConversationDetails
+	}
+	if err := details.Relabel(req); err != nil {
+		return nil, err
+	}
+	return details, saveConversationDetails(db, recording, details)
+}
+
+func saveConversationDetails(db *gorm.DB, recording *CallRecording, details *ConversationDetails) error {
+	if err := recording.SetConversationDetails(details); err != nil {
+		return err
+	}
+	return db.Model(recording).Update("conversation_details", recording.ConversationDetailsJSON).Error
+}
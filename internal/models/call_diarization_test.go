@@ -0,0 +1,109 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/diarization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diarizationDetails(start time.Time) *ConversationDetails {
+	turn := func(id int, kind string, from, to int) ConversationTurn {
+		return ConversationTurn{TurnID: id, Type: kind, Content: kind, StartTime: start.Add(time.Duration(from) * time.Second), EndTime: start.Add(time.Duration(to) * time.Second)}
+	}
+	return &ConversationDetails{
+		StartTime: start,
+		Turns: []ConversationTurn{
+			turn(1, "user", 0, 1),
+			turn(2, "ai", 1, 2),
+			turn(3, "user", 2, 3),
+			turn(4, "user", 3, 4),
+		},
+	}
+}
+
+// toneSeconds one tone per second, silence for a zero frequency
+func toneSeconds(rate int, freqs ...float64) []int16 {
+	samples := make([]int16, 0, rate*len(freqs))
+	for _, f := range freqs {
+		for i := 0; i < rate; i++ {
+			samples = append(samples, int16(8000*math.Sin(2*math.Pi*f*float64(i)/float64(rate))))
+		}
+	}
+	return samples
+}
+
+func TestConversationDetails_ApplyDiarization(t *testing.T) {
+	details := diarizationDetails(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	spans, turns := details.DiarizationSpans(details.StartTime)
+	require.Len(t, spans, 3)
+	assert.Equal(t, []int{0, 2, 3}, turns)
+	assert.Equal(t, 2*time.Second, spans[1].Start)
+
+	details.ApplyDiarization(turns, &diarization.Result{Labels: []int{0, 1, -1}, Speakers: 2})
+	assert.Equal(t, "speaker_1", details.Turns[0].SpeakerID)
+	assert.Empty(t, details.Turns[1].SpeakerID)
+	assert.Equal(t, "speaker_2", details.Turns[2].SpeakerID)
+	assert.Empty(t, details.Turns[3].SpeakerID)
+	assert.Equal(t, []ConversationSpeaker{{ID: "speaker_1", Turns: 1}, {ID: "speaker_2", Turns: 1}}, details.Speakers)
+}
+
+func TestConversationDetails_Relabel(t *testing.T) {
+	details := diarizationDetails(time.Now())
+	_, turns := details.DiarizationSpans(details.StartTime)
+	details.ApplyDiarization(turns, &diarization.Result{Labels: []int{0, 1, 1}, Speakers: 2})
+
+	require.NoError(t, details.Relabel(&SpeakerRelabel{Labels: map[string]string{"speaker_2": " Supervisor "}}))
+	assert.Equal(t, "Supervisor", details.SpeakerName("speaker_2"))
+	assert.Equal(t, "speaker_1", details.SpeakerName("speaker_1"))
+	assert.False(t, details.SpeakersEdited, "renaming keeps automatic diarization")
+
+	require.NoError(t, details.Relabel(&SpeakerRelabel{
+		Turns:  map[int]string{4: "speaker_3"},
+		Labels: map[string]string{"speaker_3": "Customer"},
+	}))
+	assert.True(t, details.SpeakersEdited)
+	assert.Equal(t, []ConversationSpeaker{
+		{ID: "speaker_1", Turns: 1},
+		{ID: "speaker_2", Label: "Supervisor", Turns: 1},
+		{ID: "speaker_3", Label: "Customer", Turns: 1},
+	}, details.Speakers)
+
+	assert.ErrorIs(t, details.Relabel(&SpeakerRelabel{Turns: map[int]string{2: "speaker_1"}}), ErrUnknownTurn, "assistant turns")
+	assert.ErrorIs(t, details.Relabel(&SpeakerRelabel{Turns: map[int]string{1: "agent"}}), ErrInvalidSpeaker)
+	assert.ErrorIs(t, details.Relabel(&SpeakerRelabel{Labels: map[string]string{"speaker_0": "x"}}), ErrInvalidSpeaker)
+}
+
+func TestDiarizeCallRecording(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallRecording{})
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	recording := &CallRecording{UserID: 1, AssistantID: 1, StartTime: start}
+	require.NoError(t, recording.SetConversationDetails(diarizationDetails(start)))
+	require.NoError(t, db.Create(recording).Error)
+
+	const rate = 8000
+	details, err := DiarizeCallRecording(db, recording, toneSeconds(rate, 200, 0, 1800, 200), rate)
+	require.NoError(t, err)
+	assert.Equal(t, "speaker_1", details.Turns[0].SpeakerID)
+	assert.Equal(t, "speaker_2", details.Turns[2].SpeakerID)
+	assert.Equal(t, "speaker_1", details.Turns[3].SpeakerID)
+
+	var stored CallRecording
+	require.NoError(t, db.First(&stored, recording.ID).Error)
+	saved, err := stored.GetConversationDetails()
+	require.NoError(t, err)
+	assert.Len(t, saved.Speakers, 2)
+
+	// Manual corrections survive another analysis
+	_, err = RelabelCallRecordingSpeakers(db, &stored, &SpeakerRelabel{Turns: map[int]string{4: "speaker_2"}})
+	require.NoError(t, err)
+	details, err = DiarizeCallRecording(db, &stored, toneSeconds(rate, 200, 0, 1800, 200), rate)
+	require.NoError(t, err)
+	assert.Equal(t, "speaker_2", details.Turns[3].SpeakerID)
+
+	_, err = DiarizeCallRecording(db, &CallRecording{}, nil, rate)
+	assert.ErrorIs(t, err, ErrNoConversationDetails)
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/code-100-precent/LingEcho/pkg/pii"
+	"gorm.io/gorm"
+)
+
+// PIIMetricsDefaultDays 检测质量指标默认统计最近的天数
+const PIIMetricsDefaultDays = 30
+
+var ErrInvalidPIIReview = errors.New("invalid PII review")
+
+// TranscriptPIIEntity 对话轮次中标记的个人信息，Start/End 为轮次内容中的字符位置
+type TranscriptPIIEntity struct {
+	TurnID int `json:"turnId"`
+	pii.Entity
+	Status     string     `json:"status"` // detected / confirmed / rejected / added
+	ReviewedBy *uint      `json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+}
+
+// PIIReview 审核一个标记：确认、驳回检测结果，或补充漏检的个人信息
+type PIIReview struct {
+	TurnID int    `json:"turnId"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Type   string `json:"type"`   // 补充时必填
+	Status string `json:"status"` // confirmed / rejected / added
+}
+
+// parsePIITypes 解析逗号分隔的个人信息类型
+func parsePIITypes(list string) ([]string, error) {
+	types := []string{}
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !pii.ValidType(t) {
+			return nil, fmt.Errorf("unknown PII type %q, expected one of %s", t, strings.Join(pii.Types, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// DetectPII 重新检测所有轮次中的个人信息；审核过的标记保留，与其重叠的新检测结果丢弃
+func (d *ConversationDetails) DetectPII(now time.Time) {
+	var entities []TranscriptPIIEntity
+	for _, e := range d.PIIEntities {
+		if e.Status != pii.StatusDetected {
+			entities = append(entities, e)
+		}
+	}
+	reviewed := len(entities)
+	for _, turn := range d.Turns {
+		for _, found := range pii.Detect(turn.Content) {
+			overlaps := false
+			for _, e := range entities[:reviewed] {
+				overlaps = overlaps || (e.TurnID == turn.TurnID && found.Start < e.End && e.Start < found.End)
+			}
+			if !overlaps {
+				entities = append(entities, TranscriptPIIEntity{TurnID: turn.TurnID, Entity: found, Status: pii.StatusDetected})
+			}
+		}
+	}
+	d.PIIEntities = entities
+	d.PIIDetectedAt = &now
+}
+
+// ReviewPII 应用审核结果
+func (d *ConversationDetails) ReviewPII(reviews []PIIReview, reviewerID uint, now time.Time) error {
+	contents := map[int]string{}
+	for _, turn := range d.Turns {
+		contents[turn.TurnID] = turn.Content
+	}
+	for _, r := range reviews {
+		content, ok := contents[r.TurnID]
+		if !ok {
+			return ErrUnknownTurn
+		}
+		if r.Start < 0 || r.End <= r.Start || r.End > utf8.RuneCountInString(content) {
+			return fmt.Errorf("%w: span %d-%d is outside turn %d", ErrInvalidPIIReview, r.Start, r.End, r.TurnID)
+		}
+		if r.Status != pii.StatusConfirmed && r.Status != pii.StatusRejected && r.Status != pii.StatusAdded {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidPIIReview, r.Status)
+		}
+		if r.Type != "" && !pii.ValidType(r.Type) {
+			return fmt.Errorf("%w: unknown type %q", ErrInvalidPIIReview, r.Type)
+		}
+		tagged := d.findPII(r.TurnID, r.Start, r.End) != nil
+		if !tagged && (r.Status != pii.StatusAdded || r.Type == "") {
+			return fmt.Errorf("%w: turn %d %d-%d is not tagged, add it with a type", ErrInvalidPIIReview, r.TurnID, r.Start, r.End)
+		}
+		if tagged && r.Status == pii.StatusAdded {
+			return fmt.Errorf("%w: turn %d %d-%d is already tagged", ErrInvalidPIIReview, r.TurnID, r.Start, r.End)
+		}
+	}
+
+	for _, r := range reviews {
+		reviewer := reviewerID
+		reviewedAt := now
+		entity := d.findPII(r.TurnID, r.Start, r.End)
+		if entity == nil {
+			// 补充漏检的个人信息，计入检测质量指标的漏检数
+			runes := []rune(contents[r.TurnID])
+			d.PIIEntities = append(d.PIIEntities, TranscriptPIIEntity{
+				TurnID: r.TurnID,
+				Entity: pii.Entity{Type: r.Type, Start: r.Start, End: r.End, Text: string(runes[r.Start:r.End]), Confidence: 1},
+			})
+			entity = &d.PIIEntities[len(d.PIIEntities)-1]
+		}
+		if r.Type != "" {
+			entity.Type = r.Type
+		}
+		entity.Status = r.Status
+		entity.ReviewedBy = &reviewer
+		entity.ReviewedAt = &reviewedAt
+	}
+	return nil
+}
+
+func (d *ConversationDetails) findPII(turnID, start, end int) *TranscriptPIIEntity {
+	for i := range d.PIIEntities {
+		e := &d.PIIEntities[i]
+		if e.TurnID == turnID && e.Start == start && e.End == end {
+			return e
+		}
+	}
+	return nil
+}
+
+// MaskPII 遮盖轮次内容和标记中的个人信息，types 为空时遮盖所有类型；驳回的标记不遮盖，
+// 已修正的轮次按修正后的内容重新检测后遮盖
+func (d *ConversationDetails) MaskPII(types []string) {
+	masked := func(t string) bool {
+		if len(types) == 0 {
+			return true
+		}
+		for _, m := range types {
+			if m == t {
+				return true
+			}
+		}
+		return false
+	}
+	byTurn := map[int][]pii.Entity{}
+	for i := range d.PIIEntities {
+		e := &d.PIIEntities[i]
+		if !pii.Masked(e.Status) || !masked(e.Type) {
+			continue
+		}
+		byTurn[e.TurnID] = append(byTurn[e.TurnID], e.Entity)
+		e.Text = strings.Repeat(string(pii.MaskChar), utf8.RuneCountInString(e.Text))
+	}
+	for i := range d.Turns {
+		turn := &d.Turns[i]
+		if !turn.Corrected {
+			turn.Content = pii.Mask(turn.Content, byTurn[turn.TurnID])
+			continue
+		}
+		// 标记位置对应原始识别结果，修正后的内容重新检测
+		turn.OriginalContent = pii.Mask(turn.OriginalContent, byTurn[turn.TurnID])
+		var found []pii.Entity
+		for _, e := range pii.Detect(turn.Content) {
+			if masked(e.Type) {
+				found = append(found, e)
+			}
+		}
+		turn.Content = pii.Mask(turn.Content, found)
+	}
+}
+
+// DetectCallRecordingPII 检测录音对话中的个人信息并保存
+func DetectCallRecordingPII(db *gorm.DB, recording *CallRecording, now time.Time) (*ConversationDetails, error) {
+	details, err := recording.GetConversationDetails()
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, ErrNoConversationDetails
+	}
+	details.DetectPII(now)
+	return details, saveConversationDetails(db, recording, details)
+}
+
+// ReviewCallRecordingPII 审核录音对话中的个人信息标记
+func ReviewCallRecordingPII(db *gorm.DB, recording *CallRecording, reviews []PIIReview, reviewerID uint, now time.Time) (*ConversationDetails, error) {
+	details, err := recording.GetConversationDetails()
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, ErrNoConversationDetails
+	}
+	if err := details.ReviewPII(reviews, reviewerID, now); err != nil {
+		return nil, err
+	}
+	return details, saveConversationDetails(db, recording, details)
+}
+
+// CallRecordingGroupID 录音所属助手的组织，个人助手返回 nil
+func CallRecordingGroupID(db *gorm.DB, recording *CallRecording) *uint {
+	var assistant Assistant
+	if err := db.Select("id", "group_id").First(&assistant, recording.AssistantID).Error; err != nil {
+		return nil
+	}
+	return assistant.GroupID
+}
+
+// PIIMaskForViewer 查看者需要遮盖的个人信息类型；mask 为 false 表示不遮盖。
+// 按录音助手所属组织的策略决定，组织管理员可以看到原文以便审核
+func PIIMaskForViewer(db *gorm.DB, recording *CallRecording, viewer *User) (types []string, mask bool) {
+	groupID := CallRecordingGroupID(db, recording)
+	if groupID == nil {
+		return nil, false
+	}
+	policy, err := GetGroupSecurityPolicy(db, *groupID)
+	if err != nil || !policy.MaskPII {
+		return nil, false
+	}
+	var group Group
+	if err := db.First(&group, *groupID).Error; err == nil && viewer != nil && IsGroupAdmin(db, &group, viewer.ID) {
+		return nil, false
+	}
+	types, _ = parsePIITypes(policy.MaskPIITypes)
+	return types, true
+}
+
+// GroupPIIMetrics 组织助手的录音在 since 之后的个人信息检测质量，按审核结果计算
+func GroupPIIMetrics(db *gorm.DB, groupID uint, since time.Time) (*pii.Metrics, error) {
+	metrics := pii.NewMetrics()
+	var recordings []CallRecording
+	err := db.Select("id", "conversation_details").
+		Where("assistant_id IN (?) AND created_at >= ? AND is_deleted = ?",
+			db.Model(&Assistant{}).Select("id").Where("group_id = ?", groupID), since, false).
+		FindInBatches(&recordings, 200, func(tx *gorm.DB, batch int) error {
+			for i := range recordings {
+				details, err := recordings[i].GetConversationDetails()
+				if err != nil || details == nil || details.PIIDetectedAt == nil {
+					continue
+				}
+				metrics.Transcripts++
+				reviewed := false
+				for _, e := range details.PIIEntities {
+					metrics.Add(e.Type, e.Status)
+					reviewed = reviewed || e.Status != pii.StatusDetected
+				}
+				if reviewed {
+					metrics.Reviewed++
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return metrics.Finish(), nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/pii"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func piiDetails() *ConversationDetails {
+	return &ConversationDetails{
+		Turns: []ConversationTurn{
+			{TurnID: 1, Type: "user", Content: "我叫张伟，电话13812345678"},
+			{TurnID: 2, Type: "ai", Content: "好的张先生，已记录您的电话"},
+			{TurnID: 3, Type: "user", Content: "地址是幸福小区5栋"},
+		},
+	}
+}
+
+func TestConversationDetails_DetectAndReviewPII(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	details := piiDetails()
+	details.DetectPII(now)
+	require.Len(t, details.PIIEntities, 3)
+	assert.Equal(t, pii.TypeName, details.PIIEntities[0].Type)
+	assert.Equal(t, "13812345678", details.PIIEntities[1].Text)
+	assert.Equal(t, 2, details.PIIEntities[2].TurnID)
+	assert.Equal(t, pii.StatusDetected, details.PIIEntities[2].Status)
+
+	// "张" alone is a surname, the reviewer rejects it and tags the missed address
+	require.NoError(t, details.ReviewPII([]PIIReview{
+		{TurnID: 1, Start: 2, End: 4, Status: pii.StatusConfirmed},
+		{TurnID: 2, Start: 2, End: 3, Status: pii.StatusRejected},
+		{TurnID: 3, Start: 3, End: 9, Type: pii.TypeAddress, Status: pii.StatusAdded},
+	}, 7, now))
+	added := details.PIIEntities[3]
+	assert.Equal(t, "幸福小区5栋", added.Text)
+	assert.Equal(t, uint(7), *added.ReviewedBy)
+
+	// Re-detection keeps the reviews and does not tag the rejected span again
+	details.DetectPII(now.Add(time.Hour))
+	require.Len(t, details.PIIEntities, 4)
+	statuses := map[string]int{}
+	for _, e := range details.PIIEntities {
+		statuses[e.Status]++
+	}
+	assert.Equal(t, map[string]int{pii.StatusConfirmed: 1, pii.StatusRejected: 1, pii.StatusAdded: 1, pii.StatusDetected: 1}, statuses)
+
+	for name, review := range map[string]PIIReview{
+		"unknown turn":   {TurnID: 9, Start: 0, End: 1, Status: pii.StatusConfirmed},
+		"out of range":   {TurnID: 1, Start: 2, End: 40, Status: pii.StatusConfirmed},
+		"not tagged":     {TurnID: 1, Start: 0, End: 1, Status: pii.StatusConfirmed},
+		"no type":        {TurnID: 1, Start: 0, End: 1, Status: pii.StatusAdded},
+		"already tagged": {TurnID: 1, Start: 2, End: 4, Type: pii.TypeName, Status: pii.StatusAdded},
+		"bad status":     {TurnID: 1, Start: 2, End: 4, Status: "maybe"},
+	} {
+		assert.Error(t, details.ReviewPII([]PIIReview{review}, 7, now), name)
+	}
+}
+
+func TestConversationDetails_MaskPII(t *testing.T) {
+	details := piiDetails()
+	details.DetectPII(time.Now())
+	require.NoError(t, details.ReviewPII([]PIIReview{{TurnID: 2, Start: 2, End: 3, Status: pii.StatusRejected}}, 1, time.Now()))
+
+	phonesOnly := piiDetails()
+	phonesOnly.DetectPII(time.Now())
+	phonesOnly.MaskPII([]string{pii.TypePhone})
+	assert.Equal(t, "我叫张伟，电话***********", phonesOnly.Turns[0].Content)
+
+	details.MaskPII(nil)
+	assert.Equal(t, "我叫**，电话***********", details.Turns[0].Content)
+	assert.Equal(t, "好的张先生，已记录您的电话", details.Turns[1].Content, "rejected tags are not masked")
+	assert.Equal(t, "**", details.PIIEntities[0].Text)
+}
+
+func TestPIIPolicyAndMetrics(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallRecording{}, &Assistant{}, &Group{}, &GroupMember{}, &GroupSecurityPolicy{})
+	groupID := uint(1)
+	require.NoError(t, db.Create(&Group{ID: groupID, Name: "acme", CreatorID: 1}).Error)
+	require.NoError(t, db.Create(&Assistant{ID: 5, UserID: 2, GroupID: &groupID}).Error)
+	require.NoError(t, db.Create(&Assistant{ID: 6, UserID: 2}).Error)
+
+	recording := &CallRecording{UserID: 2, AssistantID: 5}
+	require.NoError(t, recording.SetConversationDetails(piiDetails()))
+	require.NoError(t, db.Create(recording).Error)
+	personal := &CallRecording{UserID: 2, AssistantID: 6}
+	require.NoError(t, personal.SetConversationDetails(piiDetails()))
+	require.NoError(t, db.Create(personal).Error)
+
+	_, err := DetectCallRecordingPII(db, recording, time.Now())
+	require.NoError(t, err)
+	_, err = ReviewCallRecordingPII(db, recording, []PIIReview{
+		{TurnID: 1, Start: 7, End: 18, Status: pii.StatusConfirmed},
+		{TurnID: 2, Start: 2, End: 3, Status: pii.StatusRejected},
+	}, 1, time.Now())
+	require.NoError(t, err)
+	_, err = DetectCallRecordingPII(db, personal, time.Now())
+	require.NoError(t, err)
+
+	// No policy yet, nothing is masked
+	_, mask := PIIMaskForViewer(db, recording, userWithID(2))
+	assert.False(t, mask)
+
+	policy := &GroupSecurityPolicy{GroupID: groupID, MaskPII: true, MaskPIITypes: "phone, name"}
+	require.NoError(t, SaveGroupSecurityPolicy(db, policy))
+	assert.Equal(t, "phone,name", policy.MaskPIITypes)
+	assert.Error(t, SaveGroupSecurityPolicy(db, &GroupSecurityPolicy{GroupID: groupID, MaskPIITypes: "ssn"}))
+
+	types, mask := PIIMaskForViewer(db, recording, userWithID(2))
+	assert.True(t, mask)
+	assert.Equal(t, []string{pii.TypePhone, pii.TypeName}, types)
+	_, mask = PIIMaskForViewer(db, recording, userWithID(1))
+	assert.False(t, mask, "organization admins see the original text")
+	_, mask = PIIMaskForViewer(db, personal, userWithID(2))
+	assert.False(t, mask, "personal assistants have no organization policy")
+
+	metrics, err := GroupPIIMetrics(db, groupID, time.Now().AddDate(0, 0, -PIIMetricsDefaultDays))
+	require.NoError(t, err)
+	assert.Equal(t, 1, metrics.Transcripts)
+	assert.Equal(t, 1, metrics.Reviewed)
+	assert.Equal(t, 3, metrics.Overall.Detected)
+	assert.Equal(t, 1, metrics.ByType[pii.TypePhone].Confirmed)
+	require.NotNil(t, metrics.Overall.Precision)
+	assert.InDelta(t, 0.5, *metrics.Overall.Precision, 1e-9)
+}
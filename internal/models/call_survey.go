@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CallSurveyStatus 通话后满意度调查的结果
+type CallSurveyStatus string
+
+const (
+	CallSurveyAnswered    CallSurveyStatus = "answered"    // 已按键评分
+	CallSurveyNoResponse  CallSurveyStatus = "no_response" // 未在时限内按有效键
+	CallSurveyHangup      CallSurveyStatus = "hangup"      // 评分前已挂断
+	CallSurveyUnavailable CallSurveyStatus = "unavailable" // 提示音无法播放
+)
+
+const (
+	DefaultSurveyPrompt  = "请为本次服务打分，1分表示非常不满意，5分表示非常满意，请按电话键盘上的数字"
+	DefaultSurveyThanks  = "感谢您的评价，再见"
+	defaultSurveyTimeout = 10
+	defaultSurveyScale   = 5
+)
+
+// CallSurveySettings 用户的通话后满意度调查配置，作用于该用户的 AI 代接通话和坐席呼出通话
+type CallSurveySettings struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID         uint      `json:"userId" gorm:"uniqueIndex;not null"`
+	Enabled        bool      `json:"enabled"`
+	Prompt         string    `json:"prompt,omitempty" gorm:"type:text"`    // 调查提示语，AI 通话通过 TTS 播放
+	PromptFile     string    `json:"promptFile,omitempty" gorm:"size:256"` // 调查提示音 WAV（8kHz 16bit 单声道），优先于提示语；坐席通话必须配置
+	Thanks         string    `json:"thanks,omitempty" gorm:"type:text"`    // 评分后的致谢语，仅 AI 通话播放
+	Scale          int       `json:"scale" gorm:"default:5"`               // 最高分，按键 1~Scale 有效
+	TimeoutSeconds int       `json:"timeoutSeconds" gorm:"default:10"`     // 等待按键时长
+	Channels       string    `json:"channels,omitempty" gorm:"size:32"`    // 生效的通话类型：ai, agent, 逗号分隔，为空表示全部
+}
+
+// TableName 指定表名
+func (CallSurveySettings) TableName() string {
+	return "call_survey_settings"
+}
+
+// Validate 检查配置并补全默认值
+func (s *CallSurveySettings) Validate() error {
+	if s.Scale == 0 {
+		s.Scale = defaultSurveyScale
+	}
+	if s.Scale < 2 || s.Scale > 9 {
+		return errors.New("scale must be between 2 and 9")
+	}
+	if s.TimeoutSeconds == 0 {
+		s.TimeoutSeconds = defaultSurveyTimeout
+	}
+	if s.TimeoutSeconds < 3 || s.TimeoutSeconds > 60 {
+		return errors.New("timeoutSeconds must be between 3 and 60")
+	}
+	for _, ch := range splitCSV(s.Channels) {
+		if ch != CallSurveyChannelAI && ch != CallSurveyChannelAgent {
+			return fmt.Errorf("unsupported channel: %q", ch)
+		}
+	}
+	return nil
+}
+
+// 调查生效的通话类型
+const (
+	CallSurveyChannelAI    = "ai"    // AI 代接通话，助手结束对话后调查
+	CallSurveyChannelAgent = "agent" // 坐席呼出通话，坐席挂断时调查
+)
+
+// AppliesTo 配置是否对该类型的通话生效
+func (s *CallSurveySettings) AppliesTo(channel string) bool {
+	if !s.Enabled {
+		return false
+	}
+	channels := splitCSV(s.Channels)
+	if len(channels) == 0 {
+		return true
+	}
+	for _, ch := range channels {
+		if ch == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// PromptText 调查提示语，未配置时使用默认提示语
+func (s *CallSurveySettings) PromptText() string {
+	if s.Prompt != "" {
+		return s.Prompt
+	}
+	return DefaultSurveyPrompt
+}
+
+// ThanksText 致谢语，未配置时使用默认致谢语
+func (s *CallSurveySettings) ThanksText() string {
+	if s.Thanks != "" {
+		return s.Thanks
+	}
+	return DefaultSurveyThanks
+}
+
+// Score 将按键转为评分，无效按键返回 0
+func (s *CallSurveySettings) Score(digit string) int {
+	score, err := strconv.Atoi(digit)
+	if err != nil || score < 1 || score > s.Scale {
+		return 0
+	}
+	return score
+}
+
+// GetCallSurveySettings 获取用户的调查配置，未配置时返回 nil
+func GetCallSurveySettings(db *gorm.DB, userID uint) (*CallSurveySettings, error) {
+	var settings CallSurveySettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveCallSurveySettings 保存用户的调查配置
+func SaveCallSurveySettings(db *gorm.DB, settings *CallSurveySettings) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "enabled", "prompt", "prompt_file", "thanks", "scale", "timeout_seconds", "channels"}),
+	}).Create(settings).Error
+}
+
+// CallSurvey 一通电话的满意度调查结果
+type CallSurvey struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+	CallID      string           `json:"callId" gorm:"size:128;uniqueIndex;not null"`
+	UserID      uint             `json:"userId" gorm:"index"`                // 调查配置所属用户
+	GroupID     *uint            `json:"groupId,omitempty" gorm:"index"`     // 通话所属组织
+	AssistantID *int64           `json:"assistantId,omitempty" gorm:"index"` // AI 代接通话的助手
+	AgentUserID *uint            `json:"agentUserId,omitempty" gorm:"index"` // 坐席通话的坐席
+	Channel     string           `json:"channel" gorm:"size:16"`             // ai, agent
+	Status      CallSurveyStatus `json:"status" gorm:"size:16;index"`
+	Digit       string           `json:"digit,omitempty" gorm:"size:1"` // 对方按下的键
+	Score       int              `json:"score"`                         // 1~Scale，未评分为 0
+	Scale       int              `json:"scale"`                         // 调查时的最高分
+	PromptedAt  time.Time        `json:"promptedAt" gorm:"index"`       // 开始调查的时间
+	AnsweredAt  *time.Time       `json:"answeredAt,omitempty"`          // 按键时间
+}
+
+// TableName 指定表名
+func (CallSurvey) TableName() string {
+	return "call_surveys"
+}
+
+// SaveCallSurvey 保存调查结果，同一通话只保留一份结果
+func SaveCallSurvey(db *gorm.DB, survey *CallSurvey) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "call_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "digit", "score", "scale", "answered_at"}),
+	}).Create(survey).Error
+}
+
+// GetCallSurveyByCallID 获取通话的调查结果
+func GetCallSurveyByCallID(db *gorm.DB, callID string) (*CallSurvey, error) {
+	var survey CallSurvey
+	if err := db.Where("call_id = ?", callID).First(&survey).Error; err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// 满意度报表的分组方式
+const (
+	SatisfactionByAssistant = "assistant"
+	SatisfactionByAgent     = "agent"
+	SatisfactionByDay       = "day"
+)
+
+// SatisfactionRow 满意度报表的一行
+type SatisfactionRow struct {
+	Key          string        `json:"key"` // 助手 ID、坐席用户 ID 或日期
+	Surveys      int64         `json:"surveys"`
+	Responses    int64         `json:"responses"`
+	ResponseRate float64       `json:"responseRate"`
+	AvgScore     float64       `json:"avgScore"`     // 按 5 分制折算的平均分
+	CSAT         float64       `json:"csat"`         // 给出最高两档评分的比例
+	Distribution map[int]int64 `json:"distribution"` // 评分 -> 次数
+}
+
+// SatisfactionReport 统计用户在 [from, to) 内的调查结果，按助手、坐席或日期分组
+func SatisfactionReport(db *gorm.DB, userID uint, groupBy string, from, to time.Time) ([]SatisfactionRow, error) {
+	var keyOf func(s *CallSurvey) (string, bool)
+	switch groupBy {
+	case SatisfactionByAssistant:
+		keyOf = func(s *CallSurvey) (string, bool) {
+			if s.AssistantID == nil {
+				return "", false
+			}
+			return strconv.FormatInt(*s.AssistantID, 10), true
+		}
+	case SatisfactionByAgent:
+		keyOf = func(s *CallSurvey) (string, bool) {
+			if s.AgentUserID == nil {
+				return "", false
+			}
+			return strconv.FormatUint(uint64(*s.AgentUserID), 10), true
+		}
+	case SatisfactionByDay:
+		keyOf = func(s *CallSurvey) (string, bool) {
+			return s.PromptedAt.Format("2006-01-02"), true
+		}
+	default:
+		return nil, fmt.Errorf("unsupported groupBy: %q", groupBy)
+	}
+
+	var surveys []CallSurvey
+	err := db.Where("user_id = ? AND prompted_at >= ? AND prompted_at < ? AND status <> ?", userID, from, to, CallSurveyUnavailable).
+		Find(&surveys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]*SatisfactionRow)
+	scoreSums := make(map[string]float64)
+	for i := range surveys {
+		s := &surveys[i]
+		key, ok := keyOf(s)
+		if !ok {
+			continue
+		}
+		row, exists := rows[key]
+		if !exists {
+			row = &SatisfactionRow{Key: key, Distribution: make(map[int]int64)}
+			rows[key] = row
+		}
+		row.Surveys++
+		if s.Status != CallSurveyAnswered || s.Score == 0 || s.Scale < 2 {
+			continue
+		}
+		row.Responses++
+		row.Distribution[s.Score]++
+		// 不同时期的分制可能不同，统一折算为 5 分制
+		scoreSums[key] += 1 + float64(s.Score-1)*4/float64(s.Scale-1)
+		if s.Score >= s.Scale-1 {
+			row.CSAT++
+		}
+	}
+
+	result := make([]SatisfactionRow, 0, len(rows))
+	for key, row := range rows {
+		row.ResponseRate = float64(row.Responses) / float64(row.Surveys)
+		if row.Responses > 0 {
+			row.AvgScore = scoreSums[key] / float64(row.Responses)
+			row.CSAT /= float64(row.Responses)
+		}
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, errA := strconv.ParseInt(result[i].Key, 10, 64)
+		b, errB := strconv.ParseInt(result[j].Key, 10, 64)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
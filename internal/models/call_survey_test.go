@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallSurveySettings(t *testing.T) {
+	s := CallSurveySettings{Enabled: true, Channels: "ai"}
+	require.NoError(t, s.Validate())
+	assert.Equal(t, 5, s.Scale)
+	assert.Equal(t, 10, s.TimeoutSeconds)
+	assert.True(t, s.AppliesTo(CallSurveyChannelAI))
+	assert.False(t, s.AppliesTo(CallSurveyChannelAgent))
+	assert.Equal(t, 3, s.Score("3"))
+	assert.Equal(t, 0, s.Score("6"))
+	assert.Equal(t, 0, s.Score("#"))
+	assert.Equal(t, DefaultSurveyPrompt, s.PromptText())
+
+	assert.Error(t, (&CallSurveySettings{Scale: 12}).Validate())
+	assert.Error(t, (&CallSurveySettings{Channels: "email"}).Validate())
+	assert.False(t, (&CallSurveySettings{}).AppliesTo(CallSurveyChannelAI), "disabled settings never apply")
+
+	db := setupTestDBWithSilentLogger(t, &CallSurveySettings{})
+	require.NoError(t, SaveCallSurveySettings(db, &CallSurveySettings{UserID: 1, Enabled: true, Scale: 5, TimeoutSeconds: 10}))
+	require.NoError(t, SaveCallSurveySettings(db, &CallSurveySettings{UserID: 1, Enabled: false, Scale: 3, TimeoutSeconds: 10}))
+	saved, err := GetCallSurveySettings(db, 1)
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.False(t, saved.Enabled)
+	assert.Equal(t, 3, saved.Scale)
+
+	missing, err := GetCallSurveySettings(db, 2)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestSatisfactionReport(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallSurvey{})
+	day := time.Date(2026, 10, 14, 10, 0, 0, 0, time.Local)
+	assistantA, assistantB := int64(1), int64(2)
+	agent := uint(9)
+	surveys := []CallSurvey{
+		{CallID: "a", UserID: 1, AssistantID: &assistantA, Channel: CallSurveyChannelAI, Status: CallSurveyAnswered, Score: 5, Scale: 5, PromptedAt: day},
+		{CallID: "b", UserID: 1, AssistantID: &assistantA, Channel: CallSurveyChannelAI, Status: CallSurveyAnswered, Score: 2, Scale: 5, PromptedAt: day},
+		{CallID: "c", UserID: 1, AssistantID: &assistantA, Channel: CallSurveyChannelAI, Status: CallSurveyNoResponse, Scale: 5, PromptedAt: day.Add(24 * time.Hour)},
+		{CallID: "d", UserID: 1, AssistantID: &assistantB, Channel: CallSurveyChannelAI, Status: CallSurveyUnavailable, Scale: 5, PromptedAt: day},
+		{CallID: "e", UserID: 1, AgentUserID: &agent, Channel: CallSurveyChannelAgent, Status: CallSurveyAnswered, Score: 3, Scale: 3, PromptedAt: day},
+		{CallID: "f", UserID: 2, AssistantID: &assistantA, Channel: CallSurveyChannelAI, Status: CallSurveyAnswered, Score: 1, Scale: 5, PromptedAt: day},
+	}
+	for i := range surveys {
+		require.NoError(t, SaveCallSurvey(db, &surveys[i]))
+	}
+	// Saving again replaces the result of the call
+	require.NoError(t, SaveCallSurvey(db, &CallSurvey{CallID: "b", UserID: 1, AssistantID: &assistantA, Status: CallSurveyAnswered, Score: 4, Scale: 5, PromptedAt: day}))
+
+	from, to := day.Add(-time.Hour), day.Add(48*time.Hour)
+	rows, err := SatisfactionReport(db, 1, SatisfactionByAssistant, from, to)
+	require.NoError(t, err)
+	require.Len(t, rows, 1, "unavailable surveys are not reported")
+	assert.Equal(t, "1", rows[0].Key)
+	assert.Equal(t, int64(3), rows[0].Surveys)
+	assert.Equal(t, int64(2), rows[0].Responses)
+	assert.InDelta(t, 4.5, rows[0].AvgScore, 0.001)
+	assert.InDelta(t, 1.0, rows[0].CSAT, 0.001)
+	assert.Equal(t, int64(1), rows[0].Distribution[4])
+
+	rows, err = SatisfactionReport(db, 1, SatisfactionByAgent, from, to)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.InDelta(t, 5.0, rows[0].AvgScore, 0.001, "scores are scaled to five points")
+
+	rows, err = SatisfactionReport(db, 1, SatisfactionByDay, from, to)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "2026-10-14", rows[0].Key)
+	assert.Equal(t, int64(3), rows[0].Surveys)
+	assert.InDelta(t, 0.0, rows[1].ResponseRate, 0.001)
+
+	_, err = SatisfactionReport(db, 1, "week", from, to)
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/callerlookup"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"gorm.io/gorm"
+)
+
+// Outcome of a caller lookup
+const (
+	CallerLookupMatched = "matched" // CRM knew the caller, context injected
+	CallerLookupUnknown = "unknown" // CRM does not know the number
+	CallerLookupFailed  = "failed"  // Lookup error or timeout, the call continues without context
+)
+
+// CallerLookup CRM webhook an assistant queries with the caller number before
+// greeting inbound callers
+type CallerLookup struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt        time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID      int64     `json:"assistantId" gorm:"uniqueIndex;not null"`
+	UserID           uint      `json:"userId" gorm:"index"`
+	URL              string    `json:"url" gorm:"size:500"`
+	Secret           string    `json:"-" gorm:"size:128"`                 // HMAC signing secret, never returned
+	TimeoutMs        int       `json:"timeoutMs"`                         // 0 uses callerlookup.DefaultTimeout
+	GreetingTemplate string    `json:"greetingTemplate" gorm:"type:text"` // text/template over the CRM context, empty keeps the opening message
+	Enabled          bool      `json:"enabled"`
+	HasSecret        bool      `json:"hasSecret" gorm:"-"`
+}
+
+// TableName 指定表名
+func (CallerLookup) TableName() string {
+	return "caller_lookups"
+}
+
+func (l *CallerLookup) AfterFind(tx *gorm.DB) error {
+	l.HasSecret = l.Secret != ""
+	return nil
+}
+
+// callerLookupClient delivers CRM lookups, nil uses the public-only webhook client
+var callerLookupClient *webhook.Client
+
+// Validate checks the webhook URL resolves to a public address, the timeout and the greeting template
+func (l *CallerLookup) Validate(ctx context.Context) error {
+	if err := webhook.ValidateURL(ctx, l.URL); err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if l.TimeoutMs < 0 || time.Duration(l.TimeoutMs)*time.Millisecond > callerlookup.MaxTimeout {
+		return errors.New("timeoutMs must be between 0 and 5000")
+	}
+	if _, err := callerlookup.ParseGreeting(l.GreetingTemplate); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Config webhook settings of the lookup
+func (l *CallerLookup) Config() callerlookup.Config {
+	return callerlookup.Config{
+		URL:     l.URL,
+		Secret:  l.Secret,
+		Timeout: time.Duration(l.TimeoutMs) * time.Millisecond,
+		Client:  callerLookupClient,
+	}
+}
+
+// CallerLookupLog records what CRM data personalized a call
+type CallerLookupLog struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+	AssistantID int64     `json:"assistantId" gorm:"index"`
+	CallID      string    `json:"callId" gorm:"size:128;index"`
+	Caller      string    `json:"caller" gorm:"size:64;index"`
+	Status      string    `json:"status" gorm:"size:16"`
+	DurationMs  int64     `json:"durationMs"`
+	Fields      string    `json:"fields,omitempty" gorm:"size:512"`    // Comma separated CRM fields used
+	Context     string    `json:"context,omitempty" gorm:"type:text"`  // CRM data as returned, JSON
+	Prompt      string    `json:"prompt,omitempty" gorm:"type:text"`   // Section added to the system prompt
+	Greeting    string    `json:"greeting,omitempty" gorm:"type:text"` // Personalized greeting, empty when the opening message was kept
+	Error       string    `json:"error,omitempty" gorm:"type:text"`
+}
+
+// TableName 指定表名
+func (CallerLookupLog) TableName() string {
+	return "caller_lookup_logs"
+}
+
+// GetCallerLookup gets the caller lookup of an assistant, nil if none
+func GetCallerLookup(db *gorm.DB, assistantID int64) (*CallerLookup, error) {
+	var l CallerLookup
+	err := db.Where("assistant_id = ?", assistantID).First(&l).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// CallerEnrichment what the lookup adds to a call
+type CallerEnrichment struct {
+	Prompt   string // Appended to the system prompt
+	Greeting string // Replaces the opening message when set
+	Log      *CallerLookupLog
+}
+
+// EnrichInboundCall looks the caller up in the assistant's CRM and logs the data
+// used. Returns nil when the assistant has no enabled lookup; lookup failures are
+// logged and return an enrichment without context so the call goes on.
+func EnrichInboundCall(ctx context.Context, db *gorm.DB, assistant *Assistant, callID, caller, callee string) (*CallerEnrichment, error) {
+	if caller == "" {
+		return nil, nil
+	}
+	lookup, err := GetCallerLookup(db, assistant.ID)
+	if err != nil || lookup == nil || !lookup.Enabled {
+		return nil, err
+	}
+	enrichment := LookupCaller(ctx, lookup, assistant.ID, callID, caller, callee)
+	if err := db.Create(enrichment.Log).Error; err != nil {
+		return enrichment, err
+	}
+	return enrichment, nil
+}
+
+// LookupCaller queries the CRM and prepares the prompt and greeting, without saving the log
+func LookupCaller(ctx context.Context, lookup *CallerLookup, assistantID int64, callID, caller, callee string) *CallerEnrichment {
+	log := &CallerLookupLog{AssistantID: assistantID, CallID: callID, Caller: caller}
+	enrichment := &CallerEnrichment{Log: log}
+
+	start := time.Now()
+	found, err := callerlookup.Lookup(ctx, lookup.Config(), callerlookup.Request{
+		CallID:      callID,
+		Caller:      caller,
+		Callee:      callee,
+		AssistantID: assistantID,
+	})
+	log.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		log.Status = CallerLookupFailed
+		log.Error = err.Error()
+		return enrichment
+	}
+	if !found.Found {
+		log.Status = CallerLookupUnknown
+		return enrichment
+	}
+
+	log.Status = CallerLookupMatched
+	log.Fields = strings.Join(found.Fields(), ",")
+	if data, err := json.Marshal(found); err == nil {
+		log.Context = string(data)
+	}
+	enrichment.Prompt = found.PromptSection()
+	log.Prompt = enrichment.Prompt
+	greeting, err := callerlookup.Greeting(lookup.GreetingTemplate, found)
+	if err != nil {
+		log.Error = "greeting template: " + err.Error()
+	}
+	enrichment.Greeting = greeting
+	log.Greeting = greeting
+	return enrichment
+}
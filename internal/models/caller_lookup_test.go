@@ -0,0 +1,60 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichInboundCall(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallerLookup{}, &CallerLookupLog{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"Alice","accountTier":"gold","openTickets":[{"id":"7","subject":"Refund"}]}`))
+	}))
+	defer srv.Close()
+	// httptest listens on loopback, which the default public client refuses
+	callerLookupClient = webhook.NewClient(nil)
+	defer func() { callerLookupClient = nil }()
+
+	assistant := &Assistant{ID: 9}
+	enrichment, err := EnrichInboundCall(context.Background(), db, assistant, "c1", "+15550100", "+15550199")
+	require.NoError(t, err)
+	assert.Nil(t, enrichment, "assistants without a lookup are not enriched")
+
+	lookup := CallerLookup{AssistantID: 9, URL: srv.URL, GreetingTemplate: "Hi {{.Name}}, thanks for calling.", Enabled: true}
+	require.NoError(t, db.Create(&lookup).Error)
+
+	enrichment, err = EnrichInboundCall(context.Background(), db, assistant, "c1", "+15550100", "+15550199")
+	require.NoError(t, err)
+	require.NotNil(t, enrichment)
+	assert.Equal(t, "Hi Alice, thanks for calling.", enrichment.Greeting)
+	assert.Contains(t, enrichment.Prompt, "Alice")
+
+	var logs []CallerLookupLog
+	require.NoError(t, db.Find(&logs).Error)
+	require.Len(t, logs, 1)
+	assert.Equal(t, CallerLookupMatched, logs[0].Status)
+	assert.Equal(t, "name,accountTier,openTickets", logs[0].Fields)
+	assert.Equal(t, enrichment.Greeting, logs[0].Greeting)
+	assert.JSONEq(t, `{"found":true,"name":"Alice","accountTier":"gold","openTickets":[{"id":"7","subject":"Refund"}]}`, logs[0].Context)
+
+	// Failures are logged and the call continues without context
+	srv.Close()
+	enrichment, err = EnrichInboundCall(context.Background(), db, assistant, "c2", "+15550100", "")
+	require.NoError(t, err)
+	assert.Empty(t, enrichment.Prompt)
+	assert.Empty(t, enrichment.Greeting)
+	assert.Equal(t, CallerLookupFailed, enrichment.Log.Status)
+
+	ctx := context.Background()
+	assert.NoError(t, (&CallerLookup{URL: "https://8.8.8.8/crm", GreetingTemplate: "Hi {{.Name}}"}).Validate(ctx))
+	assert.Error(t, (&CallerLookup{URL: "crm"}).Validate(ctx))
+	assert.ErrorIs(t, (&CallerLookup{URL: srv.URL}).Validate(ctx), webhook.ErrUnsafeTarget)
+	assert.Error(t, (&CallerLookup{URL: "https://8.8.8.8/crm", TimeoutMs: 60000}).Validate(ctx))
+	assert.Error(t, (&CallerLookup{URL: "https://8.8.8.8/crm", GreetingTemplate: "{{.Name"}).Validate(ctx))
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 零停机列重命名流程：
+//  1. 模型同时声明新旧两列并双写，读取旧列（dual_write），发布后 AutoMigrate 新增新列
+//  2. 回填：把旧列的值分批复制到新列
+//  3. 校验：新旧列完全一致后才能切换
+//  4. 切换：读取新列（read_new），此时仍双写，可随时回退到 dual_write；阶段在进程启动时加载，其他实例重启后生效
+//  5. 删除旧字段的代码发布后，删除旧列（dropped）
+type ColumnRenamePhase string
+
+const (
+	ColumnRenameDualWrite ColumnRenamePhase = "dual_write" // 双写，读旧列
+	ColumnRenameReadNew   ColumnRenamePhase = "read_new"   // 双写，读新列
+	ColumnRenameDropped   ColumnRenamePhase = "dropped"    // 旧列已删除
+)
+
+// DefaultColumnRenameBatch 回填每批处理的行数
+const DefaultColumnRenameBatch = 1000
+
+var (
+	ErrUnknownColumnRename  = errors.New("unknown column rename")
+	ErrColumnRenameMismatch = errors.New("renamed columns differ, backfill and verify again")
+	ErrColumnRenamePhase    = errors.New("column rename is not in the required phase")
+)
+
+// ColumnRename 一次列重命名，Name 为唯一标识（如 knowledge.update_at）
+type ColumnRename struct {
+	Name  string
+	Table string
+	From  string // 旧列
+	To    string // 新列
+	Key   string // 分批回填使用的整数主键，默认 id
+}
+
+// ColumnRenameState 列重命名的进度
+type ColumnRenameState struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+	Name           string            `json:"name" gorm:"size:128;uniqueIndex"`
+	Phase          ColumnRenamePhase `json:"phase" gorm:"size:20"`
+	BackfilledRows int64             `json:"backfilledRows"`
+	BackfilledAt   *time.Time        `json:"backfilledAt,omitempty"`
+	VerifiedAt     *time.Time        `json:"verifiedAt,omitempty"`
+	SwitchedAt     *time.Time        `json:"switchedAt,omitempty"`
+	DroppedAt      *time.Time        `json:"droppedAt,omitempty"`
+}
+
+// TableName 指定表名
+func (ColumnRenameState) TableName() string {
+	return "column_renames"
+}
+
+var (
+	columnRenames      = map[string]*ColumnRename{}
+	columnRenamePhases sync.Map // name -> ColumnRenamePhase
+)
+
+// RegisterColumnRename 注册列重命名，返回的指针用于读写时选择列
+func RegisterColumnRename(r ColumnRename) *ColumnRename {
+	if r.Key == "" {
+		r.Key = "id"
+	}
+	columnRenames[r.Name] = &r
+	return &r
+}
+
+// GetColumnRename 按名称获取已注册的列重命名
+func GetColumnRename(name string) (*ColumnRename, error) {
+	r, ok := columnRenames[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownColumnRename, name)
+	}
+	return r, nil
+}
+
+// ColumnRenames 按名称排序返回已注册的列重命名
+func ColumnRenames() []*ColumnRename {
+	list := make([]*ColumnRename, 0, len(columnRenames))
+	for _, r := range columnRenames {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// LoadColumnRenamePhases 启动时从数据库加载各重命名所处阶段，未记录的视为双写阶段
+func LoadColumnRenamePhases(db *gorm.DB) error {
+	var states []ColumnRenameState
+	if err := db.Find(&states).Error; err != nil {
+		return err
+	}
+	for _, s := range states {
+		columnRenamePhases.Store(s.Name, s.Phase)
+	}
+	return nil
+}
+
+// Phase 当前进程使用的阶段
+func (r *ColumnRename) Phase() ColumnRenamePhase {
+	if phase, ok := columnRenamePhases.Load(r.Name); ok {
+		return phase.(ColumnRenamePhase)
+	}
+	return ColumnRenameDualWrite
+}
+
+// ReadsNew 是否应读取新列
+func (r *ColumnRename) ReadsNew() bool {
+	return r.Phase() != ColumnRenameDualWrite
+}
+
+// ReadColumn 当前应读取的列名
+func (r *ColumnRename) ReadColumn() string {
+	if r.ReadsNew() {
+		return r.To
+	}
+	return r.From
+}
+
+// Values 双写时同时更新新旧两列，旧列删除后只写新列
+func (r *ColumnRename) Values(value interface{}) map[string]interface{} {
+	if r.Phase() == ColumnRenameDropped {
+		return map[string]interface{}{r.To: value}
+	}
+	return map[string]interface{}{r.From: value, r.To: value}
+}
+
+// State 获取持久化的进度，没有记录时返回双写阶段
+func (r *ColumnRename) State(db *gorm.DB) (*ColumnRenameState, error) {
+	var state ColumnRenameState
+	err := db.Where("name = ?", r.Name).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &ColumnRenameState{Name: r.Name, Phase: ColumnRenameDualWrite}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *ColumnRename) saveState(db *gorm.DB, state *ColumnRenameState) error {
+	if err := db.Save(state).Error; err != nil {
+		return err
+	}
+	columnRenamePhases.Store(r.Name, state.Phase)
+	return nil
+}
+
+// differs 新旧列不一致的行
+func (r *ColumnRename) differs(db *gorm.DB) *gorm.DB {
+	from, to := clause.Column{Name: r.From}, clause.Column{Name: r.To}
+	return db.Table(r.Table).Where("(? IS NULL AND ? IS NOT NULL) OR ? <> ?", to, from, to, from)
+}
+
+// Backfill 按主键分批把旧列复制到新列，返回更新的行数；可重复执行
+func (r *ColumnRename) Backfill(db *gorm.DB, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultColumnRenameBatch
+	}
+	state, err := r.State(db)
+	if err != nil {
+		return 0, err
+	}
+	if state.Phase == ColumnRenameDropped {
+		return 0, ErrColumnRenamePhase
+	}
+
+	var maxKey int64
+	if err := db.Table(r.Table).Select("COALESCE(MAX(?), 0)", clause.Column{Name: r.Key}).Scan(&maxKey).Error; err != nil {
+		return 0, err
+	}
+	var total int64
+	key := clause.Column{Name: r.Key}
+	for lower := int64(0); lower < maxKey; lower += int64(batchSize) {
+		result := r.differs(db).
+			Where("? > ? AND ? <= ?", key, lower, key, lower+int64(batchSize)).
+			Update(r.To, gorm.Expr("?", clause.Column{Name: r.From}))
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+	}
+
+	now := time.Now()
+	state.BackfilledRows = total
+	state.BackfilledAt = &now
+	state.VerifiedAt = nil
+	return total, r.saveState(db, state)
+}
+
+// Verify 统计新旧列不一致的行数，全部一致时记录校验时间
+func (r *ColumnRename) Verify(db *gorm.DB) (int64, error) {
+	var mismatches int64
+	if err := r.differs(db).Count(&mismatches).Error; err != nil {
+		return 0, err
+	}
+	state, err := r.State(db)
+	if err != nil {
+		return mismatches, err
+	}
+	if mismatches > 0 {
+		state.VerifiedAt = nil
+	} else {
+		now := time.Now()
+		state.VerifiedAt = &now
+	}
+	return mismatches, r.saveState(db, state)
+}
+
+// SwitchToNew 切换为读取新列，切换前重新校验，不一致时拒绝
+func (r *ColumnRename) SwitchToNew(db *gorm.DB) error {
+	mismatches, err := r.Verify(db)
+	if err != nil {
+		return err
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%w: %d rows", ErrColumnRenameMismatch, mismatches)
+	}
+	state, err := r.State(db)
+	if err != nil {
+		return err
+	}
+	if state.Phase == ColumnRenameDropped {
+		return ErrColumnRenamePhase
+	}
+	now := time.Now()
+	state.Phase = ColumnRenameReadNew
+	state.SwitchedAt = &now
+	return r.saveState(db, state)
+}
+
+// SwitchBack 回退为读取旧列，旧列删除后不可回退
+func (r *ColumnRename) SwitchBack(db *gorm.DB) error {
+	state, err := r.State(db)
+	if err != nil {
+		return err
+	}
+	if state.Phase == ColumnRenameDropped {
+		return ErrColumnRenamePhase
+	}
+	state.Phase = ColumnRenameDualWrite
+	state.SwitchedAt = nil
+	return r.saveState(db, state)
+}
+
+// DropOldColumn 删除旧列，只允许在 read_new 阶段、且不再声明旧字段的版本发布后执行
+func (r *ColumnRename) DropOldColumn(db *gorm.DB) error {
+	state, err := r.State(db)
+	if err != nil {
+		return err
+	}
+	if state.Phase != ColumnRenameReadNew {
+		return ErrColumnRenamePhase
+	}
+	if db.Migrator().HasColumn(r.Table, r.From) {
+		// 模型已不再声明旧字段，按表名直接删除
+		if err := db.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: r.Table}, clause.Column{Name: r.From}).Error; err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	state.Phase = ColumnRenameDropped
+	state.DroppedAt = &now
+	return r.saveState(db, state)
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// renameTestRow 旧列 label 更名为 name
+type renameTestRow struct {
+	ID    uint `gorm:"primaryKey"`
+	Label string
+	Name  *string
+}
+
+func TestColumnRename_Lifecycle(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ColumnRenameState{}, &renameTestRow{})
+	r := RegisterColumnRename(ColumnRename{Name: "test.label", Table: "rename_test_rows", From: "label", To: "name"})
+	t.Cleanup(func() {
+		delete(columnRenames, r.Name)
+		columnRenamePhases.Delete(r.Name)
+	})
+
+	for _, label := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, db.Create(&renameTestRow{Label: label}).Error)
+	}
+	assert.Equal(t, ColumnRenameDualWrite, r.Phase())
+	assert.Equal(t, "label", r.ReadColumn())
+	assert.Equal(t, map[string]interface{}{"label": "x", "name": "x"}, r.Values("x"))
+
+	mismatches, err := r.Verify(db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), mismatches)
+	assert.ErrorIs(t, r.SwitchToNew(db), ErrColumnRenameMismatch)
+	assert.ErrorIs(t, r.DropOldColumn(db), ErrColumnRenamePhase)
+
+	rows, err := r.Backfill(db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), rows)
+	rows, err = r.Backfill(db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), rows)
+
+	require.NoError(t, r.SwitchToNew(db))
+	assert.Equal(t, "name", r.ReadColumn())
+	state, err := r.State(db)
+	require.NoError(t, err)
+	assert.Equal(t, ColumnRenameReadNew, state.Phase)
+	assert.NotNil(t, state.VerifiedAt)
+
+	// 阶段持久化，重启后重新加载
+	columnRenamePhases.Delete(r.Name)
+	assert.False(t, r.ReadsNew())
+	require.NoError(t, LoadColumnRenamePhases(db))
+	assert.True(t, r.ReadsNew())
+
+	require.NoError(t, r.SwitchBack(db))
+	assert.Equal(t, "label", r.ReadColumn())
+	require.NoError(t, r.SwitchToNew(db))
+
+	require.NoError(t, r.DropOldColumn(db))
+	assert.False(t, db.Migrator().HasColumn("rename_test_rows", "label"))
+	assert.Equal(t, map[string]interface{}{"name": "x"}, r.Values("x"))
+	assert.ErrorIs(t, r.SwitchBack(db), ErrColumnRenamePhase)
+
+	var names []string
+	require.NoError(t, db.Table("rename_test_rows").Order("id").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+}
+
+func TestKnowledge_UpdatedAtDualWrite(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Knowledge{}, &ColumnRenameState{})
+	t.Cleanup(func() { columnRenamePhases.Delete(KnowledgeUpdatedAtRename.Name) })
+
+	created := time.Now().Add(-time.Hour)
+	require.NoError(t, db.Create(&Knowledge{UserID: 1, KnowledgeKey: "kb", UpdateAt: created}).Error)
+
+	var k Knowledge
+	require.NoError(t, db.Where("knowledge_key = ?", "kb").First(&k).Error)
+	assert.True(t, k.UpdatedAt.Equal(k.UpdateAt))
+
+	require.NoError(t, TouchKnowledge(db, "kb"))
+	version := KnowledgeVersion(db, "kb")
+	assert.Greater(t, version, created.UnixNano())
+
+	require.NoError(t, KnowledgeUpdatedAtRename.SwitchToNew(db))
+	assert.Equal(t, version, KnowledgeVersion(db, "kb"))
+}
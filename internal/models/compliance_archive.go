@@ -0,0 +1,363 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/compliance"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"gorm.io/gorm"
+)
+
+// 合规归档的记录来源
+const (
+	ComplianceSourceRecording = "call_recording" // 设备/语音会话录音
+	ComplianceSourceSipCall   = "sip_call"       // SIP 通话
+)
+
+// 归档投递状态
+const (
+	ComplianceExportPending   = "pending"   // 等待投递或重试
+	ComplianceExportDelivered = "delivered" // 归档已返回回执
+	ComplianceExportFailed    = "failed"    // 被拒绝或重试次数用尽，需人工重试
+)
+
+const (
+	// complianceSettleDelay 通话结束后等待录音上传、转写完成再归档
+	complianceSettleDelay = 5 * time.Minute
+	// ComplianceMaxAttempts 投递失败的最大尝试次数
+	ComplianceMaxAttempts = 8
+	complianceRetryBase   = time.Minute
+	complianceRetryMax    = 6 * time.Hour
+	complianceEnqueueSize = 200
+)
+
+var (
+	ErrComplianceExportNotFailed = errors.New("only failed exports can be retried")
+	ErrComplianceSourceMissing   = errors.New("archived record no longer exists")
+)
+
+// ComplianceArchive 组织的合规归档配置：通话结束后录音和转写自动投递到
+// 一次写入（WORM）的 S3 存储桶或归档厂商 API，满足金融行业的留存要求
+type ComplianceArchive struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID       uint      `json:"groupId" gorm:"uniqueIndex;not null"`
+	Enabled       bool      `json:"enabled"`
+	Kind          string    `json:"kind" gorm:"size:8"`        // s3 或 api
+	Endpoint      string    `json:"endpoint" gorm:"size:500"`  // S3 兼容服务地址（空为 AWS），或厂商 API 地址
+	Region        string    `json:"region" gorm:"size:64"`     // S3 区域
+	Bucket        string    `json:"bucket" gorm:"size:255"`    // S3 存储桶，需开启 Object Lock
+	Prefix        string    `json:"prefix" gorm:"size:255"`    // 对象键前缀
+	AccessKey     string    `json:"accessKey" gorm:"size:128"` // S3 访问密钥 ID
+	SecretKey     string    `json:"-" gorm:"size:256"`         // S3 访问密钥，不返回
+	Token         string    `json:"-" gorm:"size:512"`         // 厂商 API 令牌，不返回
+	Secret        string    `json:"-" gorm:"size:128"`         // 厂商 API 签名密钥，不返回
+	RetentionDays int       `json:"retentionDays"`             // S3 合规模式锁定天数，0 表示不锁定
+	Since         time.Time `json:"since"`                     // 仅归档此后结束的通话
+	CreatedBy     uint      `json:"createdBy"`
+	HasSecretKey  bool      `json:"hasSecretKey" gorm:"-"`
+	HasToken      bool      `json:"hasToken" gorm:"-"`
+}
+
+func (ComplianceArchive) TableName() string {
+	return "compliance_archives"
+}
+
+func (a *ComplianceArchive) AfterFind(tx *gorm.DB) error {
+	a.HasSecretKey = a.SecretKey != ""
+	a.HasToken = a.Token != ""
+	return nil
+}
+
+// Validate 检查归档配置
+func (a *ComplianceArchive) Validate() error {
+	switch a.Kind {
+	case compliance.KindS3:
+		if a.Bucket == "" || a.Region == "" {
+			return errors.New("bucket and region are required for s3 archives")
+		}
+		if a.AccessKey == "" || a.SecretKey == "" {
+			return errors.New("accessKey and secretKey are required for s3 archives")
+		}
+		if a.Endpoint != "" && !isHTTPURL(a.Endpoint) {
+			return errors.New("endpoint must be an http(s) URL")
+		}
+	case compliance.KindAPI:
+		if !isHTTPURL(a.Endpoint) {
+			return errors.New("endpoint must be the http(s) URL of the archive API")
+		}
+	default:
+		return fmt.Errorf("kind must be %q or %q", compliance.KindS3, compliance.KindAPI)
+	}
+	if a.RetentionDays < 0 || a.RetentionDays > 36500 {
+		return errors.New("retentionDays must be between 0 and 36500")
+	}
+	return nil
+}
+
+// Sink 根据配置创建归档投递目标
+func (a *ComplianceArchive) Sink() compliance.Sink {
+	if a.Kind == compliance.KindS3 {
+		return &compliance.S3Sink{
+			Endpoint:      a.Endpoint,
+			Region:        a.Region,
+			Bucket:        a.Bucket,
+			Prefix:        a.Prefix,
+			AccessKey:     a.AccessKey,
+			SecretKey:     a.SecretKey,
+			RetentionDays: a.RetentionDays,
+		}
+	}
+	return &compliance.APISink{URL: a.Endpoint, Token: a.Token, Secret: a.Secret}
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// GetComplianceArchive 获取组织的归档配置，未配置时返回 nil
+func GetComplianceArchive(db *gorm.DB, groupID uint) (*ComplianceArchive, error) {
+	var a ComplianceArchive
+	err := db.Where("group_id = ?", groupID).First(&a).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ComplianceExport 一条记录的归档投递，保存归档返回的回执作为留存凭证
+type ComplianceExport struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt       time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID         uint       `json:"groupId" gorm:"index"`
+	Source          string     `json:"source" gorm:"size:16;uniqueIndex:idx_compliance_export_source"`
+	SourceID        uint       `json:"sourceId" gorm:"uniqueIndex:idx_compliance_export_source"`
+	Status          string     `json:"status" gorm:"size:16;index"`
+	Attempts        int        `json:"attempts"`
+	NextAttemptAt   time.Time  `json:"nextAttemptAt" gorm:"index"`
+	LastError       string     `json:"lastError,omitempty" gorm:"type:text"`
+	ReceiptID       string     `json:"receiptId,omitempty" gorm:"size:512"`       // 归档返回的回执
+	ReceiptLocation string     `json:"receiptLocation,omitempty" gorm:"size:512"` // 归档存放位置
+	Checksum        string     `json:"checksum,omitempty" gorm:"size:64"`         // 投递内容的 SHA-256
+	DeliveredAt     *time.Time `json:"deliveredAt,omitempty"`
+}
+
+func (ComplianceExport) TableName() string {
+	return "compliance_exports"
+}
+
+// RecordID 归档中的记录标识，重复投递同一记录时保持不变
+func (e *ComplianceExport) RecordID() string {
+	return fmt.Sprintf("%s-%d", e.Source, e.SourceID)
+}
+
+// EnqueueComplianceExports 为开启归档的组织登记已定稿、尚未投递的录音和 SIP 通话
+func EnqueueComplianceExports(db *gorm.DB, now time.Time) (int, error) {
+	var archives []ComplianceArchive
+	if err := db.Where("enabled = ?", true).Find(&archives).Error; err != nil {
+		return 0, err
+	}
+	settled := now.Add(-complianceSettleDelay)
+	queued := 0
+	for _, a := range archives {
+		var recordingIDs []uint
+		err := db.Table(constants.CALL_RECORDING_TABLE_NAME+" AS r").
+			Joins("JOIN assistants ON assistants.id = r.assistant_id").
+			Where("assistants.group_id = ? AND r.call_status = ? AND r.end_time >= ? AND r.updated_at <= ?",
+				a.GroupID, "completed", a.Since, settled).
+			Where("NOT EXISTS (SELECT 1 FROM compliance_exports e WHERE e.source = ? AND e.source_id = r.id)", ComplianceSourceRecording).
+			Order("r.id").Limit(complianceEnqueueSize).Pluck("r.id", &recordingIDs).Error
+		if err != nil {
+			return queued, err
+		}
+
+		var sipCallIDs []uint
+		err = db.Model(&SipCall{}).
+			Where("group_id = ? AND status = ? AND end_time >= ? AND updated_at <= ?",
+				a.GroupID, SipCallStatusEnded, a.Since, settled).
+			Where("transcription_status NOT IN ?", []string{"pending", "processing"}).
+			Where("NOT EXISTS (SELECT 1 FROM compliance_exports e WHERE e.source = ? AND e.source_id = sip_calls.id)", ComplianceSourceSipCall).
+			Order("id").Limit(complianceEnqueueSize).Pluck("id", &sipCallIDs).Error
+		if err != nil {
+			return queued, err
+		}
+
+		exports := make([]ComplianceExport, 0, len(recordingIDs)+len(sipCallIDs))
+		for _, id := range recordingIDs {
+			exports = append(exports, ComplianceExport{GroupID: a.GroupID, Source: ComplianceSourceRecording, SourceID: id, Status: ComplianceExportPending, NextAttemptAt: now})
+		}
+		for _, id := range sipCallIDs {
+			exports = append(exports, ComplianceExport{GroupID: a.GroupID, Source: ComplianceSourceSipCall, SourceID: id, Status: ComplianceExportPending, NextAttemptAt: now})
+		}
+		if len(exports) == 0 {
+			continue
+		}
+		if err := db.Create(&exports).Error; err != nil {
+			return queued, err
+		}
+		queued += len(exports)
+	}
+	return queued, nil
+}
+
+// DueComplianceExports 到期待投递的记录，组织关闭归档后暂停投递
+func DueComplianceExports(db *gorm.DB, now time.Time, limit int) ([]ComplianceExport, error) {
+	var exports []ComplianceExport
+	err := db.Where("status = ? AND next_attempt_at <= ?", ComplianceExportPending, now).
+		Where("group_id IN (SELECT group_id FROM compliance_archives WHERE enabled = ?)", true).
+		Order("next_attempt_at").Limit(limit).Find(&exports).Error
+	return exports, err
+}
+
+// ComplianceSource 待归档记录的内容，AudioLocation 为录音的 URL 或本地路径
+type ComplianceSource struct {
+	Record        *compliance.Record
+	AudioLocation string
+}
+
+// LoadComplianceSource 读取待归档记录的元数据和转写
+func LoadComplianceSource(db *gorm.DB, e *ComplianceExport) (*ComplianceSource, error) {
+	src := &ComplianceSource{Record: &compliance.Record{ID: e.RecordID()}}
+	switch e.Source {
+	case ComplianceSourceRecording:
+		var r CallRecording
+		if err := db.First(&r, e.SourceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrComplianceSourceMissing
+			}
+			return nil, err
+		}
+		src.Record.Metadata = map[string]any{
+			"source":      e.Source,
+			"recordingId": r.ID,
+			"userId":      r.UserID,
+			"assistantId": r.AssistantID,
+			"deviceId":    r.DeviceID,
+			"sessionId":   r.SessionID,
+			"callType":    r.CallType,
+			"startTime":   r.StartTime,
+			"endTime":     r.EndTime,
+			"duration":    r.Duration,
+			"audioFormat": r.AudioFormat,
+			"audioSha256": r.AudioSHA256, // 定稿时的哈希，与每日摘要链对应
+			"summary":     r.Summary,
+		}
+		src.Record.Transcript = []byte(r.ConversationDetailsJSON)
+		src.Record.AudioExt = r.AudioFormat
+		src.AudioLocation = r.StorageURL
+	case ComplianceSourceSipCall:
+		var call SipCall
+		if err := db.First(&call, e.SourceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrComplianceSourceMissing
+			}
+			return nil, err
+		}
+		src.Record.Metadata = map[string]any{
+			"source":        e.Source,
+			"sipCallId":     call.ID,
+			"callId":        call.CallID,
+			"direction":     call.Direction,
+			"from":          call.FromUsername,
+			"to":            call.ToUsername,
+			"startTime":     call.StartTime,
+			"endTime":       call.EndTime,
+			"duration":      call.Duration,
+			"consentStatus": call.ConsentStatus,
+		}
+		transcript, err := json.Marshal(map[string]any{
+			"text":   call.Transcription,
+			"status": call.TranscriptionStatus,
+		})
+		if err != nil {
+			return nil, err
+		}
+		src.Record.Transcript = transcript
+		src.Record.AudioExt = "wav"
+		src.AudioLocation = call.RecordURL
+	default:
+		return nil, fmt.Errorf("unknown compliance export source %q", e.Source)
+	}
+	return src, nil
+}
+
+// RecordComplianceDelivery 记录一次投递结果：成功保存回执；失败按指数退避重试，
+// 归档明确拒绝或次数用尽时标记为失败
+func RecordComplianceDelivery(db *gorm.DB, e *ComplianceExport, receipt *compliance.Receipt, deliveryErr error, now time.Time) error {
+	e.Attempts++
+	updates := map[string]any{"attempts": e.Attempts}
+	if deliveryErr == nil {
+		e.Status = ComplianceExportDelivered
+		e.ReceiptID, e.ReceiptLocation, e.Checksum = receipt.ID, receipt.Location, receipt.Checksum
+		e.DeliveredAt = &now
+		e.LastError = ""
+		updates["receipt_id"] = receipt.ID
+		updates["receipt_location"] = receipt.Location
+		updates["checksum"] = receipt.Checksum
+		updates["delivered_at"] = now
+	} else {
+		e.LastError = deliveryErr.Error()
+		if errors.Is(deliveryErr, compliance.ErrPermanent) || errors.Is(deliveryErr, ErrComplianceSourceMissing) ||
+			e.Attempts >= ComplianceMaxAttempts {
+			e.Status = ComplianceExportFailed
+		} else {
+			delay := complianceRetryBase << (e.Attempts - 1)
+			if delay > complianceRetryMax {
+				delay = complianceRetryMax
+			}
+			e.NextAttemptAt = now.Add(delay)
+			updates["next_attempt_at"] = e.NextAttemptAt
+		}
+	}
+	updates["status"] = e.Status
+	updates["last_error"] = e.LastError
+	return db.Model(e).Updates(updates).Error
+}
+
+// RetryComplianceExport 重新投递失败的记录
+func RetryComplianceExport(db *gorm.DB, groupID, exportID uint, now time.Time) (*ComplianceExport, error) {
+	var e ComplianceExport
+	if err := db.Where("id = ? AND group_id = ?", exportID, groupID).First(&e).Error; err != nil {
+		return nil, err
+	}
+	if e.Status != ComplianceExportFailed {
+		return nil, ErrComplianceExportNotFailed
+	}
+	e.Status, e.Attempts, e.NextAttemptAt = ComplianceExportPending, 0, now
+	err := db.Model(&e).Updates(map[string]any{
+		"status":          e.Status,
+		"attempts":        0,
+		"next_attempt_at": now,
+	}).Error
+	return &e, err
+}
+
+// ComplianceExportSummary 各状态的投递数量
+func ComplianceExportSummary(db *gorm.DB, groupID uint) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := db.Model(&ComplianceExport{}).Select("status, COUNT(*) AS count").
+		Where("group_id = ?", groupID).Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	summary := map[string]int64{
+		ComplianceExportPending:   0,
+		ComplianceExportDelivered: 0,
+		ComplianceExportFailed:    0,
+	}
+	for _, row := range rows {
+		summary[row.Status] = row.Count
+	}
+	return summary, nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/compliance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplianceArchiveValidate(t *testing.T) {
+	s3 := ComplianceArchive{Kind: compliance.KindS3, Bucket: "b", Region: "us-east-1", AccessKey: "a", SecretKey: "s", RetentionDays: 2555}
+	require.NoError(t, s3.Validate())
+	assert.IsType(t, &compliance.S3Sink{}, s3.Sink())
+
+	api := ComplianceArchive{Kind: compliance.KindAPI, Endpoint: "https://vault.example.com/ingest"}
+	require.NoError(t, api.Validate())
+	assert.IsType(t, &compliance.APISink{}, api.Sink())
+
+	assert.Error(t, (&ComplianceArchive{Kind: "ftp"}).Validate())
+	assert.Error(t, (&ComplianceArchive{Kind: compliance.KindS3, Bucket: "b", Region: "r"}).Validate())
+	assert.Error(t, (&ComplianceArchive{Kind: compliance.KindAPI, Endpoint: "vault"}).Validate())
+}
+
+func TestComplianceExportLifecycle(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ComplianceArchive{}, &ComplianceExport{}, &Assistant{}, &CallRecording{}, &SipCall{})
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now().Add(time.Hour)
+	groupID := uint(5)
+
+	archive := ComplianceArchive{GroupID: groupID, Enabled: true, Kind: compliance.KindAPI, Endpoint: "https://vault.example.com", Since: since}
+	require.NoError(t, db.Create(&archive).Error)
+
+	shared := Assistant{UserID: 1, Name: "shared", GroupID: &groupID}
+	private := Assistant{UserID: 1, Name: "private"}
+	require.NoError(t, db.Create(&shared).Error)
+	require.NoError(t, db.Create(&private).Error)
+
+	end := since.Add(time.Hour)
+	recordings := []CallRecording{
+		{UserID: 1, AssistantID: uint(shared.ID), CallStatus: "completed", EndTime: end, StorageURL: "https://cdn/a.wav", AudioFormat: "wav", ConversationDetailsJSON: `{"turns":[]}`},
+		{UserID: 1, AssistantID: uint(shared.ID), CallStatus: "completed", EndTime: since.Add(-time.Hour)}, // before the archive started
+		{UserID: 1, AssistantID: uint(shared.ID), CallStatus: "interrupted", EndTime: end},
+		{UserID: 1, AssistantID: uint(private.ID), CallStatus: "completed", EndTime: end},
+	}
+	require.NoError(t, db.Create(&recordings).Error)
+	calls := []SipCall{
+		{CallID: "c1", Status: SipCallStatusEnded, GroupID: &groupID, EndTime: &end, Transcription: "hello", TranscriptionStatus: "completed", RecordURL: "/rec/c1.wav"},
+		{CallID: "c2", Status: SipCallStatusEnded, GroupID: &groupID, EndTime: &end, TranscriptionStatus: "processing"},
+	}
+	require.NoError(t, db.Create(&calls).Error)
+
+	queued, err := EnqueueComplianceExports(db, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+	queued, err = EnqueueComplianceExports(db, now)
+	require.NoError(t, err)
+	assert.Zero(t, queued, "records are enqueued once")
+
+	due, err := DueComplianceExports(db, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+
+	src, err := LoadComplianceSource(db, &due[0])
+	require.NoError(t, err)
+	assert.Equal(t, "call_recording-1", src.Record.ID)
+	assert.Equal(t, "https://cdn/a.wav", src.AudioLocation)
+	assert.JSONEq(t, `{"turns":[]}`, string(src.Record.Transcript))
+	src, err = LoadComplianceSource(db, &due[1])
+	require.NoError(t, err)
+	assert.Equal(t, "/rec/c1.wav", src.AudioLocation)
+	assert.JSONEq(t, `{"text":"hello","status":"completed"}`, string(src.Record.Transcript))
+
+	// Transient failures back off, rejections need a manual retry
+	require.NoError(t, RecordComplianceDelivery(db, &due[0], nil, errors.New("timeout"), now))
+	assert.Equal(t, ComplianceExportPending, due[0].Status)
+	assert.Equal(t, now.Add(time.Minute), due[0].NextAttemptAt)
+	require.NoError(t, RecordComplianceDelivery(db, &due[1], nil, compliance.ErrPermanent, now))
+	assert.Equal(t, ComplianceExportFailed, due[1].Status)
+	rejected := due[1].ID
+
+	due, err = DueComplianceExports(db, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	retried, err := RetryComplianceExport(db, groupID, rejected, now)
+	require.NoError(t, err)
+	assert.Equal(t, ComplianceExportPending, retried.Status)
+	_, err = RetryComplianceExport(db, groupID, retried.ID, now)
+	assert.ErrorIs(t, err, ErrComplianceExportNotFailed)
+
+	require.NoError(t, RecordComplianceDelivery(db, retried, &compliance.Receipt{ID: "R-1", Location: "vault/1", Checksum: "abc"}, nil, now))
+	var stored ComplianceExport
+	require.NoError(t, db.First(&stored, retried.ID).Error)
+	assert.Equal(t, ComplianceExportDelivered, stored.Status)
+	assert.Equal(t, "R-1", stored.ReceiptID)
+	assert.NotNil(t, stored.DeliveredAt)
+
+	summary, err := ComplianceExportSummary(db, groupID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), summary[ComplianceExportPending])
+	assert.Equal(t, int64(1), summary[ComplianceExportDelivered])
+
+	// Disabling the archive pauses deliveries
+	require.NoError(t, db.Model(&archive).Update("enabled", false).Error)
+	due, err = DueComplianceExports(db, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/transcript"
+	"gorm.io/gorm"
+)
+
+// 导出的会话来源
+const (
+	ExportSourceCallRecording = "call_recording" // 设备通话录音
+	ExportSourceChatSession   = "chat_session"   // 聊天会话
+)
+
+// 导出任务状态
+const (
+	ConversationExportPending   = "pending"
+	ConversationExportRunning   = "running"
+	ConversationExportCompleted = "completed"
+	ConversationExportFailed    = "failed"
+	ConversationExportExpired   = "expired" // 文件已过保留期删除
+)
+
+const (
+	// ConversationExportMaxAttempts 生成失败的最大尝试次数
+	ConversationExportMaxAttempts = 3
+	// ConversationExportRetention 导出文件的保留时长，过期后删除
+	ConversationExportRetention = 7 * 24 * time.Hour
+	// conversationExportStale 运行中超过该时长的任务视为进程中断，重新领取
+	conversationExportStale = 10 * time.Minute
+
+	conversationExportLinkPurpose = "conversation-export"
+)
+
+var (
+	ErrConversationNotFound         = errors.New("conversation not found")
+	ErrInvalidConversationExportURL = errors.New("export link is invalid or expired")
+)
+
+// ConversationExport 会话导出任务：由任务队列异步生成 JSON、SRT、VTT 或 DOCX 文件，
+// 完成后通知发起人下载
+type ConversationExport struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID      uint       `json:"userId" gorm:"index"`
+	Source      string     `json:"source" gorm:"size:32"`
+	SourceID    string     `json:"sourceId" gorm:"size:128"` // 录音 ID 或聊天会话 ID
+	Format      string     `json:"format" gorm:"size:8"`
+	Status      string     `json:"status" gorm:"size:16;index"`
+	Attempts    int        `json:"attempts"`
+	Error       string     `json:"error,omitempty" gorm:"size:512"`
+	FilePath    string     `json:"-" gorm:"size:512"`
+	FileName    string     `json:"fileName,omitempty" gorm:"size:255"`
+	FileSize    int64      `json:"fileSize"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" gorm:"index"` // 文件删除时间
+}
+
+func (ConversationExport) TableName() string {
+	return "conversation_exports"
+}
+
+// CreateConversationExport 校验会话归属后创建等待生成的导出任务
+func CreateConversationExport(db *gorm.DB, userID uint, source, sourceID, format string) (*ConversationExport, error) {
+	if !transcript.ValidFormat(format) {
+		return nil, fmt.Errorf("%w %q", transcript.ErrUnknownFormat, format)
+	}
+	var count int64
+	switch source {
+	case ExportSourceCallRecording:
+		id, err := strconv.ParseUint(sourceID, 10, 64)
+		if err != nil {
+			return nil, ErrConversationNotFound
+		}
+		if err := db.Model(&CallRecording{}).Where("id = ? AND user_id = ?", id, userID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+	case ExportSourceChatSession:
+		if err := db.Model(&ChatSessionLog{}).Where("session_id = ? AND user_id = ?", sourceID, userID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+	if count == 0 {
+		return nil, ErrConversationNotFound
+	}
+	e := &ConversationExport{
+		UserID:   userID,
+		Source:   source,
+		SourceID: sourceID,
+		Format:   format,
+		Status:   ConversationExportPending,
+	}
+	return e, db.Create(e).Error
+}
+
+// ClaimConversationExports 领取等待生成的任务（包括中断的运行中任务），标记为运行中
+func ClaimConversationExports(db *gorm.DB, now time.Time, limit int) ([]ConversationExport, error) {
+	var candidates []ConversationExport
+	err := db.Where("status = ? OR (status = ? AND updated_at < ?)",
+		ConversationExportPending, ConversationExportRunning, now.Add(-conversationExportStale)).
+		Order("id").Limit(limit).Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	claimed := make([]ConversationExport, 0, len(candidates))
+	for _, e := range candidates {
+		res := db.Model(&ConversationExport{}).
+			Where("id = ? AND status = ? AND attempts = ?", e.ID, e.Status, e.Attempts).
+			Updates(map[string]any{"status": ConversationExportRunning, "attempts": e.Attempts + 1, "updated_at": now})
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		e.Status = ConversationExportRunning
+		e.Attempts++
+		claimed = append(claimed, e)
+	}
+	return claimed, nil
+}
+
+// CompleteConversationExport 记录生成的文件
+func CompleteConversationExport(db *gorm.DB, e *ConversationExport, filePath, fileName string, size int64, now time.Time) error {
+	expires := now.Add(ConversationExportRetention)
+	e.Status = ConversationExportCompleted
+	e.FilePath = filePath
+	e.FileName = fileName
+	e.FileSize = size
+	e.Error = ""
+	e.CompletedAt = &now
+	e.ExpiresAt = &expires
+	return db.Model(e).Updates(map[string]any{
+		"status":       e.Status,
+		"file_path":    filePath,
+		"file_name":    fileName,
+		"file_size":    size,
+		"error":        "",
+		"completed_at": now,
+		"expires_at":   expires,
+	}).Error
+}
+
+// FailConversationExport 记录失败，未用尽尝试次数时放回队列，返回是否最终失败
+func FailConversationExport(db *gorm.DB, e *ConversationExport, cause error) (bool, error) {
+	e.Error = truncateRunes(cause.Error(), 512)
+	e.Status = ConversationExportPending
+	if e.Attempts >= ConversationExportMaxAttempts || errors.Is(cause, ErrConversationNotFound) {
+		e.Status = ConversationExportFailed
+	}
+	err := db.Model(e).Updates(map[string]any{"status": e.Status, "error": e.Error}).Error
+	return e.Status == ConversationExportFailed, err
+}
+
+// ExpiredConversationExports 文件已过保留期的任务
+func ExpiredConversationExports(db *gorm.DB, now time.Time) ([]ConversationExport, error) {
+	var exports []ConversationExport
+	err := db.Where("status = ? AND expires_at < ?", ConversationExportCompleted, now).Find(&exports).Error
+	return exports, err
+}
+
+// ExpireConversationExport 文件删除后标记任务已过期
+func ExpireConversationExport(db *gorm.DB, e *ConversationExport) error {
+	e.Status = ConversationExportExpired
+	e.FilePath = ""
+	return db.Model(e).Updates(map[string]any{"status": e.Status, "file_path": ""}).Error
+}
+
+// ListConversationExports 用户最近的导出任务
+func ListConversationExports(db *gorm.DB, userID uint, limit int) ([]ConversationExport, error) {
+	var exports []ConversationExport
+	err := db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&exports).Error
+	return exports, err
+}
+
+// conversationExportLinkClaims 下载链接中签名的内容
+type conversationExportLinkClaims struct {
+	ExportID  uint  `json:"x"`
+	ExpiresAt int64 `json:"e"`
+}
+
+// SignConversationExportLink 生成导出文件的免登录下载令牌，随完成通知发送
+func SignConversationExportLink(secret string, e *ConversationExport) (string, error) {
+	expires := time.Now().Add(ConversationExportRetention)
+	if e.ExpiresAt != nil {
+		expires = *e.ExpiresAt
+	}
+	return signLink(secret, conversationExportLinkPurpose, conversationExportLinkClaims{ExportID: e.ID, ExpiresAt: expires.Unix()})
+}
+
+// VerifyConversationExportLink 校验令牌是否授权下载该导出文件
+func VerifyConversationExportLink(secret, token string, exportID uint, now time.Time) error {
+	var claims conversationExportLinkClaims
+	if err := verifyLink(secret, conversationExportLinkPurpose, token, &claims); err != nil {
+		return ErrInvalidConversationExportURL
+	}
+	if claims.ExportID != exportID || now.Unix() > claims.ExpiresAt {
+		return ErrInvalidConversationExportURL
+	}
+	return nil
+}
+
+// LoadConversationTranscript 读取导出任务对应的会话，时间偏移相对录音开始时刻
+func LoadConversationTranscript(db *gorm.DB, e *ConversationExport) (*transcript.Transcript, error) {
+	switch e.Source {
+	case ExportSourceCallRecording:
+		return callRecordingTranscript(db, e)
+	case ExportSourceChatSession:
+		return chatSessionTranscript(db, e)
+	}
+	return nil, fmt.Errorf("unknown source %q", e.Source)
+}
+
+func callRecordingTranscript(db *gorm.DB, e *ConversationExport) (*transcript.Transcript, error) {
+	var recording CallRecording
+	err := db.Where("id = ? AND user_id = ?", e.SourceID, e.UserID).First(&recording).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	details, err := CorrectedConversationDetails(db, &recording)
+	if err != nil {
+		return nil, err
+	}
+
+	base := recording.StartTime
+	if base.IsZero() && details != nil {
+		base = details.StartTime
+	}
+	t := &transcript.Transcript{
+		Title:     fmt.Sprintf("Call %d", recording.ID),
+		Source:    e.Source,
+		SourceID:  e.SourceID,
+		StartedAt: base,
+		Duration:  time.Duration(recording.Duration) * time.Second,
+		Summary:   recording.Summary,
+		Metadata:  map[string]string{},
+	}
+	var assistant Assistant
+	if db.Select("id", "name").First(&assistant, recording.AssistantID).Error == nil && assistant.Name != "" {
+		t.Metadata["Assistant"] = assistant.Name
+		t.Title = fmt.Sprintf("Call with %s", assistant.Name)
+	}
+	if recording.DeviceID != "" {
+		t.Metadata["Device"] = recording.DeviceID
+	}
+	if details == nil {
+		return t, nil
+	}
+	for _, turn := range details.Turns {
+		start := turn.StartTime
+		if start.IsZero() {
+			start = turn.Timestamp
+		}
+		segment := transcript.Segment{Speaker: "assistant", Text: turn.Content, Start: start.Sub(base), Corrected: turn.Corrected}
+		if turn.Type == "user" {
+			segment.Speaker = "user"
+		}
+		if !turn.EndTime.IsZero() {
+			segment.End = turn.EndTime.Sub(base)
+		}
+		t.Segments = append(t.Segments, segment)
+	}
+	return t, nil
+}
+
+// chatSessionTranscript 聊天没有录音，时间取消息记录时刻相对第一条消息的偏移
+func chatSessionTranscript(db *gorm.DB, e *ConversationExport) (*transcript.Transcript, error) {
+	var logs []ChatSessionLog
+	if err := db.Where("session_id = ? AND user_id = ?", e.SourceID, e.UserID).Order("id").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, ErrConversationNotFound
+	}
+	base := logs[0].CreatedAt
+	t := &transcript.Transcript{
+		Title:     "Chat session " + e.SourceID,
+		Source:    e.Source,
+		SourceID:  e.SourceID,
+		StartedAt: base,
+		Duration:  logs[len(logs)-1].CreatedAt.Sub(base),
+		Metadata:  map[string]string{"Type": logs[0].ChatType},
+	}
+	var assistant Assistant
+	if db.Select("id", "name").First(&assistant, logs[0].AssistantID).Error == nil && assistant.Name != "" {
+		t.Metadata["Assistant"] = assistant.Name
+		t.Title = fmt.Sprintf("Chat with %s", assistant.Name)
+	}
+	for _, log := range logs {
+		offset := log.CreatedAt.Sub(base)
+		t.Segments = append(t.Segments,
+			transcript.Segment{Speaker: "user", Text: log.UserMessage, Start: offset},
+			transcript.Segment{Speaker: "assistant", Text: log.AgentMessage, Start: offset},
+		)
+	}
+	return t, nil
+}
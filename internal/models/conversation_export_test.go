@@ -0,0 +1,145 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/transcript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateConversationExport(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ConversationExport{}, &CallRecording{}, &ChatSessionLog{})
+	recording := &CallRecording{UserID: 1, AssistantID: 1, CallStatus: "completed"}
+	require.NoError(t, db.Create(recording).Error)
+	require.NoError(t, db.Create(&ChatSessionLog{SessionID: "s1", UserID: 1, UserMessage: "hi"}).Error)
+	recordingID := strconv.FormatUint(uint64(recording.ID), 10)
+
+	e, err := CreateConversationExport(db, 1, ExportSourceCallRecording, recordingID, transcript.FormatSRT)
+	require.NoError(t, err)
+	assert.Equal(t, ConversationExportPending, e.Status)
+	_, err = CreateConversationExport(db, 1, ExportSourceChatSession, "s1", transcript.FormatJSON)
+	assert.NoError(t, err)
+
+	_, err = CreateConversationExport(db, 2, ExportSourceCallRecording, recordingID, transcript.FormatSRT)
+	assert.ErrorIs(t, err, ErrConversationNotFound, "another user's recording")
+	_, err = CreateConversationExport(db, 1, ExportSourceChatSession, "s2", transcript.FormatJSON)
+	assert.ErrorIs(t, err, ErrConversationNotFound)
+	_, err = CreateConversationExport(db, 1, ExportSourceChatSession, "s1", "pdf")
+	assert.ErrorIs(t, err, transcript.ErrUnknownFormat)
+	_, err = CreateConversationExport(db, 1, "email", "s1", transcript.FormatJSON)
+	assert.Error(t, err)
+}
+
+func TestClaimConversationExports(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ConversationExport{})
+	now := time.Now()
+	require.NoError(t, db.Create(&ConversationExport{UserID: 1, Source: ExportSourceChatSession, SourceID: "s1", Format: "json", Status: ConversationExportPending}).Error)
+	require.NoError(t, db.Create(&ConversationExport{UserID: 1, Source: ExportSourceChatSession, SourceID: "s2", Format: "json", Status: ConversationExportCompleted}).Error)
+
+	claimed, err := ClaimConversationExports(db, now, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	// A running export is only reclaimed once it is stale
+	claimed, err = ClaimConversationExports(db, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+	claimed, err = ClaimConversationExports(db, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 2, claimed[0].Attempts)
+
+	final, err := FailConversationExport(db, &claimed[0], errors.New("disk full"))
+	require.NoError(t, err)
+	assert.False(t, final)
+	claimed, err = ClaimConversationExports(db, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	final, err = FailConversationExport(db, &claimed[0], errors.New("disk full"))
+	require.NoError(t, err)
+	assert.True(t, final, "attempts exhausted")
+
+	var stored ConversationExport
+	require.NoError(t, db.First(&stored, claimed[0].ID).Error)
+	assert.Equal(t, ConversationExportFailed, stored.Status)
+	assert.Equal(t, "disk full", stored.Error)
+}
+
+func TestCompleteAndExpireConversationExport(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ConversationExport{})
+	now := time.Now()
+	e := &ConversationExport{UserID: 1, Source: ExportSourceChatSession, SourceID: "s1", Format: "vtt", Status: ConversationExportRunning}
+	require.NoError(t, db.Create(e).Error)
+	require.NoError(t, CompleteConversationExport(db, e, "/tmp/x.vtt", "chat.vtt", 10, now))
+
+	token, err := SignConversationExportLink("secret", e)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyConversationExportLink("secret", token, e.ID, now))
+	assert.ErrorIs(t, VerifyConversationExportLink("secret", token, e.ID+1, now), ErrInvalidConversationExportURL)
+	assert.ErrorIs(t, VerifyConversationExportLink("other", token, e.ID, now), ErrInvalidConversationExportURL)
+	assert.ErrorIs(t, VerifyConversationExportLink("secret", token, e.ID, now.Add(ConversationExportRetention+time.Minute)), ErrInvalidConversationExportURL)
+
+	expired, err := ExpiredConversationExports(db, now)
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+	expired, err = ExpiredConversationExports(db, now.Add(ConversationExportRetention+time.Minute))
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	require.NoError(t, ExpireConversationExport(db, &expired[0]))
+
+	exports, err := ListConversationExports(db, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, exports, 1)
+	assert.Equal(t, ConversationExportExpired, exports[0].Status)
+}
+
+func TestLoadConversationTranscript_CallRecording(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ConversationExport{}, &CallRecording{}, &Assistant{}, &TranscriptCorrection{})
+	assistant := &Assistant{UserID: 1, Name: "Helpdesk"}
+	require.NoError(t, db.Create(assistant).Error)
+
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	recording := &CallRecording{UserID: 1, AssistantID: uint(assistant.ID), StartTime: start, Duration: 30, Summary: "refund", DeviceID: "aa:bb"}
+	require.NoError(t, recording.SetConversationDetails(&ConversationDetails{Turns: []ConversationTurn{
+		{Type: "user", Content: "I want a refund", StartTime: start.Add(2 * time.Second), EndTime: start.Add(4 * time.Second)},
+		{Type: "ai", Content: "Let me check", Timestamp: start.Add(5 * time.Second)},
+	}}))
+	require.NoError(t, db.Create(recording).Error)
+
+	e := &ConversationExport{UserID: 1, Source: ExportSourceCallRecording, SourceID: strconv.FormatUint(uint64(recording.ID), 10)}
+	tr, err := LoadConversationTranscript(db, e)
+	require.NoError(t, err)
+	assert.Equal(t, "Call with Helpdesk", tr.Title)
+	assert.Equal(t, "aa:bb", tr.Metadata["Device"])
+	require.Len(t, tr.Segments, 2)
+	assert.Equal(t, "user", tr.Segments[0].Speaker)
+	assert.Equal(t, 2*time.Second, tr.Segments[0].Start)
+	assert.Equal(t, 4*time.Second, tr.Segments[0].End)
+	assert.Equal(t, "assistant", tr.Segments[1].Speaker)
+	assert.Equal(t, 5*time.Second, tr.Segments[1].Start)
+
+	var buf bytes.Buffer
+	require.NoError(t, tr.Render(&buf, transcript.FormatSRT))
+	assert.Contains(t, buf.String(), "00:00:02,000 --> 00:00:04,000\nUser: I want a refund")
+
+	_, err = LoadConversationTranscript(db, &ConversationExport{UserID: 2, Source: ExportSourceCallRecording, SourceID: e.SourceID})
+	assert.ErrorIs(t, err, ErrConversationNotFound)
+}
+
+func TestLoadConversationTranscript_ChatSession(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ChatSessionLog{}, &Assistant{})
+	require.NoError(t, db.Create(&ChatSessionLog{SessionID: "s1", UserID: 1, ChatType: "text", UserMessage: "hi", AgentMessage: "hello"}).Error)
+	require.NoError(t, db.Create(&ChatSessionLog{SessionID: "s1", UserID: 1, ChatType: "text", UserMessage: "bye", AgentMessage: "see you"}).Error)
+
+	tr, err := LoadConversationTranscript(db, &ConversationExport{UserID: 1, Source: ExportSourceChatSession, SourceID: "s1"})
+	require.NoError(t, err)
+	require.Len(t, tr.Segments, 4)
+	assert.Equal(t, "see you", tr.Segments[3].Text)
+	assert.Equal(t, "text", tr.Metadata["Type"])
+}
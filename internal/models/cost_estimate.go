@@ -0,0 +1,373 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// PriceTableDefaultKey 价格表中未匹配到模型或服务商时使用的条目
+const PriceTableDefaultKey = "default"
+
+// 成本估算的默认假设（每分钟通话）
+const (
+	costTurnsPerMinute       = 4.0   // 每分钟对话轮数
+	costSpeechRatio          = 0.5   // 助手说话时长占通话时长的比例
+	costHistoryTokensPerTurn = 300.0 // 每轮携带的上下文历史 token
+	costUserTokensPerTurn    = 40.0  // 每轮用户输入 token
+	costCompletionTokens     = 80.0  // 每轮回复 token
+	costKBChunkTokens        = 200.0 // 每个检索片段注入提示词的 token
+	costDefaultKBTopK        = 5
+	costDefaultCallSeconds   = 120.0
+)
+
+// 平均通话时长来源
+const (
+	CostSourceRequest = "request"
+	CostSourceHistory = "history"
+	CostSourceDefault = "default"
+)
+
+// LLMPrice LLM 单价（每千 token）
+type LLMPrice struct {
+	InputPer1K  float64 `json:"inputPer1k"`
+	OutputPer1K float64 `json:"outputPer1k"`
+}
+
+// PriceTable 服务商价格表，保存在系统配置 PRICE_TABLE 中
+type PriceTable struct {
+	Currency            string              `json:"currency"`
+	LLM                 map[string]LLMPrice `json:"llm"` // 按模型名，支持前缀匹配
+	ASR                 map[string]float64  `json:"asr"` // 每分钟识别音频，按服务商
+	TTS                 map[string]float64  `json:"tts"` // 每分钟合成音频，按服务商
+	KBRetrievalPerQuery float64             `json:"kbRetrievalPerQuery"`
+	TelephonyPerMinute  float64             `json:"telephonyPerMinute"`
+
+	// 每千字符合成，按服务商；计算通话成本时优先于每分钟单价
+	TTSPerKChars map[string]float64 `json:"ttsPerKChars,omitempty"`
+}
+
+// DefaultPriceTable 未配置价格表时使用的参考价格（人民币）
+func DefaultPriceTable() PriceTable {
+	return PriceTable{
+		Currency: "CNY",
+		LLM: map[string]LLMPrice{
+			PriceTableDefaultKey: {InputPer1K: 0.002, OutputPer1K: 0.008},
+			"qwen-turbo":         {InputPer1K: 0.0003, OutputPer1K: 0.0006},
+			"qwen-plus":          {InputPer1K: 0.0008, OutputPer1K: 0.002},
+			"qwen-max":           {InputPer1K: 0.0024, OutputPer1K: 0.0096},
+			"deepseek-chat":      {InputPer1K: 0.002, OutputPer1K: 0.008},
+			"gpt-4o-mini":        {InputPer1K: 0.0011, OutputPer1K: 0.0044},
+			"gpt-4o":             {InputPer1K: 0.018, OutputPer1K: 0.072},
+		},
+		ASR: map[string]float64{
+			PriceTableDefaultKey: 0.02,
+			"tencent":            0.0208,
+			"qcloud":             0.0208,
+			"xunfei":             0.02,
+			"volcengine":         0.015,
+			"aliyun":             0.0198,
+		},
+		TTS: map[string]float64{
+			PriceTableDefaultKey: 0.04,
+			"tencent":            0.04,
+			"qcloud":             0.04,
+			"xunfei":             0.035,
+			"volcengine":         0.03,
+			"aliyun":             0.035,
+		},
+		KBRetrievalPerQuery: 0.0005,
+		TelephonyPerMinute:  0.06,
+	}
+}
+
+// Validate 校验价格表
+func (p PriceTable) Validate() error {
+	if strings.TrimSpace(p.Currency) == "" {
+		return errors.New("currency is required")
+	}
+	if p.KBRetrievalPerQuery < 0 || p.TelephonyPerMinute < 0 {
+		return errors.New("prices must not be negative")
+	}
+	for name, price := range p.LLM {
+		if price.InputPer1K < 0 || price.OutputPer1K < 0 {
+			return fmt.Errorf("llm price of %s must not be negative", name)
+		}
+	}
+	for _, prices := range []map[string]float64{p.ASR, p.TTS, p.TTSPerKChars} {
+		for name, price := range prices {
+			if price < 0 {
+				return fmt.Errorf("price of %s must not be negative", name)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadPriceTable 读取系统配置中的价格表，未配置或格式错误时返回默认价格表
+func LoadPriceTable(db *gorm.DB) PriceTable {
+	raw := utils.GetValue(db, constants.KEY_PRICE_TABLE)
+	if raw == "" {
+		return DefaultPriceTable()
+	}
+	var table PriceTable
+	if err := json.Unmarshal([]byte(raw), &table); err != nil || table.Validate() != nil {
+		return DefaultPriceTable()
+	}
+	return table
+}
+
+// SavePriceTable 保存价格表
+func SavePriceTable(db *gorm.DB, table PriceTable) error {
+	if err := table.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	utils.SetValue(db, constants.KEY_PRICE_TABLE, string(data), "json", true, false)
+	return nil
+}
+
+// matchPriceKey 按名称查找价格条目：精确匹配、最长前缀匹配，最后使用 default
+func matchPriceKey(keys []string, name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, key := range keys {
+		if strings.ToLower(key) == name {
+			return key, true
+		}
+	}
+	if name != "" {
+		for _, key := range keys {
+			if key != PriceTableDefaultKey && strings.HasPrefix(name, strings.ToLower(key)) {
+				return key, true
+			}
+		}
+	}
+	for _, key := range keys {
+		if key == PriceTableDefaultKey {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// LLMPriceFor 查找模型单价，返回匹配到的条目名
+func (p PriceTable) LLMPriceFor(model string) (LLMPrice, string, bool) {
+	keys := make([]string, 0, len(p.LLM))
+	for k := range p.LLM {
+		keys = append(keys, k)
+	}
+	key, ok := matchPriceKey(keys, model)
+	return p.LLM[key], key, ok
+}
+
+// minutePriceFor 查找 ASR/TTS 每分钟单价
+func minutePriceFor(prices map[string]float64, provider string) (float64, string, bool) {
+	keys := make([]string, 0, len(prices))
+	for k := range prices {
+		keys = append(keys, k)
+	}
+	key, ok := matchPriceKey(keys, provider)
+	return prices[key], key, ok
+}
+
+// CostEstimateInput 估算所需的助手配置
+type CostEstimateInput struct {
+	AssistantID    *uint   `json:"assistantId,omitempty"`
+	LLMModel       string  `json:"llmModel"`
+	TTSProvider    string  `json:"ttsProvider"`
+	ASRProvider    string  `json:"asrProvider"`
+	SystemPrompt   string  `json:"systemPrompt"`
+	MaxTokens      int     `json:"maxTokens"`
+	KnowledgeBase  bool    `json:"knowledgeBase"`
+	KBTopK         int     `json:"kbTopK"`
+	Telephony      bool    `json:"telephony"`
+	AvgCallSeconds float64 `json:"avgCallSeconds"` // 为 0 时使用助手历史平均值
+}
+
+// CostEstimateAssumptions 估算使用的假设，历史数据可用时 token 用量按实际统计
+type CostEstimateAssumptions struct {
+	AvgCallSeconds        float64 `json:"avgCallSeconds"`
+	AvgCallSource         string  `json:"avgCallSource"`
+	HistoryCalls          int64   `json:"historyCalls"`
+	TurnsPerMinute        float64 `json:"turnsPerMinute"`
+	PromptTokensPerMinute float64 `json:"promptTokensPerMinute"`
+	OutputTokensPerMinute float64 `json:"outputTokensPerMinute"`
+	TokenSource           string  `json:"tokenSource"`
+	SpeechRatio           float64 `json:"speechRatio"`
+	KBTokensPerTurn       float64 `json:"kbTokensPerTurn,omitempty"`
+	SystemPromptTokens    float64 `json:"systemPromptTokens"`
+}
+
+// CostLineItem 单项成本（每分钟通话）
+type CostLineItem struct {
+	Component     string  `json:"component"` // llm_input, llm_output, asr, tts, kb_retrieval, telephony
+	PriceKey      string  `json:"priceKey"`  // 匹配到的价格条目
+	Unit          string  `json:"unit"`
+	Quantity      float64 `json:"quantity"` // 每分钟用量
+	UnitPrice     float64 `json:"unitPrice"`
+	CostPerMinute float64 `json:"costPerMinute"`
+}
+
+// CostEstimate 成本估算结果
+type CostEstimate struct {
+	Currency      string                  `json:"currency"`
+	CostPerMinute float64                 `json:"costPerMinute"`
+	CostPerCall   float64                 `json:"costPerCall"`
+	Items         []CostLineItem          `json:"items"`
+	Assumptions   CostEstimateAssumptions `json:"assumptions"`
+	Warnings      []string                `json:"warnings,omitempty"`
+}
+
+// assistantUsageHistory 助手的历史通话和 LLM 用量
+type assistantUsageHistory struct {
+	CallSeconds      int64
+	CallCount        int64
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+func getAssistantUsageHistory(db *gorm.DB, assistantID uint) (assistantUsageHistory, error) {
+	var h assistantUsageHistory
+	err := db.Model(&UsageRecord{}).
+		Select("COALESCE(SUM(CASE WHEN usage_type = ? THEN call_duration ELSE 0 END), 0) AS call_seconds, "+
+			"COALESCE(SUM(CASE WHEN usage_type = ? THEN call_count ELSE 0 END), 0) AS call_count, "+
+			"COALESCE(SUM(CASE WHEN usage_type = ? THEN prompt_tokens ELSE 0 END), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(CASE WHEN usage_type = ? THEN completion_tokens ELSE 0 END), 0) AS completion_tokens",
+			UsageTypeCall, UsageTypeCall, UsageTypeLLM, UsageTypeLLM).
+		Where("assistant_id = ?", assistantID).
+		Scan(&h).Error
+	return h, err
+}
+
+// estimateTokens 粗略估算文本 token 数：ASCII 约 4 字符一个 token，其他字符约 1 个
+func estimateTokens(text string) float64 {
+	var ascii, other float64
+	for _, r := range text {
+		if r < 128 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return math.Ceil(ascii/4 + other)
+}
+
+func roundCost(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// EstimateAssistantCost 按价格表和助手配置估算每分钟及每通通话成本。
+// 指定 AssistantID 时使用该助手的历史平均通话时长和实际 token 用量
+func EstimateAssistantCost(db *gorm.DB, table PriceTable, in CostEstimateInput) (*CostEstimate, error) {
+	est := &CostEstimate{Currency: table.Currency}
+	a := CostEstimateAssumptions{
+		TurnsPerMinute:     costTurnsPerMinute,
+		SpeechRatio:        costSpeechRatio,
+		SystemPromptTokens: estimateTokens(in.SystemPrompt),
+		AvgCallSeconds:     costDefaultCallSeconds,
+		AvgCallSource:      CostSourceDefault,
+		TokenSource:        CostSourceDefault,
+	}
+
+	// 每轮 token：系统提示词 + 历史 + 用户输入 + 知识库片段
+	completion := costCompletionTokens
+	if in.MaxTokens > 0 && float64(in.MaxTokens) < completion {
+		completion = float64(in.MaxTokens)
+	}
+	promptPerTurn := a.SystemPromptTokens + costHistoryTokensPerTurn + costUserTokensPerTurn
+	if in.KnowledgeBase {
+		topK := in.KBTopK
+		if topK <= 0 {
+			topK = costDefaultKBTopK
+		}
+		a.KBTokensPerTurn = float64(topK) * costKBChunkTokens
+		promptPerTurn += a.KBTokensPerTurn
+	}
+	a.PromptTokensPerMinute = promptPerTurn * a.TurnsPerMinute
+	a.OutputTokensPerMinute = completion * a.TurnsPerMinute
+
+	if in.AssistantID != nil {
+		history, err := getAssistantUsageHistory(db, *in.AssistantID)
+		if err != nil {
+			return nil, err
+		}
+		a.HistoryCalls = history.CallCount
+		if history.CallCount > 0 && history.CallSeconds > 0 {
+			a.AvgCallSeconds = float64(history.CallSeconds) / float64(history.CallCount)
+			a.AvgCallSource = CostSourceHistory
+			if history.PromptTokens+history.CompletionTokens > 0 {
+				minutes := float64(history.CallSeconds) / 60
+				a.PromptTokensPerMinute = float64(history.PromptTokens) / minutes
+				a.OutputTokensPerMinute = float64(history.CompletionTokens) / minutes
+				a.TokenSource = CostSourceHistory
+			}
+		}
+	}
+	if in.AvgCallSeconds > 0 {
+		a.AvgCallSeconds = in.AvgCallSeconds
+		a.AvgCallSource = CostSourceRequest
+	}
+
+	add := func(component, key, unit string, quantity, unitPrice float64) {
+		item := CostLineItem{
+			Component:     component,
+			PriceKey:      key,
+			Unit:          unit,
+			Quantity:      roundCost(quantity),
+			UnitPrice:     unitPrice,
+			CostPerMinute: roundCost(quantity * unitPrice),
+		}
+		est.Items = append(est.Items, item)
+		est.CostPerMinute += quantity * unitPrice
+	}
+	warnUnpriced := func(kind, name, key string) {
+		if key == PriceTableDefaultKey && name != "" {
+			est.Warnings = append(est.Warnings, fmt.Sprintf("no %s price for %q, the default price is used", kind, name))
+		}
+	}
+
+	if price, key, ok := table.LLMPriceFor(in.LLMModel); ok {
+		warnUnpriced("llm", in.LLMModel, key)
+		add("llm_input", key, "1k tokens", a.PromptTokensPerMinute/1000, price.InputPer1K)
+		add("llm_output", key, "1k tokens", a.OutputTokensPerMinute/1000, price.OutputPer1K)
+	} else {
+		est.Warnings = append(est.Warnings, "the price table has no llm prices")
+	}
+	if price, key, ok := minutePriceFor(table.ASR, in.ASRProvider); ok {
+		warnUnpriced("asr", in.ASRProvider, key)
+		add("asr", key, "minute", 1, price)
+	} else {
+		est.Warnings = append(est.Warnings, "the price table has no asr prices")
+	}
+	if price, key, ok := minutePriceFor(table.TTS, in.TTSProvider); ok {
+		warnUnpriced("tts", in.TTSProvider, key)
+		add("tts", key, "minute", a.SpeechRatio, price)
+	} else {
+		est.Warnings = append(est.Warnings, "the price table has no tts prices")
+	}
+	if in.KnowledgeBase {
+		add("kb_retrieval", "kbRetrievalPerQuery", "query", a.TurnsPerMinute, table.KBRetrievalPerQuery)
+	}
+	if in.Telephony {
+		add("telephony", "telephonyPerMinute", "minute", 1, table.TelephonyPerMinute)
+	}
+
+	a.PromptTokensPerMinute = math.Round(a.PromptTokensPerMinute)
+	a.OutputTokensPerMinute = math.Round(a.OutputTokensPerMinute)
+	a.AvgCallSeconds = math.Round(a.AvgCallSeconds*10) / 10
+	est.Assumptions = a
+	est.CostPerCall = roundCost(est.CostPerMinute * a.AvgCallSeconds / 60)
+	est.CostPerMinute = roundCost(est.CostPerMinute)
+	return est, nil
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPriceTable() PriceTable {
+	return PriceTable{
+		Currency: "CNY",
+		LLM: map[string]LLMPrice{
+			PriceTableDefaultKey: {InputPer1K: 1, OutputPer1K: 2},
+			"gpt-4o":             {InputPer1K: 10, OutputPer1K: 20},
+			"gpt-4o-mini":        {InputPer1K: 0.1, OutputPer1K: 0.2},
+		},
+		ASR:                 map[string]float64{PriceTableDefaultKey: 0.5, "xunfei": 0.2},
+		TTS:                 map[string]float64{PriceTableDefaultKey: 1, "volcengine": 0.4},
+		KBRetrievalPerQuery: 0.01,
+		TelephonyPerMinute:  0.3,
+	}
+}
+
+func TestPriceTableLookup(t *testing.T) {
+	table := testPriceTable()
+
+	price, key, ok := table.LLMPriceFor("gpt-4o-mini-2024-07-18")
+	require.True(t, ok)
+	assert.Equal(t, "gpt-4o-mini", key)
+	assert.Equal(t, 0.1, price.InputPer1K)
+
+	_, key, _ = table.LLMPriceFor("GPT-4o")
+	assert.Equal(t, "gpt-4o", key)
+	_, key, _ = table.LLMPriceFor("unknown-model")
+	assert.Equal(t, PriceTableDefaultKey, key)
+
+	_, _, ok = PriceTable{Currency: "CNY"}.LLMPriceFor("gpt-4o")
+	assert.False(t, ok)
+
+	assert.Error(t, PriceTable{}.Validate())
+	assert.Error(t, PriceTable{Currency: "CNY", TTS: map[string]float64{"x": -1}}.Validate())
+	assert.NoError(t, DefaultPriceTable().Validate())
+}
+
+func TestPriceTableStorage(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &utils.Config{})
+
+	assert.Equal(t, DefaultPriceTable().Currency, LoadPriceTable(db).Currency)
+
+	table := testPriceTable()
+	table.Currency = "USD"
+	require.NoError(t, SavePriceTable(db, table))
+	loaded := LoadPriceTable(db)
+	assert.Equal(t, "USD", loaded.Currency)
+	assert.Equal(t, 0.3, loaded.TelephonyPerMinute)
+
+	assert.Error(t, SavePriceTable(db, PriceTable{}))
+}
+
+func TestEstimateAssistantCost(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &UsageRecord{})
+	table := testPriceTable()
+
+	est, err := EstimateAssistantCost(db, table, CostEstimateInput{
+		LLMModel:    "gpt-4o-mini",
+		ASRProvider: "xunfei",
+		TTSProvider: "volcengine",
+		Telephony:   true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, CostSourceDefault, est.Assumptions.AvgCallSource)
+	assert.Len(t, est.Items, 5)
+	assert.Empty(t, est.Warnings)
+
+	var sum float64
+	for _, item := range est.Items {
+		sum += item.CostPerMinute
+	}
+	assert.InDelta(t, sum, est.CostPerMinute, 1e-5)
+	assert.InDelta(t, est.CostPerMinute*2, est.CostPerCall, 1e-5)
+
+	// A knowledge base adds retrieval queries and retrieved chunks to the prompt
+	withKB, err := EstimateAssistantCost(db, table, CostEstimateInput{
+		LLMModel:      "gpt-4o-mini",
+		ASRProvider:   "xunfei",
+		TTSProvider:   "volcengine",
+		Telephony:     true,
+		KnowledgeBase: true,
+		KBTopK:        3,
+	})
+	require.NoError(t, err)
+	assert.Len(t, withKB.Items, 6)
+	assert.Equal(t, 600.0, withKB.Assumptions.KBTokensPerTurn)
+	assert.Greater(t, withKB.CostPerMinute, est.CostPerMinute)
+
+	unknown, err := EstimateAssistantCost(db, table, CostEstimateInput{LLMModel: "mystery", TTSProvider: "other"})
+	require.NoError(t, err)
+	assert.Len(t, unknown.Warnings, 2)
+}
+
+func TestEstimateAssistantCostHistory(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &UsageRecord{})
+	assistantID := uint(7)
+
+	require.NoError(t, RecordCallUsage(db, 1, 1, &assistantID, nil, "s1", nil, 60))
+	require.NoError(t, RecordCallUsage(db, 1, 1, &assistantID, nil, "s2", nil, 180))
+	require.NoError(t, RecordLLMUsage(db, 1, 1, &assistantID, nil, "s1", "gpt-4o", 4000, 400, 4400))
+
+	est, err := EstimateAssistantCost(db, testPriceTable(), CostEstimateInput{AssistantID: &assistantID, LLMModel: "gpt-4o"})
+	require.NoError(t, err)
+	assert.Equal(t, CostSourceHistory, est.Assumptions.AvgCallSource)
+	assert.Equal(t, int64(2), est.Assumptions.HistoryCalls)
+	assert.Equal(t, 120.0, est.Assumptions.AvgCallSeconds)
+	assert.Equal(t, CostSourceHistory, est.Assumptions.TokenSource)
+	assert.Equal(t, 1000.0, est.Assumptions.PromptTokensPerMinute)
+	assert.Equal(t, 100.0, est.Assumptions.OutputTokensPerMinute)
+
+	// An explicit call length overrides history
+	est, err = EstimateAssistantCost(db, testPriceTable(), CostEstimateInput{AssistantID: &assistantID, AvgCallSeconds: 30})
+	require.NoError(t, err)
+	assert.Equal(t, CostSourceRequest, est.Assumptions.AvgCallSource)
+	assert.InDelta(t, est.CostPerMinute/2, est.CostPerCall, 1e-5)
+}
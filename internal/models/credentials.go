@@ -9,6 +9,7 @@ import (
 
 	"github.com/code-100-precent/LingEcho/pkg/constants"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -262,6 +263,19 @@ func GetUserCredentialByApiSecretAndApiKey(db *gorm.DB, apiKey, apiSecret string
 	return &credential, nil
 }
 
+// SetCurrentCredential 记录当前请求使用的 API 凭证，用于按 API Key 统计用量
+func SetCurrentCredential(c *gin.Context, credential *UserCredential) {
+	c.Set(constants.CredentialField, credential)
+}
+
+// CurrentCredential 当前请求使用的 API 凭证，非 API Key 认证的请求返回 nil
+func CurrentCredential(c *gin.Context) *UserCredential {
+	if obj, exists := c.Get(constants.CredentialField); exists && obj != nil {
+		return obj.(*UserCredential)
+	}
+	return nil
+}
+
 // CheckAndReserveCredits 原子性校验并预占额度（可选）。need 为需要的额度。
 func CheckAndReserveCredits(db *gorm.DB, credentialID uint, need int64) (*UserCredential, error) {
 	var cred UserCredential
@@ -0,0 +1,372 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 自定义域名状态
+const (
+	CustomDomainPending  = "pending"
+	CustomDomainVerified = "verified"
+	CustomDomainFailed   = "failed"
+)
+
+// 证书状态
+const (
+	CustomDomainCertNone    = "none"
+	CustomDomainCertPending = "pending"
+	CustomDomainCertIssued  = "issued"
+	CustomDomainCertFailed  = "failed"
+)
+
+const (
+	// customDomainCacheTTL 按 Host 解析域名的缓存时间，未命中也会缓存，避免每个请求查库
+	customDomainCacheTTL = time.Minute
+	customDomainField    = "_lingecho_custom_domain"
+)
+
+var (
+	ErrInvalidCustomDomain = errors.New("invalid domain name")
+	ErrCustomDomainTaken   = errors.New("domain is already registered")
+	// ErrCustomDomainCNAME CNAME 未指向平台
+	ErrCustomDomainCNAME = errors.New("domain CNAME does not point to the platform")
+)
+
+// CustomDomain 组织的自定义域名，CNAME 验证通过后控制台和 API 可通过该域名访问，
+// 并按域名使用组织的品牌信息和发件人
+type CustomDomain struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID       uint       `json:"groupId" gorm:"index"`
+	Domain        string     `json:"domain" gorm:"size:253;uniqueIndex"`
+	Status        string     `json:"status" gorm:"size:16;index"`
+	VerifiedAt    *time.Time `json:"verifiedAt,omitempty"`
+	LastCheckedAt *time.Time `json:"lastCheckedAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty" gorm:"size:512"`
+	CertStatus    string     `json:"certStatus" gorm:"size:16"`
+	CertExpiresAt *time.Time `json:"certExpiresAt,omitempty"`
+	CertError     string     `json:"certError,omitempty" gorm:"size:512"`
+
+	// 品牌信息，为空时使用站点默认值
+	SiteName   string `json:"siteName" gorm:"size:128"`
+	LogoURL    string `json:"logoUrl" gorm:"size:512"`
+	FaviconURL string `json:"faviconUrl" gorm:"size:512"`
+	TermsURL   string `json:"termsUrl" gorm:"size:512"`
+	PrivacyURL string `json:"privacyUrl" gorm:"size:512"`
+
+	// 发件人，为空时使用全局邮件配置
+	MailFrom     string `json:"mailFrom" gorm:"size:255"`
+	MailFromName string `json:"mailFromName" gorm:"size:128"`
+}
+
+func (CustomDomain) TableName() string {
+	return "custom_domains"
+}
+
+// CustomDomainBranding 可修改的品牌和发件人配置
+type CustomDomainBranding struct {
+	SiteName     string `json:"siteName"`
+	LogoURL      string `json:"logoUrl"`
+	FaviconURL   string `json:"faviconUrl"`
+	TermsURL     string `json:"termsUrl"`
+	PrivacyURL   string `json:"privacyUrl"`
+	MailFrom     string `json:"mailFrom"`
+	MailFromName string `json:"mailFromName"`
+}
+
+// Validate 校验发件人地址，发件人域名必须是该自定义域名或其子域名
+func (b CustomDomainBranding) Validate(domain string) error {
+	if b.MailFrom == "" {
+		return nil
+	}
+	addr, err := mail.ParseAddress(b.MailFrom)
+	if err != nil || addr.Name != "" {
+		return fmt.Errorf("invalid mail sender address %q", b.MailFrom)
+	}
+	at := strings.LastIndex(addr.Address, "@")
+	senderDomain := strings.ToLower(addr.Address[at+1:])
+	if senderDomain != domain && !strings.HasSuffix(senderDomain, "."+domain) {
+		return fmt.Errorf("mail sender must use %s or one of its subdomains", domain)
+	}
+	return nil
+}
+
+// NormalizeCustomDomain 规范化域名：去掉协议、端口和末尾的点并转为小写
+func NormalizeCustomDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(domain, "://"); i >= 0 {
+		domain = domain[i+3:]
+	}
+	if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+		domain = domain[:i]
+	}
+	if host, _, err := net.SplitHostPort(domain); err == nil {
+		domain = host
+	}
+	domain = strings.TrimSuffix(domain, ".")
+	if len(domain) == 0 || len(domain) > 253 || net.ParseIP(domain) != nil {
+		return "", ErrInvalidCustomDomain
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", ErrInvalidCustomDomain
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", ErrInvalidCustomDomain
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return "", ErrInvalidCustomDomain
+			}
+		}
+	}
+	return domain, nil
+}
+
+// CreateCustomDomain 注册自定义域名，等待 CNAME 验证
+func CreateCustomDomain(db *gorm.DB, groupID uint, domain string) (*CustomDomain, error) {
+	domain, err := NormalizeCustomDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	if err := db.Model(&CustomDomain{}).Where("domain = ?", domain).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrCustomDomainTaken
+	}
+	d := &CustomDomain{
+		GroupID:    groupID,
+		Domain:     domain,
+		Status:     CustomDomainPending,
+		CertStatus: CustomDomainCertNone,
+	}
+	if err := db.Create(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetCustomDomains 获取组织的自定义域名
+func GetCustomDomains(db *gorm.DB, groupID uint) ([]CustomDomain, error) {
+	var domains []CustomDomain
+	err := db.Where("group_id = ?", groupID).Order("id ASC").Find(&domains).Error
+	return domains, err
+}
+
+// GetCustomDomain 获取组织的某个自定义域名
+func GetCustomDomain(db *gorm.DB, groupID, id uint) (*CustomDomain, error) {
+	var d CustomDomain
+	if err := db.Where("id = ? AND group_id = ?", id, groupID).First(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DeleteCustomDomain 删除自定义域名
+func DeleteCustomDomain(db *gorm.DB, d *CustomDomain) error {
+	if err := db.Delete(d).Error; err != nil {
+		return err
+	}
+	InvalidateCustomDomainCache(d.Domain)
+	return nil
+}
+
+// UpdateCustomDomainBranding 更新品牌和发件人配置
+func UpdateCustomDomainBranding(db *gorm.DB, d *CustomDomain, b CustomDomainBranding) error {
+	if err := b.Validate(d.Domain); err != nil {
+		return err
+	}
+	d.SiteName = b.SiteName
+	d.LogoURL = b.LogoURL
+	d.FaviconURL = b.FaviconURL
+	d.TermsURL = b.TermsURL
+	d.PrivacyURL = b.PrivacyURL
+	d.MailFrom = b.MailFrom
+	d.MailFromName = b.MailFromName
+	if err := db.Model(d).Select("site_name", "logo_url", "favicon_url", "terms_url", "privacy_url", "mail_from", "mail_from_name").
+		Updates(d).Error; err != nil {
+		return err
+	}
+	InvalidateCustomDomainCache(d.Domain)
+	return nil
+}
+
+// VerifyCustomDomainCNAME 检查域名的 CNAME 是否指向 target 并更新状态，lookup 为空时使用 net.LookupCNAME。
+// 返回 true 表示本次从未验证变为已验证
+func VerifyCustomDomainCNAME(db *gorm.DB, d *CustomDomain, target string, lookup func(string) (string, error)) (bool, error) {
+	if lookup == nil {
+		lookup = net.LookupCNAME
+	}
+	target = strings.TrimSuffix(strings.ToLower(target), ".")
+
+	var checkErr error
+	cname, err := lookup(d.Domain)
+	if err != nil {
+		checkErr = fmt.Errorf("lookup CNAME: %w", err)
+	} else if strings.TrimSuffix(strings.ToLower(cname), ".") != target {
+		checkErr = fmt.Errorf("%w: %s resolves to %s, expected %s", ErrCustomDomainCNAME, d.Domain, strings.TrimSuffix(cname, "."), target)
+	}
+
+	now := time.Now()
+	wasVerified := d.Status == CustomDomainVerified
+	d.LastCheckedAt = &now
+	if checkErr != nil {
+		d.Status = CustomDomainFailed
+		d.LastError = checkErr.Error()
+	} else {
+		d.Status = CustomDomainVerified
+		d.LastError = ""
+		if d.VerifiedAt == nil {
+			d.VerifiedAt = &now
+		}
+	}
+	if err := db.Model(d).Select("status", "verified_at", "last_checked_at", "last_error").Updates(d).Error; err != nil {
+		return false, err
+	}
+	InvalidateCustomDomainCache(d.Domain)
+	return !wasVerified && d.Status == CustomDomainVerified, checkErr
+}
+
+// UpdateCustomDomainCert 记录证书签发结果
+func UpdateCustomDomainCert(db *gorm.DB, domain string, expiresAt *time.Time, certErr error) error {
+	updates := map[string]interface{}{
+		"cert_status":     CustomDomainCertIssued,
+		"cert_expires_at": expiresAt,
+		"cert_error":      "",
+	}
+	if certErr != nil {
+		updates = map[string]interface{}{
+			"cert_status": CustomDomainCertFailed,
+			"cert_error":  certErr.Error(),
+		}
+	}
+	return db.Model(&CustomDomain{}).Where("domain = ?", domain).Updates(updates).Error
+}
+
+// IsVerifiedCustomDomain 域名是否已验证，用于证书签发的 HostPolicy
+func IsVerifiedCustomDomain(db *gorm.DB, host string) bool {
+	d, _ := LookupCustomDomain(db, host)
+	return d != nil
+}
+
+type customDomainCacheEntry struct {
+	domain  *CustomDomain
+	expires time.Time
+}
+
+var customDomainCache sync.Map // host -> customDomainCacheEntry
+
+// InvalidateCustomDomainCache 清除域名缓存
+func InvalidateCustomDomainCache(domain string) {
+	customDomainCache.Delete(domain)
+}
+
+// LookupCustomDomain 按请求 Host 查找已验证的自定义域名，不是自定义域名时返回 nil
+func LookupCustomDomain(db *gorm.DB, host string) (*CustomDomain, error) {
+	host, err := NormalizeCustomDomain(host)
+	if err != nil {
+		return nil, nil
+	}
+	if v, ok := customDomainCache.Load(host); ok {
+		entry := v.(customDomainCacheEntry)
+		if time.Now().Before(entry.expires) {
+			return entry.domain, nil
+		}
+	}
+	var d CustomDomain
+	err = db.Where("domain = ? AND status = ?", host, CustomDomainVerified).First(&d).Error
+	var found *CustomDomain
+	if err == nil {
+		found = &d
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	customDomainCache.Store(host, customDomainCacheEntry{domain: found, expires: time.Now().Add(customDomainCacheTTL)})
+	return found, nil
+}
+
+// Branding 覆盖到页面上下文 Site 中的品牌字段，只包含已配置的值
+func (d *CustomDomain) Branding() map[string]any {
+	branding := map[string]any{"Url": "https://" + d.Domain}
+	set := func(key, value string) {
+		if value != "" {
+			branding[key] = value
+		}
+	}
+	set("Name", d.SiteName)
+	set("LogoUrl", d.LogoURL)
+	set("FaviconUrl", d.FaviconURL)
+	set("TermsUrl", d.TermsURL)
+	set("PrivacyUrl", d.PrivacyURL)
+	return branding
+}
+
+// MailConfig 使用域名的发件人覆盖全局邮件配置
+func (d *CustomDomain) MailConfig(base notification.MailConfig) notification.MailConfig {
+	if d == nil || d.MailFrom == "" {
+		return base
+	}
+	base.From = d.MailFrom
+	if d.MailFromName != "" {
+		base.FromName = d.MailFromName
+	}
+	return base
+}
+
+// WithCustomDomain 按 Host 解析自定义域名，写入上下文供品牌渲染和发件人选择使用
+func WithCustomDomain(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d, err := LookupCustomDomain(db, c.Request.Host); err == nil && d != nil {
+			c.Set(constants.DomainField, d.Branding())
+			c.Set(customDomainField, d)
+		}
+		c.Next()
+	}
+}
+
+// CurrentCustomDomain 当前请求的自定义域名，不是通过自定义域名访问时返回 nil
+func CurrentCustomDomain(c *gin.Context) *CustomDomain {
+	if v, ok := c.Get(customDomainField); ok {
+		if d, ok := v.(*CustomDomain); ok {
+			return d
+		}
+	}
+	return nil
+}
+
+// RequestMailConfig 按当前请求的域名选择发件人
+func RequestMailConfig(c *gin.Context, base notification.MailConfig) notification.MailConfig {
+	return CurrentCustomDomain(c).MailConfig(base)
+}
+
+// UserMailConfig 按用户所属组织的已验证域名选择发件人，用于没有请求上下文的邮件
+func UserMailConfig(db *gorm.DB, userID uint, base notification.MailConfig) notification.MailConfig {
+	if db == nil || userID == 0 {
+		return base
+	}
+	var d CustomDomain
+	err := db.Where("status = ? AND mail_from <> ''", CustomDomainVerified).
+		Where("group_id IN (?) OR group_id IN (?)",
+			db.Model(&GroupMember{}).Select("group_id").Where("user_id = ?", userID),
+			db.Model(&Group{}).Select("id").Where("creator_id = ?", userID)).
+		Order("id ASC").First(&d).Error
+	if err != nil {
+		return base
+	}
+	return d.MailConfig(base)
+}
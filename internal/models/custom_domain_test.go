@@ -0,0 +1,137 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeCustomDomain(t *testing.T) {
+	for input, want := range map[string]string{
+		"Voice.Example.com":               "voice.example.com",
+		"https://voice.example.com/login": "voice.example.com",
+		"voice.example.com:8443":          "voice.example.com",
+		"voice.example.com.":              "voice.example.com",
+	} {
+		got, err := NormalizeCustomDomain(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got)
+	}
+	for _, input := range []string{"", "localhost", "127.0.0.1", "-bad.example.com", "bad_label.example.com"} {
+		_, err := NormalizeCustomDomain(input)
+		assert.ErrorIs(t, err, ErrInvalidCustomDomain, input)
+	}
+}
+
+func TestCustomDomainVerification(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CustomDomain{})
+
+	d, err := CreateCustomDomain(db, 1, "Voice.Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, CustomDomainPending, d.Status)
+	_, err = CreateCustomDomain(db, 2, "voice.example.com")
+	assert.ErrorIs(t, err, ErrCustomDomainTaken)
+
+	// Unverified domains are not served
+	found, err := LookupCustomDomain(db, "voice.example.com")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	wrong := func(string) (string, error) { return "other.example.net.", nil }
+	verified, err := VerifyCustomDomainCNAME(db, d, "edge.lingecho.com", wrong)
+	assert.ErrorIs(t, err, ErrCustomDomainCNAME)
+	assert.False(t, verified)
+	assert.Equal(t, CustomDomainFailed, d.Status)
+
+	failing := func(string) (string, error) { return "", errors.New("no such host") }
+	_, err = VerifyCustomDomainCNAME(db, d, "edge.lingecho.com", failing)
+	assert.Error(t, err)
+	assert.NotEmpty(t, d.LastError)
+
+	right := func(string) (string, error) { return "EDGE.lingecho.com.", nil }
+	verified, err = VerifyCustomDomainCNAME(db, d, "edge.lingecho.com.", right)
+	require.NoError(t, err)
+	assert.True(t, verified)
+	require.NotNil(t, d.VerifiedAt)
+
+	// Verifying again does not report a new verification
+	verified, err = VerifyCustomDomainCNAME(db, d, "edge.lingecho.com", right)
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	found, err = LookupCustomDomain(db, "VOICE.example.com:443")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.True(t, IsVerifiedCustomDomain(db, "voice.example.com"))
+
+	require.NoError(t, DeleteCustomDomain(db, d))
+	assert.False(t, IsVerifiedCustomDomain(db, "voice.example.com"))
+}
+
+func TestCustomDomainBranding(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CustomDomain{})
+	d, err := CreateCustomDomain(db, 1, "voice.example.com")
+	require.NoError(t, err)
+
+	err = UpdateCustomDomainBranding(db, d, CustomDomainBranding{MailFrom: "noreply@example.net"})
+	assert.Error(t, err)
+	err = UpdateCustomDomainBranding(db, d, CustomDomainBranding{MailFrom: "Acme <noreply@voice.example.com>"})
+	assert.Error(t, err)
+
+	require.NoError(t, UpdateCustomDomainBranding(db, d, CustomDomainBranding{
+		SiteName:     "Acme Voice",
+		LogoURL:      "https://cdn.example.com/logo.png",
+		MailFrom:     "noreply@mail.voice.example.com",
+		MailFromName: "Acme",
+	}))
+	_, err = VerifyCustomDomainCNAME(db, d, "edge", func(string) (string, error) { return "edge", nil })
+	require.NoError(t, err)
+
+	branding := d.Branding()
+	assert.Equal(t, "https://voice.example.com", branding["Url"])
+	assert.Equal(t, "Acme Voice", branding["Name"])
+	assert.NotContains(t, branding, "FaviconUrl")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(WithCustomDomain(db))
+	router.GET("/", func(c *gin.Context) {
+		value, _ := c.Get(constants.DomainField)
+		site := value.(map[string]any)
+		mailConfig := RequestMailConfig(c, notification.MailConfig{From: "noreply@lingecho.com"})
+		c.String(http.StatusOK, site["Name"].(string)+"|"+mailConfig.From+"|"+mailConfig.FromName)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "voice.example.com"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "Acme Voice|noreply@mail.voice.example.com|Acme", w.Body.String())
+}
+
+func TestUserMailConfig(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CustomDomain{}, &Group{}, &GroupMember{})
+	base := notification.MailConfig{From: "noreply@lingecho.com"}
+
+	group := Group{Name: "acme", CreatorID: 1}
+	require.NoError(t, db.Create(&group).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: 2, GroupID: group.ID, Role: "member"}).Error)
+	d, err := CreateCustomDomain(db, group.ID, "acme.example.com")
+	require.NoError(t, err)
+	require.NoError(t, UpdateCustomDomainBranding(db, d, CustomDomainBranding{MailFrom: "team@acme.example.com"}))
+
+	// Not verified yet
+	assert.Equal(t, base.From, UserMailConfig(db, 2, base).From)
+
+	_, err = VerifyCustomDomainCNAME(db, d, "edge", func(string) (string, error) { return "edge", nil })
+	require.NoError(t, err)
+	assert.Equal(t, "team@acme.example.com", UserMailConfig(db, 1, base).From)
+	assert.Equal(t, "team@acme.example.com", UserMailConfig(db, 2, base).From)
+	assert.Equal(t, base.From, UserMailConfig(db, 3, base).From)
+}
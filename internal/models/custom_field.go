@@ -0,0 +1,375 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CustomFieldEntity entity types that support custom fields
+type CustomFieldEntity string
+
+const (
+	CustomFieldEntityDevice    CustomFieldEntity = "device"
+	CustomFieldEntityAssistant CustomFieldEntity = "assistant"
+)
+
+// CustomFieldType value type of a custom field
+type CustomFieldType string
+
+const (
+	CustomFieldTypeString  CustomFieldType = "string"
+	CustomFieldTypeNumber  CustomFieldType = "number"
+	CustomFieldTypeBoolean CustomFieldType = "boolean"
+	CustomFieldTypeSelect  CustomFieldType = "select"
+	CustomFieldTypeDate    CustomFieldType = "date" // YYYY-MM-DD
+)
+
+// CustomFieldFilterPrefix query parameter prefix used to filter list endpoints, e.g. ?cf.region=east
+const CustomFieldFilterPrefix = "cf."
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,63}$`)
+
+// CustomFieldDefinition organization-level definition of a custom field
+type CustomFieldDefinition struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID    uint              `json:"groupId" gorm:"uniqueIndex:idx_custom_field_def;not null"`
+	EntityType CustomFieldEntity `json:"entityType" gorm:"size:32;uniqueIndex:idx_custom_field_def;not null"`
+	Key        string            `json:"key" gorm:"column:field_key;size:64;uniqueIndex:idx_custom_field_def;not null"`
+	Label      string            `json:"label" gorm:"size:128"`
+	FieldType  CustomFieldType   `json:"fieldType" gorm:"size:20;not null"`
+	Required   bool              `json:"required"`
+	Options    StringArray       `json:"options,omitempty" gorm:"type:json"` // Allowed values for select fields
+	CreateBy   uint              `json:"createBy"`
+}
+
+// TableName 指定表名
+func (CustomFieldDefinition) TableName() string {
+	return "custom_field_definitions"
+}
+
+// CustomFieldValue value of a custom field on a single device or assistant
+type CustomFieldValue struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID    uint              `json:"groupId" gorm:"index"`
+	EntityType CustomFieldEntity `json:"entityType" gorm:"size:32;uniqueIndex:idx_custom_field_value;not null"`
+	EntityID   string            `json:"entityId" gorm:"size:64;uniqueIndex:idx_custom_field_value;not null"`
+	FieldKey   string            `json:"fieldKey" gorm:"size:64;uniqueIndex:idx_custom_field_value;index:idx_custom_field_lookup;not null"`
+	Value      string            `json:"value" gorm:"size:512;index:idx_custom_field_lookup"`
+}
+
+// TableName 指定表名
+func (CustomFieldValue) TableName() string {
+	return "custom_field_values"
+}
+
+// Validate checks the definition itself
+func (d *CustomFieldDefinition) Validate() error {
+	if d.EntityType != CustomFieldEntityDevice && d.EntityType != CustomFieldEntityAssistant {
+		return fmt.Errorf("unsupported entity type: %s", d.EntityType)
+	}
+	if !customFieldKeyPattern.MatchString(d.Key) {
+		return fmt.Errorf("invalid field key: %s", d.Key)
+	}
+	switch d.FieldType {
+	case CustomFieldTypeString, CustomFieldTypeNumber, CustomFieldTypeBoolean, CustomFieldTypeDate:
+	case CustomFieldTypeSelect:
+		if len(d.Options) == 0 {
+			return fmt.Errorf("select field %s requires options", d.Key)
+		}
+	default:
+		return fmt.Errorf("unsupported field type: %s", d.FieldType)
+	}
+	return nil
+}
+
+// Normalize validates a raw value against the definition and returns its stored form
+func (d *CustomFieldDefinition) Normalize(raw interface{}) (string, error) {
+	switch d.FieldType {
+	case CustomFieldTypeNumber:
+		switch v := raw.(type) {
+		case float64:
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				return strconv.FormatFloat(v, 'f', -1, 64), nil
+			}
+		case int:
+			return strconv.Itoa(v), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case string:
+			// Strings are reformatted like JSON numbers, so "12.0" and 12 are stored and matched as "12"
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				return strconv.FormatFloat(f, 'f', -1, 64), nil
+			}
+		}
+		return "", fmt.Errorf("field %s must be a number", d.Key)
+	case CustomFieldTypeBoolean:
+		switch v := raw.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return strconv.FormatBool(b), nil
+			}
+		}
+		return "", fmt.Errorf("field %s must be a boolean", d.Key)
+	case CustomFieldTypeDate:
+		if v, ok := raw.(string); ok {
+			if _, err := time.Parse("2006-01-02", v); err == nil {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("field %s must be a date (YYYY-MM-DD)", d.Key)
+	case CustomFieldTypeSelect:
+		if v, ok := raw.(string); ok {
+			for _, option := range d.Options {
+				if option == v {
+					return v, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("field %s must be one of %s", d.Key, strings.Join(d.Options, ", "))
+	default:
+		v, ok := raw.(string)
+		if !ok {
+			return "", fmt.Errorf("field %s must be a string", d.Key)
+		}
+		if len(v) > 512 {
+			return "", fmt.Errorf("field %s exceeds 512 characters", d.Key)
+		}
+		return v, nil
+	}
+}
+
+// ListCustomFieldDefinitions lists definitions of an organization, entityType may be empty for all
+func ListCustomFieldDefinitions(db *gorm.DB, groupID uint, entityType CustomFieldEntity) ([]CustomFieldDefinition, error) {
+	var defs []CustomFieldDefinition
+	query := db.Where("group_id = ?", groupID)
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	err := query.Order("entity_type, id").Find(&defs).Error
+	return defs, err
+}
+
+// CreateCustomFieldDefinition creates a definition after validating it
+func CreateCustomFieldDefinition(db *gorm.DB, def *CustomFieldDefinition) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	var count int64
+	db.Model(&CustomFieldDefinition{}).
+		Where(&CustomFieldDefinition{GroupID: def.GroupID, EntityType: def.EntityType, Key: def.Key}).
+		Count(&count)
+	if count > 0 {
+		return fmt.Errorf("field key already exists: %s", def.Key)
+	}
+	return db.Create(def).Error
+}
+
+// UpdateCustomFieldDefinition saves a modified definition
+func UpdateCustomFieldDefinition(db *gorm.DB, def *CustomFieldDefinition) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	return db.Save(def).Error
+}
+
+// DeleteCustomFieldDefinition deletes a definition together with its stored values
+func DeleteCustomFieldDefinition(db *gorm.DB, def *CustomFieldDefinition) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ? AND entity_type = ? AND field_key = ?", def.GroupID, def.EntityType, def.Key).
+			Delete(&CustomFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(def).Error
+	})
+}
+
+// GetCustomFieldValues returns the custom field values of an entity keyed by field key
+func GetCustomFieldValues(db *gorm.DB, entityType CustomFieldEntity, entityID string) (map[string]string, error) {
+	var values []CustomFieldValue
+	if err := db.Where("entity_type = ? AND entity_id = ?", entityType, entityID).Find(&values).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(values))
+	for _, v := range values {
+		result[v.FieldKey] = v.Value
+	}
+	return result, nil
+}
+
+// GetCustomFieldValuesBatch returns custom field values of several entities keyed by entity ID
+func GetCustomFieldValuesBatch(db *gorm.DB, entityType CustomFieldEntity, entityIDs []string) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string, len(entityIDs))
+	if len(entityIDs) == 0 {
+		return result, nil
+	}
+	var values []CustomFieldValue
+	if err := db.Where("entity_type = ? AND entity_id IN ?", entityType, entityIDs).Find(&values).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if result[v.EntityID] == nil {
+			result[v.EntityID] = make(map[string]string)
+		}
+		result[v.EntityID][v.FieldKey] = v.Value
+	}
+	return result, nil
+}
+
+// AttachDeviceCustomFields fills CustomFields of the devices with a single query
+func AttachDeviceCustomFields(db *gorm.DB, devices []Device) error {
+	ids := make([]string, len(devices))
+	for i := range devices {
+		ids[i] = devices[i].ID
+	}
+	values, err := GetCustomFieldValuesBatch(db, CustomFieldEntityDevice, ids)
+	if err != nil {
+		return err
+	}
+	for i := range devices {
+		devices[i].CustomFields = values[devices[i].ID]
+	}
+	return nil
+}
+
+// AttachAssistantCustomFields fills CustomFields of the assistants with a single query
+func AttachAssistantCustomFields(db *gorm.DB, assistants []Assistant) error {
+	ids := make([]string, len(assistants))
+	for i := range assistants {
+		ids[i] = strconv.FormatInt(assistants[i].ID, 10)
+	}
+	values, err := GetCustomFieldValuesBatch(db, CustomFieldEntityAssistant, ids)
+	if err != nil {
+		return err
+	}
+	for i := range assistants {
+		assistants[i].CustomFields = values[ids[i]]
+	}
+	return nil
+}
+
+// SetCustomFieldValues validates values against the organization's definitions and stores them.
+// A nil value removes the field. Required fields must be present after the update.
+func SetCustomFieldValues(db *gorm.DB, groupID uint, entityType CustomFieldEntity, entityID string, values map[string]interface{}) (map[string]string, error) {
+	defs, err := ListCustomFieldDefinitions(db, groupID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	defByKey := make(map[string]*CustomFieldDefinition, len(defs))
+	for i := range defs {
+		defByKey[defs[i].Key] = &defs[i]
+	}
+
+	current, err := GetCustomFieldValues(db, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	upserts := make([]CustomFieldValue, 0, len(values))
+	var removals []string
+	for key, raw := range values {
+		def, ok := defByKey[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field: %s", key)
+		}
+		if raw == nil {
+			removals = append(removals, key)
+			delete(current, key)
+			continue
+		}
+		normalized, err := def.Normalize(raw)
+		if err != nil {
+			return nil, err
+		}
+		current[key] = normalized
+		upserts = append(upserts, CustomFieldValue{
+			GroupID:    groupID,
+			EntityType: entityType,
+			EntityID:   entityID,
+			FieldKey:   key,
+			Value:      normalized,
+		})
+	}
+
+	for _, def := range defs {
+		if _, ok := current[def.Key]; def.Required && !ok {
+			return nil, fmt.Errorf("custom field %s is required", def.Key)
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(removals) > 0 {
+			if err := tx.Where("entity_type = ? AND entity_id = ? AND field_key IN ?", entityType, entityID, removals).
+				Delete(&CustomFieldValue{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(upserts) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_type"}, {Name: "entity_id"}, {Name: "field_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "group_id", "updated_at"}),
+		}).Create(&upserts).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// DeleteCustomFieldValues removes all custom field values of an entity
+func DeleteCustomFieldValues(db *gorm.DB, entityType CustomFieldEntity, entityID string) error {
+	return db.Where("entity_type = ? AND entity_id = ?", entityType, entityID).Delete(&CustomFieldValue{}).Error
+}
+
+// FindEntityIDsByCustomFields returns IDs of entities whose custom fields match every filter
+func FindEntityIDsByCustomFields(db *gorm.DB, entityType CustomFieldEntity, filters map[string]string) ([]string, error) {
+	var ids []string
+	first := true
+	for key, value := range filters {
+		var matched []string
+		if err := db.Model(&CustomFieldValue{}).
+			Where("entity_type = ? AND field_key = ? AND value = ?", entityType, key, value).
+			Pluck("entity_id", &matched).Error; err != nil {
+			return nil, err
+		}
+		if first {
+			ids = matched
+			first = false
+			continue
+		}
+		ids = intersectStrings(ids, matched)
+	}
+	return ids, nil
+}
+
+func intersectStrings(a, b []string) []string {
+	set := make(map[string]struct{}, len(b))
+	for _, v := range b {
+		set[v] = struct{}{}
+	}
+	result := make([]string, 0)
+	for _, v := range a {
+		if _, ok := set[v]; ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// ErrCustomFieldsRequireGroup returned when setting custom fields on an entity not shared with an organization
+var ErrCustomFieldsRequireGroup = errors.New("custom fields are only available for organization resources")
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupCustomFieldTestDB(t *testing.T) *gorm.DB {
+	db := setupTestDBWithSilentLogger(t, &CustomFieldDefinition{}, &CustomFieldValue{})
+
+	defs := []CustomFieldDefinition{
+		{GroupID: 1, EntityType: CustomFieldEntityDevice, Key: "storeNumber", FieldType: CustomFieldTypeNumber, Required: true},
+		{GroupID: 1, EntityType: CustomFieldEntityDevice, Key: "region", FieldType: CustomFieldTypeSelect, Options: StringArray{"east", "west"}},
+		{GroupID: 1, EntityType: CustomFieldEntityDevice, Key: "costCenter", FieldType: CustomFieldTypeString},
+	}
+	for i := range defs {
+		require.NoError(t, CreateCustomFieldDefinition(db, &defs[i]))
+	}
+	return db
+}
+
+func TestCustomFieldDefinition_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     CustomFieldDefinition
+		wantErr bool
+	}{
+		{"valid string", CustomFieldDefinition{EntityType: CustomFieldEntityDevice, Key: "store", FieldType: CustomFieldTypeString}, false},
+		{"bad entity", CustomFieldDefinition{EntityType: "user", Key: "store", FieldType: CustomFieldTypeString}, true},
+		{"bad key", CustomFieldDefinition{EntityType: CustomFieldEntityDevice, Key: "1store", FieldType: CustomFieldTypeString}, true},
+		{"select without options", CustomFieldDefinition{EntityType: CustomFieldEntityAssistant, Key: "tier", FieldType: CustomFieldTypeSelect}, true},
+		{"bad type", CustomFieldDefinition{EntityType: CustomFieldEntityDevice, Key: "store", FieldType: "blob"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.def.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCustomFieldDefinition_Normalize(t *testing.T) {
+	number := CustomFieldDefinition{Key: "n", FieldType: CustomFieldTypeNumber}
+	v, err := number.Normalize(float64(42))
+	require.NoError(t, err)
+	assert.Equal(t, "42", v)
+	for _, raw := range []interface{}{"12.0", " 12 ", 12, int64(12), 12.0} {
+		v, err = number.Normalize(raw)
+		require.NoError(t, err)
+		assert.Equal(t, "12", v, "%#v", raw)
+	}
+	v, err = number.Normalize("3.50")
+	require.NoError(t, err)
+	assert.Equal(t, "3.5", v)
+	_, err = number.Normalize("abc")
+	assert.Error(t, err)
+	_, err = number.Normalize("NaN")
+	assert.Error(t, err)
+
+	boolean := CustomFieldDefinition{Key: "b", FieldType: CustomFieldTypeBoolean}
+	v, err = boolean.Normalize("1")
+	require.NoError(t, err)
+	assert.Equal(t, "true", v)
+
+	date := CustomFieldDefinition{Key: "d", FieldType: CustomFieldTypeDate}
+	_, err = date.Normalize("2024-13-01")
+	assert.Error(t, err)
+
+	sel := CustomFieldDefinition{Key: "s", FieldType: CustomFieldTypeSelect, Options: StringArray{"a"}}
+	_, err = sel.Normalize("b")
+	assert.Error(t, err)
+}
+
+func TestCreateCustomFieldDefinition_Duplicate(t *testing.T) {
+	db := setupCustomFieldTestDB(t)
+	err := CreateCustomFieldDefinition(db, &CustomFieldDefinition{
+		GroupID: 1, EntityType: CustomFieldEntityDevice, Key: "region", FieldType: CustomFieldTypeString,
+	})
+	assert.Error(t, err)
+
+	// Same key is allowed for another entity type
+	err = CreateCustomFieldDefinition(db, &CustomFieldDefinition{
+		GroupID: 1, EntityType: CustomFieldEntityAssistant, Key: "region", FieldType: CustomFieldTypeString,
+	})
+	assert.NoError(t, err)
+}
+
+func TestSetCustomFieldValues(t *testing.T) {
+	db := setupCustomFieldTestDB(t)
+
+	// Required field missing
+	_, err := SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-1", map[string]interface{}{"region": "east"})
+	assert.Error(t, err)
+
+	// Unknown field
+	_, err = SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-1", map[string]interface{}{"storeNumber": 1.0, "unknown": "x"})
+	assert.Error(t, err)
+
+	values, err := SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-1", map[string]interface{}{
+		"storeNumber": float64(12),
+		"region":      "east",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"storeNumber": "12", "region": "east"}, values)
+
+	// Update one field, remove another
+	values, err = SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-1", map[string]interface{}{
+		"region":     nil,
+		"costCenter": "CC-7",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"storeNumber": "12", "costCenter": "CC-7"}, values)
+
+	stored, err := GetCustomFieldValues(db, CustomFieldEntityDevice, "dev-1")
+	require.NoError(t, err)
+	assert.Equal(t, values, stored)
+
+	// Removing a required field is rejected
+	_, err = SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-1", map[string]interface{}{"storeNumber": nil})
+	assert.Error(t, err)
+}
+
+func TestFindEntityIDsByCustomFields(t *testing.T) {
+	db := setupCustomFieldTestDB(t)
+
+	_, err := SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-1", map[string]interface{}{"storeNumber": 1.0, "region": "east"})
+	require.NoError(t, err)
+	_, err = SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-2", map[string]interface{}{"storeNumber": 2.0, "region": "east"})
+	require.NoError(t, err)
+	_, err = SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-3", map[string]interface{}{"storeNumber": 2.0, "region": "west"})
+	require.NoError(t, err)
+
+	ids, err := FindEntityIDsByCustomFields(db, CustomFieldEntityDevice, map[string]string{"region": "east"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"dev-1", "dev-2"}, ids)
+
+	ids, err = FindEntityIDsByCustomFields(db, CustomFieldEntityDevice, map[string]string{"region": "east", "storeNumber": "2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev-2"}, ids)
+
+	batch, err := GetCustomFieldValuesBatch(db, CustomFieldEntityDevice, []string{"dev-1", "dev-3"})
+	require.NoError(t, err)
+	assert.Equal(t, "west", batch["dev-3"]["region"])
+	assert.Len(t, batch, 2)
+}
+
+func TestDeleteCustomFieldDefinition_RemovesValues(t *testing.T) {
+	db := setupCustomFieldTestDB(t)
+	_, err := SetCustomFieldValues(db, 1, CustomFieldEntityDevice, "dev-1", map[string]interface{}{"storeNumber": 1.0, "costCenter": "A"})
+	require.NoError(t, err)
+
+	defs, err := ListCustomFieldDefinitions(db, 1, CustomFieldEntityDevice)
+	require.NoError(t, err)
+	for i := range defs {
+		if defs[i].Key == "costCenter" {
+			require.NoError(t, DeleteCustomFieldDefinition(db, &defs[i]))
+		}
+	}
+
+	values, err := GetCustomFieldValues(db, CustomFieldEntityDevice, "dev-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"storeNumber": "1"}, values)
+}
+
+func TestDeleteDevice_RemovesCustomFieldValues(t *testing.T) {
+	db := setupCustomFieldTestDB(t)
+	require.NoError(t, db.AutoMigrate(&Device{}))
+	for _, id := range []string{"dev-1", "dev-2"} {
+		require.NoError(t, db.Create(&Device{ID: id, MacAddress: id}).Error)
+		_, err := SetCustomFieldValues(db, 1, CustomFieldEntityDevice, id, map[string]interface{}{"storeNumber": "7.0"})
+		require.NoError(t, err)
+	}
+
+	devices := []Device{{ID: "dev-1"}, {ID: "dev-2"}, {ID: "dev-3"}}
+	require.NoError(t, AttachDeviceCustomFields(db, devices))
+	assert.Equal(t, map[string]string{"storeNumber": "7"}, devices[0].CustomFields)
+	assert.Nil(t, devices[2].CustomFields)
+
+	require.NoError(t, DeleteDevice(db, "dev-1"))
+	batch, err := GetCustomFieldValuesBatch(db, CustomFieldEntityDevice, []string{"dev-1", "dev-2"})
+	require.NoError(t, err)
+	assert.NotContains(t, batch, "dev-1")
+	assert.Contains(t, batch, "dev-2")
+}
@@ -0,0 +1,348 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DemoGroupName 演示组织名称，按名称识别，重复运行不会再创建
+	DemoGroupName = "Demo Organization"
+	// DemoEmailDomain 演示用户邮箱域名（保留域名，不会发出真实邮件）
+	DemoEmailDomain = "demo.example.com"
+	// DemoDefaultPassword 未指定密码时演示用户的密码
+	DemoDefaultPassword = "demo123"
+	// DemoKnowledgeKey 演示知识库的 key
+	DemoKnowledgeKey = "demo-product-faq"
+	// DemoDefaultDevices 默认创建的演示设备数量
+	DemoDefaultDevices = 3
+	// DemoMaxDevices 演示设备数量上限
+	DemoMaxDevices = 50
+)
+
+var ErrInvalidDemoDevices = errors.New("demo device count out of range")
+
+// DemoTenantOptions 演示租户选项
+type DemoTenantOptions struct {
+	Password    string    // 新建演示用户的密码，已存在的用户不修改
+	Devices     int       // 演示设备数量
+	DocumentDir string    // 知识库文档存放目录，为空时只记录文档不写文件
+	Now         time.Time // 遥测数据的时间基准，测试中固定
+}
+
+// DemoTenant 演示租户的内容，Created 为本次新建的记录数，重复运行时为 0
+type DemoTenant struct {
+	Group     Group                 `json:"group"`
+	Users     []User                `json:"users"`
+	Assistant Assistant             `json:"assistant"`
+	Knowledge Knowledge             `json:"knowledge"`
+	Documents []KnowledgeSourceFile `json:"documents"`
+	SipUsers  []SipUser             `json:"sipUsers"`
+	Devices   []Device              `json:"devices"`
+	Created   int                   `json:"created"`
+}
+
+// demoMember 演示用户及其组织角色
+type demoMember struct {
+	Name  string
+	Local string
+	Role  string
+}
+
+var demoMembers = []demoMember{
+	{Name: "Demo Owner", Local: "owner", Role: GroupRoleAdmin},
+	{Name: "Demo Admin", Local: "admin", Role: GroupRoleAdmin},
+	{Name: "Demo Agent", Local: "agent", Role: GroupRoleMember},
+	{Name: "Demo Viewer", Local: "viewer", Role: GroupRoleMember},
+}
+
+var demoDocuments = []struct {
+	Filename string
+	Content  string
+}{
+	{"product-overview.md", "# Product overview\n\nSoulNexus answers customer calls with AI assistants over WebRTC, SIP and hardware devices.\n"},
+	{"opening-hours.md", "# Opening hours\n\nHuman agents are available Monday to Friday, 9:00-18:00. The assistant answers around the clock.\n"},
+	{"refund-policy.md", "# Refund policy\n\nOrders can be refunded within 7 days of delivery. Refunds are returned to the original payment method within 3 business days.\n"},
+}
+
+// DemoUserEmail 演示用户的邮箱
+func DemoUserEmail(local string) string {
+	return local + "@" + DemoEmailDomain
+}
+
+// DemoDeviceMAC 第 n 台演示设备的 MAC 地址（本地管理地址，不会与真实设备冲突），从 1 开始
+func DemoDeviceMAC(n int) string {
+	return fmt.Sprintf("02:de:00:00:%02x:%02x", n>>8&0xff, n&0xff)
+}
+
+// SeedDemoTenant 创建演示组织、带角色的用户、带文档的知识库和助手、SIP 用户和带遥测的设备。
+// 所有记录按名称、邮箱、用户名或 MAC 查找，已存在时复用，可以安全地重复运行；
+// 已有设备只刷新遥测，不追加位置历史
+func SeedDemoTenant(db *gorm.DB, opts DemoTenantOptions) (*DemoTenant, error) {
+	if opts.Password == "" {
+		opts.Password = DemoDefaultPassword
+	}
+	if opts.Devices == 0 {
+		opts.Devices = DemoDefaultDevices
+	}
+	if opts.Devices < 0 || opts.Devices > DemoMaxDevices {
+		return nil, ErrInvalidDemoDevices
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	tenant := &DemoTenant{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tenant.seedUsers(tx, opts); err != nil {
+			return err
+		}
+		if err := tenant.seedGroup(tx); err != nil {
+			return err
+		}
+		if err := tenant.seedKnowledge(tx, opts); err != nil {
+			return err
+		}
+		if err := tenant.seedAssistant(tx); err != nil {
+			return err
+		}
+		if err := tenant.seedSipUsers(tx); err != nil {
+			return err
+		}
+		return tenant.seedDevices(tx, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// firstOrCreate 按条件查找记录，不存在时创建 value
+func (t *DemoTenant) firstOrCreate(tx *gorm.DB, value interface{}, query string, args ...interface{}) error {
+	err := tx.Where(query, args...).First(value).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err := tx.Create(value).Error; err != nil {
+		return err
+	}
+	t.Created++
+	return nil
+}
+
+func (t *DemoTenant) owner() *User {
+	return &t.Users[0]
+}
+
+func (t *DemoTenant) seedUsers(tx *gorm.DB, opts DemoTenantOptions) error {
+	password := HashPassword(opts.Password)
+	for _, m := range demoMembers {
+		user := User{
+			Email:         DemoUserEmail(m.Local),
+			Password:      password,
+			DisplayName:   m.Name,
+			Role:          RoleUser,
+			Enabled:       true,
+			Activated:     true,
+			EmailVerified: true,
+			Source:        "demo",
+		}
+		if err := t.firstOrCreate(tx, &user, "email = ?", user.Email); err != nil {
+			return err
+		}
+		t.Users = append(t.Users, user)
+	}
+	return nil
+}
+
+func (t *DemoTenant) seedGroup(tx *gorm.DB) error {
+	t.Group = Group{Name: DemoGroupName, Type: "demo", CreatorID: t.owner().ID}
+	if err := t.firstOrCreate(tx, &t.Group, "name = ? AND creator_id = ?", DemoGroupName, t.owner().ID); err != nil {
+		return err
+	}
+	for i, m := range demoMembers {
+		member := GroupMember{UserID: t.Users[i].ID, GroupID: t.Group.ID, Role: m.Role}
+		if err := t.firstOrCreate(tx, &member, "user_id = ? AND group_id = ?", member.UserID, member.GroupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *DemoTenant) seedKnowledge(tx *gorm.DB, opts DemoTenantOptions) error {
+	groupID := t.Group.ID
+	now := opts.Now
+	t.Knowledge = Knowledge{
+		UserID:        int(t.owner().ID),
+		GroupID:       &groupID,
+		KnowledgeKey:  DemoKnowledgeKey,
+		KnowledgeName: "Demo product FAQ",
+		Config:        `{"demo":true}`,
+		CreatedAt:     now,
+		UpdateAt:      now,
+		UpdatedAt:     now,
+	}
+	if err := t.firstOrCreate(tx, &t.Knowledge, "user_id = ? AND knowledge_key = ?", t.Knowledge.UserID, DemoKnowledgeKey); err != nil {
+		return err
+	}
+
+	for _, doc := range demoDocuments {
+		sum := sha256.Sum256([]byte(doc.Content))
+		file := KnowledgeSourceFile{
+			KnowledgeID: t.Knowledge.ID,
+			UserID:      t.owner().ID,
+			Filename:    doc.Filename,
+			ContentType: "text/markdown",
+			Size:        int64(len(doc.Content)),
+			SHA256:      hex.EncodeToString(sum[:]),
+		}
+		if opts.DocumentDir != "" {
+			// 与上传的文档相同，按内容哈希存放，重复运行写入同一文件
+			dir := filepath.Join(opts.DocumentDir, strconv.Itoa(t.Knowledge.ID))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			file.Path = filepath.Join(dir, file.SHA256+filepath.Ext(doc.Filename))
+			if err := os.WriteFile(file.Path, []byte(doc.Content), 0o644); err != nil {
+				return err
+			}
+		}
+		if err := t.firstOrCreate(tx, &file, "knowledge_id = ? AND filename = ?", file.KnowledgeID, file.Filename); err != nil {
+			return err
+		}
+		t.Documents = append(t.Documents, file)
+	}
+	return nil
+}
+
+func (t *DemoTenant) seedAssistant(tx *gorm.DB) error {
+	groupID := t.Group.ID
+	knowledgeKey := t.Knowledge.KnowledgeKey
+	t.Assistant = Assistant{
+		UserID:          t.owner().ID,
+		GroupID:         &groupID,
+		Name:            "Demo Receptionist",
+		Description:     "Answers product, opening hours and refund questions for the demo organization",
+		Icon:            "Bot",
+		SystemPrompt:    "You are the receptionist of the demo organization. Answer briefly using the product FAQ knowledge base.",
+		PersonaTag:      "support",
+		Temperature:     0.6,
+		MaxTokens:       200,
+		KnowledgeBaseID: &knowledgeKey,
+	}
+	return t.firstOrCreate(tx, &t.Assistant, "group_id = ? AND name = ?", groupID, t.Assistant.Name)
+}
+
+func (t *DemoTenant) seedSipUsers(tx *gorm.DB) error {
+	groupID := t.Group.ID
+	assistantID := uint(t.Assistant.ID)
+	for i, scheme := range []string{"Front desk", "After hours"} {
+		sipUser := SipUser{
+			SchemeName:     scheme,
+			Username:       fmt.Sprintf("demo%d", 1001+i),
+			Password:       DemoDefaultPassword,
+			Status:         SipUserStatusUnregistered,
+			UserID:         &t.Users[i].ID,
+			GroupID:        &groupID,
+			AssistantID:    &assistantID,
+			AutoAnswer:     true,
+			OpeningMessage: "Hello, this is the demo organization. How can I help you?",
+			AIFreeResponse: true,
+		}
+		if err := t.firstOrCreate(tx, &sipUser, "username = ?", sipUser.Username); err != nil {
+			return err
+		}
+		t.SipUsers = append(t.SipUsers, sipUser)
+	}
+	return nil
+}
+
+func (t *DemoTenant) seedDevices(tx *gorm.DB, opts DemoTenantOptions) error {
+	groupID := t.Group.ID
+	assistantID := uint(t.Assistant.ID)
+	for n := 1; n <= opts.Devices; n++ {
+		mac := DemoDeviceMAC(n)
+		device := Device{
+			ID:          mac,
+			UserID:      t.owner().ID,
+			GroupID:     &groupID,
+			MacAddress:  mac,
+			DeviceName:  fmt.Sprintf("Demo speaker %d", n),
+			Board:       "esp32-s3-demo",
+			AppVersion:  "1.0.0",
+			AutoUpdate:  1,
+			AssistantID: &assistantID,
+		}
+		created := t.Created
+		if err := t.firstOrCreate(tx, &device, "id = ?", mac); err != nil {
+			return err
+		}
+		if err := seedDemoTelemetry(tx, &device, n, opts.Now, t.Created > created); err != nil {
+			return err
+		}
+		t.Devices = append(t.Devices, device)
+	}
+	return nil
+}
+
+// seedDemoTelemetry 写入设备的运行状态；新建的设备还会写入一天的位置历史
+func seedDemoTelemetry(tx *gorm.DB, device *Device, n int, now time.Time, withHistory bool) error {
+	// 每台设备的数值固定，重复运行结果一致
+	online := n%3 != 0
+	lastSeen := now.Add(-time.Duration(n) * time.Minute)
+	started := now.Add(-time.Duration(24*n) * time.Hour)
+	systemInfo, _ := json.Marshal(map[string]interface{}{"os": "FreeRTOS", "flashSize": 16 << 20, "psramSize": 8 << 20})
+	networkInfo, _ := json.Marshal(map[string]interface{}{"type": "wifi", "ssid": "demo", "rssi": -40 - 5*n})
+	system, network := string(systemInfo), string(networkInfo)
+	lat, lng := 31.2304+0.01*float64(n), 121.4737+0.01*float64(n)
+
+	updates := map[string]interface{}{
+		"is_online":       online,
+		"last_seen":       lastSeen,
+		"start_time":      started,
+		"uptime":          int64(now.Sub(started).Seconds()),
+		"cpu_usage":       float64(10 + 7*n%60),
+		"memory_usage":    float64(30 + 5*n%50),
+		"temperature":     float64(38 + n%10),
+		"system_info":     system,
+		"network_info":    network,
+		"latitude":        lat,
+		"longitude":       lng,
+		"location_source": "gps",
+		"located_at":      lastSeen,
+	}
+	if err := tx.Model(device).Updates(updates).Error; err != nil {
+		return err
+	}
+	if err := tx.First(device, "id = ?", device.ID).Error; err != nil {
+		return err
+	}
+	if !withHistory {
+		return nil
+	}
+	for h := 24; h > 0; h -= 6 {
+		loc := DeviceLocation{
+			DeviceID:   device.ID,
+			UserID:     device.UserID,
+			GroupID:    device.GroupID,
+			Latitude:   lat - 0.001*float64(h),
+			Longitude:  lng - 0.001*float64(h),
+			Accuracy:   10,
+			Source:     "gps",
+			ReportedAt: now.Add(-time.Duration(h) * time.Hour),
+		}
+		if err := tx.Create(&loc).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
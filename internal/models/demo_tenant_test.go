@@ -0,0 +1,75 @@
+package models
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupDemoTenantDB(t *testing.T) *gorm.DB {
+	return setupTestDBWithSilentLogger(t, &User{}, &Group{}, &GroupMember{}, &Knowledge{}, &KnowledgeSourceFile{},
+		&Assistant{}, &SipUser{}, &Device{}, &DeviceLocation{})
+}
+
+func TestSeedDemoTenant(t *testing.T) {
+	db := setupDemoTenantDB(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	tenant, err := SeedDemoTenant(db, DemoTenantOptions{Devices: 2, DocumentDir: dir, Now: now})
+	require.NoError(t, err)
+	assert.Equal(t, DemoGroupName, tenant.Group.Name)
+	require.Len(t, tenant.Users, 4)
+	assert.Equal(t, DemoUserEmail("owner"), tenant.Users[0].Email)
+	assert.True(t, CheckPassword(&tenant.Users[0], DemoDefaultPassword))
+	assert.True(t, IsGroupAdmin(db, &tenant.Group, tenant.Users[1].ID))
+	assert.False(t, IsGroupAdmin(db, &tenant.Group, tenant.Users[2].ID))
+
+	require.Len(t, tenant.Documents, 3)
+	content, err := os.ReadFile(tenant.Documents[0].Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Product overview")
+	assert.Equal(t, DemoKnowledgeKey, *tenant.Assistant.KnowledgeBaseID)
+
+	require.Len(t, tenant.SipUsers, 2)
+	assert.Equal(t, "demo1001", tenant.SipUsers[0].Username)
+	assert.Equal(t, uint(tenant.Assistant.ID), *tenant.SipUsers[0].AssistantID)
+
+	require.Len(t, tenant.Devices, 2)
+	assert.Equal(t, "02:de:00:00:00:01", tenant.Devices[0].ID)
+	assert.True(t, tenant.Devices[0].IsOnline)
+	assert.NotZero(t, tenant.Devices[0].CPUUsage)
+	require.NotNil(t, tenant.Devices[0].LastSeen)
+	assert.Equal(t, now.Add(-time.Minute), tenant.Devices[0].LastSeen.UTC())
+	locations, err := GetDeviceLocations(db, tenant.Devices[0].ID, nil, nil, 0)
+	require.NoError(t, err)
+	assert.Len(t, locations, 4)
+
+	// Running again reuses every record and does not add location history
+	again, err := SeedDemoTenant(db, DemoTenantOptions{Devices: 2, DocumentDir: dir, Password: "changed", Now: now.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Zero(t, again.Created)
+	assert.Equal(t, tenant.Group.ID, again.Group.ID)
+	assert.True(t, CheckPassword(&again.Users[0], DemoDefaultPassword), "existing users keep their password")
+	assert.Equal(t, now.Add(59*time.Minute), again.Devices[0].LastSeen.UTC())
+	locations, _ = GetDeviceLocations(db, tenant.Devices[0].ID, nil, nil, 0)
+	assert.Len(t, locations, 4)
+
+	for model, want := range map[interface{}]int64{&User{}: 4, &GroupMember{}: 4, &Assistant{}: 1, &KnowledgeSourceFile{}: 3, &SipUser{}: 2, &Device{}: 2} {
+		var count int64
+		require.NoError(t, db.Model(model).Count(&count).Error)
+		assert.Equal(t, want, count, "%T", model)
+	}
+
+	// More devices can be added later
+	more, err := SeedDemoTenant(db, DemoTenantOptions{Devices: 3, Now: now})
+	require.NoError(t, err)
+	assert.Equal(t, 1, more.Created)
+
+	_, err = SeedDemoTenant(db, DemoTenantOptions{Devices: DemoMaxDevices + 1})
+	assert.ErrorIs(t, err, ErrInvalidDemoDevices)
+}
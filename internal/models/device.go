@@ -2,10 +2,12 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/code-100-precent/LingEcho/pkg/actionmanifest"
 	"github.com/code-100-precent/LingEcho/pkg/logger"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -57,12 +59,13 @@ type Device struct {
 	UserID      uint   `json:"userId" gorm:"index"`
 	GroupID     *uint  `json:"groupId,omitempty" gorm:"index"` // 组织ID，如果设置则表示这是组织共享的设备
 	MacAddress  string `json:"macAddress" gorm:"size:64;uniqueIndex"`
-	DeviceName  string `json:"deviceName,omitempty" gorm:"size:128"` // 设备名称/别名
-	Board       string `json:"board,omitempty" gorm:"size:128"`      // Board type
-	AppVersion  string `json:"appVersion,omitempty" gorm:"size:64"`  // Application version
-	AutoUpdate  int    `json:"autoUpdate" gorm:"default:1"`          // 0 = disabled, 1 = enabled
-	AssistantID *uint  `json:"assistantId,omitempty" gorm:"index"`   // Assistant ID (对应 xiaozhi-esp32 的 agentId)
-	Alias       string `json:"alias,omitempty" gorm:"size:128"`      // Device alias
+	DeviceName  string `json:"deviceName,omitempty" gorm:"size:128"`  // 设备名称/别名
+	Board       string `json:"board,omitempty" gorm:"size:128"`       // Board type
+	AppVersion  string `json:"appVersion,omitempty" gorm:"size:64"`   // Application version
+	AutoUpdate  int    `json:"autoUpdate" gorm:"default:1"`           // 0 = disabled, 1 = enabled
+	AssistantID *uint  `json:"assistantId,omitempty" gorm:"index"`    // Assistant ID (对应 xiaozhi-esp32 的 agentId)
+	Alias       string `json:"alias,omitempty" gorm:"size:128"`       // Device alias
+	Region      string `json:"region,omitempty" gorm:"size:32;index"` // 固定的部署地区，为空时由任意地区服务
 
 	// 运行状态监控
 	IsOnline    bool       `json:"isOnline" gorm:"default:false;index"`  // 在线状态
@@ -78,6 +81,12 @@ type Device struct {
 	HardwareInfo *string `json:"hardwareInfo,omitempty" gorm:"type:json"` // 硬件信息JSON
 	NetworkInfo  *string `json:"networkInfo,omitempty" gorm:"type:json"`  // 网络信息JSON
 
+	// 最近一次上报的地理位置（历史见 DeviceLocation）
+	Latitude       *float64   `json:"latitude,omitempty"`                      // 纬度
+	Longitude      *float64   `json:"longitude,omitempty"`                     // 经度
+	LocationSource string     `json:"locationSource,omitempty" gorm:"size:16"` // 位置来源: gps / ip
+	LocatedAt      *time.Time `json:"locatedAt,omitempty"`                     // 定位时间
+
 	// 性能状态
 	CPUUsage    float64 `json:"cpuUsage"`    // CPU使用率
 	MemoryUsage float64 `json:"memoryUsage"` // 内存使用率
@@ -86,12 +95,19 @@ type Device struct {
 	// 音频设备状态
 	AudioStatus *string `json:"audioStatus,omitempty" gorm:"type:json"` // 音频设备状态JSON
 
+	// 麦克风测试结果（历史见 DeviceAudioTest）
+	AudioQualityScore *int       `json:"audioQualityScore,omitempty"`                 // 最近一次测试得分 0-100
+	AudioQualityAt    *time.Time `json:"audioQualityAt,omitempty"`                    // 最近一次测试时间
+	NeedsReplacement  bool       `json:"needsReplacement" gorm:"default:false;index"` // 得分低于阈值，建议更换
+
 	// 服务状态
 	ServiceStatus *string `json:"serviceStatus,omitempty" gorm:"type:json"` // 服务状态JSON
 
 	LastConnected *time.Time `json:"lastConnected,omitempty"`
 	CreatedAt     time.Time  `json:"createdAt" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	CustomFields map[string]string `json:"customFields,omitempty" gorm:"-"` // 组织自定义字段，列表接口填充
 }
 
 // TableName specifies the table name
@@ -109,6 +125,13 @@ type ConversationTurn struct {
 	EndTime   time.Time `json:"endTime"`   // 结束时间
 	Duration  int64     `json:"duration"`  // 持续时间(毫秒)
 
+	// 说话人分离后用户轮次所属的说话人，如 speaker_1
+	SpeakerID string `json:"speakerId,omitempty"`
+
+	// 应用了用户修正的轮次，原始识别结果保留在 OriginalContent
+	Corrected       bool   `json:"corrected,omitempty"`
+	OriginalContent string `json:"originalContent,omitempty"`
+
 	// 用户输入特有字段
 	ASRStartTime *time.Time `json:"asrStartTime,omitempty"` // ASR开始时间
 	ASREndTime   *time.Time `json:"asrEndTime,omitempty"`   // ASR结束时间
@@ -137,6 +160,14 @@ type ConversationDetails struct {
 	AITurns       int                `json:"aiTurns"`       // AI回复轮次
 	Turns         []ConversationTurn `json:"turns"`         // 对话轮次列表
 	Interruptions int                `json:"interruptions"` // 中断次数
+
+	// 说话人分离结果，多人通话时区分不同的人
+	Speakers       []ConversationSpeaker `json:"speakers,omitempty"`
+	SpeakersEdited bool                  `json:"speakersEdited,omitempty"` // 轮次被手动改过说话人，重新分析时保留
+
+	// 个人信息检测结果，按组织策略在接口返回时遮盖
+	PIIEntities   []TranscriptPIIEntity `json:"piiEntities,omitempty"`
+	PIIDetectedAt *time.Time            `json:"piiDetectedAt,omitempty"`
 }
 
 // TimingMetrics 时间指标统计
@@ -178,6 +209,9 @@ type TimingMetrics struct {
 	MaxTotalDelay     int64   `json:"maxTotalDelay"`     // 最长总延迟(毫秒)
 }
 
+// ErrDeviceExists a device with the same MAC address or ID is already registered
+var ErrDeviceExists = errors.New("device already exists")
+
 // GetDeviceByMacAddress gets device by MAC address
 func GetDeviceByMacAddress(db *gorm.DB, macAddress string) (*Device, error) {
 	var device Device
@@ -188,7 +222,7 @@ func GetDeviceByMacAddress(db *gorm.DB, macAddress string) (*Device, error) {
 	return &device, nil
 }
 
-// CreateDevice creates a new device
+// CreateDevice creates a new device, ErrDeviceExists when the MAC address is taken
 func CreateDevice(db *gorm.DB, device *Device) error {
 	if db == nil {
 		return fmt.Errorf("database connection is nil")
@@ -221,21 +255,25 @@ func CreateDevice(db *gorm.DB, device *Device) error {
 		return fmt.Errorf("device user ID cannot be zero")
 	}
 
-	// 执行数据库创建操作
-	result := db.Create(device)
-	if result.Error != nil {
+	// 按 MAC 地址唯一约束插入，并发绑定同一设备时只有一个请求成功
+	created, err := CreateOrGet(db, device, "MacAddress")
+	if err != nil {
 		logger.Error("数据库创建设备记录失败",
-			zap.Error(result.Error),
+			zap.Error(err),
 			zap.String("deviceId", device.ID),
-			zap.String("macAddress", device.MacAddress),
-			zap.Int64("rowsAffected", result.RowsAffected))
-		return result.Error
+			zap.String("macAddress", device.MacAddress))
+		if IsDuplicateKey(err) {
+			return ErrDeviceExists
+		}
+		return err
+	}
+	if !created {
+		return ErrDeviceExists
 	}
 
 	logger.Info("设备数据库记录创建成功",
 		zap.String("deviceId", device.ID),
-		zap.String("macAddress", device.MacAddress),
-		zap.Int64("rowsAffected", result.RowsAffected))
+		zap.String("macAddress", device.MacAddress))
 
 	return nil
 }
@@ -255,9 +293,14 @@ func GetDeviceByID(db *gorm.DB, id string) (*Device, error) {
 	return &device, nil
 }
 
-// DeleteDevice deletes a device
+// DeleteDevice deletes a device together with its custom field values
 func DeleteDevice(db *gorm.DB, id string) error {
-	return db.Delete(&Device{}, "id = ?", id).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&Device{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return DeleteCustomFieldValues(tx, CustomFieldEntityDevice, id)
+	})
 }
 
 // GetUserDevices 获取用户的设备列表（支持组织权限）
@@ -315,6 +358,28 @@ func UpdateDeviceOnlineStatus(db *gorm.DB, macAddress string, isOnline bool) err
 	return UpdateDeviceStatus(db, macAddress, updates)
 }
 
+// MarkStaleDevicesOffline 将在线但 cutoff 之后没有上报的设备标记为离线并返回这些设备，
+// 每台设备只在由在线转为离线时返回一次
+func MarkStaleDevicesOffline(db *gorm.DB, cutoff time.Time, limit int) ([]Device, error) {
+	var devices []Device
+	err := db.Where("is_online = ? AND last_seen < ?", true, cutoff).Order("last_seen").Limit(limit).Find(&devices).Error
+	if err != nil || len(devices) == 0 {
+		return nil, err
+	}
+	marked := devices[:0]
+	for _, device := range devices {
+		result := db.Model(&Device{}).Where("id = ? AND is_online = ?", device.ID, true).Update("is_online", false)
+		if result.Error != nil {
+			return marked, result.Error
+		}
+		if result.RowsAffected == 1 {
+			device.IsOnline = false
+			marked = append(marked, device)
+		}
+	}
+	return marked, nil
+}
+
 // DeviceReportReq represents device report request
 type DeviceReportReq struct {
 	Version             *FlexibleInt           `json:"version,omitempty"`
@@ -370,12 +435,15 @@ type BoardInfo struct {
 
 // DeviceReportResp represents device report response
 type DeviceReportResp struct {
-	ServerTime *ServerTime `json:"server_time,omitempty"`
-	Activation *Activation `json:"activation,omitempty"`
-	Error      string      `json:"error,omitempty"`
-	Firmware   *Firmware   `json:"firmware,omitempty"`
-	Websocket  *Websocket  `json:"websocket,omitempty"`
-	MQTT       *MQTT       `json:"mqtt,omitempty"`
+	ServerTime *ServerTime      `json:"server_time,omitempty"`
+	Activation *Activation      `json:"activation,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Firmware   *Firmware        `json:"firmware,omitempty"`
+	Websocket  *Websocket       `json:"websocket,omitempty"`
+	MQTT       *MQTT            `json:"mqtt,omitempty"`
+	Region     *RegionEndpoints `json:"region,omitempty"` // 设备固定地区的 SIP/RTP 端点
+	// ActionKeys 操作清单验证公钥，设备据此更新本地密钥环以跟随密钥轮换
+	ActionKeys []actionmanifest.VerificationKey `json:"action_keys,omitempty"`
 }
 
 type ServerTime struct {
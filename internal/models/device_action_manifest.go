@@ -0,0 +1,353 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/actionmanifest"
+	"gorm.io/gorm"
+)
+
+// 签名密钥状态
+const (
+	DeviceSigningKeyActive   = "active"   // 当前用于签发清单
+	DeviceSigningKeyRetiring = "retiring" // 已轮换，宽限期内仍发布给设备用于验证
+)
+
+// 操作清单状态
+const (
+	ManifestIssued    = "issued"    // 已签发，等待设备拉取（破坏性操作等待维护窗口）
+	ManifestDelivered = "delivered" // 设备已拉取
+	ManifestSucceeded = "succeeded" // 设备回执：执行成功
+	ManifestFailed    = "failed"    // 设备回执：执行失败
+	ManifestRejected  = "rejected"  // 设备回执：验证未通过，拒绝执行
+)
+
+const (
+	// DeviceSigningKeyGrace 轮换后旧密钥继续发布的时长，需覆盖设备两次 OTA 检查的间隔和清单最长有效期
+	DeviceSigningKeyGrace = 14 * 24 * time.Hour
+	// DefaultManifestTTL 清单默认有效期
+	DefaultManifestTTL = time.Hour
+	// MaxManifestTTL 清单最长有效期，破坏性操作可能要等到维护窗口才下发
+	MaxManifestTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrManifestNotFound        = errors.New("action manifest not found")
+	ErrManifestReceiptRecorded = errors.New("action manifest already has a receipt")
+	ErrInvalidManifestReceipt  = errors.New("invalid receipt status")
+)
+
+// DeviceSigningKey 签发设备操作清单的 Ed25519 密钥。同一时间只有一把 active 密钥，
+// 轮换后旧密钥在宽限期内仍随 OTA 响应发布，设备据此验证轮换前签发的清单
+type DeviceSigningKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	KeyID      string     `json:"keyId" gorm:"size:32;uniqueIndex"`
+	PublicKey  string     `json:"publicKey" gorm:"size:64"` // base64
+	PrivateKey string     `json:"-" gorm:"size:128"`        // base64 Ed25519 私钥
+	Status     string     `json:"status" gorm:"size:16;index"`
+	RotatedAt  *time.Time `json:"rotatedAt,omitempty"`
+	NotAfter   *time.Time `json:"notAfter,omitempty"` // 停止发布的时间，active 密钥为空
+}
+
+func (DeviceSigningKey) TableName() string {
+	return "device_signing_keys"
+}
+
+// VerificationKey 发布给设备的公钥
+func (k *DeviceSigningKey) VerificationKey() actionmanifest.VerificationKey {
+	v := actionmanifest.VerificationKey{
+		ID:        k.KeyID,
+		Algorithm: actionmanifest.Algorithm,
+		PublicKey: k.PublicKey,
+	}
+	if k.NotAfter != nil {
+		v.NotAfter = k.NotAfter.Unix()
+	}
+	return v
+}
+
+func (k *DeviceSigningKey) privateKey() (ed25519.PrivateKey, error) {
+	priv, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing key %s is corrupt", k.KeyID)
+	}
+	return priv, nil
+}
+
+func newDeviceSigningKey() (*DeviceSigningKey, error) {
+	kid, pub, priv, err := actionmanifest.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceSigningKey{
+		KeyID:      kid,
+		PublicKey:  actionmanifest.PublicKeyString(pub),
+		PrivateKey: base64.StdEncoding.EncodeToString(priv),
+		Status:     DeviceSigningKeyActive,
+	}, nil
+}
+
+// ActiveDeviceSigningKey 当前签发密钥，首次使用时生成
+func ActiveDeviceSigningKey(db *gorm.DB) (*DeviceSigningKey, error) {
+	var key DeviceSigningKey
+	err := db.Where("status = ?", DeviceSigningKeyActive).Order("id DESC").First(&key).Error
+	if err == nil {
+		return &key, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	created, err := newDeviceSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Create(created).Error; err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// RotateDeviceSigningKey 生成新的签发密钥，旧密钥进入宽限期
+func RotateDeviceSigningKey(db *gorm.DB, now time.Time) (*DeviceSigningKey, error) {
+	created, err := newDeviceSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		notAfter := now.Add(DeviceSigningKeyGrace)
+		if err := tx.Model(&DeviceSigningKey{}).Where("status = ?", DeviceSigningKeyActive).
+			Updates(map[string]any{"status": DeviceSigningKeyRetiring, "rotated_at": now, "not_after": notAfter}).Error; err != nil {
+			return err
+		}
+		return tx.Create(created).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ListDeviceSigningKeys 当前密钥及仍在宽限期内的旧密钥，最新的在前
+func ListDeviceSigningKeys(db *gorm.DB, now time.Time) ([]DeviceSigningKey, error) {
+	var keys []DeviceSigningKey
+	err := db.Where("status = ? OR (status = ? AND not_after > ?)", DeviceSigningKeyActive, DeviceSigningKeyRetiring, now).
+		Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// PublishedVerificationKeys 发布给设备的验证公钥，没有密钥时先生成
+func PublishedVerificationKeys(db *gorm.DB, now time.Time) ([]actionmanifest.VerificationKey, error) {
+	if _, err := ActiveDeviceSigningKey(db); err != nil {
+		return nil, err
+	}
+	keys, err := ListDeviceSigningKeys(db, now)
+	if err != nil {
+		return nil, err
+	}
+	published := make([]actionmanifest.VerificationKey, 0, len(keys))
+	for i := range keys {
+		published = append(published, keys[i].VerificationKey())
+	}
+	return published, nil
+}
+
+// DeviceActionManifest 签发给设备的操作清单及设备的执行回执。设备只执行清单中
+// 预定义的操作，不提供任意远程 shell
+type DeviceActionManifest struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	ManifestID   string     `json:"manifestId" gorm:"size:40;uniqueIndex"`
+	DeviceID     string     `json:"deviceId" gorm:"size:64;index"`
+	GroupID      uint       `json:"groupId,omitempty" gorm:"index"`
+	ActionID     uint       `json:"actionId,omitempty" gorm:"index"` // 走维护窗口排队的 DeviceAction
+	Action       string     `json:"action" gorm:"size:32"`
+	Params       string     `json:"params,omitempty" gorm:"type:text"`
+	KeyID        string     `json:"keyId" gorm:"size:32"`
+	Payload      string     `json:"-" gorm:"type:text"` // 签名的清单内容（base64url）
+	Signature    string     `json:"-" gorm:"size:128"`
+	IssuedAt     time.Time  `json:"issuedAt"`
+	ExpiresAt    time.Time  `json:"expiresAt" gorm:"index"`
+	CreatedBy    uint       `json:"createdBy"`
+	Status       string     `json:"status" gorm:"size:16;index"`
+	DeliveredAt  *time.Time `json:"deliveredAt,omitempty"`
+	ExecutedAt   *time.Time `json:"executedAt,omitempty"` // 设备上报的执行时间
+	ReceiptAt    *time.Time `json:"receiptAt,omitempty"`
+	ReceiptError string     `json:"receiptError,omitempty" gorm:"size:512"` // 失败或拒绝的原因
+	ReceiptData  string     `json:"receiptData,omitempty" gorm:"type:text"` // 设备返回的结果（JSON）
+}
+
+func (DeviceActionManifest) TableName() string {
+	return "device_action_manifests"
+}
+
+// Signed 下发给设备的签名清单
+func (m *DeviceActionManifest) Signed() *actionmanifest.Signed {
+	return &actionmanifest.Signed{KeyID: m.KeyID, Payload: m.Payload, Signature: m.Signature}
+}
+
+// IssueDeviceActionManifest 校验操作和参数、用当前密钥签发清单，并作为设备操作提交：
+// 破坏性操作在维护窗口外排队，窗口打开后设备才能拉取到
+func IssueDeviceActionManifest(db *gorm.DB, device *Device, action string, params json.RawMessage, ttl time.Duration, createdBy uint, now time.Time) (*DeviceActionManifest, error) {
+	spec, err := actionmanifest.Validate(action, params)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultManifestTTL
+	}
+	if ttl > MaxManifestTTL {
+		return nil, fmt.Errorf("manifest ttl must not exceed %s", MaxManifestTTL)
+	}
+	key, err := ActiveDeviceSigningKey(db)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := key.privateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := actionmanifest.Manifest{
+		ID:        actionmanifest.NewManifestID(),
+		DeviceID:  device.ID,
+		Action:    action,
+		Params:    params,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	signed, err := actionmanifest.Sign(manifest, key.KeyID, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &DeviceActionManifest{
+		ManifestID: manifest.ID,
+		DeviceID:   device.ID,
+		Action:     action,
+		Params:     string(params),
+		KeyID:      key.KeyID,
+		Payload:    signed.Payload,
+		Signature:  signed.Signature,
+		IssuedAt:   time.Unix(manifest.IssuedAt, 0),
+		ExpiresAt:  time.Unix(manifest.ExpiresAt, 0),
+		CreatedBy:  createdBy,
+		Status:     ManifestIssued,
+	}
+	if device.GroupID != nil {
+		m.GroupID = *device.GroupID
+	}
+	if err := db.Create(m).Error; err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(signed)
+	deviceAction := DeviceAction{
+		GroupID:    m.GroupID,
+		DeviceID:   device.ID,
+		Kind:       DeviceActionCommand,
+		Disruptive: spec.Disruptive,
+		Payload:    string(payload),
+		CreatedBy:  createdBy,
+	}
+	if err := SubmitDeviceAction(db, &deviceAction, now); err != nil {
+		return nil, err
+	}
+	m.ActionID = deviceAction.ID
+	if err := db.Model(m).Update("action_id", deviceAction.ID).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PendingDeviceActionManifests 设备可执行的清单：未过期、未回执，且对应的设备操作
+// 已执行（不在维护窗口外排队、未取消）。返回的清单标记为已拉取
+func PendingDeviceActionManifests(db *gorm.DB, deviceID string, now time.Time) ([]DeviceActionManifest, error) {
+	var manifests []DeviceActionManifest
+	err := db.Where("device_id = ? AND status IN ? AND expires_at > ?", deviceID, []string{ManifestIssued, ManifestDelivered}, now).
+		Where("action_id IN (?)", db.Model(&DeviceAction{}).Select("id").Where("status = ?", DeviceActionExecuted)).
+		Order("id").Find(&manifests).Error
+	if err != nil {
+		return nil, err
+	}
+	var fresh []uint
+	for i := range manifests {
+		if manifests[i].Status == ManifestIssued {
+			fresh = append(fresh, manifests[i].ID)
+			manifests[i].Status = ManifestDelivered
+			manifests[i].DeliveredAt = &now
+		}
+	}
+	if len(fresh) > 0 {
+		if err := db.Model(&DeviceActionManifest{}).Where("id IN ? AND status = ?", fresh, ManifestIssued).
+			Updates(map[string]any{"status": ManifestDelivered, "delivered_at": now}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return manifests, nil
+}
+
+// ManifestReceipt 设备上报的执行回执
+type ManifestReceipt struct {
+	Status     string          `json:"status"`               // succeeded、failed 或 rejected
+	Error      string          `json:"error,omitempty"`      // 失败或拒绝的原因
+	Result     json.RawMessage `json:"result,omitempty"`     // 操作结果，如诊断包编号
+	ExecutedAt int64           `json:"executedAt,omitempty"` // 设备执行时间（Unix 秒）
+}
+
+// RecordManifestReceipt 记录设备的执行回执，每份清单只接受一次
+func RecordManifestReceipt(db *gorm.DB, deviceID, manifestID string, receipt *ManifestReceipt, now time.Time) (*DeviceActionManifest, error) {
+	switch receipt.Status {
+	case ManifestSucceeded, ManifestFailed, ManifestRejected:
+	default:
+		return nil, ErrInvalidManifestReceipt
+	}
+	var m DeviceActionManifest
+	err := db.Where("manifest_id = ? AND device_id = ?", manifestID, deviceID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrManifestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]any{
+		"status":        receipt.Status,
+		"receipt_at":    now,
+		"receipt_error": truncateRunes(receipt.Error, 512),
+		"receipt_data":  string(receipt.Result),
+	}
+	executedAt := now
+	if receipt.ExecutedAt > 0 {
+		executedAt = time.Unix(receipt.ExecutedAt, 0)
+	}
+	if receipt.Status != ManifestRejected {
+		updates["executed_at"] = executedAt
+	}
+	res := db.Model(&DeviceActionManifest{}).
+		Where("id = ? AND status IN ?", m.ID, []string{ManifestIssued, ManifestDelivered}).
+		Updates(updates)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrManifestReceiptRecorded
+	}
+	if err := db.First(&m, m.ID).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListDeviceActionManifests 设备最近的操作清单
+func ListDeviceActionManifests(db *gorm.DB, deviceID string, limit int) ([]DeviceActionManifest, error) {
+	var manifests []DeviceActionManifest
+	err := db.Where("device_id = ?", deviceID).Order("id DESC").Limit(limit).Find(&manifests).Error
+	return manifests, err
+}
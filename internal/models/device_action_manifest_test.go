@@ -0,0 +1,136 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/actionmanifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateDeviceSigningKey(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceSigningKey{})
+	now := time.Now()
+
+	first, err := ActiveDeviceSigningKey(db)
+	require.NoError(t, err)
+	again, err := ActiveDeviceSigningKey(db)
+	require.NoError(t, err)
+	assert.Equal(t, first.KeyID, again.KeyID)
+
+	second, err := RotateDeviceSigningKey(db, now)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.KeyID, second.KeyID)
+
+	published, err := PublishedVerificationKeys(db, now)
+	require.NoError(t, err)
+	require.Len(t, published, 2)
+	assert.Equal(t, second.KeyID, published[0].ID)
+	assert.Zero(t, published[0].NotAfter)
+	assert.Equal(t, first.KeyID, published[1].ID)
+	assert.Equal(t, now.Add(DeviceSigningKeyGrace).Unix(), published[1].NotAfter)
+
+	// After the grace period only the active key is published
+	published, err = PublishedVerificationKeys(db, now.Add(DeviceSigningKeyGrace+time.Minute))
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	assert.Equal(t, second.KeyID, published[0].ID)
+}
+
+func TestIssueDeviceActionManifest(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceSigningKey{}, &DeviceActionManifest{}, &DeviceAction{}, &MaintenanceWindow{})
+	device := &Device{ID: "aa:bb:cc:dd:ee:ff"}
+	now := time.Now()
+
+	m, err := IssueDeviceActionManifest(db, device, actionmanifest.ActionSetVolume, json.RawMessage(`{"volume": 30}`), 0, 1, now)
+	require.NoError(t, err)
+	assert.Equal(t, ManifestIssued, m.Status)
+	assert.NotZero(t, m.ActionID)
+	assert.WithinDuration(t, now.Add(DefaultManifestTTL), m.ExpiresAt, time.Second)
+
+	var action DeviceAction
+	require.NoError(t, db.First(&action, m.ActionID).Error)
+	assert.Equal(t, DeviceActionExecuted, action.Status)
+
+	// The device verifies the delivered manifest with the published keys
+	published, err := PublishedVerificationKeys(db, now)
+	require.NoError(t, err)
+	verified, err := actionmanifest.NewKeyring(published).Verify(m.Signed(), device.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, m.ManifestID, verified.ID)
+	assert.JSONEq(t, `{"volume": 30}`, string(verified.Params))
+
+	_, err = IssueDeviceActionManifest(db, device, "shell", json.RawMessage(`{"cmd": "id"}`), 0, 1, now)
+	assert.ErrorIs(t, err, actionmanifest.ErrUnknownAction)
+	_, err = IssueDeviceActionManifest(db, device, actionmanifest.ActionClearCache, nil, MaxManifestTTL+time.Hour, 1, now)
+	assert.Error(t, err)
+}
+
+func TestPendingDeviceActionManifests(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceSigningKey{}, &DeviceActionManifest{}, &DeviceAction{}, &MaintenanceWindow{})
+	groupID := uint(5)
+	device := &Device{ID: "aa:bb:cc:dd:ee:ff", GroupID: &groupID}
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	// A closed maintenance window holds back the disruptive reboot
+	require.NoError(t, db.Create(&MaintenanceWindow{GroupID: groupID, Name: "night", Enabled: true, Timezone: "UTC", Schedule: "0 2 * * *", DurationMinutes: 60}).Error)
+	reboot, err := IssueDeviceActionManifest(db, device, actionmanifest.ActionReboot, nil, 24*time.Hour, 1, now)
+	require.NoError(t, err)
+	volume, err := IssueDeviceActionManifest(db, device, actionmanifest.ActionSetVolume, json.RawMessage(`{"volume": 30}`), 0, 1, now)
+	require.NoError(t, err)
+
+	pending, err := PendingDeviceActionManifests(db, device.ID, now)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, volume.ManifestID, pending[0].ManifestID)
+	assert.Equal(t, ManifestDelivered, pending[0].Status)
+
+	// Once the window opens the reboot is dispatched
+	windowOpen := time.Date(2026, 3, 3, 2, 10, 0, 0, time.UTC)
+	_, err = DispatchDueDeviceActions(db, windowOpen)
+	require.NoError(t, err)
+	pending, err = PendingDeviceActionManifests(db, device.ID, windowOpen)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "the volume manifest has expired")
+	assert.Equal(t, reboot.ManifestID, pending[0].ManifestID)
+	pending, err = PendingDeviceActionManifests(db, "11:22:33:44:55:66", windowOpen)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRecordManifestReceipt(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceSigningKey{}, &DeviceActionManifest{}, &DeviceAction{}, &MaintenanceWindow{})
+	device := &Device{ID: "aa:bb:cc:dd:ee:ff"}
+	now := time.Now()
+	m, err := IssueDeviceActionManifest(db, device, actionmanifest.ActionClearCache, nil, 0, 1, now)
+	require.NoError(t, err)
+
+	_, err = RecordManifestReceipt(db, "11:22:33:44:55:66", m.ManifestID, &ManifestReceipt{Status: ManifestSucceeded}, now)
+	assert.ErrorIs(t, err, ErrManifestNotFound)
+	_, err = RecordManifestReceipt(db, device.ID, m.ManifestID, &ManifestReceipt{Status: "done"}, now)
+	assert.ErrorIs(t, err, ErrInvalidManifestReceipt)
+
+	executed := now.Add(-time.Minute).Unix()
+	stored, err := RecordManifestReceipt(db, device.ID, m.ManifestID, &ManifestReceipt{
+		Status:     ManifestSucceeded,
+		Result:     json.RawMessage(`{"freedBytes": 1024}`),
+		ExecutedAt: executed,
+	}, now)
+	require.NoError(t, err)
+	assert.Equal(t, ManifestSucceeded, stored.Status)
+	require.NotNil(t, stored.ExecutedAt)
+	assert.Equal(t, executed, stored.ExecutedAt.Unix())
+	assert.JSONEq(t, `{"freedBytes": 1024}`, stored.ReceiptData)
+
+	_, err = RecordManifestReceipt(db, device.ID, m.ManifestID, &ManifestReceipt{Status: ManifestFailed}, now)
+	assert.ErrorIs(t, err, ErrManifestReceiptRecorded)
+
+	manifests, err := ListDeviceActionManifests(db, device.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	pending, err := PendingDeviceActionManifests(db, device.ID, now)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
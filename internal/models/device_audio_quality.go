@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/audioquality"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// DefaultAudioQualityThreshold 未配置 AUDIO_QUALITY_THRESHOLD 时的更换阈值
+const DefaultAudioQualityThreshold = 60
+
+// DeviceAudioTest 设备上传的一次麦克风测试录音的分析结果
+type DeviceAudioTest struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	DeviceID       string    `json:"deviceId" gorm:"size:64;index"`
+	UserID         uint      `json:"userId" gorm:"index"`
+	Score          int       `json:"score"`
+	Threshold      int       `json:"threshold"` // 测试时生效的阈值
+	Passed         bool      `json:"passed"`
+	SampleRate     int       `json:"sampleRate"`
+	Duration       float64   `json:"duration"` // 秒
+	NoiseFloorDBFS float64   `json:"noiseFloorDbfs"`
+	SNRDB          float64   `json:"snrDb"`
+	ClippingRatio  float64   `json:"clippingRatio"`
+	Issues         string    `json:"issues,omitempty" gorm:"size:255"` // 逗号分隔
+	Report         string    `json:"report" gorm:"type:text"`          // 完整分析报告 JSON，含各频段响应
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+}
+
+// TableName 指定表名
+func (DeviceAudioTest) TableName() string {
+	return "device_audio_tests"
+}
+
+// AudioQualityThreshold 设备更换阈值
+func AudioQualityThreshold(db *gorm.DB) int {
+	return utils.GetIntValue(db, constants.KEY_AUDIO_QUALITY_THRESHOLD, DefaultAudioQualityThreshold)
+}
+
+// RecordDeviceAudioTest 保存测试结果并更新设备的质量得分，得分低于阈值时标记设备待更换。
+// flagged 表示设备本次新被标记，用于只告警一次
+func RecordDeviceAudioTest(db *gorm.DB, device *Device, report *audioquality.Report, threshold int) (test *DeviceAudioTest, flagged bool, err error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, false, err
+	}
+	test = &DeviceAudioTest{
+		DeviceID:       device.ID,
+		UserID:         device.UserID,
+		Score:          report.Score,
+		Threshold:      threshold,
+		Passed:         report.Score >= threshold,
+		SampleRate:     report.SampleRate,
+		Duration:       report.Duration,
+		NoiseFloorDBFS: report.NoiseFloorDBFS,
+		SNRDB:          report.SNRDB,
+		ClippingRatio:  report.ClippingRatio,
+		Issues:         strings.Join(report.Issues, ","),
+		Report:         string(data),
+	}
+
+	flagged = !test.Passed && !device.NeedsReplacement
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(test).Error; err != nil {
+			return err
+		}
+		now := test.CreatedAt
+		score := report.Score
+		if err := tx.Model(device).Updates(map[string]interface{}{
+			"audio_quality_score": score,
+			"audio_quality_at":    now,
+			"needs_replacement":   !test.Passed,
+		}).Error; err != nil {
+			return err
+		}
+		device.AudioQualityScore = &score
+		device.AudioQualityAt = &now
+		device.NeedsReplacement = !test.Passed
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return test, flagged, nil
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/audioquality"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordDeviceAudioTest(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{}, &DeviceAudioTest{}, &utils.Config{})
+	device := &Device{ID: "aa:bb:cc:dd:ee:01", MacAddress: "aa:bb:cc:dd:ee:01", UserID: 1}
+	require.NoError(t, db.Create(device).Error)
+	assert.Equal(t, DefaultAudioQualityThreshold, AudioQualityThreshold(db))
+
+	test, flagged, err := RecordDeviceAudioTest(db, device, &audioquality.Report{Score: 40, Issues: []string{audioquality.IssueClipping, audioquality.IssueLowSNR}}, 60)
+	require.NoError(t, err)
+	assert.True(t, flagged)
+	assert.False(t, test.Passed)
+	assert.Equal(t, "clipping,low_snr", test.Issues)
+
+	// Still failing: the device stays flagged but is not reported again
+	_, flagged, err = RecordDeviceAudioTest(db, device, &audioquality.Report{Score: 45}, 60)
+	require.NoError(t, err)
+	assert.False(t, flagged)
+
+	stored, err := GetDeviceByID(db, device.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.NeedsReplacement)
+	require.NotNil(t, stored.AudioQualityScore)
+	assert.Equal(t, 45, *stored.AudioQualityScore)
+
+	// A passing test after a repair clears the flag
+	test, flagged, err = RecordDeviceAudioTest(db, stored, &audioquality.Report{Score: 92}, 60)
+	require.NoError(t, err)
+	assert.False(t, flagged)
+	assert.True(t, test.Passed)
+	stored, err = GetDeviceByID(db, device.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.NeedsReplacement)
+
+	var count int64
+	db.Model(&DeviceAudioTest{}).Where("device_id = ?", device.ID).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
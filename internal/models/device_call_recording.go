@@ -112,6 +112,15 @@ type CallRecording struct {
 	LLMModel                string     `json:"llmModel" gorm:"size:128"`                              // 使用的LLM模型
 	TTSProvider             string     `json:"ttsProvider" gorm:"size:64"`                            // TTS提供商
 	ASRProvider             string     `json:"asrProvider" gorm:"size:64"`                            // ASR提供商
+	EscalationTicketID      string     `json:"escalationTicketId,omitempty" gorm:"size:128;index"`    // 升级工单ID
+	EscalationTicketURL     string     `json:"escalationTicketUrl,omitempty" gorm:"size:512"`         // 升级工单链接
+	AudioSHA256             string     `json:"audioSha256,omitempty" gorm:"size:64"`                  // 录音定稿时的 SHA-256，用于证明未被篡改
+	HashedAt                *time.Time `json:"hashedAt,omitempty" gorm:"index"`                       // 计算哈希的时间
+	PromptReleaseID         *uint      `json:"promptReleaseId,omitempty" gorm:"index"`                // 通话所属的提示词金丝雀发布
+	PromptArm               string     `json:"promptArm,omitempty" gorm:"size:16"`                    // 金丝雀分组: baseline, candidate
+
+	// 成本明细，通话结束后按价格表计算
+	CallCost `gorm:"embedded"`
 }
 
 func (CallRecording) TableName() string {
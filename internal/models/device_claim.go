@@ -0,0 +1,256 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// TechnicianTokenField 上下文中保存当前现场技术员令牌的键
+	TechnicianTokenField = "technician_token"
+	// technicianTokenPrefix 令牌前缀，便于在日志或密钥扫描中识别
+	technicianTokenPrefix = "tech_"
+	// TechnicianTokenTTL 令牌有效期，覆盖一个工作班次，过期后需重新通过 SSO 登录
+	TechnicianTokenTTL = 12 * time.Hour
+)
+
+// 技术员令牌允许的操作
+const (
+	TechnicianScopeClaim       = "device:claim"
+	TechnicianScopeDiagnostics = "device:diagnostics"
+)
+
+// TechnicianScopes 通过认领门户签发的令牌权限，只能认领和查看组织内设备的诊断信息
+var TechnicianScopes = []string{TechnicianScopeClaim, TechnicianScopeDiagnostics}
+
+var (
+	// ErrTechnicianTokenInvalid 令牌不存在、已撤销或已过期
+	ErrTechnicianTokenInvalid = errors.New("invalid or expired technician token")
+	// ErrTechnicianScope 令牌没有该操作的权限
+	ErrTechnicianScope = errors.New("technician token does not allow this operation")
+)
+
+// TechnicianToken 现场技术员通过组织 SSO 登录设备认领门户后获得的令牌，只保存哈希。
+// 令牌只能在签发的组织内认领设备和查看诊断信息，不能访问控制台的其他接口
+type TechnicianToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	GroupID     uint       `json:"groupId" gorm:"index"`
+	UserID      uint       `json:"userId" gorm:"index"`
+	Email       string     `json:"email" gorm:"size:128"`
+	TokenHash   string     `json:"-" gorm:"size:64;uniqueIndex"`
+	TokenPrefix string     `json:"tokenPrefix" gorm:"size:16"`
+	Scopes      string     `json:"scopes" gorm:"size:128"` // 逗号分隔
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy   uint       `json:"revokedBy,omitempty"`
+}
+
+// TableName 指定表名
+func (TechnicianToken) TableName() string {
+	return "technician_tokens"
+}
+
+// DeviceClaim 技术员认领设备的记录，撤销时解绑设备
+type DeviceClaim struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime;index"`
+	GroupID      uint       `json:"groupId" gorm:"index"`
+	TechnicianID uint       `json:"technicianId" gorm:"index"` // 认领的技术员用户
+	TokenID      uint       `json:"tokenId" gorm:"index"`
+	DeviceID     string     `json:"deviceId" gorm:"size:64;index"`
+	MacAddress   string     `json:"macAddress" gorm:"size:64"`
+	AssistantID  uint       `json:"assistantId"`
+	Board        string     `json:"board" gorm:"size:128"`
+	IPAddress    string     `json:"ipAddress" gorm:"size:128"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy    uint       `json:"revokedBy,omitempty"`
+}
+
+// TableName 指定表名
+func (DeviceClaim) TableName() string {
+	return "device_claims"
+}
+
+func hashTechnicianToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Allows 令牌是否包含该权限
+func (t *TechnicianToken) Allows(scope string) bool {
+	for _, s := range splitCSV(t.Scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active 令牌在 now 时是否可用
+func (t *TechnicianToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// IssueTechnicianToken 为通过组织 SSO 登录的技术员签发令牌，返回只显示一次的明文令牌
+func IssueTechnicianToken(db *gorm.DB, groupID uint, user *User, now time.Time) (*TechnicianToken, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	plain := technicianTokenPrefix + hex.EncodeToString(buf)
+	token := &TechnicianToken{
+		GroupID:     groupID,
+		UserID:      user.ID,
+		Email:       user.Email,
+		TokenHash:   hashTechnicianToken(plain),
+		TokenPrefix: plain[:len(technicianTokenPrefix)+6],
+		Scopes:      strings.Join(TechnicianScopes, ","),
+		ExpiresAt:   now.Add(TechnicianTokenTTL),
+	}
+	if err := db.Create(token).Error; err != nil {
+		return nil, "", err
+	}
+	return token, plain, nil
+}
+
+// FindTechnicianToken 按明文令牌查找可用的令牌
+func FindTechnicianToken(db *gorm.DB, plain string, now time.Time) (*TechnicianToken, error) {
+	if !strings.HasPrefix(plain, technicianTokenPrefix) {
+		return nil, ErrTechnicianTokenInvalid
+	}
+	var token TechnicianToken
+	if err := db.Where("token_hash = ?", hashTechnicianToken(plain)).First(&token).Error; err != nil {
+		return nil, ErrTechnicianTokenInvalid
+	}
+	if !token.Active(now) {
+		return nil, ErrTechnicianTokenInvalid
+	}
+	return &token, nil
+}
+
+// RevokeTechnicianTokens 撤销组织内的令牌；tokenID 为 0 时撤销该技术员的全部令牌，返回撤销的数量
+func RevokeTechnicianTokens(db *gorm.DB, groupID, technicianID, tokenID, revokedBy uint, now time.Time) (int64, error) {
+	q := db.Model(&TechnicianToken{}).Where("group_id = ? AND revoked_at IS NULL", groupID)
+	if tokenID != 0 {
+		q = q.Where("id = ?", tokenID)
+	} else {
+		q = q.Where("user_id = ?", technicianID)
+	}
+	res := q.Updates(map[string]any{"revoked_at": now, "revoked_by": revokedBy})
+	return res.RowsAffected, res.Error
+}
+
+// ListTechnicianTokens 组织签发的令牌，technicianID 不为 0 时只看该技术员
+func ListTechnicianTokens(db *gorm.DB, groupID, technicianID uint) ([]TechnicianToken, error) {
+	q := db.Where("group_id = ?", groupID)
+	if technicianID != 0 {
+		q = q.Where("user_id = ?", technicianID)
+	}
+	var tokens []TechnicianToken
+	err := q.Order("id DESC").Limit(200).Find(&tokens).Error
+	return tokens, err
+}
+
+// RecordDeviceClaim 保存认领记录
+func RecordDeviceClaim(db *gorm.DB, claim *DeviceClaim) error {
+	return db.Create(claim).Error
+}
+
+// ListDeviceClaims 组织的认领历史，technicianID 不为 0 时只看该技术员
+func ListDeviceClaims(db *gorm.DB, groupID, technicianID uint, page, size int) ([]DeviceClaim, int64, error) {
+	q := db.Model(&DeviceClaim{}).Where("group_id = ?", groupID)
+	if technicianID != 0 {
+		q = q.Where("technician_id = ?", technicianID)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var claims []DeviceClaim
+	err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&claims).Error
+	return claims, total, err
+}
+
+// RevokeDeviceClaim 撤销认领并解绑设备；设备已被重新认领时只标记记录
+func RevokeDeviceClaim(db *gorm.DB, groupID, claimID, revokedBy uint, now time.Time) (*DeviceClaim, error) {
+	var claim DeviceClaim
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND group_id = ?", claimID, groupID).First(&claim).Error; err != nil {
+			return err
+		}
+		if claim.RevokedAt != nil {
+			return nil
+		}
+		var newer int64
+		if err := tx.Model(&DeviceClaim{}).Where("device_id = ? AND id > ?", claim.DeviceID, claim.ID).Count(&newer).Error; err != nil {
+			return err
+		}
+		if newer == 0 {
+			if err := tx.Where("id = ? AND group_id = ?", claim.DeviceID, groupID).Delete(&Device{}).Error; err != nil {
+				return err
+			}
+			if err := DeleteCustomFieldValues(tx, CustomFieldEntityDevice, claim.DeviceID); err != nil {
+				return err
+			}
+		}
+		claim.RevokedAt = &now
+		claim.RevokedBy = revokedBy
+		return tx.Model(&claim).Updates(map[string]any{"revoked_at": now, "revoked_by": revokedBy}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// CurrentTechnicianToken 当前请求的技术员令牌
+func CurrentTechnicianToken(c *gin.Context) *TechnicianToken {
+	if v, ok := c.Get(TechnicianTokenField); ok {
+		if token, ok := v.(*TechnicianToken); ok {
+			return token
+		}
+	}
+	return nil
+}
+
+// TechnicianAuthRequired 校验 Authorization: Bearer <技术员令牌>，并确认技术员仍是启用的组织成员。
+// 该中间件不会设置控制台用户，认领门户以外的接口无法使用这类令牌
+func TechnicianAuthRequired(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := c.MustGet(constants.DbField).(*gorm.DB)
+		plain, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "msg": "bearer token required"})
+			return
+		}
+		now := time.Now()
+		token, err := FindTechnicianToken(db, strings.TrimSpace(plain), now)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "msg": err.Error()})
+			return
+		}
+		user, err := GetUserByUID(db, token.UserID)
+		if err != nil || !user.Enabled || !IsGroupMember(db, &Group{ID: token.GroupID}, user.ID) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "msg": ErrTechnicianTokenInvalid.Error()})
+			return
+		}
+		if scope != "" && !token.Allows(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": ErrTechnicianScope.Error()})
+			return
+		}
+		db.Model(token).UpdateColumn("last_used_at", &now)
+		c.Set(TechnicianTokenField, token)
+		c.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTechnicianToken_IssueFindRevoke(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &TechnicianToken{})
+	now := time.Now()
+	user := &User{Email: "tech@example.com"}
+	user.ID = 7
+
+	token, plain, err := IssueTechnicianToken(db, 3, user, now)
+	require.NoError(t, err)
+	assert.True(t, token.Allows(TechnicianScopeClaim))
+	assert.True(t, token.Allows(TechnicianScopeDiagnostics))
+	assert.False(t, token.Allows("device:delete"))
+
+	found, err := FindTechnicianToken(db, plain, now)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+	_, err = FindTechnicianToken(db, plain, now.Add(TechnicianTokenTTL+time.Second))
+	assert.ErrorIs(t, err, ErrTechnicianTokenInvalid)
+	_, err = FindTechnicianToken(db, "scim_"+plain, now)
+	assert.ErrorIs(t, err, ErrTechnicianTokenInvalid)
+
+	_, other, err := IssueTechnicianToken(db, 3, user, now)
+	require.NoError(t, err)
+	// Another organization cannot revoke the technician's tokens
+	n, err := RevokeTechnicianTokens(db, 4, user.ID, 0, 1, now)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	n, err = RevokeTechnicianTokens(db, 3, 0, token.ID, 1, now)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = FindTechnicianToken(db, plain, now)
+	assert.ErrorIs(t, err, ErrTechnicianTokenInvalid)
+	_, err = FindTechnicianToken(db, other, now)
+	require.NoError(t, err)
+
+	n, err = RevokeTechnicianTokens(db, 3, user.ID, 0, 1, now)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = FindTechnicianToken(db, other, now)
+	assert.ErrorIs(t, err, ErrTechnicianTokenInvalid)
+
+	tokens, err := ListTechnicianTokens(db, 3, user.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.NotNil(t, tokens[0].RevokedAt)
+}
+
+func TestDeviceClaim_HistoryAndRevoke(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceClaim{}, &Device{}, &CustomFieldValue{})
+	groupID := uint(3)
+	now := time.Now()
+	require.NoError(t, db.Create(&Device{ID: "aa:bb", MacAddress: "aa:bb", UserID: 1, GroupID: &groupID}).Error)
+
+	first := &DeviceClaim{GroupID: groupID, TechnicianID: 7, DeviceID: "aa:bb"}
+	require.NoError(t, RecordDeviceClaim(db, first))
+	require.NoError(t, RecordDeviceClaim(db, &DeviceClaim{GroupID: groupID, TechnicianID: 8, DeviceID: "cc:dd"}))
+
+	claims, total, err := ListDeviceClaims(db, groupID, 7, 1, 20)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, claims, 1)
+	_, total, err = ListDeviceClaims(db, groupID, 0, 1, 20)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+
+	_, err = RevokeDeviceClaim(db, 4, first.ID, 1, now)
+	assert.Error(t, err, "claims of other organizations are not visible")
+
+	revoked, err := RevokeDeviceClaim(db, groupID, first.ID, 1, now)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+	var count int64
+	db.Model(&Device{}).Where("id = ?", "aa:bb").Count(&count)
+	assert.Zero(t, count, "revoking unbinds the device")
+}
+
+func TestDeviceClaim_RevokeKeepsReclaimedDevice(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceClaim{}, &Device{})
+	groupID := uint(3)
+	require.NoError(t, db.Create(&Device{ID: "aa:bb", MacAddress: "aa:bb", UserID: 1, GroupID: &groupID}).Error)
+	old := &DeviceClaim{GroupID: groupID, TechnicianID: 7, DeviceID: "aa:bb"}
+	require.NoError(t, RecordDeviceClaim(db, old))
+	require.NoError(t, RecordDeviceClaim(db, &DeviceClaim{GroupID: groupID, TechnicianID: 8, DeviceID: "aa:bb"}))
+
+	_, err := RevokeDeviceClaim(db, groupID, old.ID, 1, time.Now())
+	require.NoError(t, err)
+	var count int64
+	db.Model(&Device{}).Where("id = ?", "aa:bb").Count(&count)
+	assert.EqualValues(t, 1, count)
+}
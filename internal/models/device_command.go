@@ -0,0 +1,433 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/actionmanifest"
+	"gorm.io/gorm"
+)
+
+// 设备控制意图，由助手工具或文本指令解析得到，映射为设备操作清单
+const (
+	DeviceIntentVolumeUp     = "volume_up"
+	DeviceIntentVolumeDown   = "volume_down"
+	DeviceIntentSetVolume    = "set_volume"
+	DeviceIntentMute         = "mute"
+	DeviceIntentReboot       = "reboot"
+	DeviceIntentFactoryReset = "factory_reset"
+	DeviceIntentClearCache   = "clear_cache"
+)
+
+// DeviceIntents 支持的设备控制意图
+var DeviceIntents = []string{
+	DeviceIntentVolumeUp, DeviceIntentVolumeDown, DeviceIntentSetVolume, DeviceIntentMute,
+	DeviceIntentReboot, DeviceIntentFactoryReset, DeviceIntentClearCache,
+}
+
+// 设备指令来源
+const (
+	DeviceCommandSourceChat = "chat" // 文字对话中的助手工具
+	DeviceCommandSourceCall = "call" // 语音通话中的助手工具
+	DeviceCommandSourceAPI  = "api"  // 文本指令接口
+)
+
+// 设备指令状态
+const (
+	DeviceCommandPending   = "pending_confirmation" // 破坏性操作，等待用户确认
+	DeviceCommandIssued    = "issued"               // 已签发操作清单
+	DeviceCommandCancelled = "cancelled"            // 用户取消
+	DeviceCommandExpired   = "expired"              // 超时未确认
+	DeviceCommandFailed    = "failed"               // 签发失败
+)
+
+const (
+	// DeviceCommandConfirmTTL 破坏性操作等待确认的时长
+	DeviceCommandConfirmTTL = 2 * time.Minute
+	// deviceVolumeStep 调大/调小音量的默认步长
+	deviceVolumeStep = 20
+	// deviceDefaultVolume 设备未上报音量时假定的当前音量
+	deviceDefaultVolume = 50
+)
+
+var (
+	ErrDeviceNotFound              = errors.New("no matching device")
+	ErrDeviceAmbiguous             = errors.New("more than one device matches")
+	ErrUnknownDeviceIntent         = errors.New("unknown device command")
+	ErrDeviceCommandNotPending     = errors.New("device command is not awaiting confirmation")
+	ErrDeviceCommandConfirmExpired = errors.New("device command confirmation expired")
+)
+
+// DeviceCommandLog 通过助手或文本指令执行的设备控制记录
+type DeviceCommandLog struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime;index"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID      uint       `json:"userId" gorm:"index;not null"`
+	DeviceID    string     `json:"deviceId" gorm:"size:64;index"`
+	DeviceName  string     `json:"deviceName" gorm:"size:128"`          // 执行时的设备名称
+	Source      string     `json:"source" gorm:"size:16"`               // chat, call, api
+	AssistantID *int64     `json:"assistantId,omitempty" gorm:"index"`  // 通过助手执行时的助手ID
+	Utterance   string     `json:"utterance,omitempty" gorm:"size:512"` // 用户原话
+	Intent      string     `json:"intent" gorm:"size:32"`
+	Action      string     `json:"action" gorm:"size:32"`
+	Params      string     `json:"params,omitempty" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"size:24;index"`
+	ConfirmedAt *time.Time `json:"confirmedAt,omitempty"`
+	ManifestID  string     `json:"manifestId,omitempty" gorm:"size:64"` // 签发的操作清单
+	Error       string     `json:"error,omitempty" gorm:"size:255"`
+}
+
+// TableName 指定表名
+func (DeviceCommandLog) TableName() string {
+	return "device_command_logs"
+}
+
+// DeviceCommandRequest 设备控制请求
+type DeviceCommandRequest struct {
+	UserID      uint
+	AssistantID *int64
+	Source      string
+	Utterance   string
+	Device      string // 设备别名、名称或 MAC 地址
+	Intent      string
+	Value       *int // set_volume 的目标音量，调大/调小时为步长
+}
+
+// DeviceCommandResult 设备控制结果
+type DeviceCommandResult struct {
+	Log               *DeviceCommandLog `json:"log"`
+	Device            *Device           `json:"device"`
+	NeedsConfirmation bool              `json:"needsConfirmation"`
+	Message           string            `json:"message"`
+}
+
+// normalizeDeviceName 统一大小写、空白和常见的称呼词，"the Kitchen device" 与 "kitchen" 视为同一名称
+func normalizeDeviceName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, word := range []string{"the ", " device", " speaker", "设备", "音箱"} {
+		name = strings.ReplaceAll(name, word, "")
+	}
+	return strings.TrimSpace(name)
+}
+
+// deviceLabels 设备可被称呼的名称：别名、设备名称、MAC 地址
+func deviceLabels(d *Device) []string {
+	labels := make([]string, 0, 3)
+	for _, label := range []string{d.Alias, d.DeviceName, d.MacAddress} {
+		if n := normalizeDeviceName(label); n != "" {
+			labels = append(labels, n)
+		}
+	}
+	return labels
+}
+
+// DisplayName 设备对用户展示的名称
+func (d *Device) DisplayName() string {
+	switch {
+	case d.Alias != "":
+		return d.Alias
+	case d.DeviceName != "":
+		return d.DeviceName
+	}
+	return d.MacAddress
+}
+
+// ResolveUserDevice 在用户可管理的设备（自己的设备和组织共享的设备）中按别名、名称或 MAC 地址查找设备。
+// 优先完全匹配，其次部分匹配；匹配到多台时返回 ErrDeviceAmbiguous。用户只有一台设备时名称可以为空
+func ResolveUserDevice(db *gorm.DB, userID uint, name string) (*Device, error) {
+	devices, err := GetUserDevices(db, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return matchDevice(devices, name)
+}
+
+func matchDevice(devices []Device, name string) (*Device, error) {
+	query := normalizeDeviceName(name)
+	if query == "" {
+		if len(devices) == 1 {
+			return &devices[0], nil
+		}
+		if len(devices) == 0 {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, fmt.Errorf("%w: %s", ErrDeviceAmbiguous, deviceNameList(devices))
+	}
+
+	var exact, partial []Device
+	for _, d := range devices {
+		for _, label := range deviceLabels(&d) {
+			if label == query {
+				exact = append(exact, d)
+				break
+			}
+			if strings.Contains(label, query) || strings.Contains(query, label) {
+				partial = append(partial, d)
+				break
+			}
+		}
+	}
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = partial
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("%w: %q", ErrDeviceNotFound, name)
+	case 1:
+		return &candidates[0], nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrDeviceAmbiguous, deviceNameList(candidates))
+}
+
+func deviceNameList(devices []Device) string {
+	names := make([]string, 0, len(devices))
+	for i := range devices {
+		names = append(names, devices[i].DisplayName())
+	}
+	return strings.Join(names, ", ")
+}
+
+var deviceCommandNumber = regexp.MustCompile(`\d+`)
+
+// deviceIntentKeywords 文本指令关键词，按顺序匹配，"factory reset" 需先于其他意图判断
+var deviceIntentKeywords = []struct {
+	intent   string
+	keywords []string
+}{
+	{DeviceIntentFactoryReset, []string{"factory reset", "factory settings", "恢复出厂"}},
+	{DeviceIntentReboot, []string{"reboot", "restart", "重启", "重新启动"}},
+	{DeviceIntentClearCache, []string{"clear cache", "clear the cache", "清除缓存", "清缓存", "清理缓存"}},
+	{DeviceIntentMute, []string{"mute", "静音"}},
+	{DeviceIntentVolumeDown, []string{"volume down", "turn down", "turn it down", "lower the volume", "quieter", "softer", "调小", "小声", "小一点", "降低音量"}},
+	{DeviceIntentVolumeUp, []string{"volume up", "turn up", "turn it up", "raise the volume", "louder", "调大", "大声", "大一点", "提高音量"}},
+}
+
+// ParseDeviceCommand 解析文本指令，例如 "turn volume down on the kitchen device"、"把客厅音箱音量调到30"。
+// 设备取文本中出现的最长的设备别名或名称，没有提到设备且用户只有一台设备时使用该设备
+func ParseDeviceCommand(text string, devices []Device) (DeviceCommandRequest, error) {
+	req := DeviceCommandRequest{Utterance: strings.TrimSpace(text)}
+	lower := strings.ToLower(req.Utterance)
+
+	if number := deviceCommandNumber.FindString(lower); number != "" &&
+		(strings.Contains(lower, "volume") || strings.Contains(lower, "音量")) &&
+		(strings.Contains(lower, " to ") || strings.Contains(lower, "调到") || strings.Contains(lower, "设为") || strings.Contains(lower, "设置为")) {
+		value, _ := strconv.Atoi(number)
+		req.Intent = DeviceIntentSetVolume
+		req.Value = &value
+	} else {
+		for _, entry := range deviceIntentKeywords {
+			for _, keyword := range entry.keywords {
+				if strings.Contains(lower, keyword) {
+					req.Intent = entry.intent
+					break
+				}
+			}
+			if req.Intent != "" {
+				break
+			}
+		}
+	}
+	if req.Intent == "" {
+		return req, fmt.Errorf("%w: %q", ErrUnknownDeviceIntent, text)
+	}
+
+	best := 0
+	for i := range devices {
+		for _, label := range deviceLabels(&devices[i]) {
+			if len(label) > best && strings.Contains(lower, label) {
+				best = len(label)
+				req.Device = devices[i].Alias
+				if req.Device == "" {
+					req.Device = devices[i].DisplayName()
+				}
+			}
+		}
+	}
+	return req, nil
+}
+
+// currentDeviceVolume 设备当前音量：优先使用设备上报的音频状态，其次是最近一次下发的音量，都没有时假定为默认值
+func currentDeviceVolume(db *gorm.DB, device *Device) int {
+	if device.AudioStatus != nil {
+		var status struct {
+			Volume *float64 `json:"volume"`
+		}
+		if json.Unmarshal([]byte(*device.AudioStatus), &status) == nil && status.Volume != nil {
+			return int(*status.Volume)
+		}
+	}
+	var last DeviceCommandLog
+	err := db.Where("device_id = ? AND action = ? AND status = ?", device.ID, actionmanifest.ActionSetVolume, DeviceCommandIssued).
+		Order("id DESC").First(&last).Error
+	if err == nil {
+		var params struct {
+			Volume int `json:"volume"`
+		}
+		if json.Unmarshal([]byte(last.Params), &params) == nil {
+			return params.Volume
+		}
+	}
+	return deviceDefaultVolume
+}
+
+// deviceCommandAction 将意图映射为操作清单的动作和参数
+func deviceCommandAction(db *gorm.DB, device *Device, intent string, value *int) (string, json.RawMessage, error) {
+	volume := func(v int) (string, json.RawMessage, error) {
+		v = min(max(v, 0), 100)
+		params, _ := json.Marshal(map[string]int{"volume": v})
+		return actionmanifest.ActionSetVolume, params, nil
+	}
+	step := deviceVolumeStep
+	if value != nil && *value > 0 {
+		step = *value
+	}
+
+	switch intent {
+	case DeviceIntentVolumeUp:
+		return volume(currentDeviceVolume(db, device) + step)
+	case DeviceIntentVolumeDown:
+		return volume(currentDeviceVolume(db, device) - step)
+	case DeviceIntentMute:
+		return volume(0)
+	case DeviceIntentSetVolume:
+		if value == nil || *value < 0 || *value > 100 {
+			return "", nil, errors.New("volume must be between 0 and 100")
+		}
+		return volume(*value)
+	case DeviceIntentReboot:
+		return actionmanifest.ActionReboot, nil, nil
+	case DeviceIntentFactoryReset:
+		return actionmanifest.ActionFactoryReset, nil, nil
+	case DeviceIntentClearCache:
+		return actionmanifest.ActionClearCache, nil, nil
+	}
+	return "", nil, fmt.Errorf("%w: %q", ErrUnknownDeviceIntent, intent)
+}
+
+// ExecuteDeviceCommand 执行设备控制指令：在用户的设备中解析目标设备，将意图映射为操作清单并签发。
+// 破坏性操作（重启、恢复出厂等）只记录为待确认，需调用 ConfirmDeviceCommand 确认后才签发
+func ExecuteDeviceCommand(db *gorm.DB, req DeviceCommandRequest, now time.Time) (*DeviceCommandResult, error) {
+	device, err := ResolveUserDevice(db, req.UserID, req.Device)
+	if err != nil {
+		return nil, err
+	}
+	action, params, err := deviceCommandAction(db, device, req.Intent, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := actionmanifest.Validate(action, params)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &DeviceCommandLog{
+		UserID:      req.UserID,
+		DeviceID:    device.ID,
+		DeviceName:  truncateRunes(device.DisplayName(), 127),
+		Source:      req.Source,
+		AssistantID: req.AssistantID,
+		Utterance:   truncateRunes(req.Utterance, 511),
+		Intent:      req.Intent,
+		Action:      action,
+		Params:      string(params),
+		Status:      DeviceCommandPending,
+	}
+	if err := db.Create(log).Error; err != nil {
+		return nil, err
+	}
+	result := &DeviceCommandResult{Log: log, Device: device}
+	if spec.Disruptive {
+		result.NeedsConfirmation = true
+		result.Message = fmt.Sprintf("%s on %s requires confirmation: %s", action, device.DisplayName(), spec.Description)
+		return result, nil
+	}
+	return result, issueDeviceCommand(db, result, now)
+}
+
+// ConfirmDeviceCommand 确认或取消待确认的破坏性指令，超过 DeviceCommandConfirmTTL 的指令不能再确认
+func ConfirmDeviceCommand(db *gorm.DB, userID, id uint, confirm bool, now time.Time) (*DeviceCommandResult, error) {
+	var log DeviceCommandLog
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&log).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceCommandNotPending
+		}
+		return nil, err
+	}
+	if log.Status != DeviceCommandPending {
+		return nil, ErrDeviceCommandNotPending
+	}
+	if now.Sub(log.CreatedAt) > DeviceCommandConfirmTTL {
+		db.Model(&log).Update("status", DeviceCommandExpired)
+		return nil, ErrDeviceCommandConfirmExpired
+	}
+	if !confirm {
+		log.Status = DeviceCommandCancelled
+		if err := db.Model(&log).Update("status", DeviceCommandCancelled).Error; err != nil {
+			return nil, err
+		}
+		return &DeviceCommandResult{Log: &log, Message: "cancelled"}, nil
+	}
+
+	// 确认时重新校验设备仍在用户的管理范围内
+	devices, err := GetUserDevices(db, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	var device *Device
+	for i := range devices {
+		if devices[i].ID == log.DeviceID {
+			device = &devices[i]
+			break
+		}
+	}
+	if device == nil {
+		return nil, ErrDeviceNotFound
+	}
+	log.ConfirmedAt = &now
+	result := &DeviceCommandResult{Log: &log, Device: device}
+	return result, issueDeviceCommand(db, result, now)
+}
+
+// issueDeviceCommand 签发操作清单并更新记录
+func issueDeviceCommand(db *gorm.DB, result *DeviceCommandResult, now time.Time) error {
+	log := result.Log
+	manifest, err := IssueDeviceActionManifest(db, result.Device, log.Action, json.RawMessage(log.Params), 0, log.UserID, now)
+	if err != nil {
+		log.Status = DeviceCommandFailed
+		log.Error = truncateRunes(err.Error(), 254)
+	} else {
+		log.Status = DeviceCommandIssued
+		log.ManifestID = manifest.ManifestID
+		result.Message = fmt.Sprintf("%s sent to %s", log.Action, result.Device.DisplayName())
+	}
+	if saveErr := db.Model(log).Updates(map[string]any{
+		"status":       log.Status,
+		"manifest_id":  log.ManifestID,
+		"error":        log.Error,
+		"confirmed_at": log.ConfirmedAt,
+	}).Error; saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// ListDeviceCommandLogs 用户执行过的设备指令，deviceID 为空时返回全部设备
+func ListDeviceCommandLogs(db *gorm.DB, userID uint, deviceID string, limit int) ([]DeviceCommandLog, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	q := db.Where("user_id = ?", userID)
+	if deviceID != "" {
+		q = q.Where("device_id = ?", deviceID)
+	}
+	var logs []DeviceCommandLog
+	err := q.Order("id DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
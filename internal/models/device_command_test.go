@@ -0,0 +1,143 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/actionmanifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deviceCommandFleet() []Device {
+	return []Device{
+		{ID: "aa:aa", MacAddress: "aa:aa", UserID: 1, Alias: "Kitchen"},
+		{ID: "bb:bb", MacAddress: "bb:bb", UserID: 1, DeviceName: "Living Room Speaker"},
+		{ID: "cc:cc", MacAddress: "cc:cc", UserID: 1, Alias: "Kitchen Display"},
+		{ID: "dd:dd", MacAddress: "dd:dd", UserID: 2, Alias: "Garage"},
+	}
+}
+
+func TestMatchDevice(t *testing.T) {
+	devices := deviceCommandFleet()[:3]
+
+	d, err := matchDevice(devices, "the kitchen device")
+	require.NoError(t, err)
+	assert.Equal(t, "aa:aa", d.ID, "an exact alias match wins over partial matches")
+
+	d, err = matchDevice(devices, "living room")
+	require.NoError(t, err)
+	assert.Equal(t, "bb:bb", d.ID)
+
+	_, err = matchDevice(devices, "")
+	assert.ErrorIs(t, err, ErrDeviceAmbiguous)
+	_, err = matchDevice(devices, "bedroom")
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+
+	d, err = matchDevice(devices[:1], "")
+	require.NoError(t, err)
+	assert.Equal(t, "aa:aa", d.ID, "the only device is used when none is named")
+}
+
+func TestParseDeviceCommand(t *testing.T) {
+	devices := deviceCommandFleet()[:3]
+
+	req, err := ParseDeviceCommand("Turn volume down on the kitchen device", devices)
+	require.NoError(t, err)
+	assert.Equal(t, DeviceIntentVolumeDown, req.Intent)
+	assert.Equal(t, "Kitchen", req.Device)
+
+	req, err = ParseDeviceCommand("set the living room speaker volume to 30", devices)
+	require.NoError(t, err)
+	assert.Equal(t, DeviceIntentSetVolume, req.Intent)
+	require.NotNil(t, req.Value)
+	assert.Equal(t, 30, *req.Value)
+	assert.Equal(t, "Living Room Speaker", req.Device)
+
+	req, err = ParseDeviceCommand("把kitchen display恢复出厂设置", devices)
+	require.NoError(t, err)
+	assert.Equal(t, DeviceIntentFactoryReset, req.Intent)
+	assert.Equal(t, "Kitchen Display", req.Device, "the longest device name in the text is used")
+
+	req, err = ParseDeviceCommand("重启一下", devices)
+	require.NoError(t, err)
+	assert.Equal(t, DeviceIntentReboot, req.Intent)
+	assert.Empty(t, req.Device)
+
+	_, err = ParseDeviceCommand("what's the weather", devices)
+	assert.ErrorIs(t, err, ErrUnknownDeviceIntent)
+}
+
+func TestExecuteDeviceCommand(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{}, &Group{}, &GroupMember{}, &DeviceCommandLog{},
+		&DeviceSigningKey{}, &DeviceActionManifest{}, &DeviceAction{}, &MaintenanceWindow{})
+	require.NoError(t, db.Create(deviceCommandFleet()).Error)
+	now := time.Now()
+
+	result, err := ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Source: DeviceCommandSourceAPI, Device: "kitchen", Intent: DeviceIntentVolumeDown}, now)
+	require.NoError(t, err)
+	assert.False(t, result.NeedsConfirmation)
+	assert.Equal(t, DeviceCommandIssued, result.Log.Status)
+	assert.NotEmpty(t, result.Log.ManifestID)
+	assert.JSONEq(t, `{"volume":30}`, result.Log.Params, "volume is lowered from the assumed default")
+
+	result, err = ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Device: "kitchen", Intent: DeviceIntentVolumeDown}, now)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"volume":10}`, result.Log.Params, "the last issued volume is the starting point")
+
+	status := `{"volume": 95}`
+	require.NoError(t, db.Model(&Device{}).Where("id = ?", "aa:aa").Update("audio_status", status).Error)
+	result, err = ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Device: "kitchen", Intent: DeviceIntentVolumeUp}, now)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"volume":100}`, result.Log.Params, "the reported volume is used and clamped")
+
+	_, err = ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Device: "garage", Intent: DeviceIntentReboot}, now)
+	assert.ErrorIs(t, err, ErrDeviceNotFound, "other users' devices cannot be resolved")
+	_, err = ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Device: "kitchen", Intent: "explode"}, now)
+	assert.ErrorIs(t, err, ErrUnknownDeviceIntent)
+}
+
+func TestConfirmDeviceCommand(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{}, &Group{}, &GroupMember{}, &DeviceCommandLog{},
+		&DeviceSigningKey{}, &DeviceActionManifest{}, &DeviceAction{}, &MaintenanceWindow{})
+	require.NoError(t, db.Create(deviceCommandFleet()).Error)
+	now := time.Now()
+
+	result, err := ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Device: "kitchen", Intent: DeviceIntentReboot}, now)
+	require.NoError(t, err)
+	assert.True(t, result.NeedsConfirmation)
+	assert.Equal(t, DeviceCommandPending, result.Log.Status)
+	var count int64
+	db.Model(&DeviceActionManifest{}).Count(&count)
+	assert.Zero(t, count, "destructive actions wait for confirmation")
+
+	_, err = ConfirmDeviceCommand(db, 2, result.Log.ID, true, now)
+	assert.ErrorIs(t, err, ErrDeviceCommandNotPending, "only the requesting user can confirm")
+
+	confirmed, err := ConfirmDeviceCommand(db, 1, result.Log.ID, true, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, DeviceCommandIssued, confirmed.Log.Status)
+	assert.NotNil(t, confirmed.Log.ConfirmedAt)
+	var manifest DeviceActionManifest
+	require.NoError(t, db.Where("manifest_id = ?", confirmed.Log.ManifestID).First(&manifest).Error)
+	assert.Equal(t, actionmanifest.ActionReboot, manifest.Action)
+
+	_, err = ConfirmDeviceCommand(db, 1, result.Log.ID, true, now)
+	assert.ErrorIs(t, err, ErrDeviceCommandNotPending, "a command is confirmed once")
+
+	result, err = ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Device: "kitchen", Intent: DeviceIntentFactoryReset}, now)
+	require.NoError(t, err)
+	cancelled, err := ConfirmDeviceCommand(db, 1, result.Log.ID, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, DeviceCommandCancelled, cancelled.Log.Status)
+
+	result, err = ExecuteDeviceCommand(db, DeviceCommandRequest{UserID: 1, Device: "kitchen", Intent: DeviceIntentReboot}, now)
+	require.NoError(t, err)
+	_, err = ConfirmDeviceCommand(db, 1, result.Log.ID, true, result.Log.CreatedAt.Add(DeviceCommandConfirmTTL+time.Second))
+	assert.ErrorIs(t, err, ErrDeviceCommandConfirmExpired)
+
+	logs, err := ListDeviceCommandLogs(db, 1, "aa:aa", 0)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	assert.Equal(t, DeviceCommandExpired, logs[0].Status)
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	LocationSourceGPS = "gps" // Reported by the device (GPS / network positioning)
+	LocationSourceIP  = "ip"  // Derived from the device's public IP address
+)
+
+var (
+	ErrInvalidCoordinates = errors.New("invalid coordinates")
+	ErrInvalidGeofence    = errors.New("geofence requires a center and a positive radius")
+)
+
+// DeviceLocation one geolocation report of a device
+type DeviceLocation struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DeviceID   string    `json:"deviceId" gorm:"size:64;index:idx_device_location_time"`
+	UserID     uint      `json:"userId" gorm:"index"`
+	GroupID    *uint     `json:"groupId,omitempty" gorm:"index"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Accuracy   float64   `json:"accuracy,omitempty"` // 精度(米)，IP定位为0
+	Source     string    `json:"source" gorm:"size:16"`
+	Country    string    `json:"country,omitempty" gorm:"size:64"`
+	City       string    `json:"city,omitempty" gorm:"size:64"`
+	IPAddress  string    `json:"ipAddress,omitempty" gorm:"size:64"`
+	ReportedAt time.Time `json:"reportedAt" gorm:"index:idx_device_location_time"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (DeviceLocation) TableName() string {
+	return "device_locations"
+}
+
+// DeviceGeofence circular region a device is expected to stay in.
+// A device with applicable geofences triggers an alert when it reports
+// a location outside all of them.
+type DeviceGeofence struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID    uint      `json:"userId" gorm:"index;not null"`
+	DeviceID  *string   `json:"deviceId,omitempty" gorm:"size:64;index"` // 为空表示适用于用户的所有设备
+	Name      string    `json:"name" gorm:"size:128"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Radius    float64   `json:"radius"` // 半径(米)
+	Enabled   bool      `json:"enabled"`
+}
+
+// TableName 指定表名
+func (DeviceGeofence) TableName() string {
+	return "device_geofences"
+}
+
+// Validate checks center coordinates and radius
+func (g *DeviceGeofence) Validate() error {
+	if !ValidCoordinates(g.Latitude, g.Longitude) || g.Radius <= 0 {
+		return ErrInvalidGeofence
+	}
+	return nil
+}
+
+// Contains reports whether the point lies inside the geofence
+func (g *DeviceGeofence) Contains(lat, lng float64) bool {
+	return utils.GetDistance(g.Longitude, g.Latitude, lng, lat) <= g.Radius
+}
+
+// GeoBounds bounding box used by the fleet map view
+type GeoBounds struct {
+	MinLat float64 `json:"minLat"`
+	MaxLat float64 `json:"maxLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLng float64 `json:"maxLng"`
+}
+
+// Contains reports whether the point lies inside the box.
+// Boxes crossing the antimeridian have MinLng > MaxLng.
+func (b GeoBounds) Contains(lat, lng float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLng <= b.MaxLng {
+		return lng >= b.MinLng && lng <= b.MaxLng
+	}
+	return lng >= b.MinLng || lng <= b.MaxLng
+}
+
+// ValidCoordinates checks latitude/longitude ranges
+func ValidCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// RecordDeviceLocation stores a location report and updates the device's latest location
+func RecordDeviceLocation(db *gorm.DB, device *Device, loc *DeviceLocation) error {
+	if !ValidCoordinates(loc.Latitude, loc.Longitude) {
+		return ErrInvalidCoordinates
+	}
+	loc.DeviceID = device.ID
+	loc.UserID = device.UserID
+	loc.GroupID = device.GroupID
+	if loc.Source == "" {
+		loc.Source = LocationSourceGPS
+	}
+	if loc.ReportedAt.IsZero() {
+		loc.ReportedAt = time.Now()
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(loc).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Device{}).Where("id = ?", device.ID).Updates(map[string]interface{}{
+			"latitude":        loc.Latitude,
+			"longitude":       loc.Longitude,
+			"location_source": loc.Source,
+			"located_at":      loc.ReportedAt,
+		}).Error; err != nil {
+			return err
+		}
+		device.Latitude = &loc.Latitude
+		device.Longitude = &loc.Longitude
+		device.LocationSource = loc.Source
+		device.LocatedAt = &loc.ReportedAt
+		return nil
+	})
+}
+
+// GetDeviceLocations gets the location history of a device, newest first
+func GetDeviceLocations(db *gorm.DB, deviceID string, since, until *time.Time, limit int) ([]DeviceLocation, error) {
+	var locations []DeviceLocation
+	query := db.Where("device_id = ?", deviceID)
+	if since != nil {
+		query = query.Where("reported_at >= ?", *since)
+	}
+	if until != nil {
+		query = query.Where("reported_at <= ?", *until)
+	}
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	err := query.Order("reported_at DESC").Limit(limit).Find(&locations).Error
+	return locations, err
+}
+
+// FilterDevicesInBounds keeps devices whose latest location lies in the box
+func FilterDevicesInBounds(devices []Device, bounds GeoBounds) []Device {
+	result := make([]Device, 0, len(devices))
+	for _, d := range devices {
+		if d.Latitude != nil && d.Longitude != nil && bounds.Contains(*d.Latitude, *d.Longitude) {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// FilterDevicesWithinRadius keeps devices whose latest location is within radius meters of the point
+func FilterDevicesWithinRadius(devices []Device, lat, lng, radius float64) []Device {
+	result := make([]Device, 0, len(devices))
+	for _, d := range devices {
+		if d.Latitude != nil && d.Longitude != nil && utils.GetDistance(lng, lat, *d.Longitude, *d.Latitude) <= radius {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// ListDeviceGeofences lists geofences of a user
+func ListDeviceGeofences(db *gorm.DB, userID uint) ([]DeviceGeofence, error) {
+	var fences []DeviceGeofence
+	err := db.Where("user_id = ?", userID).Order("id ASC").Find(&fences).Error
+	return fences, err
+}
+
+// CheckDeviceGeofences returns the enabled geofences applicable to the device and
+// whether the point lies outside all of them. Devices without geofences never violate.
+func CheckDeviceGeofences(db *gorm.DB, device *Device, lat, lng float64) ([]DeviceGeofence, bool, error) {
+	var fences []DeviceGeofence
+	err := db.Where("user_id = ? AND enabled = ? AND (device_id IS NULL OR device_id = ?)", device.UserID, true, device.ID).
+		Find(&fences).Error
+	if err != nil || len(fences) == 0 {
+		return fences, false, err
+	}
+	for i := range fences {
+		if fences[i].Contains(lat, lng) {
+			return fences, false, nil
+		}
+	}
+	return fences, true, nil
+}
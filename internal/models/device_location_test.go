@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordDeviceLocation(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{}, &DeviceLocation{})
+	device := &Device{ID: "aa:bb:cc:dd:ee:ff", MacAddress: "aa:bb:cc:dd:ee:ff", UserID: 1}
+	require.NoError(t, db.Create(device).Error)
+
+	err := RecordDeviceLocation(db, device, &DeviceLocation{Latitude: 91, Longitude: 0})
+	assert.ErrorIs(t, err, ErrInvalidCoordinates)
+
+	require.NoError(t, RecordDeviceLocation(db, device, &DeviceLocation{Latitude: 31.23, Longitude: 121.47}))
+	require.NoError(t, RecordDeviceLocation(db, device, &DeviceLocation{Latitude: 39.90, Longitude: 116.40, Source: LocationSourceIP}))
+
+	stored, err := GetDeviceByID(db, device.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.Latitude)
+	assert.InDelta(t, 39.90, *stored.Latitude, 1e-9)
+	assert.Equal(t, LocationSourceIP, stored.LocationSource)
+
+	history, err := GetDeviceLocations(db, device.ID, nil, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, uint(1), history[0].UserID)
+	assert.Equal(t, LocationSourceGPS, history[1].Source)
+}
+
+func TestGeoBounds_Contains(t *testing.T) {
+	box := GeoBounds{MinLat: 30, MaxLat: 32, MinLng: 120, MaxLng: 122}
+	assert.True(t, box.Contains(31, 121))
+	assert.False(t, box.Contains(33, 121))
+	assert.False(t, box.Contains(31, 119))
+
+	// Crossing the antimeridian
+	wrap := GeoBounds{MinLat: -10, MaxLat: 10, MinLng: 170, MaxLng: -170}
+	assert.True(t, wrap.Contains(0, 175))
+	assert.True(t, wrap.Contains(0, -175))
+	assert.False(t, wrap.Contains(0, 0))
+}
+
+func TestFilterDevicesByArea(t *testing.T) {
+	lat1, lng1 := 31.2304, 121.4737 // Shanghai
+	lat2, lng2 := 39.9042, 116.4074 // Beijing
+	devices := []Device{
+		{ID: "sh", Latitude: &lat1, Longitude: &lng1},
+		{ID: "bj", Latitude: &lat2, Longitude: &lng2},
+		{ID: "unknown"},
+	}
+
+	inBox := FilterDevicesInBounds(devices, GeoBounds{MinLat: 30, MaxLat: 32, MinLng: 120, MaxLng: 122})
+	require.Len(t, inBox, 1)
+	assert.Equal(t, "sh", inBox[0].ID)
+
+	nearby := FilterDevicesWithinRadius(devices, 39.91, 116.40, 5000)
+	require.Len(t, nearby, 1)
+	assert.Equal(t, "bj", nearby[0].ID)
+}
+
+func TestCheckDeviceGeofences(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceGeofence{})
+	device := &Device{ID: "dev-1", UserID: 1}
+
+	// No geofences configured: never a violation
+	_, violated, err := CheckDeviceGeofences(db, device, 0, 0)
+	require.NoError(t, err)
+	assert.False(t, violated)
+
+	otherDevice := "dev-2"
+	require.NoError(t, db.Create(&DeviceGeofence{UserID: 1, Name: "Shanghai", Latitude: 31.23, Longitude: 121.47, Radius: 50000, Enabled: true}).Error)
+	require.NoError(t, db.Create(&DeviceGeofence{UserID: 1, DeviceID: &otherDevice, Name: "Beijing", Latitude: 39.90, Longitude: 116.40, Radius: 50000, Enabled: true}).Error)
+
+	_, violated, err = CheckDeviceGeofences(db, device, 31.30, 121.50)
+	require.NoError(t, err)
+	assert.False(t, violated)
+
+	// Beijing fence belongs to another device
+	fences, violated, err := CheckDeviceGeofences(db, device, 39.90, 116.40)
+	require.NoError(t, err)
+	assert.True(t, violated)
+	assert.Len(t, fences, 1)
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 诊断包状态
+const (
+	DiagnosticsRequested = "requested" // 已通知设备，等待上传
+	DiagnosticsUploaded  = "uploaded"  // 设备已上传
+)
+
+const (
+	// DiagnosticsCommand 通知设备上传诊断包的指令名
+	DiagnosticsCommand = "upload_diagnostics"
+	// 诊断包中附带的服务端记录条数
+	diagnosticsErrorLogLimit = 100
+	diagnosticsCallLimit     = 50
+)
+
+var ErrDiagnosticsUploadClosed = errors.New("diagnostics upload link is invalid or expired")
+
+// DiagnosticsBundle 设备诊断包：设备上传的日志、配置快照和网络信息，
+// 下载时与服务端的错误日志、通话记录一起打包，供工单排查使用
+type DiagnosticsBundle struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeviceID    string     `json:"deviceId" gorm:"size:64;index"`
+	UserID      uint       `json:"userId" gorm:"index"`              // 发起人
+	Ticket      string     `json:"ticket,omitempty" gorm:"size:128"` // 关联的工单号
+	Status      string     `json:"status" gorm:"size:16;index"`
+	ActionID    uint       `json:"actionId,omitempty"`           // 通知设备的 DeviceAction
+	UploadToken string     `json:"-" gorm:"size:64;uniqueIndex"` // 设备上传凭证
+	UploadPath  string     `json:"-" gorm:"size:512"`            // 设备上传文件的本地路径
+	UploadName  string     `json:"uploadName,omitempty" gorm:"size:255"`
+	UploadSize  int64      `json:"uploadSize"`
+	UploadedAt  *time.Time `json:"uploadedAt,omitempty"`
+	ExpiresAt   time.Time  `json:"expiresAt"` // 上传链接过期时间
+}
+
+func (DiagnosticsBundle) TableName() string {
+	return "diagnostics_bundles"
+}
+
+// CreateDiagnosticsBundle 创建诊断包记录和设备上传凭证
+func CreateDiagnosticsBundle(db *gorm.DB, deviceID string, userID uint, ticket string, ttl time.Duration) (*DiagnosticsBundle, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	b := &DiagnosticsBundle{
+		DeviceID:    deviceID,
+		UserID:      userID,
+		Ticket:      ticket,
+		Status:      DiagnosticsRequested,
+		UploadToken: hex.EncodeToString(buf),
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := db.Create(b).Error; err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// GetDiagnosticsBundleForUpload 按上传凭证查找等待上传的诊断包
+func GetDiagnosticsBundleForUpload(db *gorm.DB, token string, now time.Time) (*DiagnosticsBundle, error) {
+	var b DiagnosticsBundle
+	err := db.Where("upload_token = ? AND status = ?", token, DiagnosticsRequested).First(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && now.After(b.ExpiresAt)) {
+		return nil, ErrDiagnosticsUploadClosed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// MarkDiagnosticsUploaded 记录设备上传的文件，上传凭证随即失效
+func MarkDiagnosticsUploaded(db *gorm.DB, b *DiagnosticsBundle, filePath, name string, size int64, now time.Time) error {
+	b.Status = DiagnosticsUploaded
+	b.UploadPath = filePath
+	b.UploadName = name
+	b.UploadSize = size
+	b.UploadedAt = &now
+	return db.Model(b).Updates(map[string]any{
+		"status":      b.Status,
+		"upload_path": filePath,
+		"upload_name": name,
+		"upload_size": size,
+		"uploaded_at": now,
+	}).Error
+}
+
+// DiagnosticsContext 打包进诊断包的服务端上下文
+type DiagnosticsContext struct {
+	Device         *Device          `json:"device"`
+	ErrorLogs      []DeviceErrorLog `json:"errorLogs"`
+	CallRecordings []CallRecording  `json:"callRecordings"`
+}
+
+// LoadDiagnosticsContext 设备信息及最近的错误日志和通话记录
+func LoadDiagnosticsContext(db *gorm.DB, device *Device) (*DiagnosticsContext, error) {
+	ctx := &DiagnosticsContext{Device: device}
+	if err := db.Where("device_id = ?", device.ID).Order("id DESC").
+		Limit(diagnosticsErrorLogLimit).Find(&ctx.ErrorLogs).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("device_id = ?", device.ID).Order("id DESC").
+		Limit(diagnosticsCallLimit).Find(&ctx.CallRecordings).Error; err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// WriteDiagnosticsArchive 写出 zip 诊断包：manifest.json、服务端上下文，以及设备上传的文件（位于 device/ 目录）
+func WriteDiagnosticsArchive(w io.Writer, b *DiagnosticsBundle, ctx *DiagnosticsContext, upload io.Reader) error {
+	zw := zip.NewWriter(w)
+	writeJSON := func(name string, v any) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	manifest := map[string]any{
+		"bundle":         b,
+		"generatedAt":    time.Now(),
+		"deviceUploaded": upload != nil,
+		"errorLogs":      len(ctx.ErrorLogs),
+		"callRecordings": len(ctx.CallRecordings),
+	}
+	if err := writeJSON("manifest.json", manifest); err != nil {
+		return err
+	}
+	if err := writeJSON("server/device.json", ctx.Device); err != nil {
+		return err
+	}
+	if err := writeJSON("server/error_logs.json", ctx.ErrorLogs); err != nil {
+		return err
+	}
+	if err := writeJSON("server/call_recordings.json", ctx.CallRecordings); err != nil {
+		return err
+	}
+	if upload != nil {
+		name := path.Base(b.UploadName)
+		if name == "." || name == "/" {
+			name = "bundle"
+		}
+		f, err := zw.Create("device/" + name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, upload); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
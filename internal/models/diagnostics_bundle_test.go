@@ -0,0 +1,74 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticsBundleUpload(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DiagnosticsBundle{})
+	now := time.Now()
+
+	b, err := CreateDiagnosticsBundle(db, "aa:bb", 1, "T-42", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, DiagnosticsRequested, b.Status)
+	assert.Len(t, b.UploadToken, 48)
+
+	_, err = GetDiagnosticsBundleForUpload(db, "wrong", now)
+	assert.ErrorIs(t, err, ErrDiagnosticsUploadClosed)
+	_, err = GetDiagnosticsBundleForUpload(db, b.UploadToken, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrDiagnosticsUploadClosed)
+
+	found, err := GetDiagnosticsBundleForUpload(db, b.UploadToken, now)
+	require.NoError(t, err)
+	require.NoError(t, MarkDiagnosticsUploaded(db, found, "/tmp/x", "logs.tar.gz", 12, now))
+
+	// The upload link works once
+	_, err = GetDiagnosticsBundleForUpload(db, b.UploadToken, now)
+	assert.ErrorIs(t, err, ErrDiagnosticsUploadClosed)
+}
+
+func TestWriteDiagnosticsArchive(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DeviceErrorLog{}, &CallRecording{})
+	device := &Device{ID: "aa:bb", MacAddress: "aa:bb"}
+	require.NoError(t, db.Create(&DeviceErrorLog{DeviceID: device.ID, ErrorType: "audio", ErrorMsg: "mic failure"}).Error)
+	require.NoError(t, db.Create(&DeviceErrorLog{DeviceID: "other", ErrorType: "net"}).Error)
+
+	ctx, err := LoadDiagnosticsContext(db, device)
+	require.NoError(t, err)
+	require.Len(t, ctx.ErrorLogs, 1)
+
+	bundle := &DiagnosticsBundle{ID: 3, DeviceID: device.ID, UploadName: "../logs.tar.gz"}
+	var buf bytes.Buffer
+	require.NoError(t, WriteDiagnosticsArchive(&buf, bundle, ctx, strings.NewReader("device logs")))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	assert.Contains(t, files, "manifest.json")
+	assert.Contains(t, files, "server/device.json")
+	assert.Contains(t, files["server/error_logs.json"], "mic failure")
+	assert.Contains(t, files, "server/call_recordings.json")
+	assert.Equal(t, "device logs", files["device/logs.tar.gz"])
+
+	// Without a device upload only the server-side context is packaged
+	buf.Reset()
+	require.NoError(t, WriteDiagnosticsArchive(&buf, bundle, ctx, nil))
+	zr, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Len(t, zr.File, 4)
+}
@@ -0,0 +1,295 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DIDTargetType 呼入号码的去向
+type DIDTargetType string
+
+const (
+	DIDTargetAssistant DIDTargetType = "assistant" // AI 助手直接接听
+	DIDTargetIVR       DIDTargetType = "ivr"       // 执行工作流，由工作流输出决定去向
+	DIDTargetQueue     DIDTargetType = "queue"     // 转给队列中第一个空闲坐席
+)
+
+// DIDRoute 呼入号码的实际路由结果，记录在通话上用于统计
+type DIDRoute string
+
+const (
+	DIDRouteAssistant DIDRoute = "assistant" // 由 AI 助手接听
+	DIDRouteAgent     DIDRoute = "agent"     // 转给坐席
+	DIDRouteRejected  DIDRoute = "rejected"  // 无可用去向，已拒接
+)
+
+// DIDNumber 自定义呼入号码（DID）或 SIP 别名，呼入时按被叫号码匹配并路由到助手、IVR 流程或队列
+type DIDNumber struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time     `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time     `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID      uint          `json:"userId" gorm:"index;not null"`
+	GroupID     *uint         `json:"groupId,omitempty" gorm:"index"`
+	Number      string        `json:"number" gorm:"size:128;uniqueIndex;not null"` // 规范化后的号码（如 +8613800138000）或 SIP 别名
+	Label       string        `json:"label,omitempty" gorm:"size:128"`
+	TargetType  DIDTargetType `json:"targetType" gorm:"size:16;not null"`     // assistant, ivr, queue
+	AssistantID *int64        `json:"assistantId,omitempty" gorm:"index"`     // assistant 去向；ivr 工作流未给出去向时的兜底助手
+	WorkflowID  *uint         `json:"workflowId,omitempty" gorm:"index"`      // ivr 去向执行的工作流
+	QueueAgents string        `json:"queueAgents,omitempty" gorm:"size:1024"` // queue 去向的坐席 SIP 用户名，逗号分隔，靠前的优先
+	Greeting    string        `json:"greeting,omitempty" gorm:"type:text"`    // 覆盖助手的开场白
+	Enabled     bool          `json:"enabled" gorm:"index"`
+}
+
+// TableName 指定表名
+func (DIDNumber) TableName() string {
+	return "did_numbers"
+}
+
+// Agents 返回队列坐席，保持配置顺序
+func (d *DIDNumber) Agents() []string {
+	return splitCSV(d.QueueAgents)
+}
+
+// splitCSV 拆分逗号分隔的列表，去掉空白项
+func splitCSV(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate 规范化号码并检查去向配置是否完整
+func (d *DIDNumber) Validate() error {
+	d.Number = NormalizeDIDNumber(d.Number)
+	if d.Number == "" {
+		return errors.New("number is required")
+	}
+	switch d.TargetType {
+	case DIDTargetAssistant:
+		if d.AssistantID == nil || *d.AssistantID == 0 {
+			return errors.New("assistantId is required for assistant target")
+		}
+	case DIDTargetIVR:
+		if d.WorkflowID == nil || *d.WorkflowID == 0 {
+			return errors.New("workflowId is required for ivr target")
+		}
+	case DIDTargetQueue:
+		if len(d.Agents()) == 0 {
+			return errors.New("queueAgents is required for queue target")
+		}
+		d.QueueAgents = strings.Join(d.Agents(), ",")
+	default:
+		return fmt.Errorf("unsupported target type: %q", d.TargetType)
+	}
+	return nil
+}
+
+// NormalizeDIDNumber 规范化号码：去掉 sip: 前缀和域名，电话号码去掉分隔符并将 00 国际前缀统一为 +，
+// 其他内容视为 SIP 别名原样保留
+func NormalizeDIDNumber(number string) string {
+	number = strings.TrimSpace(number)
+	number = strings.TrimPrefix(strings.TrimPrefix(number, "sips:"), "sip:")
+	if at := strings.IndexByte(number, '@'); at >= 0 {
+		number = number[:at]
+	}
+	if isPhoneLike(number) {
+		return normalizeConsentNumber(number)
+	}
+	return number
+}
+
+// isPhoneLike 只包含数字、+ 和常见分隔符且至少有一位数字
+func isPhoneLike(s string) bool {
+	digits := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case strings.ContainsRune("+-(). ", r):
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// MatchDIDNumber 按被叫号码查找启用的 DID，+ 前缀可有可无；没有匹配时返回 nil
+func MatchDIDNumber(db *gorm.DB, number string) (*DIDNumber, error) {
+	number = NormalizeDIDNumber(number)
+	if number == "" {
+		return nil, nil
+	}
+	candidates := []string{number}
+	if strings.HasPrefix(number, "+") {
+		candidates = append(candidates, number[1:])
+	} else if isPhoneLike(number) {
+		candidates = append(candidates, "+"+number)
+	}
+
+	var dids []DIDNumber
+	if err := db.Where("number IN ? AND enabled = ?", candidates, true).Find(&dids).Error; err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		for i := range dids {
+			if dids[i].Number == candidate {
+				return &dids[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// SaveSipCallDID 将匹配的 DID 和路由结果记录到通话
+func SaveSipCallDID(db *gorm.DB, callID string, didID uint, route DIDRoute) error {
+	return db.Model(&SipCall{}).Where("call_id = ?", callID).Updates(map[string]interface{}{
+		"did_number_id": didID,
+		"did_route":     route,
+	}).Error
+}
+
+// DIDImportError 批量导入中失败的一行
+type DIDImportError struct {
+	Row    int    `json:"row"` // 从 1 开始
+	Number string `json:"number"`
+	Error  string `json:"error"`
+}
+
+// DIDImportResult 批量导入结果
+type DIDImportResult struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Errors  []DIDImportError `json:"errors,omitempty"`
+}
+
+// ImportDIDNumbers 批量导入用户的 DID，号码已存在时更新其去向。
+// 属于其他用户的号码和配置不完整的行记入 Errors，不影响其他行。
+func ImportDIDNumbers(db *gorm.DB, userID uint, groupID *uint, rows []DIDNumber) (*DIDImportResult, error) {
+	result := &DIDImportResult{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range rows {
+			row := rows[i]
+			fail := func(msg string) {
+				result.Errors = append(result.Errors, DIDImportError{Row: i + 1, Number: row.Number, Error: msg})
+			}
+			if err := row.Validate(); err != nil {
+				fail(err.Error())
+				continue
+			}
+
+			var existing DIDNumber
+			err := tx.Where("number = ?", row.Number).First(&existing).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				row.ID = 0
+				row.UserID, row.GroupID = userID, groupID
+				if err := tx.Create(&row).Error; err != nil {
+					return err
+				}
+				result.Created++
+			case err != nil:
+				return err
+			case existing.UserID != userID:
+				fail("number is registered by another user")
+			default:
+				if err := tx.Model(&existing).Updates(map[string]interface{}{
+					"label":        row.Label,
+					"target_type":  row.TargetType,
+					"assistant_id": row.AssistantID,
+					"workflow_id":  row.WorkflowID,
+					"queue_agents": row.QueueAgents,
+					"greeting":     row.Greeting,
+					"enabled":      row.Enabled,
+				}).Error; err != nil {
+					return err
+				}
+				result.Updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DIDDailyStats DID 单日通话统计
+type DIDDailyStats struct {
+	Date          string `json:"date"`
+	TotalCalls    int64  `json:"totalCalls"`
+	AnsweredCalls int64  `json:"answeredCalls"`
+	TotalDuration int64  `json:"totalDuration"`
+}
+
+// DIDStats DID 通话统计
+type DIDStats struct {
+	DIDNumberID   uint               `json:"didNumberId"`
+	TotalCalls    int64              `json:"totalCalls"`
+	AnsweredCalls int64              `json:"answeredCalls"`
+	MissedCalls   int64              `json:"missedCalls"`
+	TotalDuration int64              `json:"totalDuration"` // 秒
+	AvgDuration   float64            `json:"avgDuration"`   // 接通通话的平均时长（秒）
+	AnswerRate    float64            `json:"answerRate"`
+	UniqueCallers int64              `json:"uniqueCallers"`
+	Routes        map[DIDRoute]int64 `json:"routes"`
+	Daily         []DIDDailyStats    `json:"daily"`
+}
+
+// GetDIDStats 统计 since 之后呼入该 DID 的通话
+func GetDIDStats(db *gorm.DB, didID uint, since time.Time) (*DIDStats, error) {
+	var calls []SipCall
+	err := db.Select("from_username", "start_time", "answer_time", "duration", "did_route").
+		Where("did_number_id = ? AND start_time >= ?", didID, since).
+		Order("start_time").
+		Find(&calls).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &DIDStats{DIDNumberID: didID, Routes: make(map[DIDRoute]int64), Daily: []DIDDailyStats{}}
+	callers := make(map[string]struct{})
+	days := make(map[string]int)
+	for _, call := range calls {
+		date := call.StartTime.Format("2006-01-02")
+		idx, ok := days[date]
+		if !ok {
+			stats.Daily = append(stats.Daily, DIDDailyStats{Date: date})
+			idx = len(stats.Daily) - 1
+			days[date] = idx
+		}
+		day := &stats.Daily[idx]
+
+		stats.TotalCalls++
+		day.TotalCalls++
+		if call.AnswerTime != nil {
+			stats.AnsweredCalls++
+			day.AnsweredCalls++
+		}
+		stats.TotalDuration += int64(call.Duration)
+		day.TotalDuration += int64(call.Duration)
+		if call.DIDRoute != "" {
+			stats.Routes[call.DIDRoute]++
+		}
+		if call.FromUsername != "" {
+			callers[call.FromUsername] = struct{}{}
+		}
+	}
+
+	stats.MissedCalls = stats.TotalCalls - stats.AnsweredCalls
+	stats.UniqueCallers = int64(len(callers))
+	if stats.AnsweredCalls > 0 {
+		stats.AvgDuration = float64(stats.TotalDuration) / float64(stats.AnsweredCalls)
+	}
+	if stats.TotalCalls > 0 {
+		stats.AnswerRate = float64(stats.AnsweredCalls) / float64(stats.TotalCalls)
+	}
+	return stats, nil
+}
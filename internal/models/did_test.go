@@ -0,0 +1,112 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDIDNumber(t *testing.T) {
+	cases := map[string]string{
+		"+86 138-0013-8000":          "+8613800138000",
+		"0086 13800138000":           "+8613800138000",
+		"sip:4001@pbx.example.com":   "4001",
+		"sips:sales@pbx.example.com": "sales",
+		" support ":                  "support",
+		"":                           "",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, NormalizeDIDNumber(in), in)
+	}
+}
+
+func TestDIDNumberValidate(t *testing.T) {
+	assistantID := int64(1)
+	d := DIDNumber{Number: "+1 (415) 555-0100", TargetType: DIDTargetAssistant, AssistantID: &assistantID}
+	require.NoError(t, d.Validate())
+	assert.Equal(t, "+14155550100", d.Number)
+
+	d = DIDNumber{Number: "sales", TargetType: DIDTargetQueue, QueueAgents: " 1001, ,1002 "}
+	require.NoError(t, d.Validate())
+	assert.Equal(t, "1001,1002", d.QueueAgents)
+
+	assert.Error(t, (&DIDNumber{Number: "sales", TargetType: DIDTargetIVR}).Validate())
+	assert.Error(t, (&DIDNumber{Number: "sales", TargetType: "voicemail"}).Validate())
+	assert.Error(t, (&DIDNumber{TargetType: DIDTargetQueue, QueueAgents: "1001"}).Validate())
+}
+
+func TestMatchDIDNumber(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DIDNumber{})
+	require.NoError(t, db.Create(&DIDNumber{UserID: 1, Number: "+8613800138000", TargetType: DIDTargetQueue, QueueAgents: "1001", Enabled: true}).Error)
+	require.NoError(t, db.Create(&DIDNumber{UserID: 1, Number: "sales", TargetType: DIDTargetQueue, QueueAgents: "1002", Enabled: true}).Error)
+	require.NoError(t, db.Create(&DIDNumber{UserID: 1, Number: "4001", TargetType: DIDTargetQueue, QueueAgents: "1003"}).Error)
+
+	did, err := MatchDIDNumber(db, "8613800138000")
+	require.NoError(t, err)
+	require.NotNil(t, did)
+	assert.Equal(t, "1001", did.QueueAgents)
+
+	did, err = MatchDIDNumber(db, "sales")
+	require.NoError(t, err)
+	require.NotNil(t, did)
+	assert.Equal(t, "1002", did.QueueAgents)
+
+	did, err = MatchDIDNumber(db, "4001")
+	require.NoError(t, err)
+	assert.Nil(t, did, "disabled DIDs do not match")
+}
+
+func TestImportDIDNumbers(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &DIDNumber{})
+	require.NoError(t, db.Create(&DIDNumber{UserID: 2, Number: "+14155550100", TargetType: DIDTargetQueue, QueueAgents: "2001", Enabled: true}).Error)
+	require.NoError(t, db.Create(&DIDNumber{UserID: 1, Number: "sales", TargetType: DIDTargetQueue, QueueAgents: "1001", Enabled: true}).Error)
+
+	workflowID := uint(3)
+	result, err := ImportDIDNumbers(db, 1, nil, []DIDNumber{
+		{Number: "+86 400-100-2000", TargetType: DIDTargetIVR, WorkflowID: &workflowID, Enabled: true},
+		{Number: "sales", TargetType: DIDTargetQueue, QueueAgents: "1001,1002", Enabled: true},
+		{Number: "+1 415 555 0100", TargetType: DIDTargetQueue, QueueAgents: "1001", Enabled: true},
+		{Number: "support", TargetType: DIDTargetAssistant},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, 1, result.Updated)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, 3, result.Errors[0].Row)
+	assert.Equal(t, 4, result.Errors[1].Row)
+
+	var sales DIDNumber
+	require.NoError(t, db.Where("number = ?", "sales").First(&sales).Error)
+	assert.Equal(t, "1001,1002", sales.QueueAgents)
+
+	var ivr DIDNumber
+	require.NoError(t, db.Where("number = ?", "+864001002000").First(&ivr).Error)
+	assert.Equal(t, uint(1), ivr.UserID)
+}
+
+func TestGetDIDStats(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{})
+	day := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local)
+	answered := day.Add(5 * time.Second)
+	didID := uint(7)
+	otherID := uint(8)
+	require.NoError(t, db.Create(&SipCall{CallID: "a", FromUsername: "alice", StartTime: day, AnswerTime: &answered, Duration: 60, DIDNumberID: &didID, DIDRoute: DIDRouteAssistant}).Error)
+	require.NoError(t, db.Create(&SipCall{CallID: "b", FromUsername: "alice", StartTime: day.Add(time.Hour), DIDNumberID: &didID, DIDRoute: DIDRouteRejected}).Error)
+	require.NoError(t, db.Create(&SipCall{CallID: "c", FromUsername: "bob", StartTime: day.Add(24 * time.Hour), AnswerTime: &answered, Duration: 120, DIDNumberID: &didID, DIDRoute: DIDRouteAssistant}).Error)
+	require.NoError(t, db.Create(&SipCall{CallID: "d", FromUsername: "carol", StartTime: day, DIDNumberID: &otherID}).Error)
+
+	stats, err := GetDIDStats(db, didID, day.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalCalls)
+	assert.Equal(t, int64(2), stats.AnsweredCalls)
+	assert.Equal(t, int64(1), stats.MissedCalls)
+	assert.Equal(t, int64(2), stats.UniqueCallers)
+	assert.Equal(t, 90.0, stats.AvgDuration)
+	assert.Equal(t, int64(2), stats.Routes[DIDRouteAssistant])
+	require.Len(t, stats.Daily, 2)
+	assert.Equal(t, "2026-10-14", stats.Daily[0].Date)
+	assert.Equal(t, int64(2), stats.Daily[0].TotalCalls)
+	assert.Equal(t, int64(1), stats.Daily[1].AnsweredCalls)
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 需要邮箱验证的操作，系统配置 EMAIL_VERIFICATION_REQUIRED 中以逗号分隔列出，"*" 表示全部
+const (
+	EmailActionCreateAssistant  = "assistant.create"
+	EmailActionBindDevice       = "device.bind"
+	EmailActionOutboundCall     = "call.outbound"
+	EmailActionCreateCredential = "credential.create"
+	EmailActionCreateGroup      = "group.create"
+)
+
+// EmailVerificationActions 所有可配置的操作
+var EmailVerificationActions = []string{
+	EmailActionCreateAssistant,
+	EmailActionBindDevice,
+	EmailActionOutboundCall,
+	EmailActionCreateCredential,
+	EmailActionCreateGroup,
+}
+
+// ErrCodeEmailNotVerified 邮箱未验证时 403 响应中的错误码
+const ErrCodeEmailNotVerified = "EMAIL_NOT_VERIFIED"
+
+// ParseEmailVerificationPolicy 解析配置值，返回需要验证邮箱的操作，忽略未知操作
+func ParseEmailVerificationPolicy(value string) []string {
+	required := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "*" {
+			return append([]string(nil), EmailVerificationActions...)
+		}
+		for _, action := range EmailVerificationActions {
+			if item == action {
+				required[action] = true
+			}
+		}
+	}
+	actions := make([]string, 0, len(required))
+	for action := range required {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// EmailVerificationRequiredActions 当前配置中需要验证邮箱的操作，未配置时不强制
+func EmailVerificationRequiredActions(db *gorm.DB) []string {
+	return ParseEmailVerificationPolicy(utils.GetValue(db, constants.KEY_EMAIL_VERIFICATION_REQUIRED))
+}
+
+// RequiresEmailVerification 判断操作是否要求用户已验证邮箱
+func RequiresEmailVerification(db *gorm.DB, action string) bool {
+	for _, a := range EmailVerificationRequiredActions(db) {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailVerificationAllowed 判断用户能否执行操作，超级管理员和已验证邮箱的用户不受限制
+func EmailVerificationAllowed(db *gorm.DB, user *User, action string) bool {
+	if user == nil || user.EmailVerified || user.IsSuperAdmin() {
+		return true
+	}
+	return !RequiresEmailVerification(db, action)
+}
+
+// EmailVerificationRequired 操作要求已验证邮箱而当前用户未验证时返回 403，
+// 响应中给出错误码、操作和发送验证邮件的接口，需放在 AuthRequired 之后
+func EmailVerificationRequired(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(constants.DbField)
+		if !ok {
+			c.Next()
+			return
+		}
+		db, _ := v.(*gorm.DB)
+		user := CurrentUser(c)
+		if db == nil || EmailVerificationAllowed(db, user, action) {
+			c.Next()
+			return
+		}
+		data := gin.H{
+			"action": action,
+			"email":  user.Email,
+		}
+		if config.GlobalConfig != nil {
+			data["sendVerificationUrl"] = config.GlobalConfig.Server.APIPrefix + "/auth/send-email-verification"
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"code":  http.StatusForbidden,
+			"msg":   "Please verify your email address before continuing",
+			"error": ErrCodeEmailNotVerified,
+			"data":  data,
+		})
+	}
+}
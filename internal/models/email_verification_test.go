@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEmailVerificationPolicy(t *testing.T) {
+	assert.Empty(t, ParseEmailVerificationPolicy(""))
+	assert.Equal(t, []string{EmailActionCreateAssistant, EmailActionBindDevice},
+		ParseEmailVerificationPolicy(" device.bind, Assistant.Create ,unknown,device.bind"))
+	assert.Equal(t, EmailVerificationActions, ParseEmailVerificationPolicy("call.outbound,*"))
+}
+
+func TestEmailVerificationRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDBWithSilentLogger(t, &utils.Config{})
+	utils.SetValue(db, constants.KEY_EMAIL_VERIFICATION_REQUIRED, EmailActionCreateAssistant, "text", true, false)
+	t.Cleanup(func() { utils.SetValue(db, constants.KEY_EMAIL_VERIFICATION_REQUIRED, "", "text", true, false) })
+
+	unverified := userWithID(1)
+	unverified.Email = "new@example.com"
+	verified := userWithID(2)
+	verified.EmailVerified = true
+
+	run := func(user *User, action string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set(constants.DbField, db)
+			c.Set(constants.UserField, user)
+		})
+		r.POST("/", EmailVerificationRequired(action), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		return w
+	}
+
+	w := run(unverified, EmailActionCreateAssistant)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	var body struct {
+		Error string         `json:"error"`
+		Data  map[string]any `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, ErrCodeEmailNotVerified, body.Error)
+	assert.Equal(t, EmailActionCreateAssistant, body.Data["action"])
+	assert.Equal(t, "new@example.com", body.Data["email"])
+
+	// Actions not listed, verified users and super admins pass
+	assert.Equal(t, http.StatusNoContent, run(unverified, EmailActionBindDevice).Code)
+	assert.Equal(t, http.StatusNoContent, run(verified, EmailActionCreateAssistant).Code)
+	admin := userWithID(3)
+	admin.Role = RoleSuperAdmin
+	assert.Equal(t, http.StatusNoContent, run(admin, EmailActionCreateAssistant).Code)
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	EscalationTicketCreated = "created"
+	EscalationTicketFailed  = "failed"
+)
+
+// EscalationConnector ticketing integration of an assistant, used to hand over
+// conversations the assistant could not resolve
+type EscalationConnector struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID   int64     `json:"assistantId" gorm:"index;not null"`
+	UserID        uint      `json:"userId" gorm:"index;not null"`
+	Name          string    `json:"name" gorm:"size:128"`
+	Provider      string    `json:"provider" gorm:"size:32;not null"` // jira, zendesk, webhook
+	Config        JSONMap   `json:"-" gorm:"type:json"`               // Provider settings, contains credentials
+	TitleTemplate string    `json:"titleTemplate" gorm:"type:text"`   // text/template, empty uses the default
+	BodyTemplate  string    `json:"bodyTemplate" gorm:"type:text"`    // text/template, empty uses the default
+	Priority      string    `json:"priority,omitempty" gorm:"size:32"`
+	Labels        string    `json:"labels,omitempty" gorm:"size:255"` // Comma separated
+	AutoEscalate  bool      `json:"autoEscalate"`                     // Create tickets when analysis marks a call unresolved
+	Enabled       bool      `json:"enabled"`
+}
+
+// TableName 指定表名
+func (EscalationConnector) TableName() string {
+	return "escalation_connectors"
+}
+
+// EscalationTicket ticket created for a call record
+type EscalationTicket struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	ConnectorID     uint      `json:"connectorId" gorm:"index"`
+	AssistantID     int64     `json:"assistantId" gorm:"index"`
+	UserID          uint      `json:"userId" gorm:"index"`
+	CallRecordingID uint      `json:"callRecordingId" gorm:"index"`
+	Provider        string    `json:"provider" gorm:"size:32"`
+	ExternalID      string    `json:"externalId,omitempty" gorm:"size:128;index"`
+	ExternalURL     string    `json:"externalUrl,omitempty" gorm:"size:512"`
+	Title           string    `json:"title" gorm:"size:255"`
+	Reason          string    `json:"reason,omitempty" gorm:"type:text"`
+	Status          string    `json:"status" gorm:"size:16"` // created, failed
+	Error           string    `json:"error,omitempty" gorm:"type:text"`
+}
+
+// TableName 指定表名
+func (EscalationTicket) TableName() string {
+	return "escalation_tickets"
+}
+
+// GetEscalationConnectors lists connectors of an assistant
+func GetEscalationConnectors(db *gorm.DB, assistantID int64) ([]EscalationConnector, error) {
+	var connectors []EscalationConnector
+	err := db.Where("assistant_id = ?", assistantID).Order("id ASC").Find(&connectors).Error
+	return connectors, err
+}
+
+// GetAutoEscalationConnectors lists enabled connectors that escalate automatically
+func GetAutoEscalationConnectors(db *gorm.DB, assistantID int64) ([]EscalationConnector, error) {
+	var connectors []EscalationConnector
+	err := db.Where("assistant_id = ? AND enabled = ? AND auto_escalate = ?", assistantID, true, true).
+		Order("id ASC").Find(&connectors).Error
+	return connectors, err
+}
+
+// HasEscalationTicket reports whether a connector already created a ticket for the call record
+func HasEscalationTicket(db *gorm.DB, connectorID, recordingID uint) (bool, error) {
+	var count int64
+	err := db.Model(&EscalationTicket{}).
+		Where("connector_id = ? AND call_recording_id = ? AND status = ?", connectorID, recordingID, EscalationTicketCreated).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SaveEscalationTicket stores a ticket and links successful ones back to the call record
+func SaveEscalationTicket(db *gorm.DB, ticket *EscalationTicket) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ticket).Error; err != nil {
+			return err
+		}
+		if ticket.Status != EscalationTicketCreated || ticket.CallRecordingID == 0 {
+			return nil
+		}
+		return tx.Model(&CallRecording{}).Where("id = ?", ticket.CallRecordingID).Updates(map[string]interface{}{
+			"escalation_ticket_id":  ticket.ExternalID,
+			"escalation_ticket_url": ticket.ExternalURL,
+		}).Error
+	})
+}
+
+// GetEscalationTicketsByRecording lists tickets created for a call record
+func GetEscalationTicketsByRecording(db *gorm.DB, recordingID uint) ([]EscalationTicket, error) {
+	var tickets []EscalationTicket
+	err := db.Where("call_recording_id = ?", recordingID).Order("id DESC").Find(&tickets).Error
+	return tickets, err
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveEscalationTicket_LinksCallRecording(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallRecording{}, &EscalationTicket{})
+	recording := &CallRecording{UserID: 1, AssistantID: 2, SessionID: "s1"}
+	require.NoError(t, db.Create(recording).Error)
+
+	// Failed attempts are kept but not linked
+	require.NoError(t, SaveEscalationTicket(db, &EscalationTicket{
+		ConnectorID: 1, CallRecordingID: recording.ID, Status: EscalationTicketFailed, Error: "401",
+	}))
+	exists, err := HasEscalationTicket(db, 1, recording.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, SaveEscalationTicket(db, &EscalationTicket{
+		ConnectorID: 1, CallRecordingID: recording.ID, Status: EscalationTicketCreated,
+		ExternalID: "SUP-1", ExternalURL: "https://jira.example.com/browse/SUP-1",
+	}))
+	exists, err = HasEscalationTicket(db, 1, recording.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	var stored CallRecording
+	require.NoError(t, db.First(&stored, recording.ID).Error)
+	assert.Equal(t, "SUP-1", stored.EscalationTicketID)
+	assert.Equal(t, "https://jira.example.com/browse/SUP-1", stored.EscalationTicketURL)
+
+	tickets, err := GetEscalationTicketsByRecording(db, recording.ID)
+	require.NoError(t, err)
+	assert.Len(t, tickets, 2)
+}
+
+func TestGetAutoEscalationConnectors(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &EscalationConnector{})
+	require.NoError(t, db.Create(&EscalationConnector{AssistantID: 1, UserID: 1, Provider: "jira", AutoEscalate: true, Enabled: true}).Error)
+	require.NoError(t, db.Create(&EscalationConnector{AssistantID: 1, UserID: 1, Provider: "webhook", AutoEscalate: false, Enabled: true}).Error)
+	require.NoError(t, db.Create(&EscalationConnector{AssistantID: 1, UserID: 1, Provider: "zendesk", AutoEscalate: true, Enabled: false}).Error)
+
+	all, err := GetEscalationConnectors(db, 1)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	auto, err := GetAutoEscalationConnectors(db, 1)
+	require.NoError(t, err)
+	require.Len(t, auto, 1)
+	assert.Equal(t, "jira", auto[0].Provider)
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/featureflag"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// featureFlagReloadInterval 功能开关的重新加载间隔，多实例部署时其他实例最迟在该时间后生效
+const featureFlagReloadInterval = 30 * time.Second
+
+// 功能开关变更审计的操作类型
+const (
+	FeatureFlagActionCreate = "create"
+	FeatureFlagActionUpdate = "update"
+	FeatureFlagActionDelete = "delete"
+)
+
+// FeatureFlag 功能开关，用于按组织、用户和百分比灰度发布高风险功能
+type FeatureFlag struct {
+	ID          uint               `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time          `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time          `json:"updatedAt" gorm:"autoUpdateTime"`
+	Key         string             `json:"key" gorm:"column:flag_key;size:64;uniqueIndex"`
+	Description string             `json:"description,omitempty" gorm:"type:text"`
+	Enabled     bool               `json:"enabled"`                                // 总开关，关闭时对所有人关闭
+	Default     bool               `json:"default" gorm:"column:default_value"`    // 没有规则命中时的取值
+	Rules       []featureflag.Rule `json:"rules" gorm:"type:text;serializer:json"` // 按顺序匹配，第一条命中的规则生效
+	UpdatedBy   uint               `json:"updatedBy"`
+}
+
+// TableName 指定表名
+func (FeatureFlag) TableName() string {
+	return "feature_flags"
+}
+
+// Flag 转换为评估用的开关定义
+func (f *FeatureFlag) Flag() *featureflag.Flag {
+	return &featureflag.Flag{Key: f.Key, Enabled: f.Enabled, Default: f.Default, Rules: f.Rules}
+}
+
+// Validate 检查开关键名与规则
+func (f *FeatureFlag) Validate() error {
+	return f.Flag().Validate()
+}
+
+// FeatureFlagAudit 功能开关的变更记录，保存变更前后的完整定义
+type FeatureFlagAudit struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+	FlagKey   string    `json:"flagKey" gorm:"size:64;index"`
+	Action    string    `json:"action" gorm:"size:16"`
+	UserID    uint      `json:"userId" gorm:"index"`
+	Before    string    `json:"before,omitempty" gorm:"type:text"` // JSON，创建时为空
+	After     string    `json:"after,omitempty" gorm:"type:text"`  // JSON，删除时为空
+}
+
+// TableName 指定表名
+func (FeatureFlagAudit) TableName() string {
+	return "feature_flag_audits"
+}
+
+// SaveFeatureFlag 创建或更新开关并记录审计，before 为更新前的定义，创建时为 nil
+func SaveFeatureFlag(db *gorm.DB, flag *FeatureFlag, before *FeatureFlag, userID uint) error {
+	action := FeatureFlagActionCreate
+	if before != nil {
+		action = FeatureFlagActionUpdate
+	}
+	flag.UpdatedBy = userID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(flag).Error; err != nil {
+			return err
+		}
+		return tx.Create(newFeatureFlagAudit(flag.Key, action, userID, before, flag)).Error
+	})
+	if err == nil {
+		InvalidateFeatureFlags()
+	}
+	return err
+}
+
+// DeleteFeatureFlag 删除开关并记录审计，审计记录保留
+func DeleteFeatureFlag(db *gorm.DB, flag *FeatureFlag, userID uint) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(flag).Error; err != nil {
+			return err
+		}
+		return tx.Create(newFeatureFlagAudit(flag.Key, FeatureFlagActionDelete, userID, flag, nil)).Error
+	})
+	if err == nil {
+		InvalidateFeatureFlags()
+	}
+	return err
+}
+
+func newFeatureFlagAudit(key, action string, userID uint, before, after *FeatureFlag) *FeatureFlagAudit {
+	audit := &FeatureFlagAudit{FlagKey: key, Action: action, UserID: userID}
+	if before != nil {
+		data, _ := json.Marshal(before)
+		audit.Before = string(data)
+	}
+	if after != nil {
+		data, _ := json.Marshal(after)
+		audit.After = string(data)
+	}
+	return audit
+}
+
+// GetFeatureFlag 按键名获取开关
+func GetFeatureFlag(db *gorm.DB, key string) (*FeatureFlag, error) {
+	var flag FeatureFlag
+	if err := db.Where("flag_key = ?", key).First(&flag).Error; err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// featureFlagCache 进程内的开关缓存，定期从数据库重新加载
+var featureFlagCache struct {
+	sync.Mutex
+	set      featureflag.Set
+	loadedAt time.Time
+}
+
+// LoadFeatureFlags 返回当前的开关集合，加载失败时沿用上次的结果
+func LoadFeatureFlags(db *gorm.DB) featureflag.Set {
+	featureFlagCache.Lock()
+	defer featureFlagCache.Unlock()
+	if featureFlagCache.set != nil && time.Since(featureFlagCache.loadedAt) < featureFlagReloadInterval {
+		return featureFlagCache.set
+	}
+	var flags []FeatureFlag
+	if err := db.Find(&flags).Error; err != nil {
+		logger.Warn("Failed to load feature flags, keep previous flags", zap.Error(err))
+		if featureFlagCache.set == nil {
+			return featureflag.Set{}
+		}
+		return featureFlagCache.set
+	}
+	set := make(featureflag.Set, len(flags))
+	for i := range flags {
+		set[flags[i].Key] = flags[i].Flag()
+	}
+	featureFlagCache.set, featureFlagCache.loadedAt = set, time.Now()
+	return set
+}
+
+// InvalidateFeatureFlags 开关变更后让下次评估重新加载
+func InvalidateFeatureFlags() {
+	featureFlagCache.Lock()
+	featureFlagCache.set = nil
+	featureFlagCache.Unlock()
+}
+
+// FeatureSubject 构造用户的开关评估主体，包含所属和创建的组织
+func FeatureSubject(db *gorm.DB, user *User) featureflag.Subject {
+	return featureflag.Subject{UserID: user.ID, Groups: userGroupIDs(db, user.ID)}
+}
+
+// EvaluateFeature 评估用户的功能开关，未定义的开关视为关闭
+func EvaluateFeature(db *gorm.DB, key string, user *User) featureflag.Evaluation {
+	if user == nil {
+		return featureflag.Evaluation{Key: key, Reason: featureflag.ReasonUnknown, Rule: -1}
+	}
+	return LoadFeatureFlags(db).Evaluate(key, FeatureSubject(db, user))
+}
+
+// FeatureEnabled 判断功能开关对用户是否开启，供处理函数在新旧实现之间切换
+func FeatureEnabled(c *gin.Context, key string) bool {
+	v, ok := c.Get(constants.DbField)
+	if !ok {
+		return false
+	}
+	db, _ := v.(*gorm.DB)
+	if db == nil {
+		return false
+	}
+	return EvaluateFeature(db, key, CurrentUser(c)).Enabled
+}
+
+// FeatureRequired 功能开关对当前用户关闭时返回 404，需放在 AuthRequired 之后
+func FeatureRequired(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !FeatureEnabled(c, key) {
+			LingEcho.AbortWithJSONError(c, http.StatusNotFound, errors.New("feature not available"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/featureflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &FeatureFlag{}, &FeatureFlagAudit{}, &Group{}, &GroupMember{})
+	InvalidateFeatureFlags()
+	t.Cleanup(InvalidateFeatureFlags)
+
+	require.NoError(t, db.Create(&GroupMember{UserID: 2, GroupID: 10, Role: "member"}).Error)
+	flag := &FeatureFlag{
+		Key:     "voice.pipeline-v2",
+		Enabled: true,
+		Rules:   []featureflag.Rule{{Groups: []uint{10}, Value: true}},
+	}
+	require.NoError(t, flag.Validate())
+	require.NoError(t, SaveFeatureFlag(db, flag, nil, 1))
+
+	assert.True(t, EvaluateFeature(db, "voice.pipeline-v2", userWithID(2)).Enabled)
+	assert.False(t, EvaluateFeature(db, "voice.pipeline-v2", userWithID(3)).Enabled)
+	assert.Equal(t, featureflag.ReasonUnknown, EvaluateFeature(db, "risk.engine-v2", userWithID(2)).Reason)
+
+	// Updates take effect immediately on this instance and are audited
+	stored, err := GetFeatureFlag(db, "voice.pipeline-v2")
+	require.NoError(t, err)
+	assert.Len(t, stored.Rules, 1)
+	before := *stored
+	stored.Enabled = false
+	require.NoError(t, SaveFeatureFlag(db, stored, &before, 1))
+	assert.False(t, EvaluateFeature(db, "voice.pipeline-v2", userWithID(2)).Enabled)
+
+	require.NoError(t, DeleteFeatureFlag(db, stored, 4))
+	_, err = GetFeatureFlag(db, "voice.pipeline-v2")
+	assert.Error(t, err)
+
+	var audits []FeatureFlagAudit
+	require.NoError(t, db.Order("id").Find(&audits).Error)
+	require.Len(t, audits, 3)
+	assert.Equal(t, FeatureFlagActionCreate, audits[0].Action)
+	assert.Empty(t, audits[0].Before)
+	assert.Equal(t, FeatureFlagActionUpdate, audits[1].Action)
+	var after FeatureFlag
+	require.NoError(t, json.Unmarshal([]byte(audits[1].After), &after))
+	assert.False(t, after.Enabled)
+	assert.Equal(t, FeatureFlagActionDelete, audits[2].Action)
+	assert.Equal(t, uint(4), audits[2].UserID)
+	assert.Empty(t, audits[2].After)
+}
+
+func userWithID(id uint) *User {
+	u := &User{}
+	u.ID = id
+	return u
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type GroupPermission struct {
@@ -62,3 +64,41 @@ type GroupInvitation struct {
 	Status    string     `json:"status" gorm:"size:20;index;default:'pending'"` // pending, accepted, rejected
 	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
+
+// IsGroupAdmin reports whether the user is the creator or an admin member of the organization
+func IsGroupAdmin(db *gorm.DB, group *Group, userID uint) bool {
+	if group.CreatorID == userID {
+		return true
+	}
+	var count int64
+	db.Model(&GroupMember{}).
+		Where("group_id = ? AND user_id = ? AND role = ?", group.ID, userID, GroupRoleAdmin).
+		Count(&count)
+	return count > 0
+}
+
+// IsGroupMember reports whether the user is the creator or a member of the organization
+func IsGroupMember(db *gorm.DB, group *Group, userID uint) bool {
+	if group.CreatorID == userID {
+		return true
+	}
+	var count int64
+	db.Model(&GroupMember{}).
+		Where("group_id = ? AND user_id = ?", group.ID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// GroupMemberIDs returns the user IDs of the organization members, creator included
+func GroupMemberIDs(db *gorm.DB, group *Group) ([]uint, error) {
+	var ids []uint
+	if err := db.Model(&GroupMember{}).Where("group_id = ?", group.ID).Distinct().Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if id == group.CreatorID {
+			return ids, nil
+		}
+	}
+	return append(ids, group.CreatorID), nil
+}
@@ -430,3 +430,21 @@ func BenchmarkGroup_Create(b *testing.B) {
 		db.Create(group)
 	}
 }
+
+func TestIsGroupAdminAndMember(t *testing.T) {
+	db := setupGroupsTestDB(t)
+
+	group := &Group{Name: "Fleet", CreatorID: 1}
+	require.NoError(t, db.Create(group).Error)
+	require.NoError(t, db.Create(&GroupMember{GroupID: group.ID, UserID: 2, Role: GroupRoleAdmin}).Error)
+	require.NoError(t, db.Create(&GroupMember{GroupID: group.ID, UserID: 3, Role: GroupRoleMember}).Error)
+
+	assert.True(t, IsGroupAdmin(db, group, 1))
+	assert.True(t, IsGroupAdmin(db, group, 2))
+	assert.False(t, IsGroupAdmin(db, group, 3))
+	assert.False(t, IsGroupAdmin(db, group, 4))
+
+	assert.True(t, IsGroupMember(db, group, 1))
+	assert.True(t, IsGroupMember(db, group, 3))
+	assert.False(t, IsGroupMember(db, group, 4))
+}
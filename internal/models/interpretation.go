@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 传译通话的双方
+const (
+	InterpretationLegCaller = "a" // 主叫
+	InterpretationLegCallee = "b" // 助手为主叫拨打的被叫
+)
+
+// ErrInvalidInterpreter 传译配置无效
+var ErrInvalidInterpreter = errors.New("interpreter mode needs the other party's language and a SIP URI to dial")
+
+// InterpretationSegment 传译通话中翻译的一句话，同时保存原文和译文
+type InterpretationSegment struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	CallID         string    `json:"callId" gorm:"size:128;index:idx_interpretation_call_seq,priority:1;not null"` // 主叫通话
+	Seq            int       `json:"seq" gorm:"index:idx_interpretation_call_seq,priority:2"`                      // 通话内识别的先后顺序
+	AssistantID    int64     `json:"assistantId" gorm:"index"`
+	Leg            string    `json:"leg" gorm:"size:1"` // 说话的一方，a 主叫 b 被叫
+	SourceLanguage string    `json:"sourceLanguage" gorm:"size:16"`
+	TargetLanguage string    `json:"targetLanguage" gorm:"size:16"`
+	SourceText     string    `json:"sourceText" gorm:"type:text"`
+	TranslatedText string    `json:"translatedText" gorm:"type:text"`
+	RecognizedAt   time.Time `json:"recognizedAt"`
+	LatencyMs      int64     `json:"latencyMs"` // 从识别出原文到开始播放译文
+	Error          string    `json:"error,omitempty" gorm:"size:512"`
+}
+
+// TableName 指定表名
+func (InterpretationSegment) TableName() string {
+	return "interpretation_segments"
+}
+
+// InterpretationLine 某一种语言的通话文本中的一句
+type InterpretationLine struct {
+	Seq        int       `json:"seq"`
+	Leg        string    `json:"leg"`
+	Text       string    `json:"text"`
+	Translated bool      `json:"translated"` // 由另一种语言翻译而来
+	At         time.Time `json:"at"`
+}
+
+// Interpreting 助手是否以传译模式接听呼入：主叫使用助手语言，助手拨打被叫并双向翻译
+func (a *Assistant) Interpreting() bool {
+	return a.InterpreterMode && a.InterpreterLanguage != "" && a.InterpreterTarget != ""
+}
+
+// ValidateInterpreter 检查开启传译模式时的被叫语言和 SIP 地址
+func ValidateInterpreter(enabled bool, language, target string) error {
+	if !enabled {
+		return nil
+	}
+	if strings.TrimSpace(language) == "" || !strings.HasPrefix(target, "sip:") || len(target) <= len("sip:") {
+		return ErrInvalidInterpreter
+	}
+	return nil
+}
+
+// CreateInterpretationSegment 保存传译的一句话
+func CreateInterpretationSegment(db *gorm.DB, segment *InterpretationSegment) error {
+	return db.Create(segment).Error
+}
+
+// ListInterpretationSegments 通话的传译记录，按识别顺序
+func ListInterpretationSegments(db *gorm.DB, callID string) ([]InterpretationSegment, error) {
+	var segments []InterpretationSegment
+	err := db.Where("call_id = ?", callID).Order("seq ASC, id ASC").Find(&segments).Error
+	return segments, err
+}
+
+// InterpretationTranscript 用一种语言呈现整通电话：该语言说的话用原文，另一方的话用译文；
+// 翻译失败的句子保留原文
+func InterpretationTranscript(segments []InterpretationSegment, language string) []InterpretationLine {
+	lines := make([]InterpretationLine, 0, len(segments))
+	for _, s := range segments {
+		line := InterpretationLine{Seq: s.Seq, Leg: s.Leg, Text: s.SourceText, At: s.RecognizedAt}
+		if !strings.EqualFold(s.SourceLanguage, language) && s.TranslatedText != "" {
+			line.Text = s.TranslatedText
+			line.Translated = true
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateInterpreter(t *testing.T) {
+	assert.NoError(t, ValidateInterpreter(false, "", ""))
+	assert.NoError(t, ValidateInterpreter(true, "en-US", "sip:1002@10.0.0.2"))
+	assert.ErrorIs(t, ValidateInterpreter(true, "", "sip:1002@10.0.0.2"), ErrInvalidInterpreter)
+	assert.ErrorIs(t, ValidateInterpreter(true, "en-US", "tel:+15551234"), ErrInvalidInterpreter)
+	assert.ErrorIs(t, ValidateInterpreter(true, "en-US", "sip:"), ErrInvalidInterpreter)
+
+	a := &Assistant{InterpreterMode: true, InterpreterLanguage: "en-US"}
+	assert.False(t, a.Interpreting(), "no party to dial")
+	a.InterpreterTarget = "sip:1002@10.0.0.2"
+	assert.True(t, a.Interpreting())
+}
+
+func TestInterpretationTranscript(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &InterpretationSegment{})
+	now := time.Now()
+	segments := []*InterpretationSegment{
+		{CallID: "call-1", Seq: 2, Leg: InterpretationLegCallee, SourceLanguage: "en-US", TargetLanguage: "zh-CN", SourceText: "Yes, we do.", TranslatedText: "有的。", RecognizedAt: now.Add(time.Second)},
+		{CallID: "call-1", Seq: 1, Leg: InterpretationLegCaller, SourceLanguage: "zh-CN", TargetLanguage: "en-US", SourceText: "有空房吗？", TranslatedText: "Any rooms available?", RecognizedAt: now},
+		{CallID: "call-1", Seq: 3, Leg: InterpretationLegCaller, SourceLanguage: "zh-CN", TargetLanguage: "en-US", SourceText: "好的", Error: "timeout", RecognizedAt: now.Add(2 * time.Second)},
+		{CallID: "call-2", Seq: 1, Leg: InterpretationLegCaller, SourceLanguage: "zh-CN", SourceText: "别的通话"},
+	}
+	for _, s := range segments {
+		require.NoError(t, CreateInterpretationSegment(db, s))
+	}
+
+	list, err := ListInterpretationSegments(db, "call-1")
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, 1, list[0].Seq)
+
+	zh := InterpretationTranscript(list, "zh-CN")
+	assert.Equal(t, "有空房吗？", zh[0].Text)
+	assert.False(t, zh[0].Translated)
+	assert.Equal(t, "有的。", zh[1].Text)
+	assert.True(t, zh[1].Translated)
+
+	en := InterpretationTranscript(list, "en-US")
+	assert.Equal(t, "Any rooms available?", en[0].Text)
+	assert.Equal(t, "Yes, we do.", en[1].Text)
+	assert.Equal(t, "好的", en[2].Text, "untranslated clauses keep the original text")
+	assert.False(t, en[2].Translated)
+}
@@ -11,19 +11,52 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrKnowledgeExists the user already has a knowledge base with the key
+var ErrKnowledgeExists = errors.New("knowledge base key already exists")
+
 // Knowledge represents a knowledge base entity
 type Knowledge struct {
 	ID            int       `json:"id" gorm:"column:id"`
-	UserID        int       `json:"user_id" gorm:"column:user_id"`
+	UserID        int       `json:"user_id" gorm:"column:user_id;uniqueIndex:idx_knowledge_user_key"`
 	GroupID       *uint     `json:"group_id,omitempty" gorm:"column:group_id;index"` // Organization ID, if set indicates this is an organization-shared knowledge base
-	KnowledgeKey  string    `json:"knowledge_key" gorm:"column:knowledge_key"`
+	KnowledgeKey  string    `json:"knowledge_key" gorm:"column:knowledge_key;size:255;uniqueIndex:idx_knowledge_user_key"`
 	KnowledgeName string    `json:"knowledge_name" gorm:"column:knowledge_name"`
 	IndexId       string    `json:"index_id" gorm:"column:index_id"`                // Index ID for providers like Aliyun (may differ from knowledge_key)
 	Provider      string    `json:"provider" gorm:"column:provider;default:aliyun"` // Knowledge base provider type
 	Config        string    `json:"config" gorm:"column:config;type:text"`          // Configuration information (JSON format)
 	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
-	UpdateAt      time.Time `json:"update_at" gorm:"column:update_at"`
-	DeleteAt      time.Time `json:"delete_at" gorm:"column:delete_at"`
+	// UpdateAt is being renamed to UpdatedAt, both columns are written until
+	// KnowledgeUpdatedAtRename drops update_at; read whichever field, they are kept equal
+	UpdateAt  time.Time `json:"update_at" gorm:"column:update_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;autoUpdateTime:false"`
+	DeleteAt  time.Time `json:"delete_at" gorm:"column:delete_at"`
+}
+
+// KnowledgeUpdatedAtRename renames knowledges.update_at to updated_at
+var KnowledgeUpdatedAtRename = RegisterColumnRename(ColumnRename{
+	Name:  "knowledge.update_at",
+	Table: "knowledges",
+	From:  "update_at",
+	To:    "updated_at",
+})
+
+// BeforeSave writes the update time to both columns of KnowledgeUpdatedAtRename
+func (k *Knowledge) BeforeSave(tx *gorm.DB) error {
+	if k.UpdatedAt.IsZero() || (!k.UpdateAt.IsZero() && k.UpdateAt.After(k.UpdatedAt)) {
+		k.UpdatedAt = k.UpdateAt
+	}
+	k.UpdateAt = k.UpdatedAt
+	return nil
+}
+
+// AfterFind fills both update time fields from the column currently read
+func (k *Knowledge) AfterFind(tx *gorm.DB) error {
+	if KnowledgeUpdatedAtRename.ReadsNew() {
+		k.UpdateAt = k.UpdatedAt
+	} else {
+		k.UpdatedAt = k.UpdateAt
+	}
+	return nil
 }
 
 // KnowledgeList contains knowledge base list wrapper structure
@@ -57,7 +90,9 @@ func CreateKnowledge(db *gorm.DB, userID int, knowledgeKey string, knowledgeName
 	return CreateKnowledgeWithIndexId(db, userID, knowledgeKey, knowledgeName, provider, config, groupID, knowledgeKey)
 }
 
-// CreateKnowledgeWithIndexId creates a knowledge base with explicit indexId
+// CreateKnowledgeWithIndexId creates a knowledge base with explicit indexId. When
+// the user already has a knowledge base with the key, it is returned together
+// with ErrKnowledgeExists.
 func CreateKnowledgeWithIndexId(db *gorm.DB, userID int, knowledgeKey string, knowledgeName string, provider string, config map[string]interface{}, groupID *uint, indexId string) (Knowledge, error) {
 	// Check if user exists
 	var user User
@@ -71,17 +106,6 @@ func CreateKnowledgeWithIndexId(db *gorm.DB, userID int, knowledgeKey string, kn
 		return Knowledge{}, errors.Join(errors.New("failed to create knowledge base"), err)
 	}
 
-	// Check if knowledge base key already exists for the same user
-	var existingKnowledge Knowledge
-	err = db.Model(&Knowledge{}).
-		Where("knowledge_key = ? AND user_id = ?", knowledgeKey, userID).
-		First(&existingKnowledge).Error
-	if err == nil {
-		return Knowledge{}, errors.New("knowledge base key already exists")
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return Knowledge{}, errors.Join(errors.New("failed to create knowledge base"), err)
-	}
-
 	// Default provider is aliyun (for backward compatibility)
 	if provider == "" {
 		provider = knowledge.ProviderAliyun
@@ -109,13 +133,18 @@ func CreateKnowledgeWithIndexId(db *gorm.DB, userID int, knowledgeKey string, kn
 		Config:        configJSON,
 		CreatedAt:     now,
 		UpdateAt:      now,
+		UpdatedAt:     now,
 		DeleteAt:      now,
 	}
 
-	err = db.Create(&knowledge).Error
+	// The unique index on (user_id, knowledge_key) decides between concurrent creates
+	created, err := CreateOrGet(db, &knowledge, "UserID", "KnowledgeKey")
 	if err != nil {
 		return Knowledge{}, errors.Join(errors.New("failed to create knowledge base"), err)
 	}
+	if !created {
+		return knowledge, ErrKnowledgeExists
+	}
 
 	return knowledge, nil
 }
@@ -195,6 +224,23 @@ func GetKnowledge(db *gorm.DB, knowledgeKey string) (*Knowledge, error) {
 	return &k, nil
 }
 
+// TouchKnowledge marks the knowledge base content as changed, which starts a new knowledge base version
+func TouchKnowledge(db *gorm.DB, knowledgeKey string) error {
+	return db.Model(&Knowledge{}).Where("knowledge_key = ?", knowledgeKey).Updates(KnowledgeUpdatedAtRename.Values(time.Now())).Error
+}
+
+// KnowledgeVersion returns the version of the knowledge base content, 0 when it does not exist
+func KnowledgeVersion(db *gorm.DB, knowledgeKey string) int64 {
+	if knowledgeKey == "" {
+		return 0
+	}
+	var k Knowledge
+	if err := db.Select(KnowledgeUpdatedAtRename.ReadColumn()).Where("knowledge_key = ?", knowledgeKey).First(&k).Error; err != nil {
+		return 0
+	}
+	return k.UpdateAt.UnixNano()
+}
+
 // GetKnowledgeBaseInfo gets information from knowledge base (using new unified interface)
 // This method maintains backward compatibility, returning concatenated text content
 func GetKnowledgeBaseInfo(db *gorm.DB, knowledgeKey string) (string, error) {
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/faq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Review status of FAQ proposals
+const (
+	KnowledgeFAQPending  = "pending"
+	KnowledgeFAQApproved = "approved"
+	KnowledgeFAQRejected = "rejected"
+)
+
+var ErrKnowledgeFAQReviewed = errors.New("FAQ proposal has already been reviewed")
+
+// KnowledgeFAQProposal question and drafted answer mined from call transcripts.
+// It is added to the knowledge base as a document only after a reviewer approves it;
+// rejected proposals are kept so the same question is not proposed again.
+type KnowledgeFAQProposal struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	KnowledgeID  int        `json:"knowledgeId" gorm:"not null;uniqueIndex:idx_knowledge_faq_question;index:idx_knowledge_faq_status"`
+	UserID       uint       `json:"userId" gorm:"index"` // Owner of the knowledge base
+	AssistantID  int64      `json:"assistantId" gorm:"index"`
+	Question     string     `json:"question" gorm:"type:text"`
+	Answer       string     `json:"answer" gorm:"type:text"`
+	QuestionHash string     `json:"-" gorm:"size:64;uniqueIndex:idx_knowledge_faq_question"`
+	CallIDs      string     `json:"-" gorm:"type:text"` // JSON array of the SIP Call-IDs of the cluster
+	CallCount    int        `json:"callCount"`          // Calls in the cluster the question was mined from
+	Sources      []string   `json:"callIds" gorm:"-"`
+	Status       string     `json:"status" gorm:"size:16;index:idx_knowledge_faq_status"`
+	ReviewerID   *uint      `json:"reviewerId,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewedAt,omitempty"`
+	RejectReason string     `json:"rejectReason,omitempty" gorm:"size:512"`
+	DocumentName string     `json:"documentName,omitempty" gorm:"size:255"` // Document the approved FAQ was uploaded as
+}
+
+func (KnowledgeFAQProposal) TableName() string {
+	return "knowledge_faq_proposals"
+}
+
+// AfterFind decodes the source Call-IDs
+func (p *KnowledgeFAQProposal) AfterFind(tx *gorm.DB) error {
+	p.Sources = nil
+	if p.CallIDs != "" {
+		_ = json.Unmarshal([]byte(p.CallIDs), &p.Sources)
+	}
+	return nil
+}
+
+// Document renders the approved question and answer as the text document added
+// to the knowledge base
+func (p *KnowledgeFAQProposal) Document() string {
+	return "Q: " + p.Question + "\n\nA: " + p.Answer + "\n"
+}
+
+// FAQTranscript completed transcript of a call answered by an assistant with a
+// knowledge base
+type FAQTranscript struct {
+	CallID        string
+	AssistantID   int64
+	KnowledgeKey  string
+	Transcription string
+}
+
+// ListFAQTranscripts returns the completed transcripts of AI answered calls
+// started since the given time, newest first
+func ListFAQTranscripts(db *gorm.DB, since time.Time, limit int) ([]FAQTranscript, error) {
+	var rows []FAQTranscript
+	query := db.Table("sip_calls").
+		Select("sip_calls.call_id, ai_call_sessions.assistant_id, assistants.knowledge_base_id AS knowledge_key, sip_calls.transcription").
+		Joins("JOIN ai_call_sessions ON ai_call_sessions.call_id = sip_calls.call_id AND ai_call_sessions.deleted_at IS NULL").
+		Joins("JOIN assistants ON assistants.id = ai_call_sessions.assistant_id").
+		Where("sip_calls.deleted_at IS NULL AND sip_calls.transcription_status = ? AND sip_calls.transcription <> ''", "completed").
+		Where("sip_calls.start_time >= ?", since).
+		Where("assistants.knowledge_base_id IS NOT NULL AND assistants.knowledge_base_id <> ''").
+		Order("sip_calls.start_time DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Scan(&rows).Error
+	return rows, err
+}
+
+// KnowledgeFAQQuestions returns the questions already proposed for a knowledge
+// base in any status, so the generator can skip rewordings of them
+func KnowledgeFAQQuestions(db *gorm.DB, knowledgeID int) ([]string, error) {
+	var questions []string
+	err := db.Model(&KnowledgeFAQProposal{}).Where("knowledge_id = ?", knowledgeID).Pluck("question", &questions).Error
+	return questions, err
+}
+
+// CreateKnowledgeFAQProposal stores a pending proposal. It returns false without
+// error when the knowledge base already has a proposal for the same question.
+func CreateKnowledgeFAQProposal(db *gorm.DB, p *KnowledgeFAQProposal, callIDs []string) (bool, error) {
+	raw, err := json.Marshal(callIDs)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256([]byte(faq.NormalizeQuestion(p.Question)))
+	p.QuestionHash = hex.EncodeToString(sum[:])
+	p.CallIDs = string(raw)
+	p.CallCount = len(callIDs)
+	p.Sources = callIDs
+	p.Status = KnowledgeFAQPending
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(p)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListKnowledgeFAQProposals lists the proposals of a knowledge base, most calls
+// first, optionally filtered by status
+func ListKnowledgeFAQProposals(db *gorm.DB, knowledgeID int, status string, page, size int) ([]KnowledgeFAQProposal, int64, error) {
+	query := db.Model(&KnowledgeFAQProposal{}).Where("knowledge_id = ?", knowledgeID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	proposals := []KnowledgeFAQProposal{}
+	err := query.Order("call_count DESC, id DESC").Offset((page - 1) * size).Limit(size).Find(&proposals).Error
+	return proposals, total, err
+}
+
+// GetKnowledgeFAQProposal gets a proposal by ID
+func GetKnowledgeFAQProposal(db *gorm.DB, id uint) (*KnowledgeFAQProposal, error) {
+	var p KnowledgeFAQProposal
+	if err := db.First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ApproveKnowledgeFAQProposal marks a pending proposal approved with the
+// reviewed question and answer. Only one of concurrent reviews succeeds, the
+// others get ErrKnowledgeFAQReviewed.
+func ApproveKnowledgeFAQProposal(db *gorm.DB, p *KnowledgeFAQProposal, reviewerID uint, question, answer, documentName string, now time.Time) error {
+	return reviewKnowledgeFAQProposal(db, p, map[string]interface{}{
+		"status":        KnowledgeFAQApproved,
+		"question":      question,
+		"answer":        answer,
+		"document_name": documentName,
+		"reviewer_id":   reviewerID,
+		"reviewed_at":   now,
+	})
+}
+
+// RejectKnowledgeFAQProposal marks a pending proposal rejected
+func RejectKnowledgeFAQProposal(db *gorm.DB, p *KnowledgeFAQProposal, reviewerID uint, reason string, now time.Time) error {
+	return reviewKnowledgeFAQProposal(db, p, map[string]interface{}{
+		"status":        KnowledgeFAQRejected,
+		"reject_reason": truncateRunes(reason, 512),
+		"reviewer_id":   reviewerID,
+		"reviewed_at":   now,
+	})
+}
+
+// ReopenKnowledgeFAQProposal returns an approved proposal to pending, used when
+// adding its document to the knowledge base failed
+func ReopenKnowledgeFAQProposal(db *gorm.DB, p *KnowledgeFAQProposal) error {
+	return db.Model(&KnowledgeFAQProposal{}).Where("id = ? AND status = ?", p.ID, KnowledgeFAQApproved).
+		Updates(map[string]interface{}{
+			"status":        KnowledgeFAQPending,
+			"document_name": "",
+			"reviewer_id":   nil,
+			"reviewed_at":   nil,
+		}).Error
+}
+
+func reviewKnowledgeFAQProposal(db *gorm.DB, p *KnowledgeFAQProposal, updates map[string]interface{}) error {
+	result := db.Model(&KnowledgeFAQProposal{}).Where("id = ? AND status = ?", p.ID, KnowledgeFAQPending).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrKnowledgeFAQReviewed
+	}
+	reviewed, err := GetKnowledgeFAQProposal(db, p.ID)
+	if err != nil {
+		return err
+	}
+	*p = *reviewed
+	return nil
+}
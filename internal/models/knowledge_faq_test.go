@@ -0,0 +1,110 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateKnowledgeFAQProposal_Dedup(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeFAQProposal{})
+
+	p := &KnowledgeFAQProposal{KnowledgeID: 7, UserID: 1, Question: "How do I reset my password?", Answer: "Use forgot password."}
+	created, err := CreateKnowledgeFAQProposal(db, p, []string{"c1", "c2"})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, KnowledgeFAQPending, p.Status)
+	assert.Equal(t, 2, p.CallCount)
+
+	created, err = CreateKnowledgeFAQProposal(db, &KnowledgeFAQProposal{KnowledgeID: 7, Question: "how do i reset my password", Answer: "x"}, []string{"c3"})
+	require.NoError(t, err)
+	assert.False(t, created, "same question after normalization")
+
+	created, err = CreateKnowledgeFAQProposal(db, &KnowledgeFAQProposal{KnowledgeID: 8, Question: "How do I reset my password?", Answer: "x"}, []string{"c4"})
+	require.NoError(t, err)
+	assert.True(t, created, "questions are unique per knowledge base")
+
+	got, err := GetKnowledgeFAQProposal(db, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1", "c2"}, got.Sources)
+
+	questions, err := KnowledgeFAQQuestions(db, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"How do I reset my password?"}, questions)
+}
+
+func TestReviewKnowledgeFAQProposal(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeFAQProposal{})
+	now := time.Now()
+
+	a := &KnowledgeFAQProposal{KnowledgeID: 7, Question: "Opening hours?", Answer: "9 to 5"}
+	_, err := CreateKnowledgeFAQProposal(db, a, []string{"c1", "c2", "c3"})
+	require.NoError(t, err)
+	b := &KnowledgeFAQProposal{KnowledgeID: 7, Question: "Do you ship abroad?", Answer: "No"}
+	_, err = CreateKnowledgeFAQProposal(db, b, []string{"c4"})
+	require.NoError(t, err)
+
+	require.NoError(t, ApproveKnowledgeFAQProposal(db, a, 9, "What are the opening hours?", "9am to 5pm", "faq-1.txt", now))
+	assert.Equal(t, KnowledgeFAQApproved, a.Status)
+	assert.Equal(t, "What are the opening hours?", a.Question)
+	assert.Equal(t, "faq-1.txt", a.DocumentName)
+	require.NotNil(t, a.ReviewerID)
+	assert.Equal(t, uint(9), *a.ReviewerID)
+	assert.Equal(t, "Q: What are the opening hours?\n\nA: 9am to 5pm\n", a.Document())
+
+	assert.ErrorIs(t, RejectKnowledgeFAQProposal(db, a, 9, "late", now), ErrKnowledgeFAQReviewed)
+
+	require.NoError(t, RejectKnowledgeFAQProposal(db, b, 9, "not offered", now))
+	assert.Equal(t, KnowledgeFAQRejected, b.Status)
+	assert.Equal(t, "not offered", b.RejectReason)
+
+	pending, total, err := ListKnowledgeFAQProposals(db, 7, KnowledgeFAQPending, 1, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, pending)
+
+	all, total, err := ListKnowledgeFAQProposals(db, 7, "", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, a.ID, all[0].ID, "most asked first")
+
+	require.NoError(t, ReopenKnowledgeFAQProposal(db, a))
+	reopened, err := GetKnowledgeFAQProposal(db, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, KnowledgeFAQPending, reopened.Status)
+	assert.Nil(t, reopened.ReviewerID)
+	assert.Empty(t, reopened.DocumentName)
+}
+
+func TestListFAQTranscripts(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{}, &AICallSession{}, &Assistant{})
+	now := time.Now()
+	kb := "1_support"
+
+	require.NoError(t, db.Create(&Assistant{ID: 1, Name: "support", KnowledgeBaseID: &kb}).Error)
+	require.NoError(t, db.Create(&Assistant{ID: 2, Name: "no kb"}).Error)
+
+	calls := []struct {
+		callID      string
+		assistantID int64
+		status      string
+		start       time.Time
+	}{
+		{"recent", 1, "completed", now.Add(-time.Hour)},
+		{"old", 1, "completed", now.Add(-30 * 24 * time.Hour)},
+		{"pending", 1, "pending", now.Add(-time.Hour)},
+		{"no-kb", 2, "completed", now.Add(-time.Hour)},
+	}
+	for _, c := range calls {
+		require.NoError(t, db.Create(&SipCall{CallID: c.callID, StartTime: c.start, Transcription: "hello " + c.callID, TranscriptionStatus: c.status}).Error)
+		require.NoError(t, db.Create(&AICallSession{CallID: c.callID, AssistantID: c.assistantID, Status: "ended"}).Error)
+	}
+	require.NoError(t, db.Create(&SipCall{CallID: "no-session", StartTime: now, Transcription: "hi", TranscriptionStatus: "completed"}).Error)
+
+	rows, err := ListFAQTranscripts(db, now.Add(-7*24*time.Hour), 100)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, FAQTranscript{CallID: "recent", AssistantID: 1, KnowledgeKey: kb, Transcription: "hello recent"}, rows[0])
+}
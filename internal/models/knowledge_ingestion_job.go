@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	KnowledgeIngestionPending   = "pending"
+	KnowledgeIngestionSucceeded = "succeeded"
+	KnowledgeIngestionFailed    = "failed"
+)
+
+// KnowledgeIngestionJob document indexing job running at the provider, completed by the provider callback
+type KnowledgeIngestionJob struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	KnowledgeID   int        `json:"knowledgeId" gorm:"index;not null"`
+	UserID        uint       `json:"userId" gorm:"index;not null"`
+	Provider      string     `json:"provider" gorm:"size:32;uniqueIndex:idx_knowledge_ingestion_provider_job"`
+	ProviderJobID string     `json:"providerJobId" gorm:"size:128;uniqueIndex:idx_knowledge_ingestion_provider_job"`
+	Filename      string     `json:"filename" gorm:"size:255"`
+	Size          int64      `json:"size"`
+	ContentType   string     `json:"contentType,omitempty" gorm:"size:128"`
+	Status        string     `json:"status" gorm:"size:20;index;default:'pending'"`
+	Error         string     `json:"error,omitempty" gorm:"type:text"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+}
+
+// TableName 指定表名
+func (KnowledgeIngestionJob) TableName() string {
+	return "knowledge_ingestion_jobs"
+}
+
+// CreateKnowledgeIngestionJob records a submitted provider job as pending
+func CreateKnowledgeIngestionJob(db *gorm.DB, job *KnowledgeIngestionJob) error {
+	job.Status = KnowledgeIngestionPending
+	return db.Create(job).Error
+}
+
+// GetKnowledgeIngestionJobByProviderJob finds the job of a provider callback, nil if unknown
+func GetKnowledgeIngestionJobByProviderJob(db *gorm.DB, provider, providerJobID string) (*KnowledgeIngestionJob, error) {
+	var job KnowledgeIngestionJob
+	err := db.Where("provider = ? AND provider_job_id = ?", provider, providerJobID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompleteKnowledgeIngestionJob moves a pending job to succeeded or failed.
+// It returns false when the job was already finished, so redelivered callbacks are applied once
+func CompleteKnowledgeIngestionJob(db *gorm.DB, job *KnowledgeIngestionJob, succeeded bool, message string) (bool, error) {
+	now := time.Now()
+	status := KnowledgeIngestionSucceeded
+	if !succeeded {
+		status = KnowledgeIngestionFailed
+	}
+	result := db.Model(&KnowledgeIngestionJob{}).
+		Where("id = ? AND status = ?", job.ID, KnowledgeIngestionPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"error":       message,
+			"finished_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	job.Status = status
+	job.Error = message
+	job.FinishedAt = &now
+	return true, nil
+}
+
+// ListKnowledgeIngestionJobs lists the latest ingestion jobs of a knowledge base, newest first
+func ListKnowledgeIngestionJobs(db *gorm.DB, knowledgeID int, limit int) ([]KnowledgeIngestionJob, error) {
+	jobs := []KnowledgeIngestionJob{}
+	err := db.Where("knowledge_id = ?", knowledgeID).Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnowledgeIngestionJob_Complete(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeIngestionJob{})
+
+	job := &KnowledgeIngestionJob{KnowledgeID: 1, UserID: 7, Provider: "aliyun", ProviderJobID: "job-1", Filename: "faq.pdf", Size: 42}
+	require.NoError(t, CreateKnowledgeIngestionJob(db, job))
+	assert.Equal(t, KnowledgeIngestionPending, job.Status)
+
+	found, err := GetKnowledgeIngestionJobByProviderJob(db, "aliyun", "job-1")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, job.ID, found.ID)
+
+	missing, err := GetKnowledgeIngestionJobByProviderJob(db, "aliyun", "job-2")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	changed, err := CompleteKnowledgeIngestionJob(db, found, false, "parse error")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, KnowledgeIngestionFailed, found.Status)
+	assert.NotNil(t, found.FinishedAt)
+
+	// Redelivered callbacks do not change a finished job
+	changed, err = CompleteKnowledgeIngestionJob(db, job, true, "")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	jobs, err := ListKnowledgeIngestionJobs(db, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, KnowledgeIngestionFailed, jobs[0].Status)
+	assert.Equal(t, "parse error", jobs[0].Error)
+}
@@ -0,0 +1,341 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status of snapshot and restore jobs
+const (
+	KnowledgeSnapshotPending   = "pending"
+	KnowledgeSnapshotRunning   = "running"
+	KnowledgeSnapshotCompleted = "completed"
+	KnowledgeSnapshotFailed    = "failed"
+)
+
+const (
+	// KnowledgeSnapshotMaxAttempts attempts before a snapshot or restore job is failed
+	KnowledgeSnapshotMaxAttempts = 3
+	// knowledgeSnapshotStale running jobs not updated for this long were interrupted and are claimed again
+	knowledgeSnapshotStale = 10 * time.Minute
+)
+
+var (
+	ErrKnowledgeSnapshotNotReady = errors.New("snapshot is not completed")
+	ErrKnowledgeSnapshotBusy     = errors.New("a snapshot of this knowledge base is already in progress")
+)
+
+// KnowledgeSnapshot versioned export of all chunks, embeddings and metadata of a
+// knowledge base index, written to object storage by the snapshot worker
+type KnowledgeSnapshot struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	KnowledgeID  int        `json:"knowledgeId" gorm:"not null;uniqueIndex:idx_knowledge_snapshot_version"`
+	Version      int        `json:"version" gorm:"not null;uniqueIndex:idx_knowledge_snapshot_version"`
+	UserID       uint       `json:"userId" gorm:"index"`
+	Note         string     `json:"note,omitempty" gorm:"size:255"`
+	Provider     string     `json:"provider" gorm:"size:32"`
+	KnowledgeKey string     `json:"knowledgeKey" gorm:"size:255"` // Index the chunks were exported from
+	Status       string     `json:"status" gorm:"size:16;index"`
+	Attempts     int        `json:"attempts"`
+	Processed    int        `json:"processed"` // Chunks exported so far
+	Total        int        `json:"total"`     // Chunks in the index when the export started
+	Progress     float64    `json:"progress" gorm:"-"`
+	StorageKey   string     `json:"storageKey,omitempty" gorm:"size:512"`
+	StorageURL   string     `json:"-" gorm:"size:1024"`
+	Size         int64      `json:"size"`
+	Error        string     `json:"error,omitempty" gorm:"size:512"`
+	CompletedAt  *time.Time `json:"completedAt,omitempty"`
+}
+
+func (KnowledgeSnapshot) TableName() string {
+	return "knowledge_snapshots"
+}
+
+// AfterFind fills the progress percentage
+func (s *KnowledgeSnapshot) AfterFind(tx *gorm.DB) error {
+	s.Progress = snapshotProgress(s.Status, s.Processed, s.Total)
+	return nil
+}
+
+// KnowledgeSnapshotRestore restores a snapshot into its own index or into a new
+// knowledge base created for it
+type KnowledgeSnapshotRestore struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	SnapshotID  uint      `json:"snapshotId" gorm:"index;not null"`
+	KnowledgeID int       `json:"knowledgeId" gorm:"index;not null"` // Knowledge base the snapshot was taken of
+	UserID      uint      `json:"userId" gorm:"index"`
+	// NewName when set, the snapshot is restored into a new knowledge base with
+	// this name; otherwise it is written back into the original index
+	NewName string `json:"newName,omitempty" gorm:"size:255"`
+	// Replace empties the original index before restoring, so chunks added after
+	// the snapshot are removed; without it restored chunks overwrite by ID
+	Replace           bool       `json:"replace"`
+	TargetKnowledgeID int        `json:"targetKnowledgeId,omitempty"`
+	TargetKey         string     `json:"targetKey,omitempty" gorm:"size:255"`
+	Status            string     `json:"status" gorm:"size:16;index"`
+	Attempts          int        `json:"attempts"`
+	Processed         int        `json:"processed"`
+	Total             int        `json:"total"`
+	Progress          float64    `json:"progress" gorm:"-"`
+	Error             string     `json:"error,omitempty" gorm:"size:512"`
+	CompletedAt       *time.Time `json:"completedAt,omitempty"`
+}
+
+func (KnowledgeSnapshotRestore) TableName() string {
+	return "knowledge_snapshot_restores"
+}
+
+// AfterFind fills the progress percentage
+func (r *KnowledgeSnapshotRestore) AfterFind(tx *gorm.DB) error {
+	r.Progress = snapshotProgress(r.Status, r.Processed, r.Total)
+	return nil
+}
+
+func snapshotProgress(status string, processed, total int) float64 {
+	if status == KnowledgeSnapshotCompleted {
+		return 100
+	}
+	if total <= 0 {
+		return 0
+	}
+	return min(float64(processed)*100/float64(total), 99)
+}
+
+// CreateKnowledgeSnapshot queues a snapshot of the knowledge base as its next version
+func CreateKnowledgeSnapshot(db *gorm.DB, k *Knowledge, userID uint, note string) (*KnowledgeSnapshot, error) {
+	s := &KnowledgeSnapshot{
+		KnowledgeID:  k.ID,
+		UserID:       userID,
+		Note:         truncateRunes(note, 255),
+		Provider:     k.Provider,
+		KnowledgeKey: k.KnowledgeKey,
+		Status:       KnowledgeSnapshotPending,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var busy int64
+		if err := tx.Model(&KnowledgeSnapshot{}).
+			Where("knowledge_id = ? AND status IN ?", k.ID, []string{KnowledgeSnapshotPending, KnowledgeSnapshotRunning}).
+			Count(&busy).Error; err != nil {
+			return err
+		}
+		if busy > 0 {
+			return ErrKnowledgeSnapshotBusy
+		}
+		var latest int
+		if err := tx.Model(&KnowledgeSnapshot{}).Where("knowledge_id = ?", k.ID).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		s.Version = latest + 1
+		return tx.Create(s).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListKnowledgeSnapshots snapshots of a knowledge base, newest version first
+func ListKnowledgeSnapshots(db *gorm.DB, knowledgeID int, limit int) ([]KnowledgeSnapshot, error) {
+	var snapshots []KnowledgeSnapshot
+	err := db.Where("knowledge_id = ?", knowledgeID).Order("version DESC").Limit(limit).Find(&snapshots).Error
+	return snapshots, err
+}
+
+// GetKnowledgeSnapshot loads a snapshot of the knowledge base by version
+func GetKnowledgeSnapshot(db *gorm.DB, knowledgeID int, version int) (*KnowledgeSnapshot, error) {
+	var s KnowledgeSnapshot
+	if err := db.Where("knowledge_id = ? AND version = ?", knowledgeID, version).First(&s).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// claimSnapshotJob marks a queued job running unless another worker claimed it first
+func claimSnapshotJob(db *gorm.DB, model any, id uint, status string, attempts int, now time.Time) (bool, error) {
+	res := db.Model(model).
+		Where("id = ? AND status = ? AND attempts = ?", id, status, attempts).
+		Updates(map[string]any{"status": KnowledgeSnapshotRunning, "attempts": attempts + 1, "updated_at": now})
+	return res.RowsAffected > 0, res.Error
+}
+
+func claimableSnapshotJobs(db *gorm.DB, now time.Time, limit int) *gorm.DB {
+	return db.Where("status = ? OR (status = ? AND updated_at < ?)",
+		KnowledgeSnapshotPending, KnowledgeSnapshotRunning, now.Add(-knowledgeSnapshotStale)).
+		Order("id").Limit(limit)
+}
+
+// ClaimKnowledgeSnapshots claims queued (and interrupted) snapshot jobs and marks them running
+func ClaimKnowledgeSnapshots(db *gorm.DB, now time.Time, limit int) ([]KnowledgeSnapshot, error) {
+	var candidates []KnowledgeSnapshot
+	if err := claimableSnapshotJobs(db, now, limit).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	claimed := make([]KnowledgeSnapshot, 0, len(candidates))
+	for _, s := range candidates {
+		ok, err := claimSnapshotJob(db, &KnowledgeSnapshot{}, s.ID, s.Status, s.Attempts, now)
+		if err != nil {
+			return claimed, err
+		}
+		if !ok {
+			continue
+		}
+		s.Status = KnowledgeSnapshotRunning
+		s.Attempts++
+		s.Processed = 0
+		claimed = append(claimed, s)
+	}
+	return claimed, nil
+}
+
+// UpdateKnowledgeSnapshotProgress records how many chunks were exported; it also
+// keeps the running job from being considered stale
+func UpdateKnowledgeSnapshotProgress(db *gorm.DB, s *KnowledgeSnapshot, processed, total int) error {
+	s.Processed = processed
+	s.Total = max(total, processed)
+	return db.Model(s).Updates(map[string]any{"processed": s.Processed, "total": s.Total, "updated_at": time.Now()}).Error
+}
+
+// CompleteKnowledgeSnapshot records the uploaded snapshot file
+func CompleteKnowledgeSnapshot(db *gorm.DB, s *KnowledgeSnapshot, storageKey, storageURL string, size int64, chunks int, now time.Time) error {
+	s.Status = KnowledgeSnapshotCompleted
+	s.StorageKey = storageKey
+	s.StorageURL = storageURL
+	s.Size = size
+	s.Processed = chunks
+	s.Total = chunks
+	s.Progress = 100
+	s.Error = ""
+	s.CompletedAt = &now
+	return db.Model(s).Updates(map[string]any{
+		"status":       s.Status,
+		"storage_key":  storageKey,
+		"storage_url":  storageURL,
+		"size":         size,
+		"processed":    chunks,
+		"total":        chunks,
+		"error":        "",
+		"completed_at": now,
+	}).Error
+}
+
+// FailKnowledgeSnapshot records the failure and queues the job again until the
+// attempts are used up, returns whether the job failed for good
+func FailKnowledgeSnapshot(db *gorm.DB, s *KnowledgeSnapshot, cause error) (bool, error) {
+	s.Error = truncateRunes(cause.Error(), 512)
+	s.Status = KnowledgeSnapshotPending
+	if s.Attempts >= KnowledgeSnapshotMaxAttempts {
+		s.Status = KnowledgeSnapshotFailed
+	}
+	err := db.Model(s).Updates(map[string]any{"status": s.Status, "error": s.Error}).Error
+	return s.Status == KnowledgeSnapshotFailed, err
+}
+
+// CreateKnowledgeSnapshotRestore queues restoring a completed snapshot. newName
+// restores into a new knowledge base, otherwise into the original index
+func CreateKnowledgeSnapshotRestore(db *gorm.DB, s *KnowledgeSnapshot, userID uint, newName string, replace bool) (*KnowledgeSnapshotRestore, error) {
+	if s.Status != KnowledgeSnapshotCompleted {
+		return nil, ErrKnowledgeSnapshotNotReady
+	}
+	r := &KnowledgeSnapshotRestore{
+		SnapshotID:  s.ID,
+		KnowledgeID: s.KnowledgeID,
+		UserID:      userID,
+		NewName:     newName,
+		Replace:     replace && newName == "",
+		Status:      KnowledgeSnapshotPending,
+		Total:       s.Total,
+	}
+	if newName == "" {
+		r.TargetKnowledgeID = s.KnowledgeID
+		r.TargetKey = s.KnowledgeKey
+	}
+	return r, db.Create(r).Error
+}
+
+// GetKnowledgeSnapshotRestore loads a restore job of the knowledge base
+func GetKnowledgeSnapshotRestore(db *gorm.DB, knowledgeID int, id uint) (*KnowledgeSnapshotRestore, error) {
+	var r KnowledgeSnapshotRestore
+	if err := db.Where("id = ? AND knowledge_id = ?", id, knowledgeID).First(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListKnowledgeSnapshotRestores recent restore jobs of a knowledge base
+func ListKnowledgeSnapshotRestores(db *gorm.DB, knowledgeID int, limit int) ([]KnowledgeSnapshotRestore, error) {
+	var restores []KnowledgeSnapshotRestore
+	err := db.Where("knowledge_id = ?", knowledgeID).Order("id DESC").Limit(limit).Find(&restores).Error
+	return restores, err
+}
+
+// ClaimKnowledgeSnapshotRestores claims queued (and interrupted) restore jobs and marks them running
+func ClaimKnowledgeSnapshotRestores(db *gorm.DB, now time.Time, limit int) ([]KnowledgeSnapshotRestore, error) {
+	var candidates []KnowledgeSnapshotRestore
+	if err := claimableSnapshotJobs(db, now, limit).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	claimed := make([]KnowledgeSnapshotRestore, 0, len(candidates))
+	for _, r := range candidates {
+		ok, err := claimSnapshotJob(db, &KnowledgeSnapshotRestore{}, r.ID, r.Status, r.Attempts, now)
+		if err != nil {
+			return claimed, err
+		}
+		if !ok {
+			continue
+		}
+		r.Status = KnowledgeSnapshotRunning
+		r.Attempts++
+		r.Processed = 0
+		claimed = append(claimed, r)
+	}
+	return claimed, nil
+}
+
+// SetKnowledgeSnapshotRestoreTarget records the knowledge base created for the
+// restore, so a retried job writes into it instead of creating another one
+func SetKnowledgeSnapshotRestoreTarget(db *gorm.DB, r *KnowledgeSnapshotRestore, knowledgeID int, key string) error {
+	r.TargetKnowledgeID = knowledgeID
+	r.TargetKey = key
+	return db.Model(r).Updates(map[string]any{"target_knowledge_id": knowledgeID, "target_key": key}).Error
+}
+
+// UpdateKnowledgeSnapshotRestoreProgress records how many chunks were restored
+func UpdateKnowledgeSnapshotRestoreProgress(db *gorm.DB, r *KnowledgeSnapshotRestore, processed int) error {
+	r.Processed = processed
+	r.Total = max(r.Total, processed)
+	return db.Model(r).Updates(map[string]any{"processed": r.Processed, "total": r.Total, "updated_at": time.Now()}).Error
+}
+
+// CompleteKnowledgeSnapshotRestore marks the restore finished
+func CompleteKnowledgeSnapshotRestore(db *gorm.DB, r *KnowledgeSnapshotRestore, now time.Time) error {
+	r.Status = KnowledgeSnapshotCompleted
+	r.Total = r.Processed
+	r.Progress = 100
+	r.Error = ""
+	r.CompletedAt = &now
+	return db.Model(r).Updates(map[string]any{
+		"status":       r.Status,
+		"total":        r.Total,
+		"error":        "",
+		"completed_at": now,
+	}).Error
+}
+
+// FailKnowledgeSnapshotRestore records the failure and queues the job again until
+// the attempts are used up, returns whether the job failed for good
+func FailKnowledgeSnapshotRestore(db *gorm.DB, r *KnowledgeSnapshotRestore, cause error) (bool, error) {
+	r.Error = truncateRunes(cause.Error(), 512)
+	r.Status = KnowledgeSnapshotPending
+	if r.Attempts >= KnowledgeSnapshotMaxAttempts {
+		r.Status = KnowledgeSnapshotFailed
+	}
+	err := db.Model(r).Updates(map[string]any{"status": r.Status, "error": r.Error}).Error
+	return r.Status == KnowledgeSnapshotFailed, err
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateKnowledgeSnapshot_Versions(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeSnapshot{})
+	k := &Knowledge{ID: 7, KnowledgeKey: "1_faq", Provider: "qdrant"}
+
+	s1, err := CreateKnowledgeSnapshot(db, k, 1, "before re-ingestion")
+	require.NoError(t, err)
+	assert.Equal(t, 1, s1.Version)
+	assert.Equal(t, KnowledgeSnapshotPending, s1.Status)
+
+	_, err = CreateKnowledgeSnapshot(db, k, 1, "")
+	assert.ErrorIs(t, err, ErrKnowledgeSnapshotBusy, "one snapshot per knowledge base at a time")
+
+	now := time.Now()
+	require.NoError(t, CompleteKnowledgeSnapshot(db, s1, "knowledge-snapshots/7/v1.jsonl.gz", "http://store/v1", 128, 42, now))
+	s2, err := CreateKnowledgeSnapshot(db, k, 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, s2.Version)
+
+	other, err := CreateKnowledgeSnapshot(db, &Knowledge{ID: 8, KnowledgeKey: "1_other"}, 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, other.Version, "versions are numbered per knowledge base")
+
+	list, err := ListKnowledgeSnapshots(db, 7, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, 2, list[0].Version)
+	assert.Equal(t, float64(100), list[1].Progress)
+
+	got, err := GetKnowledgeSnapshot(db, 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "http://store/v1", got.StorageURL)
+	assert.Equal(t, 42, got.Total)
+}
+
+func TestClaimKnowledgeSnapshots(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeSnapshot{})
+	s, err := CreateKnowledgeSnapshot(db, &Knowledge{ID: 1, KnowledgeKey: "kb"}, 1, "")
+	require.NoError(t, err)
+
+	now := time.Now()
+	claimed, err := ClaimKnowledgeSnapshots(db, now, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	claimed, err = ClaimKnowledgeSnapshots(db, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed, "running jobs are not claimed twice")
+
+	require.NoError(t, UpdateKnowledgeSnapshotProgress(db, s, 25, 100))
+	got, err := GetKnowledgeSnapshot(db, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, float64(25), got.Progress)
+
+	claimed, err = ClaimKnowledgeSnapshots(db, now.Add(knowledgeSnapshotStale+time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "interrupted jobs are claimed again")
+
+	s = &claimed[0]
+	final, err := FailKnowledgeSnapshot(db, s, errors.New("boom"))
+	require.NoError(t, err)
+	assert.False(t, final)
+	assert.Equal(t, KnowledgeSnapshotPending, s.Status)
+
+	claimed, err = ClaimKnowledgeSnapshots(db, now, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	final, err = FailKnowledgeSnapshot(db, &claimed[0], errors.New("boom"))
+	require.NoError(t, err)
+	assert.True(t, final, "failed after the last attempt")
+}
+
+func TestKnowledgeSnapshotRestore(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeSnapshot{}, &KnowledgeSnapshotRestore{})
+	s, err := CreateKnowledgeSnapshot(db, &Knowledge{ID: 3, KnowledgeKey: "1_faq"}, 1, "")
+	require.NoError(t, err)
+
+	_, err = CreateKnowledgeSnapshotRestore(db, s, 1, "", false)
+	assert.ErrorIs(t, err, ErrKnowledgeSnapshotNotReady)
+
+	require.NoError(t, CompleteKnowledgeSnapshot(db, s, "key", "url", 10, 40, time.Now()))
+	same, err := CreateKnowledgeSnapshotRestore(db, s, 1, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, "1_faq", same.TargetKey)
+	assert.True(t, same.Replace)
+	assert.Equal(t, 40, same.Total)
+
+	fresh, err := CreateKnowledgeSnapshotRestore(db, s, 1, "FAQ copy", true)
+	require.NoError(t, err)
+	assert.Empty(t, fresh.TargetKey, "the new knowledge base is created by the worker")
+	assert.False(t, fresh.Replace, "a new index has nothing to replace")
+
+	claimed, err := ClaimKnowledgeSnapshotRestores(db, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+
+	r := &claimed[1]
+	require.NoError(t, SetKnowledgeSnapshotRestoreTarget(db, r, 9, "1_FAQ copy"))
+	require.NoError(t, UpdateKnowledgeSnapshotRestoreProgress(db, r, 10))
+	got, err := GetKnowledgeSnapshotRestore(db, 3, r.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 9, got.TargetKnowledgeID)
+	assert.Equal(t, float64(25), got.Progress)
+
+	require.NoError(t, CompleteKnowledgeSnapshotRestore(db, r, time.Now()))
+	got, err = GetKnowledgeSnapshotRestore(db, 3, r.ID)
+	require.NoError(t, err)
+	assert.Equal(t, KnowledgeSnapshotCompleted, got.Status)
+	assert.Equal(t, float64(100), got.Progress)
+
+	_, err = GetKnowledgeSnapshotRestore(db, 4, r.ID)
+	assert.Error(t, err, "restores are scoped to their knowledge base")
+
+	list, err := ListKnowledgeSnapshotRestores(db, 3, 10)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"gorm.io/gorm"
+)
+
+const (
+	// KnowledgeFileLinkTTL 引用中源文件链接的有效期
+	KnowledgeFileLinkTTL = time.Hour
+	// knowledgeCitationSnippet 引用摘录的最大字符数
+	knowledgeCitationSnippet = 300
+
+	knowledgeFileLinkPurpose = "knowledge-file"
+)
+
+var ErrInvalidKnowledgeFileLink = errors.New("knowledge file link is invalid or expired")
+
+// KnowledgeSourceFile 上传到知识库的原始文件，用于在回答中展示引用的页面或图片
+type KnowledgeSourceFile struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	KnowledgeID int       `json:"knowledgeId" gorm:"uniqueIndex:idx_knowledge_source_file;not null"`
+	UserID      uint      `json:"userId" gorm:"index"`
+	Filename    string    `json:"filename" gorm:"size:255;uniqueIndex:idx_knowledge_source_file"`
+	ContentType string    `json:"contentType" gorm:"size:128"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256" gorm:"size:64"`
+	Path        string    `json:"-" gorm:"size:512"` // 本地存储路径，不对外暴露
+}
+
+// TableName 指定表名
+func (KnowledgeSourceFile) TableName() string {
+	return "knowledge_source_files"
+}
+
+// SaveKnowledgeSourceFile 保存知识库文件，同名文件重新上传时覆盖，返回被替换的旧路径
+func SaveKnowledgeSourceFile(db *gorm.DB, file *KnowledgeSourceFile) (string, error) {
+	var existing KnowledgeSourceFile
+	err := db.Where("knowledge_id = ? AND filename = ?", file.KnowledgeID, file.Filename).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	oldPath := ""
+	if err == nil {
+		file.ID = existing.ID
+		file.CreatedAt = existing.CreatedAt
+		if existing.Path != file.Path {
+			oldPath = existing.Path
+		}
+	}
+	return oldPath, db.Save(file).Error
+}
+
+// GetKnowledgeSourceFile 按 ID 获取知识库文件
+func GetKnowledgeSourceFile(db *gorm.DB, id uint) (*KnowledgeSourceFile, error) {
+	var file KnowledgeSourceFile
+	if err := db.First(&file, id).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ListKnowledgeSourceFiles 获取知识库的所有源文件
+func ListKnowledgeSourceFiles(db *gorm.DB, knowledgeID int) ([]KnowledgeSourceFile, error) {
+	files := []KnowledgeSourceFile{}
+	err := db.Where("knowledge_id = ?", knowledgeID).Order("filename").Find(&files).Error
+	return files, err
+}
+
+// DeleteKnowledgeSourceFiles 删除知识库的源文件记录，返回需要删除的本地路径
+func DeleteKnowledgeSourceFiles(db *gorm.DB, knowledgeID int) ([]string, error) {
+	var paths []string
+	if err := db.Model(&KnowledgeSourceFile{}).Where("knowledge_id = ?", knowledgeID).Pluck("path", &paths).Error; err != nil {
+		return nil, err
+	}
+	return paths, db.Where("knowledge_id = ?", knowledgeID).Delete(&KnowledgeSourceFile{}).Error
+}
+
+// knowledgeFileLinkClaims 源文件链接中签名的内容
+type knowledgeFileLinkClaims struct {
+	FileID    uint  `json:"f"`
+	ExpiresAt int64 `json:"e"`
+}
+
+// SignKnowledgeFileLink 生成源文件的免登录访问令牌，聊天中的引用通过它打开原文件
+func SignKnowledgeFileLink(secret string, fileID uint, expiresAt time.Time) (string, error) {
+	return signLink(secret, knowledgeFileLinkPurpose, knowledgeFileLinkClaims{FileID: fileID, ExpiresAt: expiresAt.Unix()})
+}
+
+// VerifyKnowledgeFileLink 校验令牌是否授权访问该文件
+func VerifyKnowledgeFileLink(secret, token string, fileID uint, now time.Time) error {
+	var claims knowledgeFileLinkClaims
+	if err := verifyLink(secret, knowledgeFileLinkPurpose, token, &claims); err != nil {
+		return ErrInvalidKnowledgeFileLink
+	}
+	if claims.FileID != fileID || now.Unix() > claims.ExpiresAt {
+		return ErrInvalidKnowledgeFileLink
+	}
+	return nil
+}
+
+// KnowledgeCitation 回答引用的知识库片段及其源文件位置
+type KnowledgeCitation struct {
+	Index       int     `json:"index"` // 从 1 开始，与检索结果顺序一致
+	Source      string  `json:"source,omitempty"`
+	Page        int     `json:"page,omitempty"`
+	Snippet     string  `json:"snippet"`
+	Score       float64 `json:"score"`
+	FileID      uint    `json:"fileId,omitempty"`
+	ContentType string  `json:"contentType,omitempty"`
+	URL         string  `json:"url,omitempty"` // 带签名的源文件地址，PDF 附带 #page=
+}
+
+// BuildKnowledgeCitations 把检索结果映射到知识库中已保存的源文件，
+// 只匹配同一知识库内的文件，其他知识库的同名文件不会被引用
+func BuildKnowledgeCitations(db *gorm.DB, knowledgeKey string, results []knowledge.SearchResult) ([]KnowledgeCitation, error) {
+	citations := make([]KnowledgeCitation, 0, len(results))
+	if len(results) == 0 {
+		return citations, nil
+	}
+	k, err := GetKnowledge(db, knowledgeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		if name := r.SourceFile(); name != "" {
+			names = append(names, name)
+		}
+	}
+	files := map[string]KnowledgeSourceFile{}
+	if len(names) > 0 {
+		var list []KnowledgeSourceFile
+		if err := db.Where("knowledge_id = ? AND filename IN ?", k.ID, names).Find(&list).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range list {
+			files[f.Filename] = f
+		}
+	}
+
+	for i, r := range results {
+		citation := KnowledgeCitation{
+			Index:   i + 1,
+			Source:  r.SourceFile(),
+			Page:    r.Page(),
+			Snippet: truncateRunes(r.Content, knowledgeCitationSnippet),
+			Score:   r.Score,
+		}
+		if f, ok := files[citation.Source]; ok {
+			citation.FileID = f.ID
+			citation.ContentType = f.ContentType
+		}
+		citations = append(citations, citation)
+	}
+	return citations, nil
+}
+
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max]) + "…"
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveKnowledgeSourceFile_ReplacesSameName(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeSourceFile{})
+
+	first := &KnowledgeSourceFile{KnowledgeID: 1, Filename: "faq.pdf", Path: "/data/1/aaa.pdf"}
+	oldPath, err := SaveKnowledgeSourceFile(db, first)
+	require.NoError(t, err)
+	assert.Empty(t, oldPath)
+
+	second := &KnowledgeSourceFile{KnowledgeID: 1, Filename: "faq.pdf", Path: "/data/1/bbb.pdf"}
+	oldPath, err = SaveKnowledgeSourceFile(db, second)
+	require.NoError(t, err)
+	assert.Equal(t, "/data/1/aaa.pdf", oldPath)
+	assert.Equal(t, first.ID, second.ID)
+
+	_, err = SaveKnowledgeSourceFile(db, &KnowledgeSourceFile{KnowledgeID: 2, Filename: "faq.pdf", Path: "/data/2/aaa.pdf"})
+	require.NoError(t, err)
+
+	files, err := ListKnowledgeSourceFiles(db, 1)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/data/1/bbb.pdf", files[0].Path)
+
+	paths, err := DeleteKnowledgeSourceFiles(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data/1/bbb.pdf"}, paths)
+	files, err = ListKnowledgeSourceFiles(db, 2)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestKnowledgeFileLink(t *testing.T) {
+	now := time.Now()
+	token, err := SignKnowledgeFileLink("secret", 7, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	assert.NoError(t, VerifyKnowledgeFileLink("secret", token, 7, now))
+	assert.ErrorIs(t, VerifyKnowledgeFileLink("secret", token, 8, now), ErrInvalidKnowledgeFileLink)
+	assert.ErrorIs(t, VerifyKnowledgeFileLink("other", token, 7, now), ErrInvalidKnowledgeFileLink)
+	assert.ErrorIs(t, VerifyKnowledgeFileLink("secret", token, 7, now.Add(2*time.Hour)), ErrInvalidKnowledgeFileLink)
+
+	// 其他用途的令牌不能用来访问文件
+	annotationToken, err := SignAnnotationLink("secret", &SessionAnnotation{ID: 7}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.ErrorIs(t, VerifyKnowledgeFileLink("secret", annotationToken, 7, now), ErrInvalidKnowledgeFileLink)
+}
+
+func TestBuildKnowledgeCitations(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Knowledge{}, &KnowledgeSourceFile{})
+	require.NoError(t, db.Create(&Knowledge{ID: 1, UserID: 1, KnowledgeKey: "kb-1"}).Error)
+	require.NoError(t, db.Create(&Knowledge{ID: 2, UserID: 2, KnowledgeKey: "kb-2"}).Error)
+	_, err := SaveKnowledgeSourceFile(db, &KnowledgeSourceFile{KnowledgeID: 1, Filename: "manual.pdf", ContentType: "application/pdf", Path: "/data/1/m.pdf"})
+	require.NoError(t, err)
+	other := &KnowledgeSourceFile{KnowledgeID: 2, Filename: "secret.pdf", ContentType: "application/pdf", Path: "/data/2/s.pdf"}
+	_, err = SaveKnowledgeSourceFile(db, other)
+	require.NoError(t, err)
+
+	citations, err := BuildKnowledgeCitations(db, "kb-1", []knowledge.SearchResult{
+		{Content: "Reset the device", Score: 0.9, Metadata: map[string]interface{}{"doc_name": "manual.pdf", "page_number": float64(4)}},
+		{Content: "leaked", Score: 0.5, Source: "secret.pdf"},
+	})
+	require.NoError(t, err)
+	require.Len(t, citations, 2)
+	assert.Equal(t, 1, citations[0].Index)
+	assert.Equal(t, "manual.pdf", citations[0].Source)
+	assert.Equal(t, 4, citations[0].Page)
+	assert.NotZero(t, citations[0].FileID)
+	assert.Equal(t, "application/pdf", citations[0].ContentType)
+	// 其他知识库的同名文件不会被关联
+	assert.Equal(t, "secret.pdf", citations[1].Source)
+	assert.Zero(t, citations[1].FileID)
+
+	citations, err = BuildKnowledgeCitations(db, "kb-1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, citations)
+}
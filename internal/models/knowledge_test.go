@@ -313,3 +313,22 @@ func TestSearchKnowledgeBase_ValidConfig(t *testing.T) {
 	// Should fail at provider creation or search stage, not at config parsing
 	assert.NotContains(t, err.Error(), "failed to parse config")
 }
+
+func TestKnowledgeVersion(t *testing.T) {
+	db := setupKnowledgeTestDB(t)
+
+	user, err := CreateUser(db, "version@example.com", "password123")
+	require.NoError(t, err)
+	_, err = CreateKnowledge(db, int(user.ID), "kb-version", "Versioned", "aliyun", nil, nil)
+	require.NoError(t, err)
+
+	assert.Zero(t, KnowledgeVersion(db, ""))
+	assert.Zero(t, KnowledgeVersion(db, "missing"))
+
+	before := KnowledgeVersion(db, "kb-version")
+	assert.NotZero(t, before)
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, TouchKnowledge(db, "kb-version"))
+	assert.Greater(t, KnowledgeVersion(db, "kb-version"), before)
+	assert.NotEqual(t, LLMCacheScope(db, 1, "kb-version"), LLMCacheScope(db, 1, ""))
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	KnowledgeEventIngestionSucceeded = "knowledge.ingestion.succeeded"
+	KnowledgeEventIngestionFailed    = "knowledge.ingestion.failed"
+)
+
+// KnowledgeWebhook per knowledge base webhook fired when document ingestion finishes
+type KnowledgeWebhook struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	KnowledgeID int       `json:"knowledgeId" gorm:"uniqueIndex;not null"` // Knowledge base ID
+	UserID      uint      `json:"userId" gorm:"index;not null"`            // Owner user ID
+	URL         string    `json:"url" gorm:"size:500;not null"`            // Delivery URL
+	Method      string    `json:"method" gorm:"size:10;default:'POST'"`    // HTTP method
+	Secret      string    `json:"-" gorm:"size:128"`                       // HMAC signing secret, never returned
+	OnSuccess   bool      `json:"onSuccess"`                               // Fire on ingestion success
+	OnFailure   bool      `json:"onFailure"`                               // Fire on ingestion failure
+	Enabled     bool      `json:"enabled"`
+
+	// Last delivery outcome
+	LastEvent       string     `json:"lastEvent,omitempty" gorm:"size:64"`
+	LastStatusCode  int        `json:"lastStatusCode,omitempty"`
+	LastError       string     `json:"lastError,omitempty" gorm:"type:text"`
+	LastDeliveredAt *time.Time `json:"lastDeliveredAt,omitempty"`
+}
+
+// TableName 指定表名
+func (KnowledgeWebhook) TableName() string {
+	return "knowledge_webhooks"
+}
+
+// HasSecret reports whether deliveries are signed
+func (w *KnowledgeWebhook) HasSecret() bool {
+	return w.Secret != ""
+}
+
+// ShouldFire reports whether the webhook subscribes to the given event
+func (w *KnowledgeWebhook) ShouldFire(event string) bool {
+	if !w.Enabled || w.URL == "" {
+		return false
+	}
+	switch event {
+	case KnowledgeEventIngestionSucceeded:
+		return w.OnSuccess
+	case KnowledgeEventIngestionFailed:
+		return w.OnFailure
+	}
+	return false
+}
+
+// GetKnowledgeWebhook gets the webhook configured for a knowledge base, nil if none
+func GetKnowledgeWebhook(db *gorm.DB, knowledgeID int) (*KnowledgeWebhook, error) {
+	var hook KnowledgeWebhook
+	err := db.Where("knowledge_id = ?", knowledgeID).First(&hook).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// SaveKnowledgeWebhook creates or replaces the webhook of a knowledge base
+func SaveKnowledgeWebhook(db *gorm.DB, hook *KnowledgeWebhook) error {
+	existing, err := GetKnowledgeWebhook(db, hook.KnowledgeID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		hook.ID = existing.ID
+		hook.CreatedAt = existing.CreatedAt
+	}
+	// Save writes zero values too, so disabling events is persisted
+	return db.Save(hook).Error
+}
+
+// DeleteKnowledgeWebhook removes the webhook of a knowledge base
+func DeleteKnowledgeWebhook(db *gorm.DB, knowledgeID int) error {
+	return db.Where("knowledge_id = ?", knowledgeID).Delete(&KnowledgeWebhook{}).Error
+}
+
+// RecordKnowledgeWebhookDelivery stores the outcome of the latest delivery
+func RecordKnowledgeWebhookDelivery(db *gorm.DB, id uint, event string, statusCode int, deliveryErr error) error {
+	now := time.Now()
+	lastError := ""
+	if deliveryErr != nil {
+		lastError = deliveryErr.Error()
+	}
+	return db.Model(&KnowledgeWebhook{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_event":        event,
+			"last_status_code":  statusCode,
+			"last_error":        lastError,
+			"last_delivered_at": now,
+		}).Error
+}
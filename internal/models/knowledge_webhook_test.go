@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnowledgeWebhook_SaveAndGet(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeWebhook{})
+
+	hook, err := GetKnowledgeWebhook(db, 1)
+	require.NoError(t, err)
+	assert.Nil(t, hook)
+
+	err = SaveKnowledgeWebhook(db, &KnowledgeWebhook{
+		KnowledgeID: 1,
+		UserID:      7,
+		URL:         "https://example.com/hook",
+		Secret:      "secret",
+		OnSuccess:   true,
+		OnFailure:   true,
+		Enabled:     true,
+	})
+	require.NoError(t, err)
+
+	hook, err = GetKnowledgeWebhook(db, 1)
+	require.NoError(t, err)
+	require.NotNil(t, hook)
+	assert.Equal(t, "https://example.com/hook", hook.URL)
+	assert.True(t, hook.HasSecret())
+
+	// Saving again replaces the existing row and persists false flags
+	err = SaveKnowledgeWebhook(db, &KnowledgeWebhook{
+		KnowledgeID: 1,
+		UserID:      7,
+		URL:         "https://example.com/other",
+		OnSuccess:   false,
+		OnFailure:   true,
+		Enabled:     true,
+	})
+	require.NoError(t, err)
+
+	var count int64
+	db.Model(&KnowledgeWebhook{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	hook, err = GetKnowledgeWebhook(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/other", hook.URL)
+	assert.False(t, hook.OnSuccess)
+
+	require.NoError(t, DeleteKnowledgeWebhook(db, 1))
+	hook, err = GetKnowledgeWebhook(db, 1)
+	require.NoError(t, err)
+	assert.Nil(t, hook)
+}
+
+func TestKnowledgeWebhook_ShouldFire(t *testing.T) {
+	hook := &KnowledgeWebhook{URL: "https://example.com", Enabled: true, OnSuccess: true}
+	assert.True(t, hook.ShouldFire(KnowledgeEventIngestionSucceeded))
+	assert.False(t, hook.ShouldFire(KnowledgeEventIngestionFailed))
+	assert.False(t, hook.ShouldFire("unknown"))
+
+	hook.Enabled = false
+	assert.False(t, hook.ShouldFire(KnowledgeEventIngestionSucceeded))
+}
+
+func TestRecordKnowledgeWebhookDelivery(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &KnowledgeWebhook{})
+
+	hook := &KnowledgeWebhook{KnowledgeID: 2, UserID: 1, URL: "https://example.com", Enabled: true}
+	require.NoError(t, SaveKnowledgeWebhook(db, hook))
+
+	require.NoError(t, RecordKnowledgeWebhookDelivery(db, hook.ID, KnowledgeEventIngestionFailed, 500, errors.New("boom")))
+
+	got, err := GetKnowledgeWebhook(db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, KnowledgeEventIngestionFailed, got.LastEvent)
+	assert.Equal(t, 500, got.LastStatusCode)
+	assert.Equal(t, "boom", got.LastError)
+	assert.NotNil(t, got.LastDeliveredAt)
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/semcache"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// 语义缓存默认参数：相似度阈值（百分比）、新鲜期（秒）
+const (
+	defaultLLMCacheThreshold = 92
+	defaultLLMCacheTTL       = 24 * 60 * 60
+)
+
+// LLMCache 按系统配置刷新进程内的语义缓存参数，未启用时返回 nil
+func LLMCache(db *gorm.DB) *semcache.Cache {
+	if !utils.GetBoolValue(db, constants.KEY_LLM_CACHE_ENABLED) {
+		return nil
+	}
+	cache := semcache.Default()
+	cache.Configure(semcache.Config{
+		Threshold: float64(utils.GetIntValue(db, constants.KEY_LLM_CACHE_THRESHOLD, defaultLLMCacheThreshold)) / 100,
+		TTL:       time.Duration(utils.GetIntValue(db, constants.KEY_LLM_CACHE_TTL, defaultLLMCacheTTL)) * time.Second,
+	})
+	return cache
+}
+
+// LLMCacheScope 助手回答的缓存作用域，知识库内容更新后进入新的作用域，旧回答不再命中
+func LLMCacheScope(db *gorm.DB, assistantID uint, knowledgeKey string) string {
+	return semcache.Scope(assistantID, KnowledgeVersion(db, knowledgeKey))
+}
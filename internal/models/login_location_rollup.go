@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 登录地点热力图的聚合粒度
+const (
+	LoginLocationByCountry = "country"
+	LoginLocationByCity    = "city"
+)
+
+const loginLocationDayLayout = "2006-01-02"
+
+// LoginLocationRollup 按天、用户、国家和城市汇总的登录次数，由定时任务从 login_histories 生成，
+// 安全看板的地图直接查询本表，避免扫描登录历史
+type LoginLocationRollup struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	Day        string    `json:"day" gorm:"size:10;uniqueIndex:idx_login_location_rollup"` // 2006-01-02，服务器时区
+	UserID     uint      `json:"userId" gorm:"uniqueIndex:idx_login_location_rollup;index"`
+	Country    string    `json:"country" gorm:"size:64;uniqueIndex:idx_login_location_rollup"`
+	City       string    `json:"city" gorm:"size:128;uniqueIndex:idx_login_location_rollup"`
+	Logins     int64     `json:"logins"`     // 成功登录次数
+	Failed     int64     `json:"failed"`     // 失败登录次数
+	Suspicious int64     `json:"suspicious"` // 被判定为异地等可疑登录的次数
+}
+
+// TableName 指定表名
+func (LoginLocationRollup) TableName() string {
+	return "login_location_rollups"
+}
+
+// LoginLocationBucket 热力图中的一个格子
+type LoginLocationBucket struct {
+	Day        string `json:"day"`
+	Country    string `json:"country"`
+	City       string `json:"city,omitempty"`
+	Logins     int64  `json:"logins"`
+	Failed     int64  `json:"failed"`
+	Suspicious int64  `json:"suspicious"`
+}
+
+// RollupLoginLocations 重新汇总某一天的登录历史，可重复执行，已有汇总会被替换
+func RollupLoginLocations(db *gorm.DB, day time.Time) (int, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	key := start.Format(loginLocationDayLayout)
+
+	var rows []LoginLocationRollup
+	err := db.Model(&LoginHistory{}).
+		Select(`user_id, country, city,
+			SUM(CASE WHEN success THEN 1 ELSE 0 END) AS logins,
+			SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failed,
+			SUM(CASE WHEN is_suspicious THEN 1 ELSE 0 END) AS suspicious`).
+		Where("created_at >= ? AND created_at < ? AND user_id > 0", start, start.AddDate(0, 0, 1)).
+		Group("user_id, country, city").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", key).Delete(&LoginLocationRollup{}).Error; err != nil {
+			return err
+		}
+		for i := range rows {
+			rows[i].Day = key
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 200).Error
+	})
+	return len(rows), err
+}
+
+// GetLoginLocationHeatmap 按天和国家（或城市）汇总用户在 [from, to] 内的登录，userIDs 为组织成员或单个用户
+func GetLoginLocationHeatmap(db *gorm.DB, userIDs []uint, from, to time.Time, granularity string) ([]LoginLocationBucket, error) {
+	buckets := []LoginLocationBucket{}
+	if len(userIDs) == 0 {
+		return buckets, nil
+	}
+	columns := "day, country"
+	if granularity == LoginLocationByCity {
+		columns = "day, country, city"
+	}
+	err := db.Model(&LoginLocationRollup{}).
+		Select(columns+", SUM(logins) AS logins, SUM(failed) AS failed, SUM(suspicious) AS suspicious").
+		Where("user_id IN ? AND day >= ? AND day <= ?", userIDs, from.Format(loginLocationDayLayout), to.Format(loginLocationDayLayout)).
+		Group(columns).
+		Order(columns).
+		Scan(&buckets).Error
+	return buckets, err
+}
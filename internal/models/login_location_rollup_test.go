@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupLoginLocations(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &LoginHistory{}, &LoginLocationRollup{})
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	login := func(userID uint, country, city string, at time.Time, success, suspicious bool) {
+		history := LoginHistory{UserID: userID, Country: country, City: city, Success: success, IsSuspicious: suspicious}
+		history.CreatedAt = at
+		require.NoError(t, db.Create(&history).Error)
+	}
+	login(1, "CN", "Shanghai", day, true, false)
+	login(1, "CN", "Shanghai", day.Add(time.Hour), true, false)
+	login(1, "CN", "Shanghai", day.Add(2*time.Hour), false, false)
+	login(1, "US", "Seattle", day.Add(3*time.Hour), true, true)
+	login(2, "CN", "Beijing", day, true, false)
+	login(2, "CN", "Beijing", day.AddDate(0, 0, 1), true, false)
+
+	rows, err := RollupLoginLocations(db, day)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rows)
+
+	// 重复汇总不会重复计数
+	_, err = RollupLoginLocations(db, day)
+	require.NoError(t, err)
+	_, err = RollupLoginLocations(db, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+
+	buckets, err := GetLoginLocationHeatmap(db, []uint{1}, day, day, LoginLocationByCity)
+	require.NoError(t, err)
+	assert.Equal(t, []LoginLocationBucket{
+		{Day: "2026-03-10", Country: "CN", City: "Shanghai", Logins: 2, Failed: 1},
+		{Day: "2026-03-10", Country: "US", City: "Seattle", Logins: 1, Suspicious: 1},
+	}, buckets)
+
+	buckets, err = GetLoginLocationHeatmap(db, []uint{1, 2}, day, day.AddDate(0, 0, 1), LoginLocationByCountry)
+	require.NoError(t, err)
+	assert.Equal(t, []LoginLocationBucket{
+		{Day: "2026-03-10", Country: "CN", Logins: 3, Failed: 1},
+		{Day: "2026-03-10", Country: "US", Logins: 1, Suspicious: 1},
+		{Day: "2026-03-11", Country: "CN", Logins: 1},
+	}, buckets)
+
+	buckets, err = GetLoginLocationHeatmap(db, nil, day, day, LoginLocationByCity)
+	require.NoError(t, err)
+	assert.Empty(t, buckets)
+}
+
+func TestGroupMemberIDs(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &GroupMember{})
+	group := &Group{ID: 1, CreatorID: 9}
+	require.NoError(t, db.Create(&GroupMember{GroupID: 1, UserID: 2}).Error)
+	require.NoError(t, db.Create(&GroupMember{GroupID: 2, UserID: 3}).Error)
+
+	ids, err := GroupMemberIDs(db, group)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{2, 9}, ids)
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// 设备操作类型
+const (
+	DeviceActionFirmware = "firmware" // 固件升级，始终视为破坏性操作
+	DeviceActionConfig   = "config"   // 配置下发
+	DeviceActionCommand  = "command"  // 设备指令
+)
+
+// 设备操作状态
+const (
+	DeviceActionQueued    = "queued"
+	DeviceActionExecuted  = "executed"
+	DeviceActionCancelled = "cancelled"
+)
+
+const maxMaintenanceWindowMinutes = 24 * 60
+
+var ErrDeviceActionNotQueued = errors.New("device action is not queued")
+
+// MaintenanceWindow 设备群（组织下的设备）的维护窗口。配置了启用的窗口后，
+// 固件升级和标记为破坏性的配置下发、设备指令只在窗口内执行，窗口外排队，
+// 窗口打开时自动执行。未配置窗口的设备群不受限制。
+type MaintenanceWindow struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID         uint      `json:"groupId" gorm:"index"`
+	Name            string    `json:"name" gorm:"size:128"`
+	Schedule        string    `json:"schedule" gorm:"size:128"`         // 窗口开始时间，5 段 cron 表达式，如 "0 2 * * 1-5"
+	Timezone        string    `json:"timezone" gorm:"size:64"`          // IANA 时区，空为 UTC
+	DurationMinutes int       `json:"durationMinutes"`                  // 窗口时长
+	Enabled         bool      `json:"enabled"`                          // 停用的窗口不参与判断
+	CreatedBy       uint      `json:"createdBy,omitempty" gorm:"index"` // 创建者
+}
+
+func (MaintenanceWindow) TableName() string {
+	return "maintenance_windows"
+}
+
+// Validate 校验计划、时区和时长
+func (w *MaintenanceWindow) Validate() error {
+	w.Name = strings.TrimSpace(w.Name)
+	if w.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, err := cron.ParseStandard(w.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	if w.DurationMinutes <= 0 || w.DurationMinutes > maxMaintenanceWindowMinutes {
+		return fmt.Errorf("durationMinutes must be between 1 and %d", maxMaintenanceWindowMinutes)
+	}
+	return nil
+}
+
+// OpenAt 判断 t 时刻窗口是否打开，打开时返回关闭时间，否则返回下次打开时间
+func (w *MaintenanceWindow) OpenAt(t time.Time) (open bool, at time.Time) {
+	schedule, err := cron.ParseStandard(w.Schedule)
+	if err != nil {
+		return false, time.Time{}
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	local := t.In(loc)
+
+	// 在 (t-duration, t] 内开始的窗口此刻仍打开
+	start := schedule.Next(local.Add(-duration))
+	if !start.After(local) {
+		return true, start.Add(duration)
+	}
+	return false, start
+}
+
+// GetMaintenanceWindows 组织的维护窗口
+func GetMaintenanceWindows(db *gorm.DB, groupID uint) ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+	err := db.Where("group_id = ?", groupID).Order("id").Find(&windows).Error
+	return windows, err
+}
+
+// FleetMaintenanceOpen 判断设备群此刻是否允许执行破坏性操作：没有启用的窗口时始终允许，
+// 否则需有窗口打开。不允许时返回最早的打开时间
+func FleetMaintenanceOpen(db *gorm.DB, groupID *uint, now time.Time) (bool, time.Time, error) {
+	if groupID == nil {
+		return true, time.Time{}, nil
+	}
+	windows, err := GetMaintenanceWindows(db, *groupID)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	gated := false
+	var next time.Time
+	for i := range windows {
+		if !windows[i].Enabled {
+			continue
+		}
+		gated = true
+		open, at := windows[i].OpenAt(now)
+		if open {
+			return true, time.Time{}, nil
+		}
+		if !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	return !gated, next, nil
+}
+
+// DeviceAction 下发给设备的操作，破坏性操作在维护窗口外排队
+type DeviceAction struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID    uint       `json:"groupId" gorm:"index"`
+	DeviceID   string     `json:"deviceId" gorm:"size:64;index"`
+	Kind       string     `json:"kind" gorm:"size:16"`
+	Disruptive bool       `json:"disruptive"`
+	Payload    string     `json:"payload,omitempty" gorm:"type:text"` // 操作内容（通常为 JSON），固件升级时为目标版本
+	Status     string     `json:"status" gorm:"size:16;index"`
+	CreatedBy  uint       `json:"createdBy,omitempty"` // 0 表示系统创建（如 OTA 检查时暂缓的升级）
+	ExecutedAt *time.Time `json:"executedAt,omitempty"`
+}
+
+func (DeviceAction) TableName() string {
+	return "device_actions"
+}
+
+// ValidDeviceActionKind 是否为已知的操作类型
+func ValidDeviceActionKind(kind string) bool {
+	switch kind {
+	case DeviceActionFirmware, DeviceActionConfig, DeviceActionCommand:
+		return true
+	}
+	return false
+}
+
+// SubmitDeviceAction 提交设备操作：非破坏性操作或窗口打开时立即执行，否则排队
+func SubmitDeviceAction(db *gorm.DB, action *DeviceAction, now time.Time) error {
+	if action.Kind == DeviceActionFirmware {
+		action.Disruptive = true
+	}
+	action.Status = DeviceActionQueued
+	open := true
+	if action.Disruptive {
+		var err error
+		if open, _, err = FleetMaintenanceOpen(db, &action.GroupID, now); err != nil {
+			return err
+		}
+	}
+	if err := db.Create(action).Error; err != nil {
+		return err
+	}
+	if open {
+		return ExecuteDeviceAction(db, action, now)
+	}
+	return nil
+}
+
+// ExecuteDeviceAction 标记操作已执行并通知下发方
+func ExecuteDeviceAction(db *gorm.DB, action *DeviceAction, now time.Time) error {
+	res := db.Model(&DeviceAction{}).
+		Where("id = ? AND status = ?", action.ID, DeviceActionQueued).
+		Updates(map[string]any{"status": DeviceActionExecuted, "executed_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeviceActionNotQueued
+	}
+	action.Status = DeviceActionExecuted
+	action.ExecutedAt = &now
+	utils.Sig().Emit(constants.SigDeviceActionExecute, action, db)
+	return nil
+}
+
+// CancelDeviceAction 取消排队中的操作
+func CancelDeviceAction(db *gorm.DB, groupID, actionID uint) error {
+	res := db.Model(&DeviceAction{}).
+		Where("id = ? AND group_id = ? AND status = ?", actionID, groupID, DeviceActionQueued).
+		Update("status", DeviceActionCancelled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeviceActionNotQueued
+	}
+	return nil
+}
+
+// DeferFirmwareUpdate 记录窗口外暂缓的固件升级，每台设备只保留一条排队记录
+func DeferFirmwareUpdate(db *gorm.DB, device *Device, version string) error {
+	if device.GroupID == nil {
+		return nil
+	}
+	var action DeviceAction
+	err := db.Where("device_id = ? AND kind = ? AND status = ?", device.ID, DeviceActionFirmware, DeviceActionQueued).
+		First(&action).Error
+	if err == nil {
+		if action.Payload == version {
+			return nil
+		}
+		return db.Model(&action).Update("payload", version).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return db.Create(&DeviceAction{
+		GroupID:    *device.GroupID,
+		DeviceID:   device.ID,
+		Kind:       DeviceActionFirmware,
+		Disruptive: true,
+		Payload:    version,
+		Status:     DeviceActionQueued,
+	}).Error
+}
+
+// DispatchDueDeviceActions 执行维护窗口已打开的设备群中排队的操作，返回执行数量
+func DispatchDueDeviceActions(db *gorm.DB, now time.Time) (int, error) {
+	var groupIDs []uint
+	if err := db.Model(&DeviceAction{}).Where("status = ?", DeviceActionQueued).
+		Distinct("group_id").Pluck("group_id", &groupIDs).Error; err != nil {
+		return 0, err
+	}
+	executed := 0
+	for _, groupID := range groupIDs {
+		open, _, err := FleetMaintenanceOpen(db, &groupID, now)
+		if err != nil {
+			return executed, err
+		}
+		if !open {
+			continue
+		}
+		var actions []DeviceAction
+		if err := db.Where("group_id = ? AND status = ?", groupID, DeviceActionQueued).
+			Order("id").Find(&actions).Error; err != nil {
+			return executed, err
+		}
+		for i := range actions {
+			if err := ExecuteDeviceAction(db, &actions[i], now); err == nil {
+				executed++
+			}
+		}
+	}
+	return executed, nil
+}
+
+// MaintenanceWindowPreview 窗口状态及下次打开时将执行的排队操作
+type MaintenanceWindowPreview struct {
+	MaintenanceWindow
+	Open           bool           `json:"open"`
+	ClosesAt       *time.Time     `json:"closesAt,omitempty"`
+	NextOpenAt     *time.Time     `json:"nextOpenAt,omitempty"`
+	PendingActions []DeviceAction `json:"pendingActions"`
+}
+
+// PreviewMaintenanceWindows 组织的窗口及待执行操作，排队的操作归入最先打开（或正打开）的窗口
+func PreviewMaintenanceWindows(db *gorm.DB, groupID uint, now time.Time) ([]MaintenanceWindowPreview, error) {
+	windows, err := GetMaintenanceWindows(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	var pending []DeviceAction
+	if err := db.Where("group_id = ? AND status = ?", groupID, DeviceActionQueued).
+		Order("id").Find(&pending).Error; err != nil {
+		return nil, err
+	}
+
+	previews := make([]MaintenanceWindowPreview, len(windows))
+	var order []int
+	for i := range windows {
+		p := MaintenanceWindowPreview{MaintenanceWindow: windows[i], PendingActions: []DeviceAction{}}
+		if windows[i].Enabled {
+			open, at := windows[i].OpenAt(now)
+			p.Open = open
+			if open {
+				p.ClosesAt = &at
+			} else if !at.IsZero() {
+				p.NextOpenAt = &at
+			}
+			order = append(order, i)
+		}
+		previews[i] = p
+	}
+
+	// 打开的窗口优先，其次按下次打开时间
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := previews[order[a]], previews[order[b]]
+		if pa.Open != pb.Open {
+			return pa.Open
+		}
+		if pa.NextOpenAt == nil || pb.NextOpenAt == nil {
+			return pb.NextOpenAt == nil && pa.NextOpenAt != nil
+		}
+		return pa.NextOpenAt.Before(*pb.NextOpenAt)
+	})
+	if len(order) > 0 {
+		previews[order[0]].PendingActions = pending
+	}
+	return previews, nil
+}
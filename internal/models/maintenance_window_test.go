@@ -0,0 +1,102 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindowOpenAt(t *testing.T) {
+	// 02:00-04:00 Shanghai time on weekdays, i.e. 18:00-20:00 UTC the day before
+	w := MaintenanceWindow{Name: "nightly", Schedule: "0 2 * * 1-5", Timezone: "Asia/Shanghai", DurationMinutes: 120, Enabled: true}
+	require.NoError(t, w.Validate())
+
+	// Tuesday 2025-01-07 03:00 in Shanghai
+	inside := time.Date(2025, 1, 6, 19, 0, 0, 0, time.UTC)
+	open, closes := w.OpenAt(inside)
+	assert.True(t, open)
+	assert.Equal(t, time.Date(2025, 1, 6, 20, 0, 0, 0, time.UTC), closes.UTC())
+
+	open, next := w.OpenAt(time.Date(2025, 1, 6, 20, 0, 0, 0, time.UTC))
+	assert.False(t, open)
+	assert.Equal(t, time.Date(2025, 1, 7, 18, 0, 0, 0, time.UTC), next.UTC())
+
+	// Saturday night is skipped, the next window is Monday 02:00
+	_, next = w.OpenAt(time.Date(2025, 1, 10, 21, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 1, 12, 18, 0, 0, 0, time.UTC), next.UTC())
+
+	assert.Error(t, (&MaintenanceWindow{Name: "x", Schedule: "bad", DurationMinutes: 10}).Validate())
+	assert.Error(t, (&MaintenanceWindow{Name: "x", Schedule: "0 2 * * *", Timezone: "Mars/Base", DurationMinutes: 10}).Validate())
+	assert.Error(t, (&MaintenanceWindow{Name: "x", Schedule: "0 2 * * *", DurationMinutes: 0}).Validate())
+}
+
+func TestDeviceActionsGatedByMaintenanceWindow(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &MaintenanceWindow{}, &DeviceAction{}, &Device{})
+	groupID := uint(3)
+
+	var executed []uint
+	utils.Sig().Connect(constants.SigDeviceActionExecute, func(sender any, params ...any) {
+		executed = append(executed, sender.(*DeviceAction).ID)
+	})
+
+	closed := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+	opened := time.Date(2025, 1, 6, 2, 30, 0, 0, time.UTC)
+
+	// Without windows nothing is gated
+	free := DeviceAction{GroupID: groupID, DeviceID: "aa", Kind: DeviceActionCommand, Disruptive: true}
+	require.NoError(t, SubmitDeviceAction(db, &free, closed))
+	assert.Equal(t, DeviceActionExecuted, free.Status)
+
+	require.NoError(t, db.Create(&MaintenanceWindow{GroupID: groupID, Name: "nightly", Schedule: "0 2 * * *", DurationMinutes: 60, Enabled: true}).Error)
+
+	open, next, err := FleetMaintenanceOpen(db, &groupID, closed)
+	require.NoError(t, err)
+	assert.False(t, open)
+	assert.Equal(t, time.Date(2025, 1, 7, 2, 0, 0, 0, time.UTC), next)
+
+	config := DeviceAction{GroupID: groupID, DeviceID: "aa", Kind: DeviceActionConfig}
+	require.NoError(t, SubmitDeviceAction(db, &config, closed))
+	assert.Equal(t, DeviceActionExecuted, config.Status, "non-disruptive actions are not gated")
+
+	firmware := DeviceAction{GroupID: groupID, DeviceID: "aa", Kind: DeviceActionFirmware, Payload: "2.0.0"}
+	require.NoError(t, SubmitDeviceAction(db, &firmware, closed))
+	assert.Equal(t, DeviceActionQueued, firmware.Status)
+	assert.True(t, firmware.Disruptive)
+
+	// The OTA check defers its update into a single queued action per device
+	device := Device{ID: "bb", MacAddress: "bb", GroupID: &groupID}
+	require.NoError(t, DeferFirmwareUpdate(db, &device, "2.0.0"))
+	require.NoError(t, DeferFirmwareUpdate(db, &device, "2.0.1"))
+	var deferred []DeviceAction
+	require.NoError(t, db.Where("device_id = ?", "bb").Find(&deferred).Error)
+	require.Len(t, deferred, 1)
+	assert.Equal(t, "2.0.1", deferred[0].Payload)
+
+	cancelled := DeviceAction{GroupID: groupID, DeviceID: "aa", Kind: DeviceActionCommand, Disruptive: true}
+	require.NoError(t, SubmitDeviceAction(db, &cancelled, closed))
+	require.NoError(t, CancelDeviceAction(db, groupID, cancelled.ID))
+	assert.ErrorIs(t, CancelDeviceAction(db, groupID, cancelled.ID), ErrDeviceActionNotQueued)
+
+	previews, err := PreviewMaintenanceWindows(db, groupID, closed)
+	require.NoError(t, err)
+	require.Len(t, previews, 1)
+	assert.False(t, previews[0].Open)
+	assert.Len(t, previews[0].PendingActions, 2)
+
+	n, err := DispatchDueDeviceActions(db, closed)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	n, err = DispatchDueDeviceActions(db, opened)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []uint{free.ID, config.ID, firmware.ID, deferred[0].ID}, executed)
+
+	var queued int64
+	db.Model(&DeviceAction{}).Where("status = ?", DeviceActionQueued).Count(&queued)
+	assert.Zero(t, queued)
+}
@@ -0,0 +1,336 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// NotificationEvent 通知事件类型
+type NotificationEvent string
+
+const (
+	NotificationEventAlert     NotificationEvent = "alert"     // 告警
+	NotificationEventSecurity  NotificationEvent = "security"  // 新设备登录等安全事件
+	NotificationEventAccount   NotificationEvent = "account"   // 欢迎、登录等账户消息
+	NotificationEventGroup     NotificationEvent = "group"     // 组织邀请
+	NotificationEventAssistant NotificationEvent = "assistant" // 助手变更
+	NotificationEventSystem    NotificationEvent = "system"    // 系统公告
+	NotificationEventDevice    NotificationEvent = "device"    // 设备离线
+	NotificationEventCall      NotificationEvent = "call"      // 未接来电
+	NotificationEventInbox     NotificationEvent = "inbox"     // 团队收件箱的新会话、分配和 SLA 超时
+)
+
+// NotificationEvents 支持配置偏好的事件类型
+var NotificationEvents = []NotificationEvent{
+	NotificationEventAlert, NotificationEventSecurity, NotificationEventAccount,
+	NotificationEventGroup, NotificationEventAssistant, NotificationEventSystem,
+	NotificationEventDevice, NotificationEventCall, NotificationEventInbox,
+}
+
+// NotificationPreference 用户的结构化通知偏好：按事件选择渠道、免打扰时段与紧急事件例外
+type NotificationPreference struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID    uint      `json:"userId" gorm:"uniqueIndex"`
+	// Channels 事件类型到渠道列表的 JSON，未配置的事件使用调用方的默认渠道
+	Channels          string `json:"-" gorm:"type:text"`
+	QuietHoursEnabled bool   `json:"quietHoursEnabled"`
+	QuietStart        string `json:"quietStart" gorm:"size:5"`     // HH:MM
+	QuietEnd          string `json:"quietEnd" gorm:"size:5"`       // HH:MM，早于开始时间表示跨天
+	QuietTimezone     string `json:"quietTimezone" gorm:"size:64"` // 为空时使用用户时区
+	// CriticalOverride 紧急事件无视免打扰时段
+	CriticalOverride bool   `json:"criticalOverride"`
+	WebhookURL       string `json:"webhookUrl" gorm:"size:512"`
+	WebhookSecret    string `json:"-" gorm:"size:128"`
+}
+
+// TableName 指定表名
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// ChannelMap 解析按事件的渠道配置
+func (p *NotificationPreference) ChannelMap() map[NotificationEvent][]NotificationChannel {
+	channels := map[NotificationEvent][]NotificationChannel{}
+	if p.Channels != "" {
+		_ = json.Unmarshal([]byte(p.Channels), &channels)
+	}
+	return channels
+}
+
+// SetChannelMap 保存按事件的渠道配置
+func (p *NotificationPreference) SetChannelMap(channels map[NotificationEvent][]NotificationChannel) error {
+	for event, list := range channels {
+		if !validNotificationEvent(event) {
+			return fmt.Errorf("unsupported notification event: %s", event)
+		}
+		for _, ch := range list {
+			switch ch {
+			case NotificationChannelEmail, NotificationChannelInternal, NotificationChannelSMS, NotificationChannelWebhook, NotificationChannelPush:
+			default:
+				return fmt.Errorf("unsupported notification channel: %s", ch)
+			}
+		}
+	}
+	data, err := json.Marshal(channels)
+	if err != nil {
+		return err
+	}
+	p.Channels = string(data)
+	return nil
+}
+
+// Validate 检查免打扰时段配置
+func (p *NotificationPreference) Validate() error {
+	if !p.QuietHoursEnabled {
+		return nil
+	}
+	if _, err := parseClock(p.QuietStart); err != nil {
+		return fmt.Errorf("invalid quietStart: %w", err)
+	}
+	if _, err := parseClock(p.QuietEnd); err != nil {
+		return fmt.Errorf("invalid quietEnd: %w", err)
+	}
+	if p.QuietTimezone != "" {
+		if _, err := time.LoadLocation(p.QuietTimezone); err != nil {
+			return fmt.Errorf("invalid quietTimezone: %w", err)
+		}
+	}
+	return nil
+}
+
+// InQuietHours 判断时间是否处于免打扰时段，fallbackTZ 为用户时区
+func (p *NotificationPreference) InQuietHours(now time.Time, fallbackTZ string) bool {
+	if !p.QuietHoursEnabled {
+		return false
+	}
+	start, err1 := parseClock(p.QuietStart)
+	end, err2 := parseClock(p.QuietEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return false
+	}
+	tz := p.QuietTimezone
+	if tz == "" {
+		tz = fallbackTZ
+	}
+	if loc, err := time.LoadLocation(tz); err == nil && tz != "" {
+		now = now.In(loc)
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// Allows 判断事件能否通过指定渠道发送，返回拒绝原因。
+// 站内通知只受渠道选择约束，其余渠道在免打扰时段内仅放行紧急事件（开启例外时）
+func (p *NotificationPreference) Allows(user *User, event NotificationEvent, channel NotificationChannel, critical bool, now time.Time) (bool, string) {
+	if list, ok := p.ChannelMap()[event]; ok && !containsChannel(list, channel) {
+		return false, "channel disabled for event"
+	}
+	if channel == NotificationChannelEmail && user != nil && !user.EmailNotifications {
+		return false, "email notifications disabled"
+	}
+	if channel == NotificationChannelPush && user != nil && !user.PushNotifications {
+		return false, "push notifications disabled"
+	}
+	if channel != NotificationChannelInternal && p.InQuietHours(now, userTimezone(user)) && !(critical && p.CriticalOverride) {
+		return false, "quiet hours"
+	}
+	return true, ""
+}
+
+// GetNotificationPreference 获取用户通知偏好，未设置时返回默认值
+func GetNotificationPreference(db *gorm.DB, userID uint) (*NotificationPreference, error) {
+	var pref NotificationPreference
+	err := db.Where("user_id = ?", userID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NotificationPreference{UserID: userID, CriticalOverride: true, QuietStart: "22:00", QuietEnd: "08:00"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// SaveNotificationPreference 保存用户通知偏好
+func SaveNotificationPreference(db *gorm.DB, pref *NotificationPreference) error {
+	if err := pref.Validate(); err != nil {
+		return err
+	}
+	return db.Save(pref).Error
+}
+
+// NotificationAllowed 通知发送前的统一检查，读取偏好失败时放行
+func NotificationAllowed(db *gorm.DB, user *User, event NotificationEvent, channel NotificationChannel, critical bool) (bool, string) {
+	if user == nil {
+		return true, ""
+	}
+	pref, err := GetNotificationPreference(db, user.ID)
+	if err != nil {
+		logger.Warn("Failed to load notification preference", zap.Uint("userId", user.ID), zap.Error(err))
+		return true, ""
+	}
+	return pref.Allows(user, event, channel, critical, time.Now())
+}
+
+// Notice 待分发的用户通知
+type Notice struct {
+	Event    NotificationEvent
+	Title    string
+	Content  string
+	Critical bool
+	// Channels 用户未配置该事件时使用的渠道
+	Channels []NotificationChannel
+	// SendEmail 自定义邮件发送，为空时以 Content 发送 HTML 邮件
+	SendEmail func(user *User) error
+	// Data 附加在 webhook 中的数据
+	Data map[string]any
+	// PushData 推送时附加发给 App 的数据
+	PushData map[string]string
+}
+
+// NoticeFromPushTemplate 用推送模板渲染通知的标题和内容，站内通知和推送使用相同的文案，
+// 模板数据同时作为推送的附加数据
+func NoticeFromPushTemplate(event NotificationEvent, template string, data map[string]string) (Notice, error) {
+	msg, err := notification.RenderPush(template, data)
+	if err != nil {
+		return Notice{}, err
+	}
+	return Notice{Event: event, Title: msg.Title, Content: msg.Body, PushData: data}, nil
+}
+
+// DispatchNotification 按用户偏好分发通知，返回每个渠道的发送结果（nil 为成功，被偏好拦截的渠道不在结果中）
+func DispatchNotification(db *gorm.DB, user *User, notice Notice) map[NotificationChannel]error {
+	results := map[NotificationChannel]error{}
+	pref, err := GetNotificationPreference(db, user.ID)
+	if err != nil {
+		logger.Warn("Failed to load notification preference", zap.Uint("userId", user.ID), zap.Error(err))
+		pref = &NotificationPreference{UserID: user.ID}
+	}
+	channels := notice.Channels
+	if list, ok := pref.ChannelMap()[notice.Event]; ok {
+		channels = list
+	}
+
+	now := time.Now()
+	for _, channel := range channels {
+		if ok, reason := pref.Allows(user, notice.Event, channel, notice.Critical, now); !ok {
+			logger.Info("Notification suppressed",
+				zap.Uint("userId", user.ID),
+				zap.String("event", string(notice.Event)),
+				zap.String("channel", string(channel)),
+				zap.String("reason", reason))
+			continue
+		}
+		results[channel] = sendNotice(db, user, pref, channel, notice)
+		if results[channel] != nil {
+			logger.Warn("Failed to send notification",
+				zap.Uint("userId", user.ID),
+				zap.String("channel", string(channel)),
+				zap.Error(results[channel]))
+		}
+	}
+	return results
+}
+
+// notificationWebhookTimeout 通知 Webhook 单次投递的最长等待时间
+const notificationWebhookTimeout = 15 * time.Second
+
+func sendNotice(db *gorm.DB, user *User, pref *NotificationPreference, channel NotificationChannel, notice Notice) error {
+	switch channel {
+	case NotificationChannelInternal:
+		return notification.NewInternalNotificationService(db).Send(user.ID, notice.Title, notice.Content)
+	case NotificationChannelEmail:
+		if notice.SendEmail != nil {
+			return notice.SendEmail(user)
+		}
+		if config.GlobalConfig == nil || config.GlobalConfig.Services.Mail.From == "" {
+			return errors.New("mail is not configured")
+		}
+		return notification.NewMailNotificationWithDB(UserMailConfig(db, user.ID, config.GlobalConfig.Services.Mail), db, user.ID).
+			SendHTML(user.Email, notice.Title, notice.Content)
+	case NotificationChannelWebhook:
+		if pref.WebhookURL == "" {
+			return errors.New("notification webhook url not set")
+		}
+		// 地址由用户配置，只投递到公网地址
+		ctx, cancel := context.WithTimeout(context.Background(), notificationWebhookTimeout)
+		defer cancel()
+		_, err := webhook.DeliverPublic(ctx, webhook.Request{
+			URL:    pref.WebhookURL,
+			Event:  "notification." + string(notice.Event),
+			Secret: pref.WebhookSecret,
+			Payload: map[string]any{
+				"event":    notice.Event,
+				"title":    notice.Title,
+				"content":  notice.Content,
+				"critical": notice.Critical,
+				"data":     notice.Data,
+				"userId":   user.ID,
+			},
+		})
+		return err
+	case NotificationChannelPush:
+		msg := notification.PushMessage{
+			Title:    notice.Title,
+			Body:     notice.Content,
+			Critical: notice.Critical,
+			Data:     map[string]string{"event": string(notice.Event)},
+		}
+		for k, v := range notice.PushData {
+			msg.Data[k] = v
+		}
+		return SendPushNotification(db, user.ID, msg)
+	case NotificationChannelSMS:
+		return errors.New("SMS notification not implemented")
+	}
+	return fmt.Errorf("unsupported notification channel: %s", channel)
+}
+
+func validNotificationEvent(event NotificationEvent) bool {
+	for _, e := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func containsChannel(list []NotificationChannel, channel NotificationChannel) bool {
+	for _, ch := range list {
+		if ch == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func userTimezone(user *User) string {
+	if user == nil {
+		return ""
+	}
+	return user.Timezone
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
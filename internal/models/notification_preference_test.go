@@ -0,0 +1,107 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationPreference_QuietHours(t *testing.T) {
+	pref := &NotificationPreference{QuietHoursEnabled: true, QuietStart: "22:00", QuietEnd: "07:30", QuietTimezone: "Asia/Shanghai", CriticalOverride: true}
+	require.NoError(t, pref.Validate())
+
+	// 23:00 in Shanghai is 15:00 UTC
+	night := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+	day := time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC)
+	earlyMorning := time.Date(2026, 3, 1, 23, 15, 0, 0, time.UTC) // 07:15 next day
+	assert.True(t, pref.InQuietHours(night, ""))
+	assert.False(t, pref.InQuietHours(day, ""))
+	assert.True(t, pref.InQuietHours(earlyMorning, ""))
+
+	// Falls back to the user's timezone
+	pref.QuietTimezone = ""
+	assert.False(t, pref.InQuietHours(night, "UTC"))
+	assert.True(t, pref.InQuietHours(night, "Asia/Shanghai"))
+
+	pref.QuietStart = "25:00"
+	assert.Error(t, pref.Validate())
+}
+
+func TestNotificationPreference_Allows(t *testing.T) {
+	user := &User{EmailNotifications: true, Timezone: "UTC"}
+	pref := &NotificationPreference{QuietHoursEnabled: true, QuietStart: "22:00", QuietEnd: "07:00", CriticalOverride: true}
+	require.NoError(t, pref.SetChannelMap(map[NotificationEvent][]NotificationChannel{
+		NotificationEventAccount: {NotificationChannelInternal},
+	}))
+	night := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	noon := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	ok, reason := pref.Allows(user, NotificationEventAccount, NotificationChannelEmail, false, noon)
+	assert.False(t, ok)
+	assert.Equal(t, "channel disabled for event", reason)
+	ok, _ = pref.Allows(user, NotificationEventAlert, NotificationChannelEmail, false, noon)
+	assert.True(t, ok, "events without preferences keep every channel")
+
+	ok, reason = pref.Allows(user, NotificationEventAlert, NotificationChannelEmail, false, night)
+	assert.False(t, ok)
+	assert.Equal(t, "quiet hours", reason)
+	ok, _ = pref.Allows(user, NotificationEventAlert, NotificationChannelInternal, false, night)
+	assert.True(t, ok, "in-app notifications are not affected by quiet hours")
+	ok, _ = pref.Allows(user, NotificationEventAlert, NotificationChannelEmail, true, night)
+	assert.True(t, ok, "critical events override quiet hours")
+
+	pref.CriticalOverride = false
+	ok, _ = pref.Allows(user, NotificationEventAlert, NotificationChannelEmail, true, night)
+	assert.False(t, ok)
+
+	user.EmailNotifications = false
+	ok, _ = pref.Allows(user, NotificationEventAlert, NotificationChannelEmail, false, noon)
+	assert.False(t, ok)
+
+	assert.Error(t, pref.SetChannelMap(map[NotificationEvent][]NotificationChannel{"billing": {NotificationChannelEmail}}))
+	assert.Error(t, pref.SetChannelMap(map[NotificationEvent][]NotificationChannel{NotificationEventAlert: {"pager"}}))
+}
+
+func TestDispatchNotification(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &NotificationPreference{}, &notification.InternalNotification{})
+	user := &User{BaseModel: BaseModel{ID: 7}, EmailNotifications: true}
+
+	emails := 0
+	notice := Notice{
+		Event:     NotificationEventGroup,
+		Title:     "invite",
+		Content:   "join us",
+		Channels:  []NotificationChannel{NotificationChannelInternal, NotificationChannelEmail},
+		SendEmail: func(*User) error { emails++; return nil },
+	}
+	results := DispatchNotification(db, user, notice)
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[NotificationChannelInternal])
+	assert.Equal(t, 1, emails)
+
+	pref, err := GetNotificationPreference(db, user.ID)
+	require.NoError(t, err)
+	require.NoError(t, pref.SetChannelMap(map[NotificationEvent][]NotificationChannel{NotificationEventGroup: {NotificationChannelEmail}}))
+	require.NoError(t, SaveNotificationPreference(db, pref))
+
+	results = DispatchNotification(db, user, notice)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 2, emails)
+
+	var count int64
+	db.Model(&notification.InternalNotification{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestSendNotice_WebhookRefusesPrivateTargets(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &NotificationPreference{})
+	user := &User{BaseModel: BaseModel{ID: 7}}
+	pref := &NotificationPreference{UserID: user.ID, WebhookURL: "http://169.254.169.254/latest/meta-data"}
+
+	err := sendNotice(db, user, pref, NotificationChannelWebhook, Notice{Event: NotificationEventAlert, Title: "t"})
+	assert.ErrorIs(t, err, webhook.ErrUnsafeTarget)
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/oauth"
+	"gorm.io/gorm"
+)
+
+// OAuthUserSource 首次第三方登录时创建的用户来源
+const OAuthUserSource = "oauth"
+
+var (
+	// ErrOAuthEmailNotVerified 第三方账号没有已验证的邮箱，且尚未绑定到任何用户
+	ErrOAuthEmailNotVerified = errors.New("the account has no verified email; sign in and link it from your profile first")
+	// ErrOAuthNotProvisioned 邮箱没有对应用户，且未开启自动创建
+	ErrOAuthNotProvisioned = errors.New("no account is registered with this email")
+	// ErrOAuthAdminNotAllowed 管理员账号不能通过邮箱自动绑定第三方账号
+	ErrOAuthAdminNotAllowed = errors.New("administrator accounts cannot be linked by email; sign in and link it from your profile")
+	// ErrOAuthIdentityLinked 第三方账号已绑定到其他用户
+	ErrOAuthIdentityLinked = errors.New("this account is already linked to another user")
+)
+
+// OAuthIdentity 用户绑定的第三方登录账号，同一第三方账号只能绑定一个用户
+type OAuthIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UserID      uint       `json:"userId" gorm:"index"`
+	Provider    string     `json:"provider" gorm:"size:32;uniqueIndex:idx_oauth_identity,priority:1"`
+	Subject     string     `json:"subject" gorm:"size:128;uniqueIndex:idx_oauth_identity,priority:2"` // 第三方账号 ID
+	Email       string     `json:"email" gorm:"size:128"`
+	Name        string     `json:"name" gorm:"size:128"`
+	AvatarURL   string     `json:"avatarUrl" gorm:"size:512"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
+
+// TableName 指定表名
+func (OAuthIdentity) TableName() string {
+	return "oauth_identities"
+}
+
+// FindOAuthIdentity 按第三方和账号 ID 查找绑定
+func FindOAuthIdentity(db *gorm.DB, provider, subject string) (*OAuthIdentity, error) {
+	var identity OAuthIdentity
+	if err := db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// ResolveOAuthUser 第三方登录对应的用户：已绑定的直接返回；否则按已验证邮箱绑定到已有用户，
+// 没有该邮箱的用户且 autoProvision 时创建用户。返回的 created 表示新建了用户
+func ResolveOAuthUser(db *gorm.DB, id *oauth.Identity, autoProvision bool, now time.Time) (*User, bool, error) {
+	existing, err := FindOAuthIdentity(db, id.Provider, id.Subject)
+	if err == nil {
+		user, err := GetUserByUID(db, existing.UserID)
+		if err != nil {
+			return nil, false, err
+		}
+		err = db.Model(existing).Updates(map[string]any{
+			"email": id.Email, "name": id.Name, "avatar_url": id.AvatarURL, "last_login_at": now,
+		}).Error
+		return user, false, err
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(id.Email))
+	if email == "" || !id.EmailVerified {
+		return nil, false, ErrOAuthEmailNotVerified
+	}
+	created := false
+	user, err := GetUserByEmail(db, email)
+	switch {
+	case err == nil:
+		if user.IsAdmin() {
+			return nil, false, ErrOAuthAdminNotAllowed
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !autoProvision {
+			return nil, false, ErrOAuthNotProvisioned
+		}
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, false, err
+		}
+		user = &User{
+			Email:              email,
+			Password:           HashPassword(hex.EncodeToString(buf)),
+			DisplayName:        id.Name,
+			Avatar:             id.AvatarURL,
+			Enabled:            true,
+			Activated:          true,
+			EmailVerified:      true,
+			EmailNotifications: true,
+			Role:               RoleUser,
+			Source:             OAuthUserSource,
+		}
+		if err := db.Create(user).Error; err != nil {
+			return nil, false, err
+		}
+		created = true
+	default:
+		return nil, false, err
+	}
+
+	if err := LinkOAuthIdentity(db, user.ID, id, now); err != nil {
+		return nil, false, err
+	}
+	return user, created, nil
+}
+
+// LinkOAuthIdentity 将第三方账号绑定到用户，已绑定到该用户时只刷新资料
+func LinkOAuthIdentity(db *gorm.DB, userID uint, id *oauth.Identity, now time.Time) error {
+	existing, err := FindOAuthIdentity(db, id.Provider, id.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			return ErrOAuthIdentityLinked
+		}
+		return db.Model(existing).Updates(map[string]any{
+			"email": id.Email, "name": id.Name, "avatar_url": id.AvatarURL, "last_login_at": now,
+		}).Error
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return db.Create(&OAuthIdentity{
+		UserID:      userID,
+		Provider:    id.Provider,
+		Subject:     id.Subject,
+		Email:       id.Email,
+		Name:        id.Name,
+		AvatarURL:   id.AvatarURL,
+		LastLoginAt: &now,
+	}).Error
+}
+
+// ListOAuthIdentities 用户绑定的第三方账号
+func ListOAuthIdentities(db *gorm.DB, userID uint) ([]OAuthIdentity, error) {
+	var identities []OAuthIdentity
+	err := db.Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+// UnlinkOAuthIdentity 解除用户的第三方账号绑定
+func UnlinkOAuthIdentity(db *gorm.DB, userID, identityID uint) error {
+	res := db.Where("id = ? AND user_id = ?", identityID, userID).Delete(&OAuthIdentity{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SyncOIDCGroupMemberships 按 IdP 组声明同步用户在映射组织中的成员身份：声明中的组授予成员或管理员角色，
+// prune 时移除用户不再属于的映射组织。映射之外的组织不受影响，组织创建者不会被移除
+func SyncOIDCGroupMemberships(db *gorm.DB, userID uint, idpGroups []string, mapping oauth.GroupMapping, prune bool) error {
+	grants := mapping.Grants(idpGroups)
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, groupID := range mapping.GroupIDs() {
+			var group Group
+			if err := tx.Select("id", "creator_id").First(&group, groupID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue // 映射了已删除的组织
+				}
+				return err
+			}
+			var member GroupMember
+			err := tx.Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			exists := err == nil
+			role, granted := grants[groupID]
+			switch {
+			case granted && !exists:
+				err = tx.Create(&GroupMember{UserID: userID, GroupID: groupID, Role: role}).Error
+			case granted && member.Role != role && group.CreatorID != userID:
+				err = tx.Model(&member).Update("role", role).Error
+			case !granted && exists && prune && group.CreatorID != userID:
+				err = tx.Delete(&member).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
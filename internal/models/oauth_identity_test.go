@@ -0,0 +1,117 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveOAuthUser_LinkByVerifiedEmail(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &OAuthIdentity{})
+	now := time.Now()
+	existing := &User{Email: "ann@example.com", Enabled: true, Role: RoleUser}
+	require.NoError(t, db.Create(existing).Error)
+
+	id := &oauth.Identity{Provider: oauth.ProviderGitHub, Subject: "42", Email: "Ann@example.com", EmailVerified: true}
+	user, created, err := ResolveOAuthUser(db, id, false, now)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, existing.ID, user.ID)
+
+	// Later logins use the link even after the email changes at the provider
+	id.Email, id.EmailVerified = "ann@elsewhere.com", false
+	user, _, err = ResolveOAuthUser(db, id, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, user.ID)
+}
+
+func TestResolveOAuthUser_Provisioning(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &OAuthIdentity{})
+	now := time.Now()
+	id := &oauth.Identity{Provider: oauth.ProviderGoogle, Subject: "1081", Email: "new@example.com", EmailVerified: true, Name: "New"}
+
+	_, _, err := ResolveOAuthUser(db, id, false, now)
+	assert.ErrorIs(t, err, ErrOAuthNotProvisioned)
+
+	user, created, err := ResolveOAuthUser(db, id, true, now)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, OAuthUserSource, user.Source)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, "New", user.DisplayName)
+
+	_, _, err = ResolveOAuthUser(db, &oauth.Identity{Provider: oauth.ProviderGitHub, Subject: "7", Email: "x@example.com"}, true, now)
+	assert.ErrorIs(t, err, ErrOAuthEmailNotVerified, "unverified emails are neither linked nor provisioned")
+	_, _, err = ResolveOAuthUser(db, &oauth.Identity{Provider: oauth.ProviderWeChat, Subject: "u-1"}, true, now)
+	assert.ErrorIs(t, err, ErrOAuthEmailNotVerified)
+}
+
+func TestResolveOAuthUser_AdminNotLinkedByEmail(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &OAuthIdentity{})
+	require.NoError(t, db.Create(&User{Email: "root@example.com", Enabled: true, Role: RoleSuperAdmin}).Error)
+
+	_, _, err := ResolveOAuthUser(db, &oauth.Identity{Provider: oauth.ProviderGoogle, Subject: "1", Email: "root@example.com", EmailVerified: true}, true, time.Now())
+	assert.ErrorIs(t, err, ErrOAuthAdminNotAllowed)
+}
+
+func TestLinkAndUnlinkOAuthIdentity(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &OAuthIdentity{})
+	now := time.Now()
+	id := &oauth.Identity{Provider: oauth.ProviderWeChat, Subject: "u-1", Name: "小王"}
+
+	require.NoError(t, LinkOAuthIdentity(db, 1, id, now))
+	require.NoError(t, LinkOAuthIdentity(db, 1, id, now), "linking again is a no-op")
+	assert.ErrorIs(t, LinkOAuthIdentity(db, 2, id, now), ErrOAuthIdentityLinked)
+
+	identities, err := ListOAuthIdentities(db, 1)
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+
+	assert.Error(t, UnlinkOAuthIdentity(db, 2, identities[0].ID))
+	require.NoError(t, UnlinkOAuthIdentity(db, 1, identities[0].ID))
+	identities, _ = ListOAuthIdentities(db, 1)
+	assert.Empty(t, identities)
+}
+
+func TestSyncOIDCGroupMemberships(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &Group{}, &GroupMember{})
+	owner := &User{Email: "owner@corp.example", Enabled: true, Role: RoleUser}
+	ann := &User{Email: "ann@corp.example", Enabled: true, Role: RoleUser}
+	require.NoError(t, db.Create(owner).Error)
+	require.NoError(t, db.Create(ann).Error)
+	eng := &Group{Name: "Engineering", CreatorID: owner.ID}
+	ops := &Group{Name: "Ops", CreatorID: owner.ID}
+	other := &Group{Name: "Unmapped", CreatorID: owner.ID}
+	require.NoError(t, db.Create(eng).Error)
+	require.NoError(t, db.Create(ops).Error)
+	require.NoError(t, db.Create(other).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: ann.ID, GroupID: other.ID, Role: GroupRoleMember}).Error)
+
+	mapping := oauth.GroupMapping{
+		"engineering":     {{GroupID: eng.ID, Role: GroupRoleMember}},
+		"platform-admins": {{GroupID: eng.ID, Role: GroupRoleAdmin}, {GroupID: ops.ID, Role: GroupRoleMember}},
+		"deleted":         {{GroupID: 999, Role: GroupRoleMember}},
+	}
+	roleIn := func(groupID uint) string {
+		var m GroupMember
+		if err := db.Where("group_id = ? AND user_id = ?", groupID, ann.ID).First(&m).Error; err != nil {
+			return ""
+		}
+		return m.Role
+	}
+
+	require.NoError(t, SyncOIDCGroupMemberships(db, ann.ID, []string{"engineering", "platform-admins", "deleted"}, mapping, true))
+	assert.Equal(t, GroupRoleAdmin, roleIn(eng.ID), "admin wins over member")
+	assert.Equal(t, GroupRoleMember, roleIn(ops.ID))
+
+	require.NoError(t, SyncOIDCGroupMemberships(db, ann.ID, []string{"engineering"}, mapping, false))
+	assert.Equal(t, GroupRoleMember, roleIn(eng.ID), "role follows the claim")
+	assert.Equal(t, GroupRoleMember, roleIn(ops.ID), "memberships are kept without pruning")
+
+	require.NoError(t, SyncOIDCGroupMemberships(db, ann.ID, []string{"engineering"}, mapping, true))
+	assert.Empty(t, roleIn(ops.ID), "pruned once the user left the IdP group")
+	assert.Equal(t, GroupRoleMember, roleIn(other.ID), "unmapped organizations are untouched")
+}
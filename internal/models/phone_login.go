@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"gorm.io/gorm"
+)
+
+// ErrPhoneTaken 手机号已被其他账号验证，一个手机号只能登录一个账号
+var ErrPhoneTaken = errors.New("phone number is already verified by another account")
+
+// PhoneLoginForm 手机号验证码登录
+type PhoneLoginForm struct {
+	Phone         string `json:"phone" binding:"required"`
+	Code          string `json:"code" binding:"required"`
+	TwoFactorCode string `json:"twoFactorCode,omitempty"` // 启用两步验证时必填
+	AuthToken     bool   `json:"AuthToken,omitempty"`
+	Timezone      string `json:"timezone,omitempty"`
+	CaptchaID     string `json:"captchaId,omitempty"`
+	CaptchaCode   string `json:"captchaCode,omitempty"`
+}
+
+// NormalizePhone 去掉空格、连字符和括号，00 开头的国际前缀转为 +
+func NormalizePhone(phone string) string {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+	return phone
+}
+
+// GetUserByPhone 按已验证的手机号查找用户，未验证的手机号不能用于登录
+func GetUserByPhone(db *gorm.DB, phone string) (*User, error) {
+	phone = NormalizePhone(phone)
+	if phone == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var user User
+	err := db.Table(constants.USER_TABLE_NAME).
+		Where("phone = ? AND phone_verified = ?", phone, true).
+		Order("id").Take(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// MarkPhoneVerified 将用户当前的手机号标记为已验证，手机号已被其他账号验证时返回 ErrPhoneTaken
+func MarkPhoneVerified(db *gorm.DB, user *User) error {
+	if user.Phone != "" {
+		var n int64
+		err := db.Table(constants.USER_TABLE_NAME).
+			Where("phone = ? AND phone_verified = ? AND id <> ?", NormalizePhone(user.Phone), true, user.ID).
+			Count(&n).Error
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrPhoneTaken
+		}
+	}
+	err := UpdateUserFields(db, user, map[string]any{
+		"PhoneVerified":    true,
+		"PhoneVerifyToken": "",
+	})
+	if err != nil {
+		return err
+	}
+	user.PhoneVerified = true
+	user.PhoneVerifyToken = ""
+	return nil
+}
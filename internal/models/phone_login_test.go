@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	assert.Equal(t, "+8613800138000", NormalizePhone(" +86 138-0013-8000 "))
+	assert.Equal(t, "+15550100", NormalizePhone("001 (555) 0100"))
+	assert.Equal(t, "13800138000", NormalizePhone("138.0013.8000"))
+}
+
+func TestGetUserByPhone(t *testing.T) {
+	db := setupTestDB(t)
+
+	alice, err := CreateUser(db, "alice@example.com", "password123")
+	require.NoError(t, err)
+	require.NoError(t, UpdateUserFields(db, alice, map[string]any{"Phone": "+8613800138000"}))
+
+	// 未验证的手机号不能登录
+	_, err = GetUserByPhone(db, "+8613800138000")
+	assert.Error(t, err)
+
+	require.NoError(t, MarkPhoneVerified(db, alice))
+	found, err := GetUserByPhone(db, "+86 138 0013 8000")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, found.ID)
+
+	// 同一手机号不能被第二个账号验证
+	bob, err := CreateUser(db, "bob@example.com", "password123")
+	require.NoError(t, err)
+	require.NoError(t, UpdateUserFields(db, bob, map[string]any{"Phone": "+8613800138000"}))
+	assert.ErrorIs(t, MarkPhoneVerified(db, bob), ErrPhoneTaken)
+	assert.False(t, bob.PhoneVerified)
+
+	_, err = GetUserByPhone(db, "")
+	assert.Error(t, err)
+}
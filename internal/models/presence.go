@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PresenceStatus manually set presence of a user or SIP agent.
+// Call-inferred busy state is kept in memory only, see pkg/presence.
+type PresenceStatus struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	Kind      string     `json:"kind" gorm:"size:16;not null;uniqueIndex:idx_presence_subject"`       // user, sip
+	SubjectID string     `json:"subjectId" gorm:"size:128;not null;uniqueIndex:idx_presence_subject"` // User ID or SIP username
+	Status    string     `json:"status" gorm:"size:16;not null"`                                      // available, busy, dnd, offline
+	Note      string     `json:"note,omitempty" gorm:"size:255"`
+	Until     *time.Time `json:"until,omitempty"` // Status reverts to available afterwards
+}
+
+// TableName 指定表名
+func (PresenceStatus) TableName() string {
+	return "presence_statuses"
+}
+
+// SavePresenceStatus upserts the manual presence of a subject
+func SavePresenceStatus(db *gorm.DB, status *PresenceStatus) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "subject_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "note", "until", "updated_at"}),
+	}).Create(status).Error
+}
+
+// ListActivePresenceStatuses lists manual statuses that have not expired
+func ListActivePresenceStatuses(db *gorm.DB) ([]PresenceStatus, error) {
+	var statuses []PresenceStatus
+	err := db.Where("until IS NULL OR until > ?", time.Now()).Find(&statuses).Error
+	return statuses, err
+}
+
+// GroupPeerUserIDs lists the users sharing an organization with the user,
+// including the user itself
+func GroupPeerUserIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	var groupIDs []uint
+	if err := db.Model(&GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs).Error; err != nil {
+		return nil, err
+	}
+	var created []uint
+	if err := db.Model(&Group{}).Where("creator_id = ?", userID).Pluck("id", &created).Error; err != nil {
+		return nil, err
+	}
+	return GroupUserIDs(db, append(groupIDs, created...), userID)
+}
+
+// GroupUserIDs lists members and creators of the organizations plus the extra users, without duplicates
+func GroupUserIDs(db *gorm.DB, groupIDs []uint, extra ...uint) ([]uint, error) {
+	seen := make(map[uint]struct{})
+	var result []uint
+	add := func(ids []uint) {
+		for _, id := range ids {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				result = append(result, id)
+			}
+		}
+	}
+	add(extra)
+	if len(groupIDs) == 0 {
+		return result, nil
+	}
+
+	var members []uint
+	if err := db.Model(&GroupMember{}).Where("group_id IN ?", groupIDs).Pluck("user_id", &members).Error; err != nil {
+		return nil, err
+	}
+	var creators []uint
+	if err := db.Model(&Group{}).Where("id IN ?", groupIDs).Pluck("creator_id", &creators).Error; err != nil {
+		return nil, err
+	}
+	add(members)
+	add(creators)
+	return result, nil
+}
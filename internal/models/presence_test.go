@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavePresenceStatus_Upserts(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &PresenceStatus{})
+
+	require.NoError(t, SavePresenceStatus(db, &PresenceStatus{Kind: "user", SubjectID: "1", Status: "dnd", Note: "focus"}))
+	require.NoError(t, SavePresenceStatus(db, &PresenceStatus{Kind: "user", SubjectID: "1", Status: "busy"}))
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, SavePresenceStatus(db, &PresenceStatus{Kind: "sip", SubjectID: "alice", Status: "dnd", Until: &past}))
+
+	statuses, err := ListActivePresenceStatuses(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "busy", statuses[0].Status)
+	assert.Empty(t, statuses[0].Note)
+}
+
+func TestGroupPeerUserIDs(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Group{}, &GroupMember{})
+	group := &Group{Name: "team", CreatorID: 1}
+	require.NoError(t, db.Create(group).Error)
+	require.NoError(t, db.Create(&GroupMember{GroupID: group.ID, UserID: 2}).Error)
+	require.NoError(t, db.Create(&GroupMember{GroupID: group.ID, UserID: 3}).Error)
+
+	peers, err := GroupPeerUserIDs(db, 2)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{1, 2, 3}, peers)
+
+	peers, err = GroupPeerUserIDs(db, 9)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{9}, peers)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 语音管线中受保护的阶段
+const (
+	ProviderStageLLM = "llm"
+	ProviderStageTTS = "tts"
+)
+
+// 服务商故障的类型
+const (
+	ProviderIncidentTimeout  = "timeout"  // 超出阶段预算仍未返回
+	ProviderIncidentError    = "error"    // 返回错误
+	ProviderIncidentFailover = "failover" // 作为备用服务商接替了故障的主服务商
+)
+
+const (
+	DefaultLLMBudgetMs    = 8000
+	DefaultTTSBudgetMs    = 8000
+	minStageBudgetMs      = 1000
+	maxStageBudgetMs      = 30000
+	DefaultGuardApology   = "抱歉，系统暂时无法回答您的问题"
+	providerIncidentError = 512
+)
+
+// ErrInvalidStageBudget 阶段预算超出允许范围
+var ErrInvalidStageBudget = errors.New("stage budget must be 0 or between 1000 and 30000 ms")
+
+// ProviderGuard 助手语音管线的延迟保护配置（用于 JSON 存储）：
+// LLM 或 TTS 超出预算时播放垫话并切换到备用凭证的服务商，全部失败时致歉并转入留言
+type ProviderGuard struct {
+	LLMBudgetMs          int    `json:"llmBudgetMs,omitempty"`          // LLM 回复预算（毫秒），0 使用默认值
+	TTSBudgetMs          int    `json:"ttsBudgetMs,omitempty"`          // TTS 合成预算（毫秒），0 使用默认值
+	FillerPrompt         string `json:"fillerPrompt,omitempty"`         // 切换备用服务商前播放的垫话，如"请稍等，我查一下"
+	ApologyPrompt        string `json:"apologyPrompt,omitempty"`        // 全部服务商失败时的致歉语，为空使用默认致歉语
+	FailoverCredentialID uint   `json:"failoverCredentialId,omitempty"` // 备用服务商所在的凭证，须属于助手所有者
+}
+
+// Value 实现 driver.Valuer 接口
+func (g *ProviderGuard) Value() (driver.Value, error) {
+	if g == nil {
+		return nil, nil
+	}
+	return json.Marshal(g)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (g *ProviderGuard) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	}
+	if len(bytes) == 0 {
+		*g = ProviderGuard{}
+		return nil
+	}
+	return json.Unmarshal(bytes, g)
+}
+
+// Validate 检查阶段预算
+func (g *ProviderGuard) Validate() error {
+	for _, ms := range []int{g.LLMBudgetMs, g.TTSBudgetMs} {
+		if ms != 0 && (ms < minStageBudgetMs || ms > maxStageBudgetMs) {
+			return ErrInvalidStageBudget
+		}
+	}
+	return nil
+}
+
+// Guard 返回助手的延迟保护配置，未配置的预算和致歉语使用默认值
+func (a *Assistant) Guard() ProviderGuard {
+	var g ProviderGuard
+	if a.ProviderGuard != nil {
+		g = *a.ProviderGuard
+	}
+	if g.LLMBudgetMs == 0 {
+		g.LLMBudgetMs = DefaultLLMBudgetMs
+	}
+	if g.TTSBudgetMs == 0 {
+		g.TTSBudgetMs = DefaultTTSBudgetMs
+	}
+	if g.ApologyPrompt == "" {
+		g.ApologyPrompt = DefaultGuardApology
+	}
+	return g
+}
+
+// ProviderIncident 服务商每天在某一阶段的故障计数
+type ProviderIncident struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Day            string    `json:"day" gorm:"size:10;uniqueIndex:idx_provider_incident,priority:1;not null"` // 2006-01-02
+	Provider       string    `json:"provider" gorm:"size:64;uniqueIndex:idx_provider_incident,priority:2;not null"`
+	Stage          string    `json:"stage" gorm:"size:8;uniqueIndex:idx_provider_incident,priority:3;not null"` // llm, tts
+	Timeouts       int64     `json:"timeouts"`
+	Errors         int64     `json:"errors"`
+	Failovers      int64     `json:"failovers"` // 作为备用服务商成功接替的次数
+	LastError      string    `json:"lastError,omitempty" gorm:"size:512"`
+	LastIncidentAt time.Time `json:"lastIncidentAt"`
+}
+
+// TableName 指定表名
+func (ProviderIncident) TableName() string {
+	return "provider_incidents"
+}
+
+// RecordProviderIncident 为服务商在当天的计数加一
+func RecordProviderIncident(db *gorm.DB, provider, stage, kind, detail string, now time.Time) error {
+	column := "errors"
+	switch kind {
+	case ProviderIncidentTimeout:
+		column = "timeouts"
+	case ProviderIncidentFailover:
+		column = "failovers"
+	}
+	if len(detail) > providerIncidentError {
+		detail = detail[:providerIncidentError]
+	}
+	incident := &ProviderIncident{
+		Day:            now.Format("2006-01-02"),
+		Provider:       provider,
+		Stage:          stage,
+		LastIncidentAt: now,
+	}
+	updates := map[string]interface{}{
+		column:             gorm.Expr(column + " + 1"),
+		"last_incident_at": now,
+	}
+	switch column {
+	case "timeouts":
+		incident.Timeouts = 1
+	case "failovers":
+		incident.Failovers = 1
+	default:
+		incident.Errors = 1
+	}
+	if kind != ProviderIncidentFailover {
+		incident.LastError = detail
+		updates["last_error"] = detail
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}, {Name: "provider"}, {Name: "stage"}},
+		DoUpdates: clause.Assignments(updates),
+	}).Create(incident).Error
+}
+
+// ListProviderIncidents 列出 since 当天及以后的故障计数，按日期倒序
+func ListProviderIncidents(db *gorm.DB, since time.Time) ([]ProviderIncident, error) {
+	var incidents []ProviderIncident
+	err := db.Where("day >= ?", since.Format("2006-01-02")).
+		Order("day DESC").Order("provider").Order("stage").
+		Find(&incidents).Error
+	return incidents, err
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssistantGuardDefaults(t *testing.T) {
+	a := &Assistant{}
+	g := a.Guard()
+	assert.Equal(t, DefaultLLMBudgetMs, g.LLMBudgetMs)
+	assert.Equal(t, DefaultTTSBudgetMs, g.TTSBudgetMs)
+	assert.Equal(t, DefaultGuardApology, g.ApologyPrompt)
+	assert.Zero(t, g.FailoverCredentialID)
+
+	a.ProviderGuard = &ProviderGuard{LLMBudgetMs: 3000, FillerPrompt: "请稍等", FailoverCredentialID: 7}
+	g = a.Guard()
+	assert.Equal(t, 3000, g.LLMBudgetMs)
+	assert.Equal(t, DefaultTTSBudgetMs, g.TTSBudgetMs)
+	assert.Equal(t, "请稍等", g.FillerPrompt)
+	assert.Equal(t, uint(7), g.FailoverCredentialID)
+}
+
+func TestProviderGuardValidate(t *testing.T) {
+	assert.NoError(t, (&ProviderGuard{}).Validate())
+	assert.NoError(t, (&ProviderGuard{LLMBudgetMs: 1000, TTSBudgetMs: 30000}).Validate())
+	assert.ErrorIs(t, (&ProviderGuard{LLMBudgetMs: 500}).Validate(), ErrInvalidStageBudget)
+	assert.ErrorIs(t, (&ProviderGuard{TTSBudgetMs: 60000}).Validate(), ErrInvalidStageBudget)
+}
+
+func TestProviderGuardStoredOnAssistant(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Assistant{})
+	a := &Assistant{Name: "front desk", ProviderGuard: &ProviderGuard{TTSBudgetMs: 4000, ApologyPrompt: "系统繁忙"}}
+	require.NoError(t, db.Create(a).Error)
+
+	var got Assistant
+	require.NoError(t, db.First(&got, a.ID).Error)
+	require.NotNil(t, got.ProviderGuard)
+	assert.Equal(t, 4000, got.ProviderGuard.TTSBudgetMs)
+	assert.Equal(t, "系统繁忙", got.Guard().ApologyPrompt)
+}
+
+func TestRecordProviderIncident(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ProviderIncident{})
+	day := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+
+	require.NoError(t, RecordProviderIncident(db, "openai", ProviderStageLLM, ProviderIncidentTimeout, "no reply within 8s", day))
+	require.NoError(t, RecordProviderIncident(db, "openai", ProviderStageLLM, ProviderIncidentTimeout, "no reply within 8s", day.Add(time.Minute)))
+	require.NoError(t, RecordProviderIncident(db, "openai", ProviderStageLLM, ProviderIncidentError, "502 bad gateway", day.Add(2*time.Minute)))
+	require.NoError(t, RecordProviderIncident(db, "deepseek", ProviderStageLLM, ProviderIncidentFailover, "", day.Add(2*time.Minute)))
+	require.NoError(t, RecordProviderIncident(db, "openai", ProviderStageLLM, ProviderIncidentError, "old", day.AddDate(0, 0, -3)))
+
+	list, err := ListProviderIncidents(db, day.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "deepseek", list[0].Provider)
+	assert.Equal(t, int64(1), list[0].Failovers)
+	assert.Empty(t, list[0].LastError)
+
+	openai := list[1]
+	assert.Equal(t, "2026-03-02", openai.Day)
+	assert.Equal(t, int64(2), openai.Timeouts)
+	assert.Equal(t, int64(1), openai.Errors)
+	assert.Equal(t, "502 bad gateway", openai.LastError)
+
+	all, err := ListProviderIncidents(db, day.AddDate(0, 0, -7))
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxPushDeviceTokens 每个用户最多登记的推送设备，超出时淘汰最久未使用的
+const maxPushDeviceTokens = 10
+
+// PushDeviceToken 移动端 App 登记的推送令牌（FCM registration token 或 APNs device token）
+type PushDeviceToken struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID     uint      `json:"userId" gorm:"index;not null"`
+	Platform   string    `json:"platform" gorm:"size:16;not null"`       // android, ios
+	Token      string    `json:"-" gorm:"size:512;uniqueIndex;not null"` // 令牌不返回给客户端
+	DeviceName string    `json:"deviceName,omitempty" gorm:"size:128"`   // 手机型号或用户起的名称
+	AppVersion string    `json:"appVersion,omitempty" gorm:"size:32"`    // App 版本
+	LastUsedAt time.Time `json:"lastUsedAt" gorm:"index"`                // 最近登记或推送成功的时间
+	LastError  string    `json:"lastError,omitempty" gorm:"size:255"`    // 最近一次推送失败原因
+	TokenTail  string    `json:"tokenTail" gorm:"-"`                     // 令牌末尾，供用户区分设备
+}
+
+// TableName 指定表名
+func (PushDeviceToken) TableName() string {
+	return "push_device_tokens"
+}
+
+// AfterFind 填充令牌末尾
+func (t *PushDeviceToken) AfterFind(tx *gorm.DB) error {
+	if len(t.Token) > 6 {
+		t.TokenTail = t.Token[len(t.Token)-6:]
+	} else {
+		t.TokenTail = t.Token
+	}
+	return nil
+}
+
+// RegisterPushDeviceToken 登记推送令牌。同一令牌再次登记时更新归属和设备信息（手机换了登录账号），
+// 每个用户只保留最近使用的 maxPushDeviceTokens 个
+func RegisterPushDeviceToken(db *gorm.DB, userID uint, platform, token, deviceName, appVersion string) (*PushDeviceToken, error) {
+	token = strings.TrimSpace(token)
+	if token == "" || len(token) > 512 {
+		return nil, errors.New("invalid push token")
+	}
+	if platform != notification.PushPlatformAndroid && platform != notification.PushPlatformIOS {
+		return nil, fmt.Errorf("unsupported push platform: %s", platform)
+	}
+
+	var record PushDeviceToken
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Where("token = ?", token).First(&record).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			record = PushDeviceToken{UserID: userID, Platform: platform, Token: token}
+		case err != nil:
+			return err
+		}
+		record.UserID = userID
+		record.Platform = platform
+		record.DeviceName = truncateRunes(deviceName, 127)
+		record.AppVersion = truncateRunes(appVersion, 31)
+		record.LastUsedAt = now
+		record.LastError = ""
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+
+		var stale []uint
+		if err := tx.Model(&PushDeviceToken{}).Where("user_id = ?", userID).
+			Order("last_used_at DESC").Offset(maxPushDeviceTokens).Pluck("id", &stale).Error; err != nil {
+			return err
+		}
+		if len(stale) > 0 {
+			return tx.Where("id IN ?", stale).Delete(&PushDeviceToken{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	record.AfterFind(db)
+	return &record, nil
+}
+
+// ListPushDeviceTokens 用户登记的推送设备
+func ListPushDeviceTokens(db *gorm.DB, userID uint) ([]PushDeviceToken, error) {
+	var tokens []PushDeviceToken
+	err := db.Where("user_id = ?", userID).Order("last_used_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeletePushDeviceToken 注销推送设备，App 退出登录时按令牌注销，设备管理页面按ID注销
+func DeletePushDeviceToken(db *gorm.DB, userID uint, id uint, token string) (bool, error) {
+	q := db.Where("user_id = ?", userID)
+	if token != "" {
+		q = q.Where("token = ?", token)
+	} else {
+		q = q.Where("id = ?", id)
+	}
+	result := q.Delete(&PushDeviceToken{})
+	return result.RowsAffected > 0, result.Error
+}
+
+var (
+	pushMu     sync.Mutex
+	pushSender *notification.PushNotification
+)
+
+// pushNotifier 按配置创建推送发送器，创建成功后复用以缓存 FCM/APNs 的访问令牌
+func pushNotifier() (*notification.PushNotification, error) {
+	pushMu.Lock()
+	defer pushMu.Unlock()
+	if pushSender != nil {
+		return pushSender, nil
+	}
+	if config.GlobalConfig == nil {
+		return nil, errors.New("push notifications are not configured")
+	}
+	sender, err := notification.NewPushNotification(config.GlobalConfig.Services.Push)
+	if err != nil {
+		return nil, err
+	}
+	pushSender = sender
+	return sender, nil
+}
+
+// SetPushNotifier 替换推送发送器
+func SetPushNotifier(sender *notification.PushNotification) {
+	pushMu.Lock()
+	defer pushMu.Unlock()
+	pushSender = sender
+}
+
+// SendPushNotification 向用户登记的所有设备推送，失效的令牌会被删除。
+// 至少一台设备推送成功即视为成功，用户没有可推送的设备时返回错误
+func SendPushNotification(db *gorm.DB, userID uint, msg notification.PushMessage) error {
+	tokens, err := ListPushDeviceTokens(db, userID)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return errors.New("no push devices registered")
+	}
+	sender, err := pushNotifier()
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	delivered := 0
+	for i := range tokens {
+		t := &tokens[i]
+		err := sender.Send(context.Background(), t.Platform, t.Token, msg)
+		switch {
+		case err == nil:
+			delivered++
+			db.Model(t).Updates(map[string]any{"last_used_at": time.Now(), "last_error": ""})
+		case errors.Is(err, notification.ErrPushTokenInvalid):
+			logger.Info("Removing invalid push token", zap.Uint("userId", userID), zap.Uint("tokenId", t.ID))
+			db.Delete(t)
+			lastErr = err
+		default:
+			db.Model(t).Update("last_error", truncateRunes(err.Error(), 254))
+			lastErr = err
+		}
+	}
+	if delivered == 0 {
+		return lastErr
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePushSender struct {
+	sent    map[string]notification.PushMessage
+	invalid map[string]bool
+}
+
+func (f *fakePushSender) Send(_ context.Context, token string, msg notification.PushMessage) error {
+	if f.invalid[token] {
+		return notification.ErrPushTokenInvalid
+	}
+	f.sent[token] = msg
+	return nil
+}
+
+func useFakePushSender(t *testing.T) *fakePushSender {
+	fake := &fakePushSender{sent: map[string]notification.PushMessage{}, invalid: map[string]bool{}}
+	sender := &notification.PushNotification{}
+	sender.SetSender(notification.PushPlatformAndroid, fake)
+	sender.SetSender(notification.PushPlatformIOS, fake)
+	SetPushNotifier(sender)
+	t.Cleanup(func() { SetPushNotifier(nil) })
+	return fake
+}
+
+func TestRegisterPushDeviceToken(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &PushDeviceToken{})
+
+	d, err := RegisterPushDeviceToken(db, 1, notification.PushPlatformIOS, " apns-token-123456 ", "iPhone", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "123456", d.TokenTail)
+
+	_, err = RegisterPushDeviceToken(db, 1, "windows", "tok", "", "")
+	assert.Error(t, err)
+	_, err = RegisterPushDeviceToken(db, 1, notification.PushPlatformIOS, "  ", "", "")
+	assert.Error(t, err)
+
+	moved, err := RegisterPushDeviceToken(db, 2, notification.PushPlatformIOS, "apns-token-123456", "iPhone", "1.1")
+	require.NoError(t, err)
+	assert.Equal(t, d.ID, moved.ID, "a known token is moved to the new account")
+	list, err := ListPushDeviceTokens(db, 1)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	for i := 0; i < maxPushDeviceTokens+2; i++ {
+		_, err := RegisterPushDeviceToken(db, 3, notification.PushPlatformAndroid, "fcm-"+string(rune('a'+i)), "", "")
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	list, err = ListPushDeviceTokens(db, 3)
+	require.NoError(t, err)
+	require.Len(t, list, maxPushDeviceTokens)
+	assert.Equal(t, "fcm-l", list[0].Token, "the least recently used tokens are dropped")
+
+	deleted, err := DeletePushDeviceToken(db, 2, 0, "apns-token-123456")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = DeletePushDeviceToken(db, 2, list[0].ID, "")
+	require.NoError(t, err)
+	assert.False(t, deleted, "other users' devices cannot be removed")
+}
+
+func TestSendPushNotification(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &PushDeviceToken{}, &NotificationPreference{}, &notification.InternalNotification{})
+	fake := useFakePushSender(t)
+
+	assert.Error(t, SendPushNotification(db, 7, notification.PushMessage{Title: "t"}), "no registered devices")
+
+	_, err := RegisterPushDeviceToken(db, 7, notification.PushPlatformAndroid, "good", "", "")
+	require.NoError(t, err)
+	_, err = RegisterPushDeviceToken(db, 7, notification.PushPlatformIOS, "stale", "", "")
+	require.NoError(t, err)
+	fake.invalid["stale"] = true
+
+	user := &User{BaseModel: BaseModel{ID: 7}, PushNotifications: true}
+	notice, err := NoticeFromPushTemplate(NotificationEventCall, notification.PushTemplateMissedCall, map[string]string{"callId": "c1", "from": "1001", "time": "10:00"})
+	require.NoError(t, err)
+	notice.Channels = []NotificationChannel{NotificationChannelInternal, NotificationChannelPush}
+	results := DispatchNotification(db, user, notice)
+	assert.NoError(t, results[NotificationChannelPush])
+	assert.NoError(t, results[NotificationChannelInternal])
+
+	msg := fake.sent["good"]
+	assert.Equal(t, "Missed call", msg.Title)
+	assert.Equal(t, "1001 called at 10:00 and was not answered.", msg.Body)
+	assert.Equal(t, "c1", msg.Data["callId"])
+	assert.Equal(t, "call", msg.Data["event"])
+
+	list, err := ListPushDeviceTokens(db, 7)
+	require.NoError(t, err)
+	require.Len(t, list, 1, "invalid tokens are removed")
+
+	user.PushNotifications = false
+	fake.sent = map[string]notification.PushMessage{}
+	results = DispatchNotification(db, user, notice)
+	_, pushed := results[NotificationChannelPush]
+	assert.False(t, pushed, "push respects the user's push preference")
+	assert.Empty(t, fake.sent)
+}
+
+func TestMarkStaleDevicesOffline(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{})
+	now := time.Now()
+	old, recent := now.Add(-time.Hour), now.Add(-time.Minute)
+	require.NoError(t, db.Create(&[]Device{
+		{ID: "aa", MacAddress: "aa", UserID: 1, IsOnline: true, LastSeen: &old},
+		{ID: "bb", MacAddress: "bb", UserID: 1, IsOnline: true, LastSeen: &recent},
+		{ID: "cc", MacAddress: "cc", UserID: 1, IsOnline: false, LastSeen: &old},
+	}).Error)
+
+	devices, err := MarkStaleDevicesOffline(db, now.Add(-10*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "aa", devices[0].ID)
+
+	devices, err = MarkStaleDevicesOffline(db, now.Add(-10*time.Minute), 10)
+	require.NoError(t, err)
+	assert.Empty(t, devices, "reported once when going offline")
+}
+
+func TestClaimMissedCalls(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{})
+	now := time.Now()
+	ended := now.Add(-time.Minute)
+	longAgo := now.Add(-3 * time.Hour)
+	userID := uint(4)
+	require.NoError(t, db.Create(&[]SipCall{
+		{CallID: "missed", Direction: SipCallDirectionInbound, Status: SipCallStatusCancelled, UserID: &userID, EndTime: &ended},
+		{CallID: "answered", Direction: SipCallDirectionInbound, Status: SipCallStatusEnded, UserID: &userID, AnswerTime: &ended, EndTime: &ended},
+		{CallID: "outbound", Direction: SipCallDirectionOutbound, Status: SipCallStatusFailed, UserID: &userID, EndTime: &ended},
+		{CallID: "old", Direction: SipCallDirectionInbound, Status: SipCallStatusFailed, UserID: &userID, EndTime: &longAgo},
+		{CallID: "ringing", Direction: SipCallDirectionInbound, Status: SipCallStatusRinging, UserID: &userID},
+	}).Error)
+
+	calls, err := ClaimMissedCalls(db, now.Add(-time.Hour), now, 10)
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	assert.Equal(t, "missed", calls[0].CallID)
+
+	calls, err = ClaimMissedCalls(db, now.Add(-time.Hour), now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, calls, "each missed call is reported once")
+}
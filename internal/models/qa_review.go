@@ -0,0 +1,202 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 质检评分范围及标签数量限制
+const (
+	QAScoreMin      = 1
+	QAScoreMax      = 5
+	QAMaxTags       = 10
+	QALowScoreLimit = 2 // 平均分不高于该值的会话列入质检报告的低分会话
+)
+
+var (
+	ErrInvalidQAScore = errors.New("score must be between 1 and 5")
+	ErrTooManyQATags  = errors.New("at most 10 tags are allowed")
+)
+
+// QAReview 质检人员对一次会话（或其中一轮）的标注
+type QAReview struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time   `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time   `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID int64       `json:"assistantId" gorm:"index:idx_qa_review_session"`
+	SessionID   string      `json:"sessionId" gorm:"size:128;index:idx_qa_review_session"`
+	TurnIndex   *int        `json:"turnIndex,omitempty"` // 标注的对话轮次，为空表示整个会话
+	ReviewerID  uint        `json:"reviewerId" gorm:"index"`
+	Score       int         `json:"score"`
+	Tags        StringArray `json:"tags" gorm:"type:json"`
+	Comment     string      `json:"comment" gorm:"type:text"`
+}
+
+// TableName 指定表名
+func (QAReview) TableName() string {
+	return "qa_reviews"
+}
+
+// NormalizeQATags 标签统一为小写并去重
+func NormalizeQATags(tags []string) StringArray {
+	seen := make(map[string]bool)
+	normalized := StringArray{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// Validate 检查评分和标签并规范化标签
+func (r *QAReview) Validate() error {
+	if r.Score < QAScoreMin || r.Score > QAScoreMax {
+		return ErrInvalidQAScore
+	}
+	r.Tags = NormalizeQATags(r.Tags)
+	if len(r.Tags) > QAMaxTags {
+		return ErrTooManyQATags
+	}
+	return nil
+}
+
+// CreateQAReview 创建质检标注
+func CreateQAReview(db *gorm.DB, review *QAReview) error {
+	if err := review.Validate(); err != nil {
+		return err
+	}
+	return db.Create(review).Error
+}
+
+// ListQAReviews 获取会话的质检标注
+func ListQAReviews(db *gorm.DB, assistantID int64, sessionID string) ([]QAReview, error) {
+	var reviews []QAReview
+	err := db.Where("assistant_id = ? AND session_id = ?", assistantID, sessionID).Order("id").Find(&reviews).Error
+	return reviews, err
+}
+
+// GetQAReview 获取助手下的一条质检标注
+func GetQAReview(db *gorm.DB, assistantID int64, id uint) (*QAReview, error) {
+	var review QAReview
+	if err := db.Where("id = ? AND assistant_id = ?", id, assistantID).First(&review).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// QATagCount 标签出现次数
+type QATagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// AssistantQualityReport 助手在一段时间内的质检汇总
+type AssistantQualityReport struct {
+	AssistantID       int64         `json:"assistantId"`
+	Since             time.Time     `json:"since"`
+	SessionCount      int64         `json:"sessionCount"`     // 期间的会话数
+	TurnCount         int64         `json:"turnCount"`        // 期间的对话轮次
+	ReviewedSessions  int64         `json:"reviewedSessions"` // 有质检标注的会话数
+	ReviewCount       int64         `json:"reviewCount"`
+	AvgScore          float64       `json:"avgScore"`
+	ScoreDistribution map[int]int64 `json:"scoreDistribution"`
+	Tags              []QATagCount  `json:"tags"`
+	LowScoreSessions  []string      `json:"lowScoreSessions"`
+	AvgLatencyMs      int64         `json:"avgLatencyMs"` // LLM 平均耗时
+	ToolCallCount     int64         `json:"toolCallCount"`
+	ToolErrorCount    int64         `json:"toolErrorCount"`
+}
+
+// GetAssistantQualityReport 汇总质检标注和会话耗时，生成助手质量报告
+func GetAssistantQualityReport(db *gorm.DB, assistantID int64, since time.Time) (*AssistantQualityReport, error) {
+	report := &AssistantQualityReport{
+		AssistantID:       assistantID,
+		Since:             since,
+		ScoreDistribution: make(map[int]int64),
+		Tags:              []QATagCount{},
+		LowScoreSessions:  []string{},
+	}
+	for score := QAScoreMin; score <= QAScoreMax; score++ {
+		report.ScoreDistribution[score] = 0
+	}
+
+	var reviews []QAReview
+	if err := db.Where("assistant_id = ? AND created_at >= ?", assistantID, since).Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	tagCounts := make(map[string]int64)
+	sessionScores := make(map[string][]int)
+	var totalScore int64
+	for _, review := range reviews {
+		report.ScoreDistribution[review.Score]++
+		totalScore += int64(review.Score)
+		for _, tag := range review.Tags {
+			tagCounts[tag]++
+		}
+		sessionScores[review.SessionID] = append(sessionScores[review.SessionID], review.Score)
+	}
+	report.ReviewCount = int64(len(reviews))
+	report.ReviewedSessions = int64(len(sessionScores))
+	if report.ReviewCount > 0 {
+		report.AvgScore = float64(totalScore) / float64(report.ReviewCount)
+	}
+	for tag, count := range tagCounts {
+		report.Tags = append(report.Tags, QATagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(report.Tags, func(i, j int) bool {
+		if report.Tags[i].Count != report.Tags[j].Count {
+			return report.Tags[i].Count > report.Tags[j].Count
+		}
+		return report.Tags[i].Tag < report.Tags[j].Tag
+	})
+	for sessionID, scores := range sessionScores {
+		sum := 0
+		for _, s := range scores {
+			sum += s
+		}
+		if float64(sum)/float64(len(scores)) <= QALowScoreLimit {
+			report.LowScoreSessions = append(report.LowScoreSessions, sessionID)
+		}
+	}
+	sort.Strings(report.LowScoreSessions)
+
+	var logs []ChatSessionLog
+	if err := db.Select("session_id", "llm_usage").
+		Where("assistant_id = ? AND created_at >= ?", assistantID, since).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	sessions := make(map[string]bool)
+	var totalLatency, timedTurns int64
+	for _, log := range logs {
+		sessions[log.SessionID] = true
+		var usage LLMUsage
+		if log.LLMUsage == "" || json.Unmarshal([]byte(log.LLMUsage), &usage) != nil {
+			continue
+		}
+		if usage.Duration > 0 {
+			totalLatency += usage.Duration
+			timedTurns++
+		}
+		for _, call := range usage.ToolCalls {
+			report.ToolCallCount++
+			if call.Error != "" {
+				report.ToolErrorCount++
+			}
+		}
+	}
+	report.SessionCount = int64(len(sessions))
+	report.TurnCount = int64(len(logs))
+	if timedTurns > 0 {
+		report.AvgLatencyMs = totalLatency / timedTurns
+	}
+	return report, nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQAReview_Validate(t *testing.T) {
+	review := QAReview{Score: 3, Tags: StringArray{" Off-Topic", "off-topic", "", "slow"}}
+	require.NoError(t, review.Validate())
+	assert.Equal(t, StringArray{"off-topic", "slow"}, review.Tags)
+
+	review.Score = 6
+	assert.ErrorIs(t, review.Validate(), ErrInvalidQAScore)
+
+	tooMany := QAReview{Score: 1}
+	for i := 0; i <= QAMaxTags; i++ {
+		tooMany.Tags = append(tooMany.Tags, string(rune('a'+i)))
+	}
+	assert.ErrorIs(t, tooMany.Validate(), ErrTooManyQATags)
+}
+
+func TestGetAssistantQualityReport(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ChatSessionLog{}, &QAReview{})
+	since := time.Now().Add(-time.Hour)
+
+	for _, r := range []*QAReview{
+		{AssistantID: 1, SessionID: "s1", ReviewerID: 1, Score: 5, Tags: StringArray{"resolved"}},
+		{AssistantID: 1, SessionID: "s2", ReviewerID: 1, Score: 1, Tags: StringArray{"hallucination", "slow"}},
+		{AssistantID: 1, SessionID: "s2", ReviewerID: 2, Score: 2, Tags: StringArray{"slow"}},
+		{AssistantID: 2, SessionID: "s3", ReviewerID: 1, Score: 1},
+	} {
+		require.NoError(t, CreateQAReview(db, r))
+	}
+	failed := ToolCallInfo{Name: "crm", Error: "timeout"}
+	_, err := CreateChatSessionLogWithUsage(db, 1, 1, ChatTypeText, "s1", "a", "b", "", 1, &LLMUsage{Duration: 200})
+	require.NoError(t, err)
+	_, err = CreateChatSessionLogWithUsage(db, 1, 1, ChatTypeText, "s2", "a", "b", "", 1, &LLMUsage{Duration: 400, ToolCalls: []ToolCallInfo{failed}})
+	require.NoError(t, err)
+	_, err = CreateChatSessionLog(db, 1, 1, ChatTypeText, "s2", "a", "b", "", 1)
+	require.NoError(t, err)
+
+	report, err := GetAssistantQualityReport(db, 1, since)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), report.ReviewCount)
+	assert.Equal(t, int64(2), report.ReviewedSessions)
+	assert.InDelta(t, 8.0/3.0, report.AvgScore, 0.001)
+	assert.Equal(t, int64(1), report.ScoreDistribution[1])
+	assert.Equal(t, int64(0), report.ScoreDistribution[3])
+	assert.Equal(t, []QATagCount{{"slow", 2}, {"hallucination", 1}, {"resolved", 1}}, report.Tags)
+	assert.Equal(t, []string{"s2"}, report.LowScoreSessions)
+	assert.Equal(t, int64(2), report.SessionCount)
+	assert.Equal(t, int64(3), report.TurnCount)
+	assert.Equal(t, int64(300), report.AvgLatencyMs)
+	assert.Equal(t, int64(1), report.ToolCallCount)
+	assert.Equal(t, int64(1), report.ToolErrorCount)
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordingConsentMode 录音同意模式
+type RecordingConsentMode string
+
+const (
+	RecordingConsentOneParty RecordingConsentMode = "one_party" // 一方同意即可录音，不提示
+	RecordingConsentTwoParty RecordingConsentMode = "two_party" // 需播放提示并由对方按键确认
+)
+
+// RecordingConsentStatus 通话的录音同意结果
+type RecordingConsentStatus string
+
+const (
+	RecordingConsentNotRequired RecordingConsentStatus = "not_required" // 一方同意地区
+	RecordingConsentGranted     RecordingConsentStatus = "granted"      // 已按键同意
+	RecordingConsentDeclined    RecordingConsentStatus = "declined"     // 已按键拒绝
+	RecordingConsentTimeout     RecordingConsentStatus = "timeout"      // 未在时限内确认，视为拒绝
+	RecordingConsentUnavailable RecordingConsentStatus = "unavailable"  // 提示音无法播放，视为拒绝
+)
+
+// RecordingConsentPolicy 地区录音同意策略，按号码前缀匹配地区
+type RecordingConsentPolicy struct {
+	ID             uint                 `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time            `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time            `json:"updatedAt" gorm:"autoUpdateTime"`
+	Region         string               `json:"region" gorm:"size:32;uniqueIndex;not null"`     // 地区代码，如 US-CA、DE
+	Name           string               `json:"name" gorm:"size:128"`                           // 地区名称
+	NumberPrefixes string               `json:"numberPrefixes" gorm:"size:512;not null"`        // 号码前缀，逗号分隔，如 +1415,+1510
+	Mode           RecordingConsentMode `json:"mode" gorm:"size:16;not null;default:one_party"` // one_party, two_party
+	PromptFile     string               `json:"promptFile,omitempty" gorm:"size:256"`           // 提示音 WAV 文件（8kHz 16bit 单声道）
+	AcceptDigit    string               `json:"acceptDigit" gorm:"size:1;default:1"`            // 同意按键
+	DeclineDigit   string               `json:"declineDigit" gorm:"size:1;default:2"`           // 拒绝按键
+	TimeoutSeconds int                  `json:"timeoutSeconds" gorm:"default:10"`               // 等待按键时长
+	Enabled        bool                 `json:"enabled" gorm:"default:true;index"`
+}
+
+// TableName 指定表名
+func (RecordingConsentPolicy) TableName() string {
+	return "recording_consent_policies"
+}
+
+// Prefixes 返回规范化后的号码前缀
+func (p *RecordingConsentPolicy) Prefixes() []string {
+	var prefixes []string
+	for _, prefix := range strings.Split(p.NumberPrefixes, ",") {
+		if prefix = normalizeConsentNumber(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// MatchRecordingConsentPolicy 为通话双方号码查找录音同意策略。
+// 每个号码取最长前缀匹配的策略，双方中只要有一方处于双方同意地区即采用该策略；
+// 没有匹配时返回 nil，按一方同意处理。
+func MatchRecordingConsentPolicy(db *gorm.DB, numbers ...string) (*RecordingConsentPolicy, error) {
+	var policies []RecordingConsentPolicy
+	if err := db.Where("enabled = ?", true).Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	var matched *RecordingConsentPolicy
+	for _, number := range numbers {
+		number = normalizeConsentNumber(number)
+		if number == "" {
+			continue
+		}
+		var best *RecordingConsentPolicy
+		bestLen := 0
+		for i := range policies {
+			for _, prefix := range policies[i].Prefixes() {
+				if strings.HasPrefix(number, prefix) && len(prefix) > bestLen {
+					best, bestLen = &policies[i], len(prefix)
+				}
+			}
+		}
+		if best == nil {
+			continue
+		}
+		if matched == nil || (matched.Mode != RecordingConsentTwoParty && best.Mode == RecordingConsentTwoParty) {
+			matched = best
+		}
+	}
+	return matched, nil
+}
+
+// normalizeConsentNumber 去掉号码中的空格和分隔符，00 国际前缀统一为 +
+func normalizeConsentNumber(number string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	n := b.String()
+	if strings.HasPrefix(n, "00") {
+		n = "+" + n[2:]
+	}
+	return n
+}
+
+// SaveSipCallConsent 将录音同意结果记录到通话
+func SaveSipCallConsent(db *gorm.DB, callID, region string, status RecordingConsentStatus, digit string) error {
+	now := time.Now()
+	return db.Model(&SipCall{}).Where("call_id = ?", callID).Updates(map[string]interface{}{
+		"consent_region": region,
+		"consent_status": status,
+		"consent_digit":  digit,
+		"consent_at":     &now,
+	}).Error
+}
+
+// SipCallRecordingAllowed 通话是否允许录音，没有同意记录或为一方同意时允许
+func SipCallRecordingAllowed(db *gorm.DB, callID string) bool {
+	var call SipCall
+	if err := db.Select("consent_status").Where("call_id = ?", callID).First(&call).Error; err != nil {
+		return true
+	}
+	switch call.ConsentStatus {
+	case "", RecordingConsentNotRequired, RecordingConsentGranted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchRecordingConsentPolicy(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &RecordingConsentPolicy{})
+	require.NoError(t, db.Create(&RecordingConsentPolicy{Region: "US", NumberPrefixes: "+1", Mode: RecordingConsentOneParty, Enabled: true}).Error)
+	require.NoError(t, db.Create(&RecordingConsentPolicy{Region: "US-CA", NumberPrefixes: "+1415, +1510", Mode: RecordingConsentTwoParty, Enabled: true}).Error)
+	require.NoError(t, db.Create(&RecordingConsentPolicy{Region: "DE", NumberPrefixes: "+49", Mode: RecordingConsentTwoParty, Enabled: true}).Error)
+	require.NoError(t, db.Model(&RecordingConsentPolicy{}).Where("region = ?", "DE").Update("enabled", false).Error)
+
+	policy, err := MatchRecordingConsentPolicy(db, "+1 (212) 555-0100")
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+	assert.Equal(t, "US", policy.Region)
+
+	policy, err = MatchRecordingConsentPolicy(db, "0014155550100")
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+	assert.Equal(t, "US-CA", policy.Region, "longest prefix wins")
+
+	policy, err = MatchRecordingConsentPolicy(db, "+12125550100", "+15105550100")
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+	assert.Equal(t, RecordingConsentTwoParty, policy.Mode, "strictest party wins")
+
+	policy, err = MatchRecordingConsentPolicy(db, "+4930123456", "1001")
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+}
+
+func TestSipCallRecordingAllowed(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{})
+	require.NoError(t, db.Create(&SipCall{CallID: "a"}).Error)
+	require.NoError(t, db.Create(&SipCall{CallID: "b"}).Error)
+
+	assert.True(t, SipCallRecordingAllowed(db, "a"))
+	assert.True(t, SipCallRecordingAllowed(db, "missing"))
+
+	require.NoError(t, SaveSipCallConsent(db, "a", "US-CA", RecordingConsentGranted, "1"))
+	require.NoError(t, SaveSipCallConsent(db, "b", "US-CA", RecordingConsentTimeout, ""))
+	assert.True(t, SipCallRecordingAllowed(db, "a"))
+	assert.False(t, SipCallRecordingAllowed(db, "b"))
+
+	call, err := GetSipCallByCallID(db, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "US-CA", call.ConsentRegion)
+	assert.Equal(t, "1", call.ConsentDigit)
+	assert.NotNil(t, call.ConsentAt)
+}
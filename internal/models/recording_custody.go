@@ -0,0 +1,220 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"gorm.io/gorm"
+)
+
+const (
+	// RecordingDigestDateLayout 每日摘要的日期格式
+	RecordingDigestDateLayout = "2006-01-02"
+	// RecordingDigestAnchorEvent 发送给公证服务的事件名
+	RecordingDigestAnchorEvent = "recording.digest.anchor"
+)
+
+// ErrRecordingNotHashed 录音定稿时未计算哈希（如历史数据）
+var ErrRecordingNotHashed = errors.New("recording has no stored hash")
+
+// RecordingDigest 每日录音哈希摘要
+// 摘要 = SHA-256(前一日摘要 + 当日每条录音的 "ID:哈希")，形成哈希链，
+// 可锚定到外部公证服务，证明当日之后录音和哈希都未被改动
+type RecordingDigest struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	Date           string     `json:"date" gorm:"size:10;uniqueIndex"`
+	RecordingCount int        `json:"recordingCount"`
+	Digest         string     `json:"digest" gorm:"size:64"`
+	PrevDigest     string     `json:"prevDigest" gorm:"size:64"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	AnchoredAt     *time.Time `json:"anchoredAt,omitempty"`                     // 锚定到公证服务的时间
+	AnchorReceipt  string     `json:"anchorReceipt,omitempty" gorm:"type:text"` // 公证服务返回的凭证
+	AnchorError    string     `json:"anchorError,omitempty" gorm:"type:text"`
+}
+
+func (RecordingDigest) TableName() string {
+	return "recording_digests"
+}
+
+// RecordingHashCheck 录音哈希校验结果
+type RecordingHashCheck struct {
+	RecordingID uint             `json:"recordingId"`
+	StoredHash  string           `json:"storedHash"`
+	CurrentHash string           `json:"currentHash"`
+	Size        int64            `json:"size"`
+	Match       bool             `json:"match"`
+	HashedAt    *time.Time       `json:"hashedAt"`
+	CheckedAt   time.Time        `json:"checkedAt"`
+	Digest      *RecordingDigest `json:"digest,omitempty"` // 包含该录音的每日摘要
+}
+
+// HashRecordingAudio 计算录音内容的 SHA-256
+func HashRecordingAudio(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// SetCallRecordingHash 保存录音定稿时的哈希
+func SetCallRecordingHash(db *gorm.DB, recordingID uint, hash string) error {
+	now := time.Now()
+	return db.Model(&CallRecording{}).Where("id = ?", recordingID).Updates(map[string]interface{}{
+		"audio_sha256": hash,
+		"hashed_at":    now,
+	}).Error
+}
+
+// CheckCallRecordingHash 对比当前录音内容与定稿时的哈希
+func CheckCallRecordingHash(db *gorm.DB, recording *CallRecording, current io.Reader) (*RecordingHashCheck, error) {
+	if recording.AudioSHA256 == "" {
+		return nil, ErrRecordingNotHashed
+	}
+	hash, size, err := HashRecordingAudio(current)
+	if err != nil {
+		return nil, fmt.Errorf("read recording audio: %w", err)
+	}
+	check := &RecordingHashCheck{
+		RecordingID: recording.ID,
+		StoredHash:  recording.AudioSHA256,
+		CurrentHash: hash,
+		Size:        size,
+		Match:       hash == recording.AudioSHA256,
+		HashedAt:    recording.HashedAt,
+		CheckedAt:   time.Now(),
+	}
+	if recording.HashedAt != nil {
+		var digest RecordingDigest
+		err := db.Where("date = ?", recording.HashedAt.In(time.Local).Format(RecordingDigestDateLayout)).First(&digest).Error
+		if err == nil {
+			check.Digest = &digest
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return check, nil
+}
+
+// ComputeRecordingDigest 计算某日的录音摘要，不落库
+func ComputeRecordingDigest(db *gorm.DB, date string) (*RecordingDigest, error) {
+	day, err := time.ParseInLocation(RecordingDigestDateLayout, date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest date %q", date)
+	}
+
+	var prev RecordingDigest
+	err = db.Where("date < ?", date).Order("date DESC").First(&prev).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var recordings []CallRecording
+	if err := db.Select("id", "audio_sha256").
+		Where("hashed_at >= ? AND hashed_at < ? AND audio_sha256 <> ''", day, day.AddDate(0, 0, 1)).
+		Order("id ASC").Find(&recordings).Error; err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	io.WriteString(h, prev.Digest+"\n")
+	for _, recording := range recordings {
+		fmt.Fprintf(h, "%d:%s\n", recording.ID, recording.AudioSHA256)
+	}
+	return &RecordingDigest{
+		Date:           date,
+		RecordingCount: len(recordings),
+		Digest:         hex.EncodeToString(h.Sum(nil)),
+		PrevDigest:     prev.Digest,
+	}, nil
+}
+
+// CreateRecordingDigest 生成并保存某日的录音摘要，已存在时直接返回，不覆盖
+func CreateRecordingDigest(db *gorm.DB, date string) (*RecordingDigest, error) {
+	existing, err := GetRecordingDigest(db, date)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	digest, err := ComputeRecordingDigest(db, date)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Create(digest).Error; err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// GetRecordingDigest 获取某日的录音摘要
+func GetRecordingDigest(db *gorm.DB, date string) (*RecordingDigest, error) {
+	var digest RecordingDigest
+	if err := db.Where("date = ?", date).First(&digest).Error; err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+// ListRecordingDigests 分页获取录音摘要，最新的在前
+func ListRecordingDigests(db *gorm.DB, limit, offset int) ([]RecordingDigest, int64, error) {
+	var digests []RecordingDigest
+	var total int64
+	if err := db.Model(&RecordingDigest{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("date DESC").Limit(limit).Offset(offset).Find(&digests).Error
+	return digests, total, err
+}
+
+// GetUnanchoredRecordingDigests 获取尚未锚定的摘要
+func GetUnanchoredRecordingDigests(db *gorm.DB) ([]RecordingDigest, error) {
+	var digests []RecordingDigest
+	err := db.Where("anchored_at IS NULL").Order("date ASC").Find(&digests).Error
+	return digests, err
+}
+
+// MarkRecordingDigestAnchored 记录锚定结果，anchorErr 不为空时只记录错误
+func MarkRecordingDigestAnchored(db *gorm.DB, id uint, receipt string, anchorErr error) error {
+	updates := map[string]interface{}{"anchor_error": ""}
+	if anchorErr != nil {
+		updates["anchor_error"] = anchorErr.Error()
+	} else {
+		updates["anchored_at"] = time.Now()
+		updates["anchor_receipt"] = receipt
+	}
+	return db.Model(&RecordingDigest{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// AnchorRecordingDigest 将摘要提交到外部公证服务，响应体作为凭证保存
+func AnchorRecordingDigest(ctx context.Context, db *gorm.DB, digest *RecordingDigest, notaryURL, secret string) error {
+	result, err := webhook.Deliver(ctx, webhook.Request{
+		URL:    notaryURL,
+		Event:  RecordingDigestAnchorEvent,
+		Secret: secret,
+		Payload: map[string]any{
+			"date":           digest.Date,
+			"algorithm":      "sha256",
+			"digest":         digest.Digest,
+			"prevDigest":     digest.PrevDigest,
+			"recordingCount": digest.RecordingCount,
+		},
+		MaxAttempts: 3,
+	})
+	var receipt string
+	if result != nil {
+		receipt = string(result.Body)
+	}
+	if markErr := MarkRecordingDigestAnchored(db, digest.ID, receipt, err); markErr != nil {
+		return markErr
+	}
+	return err
+}
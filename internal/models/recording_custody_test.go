@@ -0,0 +1,115 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCallRecordingHash(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallRecording{}, &RecordingDigest{})
+
+	recording := CallRecording{UserID: 1, AssistantID: 1}
+	require.NoError(t, db.Create(&recording).Error)
+
+	_, err := CheckCallRecordingHash(db, &recording, strings.NewReader("audio"))
+	assert.ErrorIs(t, err, ErrRecordingNotHashed)
+
+	hash, size, err := HashRecordingAudio(strings.NewReader("audio"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), size)
+	assert.Len(t, hash, 64)
+	require.NoError(t, SetCallRecordingHash(db, recording.ID, hash))
+	require.NoError(t, db.First(&recording, recording.ID).Error)
+	assert.Equal(t, hash, recording.AudioSHA256)
+	require.NotNil(t, recording.HashedAt)
+
+	check, err := CheckCallRecordingHash(db, &recording, strings.NewReader("audio"))
+	require.NoError(t, err)
+	assert.True(t, check.Match)
+	assert.Nil(t, check.Digest)
+
+	check, err = CheckCallRecordingHash(db, &recording, strings.NewReader("tampered"))
+	require.NoError(t, err)
+	assert.False(t, check.Match)
+	assert.NotEqual(t, check.StoredHash, check.CurrentHash)
+
+	digest, err := CreateRecordingDigest(db, time.Now().Format(RecordingDigestDateLayout))
+	require.NoError(t, err)
+	check, err = CheckCallRecordingHash(db, &recording, strings.NewReader("audio"))
+	require.NoError(t, err)
+	require.NotNil(t, check.Digest)
+	assert.Equal(t, digest.Digest, check.Digest.Digest)
+}
+
+func TestRecordingDigestChain(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallRecording{}, &RecordingDigest{})
+
+	today := time.Now()
+	yesterday := today.AddDate(0, 0, -1)
+	for _, at := range []time.Time{yesterday, today, today} {
+		hashedAt := at
+		require.NoError(t, db.Create(&CallRecording{UserID: 1, AssistantID: 1, AudioSHA256: strings.Repeat("a", 64), HashedAt: &hashedAt}).Error)
+	}
+
+	first, err := CreateRecordingDigest(db, yesterday.Format(RecordingDigestDateLayout))
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.RecordingCount)
+	assert.Empty(t, first.PrevDigest)
+
+	second, err := CreateRecordingDigest(db, today.Format(RecordingDigestDateLayout))
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.RecordingCount)
+	assert.Equal(t, first.Digest, second.PrevDigest)
+
+	// Existing digests are not overwritten
+	again, err := CreateRecordingDigest(db, today.Format(RecordingDigestDateLayout))
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, again.ID)
+
+	// Altering a stored hash breaks the recomputed digest
+	require.NoError(t, db.Model(&CallRecording{}).Where("id = ?", 2).Update("audio_sha256", strings.Repeat("b", 64)).Error)
+	current, err := ComputeRecordingDigest(db, second.Date)
+	require.NoError(t, err)
+	assert.NotEqual(t, second.Digest, current.Digest)
+
+	_, err = ComputeRecordingDigest(db, "not-a-date")
+	assert.Error(t, err)
+}
+
+func TestAnchorRecordingDigest(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallRecording{}, &RecordingDigest{})
+
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, RecordingDigestAnchorEvent, r.Header.Get("X-Webhook-Event"))
+		assert.NotEmpty(t, r.Header.Get("X-Webhook-Signature"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"receipt":"r-1"}`))
+	}))
+	defer server.Close()
+
+	digest, err := CreateRecordingDigest(db, "2026-01-01")
+	require.NoError(t, err)
+	pending, err := GetUnanchoredRecordingDigests(db)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	require.NoError(t, AnchorRecordingDigest(context.Background(), db, digest, server.URL, "secret"))
+	assert.Equal(t, digest.Digest, received["digest"])
+
+	stored, err := GetRecordingDigest(db, "2026-01-01")
+	require.NoError(t, err)
+	require.NotNil(t, stored.AnchoredAt)
+	assert.Equal(t, `{"receipt":"r-1"}`, stored.AnchorReceipt)
+	pending, err = GetUnanchoredRecordingDigests(db)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRegionCode = errors.New("region code must be 1-32 lowercase letters, digits or dashes")
+	ErrRegionNotFound    = errors.New("region not found or disabled")
+)
+
+var regionCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Region 部署地区，设备和 SIP 用户可固定到某个地区，由该地区的信令和媒体节点提供服务
+type Region struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	Code         string    `json:"code" gorm:"size:32;uniqueIndex"` // 地区代码，与实例的 REGION 配置一致，如 cn-east
+	Name         string    `json:"name" gorm:"size:128"`
+	SIPHost      string    `json:"sipHost" gorm:"size:255"`      // 地区 SIP 信令地址
+	SIPPort      int       `json:"sipPort"`                      // 默认 5060
+	RTPHost      string    `json:"rtpHost" gorm:"size:255"`      // 地区媒体地址，在 SDP 中通告，为空时使用信令地址
+	WebsocketURL string    `json:"websocketUrl" gorm:"size:500"` // 设备语音 WebSocket 地址，为空时使用全局配置
+	MQTTGateway  string    `json:"mqttGateway" gorm:"size:255"`  // 设备 MQTT 网关，为空时使用全局配置
+	Enabled      bool      `json:"enabled"`
+}
+
+// TableName 指定表名
+func (Region) TableName() string {
+	return "regions"
+}
+
+// Validate 检查地区代码和信令地址
+func (r *Region) Validate() error {
+	if !regionCodePattern.MatchString(r.Code) {
+		return ErrInvalidRegionCode
+	}
+	if r.SIPHost == "" {
+		return fmt.Errorf("sipHost is required")
+	}
+	if r.SIPPort == 0 {
+		r.SIPPort = 5060
+	}
+	if r.SIPPort < 1 || r.SIPPort > 65535 {
+		return fmt.Errorf("invalid sipPort %d", r.SIPPort)
+	}
+	return nil
+}
+
+// SIPAddress 地区的 SIP 信令地址 host:port
+func (r *Region) SIPAddress() string {
+	return net.JoinHostPort(r.SIPHost, strconv.Itoa(r.SIPPort))
+}
+
+// MediaHost 地区在 SDP 中通告的媒体地址
+func (r *Region) MediaHost() string {
+	if r.RTPHost != "" {
+		return r.RTPHost
+	}
+	return r.SIPHost
+}
+
+// RegionEndpoints 下发给设备的地区端点
+type RegionEndpoints struct {
+	Code    string `json:"code"`
+	SIP     string `json:"sip"`
+	RTPHost string `json:"rtp_host"`
+}
+
+// Endpoints 地区端点配置
+func (r *Region) Endpoints() *RegionEndpoints {
+	return &RegionEndpoints{Code: r.Code, SIP: r.SIPAddress(), RTPHost: r.MediaHost()}
+}
+
+// LocalRegion 当前实例所在地区，未配置时为空，表示单地区部署
+func LocalRegion() string {
+	if config.GlobalConfig == nil {
+		return ""
+	}
+	return config.GlobalConfig.Server.Region
+}
+
+// IsRemoteRegion 判断固定地区是否由其他地区的实例服务，单地区部署或未固定时为 false
+func IsRemoteRegion(region string) bool {
+	local := LocalRegion()
+	return region != "" && local != "" && region != local
+}
+
+// GetRegion 获取启用的地区
+func GetRegion(db *gorm.DB, code string) (*Region, error) {
+	var region Region
+	if err := db.Where("code = ? AND enabled = ?", code, true).First(&region).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRegionNotFound
+		}
+		return nil, err
+	}
+	return &region, nil
+}
+
+// ListRegions 获取地区列表，enabledOnly 时只返回启用的地区
+func ListRegions(db *gorm.DB, enabledOnly bool) ([]Region, error) {
+	var regions []Region
+	query := db.Order("code")
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+	err := query.Find(&regions).Error
+	return regions, err
+}
+
+// ValidateRegionPin 检查要固定的地区，空字符串表示取消固定
+func ValidateRegionPin(db *gorm.DB, code string) error {
+	if code == "" {
+		return nil
+	}
+	_, err := GetRegion(db, code)
+	return err
+}
+
+// PinnedRegion 获取设备或 SIP 用户固定的地区，未固定或地区已停用时返回 nil
+func PinnedRegion(db *gorm.DB, code string) *Region {
+	if code == "" {
+		return nil
+	}
+	region, err := GetRegion(db, code)
+	if err != nil {
+		return nil
+	}
+	return region
+}
+
+// RegionCallStat 按地区统计的通话
+type RegionCallStat struct {
+	Region     string `json:"region"`
+	PeerRegion string `json:"peerRegion"` // 跨地区转交时被叫所在地区
+	Calls      int64  `json:"calls"`
+	Duration   int64  `json:"duration"` // 通话总时长（秒）
+}
+
+// GetRegionCallStats 统计一段时间内各地区处理的通话，userID 为空时统计全部
+func GetRegionCallStats(db *gorm.DB, userID *uint, since time.Time) ([]RegionCallStat, error) {
+	var stats []RegionCallStat
+	query := db.Model(&SipCall{}).
+		Select("region, peer_region, COUNT(*) AS calls, COALESCE(SUM(duration), 0) AS duration").
+		Where("start_time >= ?", since)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	err := query.Group("region, peer_region").Order("region, peer_region").Scan(&stats).Error
+	return stats, err
+}
+
+// RegionPinCount 固定在地区的设备和 SIP 用户数量
+func RegionPinCount(db *gorm.DB, code string) int64 {
+	var devices, sipUsers int64
+	db.Model(&Device{}).Where("region = ?", code).Count(&devices)
+	db.Model(&SipUser{}).Where("region = ?", code).Count(&sipUsers)
+	return devices + sipUsers
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegion_Validate(t *testing.T) {
+	region := Region{Code: "cn-east", SIPHost: "sip.east.example.com"}
+	require.NoError(t, region.Validate())
+	assert.Equal(t, 5060, region.SIPPort)
+	assert.Equal(t, "sip.east.example.com:5060", region.SIPAddress())
+	assert.Equal(t, "sip.east.example.com", region.MediaHost())
+
+	region.RTPHost = "203.0.113.7"
+	assert.Equal(t, &RegionEndpoints{Code: "cn-east", SIP: "sip.east.example.com:5060", RTPHost: "203.0.113.7"}, region.Endpoints())
+
+	assert.ErrorIs(t, (&Region{Code: "CN East", SIPHost: "h"}).Validate(), ErrInvalidRegionCode)
+	assert.Error(t, (&Region{Code: "eu"}).Validate())
+	assert.Error(t, (&Region{Code: "eu", SIPHost: "h", SIPPort: 70000}).Validate())
+}
+
+func TestIsRemoteRegion(t *testing.T) {
+	previous := config.GlobalConfig
+	t.Cleanup(func() { config.GlobalConfig = previous })
+
+	config.GlobalConfig = &config.Config{}
+	assert.False(t, IsRemoteRegion("eu-west"), "single-region deployments never hand off")
+
+	config.GlobalConfig.Server.Region = "cn-east"
+	assert.Equal(t, "cn-east", LocalRegion())
+	assert.True(t, IsRemoteRegion("eu-west"))
+	assert.False(t, IsRemoteRegion("cn-east"))
+	assert.False(t, IsRemoteRegion(""))
+}
+
+func TestRegionPinning(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Region{}, &Device{}, &SipUser{}, &SipCall{})
+	require.NoError(t, db.Create(&Region{Code: "cn-east", SIPHost: "east", SIPPort: 5060, Enabled: true}).Error)
+	require.NoError(t, db.Create(&Region{Code: "eu-west", SIPHost: "west", SIPPort: 5060}).Error)
+
+	assert.NoError(t, ValidateRegionPin(db, ""))
+	assert.NoError(t, ValidateRegionPin(db, "cn-east"))
+	assert.ErrorIs(t, ValidateRegionPin(db, "eu-west"), ErrRegionNotFound, "disabled regions cannot be pinned")
+	assert.Nil(t, PinnedRegion(db, "eu-west"))
+	require.NotNil(t, PinnedRegion(db, "cn-east"))
+
+	regions, err := ListRegions(db, true)
+	require.NoError(t, err)
+	assert.Len(t, regions, 1)
+	regions, err = ListRegions(db, false)
+	require.NoError(t, err)
+	assert.Len(t, regions, 2)
+
+	require.NoError(t, db.Create(&Device{ID: "aa:bb", MacAddress: "aa:bb", Region: "cn-east"}).Error)
+	require.NoError(t, db.Create(&SipUser{SchemeName: "desk", Username: "1001", Region: "cn-east"}).Error)
+	assert.Equal(t, int64(2), RegionPinCount(db, "cn-east"))
+	assert.Equal(t, int64(0), RegionPinCount(db, "eu-west"))
+}
+
+func TestGetRegionCallStats(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCall{})
+	now := time.Now()
+	userID := uint(1)
+	for _, call := range []*SipCall{
+		{CallID: "1", Region: "cn-east", Duration: 30, StartTime: now, UserID: &userID},
+		{CallID: "2", Region: "cn-east", Duration: 20, StartTime: now, UserID: &userID},
+		{CallID: "3", Region: "cn-east", PeerRegion: "eu-west", StartTime: now, UserID: &userID},
+		{CallID: "4", Region: "eu-west", Duration: 10, StartTime: now},
+		{CallID: "5", Region: "cn-east", Duration: 99, StartTime: now.AddDate(0, 0, -30)},
+	} {
+		require.NoError(t, db.Create(call).Error)
+	}
+
+	stats, err := GetRegionCallStats(db, nil, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []RegionCallStat{
+		{Region: "cn-east", Calls: 2, Duration: 50},
+		{Region: "cn-east", PeerRegion: "eu-west", Calls: 1},
+		{Region: "eu-west", Calls: 1, Duration: 10},
+	}, stats)
+
+	mine, err := GetRegionCallStats(db, &userID, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, mine, 2)
+}
@@ -0,0 +1,378 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/saml"
+	"gorm.io/gorm"
+)
+
+// SamlUserSource 通过 SAML JIT 创建的用户来源
+const SamlUserSource = "saml"
+
+// SSO 审计事件
+const (
+	SSOEventLoginStarted    = "login_started"
+	SSOEventLoginSucceeded  = "login_succeeded"
+	SSOEventLoginFailed     = "login_failed"
+	SSOEventUserProvisioned = "user_provisioned"
+	SSOEventPasswordBlocked = "password_login_blocked"
+	SSOEventConfigUpdated   = "config_updated"
+	SSOEventConfigDeleted   = "config_deleted"
+	SSOEventDomainsUpdated  = "domains_updated"
+	SSOEventTechnicianToken = "technician_token_issued"
+)
+
+const samlDomainsMaxLen = 512
+
+var (
+	// ErrSamlDomainNotAllowed 断言中的邮箱不属于该组织已认领的域名
+	ErrSamlDomainNotAllowed = errors.New("email domain is not claimed by this organization")
+	// ErrSamlNotProvisioned 用户不存在且未开启 JIT
+	ErrSamlNotProvisioned = errors.New("user is not provisioned and just-in-time provisioning is disabled")
+	// ErrSamlAdminNotAllowed 系统管理员不能通过组织 SSO 登录
+	ErrSamlAdminNotAllowed = errors.New("administrators cannot sign in through organization SSO")
+)
+
+// SamlAttributeMapping 用户字段到断言属性名（Name 或 FriendlyName）的映射
+type SamlAttributeMapping map[string]string
+
+// DefaultSamlAttributeMapping 默认映射；email 缺失时使用邮箱格式的 NameID
+var DefaultSamlAttributeMapping = SamlAttributeMapping{
+	"email":       "email",
+	"displayName": "displayName",
+	"firstName":   "firstName",
+	"lastName":    "lastName",
+}
+
+// Validate 检查映射的用户字段
+func (m SamlAttributeMapping) Validate() error {
+	for field, attr := range m {
+		if _, ok := mappableUserFields[field]; !ok {
+			return fmt.Errorf("unsupported user field: %s", field)
+		}
+		if strings.TrimSpace(attr) == "" {
+			return fmt.Errorf("empty attribute name for %s", field)
+		}
+	}
+	return nil
+}
+
+// SamlConnection 组织的 SAML 单点登录配置。域名由系统管理员认领，
+// 只有邮箱属于这些域名的用户可以通过该组织的 IdP 登录，开启强制 SSO 后这些域名的用户不能使用密码登录
+type SamlConnection struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt        time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID          uint      `json:"groupId" gorm:"uniqueIndex"`
+	Enabled          bool      `json:"enabled"`
+	IdPEntityID      string    `json:"idpEntityId" gorm:"size:512"`
+	IdPSSOURL        string    `json:"idpSsoUrl" gorm:"size:1024"`
+	IdPCertificate   string    `json:"idpCertificate" gorm:"type:text"` // PEM，可包含多个证书用于轮换
+	Domains          string    `json:"domains" gorm:"size:512"`         // 逗号分隔的邮箱域名
+	AttributeMapping string    `json:"-" gorm:"type:text"`
+	JITProvisioning  bool      `json:"jitProvisioning"`
+	EnforceSSO       bool      `json:"enforceSso"`
+	TechnicianPortal bool      `json:"technicianPortal"` // 允许成员通过 SSO 登录设备认领门户，获得只能认领和诊断设备的令牌
+	UpdatedBy        uint      `json:"updatedBy"`
+}
+
+// TableName 指定表名
+func (SamlConnection) TableName() string {
+	return "saml_connections"
+}
+
+// SSOEvent SSO 审计日志
+type SSOEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+	GroupID   uint      `json:"groupId" gorm:"index"`
+	UserID    uint      `json:"userId" gorm:"index"`
+	Email     string    `json:"email" gorm:"size:128;index"`
+	Event     string    `json:"event" gorm:"size:32;index"`
+	Success   bool      `json:"success"`
+	IPAddress string    `json:"ipAddress" gorm:"size:128"`
+	UserAgent string    `json:"userAgent" gorm:"size:512"`
+	Detail    string    `json:"detail" gorm:"size:1024"`
+}
+
+// TableName 指定表名
+func (SSOEvent) TableName() string {
+	return "sso_events"
+}
+
+// RecordSSOEvent 记录 SSO 审计事件
+func RecordSSOEvent(db *gorm.DB, event *SSOEvent) error {
+	if len(event.Detail) > 1024 {
+		event.Detail = event.Detail[:1024]
+	}
+	if len(event.UserAgent) > 512 {
+		event.UserAgent = event.UserAgent[:512]
+	}
+	return db.Create(event).Error
+}
+
+// DomainList 已认领的域名
+func (s *SamlConnection) DomainList() []string {
+	return splitCSV(s.Domains)
+}
+
+// SetDomains 规范化并设置域名
+func (s *SamlConnection) SetDomains(domains []string) error {
+	seen := map[string]bool{}
+	var out []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d == "" || seen[d] {
+			continue
+		}
+		if !strings.Contains(d, ".") || strings.ContainsAny(d, " ,@/") {
+			return fmt.Errorf("invalid domain: %s", d)
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	joined := strings.Join(out, ",")
+	if len(joined) > samlDomainsMaxLen {
+		return errors.New("too many domains")
+	}
+	s.Domains = joined
+	return nil
+}
+
+// OwnsEmail 邮箱是否属于已认领的域名
+func (s *SamlConnection) OwnsEmail(email string) bool {
+	_, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok {
+		return false
+	}
+	for _, d := range s.DomainList() {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// Mapping 生效的属性映射
+func (s *SamlConnection) Mapping() SamlAttributeMapping {
+	if s.AttributeMapping != "" {
+		var m SamlAttributeMapping
+		if err := json.Unmarshal([]byte(s.AttributeMapping), &m); err == nil && m.Validate() == nil {
+			return m
+		}
+	}
+	return DefaultSamlAttributeMapping
+}
+
+// SetMapping 设置属性映射，nil 表示使用默认映射
+func (s *SamlConnection) SetMapping(m SamlAttributeMapping) error {
+	if len(m) == 0 {
+		s.AttributeMapping = ""
+		return nil
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.AttributeMapping = string(data)
+	return nil
+}
+
+// ApplyMetadata 从 IdP 元数据填充实体 ID、SSO 地址和签名证书
+func (s *SamlConnection) ApplyMetadata(data []byte) error {
+	meta, err := saml.ParseIdPMetadata(data)
+	if err != nil {
+		return err
+	}
+	s.IdPEntityID = meta.EntityID
+	s.IdPSSOURL = meta.SSOURL
+	var certs []byte
+	for _, cert := range meta.Certificates {
+		certs = append(certs, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	s.IdPCertificate = string(certs)
+	return nil
+}
+
+// Validate 检查 IdP 配置
+func (s *SamlConnection) Validate() error {
+	if strings.TrimSpace(s.IdPEntityID) == "" {
+		return errors.New("IdP entity ID is required")
+	}
+	u, err := url.Parse(s.IdPSSOURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("IdP SSO URL must be an absolute http(s) URL")
+	}
+	if _, err := saml.ParseCertificates(s.IdPCertificate); err != nil {
+		return fmt.Errorf("invalid IdP certificate: %w", err)
+	}
+	return nil
+}
+
+// ServiceProvider 构造 SP，spBase 为该组织的 SAML 地址前缀（.../auth/saml/<groupId>）
+func (s *SamlConnection) ServiceProvider(spBase string) (*saml.ServiceProvider, error) {
+	certs, err := saml.ParseCertificates(s.IdPCertificate)
+	if err != nil {
+		return nil, err
+	}
+	return &saml.ServiceProvider{
+		EntityID: spBase + "/metadata",
+		ACSURL:   spBase + "/acs",
+		IdP: saml.IdPMetadata{
+			EntityID:     s.IdPEntityID,
+			SSOURL:       s.IdPSSOURL,
+			Certificates: certs,
+		},
+	}, nil
+}
+
+// CheckSamlDomainsAvailable 检查域名是否已被其他组织认领
+func CheckSamlDomainsAvailable(db *gorm.DB, groupID uint, domains []string) error {
+	var others []SamlConnection
+	if err := db.Where("group_id <> ? AND domains <> ''", groupID).Find(&others).Error; err != nil {
+		return err
+	}
+	for _, other := range others {
+		for _, d := range domains {
+			if other.OwnsEmail("x@" + d) {
+				return fmt.Errorf("domain %s is already claimed by another organization", d)
+			}
+		}
+	}
+	return nil
+}
+
+// GetSamlConnection 获取组织的 SAML 配置
+func GetSamlConnection(db *gorm.DB, groupID uint) (*SamlConnection, error) {
+	var conn SamlConnection
+	if err := db.Where("group_id = ?", groupID).First(&conn).Error; err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// FindSamlConnectionForEmail 根据邮箱域名查找启用的 SAML 配置，不存在时返回 nil
+func FindSamlConnectionForEmail(db *gorm.DB, email string) *SamlConnection {
+	_, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok || domain == "" {
+		return nil
+	}
+	var conns []SamlConnection
+	if err := db.Where("enabled = ? AND domains LIKE ?", true, "%"+domain+"%").Find(&conns).Error; err != nil {
+		return nil
+	}
+	for i := range conns {
+		if conns[i].OwnsEmail(email) {
+			return &conns[i]
+		}
+	}
+	return nil
+}
+
+// SamlEnforcedFor 返回强制该邮箱使用 SSO 的配置；系统管理员不受限制，避免 IdP 故障时无法登录
+func SamlEnforcedFor(db *gorm.DB, email string, user *User) *SamlConnection {
+	if user != nil && user.IsAdmin() {
+		return nil
+	}
+	conn := FindSamlConnectionForEmail(db, email)
+	if conn == nil || !conn.EnforceSSO {
+		return nil
+	}
+	return conn
+}
+
+// SamlAssertionEmail 按映射从断言中取邮箱，缺失时使用邮箱格式的 NameID
+func (s *SamlConnection) SamlAssertionEmail(a *saml.Assertion) string {
+	email := a.Attribute(s.Mapping()["email"])
+	if email == "" && strings.Contains(a.NameID, "@") {
+		email = a.NameID
+	}
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ProvisionSamlUser 根据断言查找或创建用户，并更新映射的资料字段。
+// 开启 JIT 时新用户会被创建并加入组织，已有用户不是成员时也会加入
+func ProvisionSamlUser(db *gorm.DB, conn *SamlConnection, a *saml.Assertion) (*User, bool, error) {
+	email := conn.SamlAssertionEmail(a)
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, false, errors.New("assertion does not contain an email address")
+	}
+	if !conn.OwnsEmail(email) {
+		return nil, false, ErrSamlDomainNotAllowed
+	}
+
+	profile := map[string]string{}
+	for field, attr := range conn.Mapping() {
+		if v := strings.TrimSpace(a.Attribute(attr)); v != "" && field != "email" {
+			profile[field] = v
+		}
+	}
+
+	user, err := GetUserByEmail(db, email)
+	created := false
+	switch {
+	case err == nil:
+		if user.IsAdmin() {
+			return nil, false, ErrSamlAdminNotAllowed
+		}
+		if len(profile) > 0 {
+			vals := make(map[string]any, len(profile))
+			for field, v := range profile {
+				vals[strings.ToUpper(field[:1])+field[1:]] = v
+			}
+			if err := UpdateUserFields(db, user, vals); err != nil {
+				return nil, false, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !conn.JITProvisioning {
+			return nil, false, ErrSamlNotProvisioned
+		}
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, false, err
+		}
+		user = &User{
+			Email:              email,
+			Password:           HashPassword(hex.EncodeToString(buf)),
+			Enabled:            true,
+			Activated:          true,
+			EmailVerified:      true,
+			EmailNotifications: true,
+			Role:               RoleUser,
+			Source:             SamlUserSource,
+		}
+		for field, v := range profile {
+			*mappableUserFields[field](user) = v
+		}
+		if err := db.Create(user).Error; err != nil {
+			return nil, false, err
+		}
+		created = true
+	default:
+		return nil, false, err
+	}
+
+	if conn.JITProvisioning {
+		var count int64
+		db.Model(&GroupMember{}).Where("group_id = ? AND user_id = ?", conn.GroupID, user.ID).Count(&count)
+		if count == 0 {
+			if err := db.Create(&GroupMember{UserID: user.ID, GroupID: conn.GroupID, Role: GroupRoleMember}).Error; err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	return user, created, nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamlConnection_Domains(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SamlConnection{})
+	conn := &SamlConnection{GroupID: 1, Enabled: true, EnforceSSO: true}
+	require.NoError(t, conn.SetDomains([]string{" Example.COM", "@corp.example.com", "example.com"}))
+	assert.Equal(t, "example.com,corp.example.com", conn.Domains)
+	assert.Error(t, conn.SetDomains([]string{"localhost"}))
+	require.NoError(t, db.Create(conn).Error)
+
+	assert.True(t, conn.OwnsEmail("Bob@Example.com"))
+	assert.False(t, conn.OwnsEmail("bob@notexample.com"))
+	assert.Error(t, CheckSamlDomainsAvailable(db, 2, []string{"corp.example.com"}))
+	assert.NoError(t, CheckSamlDomainsAvailable(db, 1, []string{"corp.example.com"}))
+	assert.NoError(t, CheckSamlDomainsAvailable(db, 2, []string{"example.org"}))
+
+	found := FindSamlConnectionForEmail(db, "bob@corp.example.com")
+	require.NotNil(t, found)
+	assert.Equal(t, conn.ID, found.ID)
+	assert.Nil(t, FindSamlConnectionForEmail(db, "bob@sub.corp.example.com.evil"))
+
+	assert.NotNil(t, SamlEnforcedFor(db, "bob@example.com", &User{Role: RoleUser}))
+	assert.Nil(t, SamlEnforcedFor(db, "root@example.com", &User{Role: RoleSuperAdmin}), "admins keep password login")
+	assert.Nil(t, SamlEnforcedFor(db, "bob@example.org", nil))
+}
+
+func TestProvisionSamlUser(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &GroupMember{})
+	conn := &SamlConnection{GroupID: 7}
+	require.NoError(t, conn.SetDomains([]string{"example.com"}))
+	require.NoError(t, conn.SetMapping(SamlAttributeMapping{"email": "mail", "firstName": "givenName"}))
+
+	assertion := &saml.Assertion{
+		NameID: "00u123",
+		Attributes: []saml.Attribute{
+			{Name: "mail", Values: []string{"Alice@Example.com"}},
+			{Name: "urn:oid:2.5.4.42", FriendlyName: "givenName", Values: []string{"Alice"}},
+		},
+	}
+	_, _, err := ProvisionSamlUser(db, conn, assertion)
+	assert.ErrorIs(t, err, ErrSamlNotProvisioned)
+
+	conn.JITProvisioning = true
+	user, created, err := ProvisionSamlUser(db, conn, assertion)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Equal(t, "Alice", user.FirstName)
+	assert.Equal(t, SamlUserSource, user.Source)
+	assert.True(t, IsGroupMember(db, &Group{ID: 7}, user.ID))
+
+	assertion.Attributes[1].Values = []string{"Alicia"}
+	again, created, err := ProvisionSamlUser(db, conn, assertion)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, user.ID, again.ID)
+	reloaded, _ := GetUserByEmail(db, "alice@example.com")
+	assert.Equal(t, "Alicia", reloaded.FirstName)
+
+	_, _, err = ProvisionSamlUser(db, conn, &saml.Assertion{NameID: "mallory@other.com"})
+	assert.ErrorIs(t, err, ErrSamlDomainNotAllowed)
+
+	require.NoError(t, db.Create(&User{Email: "root@example.com", Role: RoleAdmin, Enabled: true}).Error)
+	_, _, err = ProvisionSamlUser(db, conn, &saml.Assertion{NameID: "root@example.com"})
+	assert.ErrorIs(t, err, ErrSamlAdminNotAllowed)
+}
@@ -0,0 +1,893 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/scim"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SCIM 资源类型
+const (
+	ScimResourceUser  = "User"
+	ScimResourceGroup = "Group"
+
+	// ScimTokenField 上下文中保存当前 SCIM 令牌的键
+	ScimTokenField = "scim_token"
+	// scimTokenPrefix 令牌前缀，便于在日志或密钥扫描中识别
+	scimTokenPrefix = "scim_"
+	// ScimUserSource 通过 SCIM 创建的用户来源
+	ScimUserSource = "scim"
+	// ScimGroupType 通过 SCIM 创建的组织类型
+	ScimGroupType = "scim"
+)
+
+// ScimToken IdP 访问 SCIM 接口的 Bearer 令牌，只保存哈希。
+// 未指定组织时可管理全部用户和组织；指定组织时只能看到该组织成员及自己创建的用户和组织，新用户自动加入该组织
+type ScimToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	Name        string     `json:"name" gorm:"size:128"`
+	TokenHash   string     `json:"-" gorm:"size:64;uniqueIndex"`
+	TokenPrefix string     `json:"tokenPrefix" gorm:"size:16"`
+	GroupID     *uint      `json:"groupId,omitempty" gorm:"index"`
+	Mapping     string     `json:"-" gorm:"type:text"` // 属性映射 JSON，为空使用默认映射
+	CreatedBy   uint       `json:"createdBy" gorm:"index"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	Revoked     bool       `json:"revoked"`
+}
+
+// TableName 指定表名
+func (ScimToken) TableName() string {
+	return "scim_tokens"
+}
+
+// ScimIdentity SCIM 资源与本地用户/组织的关联，保存 IdP 的 externalId 与 userName
+type ScimIdentity struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	TokenID      uint      `json:"tokenId" gorm:"index"`
+	ResourceType string    `json:"resourceType" gorm:"size:16;uniqueIndex:idx_scim_identity_resource"`
+	ResourceID   uint      `json:"resourceId" gorm:"uniqueIndex:idx_scim_identity_resource"`
+	ExternalID   string    `json:"externalId" gorm:"size:255;index"`
+	UserName     string    `json:"userName" gorm:"size:255;index"`
+}
+
+// TableName 指定表名
+func (ScimIdentity) TableName() string {
+	return "scim_identities"
+}
+
+// ScimAttributeMapping 用户字段到 SCIM 属性路径的映射，如 "firstName": "name.givenName"
+type ScimAttributeMapping map[string]string
+
+// DefaultScimAttributeMapping 默认映射，适用于 Okta、Azure AD 等常见 IdP
+var DefaultScimAttributeMapping = ScimAttributeMapping{
+	"email":       "userName",
+	"displayName": "displayName",
+	"firstName":   "name.givenName",
+	"lastName":    "name.familyName",
+	"phone":       "phoneNumbers[primary eq true].value",
+	"locale":      "locale",
+	"timezone":    "timezone",
+	"city":        "addresses[primary eq true].locality",
+	"region":      "addresses[primary eq true].region",
+}
+
+// mappableUserFields 可由身份提供方映射的用户字段（SCIM、SAML 共用）
+var mappableUserFields = map[string]func(u *User) *string{
+	"email":       func(u *User) *string { return &u.Email },
+	"displayName": func(u *User) *string { return &u.DisplayName },
+	"firstName":   func(u *User) *string { return &u.FirstName },
+	"lastName":    func(u *User) *string { return &u.LastName },
+	"phone":       func(u *User) *string { return &u.Phone },
+	"locale":      func(u *User) *string { return &u.Locale },
+	"timezone":    func(u *User) *string { return &u.Timezone },
+	"city":        func(u *User) *string { return &u.City },
+	"region":      func(u *User) *string { return &u.Region },
+}
+
+// Validate 检查映射的字段和路径，email 必须映射
+func (m ScimAttributeMapping) Validate() error {
+	if m["email"] == "" {
+		return errors.New("email must be mapped")
+	}
+	for field, path := range m {
+		if _, ok := mappableUserFields[field]; !ok {
+			return fmt.Errorf("unsupported user field: %s", field)
+		}
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("empty SCIM path for %s", field)
+		}
+	}
+	return nil
+}
+
+// AttributeMapping 令牌使用的属性映射
+func (t *ScimToken) AttributeMapping() ScimAttributeMapping {
+	if t.Mapping != "" {
+		var m ScimAttributeMapping
+		if err := json.Unmarshal([]byte(t.Mapping), &m); err == nil && m.Validate() == nil {
+			return m
+		}
+	}
+	return DefaultScimAttributeMapping
+}
+
+// SetAttributeMapping 设置属性映射，nil 表示使用默认映射
+func (t *ScimToken) SetAttributeMapping(m ScimAttributeMapping) error {
+	if m == nil {
+		t.Mapping = ""
+		return nil
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	t.Mapping = string(data)
+	return nil
+}
+
+func hashScimToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateScimToken 创建令牌，返回只显示一次的明文令牌
+func CreateScimToken(db *gorm.DB, token *ScimToken) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	plain := scimTokenPrefix + hex.EncodeToString(buf)
+	token.ID = 0
+	token.TokenHash = hashScimToken(plain)
+	token.TokenPrefix = plain[:len(scimTokenPrefix)+6]
+	token.Revoked = false
+	if err := db.Create(token).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// FindScimToken 按明文令牌查找有效令牌
+func FindScimToken(db *gorm.DB, plain string) (*ScimToken, error) {
+	if !strings.HasPrefix(plain, scimTokenPrefix) {
+		return nil, errors.New("invalid SCIM token")
+	}
+	var token ScimToken
+	if err := db.Where("token_hash = ?", hashScimToken(plain)).First(&token).Error; err != nil {
+		return nil, errors.New("invalid SCIM token")
+	}
+	if token.Revoked {
+		return nil, errors.New("SCIM token revoked")
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, errors.New("SCIM token expired")
+	}
+	return &token, nil
+}
+
+// ScimAuthRequired 校验 Authorization: Bearer <SCIM 令牌>
+func ScimAuthRequired(c *gin.Context) {
+	db := c.MustGet(constants.DbField).(*gorm.DB)
+	header := c.GetHeader("Authorization")
+	plain, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		abortScim(c, scim.NewError(http.StatusUnauthorized, "", "bearer token required"))
+		return
+	}
+	token, err := FindScimToken(db, strings.TrimSpace(plain))
+	if err != nil {
+		abortScim(c, scim.NewError(http.StatusUnauthorized, "", "%s", err.Error()))
+		return
+	}
+	now := time.Now()
+	db.Model(token).UpdateColumn("last_used_at", &now)
+	c.Set(ScimTokenField, token)
+	c.Next()
+}
+
+func abortScim(c *gin.Context, err *scim.Error) {
+	c.Header("Content-Type", scim.ContentType)
+	c.AbortWithStatusJSON(err.StatusCode(), err)
+}
+
+// ScimProvisioner 按令牌的范围和属性映射处理 SCIM 用户与组织
+type ScimProvisioner struct {
+	DB      *gorm.DB
+	Token   *ScimToken
+	BaseURL string // SCIM 接口地址，用于 meta.location
+	mapping ScimAttributeMapping
+}
+
+// NewScimProvisioner 创建 SCIM 处理器
+func NewScimProvisioner(db *gorm.DB, token *ScimToken, baseURL string) *ScimProvisioner {
+	return &ScimProvisioner{DB: db, Token: token, BaseURL: strings.TrimSuffix(baseURL, "/"), mapping: token.AttributeMapping()}
+}
+
+func parseScimID(resourceType, id string) (uint, error) {
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, scim.ErrNotFound(resourceType, id)
+	}
+	return uint(n), nil
+}
+
+func scimTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// scimString 读取字符串属性，[primary eq true] 没有匹配时取第一个元素
+func scimString(resource map[string]any, path string) (string, bool) {
+	v, ok := scim.GetPath(resource, path)
+	if !ok && strings.Contains(path, "[primary eq true]") {
+		v, ok = scim.GetPath(resource, strings.Replace(path, "[primary eq true]", "", 1))
+	}
+	if list, isList := v.([]any); isList {
+		if len(list) == 0 {
+			return "", false
+		}
+		v = list[0]
+	}
+	if !ok || v == nil {
+		return "", ok
+	}
+	return strings.TrimSpace(fmt.Sprint(v)), true
+}
+
+// scimBool 兼容 Azure AD 以字符串发送的布尔值
+func scimBool(v any) (bool, error) {
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	case string:
+		return strconv.ParseBool(strings.ToLower(val))
+	}
+	return false, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidValue, "invalid boolean %v", v)
+}
+
+// ---------------------------- Users ----------------------------
+
+// userScope 令牌可见的用户
+func (p *ScimProvisioner) userScope() *gorm.DB {
+	q := p.DB.Model(&User{})
+	if p.Token.GroupID != nil {
+		q = q.Where("id IN (?) OR id IN (?)",
+			p.DB.Model(&GroupMember{}).Select("user_id").Where("group_id = ?", *p.Token.GroupID),
+			p.DB.Model(&ScimIdentity{}).Select("resource_id").Where("resource_type = ? AND token_id = ?", ScimResourceUser, p.Token.ID))
+	}
+	return q
+}
+
+func (p *ScimProvisioner) identity(resourceType string, id uint) ScimIdentity {
+	var ident ScimIdentity
+	p.DB.Where("resource_type = ? AND resource_id = ?", resourceType, id).First(&ident)
+	return ident
+}
+
+func (p *ScimProvisioner) saveIdentity(resourceType string, id uint, externalID, userName string) error {
+	ident := p.identity(resourceType, id)
+	ident.TokenID = p.Token.ID
+	ident.ResourceType = resourceType
+	ident.ResourceID = id
+	ident.ExternalID = externalID
+	ident.UserName = userName
+	return p.DB.Save(&ident).Error
+}
+
+func (p *ScimProvisioner) loadUser(id string) (*User, error) {
+	uid, err := parseScimID(ScimResourceUser, id)
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	if err := p.userScope().Where("id = ?", uid).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scim.ErrNotFound(ScimResourceUser, id)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UserResource 将用户转换为 SCIM User 资源
+func (p *ScimProvisioner) UserResource(user *User) map[string]any {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	res := map[string]any{
+		"schemas":  []any{scim.SchemaUser},
+		"id":       id,
+		"userName": user.Email,
+		"active":   user.Enabled,
+		"meta":     scim.Meta(ScimResourceUser, p.BaseURL+"/Users/"+id, scimTime(user.CreatedAt), scimTime(user.UpdatedAt)),
+	}
+	var ops []scim.PatchOp
+	for field, path := range p.mapping {
+		if v := *mappableUserFields[field](user); v != "" {
+			ops = append(ops, scim.PatchOp{Op: "add", Path: path, Value: v})
+		}
+	}
+	_ = scim.ApplyPatch(res, ops)
+	if _, ok := res["emails"]; !ok && user.Email != "" {
+		res["emails"] = []any{map[string]any{"value": user.Email, "type": "work", "primary": true}}
+	}
+	ident := p.identity(ScimResourceUser, user.ID)
+	if ident.UserName != "" {
+		res["userName"] = ident.UserName
+	}
+	if ident.ExternalID != "" {
+		res["externalId"] = ident.ExternalID
+	}
+	return res
+}
+
+// applyUserResource 将 SCIM 资源写入用户字段
+func (p *ScimProvisioner) applyUserResource(user *User, res map[string]any) error {
+	for field, path := range p.mapping {
+		if v, ok := scimString(res, path); ok {
+			*mappableUserFields[field](user) = v
+		}
+	}
+	user.Email = strings.ToLower(user.Email)
+	if user.Email == "" || !strings.Contains(user.Email, "@") {
+		return scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidValue, "a valid email is required (mapped from %s)", p.mapping["email"])
+	}
+	if v, ok := res["active"]; ok {
+		active, err := scimBool(v)
+		if err != nil {
+			return err
+		}
+		user.Enabled = active
+	}
+	return nil
+}
+
+// ListUsers 列出用户，支持 userName、externalId、emails.value、displayName、active、id 过滤
+func (p *ScimProvisioner) ListUsers(filter string, startIndex, count int) (*scim.ListResponse, error) {
+	filters, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	q := p.userScope()
+	for _, f := range filters {
+		if q, err = p.applyUserFilter(q, f); err != nil {
+			return nil, err
+		}
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	offset, limit := scim.Pagination(startIndex, count)
+	var users []User
+	if limit > 0 {
+		if err := q.Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+	resources := make([]any, 0, len(users))
+	for i := range users {
+		resources = append(resources, p.UserResource(&users[i]))
+	}
+	return scim.NewListResponse(resources, total, offset+1), nil
+}
+
+func (p *ScimProvisioner) applyUserFilter(q *gorm.DB, f scim.Filter) (*gorm.DB, error) {
+	value := strings.TrimSpace(fmt.Sprint(f.Value))
+	like := func(column string) (*gorm.DB, error) {
+		switch f.Op {
+		case scim.OpEq:
+			return q.Where("LOWER("+column+") = ?", strings.ToLower(value)), nil
+		case scim.OpSw:
+			return q.Where("LOWER("+column+") LIKE ?", strings.ToLower(value)+"%"), nil
+		case scim.OpCo:
+			return q.Where("LOWER("+column+") LIKE ?", "%"+strings.ToLower(value)+"%"), nil
+		case scim.OpEw:
+			return q.Where("LOWER("+column+") LIKE ?", "%"+strings.ToLower(value)), nil
+		}
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidFilter, "operator %s is not supported for %s", f.Op, f.Attr)
+	}
+	identities := func(column string) *gorm.DB {
+		return p.DB.Model(&ScimIdentity{}).Select("resource_id").
+			Where("resource_type = ? AND LOWER("+column+") = ?", ScimResourceUser, strings.ToLower(value))
+	}
+
+	switch strings.ToLower(f.Attr) {
+	case "username":
+		if f.Op != scim.OpEq {
+			return like("email")
+		}
+		return q.Where("LOWER(email) = ? OR id IN (?)", strings.ToLower(value), identities("user_name")), nil
+	case "externalid":
+		if f.Op != scim.OpEq {
+			break
+		}
+		return q.Where("id IN (?)", identities("external_id")), nil
+	case "emails", "emails.value":
+		return like("email")
+	case "displayname":
+		return like("display_name")
+	case "id":
+		if f.Op == scim.OpEq {
+			return q.Where("id = ?", value), nil
+		}
+	case "active":
+		if active, ok := f.Value.(bool); ok && f.Op == scim.OpEq {
+			return q.Where("enabled = ?", active), nil
+		}
+	}
+	return nil, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidFilter, "unsupported filter %s", f.String())
+}
+
+// GetUser 获取用户
+func (p *ScimProvisioner) GetUser(id string) (map[string]any, error) {
+	user, err := p.loadUser(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.UserResource(user), nil
+}
+
+// CreateUser 创建用户。全局令牌遇到相同邮箱的已有用户时接管该用户，组织令牌返回冲突
+func (p *ScimProvisioner) CreateUser(res map[string]any) (map[string]any, error) {
+	user := &User{Enabled: true}
+	if err := p.applyUserResource(user, res); err != nil {
+		return nil, err
+	}
+	if existing, err := GetUserByEmail(p.DB, user.Email); err == nil {
+		if p.Token.GroupID != nil {
+			return nil, scim.NewError(http.StatusConflict, scim.ErrTypeUniqueness, "user %s already exists", user.Email)
+		}
+		if existing.IsAdmin() {
+			return nil, scim.NewError(http.StatusConflict, scim.ErrTypeUniqueness, "user %s already exists", user.Email)
+		}
+		return p.saveUser(existing, res)
+	}
+
+	password, _ := scimString(res, "password")
+	if password == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		password = hex.EncodeToString(buf)
+	}
+	user.Password = HashPassword(password)
+	user.Activated = true
+	user.EmailVerified = true
+	user.EmailNotifications = true
+	user.Role = RoleUser
+	user.Source = ScimUserSource
+
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		// Enabled 为 false 时 gorm 不会写入零值
+		if err := tx.Model(user).Update("enabled", user.Enabled).Error; err != nil {
+			return err
+		}
+		if p.Token.GroupID != nil {
+			if err := tx.Create(&GroupMember{UserID: user.ID, GroupID: *p.Token.GroupID, Role: GroupRoleMember}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.saveUserIdentity(user, res); err != nil {
+		return nil, err
+	}
+	return p.UserResource(user), nil
+}
+
+func (p *ScimProvisioner) saveUserIdentity(user *User, res map[string]any) error {
+	externalID, _ := scimString(res, "externalId")
+	userName, _ := scimString(res, "userName")
+	return p.saveIdentity(ScimResourceUser, user.ID, externalID, userName)
+}
+
+// saveUser 用资源覆盖用户字段并保存
+func (p *ScimProvisioner) saveUser(user *User, res map[string]any) (map[string]any, error) {
+	if user.IsAdmin() {
+		return nil, scim.NewError(http.StatusForbidden, scim.ErrTypeMutability, "administrators cannot be managed through SCIM")
+	}
+	if err := p.applyUserResource(user, res); err != nil {
+		return nil, err
+	}
+	if existing, err := GetUserByEmail(p.DB, user.Email); err == nil && existing.ID != user.ID {
+		return nil, scim.NewError(http.StatusConflict, scim.ErrTypeUniqueness, "user %s already exists", user.Email)
+	}
+	vals := map[string]any{"Enabled": user.Enabled}
+	for field := range mappableUserFields {
+		vals[strings.ToUpper(field[:1])+field[1:]] = *mappableUserFields[field](user)
+	}
+	if err := UpdateUserFields(p.DB, user, vals); err != nil {
+		return nil, err
+	}
+	if err := p.saveUserIdentity(user, res); err != nil {
+		return nil, err
+	}
+	return p.UserResource(user), nil
+}
+
+// ReplaceUser 以 PUT 替换用户
+func (p *ScimProvisioner) ReplaceUser(id string, res map[string]any) (map[string]any, error) {
+	user, err := p.loadUser(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.saveUser(user, res)
+}
+
+// PatchUser 以 PATCH 修改用户，active=false 即停用
+func (p *ScimProvisioner) PatchUser(id string, ops []scim.PatchOp) (map[string]any, error) {
+	user, err := p.loadUser(id)
+	if err != nil {
+		return nil, err
+	}
+	res := p.UserResource(user)
+	if err := scim.ApplyPatch(res, ops); err != nil {
+		return nil, err
+	}
+	return p.saveUser(user, res)
+}
+
+// DeactivateUser 取消分配时停用用户而不删除，保留其数据
+func (p *ScimProvisioner) DeactivateUser(id string) error {
+	user, err := p.loadUser(id)
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin() {
+		return scim.NewError(http.StatusForbidden, scim.ErrTypeMutability, "administrators cannot be managed through SCIM")
+	}
+	return UpdateUserFields(p.DB, user, map[string]any{"Enabled": false})
+}
+
+// ---------------------------- Groups ----------------------------
+
+// groupScope 令牌可见的组织
+func (p *ScimProvisioner) groupScope() *gorm.DB {
+	q := p.DB.Model(&Group{})
+	if p.Token.GroupID != nil {
+		q = q.Where("id = ? OR id IN (?)", *p.Token.GroupID,
+			p.DB.Model(&ScimIdentity{}).Select("resource_id").Where("resource_type = ? AND token_id = ?", ScimResourceGroup, p.Token.ID))
+	}
+	return q
+}
+
+func (p *ScimProvisioner) loadGroup(id string) (*Group, error) {
+	gid, err := parseScimID(ScimResourceGroup, id)
+	if err != nil {
+		return nil, err
+	}
+	var group Group
+	if err := p.groupScope().Where("id = ?", gid).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scim.ErrNotFound(ScimResourceGroup, id)
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GroupResource 将组织转换为 SCIM Group 资源
+func (p *ScimProvisioner) GroupResource(group *Group) map[string]any {
+	id := strconv.FormatUint(uint64(group.ID), 10)
+	var members []GroupMember
+	p.DB.Preload("User").Where("group_id = ?", group.ID).Order("id").Find(&members)
+	list := make([]any, 0, len(members))
+	for _, m := range members {
+		uid := strconv.FormatUint(uint64(m.UserID), 10)
+		list = append(list, map[string]any{"value": uid, "display": m.User.Email, "$ref": p.BaseURL + "/Users/" + uid})
+	}
+	res := map[string]any{
+		"schemas":     []any{scim.SchemaGroup},
+		"id":          id,
+		"displayName": group.Name,
+		"members":     list,
+		"meta":        scim.Meta(ScimResourceGroup, p.BaseURL+"/Groups/"+id, scimTime(group.CreatedAt), scimTime(group.UpdatedAt)),
+	}
+	if ident := p.identity(ScimResourceGroup, group.ID); ident.ExternalID != "" {
+		res["externalId"] = ident.ExternalID
+	}
+	return res
+}
+
+// ListGroups 列出组织，支持 displayName、externalId、id 过滤
+func (p *ScimProvisioner) ListGroups(filter string, startIndex, count int) (*scim.ListResponse, error) {
+	filters, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	q := p.groupScope()
+	for _, f := range filters {
+		value := strings.TrimSpace(fmt.Sprint(f.Value))
+		switch {
+		case strings.EqualFold(f.Attr, "displayName") && f.Op == scim.OpEq:
+			q = q.Where("LOWER(name) = ?", strings.ToLower(value))
+		case strings.EqualFold(f.Attr, "displayName") && f.Op == scim.OpSw:
+			q = q.Where("LOWER(name) LIKE ?", strings.ToLower(value)+"%")
+		case strings.EqualFold(f.Attr, "externalId") && f.Op == scim.OpEq:
+			q = q.Where("id IN (?)", p.DB.Model(&ScimIdentity{}).Select("resource_id").
+				Where("resource_type = ? AND external_id = ?", ScimResourceGroup, value))
+		case strings.EqualFold(f.Attr, "id") && f.Op == scim.OpEq:
+			q = q.Where("id = ?", value)
+		default:
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidFilter, "unsupported filter %s", f.String())
+		}
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	offset, limit := scim.Pagination(startIndex, count)
+	var groups []Group
+	if limit > 0 {
+		if err := q.Order("id").Offset(offset).Limit(limit).Find(&groups).Error; err != nil {
+			return nil, err
+		}
+	}
+	resources := make([]any, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, p.GroupResource(&groups[i]))
+	}
+	return scim.NewListResponse(resources, total, offset+1), nil
+}
+
+// GetGroup 获取组织
+func (p *ScimProvisioner) GetGroup(id string) (map[string]any, error) {
+	group, err := p.loadGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.GroupResource(group), nil
+}
+
+// CreateGroup 创建组织，创建者为令牌的创建者
+func (p *ScimProvisioner) CreateGroup(res map[string]any) (map[string]any, error) {
+	name, _ := scimString(res, "displayName")
+	if name == "" {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidValue, "displayName is required")
+	}
+	group := &Group{Name: name, Type: ScimGroupType, CreatorID: p.Token.CreatedBy}
+	if err := p.DB.Create(group).Error; err != nil {
+		return nil, err
+	}
+	externalID, _ := scimString(res, "externalId")
+	if err := p.saveIdentity(ScimResourceGroup, group.ID, externalID, ""); err != nil {
+		return nil, err
+	}
+	return p.saveGroup(group, res)
+}
+
+// saveGroup 用资源覆盖组织名称和成员
+func (p *ScimProvisioner) saveGroup(group *Group, res map[string]any) (map[string]any, error) {
+	if name, ok := scimString(res, "displayName"); ok && name != "" && name != group.Name {
+		if err := p.DB.Model(group).Update("name", name).Error; err != nil {
+			return nil, err
+		}
+	}
+	if externalID, ok := scimString(res, "externalId"); ok {
+		if err := p.saveIdentity(ScimResourceGroup, group.ID, externalID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	wanted := map[uint]bool{}
+	members, _ := res["members"].([]any)
+	for _, m := range members {
+		value := m
+		if obj, ok := m.(map[string]any); ok {
+			value = obj["value"]
+		}
+		uid, err := strconv.ParseUint(strings.TrimSpace(fmt.Sprint(value)), 10, 32)
+		if err != nil {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidValue, "invalid member %v", value)
+		}
+		wanted[uint(uid)] = true
+	}
+	var visible []uint
+	if len(wanted) > 0 {
+		ids := make([]uint, 0, len(wanted))
+		for id := range wanted {
+			ids = append(ids, id)
+		}
+		if err := p.userScope().Where("id IN ?", ids).Pluck("id", &visible).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		var current []GroupMember
+		if err := tx.Where("group_id = ?", group.ID).Find(&current).Error; err != nil {
+			return err
+		}
+		existing := map[uint]bool{}
+		for _, m := range current {
+			existing[m.UserID] = true
+			// 组织管理员不受 IdP 同步影响
+			if !wanted[m.UserID] && m.Role != GroupRoleAdmin {
+				if err := tx.Delete(&GroupMember{}, m.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for _, uid := range visible {
+			if !existing[uid] {
+				if err := tx.Create(&GroupMember{UserID: uid, GroupID: group.ID, Role: GroupRoleMember}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.DB.First(group, group.ID)
+	return p.GroupResource(group), nil
+}
+
+// ReplaceGroup 以 PUT 替换组织
+func (p *ScimProvisioner) ReplaceGroup(id string, res map[string]any) (map[string]any, error) {
+	group, err := p.loadGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.saveGroup(group, res)
+}
+
+// PatchGroup 以 PATCH 修改组织名称或成员
+func (p *ScimProvisioner) PatchGroup(id string, ops []scim.PatchOp) (map[string]any, error) {
+	group, err := p.loadGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	res := p.GroupResource(group)
+	if err := scim.ApplyPatch(res, ops); err != nil {
+		return nil, err
+	}
+	return p.saveGroup(group, res)
+}
+
+// DeleteGroup 删除组织及其成员关系，令牌绑定的组织不能删除
+func (p *ScimProvisioner) DeleteGroup(id string) error {
+	group, err := p.loadGroup(id)
+	if err != nil {
+		return err
+	}
+	if p.Token.GroupID != nil && *p.Token.GroupID == group.ID {
+		return scim.NewError(http.StatusForbidden, scim.ErrTypeMutability, "the organization bound to this token cannot be deleted")
+	}
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.ID).Delete(&GroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("resource_type = ? AND resource_id = ?", ScimResourceGroup, group.ID).Delete(&ScimIdentity{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+}
+
+// ---------------------------- Bulk ----------------------------
+
+// Bulk 依次执行批量操作，后续操作可通过 bulkId:<id> 引用前面创建的资源
+func (p *ScimProvisioner) Bulk(req *scim.BulkRequest) (*scim.BulkResponse, error) {
+	if len(req.Operations) > scim.MaxBulkOperations {
+		return nil, scim.NewError(http.StatusRequestEntityTooLarge, scim.ErrTypeTooMany, "at most %d operations are allowed", scim.MaxBulkOperations)
+	}
+	resp := &scim.BulkResponse{Schemas: []string{scim.SchemaBulkResponse}, Operations: []scim.BulkOperationResult{}}
+	ids := map[string]string{}
+	failures := 0
+	for _, op := range req.Operations {
+		if req.FailOnErrors > 0 && failures >= req.FailOnErrors {
+			break
+		}
+		result := scim.BulkOperationResult{Method: op.Method, BulkID: op.BulkID}
+		segments := strings.Split(op.Path, "/")
+		for i, seg := range segments {
+			segments[i], _ = scim.ResolveBulkIDs(seg, ids).(string)
+		}
+		path := strings.Join(segments, "/")
+		data, _ := scim.ResolveBulkIDs(op.Data, ids).(map[string]any)
+		res, status, err := p.bulkOperation(strings.ToUpper(op.Method), path, data)
+		if err != nil {
+			failures++
+			scimErr, ok := err.(*scim.Error)
+			if !ok {
+				scimErr = scim.NewError(http.StatusInternalServerError, "", "%s", err.Error())
+			}
+			result.Status = scimErr.Status
+			result.Response = scimErr
+		} else {
+			result.Status = strconv.Itoa(status)
+			if res != nil {
+				meta, _ := res["meta"].(map[string]any)
+				result.Location, _ = meta["location"].(string)
+				if op.BulkID != "" {
+					ids[op.BulkID], _ = res["id"].(string)
+				}
+			}
+		}
+		resp.Operations = append(resp.Operations, result)
+	}
+	return resp, nil
+}
+
+func (p *ScimProvisioner) bulkOperation(method, path string, data map[string]any) (map[string]any, int, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	resource, id := parts[0], ""
+	if len(parts) == 2 {
+		id = parts[1]
+	}
+	if len(parts) > 2 || (resource != "Users" && resource != "Groups") || (method == http.MethodPost) != (id == "") {
+		return nil, 0, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidPath, "invalid bulk path %q", path)
+	}
+	users := resource == "Users"
+
+	switch method {
+	case http.MethodPost:
+		var res map[string]any
+		var err error
+		if users {
+			res, err = p.CreateUser(data)
+		} else {
+			res, err = p.CreateGroup(data)
+		}
+		return res, http.StatusCreated, err
+	case http.MethodPut:
+		var res map[string]any
+		var err error
+		if users {
+			res, err = p.ReplaceUser(id, data)
+		} else {
+			res, err = p.ReplaceGroup(id, data)
+		}
+		return res, http.StatusOK, err
+	case http.MethodPatch:
+		var patch scim.PatchRequest
+		raw, _ := json.Marshal(data)
+		if err := json.Unmarshal(raw, &patch); err != nil {
+			return nil, 0, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidSyntax, "invalid patch request")
+		}
+		var res map[string]any
+		var err error
+		if users {
+			res, err = p.PatchUser(id, patch.Operations)
+		} else {
+			res, err = p.PatchGroup(id, patch.Operations)
+		}
+		return res, http.StatusOK, err
+	case http.MethodDelete:
+		var err error
+		if users {
+			err = p.DeactivateUser(id)
+		} else {
+			err = p.DeleteGroup(id)
+		}
+		return nil, http.StatusNoContent, err
+	}
+	return nil, 0, scim.NewError(http.StatusBadRequest, scim.ErrTypeInvalidSyntax, "unsupported bulk method %q", method)
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/scim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupScimTest(t *testing.T, groupID *uint) (*gorm.DB, *ScimProvisioner) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &Group{}, &GroupMember{}, &ScimToken{}, &ScimIdentity{})
+	token := &ScimToken{Name: "okta", GroupID: groupID, CreatedBy: 1}
+	plain, err := CreateScimToken(db, token)
+	require.NoError(t, err)
+
+	found, err := FindScimToken(db, plain)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+	_, err = FindScimToken(db, plain+"x")
+	assert.Error(t, err)
+
+	return db, NewScimProvisioner(db, found, "https://example.com/scim/v2")
+}
+
+func TestScimProvisioner_UserLifecycle(t *testing.T) {
+	db, p := setupScimTest(t, nil)
+
+	res, err := p.CreateUser(map[string]any{
+		"schemas":      []any{scim.SchemaUser},
+		"userName":     "Bjensen@Example.com",
+		"externalId":   "00u1",
+		"name":         map[string]any{"givenName": "Barbara", "familyName": "Jensen"},
+		"phoneNumbers": []any{map[string]any{"value": "+8613800000000", "type": "work"}},
+		"active":       true,
+	})
+	require.NoError(t, err)
+	id := res["id"].(string)
+	assert.Equal(t, "Bjensen@Example.com", res["userName"])
+	assert.Equal(t, "00u1", res["externalId"])
+
+	user, err := GetUserByEmail(db, "bjensen@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Barbara", user.FirstName)
+	assert.Equal(t, "+8613800000000", user.Phone)
+	assert.Equal(t, ScimUserSource, user.Source)
+	assert.True(t, user.Enabled)
+
+	list, err := p.ListUsers(`userName eq "bjensen@example.com"`, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, list.TotalResults)
+	list, err = p.ListUsers(`externalId eq "00u1"`, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, list.TotalResults)
+
+	_, err = p.PatchUser(id, []scim.PatchOp{
+		{Op: "replace", Path: "name.familyName", Value: "Smith"},
+		{Op: "replace", Path: "active", Value: "False"},
+	})
+	require.NoError(t, err)
+	user, _ = GetUserByEmail(db, "bjensen@example.com")
+	assert.Equal(t, "Smith", user.LastName)
+	assert.False(t, user.Enabled)
+
+	require.NoError(t, UpdateUserFields(db, user, map[string]any{"Enabled": true}))
+	require.NoError(t, p.DeactivateUser(id))
+	user, _ = GetUserByEmail(db, "bjensen@example.com")
+	assert.False(t, user.Enabled, "deprovisioning deactivates instead of deleting")
+
+	_, err = p.GetUser("999")
+	var scimErr *scim.Error
+	require.ErrorAs(t, err, &scimErr)
+	assert.Equal(t, 404, scimErr.StatusCode())
+}
+
+func TestScimProvisioner_AdminsProtected(t *testing.T) {
+	db, p := setupScimTest(t, nil)
+	admin := &User{Email: "root@example.com", Role: RoleAdmin, Enabled: true}
+	require.NoError(t, db.Create(admin).Error)
+
+	_, err := p.CreateUser(map[string]any{"userName": "root@example.com"})
+	assert.Error(t, err)
+	err = p.DeactivateUser("1")
+	var scimErr *scim.Error
+	require.ErrorAs(t, err, &scimErr)
+	assert.Equal(t, scim.ErrTypeMutability, scimErr.ScimType)
+}
+
+func TestScimProvisioner_ScopedToken(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &Group{})
+	outsider := &User{Email: "other@example.com", Enabled: true}
+	require.NoError(t, db.Create(outsider).Error)
+	org := &Group{Name: "acme", CreatorID: 1}
+	require.NoError(t, db.Create(org).Error)
+
+	db, p := func() (*gorm.DB, *ScimProvisioner) {
+		require.NoError(t, db.AutoMigrate(&GroupMember{}, &ScimToken{}, &ScimIdentity{}))
+		token := &ScimToken{Name: "acme", GroupID: &org.ID, CreatedBy: 1}
+		_, err := CreateScimToken(db, token)
+		require.NoError(t, err)
+		return db, NewScimProvisioner(db, token, "/scim/v2")
+	}()
+
+	list, err := p.ListUsers("", 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, list.TotalResults, "scoped token cannot see users outside its organization")
+
+	_, err = p.CreateUser(map[string]any{"userName": "other@example.com"})
+	assert.Error(t, err)
+
+	res, err := p.CreateUser(map[string]any{"userName": "new@example.com"})
+	require.NoError(t, err)
+	var count int64
+	db.Model(&GroupMember{}).Where("group_id = ?", org.ID).Count(&count)
+	assert.EqualValues(t, 1, count, "provisioned users join the token's organization")
+
+	group, err := p.CreateGroup(map[string]any{"displayName": "eng", "members": []any{
+		map[string]any{"value": res["id"]},
+		map[string]any{"value": "1"}, // outsider, ignored
+	}})
+	require.NoError(t, err)
+	assert.Len(t, group["members"], 1)
+
+	group, err = p.PatchGroup(group["id"].(string), []scim.PatchOp{{Op: "remove", Path: "members"}})
+	require.NoError(t, err)
+	assert.Len(t, group["members"], 0)
+
+	groups, err := p.ListGroups(`displayName eq "eng"`, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, groups.TotalResults)
+	assert.Error(t, p.DeleteGroup("1"), "the bound organization cannot be deleted")
+	assert.NoError(t, p.DeleteGroup(group["id"].(string)))
+}
+
+func TestScimProvisioner_Bulk(t *testing.T) {
+	_, p := setupScimTest(t, nil)
+	resp, err := p.Bulk(&scim.BulkRequest{Operations: []scim.BulkOperation{
+		{Method: "POST", Path: "/Users", BulkID: "u1", Data: map[string]any{"userName": "a@example.com"}},
+		{Method: "POST", Path: "/Groups", BulkID: "g1", Data: map[string]any{
+			"displayName": "team",
+			"members":     []any{map[string]any{"value": "bulkId:u1"}},
+		}},
+		{Method: "POST", Path: "/Users", Data: map[string]any{"userName": "invalid"}},
+		{Method: "DELETE", Path: "/Users/bulkId:u1"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, resp.Operations, 4)
+	assert.Equal(t, "201", resp.Operations[0].Status)
+	assert.Equal(t, "201", resp.Operations[1].Status)
+	assert.Equal(t, "400", resp.Operations[2].Status)
+	assert.Equal(t, "204", resp.Operations[3].Status)
+
+	group, err := p.GetGroup("1")
+	require.NoError(t, err)
+	assert.Len(t, group["members"], 1)
+}
+
+func TestScimAttributeMapping(t *testing.T) {
+	token := &ScimToken{}
+	assert.Error(t, token.SetAttributeMapping(ScimAttributeMapping{"displayName": "displayName"}))
+	assert.Error(t, token.SetAttributeMapping(ScimAttributeMapping{"email": "userName", "salary": "x"}))
+	require.NoError(t, token.SetAttributeMapping(ScimAttributeMapping{"email": "emails[type eq \"work\"].value"}))
+
+	p := &ScimProvisioner{Token: token, mapping: token.AttributeMapping()}
+	user := &User{}
+	require.NoError(t, p.applyUserResource(user, map[string]any{
+		"userName": "ignored",
+		"emails":   []any{map[string]any{"value": "Work@Example.com", "type": "work"}},
+	}))
+	assert.Equal(t, "work@example.com", user.Email)
+}
@@ -0,0 +1,414 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 安全策略违规类型
+const (
+	PolicyViolationTwoFactor       = "two_factor_disabled" // 未开启双因素认证
+	PolicyViolationPasswordExpired = "password_expired"    // 密码超过最长使用期限
+	PolicyViolationLoginIP         = "login_ip_outside"    // 最近一次登录的 IP 不在白名单内
+)
+
+const (
+	minPolicyPasswordLength = 8
+	maxPolicyPasswordLength = 128
+	maxPolicyAllowlist      = 100
+)
+
+var (
+	ErrPolicyIPNotAllowed     = errors.New("access from this IP address is not allowed by your organization")
+	ErrPolicySessionExpired   = errors.New("session exceeded the maximum age set by your organization, please sign in again")
+	ErrPolicyTwoFactorMissing = errors.New("your organization requires two-factor authentication")
+)
+
+// GroupSecurityPolicy 组织安全策略：要求成员开启双因素认证、限制会话时长、
+// 控制台访问 IP 白名单和密码规则。用户属于多个组织时取最严格的组合
+type GroupSecurityPolicy struct {
+	ID                   uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt            time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt            time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID              uint      `json:"groupId" gorm:"uniqueIndex;not null"`
+	RequireTwoFactor     bool      `json:"requireTwoFactor"`
+	SessionMaxAgeMinutes int       `json:"sessionMaxAgeMinutes"`         // 登录后会话最长有效期，0 表示不限制
+	IPAllowlist          string    `json:"ipAllowlist" gorm:"type:text"` // IP 或 CIDR，逗号或换行分隔，空表示不限制
+	PasswordMinLength    int       `json:"passwordMinLength"`            // 0 表示不限制
+	PasswordRequireUpper bool      `json:"passwordRequireUpper"`         // 需包含大写字母
+	PasswordRequireLower bool      `json:"passwordRequireLower"`         // 需包含小写字母
+	PasswordRequireDigit bool      `json:"passwordRequireDigit"`         // 需包含数字
+	PasswordRequireOther bool      `json:"passwordRequireSymbol"`        // 需包含符号
+	PasswordMaxAgeDays   int       `json:"passwordMaxAgeDays"`           // 密码最长使用天数，0 表示不限制
+	MaskPII              bool      `json:"maskPii"`                      // 接口返回的对话中遮盖标记的个人信息，组织管理员除外
+	MaskPIITypes         string    `json:"maskPiiTypes"`                 // 遮盖的个人信息类型，逗号分隔，空表示全部
+	UpdatedBy            uint      `json:"updatedBy,omitempty" gorm:"index"`
+}
+
+func (GroupSecurityPolicy) TableName() string {
+	return "group_security_policies"
+}
+
+// Validate 校验并规范化策略
+func (p *GroupSecurityPolicy) Validate() error {
+	if p.SessionMaxAgeMinutes < 0 || p.PasswordMaxAgeDays < 0 {
+		return errors.New("durations must not be negative")
+	}
+	if p.PasswordMinLength != 0 && (p.PasswordMinLength < minPolicyPasswordLength || p.PasswordMinLength > maxPolicyPasswordLength) {
+		return fmt.Errorf("passwordMinLength must be between %d and %d", minPolicyPasswordLength, maxPolicyPasswordLength)
+	}
+	types, err := parsePIITypes(p.MaskPIITypes)
+	if err != nil {
+		return err
+	}
+	p.MaskPIITypes = strings.Join(types, ",")
+	nets, err := parseIPAllowlist(p.IPAllowlist)
+	if err != nil {
+		return err
+	}
+	if len(nets) > maxPolicyAllowlist {
+		return fmt.Errorf("ipAllowlist holds at most %d entries", maxPolicyAllowlist)
+	}
+	entries := make([]string, 0, len(nets))
+	for _, n := range nets {
+		entries = append(entries, n.String())
+	}
+	p.IPAllowlist = strings.Join(entries, "\n")
+	return nil
+}
+
+// AllowsIP IP 是否在本组织的白名单内，未配置白名单时允许所有地址
+func (p *GroupSecurityPolicy) AllowsIP(ip string) bool {
+	e := &EffectiveSecurityPolicy{}
+	e.merge(p)
+	return e.AllowsIP(ip)
+}
+
+// parseIPAllowlist 解析白名单，单个 IP 视为 /32 或 /128
+func parseIPAllowlist(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' || r == ' ' || r == '\r' }) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// PasswordPolicy 合并后的密码规则
+type PasswordPolicy struct {
+	MinLength     int  `json:"minLength"`
+	RequireUpper  bool `json:"requireUpper"`
+	RequireLower  bool `json:"requireLower"`
+	RequireDigit  bool `json:"requireDigit"`
+	RequireSymbol bool `json:"requireSymbol"`
+	MaxAgeDays    int  `json:"maxAgeDays"`
+}
+
+// Check 返回密码不满足的规则，全部满足时为空
+func (p *PasswordPolicy) Check(password string) []string {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	var problems []string
+	if n := len([]rune(password)); p.MinLength > 0 && n < p.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if p.RequireUpper && !upper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "a symbol")
+	}
+	return problems
+}
+
+// Expired 密码是否超过最长使用期限，从未修改过时以注册时间计算
+func (p *PasswordPolicy) Expired(user *User, now time.Time) bool {
+	if p.MaxAgeDays <= 0 {
+		return false
+	}
+	changed := user.CreatedAt
+	if user.LastPasswordChange != nil {
+		changed = *user.LastPasswordChange
+	}
+	return now.Sub(changed) > time.Duration(p.MaxAgeDays)*24*time.Hour
+}
+
+// EffectiveSecurityPolicy 用户所属各组织策略的最严格组合
+type EffectiveSecurityPolicy struct {
+	GroupIDs         []uint         `json:"groupIds"` // 策略来源组织
+	RequireTwoFactor bool           `json:"requireTwoFactor"`
+	SessionMaxAge    time.Duration  `json:"-"`
+	Password         PasswordPolicy `json:"password"`
+	allowlists       [][]*net.IPNet // 每个配置了白名单的组织，IP 需同时被所有白名单允许
+}
+
+// AllowsIP IP 是否被所有组织的白名单允许
+func (e *EffectiveSecurityPolicy) AllowsIP(ip string) bool {
+	if len(e.allowlists) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, nets := range e.allowlists {
+		allowed := false
+		for _, n := range nets {
+			if n.Contains(parsed) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// merge 合并一个组织的策略
+func (e *EffectiveSecurityPolicy) merge(p *GroupSecurityPolicy) {
+	e.GroupIDs = append(e.GroupIDs, p.GroupID)
+	e.RequireTwoFactor = e.RequireTwoFactor || p.RequireTwoFactor
+	if age := time.Duration(p.SessionMaxAgeMinutes) * time.Minute; age > 0 && (e.SessionMaxAge == 0 || age < e.SessionMaxAge) {
+		e.SessionMaxAge = age
+	}
+	if nets, err := parseIPAllowlist(p.IPAllowlist); err == nil && len(nets) > 0 {
+		e.allowlists = append(e.allowlists, nets)
+	}
+	if p.PasswordMinLength > e.Password.MinLength {
+		e.Password.MinLength = p.PasswordMinLength
+	}
+	e.Password.RequireUpper = e.Password.RequireUpper || p.PasswordRequireUpper
+	e.Password.RequireLower = e.Password.RequireLower || p.PasswordRequireLower
+	e.Password.RequireDigit = e.Password.RequireDigit || p.PasswordRequireDigit
+	e.Password.RequireSymbol = e.Password.RequireSymbol || p.PasswordRequireOther
+	if d := p.PasswordMaxAgeDays; d > 0 && (e.Password.MaxAgeDays == 0 || d < e.Password.MaxAgeDays) {
+		e.Password.MaxAgeDays = d
+	}
+}
+
+// GetGroupSecurityPolicy 组织的安全策略，未配置时返回空策略
+func GetGroupSecurityPolicy(db *gorm.DB, groupID uint) (*GroupSecurityPolicy, error) {
+	var p GroupSecurityPolicy
+	err := db.Where("group_id = ?", groupID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &GroupSecurityPolicy{GroupID: groupID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveGroupSecurityPolicy 校验后保存组织的安全策略
+func SaveGroupSecurityPolicy(db *gorm.DB, p *GroupSecurityPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	existing, err := GetGroupSecurityPolicy(db, p.GroupID)
+	if err != nil {
+		return err
+	}
+	p.ID = existing.ID
+	p.CreatedAt = existing.CreatedAt
+	return db.Save(p).Error
+}
+
+// GetEffectiveSecurityPolicy 用户所属（含创建）组织的合并策略；系统管理员不受限制，
+// 避免策略配置错误时无法登录处理
+func GetEffectiveSecurityPolicy(db *gorm.DB, user *User) (*EffectiveSecurityPolicy, error) {
+	effective := &EffectiveSecurityPolicy{}
+	if user == nil || user.IsAdmin() {
+		return effective, nil
+	}
+	var policies []GroupSecurityPolicy
+	err := db.Where("group_id IN (?) OR group_id IN (?)",
+		db.Model(&GroupMember{}).Select("group_id").Where("user_id = ?", user.ID),
+		db.Model(&Group{}).Select("id").Where("creator_id = ?", user.ID)).
+		Order("group_id").Find(&policies).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range policies {
+		effective.merge(&policies[i])
+	}
+	return effective, nil
+}
+
+// CheckPasswordPolicy 设置新密码前校验组织的密码规则
+func CheckPasswordPolicy(db *gorm.DB, user *User, password string) error {
+	policy, err := GetEffectiveSecurityPolicy(db, user)
+	if err != nil {
+		return err
+	}
+	if problems := policy.Password.Check(password); len(problems) > 0 {
+		return fmt.Errorf("your organization requires passwords with %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// CheckLoginSecurityPolicy 登录时校验 IP 白名单
+func CheckLoginSecurityPolicy(db *gorm.DB, user *User, clientIP string) error {
+	policy, err := GetEffectiveSecurityPolicy(db, user)
+	if err != nil {
+		return err
+	}
+	if !policy.AllowsIP(clientIP) {
+		return ErrPolicyIPNotAllowed
+	}
+	return nil
+}
+
+// twoFactorSetupPaths 未开启双因素认证的成员仍可访问的接口，用于完成设置或退出
+var twoFactorSetupPaths = []string{"/two-factor/", "/info", "/logout"}
+
+// enforceSecurityPolicy 会话中间件钩子：校验 IP 白名单、会话时长和双因素认证要求，
+// 不满足时中止请求并返回 false
+func enforceSecurityPolicy(c *gin.Context, db *gorm.DB, user *User, loginAt time.Time) bool {
+	policy, err := GetEffectiveSecurityPolicy(db, user)
+	if err != nil || len(policy.GroupIDs) == 0 {
+		return true
+	}
+	if !policy.AllowsIP(c.ClientIP()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": ErrPolicyIPNotAllowed.Error()})
+		return false
+	}
+	if policy.SessionMaxAge > 0 && !loginAt.IsZero() && time.Since(loginAt) > policy.SessionMaxAge {
+		session := sessions.Default(c)
+		session.Delete(constants.UserField)
+		session.Delete(constants.LoginAtField)
+		session.Save()
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "msg": ErrPolicySessionExpired.Error(), "sessionExpired": true})
+		return false
+	}
+	if policy.RequireTwoFactor && !user.TwoFactorEnabled {
+		path := c.FullPath()
+		for _, allowed := range twoFactorSetupPaths {
+			if strings.Contains(path, allowed) {
+				return true
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": ErrPolicyTwoFactorMissing.Error(), "twoFactorSetupRequired": true})
+		return false
+	}
+	return true
+}
+
+// sessionLoginAt 会话的登录时间，旧会话没有记录时取用户最后登录时间
+func sessionLoginAt(c *gin.Context, user *User) time.Time {
+	if v, ok := sessions.Default(c).Get(constants.LoginAtField).(int64); ok && v > 0 {
+		return time.Unix(v, 0)
+	}
+	if user.LastLogin != nil {
+		return *user.LastLogin
+	}
+	return time.Time{}
+}
+
+// SecurityPolicyViolation 成员违反策略的情况
+type SecurityPolicyViolation struct {
+	UserID      uint       `json:"userId"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"displayName,omitempty"`
+	Violations  []string   `json:"violations"`
+	LastLogin   *time.Time `json:"lastLogin,omitempty"`
+}
+
+// SecurityPolicyReport 组织的合规报告
+type SecurityPolicyReport struct {
+	GroupID     uint                      `json:"groupId"`
+	GeneratedAt time.Time                 `json:"generatedAt"`
+	Members     int                       `json:"members"`
+	Compliant   int                       `json:"compliant"`
+	Violations  []SecurityPolicyViolation `json:"violations"`
+}
+
+// BuildSecurityPolicyReport 检查组织每个成员是否满足本组织策略
+func BuildSecurityPolicyReport(db *gorm.DB, group *Group, now time.Time) (*SecurityPolicyReport, error) {
+	policy, err := GetGroupSecurityPolicy(db, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := GroupMemberIDs(db, group)
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if len(ids) > 0 {
+		if err := db.Where("id IN ?", ids).Order("id").Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	scoped := &EffectiveSecurityPolicy{}
+	scoped.merge(policy)
+	report := &SecurityPolicyReport{GroupID: group.ID, GeneratedAt: now, Members: len(users), Violations: []SecurityPolicyViolation{}}
+	for i := range users {
+		u := &users[i]
+		var violations []string
+		if policy.RequireTwoFactor && !u.TwoFactorEnabled {
+			violations = append(violations, PolicyViolationTwoFactor)
+		}
+		if scoped.Password.Expired(u, now) {
+			violations = append(violations, PolicyViolationPasswordExpired)
+		}
+		if u.LastLoginIP != "" && !scoped.AllowsIP(u.LastLoginIP) {
+			violations = append(violations, PolicyViolationLoginIP)
+		}
+		if len(violations) == 0 {
+			report.Compliant++
+			continue
+		}
+		report.Violations = append(report.Violations, SecurityPolicyViolation{
+			UserID:      u.ID,
+			Email:       u.Email,
+			DisplayName: u.DisplayName,
+			Violations:  violations,
+			LastLogin:   u.LastLogin,
+		})
+	}
+	return report, nil
+}
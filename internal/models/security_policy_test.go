@@ -0,0 +1,122 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupSecurityPolicy_Validate(t *testing.T) {
+	p := &GroupSecurityPolicy{IPAllowlist: "10.0.0.0/8, 192.168.1.7\n2001:db8::/32"}
+	require.NoError(t, p.Validate())
+	assert.Equal(t, "10.0.0.0/8\n192.168.1.7/32\n2001:db8::/32", p.IPAllowlist)
+	assert.True(t, p.AllowsIP("10.1.2.3"))
+	assert.True(t, p.AllowsIP("192.168.1.7"))
+	assert.False(t, p.AllowsIP("192.168.1.8"))
+	assert.False(t, p.AllowsIP("not-an-ip"))
+
+	assert.Error(t, (&GroupSecurityPolicy{IPAllowlist: "10.0.0.300"}).Validate())
+	assert.Error(t, (&GroupSecurityPolicy{IPAllowlist: "10.0.0.0/40"}).Validate())
+	assert.Error(t, (&GroupSecurityPolicy{PasswordMinLength: 4}).Validate())
+	assert.Error(t, (&GroupSecurityPolicy{SessionMaxAgeMinutes: -1}).Validate())
+	assert.True(t, (&GroupSecurityPolicy{}).AllowsIP("8.8.8.8"), "no allowlist allows everything")
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	p := &PasswordPolicy{MinLength: 10, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+	assert.Empty(t, p.Check("Secure-pass1"))
+	assert.Len(t, p.Check("short"), 4)
+	assert.Equal(t, []string{"a symbol"}, p.Check("Securepass1"))
+
+	now := time.Now()
+	old := now.Add(-100 * 24 * time.Hour)
+	user := &User{}
+	user.CreatedAt = old
+	p.MaxAgeDays = 90
+	assert.True(t, p.Expired(user, now), "never changed counts from registration")
+	user.LastPasswordChange = &now
+	assert.False(t, p.Expired(user, now))
+}
+
+func TestGetEffectiveSecurityPolicy(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &Group{}, &GroupMember{}, &GroupSecurityPolicy{})
+	user := &User{Email: "member@example.com"}
+	require.NoError(t, db.Create(user).Error)
+	g1 := &Group{Name: "a", CreatorID: 99}
+	g2 := &Group{Name: "b", CreatorID: user.ID}
+	g3 := &Group{Name: "c", CreatorID: 99}
+	require.NoError(t, db.Create(g1).Error)
+	require.NoError(t, db.Create(g2).Error)
+	require.NoError(t, db.Create(g3).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: user.ID, GroupID: g1.ID, Role: "member"}).Error)
+
+	require.NoError(t, SaveGroupSecurityPolicy(db, &GroupSecurityPolicy{GroupID: g1.ID, SessionMaxAgeMinutes: 60, PasswordMinLength: 12, IPAllowlist: "10.0.0.0/8"}))
+	require.NoError(t, SaveGroupSecurityPolicy(db, &GroupSecurityPolicy{GroupID: g2.ID, RequireTwoFactor: true, SessionMaxAgeMinutes: 30, PasswordRequireDigit: true, IPAllowlist: "10.1.0.0/16"}))
+	require.NoError(t, SaveGroupSecurityPolicy(db, &GroupSecurityPolicy{GroupID: g3.ID, RequireTwoFactor: true, SessionMaxAgeMinutes: 5}))
+
+	effective, err := GetEffectiveSecurityPolicy(db, user)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{g1.ID, g2.ID}, effective.GroupIDs, "only organizations the user belongs to")
+	assert.True(t, effective.RequireTwoFactor)
+	assert.Equal(t, 30*time.Minute, effective.SessionMaxAge)
+	assert.Equal(t, 12, effective.Password.MinLength)
+	assert.True(t, effective.Password.RequireDigit)
+	assert.True(t, effective.AllowsIP("10.1.2.3"))
+	assert.False(t, effective.AllowsIP("10.2.0.1"), "every allowlist must permit the address")
+
+	assert.Error(t, CheckPasswordPolicy(db, user, "shortpass1"))
+	assert.NoError(t, CheckPasswordPolicy(db, user, "longenoughpass1"))
+	assert.ErrorIs(t, CheckLoginSecurityPolicy(db, user, "172.16.0.1"), ErrPolicyIPNotAllowed)
+	assert.NoError(t, CheckLoginSecurityPolicy(db, user, "10.1.0.1"))
+
+	admin := &User{Email: "admin@example.com", Role: RoleAdmin}
+	require.NoError(t, db.Create(admin).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: admin.ID, GroupID: g1.ID, Role: "member"}).Error)
+	effective, err = GetEffectiveSecurityPolicy(db, admin)
+	require.NoError(t, err)
+	assert.Empty(t, effective.GroupIDs, "system admins are exempt")
+}
+
+func TestSaveGroupSecurityPolicy_Replaces(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &GroupSecurityPolicy{})
+	require.NoError(t, SaveGroupSecurityPolicy(db, &GroupSecurityPolicy{GroupID: 1, RequireTwoFactor: true}))
+	require.NoError(t, SaveGroupSecurityPolicy(db, &GroupSecurityPolicy{GroupID: 1, PasswordMinLength: 10}))
+
+	var count int64
+	db.Model(&GroupSecurityPolicy{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+	p, err := GetGroupSecurityPolicy(db, 1)
+	require.NoError(t, err)
+	assert.False(t, p.RequireTwoFactor)
+	assert.Equal(t, 10, p.PasswordMinLength)
+
+	p, err = GetGroupSecurityPolicy(db, 2)
+	require.NoError(t, err)
+	assert.Zero(t, p.ID, "unconfigured organizations get an empty policy")
+}
+
+func TestBuildSecurityPolicyReport(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &User{}, &Group{}, &GroupMember{}, &GroupSecurityPolicy{})
+	now := time.Now()
+	recent := now.Add(-24 * time.Hour)
+	owner := &User{Email: "owner@example.com", TwoFactorEnabled: true, LastPasswordChange: &recent, LastLoginIP: "10.0.0.1"}
+	lax := &User{Email: "lax@example.com", LastLoginIP: "8.8.8.8"}
+	require.NoError(t, db.Create(owner).Error)
+	lax.CreatedAt = now.Add(-365 * 24 * time.Hour)
+	require.NoError(t, db.Create(lax).Error)
+	group := &Group{Name: "acme", CreatorID: owner.ID}
+	require.NoError(t, db.Create(group).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: owner.ID, GroupID: group.ID, Role: "admin"}).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: lax.ID, GroupID: group.ID, Role: "member"}).Error)
+	require.NoError(t, SaveGroupSecurityPolicy(db, &GroupSecurityPolicy{GroupID: group.ID, RequireTwoFactor: true, PasswordMaxAgeDays: 90, IPAllowlist: "10.0.0.0/8"}))
+
+	report, err := BuildSecurityPolicyReport(db, group, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Members)
+	assert.Equal(t, 1, report.Compliant)
+	require.Len(t, report.Violations, 1)
+	assert.Equal(t, lax.ID, report.Violations[0].UserID)
+	assert.Equal(t, []string{PolicyViolationTwoFactor, PolicyViolationPasswordExpired, PolicyViolationLoginIP}, report.Violations[0].Violations)
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SessionAnnotationMaxBody     = 4000
+	SessionAnnotationMaxMentions = 20
+	// AnnotationLinkMaxTTL 分享链接的最长有效期
+	AnnotationLinkMaxTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrAnnotationTarget      = errors.New("annotation needs a turn index or a recording offset")
+	ErrAnnotationBody        = errors.New("annotation body must be 1-4000 characters")
+	ErrTooManyMentions       = errors.New("at most 20 mentions are allowed")
+	ErrInvalidAnnotationLink = errors.New("annotation link is invalid or expired")
+)
+
+// SessionAnnotation 会话的时间点批注，定位到对话轮次或录音中的某一时刻，可提及同事
+type SessionAnnotation struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID int64     `json:"assistantId" gorm:"index:idx_session_annotation"`
+	SessionID   string    `json:"sessionId" gorm:"size:128;index:idx_session_annotation"`
+	AuthorID    uint      `json:"authorId" gorm:"index"`
+	TurnIndex   *int      `json:"turnIndex,omitempty"` // 对话轮次，从 1 开始
+	OffsetMs    *int64    `json:"offsetMs,omitempty"`  // 录音中的位置（毫秒）
+	Body        string    `json:"body" gorm:"type:text"`
+	// LinkVersion 分享链接版本，递增后已分享的链接全部失效
+	LinkVersion int    `json:"-"`
+	MentionIDs  []uint `json:"mentions" gorm:"-"`
+}
+
+// TableName 指定表名
+func (SessionAnnotation) TableName() string {
+	return "session_annotations"
+}
+
+// SessionAnnotationMention 批注中提及的用户
+type SessionAnnotationMention struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	AnnotationID uint      `json:"annotationId" gorm:"uniqueIndex:idx_annotation_mention"`
+	UserID       uint      `json:"userId" gorm:"uniqueIndex:idx_annotation_mention;index"`
+}
+
+// TableName 指定表名
+func (SessionAnnotationMention) TableName() string {
+	return "session_annotation_mentions"
+}
+
+// Validate 检查批注位置、内容和提及人数，提及去重
+func (a *SessionAnnotation) Validate() error {
+	if a.TurnIndex == nil && a.OffsetMs == nil {
+		return ErrAnnotationTarget
+	}
+	if (a.TurnIndex != nil && *a.TurnIndex < 1) || (a.OffsetMs != nil && *a.OffsetMs < 0) {
+		return ErrAnnotationTarget
+	}
+	a.Body = strings.TrimSpace(a.Body)
+	if a.Body == "" || len([]rune(a.Body)) > SessionAnnotationMaxBody {
+		return ErrAnnotationBody
+	}
+	seen := map[uint]bool{}
+	mentions := []uint{}
+	for _, id := range a.MentionIDs {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		mentions = append(mentions, id)
+	}
+	if len(mentions) > SessionAnnotationMaxMentions {
+		return ErrTooManyMentions
+	}
+	a.MentionIDs = mentions
+	return nil
+}
+
+// CreateSessionAnnotation 创建批注及其提及
+func CreateSessionAnnotation(db *gorm.DB, annotation *SessionAnnotation) error {
+	if err := annotation.Validate(); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(annotation).Error; err != nil {
+			return err
+		}
+		for _, userID := range annotation.MentionIDs {
+			if err := tx.Create(&SessionAnnotationMention{AnnotationID: annotation.ID, UserID: userID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadAnnotationMentions 填充批注的提及用户
+func loadAnnotationMentions(db *gorm.DB, annotations []SessionAnnotation) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(annotations))
+	index := make(map[uint]int, len(annotations))
+	for i := range annotations {
+		ids[i] = annotations[i].ID
+		index[annotations[i].ID] = i
+		annotations[i].MentionIDs = []uint{}
+	}
+	var mentions []SessionAnnotationMention
+	if err := db.Where("annotation_id IN ?", ids).Order("id").Find(&mentions).Error; err != nil {
+		return err
+	}
+	for _, m := range mentions {
+		a := &annotations[index[m.AnnotationID]]
+		a.MentionIDs = append(a.MentionIDs, m.UserID)
+	}
+	return nil
+}
+
+// ListSessionAnnotations 按位置顺序获取会话的批注
+func ListSessionAnnotations(db *gorm.DB, assistantID int64, sessionID string) ([]SessionAnnotation, error) {
+	annotations := []SessionAnnotation{}
+	if err := db.Where("assistant_id = ? AND session_id = ?", assistantID, sessionID).
+		Order("turn_index, offset_ms, id").Find(&annotations).Error; err != nil {
+		return nil, err
+	}
+	return annotations, loadAnnotationMentions(db, annotations)
+}
+
+// GetSessionAnnotation 获取助手下的一条批注
+func GetSessionAnnotation(db *gorm.DB, assistantID int64, id uint) (*SessionAnnotation, error) {
+	var annotation SessionAnnotation
+	if err := db.Where("id = ? AND assistant_id = ?", id, assistantID).First(&annotation).Error; err != nil {
+		return nil, err
+	}
+	list := []SessionAnnotation{annotation}
+	if err := loadAnnotationMentions(db, list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// ListMentionedAnnotations 获取提及该用户的批注，新的在前
+func ListMentionedAnnotations(db *gorm.DB, userID uint, limit int) ([]SessionAnnotation, error) {
+	annotations := []SessionAnnotation{}
+	if err := db.Where("id IN (?)", db.Model(&SessionAnnotationMention{}).Select("annotation_id").Where("user_id = ?", userID)).
+		Order("id DESC").Limit(limit).Find(&annotations).Error; err != nil {
+		return nil, err
+	}
+	return annotations, loadAnnotationMentions(db, annotations)
+}
+
+// DeleteSessionAnnotation 删除批注及其提及，已分享的链接随之失效
+func DeleteSessionAnnotation(db *gorm.DB, annotation *SessionAnnotation) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("annotation_id = ?", annotation.ID).Delete(&SessionAnnotationMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(annotation).Error
+	})
+}
+
+// RevokeAnnotationLinks 使批注已分享的链接全部失效
+func RevokeAnnotationLinks(db *gorm.DB, annotation *SessionAnnotation) error {
+	annotation.LinkVersion++
+	return db.Model(annotation).Update("link_version", annotation.LinkVersion).Error
+}
+
+// annotationLinkClaims 分享链接中签名的内容
+type annotationLinkClaims struct {
+	AnnotationID uint  `json:"a"`
+	Version      int   `json:"v"`
+	ExpiresAt    int64 `json:"e"`
+}
+
+// annotationLinkPurpose 批注分享令牌的签名用途
+const annotationLinkPurpose = "annotation-link"
+
+// SignAnnotationLink 生成批注分享令牌，持有者在到期前无需登录即可打开批注所在的会话回放
+func SignAnnotationLink(secret string, annotation *SessionAnnotation, expiresAt time.Time) (string, error) {
+	return signLink(secret, annotationLinkPurpose, annotationLinkClaims{
+		AnnotationID: annotation.ID,
+		Version:      annotation.LinkVersion,
+		ExpiresAt:    expiresAt.Unix(),
+	})
+}
+
+// VerifyAnnotationLink 校验分享令牌并返回批注，签名错误、过期、已撤销或批注已删除时返回 ErrInvalidAnnotationLink
+func VerifyAnnotationLink(db *gorm.DB, secret, token string, now time.Time) (*SessionAnnotation, error) {
+	var claims annotationLinkClaims
+	if err := verifyLink(secret, annotationLinkPurpose, token, &claims); err != nil || now.Unix() > claims.ExpiresAt {
+		return nil, ErrInvalidAnnotationLink
+	}
+	var annotation SessionAnnotation
+	if err := db.First(&annotation, claims.AnnotationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAnnotationLink
+		}
+		return nil, err
+	}
+	if annotation.LinkVersion != claims.Version {
+		return nil, ErrInvalidAnnotationLink
+	}
+	list := []SessionAnnotation{annotation}
+	if err := loadAnnotationMentions(db, list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// AnnotationRecordingOffset 批注在录音中的位置：优先使用批注的偏移，否则取所在轮次在录音中的位置
+func AnnotationRecordingOffset(annotation *SessionAnnotation, replay *SessionReplay) *int64 {
+	if annotation.OffsetMs != nil {
+		return annotation.OffsetMs
+	}
+	if replay == nil || annotation.TurnIndex == nil {
+		return nil
+	}
+	for _, turn := range replay.Turns {
+		if turn.Index == *annotation.TurnIndex {
+			return turn.RecordingOffsetMs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionAnnotationValidate(t *testing.T) {
+	turn, zero := 2, 0
+	offset := int64(1500)
+
+	a := &SessionAnnotation{Body: "hi"}
+	assert.ErrorIs(t, a.Validate(), ErrAnnotationTarget)
+	a = &SessionAnnotation{TurnIndex: &zero, Body: "hi"}
+	assert.ErrorIs(t, a.Validate(), ErrAnnotationTarget)
+	a = &SessionAnnotation{OffsetMs: &offset, Body: "   "}
+	assert.ErrorIs(t, a.Validate(), ErrAnnotationBody)
+	a = &SessionAnnotation{OffsetMs: &offset, Body: strings.Repeat("字", SessionAnnotationMaxBody+1)}
+	assert.ErrorIs(t, a.Validate(), ErrAnnotationBody)
+
+	mentions := make([]uint, 0, SessionAnnotationMaxMentions+1)
+	for i := 1; i <= SessionAnnotationMaxMentions+1; i++ {
+		mentions = append(mentions, uint(i))
+	}
+	a = &SessionAnnotation{TurnIndex: &turn, Body: "hi", MentionIDs: mentions}
+	assert.ErrorIs(t, a.Validate(), ErrTooManyMentions)
+
+	// 重复和无效的提及被去掉
+	a = &SessionAnnotation{TurnIndex: &turn, Body: " check this ", MentionIDs: []uint{3, 0, 3, 4}}
+	require.NoError(t, a.Validate())
+	assert.Equal(t, "check this", a.Body)
+	assert.Equal(t, []uint{3, 4}, a.MentionIDs)
+}
+
+func TestSessionAnnotationMentions(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SessionAnnotation{}, &SessionAnnotationMention{})
+	turn1, turn3 := 1, 3
+	late := &SessionAnnotation{AssistantID: 1, SessionID: "s1", AuthorID: 1, TurnIndex: &turn3, Body: "late", MentionIDs: []uint{2}}
+	early := &SessionAnnotation{AssistantID: 1, SessionID: "s1", AuthorID: 1, TurnIndex: &turn1, Body: "early", MentionIDs: []uint{2, 3}}
+	other := &SessionAnnotation{AssistantID: 1, SessionID: "s2", AuthorID: 1, TurnIndex: &turn1, Body: "other"}
+	for _, a := range []*SessionAnnotation{late, early, other} {
+		require.NoError(t, CreateSessionAnnotation(db, a))
+	}
+
+	list, err := ListSessionAnnotations(db, 1, "s1")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "early", list[0].Body)
+	assert.Equal(t, []uint{2, 3}, list[0].MentionIDs)
+	assert.Equal(t, "late", list[1].Body)
+
+	mentioned, err := ListMentionedAnnotations(db, 2, 10)
+	require.NoError(t, err)
+	require.Len(t, mentioned, 2)
+	assert.Equal(t, early.ID, mentioned[0].ID)
+
+	mentioned, err = ListMentionedAnnotations(db, 3, 10)
+	require.NoError(t, err)
+	require.Len(t, mentioned, 1)
+
+	require.NoError(t, DeleteSessionAnnotation(db, early))
+	mentioned, err = ListMentionedAnnotations(db, 3, 10)
+	require.NoError(t, err)
+	assert.Empty(t, mentioned)
+	_, err = GetSessionAnnotation(db, 1, early.ID)
+	assert.Error(t, err)
+}
+
+func TestAnnotationLink(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SessionAnnotation{}, &SessionAnnotationMention{})
+	offset := int64(4200)
+	annotation := &SessionAnnotation{AssistantID: 1, SessionID: "s1", AuthorID: 1, OffsetMs: &offset, Body: "here"}
+	require.NoError(t, CreateSessionAnnotation(db, annotation))
+
+	now := time.Now()
+	token, err := SignAnnotationLink("secret", annotation, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	got, err := VerifyAnnotationLink(db, "secret", token, now)
+	require.NoError(t, err)
+	assert.Equal(t, annotation.ID, got.ID)
+
+	_, err = VerifyAnnotationLink(db, "other", token, now)
+	assert.ErrorIs(t, err, ErrInvalidAnnotationLink)
+	_, err = VerifyAnnotationLink(db, "secret", token+"x", now)
+	assert.ErrorIs(t, err, ErrInvalidAnnotationLink)
+	_, err = VerifyAnnotationLink(db, "secret", token, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidAnnotationLink)
+
+	// 撤销后旧链接失效，新签发的链接可用
+	require.NoError(t, RevokeAnnotationLinks(db, annotation))
+	_, err = VerifyAnnotationLink(db, "secret", token, now)
+	assert.ErrorIs(t, err, ErrInvalidAnnotationLink)
+	token, err = SignAnnotationLink("secret", annotation, now.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = VerifyAnnotationLink(db, "secret", token, now)
+	require.NoError(t, err)
+
+	require.NoError(t, DeleteSessionAnnotation(db, annotation))
+	_, err = VerifyAnnotationLink(db, "secret", token, now)
+	assert.ErrorIs(t, err, ErrInvalidAnnotationLink)
+}
+
+func TestAnnotationRecordingOffset(t *testing.T) {
+	turn := 2
+	offset := int64(900)
+	turnOffset := int64(3000)
+	replay := &SessionReplay{Turns: []ReplayTurn{{Index: 1}, {Index: 2, RecordingOffsetMs: &turnOffset}}}
+
+	assert.Equal(t, &offset, AnnotationRecordingOffset(&SessionAnnotation{OffsetMs: &offset, TurnIndex: &turn}, replay))
+	assert.Equal(t, &turnOffset, AnnotationRecordingOffset(&SessionAnnotation{TurnIndex: &turn}, replay))
+	assert.Nil(t, AnnotationRecordingOffset(&SessionAnnotation{TurnIndex: &turn}, nil))
+}
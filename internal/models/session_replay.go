@@ -0,0 +1,229 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"gorm.io/gorm"
+)
+
+// 会话事件类型，补充聊天记录中没有的过程数据
+const (
+	SessionEventKnowledgeRetrieval = "kb_retrieval" // 知识库检索，Output 为命中的片段
+)
+
+// SessionEvent 会话过程事件，用于质检回放时还原每一轮的检索等步骤
+type SessionEvent struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	SessionID   string    `json:"sessionId" gorm:"size:128;index"`
+	AssistantID int64     `json:"assistantId" gorm:"index"`
+	Kind        string    `json:"kind" gorm:"size:32"`
+	Name        string    `json:"name" gorm:"size:255"` // 知识库 key 等
+	At          time.Time `json:"at"`                   // 事件开始时间
+	LatencyMs   int64     `json:"latencyMs"`
+	Input       string    `json:"input" gorm:"type:text"`
+	Output      string    `json:"output" gorm:"type:text"` // JSON
+}
+
+// TableName 指定表名
+func (SessionEvent) TableName() string {
+	return "session_events"
+}
+
+// RecordKnowledgeRetrieval 记录一次知识库检索及命中的片段，没有会话ID时不记录
+func RecordKnowledgeRetrieval(db *gorm.DB, sessionID string, assistantID int64, knowledgeKey, query string, results []knowledge.SearchResult, started time.Time) error {
+	if sessionID == "" {
+		return nil
+	}
+	output, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return db.Create(&SessionEvent{
+		SessionID:   sessionID,
+		AssistantID: assistantID,
+		Kind:        SessionEventKnowledgeRetrieval,
+		Name:        knowledgeKey,
+		At:          started,
+		LatencyMs:   time.Since(started).Milliseconds(),
+		Input:       query,
+		Output:      string(output),
+	}).Error
+}
+
+// ReplayRetrieval 回放中的一次知识库检索
+type ReplayRetrieval struct {
+	KnowledgeKey string                   `json:"knowledgeKey"`
+	Query        string                   `json:"query"`
+	OffsetMs     int64                    `json:"offsetMs"`
+	LatencyMs    int64                    `json:"latencyMs"`
+	Chunks       []knowledge.SearchResult `json:"chunks"`
+}
+
+// ReplayTurn 回放中的一轮对话，偏移量均相对会话开始时间
+type ReplayTurn struct {
+	Index             int               `json:"index"`
+	LogID             int64             `json:"logId"`
+	StartedAt         time.Time         `json:"startedAt"`
+	OffsetMs          int64             `json:"offsetMs"`
+	RecordingOffsetMs *int64            `json:"recordingOffsetMs,omitempty"` // 在通话录音中的位置
+	UserMessage       string            `json:"userMessage"`
+	AgentMessage      string            `json:"agentMessage"`
+	AudioURL          string            `json:"audioUrl,omitempty"`
+	Model             string            `json:"model,omitempty"`
+	LatencyMs         int64             `json:"latencyMs"` // LLM 调用耗时
+	PromptTokens      int               `json:"promptTokens"`
+	CompletionTokens  int               `json:"completionTokens"`
+	ToolCalls         []ToolCallInfo    `json:"toolCalls"`
+	Retrievals        []ReplayRetrieval `json:"retrievals"`
+}
+
+// SessionReplay 质检用的完整会话回放
+type SessionReplay struct {
+	SessionID    string              `json:"sessionId"`
+	AssistantID  int64               `json:"assistantId"`
+	UserID       uint                `json:"userId"`
+	ChatType     string              `json:"chatType"`
+	StartedAt    time.Time           `json:"startedAt"`
+	EndedAt      time.Time           `json:"endedAt"`
+	DurationMs   int64               `json:"durationMs"`
+	RecordingURL string              `json:"recordingUrl,omitempty"`
+	AvgLatencyMs int64               `json:"avgLatencyMs"`
+	Turns        []ReplayTurn        `json:"turns"`
+	Reviews      []QAReview          `json:"reviews"`
+	Annotations  []SessionAnnotation `json:"annotations"`
+}
+
+// BuildSessionReplay 根据聊天记录、会话事件和 SIP 通话记录重建会话，
+// 会话不存在或不属于该助手时返回 gorm.ErrRecordNotFound
+func BuildSessionReplay(db *gorm.DB, assistantID int64, sessionID string) (*SessionReplay, error) {
+	var logs []ChatSessionLog
+	if err := db.Where("session_id = ? AND assistant_id = ?", sessionID, assistantID).
+		Order("created_at ASC, id ASC").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	replay := &SessionReplay{
+		SessionID:   sessionID,
+		AssistantID: assistantID,
+		UserID:      logs[0].UserID,
+		ChatType:    logs[0].ChatType,
+		Turns:       make([]ReplayTurn, 0, len(logs)),
+		Reviews:     []QAReview{},
+	}
+	var totalLatency int64
+	for _, log := range logs {
+		turn := ReplayTurn{
+			LogID:        log.ID,
+			UserMessage:  log.UserMessage,
+			AgentMessage: log.AgentMessage,
+			AudioURL:     log.AudioURL,
+			StartedAt:    log.CreatedAt.Add(-time.Duration(log.Duration) * time.Second),
+			ToolCalls:    []ToolCallInfo{},
+			Retrievals:   []ReplayRetrieval{},
+		}
+		var usage LLMUsage
+		if log.LLMUsage != "" && json.Unmarshal([]byte(log.LLMUsage), &usage) == nil {
+			turn.Model = usage.Model
+			turn.LatencyMs = usage.Duration
+			turn.PromptTokens = usage.PromptTokens
+			turn.CompletionTokens = usage.CompletionTokens
+			if !usage.StartTime.IsZero() {
+				turn.StartedAt = usage.StartTime
+			}
+			if len(usage.ToolCalls) > 0 {
+				turn.ToolCalls = usage.ToolCalls
+			}
+		}
+		totalLatency += turn.LatencyMs
+		replay.Turns = append(replay.Turns, turn)
+		if log.CreatedAt.After(replay.EndedAt) {
+			replay.EndedAt = log.CreatedAt
+		}
+	}
+	sort.SliceStable(replay.Turns, func(i, j int) bool {
+		return replay.Turns[i].StartedAt.Before(replay.Turns[j].StartedAt)
+	})
+	replay.AvgLatencyMs = totalLatency / int64(len(replay.Turns))
+
+	var events []SessionEvent
+	if err := db.Where("session_id = ? AND assistant_id = ?", sessionID, assistantID).
+		Order("at ASC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	replay.StartedAt = replay.Turns[0].StartedAt
+	if len(events) > 0 && events[0].At.Before(replay.StartedAt) {
+		replay.StartedAt = events[0].At
+	}
+
+	// 检索发生在 LLM 调用之前，归入其后开始的第一轮
+	for _, event := range events {
+		if event.Kind != SessionEventKnowledgeRetrieval {
+			continue
+		}
+		retrieval := ReplayRetrieval{
+			KnowledgeKey: event.Name,
+			Query:        event.Input,
+			OffsetMs:     event.At.Sub(replay.StartedAt).Milliseconds(),
+			LatencyMs:    event.LatencyMs,
+			Chunks:       []knowledge.SearchResult{},
+		}
+		_ = json.Unmarshal([]byte(event.Output), &retrieval.Chunks)
+		idx := len(replay.Turns) - 1
+		for i := range replay.Turns {
+			if !replay.Turns[i].StartedAt.Before(event.At) {
+				idx = i
+				break
+			}
+		}
+		replay.Turns[idx].Retrievals = append(replay.Turns[idx].Retrievals, retrieval)
+	}
+
+	// SIP 通话的会话ID即 Call-ID，录音从接通开始
+	var recordingStart *time.Time
+	var call SipCall
+	if err := db.Where("call_id = ?", sessionID).First(&call).Error; err == nil {
+		replay.RecordingURL = call.RecordURL
+		recordingStart = &call.StartTime
+		if call.AnswerTime != nil {
+			recordingStart = call.AnswerTime
+		}
+		if call.EndTime != nil && call.EndTime.After(replay.EndedAt) {
+			replay.EndedAt = *call.EndTime
+		}
+	}
+
+	for i := range replay.Turns {
+		turn := &replay.Turns[i]
+		turn.Index = i + 1
+		turn.OffsetMs = turn.StartedAt.Sub(replay.StartedAt).Milliseconds()
+		if recordingStart != nil {
+			offset := turn.StartedAt.Sub(*recordingStart).Milliseconds()
+			if offset < 0 {
+				offset = 0
+			}
+			turn.RecordingOffsetMs = &offset
+		}
+	}
+	replay.DurationMs = replay.EndedAt.Sub(replay.StartedAt).Milliseconds()
+
+	reviews, err := ListQAReviews(db, assistantID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	replay.Reviews = reviews
+
+	annotations, err := ListSessionAnnotations(db, assistantID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	replay.Annotations = annotations
+	return replay, nil
+}
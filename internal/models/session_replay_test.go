@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/knowledge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBuildSessionReplay(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &ChatSessionLog{}, &SessionEvent{}, &SipCall{}, &QAReview{}, &SessionAnnotation{}, &SessionAnnotationMention{})
+	start := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	_, err := BuildSessionReplay(db, 1, "call-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	answered := start.Add(-2 * time.Second)
+	require.NoError(t, db.Create(&SipCall{CallID: "call-1", StartTime: start.Add(-5 * time.Second), AnswerTime: &answered, RecordURL: "/media/call-1.wav"}).Error)
+
+	usage := func(offset time.Duration, latency int64, calls ...ToolCallInfo) *LLMUsage {
+		return &LLMUsage{Model: "gpt", StartTime: start.Add(offset), Duration: latency, ToolCalls: calls}
+	}
+	second := usage(10*time.Second, 300, ToolCallInfo{Name: "lookup_order", Arguments: `{"id":"42"}`, Output: "shipped", Duration: 20})
+	_, err = CreateChatSessionLogWithUsage(db, 9, 1, ChatTypeRealtime, "call-1", "where is my order", "it shipped", "", 1, second)
+	require.NoError(t, err)
+	_, err = CreateChatSessionLogWithUsage(db, 9, 1, ChatTypeRealtime, "call-1", "hello", "hi", "", 1, usage(0, 500))
+	require.NoError(t, err)
+	// Another assistant's log in the same session is not part of the replay
+	_, err = CreateChatSessionLogWithUsage(db, 9, 2, ChatTypeRealtime, "call-1", "x", "y", "", 1, usage(0, 1))
+	require.NoError(t, err)
+
+	chunks := []knowledge.SearchResult{{Content: "Orders ship in 2 days", Score: 0.9, Source: "faq.md"}}
+	require.NoError(t, RecordKnowledgeRetrieval(db, "call-1", 1, "kb-1", "where is my order", chunks, start.Add(9*time.Second)))
+	require.NoError(t, CreateQAReview(db, &QAReview{AssistantID: 1, SessionID: "call-1", ReviewerID: 9, Score: 4}))
+
+	replay, err := BuildSessionReplay(db, 1, "call-1")
+	require.NoError(t, err)
+	require.Len(t, replay.Turns, 2)
+	assert.Equal(t, "/media/call-1.wav", replay.RecordingURL)
+	assert.Equal(t, int64(400), replay.AvgLatencyMs)
+	assert.Len(t, replay.Reviews, 1)
+
+	first, last := replay.Turns[0], replay.Turns[1]
+	assert.Equal(t, "hello", first.UserMessage)
+	assert.Equal(t, int64(0), first.OffsetMs)
+	require.NotNil(t, first.RecordingOffsetMs)
+	assert.Equal(t, int64(2000), *first.RecordingOffsetMs)
+	assert.Empty(t, first.Retrievals)
+
+	assert.Equal(t, 2, last.Index)
+	assert.Equal(t, int64(10000), last.OffsetMs)
+	require.Len(t, last.ToolCalls, 1)
+	assert.Equal(t, "shipped", last.ToolCalls[0].Output)
+	require.Len(t, last.Retrievals, 1)
+	assert.Equal(t, int64(9000), last.Retrievals[0].OffsetMs)
+	assert.Equal(t, "faq.md", last.Retrievals[0].Chunks[0].Source)
+
+	body, err := json.Marshal(replay)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"recordingOffsetMs":12000`)
+}
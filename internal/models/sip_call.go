@@ -64,6 +64,10 @@ type SipCall struct {
 	GroupID *uint `json:"groupId,omitempty" gorm:"index"` // 关联到组织（可选）
 	Group   Group `json:"group,omitempty" gorm:"foreignKey:GroupID"`
 
+	// 地区
+	Region     string `json:"region,omitempty" gorm:"size:32;index"` // 处理通话的实例所在地区
+	PeerRegion string `json:"peerRegion,omitempty" gorm:"size:32"`   // 被叫固定在其他地区时转交到的地区
+
 	// 错误信息
 	ErrorCode    int    `json:"errorCode,omitempty"`                    // 错误代码
 	ErrorMessage string `json:"errorMessage,omitempty" gorm:"size:500"` // 错误消息
@@ -71,6 +75,16 @@ type SipCall struct {
 	// 通话记录
 	RecordURL string `json:"recordUrl,omitempty" gorm:"size:500"` // 通话录音文件URL
 
+	// 录音同意
+	ConsentRegion string                 `json:"consentRegion,omitempty" gorm:"size:32"`       // 匹配的同意策略地区
+	ConsentStatus RecordingConsentStatus `json:"consentStatus,omitempty" gorm:"size:20;index"` // not_required, granted, declined, timeout, unavailable
+	ConsentDigit  string                 `json:"consentDigit,omitempty" gorm:"size:1"`         // 对方按下的确认键
+	ConsentAt     *time.Time             `json:"consentAt,omitempty"`                          // 确认时间
+
+	// 呼入号码（DID）
+	DIDNumberID *uint    `json:"didNumberId,omitempty" gorm:"column:did_number_id;index"` // 匹配的 DID
+	DIDRoute    DIDRoute `json:"didRoute,omitempty" gorm:"column:did_route;size:16"`      // assistant, agent, rejected
+
 	// 转录信息
 	Transcription       string `json:"transcription,omitempty" gorm:"type:text"`     // 转录文本
 	TranscriptionStatus string `json:"transcriptionStatus,omitempty" gorm:"size:20"` // 转录状态：pending, processing, completed, failed
@@ -79,6 +93,12 @@ type SipCall struct {
 	// 元数据
 	Metadata string `json:"metadata,omitempty" gorm:"type:text"` // JSON格式的额外信息
 	Notes    string `json:"notes,omitempty" gorm:"type:text"`    // 备注
+
+	// 成本明细，通话结束后按价格表计算
+	CallCost `gorm:"embedded"`
+
+	// 未接来电提醒的发送时间
+	MissedNotifiedAt *time.Time `json:"-" gorm:"index"`
 }
 
 // TableName 指定表名
@@ -127,3 +147,26 @@ func GetSipCallsByStatus(db *gorm.DB, status SipCallStatus, limit int) ([]SipCal
 	err := query.Find(&sipCalls).Error
 	return sipCalls, err
 }
+
+// ClaimMissedCalls 取出 since 之后结束、未接通且尚未提醒的呼入通话，并标记为已提醒，
+// 每通未接来电只提醒一次
+func ClaimMissedCalls(db *gorm.DB, since, now time.Time, limit int) ([]SipCall, error) {
+	var calls []SipCall
+	err := db.Where("direction = ? AND answer_time IS NULL AND end_time IS NOT NULL AND end_time >= ? AND user_id IS NOT NULL AND missed_notified_at IS NULL",
+		SipCallDirectionInbound, since).
+		Order("id").Limit(limit).Find(&calls).Error
+	if err != nil || len(calls) == 0 {
+		return nil, err
+	}
+	claimed := calls[:0]
+	for _, call := range calls {
+		result := db.Model(&SipCall{}).Where("id = ? AND missed_notified_at IS NULL", call.ID).Update("missed_notified_at", now)
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = append(claimed, call)
+		}
+	}
+	return claimed, nil
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SipCallbackStatus 排队回拨的状态
+type SipCallbackStatus string
+
+const (
+	SipCallbackDeclined   SipCallbackStatus = "declined"    // 主叫选择继续排队
+	SipCallbackNoResponse SipCallbackStatus = "no_response" // 提示后未按键或挂断，未接受回拨
+	SipCallbackWaiting    SipCallbackStatus = "waiting"     // 已接受回拨，在队列中保留位置
+	SipCallbackDialing    SipCallbackStatus = "dialing"     // 轮到主叫，正在回拨
+	SipCallbackConnected  SipCallbackStatus = "connected"   // 回拨已接通并转给助手
+	SipCallbackCompleted  SipCallbackStatus = "completed"   // 回拨通话已结束
+	SipCallbackFailed     SipCallbackStatus = "failed"      // 回拨未接通或无法转接
+	SipCallbackExpired    SipCallbackStatus = "expired"     // 保留位置超时，未能回拨
+)
+
+// DefaultCallbackHold 接受回拨后在队列中保留位置的最长时间
+const DefaultCallbackHold = 30 * time.Minute
+
+// SipCallback 排队等待超过阈值时向主叫提供的回拨，记录提供、接受和回拨结果
+type SipCallback struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+	CallID         string            `json:"callId" gorm:"size:128;index;not null"` // 排队的呼入通话
+	CallbackCallID string            `json:"callbackCallId,omitempty" gorm:"size:128;index"`
+	UserID         *uint             `json:"userId,omitempty" gorm:"index"` // 被叫 SIP 用户的所属用户
+	GroupID        *uint             `json:"groupId,omitempty" gorm:"index"`
+	AssistantID    int64             `json:"assistantId" gorm:"index"`
+	SipUserID      uint              `json:"sipUserId"`
+	CallerNumber   string            `json:"callerNumber" gorm:"size:64"`
+	CallbackNumber string            `json:"callbackNumber,omitempty" gorm:"size:64"` // 主叫确认或输入的回拨号码
+	CallbackURI    string            `json:"callbackUri,omitempty" gorm:"size:256"`
+	Status         SipCallbackStatus `json:"status" gorm:"size:16;index"`
+	QueueWait      int               `json:"queueWait"` // 提供回拨前已排队的秒数
+	OfferedAt      time.Time         `json:"offeredAt" gorm:"index"`
+	AcceptedAt     *time.Time        `json:"acceptedAt,omitempty"`
+	DialedAt       *time.Time        `json:"dialedAt,omitempty"`
+	ConnectedAt    *time.Time        `json:"connectedAt,omitempty"`
+	EndedAt        *time.Time        `json:"endedAt,omitempty"`
+	Error          string            `json:"error,omitempty" gorm:"size:512"`
+}
+
+// TableName 指定表名
+func (SipCallback) TableName() string {
+	return "sip_callbacks"
+}
+
+// ValidCallbackNumber 检查回拨号码：3~20 位数字，可带前导 +
+func ValidCallbackNumber(number string) bool {
+	digits := strings.TrimPrefix(number, "+")
+	if len(digits) < 3 || len(digits) > 20 {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateSipCallback 保存回拨提供结果
+func CreateSipCallback(db *gorm.DB, callback *SipCallback) error {
+	return db.Create(callback).Error
+}
+
+// UpdateSipCallbackStatus 更新回拨状态，并记录进入该状态的时间
+func UpdateSipCallbackStatus(db *gorm.DB, callback *SipCallback, status SipCallbackStatus, errMsg string) error {
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case SipCallbackDialing:
+		callback.DialedAt = &now
+		updates["dialed_at"] = now
+		updates["callback_call_id"] = callback.CallbackCallID
+		updates["callback_uri"] = callback.CallbackURI
+	case SipCallbackConnected:
+		callback.ConnectedAt = &now
+		updates["connected_at"] = now
+	case SipCallbackCompleted, SipCallbackFailed, SipCallbackExpired:
+		callback.EndedAt = &now
+		updates["ended_at"] = now
+	}
+	if errMsg != "" {
+		if len(errMsg) > 512 {
+			errMsg = errMsg[:512]
+		}
+		updates["error"] = errMsg
+		callback.Error = errMsg
+	}
+	callback.Status = status
+	return db.Model(&SipCallback{}).Where("id = ?", callback.ID).Updates(updates).Error
+}
+
+// ListSipCallbacks 分页查询用户的回拨，status 为空时不过滤
+func ListSipCallbacks(db *gorm.DB, userID uint, status string, page, pageSize int) ([]SipCallback, int64, error) {
+	query := db.Model(&SipCallback{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var callbacks []SipCallback
+	err := query.Order("offered_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&callbacks).Error
+	return callbacks, total, err
+}
+
+// SipCallbackReport 回拨的提供、接受和接通情况
+type SipCallbackReport struct {
+	Offered     int64                       `json:"offered"`
+	Accepted    int64                       `json:"accepted"`
+	Connected   int64                       `json:"connected"` // 回拨接通的数量，含已结束的
+	ByStatus    map[SipCallbackStatus]int64 `json:"byStatus"`
+	AcceptRate  float64                     `json:"acceptRate"`  // 接受 / 提供
+	ConnectRate float64                     `json:"connectRate"` // 接通 / 不再等待或回拨中的接受
+	AvgHold     float64                     `json:"avgHold"`     // 接受到开始回拨的平均秒数
+}
+
+// BuildSipCallbackReport 统计用户 since 之后提供的回拨
+func BuildSipCallbackReport(db *gorm.DB, userID uint, since time.Time) (*SipCallbackReport, error) {
+	var callbacks []SipCallback
+	if err := db.Where("user_id = ? AND offered_at >= ?", userID, since).Find(&callbacks).Error; err != nil {
+		return nil, err
+	}
+	report := &SipCallbackReport{ByStatus: make(map[SipCallbackStatus]int64)}
+	var resolved, held int64
+	var holdSum float64
+	for i := range callbacks {
+		cb := &callbacks[i]
+		report.Offered++
+		report.ByStatus[cb.Status]++
+		if cb.AcceptedAt == nil {
+			continue
+		}
+		report.Accepted++
+		if cb.ConnectedAt != nil {
+			report.Connected++
+		}
+		if cb.Status != SipCallbackWaiting && cb.Status != SipCallbackDialing {
+			resolved++
+		}
+		if cb.DialedAt != nil {
+			held++
+			holdSum += cb.DialedAt.Sub(*cb.AcceptedAt).Seconds()
+		}
+	}
+	if report.Offered > 0 {
+		report.AcceptRate = float64(report.Accepted) / float64(report.Offered)
+	}
+	if resolved > 0 {
+		report.ConnectRate = float64(report.Connected) / float64(resolved)
+	}
+	if held > 0 {
+		report.AvgHold = holdSum / float64(held)
+	}
+	return report, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidCallbackNumber(t *testing.T) {
+	assert.True(t, ValidCallbackNumber("13812345678"))
+	assert.True(t, ValidCallbackNumber("+8613812345678"))
+	assert.True(t, ValidCallbackNumber("110"))
+	assert.False(t, ValidCallbackNumber("12"))
+	assert.False(t, ValidCallbackNumber("+"))
+	assert.False(t, ValidCallbackNumber("1381234567a"))
+	assert.False(t, ValidCallbackNumber("123456789012345678901"))
+}
+
+func TestSipCallbackLifecycleAndReport(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipCallback{})
+	userID := uint(3)
+	now := time.Now()
+
+	newCallback := func(callID string, status SipCallbackStatus, accepted bool) *SipCallback {
+		cb := &SipCallback{CallID: callID, UserID: &userID, AssistantID: 1, CallerNumber: "1001", Status: status, OfferedAt: now}
+		if accepted {
+			acceptedAt := now.Add(-time.Minute)
+			cb.AcceptedAt = &acceptedAt
+			cb.CallbackNumber = "13812345678"
+		}
+		require.NoError(t, CreateSipCallback(db, cb))
+		return cb
+	}
+	newCallback("declined", SipCallbackDeclined, false)
+	newCallback("silent", SipCallbackNoResponse, false)
+	reached := newCallback("reached", SipCallbackWaiting, true)
+	missed := newCallback("missed", SipCallbackWaiting, true)
+	newCallback("waiting", SipCallbackWaiting, true)
+	other := uint(4)
+	require.NoError(t, CreateSipCallback(db, &SipCallback{CallID: "other", UserID: &other, Status: SipCallbackDeclined, OfferedAt: now}))
+
+	reached.CallbackCallID, reached.CallbackURI = "cb-1", "sip:13812345678@10.0.0.1"
+	require.NoError(t, UpdateSipCallbackStatus(db, reached, SipCallbackDialing, ""))
+	require.NoError(t, UpdateSipCallbackStatus(db, reached, SipCallbackConnected, ""))
+	require.NoError(t, UpdateSipCallbackStatus(db, reached, SipCallbackCompleted, ""))
+	missed.CallbackCallID = "cb-2"
+	require.NoError(t, UpdateSipCallbackStatus(db, missed, SipCallbackDialing, ""))
+	require.NoError(t, UpdateSipCallbackStatus(db, missed, SipCallbackFailed, "busy"))
+
+	var stored SipCallback
+	require.NoError(t, db.First(&stored, reached.ID).Error)
+	assert.Equal(t, SipCallbackCompleted, stored.Status)
+	assert.Equal(t, "cb-1", stored.CallbackCallID)
+	assert.Equal(t, "sip:13812345678@10.0.0.1", stored.CallbackURI)
+	assert.NotNil(t, stored.DialedAt)
+	assert.NotNil(t, stored.ConnectedAt)
+	assert.NotNil(t, stored.EndedAt)
+	var failed SipCallback
+	require.NoError(t, db.First(&failed, missed.ID).Error)
+	assert.Equal(t, "busy", failed.Error)
+	assert.Nil(t, failed.ConnectedAt)
+
+	callbacks, total, err := ListSipCallbacks(db, userID, "", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Len(t, callbacks, 2)
+	callbacks, total, err = ListSipCallbacks(db, userID, string(SipCallbackWaiting), 1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "waiting", callbacks[0].CallID)
+
+	report, err := BuildSipCallbackReport(db, userID, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), report.Offered)
+	assert.Equal(t, int64(3), report.Accepted)
+	assert.Equal(t, int64(1), report.Connected)
+	assert.Equal(t, int64(1), report.ByStatus[SipCallbackDeclined])
+	assert.InDelta(t, 0.6, report.AcceptRate, 1e-9)
+	assert.InDelta(t, 0.5, report.ConnectRate, 1e-9, "the waiting callback is not resolved yet")
+	assert.InDelta(t, 60, report.AvgHold, 5)
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SIP 媒体主备角色
+const (
+	SipHARoleActive  = "active"
+	SipHARoleStandby = "standby"
+)
+
+// 主备切换事件类型
+const (
+	SipFailoverEventFailover  = "failover"        // 活动实例租约过期，备用实例接管
+	SipFailoverEventDrill     = "drill"           // 演练中备用实例接管
+	SipFailoverEventRequested = "drill_requested" // 管理员发起演练
+	SipFailoverEventStepDown  = "step_down"       // 活动实例失去租约，停止处理媒体
+)
+
+// ErrNoHealthyStandby 没有心跳正常的备用实例，演练会导致媒体中断
+var ErrNoHealthyStandby = errors.New("no healthy standby instance to take over")
+
+// ErrNoActiveInstance 集群当前没有活动实例
+var ErrNoActiveInstance = errors.New("no active SIP media instance")
+
+// SipHALease 主备集群的活动实例租约，持有者通告 VIP 并处理媒体。
+// 每次易主 Epoch 加一，失去租约的实例据此知道自己已被取代
+type SipHALease struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UpdatedAt       time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	Cluster         string     `json:"cluster" gorm:"size:64;uniqueIndex"`
+	Holder          string     `json:"holder" gorm:"size:128"`
+	Epoch           int64      `json:"epoch"`
+	AcquiredAt      time.Time  `json:"acquiredAt"`
+	RenewedAt       time.Time  `json:"renewedAt"`
+	ExpiresAt       time.Time  `json:"expiresAt"`
+	DrainedInstance string     `json:"drainedInstance,omitempty" gorm:"size:128"` // 演练中让出租约的实例，在 DrainedUntil 前不能重新获取
+	DrainedUntil    *time.Time `json:"drainedUntil,omitempty"`
+}
+
+// TableName 指定表名
+func (SipHALease) TableName() string {
+	return "sip_ha_leases"
+}
+
+// SipHANode 主备集群中的实例及其心跳
+type SipHANode struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt     time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	Cluster       string    `json:"cluster" gorm:"size:64;uniqueIndex:idx_sip_ha_node,priority:1"`
+	Instance      string    `json:"instance" gorm:"size:128;uniqueIndex:idx_sip_ha_node,priority:2"`
+	Role          string    `json:"role" gorm:"size:16"`
+	VIP           string    `json:"vip" gorm:"column:vip;size:64"`
+	HeartbeatAt   time.Time `json:"heartbeatAt"`
+	MirroredUsers int       `json:"mirroredUsers"` // 备用实例从共享注册表同步的注册用户数
+	ActiveCalls   int       `json:"activeCalls"`
+}
+
+// TableName 指定表名
+func (SipHANode) TableName() string {
+	return "sip_ha_nodes"
+}
+
+// SipFailoverEvent 主备切换和演练记录
+type SipFailoverEvent struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+	Cluster         string    `json:"cluster" gorm:"size:64;index"`
+	Kind            string    `json:"kind" gorm:"size:32"`
+	FromInstance    string    `json:"fromInstance" gorm:"size:128"`
+	ToInstance      string    `json:"toInstance" gorm:"size:128"`
+	Epoch           int64     `json:"epoch"`
+	ResumedCalls    int       `json:"resumedCalls"`
+	TerminatedCalls int       `json:"terminatedCalls"`
+	TakeoverMs      int64     `json:"takeoverMs"` // 从旧租约过期到新实例接管完成
+	RequestedBy     uint      `json:"requestedBy,omitempty"`
+	Reason          string    `json:"reason" gorm:"size:512"`
+}
+
+// TableName 指定表名
+func (SipFailoverEvent) TableName() string {
+	return "sip_failover_events"
+}
+
+// CanAcquire instance 在 now 时能否获取或续约租约
+func (l *SipHALease) CanAcquire(instance string, now time.Time) bool {
+	if l.DrainedInstance == instance && l.DrainedUntil != nil && now.Before(*l.DrainedUntil) {
+		return false
+	}
+	return l.Holder == instance || l.Holder == "" || !now.Before(l.ExpiresAt)
+}
+
+// AcquireSipHALease 获取或续约集群的租约，返回更新前的租约和是否成功。
+// 以更新前的持有者和 Epoch 为条件更新，多个实例同时争抢时只有一个成功
+func AcquireSipHALease(db *gorm.DB, cluster, instance string, ttl time.Duration, now time.Time) (previous SipHALease, acquired bool, err error) {
+	lease := SipHALease{Cluster: cluster, Holder: instance, Epoch: 1, AcquiredAt: now, RenewedAt: now, ExpiresAt: now.Add(ttl)}
+	created, err := CreateOrGet(db, &lease, "Cluster")
+	if err != nil {
+		return SipHALease{}, false, err
+	}
+	if created {
+		return SipHALease{Cluster: cluster}, true, nil
+	}
+	previous = lease
+	if !lease.CanAcquire(instance, now) {
+		return previous, false, nil
+	}
+
+	updates := map[string]interface{}{"renewed_at": now, "expires_at": now.Add(ttl)}
+	if lease.Holder != instance {
+		updates["holder"] = instance
+		updates["epoch"] = lease.Epoch + 1
+		updates["acquired_at"] = now
+		updates["drained_instance"] = ""
+		updates["drained_until"] = nil
+	}
+	result := db.Model(&SipHALease{}).
+		Where("id = ? AND holder = ? AND epoch = ?", lease.ID, lease.Holder, lease.Epoch).
+		Updates(updates)
+	if result.Error != nil {
+		return previous, false, result.Error
+	}
+	return previous, result.RowsAffected > 0, nil
+}
+
+// ReleaseSipHALease 持有者主动让出租约，保留持有者以便接管的实例找到它的通话；
+// drainFor 大于 0 时该实例在这段时间内不能重新获取
+func ReleaseSipHALease(db *gorm.DB, cluster, instance string, drainFor time.Duration, now time.Time) error {
+	updates := map[string]interface{}{"expires_at": now}
+	if drainFor > 0 {
+		until := now.Add(drainFor)
+		updates["drained_instance"] = instance
+		updates["drained_until"] = &until
+	}
+	return db.Model(&SipHALease{}).Where("cluster = ? AND holder = ?", cluster, instance).Updates(updates).Error
+}
+
+// GetSipHALease 集群的租约
+func GetSipHALease(db *gorm.DB, cluster string) (*SipHALease, error) {
+	var lease SipHALease
+	if err := db.Where("cluster = ?", cluster).First(&lease).Error; err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// HeartbeatSipHANode 刷新实例的角色和心跳
+func HeartbeatSipHANode(db *gorm.DB, node *SipHANode) error {
+	return Upsert(db, node, []string{"Cluster", "Instance"}, "role", "vip", "heartbeat_at", "mirrored_users", "active_calls")
+}
+
+// ListSipHANodes 集群中的实例
+func ListSipHANodes(db *gorm.DB, cluster string) ([]SipHANode, error) {
+	var nodes []SipHANode
+	err := db.Where("cluster = ?", cluster).Order("instance").Find(&nodes).Error
+	return nodes, err
+}
+
+// HealthyStandby 心跳晚于 since 的备用实例，没有时返回 nil
+func HealthyStandby(nodes []SipHANode, since time.Time) *SipHANode {
+	for i := range nodes {
+		if nodes[i].Role == SipHARoleStandby && nodes[i].HeartbeatAt.After(since) {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+// RequestSipFailoverDrill 发起主备切换演练：让当前活动实例的租约立即过期，并在 drainFor 内禁止它重新获取，
+// 由备用实例接管。没有心跳晚于 standbySince 的备用实例时返回 ErrNoHealthyStandby
+func RequestSipFailoverDrill(db *gorm.DB, cluster string, standbySince time.Time, drainFor time.Duration, requestedBy uint, reason string, now time.Time) (*SipFailoverEvent, error) {
+	lease, err := GetSipHALease(db, cluster)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoActiveInstance
+	} else if err != nil {
+		return nil, err
+	}
+	if lease.Holder == "" || !now.Before(lease.ExpiresAt) {
+		return nil, ErrNoActiveInstance
+	}
+	nodes, err := ListSipHANodes(db, cluster)
+	if err != nil {
+		return nil, err
+	}
+	standby := HealthyStandby(nodes, standbySince)
+	if standby == nil {
+		return nil, ErrNoHealthyStandby
+	}
+
+	until := now.Add(drainFor)
+	result := db.Model(&SipHALease{}).
+		Where("id = ? AND holder = ? AND epoch = ?", lease.ID, lease.Holder, lease.Epoch).
+		Updates(map[string]interface{}{"expires_at": now, "drained_instance": lease.Holder, "drained_until": &until})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("lease changed while requesting the drill, try again")
+	}
+	event := &SipFailoverEvent{
+		Cluster:      cluster,
+		Kind:         SipFailoverEventRequested,
+		FromInstance: lease.Holder,
+		ToInstance:   standby.Instance,
+		Epoch:        lease.Epoch,
+		RequestedBy:  requestedBy,
+		Reason:       reason,
+	}
+	if err := RecordSipFailoverEvent(db, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// RecordSipFailoverEvent 保存切换记录
+func RecordSipFailoverEvent(db *gorm.DB, event *SipFailoverEvent) error {
+	if len(event.Reason) > 512 {
+		event.Reason = event.Reason[:512]
+	}
+	return db.Create(event).Error
+}
+
+// ListSipFailoverEvents 集群最近的切换记录
+func ListSipFailoverEvents(db *gorm.DB, cluster string, limit int) ([]SipFailoverEvent, error) {
+	var events []SipFailoverEvent
+	err := db.Where("cluster = ?", cluster).Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// ListInstanceAICallCheckpoints 实例持有的 AI 通话检查点，新的活动实例接管时使用
+func ListInstanceAICallCheckpoints(db *gorm.DB, instance string) ([]AICallCheckpoint, error) {
+	var checkpoints []AICallCheckpoint
+	err := db.Where("instance = ?", instance).Order("heartbeat").Find(&checkpoints).Error
+	return checkpoints, err
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSipHALease_AcquireRenewTakeover(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipHALease{})
+	now := time.Now()
+	ttl := 15 * time.Second
+
+	_, ok, err := AcquireSipHALease(db, "media", "a", ttl, now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// The standby can't take a live lease
+	prev, ok, err := AcquireSipHALease(db, "media", "b", ttl, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "a", prev.Holder)
+
+	_, ok, err = AcquireSipHALease(db, "media", "a", ttl, now.Add(5*time.Second))
+	require.NoError(t, err)
+	assert.True(t, ok, "holder renews")
+
+	prev, ok, err = AcquireSipHALease(db, "media", "b", ttl, now.Add(21*time.Second))
+	require.NoError(t, err)
+	assert.True(t, ok, "expired lease is taken over")
+	assert.Equal(t, "a", prev.Holder)
+
+	lease, err := GetSipHALease(db, "media")
+	require.NoError(t, err)
+	assert.Equal(t, "b", lease.Holder)
+	assert.EqualValues(t, 2, lease.Epoch)
+
+	_, ok, err = AcquireSipHALease(db, "media", "a", ttl, now.Add(22*time.Second))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSipHALease_ReleaseKeepsHolder(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipHALease{})
+	now := time.Now()
+	_, _, err := AcquireSipHALease(db, "media", "a", time.Minute, now)
+	require.NoError(t, err)
+	require.NoError(t, ReleaseSipHALease(db, "media", "a", 0, now.Add(time.Second)))
+
+	prev, ok, err := AcquireSipHALease(db, "media", "b", time.Minute, now.Add(2*time.Second))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", prev.Holder, "the new holder finds the calls of the released instance")
+}
+
+func TestRequestSipFailoverDrill(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &SipHALease{}, &SipHANode{}, &SipFailoverEvent{})
+	now := time.Now()
+
+	_, err := RequestSipFailoverDrill(db, "media", now.Add(-30*time.Second), time.Minute, 1, "", now)
+	assert.ErrorIs(t, err, ErrNoActiveInstance)
+
+	_, _, err = AcquireSipHALease(db, "media", "a", time.Minute, now)
+	require.NoError(t, err)
+	require.NoError(t, HeartbeatSipHANode(db, &SipHANode{Cluster: "media", Instance: "a", Role: SipHARoleActive, HeartbeatAt: now}))
+	require.NoError(t, HeartbeatSipHANode(db, &SipHANode{Cluster: "media", Instance: "b", Role: SipHARoleStandby, HeartbeatAt: now.Add(-time.Minute)}))
+
+	_, err = RequestSipFailoverDrill(db, "media", now.Add(-30*time.Second), time.Minute, 1, "", now)
+	assert.ErrorIs(t, err, ErrNoHealthyStandby, "stale standby")
+
+	require.NoError(t, HeartbeatSipHANode(db, &SipHANode{Cluster: "media", Instance: "b", Role: SipHARoleStandby, HeartbeatAt: now}))
+	event, err := RequestSipFailoverDrill(db, "media", now.Add(-30*time.Second), time.Minute, 1, "quarterly drill", now)
+	require.NoError(t, err)
+	assert.Equal(t, "a", event.FromInstance)
+	assert.Equal(t, "b", event.ToInstance)
+
+	// The drained instance can't take the lease back before the standby
+	_, ok, err := AcquireSipHALease(db, "media", "a", time.Minute, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	prev, ok, err := AcquireSipHALease(db, "media", "b", time.Minute, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", prev.DrainedInstance)
+
+	events, err := ListSipFailoverEvents(db, "media", 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
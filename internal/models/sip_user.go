@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -26,6 +27,13 @@ const (
 	RecordingModeMessage  RecordingMode = "message"  // 仅留言阶段录音
 )
 
+// 静音抑制判定电平范围（dBov）
+const (
+	DefaultSilenceThreshold = -50
+	MinSilenceThreshold     = -90
+	MaxSilenceThreshold     = -20
+)
+
 // KeywordReply 关键词回复配置
 type KeywordReply struct {
 	Keyword string `json:"keyword"` // 关键词
@@ -92,10 +100,11 @@ type SipUser struct {
 	RemoteIP  string `json:"remoteIp,omitempty" gorm:"size:64"`   // 远程IP地址
 
 	// ========== 关联信息 ==========
-	UserID  *uint `json:"userId,omitempty" gorm:"index"` // 关联到系统用户（可选）
-	User    User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	GroupID *uint `json:"groupId,omitempty" gorm:"index"` // 关联到组织（可选）
-	Group   Group `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+	UserID  *uint  `json:"userId,omitempty" gorm:"index"` // 关联到系统用户（可选）
+	User    User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	GroupID *uint  `json:"groupId,omitempty" gorm:"index"` // 关联到组织（可选）
+	Group   Group  `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+	Region  string `json:"region,omitempty" gorm:"size:32;index"` // 固定的部署地区，注册和呼入由该地区的实例处理
 
 	// ========== AI 代接配置 ==========
 	AssistantID     *uint     `json:"assistantId,omitempty" gorm:"index"` // 绑定的 AI 助手 ID
@@ -114,6 +123,10 @@ type SipUser struct {
 	RecordingMode    RecordingMode `json:"recordingMode" gorm:"size:20;default:'full'"` // 录音模式：full(全程) / message(仅留言)
 	RecordingPath    string        `json:"recordingPath,omitempty" gorm:"size:512"`     // 录音文件存储路径模板
 
+	// ========== 媒体配置 ==========
+	SilenceSuppression bool `json:"silenceSuppression" gorm:"default:false"` // 发送方向静音抑制，静音期间停发语音包，对端支持时改发 RFC 3389 舒适噪声
+	SilenceThreshold   int  `json:"silenceThreshold" gorm:"default:-50"`     // 静音判定电平（dBov），低于该值的帧视为静音
+
 	// ========== 留言配置 ==========
 	MessageEnabled  bool   `json:"messageEnabled" gorm:"default:true"`       // 是否启用留言功能
 	MessageDuration int    `json:"messageDuration" gorm:"default:20"`        // 留言时长（秒，默认20秒）
@@ -143,6 +156,17 @@ func (SipUser) TableName() string {
 	return "sip_users"
 }
 
+// NormalizeSilenceThreshold 校验静音判定电平，0 取默认值
+func (su *SipUser) NormalizeSilenceThreshold() error {
+	if su.SilenceThreshold == 0 {
+		su.SilenceThreshold = DefaultSilenceThreshold
+	}
+	if su.SilenceThreshold < MinSilenceThreshold || su.SilenceThreshold > MaxSilenceThreshold {
+		return fmt.Errorf("silenceThreshold must be between %d and %d dBov", MinSilenceThreshold, MaxSilenceThreshold)
+	}
+	return nil
+}
+
 // IsRegistered 检查用户是否已注册
 func (su *SipUser) IsRegistered() bool {
 	return su.Status == SipUserStatusRegistered
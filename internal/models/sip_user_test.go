@@ -230,6 +230,21 @@ func TestSipUser_IsRegistered(t *testing.T) {
 	assert.False(t, sipUser.IsRegistered())
 }
 
+func TestSipUser_NormalizeSilenceThreshold(t *testing.T) {
+	sipUser := &SipUser{}
+	assert.NoError(t, sipUser.NormalizeSilenceThreshold())
+	assert.Equal(t, DefaultSilenceThreshold, sipUser.SilenceThreshold)
+
+	sipUser.SilenceThreshold = -60
+	assert.NoError(t, sipUser.NormalizeSilenceThreshold())
+	assert.Equal(t, -60, sipUser.SilenceThreshold)
+
+	sipUser.SilenceThreshold = -10
+	assert.Error(t, sipUser.NormalizeSilenceThreshold())
+	sipUser.SilenceThreshold = -100
+	assert.Error(t, sipUser.NormalizeSilenceThreshold())
+}
+
 func TestSipUser_IsExpired(t *testing.T) {
 	sipUser := &SipUser{}
 
@@ -0,0 +1,371 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/events"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/plans"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// subscriptionEventSource 订阅账单事件的来源
+const subscriptionEventSource = "billing"
+
+// planQuotaDescription 由套餐同步的组织配额的描述前缀，用于区分手动设置的配额
+const planQuotaDescription = "plan:"
+
+var (
+	ErrSubscriptionExists     = errors.New("the organization already has a subscription")
+	ErrUnknownPlan            = errors.New("unknown plan")
+	ErrPlanReadOnly           = errors.New("your organization's subscription has expired, renew it to make changes")
+	ErrPlanFeatureUnavailable = errors.New("this feature is not included in your organization's plan")
+)
+
+// planReadOnlyPaths 只读模式下仍允许的写操作：退出登录和续费
+var planReadOnlyPaths = []string{"/auth/logout", "/subscription"}
+
+// GroupSubscription 组织订阅的套餐。没有订阅的组织不受套餐限制；用户属于多个
+// 有订阅的组织时，取可写且等级最高的套餐
+type GroupSubscription struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID     uint       `json:"groupId" gorm:"uniqueIndex;not null"`
+	PlanKey     string     `json:"plan" gorm:"size:32"`
+	Status      string     `json:"status" gorm:"size:16;index"`   // 最近一次保存的状态，实际状态见 EffectiveStatus
+	EndsAt      *time.Time `json:"endsAt,omitempty" gorm:"index"` // 试用或付费周期结束时间，空表示不到期
+	GraceEndsAt *time.Time `json:"graceEndsAt,omitempty"`         // 宽限期结束时间，之后变为只读
+	TrialUsed   bool       `json:"trialUsed"`                     // 每个组织只能试用一次
+	ChangedBy   uint       `json:"changedBy,omitempty"`
+}
+
+func (GroupSubscription) TableName() string {
+	return "group_subscriptions"
+}
+
+// Plan 订阅的套餐定义
+func (s *GroupSubscription) Plan() *plans.Plan {
+	p, ok := plans.Get(s.PlanKey)
+	if !ok {
+		// 已下线的套餐按试用处理
+		p, _ = plans.Get(plans.PlanTrial)
+	}
+	return p
+}
+
+// EffectiveStatus 订阅在 now 时刻的状态
+func (s *GroupSubscription) EffectiveStatus(now time.Time) string {
+	return plans.EffectiveStatus(s.Status, s.EndsAt, s.GraceEndsAt, now)
+}
+
+// SubscriptionEvent 订阅的账单事件记录，同时发布到事件总线供计费系统消费
+type SubscriptionEvent struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+	GroupID      uint      `json:"groupId" gorm:"index"`
+	Type         string    `json:"type" gorm:"size:64;index"`
+	PlanKey      string    `json:"plan" gorm:"size:32"`
+	PreviousPlan string    `json:"previousPlan,omitempty" gorm:"size:32"`
+	Status       string    `json:"status" gorm:"size:16"`
+	UserID       uint      `json:"userId,omitempty"`
+	Data         string    `json:"data,omitempty" gorm:"type:text"` // JSON，与事件总线的数据一致
+}
+
+func (SubscriptionEvent) TableName() string {
+	return "subscription_events"
+}
+
+// GetGroupSubscription 获取组织的订阅，没有订阅时返回 gorm.ErrRecordNotFound
+func GetGroupSubscription(db *gorm.DB, groupID uint) (*GroupSubscription, error) {
+	var sub GroupSubscription
+	if err := db.Where("group_id = ?", groupID).First(&sub).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListSubscriptionEvents 组织的账单事件，按时间倒序
+func ListSubscriptionEvents(db *gorm.DB, groupID uint, limit int) ([]SubscriptionEvent, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	var list []SubscriptionEvent
+	err := db.Where("group_id = ?", groupID).Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// StartGroupTrial 为还没有订阅的组织开通试用
+func StartGroupTrial(db *gorm.DB, groupID, userID uint, now time.Time) (*GroupSubscription, error) {
+	trial, _ := plans.Get(plans.PlanTrial)
+	endsAt, graceEndsAt, _ := trial.Term(now, nil)
+	sub := &GroupSubscription{
+		GroupID:     groupID,
+		PlanKey:     trial.Key,
+		Status:      plans.StatusTrialing,
+		EndsAt:      endsAt,
+		GraceEndsAt: graceEndsAt,
+		TrialUsed:   true,
+		ChangedBy:   userID,
+	}
+	var event *SubscriptionEvent
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&GroupSubscription{}).Where("group_id = ?", groupID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrSubscriptionExists
+		}
+		if err := tx.Create(sub).Error; err != nil {
+			return err
+		}
+		if err := applyPlanQuota(tx, groupID, trial); err != nil {
+			return err
+		}
+		var err error
+		event, err = recordSubscriptionEvent(tx, events.BillingTrialStarted, sub, "", userID, map[string]interface{}{"endsAt": endsAt})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	publishSubscriptionEvent(event)
+	return sub, nil
+}
+
+// ChangeGroupPlan 修改组织的套餐并重新计算期限，paidUntil 为付费周期的结束时间，
+// 为空表示不到期（合同计费）。组织没有订阅时创建订阅
+func ChangeGroupPlan(db *gorm.DB, groupID uint, planKey string, paidUntil *time.Time, userID uint, now time.Time) (*GroupSubscription, error) {
+	plan, ok := plans.Get(planKey)
+	if !ok {
+		return nil, ErrUnknownPlan
+	}
+	endsAt, graceEndsAt, err := plan.Term(now, paidUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sub GroupSubscription
+	var event *SubscriptionEvent
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("group_id = ?", groupID).First(&sub).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		previous := sub.PlanKey
+		sub.GroupID = groupID
+		sub.PlanKey = plan.Key
+		sub.Status = plans.StatusActive
+		if plan.IsTrial() {
+			sub.Status = plans.StatusTrialing
+			sub.TrialUsed = true
+		}
+		sub.EndsAt, sub.GraceEndsAt = endsAt, graceEndsAt
+		sub.ChangedBy = userID
+		if err := tx.Save(&sub).Error; err != nil {
+			return err
+		}
+		if err := applyPlanQuota(tx, groupID, plan); err != nil {
+			return err
+		}
+		event, err = recordSubscriptionEvent(tx, events.BillingPlanChanged, &sub, previous, userID, map[string]interface{}{
+			"previousPlan": previous,
+			"endsAt":       endsAt,
+			"changedBy":    userID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	publishSubscriptionEvent(event)
+	return &sub, nil
+}
+
+// AdvanceGroupSubscriptions 将到期的订阅转入宽限期或只读并记录账单事件，返回变更的数量
+func AdvanceGroupSubscriptions(db *gorm.DB, now time.Time) (int, error) {
+	var subs []GroupSubscription
+	err := db.Where("status <> ? AND ends_at IS NOT NULL AND ends_at <= ?", plans.StatusReadOnly, now).Find(&subs).Error
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for i := range subs {
+		sub := &subs[i]
+		status := sub.EffectiveStatus(now)
+		if status == sub.Status {
+			continue
+		}
+		eventType := events.BillingGraceStarted
+		data := map[string]interface{}{"graceEndsAt": sub.GraceEndsAt}
+		if status == plans.StatusReadOnly {
+			eventType, data = events.BillingReadOnly, nil
+		}
+		var event *SubscriptionEvent
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// 只更新仍是旧状态且已到期的记录，避免覆盖同时发生的续费
+			res := tx.Model(&GroupSubscription{}).
+				Where("id = ? AND status = ? AND ends_at <= ?", sub.ID, sub.Status, now).
+				Update("status", status)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			sub.Status = status
+			var err error
+			event, err = recordSubscriptionEvent(tx, eventType, sub, "", 0, data)
+			return err
+		})
+		if err != nil {
+			logger.Warn("Failed to advance subscription", zap.Uint("groupId", sub.GroupID), zap.Error(err))
+			continue
+		}
+		if event != nil {
+			publishSubscriptionEvent(event)
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// applyPlanQuota 按套餐同步组织的 AI 通话时长配额，不修改手动设置的配额
+func applyPlanQuota(tx *gorm.DB, groupID uint, plan *plans.Plan) error {
+	var quota GroupQuota
+	err := tx.Where("group_id = ? AND quota_type = ?", groupID, QuotaTypeCallDuration).First(&quota).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if quota.ID != 0 && !strings.HasPrefix(quota.Description, planQuotaDescription) {
+		return nil
+	}
+	quota.GroupID = groupID
+	quota.QuotaType = QuotaTypeCallDuration
+	quota.TotalQuota = plan.AIMinutes * 60 // 0 表示无限制
+	quota.Period = QuotaPeriodMonthly
+	quota.Description = planQuotaDescription + plan.Key
+	return tx.Save(&quota).Error
+}
+
+func recordSubscriptionEvent(tx *gorm.DB, eventType string, sub *GroupSubscription, previous string, userID uint, data map[string]interface{}) (*SubscriptionEvent, error) {
+	payload := map[string]interface{}{"groupId": sub.GroupID, "plan": sub.PlanKey, "status": sub.Status}
+	for k, v := range data {
+		payload[k] = v
+	}
+	raw, _ := json.Marshal(payload)
+	event := &SubscriptionEvent{
+		GroupID:      sub.GroupID,
+		Type:         eventType,
+		PlanKey:      sub.PlanKey,
+		PreviousPlan: previous,
+		Status:       sub.Status,
+		UserID:       userID,
+		Data:         string(raw),
+	}
+	return event, tx.Create(event).Error
+}
+
+// publishSubscriptionEvent 事务提交后发布到事件总线
+func publishSubscriptionEvent(event *SubscriptionEvent) {
+	var data map[string]interface{}
+	json.Unmarshal([]byte(event.Data), &data)
+	events.PublishEvent(event.Type, data, subscriptionEventSource)
+}
+
+// UserPlan 用户所在组织中可写且等级最高的订阅套餐及其状态，
+// managed 为 false 表示用户的组织都没有订阅，不受套餐限制
+func UserPlan(db *gorm.DB, user *User, now time.Time) (plan *plans.Plan, status string, managed bool) {
+	groupIDs := userGroupIDs(db, user.ID)
+	if len(groupIDs) == 0 {
+		return nil, "", false
+	}
+	var subs []GroupSubscription
+	if err := db.Where("group_id IN ?", groupIDs).Find(&subs).Error; err != nil || len(subs) == 0 {
+		return nil, "", false
+	}
+	for i := range subs {
+		p, s := subs[i].Plan(), subs[i].EffectiveStatus(now)
+		if plan == nil || betterPlan(p, s, plan, status) {
+			plan, status = p, s
+		}
+	}
+	return plan, status, true
+}
+
+func betterPlan(p *plans.Plan, status string, than *plans.Plan, thanStatus string) bool {
+	if plans.Writable(status) != plans.Writable(thanStatus) {
+		return plans.Writable(status)
+	}
+	return p.Rank > than.Rank
+}
+
+// PlanAllows 判断用户的套餐是否包含功能，只读的订阅不包含任何功能
+func PlanAllows(db *gorm.DB, user *User, feature string) bool {
+	if user == nil {
+		return false
+	}
+	if user.IsSuperAdmin() {
+		return true
+	}
+	plan, status, managed := UserPlan(db, user, time.Now())
+	if !managed {
+		return true
+	}
+	return plans.Writable(status) && plan.Allows(feature)
+}
+
+// PlanAllowsProvider 判断用户的套餐是否可以使用知识库提供商
+func PlanAllowsProvider(db *gorm.DB, user *User, provider string) bool {
+	if user == nil {
+		return false
+	}
+	if user.IsSuperAdmin() {
+		return true
+	}
+	plan, status, managed := UserPlan(db, user, time.Now())
+	if !managed {
+		return true
+	}
+	return plans.Writable(status) && plan.AllowsProvider(provider)
+}
+
+// PlanFeatureRequired 用户的套餐不包含功能时返回 403，需放在 AuthRequired 之后
+func PlanFeatureRequired(feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := c.MustGet(constants.DbField).(*gorm.DB)
+		if !PlanAllows(db, CurrentUser(c), feature) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": ErrPlanFeatureUnavailable.Error(), "upgradeRequired": true, "feature": feature})
+			return
+		}
+		c.Next()
+	}
+}
+
+// enforcePlanReadOnly 订阅只读时拒绝写操作，读取、退出登录和续费不受影响
+func enforcePlanReadOnly(c *gin.Context, db *gorm.DB, user *User) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if user.IsSuperAdmin() {
+		return true
+	}
+	path := c.FullPath()
+	for _, allowed := range planReadOnlyPaths {
+		if strings.Contains(path, allowed) {
+			return true
+		}
+	}
+	_, status, managed := UserPlan(db, user, time.Now())
+	if !managed || plans.Writable(status) {
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": ErrPlanReadOnly.Error(), "readOnly": true})
+	return false
+}
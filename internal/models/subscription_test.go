@@ -0,0 +1,177 @@
+package models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/events"
+	"github.com/code-100-precent/LingEcho/pkg/plans"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupSubscriptionDB(t *testing.T) *gorm.DB {
+	return setupTestDBWithSilentLogger(t, &GroupSubscription{}, &SubscriptionEvent{}, &GroupQuota{}, &Group{}, &GroupMember{})
+}
+
+func TestStartGroupTrial(t *testing.T) {
+	db := setupSubscriptionDB(t)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	sub, err := StartGroupTrial(db, 10, 1, now)
+	require.NoError(t, err)
+	assert.Equal(t, plans.StatusTrialing, sub.Status)
+	assert.Equal(t, now.AddDate(0, 0, 14), *sub.EndsAt)
+	assert.True(t, sub.TrialUsed)
+
+	_, err = StartGroupTrial(db, 10, 1, now)
+	assert.ErrorIs(t, err, ErrSubscriptionExists)
+
+	quota, err := GetGroupQuota(db, 10, QuotaTypeCallDuration)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100*60), quota.TotalQuota)
+	assert.Equal(t, QuotaPeriodMonthly, quota.Period)
+
+	list, err := ListSubscriptionEvents(db, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, events.BillingTrialStarted, list[0].Type)
+	assert.Contains(t, list[0].Data, `"plan":"trial"`)
+}
+
+func TestChangeGroupPlan(t *testing.T) {
+	db := setupSubscriptionDB(t)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := StartGroupTrial(db, 10, 1, now)
+	require.NoError(t, err)
+
+	paidUntil := now.AddDate(0, 1, 0)
+	sub, err := ChangeGroupPlan(db, 10, plans.PlanPro, &paidUntil, 2, now)
+	require.NoError(t, err)
+	assert.Equal(t, plans.StatusActive, sub.Status)
+	assert.True(t, sub.TrialUsed, "trial is not offered again")
+	assert.Equal(t, paidUntil.AddDate(0, 0, 7), *sub.GraceEndsAt)
+
+	quota, _ := GetGroupQuota(db, 10, QuotaTypeCallDuration)
+	assert.Equal(t, int64(5000*60), quota.TotalQuota)
+
+	// Manually set quotas are left alone
+	require.NoError(t, db.Model(&GroupQuota{}).Where("id = ?", quota.ID).Update("description", "negotiated").Error)
+	_, err = ChangeGroupPlan(db, 10, plans.PlanEnterprise, nil, 2, now)
+	require.NoError(t, err)
+	quota, _ = GetGroupQuota(db, 10, QuotaTypeCallDuration)
+	assert.Equal(t, int64(5000*60), quota.TotalQuota)
+
+	list, _ := ListSubscriptionEvents(db, 10, 0)
+	require.Len(t, list, 3)
+	assert.Equal(t, events.BillingPlanChanged, list[0].Type)
+	assert.Equal(t, plans.PlanPro, list[0].PreviousPlan)
+
+	_, err = ChangeGroupPlan(db, 10, "gold", nil, 2, now)
+	assert.ErrorIs(t, err, ErrUnknownPlan)
+}
+
+func TestAdvanceGroupSubscriptions(t *testing.T) {
+	db := setupSubscriptionDB(t)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := StartGroupTrial(db, 10, 1, now)
+	require.NoError(t, err)
+	_, err = ChangeGroupPlan(db, 11, plans.PlanEnterprise, nil, 1, now)
+	require.NoError(t, err)
+
+	changed, err := AdvanceGroupSubscriptions(db, now.AddDate(0, 0, 13))
+	require.NoError(t, err)
+	assert.Zero(t, changed)
+
+	changed, err = AdvanceGroupSubscriptions(db, now.AddDate(0, 0, 15))
+	require.NoError(t, err)
+	assert.Equal(t, 1, changed)
+	sub, _ := GetGroupSubscription(db, 10)
+	assert.Equal(t, plans.StatusGrace, sub.Status)
+
+	changed, err = AdvanceGroupSubscriptions(db, now.AddDate(0, 0, 18))
+	require.NoError(t, err)
+	assert.Equal(t, 1, changed)
+	sub, _ = GetGroupSubscription(db, 10)
+	assert.Equal(t, plans.StatusReadOnly, sub.Status)
+
+	list, _ := ListSubscriptionEvents(db, 10, 0)
+	require.Len(t, list, 3)
+	assert.Equal(t, events.BillingReadOnly, list[0].Type)
+	assert.Equal(t, events.BillingGraceStarted, list[1].Type)
+
+	changed, _ = AdvanceGroupSubscriptions(db, now.AddDate(0, 0, 30))
+	assert.Zero(t, changed)
+}
+
+func TestPlanEnforcement(t *testing.T) {
+	db := setupSubscriptionDB(t)
+	require.NoError(t, db.Create(&GroupMember{UserID: 2, GroupID: 10, Role: GroupRoleMember}).Error)
+	require.NoError(t, db.Create(&GroupMember{UserID: 3, GroupID: 11, Role: GroupRoleMember}).Error)
+	_, err := StartGroupTrial(db, 10, 2, time.Now())
+	require.NoError(t, err)
+
+	// Users outside subscribed organizations are not limited
+	assert.True(t, PlanAllows(db, userWithID(3), plans.FeatureSIPTrunking))
+	assert.True(t, PlanAllowsProvider(db, userWithID(3), "pinecone"))
+
+	assert.False(t, PlanAllows(db, userWithID(2), plans.FeatureSIPTrunking))
+	assert.True(t, PlanAllowsProvider(db, userWithID(2), "aliyun"))
+	assert.False(t, PlanAllowsProvider(db, userWithID(2), "pinecone"))
+
+	// The best writable plan among the user's organizations applies
+	require.NoError(t, db.Create(&GroupMember{UserID: 2, GroupID: 11, Role: GroupRoleMember}).Error)
+	_, err = ChangeGroupPlan(db, 11, plans.PlanPro, nil, 1, time.Now())
+	require.NoError(t, err)
+	plan, status, managed := UserPlan(db, userWithID(2), time.Now())
+	assert.True(t, managed)
+	assert.Equal(t, plans.PlanPro, plan.Key)
+	assert.Equal(t, plans.StatusActive, status)
+	assert.True(t, PlanAllows(db, userWithID(2), plans.FeatureSIPTrunking))
+
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, db.Model(&GroupSubscription{}).Where("group_id = ?", 11).
+		Updates(map[string]interface{}{"ends_at": expired, "grace_ends_at": expired}).Error)
+	plan, status, _ = UserPlan(db, userWithID(2), time.Now())
+	assert.Equal(t, plans.PlanTrial, plan.Key, "writable trial beats read-only pro")
+	assert.Equal(t, plans.StatusTrialing, status)
+}
+
+func TestEnforcePlanReadOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupSubscriptionDB(t)
+	require.NoError(t, db.Create(&GroupMember{UserID: 2, GroupID: 10, Role: GroupRoleMember}).Error)
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, db.Create(&GroupSubscription{GroupID: 10, PlanKey: plans.PlanTrial, Status: plans.StatusTrialing, EndsAt: &expired, GraceEndsAt: &expired}).Error)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		if !enforcePlanReadOnly(c, db, userWithID(2)) {
+			return
+		}
+		c.Next()
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/assistant", ok)
+	router.POST("/api/assistant/add", ok)
+	router.PUT("/api/group/:id/subscription", ok)
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/assistant", http.StatusOK},
+		{http.MethodPost, "/api/assistant/add", http.StatusForbidden},
+		{http.MethodPut, "/api/group/10/subscription", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.status, w.Code, "%s %s", tc.method, tc.path)
+	}
+}
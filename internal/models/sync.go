@@ -0,0 +1,187 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 差异同步实体类型
+const (
+	SyncEntityDevice    = "device"
+	SyncEntityAssistant = "assistant"
+	SyncEntityRecording = "recording"
+)
+
+const (
+	// SyncTombstoneRetention 删除记录保留时长，早于该时间的游标需要全量同步
+	SyncTombstoneRetention = 30 * 24 * time.Hour
+	// DefaultSyncLimit 每类实体单次返回的默认条数
+	DefaultSyncLimit = 200
+	// MaxSyncLimit 每类实体单次返回的最大条数
+	MaxSyncLimit = 1000
+)
+
+// ErrInvalidSyncCursor 游标格式错误
+var ErrInvalidSyncCursor = errors.New("invalid sync cursor")
+
+// SyncTombstone 已删除实体的墓碑记录，供客户端同步删除
+type SyncTombstone struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	UserID    uint      `json:"-" gorm:"index:idx_sync_tombstone_user_time"`
+	Entity    string    `json:"entity" gorm:"size:32"`
+	EntityID  string    `json:"id" gorm:"size:64"`
+	DeletedAt time.Time `json:"deletedAt" gorm:"index:idx_sync_tombstone_user_time"`
+}
+
+func (SyncTombstone) TableName() string {
+	return "sync_tombstones"
+}
+
+// RecordSyncTombstone 记录实体删除
+func RecordSyncTombstone(db *gorm.DB, userID uint, entity, entityID string) error {
+	return db.Create(&SyncTombstone{
+		UserID:    userID,
+		Entity:    entity,
+		EntityID:  entityID,
+		DeletedAt: time.Now(),
+	}).Error
+}
+
+// PruneSyncTombstones 清理过期的墓碑记录
+func PruneSyncTombstones(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("deleted_at < ?", before).Delete(&SyncTombstone{})
+	return result.RowsAffected, result.Error
+}
+
+// FormatSyncCursor 游标为毫秒时间戳
+func FormatSyncCursor(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// ParseSyncCursor 解析游标，兼容毫秒时间戳和 RFC3339 时间，空值表示从头同步
+func ParseSyncCursor(cursor string) (time.Time, error) {
+	cursor = strings.TrimSpace(cursor)
+	if cursor == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(cursor, 10, 64); err == nil && ms >= 0 {
+		return time.UnixMilli(ms), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, cursor); err == nil {
+		return t, nil
+	}
+	return time.Time{}, ErrInvalidSyncCursor
+}
+
+// SyncChanges 游标之后的变更
+// 同一条记录可能在相邻两次同步中重复出现，客户端需按 ID 幂等地覆盖
+type SyncChanges struct {
+	Devices    []Device        `json:"devices"`
+	Assistants []Assistant     `json:"assistants"`
+	Recordings []CallRecording `json:"recordings"`
+	Deleted    []SyncTombstone `json:"deleted"`
+	Cursor     string          `json:"cursor"`  // 下次同步使用的游标
+	HasMore    bool            `json:"hasMore"` // 还有未返回的变更，应立即用新游标继续拉取
+	Full       bool            `json:"full"`    // 全量同步的第一页，客户端应清空本地数据后再应用
+}
+
+// GetSyncChanges 获取用户在 since 之后创建、更新和删除的设备、助手和通话录音
+func GetSyncChanges(db *gorm.DB, userID uint, since time.Time, limit int) (*SyncChanges, error) {
+	if limit <= 0 {
+		limit = DefaultSyncLimit
+	}
+	if limit > MaxSyncLimit {
+		limit = MaxSyncLimit
+	}
+	// 先取当前时间，查询期间发生的变更留到下次同步
+	now := time.Now()
+	changes := &SyncChanges{
+		Devices:    []Device{},
+		Assistants: []Assistant{},
+		Recordings: []CallRecording{},
+		Deleted:    []SyncTombstone{},
+	}
+	if since.IsZero() || since.Before(now.Add(-SyncTombstoneRetention)) {
+		// 游标过旧，删除记录可能已被清理，只能全量同步
+		changes.Full = true
+		since = time.Time{}
+	}
+
+	// 截断的实体中最早的最后更新时间，作为下次游标
+	var next time.Time
+	truncated := func(n int, last time.Time) {
+		if n >= limit {
+			changes.HasMore = true
+			if next.IsZero() || last.Before(next) {
+				next = last
+			}
+		}
+	}
+
+	if err := db.Where("user_id = ? AND updated_at >= ?", userID, since).
+		Order("updated_at ASC").Limit(limit).Find(&changes.Devices).Error; err != nil {
+		return nil, err
+	}
+	if n := len(changes.Devices); n > 0 {
+		truncated(n, changes.Devices[n-1].UpdatedAt)
+	}
+
+	if err := db.Where("user_id = ? AND updated_at >= ?", userID, since).
+		Order("updated_at ASC").Limit(limit).Find(&changes.Assistants).Error; err != nil {
+		return nil, err
+	}
+	if n := len(changes.Assistants); n > 0 {
+		truncated(n, changes.Assistants[n-1].UpdatedAt)
+	}
+
+	var recordings []CallRecording
+	query := db.Where("user_id = ? AND updated_at >= ?", userID, since)
+	if changes.Full {
+		query = query.Where("is_deleted = ?", 0)
+	}
+	if err := query.Order("updated_at ASC").Limit(limit).Find(&recordings).Error; err != nil {
+		return nil, err
+	}
+	if n := len(recordings); n > 0 {
+		truncated(n, recordings[n-1].UpdatedAt)
+	}
+	for _, recording := range recordings {
+		if recording.IsDeleted != 0 {
+			// 逻辑删除的录音作为删除返回
+			changes.Deleted = append(changes.Deleted, SyncTombstone{
+				Entity:    SyncEntityRecording,
+				EntityID:  strconv.FormatUint(uint64(recording.ID), 10),
+				DeletedAt: recording.UpdatedAt,
+			})
+			continue
+		}
+		changes.Recordings = append(changes.Recordings, recording)
+	}
+
+	if !changes.Full {
+		var tombstones []SyncTombstone
+		if err := db.Where("user_id = ? AND deleted_at >= ?", userID, since).
+			Order("deleted_at ASC").Limit(limit).Find(&tombstones).Error; err != nil {
+			return nil, err
+		}
+		if n := len(tombstones); n > 0 {
+			truncated(n, tombstones[n-1].DeletedAt)
+		}
+		changes.Deleted = append(changes.Deleted, tombstones...)
+	}
+
+	if changes.HasMore {
+		// 同一毫秒内的变更超过一页时前进 1ms，避免客户端反复拉取同一页
+		if next.UnixMilli() <= since.UnixMilli() {
+			next = since.Add(time.Millisecond)
+		}
+		changes.Cursor = FormatSyncCursor(next)
+	} else {
+		changes.Cursor = FormatSyncCursor(now)
+	}
+	return changes, nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSyncCursor(t *testing.T) {
+	zero, err := ParseSyncCursor("")
+	require.NoError(t, err)
+	assert.True(t, zero.IsZero())
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	parsed, err := ParseSyncCursor(FormatSyncCursor(now))
+	require.NoError(t, err)
+	assert.True(t, now.Equal(parsed))
+
+	parsed, err = ParseSyncCursor("2026-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, 2026, parsed.Year())
+
+	_, err = ParseSyncCursor("yesterday")
+	assert.ErrorIs(t, err, ErrInvalidSyncCursor)
+}
+
+func TestGetSyncChanges(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{}, &Assistant{}, &CallRecording{}, &SyncTombstone{})
+
+	require.NoError(t, db.Create(&Device{ID: "aa:bb", MacAddress: "aa:bb", UserID: 1}).Error)
+	require.NoError(t, db.Create(&Device{ID: "cc:dd", MacAddress: "cc:dd", UserID: 2}).Error)
+	require.NoError(t, db.Create(&Assistant{UserID: 1, Name: "a"}).Error)
+	kept := CallRecording{UserID: 1, AssistantID: 1}
+	require.NoError(t, db.Create(&kept).Error)
+	removed := CallRecording{UserID: 1, AssistantID: 1}
+	require.NoError(t, db.Create(&removed).Error)
+	require.NoError(t, db.Model(&removed).Update("is_deleted", 1).Error)
+	time.Sleep(5 * time.Millisecond)
+
+	// First sync is a full snapshot without deleted rows
+	full, err := GetSyncChanges(db, 1, time.Time{}, 0)
+	require.NoError(t, err)
+	assert.True(t, full.Full)
+	assert.False(t, full.HasMore)
+	require.Len(t, full.Devices, 1)
+	assert.Equal(t, "aa:bb", full.Devices[0].ID)
+	assert.Len(t, full.Assistants, 1)
+	require.Len(t, full.Recordings, 1)
+	assert.Equal(t, kept.ID, full.Recordings[0].ID)
+	assert.Empty(t, full.Deleted)
+
+	cursor, err := ParseSyncCursor(full.Cursor)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	// Incremental sync only returns what changed after the cursor
+	require.NoError(t, db.Delete(&Device{}, "id = ?", "aa:bb").Error)
+	require.NoError(t, RecordSyncTombstone(db, 1, SyncEntityDevice, "aa:bb"))
+	require.NoError(t, db.Model(&kept).Update("is_deleted", 1).Error)
+
+	delta, err := GetSyncChanges(db, 1, cursor, 0)
+	require.NoError(t, err)
+	assert.False(t, delta.Full)
+	assert.Empty(t, delta.Devices)
+	assert.Empty(t, delta.Assistants)
+	assert.Empty(t, delta.Recordings)
+	var deleted []string
+	for _, d := range delta.Deleted {
+		deleted = append(deleted, d.Entity+":"+d.EntityID)
+	}
+	assert.ElementsMatch(t, []string{"device:aa:bb", "recording:" + strconv.FormatUint(uint64(kept.ID), 10)}, deleted)
+
+	// Stale cursors fall back to a full sync
+	stale, err := GetSyncChanges(db, 1, time.Now().Add(-2*SyncTombstoneRetention), 0)
+	require.NoError(t, err)
+	assert.True(t, stale.Full)
+	assert.Empty(t, stale.Deleted)
+
+	pruned, err := PruneSyncTombstones(db, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+}
+
+func TestGetSyncChanges_Paging(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{}, &Assistant{}, &CallRecording{}, &SyncTombstone{})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, db.Create(&Assistant{UserID: 1, Name: "a"}).Error)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	seen := map[int64]bool{}
+	var since time.Time
+	for page := 0; page < 5; page++ {
+		changes, err := GetSyncChanges(db, 1, since, 2)
+		require.NoError(t, err)
+		for _, a := range changes.Assistants {
+			seen[a.ID] = true
+		}
+		since, err = ParseSyncCursor(changes.Cursor)
+		require.NoError(t, err)
+		if !changes.HasMore {
+			break
+		}
+	}
+	assert.Len(t, seen, 3)
+}
@@ -0,0 +1,463 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Inbox conversation status
+const (
+	InboxStatusOpen     = "open"     // Waiting for the team
+	InboxStatusPending  = "pending"  // Waiting for the customer or a third party, SLA timers are not checked
+	InboxStatusResolved = "resolved" // Done
+)
+
+// Inbox conversation source
+const (
+	InboxSourceEscalation = "escalation" // Escalated manually or by the conversation analysis
+	InboxSourceFlag       = "flag"       // Flagged by a teammate
+)
+
+// Inbox conversation priority
+const (
+	InboxPriorityUrgent = "urgent"
+	InboxPriorityHigh   = "high"
+	InboxPriorityNormal = "normal"
+	InboxPriorityLow    = "low"
+)
+
+var (
+	ErrInboxStatus   = errors.New("status must be open, pending or resolved")
+	ErrInboxPriority = errors.New("priority must be urgent, high, normal or low")
+	ErrInboxTarget   = errors.New("a call recording or chat session is required")
+	ErrInboxAssignee = errors.New("assignee is not a member of the assistant's team")
+	ErrInboxNoteBody = errors.New("note must be 1-4000 characters")
+)
+
+const (
+	inboxMaxNoteRunes    = 4000
+	inboxMaxSubjectRunes = 255
+)
+
+// InboxSLA response targets of a priority
+type InboxSLA struct {
+	FirstResponse time.Duration `json:"firstResponse"` // Until a teammate first acts on the conversation
+	Resolve       time.Duration `json:"resolve"`       // Until it is resolved
+}
+
+// InboxSLAPolicies SLA targets per priority
+var InboxSLAPolicies = map[string]InboxSLA{
+	InboxPriorityUrgent: {FirstResponse: 15 * time.Minute, Resolve: 4 * time.Hour},
+	InboxPriorityHigh:   {FirstResponse: time.Hour, Resolve: 8 * time.Hour},
+	InboxPriorityNormal: {FirstResponse: 4 * time.Hour, Resolve: 24 * time.Hour},
+	InboxPriorityLow:    {FirstResponse: 24 * time.Hour, Resolve: 72 * time.Hour},
+}
+
+// InboxConversation escalated or flagged conversation worked by the team of
+// the assistant: its owner and everyone who can use it through an organization
+type InboxConversation struct {
+	ID                 uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt          time.Time  `json:"createdAt" gorm:"autoCreateTime;index"`
+	UpdatedAt          time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID        int64      `json:"assistantId" gorm:"index;not null"`
+	UserID             uint       `json:"userId" gorm:"index"` // Assistant owner
+	Source             string     `json:"source" gorm:"size:16"`
+	CallRecordingID    uint       `json:"callRecordingId,omitempty" gorm:"index"`
+	SessionID          string     `json:"sessionId,omitempty" gorm:"size:128;index"` // Chat session
+	Subject            string     `json:"subject" gorm:"size:255"`
+	Summary            string     `json:"summary,omitempty" gorm:"type:text"`
+	Reason             string     `json:"reason,omitempty" gorm:"type:text"`
+	Status             string     `json:"status" gorm:"size:16;index"`
+	Priority           string     `json:"priority" gorm:"size:16"`
+	AssigneeID         *uint      `json:"assigneeId,omitempty" gorm:"index"`
+	CreatedBy          uint       `json:"createdBy"` // 0 when opened automatically
+	FirstResponseDueAt time.Time  `json:"firstResponseDueAt"`
+	ResolveDueAt       time.Time  `json:"resolveDueAt" gorm:"index"`
+	FirstRespondedAt   *time.Time `json:"firstRespondedAt,omitempty"`
+	ResolvedAt         *time.Time `json:"resolvedAt,omitempty"`
+	SLABreachedAt      *time.Time `json:"slaBreachedAt,omitempty" gorm:"index"` // First missed target, notified once
+	NoteCount          int        `json:"noteCount"`
+}
+
+// TableName 指定表名
+func (InboxConversation) TableName() string {
+	return "inbox_conversations"
+}
+
+// InboxNote internal note on an inbox conversation, only visible to the team
+type InboxNote struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	ConversationID uint      `json:"conversationId" gorm:"index;not null"`
+	AuthorID       uint      `json:"authorId" gorm:"index"`
+	Body           string    `json:"body" gorm:"type:text"`
+}
+
+// TableName 指定表名
+func (InboxNote) TableName() string {
+	return "inbox_notes"
+}
+
+// SLAState reports the SLA target the conversation is currently measured
+// against: first_response, resolve, or empty when resolved or pending
+func (c *InboxConversation) SLAState() string {
+	switch {
+	case c.Status != InboxStatusOpen:
+		return ""
+	case c.FirstRespondedAt == nil:
+		return "first_response"
+	}
+	return "resolve"
+}
+
+// Breached reports whether the current SLA target was missed at now
+func (c *InboxConversation) Breached(now time.Time) bool {
+	switch c.SLAState() {
+	case "first_response":
+		return now.After(c.FirstResponseDueAt)
+	case "resolve":
+		return now.After(c.ResolveDueAt)
+	}
+	return false
+}
+
+func validInboxPriority(priority string) bool {
+	_, ok := InboxSLAPolicies[priority]
+	return ok
+}
+
+// OpenInboxConversation puts a conversation in the team inbox. A conversation
+// that is already open or pending is returned instead of a duplicate; a
+// resolved one is reopened. created reports whether a new entry was made
+func OpenInboxConversation(db *gorm.DB, conv *InboxConversation, now time.Time) (result *InboxConversation, created bool, err error) {
+	if conv.CallRecordingID == 0 && conv.SessionID == "" {
+		return nil, false, ErrInboxTarget
+	}
+	if conv.Priority == "" {
+		conv.Priority = InboxPriorityNormal
+	}
+	if !validInboxPriority(conv.Priority) {
+		return nil, false, ErrInboxPriority
+	}
+
+	var existing InboxConversation
+	q := db.Where("assistant_id = ?", conv.AssistantID)
+	if conv.CallRecordingID != 0 {
+		q = q.Where("call_recording_id = ?", conv.CallRecordingID)
+	} else {
+		q = q.Where("session_id = ?", conv.SessionID)
+	}
+	err = q.Order("id DESC").First(&existing).Error
+	switch {
+	case err == nil:
+		if existing.Status != InboxStatusResolved {
+			return &existing, false, nil
+		}
+		if err := SetInboxStatus(db, &existing, InboxStatusOpen, now); err != nil {
+			return nil, false, err
+		}
+		if conv.Reason != "" {
+			db.Model(&existing).Update("reason", conv.Reason)
+			existing.Reason = conv.Reason
+		}
+		return &existing, false, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, false, err
+	}
+
+	sla := InboxSLAPolicies[conv.Priority]
+	conv.ID = 0
+	conv.Status = InboxStatusOpen
+	conv.Subject = truncateRunes(strings.TrimSpace(conv.Subject), inboxMaxSubjectRunes-1)
+	if conv.Subject == "" {
+		conv.Subject = "Conversation " + conv.SessionID
+		if conv.CallRecordingID != 0 {
+			conv.Subject = "Call recording #" + strconv.FormatUint(uint64(conv.CallRecordingID), 10)
+		}
+	}
+	conv.FirstResponseDueAt = now.Add(sla.FirstResponse)
+	conv.ResolveDueAt = now.Add(sla.Resolve)
+	if err := db.Create(conv).Error; err != nil {
+		return nil, false, err
+	}
+	return conv, true, nil
+}
+
+// InboxAssistantIDs assistants whose inbox the user works: owned assistants,
+// assistants of the user's organizations and assistants shared with them
+func InboxAssistantIDs(db *gorm.DB, userID uint) ([]int64, error) {
+	q := db.Model(&Assistant{}).Where("user_id = ?", userID)
+	if groupIDs := userGroupIDs(db, userID); len(groupIDs) > 0 {
+		q = q.Or("group_id IN ?", groupIDs)
+	}
+	var ids []int64
+	if err := q.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return append(ids, SharedAssistantIDs(db, userID)...), nil
+}
+
+// InboxTeamIDs users who work the assistant's inbox: the owner and the members
+// of the organization owning it or the organizations it is shared with
+func InboxTeamIDs(db *gorm.DB, assistant *Assistant) ([]uint, error) {
+	var groupIDs []uint
+	if assistant.GroupID != nil {
+		groupIDs = append(groupIDs, *assistant.GroupID)
+	}
+	var shared []uint
+	if err := db.Model(&AssistantShare{}).Where("assistant_id = ?", assistant.ID).Pluck("group_id", &shared).Error; err != nil {
+		return nil, err
+	}
+	return GroupUserIDs(db, append(groupIDs, shared...), assistant.UserID)
+}
+
+// InboxFilter filters of the inbox list
+type InboxFilter struct {
+	AssistantID int64
+	Status      string
+	Source      string
+	AssigneeID  uint
+	Unassigned  bool
+	Breached    bool // Open conversations past their current SLA target
+	Page        int
+	PageSize    int
+}
+
+// ListInboxConversations lists the conversations of the user's inbox, SLA
+// deadlines first, with the number of conversations per status
+func ListInboxConversations(db *gorm.DB, userID uint, filter InboxFilter, now time.Time) ([]InboxConversation, int64, map[string]int64, error) {
+	assistantIDs, err := InboxAssistantIDs(db, userID)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if filter.AssistantID != 0 {
+		allowed := false
+		for _, id := range assistantIDs {
+			allowed = allowed || id == filter.AssistantID
+		}
+		if !allowed {
+			return []InboxConversation{}, 0, map[string]int64{}, nil
+		}
+		assistantIDs = []int64{filter.AssistantID}
+	}
+	if len(assistantIDs) == 0 {
+		return []InboxConversation{}, 0, map[string]int64{}, nil
+	}
+
+	base := db.Model(&InboxConversation{}).Where("assistant_id IN ?", assistantIDs)
+	if filter.Source != "" {
+		base = base.Where("source = ?", filter.Source)
+	}
+	if filter.Unassigned {
+		base = base.Where("assignee_id IS NULL")
+	} else if filter.AssigneeID != 0 {
+		base = base.Where("assignee_id = ?", filter.AssigneeID)
+	}
+
+	counts := map[string]int64{}
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := base.Session(&gorm.Session{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, 0, nil, err
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	q := base.Session(&gorm.Session{})
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.Breached {
+		q = q.Where("status = ? AND ((first_responded_at IS NULL AND first_response_due_at < ?) OR (first_responded_at IS NOT NULL AND resolve_due_at < ?))",
+			InboxStatusOpen, now, now)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+	var list []InboxConversation
+	err = q.Order("CASE status WHEN 'open' THEN 0 WHEN 'pending' THEN 1 ELSE 2 END").
+		Order("resolve_due_at ASC").Order("id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).Find(&list).Error
+	return list, total, counts, err
+}
+
+// GetInboxConversation loads a conversation of the user's inbox
+func GetInboxConversation(db *gorm.DB, userID, id uint) (*InboxConversation, *Assistant, error) {
+	var conv InboxConversation
+	if err := db.First(&conv, id).Error; err != nil {
+		return nil, nil, err
+	}
+	var assistant Assistant
+	if err := db.First(&assistant, conv.AssistantID).Error; err != nil {
+		return nil, nil, err
+	}
+	if !CanUseAssistant(db, &assistant, userID) {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	return &conv, &assistant, nil
+}
+
+// markInboxResponded stops the first response timer the first time a teammate
+// acts on the conversation
+func markInboxResponded(updates map[string]any, conv *InboxConversation, now time.Time) {
+	if conv.FirstRespondedAt == nil {
+		conv.FirstRespondedAt = &now
+		updates["first_responded_at"] = now
+	}
+}
+
+// AssignInboxConversation assigns the conversation to a teammate, assigneeID 0 unassigns it
+func AssignInboxConversation(db *gorm.DB, conv *InboxConversation, assistant *Assistant, assigneeID uint, now time.Time) error {
+	updates := map[string]any{}
+	if assigneeID == 0 {
+		conv.AssigneeID = nil
+		updates["assignee_id"] = nil
+	} else {
+		team, err := InboxTeamIDs(db, assistant)
+		if err != nil {
+			return err
+		}
+		member := false
+		for _, id := range team {
+			member = member || id == assigneeID
+		}
+		if !member {
+			return ErrInboxAssignee
+		}
+		conv.AssigneeID = &assigneeID
+		updates["assignee_id"] = assigneeID
+		markInboxResponded(updates, conv, now)
+	}
+	return db.Model(conv).Updates(updates).Error
+}
+
+// SetInboxStatus changes the status. Resolving stops the timers; reopening a
+// resolved conversation starts a new resolve target
+func SetInboxStatus(db *gorm.DB, conv *InboxConversation, status string, now time.Time) error {
+	if status != InboxStatusOpen && status != InboxStatusPending && status != InboxStatusResolved {
+		return ErrInboxStatus
+	}
+	updates := map[string]any{"status": status}
+	switch {
+	case status == InboxStatusResolved:
+		conv.ResolvedAt = &now
+		updates["resolved_at"] = now
+	case conv.Status == InboxStatusResolved:
+		conv.ResolvedAt = nil
+		conv.SLABreachedAt = nil
+		conv.ResolveDueAt = now.Add(InboxSLAPolicies[conv.Priority].Resolve)
+		updates["resolved_at"] = nil
+		updates["sla_breached_at"] = nil
+		updates["resolve_due_at"] = conv.ResolveDueAt
+	}
+	if status != InboxStatusOpen {
+		markInboxResponded(updates, conv, now)
+	}
+	conv.Status = status
+	return db.Model(conv).Updates(updates).Error
+}
+
+// SetInboxPriority changes the priority and moves the SLA targets, measured
+// from when the conversation was opened
+func SetInboxPriority(db *gorm.DB, conv *InboxConversation, priority string) error {
+	if !validInboxPriority(priority) {
+		return ErrInboxPriority
+	}
+	sla := InboxSLAPolicies[priority]
+	conv.Priority = priority
+	conv.FirstResponseDueAt = conv.CreatedAt.Add(sla.FirstResponse)
+	conv.ResolveDueAt = conv.CreatedAt.Add(sla.Resolve)
+	return db.Model(conv).Updates(map[string]any{
+		"priority":              priority,
+		"first_response_due_at": conv.FirstResponseDueAt,
+		"resolve_due_at":        conv.ResolveDueAt,
+	}).Error
+}
+
+// AddInboxNote adds an internal note, which counts as the team's first response
+func AddInboxNote(db *gorm.DB, conv *InboxConversation, authorID uint, body string, now time.Time) (*InboxNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || len([]rune(body)) > inboxMaxNoteRunes {
+		return nil, ErrInboxNoteBody
+	}
+	note := &InboxNote{ConversationID: conv.ID, AuthorID: authorID, Body: body}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(note).Error; err != nil {
+			return err
+		}
+		updates := map[string]any{"note_count": gorm.Expr("note_count + 1")}
+		markInboxResponded(updates, conv, now)
+		return tx.Model(conv).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	conv.NoteCount++
+	return note, nil
+}
+
+// ListInboxNotes notes of a conversation, oldest first
+func ListInboxNotes(db *gorm.DB, conversationID uint) ([]InboxNote, error) {
+	var notes []InboxNote
+	err := db.Where("conversation_id = ?", conversationID).Order("id ASC").Find(&notes).Error
+	return notes, err
+}
+
+// ClaimInboxSLABreaches returns open conversations that missed their current
+// SLA target and were not reported yet, marking them as reported
+func ClaimInboxSLABreaches(db *gorm.DB, now time.Time, limit int) ([]InboxConversation, error) {
+	var list []InboxConversation
+	err := db.Where("status = ? AND sla_breached_at IS NULL", InboxStatusOpen).
+		Where("(first_responded_at IS NULL AND first_response_due_at < ?) OR (first_responded_at IS NOT NULL AND resolve_due_at < ?)", now, now).
+		Order("id").Limit(limit).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	claimed := list[:0]
+	for i := range list {
+		result := db.Model(&InboxConversation{}).Where("id = ? AND sla_breached_at IS NULL", list[i].ID).Update("sla_breached_at", now)
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			list[i].SLABreachedAt = &now
+			claimed = append(claimed, list[i])
+		}
+	}
+	return claimed, nil
+}
+
+// NotifyInbox notifies teammates about an inbox conversation in-app and by
+// push, subject to their notification preferences
+func NotifyInbox(db *gorm.DB, conv *InboxConversation, recipients []uint, title, content string) {
+	for _, id := range recipients {
+		user, err := GetUserByUID(db, id)
+		if err != nil {
+			continue
+		}
+		DispatchNotification(db, user, Notice{
+			Event:    NotificationEventInbox,
+			Title:    title,
+			Content:  content,
+			Channels: []NotificationChannel{NotificationChannelInternal, NotificationChannelPush},
+			Data:     map[string]any{"conversationId": conv.ID, "assistantId": conv.AssistantID},
+			PushData: map[string]string{"conversationId": fmt.Sprint(conv.ID)},
+		})
+	}
+}
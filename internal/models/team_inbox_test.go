@@ -0,0 +1,153 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupInboxDB(t *testing.T) (*gorm.DB, *Assistant) {
+	t.Helper()
+	db := setupTestDBWithSilentLogger(t, &Assistant{}, &AssistantShare{}, &Group{}, &GroupMember{}, &InboxConversation{}, &InboxNote{})
+	groupID := uint(3)
+	require.NoError(t, db.Create(&Group{ID: groupID, Name: "support", CreatorID: 1}).Error)
+	require.NoError(t, db.Create(&GroupMember{GroupID: groupID, UserID: 2}).Error)
+	assistant := &Assistant{ID: 10, UserID: 1, GroupID: &groupID, Name: "helpdesk"}
+	require.NoError(t, db.Create(assistant).Error)
+	require.NoError(t, db.Create(&Assistant{ID: 11, UserID: 9, Name: "other"}).Error)
+	return db, assistant
+}
+
+func TestOpenInboxConversation(t *testing.T) {
+	db, assistant := setupInboxDB(t)
+	now := time.Now()
+
+	_, _, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID}, now)
+	assert.ErrorIs(t, err, ErrInboxTarget)
+	_, _, err = OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, SessionID: "s1", Priority: "asap"}, now)
+	assert.ErrorIs(t, err, ErrInboxPriority)
+
+	conv, created, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, CallRecordingID: 5, Priority: InboxPriorityUrgent}, now)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, InboxStatusOpen, conv.Status)
+	assert.Equal(t, "Call recording #5", conv.Subject)
+	assert.Equal(t, now.Add(15*time.Minute), conv.FirstResponseDueAt)
+	assert.Equal(t, now.Add(4*time.Hour), conv.ResolveDueAt)
+
+	again, created, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, CallRecordingID: 5}, now)
+	require.NoError(t, err)
+	assert.False(t, created, "an open conversation is not duplicated")
+	assert.Equal(t, conv.ID, again.ID)
+
+	require.NoError(t, SetInboxStatus(db, conv, InboxStatusResolved, now))
+	reopened, created, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, CallRecordingID: 5, Reason: "called back"}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, conv.ID, reopened.ID)
+	assert.Equal(t, InboxStatusOpen, reopened.Status)
+	assert.Nil(t, reopened.ResolvedAt)
+	assert.Equal(t, now.Add(5*time.Hour), reopened.ResolveDueAt, "reopening starts a new resolve target")
+	assert.Equal(t, "called back", reopened.Reason)
+}
+
+func TestInboxTeamAndAccess(t *testing.T) {
+	db, assistant := setupInboxDB(t)
+
+	team, err := InboxTeamIDs(db, assistant)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{1, 2}, team)
+
+	conv, _, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, SessionID: "s1"}, time.Now())
+	require.NoError(t, err)
+	_, _, err = GetInboxConversation(db, 2, conv.ID)
+	assert.NoError(t, err, "organization members work the inbox")
+	_, _, err = GetInboxConversation(db, 9, conv.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	assert.ErrorIs(t, AssignInboxConversation(db, conv, assistant, 9, time.Now()), ErrInboxAssignee)
+	require.NoError(t, AssignInboxConversation(db, conv, assistant, 2, time.Now()))
+	assert.Equal(t, uint(2), *conv.AssigneeID)
+	assert.NotNil(t, conv.FirstRespondedAt, "assignment counts as the first response")
+	require.NoError(t, AssignInboxConversation(db, conv, assistant, 0, time.Now()))
+	assert.Nil(t, conv.AssigneeID)
+}
+
+func TestListInboxConversations(t *testing.T) {
+	db, assistant := setupInboxDB(t)
+	now := time.Now()
+
+	urgent, _, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, SessionID: "a", Priority: InboxPriorityUrgent}, now.Add(-time.Hour))
+	require.NoError(t, err)
+	low, _, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, SessionID: "b", Priority: InboxPriorityLow, Source: InboxSourceFlag}, now)
+	require.NoError(t, err)
+	done, _, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, SessionID: "c"}, now)
+	require.NoError(t, err)
+	require.NoError(t, SetInboxStatus(db, done, InboxStatusResolved, now))
+	_, _, err = OpenInboxConversation(db, &InboxConversation{AssistantID: 11, SessionID: "d"}, now)
+	require.NoError(t, err)
+	require.NoError(t, AssignInboxConversation(db, low, assistant, 2, now))
+
+	list, total, counts, err := ListInboxConversations(db, 2, InboxFilter{}, now)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total, "other teams' conversations are not listed")
+	require.Len(t, list, 3)
+	assert.Equal(t, []uint{urgent.ID, low.ID, done.ID}, []uint{list[0].ID, list[1].ID, list[2].ID})
+	assert.Equal(t, map[string]int64{InboxStatusOpen: 2, InboxStatusResolved: 1}, counts)
+
+	list, _, _, err = ListInboxConversations(db, 2, InboxFilter{Breached: true}, now)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, urgent.ID, list[0].ID)
+
+	list, _, _, err = ListInboxConversations(db, 1, InboxFilter{AssigneeID: 2}, now)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, low.ID, list[0].ID)
+
+	list, total, _, err = ListInboxConversations(db, 1, InboxFilter{AssistantID: 11}, now)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Zero(t, total)
+}
+
+func TestInboxSLA(t *testing.T) {
+	db, assistant := setupInboxDB(t)
+	now := time.Now()
+
+	conv, _, err := OpenInboxConversation(db, &InboxConversation{AssistantID: assistant.ID, SessionID: "s1", Priority: InboxPriorityHigh}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "first_response", conv.SLAState())
+	assert.False(t, conv.Breached(now))
+
+	breaches, err := ClaimInboxSLABreaches(db, now.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, breaches, 1)
+	breaches, err = ClaimInboxSLABreaches(db, now.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, breaches, "a breach is reported once")
+
+	note, err := AddInboxNote(db, conv, 2, "  looking into it ", now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "looking into it", note.Body)
+	assert.Equal(t, "resolve", conv.SLAState())
+	_, err = AddInboxNote(db, conv, 2, " ", now)
+	assert.ErrorIs(t, err, ErrInboxNoteBody)
+
+	require.NoError(t, SetInboxPriority(db, conv, InboxPriorityLow))
+	assert.Equal(t, conv.CreatedAt.Add(72*time.Hour), conv.ResolveDueAt)
+
+	require.NoError(t, SetInboxStatus(db, conv, InboxStatusPending, now))
+	assert.False(t, conv.Breached(now.Add(100*time.Hour)), "pending conversations wait on the customer")
+	assert.ErrorIs(t, SetInboxStatus(db, conv, "closed", now), ErrInboxStatus)
+
+	var stored InboxConversation
+	require.NoError(t, db.First(&stored, conv.ID).Error)
+	assert.Equal(t, 1, stored.NoteCount)
+	notes, err := ListInboxNotes(db, conv.ID)
+	require.NoError(t, err)
+	assert.Len(t, notes, 1)
+}
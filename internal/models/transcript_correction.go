@@ -0,0 +1,318 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 转写修正状态
+const (
+	CorrectionPending  = "pending"
+	CorrectionAccepted = "accepted"
+	CorrectionRejected = "rejected"
+)
+
+const (
+	// MaxCorrectionLength 修正文本的最大字符数
+	MaxCorrectionLength = 2000
+	// MaxAssistantVocabulary 识别时带上的助手热词数量上限
+	MaxAssistantVocabulary = 100
+	// DefaultVocabularyWeight 修正产生的热词权重
+	DefaultVocabularyWeight = 10
+
+	minTermRunes = 2
+	maxTermRunes = 20
+)
+
+// 热词来源
+const (
+	VocabularySourceCorrection = "correction"
+	VocabularySourceManual     = "manual"
+)
+
+var (
+	ErrInvalidCorrection   = errors.New("corrected text must be non-empty, at most 2000 characters and differ from the transcript")
+	ErrCorrectionReviewed  = errors.New("correction has already been reviewed")
+	ErrInvalidReviewStatus = errors.New("status must be accepted or rejected")
+	ErrInvalidVocabulary   = errors.New("words must be 2 to 20 characters with a weight between 1 and 10")
+)
+
+// TranscriptCorrection 用户对通话转写中识别错误的修正，作为覆盖层保存，录音中的原始转写不变
+type TranscriptCorrection struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	RecordingID uint       `json:"recordingId" gorm:"not null;uniqueIndex:idx_correction_turn"`
+	TurnID      int        `json:"turnId" gorm:"uniqueIndex:idx_correction_turn"`
+	AssistantID uint       `json:"assistantId" gorm:"index"`
+	SessionID   string     `json:"sessionId" gorm:"size:128;index"`
+	Original    string     `json:"original" gorm:"type:text"` // 修正时的识别结果，识别结果变化后修正不再生效
+	Corrected   string     `json:"corrected" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"size:16;index"` // pending / accepted / rejected
+	CreatedBy   uint       `json:"createdBy"`
+	ReviewedBy  *uint      `json:"reviewedBy,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewedAt,omitempty"`
+	Terms       string     `json:"terms,omitempty" gorm:"size:512"` // 采纳后加入助手热词的词语，逗号分隔
+}
+
+// TableName 指定表名
+func (TranscriptCorrection) TableName() string {
+	return "transcript_corrections"
+}
+
+// AssistantVocabulary 助手的语音识别热词，识别时提高这些词的权重
+type AssistantVocabulary struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	AssistantID int64     `json:"assistantId" gorm:"not null;uniqueIndex:idx_assistant_word"`
+	Word        string    `json:"word" gorm:"size:64;not null;uniqueIndex:idx_assistant_word"`
+	Weight      int       `json:"weight"`
+	Source      string    `json:"source" gorm:"size:16"` // correction / manual
+	Hits        int       `json:"hits"`                  // 采纳的修正中出现的次数
+}
+
+// TableName 指定表名
+func (AssistantVocabulary) TableName() string {
+	return "assistant_vocabularies"
+}
+
+// SubmitTranscriptCorrection 提交用户轮次的修正，同一轮次再次提交时覆盖之前的修正并重新待审核
+func SubmitTranscriptCorrection(db *gorm.DB, recording *CallRecording, turnID int, text string, userID uint) (*TranscriptCorrection, error) {
+	details, err := recording.GetConversationDetails()
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, ErrNoConversationDetails
+	}
+	var original string
+	found := false
+	for _, turn := range details.Turns {
+		if turn.TurnID == turnID && turn.Type == "user" {
+			original, found = turn.Content, true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrUnknownTurn
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || text == original || utf8.RuneCountInString(text) > MaxCorrectionLength {
+		return nil, ErrInvalidCorrection
+	}
+
+	correction := &TranscriptCorrection{
+		RecordingID: recording.ID,
+		TurnID:      turnID,
+		AssistantID: recording.AssistantID,
+		SessionID:   recording.SessionID,
+		Original:    original,
+		Corrected:   text,
+		Status:      CorrectionPending,
+		CreatedBy:   userID,
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "recording_id"}, {Name: "turn_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"original": original, "corrected": text, "status": CorrectionPending, "created_by": userID, "reviewed_by": nil, "reviewed_at": nil, "terms": "", "updated_at": time.Now()}),
+	}).Create(correction).Error
+	if err != nil {
+		return nil, err
+	}
+	return correction, db.Where("recording_id = ? AND turn_id = ?", recording.ID, turnID).First(correction).Error
+}
+
+// ReviewTranscriptCorrection 采纳或驳回修正，采纳时把修正的词语加入助手热词
+func ReviewTranscriptCorrection(db *gorm.DB, correction *TranscriptCorrection, status string, reviewerID uint, now time.Time) error {
+	if status != CorrectionAccepted && status != CorrectionRejected {
+		return ErrInvalidReviewStatus
+	}
+	if correction.Status != CorrectionPending {
+		return ErrCorrectionReviewed
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		terms := []string{}
+		if status == CorrectionAccepted {
+			terms = CorrectionTerms(correction.Original, correction.Corrected)
+			for _, term := range terms {
+				if err := addVocabulary(tx, int64(correction.AssistantID), term); err != nil {
+					return err
+				}
+			}
+		}
+		updates := map[string]interface{}{
+			"status":      status,
+			"reviewed_by": reviewerID,
+			"reviewed_at": now,
+			"terms":       strings.Join(terms, ","),
+		}
+		res := tx.Model(&TranscriptCorrection{}).Where("id = ? AND status = ?", correction.ID, CorrectionPending).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrCorrectionReviewed
+		}
+		correction.Status = status
+		correction.ReviewedBy = &reviewerID
+		correction.ReviewedAt = &now
+		correction.Terms = strings.Join(terms, ",")
+		return nil
+	})
+}
+
+// addVocabulary 加入热词，已有的热词累加采纳次数
+func addVocabulary(db *gorm.DB, assistantID int64, word string) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "assistant_id"}, {Name: "word"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"hits": gorm.Expr("assistant_vocabularies.hits + 1"), "updated_at": time.Now()}),
+	}).Create(&AssistantVocabulary{
+		AssistantID: assistantID,
+		Word:        word,
+		Weight:      DefaultVocabularyWeight,
+		Source:      VocabularySourceCorrection,
+		Hits:        1,
+	}).Error
+}
+
+// CorrectionTerms 提取修正中改动的词语：去掉前后相同的部分，改动处补全为完整的英文单词，
+// 单个汉字补上后一个（没有时前一个）汉字，按空白和标点拆分后保留 2~20 个字符的词语
+func CorrectionTerms(original, corrected string) []string {
+	a, b := []rune(original), []rune(corrected)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	start, end := prefix, len(b)-suffix
+	if start >= end {
+		return []string{}
+	}
+	// 补全被部分修改的英文单词或数字
+	for start > 0 && isWordRune(b[start-1]) && isWordRune(b[start]) {
+		start--
+	}
+	for end < len(b) && isWordRune(b[end-1]) && isWordRune(b[end]) {
+		end++
+	}
+	if end-start < minTermRunes {
+		if end < len(b) && unicode.Is(unicode.Han, b[end]) {
+			end++
+		} else if start > 0 && unicode.Is(unicode.Han, b[start-1]) {
+			start--
+		}
+	}
+
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, field := range strings.FieldsFunc(string(b[start:end]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) {
+		n := utf8.RuneCountInString(field)
+		if n < minTermRunes || n > maxTermRunes || seen[field] {
+			continue
+		}
+		seen[field] = true
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// ListTranscriptCorrections 列出录音的修正
+func ListTranscriptCorrections(db *gorm.DB, recordingID uint) ([]TranscriptCorrection, error) {
+	var corrections []TranscriptCorrection
+	err := db.Where("recording_id = ?", recordingID).Order("turn_id").Find(&corrections).Error
+	return corrections, err
+}
+
+// ApplyTranscriptCorrections 用已采纳的修正覆盖轮次内容并标记为已修正，原文保留在 OriginalContent。
+// 修正后识别结果又发生变化的轮次不覆盖
+func ApplyTranscriptCorrections(db *gorm.DB, recordingID uint, details *ConversationDetails) error {
+	if details == nil {
+		return nil
+	}
+	var corrections []TranscriptCorrection
+	if err := db.Where("recording_id = ? AND status = ?", recordingID, CorrectionAccepted).Find(&corrections).Error; err != nil {
+		return err
+	}
+	byTurn := make(map[int]*TranscriptCorrection, len(corrections))
+	for i := range corrections {
+		byTurn[corrections[i].TurnID] = &corrections[i]
+	}
+	for i := range details.Turns {
+		turn := &details.Turns[i]
+		c, ok := byTurn[turn.TurnID]
+		if !ok || turn.Type != "user" || turn.Content != c.Original {
+			continue
+		}
+		turn.OriginalContent = turn.Content
+		turn.Content = c.Corrected
+		turn.Corrected = true
+	}
+	return nil
+}
+
+// CorrectedConversationDetails 读取录音对话并应用已采纳的修正，供查看、导出和分析使用
+func CorrectedConversationDetails(db *gorm.DB, recording *CallRecording) (*ConversationDetails, error) {
+	details, err := recording.GetConversationDetails()
+	if err != nil || details == nil {
+		return details, err
+	}
+	return details, ApplyTranscriptCorrections(db, recording.ID, details)
+}
+
+// ListAssistantVocabulary 按采纳次数列出助手热词
+func ListAssistantVocabulary(db *gorm.DB, assistantID int64, limit int) ([]AssistantVocabulary, error) {
+	var words []AssistantVocabulary
+	query := db.Where("assistant_id = ?", assistantID).Order("hits DESC, id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&words).Error
+	return words, err
+}
+
+// SaveManualVocabulary 手动添加或修改助手热词
+func SaveManualVocabulary(db *gorm.DB, assistantID int64, word string, weight int) (*AssistantVocabulary, error) {
+	word = strings.TrimSpace(word)
+	if n := utf8.RuneCountInString(word); n < minTermRunes || n > maxTermRunes {
+		return nil, ErrInvalidVocabulary
+	}
+	if weight < 1 || weight > DefaultVocabularyWeight {
+		return nil, ErrInvalidVocabulary
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "assistant_id"}, {Name: "word"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"weight": weight, "updated_at": time.Now()}),
+	}).Create(&AssistantVocabulary{AssistantID: assistantID, Word: word, Weight: weight, Source: VocabularySourceManual}).Error
+	if err != nil {
+		return nil, err
+	}
+	var saved AssistantVocabulary
+	return &saved, db.Where("assistant_id = ? AND word = ?", assistantID, word).First(&saved).Error
+}
+
+// DeleteAssistantVocabulary 删除助手热词
+func DeleteAssistantVocabulary(db *gorm.DB, assistantID int64, id uint) error {
+	res := db.Where("id = ? AND assistant_id = ?", id, assistantID).Delete(&AssistantVocabulary{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrectionTerms(t *testing.T) {
+	cases := []struct {
+		original, corrected string
+		want                []string
+	}{
+		{"我想咨询零西的价格", "我想咨询灵犀的价格", []string{"灵犀"}},
+		{"帮我查一下订单", "帮我查一下定单", []string{"定单"}},
+		{"I use lingo echo daily", "I use LingEcho daily", []string{"LingEcho"}},
+		{"call me at 1380013800", "call me at 13800138000", []string{"13800138000"}},
+		{"打开空调", "打开空调", []string{}},
+		{"好", "嗯", []string{}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, CorrectionTerms(c.original, c.corrected), c.corrected)
+	}
+}
+
+func TestTranscriptCorrections(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &CallRecording{}, &TranscriptCorrection{}, &AssistantVocabulary{})
+	recording := &CallRecording{UserID: 2, AssistantID: 5, SessionID: "s1"}
+	require.NoError(t, recording.SetConversationDetails(&ConversationDetails{
+		Turns: []ConversationTurn{
+			{TurnID: 1, Type: "user", Content: "我想咨询零西的价格"},
+			{TurnID: 2, Type: "ai", Content: "好的"},
+			{TurnID: 3, Type: "user", Content: "帮我查一下订单"},
+		},
+	}))
+	require.NoError(t, db.Create(recording).Error)
+
+	_, err := SubmitTranscriptCorrection(db, recording, 2, "不好", 7)
+	assert.ErrorIs(t, err, ErrUnknownTurn, "only user turns are transcribed")
+	_, err = SubmitTranscriptCorrection(db, recording, 1, " 我想咨询零西的价格 ", 7)
+	assert.ErrorIs(t, err, ErrInvalidCorrection)
+
+	first, err := SubmitTranscriptCorrection(db, recording, 1, "我想咨询灵溪的价格", 7)
+	require.NoError(t, err)
+	// Submitting again replaces the correction of the turn
+	correction, err := SubmitTranscriptCorrection(db, recording, 1, "我想咨询灵犀的价格", 7)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, correction.ID)
+	assert.Equal(t, "我想咨询灵犀的价格", correction.Corrected)
+	assert.Equal(t, CorrectionPending, correction.Status)
+	other, err := SubmitTranscriptCorrection(db, recording, 3, "帮我查一下定单", 7)
+	require.NoError(t, err)
+
+	// Pending corrections are not applied yet
+	details, err := CorrectedConversationDetails(db, recording)
+	require.NoError(t, err)
+	assert.False(t, details.Turns[0].Corrected)
+
+	now := time.Now()
+	assert.ErrorIs(t, ReviewTranscriptCorrection(db, correction, "maybe", 1, now), ErrInvalidReviewStatus)
+	require.NoError(t, ReviewTranscriptCorrection(db, correction, CorrectionAccepted, 1, now))
+	assert.Equal(t, "灵犀", correction.Terms)
+	assert.ErrorIs(t, ReviewTranscriptCorrection(db, correction, CorrectionRejected, 1, now), ErrCorrectionReviewed)
+	require.NoError(t, ReviewTranscriptCorrection(db, other, CorrectionRejected, 1, now))
+
+	details, err = CorrectedConversationDetails(db, recording)
+	require.NoError(t, err)
+	assert.True(t, details.Turns[0].Corrected)
+	assert.Equal(t, "我想咨询灵犀的价格", details.Turns[0].Content)
+	assert.Equal(t, "我想咨询零西的价格", details.Turns[0].OriginalContent)
+	assert.False(t, details.Turns[2].Corrected, "rejected corrections are not applied")
+
+	stored, err := recording.GetConversationDetails()
+	require.NoError(t, err)
+	assert.Equal(t, "我想咨询零西的价格", stored.Turns[0].Content, "the original transcript is preserved")
+
+	corrections, err := ListTranscriptCorrections(db, recording.ID)
+	require.NoError(t, err)
+	assert.Len(t, corrections, 2)
+
+	// Another accepted correction with the same term counts a hit
+	second := &CallRecording{UserID: 2, AssistantID: 5}
+	require.NoError(t, second.SetConversationDetails(&ConversationDetails{Turns: []ConversationTurn{{TurnID: 1, Type: "user", Content: "零西在哪"}}}))
+	require.NoError(t, db.Create(second).Error)
+	c2, err := SubmitTranscriptCorrection(db, second, 1, "灵犀在哪", 7)
+	require.NoError(t, err)
+	require.NoError(t, ReviewTranscriptCorrection(db, c2, CorrectionAccepted, 1, now))
+
+	_, err = SaveManualVocabulary(db, 5, "声网", 6)
+	require.NoError(t, err)
+	_, err = SaveManualVocabulary(db, 5, "x", 6)
+	assert.ErrorIs(t, err, ErrInvalidVocabulary)
+	words, err := ListAssistantVocabulary(db, 5, MaxAssistantVocabulary)
+	require.NoError(t, err)
+	require.Len(t, words, 2)
+	assert.Equal(t, "灵犀", words[0].Word)
+	assert.Equal(t, 2, words[0].Hits)
+	assert.Equal(t, VocabularySourceManual, words[1].Source)
+
+	require.NoError(t, DeleteAssistantVocabulary(db, 5, words[1].ID))
+	assert.Error(t, DeleteAssistantVocabulary(db, 6, words[0].ID), "words of other assistants are not deleted")
+}
+
+func TestMaskPII_CorrectedTurn(t *testing.T) {
+	details := &ConversationDetails{Turns: []ConversationTurn{{TurnID: 1, Type: "user", Content: "电话13812345678"}}}
+	details.DetectPII(time.Now())
+	details.Turns[0].OriginalContent = details.Turns[0].Content
+	details.Turns[0].Content = "我的电话是13987654321"
+	details.Turns[0].Corrected = true
+	details.MaskPII(nil)
+	assert.Equal(t, "我的电话是***********", details.Turns[0].Content)
+	assert.Equal(t, "电话***********", details.Turns[0].OriginalContent)
+}
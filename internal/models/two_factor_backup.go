@@ -0,0 +1,112 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TwoFactorBackupCodeCount 每次生成的备用码数量
+	TwoFactorBackupCodeCount = 10
+	backupCodeLength         = 10
+	// 去掉容易混淆的 0/o、1/l/i
+	backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// TwoFactorBackupCode 两步验证的一次性备用码，只保存哈希，用于丢失验证器时登录
+type TwoFactorBackupCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UserID    uint       `json:"userId" gorm:"index:idx_backup_code_user_hash,priority:1;not null"`
+	CodeHash  string     `json:"-" gorm:"size:64;index:idx_backup_code_user_hash,priority:2;not null"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
+
+// TableName 指定表名
+func (TwoFactorBackupCode) TableName() string {
+	return "two_factor_backup_codes"
+}
+
+// normalizeBackupCode 忽略大小写、空格和连字符
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashBackupCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeBackupCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+func newBackupCode() (string, error) {
+	// 丢弃超出字母表整数倍的字节，避免取模偏差
+	limit := byte(256 - 256%len(backupCodeAlphabet))
+	code := make([]byte, 0, backupCodeLength)
+	buf := make([]byte, backupCodeLength*2)
+	for len(code) < backupCodeLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if b < limit && len(code) < backupCodeLength {
+				code = append(code, backupCodeAlphabet[int(b)%len(backupCodeAlphabet)])
+			}
+		}
+	}
+	return string(code[:backupCodeLength/2]) + "-" + string(code[backupCodeLength/2:]), nil
+}
+
+// GenerateTwoFactorBackupCodes 为用户生成一组新的备用码，之前的备用码全部作废。明文只在此时返回一次
+func GenerateTwoFactorBackupCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, TwoFactorBackupCodeCount)
+	rows := make([]TwoFactorBackupCode, 0, TwoFactorBackupCodeCount)
+	for len(codes) < TwoFactorBackupCodeCount {
+		code, err := newBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, TwoFactorBackupCode{UserID: userID, CodeHash: hashBackupCode(code)})
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&TwoFactorBackupCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseTwoFactorBackupCode 核销一个未使用的备用码，返回是否核销成功
+func UseTwoFactorBackupCode(db *gorm.DB, userID uint, code string, now time.Time) (bool, error) {
+	if normalizeBackupCode(code) == "" {
+		return false, nil
+	}
+	res := db.Model(&TwoFactorBackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashBackupCode(code)).
+		Update("used_at", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// CountTwoFactorBackupCodes 返回用户剩余可用的备用码数量
+func CountTwoFactorBackupCodes(db *gorm.DB, userID uint) (int64, error) {
+	var n int64
+	err := db.Model(&TwoFactorBackupCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+// DeleteTwoFactorBackupCodes 关闭两步验证时删除全部备用码
+func DeleteTwoFactorBackupCodes(db *gorm.DB, userID uint) error {
+	return db.Where("user_id = ?", userID).Delete(&TwoFactorBackupCode{}).Error
+}
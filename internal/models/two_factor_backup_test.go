@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorBackupCodes(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &TwoFactorBackupCode{})
+	now := time.Now()
+
+	codes, err := GenerateTwoFactorBackupCodes(db, 1)
+	require.NoError(t, err)
+	require.Len(t, codes, TwoFactorBackupCodeCount)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Len(t, code, backupCodeLength+1)
+		assert.Equal(t, byte('-'), code[backupCodeLength/2])
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+	var stored TwoFactorBackupCode
+	require.NoError(t, db.First(&stored).Error)
+	assert.NotContains(t, stored.CodeHash, normalizeBackupCode(codes[0]), "codes are stored hashed")
+
+	// Case, spaces and the dash don't matter, but each code works once
+	ok, err := UseTwoFactorBackupCode(db, 1, " "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))+" ", now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = UseTwoFactorBackupCode(db, 1, codes[0], now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Codes belong to their user
+	ok, err = UseTwoFactorBackupCode(db, 2, codes[1], now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = UseTwoFactorBackupCode(db, 1, "", now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	remaining, err := CountTwoFactorBackupCodes(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(TwoFactorBackupCodeCount-1), remaining)
+
+	// Regenerating invalidates the old set
+	fresh, err := GenerateTwoFactorBackupCodes(db, 1)
+	require.NoError(t, err)
+	ok, err = UseTwoFactorBackupCode(db, 1, codes[1], now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = UseTwoFactorBackupCode(db, 1, fresh[0], now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, DeleteTwoFactorBackupCodes(db, 1))
+	remaining, err = CountTwoFactorBackupCodes(db, 1)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDuplicateKey a unique constraint other than the conflict columns rejected the row
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// IsDuplicateKey reports whether err is a unique constraint violation of MySQL,
+// PostgreSQL or SQLite
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, ErrDuplicateKey) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Duplicate entry") || // MySQL 1062
+		strings.Contains(msg, "duplicate key value") || // PostgreSQL 23505
+		strings.Contains(msg, "UNIQUE constraint failed") // SQLite
+}
+
+// CreateOrGet inserts value unless a row with the same conflict columns exists,
+// in which case value is loaded with that row. The conflict columns (field or
+// column names) must be covered by a unique index, so concurrent callers never
+// see a duplicate-key error: exactly one of them creates the row.
+func CreateOrGet[T any](db *gorm.DB, value *T, conflict ...string) (created bool, err error) {
+	columns, where, err := conflictColumns(db, value, conflict)
+	if err != nil {
+		return false, err
+	}
+	result := db.Clauses(clause.OnConflict{Columns: columns, DoNothing: true}).Create(value)
+	if result.Error != nil {
+		if IsDuplicateKey(result.Error) {
+			return false, fmt.Errorf("%w: %v", ErrDuplicateKey, result.Error)
+		}
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+	var existing T
+	if err := db.Where(where).Take(&existing).Error; err != nil {
+		return false, err
+	}
+	*value = existing
+	return false, nil
+}
+
+// Upsert inserts value or, when a row with the same conflict columns exists,
+// updates the given columns of that row. value is reloaded afterwards so it
+// carries the stored row including its primary key.
+func Upsert[T any](db *gorm.DB, value *T, conflict []string, update ...string) error {
+	columns, where, err := conflictColumns(db, value, conflict)
+	if err != nil {
+		return err
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return err
+	}
+	assign := make([]string, 0, len(update))
+	for _, name := range update {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil {
+			return fmt.Errorf("upsert: unknown column %q", name)
+		}
+		assign = append(assign, field.DBName)
+	}
+	onConflict := clause.OnConflict{Columns: columns, DoUpdates: clause.AssignmentColumns(assign)}
+	if len(assign) == 0 {
+		onConflict = clause.OnConflict{Columns: columns, DoNothing: true}
+	}
+	if err := db.Clauses(onConflict).Create(value).Error; err != nil {
+		if IsDuplicateKey(err) {
+			return fmt.Errorf("%w: %v", ErrDuplicateKey, err)
+		}
+		return err
+	}
+	var stored T
+	if err := db.Where(where).Take(&stored).Error; err != nil {
+		return err
+	}
+	*value = stored
+	return nil
+}
+
+// conflictColumns resolves the conflict columns of value and the condition
+// selecting the row holding the same values
+func conflictColumns(db *gorm.DB, value any, names []string) ([]clause.Column, clause.AndConditions, error) {
+	if len(names) == 0 {
+		return nil, clause.AndConditions{}, errors.New("upsert: conflict columns are required")
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return nil, clause.AndConditions{}, err
+	}
+	rv := reflect.ValueOf(value)
+	columns := make([]clause.Column, 0, len(names))
+	var where clause.AndConditions
+	for _, name := range names {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil {
+			return nil, clause.AndConditions{}, fmt.Errorf("upsert: unknown column %q", name)
+		}
+		v, _ := field.ValueOf(context.Background(), rv.Elem())
+		column := clause.Column{Table: stmt.Schema.Table, Name: field.DBName}
+		columns = append(columns, clause.Column{Name: field.DBName})
+		where.Exprs = append(where.Exprs, clause.Eq{Column: column, Value: v})
+	}
+	return columns, where, nil
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateOrGet(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{})
+
+	device := &Device{ID: "aa:bb:cc:00:00:01", MacAddress: "aa:bb:cc:00:00:01", UserID: 1, DeviceName: "first"}
+	created, err := CreateOrGet(db, device, "MacAddress")
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	again := &Device{ID: "aa:bb:cc:00:00:01", MacAddress: "aa:bb:cc:00:00:01", UserID: 2, DeviceName: "second"}
+	created, err = CreateOrGet(db, again, "MacAddress")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "first", again.DeviceName, "the stored row is loaded")
+	assert.Equal(t, uint(1), again.UserID)
+
+	// Another unique constraint is reported as a duplicate key
+	other := &Device{ID: "aa:bb:cc:00:00:01", MacAddress: "aa:bb:cc:00:00:02", UserID: 3}
+	_, err = CreateOrGet(db, other, "MacAddress")
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.True(t, IsDuplicateKey(err))
+
+	_, err = CreateOrGet(db, &Device{}, "NoSuchField")
+	assert.Error(t, err)
+}
+
+func TestCreateDevice_Concurrent(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &Device{})
+	// A shared in-memory SQLite database needs a single connection
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	var wg sync.WaitGroup
+	results := make([]error, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = CreateDevice(db, &Device{ID: "aa:bb:cc:00:00:09", MacAddress: "aa:bb:cc:00:00:09", UserID: uint(i + 1)})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range results {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.ErrorIs(t, err, ErrDeviceExists)
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestUpsert_AccountLock(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &AccountLock{})
+
+	first, err := CreateOrUpdateAccountLock(db, "lock@example.com", 1, "10.0.0.1", 5)
+	require.NoError(t, err)
+	require.NotZero(t, first.ID)
+
+	second, err := CreateOrUpdateAccountLock(db, "lock@example.com", 1, "10.0.0.2", 6)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, 6, second.FailedAttempts)
+	assert.Equal(t, "10.0.0.2", second.IPAddress)
+
+	// After unlocking, the next lock is a new record and the old one is kept
+	require.NoError(t, UnlockAccount(db, "lock@example.com", 0))
+	third, err := CreateOrUpdateAccountLock(db, "lock@example.com", 1, "10.0.0.3", 5)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, third.ID)
+
+	var count int64
+	db.Model(&AccountLock{}).Where("email = ?", "lock@example.com").Count(&count)
+	assert.Equal(t, int64(2), count)
+}
@@ -1,9 +1,12 @@
 package models
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"gorm.io/gorm"
 )
 
@@ -43,6 +46,17 @@ type LoginHistory struct {
 	Success       bool   `gorm:"index" json:"success"`
 	FailureReason string `gorm:"size:256" json:"failureReason"`
 	IsSuspicious  bool   `gorm:"default:false;index" json:"isSuspicious"`
+
+	// 登录异常评分，未评分的登录为零值
+	AnomalyScore        float64  `gorm:"default:0" json:"anomalyScore"`                      // 参与判定的评分
+	AnomalyRuleScore    float64  `gorm:"default:0" json:"anomalyRuleScore"`                  // 规则评分
+	AnomalyModelScore   *float64 `json:"anomalyModelScore,omitempty"`                        // 模型评分，未调用或失败时为空
+	AnomalyModelVersion string   `gorm:"size:64;index" json:"anomalyModelVersion,omitempty"` // 模型版本
+	AnomalyMode         string   `gorm:"size:16" json:"anomalyMode,omitempty"`               // off / shadow / enforce
+	RiskDecision        string   `gorm:"size:16;index" json:"riskDecision,omitempty"`        // allow / challenge / block
+	RiskReasons         string   `gorm:"size:256" json:"riskReasons,omitempty"`              // 命中的风险规则，逗号分隔
+	Latitude            float64  `gorm:"default:0" json:"latitude,omitempty"`                // 登录位置坐标，用于判断不可能的移动
+	Longitude           float64  `gorm:"default:0" json:"longitude,omitempty"`
 }
 
 func (LoginHistory) TableName() string {
@@ -60,8 +74,34 @@ type AccountLock struct {
 	Reason         string    `gorm:"size:256" json:"reason"`               // 锁定原因
 	FailedAttempts int       `gorm:"default:0" json:"failedAttempts"`      // 失败次数
 	IsActive       bool      `gorm:"default:true;index" json:"isActive"`   // 是否激活
+	ActiveEmail    *string   `gorm:"size:128;uniqueIndex" json:"-"`        // 激活时等于邮箱、解锁后为空，保证每个邮箱只有一条生效的锁定
+
+	UnlockToken        string     `gorm:"size:64;index" json:"-"`                // 自助解锁邮件中的令牌
+	UnlockTokenExpires *time.Time `json:"-"`                                     // 解锁令牌过期时间
+	UnlockedAt         *time.Time `json:"unlockedAt,omitempty"`                  // 实际解锁时间
+	UnlockMethod       string     `gorm:"size:16" json:"unlockMethod,omitempty"` // 解锁方式：self / admin / expired
+	UnlockedBy         uint       `gorm:"default:0" json:"unlockedBy,omitempty"` // 手动解除锁定的管理员
 }
 
+// 账号解锁方式
+const (
+	AccountUnlockSelf    = "self"    // 用户通过邮件自助解锁
+	AccountUnlockAdmin   = "admin"   // 管理员解除
+	AccountUnlockExpired = "expired" // 锁定到期自动解除
+)
+
+const (
+	accountUnlockTokenTTL      = time.Hour   // 自助解锁链接的有效期
+	accountUnlockEmailInterval = time.Minute // 同一锁定重复发送解锁邮件的最小间隔
+)
+
+var (
+	// ErrAccountUnlockTooFrequent 解锁邮件发送过于频繁
+	ErrAccountUnlockTooFrequent = errors.New("解锁邮件刚刚发送过，请稍后再试")
+	// ErrInvalidAccountUnlockToken 解锁令牌无效、过期或锁定已解除
+	ErrInvalidAccountUnlockToken = errors.New("无效或过期的解锁令牌")
+)
+
 func (AccountLock) TableName() string {
 	return constants.ACCOUNT_LOCK_TABLE_NAME
 }
@@ -74,36 +114,23 @@ func (al *AccountLock) IsLocked() bool {
 	return time.Now().Before(al.UnlockAt)
 }
 
-// CreateOrUpdateAccountLock 创建或更新账号锁定记录
+// CreateOrUpdateAccountLock 创建或更新账号锁定记录，按邮箱原子地写入，并发的登录失败不会产生重复的锁定
 func CreateOrUpdateAccountLock(db *gorm.DB, email string, userID uint, ipAddress string, failedAttempts int) (*AccountLock, error) {
-	var lock AccountLock
-	query := db.Where("email = ? AND is_active = ?", email, true)
-	if userID > 0 {
-		query = query.Or("user_id = ? AND is_active = ?", userID, true)
-	}
-	err := query.First(&lock).Error
 	lockTime := 30 * time.Minute // 锁定30分钟
-	if err == gorm.ErrRecordNotFound {
-		lock = AccountLock{
-			Email:          email,
-			UserID:         userID,
-			IPAddress:      ipAddress,
-			LockedAt:       time.Now(),
-			UnlockAt:       time.Now().Add(lockTime),
-			FailedAttempts: failedAttempts,
-			Reason:         "Too many failed login attempts",
-			IsActive:       true,
-		}
-		err = db.Create(&lock).Error
-	} else if err == nil {
-		// 更新现有锁定记录
-		lock.FailedAttempts = failedAttempts
-		lock.UnlockAt = time.Now().Add(lockTime)
-		lock.IPAddress = ipAddress
-		lock.UpdatedAt = time.Now()
-		err = db.Save(&lock).Error
+	now := time.Now()
+	lock := AccountLock{
+		Email:          email,
+		UserID:         userID,
+		IPAddress:      ipAddress,
+		LockedAt:       now,
+		UnlockAt:       now.Add(lockTime),
+		FailedAttempts: failedAttempts,
+		Reason:         "Too many failed login attempts",
+		IsActive:       true,
+		ActiveEmail:    &email,
 	}
-
+	// 已有生效的锁定时只延长锁定时间并更新失败次数与 IP
+	err := Upsert(db, &lock, []string{"ActiveEmail"}, "FailedAttempts", "UnlockAt", "IPAddress", "UpdatedAt")
 	return &lock, err
 }
 
@@ -137,11 +164,118 @@ func UnlockAccount(db *gorm.DB, email string, userID uint) error {
 		query = query.Where("user_id = ?", userID)
 	}
 
-	return query.Update("is_active", false).Error
+	return query.Updates(map[string]interface{}{"is_active": false, "active_email": nil}).Error
+}
+
+// ListActiveAccountLocks 分页列出当前仍在锁定期内的账号，email 不为空时按邮箱模糊筛选
+func ListActiveAccountLocks(db *gorm.DB, email string, now time.Time, page, size int) ([]AccountLock, int64, error) {
+	q := db.Model(&AccountLock{}).Where("is_active = ? AND unlock_at > ?", true, now)
+	if email != "" {
+		q = q.Where("email LIKE ?", "%"+email+"%")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var locks []AccountLock
+	err := q.Order("locked_at DESC").Offset((page - 1) * size).Limit(size).Find(&locks).Error
+	return locks, total, err
+}
+
+// IssueAccountUnlockToken 为生效中的锁定生成自助解锁令牌，间隔过短时返回 ErrAccountUnlockTooFrequent
+func IssueAccountUnlockToken(db *gorm.DB, lock *AccountLock, now time.Time) (string, error) {
+	if lock.UnlockTokenExpires != nil && lock.UnlockTokenExpires.After(now.Add(accountUnlockTokenTTL-accountUnlockEmailInterval)) {
+		return "", ErrAccountUnlockTooFrequent
+	}
+	token := utils.RandString(32)
+	expires := now.Add(accountUnlockTokenTTL)
+	err := db.Model(&AccountLock{}).Where("id = ? AND is_active = ?", lock.ID, true).
+		Updates(map[string]interface{}{"unlock_token": token, "unlock_token_expires": &expires}).Error
+	if err != nil {
+		return "", err
+	}
+	lock.UnlockToken = token
+	lock.UnlockTokenExpires = &expires
+	return token, nil
+}
+
+// UnlockAccountByToken 使用解锁邮件中的令牌解除锁定，令牌只能使用一次
+func UnlockAccountByToken(db *gorm.DB, token string, now time.Time) (*AccountLock, error) {
+	if token == "" {
+		return nil, ErrInvalidAccountUnlockToken
+	}
+	var lock AccountLock
+	err := db.Where("unlock_token = ? AND unlock_token_expires > ? AND is_active = ?", token, now, true).First(&lock).Error
+	if err != nil {
+		return nil, ErrInvalidAccountUnlockToken
+	}
+	released, err := ReleaseAccountLock(db, &lock, AccountUnlockSelf, 0, now)
+	if err != nil {
+		return nil, err
+	}
+	if !released {
+		return nil, ErrInvalidAccountUnlockToken
+	}
+	return &lock, nil
+}
+
+// ReleaseAccountLock 解除一条锁定并记录解锁方式，by 为操作的管理员。
+// 锁定已被其他请求解除时返回 false，调用方据此避免重复通知
+func ReleaseAccountLock(db *gorm.DB, lock *AccountLock, method string, by uint, now time.Time) (bool, error) {
+	q := db.Model(&AccountLock{}).Where("id = ? AND is_active = ?", lock.ID, true)
+	if method == AccountUnlockExpired {
+		// 期间又有登录失败延长了锁定时不能自动解除
+		q = q.Where("unlock_at <= ?", now)
+	}
+	result := q.Updates(map[string]interface{}{
+		"is_active":            false,
+		"active_email":         nil,
+		"unlock_token":         "",
+		"unlock_token_expires": nil,
+		"unlocked_at":          now,
+		"unlock_method":        method,
+		"unlocked_by":          by,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	lock.IsActive = false
+	lock.ActiveEmail = nil
+	lock.UnlockToken = ""
+	lock.UnlockTokenExpires = nil
+	lock.UnlockedAt = &now
+	lock.UnlockMethod = method
+	lock.UnlockedBy = by
+	return true, nil
+}
+
+// ReleaseExpiredAccountLocks 解除已到期的锁定，返回本次解除的记录（最多 limit 条）用于发送解锁通知
+func ReleaseExpiredAccountLocks(db *gorm.DB, now time.Time, limit int) ([]AccountLock, error) {
+	var locks []AccountLock
+	err := db.Where("is_active = ? AND unlock_at <= ?", true, now).Order("unlock_at").Limit(limit).Find(&locks).Error
+	if err != nil {
+		return nil, err
+	}
+	released := locks[:0]
+	for i := range locks {
+		ok, err := ReleaseAccountLock(db, &locks[i], AccountUnlockExpired, 0, now)
+		if err != nil {
+			return released, err
+		}
+		if ok {
+			released = append(released, locks[i])
+		}
+	}
+	return released, nil
 }
 
 // RecordLoginHistory 记录登录历史
 func RecordLoginHistory(db *gorm.DB, userID uint, email, ipAddress, location, country, city, userAgent, deviceID, loginType string, success bool, failureReason string, isSuspicious bool) error {
+	return RecordLoginHistoryWithAnomaly(db, userID, email, ipAddress, location, country, city, userAgent, deviceID, loginType, success, failureReason, isSuspicious, nil)
+}
+
+// RecordLoginHistoryWithAnomaly 记录登录历史及其异常评分，anomaly 为空时与 RecordLoginHistory 相同
+func RecordLoginHistoryWithAnomaly(db *gorm.DB, userID uint, email, ipAddress, location, country, city, userAgent, deviceID, loginType string, success bool, failureReason string, isSuspicious bool, anomaly *utils.LoginAnomalyResult) error {
 	history := LoginHistory{
 		UserID:        userID,
 		Email:         email,
@@ -156,10 +290,98 @@ func RecordLoginHistory(db *gorm.DB, userID uint, email, ipAddress, location, co
 		FailureReason: failureReason,
 		IsSuspicious:  isSuspicious,
 	}
+	if anomaly != nil {
+		history.AnomalyScore = anomaly.Score
+		history.AnomalyRuleScore = anomaly.RuleScore
+		history.AnomalyModelScore = anomaly.ModelScore
+		history.AnomalyModelVersion = anomaly.ModelVersion
+		history.AnomalyMode = anomaly.Mode
+		history.RiskDecision = anomaly.Decision
+		history.RiskReasons = strings.Join(anomaly.Reasons, ",")
+		history.Latitude, history.Longitude = anomaly.Latitude, anomaly.Longitude
+		history.IsSuspicious = isSuspicious || anomaly.Suspicious
+	}
 
 	return db.Create(&history).Error
 }
 
+// loginAnomalyHistoryWindow 计算登录特征时回看的时长和最多条数
+const (
+	loginAnomalyHistoryWindow = 30 * 24 * time.Hour
+	loginAnomalyHistoryLimit  = 200
+)
+
+// RecentLoginAttempts 用户最近 30 天的登录尝试，用于计算登录异常特征
+func RecentLoginAttempts(db *gorm.DB, userID uint, now time.Time) ([]utils.LoginAttempt, error) {
+	var histories []LoginHistory
+	err := db.Select("created_at", "ip_address", "device_id", "country", "city", "success", "latitude", "longitude").
+		Where("user_id = ? AND created_at > ?", userID, now.Add(-loginAnomalyHistoryWindow)).
+		Order("created_at DESC").
+		Limit(loginAnomalyHistoryLimit).
+		Find(&histories).Error
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]utils.LoginAttempt, len(histories))
+	for i, h := range histories {
+		attempts[i] = utils.LoginAttempt{
+			At:       h.CreatedAt,
+			IP:       h.IPAddress,
+			DeviceID: h.DeviceID,
+			Country:  h.Country,
+			City:     h.City,
+			Success:  h.Success,
+
+			Latitude:  h.Latitude,
+			Longitude: h.Longitude,
+		}
+	}
+	return attempts, nil
+}
+
+// LoginAnomalyModelStats 影子模式评估：某个模型版本与规则判定的对比
+type LoginAnomalyModelStats struct {
+	ModelVersion  string  `json:"modelVersion"`
+	Scored        int64   `json:"scored"`        // 模型评分的登录数
+	ModelFlagged  int64   `json:"modelFlagged"`  // 模型判定可疑
+	RulesFlagged  int64   `json:"rulesFlagged"`  // 规则判定可疑
+	BothFlagged   int64   `json:"bothFlagged"`   // 两者都判定可疑
+	Agreement     float64 `json:"agreement"`     // 判定一致的比例
+	AvgModelScore float64 `json:"avgModelScore"` // 模型平均评分
+	AvgRuleScore  float64 `json:"avgRuleScore"`  // 规则平均评分
+}
+
+// LoginAnomalyShadowReport 按模型版本对比 since 之后模型和规则在 threshold 下的判定，
+// fallbacks 为调用了模型却回退到规则的登录数
+func LoginAnomalyShadowReport(db *gorm.DB, since time.Time, threshold float64) (stats []LoginAnomalyModelStats, fallbacks int64, err error) {
+	err = db.Model(&LoginHistory{}).
+		Select(`anomaly_model_version AS model_version, COUNT(*) AS scored,
+			SUM(CASE WHEN anomaly_model_score >= ? THEN 1 ELSE 0 END) AS model_flagged,
+			SUM(CASE WHEN anomaly_rule_score >= ? THEN 1 ELSE 0 END) AS rules_flagged,
+			SUM(CASE WHEN anomaly_model_score >= ? AND anomaly_rule_score >= ? THEN 1 ELSE 0 END) AS both_flagged,
+			AVG(anomaly_model_score) AS avg_model_score,
+			AVG(anomaly_rule_score) AS avg_rule_score`, threshold, threshold, threshold, threshold).
+		Where("anomaly_model_score IS NOT NULL AND created_at >= ?", since).
+		Group("anomaly_model_version").
+		Order("anomaly_model_version").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range stats {
+		s := &stats[i]
+		if s.Scored > 0 {
+			agreed := s.Scored - s.ModelFlagged - s.RulesFlagged + 2*s.BothFlagged
+			s.Agreement = float64(agreed) / float64(s.Scored)
+		}
+	}
+	err = db.Model(&LoginHistory{}).
+		Where("anomaly_mode IN ? AND anomaly_model_score IS NULL AND created_at >= ?",
+			[]string{utils.LoginAnomalyModeShadow, utils.LoginAnomalyModeEnforce}, since).
+		Count(&fallbacks).Error
+	return stats, fallbacks, err
+}
+
 // GetRecentLoginLocations 获取最近的登录位置（用于异地登录检测）
 func GetRecentLoginLocations(db *gorm.DB, userID uint, limit int) ([]LoginHistory, error) {
 	var histories []LoginHistory
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
@@ -410,6 +411,101 @@ func TestAccountLock_ByEmailOnly(t *testing.T) {
 	assert.Nil(t, unlockedAccount)
 }
 
+func TestAccountLock_SelfServiceUnlock(t *testing.T) {
+	db := setupUserDevicesTestDB(t)
+	user := createTestUserForDevices(t, db)
+	now := time.Now()
+
+	lock, err := CreateOrUpdateAccountLock(db, user.Email, user.ID, "192.168.1.100", 7)
+	require.NoError(t, err)
+
+	token, err := IssueAccountUnlockToken(db, lock, now)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	// 一分钟内不能重复发送解锁邮件
+	_, err = IssueAccountUnlockToken(db, lock, now.Add(30*time.Second))
+	assert.ErrorIs(t, err, ErrAccountUnlockTooFrequent)
+
+	// 过期的令牌无效
+	_, err = UnlockAccountByToken(db, token, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidAccountUnlockToken)
+
+	unlocked, err := UnlockAccountByToken(db, token, now)
+	require.NoError(t, err)
+	assert.Equal(t, lock.ID, unlocked.ID)
+	assert.Equal(t, AccountUnlockSelf, unlocked.UnlockMethod)
+	assert.NotNil(t, unlocked.UnlockedAt)
+
+	active, err := GetAccountLock(db, user.Email, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, active)
+
+	// 令牌只能使用一次
+	_, err = UnlockAccountByToken(db, token, now)
+	assert.ErrorIs(t, err, ErrInvalidAccountUnlockToken)
+}
+
+func TestAccountLock_AdminListAndRelease(t *testing.T) {
+	db := setupUserDevicesTestDB(t)
+	now := time.Now()
+
+	_, err := CreateOrUpdateAccountLock(db, "alice@example.com", 1, "10.0.0.1", 7)
+	require.NoError(t, err)
+	bob, err := CreateOrUpdateAccountLock(db, "bob@example.com", 2, "10.0.0.2", 7)
+	require.NoError(t, err)
+
+	locks, total, err := ListActiveAccountLocks(db, "", now, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, locks, 2)
+
+	locks, total, err = ListActiveAccountLocks(db, "bob", now, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, bob.ID, locks[0].ID)
+
+	released, err := ReleaseAccountLock(db, bob, AccountUnlockAdmin, 99, now)
+	require.NoError(t, err)
+	assert.True(t, released)
+	assert.Equal(t, uint(99), bob.UnlockedBy)
+
+	// 重复解除不会再次生效
+	released, err = ReleaseAccountLock(db, bob, AccountUnlockAdmin, 99, now)
+	require.NoError(t, err)
+	assert.False(t, released)
+
+	_, total, err = ListActiveAccountLocks(db, "", now, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestReleaseExpiredAccountLocks(t *testing.T) {
+	db := setupUserDevicesTestDB(t)
+	now := time.Now()
+
+	expired, err := CreateOrUpdateAccountLock(db, "expired@example.com", 1, "10.0.0.1", 7)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(expired).Update("unlock_at", now.Add(-time.Minute)).Error)
+	_, err = CreateOrUpdateAccountLock(db, "locked@example.com", 2, "10.0.0.2", 7)
+	require.NoError(t, err)
+
+	released, err := ReleaseExpiredAccountLocks(db, now, 10)
+	require.NoError(t, err)
+	require.Len(t, released, 1)
+	assert.Equal(t, expired.ID, released[0].ID)
+	assert.Equal(t, AccountUnlockExpired, released[0].UnlockMethod)
+
+	// 已解除的锁定不会重复通知
+	released, err = ReleaseExpiredAccountLocks(db, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, released)
+
+	active, err := GetAccountLock(db, "locked@example.com", 0)
+	require.NoError(t, err)
+	assert.NotNil(t, active)
+}
+
 func TestUserDevice_DeviceTypes(t *testing.T) {
 	db := setupUserDevicesTestDB(t)
 	user := createTestUserForDevices(t, db)
@@ -463,6 +559,72 @@ func TestLoginHistory_SuspiciousActivity(t *testing.T) {
 	assert.Equal(t, "美国", suspiciousLogins[0].Country)
 }
 
+func TestLoginHistory_Anomaly(t *testing.T) {
+	db := setupUserDevicesTestDB(t)
+	user := createTestUserForDevices(t, db)
+	modelScore := func(v float64) *float64 { return &v }
+
+	// 影子模式：模型判定可疑，规则未判定
+	require.NoError(t, RecordLoginHistoryWithAnomaly(db, user.ID, user.Email, "203.0.113.10", "纽约", "美国", "纽约", "Mozilla/5.0", "device-002", "password", true, "", false,
+		&utils.LoginAnomalyResult{Score: 0.2, RuleScore: 0.2, ModelScore: modelScore(0.9), ModelVersion: "v1", Mode: utils.LoginAnomalyModeShadow}))
+	// 两者都判定可疑
+	require.NoError(t, RecordLoginHistoryWithAnomaly(db, user.ID, user.Email, "203.0.113.11", "纽约", "美国", "纽约", "Mozilla/5.0", "device-003", "password", true, "", false,
+		&utils.LoginAnomalyResult{Score: 0.8, RuleScore: 0.8, ModelScore: modelScore(0.8), ModelVersion: "v1", Mode: utils.LoginAnomalyModeShadow, Suspicious: true}))
+	// 模型超时回退
+	require.NoError(t, RecordLoginHistoryWithAnomaly(db, user.ID, user.Email, "192.168.1.100", "北京市", "中国", "北京", "Mozilla/5.0", "device-001", "password", true, "", false,
+		&utils.LoginAnomalyResult{Score: 0.1, RuleScore: 0.1, Mode: utils.LoginAnomalyModeShadow, Fallback: "timeout"}))
+	require.NoError(t, RecordLoginHistory(db, user.ID, user.Email, "192.168.1.100", "北京市", "中国", "北京", "Mozilla/5.0", "device-001", "password", false, "密码错误", false))
+
+	var flagged int64
+	db.Model(&LoginHistory{}).Where("is_suspicious = ?", true).Count(&flagged)
+	assert.EqualValues(t, 1, flagged, "only the decisive score marks the login suspicious")
+
+	attempts, err := RecentLoginAttempts(db, user.ID, time.Now())
+	require.NoError(t, err)
+	require.Len(t, attempts, 4)
+	assert.False(t, attempts[0].Success, "newest attempt first")
+	assert.Equal(t, "device-001", attempts[0].DeviceID)
+
+	stats, fallbacks, err := LoginAnomalyShadowReport(db, time.Now().Add(-time.Hour), 0.7)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, fallbacks)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "v1", stats[0].ModelVersion)
+	assert.EqualValues(t, 2, stats[0].Scored)
+	assert.EqualValues(t, 2, stats[0].ModelFlagged)
+	assert.EqualValues(t, 1, stats[0].RulesFlagged)
+	assert.EqualValues(t, 1, stats[0].BothFlagged)
+	assert.InDelta(t, 0.5, stats[0].Agreement, 1e-9)
+	assert.InDelta(t, 0.85, stats[0].AvgModelScore, 1e-9)
+}
+
+func TestLoginHistory_RiskDecision(t *testing.T) {
+	db := setupUserDevicesTestDB(t)
+	user := createTestUserForDevices(t, db)
+
+	require.NoError(t, RecordLoginHistoryWithAnomaly(db, user.ID, user.Email, "203.0.113.10", "纽约", "美国", "纽约", "Mozilla/5.0", "device-002", "password", false, "login blocked", false,
+		&utils.LoginAnomalyResult{
+			Score:     0.95,
+			RuleScore: 0.95,
+			Mode:      utils.LoginAnomalyModeOff,
+			Reasons:   []string{utils.LoginRiskRuleImpossibleTravel, utils.LoginRiskRuleProxy},
+			Decision:  utils.LoginRiskBlock,
+			Latitude:  40.7,
+			Longitude: -74.0,
+		}))
+
+	var history LoginHistory
+	require.NoError(t, db.Where("user_id = ?", user.ID).First(&history).Error)
+	assert.Equal(t, utils.LoginRiskBlock, history.RiskDecision)
+	assert.Equal(t, "impossible_travel,proxy", history.RiskReasons)
+
+	attempts, err := RecentLoginAttempts(db, user.ID, time.Now())
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, 40.7, attempts[0].Latitude)
+	assert.Equal(t, -74.0, attempts[0].Longitude)
+}
+
 // Benchmark tests
 func BenchmarkCreateOrUpdateUserDevice(b *testing.B) {
 	db := setupUserDevicesTestDB(&testing.T{})
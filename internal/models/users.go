@@ -33,6 +33,7 @@ const (
 
 type SendEmailVerifyEmail struct {
 	Email     string `json:"email"`
+	Purpose   string `json:"purpose"` // login（默认）、register、change_password
 	ClientIp  string `json:"clientIp"`
 	UserAgent string `json:"userAgent"`
 }
@@ -129,6 +130,7 @@ type User struct {
 	Locale                string     `json:"locale,omitempty" gorm:"size:20"`
 	Timezone              string     `json:"timezone,omitempty" gorm:"size:200"`
 	AuthToken             string     `json:"token,omitempty" gorm:"-"`
+	RefreshToken          string     `json:"refreshToken,omitempty" gorm:"-"`
 	Avatar                string     `json:"avatar,omitempty"`
 	Gender                string     `json:"gender,omitempty"`
 	City                  string     `json:"city,omitempty"`
@@ -184,6 +186,7 @@ func Login(c *gin.Context, user *User) {
 
 	session := sessions.Default(c)
 	session.Set(constants.UserField, user.ID)
+	session.Set(constants.LoginAtField, time.Now().Unix())
 	session.Save()
 	utils.Sig().Emit(constants.SigUserLogin, user, db)
 }
@@ -192,12 +195,22 @@ func Logout(c *gin.Context, user *User) {
 	c.Set(constants.UserField, nil)
 	session := sessions.Default(c)
 	session.Delete(constants.UserField)
+	session.Delete(constants.LoginAtField)
 	session.Save()
 	utils.Sig().Emit(constants.SigUserLogout, user, c)
 }
 
 func AuthRequired(c *gin.Context) {
-	if CurrentUser(c) != nil {
+	if user := CurrentUser(c); user != nil {
+		// 组织安全策略：IP 白名单、会话时长和双因素认证
+		db := c.MustGet(constants.DbField).(*gorm.DB)
+		if !enforceSecurityPolicy(c, db, user, sessionLoginAt(c, user)) {
+			return
+		}
+		// 订阅过期的组织只读
+		if !enforcePlanReadOnly(c, db, user) {
+			return
+		}
 		c.Next()
 		return
 	}
@@ -224,6 +237,12 @@ func AuthRequired(c *gin.Context) {
 		LingEcho.AbortWithJSONError(c, http.StatusUnauthorized, err)
 		return
 	}
+	if !enforceSecurityPolicy(c, db, user, sessionLoginAt(c, user)) {
+		return
+	}
+	if !enforcePlanReadOnly(c, db, user) {
+		return
+	}
 	c.Set(constants.UserField, user)
 	c.Next()
 }
@@ -444,6 +463,9 @@ func GetUserByAPIKey(c *gin.Context, apiKey, apiSecret string) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
+	if userCredential.ID != 0 {
+		SetCurrentCredential(c, &userCredential)
+	}
 	var user *User
 	err = db.Model(&User{}).Where("id = ?", userCredential.UserID).Find(&user).Error
 	if err != nil {
@@ -532,49 +554,83 @@ func SetLastLogin(db *gorm.DB, user *User, lastIp string) error {
 
 func EncodeHashToken(user *User, timestamp int64, useLastlogin bool) (hash string) {
 	// ts-uid-token
+	return signHashToken(user, fmt.Sprintf("%s$%d", user.Email, timestamp), useLastlogin)
+}
+
+// EncodeAccessToken 生成带令牌 ID 和签发时间的访问令牌，可通过撤销列表单独作废
+func EncodeAccessToken(user *User, claims AccessClaims, useLastlogin bool) string {
+	t := fmt.Sprintf("%s$%d$%d$%s", user.Email, claims.ExpiresAt.Unix(), claims.IssuedAt.UnixMilli(), claims.JTI)
+	return signHashToken(user, t, useLastlogin)
+}
+
+func signHashToken(user *User, t string, useLastlogin bool) string {
 	logintimestamp := "0"
 	if useLastlogin && user.LastLogin != nil {
 		logintimestamp = fmt.Sprintf("%d", user.LastLogin.Unix())
 	}
-	t := fmt.Sprintf("%s$%d", user.Email, timestamp)
 	hashVal := sha256.Sum256([]byte(logintimestamp + user.Password + t))
-	hash = base64.RawStdEncoding.EncodeToString([]byte(t)) + "-" + fmt.Sprintf("%x", hashVal)
-	return hash
+	return base64.RawStdEncoding.EncodeToString([]byte(t)) + "-" + fmt.Sprintf("%x", hashVal)
+}
+
+// DecodeHashToken 校验令牌并返回用户，已撤销的令牌返回 ErrTokenRevoked。
+// 所有用令牌换取身份的地方（接口鉴权、令牌登录）都应使用它
+func DecodeHashToken(db *gorm.DB, hash string, useLastLogin bool) (*User, error) {
+	user, claims, err := DecodeAccessToken(db, hash, useLastLogin)
+	if err != nil {
+		return nil, err
+	}
+	if IsAccessTokenRevoked(db, user.ID, claims) {
+		return nil, ErrTokenRevoked
+	}
+	return user, nil
 }
 
-func DecodeHashToken(db *gorm.DB, hash string, useLastLogin bool) (user *User, err error) {
+// DecodeAccessToken 校验令牌并返回用户和签发信息，旧格式令牌的签发信息只有过期时间
+func DecodeAccessToken(db *gorm.DB, hash string, useLastLogin bool) (*User, *AccessClaims, error) {
 	vals := strings.Split(hash, "-")
 	if len(vals) != 2 {
-		return nil, errors.New("bad token")
+		return nil, nil, errors.New("bad token")
 	}
 	data, err := base64.RawStdEncoding.DecodeString(vals[0])
 	if err != nil {
-		return nil, errors.New("bad token")
+		return nil, nil, errors.New("bad token")
 	}
 
 	vals = strings.Split(string(data), "$")
-	if len(vals) != 2 {
-		return nil, errors.New("bad token")
+	if len(vals) != 2 && len(vals) != 4 {
+		return nil, nil, errors.New("bad token")
 	}
 
 	ts, err := strconv.ParseInt(vals[1], 10, 64)
 	if err != nil {
-		return nil, errors.New("bad token")
+		return nil, nil, errors.New("bad token")
+	}
+	claims := &AccessClaims{ExpiresAt: time.Unix(ts, 0)}
+	if len(vals) == 4 {
+		iat, err := strconv.ParseInt(vals[2], 10, 64)
+		if err != nil || vals[3] == "" {
+			return nil, nil, errors.New("bad token")
+		}
+		claims.IssuedAt = time.UnixMilli(iat)
+		claims.JTI = vals[3]
 	}
 
 	if time.Now().Unix() > ts {
-		return nil, errors.New("token expired")
+		return nil, nil, errors.New("token expired")
 	}
 
-	user, err = GetUserByEmail(db, vals[0])
+	user, err := GetUserByEmail(db, vals[0])
 	if err != nil {
-		return nil, errors.New("bad token")
+		return nil, nil, errors.New("bad token")
 	}
 	token := EncodeHashToken(user, ts, useLastLogin)
+	if claims.JTI != "" {
+		token = EncodeAccessToken(user, *claims, useLastLogin)
+	}
 	if token != hash {
-		return nil, errors.New("bad token")
+		return nil, nil, errors.New("bad token")
 	}
-	return user, nil
+	return user, claims, nil
 }
 
 func CheckUserAllowLogin(db *gorm.DB, user *User) error {
@@ -624,8 +680,8 @@ func InTimezone(c *gin.Context, timezone string) {
 }
 
 func BuildAuthToken(user *User, expired time.Duration, useLoginTime bool) string {
-	n := time.Now().Add(expired)
-	return EncodeHashToken(user, n.Unix(), useLoginTime)
+	now := time.Now()
+	return EncodeAccessToken(user, AccessClaims{JTI: newTokenID(), IssuedAt: now, ExpiresAt: now.Add(expired)}, useLoginTime)
 }
 
 func UpdateUser(db *gorm.DB, user *User, vals map[string]any) error {
@@ -774,17 +830,7 @@ func VerifyPhone(db *gorm.DB, user *User, token string) error {
 	}
 
 	// 更新手机验证状态
-	err := UpdateUserFields(db, user, map[string]any{
-		"PhoneVerified":    true,
-		"PhoneVerifyToken": "",
-	})
-	if err != nil {
-		return err
-	}
-
-	user.PhoneVerified = true
-	user.PhoneVerifyToken = ""
-	return nil
+	return MarkPhoneVerified(db, user)
 }
 
 // UpdateNotificationSettings 更新通知设置
@@ -26,7 +26,7 @@ func init() {
 }
 
 func setupHandlerTestDB(t *testing.T) *gorm.DB {
-	return setupTestDBWithSilentLogger(t, &User{}, &UserCredential{})
+	return setupTestDBWithSilentLogger(t, &User{}, &UserCredential{}, &RevokedToken{})
 }
 
 func setupHandlerTestRouter(t *testing.T, db *gorm.DB) *gin.Engine {
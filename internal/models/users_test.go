@@ -18,6 +18,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&UserCredential{},
 		&Group{},
 		&GroupMember{},
+		&RevokedToken{},
 	)
 }
 
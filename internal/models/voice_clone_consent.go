@@ -0,0 +1,209 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VoiceCloneConsentVersion 当前同意声明的版本，声明内容变更时递增，客户端需提交展示给用户的版本
+const VoiceCloneConsentVersion = "2026-10"
+
+// VoiceCloneConsentStatement 上传音色样本前用户必须明确同意的声明
+const VoiceCloneConsentStatement = "I confirm that the voice in the recordings I upload is my own, or that the speaker " +
+	"has given me explicit permission to create a synthetic copy of their voice and to use it with this account. " +
+	"I will not use the cloned voice to impersonate anyone or to mislead listeners. The cloned voice can only be " +
+	"used by this account and its organizations, and I can delete it at any time, which revokes this consent."
+
+var (
+	ErrVoiceConsentRequired = errors.New("explicit consent to the current voice cloning statement is required")
+	ErrVoiceCloneNotAllowed = errors.New("voice clone belongs to another account")
+)
+
+// VoiceCloneConsent 音色样本上传时的同意记录，删除音色时标记撤销但保留用于审计
+type VoiceCloneConsent struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt        time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UserID           uint       `json:"userId" gorm:"index:idx_voice_consent_task"`
+	Provider         string     `json:"provider" gorm:"size:32;index:idx_voice_consent_task"`
+	TaskID           string     `json:"taskId" gorm:"size:100;index:idx_voice_consent_task"` // 训练任务ID，火山引擎为 speaker_id
+	VoiceCloneID     *uint      `json:"voiceCloneId,omitempty" gorm:"index"`                 // 训练成功后关联的音色
+	SpeakerName      string     `json:"speakerName" gorm:"size:128"`
+	SpeakerIsSelf    bool       `json:"speakerIsSelf"` // 说话人是否为账号本人
+	StatementVersion string     `json:"statementVersion" gorm:"size:32"`
+	SampleSHA256     string     `json:"sampleSha256" gorm:"size:64"` // 上传样本的哈希，样本本身不保存
+	SampleSize       int64      `json:"sampleSize"`
+	IP               string     `json:"ip" gorm:"size:64"`
+	UserAgent        string     `json:"userAgent" gorm:"size:255"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+}
+
+// TableName 指定表名
+func (VoiceCloneConsent) TableName() string {
+	return "voice_clone_consents"
+}
+
+// VoiceConsentInput 客户端随样本提交的同意信息
+type VoiceConsentInput struct {
+	Consent       bool   `form:"consent" json:"consent"`
+	Version       string `form:"consentVersion" json:"consentVersion"`
+	SpeakerName   string `form:"speakerName" json:"speakerName"`
+	SpeakerIsSelf bool   `form:"speakerIsSelf" json:"speakerIsSelf"`
+}
+
+// Validate 检查用户是否明确同意了当前版本的声明并填写了说话人
+func (in *VoiceConsentInput) Validate() error {
+	if !in.Consent || in.Version != VoiceCloneConsentVersion {
+		return ErrVoiceConsentRequired
+	}
+	if strings.TrimSpace(in.SpeakerName) == "" {
+		return fmt.Errorf("%w: speakerName is required", ErrVoiceConsentRequired)
+	}
+	return nil
+}
+
+// RecordVoiceCloneConsent 记录一次样本上传的同意
+func RecordVoiceCloneConsent(db *gorm.DB, userID uint, provider, taskID string, in *VoiceConsentInput, sample []byte, ip, userAgent string) (*VoiceCloneConsent, error) {
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(sample)
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	consent := &VoiceCloneConsent{
+		UserID:           userID,
+		Provider:         provider,
+		TaskID:           taskID,
+		SpeakerName:      strings.TrimSpace(in.SpeakerName),
+		SpeakerIsSelf:    in.SpeakerIsSelf,
+		StatementVersion: in.Version,
+		SampleSHA256:     hex.EncodeToString(sum[:]),
+		SampleSize:       int64(len(sample)),
+		IP:               ip,
+		UserAgent:        userAgent,
+	}
+	if err := db.Create(consent).Error; err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// HasVoiceCloneConsent 判断训练任务是否有未撤销的同意记录
+func HasVoiceCloneConsent(db *gorm.DB, userID uint, provider, taskID string) bool {
+	var count int64
+	db.Model(&VoiceCloneConsent{}).
+		Where("user_id = ? AND provider = ? AND task_id = ? AND revoked_at IS NULL", userID, provider, taskID).
+		Count(&count)
+	return count > 0
+}
+
+// GetVoiceCloneConsents 获取音色的同意记录
+func GetVoiceCloneConsents(db *gorm.DB, voiceCloneID uint) ([]VoiceCloneConsent, error) {
+	var consents []VoiceCloneConsent
+	err := db.Where("voice_clone_id = ?", voiceCloneID).Order("id").Find(&consents).Error
+	return consents, err
+}
+
+// RevokeVoiceCloneConsents 删除音色时撤销其同意记录
+func RevokeVoiceCloneConsents(db *gorm.DB, voiceCloneID uint) error {
+	return db.Model(&VoiceCloneConsent{}).
+		Where("voice_clone_id = ? AND revoked_at IS NULL", voiceCloneID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// VoiceAssetOwnedByOther 判断平台音色ID是否已被其他账号使用（包括已删除的音色）
+func VoiceAssetOwnedByOther(db *gorm.DB, provider, assetID string, userID uint) bool {
+	var count int64
+	db.Unscoped().Model(&VoiceClone{}).
+		Where("provider = ? AND asset_id = ? AND user_id <> ?", provider, assetID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// SaveTrainedVoiceClone 训练成功后创建或更新音色并关联同意记录。新建或恢复已删除的
+// 音色需要未撤销的同意记录；平台音色ID属于其他账号时拒绝
+func SaveTrainedVoiceClone(db *gorm.DB, userID uint, task *VoiceTrainingTask, assetID, trainVID, provider string) (*VoiceClone, error) {
+	var clone VoiceClone
+	err := db.Unscoped().Where("asset_id = ?", assetID).First(&clone).Error
+	switch {
+	case err == nil && clone.UserID != userID:
+		return nil, ErrVoiceCloneNotAllowed
+	case err == nil && !clone.DeletedAt.Valid:
+		clone.TrainVID = trainVID
+		clone.IsActive = true
+		if err := db.Save(&clone).Error; err != nil {
+			return nil, err
+		}
+		return &clone, nil
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	if !HasVoiceCloneConsent(db, userID, provider, task.TaskID) {
+		return nil, ErrVoiceConsentRequired
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if clone.ID != 0 {
+			// 同一账号重新训练已删除的音色
+			clone.DeletedAt = gorm.DeletedAt{}
+			clone.TrainingTaskID = task.ID
+			clone.Provider = provider
+			clone.TrainVID = trainVID
+			clone.IsActive = true
+			if err := tx.Unscoped().Save(&clone).Error; err != nil {
+				return err
+			}
+		} else {
+			clone = VoiceClone{
+				UserID:           userID,
+				TrainingTaskID:   task.ID,
+				Provider:         provider,
+				AssetID:          assetID,
+				TrainVID:         trainVID,
+				VoiceName:        task.TaskName,
+				VoiceDescription: fmt.Sprintf("基于任务 %s 训练的音色", task.TaskName),
+				IsActive:         true,
+			}
+			if err := tx.Create(&clone).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&VoiceCloneConsent{}).
+			Where("user_id = ? AND provider = ? AND task_id = ? AND revoked_at IS NULL", userID, provider, task.TaskID).
+			Update("voice_clone_id", clone.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// VoiceCloneUsableBy 判断音色能否被助手使用：音色属于助手的所有者，
+// 或者音色与助手属于同一组织
+func VoiceCloneUsableBy(clone *VoiceClone, userID uint, groupID *uint) bool {
+	if clone.UserID == userID {
+		return true
+	}
+	return clone.GroupID != nil && groupID != nil && *clone.GroupID == *groupID
+}
+
+// GetAssistantVoiceClone 获取助手可用的音色，音色不存在、已停用或属于其他账号时返回错误
+func GetAssistantVoiceClone(db *gorm.DB, voiceCloneID int64, assistant *Assistant) (*VoiceClone, error) {
+	clone, err := GetVoiceCloneByID(db, voiceCloneID)
+	if err != nil {
+		return nil, err
+	}
+	if !clone.IsActive {
+		return nil, fmt.Errorf("voice clone %d is not active", voiceCloneID)
+	}
+	if !VoiceCloneUsableBy(clone, assistant.UserID, assistant.GroupID) {
+		return nil, ErrVoiceCloneNotAllowed
+	}
+	return clone, nil
+}
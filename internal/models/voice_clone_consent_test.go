@@ -0,0 +1,94 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoiceConsentInput_Validate(t *testing.T) {
+	in := VoiceConsentInput{Consent: true, Version: VoiceCloneConsentVersion, SpeakerName: "Alice", SpeakerIsSelf: true}
+	assert.NoError(t, in.Validate())
+
+	stale := in
+	stale.Version = "2020-01"
+	assert.ErrorIs(t, stale.Validate(), ErrVoiceConsentRequired)
+	declined := in
+	declined.Consent = false
+	assert.ErrorIs(t, declined.Validate(), ErrVoiceConsentRequired)
+	anonymous := in
+	anonymous.SpeakerName = " "
+	assert.ErrorIs(t, anonymous.Validate(), ErrVoiceConsentRequired)
+}
+
+func TestSaveTrainedVoiceClone(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &VoiceTrainingTask{}, &VoiceClone{}, &VoiceCloneConsent{})
+	task := &VoiceTrainingTask{UserID: 1, TaskID: "task-1", TaskName: "my voice"}
+	require.NoError(t, db.Create(task).Error)
+
+	// Training finished without a recorded consent: no clone
+	_, err := SaveTrainedVoiceClone(db, 1, task, "asset-1", "vid", "xunfei")
+	assert.ErrorIs(t, err, ErrVoiceConsentRequired)
+
+	in := &VoiceConsentInput{Consent: true, Version: VoiceCloneConsentVersion, SpeakerName: "Alice", SpeakerIsSelf: true}
+	consent, err := RecordVoiceCloneConsent(db, 1, "xunfei", "task-1", in, []byte("sample"), "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Len(t, consent.SampleSHA256, 64)
+
+	clone, err := SaveTrainedVoiceClone(db, 1, task, "asset-1", "vid", "xunfei")
+	require.NoError(t, err)
+	consents, err := GetVoiceCloneConsents(db, clone.ID)
+	require.NoError(t, err)
+	require.Len(t, consents, 1)
+	assert.Equal(t, "Alice", consents[0].SpeakerName)
+
+	// Polling again updates the clone in place
+	again, err := SaveTrainedVoiceClone(db, 1, task, "asset-1", "vid2", "xunfei")
+	require.NoError(t, err)
+	assert.Equal(t, clone.ID, again.ID)
+	assert.Equal(t, "vid2", again.TrainVID)
+
+	// Another account cannot claim the provider voice, even after deletion
+	other := &VoiceTrainingTask{UserID: 2, TaskID: "task-1", TaskName: "stolen"}
+	_, err = RecordVoiceCloneConsent(db, 2, "xunfei", "task-1", in, []byte("sample"), "", "")
+	require.NoError(t, err)
+	_, err = SaveTrainedVoiceClone(db, 2, other, "asset-1", "vid", "xunfei")
+	assert.ErrorIs(t, err, ErrVoiceCloneNotAllowed)
+	assert.True(t, VoiceAssetOwnedByOther(db, "xunfei", "asset-1", 2))
+	assert.False(t, VoiceAssetOwnedByOther(db, "xunfei", "asset-1", 1))
+
+	// Deleting revokes the consent; restoring the voice needs a new one
+	require.NoError(t, db.Delete(clone).Error)
+	require.NoError(t, RevokeVoiceCloneConsents(db, clone.ID))
+	assert.False(t, HasVoiceCloneConsent(db, 1, "xunfei", "task-1"))
+	_, err = SaveTrainedVoiceClone(db, 1, task, "asset-1", "vid", "xunfei")
+	assert.ErrorIs(t, err, ErrVoiceConsentRequired)
+	_, err = RecordVoiceCloneConsent(db, 1, "xunfei", "task-1", in, []byte("sample"), "", "")
+	require.NoError(t, err)
+	restored, err := SaveTrainedVoiceClone(db, 1, task, "asset-1", "vid", "xunfei")
+	require.NoError(t, err)
+	assert.Equal(t, clone.ID, restored.ID)
+	assert.False(t, restored.DeletedAt.Valid)
+}
+
+func TestGetAssistantVoiceClone(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &VoiceClone{})
+	groupID := uint(7)
+	own := &VoiceClone{UserID: 1, AssetID: "a1", VoiceName: "own", IsActive: true}
+	shared := &VoiceClone{UserID: 3, GroupID: &groupID, AssetID: "a2", VoiceName: "org", IsActive: true}
+	foreign := &VoiceClone{UserID: 2, AssetID: "a3", VoiceName: "foreign", IsActive: true}
+	require.NoError(t, db.Create([]*VoiceClone{own, shared, foreign}).Error)
+
+	assistant := &Assistant{UserID: 1}
+	_, err := GetAssistantVoiceClone(db, int64(own.ID), assistant)
+	assert.NoError(t, err)
+	_, err = GetAssistantVoiceClone(db, int64(foreign.ID), assistant)
+	assert.ErrorIs(t, err, ErrVoiceCloneNotAllowed)
+	_, err = GetAssistantVoiceClone(db, int64(shared.ID), assistant)
+	assert.ErrorIs(t, err, ErrVoiceCloneNotAllowed)
+
+	orgAssistant := &Assistant{UserID: 1, GroupID: &groupID}
+	_, err = GetAssistantVoiceClone(db, int64(shared.ID), orgAssistant)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// WebWidgetTokenTTL 会话令牌须在此时间内用于建立通话
+	WebWidgetTokenTTL = 2 * time.Minute
+
+	webWidgetKeyPrefix   = "ww_"
+	webWidgetTokenPrefix = "wst_"
+
+	defaultWidgetMaxSessionSeconds  = 300
+	maxWidgetMaxSessionSeconds      = 3600
+	defaultWidgetSessionsPerIPHour  = 10
+	defaultWidgetSessionsPerDay     = 1000
+	defaultWidgetConcurrentSessions = 20
+	maxWidgetAllowedOrigins         = 20
+)
+
+var (
+	ErrWidgetDisabled         = errors.New("widget is disabled")
+	ErrWidgetOriginNotAllowed = errors.New("origin is not allowed for this widget")
+	ErrWidgetRateLimited      = errors.New("too many widget sessions, please try again later")
+	ErrWidgetTokenInvalid     = errors.New("widget session token is invalid or expired")
+	ErrInvalidWidgetOrigin    = errors.New("origin must look like https://example.com or https://*.example.com")
+)
+
+// WebWidget 可嵌入网页的通话组件，访客通过公开的 Key 换取绑定到一个助手的匿名短期会话令牌，
+// 仅允许来自白名单域名的页面发起，用量计入所选凭证
+type WebWidget struct {
+	ID                    uint        `json:"id" gorm:"primaryKey"`
+	CreatedAt             time.Time   `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt             time.Time   `json:"updatedAt" gorm:"autoUpdateTime"`
+	UserID                uint        `json:"userId" gorm:"index"`
+	AssistantID           int64       `json:"assistantId" gorm:"index"`
+	CredentialID          uint        `json:"credentialId"` // 访客通话使用的凭证，需属于助手所有者
+	Name                  string      `json:"name" gorm:"size:128"`
+	PublicKey             string      `json:"publicKey" gorm:"size:64;uniqueIndex"` // 嵌入网页的公开标识
+	AllowedOrigins        StringArray `json:"allowedOrigins" gorm:"type:json"`      // 允许的来源，支持 https://*.example.com
+	Enabled               bool        `json:"enabled"`
+	MaxSessionSeconds     int         `json:"maxSessionSeconds"`     // 单次通话最长时间
+	SessionsPerIPHour     int         `json:"sessionsPerIpHour"`     // 每个 IP 每小时可发起的会话数
+	SessionsPerDay        int         `json:"sessionsPerDay"`        // 组件每天可发起的会话数
+	MaxConcurrentSessions int         `json:"maxConcurrentSessions"` // 同时进行的通话数
+}
+
+// TableName 指定表名
+func (WebWidget) TableName() string {
+	return "web_widgets"
+}
+
+// WebWidgetSession 组件的匿名会话，令牌只保存哈希，建立通话时一次性兑换
+type WebWidgetSession struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime;index"`
+	WidgetID    uint       `json:"widgetId" gorm:"index"`
+	AssistantID int64      `json:"assistantId"`
+	TokenHash   string     `json:"-" gorm:"size:64;uniqueIndex"`
+	Origin      string     `json:"origin" gorm:"size:255"`
+	ClientIP    string     `json:"clientIp" gorm:"size:64;index"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	EndedAt     *time.Time `json:"endedAt,omitempty"`
+}
+
+// TableName 指定表名
+func (WebWidgetSession) TableName() string {
+	return "web_widget_sessions"
+}
+
+// NormalizeWidgetOrigin 将来源规范为 scheme://host[:port]，支持一级通配子域名
+func NormalizeWidgetOrigin(origin string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(strings.ToLower(origin)))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", ErrInvalidWidgetOrigin
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", ErrInvalidWidgetOrigin
+	}
+	host := u.Hostname()
+	if rest, ok := strings.CutPrefix(host, "*."); ok {
+		host = rest
+	}
+	if host == "" || strings.Contains(host, "*") {
+		return "", ErrInvalidWidgetOrigin
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// Validate 检查组件配置并补全默认限制
+func (w *WebWidget) Validate() error {
+	if len(w.AllowedOrigins) == 0 {
+		return errors.New("at least one allowed origin is required")
+	}
+	if len(w.AllowedOrigins) > maxWidgetAllowedOrigins {
+		return fmt.Errorf("at most %d allowed origins", maxWidgetAllowedOrigins)
+	}
+	origins := StringArray{}
+	seen := map[string]bool{}
+	for _, origin := range w.AllowedOrigins {
+		normalized, err := NormalizeWidgetOrigin(origin)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, origin)
+		}
+		if !seen[normalized] {
+			seen[normalized] = true
+			origins = append(origins, normalized)
+		}
+	}
+	w.AllowedOrigins = origins
+
+	if w.MaxSessionSeconds <= 0 {
+		w.MaxSessionSeconds = defaultWidgetMaxSessionSeconds
+	}
+	if w.MaxSessionSeconds > maxWidgetMaxSessionSeconds {
+		w.MaxSessionSeconds = maxWidgetMaxSessionSeconds
+	}
+	if w.SessionsPerIPHour <= 0 {
+		w.SessionsPerIPHour = defaultWidgetSessionsPerIPHour
+	}
+	if w.SessionsPerDay <= 0 {
+		w.SessionsPerDay = defaultWidgetSessionsPerDay
+	}
+	if w.MaxConcurrentSessions <= 0 {
+		w.MaxConcurrentSessions = defaultWidgetConcurrentSessions
+	}
+	return nil
+}
+
+// OriginAllowed 判断来源是否在白名单中，通配项匹配任意一级或多级子域名，但不匹配根域名本身
+func (w *WebWidget) OriginAllowed(origin string) bool {
+	normalized, err := NormalizeWidgetOrigin(origin)
+	if err != nil || strings.Contains(normalized, "*") {
+		return false
+	}
+	for _, allowed := range w.AllowedOrigins {
+		if allowed == normalized {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.HasPrefix(normalized, scheme+"://") && strings.HasSuffix(normalized, "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxSessionDuration 单次通话最长时间
+func (w *WebWidget) MaxSessionDuration() time.Duration {
+	return time.Duration(w.MaxSessionSeconds) * time.Second
+}
+
+// GenerateWidgetKey 生成组件公开标识
+func GenerateWidgetKey() (string, error) {
+	return randomWidgetString(webWidgetKeyPrefix, 16)
+}
+
+func randomWidgetString(prefix string, n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+func hashWidgetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetWebWidgetByKey 按公开标识获取组件
+func GetWebWidgetByKey(db *gorm.DB, key string) (*WebWidget, error) {
+	var widget WebWidget
+	if err := db.Where("public_key = ?", key).First(&widget).Error; err != nil {
+		return nil, err
+	}
+	return &widget, nil
+}
+
+// IssueWebWidgetSession 为来自白名单页面的访客签发会话令牌，按 IP、每日总量和并发数限流
+func IssueWebWidgetSession(db *gorm.DB, widget *WebWidget, origin, clientIP string, now time.Time) (string, *WebWidgetSession, error) {
+	if !widget.Enabled {
+		return "", nil, ErrWidgetDisabled
+	}
+	if !widget.OriginAllowed(origin) {
+		return "", nil, ErrWidgetOriginNotAllowed
+	}
+
+	var perIP, perDay, active int64
+	base := db.Model(&WebWidgetSession{}).Where("widget_id = ?", widget.ID)
+	if err := base.Session(&gorm.Session{}).Where("client_ip = ? AND created_at >= ?", clientIP, now.Add(-time.Hour)).Count(&perIP).Error; err != nil {
+		return "", nil, err
+	}
+	if err := base.Session(&gorm.Session{}).Where("created_at >= ?", now.Add(-24*time.Hour)).Count(&perDay).Error; err != nil {
+		return "", nil, err
+	}
+	if err := base.Session(&gorm.Session{}).
+		Where("started_at IS NOT NULL AND ended_at IS NULL AND started_at >= ?", now.Add(-widget.MaxSessionDuration())).
+		Count(&active).Error; err != nil {
+		return "", nil, err
+	}
+	if perIP >= int64(widget.SessionsPerIPHour) || perDay >= int64(widget.SessionsPerDay) || active >= int64(widget.MaxConcurrentSessions) {
+		return "", nil, ErrWidgetRateLimited
+	}
+
+	token, err := randomWidgetString(webWidgetTokenPrefix, 24)
+	if err != nil {
+		return "", nil, err
+	}
+	normalized, _ := NormalizeWidgetOrigin(origin)
+	session := &WebWidgetSession{
+		WidgetID:    widget.ID,
+		AssistantID: widget.AssistantID,
+		TokenHash:   hashWidgetToken(token),
+		Origin:      normalized,
+		ClientIP:    clientIP,
+		ExpiresAt:   now.Add(WebWidgetTokenTTL),
+	}
+	if err := db.Create(session).Error; err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// RedeemWebWidgetSession 兑换会话令牌建立通话，令牌只能使用一次，且须来自签发时的页面来源
+func RedeemWebWidgetSession(db *gorm.DB, token, origin string, now time.Time) (*WebWidgetSession, error) {
+	var session WebWidgetSession
+	if err := db.Where("token_hash = ?", hashWidgetToken(token)).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWidgetTokenInvalid
+		}
+		return nil, err
+	}
+	if now.After(session.ExpiresAt) {
+		return nil, ErrWidgetTokenInvalid
+	}
+	if normalized, err := NormalizeWidgetOrigin(origin); err != nil || normalized != session.Origin {
+		return nil, ErrWidgetOriginNotAllowed
+	}
+	result := db.Model(&WebWidgetSession{}).
+		Where("id = ? AND started_at IS NULL", session.ID).
+		Update("started_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWidgetTokenInvalid
+	}
+	session.StartedAt = &now
+	return &session, nil
+}
+
+// EndWebWidgetSession 记录通话结束
+func EndWebWidgetSession(db *gorm.DB, sessionID uint, now time.Time) error {
+	return db.Model(&WebWidgetSession{}).Where("id = ? AND ended_at IS NULL", sessionID).Update("ended_at", now).Error
+}
+
+// WebWidgetUsage 组件近期的会话用量
+type WebWidgetUsage struct {
+	Sessions  int64 `json:"sessions"`  // 签发的会话数
+	Started   int64 `json:"started"`   // 建立了通话的会话数
+	UniqueIPs int64 `json:"uniqueIps"` // 访客 IP 数
+}
+
+// GetWebWidgetUsage 统计组件自 since 以来的会话
+func GetWebWidgetUsage(db *gorm.DB, widgetID uint, since time.Time) (*WebWidgetUsage, error) {
+	usage := &WebWidgetUsage{}
+	err := db.Model(&WebWidgetSession{}).
+		Select("COUNT(*) AS sessions, COUNT(started_at) AS started, COUNT(DISTINCT client_ip) AS unique_ips").
+		Where("widget_id = ? AND created_at >= ?", widgetID, since).
+		Scan(usage).Error
+	return usage, err
+}
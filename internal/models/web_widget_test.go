@@ -0,0 +1,97 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeWidgetOrigin(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://Example.com":        "https://example.com",
+		"https://example.com/":       "https://example.com",
+		"http://localhost:3000":      "http://localhost:3000",
+		"https://*.example.com":      "https://*.example.com",
+		" https://shop.example.com ": "https://shop.example.com",
+	} {
+		got, err := NormalizeWidgetOrigin(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got)
+	}
+	for _, in := range []string{"", "example.com", "ftp://example.com", "https://example.com/page", "https://*", "https://a.*.com"} {
+		_, err := NormalizeWidgetOrigin(in)
+		assert.ErrorIs(t, err, ErrInvalidWidgetOrigin, in)
+	}
+}
+
+func TestWebWidget_OriginAllowed(t *testing.T) {
+	widget := WebWidget{AllowedOrigins: StringArray{"https://example.com", "https://*.shop.io", "https://*.shop.io"}}
+	require.NoError(t, widget.Validate())
+	assert.Equal(t, StringArray{"https://example.com", "https://*.shop.io"}, widget.AllowedOrigins)
+	assert.Equal(t, defaultWidgetMaxSessionSeconds, widget.MaxSessionSeconds)
+
+	assert.True(t, widget.OriginAllowed("https://example.com"))
+	assert.True(t, widget.OriginAllowed("https://eu.shop.io"))
+	assert.True(t, widget.OriginAllowed("https://a.b.shop.io"))
+	assert.False(t, widget.OriginAllowed("https://shop.io"))
+	assert.False(t, widget.OriginAllowed("http://example.com"))
+	assert.False(t, widget.OriginAllowed("https://evilshop.io"))
+	assert.False(t, widget.OriginAllowed("https://example.com.evil.io"))
+	assert.False(t, widget.OriginAllowed(""))
+
+	assert.Error(t, (&WebWidget{}).Validate(), "an allowlist is required")
+}
+
+func TestWebWidgetSessions(t *testing.T) {
+	db := setupTestDBWithSilentLogger(t, &WebWidget{}, &WebWidgetSession{})
+	now := time.Now()
+	widget := &WebWidget{
+		AssistantID:           7,
+		PublicKey:             "ww_test",
+		AllowedOrigins:        StringArray{"https://example.com"},
+		Enabled:               true,
+		SessionsPerIPHour:     2,
+		MaxConcurrentSessions: 1,
+	}
+	require.NoError(t, widget.Validate())
+	require.NoError(t, db.Create(widget).Error)
+
+	_, _, err := IssueWebWidgetSession(db, widget, "https://other.com", "1.1.1.1", now)
+	assert.ErrorIs(t, err, ErrWidgetOriginNotAllowed)
+
+	token, session, err := IssueWebWidgetSession(db, widget, "https://example.com", "1.1.1.1", now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), session.AssistantID)
+	assert.NotContains(t, session.TokenHash, token)
+
+	_, err = RedeemWebWidgetSession(db, token, "https://other.com", now)
+	assert.ErrorIs(t, err, ErrWidgetOriginNotAllowed)
+	_, err = RedeemWebWidgetSession(db, token, "https://example.com", now.Add(WebWidgetTokenTTL+time.Second))
+	assert.ErrorIs(t, err, ErrWidgetTokenInvalid)
+	redeemed, err := RedeemWebWidgetSession(db, token, "https://example.com", now)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, redeemed.ID)
+	_, err = RedeemWebWidgetSession(db, token, "https://example.com", now)
+	assert.ErrorIs(t, err, ErrWidgetTokenInvalid, "tokens are single-use")
+
+	_, _, err = IssueWebWidgetSession(db, widget, "https://example.com", "2.2.2.2", now)
+	assert.ErrorIs(t, err, ErrWidgetRateLimited, "one call is in progress")
+	require.NoError(t, EndWebWidgetSession(db, session.ID, now))
+
+	_, _, err = IssueWebWidgetSession(db, widget, "https://example.com", "1.1.1.1", now)
+	require.NoError(t, err)
+	_, _, err = IssueWebWidgetSession(db, widget, "https://example.com", "1.1.1.1", now)
+	assert.ErrorIs(t, err, ErrWidgetRateLimited, "per-IP hourly limit")
+	_, _, err = IssueWebWidgetSession(db, widget, "https://example.com", "2.2.2.2", now)
+	require.NoError(t, err)
+
+	usage, err := GetWebWidgetUsage(db, widget.ID, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, &WebWidgetUsage{Sessions: 3, Started: 1, UniqueIPs: 2}, usage)
+
+	widget.Enabled = false
+	_, _, err = IssueWebWidgetSession(db, widget, "https://example.com", "3.3.3.3", now)
+	assert.ErrorIs(t, err, ErrWidgetDisabled)
+}
@@ -0,0 +1,53 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const accountLockExpiryBatch = 100
+
+// StartAccountLockExpiry starts the job that releases login locks once they expire and
+// tells the affected users they can sign in again. Locks already stop blocking logins at
+// their unlock time, the job only closes them and sends the notification
+func StartAccountLockExpiry(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	schedule := "@every 1m"
+	if _, err := c.AddFunc(schedule, func() {
+		releaseExpiredAccountLocks(db, time.Now())
+	}); err != nil {
+		logger.Error("Failed to add account lock expiry cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Account lock expiry started", zap.String("schedule", schedule))
+}
+
+func releaseExpiredAccountLocks(db *gorm.DB, now time.Time) {
+	locks, err := models.ReleaseExpiredAccountLocks(db, now, accountLockExpiryBatch)
+	if err != nil {
+		logger.Error("Failed to release expired account locks", zap.Error(err))
+	}
+	for i := range locks {
+		lock := &locks[i]
+		if utils.GlobalLoginSecurityManager != nil {
+			utils.GlobalLoginSecurityManager.ClearFailedLoginCount(lock.Email)
+		}
+		// Locks on emails without an account have nobody to notify
+		user, err := models.GetUserByEmail(db, lock.Email)
+		if err != nil {
+			continue
+		}
+		utils.Sig().Emit(constants.SigUserAccountUnlocked, user, lock, db)
+	}
+}
@@ -0,0 +1,46 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StartAPIKeyUsageFlusher starts the job that writes per API key request counts,
+// errors and latency histograms collected in memory to api_key_usage_rollups
+func StartAPIKeyUsageFlusher(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	// The dashboard lags at most one minute behind, old rollups are pruned nightly
+	schedule := "* * * * *"
+	cleanupSchedule := "30 3 * * *"
+
+	if _, err := c.AddFunc(schedule, func() {
+		flushAPIKeyUsage(db)
+	}); err != nil {
+		logger.Error("Failed to add API key usage flush cron job", zap.Error(err))
+		return
+	}
+	if _, err := c.AddFunc(cleanupSchedule, func() {
+		if _, err := models.CleanupAPIKeyUsage(db, time.Now()); err != nil {
+			logger.Error("API key usage cleanup failed", zap.Error(err))
+		}
+	}); err != nil {
+		logger.Error("Failed to add API key usage cleanup cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("API key usage flusher started", zap.String("schedule", schedule))
+}
+
+func flushAPIKeyUsage(db *gorm.DB) {
+	if _, err := models.DefaultAPIKeyUsage.Flush(db); err != nil {
+		logger.Error("API key usage flush failed, retrying on next run", zap.Error(err))
+	}
+}
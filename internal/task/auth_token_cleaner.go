@@ -0,0 +1,37 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StartAuthTokenCleaner starts the daily cleanup of expired refresh tokens and
+// revocation entries, an expired token is rejected without them
+func StartAuthTokenCleaner(db *gorm.DB) {
+	c := cron.New()
+
+	// Execute cleanup task at 3:45 AM every day
+	schedule := "45 3 * * *"
+
+	_, err := c.AddFunc(schedule, func() {
+		deleted, err := models.PruneExpiredTokens(db, time.Now())
+		if err != nil {
+			logger.Error("Auth token cleaner task failed", zap.Error(err))
+			return
+		}
+		logger.Info("Auth token cleaner task completed", zap.Int64("deleted", deleted))
+	})
+	if err != nil {
+		logger.Error("Failed to add auth token cleaner cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Auth token cleaner started", zap.String("schedule", schedule))
+}
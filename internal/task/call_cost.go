@@ -0,0 +1,40 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// callCostBatch calls rated per run
+const callCostBatch = 200
+
+// StartCallCostRater starts the job that prices ended SIP and device calls from
+// the configured price table. Costs are written once, so later price changes
+// do not alter calls already reconciled against invoices
+func StartCallCostRater(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	schedule := "@every 1m"
+	if _, err := c.AddFunc(schedule, func() {
+		rated, err := models.RateEndedCalls(db, models.LoadPriceTable(db), time.Now(), callCostBatch)
+		if err != nil {
+			logger.Error("Call cost rating failed", zap.Error(err))
+			return
+		}
+		if rated > 0 {
+			logger.Info("Rated ended calls", zap.Int("calls", rated))
+		}
+	}); err != nil {
+		logger.Error("Failed to add call cost cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Call cost rater started", zap.String("schedule", schedule))
+}
@@ -0,0 +1,131 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/compliance"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// complianceBatchSize deliveries per run, the rest wait for the next minute
+	complianceBatchSize = 50
+	// complianceMaxAudio largest recording fetched for archival
+	complianceMaxAudio = 512 << 20
+)
+
+// StartComplianceExporter starts the job that streams finalized recordings and
+// transcripts of organizations with a compliance archive to that archive
+func StartComplianceExporter(db *gorm.DB) {
+	// Deliveries may outlast a minute, never run two batches at once
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	schedule := "* * * * *"
+
+	_, err := c.AddFunc(schedule, func() {
+		now := time.Now()
+		queued, err := models.EnqueueComplianceExports(db, now)
+		if err != nil {
+			logger.Error("Failed to enqueue compliance exports", zap.Error(err))
+		}
+		if queued > 0 {
+			logger.Info("Compliance exports queued", zap.Int("count", queued))
+		}
+		exportDueCompliance(db, now)
+	})
+	if err != nil {
+		logger.Error("Failed to add compliance export cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Compliance exporter started", zap.String("schedule", schedule))
+}
+
+func exportDueCompliance(db *gorm.DB, now time.Time) {
+	due, err := models.DueComplianceExports(db, now, complianceBatchSize)
+	if err != nil {
+		logger.Error("Failed to load due compliance exports", zap.Error(err))
+		return
+	}
+	archives := map[uint]*models.ComplianceArchive{}
+	for i := range due {
+		export := &due[i]
+		archive, ok := archives[export.GroupID]
+		if !ok {
+			if archive, err = models.GetComplianceArchive(db, export.GroupID); err != nil {
+				logger.Error("Failed to load compliance archive", zap.Uint("groupID", export.GroupID), zap.Error(err))
+				continue
+			}
+			archives[export.GroupID] = archive
+		}
+		if archive == nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), compliance.DefaultTimeout)
+		receipt, err := deliverCompliance(ctx, db, archive, export)
+		cancel()
+		if err := models.RecordComplianceDelivery(db, export, receipt, err, time.Now()); err != nil {
+			logger.Error("Failed to record compliance delivery", zap.Uint("exportID", export.ID), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			logger.Warn("Compliance export failed",
+				zap.Uint("exportID", export.ID),
+				zap.String("record", export.RecordID()),
+				zap.Int("attempts", export.Attempts),
+				zap.String("status", export.Status),
+				zap.Error(err))
+		}
+	}
+}
+
+func deliverCompliance(ctx context.Context, db *gorm.DB, archive *models.ComplianceArchive, export *models.ComplianceExport) (*compliance.Receipt, error) {
+	src, err := models.LoadComplianceSource(db, export)
+	if err != nil {
+		return nil, err
+	}
+	if src.AudioLocation != "" {
+		if src.Record.Audio, err = fetchRecordingAudio(ctx, src.AudioLocation); err != nil {
+			return nil, fmt.Errorf("fetch recording audio: %w", err)
+		}
+	}
+	return archive.Sink().Deliver(ctx, src.Record)
+}
+
+// fetchRecordingAudio reads a recording from its storage URL or local path
+func fetchRecordingAudio(ctx context.Context, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, complianceMaxAudio))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("storage responded %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, complianceMaxAudio))
+}
@@ -0,0 +1,141 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// conversationExportBatchSize exports generated per run
+const conversationExportBatchSize = 20
+
+// conversationExportDir directory of the generated files, CONVERSATION_EXPORT_DIR
+func conversationExportDir() string {
+	if dir := utils.GetEnv("CONVERSATION_EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "./exports"
+}
+
+// StartConversationExporter starts the worker that generates queued conversation
+// exports and notifies the requester with a download link, and removes files
+// past their retention
+func StartConversationExporter(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	// Exports are picked up within seconds of being requested
+	schedule := "@every 10s"
+	cleanupSchedule := "15 4 * * *"
+
+	if _, err := c.AddFunc(schedule, func() {
+		runConversationExports(db, time.Now())
+	}); err != nil {
+		logger.Error("Failed to add conversation export cron job", zap.Error(err))
+		return
+	}
+	if _, err := c.AddFunc(cleanupSchedule, func() {
+		cleanupConversationExports(db, time.Now())
+	}); err != nil {
+		logger.Error("Failed to add conversation export cleanup cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Conversation exporter started", zap.String("schedule", schedule))
+}
+
+func runConversationExports(db *gorm.DB, now time.Time) {
+	exports, err := models.ClaimConversationExports(db, now, conversationExportBatchSize)
+	if err != nil {
+		logger.Error("Failed to claim conversation exports", zap.Error(err))
+	}
+	for i := range exports {
+		e := &exports[i]
+		if err := generateConversationExport(db, e); err != nil {
+			final, ferr := models.FailConversationExport(db, e, err)
+			if ferr != nil {
+				logger.Error("Failed to record conversation export failure", zap.Uint("exportId", e.ID), zap.Error(ferr))
+			}
+			logger.Warn("Conversation export failed", zap.Uint("exportId", e.ID), zap.Int("attempts", e.Attempts), zap.Error(err))
+			if final {
+				notifyConversationExport(db, e, "Conversation export failed",
+					fmt.Sprintf("The %s export of %s %s could not be generated: %s", e.Format, e.Source, e.SourceID, e.Error))
+			}
+			continue
+		}
+		token, err := models.SignConversationExportLink(config.GlobalConfig.Auth.SessionSecret, e)
+		if err != nil {
+			logger.Error("Failed to sign conversation export link", zap.Uint("exportId", e.ID), zap.Error(err))
+			continue
+		}
+		link := fmt.Sprintf("%s/conversation-exports/%d/download?token=%s", config.GlobalConfig.Server.APIPrefix, e.ID, token)
+		notifyConversationExport(db, e, "Conversation export ready",
+			fmt.Sprintf("Your %s export %s is ready until %s: %s", e.Format, e.FileName, e.ExpiresAt.Format(time.RFC3339), link))
+	}
+}
+
+// generateConversationExport renders the transcript into a file under the export directory
+func generateConversationExport(db *gorm.DB, e *models.ConversationExport) error {
+	t, err := models.LoadConversationTranscript(db, e)
+	if err != nil {
+		return err
+	}
+	dir := conversationExportDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.%s", e.Source, e.SourceID, e.Format)
+	path := filepath.Join(dir, fmt.Sprintf("export-%d.%s", e.ID, e.Format))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.Render(f, e.Format); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return models.CompleteConversationExport(db, e, path, name, info.Size(), time.Now())
+}
+
+func notifyConversationExport(db *gorm.DB, e *models.ConversationExport, title, content string) {
+	if err := notification.NewInternalNotificationService(db).Send(e.UserID, title, content); err != nil {
+		logger.Warn("Failed to send conversation export notification", zap.Uint("exportId", e.ID), zap.Error(err))
+	}
+}
+
+func cleanupConversationExports(db *gorm.DB, now time.Time) {
+	expired, err := models.ExpiredConversationExports(db, now)
+	if err != nil {
+		logger.Error("Failed to load expired conversation exports", zap.Error(err))
+		return
+	}
+	for i := range expired {
+		if err := os.Remove(expired[i].FilePath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("Failed to remove conversation export", zap.Uint("exportId", expired[i].ID), zap.Error(err))
+			continue
+		}
+		if err := models.ExpireConversationExport(db, &expired[i]); err != nil {
+			logger.Error("Failed to mark conversation export expired", zap.Uint("exportId", expired[i].ID), zap.Error(err))
+		}
+	}
+}
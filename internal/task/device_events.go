@@ -0,0 +1,94 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// deviceOfflineAfter devices marked online that have not reported for this
+	// long are considered offline
+	deviceOfflineAfter = 10 * time.Minute
+	// missedCallLookback only calls missed recently are reported, so the first
+	// run after a deployment does not notify old calls
+	missedCallLookback = time.Hour
+	deviceEventBatch   = 100
+)
+
+// StartDeviceEventNotifier starts the job that notifies owners of devices
+// that stopped reporting and of inbound calls nobody answered, in-app and by
+// push to the mobile app, subject to their notification preferences
+func StartDeviceEventNotifier(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	schedule := "@every 1m"
+	if _, err := c.AddFunc(schedule, func() {
+		now := time.Now()
+		notifyOfflineDevices(db, now)
+		notifyMissedCalls(db, now)
+	}); err != nil {
+		logger.Error("Failed to add device event notifier cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Device event notifier started", zap.String("schedule", schedule))
+}
+
+func notifyOfflineDevices(db *gorm.DB, now time.Time) {
+	devices, err := models.MarkStaleDevicesOffline(db, now.Add(-deviceOfflineAfter), deviceEventBatch)
+	if err != nil {
+		logger.Error("Failed to mark stale devices offline", zap.Error(err))
+	}
+	for _, device := range devices {
+		name := device.DeviceName
+		if name == "" {
+			name = device.MacAddress
+		}
+		lastSeen := ""
+		if device.LastSeen != nil {
+			lastSeen = device.LastSeen.Format("2006-01-02 15:04")
+		}
+		notifyUser(db, device.UserID, models.NotificationEventDevice, notification.PushTemplateDeviceOffline, map[string]string{
+			"deviceId":   device.ID,
+			"deviceName": name,
+			"lastSeen":   lastSeen,
+		})
+	}
+}
+
+func notifyMissedCalls(db *gorm.DB, now time.Time) {
+	calls, err := models.ClaimMissedCalls(db, now.Add(-missedCallLookback), now, deviceEventBatch)
+	if err != nil {
+		logger.Error("Failed to load missed calls", zap.Error(err))
+	}
+	for _, call := range calls {
+		notifyUser(db, *call.UserID, models.NotificationEventCall, notification.PushTemplateMissedCall, map[string]string{
+			"callId": call.CallID,
+			"from":   call.FromUsername,
+			"to":     call.ToUsername,
+			"time":   call.StartTime.Format("2006-01-02 15:04"),
+		})
+	}
+}
+
+func notifyUser(db *gorm.DB, userID uint, event models.NotificationEvent, template string, data map[string]string) {
+	user, err := models.GetUserByUID(db, userID)
+	if err != nil {
+		return
+	}
+	notice, err := models.NoticeFromPushTemplate(event, template, data)
+	if err != nil {
+		logger.Error("Failed to render notification", zap.String("template", template), zap.Error(err))
+		return
+	}
+	notice.Channels = []models.NotificationChannel{models.NotificationChannelInternal, models.NotificationChannelPush}
+	models.DispatchNotification(db, user, notice)
+}
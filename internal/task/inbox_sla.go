@@ -0,0 +1,66 @@
+package task
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const inboxSLABatch = 100
+
+// StartInboxSLAMonitor starts the job that notifies the team when an open
+// inbox conversation misses its first response or resolve target. The
+// assignee is notified, or the whole team when nobody is assigned
+func StartInboxSLAMonitor(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	schedule := "@every 1m"
+	if _, err := c.AddFunc(schedule, func() {
+		notifyInboxSLABreaches(db, time.Now())
+	}); err != nil {
+		logger.Error("Failed to add inbox SLA monitor cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Inbox SLA monitor started", zap.String("schedule", schedule))
+}
+
+func notifyInboxSLABreaches(db *gorm.DB, now time.Time) {
+	breaches, err := models.ClaimInboxSLABreaches(db, now, inboxSLABatch)
+	if err != nil {
+		logger.Error("Failed to claim inbox SLA breaches", zap.Error(err))
+	}
+	for i := range breaches {
+		conv := &breaches[i]
+		recipients := []uint{}
+		if conv.AssigneeID != nil {
+			recipients = append(recipients, *conv.AssigneeID)
+		} else {
+			var assistant models.Assistant
+			if err := db.First(&assistant, conv.AssistantID).Error; err != nil {
+				continue
+			}
+			if recipients, err = models.InboxTeamIDs(db, &assistant); err != nil {
+				logger.Warn("Failed to load inbox team", zap.Int64("assistantID", conv.AssistantID), zap.Error(err))
+				continue
+			}
+		}
+
+		target, due := "first response", conv.FirstResponseDueAt
+		if conv.FirstRespondedAt != nil {
+			target, due = "resolution", conv.ResolveDueAt
+		}
+		models.NotifyInbox(db, conv, recipients,
+			"SLA missed: "+conv.Subject,
+			fmt.Sprintf("The %s target of this %s priority conversation was due at %s.",
+				target, conv.Priority, html.EscapeString(due.Format("2006-01-02 15:04"))))
+	}
+}
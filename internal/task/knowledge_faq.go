@@ -0,0 +1,199 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/faq"
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// knowledgeFAQWindow transcripts of calls started within this window are clustered
+	knowledgeFAQWindow = 7 * 24 * time.Hour
+	// knowledgeFAQMaxTranscripts newest transcripts loaded per run
+	knowledgeFAQMaxTranscripts = 2000
+	// knowledgeFAQMinCalls a topic needs this many calls to count as frequently asked
+	knowledgeFAQMinCalls = 3
+	// knowledgeFAQMaxClusters largest topics sent to the LLM per knowledge base and run
+	knowledgeFAQMaxClusters = 10
+	// knowledgeFAQQuestionsPerCluster questions extracted per topic
+	knowledgeFAQQuestionsPerCluster = 3
+	// knowledgeFAQDuplicate questions this similar to an earlier proposal are skipped
+	knowledgeFAQDuplicate = 0.8
+)
+
+// StartKnowledgeFAQGenerator starts the daily job that clusters the last week's
+// call transcripts of assistants with a knowledge base, extracts frequently asked
+// questions with drafted answers via the assistant's LLM and stores them as
+// pending proposals. Approved proposals are added to the knowledge base by the
+// review API.
+func StartKnowledgeFAQGenerator(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	// Execute at 03:00 every day, outside business hours
+	schedule := "0 3 * * *"
+	if _, err := c.AddFunc(schedule, func() {
+		generateKnowledgeFAQs(db, time.Now())
+	}); err != nil {
+		logger.Error("Failed to add knowledge FAQ cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Knowledge FAQ generator started", zap.String("schedule", schedule))
+}
+
+func generateKnowledgeFAQs(db *gorm.DB, now time.Time) {
+	rows, err := models.ListFAQTranscripts(db, now.Add(-knowledgeFAQWindow), knowledgeFAQMaxTranscripts)
+	if err != nil {
+		logger.Error("Failed to load call transcripts for FAQ generation", zap.Error(err))
+		return
+	}
+
+	// Assistants sharing a knowledge base contribute to the same proposals
+	byKnowledge := map[string][]models.FAQTranscript{}
+	var keys []string
+	for _, row := range rows {
+		if _, ok := byKnowledge[row.KnowledgeKey]; !ok {
+			keys = append(keys, row.KnowledgeKey)
+		}
+		byKnowledge[row.KnowledgeKey] = append(byKnowledge[row.KnowledgeKey], row)
+	}
+
+	for _, key := range keys {
+		created, err := generateKnowledgeBaseFAQs(db, key, byKnowledge[key])
+		if err != nil {
+			logger.Warn("Knowledge FAQ generation failed", zap.String("knowledgeKey", key), zap.Error(err))
+			continue
+		}
+		if created > 0 {
+			logger.Info("Knowledge FAQ proposals created", zap.String("knowledgeKey", key), zap.Int("proposals", created))
+		}
+	}
+}
+
+func generateKnowledgeBaseFAQs(db *gorm.DB, knowledgeKey string, rows []models.FAQTranscript) (int, error) {
+	if len(rows) < knowledgeFAQMinCalls {
+		return 0, nil
+	}
+	k, err := models.GetKnowledge(db, knowledgeKey)
+	if err != nil {
+		return 0, fmt.Errorf("knowledge base: %w", err)
+	}
+
+	transcripts := make([]faq.Transcript, len(rows))
+	for i, row := range rows {
+		transcripts[i] = faq.Transcript{ID: row.CallID, Text: row.Transcription}
+	}
+	var clusters []faq.Cluster
+	for _, cluster := range faq.ClusterTranscripts(transcripts, faq.DefaultThreshold) {
+		if len(cluster.Transcripts) < knowledgeFAQMinCalls || len(clusters) == knowledgeFAQMaxClusters {
+			break
+		}
+		clusters = append(clusters, cluster)
+	}
+	if len(clusters) == 0 {
+		return 0, nil
+	}
+
+	var assistant models.Assistant
+	if err := db.First(&assistant, rows[0].AssistantID).Error; err != nil {
+		return 0, fmt.Errorf("assistant: %w", err)
+	}
+	credential, err := assistantLLMCredential(db, &assistant)
+	if err != nil {
+		return 0, err
+	}
+	provider, err := llm.NewLLMProvider(context.Background(), credential.LLMProvider, credential.LLMApiKey, credential.LLMApiURL,
+		"You are a support analyst writing knowledge base FAQs from call transcripts.")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	defer provider.Hangup()
+
+	temp := float32(0.2)
+	options := llm.QueryOptions{Model: assistant.LLMModel, Temperature: &temp, MaxTokens: intPtr(2000)}
+	if options.Model == "" {
+		options.Model = "gpt-4o-mini"
+	}
+
+	existing, err := models.KnowledgeFAQQuestions(db, k.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, cluster := range clusters {
+		// Each topic is asked without the previous topics in the history
+		provider.ResetMessages()
+		proposals, err := faq.Extract(provider, options, cluster, knowledgeFAQQuestionsPerCluster)
+		if err != nil {
+			logger.Warn("Failed to extract FAQs from call cluster",
+				zap.String("knowledgeKey", knowledgeKey), zap.Strings("terms", cluster.Terms), zap.Error(err))
+			continue
+		}
+		for _, p := range proposals {
+			if isKnownFAQ(existing, p.Question) {
+				continue
+			}
+			proposal := &models.KnowledgeFAQProposal{
+				KnowledgeID: k.ID,
+				UserID:      uint(k.UserID),
+				AssistantID: assistant.ID,
+				Question:    p.Question,
+				Answer:      p.Answer,
+			}
+			ok, err := models.CreateKnowledgeFAQProposal(db, proposal, cluster.IDs())
+			if err != nil {
+				return created, err
+			}
+			existing = append(existing, p.Question)
+			if ok {
+				created++
+			}
+		}
+	}
+
+	if created > 0 {
+		content := fmt.Sprintf("%d frequently asked questions from recent calls are waiting for review in knowledge base %s.", created, k.KnowledgeName)
+		if err := notification.NewInternalNotificationService(db).Send(uint(k.UserID), "New FAQ proposals", content); err != nil {
+			logger.Warn("Failed to send knowledge FAQ notification", zap.Int("knowledgeId", k.ID), zap.Error(err))
+		}
+	}
+	return created, nil
+}
+
+// isKnownFAQ reports whether the question rewords an earlier proposal
+func isKnownFAQ(existing []string, question string) bool {
+	for _, q := range existing {
+		if faq.Similarity(q, question) >= knowledgeFAQDuplicate {
+			return true
+		}
+	}
+	return false
+}
+
+// assistantLLMCredential finds the LLM credential of the assistant's API key,
+// falling back to any LLM credential of its owner
+func assistantLLMCredential(db *gorm.DB, assistant *models.Assistant) (*models.UserCredential, error) {
+	var credential models.UserCredential
+	query := db.Where("llm_provider != ''")
+	if assistant.ApiKey != "" && assistant.ApiSecret != "" {
+		query = query.Where("api_key = ? AND api_secret = ?", assistant.ApiKey, assistant.ApiSecret)
+	} else {
+		query = query.Where("user_id = ?", assistant.UserID)
+	}
+	if err := query.First(&credential).Error; err != nil {
+		return nil, fmt.Errorf("no LLM credential for assistant %d: %w", assistant.ID, err)
+	}
+	return &credential, nil
+}
@@ -0,0 +1,29 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// StartKnowledgeSnapshotWorker starts the worker that exports queued knowledge
+// base snapshots to object storage and runs queued restores. run lives with the
+// handlers, which resolve the provider configuration of each knowledge base.
+func StartKnowledgeSnapshotWorker(run func(now time.Time)) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	// Jobs are picked up within seconds of being requested
+	schedule := "@every 10s"
+	if _, err := c.AddFunc(schedule, func() {
+		run(time.Now())
+	}); err != nil {
+		logger.Error("Failed to add knowledge snapshot cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Knowledge snapshot worker started", zap.String("schedule", schedule))
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// loginLocationBackfillDays days rolled up when the server starts, so the
+// heatmap is complete after a deployment or downtime
+const loginLocationBackfillDays = 30
+
+// StartLoginLocationRollup starts the job that aggregates login_histories into
+// per-day login counts by country and city for the security dashboard map
+func StartLoginLocationRollup(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	// Today's buckets are refreshed every 15 minutes, yesterday's are closed
+	// shortly after midnight to pick up logins recorded around it
+	schedule := "*/15 * * * *"
+	closeSchedule := "10 0 * * *"
+
+	if _, err := c.AddFunc(schedule, func() {
+		rollupLoginLocations(db, time.Now())
+	}); err != nil {
+		logger.Error("Failed to add login location rollup cron job", zap.Error(err))
+		return
+	}
+	if _, err := c.AddFunc(closeSchedule, func() {
+		rollupLoginLocations(db, time.Now().AddDate(0, 0, -1))
+	}); err != nil {
+		logger.Error("Failed to add login location rollup cron job", zap.Error(err))
+		return
+	}
+
+	go func() {
+		now := time.Now()
+		for i := loginLocationBackfillDays; i >= 0; i-- {
+			rollupLoginLocations(db, now.AddDate(0, 0, -i))
+		}
+	}()
+
+	c.Start()
+
+	logger.Info("Login location rollup started", zap.String("schedule", schedule))
+}
+
+func rollupLoginLocations(db *gorm.DB, day time.Time) {
+	if _, err := models.RollupLoginLocations(db, day); err != nil {
+		logger.Error("Login location rollup failed", zap.String("day", day.Format("2006-01-02")), zap.Error(err))
+	}
+}
@@ -0,0 +1,38 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StartMaintenanceWindowDispatcher starts the job that executes the device
+// actions queued outside a fleet's maintenance window once the window opens
+func StartMaintenanceWindowDispatcher(db *gorm.DB) {
+	c := cron.New()
+
+	// Maintenance windows start on minute boundaries
+	schedule := "* * * * *"
+
+	_, err := c.AddFunc(schedule, func() {
+		executed, err := models.DispatchDueDeviceActions(db, time.Now())
+		if err != nil {
+			logger.Error("Maintenance window dispatch failed", zap.Error(err))
+		}
+		if executed > 0 {
+			logger.Info("Queued device actions executed in maintenance window", zap.Int("count", executed))
+		}
+	})
+	if err != nil {
+		logger.Error("Failed to add maintenance window cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Maintenance window dispatcher started", zap.String("schedule", schedule))
+}
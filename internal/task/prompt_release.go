@@ -0,0 +1,29 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// StartPromptReleaseEvaluator starts the job that runs the canary analysis of
+// running assistant prompt releases. evaluate compares the arms and rolls back,
+// promotes or expires each release; it lives with the handlers, which also
+// invalidate the cached assistant lists.
+func StartPromptReleaseEvaluator(evaluate func(now time.Time)) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	schedule := "*/5 * * * *"
+	if _, err := c.AddFunc(schedule, func() {
+		evaluate(time.Now())
+	}); err != nil {
+		logger.Error("Failed to add prompt release evaluator cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Prompt release evaluator started", zap.String("schedule", schedule))
+}
@@ -0,0 +1,64 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/config"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StartRecordingDigestAnchor starts the daily job that chains the previous day's
+// recording hashes into a digest and anchors pending digests in the notary service
+func StartRecordingDigestAnchor(db *gorm.DB) {
+	c := cron.New()
+
+	// Execute at 00:30 every day, after the previous day is complete
+	schedule := "30 0 * * *"
+
+	_, err := c.AddFunc(schedule, func() {
+		date := time.Now().AddDate(0, 0, -1).Format(models.RecordingDigestDateLayout)
+		digest, err := models.CreateRecordingDigest(db, date)
+		if err != nil {
+			logger.Error("Recording digest task failed", zap.String("date", date), zap.Error(err))
+			return
+		}
+		logger.Info("Recording digest created",
+			zap.String("date", digest.Date),
+			zap.Int("recordings", digest.RecordingCount),
+			zap.String("digest", digest.Digest))
+
+		notary := config.GlobalConfig.Services.Notary
+		if notary.URL == "" {
+			return
+		}
+		// Retry digests whose anchoring failed on earlier days as well
+		pending, err := models.GetUnanchoredRecordingDigests(db)
+		if err != nil {
+			logger.Error("Failed to load unanchored recording digests", zap.Error(err))
+			return
+		}
+		for i := range pending {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			err := models.AnchorRecordingDigest(ctx, db, &pending[i], notary.URL, notary.Secret)
+			cancel()
+			if err != nil {
+				logger.Warn("Failed to anchor recording digest", zap.String("date", pending[i].Date), zap.Error(err))
+				continue
+			}
+			logger.Info("Recording digest anchored", zap.String("date", pending[i].Date))
+		}
+	})
+	if err != nil {
+		logger.Error("Failed to add recording digest cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Recording digest anchor started", zap.String("schedule", schedule))
+}
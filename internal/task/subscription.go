@@ -0,0 +1,36 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StartSubscriptionChecker starts the job that moves organizations whose plan
+// term ended into the grace period and, once that ended too, into read-only
+// mode. Each transition is recorded and published as a billing event
+func StartSubscriptionChecker(db *gorm.DB) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	schedule := "@every 1h"
+	if _, err := c.AddFunc(schedule, func() {
+		changed, err := models.AdvanceGroupSubscriptions(db, time.Now())
+		if err != nil {
+			logger.Error("Failed to advance subscriptions", zap.Error(err))
+		}
+		if changed > 0 {
+			logger.Info("Subscriptions downgraded", zap.Int("count", changed))
+		}
+	}); err != nil {
+		logger.Error("Failed to add subscription checker cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Subscription checker started", zap.String("schedule", schedule))
+}
@@ -0,0 +1,37 @@
+package task
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StartSyncTombstoneCleaner starts the daily cleanup of expired sync tombstones,
+// clients with an older cursor get a full sync instead
+func StartSyncTombstoneCleaner(db *gorm.DB) {
+	c := cron.New()
+
+	// Execute cleanup task at 3 AM every day
+	schedule := "0 3 * * *"
+
+	_, err := c.AddFunc(schedule, func() {
+		deleted, err := models.PruneSyncTombstones(db, time.Now().Add(-models.SyncTombstoneRetention))
+		if err != nil {
+			logger.Error("Sync tombstone cleaner task failed", zap.Error(err))
+			return
+		}
+		logger.Info("Sync tombstone cleaner task completed", zap.Int64("deleted", deleted))
+	})
+	if err != nil {
+		logger.Error("Failed to add sync tombstone cleaner cron job", zap.Error(err))
+		return
+	}
+
+	c.Start()
+
+	logger.Info("Sync tombstone cleaner started", zap.String("schedule", schedule))
+}
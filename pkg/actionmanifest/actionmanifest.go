@@ -0,0 +1,186 @@
+// Package actionmanifest signs and verifies device action manifests. Devices
+// never run arbitrary remote commands: the server issues a manifest naming one
+// of a fixed set of actions with its parameters, bound to a single device and
+// an expiry, signed with Ed25519. Devices keep a keyring of the published
+// verification keys, identified by key ID, so signing keys can be rotated while
+// manifests signed with the previous key are still honoured until it retires.
+package actionmanifest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Version of the manifest format
+const Version = 1
+
+// Algorithm of the manifest signatures
+const Algorithm = "Ed25519"
+
+var (
+	ErrUnknownAction = errors.New("unknown action")
+	ErrInvalidParams = errors.New("invalid action params")
+	ErrUnknownKey    = errors.New("unknown signing key")
+	ErrKeyRetired    = errors.New("signing key retired")
+	ErrBadSignature  = errors.New("invalid manifest signature")
+	ErrMalformed     = errors.New("malformed manifest")
+	ErrExpired       = errors.New("manifest expired")
+	ErrWrongDevice   = errors.New("manifest issued for another device")
+)
+
+// Manifest what the device is asked to do. IssuedAt and ExpiresAt are Unix seconds.
+type Manifest struct {
+	Version   int             `json:"v"`
+	ID        string          `json:"id"`
+	DeviceID  string          `json:"deviceId"`
+	Action    string          `json:"action"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	IssuedAt  int64           `json:"iat"`
+	ExpiresAt int64           `json:"exp"`
+	KeyID     string          `json:"kid"`
+}
+
+// Signed manifest as delivered to the device. The signature covers the exact
+// payload bytes, so devices verify before parsing and need no canonical JSON.
+type Signed struct {
+	KeyID     string `json:"kid"`
+	Payload   string `json:"payload"`   // base64url of the manifest JSON
+	Signature string `json:"signature"` // base64url Ed25519 signature of the payload bytes
+}
+
+// VerificationKey public key published to devices
+type VerificationKey struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	PublicKey string `json:"publicKey"`          // base64 of the 32 byte Ed25519 public key
+	NotAfter  int64  `json:"notAfter,omitempty"` // Unix seconds after which the key is no longer accepted, 0 while active
+}
+
+// NewManifestID random manifest identifier, used by devices to reject replays
+func NewManifestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "mf_" + hex.EncodeToString(b)
+}
+
+// GenerateKey creates a signing key with a random key ID
+func GenerateKey() (string, ed25519.PublicKey, ed25519.PrivateKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, nil, err
+	}
+	return hex.EncodeToString(id), pub, priv, nil
+}
+
+// Sign validates the manifest and signs it with the given key
+func Sign(m Manifest, keyID string, priv ed25519.PrivateKey) (*Signed, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key size %d", len(priv))
+	}
+	if _, err := Validate(m.Action, m.Params); err != nil {
+		return nil, err
+	}
+	if m.ID == "" || m.DeviceID == "" || m.ExpiresAt <= m.IssuedAt {
+		return nil, ErrMalformed
+	}
+	m.Version = Version
+	m.KeyID = keyID
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return &Signed{
+		KeyID:     keyID,
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+		Signature: base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, payload)),
+	}, nil
+}
+
+// Keyring verification keys known to a device
+type Keyring struct {
+	keys map[string]keyringEntry
+}
+
+type keyringEntry struct {
+	pub      ed25519.PublicKey
+	notAfter int64
+}
+
+// NewKeyring builds a keyring from published keys, skipping keys that cannot be decoded
+func NewKeyring(keys []VerificationKey) *Keyring {
+	k := &Keyring{keys: make(map[string]keyringEntry, len(keys))}
+	for _, key := range keys {
+		k.Add(key)
+	}
+	return k
+}
+
+// Add adds or replaces a key, reporting whether it was usable
+func (k *Keyring) Add(key VerificationKey) bool {
+	if key.Algorithm != "" && key.Algorithm != Algorithm {
+		return false
+	}
+	pub, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	k.keys[key.ID] = keyringEntry{pub: pub, notAfter: key.NotAfter}
+	return true
+}
+
+// Verify checks the signature, the key, the target device and the expiry and
+// returns the manifest. The caller still has to reject manifest IDs it has
+// already executed.
+func (k *Keyring) Verify(s *Signed, deviceID string, now time.Time) (*Manifest, error) {
+	if s == nil {
+		return nil, ErrMalformed
+	}
+	key, ok := k.keys[s.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if key.notAfter > 0 && now.Unix() > key.notAfter {
+		return nil, ErrKeyRetired
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(s.Payload)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s.Signature)
+	if err != nil || !ed25519.Verify(key.pub, payload, sig) {
+		return nil, ErrBadSignature
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, ErrMalformed
+	}
+	if m.Version != Version || m.KeyID != s.KeyID {
+		return nil, ErrMalformed
+	}
+	if m.DeviceID != deviceID {
+		return nil, ErrWrongDevice
+	}
+	if now.Unix() >= m.ExpiresAt {
+		return nil, ErrExpired
+	}
+	if _, err := Validate(m.Action, m.Params); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// PublicKeyString encodes a public key the way it is published
+func PublicKeyString(pub ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}
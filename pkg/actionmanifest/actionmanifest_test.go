@@ -0,0 +1,137 @@
+package actionmanifest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testManifest(now time.Time) Manifest {
+	return Manifest{
+		ID:        NewManifestID(),
+		DeviceID:  "aa:bb:cc:dd:ee:ff",
+		Action:    ActionSetVolume,
+		Params:    json.RawMessage(`{"volume": 40}`),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Now()
+	kid, pub, priv, err := GenerateKey()
+	require.NoError(t, err)
+	keyring := NewKeyring([]VerificationKey{{ID: kid, Algorithm: Algorithm, PublicKey: PublicKeyString(pub)}})
+
+	signed, err := Sign(testManifest(now), kid, priv)
+	require.NoError(t, err)
+	m, err := keyring.Verify(signed, "aa:bb:cc:dd:ee:ff", now)
+	require.NoError(t, err)
+	assert.Equal(t, ActionSetVolume, m.Action)
+	assert.Equal(t, Version, m.Version)
+	assert.Equal(t, kid, m.KeyID)
+
+	_, err = keyring.Verify(signed, "11:22:33:44:55:66", now)
+	assert.ErrorIs(t, err, ErrWrongDevice)
+	_, err = keyring.Verify(signed, "aa:bb:cc:dd:ee:ff", now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrExpired)
+
+	// A tampered payload fails the signature check
+	tampered := *signed
+	payload, _ := base64.RawURLEncoding.DecodeString(signed.Payload)
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(payload, &raw))
+	raw["action"] = ActionFactoryReset
+	payload, _ = json.Marshal(raw)
+	tampered.Payload = base64.RawURLEncoding.EncodeToString(payload)
+	_, err = keyring.Verify(&tampered, "aa:bb:cc:dd:ee:ff", now)
+	assert.ErrorIs(t, err, ErrBadSignature)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	now := time.Now()
+	oldID, oldPub, oldPriv, err := GenerateKey()
+	require.NoError(t, err)
+	newID, newPub, newPriv, err := GenerateKey()
+	require.NoError(t, err)
+	_, _, otherPriv, err := GenerateKey()
+	require.NoError(t, err)
+
+	keyring := NewKeyring([]VerificationKey{
+		{ID: newID, Algorithm: Algorithm, PublicKey: PublicKeyString(newPub)},
+		{ID: oldID, Algorithm: Algorithm, PublicKey: PublicKeyString(oldPub), NotAfter: now.Add(time.Minute).Unix()},
+		{ID: "broken", PublicKey: "not base64"},
+	})
+
+	fromOld, err := Sign(testManifest(now), oldID, oldPriv)
+	require.NoError(t, err)
+	fromNew, err := Sign(testManifest(now), newID, newPriv)
+	require.NoError(t, err)
+	_, err = keyring.Verify(fromOld, "aa:bb:cc:dd:ee:ff", now)
+	assert.NoError(t, err, "the previous key is honoured during the grace period")
+	_, err = keyring.Verify(fromNew, "aa:bb:cc:dd:ee:ff", now)
+	assert.NoError(t, err)
+
+	_, err = keyring.Verify(fromOld, "aa:bb:cc:dd:ee:ff", now.Add(2*time.Minute))
+	assert.ErrorIs(t, err, ErrKeyRetired)
+
+	// Signed with a key the device does not know under a known key ID
+	forged, err := Sign(testManifest(now), newID, otherPriv)
+	require.NoError(t, err)
+	_, err = keyring.Verify(forged, "aa:bb:cc:dd:ee:ff", now)
+	assert.ErrorIs(t, err, ErrBadSignature)
+
+	unknown, err := Sign(testManifest(now), "missing", otherPriv)
+	require.NoError(t, err)
+	_, err = keyring.Verify(unknown, "aa:bb:cc:dd:ee:ff", now)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.False(t, keyring.Add(VerificationKey{ID: "rsa", Algorithm: "RS256", PublicKey: PublicKeyString(newPub)}))
+}
+
+func TestValidate(t *testing.T) {
+	spec, err := Validate(ActionReboot, nil)
+	require.NoError(t, err)
+	assert.True(t, spec.Disruptive)
+
+	_, err = Validate("shell", json.RawMessage(`{"cmd": "rm -rf /"}`))
+	assert.ErrorIs(t, err, ErrUnknownAction)
+
+	cases := map[string]string{
+		ActionSetVolume:         `{"volume": 101}`,
+		ActionSetConfig:         `{"key": "wake_word"}`,
+		ActionFirmwareUpdate:    `{"version": "1.2.0", "url": "file:///etc/passwd", "sha256": "ab"}`,
+		ActionClearCache:        `{"extra": true}`,
+		ActionUploadDiagnostics: `["https://example.com"]`,
+	}
+	for action, params := range cases {
+		_, err := Validate(action, json.RawMessage(params))
+		assert.ErrorIs(t, err, ErrInvalidParams, action)
+	}
+
+	_, err = Validate(ActionFirmwareUpdate, json.RawMessage(`{"version": "1.2.0", "url": "https://cdn.example.com/fw.bin", "sha256": "ab"}`))
+	assert.NoError(t, err)
+	assert.Len(t, Specs(), 7)
+}
+
+func TestSignRejectsInvalidManifest(t *testing.T) {
+	now := time.Now()
+	kid, _, priv, err := GenerateKey()
+	require.NoError(t, err)
+
+	m := testManifest(now)
+	m.ExpiresAt = m.IssuedAt
+	_, err = Sign(m, kid, priv)
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	m = testManifest(now)
+	m.Action = "shell"
+	_, err = Sign(m, kid, priv)
+	assert.ErrorIs(t, err, ErrUnknownAction)
+
+	_, err = Sign(testManifest(now), kid, priv[:10])
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package actionmanifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// Actions a manifest may carry
+const (
+	ActionReboot            = "reboot"
+	ActionFactoryReset      = "factory_reset"
+	ActionFirmwareUpdate    = "firmware_update"
+	ActionSetVolume         = "set_volume"
+	ActionSetConfig         = "set_config"
+	ActionUploadDiagnostics = "upload_diagnostics"
+	ActionClearCache        = "clear_cache"
+)
+
+// Parameter types
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamURL    = "url"
+)
+
+// Param parameter of an action
+type Param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	Min      int    `json:"min,omitempty"` // Bounds of int parameters
+	Max      int    `json:"max,omitempty"`
+	MaxLen   int    `json:"maxLen,omitempty"` // Longest string value, 256 when 0
+}
+
+// Spec what an action accepts. Disruptive actions interrupt the device and wait
+// for the fleet's maintenance window.
+type Spec struct {
+	Action      string  `json:"action"`
+	Description string  `json:"description"`
+	Disruptive  bool    `json:"disruptive"`
+	Params      []Param `json:"params,omitempty"`
+}
+
+var specs = map[string]Spec{
+	ActionReboot: {
+		Action:      ActionReboot,
+		Description: "Restart the device",
+		Disruptive:  true,
+		Params:      []Param{{Name: "delaySeconds", Type: ParamInt, Min: 0, Max: 3600}},
+	},
+	ActionFactoryReset: {
+		Action:      ActionFactoryReset,
+		Description: "Erase settings and pairing and restart",
+		Disruptive:  true,
+	},
+	ActionFirmwareUpdate: {
+		Action:      ActionFirmwareUpdate,
+		Description: "Download and install a firmware image",
+		Disruptive:  true,
+		Params: []Param{
+			{Name: "version", Type: ParamString, Required: true, MaxLen: 64},
+			{Name: "url", Type: ParamURL, Required: true},
+			{Name: "sha256", Type: ParamString, Required: true, MaxLen: 64},
+		},
+	},
+	ActionSetVolume: {
+		Action:      ActionSetVolume,
+		Description: "Set the speaker volume",
+		Params:      []Param{{Name: "volume", Type: ParamInt, Required: true, Min: 0, Max: 100}},
+	},
+	ActionSetConfig: {
+		Action:      ActionSetConfig,
+		Description: "Change one device setting",
+		Params: []Param{
+			{Name: "key", Type: ParamString, Required: true, MaxLen: 64},
+			{Name: "value", Type: ParamString, Required: true, MaxLen: 1024},
+		},
+	},
+	ActionUploadDiagnostics: {
+		Action:      ActionUploadDiagnostics,
+		Description: "Upload logs, config snapshot and network info",
+		Params:      []Param{{Name: "uploadUrl", Type: ParamURL, Required: true}},
+	},
+	ActionClearCache: {
+		Action:      ActionClearCache,
+		Description: "Clear cached audio and assets",
+	},
+}
+
+// Specs every supported action, sorted by name
+func Specs() []Spec {
+	list := make([]Spec, 0, len(specs))
+	for _, s := range specs {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Action < list[j].Action })
+	return list
+}
+
+// Validate checks the action is supported and its params match the spec: a JSON
+// object with the required params, values of the declared types and no
+// unknown params. Empty params are allowed for actions without required params.
+func Validate(action string, params json.RawMessage) (Spec, error) {
+	spec, ok := specs[action]
+	if !ok {
+		return Spec{}, fmt.Errorf("%w %q", ErrUnknownAction, action)
+	}
+	values := map[string]json.RawMessage{}
+	if trimmed := bytes.TrimSpace(params); len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null")) {
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return spec, fmt.Errorf("%w: params must be a JSON object", ErrInvalidParams)
+		}
+	}
+
+	known := make(map[string]bool, len(spec.Params))
+	for _, p := range spec.Params {
+		known[p.Name] = true
+		raw, present := values[p.Name]
+		if !present {
+			if p.Required {
+				return spec, fmt.Errorf("%w: %s is required", ErrInvalidParams, p.Name)
+			}
+			continue
+		}
+		if err := p.check(raw); err != nil {
+			return spec, fmt.Errorf("%w: %s %v", ErrInvalidParams, p.Name, err)
+		}
+	}
+	for name := range values {
+		if !known[name] {
+			return spec, fmt.Errorf("%w: unknown param %s", ErrInvalidParams, name)
+		}
+	}
+	return spec, nil
+}
+
+func (p Param) check(raw json.RawMessage) error {
+	switch p.Type {
+	case ParamInt:
+		var v int
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		if v < p.Min || v > p.Max {
+			return fmt.Errorf("must be between %d and %d", p.Min, p.Max)
+		}
+	case ParamString, ParamURL:
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fmt.Errorf("must be a string")
+		}
+		maxLen := p.MaxLen
+		if maxLen == 0 {
+			maxLen = 256
+		}
+		if v == "" && p.Required {
+			return fmt.Errorf("must not be empty")
+		}
+		if len(v) > maxLen {
+			return fmt.Errorf("must be at most %d bytes", maxLen)
+		}
+		if p.Type == ParamURL {
+			if u, err := url.Parse(v); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return fmt.Errorf("must be an http(s) URL")
+			}
+		}
+	}
+	return nil
+}
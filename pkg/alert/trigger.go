@@ -1,16 +1,17 @@
 package alert
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"strings"
 	"time"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
 	"github.com/code-100-precent/LingEcho/pkg/config"
 	"github.com/code-100-precent/LingEcho/pkg/logger"
 	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -207,6 +208,16 @@ func (s *TriggerService) sendNotifications(alert *models.Alert, rule *models.Ale
 		notificationRecord.AlertID = alert.ID
 		notificationRecord.Channel = channel
 
+		critical := alert.Severity == models.AlertSeverityCritical
+		if ok, reason := models.NotificationAllowed(s.db, user, models.NotificationEventAlert, channel, critical); !ok {
+			now := time.Now()
+			notificationRecord.Status = "suppressed"
+			notificationRecord.Message = reason
+			notificationRecord.SentAt = &now
+			s.db.Create(&notificationRecord)
+			continue
+		}
+
 		var err error
 		switch channel {
 		case models.NotificationChannelEmail:
@@ -236,6 +247,20 @@ func (s *TriggerService) sendNotifications(alert *models.Alert, rule *models.Ale
 				notificationRecord.Message = err.Error()
 			}
 
+		case models.NotificationChannelPush:
+			err = models.SendPushNotification(s.db, user.ID, notification.PushMessage{
+				Title:    alert.Title,
+				Body:     alert.Message,
+				Critical: critical,
+				Data:     map[string]string{"event": string(models.NotificationEventAlert), "alertId": fmt.Sprint(alert.ID)},
+			})
+			if err == nil {
+				notificationRecord.Status = "success"
+			} else {
+				notificationRecord.Status = "failed"
+				notificationRecord.Message = err.Error()
+			}
+
 		case models.NotificationChannelSMS:
 			// 短信通知（预留）
 			notificationRecord.Status = "failed"
@@ -324,37 +349,15 @@ func (s *TriggerService) sendWebhookNotification(alert *models.Alert, rule *mode
 		}
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
-	// 发送HTTP请求
-	method := rule.WebhookMethod
-	if method == "" {
-		method = "POST"
-	}
-
-	req, err := http.NewRequest(method, rule.WebhookURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "LingEcho-Alert/1.0")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Webhook返回错误状态码: %d", resp.StatusCode)
-	}
-
-	return nil
+	// Webhook 地址由用户配置，只投递到公网地址
+	_, err := webhook.DeliverPublic(context.Background(), webhook.Request{
+		URL:       rule.WebhookURL,
+		Method:    rule.WebhookMethod,
+		Event:     "alert." + string(alert.AlertType),
+		UserAgent: "LingEcho-Alert/1.0",
+		Payload:   payload,
+	})
+	return err
 }
 
 // getUser 获取用户信息
@@ -518,3 +521,42 @@ func (s *TriggerService) TriggerServiceErrorAlert(userID uint, serviceName strin
 
 	return s.TriggerAlert(userID, models.AlertTypeServiceError, severity, title, message, data)
 }
+
+// TriggerGeofenceAlert 触发设备越界告警
+func (s *TriggerService) TriggerGeofenceAlert(userID uint, deviceID, deviceName string, latitude, longitude float64, fences []string) error {
+	data := map[string]interface{}{
+		"deviceId":  deviceID,
+		"latitude":  latitude,
+		"longitude": longitude,
+		"geofences": fences,
+	}
+
+	if deviceName == "" {
+		deviceName = deviceID
+	}
+	title := fmt.Sprintf("设备越界告警 - %s", deviceName)
+	message := fmt.Sprintf("设备%s上报的位置(%.5f, %.5f)不在任何允许的地理围栏内", deviceName, latitude, longitude)
+
+	return s.TriggerAlert(userID, models.AlertTypeGeofence, models.AlertSeverityHigh, title, message, data)
+}
+
+// TriggerAudioQualityAlert 触发设备音频质量告警，提示更换麦克风
+func (s *TriggerService) TriggerAudioQualityAlert(userID uint, deviceID, deviceName string, score, threshold int, issues []string) error {
+	data := map[string]interface{}{
+		"deviceId":  deviceID,
+		"score":     score,
+		"threshold": threshold,
+		"issues":    issues,
+	}
+
+	if deviceName == "" {
+		deviceName = deviceID
+	}
+	title := fmt.Sprintf("设备音频质量告警 - %s", deviceName)
+	message := fmt.Sprintf("设备%s的麦克风测试得分%d，低于阈值%d，建议更换设备", deviceName, score, threshold)
+	if len(issues) > 0 {
+		message += fmt.Sprintf("（问题：%s）", strings.Join(issues, ", "))
+	}
+
+	return s.TriggerAlert(userID, models.AlertTypeAudioQuality, models.AlertSeverityMedium, title, message, data)
+}
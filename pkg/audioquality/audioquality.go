@@ -0,0 +1,300 @@
+// Package audioquality scores a short microphone test recording. It measures
+// the noise floor, clipping and the frequency response across the speech bands
+// and combines them into a 0-100 quality score, so devices with failing
+// microphones can be found before users complain.
+package audioquality
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/code-100-precent/LingEcho/pkg/dsp"
+)
+
+const (
+	// MinDuration shortest recording that gives a stable noise floor
+	MinDuration = 1.0
+	// MaxDuration longer recordings are analyzed up to this many seconds
+	MaxDuration = 30.0
+
+	frameMs  = 20
+	fftSize  = 1024
+	fullDBFS = 32768.0
+	floorDB  = -96.0 // level of digital silence
+)
+
+// Issues found in a recording
+const (
+	IssueTooShort  = "too_short"
+	IssueNoSignal  = "no_signal"
+	IssueClipping  = "clipping"
+	IssueHighNoise = "high_noise"
+	IssueLowSNR    = "low_snr"
+	IssueWeakBand  = "weak_band"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format, expected 16-bit PCM WAV")
+	ErrEmpty             = errors.New("recording has no samples")
+)
+
+// Band level of one frequency band relative to the strongest speech band
+type Band struct {
+	Name    string  `json:"name"`
+	LowHz   float64 `json:"lowHz"`
+	HighHz  float64 `json:"highHz"`
+	LevelDB float64 `json:"levelDb"` // 0 for the strongest band, negative below it
+}
+
+// Report analysis of a test recording
+type Report struct {
+	SampleRate     int      `json:"sampleRate"`
+	Duration       float64  `json:"duration"` // seconds
+	PeakDBFS       float64  `json:"peakDbfs"`
+	RMSDBFS        float64  `json:"rmsDbfs"`
+	NoiseFloorDBFS float64  `json:"noiseFloorDbfs"` // level of the quietest frames
+	SignalDBFS     float64  `json:"signalDbfs"`     // level of the loudest frames
+	SNRDB          float64  `json:"snrDb"`
+	ClippingRatio  float64  `json:"clippingRatio"` // share of samples at full scale
+	Bands          []Band   `json:"bands"`
+	Score          int      `json:"score"` // 0-100
+	Issues         []string `json:"issues,omitempty"`
+}
+
+// speechBands bands checked for the frequency response; bands above Nyquist are skipped
+var speechBands = []Band{
+	{Name: "low", LowHz: 100, HighHz: 300},
+	{Name: "mid", LowHz: 300, HighHz: 1000},
+	{Name: "upper_mid", LowHz: 1000, HighHz: 3000},
+	{Name: "high", LowHz: 3000, HighHz: 7000},
+}
+
+// DecodeWAV reads a 16-bit PCM WAV file and returns the samples of the first channel
+func DecodeWAV(data []byte) ([]int16, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, ErrUnsupportedFormat
+	}
+	var (
+		channels   int
+		sampleRate int
+		bits       int
+		pcm        []byte
+	)
+	for off := 12; off+8 <= len(data); {
+		id := string(data[off : off+4])
+		size := int(binary.LittleEndian.Uint32(data[off+4 : off+8]))
+		body := data[off+8:]
+		if size > len(body) {
+			size = len(body) // truncated uploads still carry usable samples
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 || binary.LittleEndian.Uint16(body[0:2]) != 1 {
+				return nil, 0, ErrUnsupportedFormat
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bits = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			pcm = body[:size]
+		}
+		off += 8 + size + size%2
+	}
+	if bits != 16 || channels < 1 || sampleRate <= 0 {
+		return nil, 0, ErrUnsupportedFormat
+	}
+	frame := 2 * channels
+	samples := make([]int16, len(pcm)/frame)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*frame:]))
+	}
+	return samples, sampleRate, nil
+}
+
+// DecodePCM reads raw 16-bit little-endian mono PCM
+func DecodePCM(data []byte) []int16 {
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return samples
+}
+
+// Analyze measures the recording and scores it
+func Analyze(samples []int16, sampleRate int) (*Report, error) {
+	if len(samples) == 0 || sampleRate <= 0 {
+		return nil, ErrEmpty
+	}
+	if max := int(MaxDuration * float64(sampleRate)); len(samples) > max {
+		samples = samples[:max]
+	}
+	r := &Report{SampleRate: sampleRate, Duration: float64(len(samples)) / float64(sampleRate)}
+
+	var peak, clipped int
+	var sum float64
+	for _, s := range samples {
+		v := int(s)
+		if v < 0 {
+			v = -v
+		}
+		if v > peak {
+			peak = v
+		}
+		if v >= math.MaxInt16 {
+			clipped++
+		}
+		sum += float64(s) * float64(s)
+	}
+	r.PeakDBFS = dbfs(float64(peak))
+	r.RMSDBFS = dbfs(math.Sqrt(sum / float64(len(samples))))
+	r.ClippingRatio = float64(clipped) / float64(len(samples))
+
+	levels := frameLevels(samples, sampleRate*frameMs/1000)
+	sort.Float64s(levels)
+	r.NoiseFloorDBFS = percentile(levels, 0.10)
+	r.SignalDBFS = percentile(levels, 0.90)
+	r.SNRDB = r.SignalDBFS - r.NoiseFloorDBFS
+	r.Bands = bandLevels(samples, sampleRate)
+
+	score(r)
+	return r, nil
+}
+
+// score combines the measurements into Score and Issues
+func score(r *Report) {
+	if r.Duration < MinDuration {
+		r.Issues = append(r.Issues, IssueTooShort)
+	}
+	// A dead or muted microphone only records digital noise
+	if r.PeakDBFS < -50 {
+		r.Issues = append(r.Issues, IssueNoSignal)
+		r.Score = 0
+		return
+	}
+
+	penalty := 0.0
+	if r.ClippingRatio > 0.001 {
+		r.Issues = append(r.Issues, IssueClipping)
+		penalty += math.Min(40, r.ClippingRatio*2000)
+	}
+	if r.NoiseFloorDBFS > -50 {
+		r.Issues = append(r.Issues, IssueHighNoise)
+		penalty += math.Min(40, (r.NoiseFloorDBFS+50)*2)
+	}
+	if r.SNRDB < 20 {
+		r.Issues = append(r.Issues, IssueLowSNR)
+		penalty += math.Min(30, (20-r.SNRDB)*1.5)
+	}
+	weak := false
+	for _, b := range r.Bands {
+		if b.LevelDB < -30 {
+			weak = true
+			penalty += 15
+		}
+	}
+	if weak {
+		r.Issues = append(r.Issues, IssueWeakBand)
+	}
+	if r.Duration < MinDuration {
+		penalty += 10
+	}
+	r.Score = int(math.Round(math.Max(0, 100-penalty)))
+}
+
+// frameLevels RMS level of each frame in dBFS
+func frameLevels(samples []int16, frame int) []float64 {
+	if frame <= 0 {
+		frame = len(samples)
+	}
+	var levels []float64
+	for start := 0; start < len(samples); start += frame {
+		end := start + frame
+		if end > len(samples) {
+			if len(levels) > 0 {
+				break // a short tail frame would skew the noise floor
+			}
+			end = len(samples)
+		}
+		var sum float64
+		for _, s := range samples[start:end] {
+			sum += float64(s) * float64(s)
+		}
+		levels = append(levels, dbfs(math.Sqrt(sum/float64(end-start))))
+	}
+	return levels
+}
+
+// bandLevels averages the power spectrum over Hann-windowed frames (Welch) and
+// reports each speech band relative to the strongest one
+func bandLevels(samples []int16, sampleRate int) []Band {
+	power := make([]float64, fftSize/2)
+	window := dsp.HannWindow(fftSize)
+	buf := make([]complex128, fftSize)
+	frames := 0
+	for start := 0; start+fftSize <= len(samples); start += fftSize / 2 {
+		for i := range buf {
+			buf[i] = complex(float64(samples[start+i])*window[i], 0)
+		}
+		dsp.FFT(buf)
+		for i := range power {
+			a := cmplx.Abs(buf[i])
+			power[i] += a * a
+		}
+		frames++
+	}
+	if frames == 0 {
+		return nil
+	}
+
+	binHz := float64(sampleRate) / fftSize
+	nyquist := float64(sampleRate) / 2
+	var bands []Band
+	var levels []float64
+	strongest := math.Inf(-1)
+	for _, b := range speechBands {
+		if b.HighHz > nyquist {
+			if b.LowHz >= nyquist*0.9 {
+				continue
+			}
+			b.HighHz = nyquist * 0.9
+		}
+		var sum float64
+		n := 0
+		for i := int(math.Ceil(b.LowHz / binHz)); i < len(power) && float64(i)*binHz < b.HighHz; i++ {
+			sum += power[i]
+			n++
+		}
+		level := floorDB
+		if n > 0 && sum > 0 {
+			level = 10 * math.Log10(sum/float64(n))
+		}
+		bands = append(bands, b)
+		levels = append(levels, level)
+		strongest = math.Max(strongest, level)
+	}
+	for i := range bands {
+		bands[i].LevelDB = round1(levels[i] - strongest)
+	}
+	return bands
+}
+
+func dbfs(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return floorDB
+	}
+	return round1(math.Max(floorDB, 20*math.Log10(amplitude/fullDBFS)))
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return floorDB
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,96 @@
+package audioquality
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rate = 16000
+
+// tones synthesizes 2s of the frequencies gated on and off like speech, over quiet noise
+func tones(amplitude float64, noise float64, freqs ...float64) []int16 {
+	rng := rand.New(rand.NewSource(1))
+	out := make([]int16, 2*rate)
+	for i := range out {
+		t := float64(i) / rate
+		v := rng.NormFloat64() * noise
+		if (i/(rate/4))%2 == 0 {
+			for _, f := range freqs {
+				v += amplitude * math.Sin(2*math.Pi*f*t)
+			}
+		}
+		v = math.Max(math.MinInt16, math.Min(math.MaxInt16, v))
+		out[i] = int16(v)
+	}
+	return out
+}
+
+func wav(samples []int16) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(samples)*2))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), uint32(rate), uint32(rate * 2), uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(samples)*2))
+	binary.Write(&b, binary.LittleEndian, samples)
+	return b.Bytes()
+}
+
+func TestDecodeWAV(t *testing.T) {
+	in := tones(3000, 10, 440)
+	samples, sampleRate, err := DecodeWAV(wav(in))
+	require.NoError(t, err)
+	assert.Equal(t, rate, sampleRate)
+	assert.Equal(t, in, samples)
+
+	_, _, err = DecodeWAV([]byte("not a wav file"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.Equal(t, in[:10], DecodePCM(wav(in)[44:64]))
+}
+
+func TestAnalyze(t *testing.T) {
+	good, err := Analyze(tones(2500, 5, 200, 600, 2000, 5000), rate)
+	require.NoError(t, err)
+	assert.Empty(t, good.Issues)
+	assert.GreaterOrEqual(t, good.Score, 90)
+	assert.Less(t, good.NoiseFloorDBFS, -60.0)
+	assert.Len(t, good.Bands, 4)
+
+	silent, err := Analyze(make([]int16, rate*2), rate)
+	require.NoError(t, err)
+	assert.Equal(t, 0, silent.Score)
+	assert.Contains(t, silent.Issues, IssueNoSignal)
+
+	clipped, err := Analyze(tones(20000, 5, 200, 600, 2000, 5000), rate)
+	require.NoError(t, err)
+	assert.Contains(t, clipped.Issues, IssueClipping)
+	assert.Less(t, clipped.Score, good.Score)
+
+	noisy, err := Analyze(tones(2500, 1500, 200, 600, 2000, 5000), rate)
+	require.NoError(t, err)
+	assert.Contains(t, noisy.Issues, IssueHighNoise)
+	assert.Contains(t, noisy.Issues, IssueLowSNR)
+	assert.Less(t, noisy.Score, 60)
+
+	// A muffled microphone loses the upper bands
+	muffled, err := Analyze(tones(2500, 0, 200, 600), rate)
+	require.NoError(t, err)
+	assert.Contains(t, muffled.Issues, IssueWeakBand)
+	assert.Less(t, muffled.Score, good.Score)
+
+	short, err := Analyze(tones(2500, 5, 200, 600, 2000, 5000)[:rate/2], rate)
+	require.NoError(t, err)
+	assert.Contains(t, short.Issues, IssueTooShort)
+
+	_, err = Analyze(nil, rate)
+	assert.ErrorIs(t, err, ErrEmpty)
+}
@@ -0,0 +1,232 @@
+package calendar
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
+	"github.com/google/uuid"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// CalDAVProvider CalDAV (RFC 4791) provider. URL points to a calendar collection.
+// Invitations rely on server-side scheduling (RFC 6638): servers that support it
+// deliver iTIP requests to the ATTENDEE addresses of a stored event.
+type CalDAVProvider struct {
+	URL      string
+	Username string
+	Password string
+	client   *http.Client
+}
+
+// NewCalDAVProvider creates a CalDAV provider using basic authentication. The
+// collection URL is user supplied, so the client only connects to public addresses;
+// check it with webhook.ValidateURL before saving.
+func NewCalDAVProvider(collectionURL, username, password string) *CalDAVProvider {
+	if !strings.HasSuffix(collectionURL, "/") {
+		collectionURL += "/"
+	}
+	return &CalDAVProvider{
+		URL:      collectionURL,
+		Username: username,
+		Password: password,
+		client:   xhttp.NewPublicClient("caldav", 15*time.Second),
+	}
+}
+
+// FreeBusy lists events in the range with a calendar-query REPORT
+func (p *CalDAVProvider) FreeBusy(ctx context.Context, start, end time.Time) ([]Period, error) {
+	if !end.After(start) {
+		return nil, ErrInvalidRange
+	}
+	query := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`, start.UTC().Format(icsTimeFormat), end.UTC().Format(icsTimeFormat))
+
+	req, err := http.NewRequestWithContext(ctx, "REPORT", p.URL, strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	req.SetBasicAuth(p.Username, p.Password)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("caldav: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("caldav: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var ms struct {
+		Responses []struct {
+			CalendarData string `xml:"propstat>prop>calendar-data"`
+		} `xml:"response"`
+	}
+	if err := xml.Unmarshal(body, &ms); err != nil {
+		return nil, fmt.Errorf("caldav: parse multistatus: %w", err)
+	}
+
+	var busy []Period
+	for _, r := range ms.Responses {
+		for _, period := range parseICSEvents(r.CalendarData) {
+			if period.Start.Before(end) && period.End.After(start) {
+				busy = append(busy, period)
+			}
+		}
+	}
+	return busy, nil
+}
+
+// CreateEvent stores the event as a new calendar object resource
+func (p *CalDAVProvider) CreateEvent(ctx context.Context, event Event, sendInvites bool) (*Event, error) {
+	if !event.End.After(event.Start) {
+		return nil, ErrInvalidRange
+	}
+	created := event
+	created.ID = uuid.NewString()
+	if !sendInvites {
+		created.Attendees = nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.URL+created.ID+".ics", strings.NewReader(BuildICS(created, p.Username)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	req.Header.Set("If-None-Match", "*")
+	req.SetBasicAuth(p.Username, p.Password)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("caldav: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("caldav: status %d: %s", resp.StatusCode, string(body))
+	}
+	created.URL = p.URL + created.ID + ".ics"
+	return &created, nil
+}
+
+// BuildICS renders an event as an iCalendar object. organizer is used as
+// ORGANIZER when it is an email address.
+func BuildICS(event Event, organizer string) string {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(s + "\r\n") }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//LingEcho//Assistant Calendar//EN")
+	line("BEGIN:VEVENT")
+	line("UID:" + event.ID)
+	line("DTSTAMP:" + time.Now().UTC().Format(icsTimeFormat))
+	line("DTSTART:" + event.Start.UTC().Format(icsTimeFormat))
+	line("DTEND:" + event.End.UTC().Format(icsTimeFormat))
+	line("SUMMARY:" + escapeICS(event.Title))
+	if event.Description != "" {
+		line("DESCRIPTION:" + escapeICS(event.Description))
+	}
+	if event.Location != "" {
+		line("LOCATION:" + escapeICS(event.Location))
+	}
+	if strings.Contains(organizer, "@") {
+		line("ORGANIZER:mailto:" + organizer)
+	}
+	for _, attendee := range event.Attendees {
+		line("ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:" + attendee)
+	}
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+func escapeICS(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// parseICSEvents extracts DTSTART/DTEND of the VEVENTs in an iCalendar object.
+// Transparent events do not block time.
+func parseICSEvents(data string) []Period {
+	var periods []Period
+	var current *Period
+	var transparent bool
+
+	scanner := bufio.NewScanner(strings.NewReader(unfoldICS(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "BEGIN:VEVENT":
+			current = &Period{}
+			transparent = false
+		case line == "END:VEVENT":
+			if current != nil && !transparent && !current.Start.IsZero() {
+				if current.End.IsZero() {
+					current.End = current.Start
+				}
+				periods = append(periods, *current)
+			}
+			current = nil
+		case current == nil:
+		case strings.HasPrefix(line, "DTSTART"):
+			current.Start = parseICSTime(line)
+		case strings.HasPrefix(line, "DTEND"):
+			current.End = parseICSTime(line)
+		case strings.HasPrefix(line, "TRANSP:"):
+			transparent = strings.TrimPrefix(line, "TRANSP:") == "TRANSPARENT"
+		}
+	}
+	return periods
+}
+
+// unfoldICS joins continuation lines (RFC 5545 section 3.1)
+func unfoldICS(data string) string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	return strings.ReplaceAll(data, "\n\t", "")
+}
+
+// parseICSTime parses DTSTART/DTEND values in UTC, TZID or all-day form
+func parseICSTime(line string) time.Time {
+	idx := strings.Index(line, ":")
+	if idx < 0 {
+		return time.Time{}
+	}
+	params, value := line[:idx], line[idx+1:]
+
+	loc := time.UTC
+	for _, param := range strings.Split(params, ";")[1:] {
+		if strings.HasPrefix(param, "TZID=") {
+			if l, err := time.LoadLocation(strings.Trim(strings.TrimPrefix(param, "TZID="), `"`)); err == nil {
+				loc = l
+			}
+		}
+	}
+
+	for _, layout := range []string{icsTimeFormat, "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,90 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	ProviderGoogle = "google"
+	ProviderCalDAV = "caldav"
+)
+
+// Scopes an assistant can be granted on a calendar
+const (
+	ScopeAvailability = "availability" // Read free/busy information
+	ScopeCreateEvent  = "create_event" // Create events on the calendar
+	ScopeSendInvite   = "send_invite"  // Add attendees and notify them
+)
+
+var (
+	ErrInvalidRange = errors.New("calendar: end must be after start")
+	ErrNotFound     = errors.New("calendar: not found")
+)
+
+// Event calendar event
+type Event struct {
+	ID          string    `json:"id,omitempty"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Attendees   []string  `json:"attendees,omitempty"` // Attendee email addresses
+	URL         string    `json:"url,omitempty"`       // Link to the event in the provider UI
+}
+
+// Period busy or free time range
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Provider calendar backend
+type Provider interface {
+	// FreeBusy returns busy periods between start and end
+	FreeBusy(ctx context.Context, start, end time.Time) ([]Period, error)
+	// CreateEvent creates an event, sendInvites notifies the attendees
+	CreateEvent(ctx context.Context, event Event, sendInvites bool) (*Event, error)
+}
+
+// FreeSlots returns the free periods of at least minDuration between start and end
+func FreeSlots(busy []Period, start, end time.Time, minDuration time.Duration) []Period {
+	if !end.After(start) {
+		return nil
+	}
+	sorted := make([]Period, len(busy))
+	copy(sorted, busy)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var free []Period
+	cursor := start
+	for _, b := range sorted {
+		if !b.End.After(cursor) {
+			continue
+		}
+		if b.Start.After(end) {
+			break
+		}
+		if b.Start.Sub(cursor) >= minDuration && b.Start.After(cursor) {
+			free = append(free, Period{Start: cursor, End: b.Start})
+		}
+		cursor = b.End
+	}
+	if end.Sub(cursor) >= minDuration && end.After(cursor) {
+		free = append(free, Period{Start: cursor, End: end})
+	}
+	return free
+}
+
+// IsFree reports whether no busy period overlaps [start, end)
+func IsFree(busy []Period, start, end time.Time) bool {
+	for _, b := range busy {
+		if b.Start.Before(end) && b.End.After(start) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2024, 6, 3, hour, minute, 0, 0, time.UTC)
+}
+
+func TestFreeSlots(t *testing.T) {
+	busy := []Period{
+		{Start: at(13, 0), End: at(14, 0)},
+		{Start: at(9, 30), End: at(10, 0)},
+		{Start: at(9, 45), End: at(11, 0)}, // overlaps previous
+	}
+
+	free := FreeSlots(busy, at(9, 0), at(17, 0), 30*time.Minute)
+	assert.Equal(t, []Period{
+		{Start: at(9, 0), End: at(9, 30)},
+		{Start: at(11, 0), End: at(13, 0)},
+		{Start: at(14, 0), End: at(17, 0)},
+	}, free)
+
+	assert.Len(t, FreeSlots(busy, at(9, 0), at(17, 0), 3*time.Hour+time.Minute), 0)
+	assert.Nil(t, FreeSlots(nil, at(10, 0), at(9, 0), time.Minute))
+
+	assert.True(t, IsFree(busy, at(11, 0), at(12, 0)))
+	assert.False(t, IsFree(busy, at(12, 30), at(13, 30)))
+}
+
+func TestGoogleProvider(t *testing.T) {
+	var created map[string]interface{}
+	var sendUpdates string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/freeBusy":
+			_, _ = w.Write([]byte(`{"calendars":{"primary":{"busy":[{"start":"2024-06-03T13:00:00Z","end":"2024-06-03T14:00:00Z"}]}}}`))
+		case "/calendars/primary/events":
+			sendUpdates = r.URL.Query().Get("sendUpdates")
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			_, _ = w.Write([]byte(`{"id":"evt1","htmlLink":"https://calendar.google.com/evt1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewGoogleProviderWithClient(server.Client(), server.URL, "")
+
+	busy, err := p.FreeBusy(context.Background(), at(9, 0), at(17, 0))
+	require.NoError(t, err)
+	require.Len(t, busy, 1)
+	assert.True(t, busy[0].Start.Equal(at(13, 0)))
+
+	event, err := p.CreateEvent(context.Background(), Event{
+		Title: "Demo", Start: at(15, 0), End: at(15, 30), Attendees: []string{"guest@example.com"},
+	}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "evt1", event.ID)
+	assert.Equal(t, "all", sendUpdates)
+	assert.Equal(t, "Demo", created["summary"])
+	assert.Len(t, created["attendees"], 1)
+
+	_, err = p.CreateEvent(context.Background(), Event{Title: "bad", Start: at(15, 0), End: at(14, 0)}, false)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestGoogleProvider_RefreshesToken(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"fresh","token_type":"Bearer","expires_in":3600,"refresh_token":"r1"}`))
+	}))
+	defer tokenServer.Close()
+
+	var authHeader string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"calendars":{"primary":{"busy":[]}}}`))
+	}))
+	defer api.Close()
+
+	cfg := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}}
+	expired := &oauth2.Token{AccessToken: "stale", RefreshToken: "r1", Expiry: time.Now().Add(-time.Hour)}
+
+	var refreshed *oauth2.Token
+	p := NewGoogleProvider(context.Background(), cfg, expired, "", func(tok *oauth2.Token) { refreshed = tok })
+	p.BaseURL = api.URL
+
+	_, err := p.FreeBusy(context.Background(), at(9, 0), at(10, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer fresh", authHeader)
+	require.NotNil(t, refreshed)
+	assert.Equal(t, "fresh", refreshed.AccessToken)
+}
+
+func TestCalDAVProvider(t *testing.T) {
+	var putBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "alice@example.com" || pass != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "REPORT":
+			w.WriteHeader(http.StatusMultiStatus)
+			_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+ <d:response><d:href>/cal/a.ics</d:href><d:propstat><d:prop><c:calendar-data>BEGIN:VCALENDAR
+BEGIN:VEVENT
+DTSTART:20240603T130000Z
+DTEND:20240603T140000Z
+END:VEVENT
+BEGIN:VEVENT
+DTSTART;TZID=Europe/Berlin:20240603T170000
+DTEND;TZID=Europe/Berlin:20240603T180000
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240603T100000Z
+DTEND:20240603T110000Z
+TRANSP:TRANSPARENT
+END:VEVENT
+END:VCALENDAR
+</c:calendar-data></d:prop></d:propstat></d:response>
+</d:multistatus>`))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			putBody = string(body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	p := NewCalDAVProvider(server.URL+"/cal", "alice@example.com", "pw")
+	busy, err := p.FreeBusy(context.Background(), at(0, 0), at(23, 0))
+	assert.Empty(t, busy)
+	assert.ErrorIs(t, err, xhttp.ErrNonPublicAddress, "loopback servers are refused")
+	// httptest listens on loopback
+	p.client = xhttp.NewClient("caldav-test", 15*time.Second)
+
+	busy, err = p.FreeBusy(context.Background(), at(0, 0), at(23, 0))
+	require.NoError(t, err)
+	require.Len(t, busy, 2)
+	assert.True(t, busy[0].Start.Equal(at(13, 0)))
+	assert.True(t, busy[1].Start.Equal(at(15, 0)), "TZID times are converted, got %s", busy[1].Start.UTC())
+
+	event, err := p.CreateEvent(context.Background(), Event{
+		Title: "Call, follow-up", Start: at(15, 0), End: at(15, 30), Attendees: []string{"bob@example.com"},
+	}, true)
+	require.NoError(t, err)
+	assert.NotEmpty(t, event.ID)
+	assert.True(t, strings.HasSuffix(event.URL, event.ID+".ics"))
+	assert.Contains(t, putBody, "SUMMARY:Call\\, follow-up")
+	assert.Contains(t, putBody, "ORGANIZER:mailto:alice@example.com")
+	assert.Contains(t, putBody, "RSVP=TRUE:mailto:bob@example.com")
+	assert.Contains(t, putBody, "DTSTART:20240603T150000Z")
+}
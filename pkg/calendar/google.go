@@ -0,0 +1,205 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	GoogleAPIBaseURL = "https://www.googleapis.com/calendar/v3"
+
+	googleScopeEvents   = "https://www.googleapis.com/auth/calendar.events"
+	googleScopeFreeBusy = "https://www.googleapis.com/auth/calendar.freebusy"
+	googleScopeEmail    = "email"
+)
+
+// GoogleEndpoint Google OAuth2 endpoints
+var GoogleEndpoint = oauth2.Endpoint{
+	AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+	TokenURL: "https://oauth2.googleapis.com/token",
+}
+
+// GoogleOAuthConfig builds the OAuth2 config used to connect Google calendars
+func GoogleOAuthConfig(clientID, clientSecret, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     GoogleEndpoint,
+		Scopes:       []string{googleScopeEvents, googleScopeFreeBusy, googleScopeEmail},
+	}
+}
+
+// GoogleAuthURL returns the consent URL. Offline access with forced consent
+// makes Google return a refresh token on every connect.
+func GoogleAuthURL(cfg *oauth2.Config, state string) string {
+	return cfg.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+}
+
+// TokenRefreshFunc is called with the new token after an automatic refresh
+type TokenRefreshFunc func(*oauth2.Token)
+
+// GoogleProvider Google Calendar API v3 provider
+type GoogleProvider struct {
+	BaseURL    string
+	CalendarID string // Defaults to "primary"
+	client     *http.Client
+}
+
+// NewGoogleProvider creates a provider that refreshes the access token when it
+// expires and reports refreshed tokens to onRefresh so they can be persisted
+func NewGoogleProvider(ctx context.Context, cfg *oauth2.Config, token *oauth2.Token, calendarID string, onRefresh TokenRefreshFunc) *GoogleProvider {
+	source := &notifyingTokenSource{
+		base:      cfg.TokenSource(ctx, token),
+		last:      token.AccessToken,
+		onRefresh: onRefresh,
+	}
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	return &GoogleProvider{
+		BaseURL:    GoogleAPIBaseURL,
+		CalendarID: calendarID,
+		client:     oauth2.NewClient(ctx, source),
+	}
+}
+
+// NewGoogleProviderWithClient creates a provider on an already authorized HTTP client
+func NewGoogleProviderWithClient(client *http.Client, baseURL, calendarID string) *GoogleProvider {
+	if baseURL == "" {
+		baseURL = GoogleAPIBaseURL
+	}
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	return &GoogleProvider{BaseURL: baseURL, CalendarID: calendarID, client: client}
+}
+
+// FreeBusy queries the freeBusy endpoint for the calendar
+func (g *GoogleProvider) FreeBusy(ctx context.Context, start, end time.Time) ([]Period, error) {
+	if !end.After(start) {
+		return nil, ErrInvalidRange
+	}
+	req := map[string]interface{}{
+		"timeMin": start.Format(time.RFC3339),
+		"timeMax": end.Format(time.RFC3339),
+		"items":   []map[string]string{{"id": g.CalendarID}},
+	}
+	var resp struct {
+		Calendars map[string]struct {
+			Busy   []Period `json:"busy"`
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"calendars"`
+	}
+	if err := g.do(ctx, http.MethodPost, g.BaseURL+"/freeBusy", req, &resp); err != nil {
+		return nil, err
+	}
+	cal, ok := resp.Calendars[g.CalendarID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if len(cal.Errors) > 0 {
+		return nil, fmt.Errorf("google calendar: %s", cal.Errors[0].Reason)
+	}
+	return cal.Busy, nil
+}
+
+// CreateEvent inserts an event, sendInvites makes Google email the attendees
+func (g *GoogleProvider) CreateEvent(ctx context.Context, event Event, sendInvites bool) (*Event, error) {
+	if !event.End.After(event.Start) {
+		return nil, ErrInvalidRange
+	}
+	body := map[string]interface{}{
+		"summary":     event.Title,
+		"description": event.Description,
+		"location":    event.Location,
+		"start":       map[string]string{"dateTime": event.Start.Format(time.RFC3339)},
+		"end":         map[string]string{"dateTime": event.End.Format(time.RFC3339)},
+	}
+	if len(event.Attendees) > 0 {
+		attendees := make([]map[string]string, 0, len(event.Attendees))
+		for _, email := range event.Attendees {
+			attendees = append(attendees, map[string]string{"email": email})
+		}
+		body["attendees"] = attendees
+	}
+
+	sendUpdates := "none"
+	if sendInvites {
+		sendUpdates = "all"
+	}
+	endpoint := fmt.Sprintf("%s/calendars/%s/events?sendUpdates=%s", g.BaseURL, url.PathEscape(g.CalendarID), sendUpdates)
+
+	var resp struct {
+		ID       string `json:"id"`
+		HTMLLink string `json:"htmlLink"`
+	}
+	if err := g.do(ctx, http.MethodPost, endpoint, body, &resp); err != nil {
+		return nil, err
+	}
+	created := event
+	created.ID = resp.ID
+	created.URL = resp.HTMLLink
+	return &created, nil
+}
+
+func (g *GoogleProvider) do(ctx context.Context, method, endpoint string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("google calendar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("google calendar: status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// notifyingTokenSource reports tokens that differ from the last seen one
+type notifyingTokenSource struct {
+	mu        sync.Mutex
+	base      oauth2.TokenSource
+	last      string
+	onRefresh TokenRefreshFunc
+}
+
+func (s *notifyingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	changed := token.AccessToken != s.last
+	s.last = token.AccessToken
+	s.mu.Unlock()
+	if changed && s.onRefresh != nil {
+		s.onRefresh(token)
+	}
+	return token, nil
+}
@@ -0,0 +1,215 @@
+// Package callerlookup enriches inbound calls with CRM data. Before the
+// assistant greets the caller, the caller number is posted to a configured
+// CRM webhook; the returned context (name, account tier, open tickets) is
+// rendered into the system prompt and can personalize the greeting.
+package callerlookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+)
+
+const (
+	// EventInboundLookup is sent in X-Webhook-Event
+	EventInboundLookup = "call.inbound.lookup"
+
+	// DefaultTimeout keeps the caller waiting for the greeting at most this long
+	DefaultTimeout = 1500 * time.Millisecond
+	MaxTimeout     = 5 * time.Second
+
+	userAgent = "LingEcho-CallerLookup/1.0"
+)
+
+// Request payload posted to the CRM webhook
+type Request struct {
+	Event       string `json:"event"`
+	CallID      string `json:"callId"`
+	Caller      string `json:"caller"` // Caller number
+	Callee      string `json:"callee"` // Dialed number
+	AssistantID int64  `json:"assistantId"`
+}
+
+// Ticket open support ticket of the caller
+type Ticket struct {
+	ID      string `json:"id"`
+	Subject string `json:"subject"`
+	Status  string `json:"status,omitempty"`
+}
+
+// Context caller data returned by the CRM. A 404 response or "found": false
+// means the number is unknown.
+type Context struct {
+	Found       bool           `json:"found"`
+	Name        string         `json:"name,omitempty"`
+	AccountTier string         `json:"accountTier,omitempty"`
+	OpenTickets []Ticket       `json:"openTickets,omitempty"`
+	Notes       string         `json:"notes,omitempty"`
+	Attributes  map[string]any `json:"attributes,omitempty"` // Other CRM fields, listed in the prompt as is
+	Greeting    string         `json:"greeting,omitempty"`   // Greeting chosen by the CRM, overrides the template
+}
+
+// Config CRM webhook settings
+type Config struct {
+	URL     string
+	Secret  string // Signs the request like outbound webhooks when set
+	Timeout time.Duration
+	// Client delivers the lookup, nil uses webhook.DeliverPublic since the URL
+	// is configured by assistant owners and the response reaches the prompt
+	Client *webhook.Client
+}
+
+// Lookup asks the CRM about the caller. Unknown callers return a context with
+// Found false and no error.
+func Lookup(ctx context.Context, cfg Config, req Request) (*Context, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if timeout > MaxTimeout {
+		timeout = MaxTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req.Event = EventInboundLookup
+	deliver := webhook.DeliverPublic
+	if cfg.Client != nil {
+		deliver = cfg.Client.Deliver
+	}
+	result, err := deliver(ctx, webhook.Request{
+		URL:       cfg.URL,
+		Event:     EventInboundLookup,
+		Secret:    cfg.Secret,
+		UserAgent: userAgent,
+		Payload:   req,
+	})
+	if result != nil && result.StatusCode == http.StatusNotFound {
+		return &Context{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var found Context
+	if len(bytes.TrimSpace(result.Body)) == 0 {
+		return &found, nil
+	}
+	// Absent "found" means a match whenever the CRM returned any data
+	found.Found = true
+	if err := json.Unmarshal(result.Body, &found); err != nil {
+		return nil, fmt.Errorf("decode crm response: %w", err)
+	}
+	if found.Found && found.empty() {
+		found.Found = false
+	}
+	return &found, nil
+}
+
+func (c *Context) empty() bool {
+	return c.Name == "" && c.AccountTier == "" && len(c.OpenTickets) == 0 &&
+		c.Notes == "" && len(c.Attributes) == 0 && c.Greeting == ""
+}
+
+// Fields names of the data the context carries, recorded with every lookup
+func (c *Context) Fields() []string {
+	var fields []string
+	if c.Name != "" {
+		fields = append(fields, "name")
+	}
+	if c.AccountTier != "" {
+		fields = append(fields, "accountTier")
+	}
+	if len(c.OpenTickets) > 0 {
+		fields = append(fields, "openTickets")
+	}
+	if c.Notes != "" {
+		fields = append(fields, "notes")
+	}
+	for _, key := range sortedKeys(c.Attributes) {
+		fields = append(fields, "attributes."+key)
+	}
+	if c.Greeting != "" {
+		fields = append(fields, "greeting")
+	}
+	return fields
+}
+
+// PromptSection renders the context for the system prompt, empty for unknown callers
+func (c *Context) PromptSection() string {
+	if c == nil || !c.Found {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("来电客户信息（来自 CRM，用于称呼客户和个性化回答，不要逐字念出，也不要透露信息来源）：")
+	if c.Name != "" {
+		b.WriteString("\n- 姓名：" + c.Name)
+	}
+	if c.AccountTier != "" {
+		b.WriteString("\n- 客户等级：" + c.AccountTier)
+	}
+	if len(c.OpenTickets) > 0 {
+		b.WriteString("\n- 未结工单：")
+		for i, t := range c.OpenTickets {
+			if i > 0 {
+				b.WriteString("；")
+			}
+			b.WriteString("#" + t.ID + " " + t.Subject)
+			if t.Status != "" {
+				b.WriteString("（" + t.Status + "）")
+			}
+		}
+	}
+	if c.Notes != "" {
+		b.WriteString("\n- 备注：" + c.Notes)
+	}
+	for _, key := range sortedKeys(c.Attributes) {
+		fmt.Fprintf(&b, "\n- %s：%v", key, c.Attributes[key])
+	}
+	return b.String()
+}
+
+// ParseGreeting checks a greeting template
+func ParseGreeting(tmpl string) (*template.Template, error) {
+	return template.New("greeting").Option("missingkey=zero").Parse(tmpl)
+}
+
+// Greeting picks the greeting for the caller: the CRM's own greeting, else the
+// rendered template. Empty means the default opening message is kept.
+func Greeting(tmpl string, c *Context) (string, error) {
+	if c == nil || !c.Found {
+		return "", nil
+	}
+	if c.Greeting != "" {
+		return c.Greeting, nil
+	}
+	if strings.TrimSpace(tmpl) == "" {
+		return "", nil
+	}
+	t, err := ParseGreeting(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, c); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
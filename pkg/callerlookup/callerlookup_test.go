@@ -0,0 +1,94 @@
+package callerlookup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, EventInboundLookup, r.Header.Get(webhook.HeaderEvent))
+		var req Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Caller != "slow" {
+			assert.NotEmpty(t, r.Header.Get(webhook.HeaderSignature))
+		}
+		switch req.Caller {
+		case "13800000000":
+			w.Write([]byte(`{"name":"张三","accountTier":"VIP","openTickets":[{"id":"42","subject":"发票未收到","status":"open"}],"attributes":{"city":"上海"}}`))
+		case "unknown":
+			w.WriteHeader(http.StatusNotFound)
+		case "empty":
+			w.Write([]byte(`{"found":true}`))
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	// httptest listens on loopback, which the default public client refuses
+	local := webhook.NewClient(nil)
+	cfg := Config{URL: srv.URL, Secret: "s", Client: local}
+
+	c, err := Lookup(context.Background(), cfg, Request{CallID: "c1", Caller: "13800000000"})
+	require.NoError(t, err)
+	assert.True(t, c.Found)
+	assert.Equal(t, "张三", c.Name)
+	assert.Equal(t, []string{"name", "accountTier", "openTickets", "attributes.city"}, c.Fields())
+
+	section := c.PromptSection()
+	assert.Contains(t, section, "姓名：张三")
+	assert.Contains(t, section, "#42 发票未收到（open）")
+	assert.Contains(t, section, "city：上海")
+
+	c, err = Lookup(context.Background(), cfg, Request{Caller: "unknown"})
+	require.NoError(t, err)
+	assert.False(t, c.Found)
+	assert.Empty(t, c.PromptSection())
+
+	c, err = Lookup(context.Background(), cfg, Request{Caller: "empty"})
+	require.NoError(t, err)
+	assert.False(t, c.Found, "a match without data is treated as unknown")
+
+	_, err = Lookup(context.Background(), cfg, Request{Caller: "broken"})
+	assert.Error(t, err)
+
+	_, err = Lookup(context.Background(), Config{URL: srv.URL, Timeout: 50 * time.Millisecond, Client: local}, Request{Caller: "slow"})
+	assert.Error(t, err)
+
+	_, err = Lookup(context.Background(), Config{URL: srv.URL}, Request{Caller: "13800000000"})
+	assert.ErrorIs(t, err, webhook.ErrUnsafeTarget)
+}
+
+func TestGreeting(t *testing.T) {
+	c := &Context{Found: true, Name: "张三", AccountTier: "VIP"}
+
+	greeting, err := Greeting(`{{.Name}}您好{{if eq .AccountTier "VIP"}}，尊贵的会员{{end}}，有什么可以帮您？`, c)
+	require.NoError(t, err)
+	assert.Equal(t, "张三您好，尊贵的会员，有什么可以帮您？", greeting)
+
+	greeting, err = Greeting("", c)
+	require.NoError(t, err)
+	assert.Empty(t, greeting)
+
+	c.Greeting = "欢迎回来，张先生"
+	greeting, err = Greeting("{{.Name}}", c)
+	require.NoError(t, err)
+	assert.Equal(t, "欢迎回来，张先生", greeting)
+
+	greeting, err = Greeting("{{.Name}}", &Context{})
+	require.NoError(t, err)
+	assert.Empty(t, greeting, "unknown callers keep the default opening message")
+
+	_, err = ParseGreeting("{{.Name")
+	assert.Error(t, err)
+}
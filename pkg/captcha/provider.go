@@ -0,0 +1,230 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
+)
+
+// 人机验证服务
+const (
+	ProviderImage     = "image"     // 内置图形验证码
+	ProviderTurnstile = "turnstile" // Cloudflare Turnstile
+	ProviderHCaptcha  = "hcaptcha"  // hCaptcha
+	ProviderReCaptcha = "recaptcha" // Google reCAPTCHA v2/v3
+)
+
+const (
+	turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	hcaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+	recaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+	siteVerifyTimeout  = 5 * time.Second
+)
+
+// ErrCaptchaMisconfigured 服务端密钥无效，需要检查 CAPTCHA_SECRET_KEY
+var ErrCaptchaMisconfigured = errors.New("captcha secret key is invalid")
+
+// Config 人机验证配置
+type Config struct {
+	Provider  string  `env:"CAPTCHA_PROVIDER"`   // image（默认）、turnstile、hcaptcha、recaptcha
+	SiteKey   string  `env:"CAPTCHA_SITE_KEY"`   // 前端组件使用的站点密钥
+	SecretKey string  `env:"CAPTCHA_SECRET_KEY"` // 服务端校验使用的密钥
+	MinScore  float64 `env:"CAPTCHA_MIN_SCORE"`  // reCAPTCHA v3 的最低分数，0 不检查
+}
+
+// CaptchaProvider 人机验证服务，登录和注册时校验客户端提交的结果
+type CaptchaProvider interface {
+	// Name 服务名称，如 turnstile
+	Name() string
+	// SiteKey 前端组件使用的站点密钥，图形验证码为空
+	SiteKey() string
+	// Verify 校验结果：图形验证码为 id 和用户输入的字符，
+	// 其他服务的 code 为前端组件返回的 token，id 不使用
+	Verify(ctx context.Context, id, code, remoteIP string) (bool, error)
+}
+
+// ImageProvider 内置图形验证码
+type ImageProvider struct {
+	Manager *CaptchaManager
+}
+
+// Name 返回 image
+func (p *ImageProvider) Name() string {
+	return ProviderImage
+}
+
+// SiteKey 图形验证码没有站点密钥
+func (p *ImageProvider) SiteKey() string {
+	return ""
+}
+
+// Verify 校验图形验证码，通过后验证码失效
+func (p *ImageProvider) Verify(_ context.Context, id, code, _ string) (bool, error) {
+	if id == "" || code == "" {
+		return false, nil
+	}
+	return p.Manager.Verify(id, code)
+}
+
+// SiteVerifyProvider 通过 siteverify 接口校验 token 的服务，
+// Turnstile、hCaptcha 和 reCAPTCHA 的接口一致
+type SiteVerifyProvider struct {
+	name     string
+	siteKey  string
+	secret   string
+	minScore float64
+	Endpoint string
+	client   *http.Client
+}
+
+func newSiteVerifyProvider(name, endpoint, siteKey, secret string) (*SiteVerifyProvider, error) {
+	if siteKey == "" || secret == "" {
+		return nil, fmt.Errorf("%s requires a site key and a secret key", name)
+	}
+	return &SiteVerifyProvider{
+		name:     name,
+		siteKey:  siteKey,
+		secret:   secret,
+		Endpoint: endpoint,
+		client:   xhttp.NewClient("captcha-"+name, siteVerifyTimeout),
+	}, nil
+}
+
+// NewTurnstileProvider 创建 Cloudflare Turnstile 校验，支持无感验证
+func NewTurnstileProvider(siteKey, secret string) (*SiteVerifyProvider, error) {
+	return newSiteVerifyProvider(ProviderTurnstile, turnstileVerifyURL, siteKey, secret)
+}
+
+// NewHCaptchaProvider 创建 hCaptcha 校验
+func NewHCaptchaProvider(siteKey, secret string) (*SiteVerifyProvider, error) {
+	return newSiteVerifyProvider(ProviderHCaptcha, hcaptchaVerifyURL, siteKey, secret)
+}
+
+// NewReCaptchaProvider 创建 reCAPTCHA 校验，minScore 大于 0 时要求 v3 分数不低于该值
+func NewReCaptchaProvider(siteKey, secret string, minScore float64) (*SiteVerifyProvider, error) {
+	p, err := newSiteVerifyProvider(ProviderReCaptcha, recaptchaVerifyURL, siteKey, secret)
+	if err != nil {
+		return nil, err
+	}
+	p.minScore = minScore
+	return p, nil
+}
+
+// Name 服务名称
+func (p *SiteVerifyProvider) Name() string {
+	return p.name
+}
+
+// SiteKey 站点密钥
+func (p *SiteVerifyProvider) SiteKey() string {
+	return p.siteKey
+}
+
+// Verify 调用 siteverify 接口校验 token，每个 token 只能校验一次
+func (p *SiteVerifyProvider) Verify(ctx context.Context, _, code, remoteIP string) (bool, error) {
+	if code == "" {
+		return false, nil
+	}
+	form := url.Values{}
+	form.Set("secret", p.secret)
+	form.Set("response", code)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	if p.name == ProviderHCaptcha {
+		form.Set("sitekey", p.siteKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%s siteverify failed: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s siteverify failed: %s", p.name, resp.Status)
+	}
+	var result struct {
+		Success    bool     `json:"success"`
+		Score      *float64 `json:"score"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result); err != nil {
+		return false, fmt.Errorf("%s siteverify failed: %w", p.name, err)
+	}
+	for _, code := range result.ErrorCodes {
+		if code == "invalid-input-secret" || code == "missing-input-secret" {
+			return false, ErrCaptchaMisconfigured
+		}
+	}
+	if !result.Success {
+		return false, nil
+	}
+	if p.minScore > 0 && result.Score != nil && *result.Score < p.minScore {
+		return false, nil
+	}
+	return true, nil
+}
+
+// NewProvider 按配置创建人机验证服务，图形验证码使用 manager
+func NewProvider(cfg Config, manager *CaptchaManager) (CaptchaProvider, error) {
+	switch cfg.Provider {
+	case "", ProviderImage:
+		if manager == nil {
+			return nil, errors.New("image captcha requires a captcha manager")
+		}
+		return &ImageProvider{Manager: manager}, nil
+	case ProviderTurnstile:
+		return NewTurnstileProvider(cfg.SiteKey, cfg.SecretKey)
+	case ProviderHCaptcha:
+		return NewHCaptchaProvider(cfg.SiteKey, cfg.SecretKey)
+	case ProviderReCaptcha:
+		return NewReCaptchaProvider(cfg.SiteKey, cfg.SecretKey, cfg.MinScore)
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %q", cfg.Provider)
+	}
+}
+
+// GlobalCaptchaProvider 全局人机验证服务，为空时不校验
+var GlobalCaptchaProvider CaptchaProvider
+
+// InitGlobalCaptchaProvider 初始化全局人机验证服务，使用图形验证码时同时初始化 GlobalCaptchaManager
+func InitGlobalCaptchaProvider(cfg Config, store CaptchaStore) error {
+	var manager *CaptchaManager
+	if cfg.Provider == "" || cfg.Provider == ProviderImage {
+		InitGlobalCaptchaManager(store)
+		manager = GlobalCaptchaManager
+	} else {
+		GlobalCaptchaManager = nil
+	}
+	provider, err := NewProvider(cfg, manager)
+	if err != nil {
+		return err
+	}
+	GlobalCaptchaProvider = provider
+	return nil
+}
+
+// Enabled 是否需要人机验证
+func Enabled() bool {
+	return GlobalCaptchaProvider != nil
+}
+
+// Verify 使用全局人机验证服务校验
+func Verify(ctx context.Context, id, code, remoteIP string) (bool, error) {
+	if GlobalCaptchaProvider == nil {
+		return true, nil
+	}
+	return GlobalCaptchaProvider.Verify(ctx, id, code, remoteIP)
+}
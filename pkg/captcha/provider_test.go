@@ -0,0 +1,139 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newSiteVerifyServer(t *testing.T, wantSitekey bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("ParseForm failed: %v", err)
+		}
+		if got := r.PostForm.Get("sitekey"); wantSitekey != (got == "site") {
+			t.Errorf("unexpected sitekey %q", got)
+		}
+		switch {
+		case r.PostForm.Get("secret") != "secret":
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-secret"]}`))
+		case r.PostForm.Get("response") == "good" && r.PostForm.Get("remoteip") == "1.2.3.4":
+			w.Write([]byte(`{"success":true,"score":0.9}`))
+		case r.PostForm.Get("response") == "bot":
+			w.Write([]byte(`{"success":true,"score":0.1}`))
+		default:
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+		}
+	}))
+}
+
+func TestTurnstileProvider_Verify(t *testing.T) {
+	server := newSiteVerifyServer(t, false)
+	defer server.Close()
+
+	p, err := NewTurnstileProvider("site", "secret")
+	if err != nil {
+		t.Fatalf("NewTurnstileProvider failed: %v", err)
+	}
+	p.Endpoint = server.URL
+
+	if ok, err := p.Verify(context.Background(), "", "good", "1.2.3.4"); err != nil || !ok {
+		t.Fatalf("expected valid token, got %v %v", ok, err)
+	}
+	if ok, err := p.Verify(context.Background(), "", "forged", "1.2.3.4"); err != nil || ok {
+		t.Fatalf("expected invalid token, got %v %v", ok, err)
+	}
+	if ok, _ := p.Verify(context.Background(), "", "", "1.2.3.4"); ok {
+		t.Fatal("empty token should not verify")
+	}
+
+	p.secret = "wrong"
+	if _, err := p.Verify(context.Background(), "", "good", "1.2.3.4"); !errors.Is(err, ErrCaptchaMisconfigured) {
+		t.Fatalf("expected ErrCaptchaMisconfigured, got %v", err)
+	}
+}
+
+func TestHCaptchaProvider_SendsSitekey(t *testing.T) {
+	server := newSiteVerifyServer(t, true)
+	defer server.Close()
+
+	p, err := NewHCaptchaProvider("site", "secret")
+	if err != nil {
+		t.Fatalf("NewHCaptchaProvider failed: %v", err)
+	}
+	p.Endpoint = server.URL
+	if ok, err := p.Verify(context.Background(), "", "good", "1.2.3.4"); err != nil || !ok {
+		t.Fatalf("expected valid token, got %v %v", ok, err)
+	}
+}
+
+func TestReCaptchaProvider_MinScore(t *testing.T) {
+	server := newSiteVerifyServer(t, false)
+	defer server.Close()
+
+	p, err := NewReCaptchaProvider("site", "secret", 0.5)
+	if err != nil {
+		t.Fatalf("NewReCaptchaProvider failed: %v", err)
+	}
+	p.Endpoint = server.URL
+	if ok, _ := p.Verify(context.Background(), "", "bot", ""); ok {
+		t.Fatal("low score should not verify")
+	}
+	if ok, _ := p.Verify(context.Background(), "", "good", "1.2.3.4"); !ok {
+		t.Fatal("high score should verify")
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	manager := NewCaptchaManager(200, 60, 4, time.Minute, nil)
+	p, err := NewProvider(Config{}, manager)
+	if err != nil || p.Name() != ProviderImage {
+		t.Fatalf("expected image provider, got %v %v", p, err)
+	}
+
+	capt, err := manager.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if ok, _ := p.Verify(context.Background(), capt.ID, capt.Code, ""); !ok {
+		t.Fatal("image captcha should verify")
+	}
+
+	if _, err := NewProvider(Config{Provider: ProviderTurnstile}, nil); err == nil {
+		t.Fatal("expected error without keys")
+	}
+	if _, err := NewProvider(Config{Provider: "geetest"}, nil); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+	p, err = NewProvider(Config{Provider: ProviderHCaptcha, SiteKey: "site", SecretKey: "secret"}, nil)
+	if err != nil || p.SiteKey() != "site" {
+		t.Fatalf("expected hcaptcha provider, got %v %v", p, err)
+	}
+}
+
+func TestInitGlobalCaptchaProvider(t *testing.T) {
+	defer func() {
+		GlobalCaptchaProvider = nil
+		GlobalCaptchaManager = nil
+	}()
+
+	if err := InitGlobalCaptchaProvider(Config{Provider: ProviderTurnstile, SiteKey: "site", SecretKey: "secret"}, nil); err != nil {
+		t.Fatalf("InitGlobalCaptchaProvider failed: %v", err)
+	}
+	if GlobalCaptchaManager != nil {
+		t.Fatal("image captcha should be disabled with turnstile")
+	}
+	if !Enabled() || GlobalCaptchaProvider.Name() != ProviderTurnstile {
+		t.Fatal("expected turnstile provider")
+	}
+
+	if err := InitGlobalCaptchaProvider(Config{}, nil); err != nil {
+		t.Fatalf("InitGlobalCaptchaProvider failed: %v", err)
+	}
+	if GlobalCaptchaManager == nil || GlobalCaptchaProvider.Name() != ProviderImage {
+		t.Fatal("expected image provider")
+	}
+}
@@ -0,0 +1,116 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+)
+
+// Headers of vendor API deliveries
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReceiptID      = "X-Archive-Receipt-Id"
+)
+
+// APISink posts each record to a vendor archive API as multipart/form-data with
+// a "record" JSON part (metadata and transcript) and an "audio" file part. The
+// record ID is sent as Idempotency-Key so retried deliveries are not archived
+// twice. The archive answers with a receipt in the X-Archive-Receipt-Id header
+// or a JSON body {"receiptId": "...", "location": "..."}.
+type APISink struct {
+	URL    string
+	Token  string // Sent as a bearer token when set
+	Secret string // Signs the body like outbound webhooks when set
+
+	HTTP *http.Client // Defaults to a client with DefaultTimeout
+}
+
+func (s *APISink) Deliver(ctx context.Context, record *Record) (*Receipt, error) {
+	if s.URL == "" {
+		return nil, fmt.Errorf("%w: url is required", ErrPermanent)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	document, err := json.Marshal(map[string]any{
+		"id":          record.ID,
+		"metadata":    record.Metadata,
+		"transcript":  json.RawMessage(nonEmptyJSON(record.Transcript)),
+		"audioSha256": sha256Hex(record.Audio),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := mw.WriteField("record", string(document)); err != nil {
+		return nil, err
+	}
+	if len(record.Audio) > 0 {
+		ext := record.AudioExt
+		if ext == "" {
+			ext = "bin"
+		}
+		part, err := mw.CreateFormFile("audio", record.ID+"."+ext)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(record.Audio); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set(HeaderIdempotencyKey, record.ID)
+	req.Header.Set("User-Agent", webhook.DefaultUserAgent)
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	if s.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhook.HeaderTimestamp, timestamp)
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(s.Secret, timestamp, body.Bytes()))
+	}
+
+	client := s.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhook.MaxResponseBody))
+	if err := classify(resp, respBody); err != nil {
+		return nil, err
+	}
+
+	receipt := &Receipt{ID: resp.Header.Get(HeaderReceiptID), Checksum: record.Checksum()}
+	var answer struct {
+		ReceiptID string `json:"receiptId"`
+		Location  string `json:"location"`
+	}
+	if json.Unmarshal(respBody, &answer) == nil {
+		if receipt.ID == "" {
+			receipt.ID = answer.ReceiptID
+		}
+		receipt.Location = answer.Location
+	}
+	if receipt.ID == "" {
+		return nil, fmt.Errorf("%w: archive returned no receipt", ErrPermanent)
+	}
+	return receipt, nil
+}
@@ -0,0 +1,89 @@
+// Package compliance delivers finalized call recordings and their transcripts
+// to external compliance archives: S3 buckets with Object Lock (WORM) or a
+// vendor archive API. Every delivery returns a receipt the archive issued for
+// the stored copy, which callers keep as proof of archival.
+package compliance
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Archive kinds
+const (
+	KindS3  = "s3"
+	KindAPI = "api"
+)
+
+// DefaultTimeout of a single delivery
+const DefaultTimeout = 2 * time.Minute
+
+// ErrPermanent marks deliveries the archive rejected; retrying them without a
+// configuration change will not succeed
+var ErrPermanent = errors.New("archive rejected the delivery")
+
+// Record a call recording ready for archival
+type Record struct {
+	ID         string         // Stable identifier, deliveries of the same record are idempotent
+	Metadata   map[string]any // Call metadata stored next to the audio
+	Transcript []byte         // Transcript JSON
+	Audio      []byte         // Recording audio, may be empty for text-only calls
+	AudioExt   string         // Audio file extension, e.g. "wav"
+}
+
+// Checksum SHA-256 of the audio and transcript, hex encoded
+func (r *Record) Checksum() string {
+	h := sha256.New()
+	h.Write(r.Audio)
+	h.Write(r.Transcript)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Receipt proof of archival returned by the archive
+type Receipt struct {
+	ID       string `json:"id"`       // Archive-issued identifier (object version IDs, vendor receipt)
+	Location string `json:"location"` // Where the archive stored the record
+	Checksum string `json:"checksum"` // Checksum of the delivered content
+}
+
+// Sink an archive destination
+type Sink interface {
+	Deliver(ctx context.Context, record *Record) (*Receipt, error)
+}
+
+// classify wraps 4xx responses other than 408 and 429 as permanent failures
+func classify(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err := fmt.Errorf("archive responded %d: %s", resp.StatusCode, truncate(body, 512))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	return err
+}
+
+func truncate(b []byte, n int) string {
+	if len(b) > n {
+		return string(b[:n]) + "..."
+	}
+	return string(b)
+}
+
+func contentMD5(b []byte) string {
+	sum := md5.Sum(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
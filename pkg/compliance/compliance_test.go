@@ -0,0 +1,127 @@
+package compliance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord() *Record {
+	return &Record{
+		ID:         "rec-42",
+		Metadata:   map[string]any{"duration": 12},
+		Transcript: []byte(`[{"type":"user","content":"hello"}]`),
+		Audio:      []byte("RIFF....WAVE"),
+		AudioExt:   "wav",
+	}
+}
+
+func TestS3Sink_DeliverWithObjectLock(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string]http.Header{}
+	var document map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, contentMD5(body), r.Header.Get("Content-MD5"))
+		if strings.HasSuffix(r.URL.Path, "record.json") {
+			json.Unmarshal(body, &document)
+		}
+		mu.Lock()
+		objects[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("X-Amz-Version-Id", "v-"+r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+	}))
+	defer srv.Close()
+
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	sink := &S3Sink{Endpoint: srv.URL, Region: "us-east-1", Bucket: "archive", Prefix: "calls/", AccessKey: "AK", SecretKey: "SK", RetentionDays: 2555}
+	sink.now = func() time.Time { return now }
+
+	receipt, err := sink.Deliver(context.Background(), testRecord())
+	require.NoError(t, err)
+	assert.Equal(t, "audio=v-audio.wav;record=v-record.json", receipt.ID)
+	assert.Equal(t, "s3://archive/calls/rec-42/", receipt.Location)
+	assert.Equal(t, testRecord().Checksum(), receipt.Checksum)
+
+	require.Len(t, objects, 2)
+	audio := objects["/archive/calls/rec-42/audio.wav"]
+	require.NotNil(t, audio)
+	assert.Equal(t, "COMPLIANCE", audio.Get("X-Amz-Object-Lock-Mode"))
+	assert.Equal(t, "2032-02-28T00:00:00Z", audio.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	assert.Equal(t, "rec-42", document["id"])
+	assert.NotNil(t, document["transcript"])
+}
+
+func TestS3Sink_Errors(t *testing.T) {
+	status := http.StatusForbidden
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	sink := &S3Sink{Endpoint: srv.URL, Region: "us-east-1", Bucket: "archive"}
+
+	_, err := sink.Deliver(context.Background(), testRecord())
+	assert.True(t, errors.Is(err, ErrPermanent))
+
+	status = http.StatusServiceUnavailable
+	_, err = sink.Deliver(context.Background(), testRecord())
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrPermanent))
+
+	_, err = (&S3Sink{}).Deliver(context.Background(), testRecord())
+	assert.True(t, errors.Is(err, ErrPermanent))
+}
+
+func TestAPISink_Deliver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+		assert.Equal(t, "rec-42", r.Header.Get(HeaderIdempotencyKey))
+		assert.NotEmpty(t, r.Header.Get(webhook.HeaderSignature))
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(r.FormValue("record")), &record))
+		assert.Equal(t, "rec-42", record["id"])
+		file, header, err := r.FormFile("audio")
+		require.NoError(t, err)
+		defer file.Close()
+		assert.Equal(t, "rec-42.wav", header.Filename)
+		w.Write([]byte(`{"receiptId":"R-1","location":"vault/7"}`))
+	}))
+	defer srv.Close()
+
+	receipt, err := (&APISink{URL: srv.URL, Token: "tok", Secret: "s"}).Deliver(context.Background(), testRecord())
+	require.NoError(t, err)
+	assert.Equal(t, "R-1", receipt.ID)
+	assert.Equal(t, "vault/7", receipt.Location)
+}
+
+func TestAPISink_NoReceiptIsRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	_, err := (&APISink{URL: srv.URL}).Deliver(context.Background(), testRecord())
+	assert.True(t, errors.Is(err, ErrPermanent))
+
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	_, err = (&APISink{URL: limited.URL}).Deliver(context.Background(), testRecord())
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrPermanent))
+}
@@ -0,0 +1,143 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// S3Sink stores each record as two objects under <prefix><record id>/: the
+// audio and a JSON document with metadata and transcript. With RetentionDays
+// set, objects are written in Object Lock compliance mode so they cannot be
+// deleted or overwritten before the retention date; the bucket must have
+// Object Lock enabled.
+type S3Sink struct {
+	Endpoint      string // S3-compatible endpoint for path-style access; empty uses AWS virtual-hosted URLs
+	Region        string
+	Bucket        string
+	Prefix        string
+	AccessKey     string
+	SecretKey     string
+	RetentionDays int
+
+	HTTP *http.Client // Defaults to a client with DefaultTimeout
+	now  func() time.Time
+}
+
+func (s *S3Sink) Deliver(ctx context.Context, record *Record) (*Receipt, error) {
+	if s.Bucket == "" || s.Region == "" {
+		return nil, fmt.Errorf("%w: bucket and region are required", ErrPermanent)
+	}
+	base := s.Prefix + record.ID + "/"
+
+	document, err := json.Marshal(map[string]any{
+		"id":          record.ID,
+		"metadata":    record.Metadata,
+		"transcript":  json.RawMessage(nonEmptyJSON(record.Transcript)),
+		"audioSha256": sha256Hex(record.Audio),
+		"archivedAt":  s.clock(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	if len(record.Audio) > 0 {
+		ext := record.AudioExt
+		if ext == "" {
+			ext = "bin"
+		}
+		version, err := s.put(ctx, base+"audio."+ext, record.Audio, "application/octet-stream")
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, "audio="+version)
+	}
+	version, err := s.put(ctx, base+"record.json", document, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	versions = append(versions, "record="+version)
+
+	return &Receipt{
+		ID:       strings.Join(versions, ";"),
+		Location: "s3://" + s.Bucket + "/" + base,
+		Checksum: record.Checksum(),
+	}, nil
+}
+
+// put uploads an object and returns its version ID, or its ETag on unversioned buckets
+func (s *S3Sink) put(ctx context.Context, key string, body []byte, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	payloadHash := sha256Hex(body)
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", contentType)
+	// Object Lock requires an integrity header on uploads
+	req.Header.Set("Content-MD5", contentMD5(body))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.RetentionDays > 0 {
+		retainUntil := s.clock().AddDate(0, 0, s.RetentionDays).UTC().Format(time.RFC3339)
+		req.Header.Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+		req.Header.Set("X-Amz-Object-Lock-Retain-Until-Date", retainUntil)
+	}
+
+	creds := aws.Credentials{AccessKeyID: s.AccessKey, SecretAccessKey: s.SecretKey}
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, payloadHash, "s3", s.Region, s.clock()); err != nil {
+		return "", err
+	}
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if err := classify(resp, respBody); err != nil {
+		return "", fmt.Errorf("put %s: %w", key, err)
+	}
+	if version := resp.Header.Get("X-Amz-Version-Id"); version != "" {
+		return version, nil
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+func (s *S3Sink) objectURL(key string) string {
+	escaped := (&url.URL{Path: key}).EscapedPath()
+	if s.Endpoint == "" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, escaped)
+	}
+	return strings.TrimRight(s.Endpoint, "/") + "/" + s.Bucket + "/" + escaped
+}
+
+func (s *S3Sink) client() *http.Client {
+	if s.HTTP != nil {
+		return s.HTTP
+	}
+	return &http.Client{Timeout: DefaultTimeout}
+}
+
+func (s *S3Sink) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func nonEmptyJSON(b []byte) []byte {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return []byte("null")
+	}
+	return b
+}
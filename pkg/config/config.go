@@ -1,7 +1,6 @@
 package config
 
 import (
-	"errors"
 	"log"
 	"os"
 	"strconv"
@@ -9,8 +8,10 @@ import (
 
 	"github.com/LingByte/lingstorage-sdk-go"
 	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/captcha"
 	"github.com/code-100-precent/LingEcho/pkg/logger"
 	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/queue"
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 )
 
@@ -21,11 +22,13 @@ type Config struct {
 	Database     DatabaseConfig     `mapstructure:"database"`
 	Log          logger.LogConfig   `mapstructure:"log"`
 	Cache        cache.Config       `mapstructure:"cache"`
+	Queue        queue.Config       `mapstructure:"queue"`
 	Auth         AuthConfig         `mapstructure:"auth"`
 	Services     ServicesConfig     `mapstructure:"services"`
 	Integrations IntegrationsConfig `mapstructure:"integrations"`
 	Features     FeaturesConfig     `mapstructure:"features"`
 	Middleware   MiddlewareConfig   `mapstructure:"middleware"`
+	Domains      DomainsConfig      `mapstructure:"domains"`
 }
 
 // ServerConfig server configuration
@@ -45,6 +48,28 @@ type ServerConfig struct {
 	SSLEnabled    bool   `env:"SSL_ENABLED"`
 	SSLCertFile   string `env:"SSL_CERT_FILE"`
 	SSLKeyFile    string `env:"SSL_KEY_FILE"`
+	StrictConfig  bool   `env:"CONFIG_STRICT"` // Refuse to start when the configuration check finds errors
+	ProbeConfig   bool   `env:"CONFIG_PROBE"`  // Dial external dependencies during the configuration check
+	Region        string `env:"REGION"`        // Region served by this instance; devices and SIP users pinned elsewhere are handed off
+
+	LegacyAPISunset string `env:"LEGACY_API_SUNSET"` // Date (YYYY-MM-DD) the unversioned API routes are retired, announced in the Sunset header
+}
+
+// LegacyAPISunsetTime parses LEGACY_API_SUNSET, returning the zero time when it is not set
+func (s ServerConfig) LegacyAPISunsetTime() (time.Time, error) {
+	if s.LegacyAPISunset == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s.LegacyAPISunset)
+}
+
+// DomainsConfig organization custom domains (white-labeling)
+type DomainsConfig struct {
+	CNAMETarget   string `env:"CUSTOM_DOMAIN_CNAME_TARGET"`   // Hostname custom domains must CNAME to, registration is disabled when empty
+	AutoTLS       bool   `env:"CUSTOM_DOMAIN_AUTO_TLS"`       // Issue certificates for verified domains via ACME (TLS-ALPN-01 on the HTTPS listener)
+	CertCacheDir  string `env:"CUSTOM_DOMAIN_CERT_DIR"`       // Directory for issued certificates and the ACME account key
+	ACMEEmail     string `env:"CUSTOM_DOMAIN_ACME_EMAIL"`     // Contact email for the ACME account
+	ACMEDirectory string `env:"CUSTOM_DOMAIN_ACME_DIRECTORY"` // ACME directory URL, defaults to Let's Encrypt production
 }
 
 // DatabaseConfig database configuration
@@ -59,6 +84,15 @@ type AuthConfig struct {
 	SessionSecret    string `env:"SESSION_SECRET"`
 	SecretExpireDays string `env:"SESSION_EXPIRE_DAYS"`
 	APISecretKey     string `env:"API_SECRET_KEY"`
+	PolicyEngine     string `env:"POLICY_ENGINE"`   // 可选的策略引擎：rules、opa，为空时只使用 RBAC
+	OPAURL           string `env:"OPA_URL"`         // OPA 服务地址，如 http://localhost:8181
+	OPAPolicyPath    string `env:"OPA_POLICY_PATH"` // OPA 策略路径，如 lingecho/authz
+
+	// LoginAnomaly external model scoring logins, rule-based scoring only when no URL is set
+	LoginAnomaly utils.LoginAnomalyConfig `mapstructure:"login_anomaly"`
+
+	// Captcha challenge of login and signup, the built-in image captcha by default
+	Captcha captcha.Config `mapstructure:"captcha"`
 }
 
 // ServicesConfig services configuration
@@ -68,6 +102,9 @@ type ServicesConfig struct {
 	KnowledgeBase KnowledgeBaseConfig     `mapstructure:"knowledge_base"`
 	Voice         VoiceConfig             `mapstructure:"voice"`
 	Storage       StorageConfig           `mapstructure:"storage"`
+	Notary        NotaryConfig            `mapstructure:"notary"`
+	Push          notification.PushConfig `mapstructure:"push"`
+	SMS           notification.SMSConfig  `mapstructure:"sms"`
 }
 
 // LLMConfig LLM service configuration
@@ -99,6 +136,8 @@ type BailianConfig struct {
 	Parser          string `env:"BAILIAN_PARSER"`
 	StructType      string `env:"BAILIAN_STRUCT_TYPE"`
 	SinkType        string `env:"BAILIAN_SINK_TYPE"`
+	// CallbackSecret signs Bailian indexing callbacks; when empty uploads poll the job status instead
+	CallbackSecret string `env:"BAILIAN_CALLBACK_SECRET"`
 }
 
 // MilvusConfig Milvus configuration
@@ -190,11 +229,59 @@ type StorageConfig struct {
 	Bucket    string `env:"LINGSTORAGE_BUCKET"`
 }
 
+// NotaryConfig external notarization service for daily recording digests
+type NotaryConfig struct {
+	URL    string `env:"RECORDING_NOTARY_URL"`    // Daily digests are POSTed here, anchoring is disabled when empty
+	Secret string `env:"RECORDING_NOTARY_SECRET"` // Signs the request body with HMAC-SHA256
+}
+
 // IntegrationsConfig integrations configuration
 type IntegrationsConfig struct {
+	GoogleCalendar GoogleCalendarConfig `mapstructure:"google_calendar"`
+	OAuthLogin     OAuthLoginConfig     `mapstructure:"oauth_login"`
+	OIDC           OIDCConfig           `mapstructure:"oidc"`
 	// Other third-party integration configurations can be added here
 }
 
+// GoogleCalendarConfig Google Calendar OAuth client configuration
+type GoogleCalendarConfig struct {
+	ClientID     string `env:"GOOGLE_CALENDAR_CLIENT_ID"`
+	ClientSecret string `env:"GOOGLE_CALENDAR_CLIENT_SECRET"`
+	RedirectURL  string `env:"GOOGLE_CALENDAR_REDIRECT_URL"`
+}
+
+// OAuthLoginConfig social login providers, each enabled when both its client ID and secret are set.
+// The callback URL to register at the provider is {SERVER_URL}{API_PREFIX}{AUTH_PREFIX}/oauth/{provider}/callback
+type OAuthLoginConfig struct {
+	GoogleClientID     string `env:"OAUTH_GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string `env:"OAUTH_GOOGLE_CLIENT_SECRET"`
+	GitHubClientID     string `env:"OAUTH_GITHUB_CLIENT_ID"`
+	GitHubClientSecret string `env:"OAUTH_GITHUB_CLIENT_SECRET"`
+	WeChatAppID        string `env:"OAUTH_WECHAT_APP_ID"`
+	WeChatAppSecret    string `env:"OAUTH_WECHAT_APP_SECRET"`
+	AutoProvision      bool   `env:"OAUTH_AUTO_PROVISION"` // create an account on first login with a verified email
+}
+
+// OIDCConfig enterprise single sign-on through an OpenID Connect provider, enabled when the issuer
+// and client ID are set. The redirect URI to register is {SERVER_URL}{API_PREFIX}{AUTH_PREFIX}/oidc/callback
+type OIDCConfig struct {
+	Issuer          string `env:"OIDC_ISSUER"`
+	ClientID        string `env:"OIDC_CLIENT_ID"`
+	ClientSecret    string `env:"OIDC_CLIENT_SECRET"`    // empty for public clients, PKCE protects the code
+	Scopes          string `env:"OIDC_SCOPES"`           // space separated, default "openid email profile"
+	DisplayName     string `env:"OIDC_DISPLAY_NAME"`     // label of the login button
+	GroupsClaim     string `env:"OIDC_GROUPS_CLAIM"`     // claim listing the user's IdP groups, default groups
+	GroupMapping    string `env:"OIDC_GROUP_MAPPING"`    // idp-group=groupID[:role], comma separated
+	SyncGroups      bool   `env:"OIDC_SYNC_GROUPS"`      // remove memberships of mapped groups missing from the claim
+	JITProvisioning bool   `env:"OIDC_JIT_PROVISIONING"` // create an account on first login
+	TrustEmail      bool   `env:"OIDC_TRUST_EMAIL"`      // treat emails as verified when the IdP omits email_verified
+}
+
+// Enabled reports whether OIDC login is configured
+func (o OIDCConfig) Enabled() bool {
+	return o.Issuer != "" && o.ClientID != ""
+}
+
 // FeaturesConfig feature flags configuration
 type FeaturesConfig struct {
 	SearchEnabled   bool   `env:"SEARCH_ENABLED"`
@@ -214,11 +301,14 @@ type MiddlewareConfig struct {
 	Timeout TimeoutConfig
 	// Circuit breaker configuration
 	CircuitBreaker CircuitBreakerConfig
+	// Request body size configuration
+	BodyLimit BodyLimitConfig
 	// Whether to enable each middleware
 	EnableRateLimit      bool `env:"ENABLE_RATE_LIMIT"`
 	EnableTimeout        bool `env:"ENABLE_TIMEOUT"`
 	EnableCircuitBreaker bool `env:"ENABLE_CIRCUIT_BREAKER"`
 	EnableOperationLog   bool `env:"ENABLE_OPERATION_LOG"`
+	EnableBodyLimit      bool `env:"ENABLE_BODY_LIMIT"`
 }
 
 // RateLimiterConfig rate limiting configuration
@@ -240,6 +330,13 @@ type TimeoutConfig struct {
 	FallbackResponse interface{}
 }
 
+// BodyLimitConfig request body size configuration, in bytes (configured in MB)
+type BodyLimitConfig struct {
+	DefaultMaxBytes int64 `env:"BODY_LIMIT_DEFAULT_MB"` // Routes without their own limit
+	UploadMaxBytes  int64 `env:"BODY_LIMIT_UPLOAD_MB"`  // Document and audio upload routes
+	MultipartMemory int64 `env:"MULTIPART_MEMORY_MB"`   // Larger multipart files spill to temp files
+}
+
 // CircuitBreakerConfig circuit breaker configuration
 type CircuitBreakerConfig struct {
 	FailureThreshold      int           `env:"CIRCUIT_BREAKER_FAILURE_THRESHOLD"`
@@ -281,6 +378,11 @@ func Load() error {
 			SSLEnabled:    getBoolOrDefault("SSL_ENABLED", false),
 			SSLCertFile:   getStringOrDefault("SSL_CERT_FILE", ""),
 			SSLKeyFile:    getStringOrDefault("SSL_KEY_FILE", ""),
+			StrictConfig:  getBoolOrDefault("CONFIG_STRICT", false),
+			ProbeConfig:   getBoolOrDefault("CONFIG_PROBE", false),
+			Region:        getStringOrDefault("REGION", ""),
+
+			LegacyAPISunset: getStringOrDefault("LEGACY_API_SUNSET", ""),
 		},
 		Database: DatabaseConfig{
 			Driver: getStringOrDefault("DB_DRIVER", "sqlite"),
@@ -295,11 +397,39 @@ func Load() error {
 			Daily:      getBoolOrDefault("LOG_DAILY", true),
 		},
 		Cache: loadCacheConfig(),
+		Queue: queue.Config{
+			Backend:     getStringOrDefault("QUEUE_BACKEND", queue.BackendMemory),
+			Workers:     getIntOrDefault("QUEUE_WORKERS", 4),
+			MaxAttempts: getIntOrDefault("QUEUE_MAX_ATTEMPTS", 5),
+			RedisPrefix: getStringOrDefault("QUEUE_REDIS_PREFIX", "lingecho:queue"),
+		},
 		Auth: AuthConfig{
 			Header:           getStringOrDefault("AUTH_HEADER", "Authorization"),
 			SessionSecret:    getStringOrDefault("SESSION_SECRET", generateDefaultSessionSecret()),
 			SecretExpireDays: getStringOrDefault("SESSION_EXPIRE_DAYS", "7"),
 			APISecretKey:     getStringOrDefault("API_SECRET_KEY", generateDefaultSessionSecret()),
+			PolicyEngine:     getStringOrDefault("POLICY_ENGINE", ""),
+			OPAURL:           getStringOrDefault("OPA_URL", "http://localhost:8181"),
+			OPAPolicyPath:    getStringOrDefault("OPA_POLICY_PATH", "lingecho/authz"),
+			LoginAnomaly: utils.LoginAnomalyConfig{
+				ModelURL:  getStringOrDefault("LOGIN_ANOMALY_MODEL_URL", ""),
+				APIKey:    getStringOrDefault("LOGIN_ANOMALY_MODEL_API_KEY", ""),
+				Mode:      getStringOrDefault("LOGIN_ANOMALY_MODE", ""),
+				Timeout:   parseDuration(getStringOrDefault("LOGIN_ANOMALY_TIMEOUT", "300ms"), 300*time.Millisecond),
+				Threshold: getFloatOrDefault("LOGIN_ANOMALY_THRESHOLD", 0.7),
+
+				ChallengeThreshold: getFloatOrDefault("LOGIN_RISK_CHALLENGE_THRESHOLD", 0.7),
+				BlockThreshold:     getFloatOrDefault("LOGIN_RISK_BLOCK_THRESHOLD", 0.9),
+				RuleWeights:        getStringOrDefault("LOGIN_RISK_RULE_WEIGHTS", ""),
+				MaxTravelSpeed:     getFloatOrDefault("LOGIN_RISK_MAX_TRAVEL_SPEED", 1000),
+				IPBlocklist:        getStringOrDefault("LOGIN_RISK_IP_BLOCKLIST", ""),
+			},
+			Captcha: captcha.Config{
+				Provider:  getStringOrDefault("CAPTCHA_PROVIDER", captcha.ProviderImage),
+				SiteKey:   getStringOrDefault("CAPTCHA_SITE_KEY", ""),
+				SecretKey: getStringOrDefault("CAPTCHA_SECRET_KEY", ""),
+				MinScore:  getFloatOrDefault("CAPTCHA_MIN_SCORE", 0),
+			},
 		},
 		Services: ServicesConfig{
 			LLM: LLMConfig{
@@ -308,6 +438,26 @@ func Load() error {
 				Model:   getStringOrDefault("LLM_MODEL", "gpt-3.5-turbo"),
 			},
 			Mail: loadMailConfig(),
+			Push: notification.PushConfig{
+				FCMProjectID:       getStringOrDefault("PUSH_FCM_PROJECT_ID", ""),
+				FCMCredentialsFile: getStringOrDefault("PUSH_FCM_CREDENTIALS_FILE", ""),
+				APNsKeyID:          getStringOrDefault("PUSH_APNS_KEY_ID", ""),
+				APNsTeamID:         getStringOrDefault("PUSH_APNS_TEAM_ID", ""),
+				APNsBundleID:       getStringOrDefault("PUSH_APNS_BUNDLE_ID", ""),
+				APNsKeyFile:        getStringOrDefault("PUSH_APNS_KEY_FILE", ""),
+				APNsSandbox:        getBoolOrDefault("PUSH_APNS_SANDBOX", false),
+			},
+			SMS: notification.SMSConfig{
+				Provider:              getStringOrDefault("SMS_PROVIDER", ""),
+				AliyunAccessKeyID:     getStringOrDefault("SMS_ALIYUN_ACCESS_KEY_ID", ""),
+				AliyunAccessKeySecret: getStringOrDefault("SMS_ALIYUN_ACCESS_KEY_SECRET", ""),
+				AliyunSignName:        getStringOrDefault("SMS_ALIYUN_SIGN_NAME", ""),
+				AliyunTemplateCode:    getStringOrDefault("SMS_ALIYUN_TEMPLATE_CODE", ""),
+				TwilioAccountSID:      getStringOrDefault("SMS_TWILIO_ACCOUNT_SID", ""),
+				TwilioAuthToken:       getStringOrDefault("SMS_TWILIO_AUTH_TOKEN", ""),
+				TwilioFrom:            getStringOrDefault("SMS_TWILIO_FROM", ""),
+				TwilioBody:            getStringOrDefault("SMS_TWILIO_BODY", ""),
+			},
 			KnowledgeBase: KnowledgeBaseConfig{
 				Enabled: getBoolOrDefault("KNOWLEDGE_BASE_ENABLED", false),
 				Bailian: BailianConfig{
@@ -320,6 +470,7 @@ func Load() error {
 					Parser:          getStringOrDefault("BAILIAN_PARSER", ""),
 					StructType:      getStringOrDefault("BAILIAN_STRUCT_TYPE", ""),
 					SinkType:        getStringOrDefault("BAILIAN_SINK_TYPE", ""),
+					CallbackSecret:  getStringOrDefault("BAILIAN_CALLBACK_SECRET", ""),
 				},
 				Milvus: MilvusConfig{
 					Address:    getStringOrDefault("MILVUS_ADDRESS", "localhost:19530"),
@@ -388,6 +539,38 @@ func Load() error {
 				APISecret: getStringOrDefault("LINGSTORAGE_API_SECRET", ""),
 				Bucket:    getStringOrDefault("LINGSTORAGE_BUCKET", "default"),
 			},
+			Notary: NotaryConfig{
+				URL:    getStringOrDefault("RECORDING_NOTARY_URL", ""),
+				Secret: getStringOrDefault("RECORDING_NOTARY_SECRET", ""),
+			},
+		},
+		Integrations: IntegrationsConfig{
+			GoogleCalendar: GoogleCalendarConfig{
+				ClientID:     getStringOrDefault("GOOGLE_CALENDAR_CLIENT_ID", ""),
+				ClientSecret: getStringOrDefault("GOOGLE_CALENDAR_CLIENT_SECRET", ""),
+				RedirectURL:  getStringOrDefault("GOOGLE_CALENDAR_REDIRECT_URL", ""),
+			},
+			OAuthLogin: OAuthLoginConfig{
+				GoogleClientID:     getStringOrDefault("OAUTH_GOOGLE_CLIENT_ID", ""),
+				GoogleClientSecret: getStringOrDefault("OAUTH_GOOGLE_CLIENT_SECRET", ""),
+				GitHubClientID:     getStringOrDefault("OAUTH_GITHUB_CLIENT_ID", ""),
+				GitHubClientSecret: getStringOrDefault("OAUTH_GITHUB_CLIENT_SECRET", ""),
+				WeChatAppID:        getStringOrDefault("OAUTH_WECHAT_APP_ID", ""),
+				WeChatAppSecret:    getStringOrDefault("OAUTH_WECHAT_APP_SECRET", ""),
+				AutoProvision:      getBoolOrDefault("OAUTH_AUTO_PROVISION", true),
+			},
+			OIDC: OIDCConfig{
+				Issuer:          getStringOrDefault("OIDC_ISSUER", ""),
+				ClientID:        getStringOrDefault("OIDC_CLIENT_ID", ""),
+				ClientSecret:    getStringOrDefault("OIDC_CLIENT_SECRET", ""),
+				Scopes:          getStringOrDefault("OIDC_SCOPES", "openid email profile"),
+				DisplayName:     getStringOrDefault("OIDC_DISPLAY_NAME", "SSO"),
+				GroupsClaim:     getStringOrDefault("OIDC_GROUPS_CLAIM", "groups"),
+				GroupMapping:    getStringOrDefault("OIDC_GROUP_MAPPING", ""),
+				SyncGroups:      getBoolOrDefault("OIDC_SYNC_GROUPS", true),
+				JITProvisioning: getBoolOrDefault("OIDC_JIT_PROVISIONING", true),
+				TrustEmail:      getBoolOrDefault("OIDC_TRUST_EMAIL", false),
+			},
 		},
 		Features: FeaturesConfig{
 			SearchEnabled:   getBoolOrDefault("SEARCH_ENABLED", false),
@@ -399,6 +582,13 @@ func Load() error {
 			BackupSchedule:  getStringOrDefault("BACKUP_SCHEDULE", "0 2 * * *"),
 		},
 		Middleware: loadMiddlewareConfig(),
+		Domains: DomainsConfig{
+			CNAMETarget:   getStringOrDefault("CUSTOM_DOMAIN_CNAME_TARGET", ""),
+			AutoTLS:       getBoolOrDefault("CUSTOM_DOMAIN_AUTO_TLS", false),
+			CertCacheDir:  getStringOrDefault("CUSTOM_DOMAIN_CERT_DIR", "./certs/custom-domains"),
+			ACMEEmail:     getStringOrDefault("CUSTOM_DOMAIN_ACME_EMAIL", ""),
+			ACMEDirectory: getStringOrDefault("CUSTOM_DOMAIN_ACME_DIRECTORY", ""),
+		},
 	}
 	GlobalStore = lingstorage.NewClient(&lingstorage.Config{
 		BaseURL:   GlobalConfig.Services.Storage.BaseURL,
@@ -409,61 +599,9 @@ func Load() error {
 	return nil
 }
 
-// Validate validates the configuration
+// Validate validates the configuration, the error lists every problem found
 func (c *Config) Validate() error {
-	// Validate database configuration
-	if c.Database.DSN == "" {
-		return errors.New("database DSN is required")
-	}
-
-	// Validate server configuration
-	if c.Server.Addr == "" {
-		return errors.New("server address is required")
-	}
-
-	// Validate knowledge base configuration
-	if c.Services.KnowledgeBase.Enabled {
-		// Provider is now selected from frontend, so we don't validate it here
-		// But we still validate individual provider configurations if they are configured
-
-		// Validate Aliyun if configured
-		if c.Services.KnowledgeBase.Bailian.AccessKeyId != "" {
-			if c.Services.KnowledgeBase.Bailian.AccessKeySecret == "" {
-				return errors.New("bailian access key secret is required when access key ID is set")
-			}
-		}
-
-		// Validate Milvus if configured
-		if c.Services.KnowledgeBase.Milvus.Address != "" {
-			// Milvus is configured, validation passed
-		}
-
-		// Validate Qdrant if configured
-		if c.Services.KnowledgeBase.Qdrant.BaseURL != "" {
-			// Qdrant is configured, validation passed
-		}
-
-		// Validate Elasticsearch if configured
-		if c.Services.KnowledgeBase.Elasticsearch.BaseURL != "" {
-			// Elasticsearch is configured, validation passed
-		}
-
-		// Validate Pinecone if configured
-		if c.Services.KnowledgeBase.Pinecone.APIKey != "" {
-			if c.Services.KnowledgeBase.Pinecone.IndexName == "" {
-				return errors.New("pinecone index name is required when API key is set")
-			}
-		}
-	}
-
-	// Validate Neo4j configuration
-	if c.Services.KnowledgeBase.Neo4j.Enabled {
-		if c.Services.KnowledgeBase.Neo4j.URI == "" {
-			return errors.New("neo4j URI is required when enabled")
-		}
-	}
-
-	return nil
+	return c.Check().Err()
 }
 
 // getStringOrDefault gets environment variable value, returns default if empty
@@ -670,10 +808,16 @@ func loadMiddlewareConfig() MiddlewareConfig {
 			OpenTimeout:           parseDuration(getStringOrDefault("CIRCUIT_BREAKER_OPEN_TIMEOUT", "30s"), defaultConfig.CircuitBreaker.OpenTimeout),
 			MaxConcurrentRequests: getIntOrDefault("CIRCUIT_BREAKER_MAX_CONCURRENT", defaultConfig.CircuitBreaker.MaxConcurrentRequests),
 		},
+		BodyLimit: BodyLimitConfig{
+			DefaultMaxBytes: int64(getIntOrDefault("BODY_LIMIT_DEFAULT_MB", 10)) << 20,
+			UploadMaxBytes:  int64(getIntOrDefault("BODY_LIMIT_UPLOAD_MB", 200)) << 20,
+			MultipartMemory: int64(getIntOrDefault("MULTIPART_MEMORY_MB", 8)) << 20,
+		},
 		EnableRateLimit:      getBoolOrDefault("ENABLE_RATE_LIMIT", defaultConfig.EnableRateLimit),
 		EnableTimeout:        getBoolOrDefault("ENABLE_TIMEOUT", defaultConfig.EnableTimeout),
 		EnableCircuitBreaker: getBoolOrDefault("ENABLE_CIRCUIT_BREAKER", defaultConfig.EnableCircuitBreaker),
 		EnableOperationLog:   getBoolOrDefault("ENABLE_OPERATION_LOG", defaultConfig.EnableOperationLog),
+		EnableBodyLimit:      getBoolOrDefault("ENABLE_BODY_LIMIT", true),
 	}
 }
 
@@ -713,6 +857,7 @@ func loadMailConfig() notification.MailConfig {
 		config.APIKey = getStringOrDefault("SENDCLOUD_API_KEY", "")
 		config.From = getStringOrDefault("MAIL_FROM_EMAIL", getStringOrDefault("SENDCLOUD_FROM_EMAIL", ""))
 	}
+	config.FromName = getStringOrDefault("MAIL_FROM_NAME", "")
 
 	return config
 }
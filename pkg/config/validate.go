@@ -0,0 +1,707 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/captcha"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/oauth"
+	"github.com/code-100-precent/LingEcho/pkg/queue"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+)
+
+// Severity of a configuration issue
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // The feature cannot work, strict mode refuses to start
+	SeverityWarning Severity = "warning" // Works, but probably not as intended
+)
+
+// DefaultProbeTimeout dial timeout of each connectivity probe
+const DefaultProbeTimeout = 3 * time.Second
+
+// Issue one configuration problem with the setting to fix
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Section  string   `json:"section"`
+	Env      string   `json:"env,omitempty"` // Environment variable(s) to change
+	Message  string   `json:"message"`
+	Hint     string   `json:"hint,omitempty"`
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("[%s] %s", i.Section, i.Message)
+	if i.Env != "" {
+		s += " (" + i.Env + ")"
+	}
+	if i.Hint != "" {
+		s += ": " + i.Hint
+	}
+	return s
+}
+
+// Report consolidated result of a validation pass
+type Report struct {
+	Issues []Issue `json:"issues"`
+}
+
+func (r *Report) add(severity Severity, section, env, message, hint string) {
+	r.Issues = append(r.Issues, Issue{Severity: severity, Section: section, Env: env, Message: message, Hint: hint})
+}
+
+func (r *Report) errorf(section, env, hint, format string, args ...any) {
+	r.add(SeverityError, section, env, fmt.Sprintf(format, args...), hint)
+}
+
+func (r *Report) warnf(section, env, hint, format string, args ...any) {
+	r.add(SeverityWarning, section, env, fmt.Sprintf(format, args...), hint)
+}
+
+// Merge appends the issues of another report
+func (r *Report) Merge(other *Report) {
+	if other != nil {
+		r.Issues = append(r.Issues, other.Issues...)
+	}
+}
+
+// Errors returns the issues of error severity
+func (r *Report) Errors() []Issue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the issues of warning severity
+func (r *Report) Warnings() []Issue {
+	return r.filter(SeverityWarning)
+}
+
+func (r *Report) filter(severity Severity) []Issue {
+	var issues []Issue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// HasErrors reports whether any issue is an error
+func (r *Report) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+// String formats the report, errors first
+func (r *Report) String() string {
+	if len(r.Issues) == 0 {
+		return "configuration OK"
+	}
+	var b strings.Builder
+	errs, warns := r.Errors(), r.Warnings()
+	fmt.Fprintf(&b, "configuration check: %d error(s), %d warning(s)", len(errs), len(warns))
+	for _, issue := range errs {
+		b.WriteString("\n  ERROR   " + issue.String())
+	}
+	for _, issue := range warns {
+		b.WriteString("\n  WARNING " + issue.String())
+	}
+	return b.String()
+}
+
+// Err returns the report as an error when it contains errors
+func (r *Report) Err() error {
+	if !r.HasErrors() {
+		return nil
+	}
+	return reportError{r}
+}
+
+type reportError struct{ report *Report }
+
+func (e reportError) Error() string {
+	return e.report.String()
+}
+
+// Check validates the settings of every enabled feature without network access
+func (c *Config) Check() *Report {
+	r := &Report{}
+	c.checkServer(r)
+	c.checkDatabase(r)
+	c.checkAuth(r)
+	c.checkCache(r)
+	c.checkQueue(r)
+	c.checkLLM(r)
+	c.checkMail(r)
+	c.checkPush(r)
+	c.checkSMS(r)
+	c.checkKnowledgeBase(r)
+	c.checkVoice(r)
+	c.checkStorage(r)
+	c.checkIntegrations(r)
+	c.checkFeatures(r)
+	return r
+}
+
+func (c *Config) checkServer(r *Report) {
+	s := c.Server
+	if s.Addr == "" {
+		r.errorf("server", "ADDR", "e.g. :7072", "listen address is required")
+	} else if _, port, err := net.SplitHostPort(s.Addr); err != nil || !validPort(port) {
+		r.errorf("server", "ADDR", "use host:port or :port, e.g. :7072", "invalid listen address %q", s.Addr)
+	}
+	switch s.Mode {
+	case "development", "dev", "test", "production", "prod":
+	default:
+		r.warnf("server", "MODE", "use development, test or production", "unknown mode %q", s.Mode)
+	}
+	if s.URL != "" {
+		checkURL(r, "server", "SERVER_URL", s.URL, "http", "https")
+	} else if s.Mode == "production" {
+		r.warnf("server", "SERVER_URL", "links in emails, OAuth and SSO callbacks are derived from request headers", "public URL is not set")
+	}
+	for env, prefix := range map[string]string{
+		"API_PREFIX":     s.APIPrefix,
+		"DOCS_PREFIX":    s.DocsPrefix,
+		"ADMIN_PREFIX":   s.AdminPrefix,
+		"AUTH_PREFIX":    s.AuthPrefix,
+		"MONITOR_PREFIX": s.MonitorPrefix,
+	} {
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			r.errorf("server", env, "e.g. /api", "route prefix %q must start with /", prefix)
+		}
+	}
+	if _, err := s.LegacyAPISunsetTime(); err != nil {
+		r.errorf("server", "LEGACY_API_SUNSET", "use YYYY-MM-DD, e.g. 2027-06-30", "invalid sunset date %q", s.LegacyAPISunset)
+	}
+	if s.SSLEnabled {
+		checkFile(r, "server", "SSL_CERT_FILE", s.SSLCertFile, "TLS certificate")
+		checkFile(r, "server", "SSL_KEY_FILE", s.SSLKeyFile, "TLS private key")
+	}
+}
+
+func (c *Config) checkDatabase(r *Report) {
+	switch c.Database.Driver {
+	case "sqlite", "mysql", "postgres":
+	default:
+		r.errorf("database", "DB_DRIVER", "use sqlite, mysql or postgres", "unsupported driver %q", c.Database.Driver)
+	}
+	if c.Database.DSN == "" {
+		r.errorf("database", "DSN", "", "database DSN is required")
+	}
+}
+
+func (c *Config) checkAuth(r *Report) {
+	a := c.Auth
+	if strings.HasPrefix(a.SessionSecret, "default-secret-key-change-in-production-") {
+		if c.Server.Mode == "production" {
+			r.errorf("auth", "SESSION_SECRET", "set a long random value", "session secret is generated at startup, sessions are lost on every restart")
+		} else {
+			r.warnf("auth", "SESSION_SECRET", "set a long random value", "session secret is generated at startup")
+		}
+	} else if len(a.SessionSecret) < 16 {
+		r.warnf("auth", "SESSION_SECRET", "use at least 32 random characters", "session secret is short")
+	}
+	if days, err := strconv.Atoi(a.SecretExpireDays); err != nil || days <= 0 {
+		r.errorf("auth", "SESSION_EXPIRE_DAYS", "a positive number of days", "invalid session lifetime %q", a.SecretExpireDays)
+	}
+	switch a.PolicyEngine {
+	case "", "rules":
+	case "opa":
+		checkURL(r, "auth", "OPA_URL", a.OPAURL, "http", "https")
+		if strings.Trim(a.OPAPolicyPath, "/") == "" {
+			r.errorf("auth", "OPA_POLICY_PATH", "e.g. lingecho/authz", "OPA policy path is required")
+		}
+	default:
+		r.errorf("auth", "POLICY_ENGINE", "leave empty, or use rules or opa", "unknown policy engine %q", a.PolicyEngine)
+	}
+	if a.LoginAnomaly.ModelURL != "" {
+		checkURL(r, "auth", "LOGIN_ANOMALY_MODEL_URL", a.LoginAnomaly.ModelURL, "http", "https")
+	}
+	switch a.LoginAnomaly.Mode {
+	case "", utils.LoginAnomalyModeOff, utils.LoginAnomalyModeShadow, utils.LoginAnomalyModeEnforce:
+	default:
+		r.errorf("auth", "LOGIN_ANOMALY_MODE", "off, shadow or enforce", "unknown login anomaly mode %q", a.LoginAnomaly.Mode)
+	}
+	if t := a.LoginAnomaly.Threshold; t < 0 || t > 1 {
+		r.errorf("auth", "LOGIN_ANOMALY_THRESHOLD", "a score between 0 and 1", "invalid login anomaly threshold %v", t)
+	}
+	checkLoginRisk(r, a.LoginAnomaly)
+	switch a.Captcha.Provider {
+	case "", captcha.ProviderImage:
+	case captcha.ProviderTurnstile, captcha.ProviderHCaptcha, captcha.ProviderReCaptcha:
+		if a.Captcha.SiteKey == "" || a.Captcha.SecretKey == "" {
+			r.errorf("auth", "CAPTCHA_SITE_KEY / CAPTCHA_SECRET_KEY", "set both", "%s requires a site key and a secret key", a.Captcha.Provider)
+		}
+	default:
+		r.errorf("auth", "CAPTCHA_PROVIDER", "image, turnstile, hcaptcha or recaptcha", "unknown captcha provider %q", a.Captcha.Provider)
+	}
+	if s := a.Captcha.MinScore; s < 0 || s > 1 {
+		r.errorf("auth", "CAPTCHA_MIN_SCORE", "a score between 0 and 1", "invalid captcha score %v", s)
+	}
+}
+
+// checkLoginRisk validates the login risk thresholds, rule weights and IP blocklist
+func checkLoginRisk(r *Report, a utils.LoginAnomalyConfig) {
+	challenge, block := a.ChallengeThreshold, a.BlockThreshold
+	if challenge < 0 || challenge > 1 {
+		r.errorf("auth", "LOGIN_RISK_CHALLENGE_THRESHOLD", "a score between 0 and 1, 0 disables challenges", "invalid login risk challenge threshold %v", challenge)
+	}
+	if block < 0 || block > 1 {
+		r.errorf("auth", "LOGIN_RISK_BLOCK_THRESHOLD", "a score between 0 and 1, 0 disables blocking", "invalid login risk block threshold %v", block)
+	}
+	if challenge > 0 && block > 0 && block < challenge {
+		r.warnf("auth", "LOGIN_RISK_BLOCK_THRESHOLD", "set it above LOGIN_RISK_CHALLENGE_THRESHOLD", "logins are blocked at %v before any challenge at %v", block, challenge)
+	}
+	if _, err := utils.ParseLoginRiskWeights(a.RuleWeights); err != nil {
+		r.errorf("auth", "LOGIN_RISK_RULE_WEIGHTS", "comma-separated rule=weight pairs, e.g. impossible_travel=0.6", "%v", err)
+	}
+	if a.MaxTravelSpeed < 0 {
+		r.errorf("auth", "LOGIN_RISK_MAX_TRAVEL_SPEED", "a speed in km/h, e.g. 1000", "invalid max travel speed %v", a.MaxTravelSpeed)
+	}
+	if _, err := utils.ParseIPBlocklist(a.IPBlocklist); err != nil {
+		r.errorf("auth", "LOGIN_RISK_IP_BLOCKLIST", "comma-separated IPs or CIDRs", "%v", err)
+	}
+}
+
+func (c *Config) checkCache(r *Report) {
+	switch c.Cache.Type {
+	case "local":
+	case "redis":
+		checkHostPort(r, "cache", "REDIS_ADDR", c.Cache.Redis.Addr)
+	default:
+		r.errorf("cache", "CACHE_TYPE", "use local or redis", "unknown cache type %q", c.Cache.Type)
+	}
+}
+
+func (c *Config) checkQueue(r *Report) {
+	q := c.Queue
+	switch q.Backend {
+	case "", queue.BackendMemory:
+	case queue.BackendRedis:
+		checkHostPort(r, "queue", "REDIS_ADDR", c.Cache.Redis.Addr)
+	default:
+		r.errorf("queue", "QUEUE_BACKEND", "use memory or redis", "unknown queue backend %q", q.Backend)
+	}
+	if q.Workers < 0 {
+		r.errorf("queue", "QUEUE_WORKERS", "e.g. 4", "invalid worker count %d", q.Workers)
+	}
+	if q.MaxAttempts < 0 {
+		r.errorf("queue", "QUEUE_MAX_ATTEMPTS", "e.g. 5", "invalid max attempts %d", q.MaxAttempts)
+	}
+}
+
+func (c *Config) checkLLM(r *Report) {
+	l := c.Services.LLM
+	checkURL(r, "llm", "LLM_BASE_URL", l.BaseURL, "http", "https")
+	if l.APIKey == "" {
+		r.warnf("llm", "LLM_API_KEY", "assistants without their own credentials cannot chat", "default LLM API key is not set")
+	}
+}
+
+func (c *Config) checkMail(r *Report) {
+	m := c.Services.Mail
+	const hint = "verification codes and password reset emails will fail"
+	switch m.Provider {
+	case "smtp":
+		if m.Host == "" {
+			r.errorf("mail", "SMTP_HOST / MAIL_HOST", hint, "SMTP host is required")
+		}
+		if m.Port <= 0 || m.Port > 65535 {
+			r.errorf("mail", "SMTP_PORT / MAIL_PORT", "usually 465 or 587", "invalid SMTP port %d", m.Port)
+		}
+		if (m.Username == "") != (m.Password == "") {
+			r.errorf("mail", "SMTP_USERNAME / SMTP_PASSWORD", "set both or neither", "SMTP credentials are incomplete")
+		}
+	case "sendcloud":
+		switch {
+		case m.APIUser == "" && m.APIKey == "":
+			r.warnf("mail", "SENDCLOUD_API_USER / SENDCLOUD_API_KEY", hint, "no mail provider is configured")
+			return
+		case m.APIUser == "" || m.APIKey == "":
+			r.errorf("mail", "SENDCLOUD_API_USER / SENDCLOUD_API_KEY", "set both", "SendCloud credentials are incomplete")
+		}
+	default:
+		r.errorf("mail", "MAIL_PROVIDER", "use smtp or sendcloud", "unknown mail provider %q", m.Provider)
+		return
+	}
+	if m.From == "" {
+		r.errorf("mail", "MAIL_FROM_EMAIL", hint, "sender address is required")
+	} else if _, err := mail.ParseAddress(m.From); err != nil {
+		r.errorf("mail", "MAIL_FROM_EMAIL", "e.g. noreply@example.com", "invalid sender address %q", m.From)
+	}
+}
+
+func (c *Config) checkPush(r *Report) {
+	p := c.Services.Push
+	if p.FCMEnabled() {
+		checkFile(r, "push", "PUSH_FCM_CREDENTIALS_FILE", p.FCMCredentialsFile, "FCM service account")
+	}
+	if p.APNsEnabled() {
+		if p.APNsKeyID == "" || p.APNsTeamID == "" || p.APNsBundleID == "" {
+			r.errorf("push", "PUSH_APNS_KEY_ID / PUSH_APNS_TEAM_ID / PUSH_APNS_BUNDLE_ID", "set all three", "APNs settings are incomplete")
+		}
+		checkFile(r, "push", "PUSH_APNS_KEY_FILE", p.APNsKeyFile, "APNs signing key")
+	}
+}
+
+func (c *Config) checkSMS(r *Report) {
+	s := c.Services.SMS
+	switch s.Provider {
+	case "":
+	case notification.SMSProviderAliyun:
+		if s.AliyunAccessKeyID == "" || s.AliyunAccessKeySecret == "" {
+			r.errorf("sms", "SMS_ALIYUN_ACCESS_KEY_ID / SMS_ALIYUN_ACCESS_KEY_SECRET", "set both", "Aliyun SMS credentials are incomplete")
+		}
+		if s.AliyunSignName == "" || s.AliyunTemplateCode == "" {
+			r.errorf("sms", "SMS_ALIYUN_SIGN_NAME / SMS_ALIYUN_TEMPLATE_CODE", "template must have a ${code} variable", "Aliyun SMS sign name and template code are required")
+		}
+	case notification.SMSProviderTwilio:
+		if s.TwilioAccountSID == "" || s.TwilioAuthToken == "" || s.TwilioFrom == "" {
+			r.errorf("sms", "SMS_TWILIO_ACCOUNT_SID / SMS_TWILIO_AUTH_TOKEN / SMS_TWILIO_FROM", "set all three", "Twilio settings are incomplete")
+		}
+		if s.TwilioBody != "" && !strings.Contains(s.TwilioBody, "{code}") {
+			r.errorf("sms", "SMS_TWILIO_BODY", "e.g. Your code is {code}", "message body must contain {code}")
+		}
+	default:
+		r.errorf("sms", "SMS_PROVIDER", "use aliyun or twilio", "unknown SMS provider %q", s.Provider)
+	}
+}
+
+func (c *Config) checkKnowledgeBase(r *Report) {
+	kb := c.Services.KnowledgeBase
+	if kb.Neo4j.Enabled {
+		checkURL(r, "neo4j", "NEO4J_URI", kb.Neo4j.URI, "bolt", "bolt+s", "bolt+ssc", "neo4j", "neo4j+s", "neo4j+ssc")
+		if kb.Neo4j.Password == "" {
+			r.warnf("neo4j", "NEO4J_PASSWORD", "", "Neo4j password is empty")
+		}
+	}
+	if !kb.Enabled {
+		return
+	}
+
+	// Providers are chosen per knowledge base, so only providers that have credentials are checked
+	configured := 0
+	if kb.Bailian.AccessKeyId != "" || kb.Bailian.AccessKeySecret != "" {
+		configured++
+		if kb.Bailian.AccessKeyId == "" || kb.Bailian.AccessKeySecret == "" {
+			r.errorf("knowledge_base", "BAILIAN_ACCESS_KEY_ID / BAILIAN_ACCESS_KEY_SECRET", "set both", "Bailian credentials are incomplete")
+		}
+		if kb.Bailian.WorkspaceId == "" {
+			r.errorf("knowledge_base", "BAILIAN_WORKSPACE_ID", "", "Bailian workspace ID is required")
+		}
+		if kb.Bailian.Endpoint != "" && strings.Contains(kb.Bailian.Endpoint, "://") {
+			r.errorf("knowledge_base", "BAILIAN_ENDPOINT", "e.g. bailian.cn-beijing.aliyuncs.com", "endpoint must be a host name without scheme")
+		}
+	}
+	if kb.Milvus.Collection != "" {
+		configured++
+		checkHostPort(r, "knowledge_base", "MILVUS_ADDRESS", kb.Milvus.Address)
+		checkDimension(r, "MILVUS_DIMENSION", kb.Milvus.Dimension)
+	}
+	if kb.Qdrant.Collection != "" {
+		configured++
+		checkURL(r, "knowledge_base", "QDRANT_BASE_URL", kb.Qdrant.BaseURL, "http", "https")
+		checkDimension(r, "QDRANT_DIMENSION", kb.Qdrant.Dimension)
+	}
+	if kb.Elasticsearch.Index != "" {
+		configured++
+		checkURL(r, "knowledge_base", "ELASTICSEARCH_BASE_URL", kb.Elasticsearch.BaseURL, "http", "https")
+		if (kb.Elasticsearch.Username == "") != (kb.Elasticsearch.Password == "") {
+			r.errorf("knowledge_base", "ELASTICSEARCH_USERNAME / ELASTICSEARCH_PASSWORD", "set both or neither", "Elasticsearch credentials are incomplete")
+		}
+	}
+	if kb.Pinecone.APIKey != "" {
+		configured++
+		if kb.Pinecone.IndexName == "" {
+			r.errorf("knowledge_base", "PINECONE_INDEX_NAME", "", "Pinecone index name is required when the API key is set")
+		}
+		checkURL(r, "knowledge_base", "PINECONE_BASE_URL", kb.Pinecone.BaseURL, "https")
+		checkDimension(r, "PINECONE_DIMENSION", kb.Pinecone.Dimension)
+	}
+	if configured == 0 {
+		r.errorf("knowledge_base", "KNOWLEDGE_BASE_ENABLED",
+			"configure Bailian credentials, MILVUS_COLLECTION, QDRANT_COLLECTION, ELASTICSEARCH_INDEX or PINECONE_API_KEY, or disable the knowledge base",
+			"knowledge base is enabled but no provider is configured")
+	}
+}
+
+func (c *Config) checkVoice(r *Report) {
+	v := c.Services.Voice
+	if (v.Qiniu.ASRAPIKey != "") != (v.Qiniu.ASRBaseURL != "") {
+		r.errorf("voice", "QINIU_ASR_API_KEY / QINIU_ASR_BASE_URL", "set both", "Qiniu ASR settings are incomplete")
+	} else if v.Qiniu.ASRBaseURL != "" {
+		checkURL(r, "voice", "QINIU_ASR_BASE_URL", v.Qiniu.ASRBaseURL, "http", "https", "ws", "wss")
+	}
+	if (v.Qiniu.TTSAPIKey != "") != (v.Qiniu.TTSBaseURL != "") {
+		r.errorf("voice", "QINIU_TTS_API_KEY / QINIU_TTS_BASE_URL", "set both", "Qiniu TTS settings are incomplete")
+	} else if v.Qiniu.TTSBaseURL != "" {
+		checkURL(r, "voice", "QINIU_TTS_BASE_URL", v.Qiniu.TTSBaseURL, "http", "https", "ws", "wss")
+	}
+	x := v.Xunfei
+	if set := countSet(x.WSAppId, x.WSAPIKey, x.WSAPISecret); set > 0 && set < 3 {
+		r.errorf("voice", "XUNFEI_WS_APP_ID / XUNFEI_WS_API_KEY / XUNFEI_WS_API_SECRET", "set all three", "Xunfei credentials are incomplete")
+	}
+	vp := v.Voiceprint
+	if vp.Enabled {
+		checkURL(r, "voiceprint", "VOICEPRINT_BASE_URL", vp.BaseURL, "http", "https")
+		if vp.SimilarityThreshold <= 0 || vp.SimilarityThreshold > 1 {
+			r.errorf("voiceprint", "VOICEPRINT_SIMILARITY_THRESHOLD", "between 0 and 1, e.g. 0.6", "invalid similarity threshold %v", vp.SimilarityThreshold)
+		}
+		if vp.Timeout <= 0 {
+			r.errorf("voiceprint", "VOICEPRINT_TIMEOUT", "e.g. 30s", "timeout must be positive")
+		}
+	}
+}
+
+func (c *Config) checkStorage(r *Report) {
+	s := c.Services.Storage
+	checkURL(r, "storage", "LINGSTORAGE_BASE_URL", s.BaseURL, "http", "https")
+	switch {
+	case s.APIKey == "" && s.APISecret == "":
+		r.warnf("storage", "LINGSTORAGE_API_KEY / LINGSTORAGE_API_SECRET", "uploads and recordings cannot be stored remotely", "object storage credentials are not set")
+	case s.APIKey == "" || s.APISecret == "":
+		r.errorf("storage", "LINGSTORAGE_API_KEY / LINGSTORAGE_API_SECRET", "set both", "object storage credentials are incomplete")
+	}
+	if s.Bucket == "" {
+		r.errorf("storage", "LINGSTORAGE_BUCKET", "", "bucket is required")
+	}
+}
+
+func (c *Config) checkIntegrations(r *Report) {
+	c.checkOAuthLogin(r)
+	c.checkOIDC(r)
+	g := c.Integrations.GoogleCalendar
+	if g.ClientID == "" && g.ClientSecret == "" {
+		return
+	}
+	if g.ClientID == "" || g.ClientSecret == "" {
+		r.errorf("google_calendar", "GOOGLE_CALENDAR_CLIENT_ID / GOOGLE_CALENDAR_CLIENT_SECRET", "set both", "Google Calendar OAuth client is incomplete")
+	}
+	if g.RedirectURL != "" {
+		checkURL(r, "google_calendar", "GOOGLE_CALENDAR_REDIRECT_URL", g.RedirectURL, "http", "https")
+	} else if c.Server.URL == "" {
+		r.errorf("google_calendar", "GOOGLE_CALENDAR_REDIRECT_URL", "or set SERVER_URL", "OAuth redirect URL cannot be derived")
+	}
+}
+
+func (c *Config) checkOAuthLogin(r *Report) {
+	o := c.Integrations.OAuthLogin
+	clients := []struct{ env, id, secret string }{
+		{"OAUTH_GOOGLE_CLIENT_ID / OAUTH_GOOGLE_CLIENT_SECRET", o.GoogleClientID, o.GoogleClientSecret},
+		{"OAUTH_GITHUB_CLIENT_ID / OAUTH_GITHUB_CLIENT_SECRET", o.GitHubClientID, o.GitHubClientSecret},
+		{"OAUTH_WECHAT_APP_ID / OAUTH_WECHAT_APP_SECRET", o.WeChatAppID, o.WeChatAppSecret},
+	}
+	enabled := false
+	for _, client := range clients {
+		if (client.id == "") != (client.secret == "") {
+			r.errorf("oauth_login", client.env, "set both", "social login client is incomplete")
+		}
+		enabled = enabled || client.id != ""
+	}
+	if enabled && c.Server.URL == "" {
+		r.warnf("oauth_login", "SERVER_URL", "", "callback URLs are derived from the request host; set SERVER_URL behind a proxy")
+	}
+}
+
+func (c *Config) checkOIDC(r *Report) {
+	o := c.Integrations.OIDC
+	if o.Issuer == "" && o.ClientID == "" {
+		return
+	}
+	if o.Issuer == "" || o.ClientID == "" {
+		r.errorf("oidc", "OIDC_ISSUER / OIDC_CLIENT_ID", "set both", "OIDC client is incomplete")
+		return
+	}
+	checkURL(r, "oidc", "OIDC_ISSUER", o.Issuer, "https", "http")
+	if !strings.Contains(" "+o.Scopes+" ", " openid ") {
+		r.errorf("oidc", "OIDC_SCOPES", "include openid, e.g. openid email profile", "OIDC scopes %q do not request an ID token", o.Scopes)
+	}
+	if _, err := oauth.ParseGroupMapping(o.GroupMapping); err != nil {
+		r.errorf("oidc", "OIDC_GROUP_MAPPING", "comma-separated idp-group=groupID[:admin|member]", "%v", err)
+	}
+	if o.GroupMapping != "" && o.GroupsClaim == "" {
+		r.errorf("oidc", "OIDC_GROUPS_CLAIM", "e.g. groups", "group mapping needs the claim listing the user's groups")
+	}
+	if c.Server.URL == "" {
+		r.warnf("oidc", "SERVER_URL", "", "the redirect URI is derived from the request host; set SERVER_URL behind a proxy")
+	}
+}
+
+func (c *Config) checkFeatures(r *Report) {
+	f := c.Features
+	if f.SearchEnabled && f.SearchPath == "" {
+		r.errorf("features", "SEARCH_PATH", "", "search index path is required when search is enabled")
+	}
+	if f.BackupEnabled {
+		if f.BackupPath == "" {
+			r.errorf("features", "BACKUP_PATH", "", "backup path is required when backups are enabled")
+		}
+		if len(strings.Fields(f.BackupSchedule)) != 5 {
+			r.errorf("features", "BACKUP_SCHEDULE", "five-field cron expression, e.g. 0 2 * * *", "invalid backup schedule %q", f.BackupSchedule)
+		}
+	}
+}
+
+func checkURL(r *Report, section, env, raw string, schemes ...string) {
+	if raw == "" {
+		r.errorf(section, env, "", "URL is required")
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		r.errorf(section, env, "use an absolute URL such as "+schemes[0]+"://host", "invalid URL %q", raw)
+		return
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	r.errorf(section, env, "use "+strings.Join(schemes, ", "), "unsupported URL scheme %q", u.Scheme)
+}
+
+func checkHostPort(r *Report, section, env, addr string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" || !validPort(port) {
+		r.errorf(section, env, "use host:port", "invalid address %q", addr)
+	}
+}
+
+func checkFile(r *Report, section, env, path, what string) {
+	if path == "" {
+		r.errorf(section, env, "", "%s file is required", what)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		r.errorf(section, env, "", "%s file %s is not readable: %v", what, path, err)
+	}
+}
+
+func checkDimension(r *Report, env string, dimension int) {
+	if dimension <= 0 {
+		r.errorf("knowledge_base", env, "must match the embedding model, e.g. 768", "invalid vector dimension %d", dimension)
+	}
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// probeTarget a dependency reachable over TCP
+type probeTarget struct {
+	section string
+	env     string
+	addr    string
+}
+
+// Probe dials the external dependencies of the enabled features and reports
+// the unreachable ones. Only TCP reachability is checked, not credentials.
+func (c *Config) Probe(ctx context.Context, timeout time.Duration) *Report {
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	r := &Report{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, target := range c.probeTargets() {
+		wg.Add(1)
+		go func(t probeTarget) {
+			defer wg.Done()
+			dialer := net.Dialer{Timeout: timeout}
+			conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+			if err == nil {
+				_ = conn.Close()
+				return
+			}
+			mu.Lock()
+			r.errorf(t.section, t.env, "check the address, firewall and that the service is running", "cannot connect to %s: %v", t.addr, err)
+			mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+	sort.SliceStable(r.Issues, func(i, j int) bool { return r.Issues[i].Section < r.Issues[j].Section })
+	return r
+}
+
+func (c *Config) probeTargets() []probeTarget {
+	var targets []probeTarget
+	addURL := func(section, env, raw string) {
+		if addr := urlHostPort(raw); addr != "" {
+			targets = append(targets, probeTarget{section, env, addr})
+		}
+	}
+	if c.Cache.Type == "redis" {
+		targets = append(targets, probeTarget{"cache", "REDIS_ADDR", c.Cache.Redis.Addr})
+	}
+	if c.Auth.PolicyEngine == "opa" {
+		addURL("auth", "OPA_URL", c.Auth.OPAURL)
+	}
+	if c.Auth.LoginAnomaly.ModelURL != "" {
+		addURL("auth", "LOGIN_ANOMALY_MODEL_URL", c.Auth.LoginAnomaly.ModelURL)
+	}
+	if c.Integrations.OIDC.Enabled() {
+		addURL("oidc", "OIDC_ISSUER", c.Integrations.OIDC.Issuer)
+	}
+	if m := c.Services.Mail; m.Provider == "smtp" && m.Host != "" {
+		targets = append(targets, probeTarget{"mail", "SMTP_HOST / SMTP_PORT", net.JoinHostPort(m.Host, strconv.FormatInt(m.Port, 10))})
+	}
+	kb := c.Services.KnowledgeBase
+	if kb.Neo4j.Enabled {
+		addURL("neo4j", "NEO4J_URI", kb.Neo4j.URI)
+	}
+	if kb.Enabled {
+		if kb.Milvus.Collection != "" {
+			targets = append(targets, probeTarget{"knowledge_base", "MILVUS_ADDRESS", kb.Milvus.Address})
+		}
+		if kb.Qdrant.Collection != "" {
+			addURL("knowledge_base", "QDRANT_BASE_URL", kb.Qdrant.BaseURL)
+		}
+		if kb.Elasticsearch.Index != "" {
+			addURL("knowledge_base", "ELASTICSEARCH_BASE_URL", kb.Elasticsearch.BaseURL)
+		}
+	}
+	if c.Services.Voice.Voiceprint.Enabled {
+		addURL("voiceprint", "VOICEPRINT_BASE_URL", c.Services.Voice.Voiceprint.BaseURL)
+	}
+	return targets
+}
+
+// urlHostPort host:port of a URL, with the default port of its scheme
+func urlHostPort(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	defaults := map[string]string{
+		"http": "80", "https": "443", "ws": "80", "wss": "443",
+		"bolt": "7687", "bolt+s": "7687", "bolt+ssc": "7687", "neo4j": "7687", "neo4j+s": "7687", "neo4j+ssc": "7687",
+	}
+	port, ok := defaults[u.Scheme]
+	if !ok {
+		return ""
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/captcha"
+	"github.com/code-100-precent/LingEcho/pkg/notification"
+	"github.com/code-100-precent/LingEcho/pkg/queue"
+	"github.com/code-100-precent/LingEcho/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr: ":7072", Mode: "production", URL: "https://echo.example.com",
+			APIPrefix: "/api", AuthPrefix: "/auth",
+		},
+		Database: DatabaseConfig{Driver: "sqlite", DSN: "./ling.db"},
+		Auth:     AuthConfig{SessionSecret: "a-long-and-random-session-secret", SecretExpireDays: "7"},
+		Cache:    cache.Config{Type: "local"},
+		Services: ServicesConfig{
+			LLM: LLMConfig{APIKey: "ak", BaseURL: "https://api.openai.com/v1"},
+			Mail: notification.MailConfig{
+				Provider: "smtp", Host: "smtp.example.com", Port: 587,
+				Username: "bot", Password: "pw", From: "noreply@example.com",
+			},
+			Storage: StorageConfig{BaseURL: "https://api.lingstorage.com", APIKey: "k", APISecret: "s", Bucket: "default"},
+		},
+	}
+}
+
+func TestCheck_Valid(t *testing.T) {
+	report := validConfig().Check()
+	assert.Empty(t, report.Issues, report.String())
+	assert.NoError(t, validConfig().Validate())
+}
+
+func TestCheck_ReportsEveryProblem(t *testing.T) {
+	c := validConfig()
+	c.Auth.SessionSecret = "default-secret-key-change-in-production-abc"
+	c.Services.Mail.Host = ""
+	c.Services.Mail.From = "not-an-email"
+	c.Services.KnowledgeBase.Enabled = true
+	c.Services.KnowledgeBase.Neo4j = Neo4jConfig{Enabled: true, URI: "http://localhost:7474"}
+	c.Cache = cache.Config{Type: "redis", Redis: cache.RedisConfig{Addr: "localhost"}}
+
+	report := c.Check()
+	require.True(t, report.HasErrors())
+	envs := map[string]bool{}
+	for _, issue := range report.Errors() {
+		envs[issue.Env] = true
+	}
+	for _, env := range []string{"SESSION_SECRET", "SMTP_HOST / MAIL_HOST", "MAIL_FROM_EMAIL", "KNOWLEDGE_BASE_ENABLED", "NEO4J_URI", "REDIS_ADDR"} {
+		assert.True(t, envs[env], "missing error for %s", env)
+	}
+	assert.NotEmpty(t, report.Warnings(), "empty Neo4j password")
+
+	err := c.Validate()
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "configuration check: 6 error(s)"), err.Error())
+}
+
+func TestCheck_KnowledgeBaseProviders(t *testing.T) {
+	c := validConfig()
+	c.Services.KnowledgeBase = KnowledgeBaseConfig{
+		Enabled:  true,
+		Bailian:  BailianConfig{AccessKeyId: "id"},
+		Pinecone: PineconeConfig{APIKey: "pk", BaseURL: "https://api.pinecone.io", Dimension: 1536},
+	}
+	report := c.Check()
+	var messages []string
+	for _, issue := range report.Errors() {
+		messages = append(messages, issue.Message)
+	}
+	assert.ElementsMatch(t, []string{
+		"Bailian credentials are incomplete",
+		"Bailian workspace ID is required",
+		"Pinecone index name is required when the API key is set",
+	}, messages)
+}
+
+func TestCheck_Push(t *testing.T) {
+	c := validConfig()
+	c.Services.Push = notification.PushConfig{APNsKeyID: "k", APNsKeyFile: "/nonexistent/AuthKey.p8"}
+	report := c.Check()
+	var envs []string
+	for _, issue := range report.Errors() {
+		envs = append(envs, issue.Env)
+	}
+	assert.ElementsMatch(t, []string{"PUSH_APNS_KEY_ID / PUSH_APNS_TEAM_ID / PUSH_APNS_BUNDLE_ID", "PUSH_APNS_KEY_FILE"}, envs)
+}
+
+func TestCheck_SMS(t *testing.T) {
+	c := validConfig()
+	c.Services.SMS = notification.SMSConfig{Provider: notification.SMSProviderTwilio, TwilioAccountSID: "AC1", TwilioBody: "hello"}
+	report := c.Check()
+	var envs []string
+	for _, issue := range report.Errors() {
+		envs = append(envs, issue.Env)
+	}
+	assert.ElementsMatch(t, []string{"SMS_TWILIO_ACCOUNT_SID / SMS_TWILIO_AUTH_TOKEN / SMS_TWILIO_FROM", "SMS_TWILIO_BODY"}, envs)
+
+	c.Services.SMS = notification.SMSConfig{Provider: "sms77"}
+	assert.Len(t, c.Check().Errors(), 1)
+
+	c.Services.SMS = notification.SMSConfig{Provider: notification.SMSProviderAliyun, AliyunAccessKeyID: "k", AliyunAccessKeySecret: "s", AliyunSignName: "LingEcho", AliyunTemplateCode: "SMS_1"}
+	assert.Empty(t, c.Check().Issues)
+}
+
+func TestCheck_Queue(t *testing.T) {
+	c := validConfig()
+	c.Queue = queue.Config{Backend: "kafka", Workers: -1}
+	report := c.Check()
+	var envs []string
+	for _, issue := range report.Errors() {
+		envs = append(envs, issue.Env)
+	}
+	assert.ElementsMatch(t, []string{"QUEUE_BACKEND", "QUEUE_WORKERS"}, envs)
+
+	c.Queue = queue.Config{Backend: queue.BackendRedis}
+	assert.Len(t, c.Check().Errors(), 1, "redis backend needs REDIS_ADDR")
+
+	c.Cache.Redis.Addr = "localhost:6379"
+	assert.Empty(t, c.Check().Issues)
+}
+
+func TestCheck_Captcha(t *testing.T) {
+	c := validConfig()
+	c.Auth.Captcha = captcha.Config{Provider: captcha.ProviderTurnstile, SiteKey: "0x4AAA", MinScore: 2}
+	report := c.Check()
+	var envs []string
+	for _, issue := range report.Errors() {
+		envs = append(envs, issue.Env)
+	}
+	assert.ElementsMatch(t, []string{"CAPTCHA_SITE_KEY / CAPTCHA_SECRET_KEY", "CAPTCHA_MIN_SCORE"}, envs)
+
+	c.Auth.Captcha = captcha.Config{Provider: "geetest"}
+	assert.Len(t, c.Check().Errors(), 1)
+
+	c.Auth.Captcha = captcha.Config{Provider: captcha.ProviderHCaptcha, SiteKey: "site", SecretKey: "secret"}
+	assert.Empty(t, c.Check().Issues)
+}
+
+func TestCheck_LoginAnomaly(t *testing.T) {
+	c := validConfig()
+	c.Auth.LoginAnomaly = utils.LoginAnomalyConfig{ModelURL: "model:8080", Mode: "block", Threshold: 1.5}
+	report := c.Check()
+	var envs []string
+	for _, issue := range report.Errors() {
+		envs = append(envs, issue.Env)
+	}
+	assert.ElementsMatch(t, []string{"LOGIN_ANOMALY_MODEL_URL", "LOGIN_ANOMALY_MODE", "LOGIN_ANOMALY_THRESHOLD"}, envs)
+
+	c.Auth.LoginAnomaly = utils.LoginAnomalyConfig{ModelURL: "http://model:8080/score", Mode: utils.LoginAnomalyModeShadow, Threshold: 0.7}
+	assert.Empty(t, c.Check().Issues)
+}
+
+func TestCheck_LoginRisk(t *testing.T) {
+	c := validConfig()
+	c.Auth.LoginAnomaly = utils.LoginAnomalyConfig{ChallengeThreshold: 1.2, BlockThreshold: 0.9, RuleWeights: "vpn=0.3", IPBlocklist: "203.0.113.0/99"}
+	report := c.Check()
+	var envs []string
+	for _, issue := range report.Errors() {
+		envs = append(envs, issue.Env)
+	}
+	assert.ElementsMatch(t, []string{"LOGIN_RISK_CHALLENGE_THRESHOLD", "LOGIN_RISK_RULE_WEIGHTS", "LOGIN_RISK_IP_BLOCKLIST"}, envs)
+
+	c.Auth.LoginAnomaly = utils.LoginAnomalyConfig{ChallengeThreshold: 0.8, BlockThreshold: 0.5}
+	report = c.Check()
+	assert.Empty(t, report.Errors())
+	require.Len(t, report.Warnings(), 1)
+	assert.Equal(t, "LOGIN_RISK_BLOCK_THRESHOLD", report.Warnings()[0].Env)
+
+	c.Auth.LoginAnomaly = utils.LoginAnomalyConfig{ChallengeThreshold: 0.7, BlockThreshold: 0.9, RuleWeights: "impossible_travel=0.6,hosting=0", IPBlocklist: "203.0.113.0/24"}
+	assert.Empty(t, c.Check().Issues)
+}
+
+func TestCheck_OIDC(t *testing.T) {
+	c := validConfig()
+	c.Integrations.OIDC = OIDCConfig{Issuer: "https://login.example.com"}
+	report := c.Check()
+	require.Len(t, report.Errors(), 1)
+	assert.Equal(t, "OIDC_ISSUER / OIDC_CLIENT_ID", report.Errors()[0].Env)
+
+	c.Integrations.OIDC = OIDCConfig{Issuer: "login.example.com", ClientID: "lingecho", Scopes: "email profile", GroupMapping: "engineering=abc"}
+	var envs []string
+	for _, issue := range c.Check().Errors() {
+		envs = append(envs, issue.Env)
+	}
+	assert.ElementsMatch(t, []string{"OIDC_ISSUER", "OIDC_SCOPES", "OIDC_GROUP_MAPPING", "OIDC_GROUPS_CLAIM"}, envs)
+
+	c.Integrations.OIDC = OIDCConfig{
+		Issuer: "https://login.example.com/realms/corp", ClientID: "lingecho", Scopes: "openid email profile",
+		GroupsClaim: "groups", GroupMapping: "engineering=12,platform-admins=12:admin",
+	}
+	assert.Empty(t, c.Check().Issues)
+}
+
+func TestCheck_OAuthLogin(t *testing.T) {
+	c := validConfig()
+	c.Integrations.OAuthLogin = OAuthLoginConfig{GitHubClientID: "id", WeChatAppID: "wx", WeChatAppSecret: "s"}
+	report := c.Check()
+	require.Len(t, report.Errors(), 1)
+	assert.Equal(t, "OAUTH_GITHUB_CLIENT_ID / OAUTH_GITHUB_CLIENT_SECRET", report.Errors()[0].Env)
+
+	c.Integrations.OAuthLogin.GitHubClientSecret = "secret"
+	assert.Empty(t, c.Check().Issues)
+}
+
+func TestProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	c := validConfig()
+	c.Cache = cache.Config{Type: "redis", Redis: cache.RedisConfig{Addr: ln.Addr().String()}}
+	c.Services.Mail = notification.MailConfig{Provider: "sendcloud"}
+	c.Services.Voice.Voiceprint = VoiceprintConfig{Enabled: true, BaseURL: "http://" + closedAddr}
+
+	report := c.Probe(context.Background(), time.Second)
+	require.Len(t, report.Issues, 1, report.String())
+	assert.Equal(t, "VOICEPRINT_BASE_URL", report.Issues[0].Env)
+}
+
+func TestURLHostPort(t *testing.T) {
+	assert.Equal(t, "neo4j.local:7687", urlHostPort("bolt://neo4j.local"))
+	assert.Equal(t, "example.com:443", urlHostPort("https://example.com/v1"))
+	assert.Equal(t, "example.com:8080", urlHostPort("http://example.com:8080"))
+	assert.Equal(t, "", urlHostPort("localhost:19530"))
+}
+
+func TestCheck_LegacyAPISunset(t *testing.T) {
+	c := validConfig()
+	c.Server.LegacyAPISunset = "30/06/2027"
+	report := c.Check()
+	require.Len(t, report.Errors(), 1, report.String())
+	assert.Equal(t, "LEGACY_API_SUNSET", report.Errors()[0].Env)
+
+	c.Server.LegacyAPISunset = "2027-06-30"
+	assert.Empty(t, c.Check().Issues)
+	sunset, err := c.Server.LegacyAPISunsetTime()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC), sunset)
+}
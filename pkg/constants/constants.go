@@ -28,13 +28,24 @@ const ENV_DB_DRIVER = "DB_DRIVER"
 const ENV_DSN = "DSN"
 const DbField = "_lingecho_db"
 const UserField = "_lingecho_uid"
+const LoginAtField = "_lingecho_login_at"
 const GroupField = "_lingecho_gid"
 const TzField = "_lingecho_tz"
 const AssetsField = "_lingecho_assets"
 const TemplatesField = "_lingecho_templates"
+const DomainField = "_lingecho_domain"
+const CredentialField = "_lingecho_credential"
+
+// SigCustomDomainVerified: domain *CustomDomain, db *gorm.DB
+const SigCustomDomainVerified = "domain.verified"
+
+// SigDeviceActionExecute: action *DeviceAction, db *gorm.DB
+// A device action is due, emitted right away or when the fleet's maintenance window opens
+const SigDeviceActionExecute = "device.action.execute"
 
 const KEY_VERIFY_EMAIL_EXPIRED = "VERIFY_EMAIL_EXPIRED"
 const KEY_AUTH_TOKEN_EXPIRED = "AUTH_TOKEN_EXPIRED"
+const KEY_ACCESS_TOKEN_EXPIRED = "ACCESS_TOKEN_EXPIRED"
 const KEY_SITE_NAME = "SITE_NAME"
 const KEY_SITE_ADMIN = "SITE_ADMIN"
 const KEY_SITE_URL = "SITE_URL"
@@ -72,6 +83,20 @@ const KEY_VOICE_CLONE_VOLCENGINE_CONFIG = "VOICE_CLONE_VOLCENGINE_CONFIG"
 const KEY_VOICEPRINT_ENABLED = "VOICEPRINT_ENABLED"
 const KEY_VOICEPRINT_CONFIG = "VOICEPRINT_CONFIG"
 
+// Cost estimation price table (JSON)
+const KEY_PRICE_TABLE = "PRICE_TABLE"
+
+// Semantic LLM response cache: switch, similarity threshold (percent) and freshness TTL (seconds)
+const KEY_LLM_CACHE_ENABLED = "LLM_CACHE_ENABLED"
+const KEY_LLM_CACHE_THRESHOLD = "LLM_CACHE_THRESHOLD"
+const KEY_LLM_CACHE_TTL = "LLM_CACHE_TTL"
+
+// Device microphone tests scoring below this (0-100) flag the device for replacement
+const KEY_AUDIO_QUALITY_THRESHOLD = "AUDIO_QUALITY_THRESHOLD"
+
+// Actions (comma separated, "*" for all) unverified email addresses may not perform
+const KEY_EMAIL_VERIFICATION_REQUIRED = "EMAIL_VERIFICATION_REQUIRED"
+
 // OTA and device configuration keys
 const KEY_SERVER_WEBSOCKET = "server.websocket"
 const KEY_SERVER_MQTT_GATEWAY = "server.mqtt_gateway"
@@ -21,6 +21,10 @@ const (
 	SigUserChangeEmailDone = "user.changeemaildone"
 	//SigUserNewDeviceLogin: user *User, deviceInfo map[string]interface{}, db *gorm.DB
 	SigUserNewDeviceLogin = "user.newdevicelogin"
+	//SigUserAccountUnlockRequest: user *User, lock *AccountLock, token string, db *gorm.DB
+	SigUserAccountUnlockRequest = "user.accountunlockrequest"
+	//SigUserAccountUnlocked: user *User, lock *AccountLock, db *gorm.DB
+	SigUserAccountUnlocked = "user.accountunlocked"
 )
 
 // 缓存键前缀
@@ -0,0 +1,272 @@
+// Package diarization groups the human turns of a call recording by speaker.
+// Every turn is reduced to a voice fingerprint, its average log spectrum over
+// the speech bands with the loudness removed, and turns with similar
+// fingerprints are clustered agglomeratively. It needs no enrolled voices,
+// which suits conference and transferred calls where the humans are unknown.
+package diarization
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/dsp"
+)
+
+const (
+	// DefaultThreshold clusters closer than this cosine distance are the same speaker
+	DefaultThreshold = 0.15
+	// DefaultMaxSpeakers upper bound of speakers in one call
+	DefaultMaxSpeakers = 8
+	// MinSpeech turns with less voiced audio than this are left unlabeled
+	MinSpeech = 300 * time.Millisecond
+
+	frameSeconds = 0.032
+	bandCount    = 20
+	lowHz        = 80.0
+	highHz       = 4000.0
+	voicedDB     = 30.0 // frames this far below the loudest frame of a turn are silence
+	silenceLevel = 1e-6
+)
+
+var ErrInvalidSampleRate = errors.New("invalid sample rate")
+
+// Span position of a human turn in the recording
+type Span struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Options tune the clustering; zero values use the defaults
+type Options struct {
+	Threshold   float64
+	MaxSpeakers int
+}
+
+// Result speaker of every span
+type Result struct {
+	// Labels 0-based speaker of each span in order of first appearance, -1 when
+	// the span has too little speech to tell
+	Labels   []int `json:"labels"`
+	Speakers int   `json:"speakers"`
+}
+
+// Diarize assigns a speaker to every span of the recording
+func Diarize(samples []int16, sampleRate int, spans []Span, opts Options) (*Result, error) {
+	if sampleRate <= 0 {
+		return nil, ErrInvalidSampleRate
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.MaxSpeakers <= 0 {
+		opts.MaxSpeakers = DefaultMaxSpeakers
+	}
+
+	result := &Result{Labels: make([]int, len(spans))}
+	var prints [][]float64
+	var owners []int // span index of each fingerprint
+	for i, span := range spans {
+		result.Labels[i] = -1
+		start := clampSample(span.Start, sampleRate, len(samples))
+		end := clampSample(span.End, sampleRate, len(samples))
+		if end < start {
+			end = start
+		}
+		if fp := fingerprint(samples[start:end], sampleRate); fp != nil {
+			prints = append(prints, fp)
+			owners = append(owners, i)
+		}
+	}
+	if len(prints) == 0 {
+		return result, nil
+	}
+
+	clusters := cluster(prints, opts.Threshold, opts.MaxSpeakers)
+	// Number speakers by their first turn so labels read naturally
+	speakerOf := map[int]int{}
+	for i, c := range clusters {
+		if _, ok := speakerOf[c]; !ok {
+			speakerOf[c] = len(speakerOf)
+		}
+		result.Labels[owners[i]] = speakerOf[c]
+	}
+	result.Speakers = len(speakerOf)
+	return result, nil
+}
+
+func clampSample(d time.Duration, sampleRate, n int) int {
+	i := int(d.Seconds() * float64(sampleRate))
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// fingerprint average log band energies of the voiced frames, mean removed and
+// normalized to unit length; nil when the span has too little speech
+func fingerprint(samples []int16, sampleRate int) []float64 {
+	size := 1
+	for size < int(frameSeconds*float64(sampleRate)) {
+		size <<= 1
+	}
+	hop := size / 2
+	if len(samples) < size {
+		return nil
+	}
+
+	window := dsp.HannWindow(size)
+	edges := bandEdges(sampleRate, size)
+	buf := make([]complex128, size)
+
+	var frames [][]float64
+	var energies []float64
+	loudest := 0.0
+	for start := 0; start+size <= len(samples); start += hop {
+		var energy float64
+		for i := range buf {
+			v := float64(samples[start+i]) / 32768
+			energy += v * v
+			buf[i] = complex(v*window[i], 0)
+		}
+		energy /= float64(size)
+		if energy < silenceLevel {
+			continue
+		}
+		dsp.FFT(buf)
+		bands := make([]float64, bandCount)
+		for b := 0; b < bandCount; b++ {
+			var sum float64
+			for k := edges[b]; k < edges[b+1]; k++ {
+				a := cmplx.Abs(buf[k])
+				sum += a * a
+			}
+			bands[b] = math.Log10(sum/float64(edges[b+1]-edges[b]) + 1e-12)
+		}
+		frames = append(frames, bands)
+		energies = append(energies, energy)
+		loudest = math.Max(loudest, energy)
+	}
+
+	fp := make([]float64, bandCount)
+	voiced := 0
+	floor := loudest * math.Pow(10, -voicedDB/10)
+	for i, bands := range frames {
+		if energies[i] < floor {
+			continue
+		}
+		for b := range fp {
+			fp[b] += bands[b]
+		}
+		voiced++
+	}
+	if time.Duration(float64(voiced*hop)/float64(sampleRate)*float64(time.Second)) < MinSpeech {
+		return nil
+	}
+
+	var mean float64
+	for b := range fp {
+		fp[b] /= float64(voiced)
+		mean += fp[b]
+	}
+	mean /= bandCount
+	var norm float64
+	for b := range fp {
+		fp[b] -= mean
+		norm += fp[b] * fp[b]
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+	for b := range fp {
+		fp[b] /= norm
+	}
+	return fp
+}
+
+// bandEdges FFT bins bounding logarithmically spaced bands, every band at least one bin wide
+func bandEdges(sampleRate, size int) []int {
+	top := math.Min(highHz, float64(sampleRate)/2*0.9)
+	binHz := float64(sampleRate) / float64(size)
+	edges := make([]int, bandCount+1)
+	for b := range edges {
+		hz := lowHz * math.Pow(top/lowHz, float64(b)/bandCount)
+		edges[b] = int(math.Round(hz / binHz))
+		if b > 0 && edges[b] <= edges[b-1] {
+			edges[b] = edges[b-1] + 1
+		}
+	}
+	return edges
+}
+
+// cluster average-linkage agglomerative clustering on cosine distance; merges
+// stop at the threshold unless there are still more clusters than speakers allowed
+func cluster(prints [][]float64, threshold float64, maxSpeakers int) []int {
+	n := len(prints)
+	members := make([][]int, n)
+	for i := range members {
+		members[i] = []int{i}
+	}
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+		for j := range dist[i] {
+			dist[i][j] = cosineDistance(prints[i], prints[j])
+		}
+	}
+
+	alive := n
+	for alive > 1 {
+		a, b, best := -1, -1, math.Inf(1)
+		for i := range members {
+			if members[i] == nil {
+				continue
+			}
+			for j := i + 1; j < n; j++ {
+				if members[j] == nil {
+					continue
+				}
+				if d := linkage(dist, members[i], members[j]); d < best {
+					a, b, best = i, j, d
+				}
+			}
+		}
+		if best > threshold && alive <= maxSpeakers {
+			break
+		}
+		members[a] = append(members[a], members[b]...)
+		members[b] = nil
+		alive--
+	}
+
+	labels := make([]int, n)
+	for c, m := range members {
+		for _, i := range m {
+			labels[i] = c
+		}
+	}
+	return labels
+}
+
+func linkage(dist [][]float64, a, b []int) float64 {
+	var sum float64
+	for _, i := range a {
+		for _, j := range b {
+			sum += dist[i][j]
+		}
+	}
+	return sum / float64(len(a)*len(b))
+}
+
+func cosineDistance(a, b []float64) float64 {
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot
+}
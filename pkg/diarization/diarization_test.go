@@ -0,0 +1,94 @@
+package diarization
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRate = 16000
+
+// voice harmonics of a fundamental shaped by a formant, a crude stand-in for a speaker
+type voice struct {
+	pitch   float64
+	formant float64
+}
+
+func (v voice) speak(d time.Duration, gain float64, rng *rand.Rand) []int16 {
+	n := int(d.Seconds() * testRate)
+	out := make([]int16, n)
+	for i := range out {
+		t := float64(i) / testRate
+		var s float64
+		for h := 1; float64(h)*v.pitch < 4000; h++ {
+			f := float64(h) * v.pitch
+			s += math.Exp(-math.Pow((f-v.formant)/600, 2)) * math.Sin(2*math.Pi*f*t)
+		}
+		s += rng.NormFloat64() * 0.01
+		out[i] = int16(math.Max(-32767, math.Min(32767, s*gain*4000)))
+	}
+	return out
+}
+
+// conversation concatenates turns separated by silence and returns their spans
+func conversation(turns [][]int16) ([]int16, []Span) {
+	var samples []int16
+	var spans []Span
+	gap := make([]int16, testRate/2)
+	for _, turn := range turns {
+		samples = append(samples, gap...)
+		start := time.Duration(len(samples)) * time.Second / testRate
+		samples = append(samples, turn...)
+		spans = append(spans, Span{Start: start, End: time.Duration(len(samples)) * time.Second / testRate})
+	}
+	return samples, spans
+}
+
+func TestDiarize(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	low := voice{pitch: 110, formant: 500}
+	high := voice{pitch: 220, formant: 2200}
+	samples, spans := conversation([][]int16{
+		low.speak(time.Second, 1, rng),
+		high.speak(1200*time.Millisecond, 1, rng),
+		low.speak(800*time.Millisecond, 0.3, rng), // quieter, same speaker
+		high.speak(time.Second, 1.5, rng),
+		low.speak(100*time.Millisecond, 1, rng), // too short to tell
+	})
+
+	result, err := Diarize(samples, testRate, spans, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 0, 1, -1}, result.Labels)
+	assert.Equal(t, 2, result.Speakers)
+}
+
+func TestDiarize_MaxSpeakers(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	samples, spans := conversation([][]int16{
+		voice{pitch: 110, formant: 500}.speak(time.Second, 1, rng),
+		voice{pitch: 220, formant: 2200}.speak(time.Second, 1, rng),
+		voice{pitch: 160, formant: 1200}.speak(time.Second, 1, rng),
+	})
+
+	result, err := Diarize(samples, testRate, spans, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Speakers)
+
+	result, err = Diarize(samples, testRate, spans, Options{MaxSpeakers: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 0}, result.Labels)
+}
+
+func TestDiarize_Edges(t *testing.T) {
+	_, err := Diarize(nil, 0, nil, Options{})
+	assert.ErrorIs(t, err, ErrInvalidSampleRate)
+
+	result, err := Diarize(make([]int16, testRate), testRate, []Span{{0, time.Second}, {2 * time.Second, time.Second}}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{-1, -1}, result.Labels, "silence and spans outside the recording")
+	assert.Zero(t, result.Speakers)
+}
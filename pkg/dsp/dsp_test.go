@@ -0,0 +1,30 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFFT_Sine(t *testing.T) {
+	const n, bin = 64, 5
+	a := make([]complex128, n)
+	for i := range a {
+		a[i] = complex(math.Sin(2*math.Pi*bin*float64(i)/n), 0)
+	}
+	FFT(a)
+	for k := 0; k < n/2; k++ {
+		if k == bin {
+			assert.InDelta(t, n/2, cmplx.Abs(a[k]), 1e-9)
+		} else {
+			assert.InDelta(t, 0, cmplx.Abs(a[k]), 1e-9, "bin %d", k)
+		}
+	}
+}
+
+func TestHannWindow(t *testing.T) {
+	w := HannWindow(5)
+	assert.InDeltaSlice(t, []float64{0, 0.5, 1, 0.5, 0}, w, 1e-12)
+}
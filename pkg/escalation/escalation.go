@@ -0,0 +1,325 @@
+package escalation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+)
+
+const (
+	ProviderJira    = "jira"
+	ProviderZendesk = "zendesk"
+	ProviderWebhook = "webhook"
+
+	// EventTicketRequested is sent to generic webhook connectors
+	EventTicketRequested = "escalation.ticket.requested"
+
+	userAgent = "LingEcho-Escalation/1.0"
+)
+
+const (
+	DefaultTitleTemplate = `[{{.AssistantName}}] {{if .Summary}}{{.Summary}}{{else}}Conversation needs follow-up{{end}}`
+	DefaultBodyTemplate  = `{{if .Reason}}Reason: {{.Reason}}
+
+{{end}}Summary: {{.Summary}}
+{{if .Issues}}
+Issues:
+{{range .Issues}}- {{.}}
+{{end}}{{end}}
+Session: {{.SessionID}}
+Call record: {{.RecordingID}}
+Time: {{.StartTime.Format "2006-01-02 15:04:05"}}
+
+Transcript:
+{{.Transcript}}`
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown escalation provider")
+	ErrMissingConfig   = errors.New("escalation connector config is incomplete")
+)
+
+// Connector targets are configured by assistant owners, so they must be public
+// addresses. Tests swap these to reach local servers.
+var (
+	validateURL = webhook.ValidateURL
+	deliver     = webhook.DeliverPublic
+)
+
+// Context conversation data available to ticket templates
+type Context struct {
+	AssistantID   int64
+	AssistantName string
+	RecordingID   uint
+	SessionID     string
+	Summary       string
+	Reason        string
+	Issues        []string
+	Transcript    string
+	StartTime     time.Time
+	Duration      int
+}
+
+// Ticket rendered ticket content
+type Ticket struct {
+	Title    string   `json:"title"`
+	Body     string   `json:"body"`
+	Priority string   `json:"priority,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+}
+
+// Result reference to the ticket created in the external system
+type Result struct {
+	ExternalID string `json:"externalId"`
+	URL        string `json:"url,omitempty"`
+}
+
+// Connector creates tickets in an external system
+type Connector interface {
+	CreateTicket(ctx context.Context, ticket Ticket, conv Context) (*Result, error)
+}
+
+// Render renders title and body templates, empty templates use the defaults
+func Render(titleTmpl, bodyTmpl string, conv Context) (Ticket, error) {
+	if titleTmpl == "" {
+		titleTmpl = DefaultTitleTemplate
+	}
+	if bodyTmpl == "" {
+		bodyTmpl = DefaultBodyTemplate
+	}
+	title, err := render("title", titleTmpl, conv)
+	if err != nil {
+		return Ticket{}, err
+	}
+	body, err := render("body", bodyTmpl, conv)
+	if err != nil {
+		return Ticket{}, err
+	}
+	// Most trackers reject multi-line or very long titles
+	title = strings.Join(strings.Fields(title), " ")
+	if r := []rune(title); len(r) > 250 {
+		title = string(r[:250])
+	}
+	return Ticket{Title: title, Body: body}, nil
+}
+
+// ValidateTemplate checks a template parses
+func ValidateTemplate(tmpl string) error {
+	if tmpl == "" {
+		return nil
+	}
+	_, err := template.New("ticket").Option("missingkey=zero").Parse(tmpl)
+	return err
+}
+
+func render(name, tmpl string, conv Context) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, conv); err != nil {
+		return "", fmt.Errorf("render %s template: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// New creates a connector for provider from its stored config, rejecting
+// targets that do not resolve to public addresses
+func New(ctx context.Context, provider string, config map[string]interface{}) (Connector, error) {
+	get := func(key string) string {
+		if v, ok := config[key].(string); ok {
+			return strings.TrimSpace(v)
+		}
+		return ""
+	}
+
+	switch provider {
+	case ProviderJira:
+		c := &JiraConnector{
+			BaseURL:    strings.TrimRight(get("baseUrl"), "/"),
+			Email:      get("email"),
+			APIToken:   get("apiToken"),
+			ProjectKey: get("projectKey"),
+			IssueType:  get("issueType"),
+		}
+		if c.BaseURL == "" || c.Email == "" || c.APIToken == "" || c.ProjectKey == "" {
+			return nil, fmt.Errorf("%w: jira requires baseUrl, email, apiToken and projectKey", ErrMissingConfig)
+		}
+		return c, checkTarget(ctx, "baseUrl", c.BaseURL)
+	case ProviderZendesk:
+		c := &ZendeskConnector{
+			BaseURL:  strings.TrimRight(get("baseUrl"), "/"),
+			Email:    get("email"),
+			APIToken: get("apiToken"),
+		}
+		if c.BaseURL == "" {
+			if subdomain := get("subdomain"); subdomain != "" {
+				c.BaseURL = "https://" + subdomain + ".zendesk.com"
+			}
+		}
+		if c.BaseURL == "" || c.Email == "" || c.APIToken == "" {
+			return nil, fmt.Errorf("%w: zendesk requires subdomain (or baseUrl), email and apiToken", ErrMissingConfig)
+		}
+		return c, checkTarget(ctx, "baseUrl", c.BaseURL)
+	case ProviderWebhook:
+		c := &WebhookConnector{URL: get("url"), Secret: get("secret")}
+		if c.URL == "" {
+			return nil, fmt.Errorf("%w: webhook requires url", ErrMissingConfig)
+		}
+		return c, checkTarget(ctx, "url", c.URL)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+}
+
+func checkTarget(ctx context.Context, key, target string) error {
+	if err := validateURL(ctx, target); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// SensitiveConfigKeys config keys that are never returned by the API
+var SensitiveConfigKeys = []string{"apiToken", "secret"}
+
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// JiraConnector creates issues through the Jira REST API v2
+type JiraConnector struct {
+	BaseURL    string
+	Email      string
+	APIToken   string
+	ProjectKey string
+	IssueType  string // Defaults to Task
+}
+
+// CreateTicket creates a Jira issue
+func (j *JiraConnector) CreateTicket(ctx context.Context, ticket Ticket, _ Context) (*Result, error) {
+	issueType := j.IssueType
+	if issueType == "" {
+		issueType = "Task"
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": j.ProjectKey},
+		"summary":     ticket.Title,
+		"description": ticket.Body,
+		"issuetype":   map[string]string{"name": issueType},
+	}
+	if len(ticket.Labels) > 0 {
+		fields["labels"] = ticket.Labels
+	}
+
+	result, err := deliver(ctx, webhook.Request{
+		URL:       j.BaseURL + "/rest/api/2/issue",
+		UserAgent: userAgent,
+		Headers:   map[string]string{"Authorization": basicAuth(j.Email, j.APIToken)},
+		Payload:   map[string]interface{}{"fields": fields},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jira: %w", err)
+	}
+
+	var resp struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(result.Body, &resp); err != nil || resp.Key == "" {
+		return nil, errors.New("jira: unexpected response")
+	}
+	return &Result{ExternalID: resp.Key, URL: j.BaseURL + "/browse/" + resp.Key}, nil
+}
+
+// ZendeskConnector creates tickets through the Zendesk Support API
+type ZendeskConnector struct {
+	BaseURL  string
+	Email    string
+	APIToken string
+}
+
+// CreateTicket creates a Zendesk ticket
+func (z *ZendeskConnector) CreateTicket(ctx context.Context, ticket Ticket, _ Context) (*Result, error) {
+	body := map[string]interface{}{
+		"subject": ticket.Title,
+		"comment": map[string]string{"body": ticket.Body},
+	}
+	if ticket.Priority != "" {
+		body["priority"] = ticket.Priority
+	}
+	if len(ticket.Labels) > 0 {
+		body["tags"] = ticket.Labels
+	}
+
+	result, err := deliver(ctx, webhook.Request{
+		URL:       z.BaseURL + "/api/v2/tickets.json",
+		UserAgent: userAgent,
+		// API token auth uses "{email}/token" as the user name
+		Headers: map[string]string{"Authorization": basicAuth(z.Email+"/token", z.APIToken)},
+		Payload: map[string]interface{}{"ticket": body},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("zendesk: %w", err)
+	}
+
+	var resp struct {
+		Ticket struct {
+			ID int64 `json:"id"`
+		} `json:"ticket"`
+	}
+	if err := json.Unmarshal(result.Body, &resp); err != nil || resp.Ticket.ID == 0 {
+		return nil, errors.New("zendesk: unexpected response")
+	}
+	id := strconv.FormatInt(resp.Ticket.ID, 10)
+	return &Result{ExternalID: id, URL: z.BaseURL + "/agent/tickets/" + id}, nil
+}
+
+// WebhookConnector posts the ticket to a generic endpoint. The endpoint may
+// answer with {"ticketId": "...", "url": "..."} to link its own ticket.
+type WebhookConnector struct {
+	URL    string
+	Secret string
+}
+
+// CreateTicket delivers the ticket as a signed webhook
+func (w *WebhookConnector) CreateTicket(ctx context.Context, ticket Ticket, conv Context) (*Result, error) {
+	result, err := deliver(ctx, webhook.Request{
+		URL:       w.URL,
+		Event:     EventTicketRequested,
+		Secret:    w.Secret,
+		UserAgent: userAgent,
+		Payload: map[string]interface{}{
+			"event":       EventTicketRequested,
+			"ticket":      ticket,
+			"assistantId": conv.AssistantID,
+			"recordingId": conv.RecordingID,
+			"sessionId":   conv.SessionID,
+			"summary":     conv.Summary,
+			"reason":      conv.Reason,
+			"occurredAt":  time.Now(),
+		},
+		MaxAttempts: 3,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+
+	var resp struct {
+		TicketID string `json:"ticketId"`
+		URL      string `json:"url"`
+	}
+	_ = json.Unmarshal(result.Body, &resp)
+	if resp.TicketID == "" {
+		resp.TicketID = fmt.Sprintf("webhook-%d", time.Now().UnixNano())
+	}
+	return &Result{ExternalID: resp.TicketID, URL: resp.URL}, nil
+}
@@ -0,0 +1,179 @@
+package escalation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConv = Context{
+	AssistantID:   7,
+	AssistantName: "Support Bot",
+	RecordingID:   42,
+	SessionID:     "sess-1",
+	Summary:       "Customer cannot reset password",
+	Reason:        "Account is locked",
+	Issues:        []string{"reset email not received"},
+	Transcript:    "用户: help\nAI: sure",
+	StartTime:     time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+}
+
+// allowLocalTargets lets connectors reach httptest servers on loopback
+func allowLocalTargets(t *testing.T) {
+	t.Helper()
+	validateURL = func(context.Context, string) error { return nil }
+	deliver = webhook.Deliver
+	t.Cleanup(func() {
+		validateURL = webhook.ValidateURL
+		deliver = webhook.DeliverPublic
+	})
+}
+
+func TestRender_Defaults(t *testing.T) {
+	ticket, err := Render("", "", testConv)
+	require.NoError(t, err)
+	assert.Equal(t, "[Support Bot] Customer cannot reset password", ticket.Title)
+	assert.Contains(t, ticket.Body, "Reason: Account is locked")
+	assert.Contains(t, ticket.Body, "- reset email not received")
+	assert.Contains(t, ticket.Body, "Call record: 42")
+	assert.Contains(t, ticket.Body, "AI: sure")
+}
+
+func TestRender_CustomTemplate(t *testing.T) {
+	ticket, err := Render("Call {{.RecordingID}}\n{{.Summary}}", "{{.Transcript}}", testConv)
+	require.NoError(t, err)
+	assert.Equal(t, "Call 42 Customer cannot reset password", ticket.Title)
+	assert.Equal(t, testConv.Transcript, ticket.Body)
+
+	_, err = Render("{{.Missing", "", testConv)
+	assert.Error(t, err)
+	assert.Error(t, ValidateTemplate("{{if}}"))
+	assert.NoError(t, ValidateTemplate(DefaultBodyTemplate))
+}
+
+func TestNew_ValidatesConfig(t *testing.T) {
+	allowLocalTargets(t)
+	ctx := context.Background()
+	_, err := New(ctx, ProviderJira, map[string]interface{}{"baseUrl": "https://x.atlassian.net"})
+	assert.ErrorIs(t, err, ErrMissingConfig)
+
+	_, err = New(ctx, "servicenow", nil)
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+
+	c, err := New(ctx, ProviderZendesk, map[string]interface{}{"subdomain": "acme", "email": "a@b.c", "apiToken": "t"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://acme.zendesk.com", c.(*ZendeskConnector).BaseURL)
+}
+
+func TestNew_RejectsPrivateTargets(t *testing.T) {
+	ctx := context.Background()
+	_, err := New(ctx, ProviderWebhook, map[string]interface{}{"url": "http://169.254.169.254/latest/meta-data"})
+	assert.ErrorIs(t, err, webhook.ErrUnsafeTarget)
+
+	_, err = New(ctx, ProviderJira, map[string]interface{}{
+		"baseUrl": "http://127.0.0.1:8080", "email": "bot@acme.com", "apiToken": "token", "projectKey": "SUP",
+	})
+	assert.ErrorIs(t, err, webhook.ErrUnsafeTarget)
+
+	// Connectors built directly are still refused at send time
+	c := &WebhookConnector{URL: "http://127.0.0.1:1/hook"}
+	_, err = c.CreateTicket(ctx, Ticket{Title: "t"}, testConv)
+	assert.ErrorIs(t, err, webhook.ErrUnsafeTarget)
+}
+
+func TestJiraConnector_CreateTicket(t *testing.T) {
+	allowLocalTargets(t)
+	var fields map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/2/issue", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "bot@acme.com", user)
+		assert.Equal(t, "token", pass)
+
+		var body struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fields = body.Fields
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"10001","key":"SUP-12"}`))
+	}))
+	defer server.Close()
+
+	c, err := New(context.Background(), ProviderJira, map[string]interface{}{
+		"baseUrl": server.URL + "/", "email": "bot@acme.com", "apiToken": "token", "projectKey": "SUP",
+	})
+	require.NoError(t, err)
+
+	result, err := c.CreateTicket(context.Background(), Ticket{Title: "t", Body: "b"}, testConv)
+	require.NoError(t, err)
+	assert.Equal(t, "SUP-12", result.ExternalID)
+	assert.Equal(t, server.URL+"/browse/SUP-12", result.URL)
+	assert.Equal(t, "t", fields["summary"])
+	assert.Equal(t, map[string]interface{}{"name": "Task"}, fields["issuetype"])
+}
+
+func TestZendeskConnector_CreateTicket(t *testing.T) {
+	allowLocalTargets(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		assert.Equal(t, "bot@acme.com/token", user)
+		if r.URL.Path != "/api/v2/tickets.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ticket":{"id":981}}`))
+	}))
+	defer server.Close()
+
+	c := &ZendeskConnector{BaseURL: server.URL, Email: "bot@acme.com", APIToken: "token"}
+	result, err := c.CreateTicket(context.Background(), Ticket{Title: "t", Body: "b"}, testConv)
+	require.NoError(t, err)
+	assert.Equal(t, "981", result.ExternalID)
+	assert.Equal(t, server.URL+"/agent/tickets/981", result.URL)
+}
+
+func TestZendeskConnector_ErrorOmitsResponse(t *testing.T) {
+	allowLocalTargets(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"error":"RecordInvalid"}`))
+	}))
+	defer server.Close()
+
+	c := &ZendeskConnector{BaseURL: server.URL, Email: "bot@acme.com", APIToken: "token"}
+	_, err := c.CreateTicket(context.Background(), Ticket{Title: "t"}, testConv)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "422")
+	// Response bodies end up in user visible ticket errors
+	assert.NotContains(t, err.Error(), "RecordInvalid")
+}
+
+func TestWebhookConnector_CreateTicket(t *testing.T) {
+	allowLocalTargets(t)
+	var gotBody []byte
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header.Clone()
+		_, _ = w.Write([]byte(`{"ticketId":"T-1","url":"https://desk.example.com/T-1"}`))
+	}))
+	defer server.Close()
+
+	c := &WebhookConnector{URL: server.URL, Secret: "s"}
+	result, err := c.CreateTicket(context.Background(), Ticket{Title: "t", Body: "b"}, testConv)
+	require.NoError(t, err)
+	assert.Equal(t, "T-1", result.ExternalID)
+	assert.Equal(t, "https://desk.example.com/T-1", result.URL)
+	assert.Equal(t, EventTicketRequested, gotHeader.Get(webhook.HeaderEvent))
+	assert.Equal(t, webhook.Sign("s", gotHeader.Get(webhook.HeaderTimestamp), gotBody), gotHeader.Get(webhook.HeaderSignature))
+}
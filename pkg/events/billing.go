@@ -0,0 +1,10 @@
+package events
+
+// Billing events of organization subscriptions. Every event carries the "groupId",
+// "plan" and "status"; the other data keys are set when known
+const (
+	BillingTrialStarted = "billing.trial_started" // Organization started a trial: endsAt
+	BillingPlanChanged  = "billing.plan_changed"  // Plan or term changed: previousPlan, endsAt, changedBy
+	BillingGraceStarted = "billing.grace_started" // Term ended without renewal: graceEndsAt
+	BillingReadOnly     = "billing.read_only"     // Grace period ended, the organization is read-only
+)
@@ -0,0 +1,21 @@
+package events
+
+// Call lifecycle events published by the SIP server. Every event carries the
+// "callId"; the other data keys are set when known
+const (
+	CallOffered  = "call.offered"  // New call: direction, from, to
+	CallRinging  = "call.ringing"  // Outbound call is ringing at the callee
+	CallQueued   = "call.queued"   // Inbound call waits for a free assistant session: queue
+	CallAnswered = "call.answered" // Call connected
+	CallEnded    = "call.ended"    // Call is over: reason
+
+	AgentRegistered = "agent.registered" // SIP agent registered: agent, expires (seconds, 0 unregisters)
+)
+
+// Reasons of CallEnded
+const (
+	CallEndHangup    = "hangup"    // Connected call was hung up
+	CallEndCancelled = "cancelled" // Caller gave up before the call was answered
+	CallEndRejected  = "rejected"  // Call was refused, redirected or not answered
+	CallEndFailed    = "failed"    // Outbound call could not be set up
+)
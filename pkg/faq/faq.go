@@ -0,0 +1,298 @@
+package faq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+)
+
+const (
+	// DefaultThreshold minimum cosine similarity for a transcript to join a cluster
+	DefaultThreshold = 0.3
+	// maxPromptTranscript characters of each transcript included in the prompt
+	maxPromptTranscript = 1500
+	// maxPromptTranscripts transcripts of a cluster included in the prompt
+	maxPromptTranscripts = 8
+)
+
+var ErrNoProposals = errors.New("llm response contains no FAQ proposals")
+
+// Transcript a call transcript to mine questions from
+type Transcript struct {
+	ID   string
+	Text string
+}
+
+// Cluster transcripts that talk about the same topic
+type Cluster struct {
+	Transcripts []Transcript
+	Terms       []string // Most weighted terms of the cluster, for logging and review
+}
+
+// IDs returns the IDs of the transcripts of the cluster
+func (c Cluster) IDs() []string {
+	ids := make([]string, len(c.Transcripts))
+	for i, t := range c.Transcripts {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// Proposal a frequently asked question with a drafted answer
+type Proposal struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+type vector map[string]float64
+
+// ClusterTranscripts groups transcripts by TF-IDF cosine similarity. Each
+// transcript joins the most similar cluster centroid reaching threshold or starts
+// a new cluster. Clusters are returned largest first.
+func ClusterTranscripts(transcripts []Transcript, threshold float64) []Cluster {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	counts := make([]map[string]int, len(transcripts))
+	df := map[string]int{}
+	for i, t := range transcripts {
+		counts[i] = termCounts(t.Text)
+		for term := range counts[i] {
+			df[term]++
+		}
+	}
+	n := float64(len(transcripts))
+
+	type group struct {
+		members  []int
+		centroid vector
+	}
+	var groups []*group
+	for i, tc := range counts {
+		v := vector{}
+		for term, count := range tc {
+			v[term] = float64(count) * (math.Log((1+n)/(1+float64(df[term]))) + 1)
+		}
+		normalize(v)
+		if len(v) == 0 {
+			continue
+		}
+
+		var best *group
+		bestSim := threshold
+		for _, g := range groups {
+			if sim := cosine(v, g.centroid); sim >= bestSim {
+				best, bestSim = g, sim
+			}
+		}
+		if best == nil {
+			best = &group{centroid: vector{}}
+			groups = append(groups, best)
+		}
+		best.members = append(best.members, i)
+		for term, w := range v {
+			best.centroid[term] += w
+		}
+	}
+
+	clusters := make([]Cluster, 0, len(groups))
+	for _, g := range groups {
+		c := Cluster{Transcripts: make([]Transcript, len(g.members)), Terms: topTerms(g.centroid, 5)}
+		for i, m := range g.members {
+			c.Transcripts[i] = transcripts[m]
+		}
+		clusters = append(clusters, c)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Transcripts) > len(clusters[j].Transcripts)
+	})
+	return clusters
+}
+
+// Similarity cosine similarity of the terms of two texts, used to recognize
+// questions that were already proposed
+func Similarity(a, b string) float64 {
+	va, vb := vector{}, vector{}
+	for term, count := range termCounts(a) {
+		va[term] = float64(count)
+	}
+	for term, count := range termCounts(b) {
+		vb[term] = float64(count)
+	}
+	normalize(va)
+	normalize(vb)
+	return cosine(va, vb)
+}
+
+// NormalizeQuestion lower cases a question and drops punctuation and spaces so
+// trivially different wordings compare equal
+func NormalizeQuestion(question string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(question) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// BuildPrompt builds the prompt asking the LLM for the questions callers of the
+// cluster asked and answers based on what the agent told them
+func BuildPrompt(c Cluster, maxQuestions int) string {
+	if maxQuestions <= 0 {
+		maxQuestions = 3
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `The following are transcripts of %d support calls about the same topic.
+Identify up to %d questions callers frequently ask in these calls and draft a concise answer for each,
+based only on the information given by the agent in the calls. Skip questions the calls do not answer.
+Write the questions and answers in the language of the calls.
+
+Return only a JSON array, without markdown, in the form:
+[{"question": "...", "answer": "..."}]
+`, len(c.Transcripts), maxQuestions)
+
+	for i, t := range c.Transcripts {
+		if i == maxPromptTranscripts {
+			break
+		}
+		text := []rune(strings.TrimSpace(t.Text))
+		if len(text) > maxPromptTranscript {
+			text = append(text[:maxPromptTranscript], []rune("...")...)
+		}
+		fmt.Fprintf(&sb, "\nCall %d:\n%s\n", i+1, string(text))
+	}
+	return sb.String()
+}
+
+// ParseProposals parses the JSON array returned for BuildPrompt, tolerating
+// markdown fences and text around the array
+func ParseProposals(response string) ([]Proposal, error) {
+	start := strings.Index(response, "[")
+	end := strings.LastIndex(response, "]")
+	if start < 0 || end < start {
+		return nil, ErrNoProposals
+	}
+	var raw []Proposal
+	if err := json.Unmarshal([]byte(response[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("parse FAQ proposals: %w", err)
+	}
+
+	proposals := make([]Proposal, 0, len(raw))
+	for _, p := range raw {
+		p.Question = strings.TrimSpace(p.Question)
+		p.Answer = strings.TrimSpace(p.Answer)
+		if p.Question == "" || p.Answer == "" {
+			continue
+		}
+		proposals = append(proposals, p)
+	}
+	if len(proposals) == 0 {
+		return nil, ErrNoProposals
+	}
+	return proposals, nil
+}
+
+// Extract asks the LLM for the frequently asked questions of a cluster
+func Extract(provider llm.LLMProvider, options llm.QueryOptions, c Cluster, maxQuestions int) ([]Proposal, error) {
+	response, err := provider.QueryWithOptions(BuildPrompt(c, maxQuestions), options)
+	if err != nil {
+		return nil, err
+	}
+	return ParseProposals(response)
+}
+
+// termCounts tokenizes text into lower cased words of at least two letters and,
+// as Chinese has no spaces, bigrams of consecutive Han characters
+func termCounts(text string) map[string]int {
+	counts := map[string]int{}
+	var word []rune
+	var prevHan rune
+	flush := func() {
+		if len(word) >= 2 && !stopWords[string(word)] {
+			counts[string(word)]++
+		}
+		word = word[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if prevHan != 0 {
+				counts[string([]rune{prevHan, r})]++
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return counts
+}
+
+func normalize(v vector) {
+	var sum float64
+	for _, w := range v {
+		sum += w * w
+	}
+	if sum == 0 {
+		return
+	}
+	norm := math.Sqrt(sum)
+	for term, w := range v {
+		v[term] = w / norm
+	}
+}
+
+func cosine(a, b vector) float64 {
+	var dot, na, nb float64
+	for term, w := range a {
+		dot += w * b[term]
+		na += w * w
+	}
+	for _, w := range b {
+		nb += w * w
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func topTerms(v vector, n int) []string {
+	terms := make([]string, 0, len(v))
+	for term := range v {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if v[terms[i]] != v[terms[j]] {
+			return v[terms[i]] > v[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "you": true, "your": true, "for": true, "are": true,
+	"is": true, "it": true, "to": true, "of": true, "in": true, "on": true,
+	"my": true, "me": true, "we": true, "can": true, "do": true, "that": true,
+	"this": true, "with": true, "have": true, "be": true, "an": true, "or": true,
+	"what": true, "how": true, "yes": true, "no": true, "ok": true, "okay": true,
+	"hello": true, "hi": true, "thank": true, "thanks": true, "please": true,
+	"agent": true, "caller": true, "user": true, "assistant": true,
+}
@@ -0,0 +1,67 @@
+package faq
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterTranscripts(t *testing.T) {
+	transcripts := []Transcript{
+		{ID: "c1", Text: "Caller: how do I reset my password? Agent: open the login page and click forgot password"},
+		{ID: "c2", Text: "Caller: I forgot my password, can I reset it? Agent: use forgot password on the login page"},
+		{ID: "c3", Text: "Caller: what are your opening hours on weekends? Agent: weekends we open from nine to five"},
+		{ID: "c4", Text: "Caller: password reset email never arrived. Agent: check spam, the reset password email may be there"},
+		{ID: "c5", Text: "Caller: are you open on weekends? Agent: yes, weekends opening hours are nine to five"},
+		{ID: "c6", Text: "   "},
+	}
+
+	clusters := ClusterTranscripts(transcripts, 0)
+	require.Len(t, clusters, 2)
+	assert.Equal(t, []string{"c1", "c2", "c4"}, clusters[0].IDs())
+	assert.Contains(t, clusters[0].Terms, "password")
+	assert.Equal(t, []string{"c3", "c5"}, clusters[1].IDs())
+}
+
+func TestClusterTranscripts_Chinese(t *testing.T) {
+	transcripts := []Transcript{
+		{ID: "a", Text: "用户：我的快递什么时候到？客服：快递一般三天内送达"},
+		{ID: "b", Text: "用户：退货怎么办理？客服：在订单页面申请退货"},
+		{ID: "c", Text: "用户：请问快递几天能送达？客服：快递三天送达"},
+	}
+	clusters := ClusterTranscripts(transcripts, 0)
+	require.Len(t, clusters, 2)
+	assert.Equal(t, []string{"a", "c"}, clusters[0].IDs())
+}
+
+func TestParseProposals(t *testing.T) {
+	proposals, err := ParseProposals("Here you go:\n```json\n[{\"question\": \" How do I reset my password? \", \"answer\": \"Use forgot password.\"}, {\"question\": \"Unanswered\", \"answer\": \"\"}]\n```")
+	require.NoError(t, err)
+	assert.Equal(t, []Proposal{{Question: "How do I reset my password?", Answer: "Use forgot password."}}, proposals)
+
+	_, err = ParseProposals("I could not find any questions.")
+	assert.ErrorIs(t, err, ErrNoProposals)
+	_, err = ParseProposals("[]")
+	assert.ErrorIs(t, err, ErrNoProposals)
+	_, err = ParseProposals("[{\"question\": 1}]")
+	assert.Error(t, err)
+}
+
+func TestBuildPrompt(t *testing.T) {
+	c := Cluster{Transcripts: []Transcript{{ID: "c1", Text: strings.Repeat("a", 2000)}, {ID: "c2", Text: "short call"}}}
+	prompt := BuildPrompt(c, 2)
+	assert.Contains(t, prompt, "transcripts of 2 support calls")
+	assert.Contains(t, prompt, "up to 2 questions")
+	assert.Contains(t, prompt, strings.Repeat("a", maxPromptTranscript)+"...")
+	assert.NotContains(t, prompt, strings.Repeat("a", maxPromptTranscript+1))
+	assert.Contains(t, prompt, "Call 2:\nshort call")
+}
+
+func TestSimilarityAndNormalize(t *testing.T) {
+	assert.Greater(t, Similarity("How do I reset my password?", "how to reset the password"), 0.6)
+	assert.Less(t, Similarity("How do I reset my password?", "When are you open on weekends?"), 0.1)
+	assert.Equal(t, "如何重置密码", NormalizeQuestion("如何 重置密码？"))
+	assert.Equal(t, NormalizeQuestion("Reset password?"), NormalizeQuestion("reset  Password"))
+}
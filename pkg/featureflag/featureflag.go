@@ -0,0 +1,157 @@
+// Package featureflag evaluates feature flags with targeting rules. A flag is
+// off while disabled (kill switch); when enabled its rules are checked in order
+// and the first rule matching the subject decides, otherwise the default value
+// applies. Rules target users, organizations and a stable percentage of users.
+package featureflag
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+)
+
+// Reasons of an evaluation
+const (
+	ReasonDisabled = "disabled" // Flag switched off
+	ReasonRule     = "rule"     // A targeting rule matched
+	ReasonDefault  = "default"  // No rule matched
+	ReasonUnknown  = "unknown"  // Flag is not defined
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Rule targeting rule. Every criterion that is set must hold: the user is listed,
+// the user belongs to a listed organization, and the user falls in the rollout
+// percentage. A rule with only a percentage rolls out to that share of all users.
+type Rule struct {
+	Users      []uint `json:"users,omitempty"`
+	Groups     []uint `json:"groups,omitempty"`     // Organization IDs
+	Percentage *int   `json:"percentage,omitempty"` // 0-100, stable per user and flag
+	Value      bool   `json:"value"`                // Result when the rule matches
+}
+
+// Flag definition
+type Flag struct {
+	Key     string `json:"key"`
+	Enabled bool   `json:"enabled"`
+	Default bool   `json:"default"`
+	Rules   []Rule `json:"rules,omitempty"`
+}
+
+// Subject the flag is evaluated for
+type Subject struct {
+	UserID uint   `json:"userId"`
+	Groups []uint `json:"groups,omitempty"`
+}
+
+// Evaluation result of a flag for a subject
+type Evaluation struct {
+	Key     string `json:"key"`
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+	Rule    int    `json:"rule"` // Index of the matching rule, -1 otherwise
+}
+
+// ValidateKey checks a flag key: lowercase letters, digits, dot, dash and underscore
+func ValidateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid flag key %q", key)
+	}
+	return nil
+}
+
+// Validate checks the key and rules
+func (f *Flag) Validate() error {
+	if err := ValidateKey(f.Key); err != nil {
+		return err
+	}
+	for i, r := range f.Rules {
+		if r.Percentage != nil && (*r.Percentage < 0 || *r.Percentage > 100) {
+			return fmt.Errorf("rule %d: percentage must be between 0 and 100", i)
+		}
+		if len(r.Users) == 0 && len(r.Groups) == 0 && r.Percentage == nil {
+			return fmt.Errorf("rule %d: %w", i, errors.New("rule needs users, groups or a percentage"))
+		}
+	}
+	return nil
+}
+
+// Evaluate decides the flag for the subject
+func (f *Flag) Evaluate(s Subject) Evaluation {
+	e := Evaluation{Key: f.Key, Rule: -1}
+	if !f.Enabled {
+		e.Reason = ReasonDisabled
+		return e
+	}
+	for i, r := range f.Rules {
+		if r.matches(f.Key, s) {
+			e.Enabled, e.Reason, e.Rule = r.Value, ReasonRule, i
+			return e
+		}
+	}
+	e.Enabled, e.Reason = f.Default, ReasonDefault
+	return e
+}
+
+func (r *Rule) matches(key string, s Subject) bool {
+	if len(r.Users) > 0 && !contains(r.Users, s.UserID) {
+		return false
+	}
+	if len(r.Groups) > 0 && !intersects(r.Groups, s.Groups) {
+		return false
+	}
+	if r.Percentage != nil && Bucket(key, s.UserID) >= *r.Percentage {
+		return false
+	}
+	return true
+}
+
+// Bucket stable 0-99 bucket of the user for the flag. Hashing the key with the
+// user spreads different flags over different users, and raising a percentage
+// only adds users.
+func Bucket(key string, userID uint) int {
+	h := fnv.New32a()
+	h.Write([]byte(key + ":" + strconv.FormatUint(uint64(userID), 10)))
+	return int(h.Sum32() % 100)
+}
+
+// Set flags by key
+type Set map[string]*Flag
+
+// Evaluate decides the flag for the subject, undefined flags are off
+func (s Set) Evaluate(key string, subject Subject) Evaluation {
+	f, ok := s[key]
+	if !ok {
+		return Evaluation{Key: key, Reason: ReasonUnknown, Rule: -1}
+	}
+	return f.Evaluate(subject)
+}
+
+// EvaluateAll decides every flag for the subject
+func (s Set) EvaluateAll(subject Subject) map[string]bool {
+	out := make(map[string]bool, len(s))
+	for key, f := range s {
+		out[key] = f.Evaluate(subject).Enabled
+	}
+	return out
+}
+
+func contains(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func intersects(a, b []uint) bool {
+	for _, v := range b {
+		if contains(a, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package featureflag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pct(v int) *int { return &v }
+
+func TestFlagEvaluate(t *testing.T) {
+	flag := &Flag{
+		Key:     "voice.pipeline-v2",
+		Enabled: true,
+		Rules: []Rule{
+			{Users: []uint{13}, Value: false},                        // opted out tester
+			{Users: []uint{7}, Value: true},                          // early access
+			{Groups: []uint{100}, Percentage: pct(100), Value: true}, // pilot organization
+			{Groups: []uint{200}, Percentage: pct(0), Value: true},   // organization not started yet
+		},
+	}
+	assert.NoError(t, flag.Validate())
+
+	e := flag.Evaluate(Subject{UserID: 7})
+	assert.True(t, e.Enabled)
+	assert.Equal(t, ReasonRule, e.Reason)
+	assert.Equal(t, 1, e.Rule)
+
+	assert.True(t, flag.Evaluate(Subject{UserID: 8, Groups: []uint{5, 100}}).Enabled)
+	assert.False(t, flag.Evaluate(Subject{UserID: 13, Groups: []uint{100}}).Enabled, "first matching rule wins")
+	assert.False(t, flag.Evaluate(Subject{UserID: 9, Groups: []uint{200}}).Enabled)
+
+	e = flag.Evaluate(Subject{UserID: 9})
+	assert.False(t, e.Enabled)
+	assert.Equal(t, ReasonDefault, e.Reason)
+
+	flag.Enabled = false
+	e = flag.Evaluate(Subject{UserID: 7})
+	assert.False(t, e.Enabled)
+	assert.Equal(t, ReasonDisabled, e.Reason)
+}
+
+func TestPercentageRollout(t *testing.T) {
+	flag := &Flag{Key: "risk.engine-v2", Enabled: true, Rules: []Rule{{Percentage: pct(30), Value: true}}}
+	on := map[uint]bool{}
+	for id := uint(1); id <= 2000; id++ {
+		if flag.Evaluate(Subject{UserID: id}).Enabled {
+			on[id] = true
+		}
+	}
+	assert.InDelta(t, 600, len(on), 120)
+
+	// Raising the percentage keeps everyone already enabled
+	flag.Rules[0].Percentage = pct(60)
+	for id := range on {
+		assert.True(t, flag.Evaluate(Subject{UserID: id}).Enabled)
+	}
+	assert.Equal(t, Bucket("risk.engine-v2", 42), Bucket("risk.engine-v2", 42))
+}
+
+func TestValidateAndSet(t *testing.T) {
+	assert.Error(t, (&Flag{Key: "Bad Key"}).Validate())
+	assert.Error(t, (&Flag{Key: "a", Rules: []Rule{{Value: true}}}).Validate())
+	assert.Error(t, (&Flag{Key: "a", Rules: []Rule{{Percentage: pct(101)}}}).Validate())
+
+	set := Set{"a": {Key: "a", Enabled: true, Default: true}}
+	assert.True(t, set.Evaluate("a", Subject{UserID: 1}).Enabled)
+	e := set.Evaluate("missing", Subject{UserID: 1})
+	assert.False(t, e.Enabled)
+	assert.Equal(t, ReasonUnknown, e.Reason)
+	assert.Equal(t, map[string]bool{"a": true}, set.EvaluateAll(Subject{UserID: 1}))
+}
@@ -33,6 +33,8 @@ type HardwareOptions struct {
 	VADThreshold         float64                // VAD threshold
 	VADConsecutiveFrames int                    // VAD consecutive frames
 	VoiceCloneID         *int                   // voice clone id (optional)
+	PromptReleaseID      *uint                  // prompt canary release the call belongs to (optional)
+	PromptArm            string                 // canary arm: baseline or candidate
 }
 
 // HardwareHandler hardware handler
@@ -89,6 +91,8 @@ func (h *HardwareHandler) HandlerHardwareWebsocket(
 		DeviceID:             options.DeviceID,
 		MacAddress:           options.MacAddress,
 		VoiceCloneID:         options.VoiceCloneID,
+		PromptReleaseID:      options.PromptReleaseID,
+		PromptArm:            options.PromptArm,
 	})
 	if err := session.Start(); err != nil {
 		h.logger.Error("[Handler] start session failed: ", zap.Error(err))
@@ -148,6 +148,10 @@ func (s *HardwareSession) handleHelloMessage(msg map[string]interface{}) {
 	for key, value := range s.config.Credential.AsrConfig {
 		asrConfig[key] = value
 	}
+	// 助手热词来自用户采纳的转写修正，提高这些词的识别率
+	if words := s.assistantHotWords(); len(words) > 0 {
+		asrConfig[recognizer.ConfigKeyHotWords] = words
+	}
 	config, err := recognizer.NewTranscriberConfigFromMap(asrProvider, asrConfig, "zh")
 	if err != nil {
 		s.mu.Unlock()
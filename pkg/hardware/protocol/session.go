@@ -15,6 +15,7 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/hardware/stream"
 	"github.com/code-100-precent/LingEcho/pkg/hardware/tools"
 	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/recognizer"
 	"github.com/code-100-precent/LingEcho/pkg/synthesizer"
 	"github.com/code-100-precent/LingEcho/pkg/voiceclone"
 	"github.com/code-100-precent/LingEcho/pkg/voiceprint"
@@ -41,6 +42,8 @@ type HardwareSessionOption struct {
 	DeviceID             *string // 设备ID
 	MacAddress           string  // MAC地址
 	VoiceCloneID         *int    // 克隆音色ID（可选）
+	PromptReleaseID      *uint   // 提示词金丝雀发布ID（可选）
+	PromptArm            string  // 金丝雀分组
 }
 
 // HardwareSession hardware session
@@ -109,7 +112,13 @@ func NewHardwareSession(ctx context.Context, hardwareConfig *HardwareSessionOpti
 	// 如果指定了克隆音色ID，优先使用克隆音色
 	if hardwareConfig.VoiceCloneID != nil && *hardwareConfig.VoiceCloneID > 0 {
 		// 从数据库获取克隆音色信息
-		voiceClone, voiceCloneErr := models.GetVoiceCloneByID(hardwareConfig.DB, int64(*hardwareConfig.VoiceCloneID))
+		// 只能使用助手所有者或其组织的克隆音色
+		var voiceClone *models.VoiceClone
+		var assistant models.Assistant
+		voiceCloneErr := hardwareConfig.DB.First(&assistant, hardwareConfig.AssistantID).Error
+		if voiceCloneErr == nil {
+			voiceClone, voiceCloneErr = models.GetAssistantVoiceClone(hardwareConfig.DB, int64(*hardwareConfig.VoiceCloneID), &assistant)
+		}
 		if voiceCloneErr == nil && voiceClone != nil {
 			// 创建克隆音色服务
 			cloneFactory := voiceclone.NewFactory()
@@ -236,14 +245,16 @@ func NewHardwareSession(ctx context.Context, hardwareConfig *HardwareSessionOpti
 				}
 				return ""
 			}(),
-			MacAddress:  hardwareConfig.MacAddress,
-			CallType:    "voice",
-			CallStatus:  "ongoing",
-			StartTime:   time.Now(),
-			EndTime:     time.Now(), // 初始化为当前时间，后续会更新
-			LLMModel:    hardwareConfig.LLMModel,
-			TTSProvider: ttsProvider,
-			ASRProvider: hardwareConfig.Credential.GetASRProvider(),
+			MacAddress:      hardwareConfig.MacAddress,
+			CallType:        "voice",
+			CallStatus:      "ongoing",
+			StartTime:       time.Now(),
+			EndTime:         time.Now(), // 初始化为当前时间，后续会更新
+			LLMModel:        hardwareConfig.LLMModel,
+			TTSProvider:     ttsProvider,
+			ASRProvider:     hardwareConfig.Credential.GetASRProvider(),
+			PromptReleaseID: hardwareConfig.PromptReleaseID,
+			PromptArm:       hardwareConfig.PromptArm,
 		},
 	}
 	if hardwareConfig.Speaker != "" {
@@ -285,6 +296,13 @@ func NewHardwareSession(ctx context.Context, hardwareConfig *HardwareSessionOpti
 		// 注册声纹识别工具给 LLM，使其可以主动调用
 		tools.RegisterVoiceprintIdentifyTool(llmService, session.voiceprintTool)
 	}
+	if hardwareConfig.DB != nil {
+		var assistant models.Assistant
+		if err := hardwareConfig.DB.First(&assistant, hardwareConfig.AssistantID).Error; err == nil && assistant.EnableDeviceControl {
+			// 助手所有者允许时，通话中可以控制其设备
+			tools.RegisterDeviceControlTool(llmService, hardwareConfig.DB, &assistant)
+		}
+	}
 	sessionRef = session
 	go session.preloadCommonSpeakers(ttsConfig)
 	return session
@@ -614,6 +632,9 @@ func (s *HardwareSession) saveCallRecording() {
 		// 获取本地文件路径
 		filePath := s.recorder.GetFilePath()
 		if filePath != "" {
+			// 定稿时计算哈希，用于证明录音未被篡改
+			s.hashRecordingFile(filePath)
+
 			// 异步上传到存储服务
 			go s.uploadRecordingFile(filePath)
 
@@ -644,6 +665,8 @@ func (s *HardwareSession) saveCallRecording() {
 		"channels":             s.callRecording.Channels,
 		"storage_url":          s.callRecording.StorageURL,
 		"conversation_details": s.callRecording.ConversationDetailsJSON,
+		"audio_sha256":         s.callRecording.AudioSHA256,
+		"hashed_at":            s.callRecording.HashedAt,
 	}).Error; err != nil {
 		s.logger.Error("[Session] 更新通话记录失败", zap.Error(err))
 		return
@@ -658,6 +681,25 @@ func (s *HardwareSession) saveCallRecording() {
 		zap.String("speakers", s.callRecording.Speakers))
 }
 
+// hashRecordingFile 计算录音文件的 SHA-256
+func (s *HardwareSession) hashRecordingFile(filePath string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		s.logger.Error("[Session] 打开录音文件失败，无法计算哈希", zap.Error(err), zap.String("filePath", filePath))
+		return
+	}
+	defer file.Close()
+
+	hash, _, err := models.HashRecordingAudio(file)
+	if err != nil {
+		s.logger.Error("[Session] 计算录音哈希失败", zap.Error(err), zap.String("filePath", filePath))
+		return
+	}
+	now := time.Now()
+	s.callRecording.AudioSHA256 = hash
+	s.callRecording.HashedAt = &now
+}
+
 // uploadRecordingFile 上传录音文件到存储服务
 func (s *HardwareSession) uploadRecordingFile(filePath string) {
 	if s.callRecording == nil {
@@ -829,6 +871,9 @@ func (s *HardwareSession) saveConversationDetails() {
 		}
 	}
 
+	// 标记对话中的个人信息，是否遮盖由组织策略在查看时决定
+	details.DetectPII(time.Now())
+
 	if err := s.callRecording.SetConversationDetails(details); err != nil {
 		s.logger.Error("[Session] 设置对话详情失败", zap.Error(err))
 		return
@@ -844,3 +889,20 @@ func (s *HardwareSession) saveConversationDetails() {
 		zap.Int("userTurns", details.UserTurns),
 		zap.Int("aiTurns", details.AITurns))
 }
+
+// assistantHotWords 读取助手的识别热词
+func (s *HardwareSession) assistantHotWords() []recognizer.HotWord {
+	if s.db == nil || s.config.AssistantID == 0 {
+		return nil
+	}
+	vocabulary, err := models.ListAssistantVocabulary(s.db, int64(s.config.AssistantID), models.MaxAssistantVocabulary)
+	if err != nil {
+		s.logger.Warn("[Session] 读取助手热词失败", zap.Error(err))
+		return nil
+	}
+	words := make([]recognizer.HotWord, 0, len(vocabulary))
+	for _, v := range vocabulary {
+		words = append(words, recognizer.HotWord{Word: v.Word, Weight: v.Weight})
+	}
+	return words
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"gorm.io/gorm"
+)
+
+// RegisterDeviceControlTool 注册设备控制工具，助手可以在通话中控制所有者的设备，
+// 例如"把厨房的音箱声音调小"。破坏性操作需要用户口头确认后调用 device_control_confirm
+func RegisterDeviceControlTool(service *LLMService, db *gorm.DB, assistant *models.Assistant) {
+	service.RegisterTool(
+		"device_control",
+		"控制用户的设备，例如调节音量、静音、重启、清除缓存。device 填用户说的设备名称，例如'厨房'、'客厅音箱'",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"device": map[string]interface{}{
+					"type":        "string",
+					"description": "设备别名或名称，用户只有一台设备时可以为空",
+				},
+				"intent": map[string]interface{}{
+					"type":        "string",
+					"description": "要执行的操作",
+					"enum":        models.DeviceIntents,
+				},
+				"value": map[string]interface{}{
+					"type":        "integer",
+					"description": "set_volume 的目标音量（0-100），或调大/调小的幅度",
+				},
+				"utterance": map[string]interface{}{
+					"type":        "string",
+					"description": "用户的原话",
+				},
+			},
+			"required": []string{"intent"},
+		},
+		func(args map[string]interface{}) (string, error) {
+			req := models.DeviceCommandRequest{
+				UserID:      assistant.UserID,
+				AssistantID: &assistant.ID,
+				Source:      models.DeviceCommandSourceCall,
+			}
+			req.Device, _ = args["device"].(string)
+			req.Intent, _ = args["intent"].(string)
+			req.Utterance, _ = args["utterance"].(string)
+			if v, ok := args["value"].(float64); ok {
+				value := int(v)
+				req.Value = &value
+			}
+			result, err := models.ExecuteDeviceCommand(db, req, time.Now())
+			if err != nil {
+				return deviceControlError(err)
+			}
+			if result.NeedsConfirmation {
+				return fmt.Sprintf("[请向用户确认] 该操作会中断设备使用，请先询问用户是否确定对%s执行%s，用户同意后调用 device_control_confirm，confirmationId 为 %d",
+					result.Device.DisplayName(), result.Log.Action, result.Log.ID), nil
+			}
+			return deviceControlResult(result)
+		},
+	)
+
+	service.RegisterTool(
+		"device_control_confirm",
+		"用户明确同意或拒绝 device_control 要求确认的操作后调用，只有用户明确同意时 confirm 才能为 true",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"confirmationId": map[string]interface{}{
+					"type":        "integer",
+					"description": "device_control 返回的 confirmationId",
+				},
+				"confirm": map[string]interface{}{
+					"type":        "boolean",
+					"description": "用户同意为 true，拒绝为 false",
+				},
+			},
+			"required": []string{"confirmationId", "confirm"},
+		},
+		func(args map[string]interface{}) (string, error) {
+			id, _ := args["confirmationId"].(float64)
+			confirm, _ := args["confirm"].(bool)
+			result, err := models.ConfirmDeviceCommand(db, assistant.UserID, uint(id), confirm, time.Now())
+			if err != nil {
+				return deviceControlError(err)
+			}
+			if !confirm {
+				return "[请对用户说] 好的，已取消", nil
+			}
+			return deviceControlResult(result)
+		},
+	)
+
+	service.logger.Info("已注册设备控制工具")
+}
+
+// deviceControlResult 指令签发结果
+func deviceControlResult(result *models.DeviceCommandResult) (string, error) {
+	if result.Log.Status != models.DeviceCommandIssued {
+		return "", fmt.Errorf("向%s下发指令失败: %s", result.Device.DisplayName(), result.Log.Error)
+	}
+	return fmt.Sprintf("[请对用户说] 好的，已向%s发送指令", result.Device.DisplayName()), nil
+}
+
+// deviceControlError 用户可以纠正的错误转为提示，让 LLM 追问用户
+func deviceControlError(err error) (string, error) {
+	switch {
+	case errors.Is(err, models.ErrDeviceAmbiguous):
+		return fmt.Sprintf("有多台设备符合（%v），请询问用户指的是哪一台", err), nil
+	case errors.Is(err, models.ErrDeviceNotFound):
+		return "没有找到对应的设备，请询问用户设备的名称", nil
+	case errors.Is(err, models.ErrDeviceCommandConfirmExpired):
+		return "确认已超时，请重新询问用户后再次调用 device_control", nil
+	}
+	return "", err
+}
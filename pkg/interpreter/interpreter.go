@@ -0,0 +1,319 @@
+// Package interpreter relays a call between two parties speaking different
+// languages: what one party says is recognized, translated and spoken into the
+// other party's leg.
+//
+// Speech is translated clause by clause while the speaker is still talking:
+// streaming recognition results are cut at clause boundaries, so the listener
+// starts hearing the translation before the sentence ends. Each leg is a
+// pipeline, the next clause is translated while the previous one is spoken.
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// Leg a party of an interpreted call
+type Leg string
+
+const (
+	LegA Leg = "a" // the calling party
+	LegB Leg = "b" // the party the interpreter dialed
+)
+
+// Other the leg that hears what this leg says
+func (l Leg) Other() Leg {
+	if l == LegA {
+		return LegB
+	}
+	return LegA
+}
+
+// DefaultMinSegmentRunes partial results are cut at a clause boundary once the clause has this many runes
+const DefaultMinSegmentRunes = 6
+
+// segmentQueueSize clauses waiting per leg before the speaker is considered too far ahead
+const segmentQueueSize = 64
+
+// ErrClosed the engine was closed
+var ErrClosed = errors.New("interpreter closed")
+
+// Translator translates a clause, languages are BCP 47 tags such as zh-CN and en-US
+type Translator interface {
+	Translate(ctx context.Context, text, from, to string) (string, error)
+}
+
+// Speaker synthesizes text into a leg and returns once it has been played
+type Speaker interface {
+	Speak(ctx context.Context, leg Leg, text, language string) error
+}
+
+// Config of an interpreted call
+type Config struct {
+	LanguageA       string // language of LegA
+	LanguageB       string // language of LegB
+	MinSegmentRunes int    // 0 uses DefaultMinSegmentRunes
+	Disabled        bool   // start with interpretation off, see SetEnabled
+
+	// OnEntry is called once a clause has been spoken (or failed), in order per leg
+	OnEntry func(Entry)
+}
+
+// Entry a clause of the transcript in both languages
+type Entry struct {
+	Seq            int           `json:"seq"` // order in which clauses were recognized across both legs
+	Leg            Leg           `json:"leg"` // who said it
+	SourceLanguage string        `json:"sourceLanguage"`
+	TargetLanguage string        `json:"targetLanguage"`
+	Source         string        `json:"source"`
+	Translation    string        `json:"translation"`
+	RecognizedAt   time.Time     `json:"recognizedAt"`
+	Latency        time.Duration `json:"latency"` // from recognition until the translation started playing
+	Error          string        `json:"error,omitempty"`
+}
+
+type segment struct {
+	seq  int
+	leg  Leg
+	text string
+	at   time.Time
+}
+
+type legState struct {
+	emitted int          // runes of the current utterance already queued
+	queue   chan segment // clauses to translate
+	speak   chan Entry   // translations to play into the other leg
+}
+
+// Engine interprets one call
+type Engine struct {
+	cfg        Config
+	translator Translator
+	speaker    Speaker
+	enabled    atomic.Bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	seq     int
+	legs    map[Leg]*legState
+	entries []Entry
+}
+
+// New starts an engine; Close it when the call ends
+func New(cfg Config, translator Translator, speaker Speaker) *Engine {
+	if cfg.MinSegmentRunes <= 0 {
+		cfg.MinSegmentRunes = DefaultMinSegmentRunes
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{
+		cfg:        cfg,
+		translator: translator,
+		speaker:    speaker,
+		ctx:        ctx,
+		cancel:     cancel,
+		legs:       make(map[Leg]*legState, 2),
+	}
+	e.enabled.Store(!cfg.Disabled)
+	for _, leg := range []Leg{LegA, LegB} {
+		st := &legState{queue: make(chan segment, segmentQueueSize), speak: make(chan Entry, segmentQueueSize)}
+		e.legs[leg] = st
+		e.wg.Add(2)
+		go e.translateLoop(st)
+		go e.speakLoop(st)
+	}
+	return e
+}
+
+// Language of a leg
+func (e *Engine) Language(leg Leg) string {
+	if leg == LegA {
+		return e.cfg.LanguageA
+	}
+	return e.cfg.LanguageB
+}
+
+// Enabled whether speech is being interpreted
+func (e *Engine) Enabled() bool {
+	return e.enabled.Load()
+}
+
+// SetEnabled turns interpretation on or off during the call. While off, speech
+// is ignored (the caller is expected to pass audio between the legs) and clauses
+// not yet translated are dropped
+func (e *Engine) SetEnabled(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.enabled.Swap(enabled) == enabled {
+		return
+	}
+	for _, st := range e.legs {
+		st.emitted = 0
+	}
+}
+
+// HandleTranscript feeds a streaming recognition result of a leg: text is the
+// utterance recognized so far, final when the utterance ended
+func (e *Engine) HandleTranscript(leg Leg, text string, final bool) error {
+	if e.ctx.Err() != nil {
+		return ErrClosed
+	}
+	e.mu.Lock()
+	st, ok := e.legs[leg]
+	if !ok || !e.enabled.Load() {
+		e.mu.Unlock()
+		return nil
+	}
+	runes := []rune(text)
+	if st.emitted > len(runes) {
+		// The recognizer revised the utterance, what was queued can't be taken back
+		st.emitted = len(runes)
+	}
+	var clause string
+	if final {
+		clause = strings.TrimSpace(string(runes[st.emitted:]))
+		st.emitted = 0
+	} else if cut := clauseCut(runes[st.emitted:], e.cfg.MinSegmentRunes); cut > 0 {
+		clause = strings.TrimSpace(string(runes[st.emitted : st.emitted+cut]))
+		st.emitted += cut
+	}
+	if clause == "" || !hasLetters(clause) {
+		e.mu.Unlock()
+		return nil
+	}
+	e.seq++
+	seg := segment{seq: e.seq, leg: leg, text: clause, at: time.Now()}
+	e.mu.Unlock()
+
+	select {
+	case st.queue <- seg:
+		return nil
+	case <-e.ctx.Done():
+		return ErrClosed
+	}
+}
+
+// clauseCut the length of the longest prefix ending at a clause boundary with
+// at least min runes, 0 when there is none yet
+func clauseCut(runes []rune, min int) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if i+1 < min {
+			return 0
+		}
+		if isClauseBoundary(runes[i]) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func isClauseBoundary(r rune) bool {
+	switch r {
+	case '.', '!', '?', ';', ',', '。', '！', '？', '；', '，', '、', '…':
+		return true
+	}
+	return false
+}
+
+func hasLetters(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) translateLoop(st *legState) {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case seg := <-st.queue:
+			if !e.enabled.Load() {
+				continue
+			}
+			entry := Entry{
+				Seq:            seg.seq,
+				Leg:            seg.leg,
+				SourceLanguage: e.Language(seg.leg),
+				TargetLanguage: e.Language(seg.leg.Other()),
+				Source:         seg.text,
+				RecognizedAt:   seg.at,
+			}
+			translation, err := e.translator.Translate(e.ctx, seg.text, entry.SourceLanguage, entry.TargetLanguage)
+			if e.ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				entry.Error = err.Error()
+				e.record(entry)
+				continue
+			}
+			entry.Translation = strings.TrimSpace(translation)
+			select {
+			case st.speak <- entry:
+			case <-e.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (e *Engine) speakLoop(st *legState) {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case entry := <-st.speak:
+			if !e.enabled.Load() {
+				continue
+			}
+			entry.Latency = time.Since(entry.RecognizedAt)
+			if entry.Translation != "" {
+				if err := e.speaker.Speak(e.ctx, entry.Leg.Other(), entry.Translation, entry.TargetLanguage); err != nil {
+					if e.ctx.Err() != nil {
+						return
+					}
+					entry.Error = err.Error()
+				}
+			}
+			e.record(entry)
+		}
+	}
+}
+
+func (e *Engine) record(entry Entry) {
+	e.mu.Lock()
+	e.entries = append(e.entries, entry)
+	e.mu.Unlock()
+	if e.cfg.OnEntry != nil {
+		e.cfg.OnEntry(entry)
+	}
+}
+
+// Transcript the clauses interpreted so far in recognition order
+func (e *Engine) Transcript() []Entry {
+	e.mu.Lock()
+	entries := make([]Entry, len(e.entries))
+	copy(entries, e.entries)
+	e.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+// Close stops interpreting; clauses not yet spoken are dropped
+func (e *Engine) Close() {
+	e.cancel()
+	e.wg.Wait()
+}
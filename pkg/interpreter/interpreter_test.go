@@ -0,0 +1,166 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTranslator struct{}
+
+func (fakeTranslator) Translate(_ context.Context, text, from, to string) (string, error) {
+	if strings.Contains(text, "fail") {
+		return "", errors.New("llm unavailable")
+	}
+	return "[" + to + "]" + text, nil
+}
+
+type spoken struct {
+	leg      Leg
+	text     string
+	language string
+}
+
+type fakeSpeaker struct {
+	mu     sync.Mutex
+	spoken []spoken
+}
+
+func (s *fakeSpeaker) Speak(_ context.Context, leg Leg, text, language string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spoken = append(s.spoken, spoken{leg, text, language})
+	return nil
+}
+
+func (s *fakeSpeaker) all() []spoken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]spoken(nil), s.spoken...)
+}
+
+func newTestEngine(t *testing.T, cfg Config) (*Engine, *fakeSpeaker, chan Entry) {
+	entries := make(chan Entry, 16)
+	cfg.LanguageA, cfg.LanguageB = "zh-CN", "en-US"
+	cfg.OnEntry = func(e Entry) { entries <- e }
+	speaker := &fakeSpeaker{}
+	e := New(cfg, fakeTranslator{}, speaker)
+	t.Cleanup(e.Close)
+	return e, speaker, entries
+}
+
+func waitEntries(t *testing.T, entries chan Entry, n int) []Entry {
+	var got []Entry
+	for len(got) < n {
+		select {
+		case e := <-entries:
+			got = append(got, e)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d entries, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestEngine_StreamsClauses(t *testing.T) {
+	e, speaker, entries := newTestEngine(t, Config{})
+
+	// Partial results are translated as soon as a long enough clause is complete
+	require.NoError(t, e.HandleTranscript(LegA, "你好，", false))
+	require.NoError(t, e.HandleTranscript(LegA, "你好，我想预订明天的", false))
+	require.NoError(t, e.HandleTranscript(LegA, "你好，我想预订明天的房间。请问", false))
+	got := waitEntries(t, entries, 1)
+	assert.Equal(t, "你好，我想预订明天的房间。", got[0].Source, "short clauses wait for more text")
+	require.NoError(t, e.HandleTranscript(LegA, "你好，我想预订明天的房间。请问有空房吗", true))
+	got = waitEntries(t, entries, 1)
+	assert.Equal(t, "请问有空房吗", got[0].Source, "the rest is translated when the utterance ends")
+
+	require.NoError(t, e.HandleTranscript(LegB, "Yes, we have rooms.", true))
+	got = waitEntries(t, entries, 1)
+	assert.Equal(t, LegB, got[0].Leg)
+	assert.Equal(t, "en-US", got[0].SourceLanguage)
+	assert.Equal(t, "[zh-CN]Yes, we have rooms.", got[0].Translation)
+
+	said := speaker.all()
+	require.Len(t, said, 3)
+	for _, s := range said[:2] {
+		assert.Equal(t, LegB, s.leg, "the caller's speech is played to the other party")
+		assert.Equal(t, "en-US", s.language)
+	}
+	assert.Equal(t, "[en-US]请问有空房吗", said[1].text)
+	assert.Equal(t, LegA, said[2].leg)
+
+	transcript := e.Transcript()
+	require.Len(t, transcript, 3)
+	for i, entry := range transcript {
+		assert.Equal(t, i+1, entry.Seq)
+	}
+}
+
+func TestEngine_ToggleAndErrors(t *testing.T) {
+	e, speaker, entries := newTestEngine(t, Config{Disabled: true})
+	assert.False(t, e.Enabled())
+	require.NoError(t, e.HandleTranscript(LegA, "没有翻译的时候说的话。", true))
+
+	e.SetEnabled(true)
+	require.NoError(t, e.HandleTranscript(LegA, "please fail now", true))
+	got := waitEntries(t, entries, 1)
+	assert.Equal(t, "llm unavailable", got[0].Error)
+	assert.Empty(t, speaker.all(), "failed translations are not spoken")
+	assert.Len(t, e.Transcript(), 1, "speech while disabled is not interpreted")
+
+	require.NoError(t, e.HandleTranscript(LegA, "嗯，", true), "filler words are interpreted")
+	waitEntries(t, entries, 1)
+	require.NoError(t, e.HandleTranscript(LegA, "，。", true))
+
+	e.Close()
+	assert.ErrorIs(t, e.HandleTranscript(LegA, "再见", true), ErrClosed)
+}
+
+func TestClauseCut(t *testing.T) {
+	assert.Equal(t, 0, clauseCut([]rune("你好，"), 6))
+	assert.Equal(t, 10, clauseCut([]rune("你好，今天天气不错，我们"), 6))
+	assert.Equal(t, 6, clauseCut([]rune("Hello, world"), 6))
+	assert.Equal(t, 0, clauseCut([]rune("no boundary yet"), 6))
+}
+
+type fakeLLM struct {
+	llm.LLMProvider
+	system   string
+	resets   int
+	options  llm.QueryOptions
+	response string
+}
+
+func (f *fakeLLM) ResetMessages()                { f.resets++ }
+func (f *fakeLLM) SetSystemPrompt(prompt string) { f.system = prompt }
+func (f *fakeLLM) QueryWithOptions(text string, options llm.QueryOptions) (string, error) {
+	f.options = options
+	return f.response, nil
+}
+
+func TestLLMTranslator(t *testing.T) {
+	provider := &fakeLLM{response: " Hello \n"}
+	translator := NewLLMTranslator("gpt-4o-mini", provider)
+	out, err := translator.Translate(context.Background(), "你好", "zh-CN", "en-US")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", out)
+	assert.Equal(t, 1, provider.resets, "history is cleared before each clause")
+	assert.Contains(t, provider.system, "from zh-CN into en-US")
+	assert.Equal(t, "gpt-4o-mini", provider.options.Model)
+	assert.Zero(t, *provider.options.Temperature)
+
+	// The provider is returned to the pool
+	_, err = translator.Translate(context.Background(), "再见", "zh-CN", "en-US")
+	require.NoError(t, err)
+
+	_, err = NewLLMTranslator("").Translate(context.Background(), "x", "a", "b")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+)
+
+// LLMTranslator translates with LLM providers. Providers keep a conversation
+// history, so each one translates one clause at a time and is reset before
+// every clause; pass one provider per leg so both legs translate concurrently
+type LLMTranslator struct {
+	model     string
+	providers chan llm.LLMProvider
+}
+
+// NewLLMTranslator creates a translator, model may be empty to use the providers' default
+func NewLLMTranslator(model string, providers ...llm.LLMProvider) *LLMTranslator {
+	t := &LLMTranslator{model: model, providers: make(chan llm.LLMProvider, len(providers))}
+	for _, p := range providers {
+		t.providers <- p
+	}
+	return t
+}
+
+// Translate implements Translator
+func (t *LLMTranslator) Translate(ctx context.Context, text, from, to string) (string, error) {
+	if cap(t.providers) == 0 {
+		return "", errors.New("no LLM provider")
+	}
+	var provider llm.LLMProvider
+	select {
+	case provider = <-t.providers:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { t.providers <- provider }()
+
+	provider.ResetMessages()
+	provider.SetSystemPrompt(TranslationPrompt(from, to))
+	temperature := float32(0)
+	out, err := provider.QueryWithOptions(text, llm.QueryOptions{Model: t.model, Temperature: &temperature})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// TranslationPrompt the system prompt of an interpreter translating from one language to another
+func TranslationPrompt(from, to string) string {
+	return fmt.Sprintf("You are a professional phone interpreter. Translate what the caller says from %s into %s. "+
+		"The text is part of a live conversation and may be an unfinished sentence; translate it as is, do not complete it. "+
+		"Keep names, numbers and the speaker's tone. Reply with the translation only, without quotes, notes or explanations.", from, to)
+}
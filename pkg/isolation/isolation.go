@@ -0,0 +1,204 @@
+package isolation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Finding reasons
+const (
+	// ReasonMarkerVisible the response contains the marker of another tenant's records
+	ReasonMarkerVisible = "marker_visible"
+	// ReasonDetailAccessible a detail endpoint answered successfully for another tenant's record
+	ReasonDetailAccessible = "detail_accessible"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+// Tenant a synthetic user of one organization with the records seeded for it
+type Tenant struct {
+	Name   string
+	Token  string // Credential sent in the Auditor's auth header
+	Marker string // Unique string contained in every record seeded for the tenant
+	// Resources fills the {placeholders} of probe paths, e.g. "assistant": "12"
+	Resources map[string]string
+}
+
+// Probe a list or detail endpoint to exercise. Path placeholders are filled with
+// the resources of the tenant owning the records; a probe without placeholders
+// is a list that must only show the viewer's own records.
+type Probe struct {
+	Name   string
+	Method string // GET when empty
+	Path   string // Relative to the Auditor's prefix, e.g. /assistant/{assistant}
+	// Detail the path addresses a single record, so any successful response for
+	// another tenant's record is a leak even if the marker is not echoed
+	Detail bool
+}
+
+// Finding a record of one tenant visible to another
+type Finding struct {
+	Probe  string `json:"probe"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Viewer string `json:"viewer"`
+	Owner  string `json:"owner"`
+	Status int    `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// Inconclusive a probe that failed for the owner of the records or, for lists, did
+// not show the owner its own records, so the absence of findings says nothing about
+// it (route changed, seeding incomplete, ...)
+type Inconclusive struct {
+	Probe  string `json:"probe"`
+	Path   string `json:"path"`
+	Tenant string `json:"tenant"`
+	Status int    `json:"status"`
+}
+
+// Report result of an audit run
+type Report struct {
+	StartedAt    time.Time      `json:"startedAt"`
+	Duration     string         `json:"duration"`
+	Tenants      []string       `json:"tenants"`
+	Probes       int            `json:"probes"`
+	Requests     int            `json:"requests"`
+	Findings     []Finding      `json:"findings"`
+	Inconclusive []Inconclusive `json:"inconclusive"`
+}
+
+// Passed reports whether no record crossed a tenant boundary and every probe
+// was conclusive
+func (r *Report) Passed() bool {
+	return len(r.Findings) == 0 && len(r.Inconclusive) == 0
+}
+
+// Auditor sends probe requests straight to an HTTP handler, normally the API router
+type Auditor struct {
+	Handler    http.Handler
+	Prefix     string // Prepended to every probe path
+	AuthHeader string
+	AuthPrefix string // Prepended to tenant tokens, e.g. "Bearer "
+}
+
+// Run exercises every probe as every tenant and reports the records visible
+// across tenants. It stops early with a partial report when ctx is done.
+func (a *Auditor) Run(ctx context.Context, tenants []Tenant, probes []Probe) *Report {
+	started := time.Now()
+	report := &Report{StartedAt: started, Probes: len(probes), Findings: []Finding{}, Inconclusive: []Inconclusive{}}
+	for _, t := range tenants {
+		report.Tenants = append(report.Tenants, t.Name)
+	}
+
+	for _, probe := range probes {
+		method := probe.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		scoped := placeholderPattern.MatchString(probe.Path)
+
+		for _, owner := range tenants {
+			path, ok := fillPath(probe.Path, owner.Resources)
+			if !ok {
+				continue
+			}
+			for _, viewer := range tenants {
+				if ctx.Err() != nil {
+					report.Duration = time.Since(started).String()
+					return report
+				}
+				// Lists are run once per viewer, each viewer owns its own records
+				if !scoped && viewer.Name != owner.Name {
+					continue
+				}
+				status, body := a.request(ctx, method, path, viewer)
+				report.Requests++
+				success := succeeded(status, body)
+
+				if viewer.Name == owner.Name {
+					// Lists must show the owner its records, details only have to answer
+					if !success || (!probe.Detail && !bytes.Contains(body, []byte(owner.Marker))) {
+						report.Inconclusive = append(report.Inconclusive, Inconclusive{Probe: probe.Name, Path: path, Tenant: owner.Name, Status: status})
+					}
+					if scoped {
+						continue
+					}
+					// A list may still show the records of the other tenants
+					for _, other := range tenants {
+						if other.Name != viewer.Name && bytes.Contains(body, []byte(other.Marker)) {
+							report.Findings = append(report.Findings, Finding{Probe: probe.Name, Method: method, Path: path,
+								Viewer: viewer.Name, Owner: other.Name, Status: status, Reason: ReasonMarkerVisible})
+						}
+					}
+					continue
+				}
+
+				reason := ""
+				switch {
+				case bytes.Contains(body, []byte(owner.Marker)):
+					reason = ReasonMarkerVisible
+				case probe.Detail && success:
+					reason = ReasonDetailAccessible
+				}
+				if reason != "" {
+					report.Findings = append(report.Findings, Finding{Probe: probe.Name, Method: method, Path: path,
+						Viewer: viewer.Name, Owner: owner.Name, Status: status, Reason: reason})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Probe < report.Findings[j].Probe
+	})
+	report.Duration = time.Since(started).String()
+	return report
+}
+
+func (a *Auditor) request(ctx context.Context, method, path string, viewer Tenant) (int, []byte) {
+	req := httptest.NewRequest(method, a.Prefix+path, nil).WithContext(ctx)
+	if a.AuthHeader != "" {
+		req.Header.Set(a.AuthHeader, a.AuthPrefix+viewer.Token)
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	rec := httptest.NewRecorder()
+	a.Handler.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.Bytes()
+}
+
+// fillPath replaces the placeholders with the tenant's resources, false when the
+// tenant has no such resource
+func fillPath(path string, resources map[string]string) (string, bool) {
+	ok := true
+	filled := placeholderPattern.ReplaceAllStringFunc(path, func(m string) string {
+		value, found := resources[strings.Trim(m, "{}")]
+		if !found {
+			ok = false
+		}
+		return value
+	})
+	return filled, ok
+}
+
+// succeeded treats 2xx responses as successful unless their JSON envelope carries
+// a failure code, as handlers answer failures with HTTP 200 and code 500
+func succeeded(status int, body []byte) bool {
+	if status < 200 || status >= 300 {
+		return false
+	}
+	var envelope struct {
+		Code *int `json:"code"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Code != nil {
+		return *envelope.Code >= 200 && *envelope.Code < 300
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package isolation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	ID    string
+	Owner string
+	Name  string
+}
+
+// fakeAPI serves /items and /items/:id, leaking records when the flags are set
+type fakeAPI struct {
+	records    []record
+	leakList   bool
+	leakDetail bool
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	viewer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if viewer == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	if path == "/items" {
+		var items []record
+		for _, rec := range f.records {
+			if rec.Owner == viewer || f.leakList {
+				items = append(items, rec)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"code": 200, "data": items})
+		return
+	}
+	id := strings.TrimPrefix(path, "/items/")
+	for _, rec := range f.records {
+		if rec.ID != id {
+			continue
+		}
+		if rec.Owner != viewer && !f.leakDetail {
+			json.NewEncoder(w).Encode(map[string]any{"code": 500, "msg": "forbidden"})
+			return
+		}
+		// Detail omits the name, so only the Detail flag can catch a leak
+		json.NewEncoder(w).Encode(map[string]any{"code": 200, "data": map[string]string{"id": rec.ID}, "owner": viewer})
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func newFixture() (*fakeAPI, []Tenant) {
+	api := &fakeAPI{records: []record{
+		{ID: "1", Owner: "tok-a", Name: "item-MARKER-A"},
+		{ID: "2", Owner: "tok-b", Name: "item-MARKER-B"},
+	}}
+	tenants := []Tenant{
+		{Name: "a", Token: "tok-a", Marker: "MARKER-A", Resources: map[string]string{"item": "1"}},
+		{Name: "b", Token: "tok-b", Marker: "MARKER-B", Resources: map[string]string{"item": "2"}},
+	}
+	return api, tenants
+}
+
+func TestAuditor_Run(t *testing.T) {
+	api, tenants := newFixture()
+	auditor := &Auditor{Handler: api, Prefix: "/api", AuthHeader: "Authorization", AuthPrefix: "Bearer "}
+	probes := []Probe{{Name: "list", Path: "/items"}}
+
+	report := auditor.Run(context.Background(), tenants, probes)
+	assert.True(t, report.Passed(), "%+v", report)
+	assert.Equal(t, 2, report.Requests)
+	assert.Equal(t, []string{"a", "b"}, report.Tenants)
+
+	api.leakList = true
+	report = auditor.Run(context.Background(), tenants, probes)
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, Finding{Probe: "list", Method: http.MethodGet, Path: "/items", Viewer: "a", Owner: "b", Status: 200, Reason: ReasonMarkerVisible}, report.Findings[0])
+	assert.Empty(t, report.Inconclusive)
+}
+
+func TestAuditor_RunDetail(t *testing.T) {
+	api, tenants := newFixture()
+	auditor := &Auditor{Handler: api, Prefix: "/api", AuthHeader: "Authorization", AuthPrefix: "Bearer "}
+	probes := []Probe{{Name: "detail", Path: "/items/{item}", Detail: true}}
+
+	report := auditor.Run(context.Background(), tenants, probes)
+	assert.True(t, report.Passed(), "%+v", report)
+	assert.Equal(t, 4, report.Requests)
+
+	api.leakDetail = true
+	report = auditor.Run(context.Background(), tenants, probes)
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, ReasonDetailAccessible, report.Findings[0].Reason)
+	assert.Equal(t, "/items/1", report.Findings[0].Path)
+	assert.Equal(t, "b", report.Findings[0].Viewer)
+	assert.Equal(t, "a", report.Findings[0].Owner)
+}
+
+func TestAuditor_RunSkipsMissingResources(t *testing.T) {
+	api, tenants := newFixture()
+	auditor := &Auditor{Handler: api, Prefix: "/api", AuthHeader: "Authorization", AuthPrefix: "Bearer "}
+	report := auditor.Run(context.Background(), tenants, []Probe{{Name: "device", Path: "/items/{device}", Detail: true}})
+	assert.Zero(t, report.Requests)
+	assert.True(t, report.Passed())
+
+	// A list that does not show the owner its records proves nothing
+	report = auditor.Run(context.Background(), tenants, []Probe{{Name: "missing", Path: "/items/none"}})
+	assert.Len(t, report.Inconclusive, 2)
+	assert.Empty(t, report.Findings)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report = auditor.Run(ctx, tenants, []Probe{{Name: "list", Path: "/items"}})
+	assert.Zero(t, report.Requests)
+}
+
+func TestSucceeded(t *testing.T) {
+	assert.True(t, succeeded(200, []byte(`{"code":200}`)))
+	assert.True(t, succeeded(204, nil))
+	assert.True(t, succeeded(200, []byte(`[1,2]`)))
+	assert.False(t, succeeded(200, []byte(`{"code":500,"msg":"denied"}`)))
+	assert.False(t, succeeded(403, []byte(`{"code":200}`)))
+}
@@ -307,12 +307,29 @@ func (a *aliyunKnowledgeBase) DeleteIndex(ctx context.Context, knowledgeKey stri
 	return nil
 }
 
-// UploadDocument uploads document to knowledge base
+// UploadDocument uploads document to knowledge base and waits for indexing to finish
 func (a *aliyunKnowledgeBase) UploadDocument(ctx context.Context, knowledgeKey string, file multipart.File, header *multipart.FileHeader, metadata map[string]interface{}) error {
+	jobId, err := a.SubmitDocument(ctx, knowledgeKey, file, header, metadata)
+	if err != nil {
+		return err
+	}
+
+	// Wait for indexing job to complete (poll status)
+	if jobId != "" {
+		if err := a.waitForIndexJobCompletion(jobId, knowledgeKey); err != nil {
+			return fmt.Errorf("等待索引完成失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// SubmitDocument uploads document and submits the indexing job without waiting for it,
+// the job outcome is delivered by the Bailian callback
+func (a *aliyunKnowledgeBase) SubmitDocument(ctx context.Context, knowledgeKey string, file multipart.File, header *multipart.FileHeader, metadata map[string]interface{}) (string, error) {
 	// 1. Calculate file MD5 and size
 	md5Hash, err := calculateMD5(file)
 	if err != nil {
-		return fmt.Errorf("failed to calculate MD5: %w", err)
+		return "", fmt.Errorf("failed to calculate MD5: %w", err)
 	}
 
 	fileSize := fmt.Sprintf("%d", header.Size)
@@ -320,12 +337,12 @@ func (a *aliyunKnowledgeBase) UploadDocument(ctx context.Context, knowledgeKey s
 	// 2. Apply for upload lease
 	lease, err := a.applyLease(header, md5Hash, fileSize)
 	if err != nil {
-		return fmt.Errorf("failed to apply upload lease: %w", err)
+		return "", fmt.Errorf("failed to apply upload lease: %w", err)
 	}
 
 	leaseBody := lease.GetBody()
 	if leaseBody == nil || leaseBody.Data == nil || leaseBody.Data.Param == nil {
-		return fmt.Errorf("申请上传租约失败: 响应数据不完整")
+		return "", fmt.Errorf("申请上传租约失败: 响应数据不完整")
 	}
 
 	// 3. 上传文件到预签名URL
@@ -343,14 +360,14 @@ func (a *aliyunKnowledgeBase) UploadDocument(ctx context.Context, knowledgeKey s
 
 	err = uploadFileToURL(*preSignedUrl, headers, file)
 	if err != nil {
-		return fmt.Errorf("上传文件失败: %w", err)
+		return "", fmt.Errorf("上传文件失败: %w", err)
 	}
 
 	// 4. 调用AddFile接口
 	leaseId := *leaseBody.Data.FileUploadLeaseId
 	fileResponse, err := a.addFile(leaseId)
 	if err != nil {
-		return fmt.Errorf("添加文件失败: %w", err)
+		return "", fmt.Errorf("添加文件失败: %w", err)
 	}
 
 	fileId := *fileResponse.GetBody().Data.FileId
@@ -380,24 +397,16 @@ func (a *aliyunKnowledgeBase) UploadDocument(ctx context.Context, knowledgeKey s
 	if err != nil {
 		// If adding document fails and we just created the index, it might be because the index isn't ready yet
 		if indexCreated {
-			return fmt.Errorf("提交添加文档任务失败（索引刚创建）: %w", err)
+			return "", fmt.Errorf("提交添加文档任务失败（索引刚创建）: %w", err)
 		}
 		// If index already existed, this is a real error
-		return fmt.Errorf("提交添加文档任务失败: %w", err)
+		return "", fmt.Errorf("提交添加文档任务失败: %w", err)
 	}
 
-	// 7. Wait for indexing job to complete (poll status)
-	if jobResponse != nil && jobResponse.GetBody() != nil && jobResponse.GetBody().Data != nil {
-		jobId := jobResponse.GetBody().Data.Id
-		if jobId != nil {
-			err = a.waitForIndexJobCompletion(*jobId, knowledgeKey)
-			if err != nil {
-				return fmt.Errorf("等待索引完成失败: %w", err)
-			}
-		}
+	if jobResponse != nil && jobResponse.GetBody() != nil && jobResponse.GetBody().Data != nil && jobResponse.GetBody().Data.Id != nil {
+		return *jobResponse.GetBody().Data.Id, nil
 	}
-
-	return nil
+	return "", nil
 }
 
 // DeleteDocument 从知识库删除文档（阿里云不支持单独删除文档，需要删除整个知识库）
@@ -494,11 +503,11 @@ func (a *aliyunKnowledgeBase) waitForIndexJobCompletion(jobId, indexId string) e
 		}
 
 		// Check if job is completed
-		if *status == "SUCCEEDED" {
+		if *status == IndexJobStatusSucceeded {
 			return nil
 		}
 
-		if *status == "FAILED" {
+		if *status == IndexJobStatusFailed {
 			return fmt.Errorf("indexing job failed")
 		}
 
@@ -58,6 +58,12 @@ const (
 	DefaultPineconeDimension = 1536
 )
 
+// Index job status values reported by Aliyun Bailian
+const (
+	IndexJobStatusSucceeded = "SUCCEEDED"
+	IndexJobStatusFailed    = "FAILED"
+)
+
 // Metadata key constants
 const (
 	MetadataKeyUserID = "user_id"
@@ -69,6 +75,7 @@ const (
 const (
 	MetadataSourceAPICreate = "api_create"
 	MetadataSourceAPIUpload = "api_upload"
+	MetadataSourceFAQReview = "faq_review"
 )
 
 // Knowledge base name separator
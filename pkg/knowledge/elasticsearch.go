@@ -10,6 +10,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
 )
 
 // elasticsearchKnowledgeBase Elasticsearch全文搜索实现
@@ -41,7 +43,7 @@ func NewElasticsearchKnowledgeBase(config map[string]interface{}) (KnowledgeBase
 		username:   username,
 		password:   password,
 		indexName:  indexName,
-		httpClient: &http.Client{},
+		httpClient: xhttp.NewClient("elasticsearch", 0),
 	}, nil
 }
 
@@ -404,6 +406,160 @@ func (e *elasticsearchKnowledgeBase) GetDocument(ctx context.Context, knowledgeK
 	return io.NopCloser(strings.NewReader(content)), nil
 }
 
+// CountChunks 统计索引中的文档数
+func (e *elasticsearchKnowledgeBase) CountChunks(ctx context.Context, knowledgeKey string) (int, error) {
+	var countResp struct {
+		Count int `json:"count"`
+	}
+	url := fmt.Sprintf("%s/%s/_count", e.baseURL, knowledgeKey)
+	if err := e.snapshotRequest(ctx, "GET", url, "application/json", nil, &countResp); err != nil {
+		return 0, err
+	}
+	return countResp.Count, nil
+}
+
+// ExportChunks 通过 scroll API 导出所有文档，_source 中除 content 外的字段保存为元数据。
+// 该实现为全文检索，没有向量
+func (e *elasticsearchKnowledgeBase) ExportChunks(ctx context.Context, knowledgeKey string, batchSize int, fn func([]Chunk) error) error {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	type scrollResponse struct {
+		ScrollID string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []struct {
+				ID     string                 `json:"_id"`
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+
+	query, _ := json.Marshal(map[string]interface{}{
+		"size": batchSize,
+		"sort": []string{"_doc"},
+	})
+	var page scrollResponse
+	url := fmt.Sprintf("%s/%s/_search?scroll=1m", e.baseURL, knowledgeKey)
+	if err := e.snapshotRequest(ctx, "POST", url, "application/json", query, &page); err != nil {
+		return err
+	}
+
+	defer func() {
+		// 释放 scroll 上下文，失败时由 ES 超时回收
+		if page.ScrollID != "" {
+			body, _ := json.Marshal(map[string]interface{}{"scroll_id": page.ScrollID})
+			_ = e.snapshotRequest(context.Background(), "DELETE", e.baseURL+"/_search/scroll", "application/json", body, nil)
+		}
+	}()
+
+	for len(page.Hits.Hits) > 0 {
+		chunks := make([]Chunk, 0, len(page.Hits.Hits))
+		for _, hit := range page.Hits.Hits {
+			chunk := Chunk{ID: hit.ID, Metadata: map[string]interface{}{}}
+			for k, v := range hit.Source {
+				if k == "content" {
+					chunk.Content, _ = v.(string)
+					continue
+				}
+				chunk.Metadata[k] = v
+			}
+			chunks = append(chunks, chunk)
+		}
+		if err := fn(chunks); err != nil {
+			return err
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{"scroll": "1m", "scroll_id": page.ScrollID})
+		scrollID := page.ScrollID
+		page = scrollResponse{}
+		if err := e.snapshotRequest(ctx, "POST", e.baseURL+"/_search/scroll", "application/json", body, &page); err != nil {
+			page.ScrollID = scrollID
+			return err
+		}
+		if page.ScrollID == "" {
+			page.ScrollID = scrollID
+		}
+	}
+	return nil
+}
+
+// ImportChunks 通过 bulk API 按原文档 ID 写回
+func (e *elasticsearchKnowledgeBase) ImportChunks(ctx context.Context, knowledgeKey string, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, chunk := range chunks {
+		action := map[string]interface{}{"index": map[string]interface{}{"_index": knowledgeKey, "_id": chunk.ID}}
+		doc := map[string]interface{}{"content": chunk.Content}
+		for k, v := range chunk.Metadata {
+			doc[k] = v
+		}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	var bulkResp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string      `json:"_id"`
+			Error interface{} `json:"error"`
+		} `json:"items"`
+	}
+	if err := e.snapshotRequest(ctx, "POST", e.baseURL+"/_bulk?refresh=true", "application/x-ndjson", buf.Bytes(), &bulkResp); err != nil {
+		return err
+	}
+	if bulkResp.Errors {
+		for _, item := range bulkResp.Items {
+			for _, result := range item {
+				if result.Error != nil {
+					return fmt.Errorf("bulk import of document %s failed: %v", result.ID, result.Error)
+				}
+			}
+		}
+		return fmt.Errorf("bulk import failed")
+	}
+	return nil
+}
+
+// snapshotRequest 发送快照相关的请求并解码响应
+func (e *elasticsearchKnowledgeBase) snapshotRequest(ctx context.Context, method, url, contentType string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	e.addAuth(req)
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s failed with status %d: %s", method, url, resp.StatusCode, string(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 // addAuth 添加HTTP基本认证
 func (e *elasticsearchKnowledgeBase) addAuth(req *http.Request) {
 	if e.username != "" && e.password != "" {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"mime/multipart"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -33,6 +35,43 @@ type SearchResult struct {
 	Source string `json:"source,omitempty"`
 }
 
+// Metadata keys providers use for the source document and page of a chunk
+var (
+	sourceFileMetadataKeys = []string{"filename", "file_name", "doc_name", "title", "source"}
+	pageMetadataKeys       = []string{"page", "page_number", "page_no", "page_label"}
+)
+
+// SourceFile returns the name of the document the chunk was retrieved from, if known
+func (r SearchResult) SourceFile() string {
+	for _, key := range sourceFileMetadataKeys {
+		if name, ok := r.Metadata[key].(string); ok && strings.TrimSpace(name) != "" {
+			return strings.TrimSpace(name)
+		}
+	}
+	return r.Source
+}
+
+// Page returns the 1-based page of the source document the chunk comes from, 0 if unknown
+func (r SearchResult) Page() int {
+	for _, key := range pageMetadataKeys {
+		var page int
+		switch v := r.Metadata[key].(type) {
+		case int:
+			page = v
+		case int64:
+			page = int(v)
+		case float64:
+			page = int(v)
+		case string:
+			page, _ = strconv.Atoi(strings.TrimSpace(v))
+		}
+		if page > 0 {
+			return page
+		}
+	}
+	return 0
+}
+
 // SearchOptions search options
 type SearchOptions struct {
 	// TopK returns top K most relevant results
@@ -89,6 +128,26 @@ type KnowledgeBase interface {
 	GetDocument(ctx context.Context, knowledgeKey string, documentID string) (io.ReadCloser, error)
 }
 
+// AsyncIngester is implemented by providers whose indexing runs as a remote job.
+// SubmitDocument uploads the document and returns as soon as the indexing job is
+// submitted; the outcome arrives later through a provider callback
+type AsyncIngester interface {
+	SubmitDocument(ctx context.Context, knowledgeKey string, file multipart.File, header *multipart.FileHeader, metadata map[string]interface{}) (jobID string, err error)
+}
+
+// IndexJobEvent outcome of a remote indexing job reported by a provider callback
+type IndexJobEvent struct {
+	JobID   string `json:"JobId"`
+	IndexID string `json:"IndexId"`
+	Status  string `json:"Status"`
+	Message string `json:"Message,omitempty"`
+}
+
+// Finished reports whether the job reached a terminal status
+func (e *IndexJobEvent) Finished() bool {
+	return e.Status == IndexJobStatusSucceeded || e.Status == IndexJobStatusFailed
+}
+
 // Manager knowledge base manager for creating and managing knowledge base instances based on config
 type Manager interface {
 	// GetKnowledgeBase gets knowledge base instance by provider type (with cache)
@@ -0,0 +1,34 @@
+package knowledge
+
+import (
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchResult_SourceFile(t *testing.T) {
+	assert.Equal(t, "faq.pdf", SearchResult{Source: "42", Metadata: map[string]interface{}{"filename": "faq.pdf"}}.SourceFile())
+	assert.Equal(t, "guide.docx", SearchResult{Metadata: map[string]interface{}{"doc_name": " guide.docx "}}.SourceFile())
+	assert.Equal(t, "notes.txt", SearchResult{Source: "notes.txt"}.SourceFile())
+	assert.Equal(t, "", SearchResult{}.SourceFile())
+}
+
+func TestSearchResult_Page(t *testing.T) {
+	assert.Equal(t, 3, SearchResult{Metadata: map[string]interface{}{"page": 3}}.Page())
+	assert.Equal(t, 7, SearchResult{Metadata: map[string]interface{}{"page_number": float64(7)}}.Page())
+	assert.Equal(t, 12, SearchResult{Metadata: map[string]interface{}{"page_label": "12"}}.Page())
+	assert.Equal(t, 2, SearchResult{Metadata: map[string]interface{}{"page": int64(0), "page_no": int64(2)}}.Page())
+	assert.Equal(t, 0, SearchResult{Metadata: map[string]interface{}{"page": "cover"}}.Page())
+	assert.Equal(t, 0, SearchResult{}.Page())
+}
+
+func TestQdrantPayloadMetadata(t *testing.T) {
+	metadata := qdrantPayloadMetadata(map[string]*qdrant.Value{
+		"content":  qdrant.NewValueString("chunk text"),
+		"filename": qdrant.NewValueString("faq.pdf"),
+		"page":     qdrant.NewValueInt(4),
+	})
+	assert.Equal(t, map[string]interface{}{"filename": "faq.pdf", "page": int64(4)}, metadata)
+	assert.Equal(t, 4, SearchResult{Metadata: metadata}.Page())
+}
@@ -8,6 +8,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
 )
 
 // pineconeKnowledgeBase Pinecone向量数据库实现
@@ -46,7 +48,7 @@ func NewPineconeKnowledgeBase(config map[string]interface{}) (KnowledgeBase, err
 		baseURL:    baseURL,
 		indexName:  indexName,
 		dimension:  dimension,
-		httpClient: &http.Client{},
+		httpClient: xhttp.NewClient("pinecone", 0),
 	}, nil
 }
 
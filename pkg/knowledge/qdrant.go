@@ -107,7 +107,7 @@ func (q *qdrantKnowledgeBase) Search(ctx context.Context, knowledgeKey string, o
 			result := SearchResult{
 				Content:  content,
 				Score:    1.0, // 列出所有文档时，分数为1.0
-				Metadata: make(map[string]interface{}),
+				Metadata: qdrantPayloadMetadata(point.GetPayload()),
 				Source:   fmt.Sprintf("%v", point.GetId()),
 			}
 			results = append(results, result)
@@ -157,7 +157,7 @@ func (q *qdrantKnowledgeBase) Search(ctx context.Context, knowledgeKey string, o
 		result := SearchResult{
 			Content:  content,
 			Score:    score,
-			Metadata: make(map[string]interface{}),
+			Metadata: qdrantPayloadMetadata(scored.GetPayload()),
 			Source:   idStr,
 		}
 		results = append(results, result)
@@ -352,6 +352,26 @@ func (q *qdrantKnowledgeBase) GetDocument(ctx context.Context, knowledgeKey stri
 }
 
 // parsePointID 将字符串ID转换为uint64
+// qdrantPayloadMetadata copies the scalar payload fields (filename, page, upload metadata)
+// except the chunk content into the result metadata
+func qdrantPayloadMetadata(payload map[string]*qdrant.Value) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	for key, value := range payload {
+		if key == "content" || value == nil {
+			continue
+		}
+		switch kind := value.GetKind().(type) {
+		case *qdrant.Value_StringValue:
+			metadata[key] = kind.StringValue
+		case *qdrant.Value_IntegerValue:
+			metadata[key] = kind.IntegerValue
+		case *qdrant.Value_DoubleValue:
+			metadata[key] = kind.DoubleValue
+		}
+	}
+	return metadata
+}
+
 func parsePointID(id string) uint64 {
 	var result uint64
 	fmt.Sscanf(id, "%d", &result)
@@ -9,7 +9,10 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
 )
 
 // qdrantRESTKnowledgeBase Qdrant REST API实现
@@ -45,7 +48,7 @@ func NewQdrantRESTKnowledgeBase(config map[string]interface{}) (KnowledgeBase, e
 		baseURL:   strings.TrimSuffix(baseURL, "/"),
 		apiKey:    apiKey,
 		dimension: dimension,
-		client:    &http.Client{},
+		client:    xhttp.NewClient("qdrant", 0),
 	}, nil
 }
 
@@ -497,3 +500,153 @@ func (q *qdrantRESTKnowledgeBase) collectionExists(ctx context.Context, collecti
 	body, _ := io.ReadAll(resp.Body)
 	return false, fmt.Errorf("check collection failed with status %d: %s", resp.StatusCode, string(body))
 }
+
+// snapshotRequest 发送快照相关的 JSON 请求并解码 result
+func (q *qdrantRESTKnowledgeBase) snapshotRequest(ctx context.Context, method, url string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if q.apiKey != "" {
+		req.Header.Set("api-key", q.apiKey)
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s request failed: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s failed with status %d: %s", method, url, resp.StatusCode, string(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CountChunks 精确统计 collection 中的点数
+func (q *qdrantRESTKnowledgeBase) CountChunks(ctx context.Context, knowledgeKey string) (int, error) {
+	var result struct {
+		Result struct {
+			Count int `json:"count"`
+		} `json:"result"`
+	}
+	url := fmt.Sprintf("%s/collections/%s/points/count", q.baseURL, knowledgeKey)
+	if err := q.snapshotRequest(ctx, "POST", url, map[string]interface{}{"exact": true}, &result); err != nil {
+		return 0, err
+	}
+	return result.Result.Count, nil
+}
+
+// ExportChunks 通过 scroll 分页导出所有点，包括向量和 payload
+func (q *qdrantRESTKnowledgeBase) ExportChunks(ctx context.Context, knowledgeKey string, batchSize int, fn func([]Chunk) error) error {
+	if batchSize <= 0 {
+		batchSize = 256
+	}
+	url := fmt.Sprintf("%s/collections/%s/points/scroll", q.baseURL, knowledgeKey)
+
+	var offset json.RawMessage
+	for {
+		payload := map[string]interface{}{
+			"limit":        batchSize,
+			"with_payload": true,
+			"with_vector":  true,
+		}
+		if offset != nil {
+			payload["offset"] = offset
+		}
+
+		var result struct {
+			Result struct {
+				Points []struct {
+					ID      json.RawMessage        `json:"id"`
+					Vector  json.RawMessage        `json:"vector"`
+					Payload map[string]interface{} `json:"payload"`
+				} `json:"points"`
+				NextPageOffset json.RawMessage `json:"next_page_offset"`
+			} `json:"result"`
+		}
+		if err := q.snapshotRequest(ctx, "POST", url, payload, &result); err != nil {
+			return err
+		}
+
+		chunks := make([]Chunk, 0, len(result.Result.Points))
+		for _, point := range result.Result.Points {
+			chunk := Chunk{ID: qdrantPointIDString(point.ID), Metadata: map[string]interface{}{}}
+			// 只支持单一未命名向量，命名向量的 collection 无法原样恢复
+			if len(point.Vector) > 0 && point.Vector[0] == '[' {
+				if err := json.Unmarshal(point.Vector, &chunk.Vector); err != nil {
+					return fmt.Errorf("failed to decode vector of point %s: %w", chunk.ID, err)
+				}
+			} else if len(point.Vector) > 0 && string(point.Vector) != "null" {
+				return fmt.Errorf("point %s uses named vectors, which snapshots do not support", chunk.ID)
+			}
+			for k, v := range point.Payload {
+				if k == "content" {
+					chunk.Content, _ = v.(string)
+					continue
+				}
+				chunk.Metadata[k] = v
+			}
+			chunks = append(chunks, chunk)
+		}
+		if len(chunks) > 0 {
+			if err := fn(chunks); err != nil {
+				return err
+			}
+		}
+
+		if len(result.Result.NextPageOffset) == 0 || string(result.Result.NextPageOffset) == "null" {
+			return nil
+		}
+		offset = result.Result.NextPageOffset
+	}
+}
+
+// ImportChunks 按原 ID 写回点，已有同 ID 的点被覆盖
+func (q *qdrantRESTKnowledgeBase) ImportChunks(ctx context.Context, knowledgeKey string, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	points := make([]map[string]interface{}, 0, len(chunks))
+	for _, chunk := range chunks {
+		payload := map[string]interface{}{"content": chunk.Content}
+		for k, v := range chunk.Metadata {
+			payload[k] = v
+		}
+		points = append(points, map[string]interface{}{
+			"id":      qdrantPointID(chunk.ID),
+			"vector":  chunk.Vector,
+			"payload": payload,
+		})
+	}
+	url := fmt.Sprintf("%s/collections/%s/points?wait=true", q.baseURL, knowledgeKey)
+	return q.snapshotRequest(ctx, "PUT", url, map[string]interface{}{"points": points}, nil)
+}
+
+// qdrantPointIDString 点 ID 为无符号整数或 UUID，整数保留原始文本避免 float64 丢失精度
+func qdrantPointIDString(id json.RawMessage) string {
+	var s string
+	if json.Unmarshal(id, &s) == nil {
+		return s
+	}
+	return string(id)
+}
+
+// qdrantPointID 还原点 ID 的类型，纯数字按整数 ID 写入
+func qdrantPointID(id string) interface{} {
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return n
+	}
+	return id
+}
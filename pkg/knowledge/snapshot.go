@@ -0,0 +1,143 @@
+package knowledge
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SnapshotFormatVersion version of the snapshot file layout written by SnapshotWriter
+const SnapshotFormatVersion = 1
+
+// ErrSnapshotUnsupported the provider cannot export or import raw chunks
+var ErrSnapshotUnsupported = errors.New("knowledge base provider does not support snapshots")
+
+// Chunk a stored chunk with its embedding, as exported to and restored from snapshots
+type Chunk struct {
+	ID       string                 `json:"id"`
+	Content  string                 `json:"content"`
+	Vector   []float32              `json:"vector,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Snapshotter is implemented by providers that can copy their chunks and embeddings
+// out of an index and write them back unchanged, without re-embedding
+type Snapshotter interface {
+	// CountChunks returns the number of chunks stored in the index
+	CountChunks(ctx context.Context, knowledgeKey string) (int, error)
+
+	// ExportChunks pages through every chunk of the index, calling fn with each batch
+	ExportChunks(ctx context.Context, knowledgeKey string, batchSize int, fn func([]Chunk) error) error
+
+	// ImportChunks writes chunks into the index, overwriting chunks with the same ID.
+	// The index must exist
+	ImportChunks(ctx context.Context, knowledgeKey string, chunks []Chunk) error
+}
+
+// SnapshotHeader first line of a snapshot file
+type SnapshotHeader struct {
+	Version      int       `json:"version"`
+	Provider     string    `json:"provider"`
+	KnowledgeKey string    `json:"knowledgeKey"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// SnapshotWriter writes a snapshot as gzip-compressed JSON lines: the header
+// followed by one chunk per line
+type SnapshotWriter struct {
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	count int
+}
+
+// NewSnapshotWriter writes the header to w and returns a writer for the chunks
+func NewSnapshotWriter(w io.Writer, header SnapshotHeader) (*SnapshotWriter, error) {
+	if header.Version == 0 {
+		header.Version = SnapshotFormatVersion
+	}
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	return &SnapshotWriter{gz: gz, enc: enc}, nil
+}
+
+// Write appends chunks to the snapshot
+func (w *SnapshotWriter) Write(chunks ...Chunk) error {
+	for i := range chunks {
+		if err := w.enc.Encode(&chunks[i]); err != nil {
+			return err
+		}
+		w.count++
+	}
+	return nil
+}
+
+// Count returns the number of chunks written so far
+func (w *SnapshotWriter) Count() int {
+	return w.count
+}
+
+// Close flushes the compressed stream, it does not close the underlying writer
+func (w *SnapshotWriter) Close() error {
+	return w.gz.Close()
+}
+
+// SnapshotReader reads a snapshot written by SnapshotWriter
+type SnapshotReader struct {
+	Header SnapshotHeader
+	gz     *gzip.Reader
+	dec    *json.Decoder
+}
+
+// NewSnapshotReader reads and checks the snapshot header
+func NewSnapshotReader(r io.Reader) (*SnapshotReader, error) {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	dec := json.NewDecoder(gz)
+	var header SnapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		gz.Close()
+		return nil, fmt.Errorf("invalid snapshot header: %w", err)
+	}
+	if header.Version < 1 || header.Version > SnapshotFormatVersion {
+		gz.Close()
+		return nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+	return &SnapshotReader{Header: header, gz: gz, dec: dec}, nil
+}
+
+// Next returns up to n chunks, and io.EOF once the snapshot is exhausted
+func (r *SnapshotReader) Next(n int) ([]Chunk, error) {
+	if n <= 0 {
+		n = 1
+	}
+	chunks := make([]Chunk, 0, n)
+	for len(chunks) < n {
+		var chunk Chunk
+		if err := r.dec.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				if len(chunks) > 0 {
+					return chunks, nil
+				}
+				return nil, io.EOF
+			}
+			return chunks, fmt.Errorf("invalid snapshot chunk: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// Close releases the decompressor
+func (r *SnapshotReader) Close() error {
+	return r.gz.Close()
+}
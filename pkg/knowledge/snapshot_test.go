@@ -0,0 +1,178 @@
+package knowledge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotWriterReader(t *testing.T) {
+	var buf bytes.Buffer
+	created := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	w, err := NewSnapshotWriter(&buf, SnapshotHeader{Provider: ProviderQdrant, KnowledgeKey: "1_faq", CreatedAt: created})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(
+		Chunk{ID: "1", Content: "hello", Vector: []float32{0.1, 0.2, 0.3}, Metadata: map[string]interface{}{"filename": "faq.pdf"}},
+		Chunk{ID: "2", Content: "world"},
+	))
+	require.NoError(t, w.Write(Chunk{ID: "3", Content: "again"}))
+	assert.Equal(t, 3, w.Count())
+	require.NoError(t, w.Close())
+
+	r, err := NewSnapshotReader(&buf)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, SnapshotFormatVersion, r.Header.Version)
+	assert.Equal(t, "1_faq", r.Header.KnowledgeKey)
+	assert.True(t, created.Equal(r.Header.CreatedAt))
+
+	batch, err := r.Next(2)
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+	assert.Equal(t, []float32{0.1, 0.2, 0.3}, batch[0].Vector)
+	assert.Equal(t, "faq.pdf", batch[0].Metadata["filename"])
+
+	batch, err = r.Next(2)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, "again", batch[0].Content)
+
+	_, err = r.Next(2)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestSnapshotReader_Invalid(t *testing.T) {
+	_, err := NewSnapshotReader(bytes.NewReader([]byte("not gzip")))
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	w, err := NewSnapshotWriter(&buf, SnapshotHeader{Version: SnapshotFormatVersion + 1})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = NewSnapshotReader(&buf)
+	assert.ErrorContains(t, err, "unsupported snapshot version")
+}
+
+func TestQdrantSnapshotRoundTrip(t *testing.T) {
+	var upserted []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/collections/kb/points/count":
+			_, _ = w.Write([]byte(`{"result":{"count":3}}`))
+		case "/collections/kb/points/scroll":
+			assert.Equal(t, true, req["with_vector"])
+			if req["offset"] == nil {
+				_, _ = w.Write([]byte(`{"result":{"points":[
+					{"id":18446744073709551615,"vector":[0.5,0.25],"payload":{"content":"a","filename":"x.txt"}},
+					{"id":"6f1c9b3e-0d5a-4a9e-9a0e-2f6f4b1d7c11","vector":[1,0],"payload":{"content":"b"}}
+				],"next_page_offset":"7a1c9b3e-0d5a-4a9e-9a0e-2f6f4b1d7c11"}}`))
+				return
+			}
+			assert.Equal(t, "7a1c9b3e-0d5a-4a9e-9a0e-2f6f4b1d7c11", req["offset"])
+			_, _ = w.Write([]byte(`{"result":{"points":[{"id":3,"vector":[0,1],"payload":{"content":"c"}}],"next_page_offset":null}}`))
+		case "/collections/kb2/points":
+			assert.Equal(t, http.MethodPut, r.Method)
+			for _, p := range req["points"].([]interface{}) {
+				upserted = append(upserted, p.(map[string]interface{}))
+			}
+			_, _ = w.Write([]byte(`{"result":{"status":"completed"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	kb, err := NewQdrantRESTKnowledgeBase(map[string]interface{}{ConfigKeyQdrantHost: server.URL})
+	require.NoError(t, err)
+	s := kb.(Snapshotter)
+
+	count, err := s.CountChunks(context.Background(), "kb")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	var chunks []Chunk
+	require.NoError(t, s.ExportChunks(context.Background(), "kb", 2, func(batch []Chunk) error {
+		chunks = append(chunks, batch...)
+		return nil
+	}))
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "18446744073709551615", chunks[0].ID, "integer IDs keep full precision")
+	assert.Equal(t, []float32{0.5, 0.25}, chunks[0].Vector)
+	assert.Equal(t, "a", chunks[0].Content)
+	assert.Equal(t, map[string]interface{}{"filename": "x.txt"}, chunks[0].Metadata)
+	assert.Equal(t, "6f1c9b3e-0d5a-4a9e-9a0e-2f6f4b1d7c11", chunks[1].ID)
+
+	require.NoError(t, s.ImportChunks(context.Background(), "kb2", chunks))
+	require.Len(t, upserted, 3)
+	assert.Equal(t, map[string]interface{}{"content": "a", "filename": "x.txt"}, upserted[0]["payload"])
+	assert.Equal(t, "6f1c9b3e-0d5a-4a9e-9a0e-2f6f4b1d7c11", upserted[1]["id"])
+	assert.Equal(t, float64(3), upserted[2]["id"])
+}
+
+func TestElasticsearchSnapshotRoundTrip(t *testing.T) {
+	var bulk []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/kb/_count":
+			_, _ = w.Write([]byte(`{"count":2}`))
+		case r.URL.Path == "/kb/_search":
+			_, _ = w.Write([]byte(`{"_scroll_id":"s1","hits":{"hits":[{"_id":"d1","_source":{"content":"a","title":"x.txt","metadata":{"user_id":1}}}]}}`))
+		case r.URL.Path == "/_search/scroll" && r.Method == http.MethodPost:
+			var req map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req["scroll_id"] == "s1" {
+				_, _ = w.Write([]byte(`{"_scroll_id":"s2","hits":{"hits":[{"_id":"d2","_source":{"content":"b"}}]}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"_scroll_id":"s2","hits":{"hits":[]}}`))
+		case r.URL.Path == "/_search/scroll" && r.Method == http.MethodDelete:
+			_, _ = w.Write([]byte(`{"succeeded":true}`))
+		case r.URL.Path == "/_bulk":
+			assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var line map[string]interface{}
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				bulk = append(bulk, line)
+			}
+			_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	kb, err := NewElasticsearchKnowledgeBase(map[string]interface{}{"base_url": server.URL, "index_name": "kb"})
+	require.NoError(t, err)
+	s := kb.(Snapshotter)
+
+	count, err := s.CountChunks(context.Background(), "kb")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	var chunks []Chunk
+	require.NoError(t, s.ExportChunks(context.Background(), "kb", 1, func(batch []Chunk) error {
+		chunks = append(chunks, batch...)
+		return nil
+	}))
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "x.txt", chunks[0].Metadata["title"])
+	assert.Equal(t, "b", chunks[1].Content)
+
+	require.NoError(t, s.ImportChunks(context.Background(), "restored", chunks))
+	require.Len(t, bulk, 4)
+	assert.Equal(t, map[string]interface{}{"index": map[string]interface{}{"_index": "restored", "_id": "d1"}}, bulk[0])
+	assert.Equal(t, "a", bulk[1]["content"])
+	assert.Equal(t, "x.txt", bulk[1]["title"])
+}
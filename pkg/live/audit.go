@@ -0,0 +1,177 @@
+package live
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// 域名配置变更操作
+const (
+	ChangeBindPushDomain         = "bindPushDomain"
+	ChangeUnbindPushDomain       = "unbindPushDomain"
+	ChangeUpdatePushDomainConfig = "updatePushDomainConfig"
+	ChangeSetPushDomainSnapshot  = "setPushDomainSnapshot"
+	ChangeBindPlayDomain         = "bindPlayDomain"
+	ChangeUnbindPlayDomain       = "unbindPlayDomain"
+	ChangeUpdatePlayDomainConfig = "updatePlayDomainConfig"
+	ChangeSetPlayDomainTranscode = "setPlayDomainTranscode"
+	ChangeUploadCertificate      = "uploadCertificate"
+	ChangeUpdateCertificate      = "updateCertificate"
+	ChangeDeleteCertificate      = "deleteCertificate"
+)
+
+// redactedFields 密钥和证书内容不写入变更记录，只保留指纹，可以看出是否被轮换
+var redactedFields = map[string]bool{
+	"primaryKey":   true,
+	"secondaryKey": true,
+	"priKey":       true,
+	"cert":         true,
+}
+
+// DomainChange 一次域名配置变更，Before/After 为修改前后的配置（JSON），密钥已替换为指纹
+type DomainChange struct {
+	Operation string          `json:"operation"`
+	Bucket    string          `json:"bucket"`
+	Domain    string          `json:"domain"`
+	Target    string          `json:"target,omitempty"` // 操作的证书 ID
+	Actor     string          `json:"actor,omitempty"`  // WithActor 传入的操作人
+	Request   json.RawMessage `json:"request,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"` // 修改前的配置，不存在或查询失败时为空
+	After     json.RawMessage `json:"after,omitempty"`  // 修改后的配置，解绑、删除或失败时为空
+	Error     string          `json:"error,omitempty"`  // 修改失败时的错误
+	At        time.Time       `json:"at"`
+}
+
+// Succeeded 修改是否成功
+func (c *DomainChange) Succeeded() bool {
+	return c.Error == ""
+}
+
+// ChangeRecorder 接收通过客户端修改的域名配置，由控制台层注入，写入平台审计日志。
+// 修改失败的调用也会记录；RecordDomainChange 同步调用，不应长时间阻塞
+type ChangeRecorder interface {
+	RecordDomainChange(change *DomainChange)
+}
+
+// ChangeRecorderFunc 函数形式的 ChangeRecorder
+type ChangeRecorderFunc func(change *DomainChange)
+
+// RecordDomainChange 实现 ChangeRecorder
+func (f ChangeRecorderFunc) RecordDomainChange(change *DomainChange) {
+	f(change)
+}
+
+// SetChangeRecorder 设置域名配置变更的记录器，nil 表示不记录。
+// 设置后每次修改前后会多查询一次配置
+func (c *BucketClient) SetChangeRecorder(recorder ChangeRecorder) {
+	c.recorder = recorder
+}
+
+// WithActor 本次调用的操作人，写入变更记录
+func WithActor(actor string) CallOption {
+	return func(o *callOptions) {
+		o.actor = actor
+	}
+}
+
+// domainChange 一次修改的描述，before/after 为空时不查询
+type domainChange struct {
+	operation string
+	bucket    string
+	domain    string
+	target    string
+	request   interface{}
+	before    func() (interface{}, error)
+	after     func() (interface{}, error) // 为空时以修改的返回值作为修改后的配置
+	removes   bool                        // 修改后配置不再存在（解绑、删除）
+}
+
+// recordDomainChange 执行修改，设置了记录器时记录修改前后的配置
+func recordDomainChange[T any](c *BucketClient, change domainChange, opts []CallOption, call func() (T, error)) (T, error) {
+	if c.recorder == nil {
+		return call()
+	}
+	options := callOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	record := &DomainChange{
+		Operation: change.operation,
+		Bucket:    change.bucket,
+		Domain:    change.domain,
+		Target:    change.target,
+		Actor:     options.actor,
+		Request:   redactedJSON(change.request),
+	}
+	if change.before != nil {
+		if before, err := change.before(); err == nil {
+			record.Before = redactedJSON(before)
+		}
+	}
+
+	result, err := call()
+	record.At = time.Now()
+	switch {
+	case err != nil:
+		record.Error = err.Error()
+	case change.removes:
+	case change.after != nil:
+		if after, err := change.after(); err == nil {
+			record.After = redactedJSON(after)
+		}
+	default:
+		record.After = redactedJSON(result)
+	}
+	c.recorder.RecordDomainChange(record)
+	return result, err
+}
+
+// findCertificate 按 ID 查找域名证书，不存在时返回 nil
+func (c *BucketClient) findCertificate(bucketName, domain, certID string, opts []CallOption) (interface{}, error) {
+	certs, err := c.ListCertificates(bucketName, domain, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range certs {
+		if certs[i].CertificateID == certID {
+			return &certs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// redactedJSON 序列化配置并把密钥替换为指纹，空值返回 nil
+func redactedJSON(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil
+	}
+	data, _ = json.Marshal(redact(tree))
+	return data
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && redactedFields[key] && s != "" {
+				sum := sha256.Sum256([]byte(s))
+				v[key] = "sha256:" + hex.EncodeToString(sum[:4])
+				continue
+			}
+			v[key] = redact(value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	}
+	return v
+}
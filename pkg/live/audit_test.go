@@ -0,0 +1,105 @@
+package live
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketClient_ChangeRecorder(t *testing.T) {
+	config := PushDomainConfigResponse{Domain: "push.example.com", Enable: true, Auth: &PushDomainAuthConfig{Enable: true, PrimaryKey: "old-key"}}
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Has("pushDomainConfig") && r.Method == http.MethodPatch:
+			var req UpdatePushDomainConfigRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			config.Auth = req.Auth
+			json.NewEncoder(w).Encode(config)
+		case r.URL.Query().Has("pushDomainConfig"):
+			json.NewEncoder(w).Encode(config)
+		case r.URL.Query().Has("pushDomain") && r.Method == http.MethodDelete:
+			w.WriteHeader(qiniuStatusNotFound)
+			w.Write([]byte(`{"error":"domain not found"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+
+	// Without a recorder nothing extra is requested
+	_, err := c.UpdatePushDomainConfig("live", "push.example.com", &UpdatePushDomainConfigRequest{Auth: config.Auth})
+	require.NoError(t, err)
+
+	var changes []*DomainChange
+	c.SetChangeRecorder(ChangeRecorderFunc(func(change *DomainChange) { changes = append(changes, change) }))
+
+	_, err = c.UpdatePushDomainConfig("live", "push.example.com", &UpdatePushDomainConfigRequest{
+		Auth: &PushDomainAuthConfig{Enable: true, PrimaryKey: "new-key"},
+	}, WithActor("user:42"))
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	change := changes[0]
+	assert.Equal(t, ChangeUpdatePushDomainConfig, change.Operation)
+	assert.Equal(t, "live", change.Bucket)
+	assert.Equal(t, "push.example.com", change.Domain)
+	assert.Equal(t, "user:42", change.Actor)
+	assert.True(t, change.Succeeded())
+	assert.False(t, change.At.IsZero())
+
+	var before, after PushDomainConfigResponse
+	require.NoError(t, json.Unmarshal(change.Before, &before))
+	require.NoError(t, json.Unmarshal(change.After, &after))
+	assert.True(t, before.Enable)
+	assert.Regexp(t, `^sha256:[0-9a-f]{8}$`, before.Auth.PrimaryKey, "keys are fingerprinted")
+	assert.NotEqual(t, before.Auth.PrimaryKey, after.Auth.PrimaryKey, "rotation is visible")
+	assert.NotContains(t, string(change.Request), "new-key")
+	assert.NotContains(t, string(change.After), "new-key")
+
+	// Failed calls are recorded too
+	_, err = c.UnbindPushDomain("live", "push.example.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.Len(t, changes, 2)
+	assert.Equal(t, ChangeUnbindPushDomain, changes[1].Operation)
+	assert.False(t, changes[1].Succeeded())
+	assert.NotEmpty(t, changes[1].Before)
+	assert.Empty(t, changes[1].After)
+}
+
+func TestBucketClient_ChangeRecorderCertificate(t *testing.T) {
+	certs := []CertificateInfo{{CertificateID: "cert-1", Domain: "play.example.com", Cert: "-----BEGIN CERTIFICATE-----", PriKey: "secret", NotAfter: 100}}
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			certs[0].NotAfter = 200
+			json.NewEncoder(w).Encode(CertificateResponse{Message: "ok"})
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(certs)
+		case http.MethodDelete:
+			json.NewEncoder(w).Encode(CertificateResponse{Message: "ok"})
+		}
+	})
+	var changes []*DomainChange
+	c.SetChangeRecorder(ChangeRecorderFunc(func(change *DomainChange) { changes = append(changes, change) }))
+
+	_, err := c.UpdateCertificate("live", "play.example.com", "cert-1", &UpdateCertificateRequest{PriKey: "secret-2"})
+	require.NoError(t, err)
+	_, err = c.DeleteCertificate("live", "play.example.com", "cert-1")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	var before, after CertificateInfo
+	require.NoError(t, json.Unmarshal(changes[0].Before, &before))
+	require.NoError(t, json.Unmarshal(changes[0].After, &after))
+	assert.Equal(t, "cert-1", changes[0].Target)
+	assert.Equal(t, int64(100), before.NotAfter)
+	assert.Equal(t, int64(200), after.NotAfter)
+	for _, change := range changes {
+		raw, _ := json.Marshal(change)
+		assert.NotContains(t, string(raw), "secret")
+		assert.NotContains(t, string(raw), "BEGIN CERTIFICATE")
+	}
+	assert.Equal(t, ChangeDeleteCertificate, changes[1].Operation)
+	assert.Empty(t, changes[1].After)
+}
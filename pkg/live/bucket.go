@@ -10,6 +10,7 @@ import (
 
 	"github.com/code-100-precent/LingEcho/pkg/utils"
 	"github.com/code-100-precent/LingEcho/pkg/utils/qiniu/auth"
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
 )
 
 const (
@@ -112,6 +113,10 @@ type BucketClient struct {
 	region     string
 	baseHost   string
 	httpClient *http.Client
+	retry      RetryPolicy
+	recorder   ChangeRecorder
+
+	maxResponseSize int64 // 响应体大小上限，<=0 时使用 DefaultMaxResponseSize
 }
 
 // NewBucketClient 创建新的客户端
@@ -122,13 +127,21 @@ func NewBucketClient() (*BucketClient, error) {
 		return nil, fmt.Errorf("please set QINIU_ACCESS_KEY and QINIU_SECRET_KEY 环境变量")
 	}
 
+	return NewBucketClientWithKeys(accessKey, secretKey), nil
+}
+
+// NewBucketClientWithKeys 使用指定的 AccessKey 和 SecretKey 创建客户端
+func NewBucketClientWithKeys(accessKey, secretKey string) *BucketClient {
 	return &BucketClient{
 		accessKey:  accessKey,
 		secretKey:  secretKey,
 		region:     DefaultRegion,
 		baseHost:   DefaultBaseHost,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
-	}, nil
+		httpClient: xhttp.NewClient("live", 30*time.Second),
+		retry:      DefaultRetryPolicy(),
+
+		maxResponseSize: DefaultMaxResponseSize,
+	}
 }
 
 // SetRegion 设置区域
@@ -146,7 +159,7 @@ func (c *BucketClient) SetHTTPClient(client *http.Client) {
 
 // CreateBucket 创建空间
 // bucketName: 空间名称
-func (c *BucketClient) CreateBucket(bucketName string) (*CreateBucketResponse, error) {
+func (c *BucketClient) CreateBucket(bucketName string, opts ...CallOption) (*CreateBucketResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -186,7 +199,7 @@ func (c *BucketClient) CreateBucket(bucketName string) (*CreateBucketResponse, e
 	req.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -200,7 +213,7 @@ func (c *BucketClient) CreateBucket(bucketName string) (*CreateBucketResponse, e
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -214,7 +227,7 @@ func (c *BucketClient) CreateBucket(bucketName string) (*CreateBucketResponse, e
 
 // DeleteBucket 删除空间
 // bucketName: 空间名称
-func (c *BucketClient) DeleteBucket(bucketName string) (*DeleteBucketResponse, error) {
+func (c *BucketClient) DeleteBucket(bucketName string, opts ...CallOption) (*DeleteBucketResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -254,7 +267,7 @@ func (c *BucketClient) DeleteBucket(bucketName string) (*DeleteBucketResponse, e
 	req.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -268,7 +281,7 @@ func (c *BucketClient) DeleteBucket(bucketName string) (*DeleteBucketResponse, e
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -281,7 +294,7 @@ func (c *BucketClient) DeleteBucket(bucketName string) (*DeleteBucketResponse, e
 }
 
 // ListBuckets 列举空间
-func (c *BucketClient) ListBuckets() (*ListBucketsResponse, error) {
+func (c *BucketClient) ListBuckets(opts ...CallOption) (*ListBucketsResponse, error) {
 	host := c.baseHost
 	path := "/"
 	method := "GET"
@@ -311,7 +324,7 @@ func (c *BucketClient) ListBuckets() (*ListBucketsResponse, error) {
 	req.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -325,7 +338,7 @@ func (c *BucketClient) ListBuckets() (*ListBucketsResponse, error) {
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -340,7 +353,7 @@ func (c *BucketClient) ListBuckets() (*ListBucketsResponse, error) {
 // UpdateBucketConfig 修改空间配置
 // bucketName: 空间名称
 // config: 配置信息
-func (c *BucketClient) UpdateBucketConfig(bucketName string, config *UpdateBucketConfigRequest) (*BucketConfigResponse, error) {
+func (c *BucketClient) UpdateBucketConfig(bucketName string, config *UpdateBucketConfigRequest, opts ...CallOption) (*BucketConfigResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -385,7 +398,7 @@ func (c *BucketClient) UpdateBucketConfig(bucketName string, config *UpdateBucke
 	req.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -399,7 +412,7 @@ func (c *BucketClient) UpdateBucketConfig(bucketName string, config *UpdateBucke
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -413,7 +426,7 @@ func (c *BucketClient) UpdateBucketConfig(bucketName string, config *UpdateBucke
 
 // GetBucketConfig 获取空间配置
 // bucketName: 空间名称
-func (c *BucketClient) GetBucketConfig(bucketName string) (*BucketConfigResponse, error) {
+func (c *BucketClient) GetBucketConfig(bucketName string, opts ...CallOption) (*BucketConfigResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -450,7 +463,7 @@ func (c *BucketClient) GetBucketConfig(bucketName string) (*BucketConfigResponse
 	req.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -464,7 +477,7 @@ func (c *BucketClient) GetBucketConfig(bucketName string) (*BucketConfigResponse
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -479,7 +492,7 @@ func (c *BucketClient) GetBucketConfig(bucketName string) (*BucketConfigResponse
 // BucketExists 检查空间是否存在
 // bucketName: 空间名称
 // 返回: true 表示存在，false 表示不存在，error 表示请求过程中发生的错误
-func (c *BucketClient) BucketExists(bucketName string) (bool, error) {
+func (c *BucketClient) BucketExists(bucketName string, opts ...CallOption) (bool, error) {
 	if bucketName == "" {
 		return false, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -516,7 +529,7 @@ func (c *BucketClient) BucketExists(bucketName string) (bool, error) {
 	req.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, opts...)
 	if err != nil {
 		return false, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -539,5 +552,5 @@ func (c *BucketClient) BucketExists(bucketName string) (bool, error) {
 	}
 
 	// 其他状态码表示请求过程中发生了错误
-	return false, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+	return false, newAPIError(resp, respBody)
 }
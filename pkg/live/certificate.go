@@ -45,7 +45,19 @@ type UpdateCertificateRequest struct {
 // UploadCertificate 上传域名证书
 // bucketName: 空间名称
 // req: 证书信息
-func (c *BucketClient) UploadCertificate(bucketName string, req *UploadCertificateRequest) (*CertificateResponse, error) {
+func (c *BucketClient) UploadCertificate(bucketName string, req *UploadCertificateRequest, opts ...CallOption) (*CertificateResponse, error) {
+	change := domainChange{operation: ChangeUploadCertificate, bucket: bucketName, request: req}
+	if req != nil {
+		change.domain, change.target = req.Domain, req.CertificateID
+		change.after = func() (interface{}, error) { return c.findCertificate(bucketName, req.Domain, req.CertificateID, opts) }
+	}
+	return recordDomainChange(c, change, opts, func() (*CertificateResponse, error) {
+		return c.uploadCertificate(bucketName, req, opts...)
+	})
+}
+
+// uploadCertificate UploadCertificate 的实现，不记录变更
+func (c *BucketClient) uploadCertificate(bucketName string, req *UploadCertificateRequest, opts ...CallOption) (*CertificateResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -102,7 +114,7 @@ func (c *BucketClient) UploadCertificate(bucketName string, req *UploadCertifica
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -116,7 +128,7 @@ func (c *BucketClient) UploadCertificate(bucketName string, req *UploadCertifica
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -132,7 +144,17 @@ func (c *BucketClient) UploadCertificate(bucketName string, req *UploadCertifica
 // bucketName: 空间名称
 // domain: 域名
 // certName: 证书名称
-func (c *BucketClient) DeleteCertificate(bucketName, domain, certName string) (*CertificateResponse, error) {
+func (c *BucketClient) DeleteCertificate(bucketName, domain, certName string, opts ...CallOption) (*CertificateResponse, error) {
+	return recordDomainChange(c, domainChange{
+		operation: ChangeDeleteCertificate, bucket: bucketName, domain: domain, target: certName, removes: true,
+		before: func() (interface{}, error) { return c.findCertificate(bucketName, domain, certName, opts) },
+	}, opts, func() (*CertificateResponse, error) {
+		return c.deleteCertificate(bucketName, domain, certName, opts...)
+	})
+}
+
+// deleteCertificate DeleteCertificate 的实现，不记录变更
+func (c *BucketClient) deleteCertificate(bucketName, domain, certName string, opts ...CallOption) (*CertificateResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -175,7 +197,7 @@ func (c *BucketClient) DeleteCertificate(bucketName, domain, certName string) (*
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -189,7 +211,7 @@ func (c *BucketClient) DeleteCertificate(bucketName, domain, certName string) (*
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -204,7 +226,7 @@ func (c *BucketClient) DeleteCertificate(bucketName, domain, certName string) (*
 // ListCertificates 列举域名证书
 // bucketName: 空间名称
 // domain: 域名
-func (c *BucketClient) ListCertificates(bucketName, domain string) ([]CertificateInfo, error) {
+func (c *BucketClient) ListCertificates(bucketName, domain string, opts ...CallOption) ([]CertificateInfo, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -243,7 +265,7 @@ func (c *BucketClient) ListCertificates(bucketName, domain string) ([]Certificat
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -257,7 +279,7 @@ func (c *BucketClient) ListCertificates(bucketName, domain string) ([]Certificat
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应（返回的是数组）
@@ -274,7 +296,18 @@ func (c *BucketClient) ListCertificates(bucketName, domain string) ([]Certificat
 // domain: 域名
 // certName: 证书名称
 // req: 更新请求
-func (c *BucketClient) UpdateCertificate(bucketName, domain, certName string, req *UpdateCertificateRequest) (*CertificateResponse, error) {
+func (c *BucketClient) UpdateCertificate(bucketName, domain, certName string, req *UpdateCertificateRequest, opts ...CallOption) (*CertificateResponse, error) {
+	return recordDomainChange(c, domainChange{
+		operation: ChangeUpdateCertificate, bucket: bucketName, domain: domain, target: certName, request: req,
+		before: func() (interface{}, error) { return c.findCertificate(bucketName, domain, certName, opts) },
+		after:  func() (interface{}, error) { return c.findCertificate(bucketName, domain, certName, opts) },
+	}, opts, func() (*CertificateResponse, error) {
+		return c.updateCertificate(bucketName, domain, certName, req, opts...)
+	})
+}
+
+// updateCertificate UpdateCertificate 的实现，不记录变更
+func (c *BucketClient) updateCertificate(bucketName, domain, certName string, req *UpdateCertificateRequest, opts ...CallOption) (*CertificateResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -325,7 +358,7 @@ func (c *BucketClient) UpdateCertificate(bucketName, domain, certName string, re
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -339,7 +372,7 @@ func (c *BucketClient) UpdateCertificate(bucketName, domain, certName string, re
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
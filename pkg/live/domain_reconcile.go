@@ -0,0 +1,281 @@
+package live
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 变更计划中的操作
+const (
+	DomainActionBind   = "bind"
+	DomainActionUpdate = "update"
+	DomainActionUnbind = "unbind"
+)
+
+// DomainSpec 空间中一个域名的期望状态，配置字段为空时不受约束
+type DomainSpec struct {
+	Domain        string              `json:"domain"`
+	Kind          string              `json:"kind"`             // push, play
+	Type          string              `json:"type"`             // 绑定使用的域名类型，上行如 pushRtmp，下行如 liveHls
+	Enable        *bool               `json:"enable,omitempty"` // 仅上行域名
+	Auth          *DomainAuthTemplate `json:"auth,omitempty"`
+	HTTPSEnable   *bool               `json:"httpsEnable,omitempty"`
+	CertificateID string              `json:"certificateID,omitempty"`
+	IPLimit       *IPLimitConfig      `json:"ipLimit,omitempty"`     // 仅上行域名
+	URLRewrites   []URLRewriteRule    `json:"urlRewrites,omitempty"` // 仅上行域名
+}
+
+// template 以模板的形式比对和修正域名配置
+func (s *DomainSpec) template() *DomainTemplate {
+	return &DomainTemplate{
+		Name:          s.Domain,
+		Kind:          s.Kind,
+		Enable:        s.Enable,
+		Auth:          s.Auth,
+		HTTPSEnable:   s.HTTPSEnable,
+		CertificateID: s.CertificateID,
+		IPLimit:       s.IPLimit,
+		URLRewrites:   s.URLRewrites,
+	}
+}
+
+// Validate 检查期望状态
+func (s *DomainSpec) Validate() error {
+	if s.Domain == "" {
+		return fmt.Errorf("domain cannot be empty")
+	}
+	if s.Type == "" {
+		return fmt.Errorf("domain %s: type cannot be empty", s.Domain)
+	}
+	return s.template().Validate()
+}
+
+// DomainClient 绑定、解绑和配置空间的上下行域名，BucketClient 实现了该接口
+type DomainClient interface {
+	DomainConfigClient
+	ListPushDomains(bucketName string, opts ...CallOption) (*ListPushDomainsResponse, error)
+	BindPushDomain(bucketName string, req *BindPushDomainRequest, opts ...CallOption) (*BindPushDomainResponse, error)
+	UnbindPushDomain(bucketName, domain string, opts ...CallOption) (*UnbindPushDomainResponse, error)
+	ListPlayDomains(bucketName string, opts ...CallOption) (*ListPlayDomainsResponse, error)
+	BindPlayDomain(bucketName string, req *BindPlayDomainRequest, opts ...CallOption) (*BindPlayDomainResponse, error)
+	UnbindPlayDomain(bucketName, domain string, opts ...CallOption) (*UnbindPlayDomainResponse, error)
+}
+
+var _ DomainClient = (*BucketClient)(nil)
+
+// LiveDomainAPI 开通直播域名所需的域名和证书接口，BucketClient 实现了该接口。
+// 下游服务依赖该接口而不是 BucketClient，单元测试时可以替换为 livetest.Fake
+type LiveDomainAPI interface {
+	DomainClient
+	UploadCertificate(bucketName string, req *UploadCertificateRequest, opts ...CallOption) (*CertificateResponse, error)
+	DeleteCertificate(bucketName, domain, certName string, opts ...CallOption) (*CertificateResponse, error)
+	ListCertificates(bucketName, domain string, opts ...CallOption) ([]CertificateInfo, error)
+	UpdateCertificate(bucketName, domain, certName string, req *UpdateCertificateRequest, opts ...CallOption) (*CertificateResponse, error)
+}
+
+var _ LiveDomainAPI = (*BucketClient)(nil)
+
+// DomainPlanChange 变更计划中的一项操作
+type DomainPlanChange struct {
+	Action  string        `json:"action"` // bind, update, unbind
+	Domain  string        `json:"domain"`
+	Kind    string        `json:"kind"`
+	Drifts  []DomainDrift `json:"drifts,omitempty"` // 需要修正的配置，绑定时为绑定后与期望状态的差异
+	Applied bool          `json:"applied"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// DomainPlan 使空间域名达到期望状态的变更计划
+type DomainPlan struct {
+	Bucket    string             `json:"bucket"`
+	Changes   []DomainPlanChange `json:"changes"`
+	Unchanged []string           `json:"unchanged"` // 已符合期望状态的域名
+}
+
+// InSync 空间域名是否已符合期望状态
+func (p *DomainPlan) InSync() bool {
+	return len(p.Changes) == 0
+}
+
+// Failed 执行失败的操作
+func (p *DomainPlan) Failed() []DomainPlanChange {
+	var failed []DomainPlanChange
+	for _, c := range p.Changes {
+		if c.Error != "" {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// boundDomain 空间中已绑定的域名
+type boundDomain struct {
+	kind     string
+	domain   string
+	bindType string
+}
+
+// Reconcile 比对空间当前绑定的上下行域名与期望状态，生成绑定、修改和解绑的变更计划。
+// opts.AutoApply 时只执行计划中的操作，opts.Prune 时才解绑期望状态中未列出的域名；
+// 单个操作失败记录在该项中，不影响其他操作。期望状态无效或无法列举域名时返回错误
+func Reconcile(client DomainClient, bucket string, desired []DomainSpec, opts ReconcileOptions) (*DomainPlan, error) {
+	seen := make(map[string]bool, len(desired))
+	for i := range desired {
+		if err := desired[i].Validate(); err != nil {
+			return nil, err
+		}
+		key := desired[i].Kind + "/" + desired[i].Domain
+		if seen[key] {
+			return nil, fmt.Errorf("domain %s is listed twice", desired[i].Domain)
+		}
+		seen[key] = true
+	}
+
+	bound, err := listBoundDomains(client, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DomainPlan{Bucket: bucket, Changes: []DomainPlanChange{}, Unchanged: []string{}}
+	for i := range desired {
+		spec := &desired[i]
+		key := spec.Kind + "/" + spec.Domain
+		current, ok := bound[key]
+		delete(bound, key)
+		if !ok {
+			plan.Changes = append(plan.Changes, bindDomain(client, bucket, spec, opts))
+			continue
+		}
+		change := DomainPlanChange{Action: DomainActionUpdate, Domain: spec.Domain, Kind: spec.Kind}
+		if err := updateDomain(client, bucket, spec, current.bindType, &change, opts.AutoApply); err != nil {
+			change.Error = err.Error()
+		}
+		if len(change.Drifts) == 0 && change.Error == "" {
+			plan.Unchanged = append(plan.Unchanged, spec.Domain)
+			continue
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	// 剩余的是期望状态中未列出的域名
+	keys := make([]string, 0, len(bound))
+	for key := range bound {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b := bound[key]
+		change := DomainPlanChange{Action: DomainActionUnbind, Domain: b.domain, Kind: b.kind}
+		if opts.AutoApply && opts.Prune {
+			if err := unbindDomain(client, bucket, b); err != nil {
+				change.Error = err.Error()
+			} else {
+				change.Applied = true
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
+
+func listBoundDomains(client DomainClient, bucket string) (map[string]boundDomain, error) {
+	bound := make(map[string]boundDomain)
+	push, err := client.ListPushDomains(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("列举上行域名失败: %w", err)
+	}
+	for _, d := range push.Domains {
+		bound[DomainKindPush+"/"+d.Domain] = boundDomain{kind: DomainKindPush, domain: d.Domain, bindType: d.Type}
+	}
+	play, err := client.ListPlayDomains(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("列举下行域名失败: %w", err)
+	}
+	for _, d := range play.Domains {
+		bound[DomainKindPlay+"/"+d.Domain] = boundDomain{kind: DomainKindPlay, domain: d.Domain, bindType: d.Type}
+	}
+	return bound, nil
+}
+
+// bindDomain 绑定缺少的域名，绑定后再按期望状态修正配置
+func bindDomain(client DomainClient, bucket string, spec *DomainSpec, opts ReconcileOptions) DomainPlanChange {
+	change := DomainPlanChange{Action: DomainActionBind, Domain: spec.Domain, Kind: spec.Kind}
+	if !opts.AutoApply {
+		return change
+	}
+	var err error
+	if spec.Kind == DomainKindPush {
+		_, err = client.BindPushDomain(bucket, &BindPushDomainRequest{Domain: spec.Domain, Type: spec.Type})
+	} else {
+		_, err = client.BindPlayDomain(bucket, &BindPlayDomainRequest{Domain: spec.Domain, Type: spec.Type})
+	}
+	if err != nil {
+		change.Error = fmt.Sprintf("绑定域名失败: %v", err)
+		return change
+	}
+	change.Applied = true
+	if err := updateDomain(client, bucket, spec, spec.Type, &change, true); err != nil {
+		change.Error = err.Error()
+	}
+	return change
+}
+
+// updateDomain 比对域名配置，apply 时修正差异
+func updateDomain(client DomainClient, bucket string, spec *DomainSpec, bindType string, change *DomainPlanChange, apply bool) error {
+	t := spec.template()
+	typeDrift := bindType != spec.Type
+	if spec.Kind == DomainKindPush {
+		actual, err := client.GetPushDomainConfig(bucket, spec.Domain)
+		if err != nil {
+			return err
+		}
+		change.Drifts = diffPushDomain(t, actual)
+		if typeDrift {
+			change.Drifts = append(change.Drifts, DomainDrift{Field: "type", Expected: spec.Type, Actual: bindType})
+		}
+		if !apply || len(change.Drifts) == 0 {
+			return nil
+		}
+		fix := pushDomainFix(t, actual)
+		if typeDrift {
+			fix.Type = spec.Type
+		}
+		if _, err := client.UpdatePushDomainConfig(bucket, spec.Domain, fix); err != nil {
+			return fmt.Errorf("修正域名配置失败: %w", err)
+		}
+	} else {
+		actual, err := client.GetPlayDomainConfig(bucket, spec.Domain)
+		if err != nil {
+			return err
+		}
+		change.Drifts = diffPlayDomain(t, actual)
+		if typeDrift {
+			change.Drifts = append(change.Drifts, DomainDrift{Field: "type", Expected: spec.Type, Actual: bindType})
+		}
+		if !apply || len(change.Drifts) == 0 {
+			return nil
+		}
+		fix := playDomainFix(t, actual)
+		if typeDrift {
+			fix.Type = spec.Type
+		}
+		if _, err := client.UpdatePlayDomainConfig(bucket, spec.Domain, fix); err != nil {
+			return fmt.Errorf("修正域名配置失败: %w", err)
+		}
+	}
+	change.Applied = true
+	return nil
+}
+
+func unbindDomain(client DomainClient, bucket string, b boundDomain) error {
+	var err error
+	if b.kind == DomainKindPush {
+		_, err = client.UnbindPushDomain(bucket, b.domain)
+	} else {
+		_, err = client.UnbindPlayDomain(bucket, b.domain)
+	}
+	if err != nil {
+		return fmt.Errorf("解绑域名失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package live
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBucketDomains 在 fakeDomainClient 上增加域名的列举、绑定和解绑
+type fakeBucketDomains struct {
+	fakeDomainClient
+	types   map[string]string // kind/domain -> type
+	calls   []string
+	bindErr error
+}
+
+func newFakeBucketDomains() *fakeBucketDomains {
+	return &fakeBucketDomains{
+		fakeDomainClient: fakeDomainClient{
+			push:        map[string]*PushDomainConfigResponse{},
+			play:        map[string]*PlayDomainConfigResponse{},
+			pushUpdates: map[string]*UpdatePushDomainConfigRequest{},
+			playUpdates: map[string]*UpdatePlayDomainConfigRequest{},
+		},
+		types: map[string]string{},
+	}
+}
+
+func (f *fakeBucketDomains) ListPushDomains(_ string, _ ...CallOption) (*ListPushDomainsResponse, error) {
+	resp := &ListPushDomainsResponse{}
+	for domain := range f.push {
+		resp.Domains = append(resp.Domains, PushDomainInfo{Domain: domain, Type: f.types["push/"+domain]})
+	}
+	return resp, nil
+}
+
+func (f *fakeBucketDomains) BindPushDomain(_ string, req *BindPushDomainRequest, _ ...CallOption) (*BindPushDomainResponse, error) {
+	if f.bindErr != nil {
+		return nil, f.bindErr
+	}
+	f.calls = append(f.calls, "bind push "+req.Domain)
+	f.push[req.Domain] = &PushDomainConfigResponse{Domain: req.Domain, Enable: true, Type: req.Type}
+	f.types["push/"+req.Domain] = req.Type
+	return &BindPushDomainResponse{Domain: req.Domain, Type: req.Type}, nil
+}
+
+func (f *fakeBucketDomains) UnbindPushDomain(_, domain string, _ ...CallOption) (*UnbindPushDomainResponse, error) {
+	f.calls = append(f.calls, "unbind push "+domain)
+	delete(f.push, domain)
+	return &UnbindPushDomainResponse{}, nil
+}
+
+func (f *fakeBucketDomains) ListPlayDomains(_ string, _ ...CallOption) (*ListPlayDomainsResponse, error) {
+	resp := &ListPlayDomainsResponse{}
+	for domain := range f.play {
+		resp.Domains = append(resp.Domains, PlayDomainInfo{Domain: domain, Type: f.types["play/"+domain]})
+	}
+	return resp, nil
+}
+
+func (f *fakeBucketDomains) BindPlayDomain(_ string, req *BindPlayDomainRequest, _ ...CallOption) (*BindPlayDomainResponse, error) {
+	if f.bindErr != nil {
+		return nil, f.bindErr
+	}
+	f.calls = append(f.calls, "bind play "+req.Domain)
+	f.play[req.Domain] = &PlayDomainConfigResponse{Domain: req.Domain, Type: req.Type}
+	f.types["play/"+req.Domain] = req.Type
+	return &BindPlayDomainResponse{Domain: req.Domain, Type: req.Type}, nil
+}
+
+func (f *fakeBucketDomains) UnbindPlayDomain(_, domain string, _ ...CallOption) (*UnbindPlayDomainResponse, error) {
+	f.calls = append(f.calls, "unbind play "+domain)
+	delete(f.play, domain)
+	return &UnbindPlayDomainResponse{}, nil
+}
+
+func TestReconcile(t *testing.T) {
+	enabled := true
+	client := newFakeBucketDomains()
+	client.push["push.example.com"] = &PushDomainConfigResponse{Enable: true, IPLimit: &IPLimitConfig{Blacklist: []string{"1.1.1.1"}}}
+	client.types["push/push.example.com"] = "pushRtmp"
+	client.play["hls.example.com"] = &PlayDomainConfigResponse{HTTPSEnable: true, CertificateID: "cert-1"}
+	client.types["play/hls.example.com"] = "liveHls"
+	client.play["old.example.com"] = &PlayDomainConfigResponse{}
+	client.types["play/old.example.com"] = "liveFlv"
+
+	desired := []DomainSpec{
+		{Domain: "push.example.com", Kind: DomainKindPush, Type: "pushRtmp", IPLimit: &IPLimitConfig{Blacklist: []string{"1.1.1.1", "2.2.2.2"}}},
+		{Domain: "hls.example.com", Kind: DomainKindPlay, Type: "liveHls", HTTPSEnable: &enabled, CertificateID: "cert-1"},
+		{Domain: "flv.example.com", Kind: DomainKindPlay, Type: "liveFlv", HTTPSEnable: &enabled, CertificateID: "cert-1"},
+	}
+
+	plan, err := Reconcile(client, "b", desired, ReconcileOptions{})
+	require.NoError(t, err)
+	assert.False(t, plan.InSync())
+	assert.Equal(t, []string{"hls.example.com"}, plan.Unchanged)
+	require.Len(t, plan.Changes, 3)
+	assert.Equal(t, DomainActionUpdate, plan.Changes[0].Action)
+	assert.Equal(t, "ipLimit", plan.Changes[0].Drifts[0].Field)
+	assert.Equal(t, DomainActionBind, plan.Changes[1].Action)
+	assert.Equal(t, DomainActionUnbind, plan.Changes[2].Action)
+	assert.Equal(t, "old.example.com", plan.Changes[2].Domain)
+	for _, c := range plan.Changes {
+		assert.False(t, c.Applied)
+	}
+	assert.Empty(t, client.calls, "planning changes nothing")
+	assert.Empty(t, client.pushUpdates)
+
+	// Without Prune unlisted domains stay bound
+	plan, err = Reconcile(client, "b", desired, ReconcileOptions{AutoApply: true})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Failed())
+	assert.Equal(t, []string{"bind play flv.example.com"}, client.calls)
+	assert.Equal(t, []string{"1.1.1.1", "2.2.2.2"}, client.pushUpdates["push.example.com"].IPLimit.Blacklist)
+	bind := plan.Changes[1]
+	assert.True(t, bind.Applied)
+	assert.Equal(t, []string{"httpsEnable", "certificateID"}, []string{bind.Drifts[0].Field, bind.Drifts[1].Field})
+	assert.Equal(t, "cert-1", client.playUpdates["flv.example.com"].CertificateID, "new domains are configured after binding")
+	assert.False(t, plan.Changes[2].Applied)
+
+	plan, err = Reconcile(client, "b", desired, ReconcileOptions{AutoApply: true, Prune: true})
+	require.NoError(t, err)
+	assert.Contains(t, client.calls, "unbind play old.example.com")
+	assert.NotContains(t, client.play, "old.example.com")
+}
+
+func TestReconcile_TypeDriftAndErrors(t *testing.T) {
+	client := newFakeBucketDomains()
+	client.push["push.example.com"] = &PushDomainConfigResponse{Enable: true}
+	client.types["push/push.example.com"] = "pushRtmp"
+
+	plan, err := Reconcile(client, "b", []DomainSpec{{Domain: "push.example.com", Kind: DomainKindPush, Type: "whip"}}, ReconcileOptions{AutoApply: true})
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 1)
+	assert.Equal(t, "type", plan.Changes[0].Drifts[0].Field)
+	assert.Equal(t, "whip", client.pushUpdates["push.example.com"].Type)
+
+	client.bindErr = errors.New("quota exceeded")
+	plan, err = Reconcile(client, "b", []DomainSpec{
+		{Domain: "push.example.com", Kind: DomainKindPush, Type: "pushRtmp"},
+		{Domain: "new.example.com", Kind: DomainKindPlay, Type: "liveHls"},
+	}, ReconcileOptions{AutoApply: true})
+	require.NoError(t, err)
+	failed := plan.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "new.example.com", failed[0].Domain)
+	assert.Contains(t, failed[0].Error, "quota exceeded")
+
+	for name, spec := range map[string]DomainSpec{
+		"no domain":        {Kind: DomainKindPush, Type: "pushRtmp"},
+		"no type":          {Domain: "a.example.com", Kind: DomainKindPush},
+		"play with ip acl": {Domain: "a.example.com", Kind: DomainKindPlay, Type: "liveHls", IPLimit: &IPLimitConfig{}},
+	} {
+		_, err := Reconcile(client, "b", []DomainSpec{spec}, ReconcileOptions{})
+		assert.Error(t, err, name)
+	}
+	dup := DomainSpec{Domain: "a.example.com", Kind: DomainKindPush, Type: "pushRtmp"}
+	_, err = Reconcile(client, "b", []DomainSpec{dup, dup}, ReconcileOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,354 @@
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// 域名类型
+const (
+	DomainKindPush = "push" // 上行（推流）域名
+	DomainKindPlay = "play" // 下行（播放）域名
+)
+
+// DomainAuthTemplate 模板中的防盗链配置，密钥为空时不比较也不覆盖现有密钥
+type DomainAuthTemplate struct {
+	Type          string `json:"type,omitempty"`
+	Enable        bool   `json:"enable"`
+	PrimaryKey    string `json:"primaryKey,omitempty"`
+	SecondaryKey  string `json:"secondaryKey,omitempty"`
+	ExpireSeconds int    `json:"expireSeconds,omitempty"`
+}
+
+// DomainTemplate 命名的域名配置模板，为空的字段表示不受模板约束
+type DomainTemplate struct {
+	Name          string              `json:"name"`
+	Kind          string              `json:"kind"` // push, play
+	Description   string              `json:"description,omitempty"`
+	Enable        *bool               `json:"enable,omitempty"` // 仅上行域名
+	Auth          *DomainAuthTemplate `json:"auth,omitempty"`
+	HTTPSEnable   *bool               `json:"httpsEnable,omitempty"`
+	CertificateID string              `json:"certificateID,omitempty"`
+	IPLimit       *IPLimitConfig      `json:"ipLimit,omitempty"`     // 仅上行域名
+	URLRewrites   []URLRewriteRule    `json:"urlRewrites,omitempty"` // 仅上行域名
+}
+
+// Validate 检查模板
+func (t *DomainTemplate) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	switch t.Kind {
+	case DomainKindPush:
+	case DomainKindPlay:
+		if t.Enable != nil || t.IPLimit != nil || len(t.URLRewrites) > 0 {
+			return fmt.Errorf("template %s: play domains only support auth, https and certificate settings", t.Name)
+		}
+	default:
+		return fmt.Errorf("template %s: unsupported domain kind %q", t.Name, t.Kind)
+	}
+	if t.HTTPSEnable != nil && *t.HTTPSEnable && t.CertificateID == "" {
+		return fmt.Errorf("template %s: https requires a certificate", t.Name)
+	}
+	return nil
+}
+
+// DomainTemplateRegistry 域名配置模板集合
+type DomainTemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]*DomainTemplate
+}
+
+// NewDomainTemplateRegistry 创建模板集合
+func NewDomainTemplateRegistry() *DomainTemplateRegistry {
+	return &DomainTemplateRegistry{templates: make(map[string]*DomainTemplate)}
+}
+
+// LoadDomainTemplates 从 JSON 数组加载模板
+func LoadDomainTemplates(r io.Reader) (*DomainTemplateRegistry, error) {
+	var templates []*DomainTemplate
+	if err := json.NewDecoder(r).Decode(&templates); err != nil {
+		return nil, fmt.Errorf("解析域名模板失败: %w", err)
+	}
+	registry := NewDomainTemplateRegistry()
+	for _, t := range templates {
+		if err := registry.Register(t); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register 添加或替换模板
+func (r *DomainTemplateRegistry) Register(t *DomainTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.templates[t.Name] = t
+	r.mu.Unlock()
+	return nil
+}
+
+// Remove 删除模板
+func (r *DomainTemplateRegistry) Remove(name string) {
+	r.mu.Lock()
+	delete(r.templates, name)
+	r.mu.Unlock()
+}
+
+// Get 获取模板
+func (r *DomainTemplateRegistry) Get(name string) (*DomainTemplate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[name]
+	return t, ok
+}
+
+// List 按名称排序列出模板
+func (r *DomainTemplateRegistry) List() []*DomainTemplate {
+	r.mu.RLock()
+	list := make([]*DomainTemplate, 0, len(r.templates))
+	for _, t := range r.templates {
+		list = append(list, t)
+	}
+	r.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// DomainBinding 域名与模板的绑定关系
+type DomainBinding struct {
+	Bucket   string `json:"bucket"`
+	Domain   string `json:"domain"`
+	Kind     string `json:"kind"` // push, play
+	Template string `json:"template"`
+}
+
+// DomainDrift 一项与模板不一致的配置
+type DomainDrift struct {
+	Field    string      `json:"field"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+}
+
+// DomainDriftReport 单个域名的比对结果
+type DomainDriftReport struct {
+	DomainBinding
+	Drifts  []DomainDrift `json:"drifts"`
+	Applied bool          `json:"applied"` // 是否已按模板修正
+	Error   string        `json:"error,omitempty"`
+}
+
+// InSync 域名配置是否与模板一致
+func (r *DomainDriftReport) InSync() bool {
+	return r.Error == "" && len(r.Drifts) == 0
+}
+
+// DomainConfigClient 读取和修改域名配置，BucketClient 实现了该接口
+type DomainConfigClient interface {
+	GetPushDomainConfig(bucketName, domain string, opts ...CallOption) (*PushDomainConfigResponse, error)
+	UpdatePushDomainConfig(bucketName, domain string, req *UpdatePushDomainConfigRequest, opts ...CallOption) (*PushDomainConfigResponse, error)
+	GetPlayDomainConfig(bucketName, domain string, opts ...CallOption) (*PlayDomainConfigResponse, error)
+	UpdatePlayDomainConfig(bucketName, domain string, req *UpdatePlayDomainConfigRequest, opts ...CallOption) (*PlayDomainConfigResponse, error)
+}
+
+var _ DomainConfigClient = (*BucketClient)(nil)
+
+// ReconcileOptions 比对选项
+type ReconcileOptions struct {
+	AutoApply bool // 发现差异时按模板修改线上配置
+	Prune     bool // Reconcile 解绑期望配置中未列出的域名，未设置时只在计划中列出
+}
+
+// ReconcileDomains 逐个比对绑定域名的线上配置与模板，单个域名失败不影响其他域名
+func ReconcileDomains(client DomainConfigClient, registry *DomainTemplateRegistry, bindings []DomainBinding, opts ReconcileOptions) []DomainDriftReport {
+	reports := make([]DomainDriftReport, 0, len(bindings))
+	for _, b := range bindings {
+		report := DomainDriftReport{DomainBinding: b, Drifts: []DomainDrift{}}
+		if err := reconcileDomain(client, registry, &report, opts); err != nil {
+			report.Error = err.Error()
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func reconcileDomain(client DomainConfigClient, registry *DomainTemplateRegistry, report *DomainDriftReport, opts ReconcileOptions) error {
+	t, ok := registry.Get(report.Template)
+	if !ok {
+		return fmt.Errorf("domain template %s not found", report.Template)
+	}
+	if t.Kind != report.Kind {
+		return fmt.Errorf("template %s is for %s domains, domain is %s", t.Name, t.Kind, report.Kind)
+	}
+
+	switch report.Kind {
+	case DomainKindPush:
+		actual, err := client.GetPushDomainConfig(report.Bucket, report.Domain)
+		if err != nil {
+			return err
+		}
+		report.Drifts = diffPushDomain(t, actual)
+		if opts.AutoApply && len(report.Drifts) > 0 {
+			if _, err := client.UpdatePushDomainConfig(report.Bucket, report.Domain, pushDomainFix(t, actual)); err != nil {
+				return fmt.Errorf("修正域名配置失败: %w", err)
+			}
+			report.Applied = true
+		}
+	case DomainKindPlay:
+		actual, err := client.GetPlayDomainConfig(report.Bucket, report.Domain)
+		if err != nil {
+			return err
+		}
+		report.Drifts = diffPlayDomain(t, actual)
+		if opts.AutoApply && len(report.Drifts) > 0 {
+			if _, err := client.UpdatePlayDomainConfig(report.Bucket, report.Domain, playDomainFix(t, actual)); err != nil {
+				return fmt.Errorf("修正域名配置失败: %w", err)
+			}
+			report.Applied = true
+		}
+	default:
+		return fmt.Errorf("unsupported domain kind %q", report.Kind)
+	}
+	return nil
+}
+
+func diffPushDomain(t *DomainTemplate, actual *PushDomainConfigResponse) []DomainDrift {
+	drifts := []DomainDrift{}
+	if t.Enable != nil && *t.Enable != actual.Enable {
+		drifts = append(drifts, DomainDrift{Field: "enable", Expected: *t.Enable, Actual: actual.Enable})
+	}
+	drifts = append(drifts, diffDomainCommon(t, actual.HTTPSEnable, actual.CertificateID, authFromPush(actual.Auth))...)
+	if t.IPLimit != nil && !sameIPLimit(t.IPLimit, actual.IPLimit) {
+		drifts = append(drifts, DomainDrift{Field: "ipLimit", Expected: t.IPLimit, Actual: actual.IPLimit})
+	}
+	if len(t.URLRewrites) > 0 && !reflect.DeepEqual(t.URLRewrites, actual.URLRewrites) {
+		drifts = append(drifts, DomainDrift{Field: "urlRewrites", Expected: t.URLRewrites, Actual: actual.URLRewrites})
+	}
+	return drifts
+}
+
+func diffPlayDomain(t *DomainTemplate, actual *PlayDomainConfigResponse) []DomainDrift {
+	return append([]DomainDrift{}, diffDomainCommon(t, actual.HTTPSEnable, actual.CertificateID, authFromPlay(actual.Auth))...)
+}
+
+func diffDomainCommon(t *DomainTemplate, httpsEnable bool, certificateID string, auth *DomainAuthTemplate) []DomainDrift {
+	var drifts []DomainDrift
+	if t.HTTPSEnable != nil && *t.HTTPSEnable != httpsEnable {
+		drifts = append(drifts, DomainDrift{Field: "httpsEnable", Expected: *t.HTTPSEnable, Actual: httpsEnable})
+	}
+	if t.CertificateID != "" && t.CertificateID != certificateID {
+		drifts = append(drifts, DomainDrift{Field: "certificateID", Expected: t.CertificateID, Actual: certificateID})
+	}
+	if t.Auth != nil && !authMatches(t.Auth, auth) {
+		drifts = append(drifts, DomainDrift{Field: "auth", Expected: maskAuth(t.Auth), Actual: maskAuth(auth)})
+	}
+	return drifts
+}
+
+// authMatches 比较防盗链配置，模板未设置密钥时忽略密钥
+func authMatches(want, got *DomainAuthTemplate) bool {
+	if got == nil {
+		return !want.Enable
+	}
+	if want.Enable != got.Enable || want.Type != got.Type || want.ExpireSeconds != got.ExpireSeconds {
+		return false
+	}
+	if want.PrimaryKey != "" && want.PrimaryKey != got.PrimaryKey {
+		return false
+	}
+	return want.SecondaryKey == "" || want.SecondaryKey == got.SecondaryKey
+}
+
+// mergeAuth 以模板为准，模板未设置的密钥保留线上值
+func mergeAuth(want, got *DomainAuthTemplate) DomainAuthTemplate {
+	merged := *want
+	if got != nil {
+		if merged.PrimaryKey == "" {
+			merged.PrimaryKey = got.PrimaryKey
+		}
+		if merged.SecondaryKey == "" {
+			merged.SecondaryKey = got.SecondaryKey
+		}
+	}
+	return merged
+}
+
+// maskAuth 报告中不输出密钥
+func maskAuth(a *DomainAuthTemplate) *DomainAuthTemplate {
+	if a == nil {
+		return nil
+	}
+	masked := *a
+	if masked.PrimaryKey != "" {
+		masked.PrimaryKey = "***"
+	}
+	if masked.SecondaryKey != "" {
+		masked.SecondaryKey = "***"
+	}
+	return &masked
+}
+
+func authFromPush(a *PushDomainAuthConfig) *DomainAuthTemplate {
+	if a == nil {
+		return nil
+	}
+	return &DomainAuthTemplate{Type: a.Type, Enable: a.Enable, PrimaryKey: a.PrimaryKey, SecondaryKey: a.SecondaryKey, ExpireSeconds: a.ExpireSeconds}
+}
+
+func authFromPlay(a *PlayDomainAuthConfig) *DomainAuthTemplate {
+	if a == nil {
+		return nil
+	}
+	return &DomainAuthTemplate{Type: a.Type, Enable: a.Enable, PrimaryKey: a.PrimaryKey, SecondaryKey: a.SecondaryKey, ExpireSeconds: a.ExpireSeconds}
+}
+
+// sameIPLimit 不区分顺序比较黑白名单
+func sameIPLimit(want, got *IPLimitConfig) bool {
+	if got == nil {
+		got = &IPLimitConfig{}
+	}
+	return sameStringSet(want.Whitelist, got.Whitelist) && sameStringSet(want.Blacklist, got.Blacklist)
+}
+
+func sameStringSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sa, sb := append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(sa)
+	sort.Strings(sb)
+	return reflect.DeepEqual(sa, sb)
+}
+
+func pushDomainFix(t *DomainTemplate, actual *PushDomainConfigResponse) *UpdatePushDomainConfigRequest {
+	req := &UpdatePushDomainConfigRequest{
+		Enable:        t.Enable,
+		HTTPSEnable:   t.HTTPSEnable,
+		CertificateID: t.CertificateID,
+		IPLimit:       t.IPLimit,
+		URLRewrites:   t.URLRewrites,
+	}
+	if t.Auth != nil {
+		a := mergeAuth(t.Auth, authFromPush(actual.Auth))
+		req.Auth = &PushDomainAuthConfig{Type: a.Type, Enable: a.Enable, PrimaryKey: a.PrimaryKey, SecondaryKey: a.SecondaryKey, ExpireSeconds: a.ExpireSeconds}
+	}
+	return req
+}
+
+func playDomainFix(t *DomainTemplate, actual *PlayDomainConfigResponse) *UpdatePlayDomainConfigRequest {
+	req := &UpdatePlayDomainConfigRequest{
+		HTTPSEnable:   t.HTTPSEnable,
+		CertificateID: t.CertificateID,
+	}
+	if t.Auth != nil {
+		a := mergeAuth(t.Auth, authFromPlay(actual.Auth))
+		req.Auth = &PlayDomainAuthConfig{Type: a.Type, Enable: a.Enable, PrimaryKey: a.PrimaryKey, SecondaryKey: a.SecondaryKey, ExpireSeconds: a.ExpireSeconds}
+	}
+	return req
+}
@@ -0,0 +1,130 @@
+package live
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDomainClient struct {
+	push        map[string]*PushDomainConfigResponse
+	play        map[string]*PlayDomainConfigResponse
+	pushUpdates map[string]*UpdatePushDomainConfigRequest
+	playUpdates map[string]*UpdatePlayDomainConfigRequest
+}
+
+func (f *fakeDomainClient) GetPushDomainConfig(_, domain string, _ ...CallOption) (*PushDomainConfigResponse, error) {
+	if cfg, ok := f.push[domain]; ok {
+		return cfg, nil
+	}
+	return nil, errors.New("domain not found")
+}
+
+func (f *fakeDomainClient) UpdatePushDomainConfig(_, domain string, req *UpdatePushDomainConfigRequest, _ ...CallOption) (*PushDomainConfigResponse, error) {
+	f.pushUpdates[domain] = req
+	return f.push[domain], nil
+}
+
+func (f *fakeDomainClient) GetPlayDomainConfig(_, domain string, _ ...CallOption) (*PlayDomainConfigResponse, error) {
+	if cfg, ok := f.play[domain]; ok {
+		return cfg, nil
+	}
+	return nil, errors.New("domain not found")
+}
+
+func (f *fakeDomainClient) UpdatePlayDomainConfig(_, domain string, req *UpdatePlayDomainConfigRequest, _ ...CallOption) (*PlayDomainConfigResponse, error) {
+	f.playUpdates[domain] = req
+	return f.play[domain], nil
+}
+
+func TestLoadDomainTemplates(t *testing.T) {
+	registry, err := LoadDomainTemplates(strings.NewReader(`[
+		{"name": "secure-push", "kind": "push", "httpsEnable": true, "certificateID": "cert-1",
+		 "auth": {"type": "typeA", "enable": true, "expireSeconds": 3600},
+		 "ipLimit": {"blacklist": ["1.1.1.1"]}},
+		{"name": "open-play", "kind": "play", "auth": {"enable": false}}
+	]`))
+	require.NoError(t, err)
+	list := registry.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, "open-play", list[0].Name)
+
+	_, err = LoadDomainTemplates(strings.NewReader(`[{"name": "bad", "kind": "play", "ipLimit": {}}]`))
+	assert.Error(t, err)
+	_, err = LoadDomainTemplates(strings.NewReader(`[{"name": "bad", "kind": "push", "httpsEnable": true}]`))
+	assert.Error(t, err)
+}
+
+func TestReconcileDomains(t *testing.T) {
+	enabled := true
+	registry := NewDomainTemplateRegistry()
+	require.NoError(t, registry.Register(&DomainTemplate{
+		Name:          "secure-push",
+		Kind:          DomainKindPush,
+		HTTPSEnable:   &enabled,
+		CertificateID: "cert-1",
+		Auth:          &DomainAuthTemplate{Type: "typeA", Enable: true, ExpireSeconds: 3600},
+		IPLimit:       &IPLimitConfig{Blacklist: []string{"1.1.1.1", "2.2.2.2"}},
+	}))
+	require.NoError(t, registry.Register(&DomainTemplate{Name: "https-play", Kind: DomainKindPlay, HTTPSEnable: &enabled, CertificateID: "cert-1"}))
+
+	client := &fakeDomainClient{
+		push: map[string]*PushDomainConfigResponse{
+			"push-ok.example.com": {
+				HTTPSEnable: true, CertificateID: "cert-1",
+				Auth:    &PushDomainAuthConfig{Type: "typeA", Enable: true, PrimaryKey: "k1", ExpireSeconds: 3600},
+				IPLimit: &IPLimitConfig{Blacklist: []string{"2.2.2.2", "1.1.1.1"}},
+			},
+			"push-drift.example.com": {
+				HTTPSEnable: true, CertificateID: "cert-1",
+				Auth: &PushDomainAuthConfig{Type: "typeA", Enable: false, PrimaryKey: "secret"},
+			},
+		},
+		play: map[string]*PlayDomainConfigResponse{
+			"play.example.com": {CertificateID: "cert-0"},
+		},
+		pushUpdates: map[string]*UpdatePushDomainConfigRequest{},
+		playUpdates: map[string]*UpdatePlayDomainConfigRequest{},
+	}
+	bindings := []DomainBinding{
+		{Bucket: "b", Domain: "push-ok.example.com", Kind: DomainKindPush, Template: "secure-push"},
+		{Bucket: "b", Domain: "push-drift.example.com", Kind: DomainKindPush, Template: "secure-push"},
+		{Bucket: "b", Domain: "play.example.com", Kind: DomainKindPlay, Template: "https-play"},
+		{Bucket: "b", Domain: "missing.example.com", Kind: DomainKindPlay, Template: "https-play"},
+		{Bucket: "b", Domain: "push-ok.example.com", Kind: DomainKindPush, Template: "https-play"},
+	}
+
+	reports := ReconcileDomains(client, registry, bindings, ReconcileOptions{})
+	require.Len(t, reports, 5)
+	assert.True(t, reports[0].InSync())
+
+	fields := func(r DomainDriftReport) []string {
+		var names []string
+		for _, d := range r.Drifts {
+			names = append(names, d.Field)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"auth", "ipLimit"}, fields(reports[1]))
+	assert.Equal(t, "***", reports[1].Drifts[0].Actual.(*DomainAuthTemplate).PrimaryKey, "keys are masked in reports")
+	assert.Equal(t, []string{"httpsEnable", "certificateID"}, fields(reports[2]))
+	assert.NotEmpty(t, reports[3].Error)
+	assert.Contains(t, reports[4].Error, "play domains")
+	assert.Empty(t, client.pushUpdates, "nothing is applied without AutoApply")
+
+	reports = ReconcileDomains(client, registry, bindings, ReconcileOptions{AutoApply: true})
+	assert.False(t, reports[0].Applied)
+	assert.True(t, reports[1].Applied)
+	assert.True(t, reports[2].Applied)
+
+	fix := client.pushUpdates["push-drift.example.com"]
+	require.NotNil(t, fix)
+	assert.True(t, fix.Auth.Enable)
+	assert.Equal(t, "secret", fix.Auth.PrimaryKey, "existing keys are kept")
+	assert.Equal(t, []string{"1.1.1.1", "2.2.2.2"}, fix.IPLimit.Blacklist)
+	assert.NotContains(t, client.pushUpdates, "push-ok.example.com")
+	assert.Equal(t, "cert-1", client.playUpdates["play.example.com"].CertificateID)
+}
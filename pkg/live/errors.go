@@ -0,0 +1,130 @@
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 可用 errors.Is 判断的错误类别
+var (
+	ErrBadRequest         = errors.New("live: bad request")
+	ErrUnauthorized       = errors.New("live: unauthorized")
+	ErrForbidden          = errors.New("live: forbidden")
+	ErrNotFound           = errors.New("live: resource not found")
+	ErrAlreadyExists      = errors.New("live: resource already exists")
+	ErrDomainAlreadyBound = errors.New("live: domain already bound")
+	ErrRateLimited        = errors.New("live: rate limited")
+	ErrServer             = errors.New("live: server error")
+)
+
+// 七牛 API 的扩展状态码
+const (
+	qiniuStatusNotFound = 612 // 资源不存在
+	qiniuStatusExists   = 614 // 资源已存在
+)
+
+// requestIDHeader 七牛响应中的请求 ID，提交工单时需要提供
+const requestIDHeader = "X-Reqid"
+
+// APIError 七牛 API 返回的非 2xx 响应
+// 用 errors.As 取出状态码和错误码，用 errors.Is 判断错误类别（ErrNotFound、ErrDomainAlreadyBound 等）
+type APIError struct {
+	StatusCode int    // HTTP 状态码
+	RequestID  string // X-Reqid 响应头
+	Code       string // 响应体中的错误码，没有时为空
+	Message    string // 响应体中的错误信息，响应体不是 JSON 时为原始内容
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "live: request failed with status %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&sb, ", code %s", e.Code)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&sb, ": %s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&sb, " (request id %s)", e.RequestID)
+	}
+	return sb.String()
+}
+
+// Is 按状态码、错误码和错误信息归类
+func (e *APIError) Is(target error) bool {
+	detail := strings.ToLower(e.Code + " " + e.Message)
+	alreadyBound := strings.Contains(detail, "already bound") || strings.Contains(detail, "alreadybound") ||
+		(strings.Contains(detail, "domain") && (strings.Contains(detail, "exist") || strings.Contains(detail, "already")))
+
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == qiniuStatusNotFound
+	case ErrAlreadyExists:
+		return e.StatusCode == http.StatusConflict || e.StatusCode == qiniuStatusExists ||
+			(e.StatusCode == http.StatusBadRequest && (alreadyBound || strings.Contains(detail, "already exist")))
+	case ErrDomainAlreadyBound:
+		return alreadyBound && e.StatusCode >= 400 && e.StatusCode < 500
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500 && e.StatusCode < 600
+	}
+	return false
+}
+
+// newAPIError 从响应解析错误，兼容 {"error": "..."}、{"code": 400, "message": "..."}
+// 和 {"error_code": "...", "error": "..."} 几种格式
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(requestIDHeader),
+	}
+
+	var parsed struct {
+		Error     string          `json:"error"`
+		ErrorCode json.RawMessage `json:"error_code"`
+		Code      json.RawMessage `json:"code"`
+		Message   string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		apiErr.Message = strings.TrimSpace(string(body))
+		return apiErr
+	}
+
+	apiErr.Message = parsed.Message
+	if apiErr.Message == "" {
+		apiErr.Message = parsed.Error
+	}
+	apiErr.Code = rawCode(parsed.ErrorCode)
+	if apiErr.Code == "" {
+		apiErr.Code = rawCode(parsed.Code)
+	}
+	// 只有 code 没有单独错误码时，code 常常与 HTTP 状态码相同，不重复展示
+	if apiErr.Code == strconv.Itoa(resp.StatusCode) {
+		apiErr.Code = ""
+	}
+	return apiErr
+}
+
+// rawCode 错误码可能是字符串也可能是数字
+func rawCode(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
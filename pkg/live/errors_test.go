@@ -0,0 +1,91 @@
+package live
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func errorResponse(status int, reqID string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}}
+	if reqID != "" {
+		resp.Header.Set(requestIDHeader, reqID)
+	}
+	return resp
+}
+
+func TestNewAPIError_Formats(t *testing.T) {
+	err := newAPIError(errorResponse(http.StatusBadRequest, "req-1"), []byte(`{"error_code":"DomainAlreadyBound","error":"domain live.example.com already bound"}`))
+	assert.Equal(t, &APIError{StatusCode: 400, RequestID: "req-1", Code: "DomainAlreadyBound", Message: "domain live.example.com already bound"}, err)
+	assert.Equal(t, "live: request failed with status 400, code DomainAlreadyBound: domain live.example.com already bound (request id req-1)", err.Error())
+
+	err = newAPIError(errorResponse(http.StatusUnauthorized, ""), []byte(`{"code":401,"message":"bad token"}`))
+	assert.Empty(t, err.Code, "code equal to the status is not repeated")
+	assert.Equal(t, "bad token", err.Message)
+
+	err = newAPIError(errorResponse(http.StatusNotFound, ""), []byte(`{"code":"StreamNotFound","error":"stream not found"}`))
+	assert.Equal(t, "StreamNotFound", err.Code)
+
+	err = newAPIError(errorResponse(http.StatusBadGateway, ""), []byte("<html>bad gateway</html>\n"))
+	assert.Equal(t, "<html>bad gateway</html>", err.Message)
+	assert.Equal(t, "live: request failed with status 502: <html>bad gateway</html>", err.Error())
+}
+
+func TestAPIError_Is(t *testing.T) {
+	bound := newAPIError(errorResponse(http.StatusBadRequest, ""), []byte(`{"error":"domain already exists"}`))
+	wrapped := fmt.Errorf("bind play domain: %w", bound)
+	assert.ErrorIs(t, wrapped, ErrDomainAlreadyBound)
+	assert.ErrorIs(t, wrapped, ErrAlreadyExists)
+	assert.ErrorIs(t, wrapped, ErrBadRequest)
+	assert.NotErrorIs(t, wrapped, ErrUnauthorized)
+
+	var apiErr *APIError
+	require.True(t, errors.As(wrapped, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	cases := []struct {
+		status int
+		body   string
+		is     error
+	}{
+		{http.StatusUnauthorized, `{"error":"bad token"}`, ErrUnauthorized},
+		{http.StatusForbidden, `{"error":"no permission"}`, ErrForbidden},
+		{qiniuStatusNotFound, `{"error":"no such entry"}`, ErrNotFound},
+		{http.StatusNotFound, ``, ErrNotFound},
+		{qiniuStatusExists, `{"error":"file exists"}`, ErrAlreadyExists},
+		{http.StatusConflict, `{"error":"stream exists"}`, ErrAlreadyExists},
+		{http.StatusTooManyRequests, ``, ErrRateLimited},
+		{http.StatusServiceUnavailable, ``, ErrServer},
+	}
+	for _, tc := range cases {
+		err := newAPIError(errorResponse(tc.status, ""), []byte(tc.body))
+		assert.ErrorIs(t, err, tc.is, "status %d", tc.status)
+		assert.NotErrorIs(t, err, ErrDomainAlreadyBound, "status %d", tc.status)
+	}
+	assert.NotErrorIs(t, newAPIError(errorResponse(http.StatusUnauthorized, ""), nil), ErrServer)
+}
+
+func TestBucketClient_ReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(requestIDHeader, "abc123")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"bad token"}`))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	c := &BucketClient{httpClient: server.Client(), retry: NoRetry()}
+	resp, err := c.do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	apiErr := newAPIError(resp, []byte(`{"error":"bad token"}`))
+	assert.Equal(t, "abc123", apiErr.RequestID)
+	assert.ErrorIs(t, apiErr, ErrUnauthorized)
+}
@@ -0,0 +1,466 @@
+// Package livetest 提供 live 包的测试替身：内存实现的 Fake 和模拟七牛直播 API 的 Server，
+// 下游服务无需访问七牛即可测试域名开通逻辑
+package livetest
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/live"
+)
+
+// 七牛 API 的扩展状态码
+const (
+	statusNotFound = 612
+	statusExists   = 614
+)
+
+// Call 一次接口调用
+type Call struct {
+	Method string
+	Bucket string
+	Domain string
+}
+
+// Fake 内存实现的 live.LiveDomainAPI，并发安全。
+// 错误与真实接口一致，可以用 errors.Is 判断 live.ErrNotFound、live.ErrDomainAlreadyBound 等
+type Fake struct {
+	mu      sync.Mutex
+	buckets map[string]*fakeBucket
+	errors  map[string][]error
+	calls   []Call
+	now     func() time.Time
+}
+
+type fakeBucket struct {
+	push  map[string]*live.PushDomainConfigResponse
+	play  map[string]*live.PlayDomainConfigResponse
+	certs map[string][]live.CertificateInfo // 域名 -> 证书
+}
+
+var _ live.LiveDomainAPI = (*Fake)(nil)
+
+// NewFake 创建没有任何域名的 Fake，空间在第一次使用时自动创建
+func NewFake() *Fake {
+	return &Fake{
+		buckets: make(map[string]*fakeBucket),
+		errors:  make(map[string][]error),
+		now:     time.Now,
+	}
+}
+
+// FailNext 使接下来对 method（如 "BindPushDomain"）的调用依次返回 errs
+func (f *Fake) FailNext(method string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[method] = append(f.errors[method], errs...)
+}
+
+// Calls 到目前为止的调用记录
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Reset 清空域名、证书、调用记录和待返回的错误
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets = make(map[string]*fakeBucket)
+	f.errors = make(map[string][]error)
+	f.calls = nil
+}
+
+// NotFoundError 资源不存在的错误
+func NotFoundError(format string, args ...interface{}) error {
+	return &live.APIError{StatusCode: statusNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// AlreadyBoundError 域名已被绑定的错误，与七牛一致返回 400
+func AlreadyBoundError(domain string) error {
+	return &live.APIError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("domain %s already bound", domain)}
+}
+
+// BadRequestError 参数错误
+func BadRequestError(format string, args ...interface{}) error {
+	return &live.APIError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// begin 记录调用并取出待返回的错误，调用方需持有锁
+func (f *Fake) begin(method, bucket, domain string) (*fakeBucket, error) {
+	f.calls = append(f.calls, Call{Method: method, Bucket: bucket, Domain: domain})
+	if errs := f.errors[method]; len(errs) > 0 {
+		f.errors[method] = errs[1:]
+		return nil, errs[0]
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	b, ok := f.buckets[bucket]
+	if !ok {
+		b = &fakeBucket{
+			push:  make(map[string]*live.PushDomainConfigResponse),
+			play:  make(map[string]*live.PlayDomainConfigResponse),
+			certs: make(map[string][]live.CertificateInfo),
+		}
+		f.buckets[bucket] = b
+	}
+	return b, nil
+}
+
+func (f *Fake) timestamp() string {
+	return f.now().UTC().Format(time.RFC3339)
+}
+
+func (b *fakeBucket) bound(domain string) bool {
+	_, push := b.push[domain]
+	_, play := b.play[domain]
+	return push || play
+}
+
+func cname(domain string) string {
+	return domain + ".qiniudns.com"
+}
+
+// ListPushDomains 实现 live.DomainClient
+func (f *Fake) ListPushDomains(bucketName string, _ ...live.CallOption) (*live.ListPushDomainsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("ListPushDomains", bucketName, "")
+	if err != nil {
+		return nil, err
+	}
+	resp := &live.ListPushDomainsResponse{Domains: []live.PushDomainInfo{}}
+	for _, name := range sortedKeys(b.push) {
+		d := b.push[name]
+		resp.Domains = append(resp.Domains, live.PushDomainInfo{
+			Enable:        d.Enable,
+			Domain:        d.Domain,
+			CNAME:         d.CNAME,
+			Type:          d.Type,
+			Auth:          d.Auth,
+			CertificateID: d.CertificateID,
+			CreationDate:  d.CreationDate,
+			LastModified:  d.LastModified,
+			HTTPSEnable:   d.HTTPSEnable,
+		})
+	}
+	return resp, nil
+}
+
+// BindPushDomain 实现 live.DomainClient
+func (f *Fake) BindPushDomain(bucketName string, req *live.BindPushDomainRequest, _ ...live.CallOption) (*live.BindPushDomainResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("BindPushDomain", bucketName, req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if req.Domain == "" || req.Type == "" {
+		return nil, BadRequestError("domain and type are required")
+	}
+	if b.bound(req.Domain) {
+		return nil, AlreadyBoundError(req.Domain)
+	}
+	now := f.timestamp()
+	b.push[req.Domain] = &live.PushDomainConfigResponse{
+		Enable:       true,
+		BucketID:     bucketName,
+		Domain:       req.Domain,
+		CNAME:        cname(req.Domain),
+		Type:         req.Type,
+		CreationDate: now,
+		LastModified: now,
+	}
+	return &live.BindPushDomainResponse{Domain: req.Domain, CNAME: cname(req.Domain), Type: req.Type, CreationDate: now, LastModified: now}, nil
+}
+
+// UnbindPushDomain 实现 live.DomainClient
+func (f *Fake) UnbindPushDomain(bucketName, domain string, _ ...live.CallOption) (*live.UnbindPushDomainResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("UnbindPushDomain", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := b.push[domain]; !ok {
+		return nil, NotFoundError("push domain %s not found", domain)
+	}
+	delete(b.push, domain)
+	delete(b.certs, domain)
+	return &live.UnbindPushDomainResponse{Message: "success"}, nil
+}
+
+// GetPushDomainConfig 实现 live.DomainConfigClient
+func (f *Fake) GetPushDomainConfig(bucketName, domain string, _ ...live.CallOption) (*live.PushDomainConfigResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("GetPushDomainConfig", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := b.push[domain]
+	if !ok {
+		return nil, NotFoundError("push domain %s not found", domain)
+	}
+	config := *d
+	return &config, nil
+}
+
+// UpdatePushDomainConfig 实现 live.DomainConfigClient，只修改请求中设置了的字段
+func (f *Fake) UpdatePushDomainConfig(bucketName, domain string, req *live.UpdatePushDomainConfigRequest, _ ...live.CallOption) (*live.PushDomainConfigResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("UpdatePushDomainConfig", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := b.push[domain]
+	if !ok {
+		return nil, NotFoundError("push domain %s not found", domain)
+	}
+	if req.Enable != nil {
+		d.Enable = *req.Enable
+	}
+	if req.Type != "" {
+		d.Type = req.Type
+	}
+	if req.Auth != nil {
+		d.Auth = req.Auth
+	}
+	if req.CertificateID != "" {
+		d.CertificateID = req.CertificateID
+	}
+	if req.IPLimit != nil {
+		d.IPLimit = req.IPLimit
+	}
+	if req.URLRewrites != nil {
+		d.URLRewrites = req.URLRewrites
+	}
+	if req.HTTPSEnable != nil {
+		d.HTTPSEnable = *req.HTTPSEnable
+	}
+	d.LastModified = f.timestamp()
+	config := *d
+	return &config, nil
+}
+
+// ListPlayDomains 实现 live.DomainClient
+func (f *Fake) ListPlayDomains(bucketName string, _ ...live.CallOption) (*live.ListPlayDomainsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("ListPlayDomains", bucketName, "")
+	if err != nil {
+		return nil, err
+	}
+	resp := &live.ListPlayDomainsResponse{Domains: []live.PlayDomainInfo{}}
+	for _, name := range sortedKeys(b.play) {
+		d := b.play[name]
+		resp.Domains = append(resp.Domains, live.PlayDomainInfo{
+			Enable:        d.Enable,
+			Domain:        d.Domain,
+			CNAME:         d.CNAME,
+			Type:          d.Type,
+			Auth:          d.Auth,
+			CertificateID: d.CertificateID,
+			CreationDate:  d.CreationDate,
+			LastModified:  d.LastModified,
+			HTTPSEnable:   d.HTTPSEnable,
+		})
+	}
+	return resp, nil
+}
+
+// BindPlayDomain 实现 live.DomainClient
+func (f *Fake) BindPlayDomain(bucketName string, req *live.BindPlayDomainRequest, _ ...live.CallOption) (*live.BindPlayDomainResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("BindPlayDomain", bucketName, req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if req.Domain == "" {
+		return nil, BadRequestError("domain is required")
+	}
+	if b.bound(req.Domain) {
+		return nil, AlreadyBoundError(req.Domain)
+	}
+	now := f.timestamp()
+	b.play[req.Domain] = &live.PlayDomainConfigResponse{
+		Enable:       true,
+		Domain:       req.Domain,
+		CNAME:        cname(req.Domain),
+		Type:         req.Type,
+		CreationDate: now,
+		LastModified: now,
+	}
+	return &live.BindPlayDomainResponse{Domain: req.Domain, CNAME: cname(req.Domain), Type: req.Type, CreationDate: now, LastModified: now}, nil
+}
+
+// UnbindPlayDomain 实现 live.DomainClient
+func (f *Fake) UnbindPlayDomain(bucketName, domain string, _ ...live.CallOption) (*live.UnbindPlayDomainResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("UnbindPlayDomain", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := b.play[domain]; !ok {
+		return nil, NotFoundError("play domain %s not found", domain)
+	}
+	delete(b.play, domain)
+	delete(b.certs, domain)
+	return &live.UnbindPlayDomainResponse{Message: "success"}, nil
+}
+
+// GetPlayDomainConfig 实现 live.DomainConfigClient
+func (f *Fake) GetPlayDomainConfig(bucketName, domain string, _ ...live.CallOption) (*live.PlayDomainConfigResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("GetPlayDomainConfig", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := b.play[domain]
+	if !ok {
+		return nil, NotFoundError("play domain %s not found", domain)
+	}
+	config := *d
+	return &config, nil
+}
+
+// UpdatePlayDomainConfig 实现 live.DomainConfigClient，只修改请求中设置了的字段
+func (f *Fake) UpdatePlayDomainConfig(bucketName, domain string, req *live.UpdatePlayDomainConfigRequest, _ ...live.CallOption) (*live.PlayDomainConfigResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("UpdatePlayDomainConfig", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := b.play[domain]
+	if !ok {
+		return nil, NotFoundError("play domain %s not found", domain)
+	}
+	if req.Type != "" {
+		d.Type = req.Type
+	}
+	if req.Auth != nil {
+		d.Auth = req.Auth
+	}
+	if req.CertificateID != "" {
+		d.CertificateID = req.CertificateID
+	}
+	if req.HTTPSEnable != nil {
+		d.HTTPSEnable = *req.HTTPSEnable
+	}
+	d.LastModified = f.timestamp()
+	config := *d
+	return &config, nil
+}
+
+// UploadCertificate 实现 live.LiveDomainAPI，证书为 PEM 格式时解析出有效期
+func (f *Fake) UploadCertificate(bucketName string, req *live.UploadCertificateRequest, _ ...live.CallOption) (*live.CertificateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("UploadCertificate", bucketName, req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if req.Domain == "" || req.CertificateID == "" || req.Cert == "" || req.PriKey == "" {
+		return nil, BadRequestError("certificateID, domain, cert and priKey are required")
+	}
+	if !b.bound(req.Domain) {
+		return nil, NotFoundError("domain %s not found", req.Domain)
+	}
+	for _, c := range b.certs[req.Domain] {
+		if c.CertificateID == req.CertificateID {
+			return nil, &live.APIError{StatusCode: statusExists, Message: fmt.Sprintf("certificate %s already exists", req.CertificateID)}
+		}
+	}
+	cert := live.CertificateInfo{CertificateID: req.CertificateID, Domain: req.Domain, Cert: req.Cert, PriKey: req.PriKey}
+	cert.NotBefore, cert.NotAfter = validity(req.Cert)
+	b.certs[req.Domain] = append(b.certs[req.Domain], cert)
+	return &live.CertificateResponse{Message: "success"}, nil
+}
+
+// DeleteCertificate 实现 live.LiveDomainAPI
+func (f *Fake) DeleteCertificate(bucketName, domain, certName string, _ ...live.CallOption) (*live.CertificateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("DeleteCertificate", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	certs := b.certs[domain]
+	for i, c := range certs {
+		if c.CertificateID == certName {
+			b.certs[domain] = append(certs[:i:i], certs[i+1:]...)
+			return &live.CertificateResponse{Message: "success"}, nil
+		}
+	}
+	return nil, NotFoundError("certificate %s not found", certName)
+}
+
+// ListCertificates 实现 live.LiveDomainAPI
+func (f *Fake) ListCertificates(bucketName, domain string, _ ...live.CallOption) ([]live.CertificateInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("ListCertificates", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	return append([]live.CertificateInfo{}, b.certs[domain]...), nil
+}
+
+// UpdateCertificate 实现 live.LiveDomainAPI
+func (f *Fake) UpdateCertificate(bucketName, domain, certName string, req *live.UpdateCertificateRequest, _ ...live.CallOption) (*live.CertificateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := f.begin("UpdateCertificate", bucketName, domain)
+	if err != nil {
+		return nil, err
+	}
+	for i := range b.certs[domain] {
+		c := &b.certs[domain][i]
+		if c.CertificateID != certName {
+			continue
+		}
+		if req.Cert != "" {
+			c.Cert = req.Cert
+			c.NotBefore, c.NotAfter = validity(req.Cert)
+		}
+		if req.PriKey != "" {
+			c.PriKey = req.PriKey
+		}
+		return &live.CertificateResponse{Message: "success"}, nil
+	}
+	return nil, NotFoundError("certificate %s not found", certName)
+}
+
+// validity PEM 证书的有效期，无法解析时为 0
+func validity(certPEM string) (notBefore, notAfter int64) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return 0, 0
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return 0, 0
+	}
+	return cert.NotBefore.Unix(), cert.NotAfter.Unix()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
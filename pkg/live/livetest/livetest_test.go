@@ -0,0 +1,97 @@
+package livetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/live"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake_ReconcileProvisionsDomains(t *testing.T) {
+	fake := NewFake()
+	enabled := true
+	desired := []live.DomainSpec{
+		{Domain: "push.example.com", Kind: live.DomainKindPush, Type: "pushRtmp"},
+		{Domain: "hls.example.com", Kind: live.DomainKindPlay, Type: "liveHls", HTTPSEnable: &enabled, CertificateID: "hls-cert"},
+	}
+
+	plan, err := live.Reconcile(fake, "bucket", desired, live.ReconcileOptions{AutoApply: true})
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 2)
+	assert.Empty(t, plan.Failed())
+
+	play, err := fake.GetPlayDomainConfig("bucket", "hls.example.com")
+	require.NoError(t, err)
+	assert.True(t, play.HTTPSEnable, "drift found after binding is fixed")
+
+	plan, err = live.Reconcile(fake, "bucket", desired, live.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.True(t, plan.InSync())
+}
+
+func TestFake_ErrorsAndInjection(t *testing.T) {
+	fake := NewFake()
+	_, err := fake.BindPlayDomain("bucket", &live.BindPlayDomainRequest{Domain: "a.example.com", Type: "liveFlv"})
+	require.NoError(t, err)
+
+	_, err = fake.BindPushDomain("bucket", &live.BindPushDomainRequest{Domain: "a.example.com", Type: "pushRtmp"})
+	assert.ErrorIs(t, err, live.ErrDomainAlreadyBound)
+	_, err = fake.UnbindPushDomain("bucket", "a.example.com")
+	assert.ErrorIs(t, err, live.ErrNotFound)
+
+	boom := errors.New("boom")
+	fake.FailNext("ListPlayDomains", boom)
+	_, err = fake.ListPlayDomains("bucket")
+	assert.Same(t, boom, err)
+	list, err := fake.ListPlayDomains("bucket")
+	require.NoError(t, err)
+	assert.Len(t, list.Domains, 1)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 5)
+	assert.Equal(t, Call{Method: "BindPushDomain", Bucket: "bucket", Domain: "a.example.com"}, calls[1])
+
+	fake.Reset()
+	list, err = fake.ListPlayDomains("bucket")
+	require.NoError(t, err)
+	assert.Empty(t, list.Domains)
+}
+
+func TestServer_BucketClient(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	client := server.Client()
+
+	_, err := client.BindPushDomain("bucket", &live.BindPushDomainRequest{Domain: "push.example.com", Type: "pushRtmp"})
+	require.NoError(t, err)
+	disabled := false
+	config, err := client.UpdatePushDomainConfig("bucket", "push.example.com", &live.UpdatePushDomainConfigRequest{Enable: &disabled})
+	require.NoError(t, err)
+	assert.False(t, config.Enable)
+
+	domains, err := client.ListPushDomains("bucket")
+	require.NoError(t, err)
+	require.Len(t, domains.Domains, 1)
+	assert.Equal(t, "push.example.com.qiniudns.com", domains.Domains[0].CNAME)
+
+	_, err = client.UploadCertificate("bucket", &live.UploadCertificateRequest{CertificateID: "c1", Domain: "push.example.com", Cert: "cert", PriKey: "key"})
+	require.NoError(t, err)
+	certs, err := client.ListCertificates("bucket", "push.example.com")
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	_, err = client.DeleteCertificate("bucket", "push.example.com", "c1")
+	require.NoError(t, err)
+
+	// Errors keep their status through HTTP
+	_, err = client.GetPlayDomainConfig("bucket", "missing.example.com")
+	assert.ErrorIs(t, err, live.ErrNotFound)
+	_, err = client.BindPlayDomain("bucket", &live.BindPlayDomainRequest{Domain: "push.example.com", Type: "liveHls"})
+	assert.ErrorIs(t, err, live.ErrDomainAlreadyBound)
+
+	// Other buckets are independent
+	other, err := client.ListPushDomains("other")
+	require.NoError(t, err)
+	assert.Empty(t, other.Domains)
+}
@@ -0,0 +1,165 @@
+package livetest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/live"
+)
+
+// Server 以 Fake 为后端模拟七牛直播的域名和证书接口，用于测试直接使用 BucketClient 的代码，
+// 请求、鉴权头和响应都经过真实的 HTTP 编解码
+type Server struct {
+	*httptest.Server
+	Fake *Fake
+}
+
+// NewServer 启动模拟服务器，fake 为空时新建一个；测试结束时调用 Close
+func NewServer(fake *Fake) *Server {
+	if fake == nil {
+		fake = NewFake()
+	}
+	s := &Server{Fake: fake}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client 返回请求发往该服务器的 BucketClient，不重试，空间子域名同样解析到该服务器
+func (s *Server) Client() *live.BucketClient {
+	addr := s.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := live.NewBucketClientWithKeys("livetest-ak", "livetest-sk")
+	client.SetHTTPClient(&http.Client{Transport: transport})
+	client.SetRetryPolicy(live.NoRetry())
+	return client
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		writeError(w, &live.APIError{StatusCode: http.StatusUnauthorized, Message: "bad token"})
+		return
+	}
+	bucket, _, _ := strings.Cut(r.Host, ".")
+	q := r.URL.Query()
+	name := q.Get("name")
+	var (
+		result interface{}
+		err    error
+	)
+	// 证书接口同样带 domain 参数，需要先于播放域名判断
+	switch {
+	case q.Has("pushDomain"):
+		switch r.Method {
+		case http.MethodGet:
+			result, err = s.Fake.ListPushDomains(bucket)
+		case http.MethodPost:
+			var req live.BindPushDomainRequest
+			if err = decode(r, &req); err == nil {
+				result, err = s.Fake.BindPushDomain(bucket, &req)
+			}
+		case http.MethodDelete:
+			result, err = s.Fake.UnbindPushDomain(bucket, name)
+		default:
+			err = errMethod
+		}
+	case q.Has("pushDomainConfig"):
+		switch r.Method {
+		case http.MethodGet:
+			result, err = s.Fake.GetPushDomainConfig(bucket, name)
+		case http.MethodPatch:
+			var req live.UpdatePushDomainConfigRequest
+			if err = decode(r, &req); err == nil {
+				result, err = s.Fake.UpdatePushDomainConfig(bucket, name, &req)
+			}
+		default:
+			err = errMethod
+		}
+	case q.Has("domainCertificate"):
+		domain, certName := q.Get("domain"), q.Get("certName")
+		switch r.Method {
+		case http.MethodGet:
+			result, err = s.Fake.ListCertificates(bucket, domain)
+		case http.MethodPost:
+			var req live.UploadCertificateRequest
+			if err = decode(r, &req); err == nil {
+				result, err = s.Fake.UploadCertificate(bucket, &req)
+			}
+		case http.MethodDelete:
+			result, err = s.Fake.DeleteCertificate(bucket, domain, certName)
+		case http.MethodPatch:
+			var req live.UpdateCertificateRequest
+			if err = decode(r, &req); err == nil {
+				result, err = s.Fake.UpdateCertificate(bucket, domain, certName, &req)
+			}
+		default:
+			err = errMethod
+		}
+	case q.Has("domain"):
+		switch r.Method {
+		case http.MethodGet:
+			result, err = s.Fake.ListPlayDomains(bucket)
+		case http.MethodPost:
+			var req live.BindPlayDomainRequest
+			if err = decode(r, &req); err == nil {
+				result, err = s.Fake.BindPlayDomain(bucket, &req)
+			}
+		case http.MethodDelete:
+			result, err = s.Fake.UnbindPlayDomain(bucket, name)
+		default:
+			err = errMethod
+		}
+	case q.Has("domainConfig"):
+		switch r.Method {
+		case http.MethodGet:
+			result, err = s.Fake.GetPlayDomainConfig(bucket, name)
+		case http.MethodPatch:
+			var req live.UpdatePlayDomainConfigRequest
+			if err = decode(r, &req); err == nil {
+				result, err = s.Fake.UpdatePlayDomainConfig(bucket, name, &req)
+			}
+		default:
+			err = errMethod
+		}
+	default:
+		err = &live.APIError{StatusCode: http.StatusNotFound, Message: "livetest: unsupported API " + r.URL.RawQuery}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+var errMethod = &live.APIError{StatusCode: http.StatusMethodNotAllowed, Message: "method not allowed"}
+
+func decode(r *http.Request, out interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		return BadRequestError("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// writeError 按七牛的格式返回错误，Fake 返回的 live.APIError 保留状态码，其余错误按 400 处理
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := &live.APIError{StatusCode: http.StatusBadRequest, Message: err.Error()}
+	errors.As(err, &apiErr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.StatusCode)
+	body := map[string]interface{}{"error": apiErr.Message}
+	if apiErr.Code != "" {
+		body["error_code"] = apiErr.Code
+	}
+	json.NewEncoder(w).Encode(body)
+}
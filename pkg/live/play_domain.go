@@ -86,7 +86,18 @@ type PlayDomainConfigResponse struct {
 }
 
 // BindPlayDomain 绑定下行域名（播放域名）
-func (c *BucketClient) BindPlayDomain(bucketName string, req *BindPlayDomainRequest) (*BindPlayDomainResponse, error) {
+func (c *BucketClient) BindPlayDomain(bucketName string, req *BindPlayDomainRequest, opts ...CallOption) (*BindPlayDomainResponse, error) {
+	change := domainChange{operation: ChangeBindPlayDomain, bucket: bucketName, request: req}
+	if req != nil {
+		change.domain = req.Domain
+	}
+	return recordDomainChange(c, change, opts, func() (*BindPlayDomainResponse, error) {
+		return c.bindPlayDomain(bucketName, req, opts...)
+	})
+}
+
+// bindPlayDomain BindPlayDomain 的实现，不记录变更
+func (c *BucketClient) bindPlayDomain(bucketName string, req *BindPlayDomainRequest, opts ...CallOption) (*BindPlayDomainResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -134,7 +145,7 @@ func (c *BucketClient) BindPlayDomain(bucketName string, req *BindPlayDomainRequ
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -148,7 +159,7 @@ func (c *BucketClient) BindPlayDomain(bucketName string, req *BindPlayDomainRequ
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -161,7 +172,17 @@ func (c *BucketClient) BindPlayDomain(bucketName string, req *BindPlayDomainRequ
 }
 
 // UnbindPlayDomain 解绑下行域名（播放域名）
-func (c *BucketClient) UnbindPlayDomain(bucketName, domain string) (*UnbindPlayDomainResponse, error) {
+func (c *BucketClient) UnbindPlayDomain(bucketName, domain string, opts ...CallOption) (*UnbindPlayDomainResponse, error) {
+	return recordDomainChange(c, domainChange{
+		operation: ChangeUnbindPlayDomain, bucket: bucketName, domain: domain, removes: true,
+		before: func() (interface{}, error) { return c.GetPlayDomainConfig(bucketName, domain, opts...) },
+	}, opts, func() (*UnbindPlayDomainResponse, error) {
+		return c.unbindPlayDomain(bucketName, domain, opts...)
+	})
+}
+
+// unbindPlayDomain UnbindPlayDomain 的实现，不记录变更
+func (c *BucketClient) unbindPlayDomain(bucketName, domain string, opts ...CallOption) (*UnbindPlayDomainResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -201,7 +222,7 @@ func (c *BucketClient) UnbindPlayDomain(bucketName, domain string) (*UnbindPlayD
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -215,7 +236,7 @@ func (c *BucketClient) UnbindPlayDomain(bucketName, domain string) (*UnbindPlayD
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -228,7 +249,7 @@ func (c *BucketClient) UnbindPlayDomain(bucketName, domain string) (*UnbindPlayD
 }
 
 // ListPlayDomains 列举下行域名（播放域名）
-func (c *BucketClient) ListPlayDomains(bucketName string) (*ListPlayDomainsResponse, error) {
+func (c *BucketClient) ListPlayDomains(bucketName string, opts ...CallOption) (*ListPlayDomainsResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -265,34 +286,33 @@ func (c *BucketClient) ListPlayDomains(bucketName string) (*ListPlayDomainsRespo
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	// 检查状态码
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
-	}
-
-	// 解析响应
+	// 域名较多时响应很大，边读边解析
 	var result ListPlayDomainsResponse
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, err
 	}
 
 	return &result, nil
 }
 
 // UpdatePlayDomainConfig 修改下行域名配置
-func (c *BucketClient) UpdatePlayDomainConfig(bucketName, domain string, req *UpdatePlayDomainConfigRequest) (*PlayDomainConfigResponse, error) {
+func (c *BucketClient) UpdatePlayDomainConfig(bucketName, domain string, req *UpdatePlayDomainConfigRequest, opts ...CallOption) (*PlayDomainConfigResponse, error) {
+	return recordDomainChange(c, domainChange{
+		operation: ChangeUpdatePlayDomainConfig, bucket: bucketName, domain: domain, request: req,
+		before: func() (interface{}, error) { return c.GetPlayDomainConfig(bucketName, domain, opts...) },
+	}, opts, func() (*PlayDomainConfigResponse, error) {
+		return c.updatePlayDomainConfig(bucketName, domain, req, opts...)
+	})
+}
+
+// updatePlayDomainConfig UpdatePlayDomainConfig 的实现，不记录变更
+func (c *BucketClient) updatePlayDomainConfig(bucketName, domain string, req *UpdatePlayDomainConfigRequest, opts ...CallOption) (*PlayDomainConfigResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -340,7 +360,7 @@ func (c *BucketClient) UpdatePlayDomainConfig(bucketName, domain string, req *Up
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -354,7 +374,7 @@ func (c *BucketClient) UpdatePlayDomainConfig(bucketName, domain string, req *Up
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -367,7 +387,7 @@ func (c *BucketClient) UpdatePlayDomainConfig(bucketName, domain string, req *Up
 }
 
 // GetPlayDomainConfig 获取下行域名配置
-func (c *BucketClient) GetPlayDomainConfig(bucketName, domain string) (*PlayDomainConfigResponse, error) {
+func (c *BucketClient) GetPlayDomainConfig(bucketName, domain string, opts ...CallOption) (*PlayDomainConfigResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -407,7 +427,7 @@ func (c *BucketClient) GetPlayDomainConfig(bucketName, domain string) (*PlayDoma
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -421,7 +441,7 @@ func (c *BucketClient) GetPlayDomainConfig(bucketName, domain string) (*PlayDoma
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -160,7 +160,7 @@ type PubTaskRunInfoResponse struct {
 }
 
 // CreatePubTask 创建 Pub 转推任务
-func (c *BucketClient) CreatePubTask(req *CreatePubTaskRequest) (*CreatePubTaskResponse, error) {
+func (c *BucketClient) CreatePubTask(req *CreatePubTaskRequest, opts ...CallOption) (*CreatePubTaskResponse, error) {
 	if req.Name == "" {
 		return nil, fmt.Errorf("task name cannot be empty")
 	}
@@ -212,7 +212,7 @@ func (c *BucketClient) CreatePubTask(req *CreatePubTaskRequest) (*CreatePubTaskR
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -226,7 +226,7 @@ func (c *BucketClient) CreatePubTask(req *CreatePubTaskRequest) (*CreatePubTaskR
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -239,7 +239,7 @@ func (c *BucketClient) CreatePubTask(req *CreatePubTaskRequest) (*CreatePubTaskR
 }
 
 // UpdatePubTask 编辑 Pub 转推任务
-func (c *BucketClient) UpdatePubTask(taskID string, req *UpdatePubTaskRequest) error {
+func (c *BucketClient) UpdatePubTask(taskID string, req *UpdatePubTaskRequest, opts ...CallOption) error {
 	if taskID == "" {
 		return fmt.Errorf("taskID cannot be empty")
 	}
@@ -291,7 +291,7 @@ func (c *BucketClient) UpdatePubTask(taskID string, req *UpdatePubTaskRequest) e
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -305,14 +305,14 @@ func (c *BucketClient) UpdatePubTask(taskID string, req *UpdatePubTaskRequest) e
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp, respBody)
 	}
 
 	return nil
 }
 
 // StartPubTask 开始 Pub 转推任务
-func (c *BucketClient) StartPubTask(taskID string) error {
+func (c *BucketClient) StartPubTask(taskID string, opts ...CallOption) error {
 	if taskID == "" {
 		return fmt.Errorf("taskID cannot be empty")
 	}
@@ -346,7 +346,7 @@ func (c *BucketClient) StartPubTask(taskID string) error {
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -360,14 +360,14 @@ func (c *BucketClient) StartPubTask(taskID string) error {
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp, respBody)
 	}
 
 	return nil
 }
 
 // StopPubTask 停止 Pub 转推任务
-func (c *BucketClient) StopPubTask(taskID string) error {
+func (c *BucketClient) StopPubTask(taskID string, opts ...CallOption) error {
 	if taskID == "" {
 		return fmt.Errorf("taskID cannot be empty")
 	}
@@ -401,7 +401,7 @@ func (c *BucketClient) StopPubTask(taskID string) error {
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -415,14 +415,14 @@ func (c *BucketClient) StopPubTask(taskID string) error {
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp, respBody)
 	}
 
 	return nil
 }
 
 // DeletePubTask 删除 Pub 转推任务
-func (c *BucketClient) DeletePubTask(taskID string) error {
+func (c *BucketClient) DeletePubTask(taskID string, opts ...CallOption) error {
 	if taskID == "" {
 		return fmt.Errorf("taskID cannot be empty")
 	}
@@ -456,7 +456,7 @@ func (c *BucketClient) DeletePubTask(taskID string) error {
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -470,14 +470,14 @@ func (c *BucketClient) DeletePubTask(taskID string) error {
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp, respBody)
 	}
 
 	return nil
 }
 
 // GetPubTask 获取 Pub 转推任务详情
-func (c *BucketClient) GetPubTask(taskID string) (*PubTaskInfo, error) {
+func (c *BucketClient) GetPubTask(taskID string, opts ...CallOption) (*PubTaskInfo, error) {
 	if taskID == "" {
 		return nil, fmt.Errorf("taskID cannot be empty")
 	}
@@ -511,7 +511,7 @@ func (c *BucketClient) GetPubTask(taskID string) (*PubTaskInfo, error) {
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -525,7 +525,7 @@ func (c *BucketClient) GetPubTask(taskID string) (*PubTaskInfo, error) {
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -538,7 +538,7 @@ func (c *BucketClient) GetPubTask(taskID string) (*PubTaskInfo, error) {
 }
 
 // ListPubTasks 列举 Pub 转推任务列表
-func (c *BucketClient) ListPubTasks(req *ListPubTasksRequest) (*ListPubTasksResponse, error) {
+func (c *BucketClient) ListPubTasks(req *ListPubTasksRequest, opts ...CallOption) (*ListPubTasksResponse, error) {
 	host := PubManagerHost
 	path := "/tasks"
 	method := "GET"
@@ -586,7 +586,7 @@ func (c *BucketClient) ListPubTasks(req *ListPubTasksRequest) (*ListPubTasksResp
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -600,7 +600,7 @@ func (c *BucketClient) ListPubTasks(req *ListPubTasksRequest) (*ListPubTasksResp
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -613,7 +613,7 @@ func (c *BucketClient) ListPubTasks(req *ListPubTasksRequest) (*ListPubTasksResp
 }
 
 // GetPubTaskRunInfo 获取 Pub 转推任务运行日志
-func (c *BucketClient) GetPubTaskRunInfo(taskID string) (*PubTaskRunInfoResponse, error) {
+func (c *BucketClient) GetPubTaskRunInfo(taskID string, opts ...CallOption) (*PubTaskRunInfoResponse, error) {
 	if taskID == "" {
 		return nil, fmt.Errorf("taskID cannot be empty")
 	}
@@ -647,7 +647,7 @@ func (c *BucketClient) GetPubTaskRunInfo(taskID string) (*PubTaskRunInfoResponse
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -661,7 +661,7 @@ func (c *BucketClient) GetPubTaskRunInfo(taskID string) (*PubTaskRunInfoResponse
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -674,7 +674,7 @@ func (c *BucketClient) GetPubTaskRunInfo(taskID string) (*PubTaskRunInfoResponse
 }
 
 // ListPubTaskHistory 查询 Pub 转推任务历史记录
-func (c *BucketClient) ListPubTaskHistory(req *ListPubTaskHistoryRequest) (*ListPubTaskHistoryResponse, error) {
+func (c *BucketClient) ListPubTaskHistory(req *ListPubTaskHistoryRequest, opts ...CallOption) (*ListPubTaskHistoryResponse, error) {
 	host := PubManagerHost
 	path := "/history"
 	method := "GET"
@@ -728,7 +728,7 @@ func (c *BucketClient) ListPubTaskHistory(req *ListPubTaskHistoryRequest) (*List
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -742,7 +742,7 @@ func (c *BucketClient) ListPubTaskHistory(req *ListPubTaskHistoryRequest) (*List
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
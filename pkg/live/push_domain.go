@@ -98,11 +98,23 @@ type PushDomainConfigResponse struct {
 	CreationDate  string                `json:"creationDate"`
 	LastModified  string                `json:"lastModified"`
 	IPLimit       *IPLimitConfig        `json:"ipLimit"`
+	URLRewrites   []URLRewriteRule      `json:"urlRewrites,omitempty"`
 	HTTPSEnable   bool                  `json:"httpsEnable"`
 }
 
 // BindPushDomain 绑定上行域名（推流域名）
-func (c *BucketClient) BindPushDomain(bucketName string, req *BindPushDomainRequest) (*BindPushDomainResponse, error) {
+func (c *BucketClient) BindPushDomain(bucketName string, req *BindPushDomainRequest, opts ...CallOption) (*BindPushDomainResponse, error) {
+	change := domainChange{operation: ChangeBindPushDomain, bucket: bucketName, request: req}
+	if req != nil {
+		change.domain = req.Domain
+	}
+	return recordDomainChange(c, change, opts, func() (*BindPushDomainResponse, error) {
+		return c.bindPushDomain(bucketName, req, opts...)
+	})
+}
+
+// bindPushDomain BindPushDomain 的实现，不记录变更
+func (c *BucketClient) bindPushDomain(bucketName string, req *BindPushDomainRequest, opts ...CallOption) (*BindPushDomainResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -153,7 +165,7 @@ func (c *BucketClient) BindPushDomain(bucketName string, req *BindPushDomainRequ
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -167,7 +179,7 @@ func (c *BucketClient) BindPushDomain(bucketName string, req *BindPushDomainRequ
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -180,7 +192,17 @@ func (c *BucketClient) BindPushDomain(bucketName string, req *BindPushDomainRequ
 }
 
 // UnbindPushDomain 解绑上行域名（推流域名）
-func (c *BucketClient) UnbindPushDomain(bucketName, domain string) (*UnbindPushDomainResponse, error) {
+func (c *BucketClient) UnbindPushDomain(bucketName, domain string, opts ...CallOption) (*UnbindPushDomainResponse, error) {
+	return recordDomainChange(c, domainChange{
+		operation: ChangeUnbindPushDomain, bucket: bucketName, domain: domain, removes: true,
+		before: func() (interface{}, error) { return c.GetPushDomainConfig(bucketName, domain, opts...) },
+	}, opts, func() (*UnbindPushDomainResponse, error) {
+		return c.unbindPushDomain(bucketName, domain, opts...)
+	})
+}
+
+// unbindPushDomain UnbindPushDomain 的实现，不记录变更
+func (c *BucketClient) unbindPushDomain(bucketName, domain string, opts ...CallOption) (*UnbindPushDomainResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -220,7 +242,7 @@ func (c *BucketClient) UnbindPushDomain(bucketName, domain string) (*UnbindPushD
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -234,7 +256,7 @@ func (c *BucketClient) UnbindPushDomain(bucketName, domain string) (*UnbindPushD
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -247,7 +269,7 @@ func (c *BucketClient) UnbindPushDomain(bucketName, domain string) (*UnbindPushD
 }
 
 // ListPushDomains 列举上行域名（推流域名）
-func (c *BucketClient) ListPushDomains(bucketName string) (*ListPushDomainsResponse, error) {
+func (c *BucketClient) ListPushDomains(bucketName string, opts ...CallOption) (*ListPushDomainsResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -284,34 +306,33 @@ func (c *BucketClient) ListPushDomains(bucketName string) (*ListPushDomainsRespo
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	// 检查状态码
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
-	}
-
-	// 解析响应
+	// 域名较多时响应很大，边读边解析
 	var result ListPushDomainsResponse
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, err
 	}
 
 	return &result, nil
 }
 
 // UpdatePushDomainConfig 修改上行域名配置
-func (c *BucketClient) UpdatePushDomainConfig(bucketName, domain string, req *UpdatePushDomainConfigRequest) (*PushDomainConfigResponse, error) {
+func (c *BucketClient) UpdatePushDomainConfig(bucketName, domain string, req *UpdatePushDomainConfigRequest, opts ...CallOption) (*PushDomainConfigResponse, error) {
+	return recordDomainChange(c, domainChange{
+		operation: ChangeUpdatePushDomainConfig, bucket: bucketName, domain: domain, request: req,
+		before: func() (interface{}, error) { return c.GetPushDomainConfig(bucketName, domain, opts...) },
+	}, opts, func() (*PushDomainConfigResponse, error) {
+		return c.updatePushDomainConfig(bucketName, domain, req, opts...)
+	})
+}
+
+// updatePushDomainConfig UpdatePushDomainConfig 的实现，不记录变更
+func (c *BucketClient) updatePushDomainConfig(bucketName, domain string, req *UpdatePushDomainConfigRequest, opts ...CallOption) (*PushDomainConfigResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -359,7 +380,7 @@ func (c *BucketClient) UpdatePushDomainConfig(bucketName, domain string, req *Up
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -373,7 +394,7 @@ func (c *BucketClient) UpdatePushDomainConfig(bucketName, domain string, req *Up
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -386,7 +407,7 @@ func (c *BucketClient) UpdatePushDomainConfig(bucketName, domain string, req *Up
 }
 
 // GetPushDomainConfig 获取上行域名配置
-func (c *BucketClient) GetPushDomainConfig(bucketName, domain string) (*PushDomainConfigResponse, error) {
+func (c *BucketClient) GetPushDomainConfig(bucketName, domain string, opts ...CallOption) (*PushDomainConfigResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -426,7 +447,7 @@ func (c *BucketClient) GetPushDomainConfig(bucketName, domain string) (*PushDoma
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -440,7 +461,7 @@ func (c *BucketClient) GetPushDomainConfig(bucketName, domain string) (*PushDoma
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -0,0 +1,481 @@
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/qiniu/auth"
+)
+
+// 录制文件格式
+const (
+	RecordFormatM3U8 = "m3u8"
+	RecordFormatMP4  = "mp4"
+	RecordFormatFLV  = "flv"
+)
+
+const (
+	// MaxRecordSegmentDuration m3u8 切片时长上限（秒）
+	MaxRecordSegmentDuration = 600
+	// MaxRecordFileDuration mp4/flv 单个文件时长上限（秒）
+	MaxRecordFileDuration = 4 * 3600
+)
+
+// RecordStreamRule 按流名匹配的录制规则，Pattern 支持 * 和 ? 通配符
+type RecordStreamRule struct {
+	Pattern string `json:"pattern"`
+	Exclude bool   `json:"exclude,omitempty"` // 匹配的流不录制
+}
+
+// RecordTemplate 录制模板，空间内的流按模板录制到存储空间
+type RecordTemplate struct {
+	Name            string             `json:"name"`
+	Enable          bool               `json:"enable"`
+	ObjectBucket    string             `json:"objectBucket"`              // 录制文件存储空间
+	ObjectZone      string             `json:"objectZone,omitempty"`      // 存储空间所在区域
+	Formats         []string           `json:"formats"`                   // m3u8, mp4, flv
+	SegmentDuration int                `json:"segmentDuration,omitempty"` // m3u8 切片时长（秒）
+	FileDuration    int                `json:"fileDuration,omitempty"`    // mp4/flv 单个文件时长（秒），超过后切分为新文件
+	Filename        string             `json:"filename,omitempty"`        // 文件名模板，如 ${stream}/${startTime}
+	ExpireDays      int                `json:"expireDays,omitempty"`      // 录制文件保存天数，0 为永久
+	PlaybackDomain  string             `json:"playbackDomain,omitempty"`  // 回放域名
+	StreamRules     []RecordStreamRule `json:"streamRules,omitempty"`     // 为空时录制所有流
+	CreatedAt       int64              `json:"createdAt,omitempty"`
+	UpdatedAt       int64              `json:"updatedAt,omitempty"`
+}
+
+// UpdateRecordTemplateRequest 修改录制模板请求，为空的字段不修改
+type UpdateRecordTemplateRequest struct {
+	Enable          *bool              `json:"enable,omitempty"`
+	ObjectBucket    string             `json:"objectBucket,omitempty"`
+	ObjectZone      string             `json:"objectZone,omitempty"`
+	Formats         []string           `json:"formats,omitempty"`
+	SegmentDuration int                `json:"segmentDuration,omitempty"`
+	FileDuration    int                `json:"fileDuration,omitempty"`
+	Filename        string             `json:"filename,omitempty"`
+	ExpireDays      *int               `json:"expireDays,omitempty"`
+	PlaybackDomain  string             `json:"playbackDomain,omitempty"`
+	StreamRules     []RecordStreamRule `json:"streamRules,omitempty"`
+}
+
+// ListRecordTemplatesResponse 列举录制模板响应
+type ListRecordTemplatesResponse struct {
+	Templates []RecordTemplate `json:"templates"`
+}
+
+// Validate 检查录制模板
+func (t *RecordTemplate) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	if t.ObjectBucket == "" {
+		return fmt.Errorf("objectBucket cannot be empty")
+	}
+	if len(t.Formats) == 0 {
+		return fmt.Errorf("formats cannot be empty")
+	}
+	return validateRecordSettings(t.Formats, t.SegmentDuration, t.FileDuration, t.StreamRules)
+}
+
+// Validate 检查修改的字段
+func (r *UpdateRecordTemplateRequest) Validate() error {
+	if r.ExpireDays != nil && *r.ExpireDays < 0 {
+		return fmt.Errorf("expireDays cannot be negative")
+	}
+	return validateRecordSettings(r.Formats, r.SegmentDuration, r.FileDuration, r.StreamRules)
+}
+
+func validateRecordSettings(formats []string, segmentDuration, fileDuration int, rules []RecordStreamRule) error {
+	for _, f := range formats {
+		if f != RecordFormatM3U8 && f != RecordFormatMP4 && f != RecordFormatFLV {
+			return fmt.Errorf("unsupported record format: %s", f)
+		}
+	}
+	if segmentDuration < 0 || segmentDuration > MaxRecordSegmentDuration {
+		return fmt.Errorf("segmentDuration must be between 1 and %d seconds", MaxRecordSegmentDuration)
+	}
+	if fileDuration < 0 || fileDuration > MaxRecordFileDuration {
+		return fmt.Errorf("fileDuration must be between 1 and %d seconds", MaxRecordFileDuration)
+	}
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("stream rule pattern cannot be empty")
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid stream rule pattern %q: %w", rule.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// RecordsStream 模板是否录制该流：按顺序使用第一条匹配的规则，没有规则时录制所有流，
+// 有规则但都不匹配时不录制
+func (t *RecordTemplate) RecordsStream(streamKey string) bool {
+	if !t.Enable {
+		return false
+	}
+	if len(t.StreamRules) == 0 {
+		return true
+	}
+	for _, rule := range t.StreamRules {
+		if ok, _ := path.Match(rule.Pattern, streamKey); ok {
+			return !rule.Exclude
+		}
+	}
+	return false
+}
+
+// CreateRecordTemplate 创建录制模板
+// bucketName: 直播空间名称
+func (c *BucketClient) CreateRecordTemplate(bucketName string, req *RecordTemplate, opts ...CallOption) (*RecordTemplate, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "POST"
+	rawQuery := "recordTemplate"
+
+	// 构建请求 body
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:      method,
+		Path:        path,
+		RawQuery:    rawQuery,
+		Host:        host,
+		ContentType: "application/json",
+		Body:        bodyBytes,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result RecordTemplate
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// GetRecordTemplate 查询录制模板
+// bucketName: 直播空间名称
+// name: 模板名称
+func (c *BucketClient) GetRecordTemplate(bucketName, name string, opts ...CallOption) (*RecordTemplate, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("template name cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "GET"
+	rawQuery := fmt.Sprintf("recordTemplate&name=%s", url.QueryEscape(name))
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result RecordTemplate
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// ListRecordTemplates 列举空间的录制模板
+// bucketName: 直播空间名称
+func (c *BucketClient) ListRecordTemplates(bucketName string, opts ...CallOption) (*ListRecordTemplatesResponse, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "GET"
+	rawQuery := "recordTemplates"
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result ListRecordTemplatesResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// UpdateRecordTemplate 修改录制模板
+// bucketName: 直播空间名称
+// name: 模板名称
+func (c *BucketClient) UpdateRecordTemplate(bucketName, name string, req *UpdateRecordTemplateRequest, opts ...CallOption) (*RecordTemplate, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("template name cannot be empty")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "PATCH"
+	rawQuery := fmt.Sprintf("recordTemplate&name=%s", url.QueryEscape(name))
+
+	// 构建请求 body
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:      method,
+		Path:        path,
+		RawQuery:    rawQuery,
+		Host:        host,
+		ContentType: "application/json",
+		Body:        bodyBytes,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result RecordTemplate
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// DeleteRecordTemplate 删除录制模板，已录制的文件不受影响
+// bucketName: 直播空间名称
+// name: 模板名称
+func (c *BucketClient) DeleteRecordTemplate(bucketName, name string, opts ...CallOption) error {
+	if bucketName == "" {
+		return fmt.Errorf("bucket name cannot be empty")
+	}
+	if name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "DELETE"
+	rawQuery := fmt.Sprintf("recordTemplate&name=%s", url.QueryEscape(name))
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp, respBody)
+	}
+
+	return nil
+}
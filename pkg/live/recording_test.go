@@ -0,0 +1,138 @@
+package live
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordTemplate_Validate(t *testing.T) {
+	valid := RecordTemplate{Name: "default", ObjectBucket: "records", Formats: []string{RecordFormatM3U8, RecordFormatMP4}, SegmentDuration: 10}
+	require.NoError(t, valid.Validate())
+
+	cases := map[string]func(r *RecordTemplate){
+		"name":       func(r *RecordTemplate) { r.Name = "" },
+		"bucket":     func(r *RecordTemplate) { r.ObjectBucket = "" },
+		"formats":    func(r *RecordTemplate) { r.Formats = nil },
+		"format":     func(r *RecordTemplate) { r.Formats = []string{"avi"} },
+		"segment":    func(r *RecordTemplate) { r.SegmentDuration = MaxRecordSegmentDuration + 1 },
+		"file":       func(r *RecordTemplate) { r.FileDuration = -1 },
+		"pattern":    func(r *RecordTemplate) { r.StreamRules = []RecordStreamRule{{Pattern: "["}} },
+		"empty rule": func(r *RecordTemplate) { r.StreamRules = []RecordStreamRule{{}} },
+	}
+	for name, mutate := range cases {
+		tpl := valid
+		mutate(&tpl)
+		assert.Error(t, tpl.Validate(), name)
+	}
+
+	expire := -1
+	assert.Error(t, (&UpdateRecordTemplateRequest{ExpireDays: &expire}).Validate())
+	assert.NoError(t, (&UpdateRecordTemplateRequest{}).Validate())
+}
+
+func TestRecordTemplate_RecordsStream(t *testing.T) {
+	tpl := RecordTemplate{Enable: true}
+	assert.True(t, tpl.RecordsStream("any"), "no rules records every stream")
+
+	tpl.StreamRules = []RecordStreamRule{
+		{Pattern: "test-*", Exclude: true},
+		{Pattern: "room-*"},
+	}
+	assert.True(t, tpl.RecordsStream("room-1"))
+	assert.False(t, tpl.RecordsStream("test-room"))
+	assert.False(t, tpl.RecordsStream("other"), "unmatched streams are not recorded")
+
+	tpl.Enable = false
+	assert.False(t, tpl.RecordsStream("room-1"))
+}
+
+func TestBucketClient_RecordTemplates(t *testing.T) {
+	var requests []string
+	stored := map[string]RecordTemplate{}
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "live.mls.test", r.Host)
+		requests = append(requests, r.Method+" "+r.URL.RawQuery)
+		name := r.URL.Query().Get("name")
+		switch r.Method {
+		case http.MethodPost:
+			var tpl RecordTemplate
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&tpl))
+			tpl.CreatedAt = 1
+			stored[tpl.Name] = tpl
+			json.NewEncoder(w).Encode(tpl)
+		case http.MethodPatch:
+			var req UpdateRecordTemplateRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			tpl := stored[name]
+			if req.Enable != nil {
+				tpl.Enable = *req.Enable
+			}
+			if req.SegmentDuration != 0 {
+				tpl.SegmentDuration = req.SegmentDuration
+			}
+			stored[name] = tpl
+			json.NewEncoder(w).Encode(tpl)
+		case http.MethodDelete:
+			delete(stored, name)
+		default:
+			if _, ok := r.URL.Query()["recordTemplates"]; ok {
+				resp := ListRecordTemplatesResponse{}
+				for _, tpl := range stored {
+					resp.Templates = append(resp.Templates, tpl)
+				}
+				json.NewEncoder(w).Encode(resp)
+				return
+			}
+			tpl, ok := stored[name]
+			if !ok {
+				w.WriteHeader(qiniuStatusNotFound)
+				w.Write([]byte(`{"error":"template not found"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(tpl)
+		}
+	})
+
+	created, err := c.CreateRecordTemplate("live", &RecordTemplate{
+		Name: "hls", Enable: true, ObjectBucket: "records", Formats: []string{RecordFormatM3U8}, SegmentDuration: 6,
+		StreamRules: []RecordStreamRule{{Pattern: "room-*"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.CreatedAt)
+
+	disabled := false
+	updated, err := c.UpdateRecordTemplate("live", "hls", &UpdateRecordTemplateRequest{Enable: &disabled, SegmentDuration: 10})
+	require.NoError(t, err)
+	assert.False(t, updated.Enable)
+	assert.Equal(t, 10, updated.SegmentDuration)
+
+	got, err := c.GetRecordTemplate("live", "hls")
+	require.NoError(t, err)
+	assert.Equal(t, "room-*", got.StreamRules[0].Pattern)
+
+	list, err := c.ListRecordTemplates("live")
+	require.NoError(t, err)
+	assert.Len(t, list.Templates, 1)
+
+	require.NoError(t, c.DeleteRecordTemplate("live", "hls"))
+	_, err = c.GetRecordTemplate("live", "hls")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Equal(t, []string{
+		"POST recordTemplate",
+		"PATCH recordTemplate&name=hls",
+		"GET recordTemplate&name=hls",
+		"GET recordTemplates",
+		"DELETE recordTemplate&name=hls",
+		"GET recordTemplate&name=hls",
+	}, requests)
+
+	_, err = c.CreateRecordTemplate("live", &RecordTemplate{Name: "bad"})
+	assert.Error(t, err)
+	assert.Error(t, c.DeleteRecordTemplate("live", ""))
+	assert.Len(t, requests, 6, "invalid requests are not sent")
+}
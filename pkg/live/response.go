@@ -0,0 +1,109 @@
+package live
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxResponseSize 默认的响应体大小上限（解压后），超过时返回 ErrResponseTooLarge
+const DefaultMaxResponseSize int64 = 32 << 20
+
+// maxErrorBodySize 错误响应只读取前面一段用于解析错误信息
+const maxErrorBodySize = 64 << 10
+
+// ErrResponseTooLarge 响应体超过大小上限
+var ErrResponseTooLarge = errors.New("live: response body too large")
+
+// SetMaxResponseSize 设置响应体大小上限（解压后的字节数），<=0 时使用 DefaultMaxResponseSize
+func (c *BucketClient) SetMaxResponseSize(size int64) {
+	c.maxResponseSize = size
+}
+
+func (c *BucketClient) responseLimit() int64 {
+	if c.maxResponseSize <= 0 {
+		return DefaultMaxResponseSize
+	}
+	return c.maxResponseSize
+}
+
+// prepareResponse 按 Content-Encoding 透明解压响应体，并限制读取的字节数
+// 请求时显式声明 Accept-Encoding 后 net/http 不再自动解压，由这里统一处理，大小上限按解压后的内容计算
+func (c *BucketClient) prepareResponse(resp *http.Response) error {
+	body := resp.Body
+	if strings.EqualFold(strings.TrimSpace(resp.Header.Get("Content-Encoding")), "gzip") {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			body.Close()
+			return fmt.Errorf("解压响应失败: %w", err)
+		}
+		body = &gzipBody{Reader: zr, raw: body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	resp.Body = &limitedBody{r: body, remaining: c.responseLimit()}
+	return nil
+}
+
+// gzipBody 关闭时同时关闭原始响应体
+type gzipBody struct {
+	*gzip.Reader
+	raw io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.raw.Close()
+}
+
+// limitedBody 读取超过上限时返回 ErrResponseTooLarge，而不是截断后当作完整内容
+type limitedBody struct {
+	r         io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// 恰好读到上限时确认后面是否还有内容
+		var probe [1]byte
+		n, err := b.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.r.Close()
+}
+
+// decodeJSON 检查状态码并以流式方式解析 JSON 响应，不把整个响应体读入内存
+func decodeJSON(resp *http.Response, out interface{}) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		if err != nil {
+			return fmt.Errorf("读取响应失败: %w", err)
+		}
+		return newAPIError(resp, respBody)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		if errors.Is(err, ErrResponseTooLarge) {
+			return err
+		}
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package live
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketClient_GzipResponses(t *testing.T) {
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		resp := ListPushDomainsResponse{ConnectID: "c1"}
+		for i := 0; i < 2000; i++ {
+			resp.Domains = append(resp.Domains, PushDomainInfo{Domain: fmt.Sprintf("push%d.example.com", i)})
+		}
+		json.NewEncoder(zw).Encode(resp)
+	})
+
+	domains, err := c.ListPushDomains("bucket")
+	require.NoError(t, err)
+	assert.Equal(t, "c1", domains.ConnectID)
+	assert.Len(t, domains.Domains, 2000)
+
+	// Endpoints still reading the whole body see the decompressed content too
+	buckets, err := c.ListBuckets()
+	require.NoError(t, err)
+	assert.Equal(t, "c1", buckets.ConnectID)
+}
+
+func TestBucketClient_MaxResponseSize(t *testing.T) {
+	body := `{"connectId":"c1","domains":[{"domain":"` + strings.Repeat("a", 1000) + `.example.com"}]}`
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		zw.Write([]byte(body))
+	})
+
+	// The limit applies to the decompressed size
+	c.SetMaxResponseSize(int64(len(body)))
+	_, err := c.ListPlayDomains("bucket")
+	require.NoError(t, err)
+
+	c.SetMaxResponseSize(512)
+	_, err = c.ListPlayDomains("bucket")
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+	_, err = c.ListBuckets()
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestBucketClient_StreamedErrorResponse(t *testing.T) {
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(requestIDHeader, "req-1")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"bucket not found"}`))
+	})
+
+	_, err := c.ListPushDomains("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "req-1", apiErr.RequestID)
+	assert.Equal(t, "bucket not found", apiErr.Message)
+}
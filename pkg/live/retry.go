@@ -0,0 +1,182 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 请求重试策略，网络错误和 RetryOnStatus 中的状态码会按指数退避重试
+type RetryPolicy struct {
+	MaxAttempts    int           // 最多尝试次数（含首次请求），<=1 表示不重试
+	InitialBackoff time.Duration // 第一次重试前的等待时间
+	MaxBackoff     time.Duration // 单次等待时间上限
+	Multiplier     float64       // 每次重试等待时间的倍数，<=1 时按 2 处理
+	Jitter         float64       // 随机抖动比例（0~1），实际等待时间在 d*(1-Jitter) 到 d*(1+Jitter) 之间
+	RetryOnStatus  []int         // 需要重试的 HTTP 状态码
+}
+
+// DefaultRetryPolicy 默认重试策略：最多 3 次，200ms 起指数退避，重试 429 和 5xx 网关类错误
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryOnStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// NoRetry 不重试
+func NoRetry() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// retryStatus 状态码是否需要重试
+func (p RetryPolicy) retryStatus(code int) bool {
+	for _, status := range p.RetryOnStatus {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 第 retry 次重试（从 1 开始）前的等待时间
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		wait *= 1 - jitter + 2*jitter*rand.Float64()
+	}
+	return time.Duration(wait)
+}
+
+// CallOption 单次调用选项，覆盖客户端的默认设置
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	retry RetryPolicy
+	actor string
+}
+
+// WithRetryPolicy 本次调用使用指定的重试策略
+func WithRetryPolicy(policy RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retry = policy
+	}
+}
+
+// WithMaxAttempts 本次调用的最多尝试次数，其余沿用客户端的重试策略
+func WithMaxAttempts(attempts int) CallOption {
+	return func(o *callOptions) {
+		o.retry.MaxAttempts = attempts
+	}
+}
+
+// WithoutRetry 本次调用不重试，适用于不能重复提交的操作
+func WithoutRetry() CallOption {
+	return WithRetryPolicy(NoRetry())
+}
+
+// SetRetryPolicy 设置客户端默认的重试策略
+func (c *BucketClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// do 发送请求，网络错误和可重试的状态码按重试策略重试
+// 请求体必须可以重放（http.NewRequest 传入 bytes.Reader 时自动支持），否则不重试
+// 返回的响应体已解压并限制了大小，见 prepareResponse
+func (c *BucketClient) do(req *http.Request, opts ...CallOption) (*http.Response, error) {
+	options := callOptions{retry: c.retry}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	policy := options.retry
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.httpClient.Do(req)
+		if attempt >= policy.MaxAttempts || !replayable || !shouldRetry(req, resp, err, policy) {
+			if err != nil {
+				return resp, err
+			}
+			if err := c.prepareResponse(resp); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		wait := policy.backoff(attempt)
+		if resp != nil {
+			if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > wait && (policy.MaxBackoff <= 0 || retryAfter <= policy.MaxBackoff) {
+				wait = retryAfter
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// shouldRetry 请求结果是否需要重试，调用方取消请求时不重试
+func shouldRetry(req *http.Request, resp *http.Response, err error, policy RetryPolicy) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return policy.retryStatus(resp.StatusCode)
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,228 @@
+package live
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 推流和播放协议
+const (
+	ProtocolRTMP = "rtmp"
+	ProtocolSRT  = "srt"
+	ProtocolHLS  = "hls"
+	ProtocolFLV  = "flv"
+	ProtocolWHIP = "whip" // WebRTC 推流
+	ProtocolWHEP = "whep" // WebRTC 播放
+)
+
+// 防盗链类型
+const (
+	// AuthTypeA 时间戳防盗链：sign = md5(key + path + t)，t 为十六进制的过期时间戳
+	AuthTypeA = "typeA"
+	// AuthTypeExpirySK 过期时间 + HMAC 签名：token = urlsafe_base64(hmac_sha1(key, path?e=过期时间戳))
+	AuthTypeExpirySK = "expiry_sk"
+)
+
+const (
+	// DefaultSignExpireSeconds 防盗链未配置过期时间时签名的有效期
+	DefaultSignExpireSeconds = 3600
+	// DefaultSRTPort SRT 协议默认端口
+	DefaultSRTPort = 1935
+)
+
+var (
+	ErrUnsupportedAuthType = errors.New("live: unsupported anti-leech auth type")
+	ErrUnsupportedProtocol = errors.New("live: protocol not supported for this domain kind")
+)
+
+// URLSigner 按域名的防盗链配置生成带时效签名的推流和播放地址
+// 防盗链未启用时生成不带签名的地址
+type URLSigner struct {
+	Domain        string
+	Kind          string // DomainKindPush, DomainKindPlay
+	HTTPS         bool   // HLS/FLV/WHIP/WHEP 是否使用 https
+	AuthType      string
+	AuthEnable    bool
+	PrimaryKey    string
+	SecondaryKey  string
+	ExpireSeconds int
+	SRTPort       int // 为 0 时使用 DefaultSRTPort
+
+	// UseSecondaryKey 使用从密钥签名，轮换主密钥期间使用
+	UseSecondaryKey bool
+
+	now func() time.Time
+}
+
+// NewPushURLSigner 根据上行域名信息创建签名器
+func NewPushURLSigner(info *PushDomainInfo) *URLSigner {
+	s := &URLSigner{Domain: info.Domain, Kind: DomainKindPush, HTTPS: info.HTTPSEnable}
+	if a := info.Auth; a != nil {
+		s.AuthType, s.AuthEnable, s.PrimaryKey, s.SecondaryKey, s.ExpireSeconds = a.Type, a.Enable, a.PrimaryKey, a.SecondaryKey, a.ExpireSeconds
+	}
+	return s
+}
+
+// NewPlayURLSigner 根据下行域名信息创建签名器
+func NewPlayURLSigner(info *PlayDomainInfo) *URLSigner {
+	s := &URLSigner{Domain: info.Domain, Kind: DomainKindPlay, HTTPS: info.HTTPSEnable}
+	if a := info.Auth; a != nil {
+		s.AuthType, s.AuthEnable, s.PrimaryKey, s.SecondaryKey, s.ExpireSeconds = a.Type, a.Enable, a.PrimaryKey, a.SecondaryKey, a.ExpireSeconds
+	}
+	return s
+}
+
+// URL 生成流的签名地址
+// protocol: 上行域名支持 rtmp、srt、whip，下行域名支持 rtmp、hls、flv、whep
+// bucketName: 空间名称
+// streamKey: 流名称
+func (s *URLSigner) URL(protocol, bucketName, streamKey string) (string, error) {
+	if s.Domain == "" {
+		return "", fmt.Errorf("domain cannot be empty")
+	}
+	if bucketName == "" || streamKey == "" {
+		return "", fmt.Errorf("bucket name and stream key cannot be empty")
+	}
+	if !s.supports(protocol) {
+		return "", fmt.Errorf("%w: %s on %s domain", ErrUnsupportedProtocol, protocol, s.Kind)
+	}
+
+	path := "/" + url.PathEscape(bucketName) + "/" + url.PathEscape(streamKey)
+	switch protocol {
+	case ProtocolHLS:
+		path += ".m3u8"
+	case ProtocolFLV:
+		path += ".flv"
+	case ProtocolWHIP:
+		path += ".whip"
+	case ProtocolWHEP:
+		path += ".whep"
+	}
+	query, err := s.sign(path)
+	if err != nil {
+		return "", err
+	}
+
+	switch protocol {
+	case ProtocolRTMP:
+		return "rtmp://" + s.Domain + withQuery(path, query), nil
+	case ProtocolSRT:
+		port := s.SRTPort
+		if port == 0 {
+			port = DefaultSRTPort
+		}
+		mode := "publish"
+		if s.Kind == DomainKindPlay {
+			mode = "request"
+		}
+		streamID := "#!::h=" + s.Domain + ",r=" + withQuery(path, query) + ",m=" + mode
+		return fmt.Sprintf("srt://%s:%d?streamid=%s", s.Domain, port, url.QueryEscape(streamID)), nil
+	default:
+		scheme := "http"
+		if s.HTTPS || protocol == ProtocolWHIP || protocol == ProtocolWHEP {
+			scheme = "https"
+		}
+		return scheme + "://" + s.Domain + withQuery(path, query), nil
+	}
+}
+
+// ExpiresAt 现在生成的签名的过期时间
+func (s *URLSigner) ExpiresAt() time.Time {
+	expire := s.ExpireSeconds
+	if expire <= 0 {
+		expire = DefaultSignExpireSeconds
+	}
+	return s.clock().Add(time.Duration(expire) * time.Second)
+}
+
+func (s *URLSigner) supports(protocol string) bool {
+	switch s.Kind {
+	case DomainKindPush:
+		return protocol == ProtocolRTMP || protocol == ProtocolSRT || protocol == ProtocolWHIP
+	case DomainKindPlay:
+		return protocol == ProtocolRTMP || protocol == ProtocolSRT || protocol == ProtocolHLS ||
+			protocol == ProtocolFLV || protocol == ProtocolWHEP
+	}
+	return false
+}
+
+// sign 计算路径的签名参数，防盗链未启用时返回空
+func (s *URLSigner) sign(path string) (url.Values, error) {
+	if !s.AuthEnable {
+		return nil, nil
+	}
+	key := s.PrimaryKey
+	if s.UseSecondaryKey {
+		key = s.SecondaryKey
+	}
+	if key == "" {
+		return nil, fmt.Errorf("anti-leech key of domain %s is not configured", s.Domain)
+	}
+	expire := s.ExpiresAt().Unix()
+
+	switch s.AuthType {
+	case AuthTypeA:
+		t := strconv.FormatInt(expire, 16)
+		sum := md5.Sum([]byte(key + path + t))
+		return url.Values{"sign": {hex.EncodeToString(sum[:])}, "t": {t}}, nil
+	case AuthTypeExpirySK:
+		e := strconv.FormatInt(expire, 10)
+		mac := hmac.New(sha1.New, []byte(key))
+		mac.Write([]byte(path + "?e=" + e))
+		return url.Values{"e": {e}, "token": {base64.URLEncoding.EncodeToString(mac.Sum(nil))}}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedAuthType, s.AuthType)
+}
+
+func (s *URLSigner) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// VerifySignedPath 校验签名地址的路径和参数是否由 key 签发且未过期，用于自建回源或测试
+func VerifySignedPath(authType, key, path string, query url.Values, now time.Time) bool {
+	var expire int64
+	var err error
+	switch authType {
+	case AuthTypeA:
+		expire, err = strconv.ParseInt(query.Get("t"), 16, 64)
+		if err != nil {
+			return false
+		}
+		sum := md5.Sum([]byte(key + path + query.Get("t")))
+		if !hmac.Equal([]byte(strings.ToLower(query.Get("sign"))), []byte(hex.EncodeToString(sum[:]))) {
+			return false
+		}
+	case AuthTypeExpirySK:
+		expire, err = strconv.ParseInt(query.Get("e"), 10, 64)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, []byte(key))
+		mac.Write([]byte(path + "?e=" + query.Get("e")))
+		if !hmac.Equal([]byte(query.Get("token")), []byte(base64.URLEncoding.EncodeToString(mac.Sum(nil)))) {
+			return false
+		}
+	default:
+		return false
+	}
+	return now.Unix() <= expire
+}
@@ -0,0 +1,95 @@
+package live
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLSigner_TypeA(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	s := NewPlayURLSigner(&PlayDomainInfo{
+		Domain:      "play.example.com",
+		HTTPSEnable: true,
+		Auth:        &PlayDomainAuthConfig{Type: AuthTypeA, Enable: true, PrimaryKey: "primary", SecondaryKey: "secondary", ExpireSeconds: 600},
+	})
+	s.now = func() time.Time { return now }
+
+	raw, err := s.URL(ProtocolHLS, "live", "room1")
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "https", u.Scheme)
+	assert.Equal(t, "/live/room1.m3u8", u.Path)
+
+	expire := now.Add(10 * time.Minute).Unix()
+	assert.Equal(t, "6955bb58", u.Query().Get("t"))
+	sum := md5.Sum([]byte("primary/live/room1.m3u8" + u.Query().Get("t")))
+	assert.Equal(t, hex.EncodeToString(sum[:]), u.Query().Get("sign"))
+	assert.True(t, VerifySignedPath(AuthTypeA, "primary", u.Path, u.Query(), now))
+	assert.False(t, VerifySignedPath(AuthTypeA, "primary", u.Path, u.Query(), time.Unix(expire+1, 0)), "expired")
+	assert.False(t, VerifySignedPath(AuthTypeA, "secondary", u.Path, u.Query(), now))
+
+	s.UseSecondaryKey = true
+	raw, err = s.URL(ProtocolFLV, "live", "room1")
+	require.NoError(t, err)
+	u, _ = url.Parse(raw)
+	assert.Equal(t, "/live/room1.flv", u.Path)
+	assert.True(t, VerifySignedPath(AuthTypeA, "secondary", u.Path, u.Query(), now))
+}
+
+func TestURLSigner_ExpirySK(t *testing.T) {
+	now := time.Now()
+	s := NewPushURLSigner(&PushDomainInfo{
+		Domain: "push.example.com",
+		Auth:   &PushDomainAuthConfig{Type: AuthTypeExpirySK, Enable: true, PrimaryKey: "k"},
+	})
+
+	raw, err := s.URL(ProtocolRTMP, "live", "room 1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, "rtmp://push.example.com/live/room%201?e="))
+	u, _ := url.Parse(raw)
+	assert.True(t, VerifySignedPath(AuthTypeExpirySK, "k", u.EscapedPath(), u.Query(), now))
+	assert.InDelta(t, now.Add(DefaultSignExpireSeconds*time.Second).Unix(), s.ExpiresAt().Unix(), 1)
+
+	raw, err = s.URL(ProtocolWHIP, "live", "room1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, "https://push.example.com/live/room1.whip?"), raw)
+
+	raw, err = s.URL(ProtocolSRT, "live", "room1")
+	require.NoError(t, err)
+	u, _ = url.Parse(raw)
+	assert.Equal(t, "srt", u.Scheme)
+	assert.Equal(t, "push.example.com:1935", u.Host)
+	streamID := u.Query().Get("streamid")
+	assert.True(t, strings.HasPrefix(streamID, "#!::h=push.example.com,r=/live/room1?e="), streamID)
+	assert.True(t, strings.HasSuffix(streamID, ",m=publish"), streamID)
+}
+
+func TestURLSigner_Errors(t *testing.T) {
+	push := NewPushURLSigner(&PushDomainInfo{Domain: "push.example.com"})
+	raw, err := push.URL(ProtocolRTMP, "live", "room1")
+	require.NoError(t, err)
+	assert.Equal(t, "rtmp://push.example.com/live/room1", raw, "auth disabled")
+
+	_, err = push.URL(ProtocolHLS, "live", "room1")
+	assert.ErrorIs(t, err, ErrUnsupportedProtocol)
+	_, err = NewPlayURLSigner(&PlayDomainInfo{Domain: "play.example.com"}).URL(ProtocolWHIP, "live", "room1")
+	assert.ErrorIs(t, err, ErrUnsupportedProtocol)
+
+	push.AuthEnable, push.AuthType, push.PrimaryKey = true, "typeZ", "k"
+	_, err = push.URL(ProtocolRTMP, "live", "room1")
+	assert.ErrorIs(t, err, ErrUnsupportedAuthType)
+
+	push.AuthType, push.PrimaryKey = AuthTypeA, ""
+	_, err = push.URL(ProtocolRTMP, "live", "room1")
+	assert.Error(t, err, "missing key")
+	_, err = push.URL(ProtocolRTMP, "", "room1")
+	assert.Error(t, err)
+}
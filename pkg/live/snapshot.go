@@ -0,0 +1,507 @@
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/qiniu/auth"
+)
+
+// 截图格式
+const (
+	SnapshotFormatJPG = "jpg"
+	SnapshotFormatPNG = "png"
+)
+
+const (
+	// MinSnapshotInterval, MaxSnapshotInterval 截图间隔范围（秒）
+	MinSnapshotInterval = 5
+	MaxSnapshotInterval = 3600
+)
+
+// snapshotFilenameVars 文件名模板可用的变量
+var snapshotFilenameVars = map[string]bool{"stream": true, "domain": true, "date": true, "time": true}
+
+var snapshotFilenameVarPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// LatestSnapshot 流的最新截图
+type LatestSnapshot struct {
+	StreamKey  string `json:"streamKey"`
+	URL        string `json:"url"`
+	Key        string `json:"key"`        // 截图在存储空间中的文件名
+	CapturedAt int64  `json:"capturedAt"` // 截图时间（Unix 秒）
+}
+
+// Validate 检查截图配置，用于上行域名或单个流（流上的配置优先于域名）。
+// Filename 可用 ${stream}、${domain}、${date}、${time}，不带 ${time} 时每次截图
+// 覆盖同一个文件，适合只保留最新画面的预览；域名级配置的文件名必须包含 ${stream}，
+// 否则不同流的截图会互相覆盖
+func (s *SnapshotConfig) Validate(perStream bool) error {
+	if s.Interval < MinSnapshotInterval || s.Interval > MaxSnapshotInterval {
+		return fmt.Errorf("interval must be between %d and %d seconds", MinSnapshotInterval, MaxSnapshotInterval)
+	}
+	if s.Bucket == "" {
+		return fmt.Errorf("snapshot bucket cannot be empty")
+	}
+	if s.Format != "" && s.Format != SnapshotFormatJPG && s.Format != SnapshotFormatPNG {
+		return fmt.Errorf("unsupported snapshot format: %s", s.Format)
+	}
+	if s.ExpireDays < 0 {
+		return fmt.Errorf("expireDays cannot be negative")
+	}
+	if s.Filename == "" || strings.HasPrefix(s.Filename, "/") {
+		return fmt.Errorf("filename cannot be empty or start with '/'")
+	}
+	for _, m := range snapshotFilenameVarPattern.FindAllStringSubmatch(s.Filename, -1) {
+		if !snapshotFilenameVars[m[1]] {
+			return fmt.Errorf("unknown filename variable: ${%s}", m[1])
+		}
+	}
+	if !perStream && !strings.Contains(s.Filename, "${stream}") {
+		return fmt.Errorf("filename of a domain snapshot config must contain ${stream}")
+	}
+	return nil
+}
+
+// SetPushDomainSnapshotConfig 设置上行域名的截图配置，对域名下没有单独配置的流生效
+// bucketName: 直播空间名称
+// domain: 上行域名
+func (c *BucketClient) SetPushDomainSnapshotConfig(bucketName, domain string, req *SnapshotConfig, opts ...CallOption) (*SnapshotConfig, error) {
+	return recordDomainChange(c, domainChange{
+		operation: ChangeSetPushDomainSnapshot, bucket: bucketName, domain: domain, request: req,
+		before: func() (interface{}, error) { return c.GetPushDomainSnapshotConfig(bucketName, domain, opts...) },
+	}, opts, func() (*SnapshotConfig, error) {
+		return c.setPushDomainSnapshotConfig(bucketName, domain, req, opts...)
+	})
+}
+
+// setPushDomainSnapshotConfig SetPushDomainSnapshotConfig 的实现，不记录变更
+func (c *BucketClient) setPushDomainSnapshotConfig(bucketName, domain string, req *SnapshotConfig, opts ...CallOption) (*SnapshotConfig, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if domain == "" {
+		return nil, fmt.Errorf("domain cannot be empty")
+	}
+	if err := req.Validate(false); err != nil {
+		return nil, err
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "PUT"
+	rawQuery := fmt.Sprintf("pushDomainSnapshot&name=%s", url.QueryEscape(domain))
+
+	// 构建请求 body
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:      method,
+		Path:        path,
+		RawQuery:    rawQuery,
+		Host:        host,
+		ContentType: "application/json",
+		Body:        bodyBytes,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result SnapshotConfig
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// GetPushDomainSnapshotConfig 查询上行域名的截图配置
+// bucketName: 直播空间名称
+// domain: 上行域名
+func (c *BucketClient) GetPushDomainSnapshotConfig(bucketName, domain string, opts ...CallOption) (*SnapshotConfig, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if domain == "" {
+		return nil, fmt.Errorf("domain cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "GET"
+	rawQuery := fmt.Sprintf("pushDomainSnapshot&name=%s", url.QueryEscape(domain))
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result SnapshotConfig
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// SetStreamSnapshotConfig 设置单个流的截图配置，覆盖域名级配置
+// bucketName: 直播空间名称
+// streamKey: 流名称
+func (c *BucketClient) SetStreamSnapshotConfig(bucketName, streamKey string, req *SnapshotConfig, opts ...CallOption) (*SnapshotConfig, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if streamKey == "" {
+		return nil, fmt.Errorf("stream key cannot be empty")
+	}
+	if err := req.Validate(true); err != nil {
+		return nil, err
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := fmt.Sprintf("/%s", url.PathEscape(streamKey))
+	method := "PUT"
+	rawQuery := "snapshot"
+
+	// 构建请求 body
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:      method,
+		Path:        path,
+		RawQuery:    rawQuery,
+		Host:        host,
+		ContentType: "application/json",
+		Body:        bodyBytes,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result SnapshotConfig
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// GetStreamSnapshotConfig 查询流的截图配置
+// bucketName: 直播空间名称
+// streamKey: 流名称
+func (c *BucketClient) GetStreamSnapshotConfig(bucketName, streamKey string, opts ...CallOption) (*SnapshotConfig, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if streamKey == "" {
+		return nil, fmt.Errorf("stream key cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := fmt.Sprintf("/%s", url.PathEscape(streamKey))
+	method := "GET"
+	rawQuery := "snapshot"
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result SnapshotConfig
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// DeleteStreamSnapshotConfig 删除流的截图配置，之后按域名级配置截图
+// bucketName: 直播空间名称
+// streamKey: 流名称
+func (c *BucketClient) DeleteStreamSnapshotConfig(bucketName, streamKey string, opts ...CallOption) error {
+	if bucketName == "" {
+		return fmt.Errorf("bucket name cannot be empty")
+	}
+	if streamKey == "" {
+		return fmt.Errorf("stream key cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := fmt.Sprintf("/%s", url.PathEscape(streamKey))
+	method := "DELETE"
+	rawQuery := "snapshot"
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp, respBody)
+	}
+
+	return nil
+}
+
+// GetLatestSnapshot 获取流最新一张截图的地址，用于内容审核和直播预览；
+// 流未开启截图或还没有截图时返回 ErrNotFound
+// bucketName: 直播空间名称
+// streamKey: 流名称
+func (c *BucketClient) GetLatestSnapshot(bucketName, streamKey string, opts ...CallOption) (*LatestSnapshot, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if streamKey == "" {
+		return nil, fmt.Errorf("stream key cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := fmt.Sprintf("/%s", url.PathEscape(streamKey))
+	method := "GET"
+	rawQuery := "latestSnapshot"
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result LatestSnapshot
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
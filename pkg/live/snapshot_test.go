@@ -0,0 +1,112 @@
+package live
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotConfig_Validate(t *testing.T) {
+	valid := SnapshotConfig{Enable: true, Interval: 10, Bucket: "snapshots", Filename: "${domain}/${stream}/${date}/${time}", Format: SnapshotFormatJPG}
+	require.NoError(t, valid.Validate(false))
+
+	cases := map[string]func(s *SnapshotConfig){
+		"interval":     func(s *SnapshotConfig) { s.Interval = 1 },
+		"bucket":       func(s *SnapshotConfig) { s.Bucket = "" },
+		"format":       func(s *SnapshotConfig) { s.Format = "gif" },
+		"expire":       func(s *SnapshotConfig) { s.ExpireDays = -1 },
+		"filename":     func(s *SnapshotConfig) { s.Filename = "" },
+		"absolute":     func(s *SnapshotConfig) { s.Filename = "/${stream}" },
+		"variable":     func(s *SnapshotConfig) { s.Filename = "${stream}/${hour}" },
+		"no ${stream}": func(s *SnapshotConfig) { s.Filename = "latest" },
+	}
+	for name, mutate := range cases {
+		cfg := valid
+		mutate(&cfg)
+		assert.Error(t, cfg.Validate(false), name)
+	}
+
+	perStream := SnapshotConfig{Interval: 30, Bucket: "snapshots", Filename: "room-1/latest"}
+	assert.NoError(t, perStream.Validate(true), "a single stream needs no ${stream}")
+	assert.Error(t, perStream.Validate(false))
+}
+
+func TestBucketClient_SnapshotConfig(t *testing.T) {
+	var requests []string
+	domains := map[string]SnapshotConfig{}
+	streams := map[string]SnapshotConfig{}
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "live.mls.test", r.Host)
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		stream := r.URL.Path[1:]
+		switch {
+		case r.URL.Query().Has("pushDomainSnapshot"):
+			name := r.URL.Query().Get("name")
+			if r.Method == http.MethodPut {
+				var cfg SnapshotConfig
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&cfg))
+				domains[name] = cfg
+			}
+			json.NewEncoder(w).Encode(domains[name])
+		case r.URL.Query().Has("latestSnapshot"):
+			if _, ok := streams[stream]; !ok {
+				w.WriteHeader(qiniuStatusNotFound)
+				w.Write([]byte(`{"error":"no snapshot"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(LatestSnapshot{StreamKey: stream, URL: "https://snap.example.com/" + stream + "/latest.jpg", CapturedAt: 100})
+		default:
+			switch r.Method {
+			case http.MethodPut:
+				var cfg SnapshotConfig
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&cfg))
+				streams[stream] = cfg
+			case http.MethodDelete:
+				delete(streams, stream)
+				return
+			}
+			json.NewEncoder(w).Encode(streams[stream])
+		}
+	})
+
+	cfg, err := c.SetPushDomainSnapshotConfig("live", "push.example.com", &SnapshotConfig{Enable: true, Interval: 60, Bucket: "snapshots", Filename: "${stream}/${time}"})
+	require.NoError(t, err)
+	assert.Equal(t, 60, cfg.Interval)
+	cfg, err = c.GetPushDomainSnapshotConfig("live", "push.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "${stream}/${time}", cfg.Filename)
+
+	_, err = c.SetStreamSnapshotConfig("live", "room 1", &SnapshotConfig{Enable: true, Interval: 5, Bucket: "moderation", Filename: "room-1/latest"})
+	require.NoError(t, err)
+	cfg, err = c.GetStreamSnapshotConfig("live", "room 1")
+	require.NoError(t, err)
+	assert.Equal(t, "moderation", cfg.Bucket)
+
+	latest, err := c.GetLatestSnapshot("live", "room 1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://snap.example.com/room 1/latest.jpg", latest.URL)
+	assert.Equal(t, int64(100), latest.CapturedAt)
+
+	require.NoError(t, c.DeleteStreamSnapshotConfig("live", "room 1"))
+	_, err = c.GetLatestSnapshot("live", "room 1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Equal(t, []string{
+		"PUT /?pushDomainSnapshot&name=push.example.com",
+		"GET /?pushDomainSnapshot&name=push.example.com",
+		"PUT /room 1?snapshot",
+		"GET /room 1?snapshot",
+		"GET /room 1?latestSnapshot",
+		"DELETE /room 1?snapshot",
+		"GET /room 1?latestSnapshot",
+	}, requests)
+
+	_, err = c.SetPushDomainSnapshotConfig("live", "push.example.com", &SnapshotConfig{Interval: 60, Bucket: "snapshots", Filename: "latest"})
+	assert.Error(t, err)
+	_, err = c.GetLatestSnapshot("live", "")
+	assert.Error(t, err)
+	assert.Len(t, requests, 7, "invalid requests are not sent")
+}
@@ -0,0 +1,305 @@
+package live
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/qiniu/auth"
+)
+
+// 统计数据的时间粒度
+const (
+	StatsGranularity5Min = "5min"
+	StatsGranularityHour = "hour"
+	StatsGranularityDay  = "day"
+)
+
+// maxStatsRange 单次统计查询的最大时间范围
+const maxStatsRange = 93 * 24 * time.Hour
+
+// StatsRequest 统计查询条件
+type StatsRequest struct {
+	BucketID    string    // 空间 ID，BucketID 和 Domains 必须二传一
+	Domains     []string  // 查询的域名，为空时查询空间下的全部域名
+	Start       time.Time // 包含
+	End         time.Time // 不包含
+	Granularity string    // 5min, hour, day，为空时按时间范围选择：一天内 5min，31 天内 hour，否则 day
+}
+
+// validate 检查查询条件并补全时间粒度
+func (r *StatsRequest) validate() error {
+	if r.BucketID == "" && len(r.Domains) == 0 {
+		return fmt.Errorf("bucket id or domains is required")
+	}
+	if r.Start.IsZero() || r.End.IsZero() || !r.Start.Before(r.End) {
+		return fmt.Errorf("start must be before end")
+	}
+	if r.End.Sub(r.Start) > maxStatsRange {
+		return fmt.Errorf("time range cannot exceed %d days", int(maxStatsRange/(24*time.Hour)))
+	}
+	switch r.Granularity {
+	case "":
+		r.Granularity = defaultGranularity(r.End.Sub(r.Start))
+	case StatsGranularity5Min, StatsGranularityHour, StatsGranularityDay:
+	default:
+		return fmt.Errorf("invalid granularity %q", r.Granularity)
+	}
+	return nil
+}
+
+func (r *StatsRequest) query() url.Values {
+	q := url.Values{}
+	if r.BucketID != "" {
+		q.Set("bucketId", r.BucketID)
+	}
+	if len(r.Domains) > 0 {
+		q.Set("domains", strings.Join(r.Domains, ","))
+	}
+	q.Set("start", strconv.FormatInt(r.Start.Unix(), 10))
+	q.Set("end", strconv.FormatInt(r.End.Unix(), 10))
+	q.Set("granularity", r.Granularity)
+	return q
+}
+
+func defaultGranularity(span time.Duration) string {
+	switch {
+	case span <= 24*time.Hour:
+		return StatsGranularity5Min
+	case span <= 31*24*time.Hour:
+		return StatsGranularityHour
+	default:
+		return StatsGranularityDay
+	}
+}
+
+// BandwidthPoint 带宽时间序列中的一个点，Time 为统计周期的开始时间
+type BandwidthPoint struct {
+	Time      time.Time `json:"time"`
+	Bandwidth int64     `json:"bandwidth"` // 周期内的峰值带宽，bps
+	Traffic   int64     `json:"traffic"`   // 周期内的流量，字节
+}
+
+// DomainBandwidth 一个下行域名的带宽时间序列
+type DomainBandwidth struct {
+	Domain       string           `json:"domain"`
+	Points       []BandwidthPoint `json:"points"`
+	Peak         BandwidthPoint   `json:"peak"`         // 带宽最高的点，用于按峰值计费
+	TotalTraffic int64            `json:"totalTraffic"` // 时间范围内的总流量，用于按流量计费
+}
+
+// BandwidthStats 带宽统计
+type BandwidthStats struct {
+	Granularity string            `json:"granularity"`
+	Domains     []DomainBandwidth `json:"domains"`
+}
+
+// ViewerPoint 观看人数时间序列中的一个点
+type ViewerPoint struct {
+	Time    time.Time `json:"time"`
+	Viewers int64     `json:"viewers"` // 周期内的最大并发观看人数
+}
+
+// DomainViewers 一个下行域名的并发观看人数时间序列
+type DomainViewers struct {
+	Domain string        `json:"domain"`
+	Points []ViewerPoint `json:"points"`
+	Peak   ViewerPoint   `json:"peak"`
+}
+
+// ViewerStats 并发观看人数统计
+type ViewerStats struct {
+	Granularity string          `json:"granularity"`
+	Domains     []DomainViewers `json:"domains"`
+}
+
+// PushSession 一次推流，推流中的 End 为零值
+type PushSession struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end,omitempty"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+}
+
+// StreamUptime 流在时间范围内的推流时长
+type StreamUptime struct {
+	Key           string        `json:"key"`
+	Start         time.Time     `json:"start"`
+	End           time.Time     `json:"end"`
+	Sessions      []PushSession `json:"sessions"`
+	Uptime        time.Duration `json:"uptime"`        // 时间范围内的推流总时长
+	Availability  float64       `json:"availability"`  // 推流时长占时间范围的比例，0-1
+	Interruptions int           `json:"interruptions"` // 时间范围内推流结束后又重新推流的次数
+}
+
+// statsSeries 统计接口返回的时间序列
+type statsSeries struct {
+	Data []struct {
+		Domain string `json:"domain"`
+		Points []struct {
+			Time      int64 `json:"time"`
+			Bandwidth int64 `json:"bandwidth"`
+			Flow      int64 `json:"flow"`
+			Count     int64 `json:"count"`
+		} `json:"points"`
+	} `json:"data"`
+}
+
+// GetBandwidthStats 查询下行域名在时间范围内的带宽和流量
+func (c *BucketClient) GetBandwidthStats(req *StatsRequest, opts ...CallOption) (*BandwidthStats, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	q := req.query()
+	q.Set("bandwidth", "")
+	var series statsSeries
+	if err := c.getJSON(c.baseHost, "/", q, &series, opts...); err != nil {
+		return nil, err
+	}
+
+	stats := &BandwidthStats{Granularity: req.Granularity, Domains: []DomainBandwidth{}}
+	for _, d := range series.Data {
+		domain := DomainBandwidth{Domain: d.Domain, Points: make([]BandwidthPoint, 0, len(d.Points))}
+		for _, p := range d.Points {
+			point := BandwidthPoint{Time: time.Unix(p.Time, 0), Bandwidth: p.Bandwidth, Traffic: p.Flow}
+			domain.Points = append(domain.Points, point)
+			domain.TotalTraffic += p.Flow
+			if p.Bandwidth > domain.Peak.Bandwidth {
+				domain.Peak = point
+			}
+		}
+		stats.Domains = append(stats.Domains, domain)
+	}
+	return stats, nil
+}
+
+// GetViewerStats 查询下行域名在时间范围内的并发观看人数
+func (c *BucketClient) GetViewerStats(req *StatsRequest, opts ...CallOption) (*ViewerStats, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	q := req.query()
+	q.Set("onlinenumber", "")
+	var series statsSeries
+	if err := c.getJSON(c.baseHost, "/", q, &series, opts...); err != nil {
+		return nil, err
+	}
+
+	stats := &ViewerStats{Granularity: req.Granularity, Domains: []DomainViewers{}}
+	for _, d := range series.Data {
+		domain := DomainViewers{Domain: d.Domain, Points: make([]ViewerPoint, 0, len(d.Points))}
+		for _, p := range d.Points {
+			point := ViewerPoint{Time: time.Unix(p.Time, 0), Viewers: p.Count}
+			domain.Points = append(domain.Points, point)
+			if p.Count > domain.Peak.Viewers {
+				domain.Peak = point
+			}
+		}
+		stats.Domains = append(stats.Domains, domain)
+	}
+	return stats, nil
+}
+
+// GetStreamUptime 查询流在时间范围内的推流记录和推流时长
+// bucketName: 空间名称
+// streamKey: 流名称
+func (c *BucketClient) GetStreamUptime(bucketName, streamKey string, start, end time.Time, opts ...CallOption) (*StreamUptime, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if streamKey == "" {
+		return nil, fmt.Errorf("stream key cannot be empty")
+	}
+	if start.IsZero() || end.IsZero() || !start.Before(end) {
+		return nil, fmt.Errorf("start must be before end")
+	}
+	if end.Sub(start) > maxStatsRange {
+		return nil, fmt.Errorf("time range cannot exceed %d days", int(maxStatsRange/(24*time.Hour)))
+	}
+
+	q := url.Values{}
+	q.Set("pushhistory", "")
+	q.Set("start", strconv.FormatInt(start.Unix(), 10))
+	q.Set("end", strconv.FormatInt(end.Unix(), 10))
+	var history struct {
+		Items []struct {
+			Start      int64  `json:"start"`
+			End        int64  `json:"end"` // 推流中为 0
+			RemoteAddr string `json:"remoteAddr"`
+		} `json:"items"`
+	}
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	if err := c.getJSON(host, "/"+url.PathEscape(streamKey), q, &history, opts...); err != nil {
+		return nil, err
+	}
+
+	sessions := make([]PushSession, 0, len(history.Items))
+	for _, item := range history.Items {
+		s := PushSession{Start: time.Unix(item.Start, 0), RemoteAddr: item.RemoteAddr}
+		if item.End > 0 {
+			s.End = time.Unix(item.End, 0)
+		}
+		sessions = append(sessions, s)
+	}
+	return streamUptime(streamKey, start, end, sessions, time.Now()), nil
+}
+
+// streamUptime 统计推流记录在 [start, end) 内的推流时长，推流中的记录计算到 now
+func streamUptime(key string, start, end time.Time, sessions []PushSession, now time.Time) *StreamUptime {
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Start.Before(sessions[j].Start) })
+	u := &StreamUptime{Key: key, Start: start, End: end, Sessions: sessions}
+	for i, s := range sessions {
+		sessionEnd := s.End
+		if sessionEnd.IsZero() {
+			sessionEnd = now
+		}
+		from, to := s.Start, sessionEnd
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			u.Uptime += to.Sub(from)
+		}
+		// 之后还有推流说明该次推流是中断而非结束
+		if !s.End.IsZero() && i < len(sessions)-1 && !s.End.Before(start) && s.End.Before(end) {
+			u.Interruptions++
+		}
+	}
+	u.Availability = float64(u.Uptime) / float64(end.Sub(start))
+	return u
+}
+
+// getJSON 发送带鉴权的 GET 请求并解析 JSON 响应
+func (c *BucketClient) getJSON(host, path string, query url.Values, out interface{}, opts ...CallOption) error {
+	rawQuery := query.Encode()
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, auth.QiniuAuthRequest{
+		Method:   http.MethodGet,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	})
+	if err != nil {
+		return fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s%s?%s", host, path, rawQuery), nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return decodeJSON(resp, out)
+}
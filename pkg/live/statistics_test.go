@@ -0,0 +1,102 @@
+package live
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketClient_GetBandwidthStats(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.True(t, q.Has("bandwidth"))
+		assert.Equal(t, "hls.example.com,flv.example.com", q.Get("domains"))
+		assert.Equal(t, "1700000000", q.Get("start"))
+		assert.Equal(t, StatsGranularity5Min, q.Get("granularity"))
+		w.Write([]byte(`{"data":[{"domain":"hls.example.com","points":[
+			{"time":1700000000,"bandwidth":1000,"flow":300},
+			{"time":1700000300,"bandwidth":5000,"flow":700},
+			{"time":1700000600,"bandwidth":2000,"flow":100}]}]}`))
+	})
+
+	stats, err := c.GetBandwidthStats(&StatsRequest{
+		Domains: []string{"hls.example.com", "flv.example.com"},
+		Start:   start,
+		End:     start.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StatsGranularity5Min, stats.Granularity)
+	require.Len(t, stats.Domains, 1)
+	d := stats.Domains[0]
+	assert.Len(t, d.Points, 3)
+	assert.Equal(t, int64(1100), d.TotalTraffic)
+	assert.Equal(t, int64(5000), d.Peak.Bandwidth)
+	assert.Equal(t, start.Add(5*time.Minute), d.Peak.Time)
+}
+
+func TestBucketClient_GetViewerStats(t *testing.T) {
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, r.URL.Query().Has("onlinenumber"))
+		assert.Equal(t, "bucket-1", r.URL.Query().Get("bucketId"))
+		assert.Equal(t, StatsGranularityHour, r.URL.Query().Get("granularity"))
+		w.Write([]byte(`{"data":[{"domain":"hls.example.com","points":[{"time":1700000000,"count":12},{"time":1700003600,"count":40}]}]}`))
+	})
+
+	start := time.Unix(1700000000, 0)
+	stats, err := c.GetViewerStats(&StatsRequest{BucketID: "bucket-1", Start: start, End: start.Add(7 * 24 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, stats.Domains, 1)
+	assert.Equal(t, int64(40), stats.Domains[0].Peak.Viewers)
+}
+
+func TestStatsRequest_Validate(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	for name, req := range map[string]StatsRequest{
+		"no scope":        {Start: start, End: start.Add(time.Hour)},
+		"reversed range":  {BucketID: "b", Start: start, End: start.Add(-time.Hour)},
+		"range too long":  {BucketID: "b", Start: start, End: start.Add(100 * 24 * time.Hour)},
+		"bad granularity": {BucketID: "b", Start: start, End: start.Add(time.Hour), Granularity: "minute"},
+	} {
+		assert.Error(t, req.validate(), name)
+	}
+	req := StatsRequest{BucketID: "b", Start: start, End: start.Add(60 * 24 * time.Hour)}
+	require.NoError(t, req.validate())
+	assert.Equal(t, StatsGranularityDay, req.Granularity)
+}
+
+func TestBucketClient_GetStreamUptime(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bucket.mls.test", r.Host)
+		assert.Equal(t, "/room-1", r.URL.Path)
+		assert.True(t, r.URL.Query().Has("pushhistory"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": []map[string]interface{}{
+			{"start": start.Add(30 * time.Minute).Unix(), "end": start.Add(90 * time.Minute).Unix(), "remoteAddr": "1.2.3.4:5000"},
+			{"start": start.Add(-time.Hour).Unix(), "end": start.Add(15 * time.Minute).Unix()},
+		}})
+	})
+
+	u, err := c.GetStreamUptime("bucket", "room-1", start, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, u.Sessions, 2)
+	assert.Equal(t, start.Add(-time.Hour), u.Sessions[0].Start, "sessions are ordered by start")
+	assert.Equal(t, 75*time.Minute, u.Uptime, "sessions are clipped to the range")
+	assert.InDelta(t, 0.625, u.Availability, 1e-9)
+	assert.Equal(t, 1, u.Interruptions)
+
+	_, err = c.GetStreamUptime("bucket", "room-1", start, start)
+	assert.Error(t, err)
+}
+
+func TestStreamUptime_Ongoing(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	now := start.Add(30 * time.Minute)
+	u := streamUptime("s", start, start.Add(time.Hour), []PushSession{{Start: start.Add(10 * time.Minute)}}, now)
+	assert.Equal(t, 20*time.Minute, u.Uptime, "ongoing sessions count until now")
+	assert.Zero(t, u.Interruptions)
+}
@@ -3,11 +3,14 @@ package live
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/code-100-precent/LingEcho/pkg/utils/qiniu/auth"
 )
@@ -90,6 +93,29 @@ type ListStreamsResponse struct {
 	PageSize  string           `json:"pageSize,omitempty"`
 }
 
+// 流状态
+const (
+	StreamStatusOnline  = "online"
+	StreamStatusOffline = "offline"
+)
+
+// listStreamsPageSize ListOnlineStreams 每页请求的流数量（接口上限）
+const listStreamsPageSize = 500
+
+// StreamStatus 流的实时状态，码率单位为 bps
+type StreamStatus struct {
+	Key          string    `json:"key"`
+	Online       bool      `json:"online"`
+	Forbidden    bool      `json:"forbidden"`
+	StartedAt    time.Time `json:"startedAt,omitempty"` // 最近一次推流开始时间
+	VideoBitrate int64     `json:"videoBitrate"`
+	AudioBitrate int64     `json:"audioBitrate"`
+	Bitrate      int64     `json:"bitrate"` // 音视频码率之和
+	FPS          float64   `json:"fps"`
+	Resolution   string    `json:"resolution,omitempty"`
+	RemoteAddr   string    `json:"remoteAddr,omitempty"` // 推流端地址
+}
+
 // ListStreamsRequest 列举流列表请求参数
 type ListStreamsRequest struct {
 	Prefix   string // 流名前缀
@@ -105,7 +131,7 @@ type ListStreamsRequest struct {
 // CreateStream 创建流
 // bucketName: 空间名称
 // streamKey: 流名称
-func (c *BucketClient) CreateStream(bucketName, streamKey string) (*CreateStreamResponse, error) {
+func (c *BucketClient) CreateStream(bucketName, streamKey string, opts ...CallOption) (*CreateStreamResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -148,7 +174,7 @@ func (c *BucketClient) CreateStream(bucketName, streamKey string) (*CreateStream
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -162,7 +188,7 @@ func (c *BucketClient) CreateStream(bucketName, streamKey string) (*CreateStream
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -177,7 +203,7 @@ func (c *BucketClient) CreateStream(bucketName, streamKey string) (*CreateStream
 // GetStreamInfo 获取流信息
 // bucketName: 空间名称
 // streamKey: 流名称
-func (c *BucketClient) GetStreamInfo(bucketName, streamKey string) (*StreamInfo, error) {
+func (c *BucketClient) GetStreamInfo(bucketName, streamKey string, opts ...CallOption) (*StreamInfo, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -216,7 +242,7 @@ func (c *BucketClient) GetStreamInfo(bucketName, streamKey string) (*StreamInfo,
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -230,7 +256,7 @@ func (c *BucketClient) GetStreamInfo(bucketName, streamKey string) (*StreamInfo,
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -246,7 +272,7 @@ func (c *BucketClient) GetStreamInfo(bucketName, streamKey string) (*StreamInfo,
 // bucketName: 空间名称
 // streamKey: 流名称
 // req: 封禁请求
-func (c *BucketClient) ForbidStream(bucketName, streamKey string, req *ForbidStreamRequest) (*ForbidStreamResponse, error) {
+func (c *BucketClient) ForbidStream(bucketName, streamKey string, req *ForbidStreamRequest, opts ...CallOption) (*ForbidStreamResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -294,7 +320,7 @@ func (c *BucketClient) ForbidStream(bucketName, streamKey string, req *ForbidStr
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -308,7 +334,7 @@ func (c *BucketClient) ForbidStream(bucketName, streamKey string, req *ForbidStr
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -323,7 +349,7 @@ func (c *BucketClient) ForbidStream(bucketName, streamKey string, req *ForbidStr
 // ReleaseStream 解封流
 // bucketName: 空间名称
 // streamKey: 流名称
-func (c *BucketClient) ReleaseStream(bucketName, streamKey string) (*ReleaseStreamResponse, error) {
+func (c *BucketClient) ReleaseStream(bucketName, streamKey string, opts ...CallOption) (*ReleaseStreamResponse, error) {
 	if bucketName == "" {
 		return nil, fmt.Errorf("bucket name cannot be empty")
 	}
@@ -363,7 +389,7 @@ func (c *BucketClient) ReleaseStream(bucketName, streamKey string) (*ReleaseStre
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -377,7 +403,7 @@ func (c *BucketClient) ReleaseStream(bucketName, streamKey string) (*ReleaseStre
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -391,7 +417,7 @@ func (c *BucketClient) ReleaseStream(bucketName, streamKey string) (*ReleaseStre
 
 // ListStreams 列举流列表
 // req: 请求参数
-func (c *BucketClient) ListStreams(req *ListStreamsRequest) (*ListStreamsResponse, error) {
+func (c *BucketClient) ListStreams(req *ListStreamsRequest, opts ...CallOption) (*ListStreamsResponse, error) {
 	host := c.baseHost
 	path := "/"
 	method := "GET"
@@ -452,7 +478,7 @@ func (c *BucketClient) ListStreams(req *ListStreamsRequest) (*ListStreamsRespons
 	httpReq.Header.Set("Authorization", token)
 
 	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -466,7 +492,7 @@ func (c *BucketClient) ListStreams(req *ListStreamsRequest) (*ListStreamsRespons
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	// 解析响应
@@ -494,7 +520,7 @@ func (c *BucketClient) StreamExists(bucketName, streamKey string) (bool, error)
 	_, err := c.GetStreamInfo(bucketName, streamKey)
 	if err != nil {
 		// 如果返回 404，说明流不存在
-		if strings.Contains(err.Error(), "404") {
+		if errors.Is(err, ErrNotFound) {
 			return false, nil
 		}
 		// 其他错误返回错误信息
@@ -504,3 +530,109 @@ func (c *BucketClient) StreamExists(bucketName, streamKey string) (bool, error)
 	// 成功获取到流信息，说明流存在
 	return true, nil
 }
+
+// ListOnlineStreams 列举正在推流的流，自动翻页
+// req: 筛选条件，Offset 和 Limit 会被忽略
+func (c *BucketClient) ListOnlineStreams(req ListStreamsRequest, opts ...CallOption) ([]StreamListItem, error) {
+	if req.BucketID == "" && req.Domain == "" {
+		return nil, fmt.Errorf("bucket id or domain is required")
+	}
+
+	var online []StreamListItem
+	req.Limit = strconv.Itoa(listStreamsPageSize)
+	for offset := 0; ; {
+		req.Offset = strconv.Itoa(offset)
+		page, err := c.ListStreams(&req, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			if item.Status == StreamStatusOnline {
+				online = append(online, item)
+			}
+		}
+		offset += len(page.Items)
+		if len(page.Items) < listStreamsPageSize || (page.Total > 0 && offset >= page.Total) {
+			return online, nil
+		}
+	}
+}
+
+// GetStreamStatus 查询流的推流状态和码率
+// bucketName: 空间名称
+// streamKey: 流名称
+func (c *BucketClient) GetStreamStatus(bucketName, streamKey string, opts ...CallOption) (*StreamStatus, error) {
+	info, err := c.GetStreamInfo(bucketName, streamKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return streamStatus(info), nil
+}
+
+// streamStatus 从流信息中提取实时状态
+func streamStatus(i *StreamInfo) *StreamStatus {
+	status := &StreamStatus{
+		Key:        i.Key,
+		Online:     i.Status == StreamStatusOnline,
+		Forbidden:  i.Forbidden,
+		RemoteAddr: i.RemoteAddr,
+	}
+	if i.LastStartAt != nil && *i.LastStartAt > 0 {
+		status.StartedAt = time.Unix(*i.LastStartAt, 0)
+	}
+	if p := i.StreamProfile; p != nil && status.Online {
+		status.VideoBitrate = parseBitrate(p.VideoRate)
+		status.AudioBitrate = parseBitrate(p.AudioRate)
+		status.Bitrate = status.VideoBitrate + status.AudioBitrate
+		status.FPS, _ = strconv.ParseFloat(strings.TrimSpace(p.VideoFps), 64)
+		status.Resolution = p.VideoResolution
+	}
+	return status
+}
+
+// DisableStream 禁止流推流，当前的推流连接会被断开
+// bucketName: 空间名称
+// streamKey: 流名称
+// until: 禁播结束时间，零值表示永久禁播
+func (c *BucketClient) DisableStream(bucketName, streamKey string, until time.Time, opts ...CallOption) error {
+	req := &ForbidStreamRequest{}
+	if !until.IsZero() {
+		if !until.After(time.Now()) {
+			return fmt.Errorf("forbidden until must be in the future")
+		}
+		req.ForbiddenTill = until.Unix()
+	}
+	_, err := c.ForbidStream(bucketName, streamKey, req, opts...)
+	return err
+}
+
+// ResumeStream 恢复被禁止的流
+// bucketName: 空间名称
+// streamKey: 流名称
+func (c *BucketClient) ResumeStream(bucketName, streamKey string, opts ...CallOption) error {
+	_, err := c.ReleaseStream(bucketName, streamKey, opts...)
+	return err
+}
+
+// parseBitrate 解析码率，支持纯数字（bps）和 bps/kbps/mbps 单位，无法解析时返回 0
+func parseBitrate(value string) int64 {
+	value = strings.ToLower(strings.TrimSpace(value))
+	multiplier := 1.0
+	for _, unit := range []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"mbps", 1e6}, {"kbps", 1e3}, {"bps", 1}, {"m", 1e6}, {"k", 1e3},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return int64(rate * multiplier)
+}
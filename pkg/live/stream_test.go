@@ -1 +1,141 @@
 package live
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBucketClient returns a client whose requests to any host, including
+// bucket subdomains, are served by handler
+func newTestBucketClient(t *testing.T, handler http.HandlerFunc) *BucketClient {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	return &BucketClient{
+		accessKey:  "ak",
+		secretKey:  "sk",
+		baseHost:   "mls.test",
+		httpClient: &http.Client{Transport: transport},
+		retry:      NoRetry(),
+	}
+}
+
+func TestBucketClient_ListOnlineStreams(t *testing.T) {
+	var offsets []string
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bucket-1", r.URL.Query().Get("bucketId"))
+		assert.Equal(t, "500", r.URL.Query().Get("limit"))
+		offset := r.URL.Query().Get("offset")
+		offsets = append(offsets, offset)
+
+		resp := ListStreamsResponse{Total: 502}
+		if offset == "0" {
+			for i := 0; i < listStreamsPageSize; i++ {
+				status := StreamStatusOffline
+				if i%250 == 0 {
+					status = StreamStatusOnline
+				}
+				resp.Items = append(resp.Items, StreamListItem{Key: "s" + string(rune('a'+i%26)), Status: status})
+			}
+		} else {
+			resp.Items = []StreamListItem{{Key: "last", Status: StreamStatusOnline}, {Key: "idle", Status: StreamStatusOffline}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	streams, err := c.ListOnlineStreams(ListStreamsRequest{BucketID: "bucket-1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "500"}, offsets)
+	require.Len(t, streams, 3)
+	assert.Equal(t, "last", streams[2].Key)
+
+	_, err = c.ListOnlineStreams(ListStreamsRequest{})
+	assert.Error(t, err)
+}
+
+func TestBucketClient_GetStreamStatus(t *testing.T) {
+	started := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC).Unix()
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "live.mls.test", r.Host)
+		assert.Equal(t, "/room%201", r.URL.EscapedPath())
+		json.NewEncoder(w).Encode(StreamInfo{
+			Key:         "room 1",
+			Status:      StreamStatusOnline,
+			LastStartAt: &started,
+			RemoteAddr:  "203.0.113.7:50000",
+			StreamProfile: &StreamProfile{
+				VideoRate:       "1200kbps",
+				AudioRate:       "128000",
+				VideoFps:        "29.97",
+				VideoResolution: "1280x720",
+			},
+		})
+	})
+
+	status, err := c.GetStreamStatus("live", "room 1")
+	require.NoError(t, err)
+	assert.True(t, status.Online)
+	assert.Equal(t, int64(1200000), status.VideoBitrate)
+	assert.Equal(t, int64(128000), status.AudioBitrate)
+	assert.Equal(t, int64(1328000), status.Bitrate)
+	assert.Equal(t, 29.97, status.FPS)
+	assert.Equal(t, "1280x720", status.Resolution)
+	assert.Equal(t, started, status.StartedAt.Unix())
+	assert.Equal(t, "203.0.113.7:50000", status.RemoteAddr)
+}
+
+func TestBucketClient_DisableAndResumeStream(t *testing.T) {
+	var requests []string
+	var forbidden ForbidStreamRequest
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RawQuery)
+		if strings.HasPrefix(r.URL.RawQuery, "forbid&") {
+			json.NewDecoder(r.Body).Decode(&forbidden)
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	until := time.Now().Add(time.Hour)
+	require.NoError(t, c.DisableStream("live", "abuser", until))
+	assert.Equal(t, until.Unix(), forbidden.ForbiddenTill)
+	require.NoError(t, c.DisableStream("live", "abuser", time.Time{}))
+	assert.Zero(t, forbidden.ForbiddenTill, "permanent")
+	assert.Error(t, c.DisableStream("live", "abuser", time.Now().Add(-time.Minute)))
+
+	require.NoError(t, c.ResumeStream("live", "abuser"))
+	require.Len(t, requests, 3)
+	assert.NotEqual(t, requests[0], requests[2])
+}
+
+func TestBucketClient_StreamExists(t *testing.T) {
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"stream not found"}`))
+	})
+	exists, err := c.StreamExists("live", "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestParseBitrate(t *testing.T) {
+	assert.Equal(t, int64(2500000), parseBitrate("2.5 Mbps"))
+	assert.Equal(t, int64(64000), parseBitrate("64k"))
+	assert.Equal(t, int64(900), parseBitrate("900bps"))
+	assert.Zero(t, parseBitrate(""))
+	assert.Zero(t, parseBitrate("fast"))
+}
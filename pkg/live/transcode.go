@@ -0,0 +1,417 @@
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/qiniu/auth"
+)
+
+// 转码编码格式
+const (
+	TranscodeVideoH264 = "h264"
+	TranscodeVideoH265 = "h265"
+	TranscodeAudioAAC  = "aac"
+	TranscodeAudioOpus = "opus"
+)
+
+const (
+	// MaxTranscodeRenditions 一个转码模板最多的码率档位
+	MaxTranscodeRenditions = 6
+	// MaxTranscodeHeight 转码输出的最大高度（4K）
+	MaxTranscodeHeight = 2160
+	// MinTranscodeVideoBitrate, MaxTranscodeVideoBitrate 视频码率范围（kbps）
+	MinTranscodeVideoBitrate = 100
+	MaxTranscodeVideoBitrate = 20000
+	// MaxTranscodeAudioBitrate 音频码率上限（kbps）
+	MaxTranscodeAudioBitrate = 320
+)
+
+// transcodeNamePattern 模板和档位名称会出现在播放地址中，只允许字母、数字、下划线和短横线
+var transcodeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// TranscodeRendition 码率阶梯中的一个档位
+type TranscodeRendition struct {
+	Name         string `json:"name"`                   // 档位名称，如 720p，播放时流名加 @名称
+	Width        int    `json:"width,omitempty"`        // 宽度，0 表示按原始宽高比缩放
+	Height       int    `json:"height"`                 // 高度
+	VideoBitrate int    `json:"videoBitrate"`           // 视频码率（kbps）
+	AudioBitrate int    `json:"audioBitrate,omitempty"` // 音频码率（kbps），0 使用默认值
+	FrameRate    int    `json:"frameRate,omitempty"`    // 帧率，0 保持原始帧率
+}
+
+// TranscodeTemplate 转码模板，绑定到下行域名后按码率阶梯输出多路流
+type TranscodeTemplate struct {
+	Name       string               `json:"name"`
+	VideoCodec string               `json:"videoCodec"`           // h264, h265
+	AudioCodec string               `json:"audioCodec,omitempty"` // aac, opus，默认 aac
+	Renditions []TranscodeRendition `json:"renditions"`           // 按分辨率从高到低排列
+	CreatedAt  int64                `json:"createdAt,omitempty"`
+	UpdatedAt  int64                `json:"updatedAt,omitempty"`
+}
+
+// ListTranscodeTemplatesResponse 列举转码模板响应
+type ListTranscodeTemplatesResponse struct {
+	Templates []TranscodeTemplate `json:"templates"`
+}
+
+// PlayDomainTranscodeRequest 下行域名绑定的转码模板
+type PlayDomainTranscodeRequest struct {
+	Templates []string `json:"templates"`
+}
+
+// PlayDomainTranscodeResponse 下行域名的转码配置
+type PlayDomainTranscodeResponse struct {
+	Domain    string   `json:"domain"`
+	Templates []string `json:"templates"`
+}
+
+// Validate 检查转码模板，码率阶梯须按分辨率从高到低排列，码率不能随分辨率升高而降低
+func (t *TranscodeTemplate) Validate() error {
+	if !transcodeNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("template name must be 1-32 letters, digits, '_' or '-'")
+	}
+	if t.VideoCodec != TranscodeVideoH264 && t.VideoCodec != TranscodeVideoH265 {
+		return fmt.Errorf("unsupported video codec: %s", t.VideoCodec)
+	}
+	if t.AudioCodec != "" && t.AudioCodec != TranscodeAudioAAC && t.AudioCodec != TranscodeAudioOpus {
+		return fmt.Errorf("unsupported audio codec: %s", t.AudioCodec)
+	}
+	if len(t.Renditions) == 0 || len(t.Renditions) > MaxTranscodeRenditions {
+		return fmt.Errorf("renditions must have 1 to %d entries", MaxTranscodeRenditions)
+	}
+	names := map[string]bool{}
+	for i, r := range t.Renditions {
+		if !transcodeNamePattern.MatchString(r.Name) {
+			return fmt.Errorf("rendition name must be 1-32 letters, digits, '_' or '-'")
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rendition name: %s", r.Name)
+		}
+		names[r.Name] = true
+		if r.Height <= 0 || r.Height > MaxTranscodeHeight || r.Height%2 != 0 || r.Width < 0 || r.Width%2 != 0 {
+			return fmt.Errorf("rendition %s: width and height must be even, height at most %d", r.Name, MaxTranscodeHeight)
+		}
+		if r.VideoBitrate < MinTranscodeVideoBitrate || r.VideoBitrate > MaxTranscodeVideoBitrate {
+			return fmt.Errorf("rendition %s: videoBitrate must be between %d and %d kbps", r.Name, MinTranscodeVideoBitrate, MaxTranscodeVideoBitrate)
+		}
+		if r.AudioBitrate < 0 || r.AudioBitrate > MaxTranscodeAudioBitrate {
+			return fmt.Errorf("rendition %s: audioBitrate must be at most %d kbps", r.Name, MaxTranscodeAudioBitrate)
+		}
+		if r.FrameRate < 0 || r.FrameRate > 60 {
+			return fmt.Errorf("rendition %s: frameRate must be at most 60", r.Name)
+		}
+		if i > 0 {
+			prev := t.Renditions[i-1]
+			if r.Height > prev.Height || r.VideoBitrate > prev.VideoBitrate {
+				return fmt.Errorf("renditions must be ordered from highest to lowest resolution and bitrate")
+			}
+		}
+	}
+	return nil
+}
+
+// TranscodeStreamKey 转码档位的流名，用于拼接多码率播放地址
+func TranscodeStreamKey(streamKey, rendition string) string {
+	return streamKey + "@" + rendition
+}
+
+// CreateTranscodeTemplate 创建转码模板
+// bucketName: 直播空间名称
+func (c *BucketClient) CreateTranscodeTemplate(bucketName string, req *TranscodeTemplate, opts ...CallOption) (*TranscodeTemplate, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "POST"
+	rawQuery := "transcodeTemplate"
+
+	// 构建请求 body
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:      method,
+		Path:        path,
+		RawQuery:    rawQuery,
+		Host:        host,
+		ContentType: "application/json",
+		Body:        bodyBytes,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result TranscodeTemplate
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// ListTranscodeTemplates 列举空间的转码模板
+// bucketName: 直播空间名称
+func (c *BucketClient) ListTranscodeTemplates(bucketName string, opts ...CallOption) (*ListTranscodeTemplatesResponse, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "GET"
+	rawQuery := "transcodeTemplates"
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result ListTranscodeTemplatesResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
+
+// DeleteTranscodeTemplate 删除转码模板，仍绑定在下行域名上的模板需先解绑
+// bucketName: 直播空间名称
+// name: 模板名称
+func (c *BucketClient) DeleteTranscodeTemplate(bucketName, name string, opts ...CallOption) error {
+	if bucketName == "" {
+		return fmt.Errorf("bucket name cannot be empty")
+	}
+	if name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "DELETE"
+	rawQuery := fmt.Sprintf("transcodeTemplate&name=%s", url.QueryEscape(name))
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:   method,
+		Path:     path,
+		RawQuery: rawQuery,
+		Host:     host,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp, respBody)
+	}
+
+	return nil
+}
+
+// SetPlayDomainTranscodeTemplates 设置下行域名绑定的转码模板，覆盖原有绑定；
+// 绑定后播放 流名@档位名 即可拉取对应码率的流
+// bucketName: 直播空间名称
+// domain: 下行域名
+// templates: 模板名称，为空时解绑全部模板
+func (c *BucketClient) SetPlayDomainTranscodeTemplates(bucketName, domain string, templates []string, opts ...CallOption) (*PlayDomainTranscodeResponse, error) {
+	return recordDomainChange(c, domainChange{operation: ChangeSetPlayDomainTranscode, bucket: bucketName, domain: domain, request: &PlayDomainTranscodeRequest{Templates: templates}}, opts, func() (*PlayDomainTranscodeResponse, error) {
+		return c.setPlayDomainTranscodeTemplates(bucketName, domain, templates, opts...)
+	})
+}
+
+// setPlayDomainTranscodeTemplates SetPlayDomainTranscodeTemplates 的实现，不记录变更
+func (c *BucketClient) setPlayDomainTranscodeTemplates(bucketName, domain string, templates []string, opts ...CallOption) (*PlayDomainTranscodeResponse, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	if domain == "" {
+		return nil, fmt.Errorf("domain cannot be empty")
+	}
+	req := &PlayDomainTranscodeRequest{Templates: []string{}}
+	for _, name := range templates {
+		if !transcodeNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid template name: %q", name)
+		}
+		req.Templates = append(req.Templates, name)
+	}
+
+	host := fmt.Sprintf("%s.%s", bucketName, c.baseHost)
+	path := "/"
+	method := "PUT"
+	rawQuery := fmt.Sprintf("domainTranscode&name=%s", url.QueryEscape(domain))
+
+	// 构建请求 body
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 生成鉴权 token
+	authReq := auth.QiniuAuthRequest{
+		Method:      method,
+		Path:        path,
+		RawQuery:    rawQuery,
+		Host:        host,
+		ContentType: "application/json",
+		Body:        bodyBytes,
+	}
+
+	token, err := auth.GenerateQiniuToken(c.accessKey, c.secretKey, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("生成鉴权 token 失败: %w", err)
+	}
+
+	// 构建请求 URL
+	url := fmt.Sprintf("https://%s%s?%s", host, path, rawQuery)
+
+	// 创建 HTTP 请求
+	httpReq, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Host", host)
+	httpReq.Header.Set("Authorization", token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// 发送请求
+	resp, err := c.do(httpReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+
+	// 解析响应
+	var result PlayDomainTranscodeResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,127 @@
+package live
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ladderTemplate() TranscodeTemplate {
+	return TranscodeTemplate{
+		Name:       "abr",
+		VideoCodec: TranscodeVideoH264,
+		Renditions: []TranscodeRendition{
+			{Name: "1080p", Height: 1080, VideoBitrate: 4500},
+			{Name: "720p", Width: 1280, Height: 720, VideoBitrate: 2500, AudioBitrate: 128},
+			{Name: "360p", Height: 360, VideoBitrate: 800, FrameRate: 25},
+		},
+	}
+}
+
+func TestTranscodeTemplate_Validate(t *testing.T) {
+	valid := ladderTemplate()
+	require.NoError(t, valid.Validate())
+
+	cases := map[string]func(t *TranscodeTemplate){
+		"name":           func(t *TranscodeTemplate) { t.Name = "a b" },
+		"video codec":    func(t *TranscodeTemplate) { t.VideoCodec = "vp9" },
+		"audio codec":    func(t *TranscodeTemplate) { t.AudioCodec = "mp3" },
+		"no renditions":  func(t *TranscodeTemplate) { t.Renditions = nil },
+		"duplicate":      func(t *TranscodeTemplate) { t.Renditions[1].Name = "1080p" },
+		"odd height":     func(t *TranscodeTemplate) { t.Renditions[2].Height = 361 },
+		"too tall":       func(t *TranscodeTemplate) { t.Renditions[0].Height = 4320 },
+		"bitrate":        func(t *TranscodeTemplate) { t.Renditions[2].VideoBitrate = 50 },
+		"audio bitrate":  func(t *TranscodeTemplate) { t.Renditions[1].AudioBitrate = 512 },
+		"frame rate":     func(t *TranscodeTemplate) { t.Renditions[2].FrameRate = 120 },
+		"ladder order":   func(t *TranscodeTemplate) { t.Renditions[0], t.Renditions[1] = t.Renditions[1], t.Renditions[0] },
+		"bitrate ladder": func(t *TranscodeTemplate) { t.Renditions[2].VideoBitrate = 3000 },
+	}
+	for name, mutate := range cases {
+		tpl := ladderTemplate()
+		mutate(&tpl)
+		assert.Error(t, tpl.Validate(), name)
+	}
+
+	assert.Equal(t, "room-1@720p", TranscodeStreamKey("room-1", "720p"))
+}
+
+func TestBucketClient_TranscodeTemplates(t *testing.T) {
+	var requests []string
+	stored := map[string]TranscodeTemplate{}
+	bindings := map[string][]string{}
+	c := newTestBucketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "live.mls.test", r.Host)
+		requests = append(requests, r.Method+" "+r.URL.RawQuery)
+		name := r.URL.Query().Get("name")
+		switch r.Method {
+		case http.MethodPost:
+			var tpl TranscodeTemplate
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&tpl))
+			tpl.CreatedAt = 1
+			stored[tpl.Name] = tpl
+			json.NewEncoder(w).Encode(tpl)
+		case http.MethodPut:
+			var req PlayDomainTranscodeRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.NotNil(t, req.Templates, "an empty list is sent to detach all templates")
+			bindings[name] = req.Templates
+			json.NewEncoder(w).Encode(PlayDomainTranscodeResponse{Domain: name, Templates: req.Templates})
+		case http.MethodDelete:
+			if _, ok := stored[name]; !ok {
+				w.WriteHeader(qiniuStatusNotFound)
+				w.Write([]byte(`{"error":"template not found"}`))
+				return
+			}
+			delete(stored, name)
+		default:
+			resp := ListTranscodeTemplatesResponse{}
+			for _, tpl := range stored {
+				resp.Templates = append(resp.Templates, tpl)
+			}
+			json.NewEncoder(w).Encode(resp)
+		}
+	})
+
+	tpl := ladderTemplate()
+	created, err := c.CreateTranscodeTemplate("live", &tpl)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.CreatedAt)
+	assert.Len(t, created.Renditions, 3)
+
+	list, err := c.ListTranscodeTemplates("live")
+	require.NoError(t, err)
+	require.Len(t, list.Templates, 1)
+	assert.Equal(t, "720p", list.Templates[0].Renditions[1].Name)
+
+	bound, err := c.SetPlayDomainTranscodeTemplates("live", "play.example.com", []string{"abr"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abr"}, bound.Templates)
+
+	bound, err = c.SetPlayDomainTranscodeTemplates("live", "play.example.com", nil)
+	require.NoError(t, err)
+	assert.Empty(t, bound.Templates)
+	assert.Equal(t, []string{}, bindings["play.example.com"])
+
+	require.NoError(t, c.DeleteTranscodeTemplate("live", "abr"))
+	assert.ErrorIs(t, c.DeleteTranscodeTemplate("live", "abr"), ErrNotFound)
+
+	assert.Equal(t, []string{
+		"POST transcodeTemplate",
+		"GET transcodeTemplates",
+		"PUT domainTranscode&name=play.example.com",
+		"PUT domainTranscode&name=play.example.com",
+		"DELETE transcodeTemplate&name=abr",
+		"DELETE transcodeTemplate&name=abr",
+	}, requests)
+
+	_, err = c.CreateTranscodeTemplate("live", &TranscodeTemplate{Name: "bad"})
+	assert.Error(t, err)
+	_, err = c.SetPlayDomainTranscodeTemplates("live", "play.example.com", []string{"a/b"})
+	assert.Error(t, err)
+	_, err = c.SetPlayDomainTranscodeTemplates("live", "", nil)
+	assert.Error(t, err)
+	assert.Len(t, requests, 6, "invalid requests are not sent")
+}
@@ -0,0 +1,405 @@
+// Package livecaption 直播实时字幕：拉取直播流音频送入 ASR，保存最近的字幕并推送给订阅者。
+// 直播 API 客户端在 live 包，WebVTT 和 WebSocket 输出在 handler 层
+package livecaption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/recognizer"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SampleRate 送入 ASR 的音频采样率（16kHz 单声道 PCM16）
+	SampleRate = 16000
+	// captionChunkSize 每次发送给 ASR 的音频大小（100ms）
+	captionChunkSize = SampleRate * 2 / 10
+	// captionBacklog 保留的历史字幕条数
+	captionBacklog = 200
+	// captionMaxRetries 拉流连续失败的最大次数
+	captionMaxRetries = 5
+	// captionRetryDelay 拉流失败后的重试间隔
+	captionRetryDelay = 3 * time.Second
+)
+
+var (
+	ErrRunning    = errors.New("captions already running for this stream")
+	ErrNotRunning = errors.New("captions not running for this stream")
+)
+
+// Cue 字幕条目，时间为相对字幕开始时刻的毫秒数
+type Cue struct {
+	ID      int    `json:"id"`
+	StartMs int64  `json:"startMs"`
+	EndMs   int64  `json:"endMs"`
+	Text    string `json:"text"`
+	Final   bool   `json:"final"` // false 为识别中的临时结果，只推送不保存
+}
+
+// AudioSource 打开直播流的音频，返回 16kHz 单声道 PCM16 数据
+type AudioSource func(ctx context.Context, sourceURL string) (io.ReadCloser, error)
+
+// FFmpegAudioSource 使用 ffmpeg 拉流并解码音频
+func FFmpegAudioSource(ctx context.Context, sourceURL string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-i", sourceURL,
+		"-vn", "-ac", "1", "-ar", fmt.Sprint(SampleRate), "-f", "s16le", "-")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建 ffmpeg 输出管道失败: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动 ffmpeg 失败: %w", err)
+	}
+	return &ffmpegReader{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+type ffmpegReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *ffmpegReader) Close() error {
+	_ = r.ReadCloser.Close()
+	if r.cmd.Process != nil {
+		_ = r.cmd.Process.Kill()
+	}
+	return r.cmd.Wait()
+}
+
+// Config 字幕任务配置
+type Config struct {
+	Bucket    string
+	Stream    string
+	SourceURL string // 可拉取音频的播放地址（rtmp/flv/hls）
+	Language  string
+	Owner     uint
+	ASR       recognizer.TranscribeService
+	Source    AudioSource // 为空时使用 FFmpegAudioSource
+}
+
+// Session 单路直播流的字幕任务
+type Session struct {
+	Bucket    string    `json:"bucket"`
+	Stream    string    `json:"stream"`
+	SourceURL string    `json:"sourceUrl"`
+	Language  string    `json:"language"`
+	Owner     uint      `json:"owner"`
+	StartedAt time.Time `json:"startedAt"`
+
+	asr    recognizer.TranscribeService
+	source AudioSource
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu          sync.RWMutex
+	cues        []Cue
+	nextID      int
+	partialFrom time.Time
+	subscribers map[chan Cue]struct{}
+	err         error
+}
+
+// Status 字幕任务状态
+type Status struct {
+	*Session
+	Running  bool   `json:"running"`
+	CueCount int    `json:"cueCount"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Manager 管理所有直播流的字幕任务
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+var defaultManager = NewManager()
+
+// DefaultManager 返回进程内默认的字幕管理器
+func DefaultManager() *Manager {
+	return defaultManager
+}
+
+// NewManager 创建字幕管理器
+func NewManager() *Manager {
+	return &Manager{sessions: make(map[string]*Session)}
+}
+
+func captionKey(bucket, stream string) string {
+	return bucket + "/" + stream
+}
+
+// Start 为直播流启动字幕任务
+func (m *Manager) Start(cfg Config) (*Session, error) {
+	if cfg.Bucket == "" || cfg.Stream == "" {
+		return nil, fmt.Errorf("bucket and stream cannot be empty")
+	}
+	if cfg.SourceURL == "" {
+		return nil, fmt.Errorf("source url cannot be empty")
+	}
+	if cfg.ASR == nil {
+		return nil, fmt.Errorf("asr service cannot be nil")
+	}
+	if cfg.Source == nil {
+		cfg.Source = FFmpegAudioSource
+	}
+
+	key := captionKey(cfg.Bucket, cfg.Stream)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[key]; ok && s.Running() {
+		return nil, ErrRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		Bucket:      cfg.Bucket,
+		Stream:      cfg.Stream,
+		SourceURL:   cfg.SourceURL,
+		Language:    cfg.Language,
+		Owner:       cfg.Owner,
+		StartedAt:   time.Now(),
+		asr:         cfg.ASR,
+		source:      cfg.Source,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		subscribers: make(map[chan Cue]struct{}),
+	}
+	s.asr.Init(s.onResult, s.onError)
+	if err := s.asr.ConnAndReceive("live_captions_" + key); err != nil {
+		cancel()
+		return nil, fmt.Errorf("ASR连接失败: %w", err)
+	}
+
+	m.sessions[key] = s
+	go s.run(ctx)
+	return s, nil
+}
+
+// Get 获取直播流的字幕任务，已结束的任务仍可读取历史字幕
+func (m *Manager) Get(bucket, stream string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[captionKey(bucket, stream)]
+	return s, ok
+}
+
+// Stop 停止直播流的字幕任务
+func (m *Manager) Stop(bucket, stream string) error {
+	m.mu.Lock()
+	s, ok := m.sessions[captionKey(bucket, stream)]
+	delete(m.sessions, captionKey(bucket, stream))
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotRunning
+	}
+	s.stop()
+	return nil
+}
+
+// Running 字幕任务是否仍在运行
+func (s *Session) Running() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Status 返回字幕任务状态
+func (s *Session) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := Status{Session: s, Running: s.Running(), CueCount: len(s.cues)}
+	if s.err != nil {
+		status.Error = s.err.Error()
+	}
+	return status
+}
+
+// Cues 返回 ID 大于 since 的已确认字幕
+func (s *Session) Cues(since int) []Cue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var cues []Cue
+	for _, cue := range s.cues {
+		if cue.ID > since {
+			cues = append(cues, cue)
+		}
+	}
+	return cues
+}
+
+// Subscribe 订阅字幕推送（包括临时结果），任务结束时通道关闭
+func (s *Session) Subscribe() (<-chan Cue, func()) {
+	ch := make(chan Cue, 32)
+	s.mu.Lock()
+	if !s.Running() {
+		s.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// WebVTT 将 ID 大于 since 的字幕渲染为 WebVTT 片段
+func (s *Session) WebVTT(since int) string {
+	return RenderWebVTT(s.Cues(since))
+}
+
+// RenderWebVTT 渲染 WebVTT 文本
+func RenderWebVTT(cues []Cue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", cue.ID, formatVTTTime(cue.StartMs), formatVTTTime(cue.EndMs), cue.Text)
+	}
+	return b.String()
+}
+
+func formatVTTTime(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// run 拉流并把音频送入 ASR，拉流中断时有限次重试
+func (s *Session) run(ctx context.Context) {
+	defer s.finish()
+
+	failures := 0
+	for ctx.Err() == nil {
+		err := s.pump(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			failures = 0
+		} else {
+			failures++
+			logrus.WithError(err).WithField("stream", captionKey(s.Bucket, s.Stream)).Warn("Live caption audio source failed")
+			if failures >= captionMaxRetries {
+				s.setError(err)
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(captionRetryDelay):
+		}
+	}
+}
+
+// pump 读取一次拉流的全部音频，正常结束（流断开）返回 nil
+func (s *Session) pump(ctx context.Context) error {
+	reader, err := s.source(ctx, s.SourceURL)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	buf := make([]byte, captionChunkSize)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if n > 0 {
+			if sendErr := s.asr.SendAudioBytes(buf[:n]); sendErr != nil {
+				return fmt.Errorf("发送音频失败: %w", sendErr)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Session) onResult(text string, isLast bool, duration time.Duration, uuid string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if s.partialFrom.IsZero() {
+		s.partialFrom = now
+	}
+	cue := Cue{
+		ID:      s.nextID + 1,
+		StartMs: s.partialFrom.Sub(s.StartedAt).Milliseconds(),
+		EndMs:   now.Sub(s.StartedAt).Milliseconds(),
+		Text:    text,
+		Final:   isLast,
+	}
+	if isLast {
+		s.nextID++
+		s.partialFrom = time.Time{}
+		s.cues = append(s.cues, cue)
+		if len(s.cues) > captionBacklog {
+			s.cues = s.cues[len(s.cues)-captionBacklog:]
+		}
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- cue:
+		default:
+			// 慢速订阅者丢弃，下一条字幕会覆盖
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *Session) onError(err error, isFatal bool) {
+	logrus.WithError(err).WithField("stream", captionKey(s.Bucket, s.Stream)).Warn("Live caption ASR error")
+	if isFatal {
+		s.setError(err)
+		s.cancel()
+	}
+}
+
+func (s *Session) setError(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (s *Session) stop() {
+	s.cancel()
+	<-s.done
+}
+
+// finish 关闭 ASR 连接和所有订阅
+func (s *Session) finish() {
+	_ = s.asr.SendEnd()
+	_ = s.asr.StopConn()
+
+	s.mu.Lock()
+	close(s.done)
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package livecaption
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/recognizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCaptionASR struct {
+	mu       sync.Mutex
+	result   recognizer.TranscribeResult
+	received int
+	stopped  bool
+}
+
+func (f *fakeCaptionASR) Init(tr recognizer.TranscribeResult, er recognizer.ProcessError) {
+	f.result = tr
+}
+func (f *fakeCaptionASR) Vendor() string              { return "fake" }
+func (f *fakeCaptionASR) ConnAndReceive(string) error { return nil }
+func (f *fakeCaptionASR) Activity() bool              { return true }
+func (f *fakeCaptionASR) RestartClient()              {}
+func (f *fakeCaptionASR) SendEnd() error              { return nil }
+func (f *fakeCaptionASR) SendAudioBytes(data []byte) error {
+	f.mu.Lock()
+	f.received += len(data)
+	f.mu.Unlock()
+	return nil
+}
+func (f *fakeCaptionASR) StopConn() error {
+	f.mu.Lock()
+	f.stopped = true
+	f.mu.Unlock()
+	return nil
+}
+
+// blockingSource yields some audio then blocks until the session stops
+func blockingSource(ctx context.Context, _ string) (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	go func() {
+		_, _ = w.Write(bytes.Repeat([]byte{0}, captionChunkSize*2))
+		<-ctx.Done()
+		_ = w.Close()
+	}()
+	return r, nil
+}
+
+func TestSession_Cues(t *testing.T) {
+	m := NewManager()
+	asr := &fakeCaptionASR{}
+	s, err := m.Start(Config{Bucket: "b", Stream: "s", SourceURL: "rtmp://example/b/s", ASR: asr, Source: blockingSource})
+	require.NoError(t, err)
+
+	_, err = m.Start(Config{Bucket: "b", Stream: "s", SourceURL: "rtmp://example/b/s", ASR: asr, Source: blockingSource})
+	assert.ErrorIs(t, err, ErrRunning)
+
+	cues, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	asr.result("hello", false, 0, "")
+	asr.result("hello world", true, 0, "")
+	asr.result("second", true, 0, "")
+
+	partial := <-cues
+	assert.False(t, partial.Final)
+	final := <-cues
+	assert.True(t, final.Final)
+	assert.Equal(t, 1, final.ID)
+	assert.Equal(t, "hello world", final.Text)
+
+	assert.Len(t, s.Cues(0), 2)
+	assert.Len(t, s.Cues(1), 1)
+
+	vtt := s.WebVTT(1)
+	assert.Contains(t, vtt, "WEBVTT\n\n2\n")
+	assert.Contains(t, vtt, "second")
+
+	require.NoError(t, m.Stop("b", "s"))
+	assert.False(t, s.Running())
+	asr.mu.Lock()
+	assert.True(t, asr.stopped)
+	asr.mu.Unlock()
+	assert.ErrorIs(t, m.Stop("b", "s"), ErrNotRunning)
+
+	select {
+	case _, ok := <-cues:
+		for ok {
+			_, ok = <-cues
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not closed")
+	}
+}
+
+func TestRenderWebVTT(t *testing.T) {
+	vtt := RenderWebVTT([]Cue{{ID: 3, StartMs: 3723004, EndMs: 3725500, Text: "hi"}})
+	assert.Equal(t, "WEBVTT\n\n3\n01:02:03.004 --> 01:02:05.500\nhi\n\n", vtt)
+}
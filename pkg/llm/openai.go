@@ -57,9 +57,24 @@ type QueryOptions struct {
 
 // ToolCallInfo contains information about a tool call
 type ToolCallInfo struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Arguments string `json:"arguments"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Arguments string    `json:"arguments"`
+	Output    string    `json:"output,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	Duration  int64     `json:"duration"` // execution time in milliseconds
+}
+
+// record stores the outcome of executing the tool call
+func (t *ToolCallInfo) record(started time.Time, output string, err error) {
+	t.StartTime = started
+	t.Duration = time.Since(started).Milliseconds()
+	if err != nil {
+		t.Error = err.Error()
+		return
+	}
+	t.Output = output
 }
 
 // LLMUsageInfo contains comprehensive LLM call information for signal emission
@@ -396,6 +411,7 @@ func (h *LLMHandler) QueryWithOptions(text string, options QueryOptions) (string
 			logger.Info("Tool calls detected", zap.Int("count", len(message.ToolCalls)))
 
 			// Collect tool call information for statistics
+			firstToolCall := len(allToolCalls)
 			for _, toolCall := range message.ToolCalls {
 				allToolCalls = append(allToolCalls, ToolCallInfo{
 					ID:        toolCall.ID,
@@ -410,9 +426,11 @@ func (h *LLMHandler) QueryWithOptions(text string, options QueryOptions) (string
 			// Track which tool calls we've processed
 			processedToolCallIDs := make(map[string]bool)
 
-			for _, toolCall := range message.ToolCalls {
+			for i, toolCall := range message.ToolCalls {
 				// Handle all function calls through the function manager
+				started := time.Now()
 				result, err := h.functionManager.HandleToolCall(toolCall)
+				allToolCalls[firstToolCall+i].record(started, result, err)
 				if err != nil {
 					logger.Error("Failed to handle tool call",
 						zap.String("tool", toolCall.Function.Name),
@@ -789,6 +807,7 @@ func (h *LLMHandler) QueryStream(text string, options QueryOptions, callback fun
 		logger.Info("Tool calls detected in stream", zap.Int("count", len(collectedToolCalls)))
 
 		// Collect tool call information for statistics
+		firstToolCall := len(allToolCalls)
 		for _, toolCall := range collectedToolCalls {
 			allToolCalls = append(allToolCalls, ToolCallInfo{
 				ID:        toolCall.ID,
@@ -805,9 +824,11 @@ func (h *LLMHandler) QueryStream(text string, options QueryOptions, callback fun
 		})
 
 		// Process each tool call
-		for _, toolCall := range collectedToolCalls {
+		for i, toolCall := range collectedToolCalls {
 			// Handle all function calls through the function manager
+			started := time.Now()
 			result, err := h.functionManager.HandleToolCall(toolCall)
+			allToolCalls[firstToolCall+i].record(started, result, err)
 			if err != nil {
 				logger.Error("Failed to handle tool call",
 					zap.String("tool", toolCall.Function.Name),
@@ -1133,6 +1154,13 @@ func (h *LLMHandler) GetMessages() []openai.ChatCompletionMessage {
 	return messages
 }
 
+// AppendMessages appends messages to the conversation history without querying the model
+func (h *LLMHandler) AppendMessages(messages ...openai.ChatCompletionMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.messages = append(h.messages, messages...)
+}
+
 func Float32Ptr(v float32) *float32 {
 	return &v
 }
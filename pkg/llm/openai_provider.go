@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"encoding/json"
+
+	"github.com/sashabaranov/go-openai"
 )
 
 // OpenAIProvider 包装现有的 LLMHandler，实现 LLMProvider 接口
@@ -94,6 +96,15 @@ func (p *OpenAIProvider) SetModel(model string) {
 	p.handler.SetModel(model)
 }
 
+// AppendMessages 追加对话历史（如缓存命中的问答），不调用模型
+func (p *OpenAIProvider) AppendMessages(messages ...Message) {
+	converted := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		converted[i] = openai.ChatCompletionMessage{Role: msg.Role, Content: msg.Content}
+	}
+	p.handler.AppendMessages(converted...)
+}
+
 // GetMessages 获取当前对话历史
 func (p *OpenAIProvider) GetMessages() []Message {
 	openaiMessages := p.handler.GetMessages()
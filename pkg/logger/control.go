@@ -0,0 +1,442 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 可单独调整级别的模块
+const (
+	ModuleAuth      = "auth"
+	ModuleSIP       = "sip"
+	ModuleKnowledge = "knowledge"
+)
+
+// 默认的模块划分，按调用位置的源文件路径匹配
+var defaultModules = map[string][]string{
+	ModuleAuth: {
+		"internal/handler/auth.go", "internal/handler/saml.go", "internal/handler/oauth.go",
+		"internal/handler/login_", "internal/handler/security_policy.go", "pkg/utils/login_security",
+	},
+	ModuleSIP:       {"pkg/sip/", "internal/handler/sip"},
+	ModuleKnowledge: {"pkg/knowledge/", "internal/handler/knowledge"},
+}
+
+// SamplingConfig 高频调试日志采样：每个 Tick 内同一条消息只输出前 First 条，之后每 Thereafter 条输出一条。
+// First 为 0 时不采样
+type SamplingConfig struct {
+	First      int           `json:"first"`
+	Thereafter int           `json:"thereafter"`
+	Tick       time.Duration `json:"tick"`
+}
+
+// Elevation 临时提升日志级别的时间窗口，到期自动恢复
+type Elevation struct {
+	Level   string    `json:"level"`
+	Modules []string  `json:"modules,omitempty"` // 为空表示所有模块
+	Until   time.Time `json:"until"`
+}
+
+// ControlState 当前的日志控制状态
+type ControlState struct {
+	Level        string            `json:"level"`
+	Modules      map[string]string `json:"modules"`
+	Sampling     SamplingConfig    `json:"sampling"`
+	Elevation    *Elevation        `json:"elevation,omitempty"`
+	KnownModules []string          `json:"knownModules"`
+}
+
+type elevation struct {
+	level   zapcore.Level
+	modules map[string]bool
+	until   time.Time
+}
+
+type sampleCounter struct {
+	tick  int64
+	count int
+}
+
+// LogControl 运行时日志控制：全局级别、模块级别覆盖、调试日志采样和临时提升。
+// 修改只在当前进程内生效，重启后恢复为配置文件中的级别
+type LogControl struct {
+	mu        sync.RWMutex
+	base      zapcore.Level
+	overrides map[string]zapcore.Level
+	paths     map[string][]string
+	sampling  SamplingConfig
+	elevated  *elevation
+	timer     *time.Timer
+	// floor 所有生效级别中最低的一个，低于它的日志直接丢弃，不计算调用位置
+	floor zap.AtomicLevel
+
+	sampleMu sync.Mutex
+	samples  map[string]*sampleCounter
+
+	logrusLoggers []*logrus.Logger
+}
+
+// Control 全局日志控制，Init 时按配置的级别初始化
+var Control = NewLogControl(zapcore.InfoLevel)
+
+// NewLogControl 创建日志控制
+func NewLogControl(base zapcore.Level) *LogControl {
+	c := &LogControl{
+		base:      base,
+		overrides: make(map[string]zapcore.Level),
+		paths:     make(map[string][]string),
+		floor:     zap.NewAtomicLevelAt(base),
+		samples:   make(map[string]*sampleCounter),
+	}
+	for module, paths := range defaultModules {
+		c.paths[module] = paths
+	}
+	return c
+}
+
+// RegisterModule 增加模块或为模块追加源文件路径片段
+func (c *LogControl) RegisterModule(module string, pathFragments ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths[module] = append(c.paths[module], pathFragments...)
+}
+
+// ParseLevel 解析 debug/info/warn/error 等级别名称
+func ParseLevel(level string) (zapcore.Level, error) {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(strings.ToLower(strings.TrimSpace(level)))); err != nil {
+		return l, fmt.Errorf("invalid log level %q", level)
+	}
+	return l, nil
+}
+
+// SetLevel 设置全局级别
+func (c *LogControl) SetLevel(level zapcore.Level) {
+	c.mu.Lock()
+	c.base = level
+	c.mu.Unlock()
+	c.refresh()
+}
+
+// SetModuleLevel 设置模块级别，clear 为 true 时恢复使用全局级别
+func (c *LogControl) SetModuleLevel(module string, level zapcore.Level, clear bool) error {
+	c.mu.Lock()
+	if _, ok := c.paths[module]; !ok {
+		c.mu.Unlock()
+		return fmt.Errorf("unknown log module %q", module)
+	}
+	if clear {
+		delete(c.overrides, module)
+	} else {
+		c.overrides[module] = level
+	}
+	c.mu.Unlock()
+	c.refresh()
+	return nil
+}
+
+// SetSampling 设置调试日志采样
+func (c *LogControl) SetSampling(cfg SamplingConfig) {
+	if cfg.Tick <= 0 {
+		cfg.Tick = time.Second
+	}
+	if cfg.Thereafter < 0 {
+		cfg.Thereafter = 0
+	}
+	c.mu.Lock()
+	c.sampling = cfg
+	c.mu.Unlock()
+	c.sampleMu.Lock()
+	c.samples = make(map[string]*sampleCounter)
+	c.sampleMu.Unlock()
+}
+
+// Elevate 在 d 时间内把模块（为空时所有模块）的级别提升到 level，覆盖正在进行的提升
+func (c *LogControl) Elevate(level zapcore.Level, modules []string, d time.Duration) (*Elevation, error) {
+	e := &elevation{level: level, until: time.Now().Add(d)}
+	if len(modules) > 0 {
+		e.modules = make(map[string]bool, len(modules))
+	}
+	c.mu.Lock()
+	for _, m := range modules {
+		if _, ok := c.paths[m]; !ok {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("unknown log module %q", m)
+		}
+		e.modules[m] = true
+	}
+	c.elevated = e
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.timer = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		expired := c.elevated == e
+		if expired {
+			c.elevated = nil
+		}
+		c.mu.Unlock()
+		if expired {
+			c.refresh()
+			if Lg != nil {
+				Lg.Info("Elevated logging window ended", zap.String("level", level.String()), zap.Strings("modules", modules))
+			}
+		}
+	})
+	c.mu.Unlock()
+	c.refresh()
+	return &Elevation{Level: level.String(), Modules: modules, Until: e.until}, nil
+}
+
+// CancelElevation 提前结束临时提升
+func (c *LogControl) CancelElevation() {
+	c.mu.Lock()
+	c.elevated = nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+	c.refresh()
+}
+
+// State 当前状态
+func (c *LogControl) State() ControlState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state := ControlState{
+		Level:    c.base.String(),
+		Modules:  make(map[string]string, len(c.overrides)),
+		Sampling: c.sampling,
+	}
+	for m, l := range c.overrides {
+		state.Modules[m] = l.String()
+	}
+	for m := range c.paths {
+		state.KnownModules = append(state.KnownModules, m)
+	}
+	sort.Strings(state.KnownModules)
+	if e := c.elevated; e != nil && time.Now().Before(e.until) {
+		state.Elevation = &Elevation{Level: e.level.String(), Until: e.until}
+		for m := range e.modules {
+			state.Elevation.Modules = append(state.Elevation.Modules, m)
+		}
+		sort.Strings(state.Elevation.Modules)
+	}
+	return state
+}
+
+// ModuleOf 源文件所属的模块，不属于任何模块时返回空
+func (c *LogControl) ModuleOf(file string) string {
+	file = strings.ReplaceAll(file, "\\", "/")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for module, fragments := range c.paths {
+		for _, f := range fragments {
+			if strings.Contains(file, f) {
+				return module
+			}
+		}
+	}
+	return ""
+}
+
+// LevelFor 模块在 now 时生效的级别
+func (c *LogControl) LevelFor(module string, now time.Time) zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.levelForLocked(module, now)
+}
+
+func (c *LogControl) levelForLocked(module string, now time.Time) zapcore.Level {
+	level := c.base
+	if l, ok := c.overrides[module]; ok && module != "" {
+		level = l
+	}
+	if e := c.elevated; e != nil && now.Before(e.until) && e.level < level && (e.modules == nil || e.modules[module]) {
+		level = e.level
+	}
+	return level
+}
+
+// Allow 按调用位置的源文件判断日志是否输出，并对调试日志采样
+func (c *LogControl) Allow(level zapcore.Level, file, msg string, now time.Time) bool {
+	if !c.floor.Enabled(level) {
+		return false
+	}
+	if level < c.LevelFor(c.ModuleOf(file), now) {
+		return false
+	}
+	return level > zapcore.DebugLevel || c.sample(msg, now)
+}
+
+func (c *LogControl) sample(msg string, now time.Time) bool {
+	c.mu.RLock()
+	cfg := c.sampling
+	c.mu.RUnlock()
+	if cfg.First <= 0 {
+		return true
+	}
+	tick := now.UnixNano() / int64(cfg.Tick)
+	c.sampleMu.Lock()
+	defer c.sampleMu.Unlock()
+	counter, ok := c.samples[msg]
+	if !ok || counter.tick != tick {
+		if len(c.samples) > 10000 {
+			c.samples = make(map[string]*sampleCounter)
+		}
+		counter = &sampleCounter{tick: tick}
+		c.samples[msg] = counter
+	}
+	counter.count++
+	if counter.count <= cfg.First {
+		return true
+	}
+	return cfg.Thereafter > 0 && (counter.count-cfg.First)%cfg.Thereafter == 0
+}
+
+// refresh 重新计算最低级别并同步到 logrus
+func (c *LogControl) refresh() {
+	c.mu.RLock()
+	floor := c.base
+	for _, l := range c.overrides {
+		if l < floor {
+			floor = l
+		}
+	}
+	if e := c.elevated; e != nil && time.Now().Before(e.until) && e.level < floor {
+		floor = e.level
+	}
+	loggers := c.logrusLoggers
+	c.mu.RUnlock()
+	c.floor.SetLevel(floor)
+	for _, l := range loggers {
+		l.SetLevel(logrusLevel(floor))
+	}
+}
+
+// Wrap 用日志控制包装 core，被包装的 core 应允许所有级别
+func (c *LogControl) Wrap(core zapcore.Core) zapcore.Core {
+	return &controlCore{Core: core, ctl: c}
+}
+
+// controlCore 先按最低级别过滤，写入时再按调用位置所属模块过滤；
+// zap 在 Check 之后才计算调用位置，所以模块级别只能在 Write 中判断
+type controlCore struct {
+	zapcore.Core
+	ctl *LogControl
+}
+
+func (cc *controlCore) Enabled(level zapcore.Level) bool {
+	return cc.ctl.floor.Enabled(level)
+}
+
+func (cc *controlCore) With(fields []zapcore.Field) zapcore.Core {
+	return &controlCore{Core: cc.Core.With(fields), ctl: cc.ctl}
+}
+
+func (cc *controlCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !cc.ctl.floor.Enabled(ent.Level) {
+		return ce
+	}
+	return ce.AddCore(ent, cc)
+}
+
+func (cc *controlCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !cc.ctl.Allow(ent.Level, ent.Caller.File, ent.Message, ent.Time) {
+		return nil
+	}
+	return cc.Core.Write(ent, fields)
+}
+
+// InstallLogrus 让 logrus 日志（SIP 等模块使用）也受日志控制
+func (c *LogControl) InstallLogrus(l *logrus.Logger) {
+	c.mu.Lock()
+	for _, existing := range c.logrusLoggers {
+		if existing == l {
+			c.mu.Unlock()
+			return
+		}
+	}
+	c.logrusLoggers = append(c.logrusLoggers, l)
+	c.mu.Unlock()
+	l.SetFormatter(&logrusFormatter{Formatter: l.Formatter, ctl: c})
+	c.refresh()
+}
+
+// logrusFormatter 被过滤的日志格式化为空内容，不会写出
+type logrusFormatter struct {
+	logrus.Formatter
+	ctl *LogControl
+}
+
+func (f *logrusFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	file := ""
+	if entry.Caller != nil {
+		file = entry.Caller.File
+	} else {
+		file = logrusCallerFile()
+	}
+	if !f.ctl.Allow(zapLevel(entry.Level), file, entry.Message, entry.Time) {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+// logrusCallerFile 调用 logrus 的源文件
+func logrusCallerFile() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.File, "sirupsen/logrus") && !strings.HasSuffix(frame.File, "pkg/logger/control.go") {
+			return frame.File
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func logrusLevel(l zapcore.Level) logrus.Level {
+	switch {
+	case l <= zapcore.DebugLevel:
+		return logrus.DebugLevel
+	case l == zapcore.InfoLevel:
+		return logrus.InfoLevel
+	case l == zapcore.WarnLevel:
+		return logrus.WarnLevel
+	case l == zapcore.ErrorLevel:
+		return logrus.ErrorLevel
+	case l == zapcore.FatalLevel:
+		return logrus.FatalLevel
+	default:
+		return logrus.PanicLevel
+	}
+}
+
+func zapLevel(l logrus.Level) zapcore.Level {
+	switch l {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return zapcore.DebugLevel
+	case logrus.InfoLevel:
+		return zapcore.InfoLevel
+	case logrus.WarnLevel:
+		return zapcore.WarnLevel
+	case logrus.ErrorLevel:
+		return zapcore.ErrorLevel
+	case logrus.FatalLevel:
+		return zapcore.FatalLevel
+	default:
+		return zapcore.PanicLevel
+	}
+}
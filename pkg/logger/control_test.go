@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogControl_ModuleLevels(t *testing.T) {
+	ctl := NewLogControl(zapcore.WarnLevel)
+	now := time.Now()
+
+	if got := ctl.ModuleOf("/src/LingEcho/internal/handler/auth.go"); got != ModuleAuth {
+		t.Fatalf("ModuleOf(auth.go) = %q", got)
+	}
+	if got := ctl.ModuleOf("/src/LingEcho/pkg/sip/sip_server.go"); got != ModuleSIP {
+		t.Fatalf("ModuleOf(sip_server.go) = %q", got)
+	}
+	if got := ctl.ModuleOf("/src/LingEcho/internal/handler/assistants.go"); got != "" {
+		t.Fatalf("ModuleOf(assistants.go) = %q", got)
+	}
+
+	if err := ctl.SetModuleLevel(ModuleAuth, zapcore.DebugLevel, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.SetModuleLevel("billing", zapcore.DebugLevel, false); err == nil {
+		t.Fatal("expected error for unknown module")
+	}
+	if !ctl.Allow(zapcore.DebugLevel, "internal/handler/auth.go", "m", now) {
+		t.Fatal("auth debug should be logged")
+	}
+	if ctl.Allow(zapcore.InfoLevel, "pkg/sip/sip_server.go", "m", now) {
+		t.Fatal("sip info should follow the global warn level")
+	}
+
+	if err := ctl.SetModuleLevel(ModuleAuth, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if ctl.Allow(zapcore.InfoLevel, "internal/handler/auth.go", "m", now) {
+		t.Fatal("cleared override should fall back to the global level")
+	}
+	if ctl.floor.Level() != zapcore.WarnLevel {
+		t.Fatalf("floor = %v, want warn", ctl.floor.Level())
+	}
+}
+
+func TestLogControl_ElevationReverts(t *testing.T) {
+	ctl := NewLogControl(zapcore.InfoLevel)
+	if _, err := ctl.Elevate(zapcore.DebugLevel, []string{ModuleSIP}, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if !ctl.Allow(zapcore.DebugLevel, "pkg/sip/ai_checkpoint.go", "m", now) {
+		t.Fatal("sip debug should be logged while elevated")
+	}
+	if ctl.Allow(zapcore.DebugLevel, "internal/handler/auth.go", "m", now) {
+		t.Fatal("elevation is limited to the given modules")
+	}
+	if ctl.State().Elevation == nil {
+		t.Fatal("state should report the elevation")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for ctl.floor.Level() != zapcore.InfoLevel && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ctl.floor.Level() != zapcore.InfoLevel {
+		t.Fatal("elevation did not revert")
+	}
+	if ctl.Allow(zapcore.DebugLevel, "pkg/sip/ai_checkpoint.go", "m", time.Now()) {
+		t.Fatal("sip debug should be dropped after the window")
+	}
+}
+
+func TestLogControl_SamplingDebugOnly(t *testing.T) {
+	ctl := NewLogControl(zapcore.DebugLevel)
+	ctl.SetSampling(SamplingConfig{First: 2, Thereafter: 3, Tick: time.Hour})
+	now := time.Now()
+
+	var logged int
+	for i := 0; i < 11; i++ {
+		if ctl.Allow(zapcore.DebugLevel, "pkg/sip/rtp.go", "rtp packet", now) {
+			logged++
+		}
+	}
+	// first 2, then the 3rd, 6th and 9th of the remaining 9
+	if logged != 5 {
+		t.Fatalf("logged %d debug entries, want 5", logged)
+	}
+	for i := 0; i < 5; i++ {
+		if !ctl.Allow(zapcore.InfoLevel, "pkg/sip/rtp.go", "rtp packet", now) {
+			t.Fatal("info entries are not sampled")
+		}
+	}
+}
+
+func TestLogControl_WrapAndLogrus(t *testing.T) {
+	ctl := NewLogControl(zapcore.InfoLevel)
+	core, logs := observer.New(zapcore.DebugLevel)
+	lg := zap.New(ctl.Wrap(core), zap.AddCaller())
+
+	lg.Debug("hidden")
+	if err := ctl.SetModuleLevel(ModuleSIP, zapcore.DebugLevel, false); err != nil {
+		t.Fatal(err)
+	}
+	// This file belongs to no module, so debug stays off
+	lg.Debug("still hidden")
+	lg.Info("shown")
+	if logs.Len() != 1 || logs.All()[0].Message != "shown" {
+		t.Fatalf("unexpected entries: %+v", logs.All())
+	}
+
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&buf)
+	ctl.InstallLogrus(l)
+	if l.GetLevel() != logrus.DebugLevel {
+		t.Fatalf("logrus level = %v, want debug (lowest module level)", l.GetLevel())
+	}
+	l.Debug("logrus debug")
+	l.Info("logrus info")
+	if strings.Contains(buf.String(), "logrus debug") || !strings.Contains(buf.String(), "logrus info") {
+		t.Fatalf("unexpected logrus output: %s", buf.String())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/natefinch/lumberjack"
+	"github.com/sirupsen/logrus"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -32,6 +33,10 @@ func Init(cfg *LogConfig, mode string) (err error) {
 	if err != nil {
 		return
 	}
+	// 级别由 Control 统一过滤，内部的 core 允许所有级别，运行时才能调低
+	Control.SetLevel(*l)
+	l = new(zapcore.Level)
+	*l = zapcore.DebugLevel
 	var core zapcore.Core
 	if mode == "dev" || mode == "development" {
 		// 进入开发模式，日志输出到终端，启用带色彩的编码器
@@ -85,7 +90,8 @@ func Init(cfg *LogConfig, mode string) (err error) {
 	}
 	// 复习回顾：日志默认输出到app.log，如何将err日志单独在 app.err.log 记录一份
 
-	Lg = zap.New(core, zap.AddCaller()) // zap.AddCaller() 添加调用栈信息
+	Lg = zap.New(Control.Wrap(core), zap.AddCaller()) // zap.AddCaller() 添加调用栈信息
+	Control.InstallLogrus(logrus.StandardLogger())
 
 	zap.ReplaceGlobals(Lg) // 替换zap包全局的logger
 
@@ -0,0 +1,344 @@
+// Package mediastream lets external services tap the media of live calls: they
+// receive the caller's decoded audio and may inject audio back into the call.
+// Streams are authorized with short-lived single-use tokens bound to one call,
+// and both directions are bounded so a slow consumer never stalls the call.
+//
+// Audio frames are 16-bit little-endian mono PCM at SampleRate, normally 20ms each.
+package mediastream
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// SampleRate of the frames exchanged with external services
+	SampleRate = 8000
+	// FrameBytes size of a 20ms frame
+	FrameBytes = SampleRate / 50 * 2
+)
+
+var (
+	ErrCallNotFound   = errors.New("call is not active")
+	ErrTooManyStreams = errors.New("too many media streams on the call")
+	ErrInvalidToken   = errors.New("invalid or expired media stream token")
+	ErrReadOnly       = errors.New("media stream does not allow injecting audio")
+	ErrClosed         = errors.New("media stream closed")
+)
+
+// Mode of a stream
+type Mode string
+
+const (
+	// ModeListen receives the call audio only
+	ModeListen Mode = "listen"
+	// ModeDuplex receives the call audio and injects audio into the call
+	ModeDuplex Mode = "duplex"
+)
+
+// Valid reports whether m is a known mode
+func (m Mode) Valid() bool {
+	return m == ModeListen || m == ModeDuplex
+}
+
+// Config limits of the hub. Zero values use the defaults.
+type Config struct {
+	MaxStreamsPerCall int // Default 4
+	OutboundBuffer    int // Frames buffered per stream before frames are dropped, default 50 (1s)
+	InjectBuffer      int // Frames buffered per call before injecting blocks, default 25 (500ms)
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxStreamsPerCall <= 0 {
+		c.MaxStreamsPerCall = 4
+	}
+	if c.OutboundBuffer <= 0 {
+		c.OutboundBuffer = 50
+	}
+	if c.InjectBuffer <= 0 {
+		c.InjectBuffer = 25
+	}
+	return c
+}
+
+// Grant what a token allows
+type Grant struct {
+	CallID    string    `json:"callId"`
+	Mode      Mode      `json:"mode"`
+	Subject   string    `json:"subject"` // Who requested the token, for auditing
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CallInfo an active call and its streams
+type CallInfo struct {
+	CallID  string    `json:"callId"`
+	Since   time.Time `json:"since"`
+	Streams int       `json:"streams"`
+}
+
+// Hub registry of the calls available for streaming
+type Hub struct {
+	cfg    Config
+	mu     sync.Mutex
+	calls  map[string]*Call
+	grants map[string]Grant
+}
+
+var defaultHub = New(Config{})
+
+// Default returns the process-wide hub
+func Default() *Hub {
+	return defaultHub
+}
+
+// New creates an empty hub
+func New(cfg Config) *Hub {
+	return &Hub{
+		cfg:    cfg.withDefaults(),
+		calls:  make(map[string]*Call),
+		grants: make(map[string]Grant),
+	}
+}
+
+// OpenCall makes a call available for streaming. The media side publishes the
+// call audio to it, plays what it reads from Injected and closes it when the call ends.
+func (h *Hub) OpenCall(callID string) *Call {
+	call := &Call{
+		id:      callID,
+		hub:     h,
+		since:   time.Now(),
+		streams: make(map[*Stream]struct{}),
+		inject:  make(chan []byte, h.cfg.InjectBuffer),
+		closed:  make(chan struct{}),
+	}
+	h.mu.Lock()
+	previous := h.calls[callID]
+	h.calls[callID] = call
+	h.mu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+	return call
+}
+
+// Calls lists the active calls
+func (h *Hub) Calls() []CallInfo {
+	h.mu.Lock()
+	calls := make([]*Call, 0, len(h.calls))
+	for _, call := range h.calls {
+		calls = append(calls, call)
+	}
+	h.mu.Unlock()
+
+	infos := make([]CallInfo, 0, len(calls))
+	for _, call := range calls {
+		call.mu.Lock()
+		infos = append(infos, CallInfo{CallID: call.id, Since: call.since, Streams: len(call.streams)})
+		call.mu.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Since.Before(infos[j].Since) })
+	return infos
+}
+
+// Issue creates a single-use token for a stream on an active call
+func (h *Hub) Issue(callID string, mode Mode, subject string, ttl time.Duration) (string, Grant, error) {
+	if !mode.Valid() {
+		return "", Grant{}, errors.New("invalid media stream mode")
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", Grant{}, err
+	}
+	token := hex.EncodeToString(buf)
+	grant := Grant{CallID: callID, Mode: mode, Subject: subject, ExpiresAt: time.Now().Add(ttl)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.calls[callID]; !ok {
+		return "", Grant{}, ErrCallNotFound
+	}
+	now := time.Now()
+	for t, g := range h.grants {
+		if now.After(g.ExpiresAt) {
+			delete(h.grants, t)
+		}
+	}
+	h.grants[token] = grant
+	return token, grant, nil
+}
+
+// Redeem consumes a token
+func (h *Hub) Redeem(token string) (Grant, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	grant, ok := h.grants[token]
+	delete(h.grants, token)
+	if !ok || time.Now().After(grant.ExpiresAt) {
+		return Grant{}, ErrInvalidToken
+	}
+	return grant, nil
+}
+
+// Attach opens a stream on the call of a redeemed grant
+func (h *Hub) Attach(grant Grant) (*Stream, error) {
+	h.mu.Lock()
+	call, ok := h.calls[grant.CallID]
+	h.mu.Unlock()
+	if !ok {
+		return nil, ErrCallNotFound
+	}
+
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	if call.isClosed() {
+		return nil, ErrCallNotFound
+	}
+	if len(call.streams) >= h.cfg.MaxStreamsPerCall {
+		return nil, ErrTooManyStreams
+	}
+	stream := &Stream{
+		call:   call,
+		grant:  grant,
+		frames: make(chan []byte, h.cfg.OutboundBuffer),
+		done:   make(chan struct{}),
+	}
+	call.streams[stream] = struct{}{}
+	return stream, nil
+}
+
+// Call media side of a streamable call
+type Call struct {
+	id      string
+	hub     *Hub
+	since   time.Time
+	mu      sync.Mutex
+	streams map[*Stream]struct{}
+	inject  chan []byte
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (c *Call) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Streaming reports whether any stream is attached, so callers can skip decoding otherwise
+func (c *Call) Streaming() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.streams) > 0
+}
+
+// Publish hands a frame of call audio to every stream. It never blocks: a
+// stream whose buffer is full drops the frame.
+func (c *Call) Publish(frame []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for stream := range c.streams {
+		select {
+		case stream.frames <- frame:
+		default:
+			stream.dropped.Add(1)
+		}
+	}
+}
+
+// Injected audio from duplex streams, to be played into the call
+func (c *Call) Injected() <-chan []byte {
+	return c.inject
+}
+
+// Done is closed when the call is closed
+func (c *Call) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Close ends every stream of the call and removes it from the hub
+func (c *Call) Close() {
+	c.once.Do(func() {
+		close(c.closed)
+		c.hub.mu.Lock()
+		if c.hub.calls[c.id] == c {
+			delete(c.hub.calls, c.id)
+		}
+		c.hub.mu.Unlock()
+
+		c.mu.Lock()
+		streams := make([]*Stream, 0, len(c.streams))
+		for stream := range c.streams {
+			streams = append(streams, stream)
+		}
+		c.mu.Unlock()
+		for _, stream := range streams {
+			stream.Close()
+		}
+	})
+}
+
+// Stream external side of a call
+type Stream struct {
+	call    *Call
+	grant   Grant
+	frames  chan []byte
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// Grant the stream was opened with
+func (s *Stream) Grant() Grant {
+	return s.grant
+}
+
+// Frames call audio, closed when the stream or the call ends
+func (s *Stream) Frames() <-chan []byte {
+	return s.frames
+}
+
+// Done is closed when the stream ends
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped frames not delivered because the consumer fell behind
+func (s *Stream) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Inject queues a frame to be played into the call, blocking while the call's
+// inject buffer is full so the producer is slowed down to real time
+func (s *Stream) Inject(ctx context.Context, frame []byte) error {
+	if s.grant.Mode != ModeDuplex {
+		return ErrReadOnly
+	}
+	select {
+	case s.call.inject <- frame:
+		return nil
+	case <-s.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close detaches the stream, safe to call more than once
+func (s *Stream) Close() {
+	s.once.Do(func() {
+		s.call.mu.Lock()
+		delete(s.call.streams, s)
+		close(s.frames)
+		s.call.mu.Unlock()
+		close(s.done)
+	})
+}
@@ -0,0 +1,108 @@
+package mediastream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_TokenLifecycle(t *testing.T) {
+	h := New(Config{})
+
+	_, _, err := h.Issue("missing", ModeListen, "admin", time.Minute)
+	assert.ErrorIs(t, err, ErrCallNotFound)
+
+	h.OpenCall("c1")
+	_, _, err = h.Issue("c1", Mode("bogus"), "admin", time.Minute)
+	assert.Error(t, err)
+
+	token, grant, err := h.Issue("c1", ModeListen, "admin", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "c1", grant.CallID)
+
+	redeemed, err := h.Redeem(token)
+	require.NoError(t, err)
+	assert.Equal(t, ModeListen, redeemed.Mode)
+
+	// Tokens are single use
+	_, err = h.Redeem(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	expired, _, err := h.Issue("c1", ModeListen, "admin", -time.Second)
+	require.NoError(t, err)
+	_, err = h.Redeem(expired)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestHub_PublishDropsForSlowConsumers(t *testing.T) {
+	h := New(Config{OutboundBuffer: 2, MaxStreamsPerCall: 1})
+	call := h.OpenCall("c1")
+	assert.False(t, call.Streaming())
+
+	stream, err := h.Attach(Grant{CallID: "c1", Mode: ModeListen})
+	require.NoError(t, err)
+	assert.True(t, call.Streaming())
+
+	_, err = h.Attach(Grant{CallID: "c1", Mode: ModeListen})
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+
+	for i := 0; i < 5; i++ {
+		call.Publish([]byte{byte(i)})
+	}
+	assert.Equal(t, []byte{0}, <-stream.Frames())
+	assert.Equal(t, []byte{1}, <-stream.Frames())
+	assert.Equal(t, int64(3), stream.Dropped())
+
+	assert.ErrorIs(t, stream.Inject(context.Background(), []byte{1}), ErrReadOnly)
+
+	stream.Close()
+	stream.Close()
+	assert.False(t, call.Streaming())
+	_, open := <-stream.Frames()
+	assert.False(t, open)
+}
+
+func TestHub_InjectBackpressure(t *testing.T) {
+	h := New(Config{InjectBuffer: 1})
+	call := h.OpenCall("c1")
+	stream, err := h.Attach(Grant{CallID: "c1", Mode: ModeDuplex})
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Inject(context.Background(), []byte{1}))
+
+	// The buffer is full, the producer blocks until the call plays the frame
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, stream.Inject(ctx, []byte{2}), context.DeadlineExceeded)
+
+	assert.Equal(t, []byte{1}, <-call.Injected())
+	require.NoError(t, stream.Inject(context.Background(), []byte{2}))
+
+	// Closing the call ends its streams
+	call.Close()
+	<-stream.Done()
+	assert.Empty(t, h.Calls())
+	_, err = h.Attach(Grant{CallID: "c1", Mode: ModeDuplex})
+	assert.ErrorIs(t, err, ErrCallNotFound)
+}
+
+func TestHub_ReopenReplacesCall(t *testing.T) {
+	h := New(Config{})
+	first := h.OpenCall("c1")
+	stream, err := h.Attach(Grant{CallID: "c1", Mode: ModeListen})
+	require.NoError(t, err)
+
+	second := h.OpenCall("c1")
+	<-stream.Done()
+	<-first.Done()
+
+	// Closing the replaced call keeps the new one registered
+	first.Close()
+	calls := h.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, 0, calls[0].Streams)
+	second.Close()
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// APIVersionV1 当前稳定的 API 版本
+	APIVersionV1 = "v1"
+	// APIVersionLegacy 未带版本号的旧路由
+	APIVersionLegacy = "legacy"
+
+	// APIVersionHeader 响应中标明实际处理请求的 API 版本
+	APIVersionHeader = "X-API-Version"
+
+	apiVersionKey = "api_version"
+	apiRootKey    = "api_version_root"
+)
+
+// DeprecationConfig 旧路由的弃用信息
+type DeprecationConfig struct {
+	Root        string    // API 根前缀，如 /api
+	Successor   string    // 替代版本，如 v1，用于生成 successor-version 链接
+	Sunset      time.Time // 旧路由下线时间，零值时不返回 Sunset 头
+	VersionsURL string    // 版本说明文档地址，作为 deprecation 链接返回
+}
+
+// APIVersion 标记路由组的 API 版本，root 为不带版本号的 API 前缀
+// 需要在限流、超时等按路径配置的中间件之前注册，以便它们按去掉版本号的路径匹配配置
+func APIVersion(root, version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, version)
+		c.Set(apiRootKey, root)
+		c.Header(APIVersionHeader, version)
+		c.Next()
+	}
+}
+
+// DeprecatedAPI 旧路由兼容中间件，返回 Deprecation、Sunset 和指向新版本的 Link 头
+func DeprecatedAPI(cfg DeprecationConfig) gin.HandlerFunc {
+	root := strings.TrimSuffix(cfg.Root, "/")
+	sunset := ""
+	if !cfg.Sunset.IsZero() {
+		sunset = cfg.Sunset.UTC().Format(http.TimeFormat)
+	}
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, APIVersionLegacy)
+		c.Header(APIVersionHeader, APIVersionLegacy)
+		c.Header("Deprecation", "true")
+		if sunset != "" {
+			c.Header("Sunset", sunset)
+		}
+
+		var links []string
+		if cfg.Successor != "" {
+			successor := root + "/" + cfg.Successor + strings.TrimPrefix(c.Request.URL.Path, root)
+			if c.Request.URL.RawQuery != "" {
+				successor += "?" + c.Request.URL.RawQuery
+			}
+			links = append(links, "<"+successor+">; rel=\"successor-version\"")
+		}
+		if cfg.VersionsURL != "" {
+			links = append(links, "<"+cfg.VersionsURL+">; rel=\"deprecation\"")
+		}
+		if len(links) > 0 {
+			c.Header("Link", strings.Join(links, ", "))
+		}
+		c.Next()
+	}
+}
+
+// GetAPIVersion 获取处理当前请求的 API 版本，未经过版本中间件时返回空
+func GetAPIVersion(c *gin.Context) string {
+	return c.GetString(apiVersionKey)
+}
+
+// UnversionedPath 返回去掉版本号的请求路径，如 /api/v1/auth/login -> /api/auth/login
+// 限流、超时、请求体限制等配置均按旧路径编写，新旧路由共用同一份配置
+func UnversionedPath(c *gin.Context) string {
+	return stripAPIVersion(c, c.Request.URL.Path)
+}
+
+// stripAPIVersion 去掉路径或路由模板中的版本号
+func stripAPIVersion(c *gin.Context, path string) string {
+	version := c.GetString(apiVersionKey)
+	if version == "" || version == APIVersionLegacy {
+		return path
+	}
+	root := strings.TrimSuffix(c.GetString(apiRootKey), "/")
+	prefix := root + "/" + version
+	if path == prefix {
+		return root
+	}
+	if strings.HasPrefix(path, prefix+"/") {
+		return root + path[len(prefix):]
+	}
+	return path
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newVersionedEngine(sunset time.Time) *gin.Engine {
+	r := newEngine()
+	api := r.Group("/api")
+	v1 := api.Group("/"+APIVersionV1, APIVersion("/api", APIVersionV1))
+	legacy := api.Group("", DeprecatedAPI(DeprecationConfig{
+		Root:        "/api",
+		Successor:   APIVersionV1,
+		Sunset:      sunset,
+		VersionsURL: "/api/versions",
+	}))
+	for _, g := range []*gin.RouterGroup{v1, legacy} {
+		g.GET("/group/:id", func(c *gin.Context) {
+			c.String(http.StatusOK, GetAPIVersion(c)+" "+UnversionedPath(c)+" "+stripAPIVersion(c, c.FullPath()))
+		})
+		g.GET("/voice/lingecho/v1/session", func(c *gin.Context) {
+			c.String(http.StatusOK, UnversionedPath(c))
+		})
+	}
+	return r
+}
+
+func TestAPIVersion_ServesBothPrefixes(t *testing.T) {
+	r := newVersionedEngine(time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/group/7", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1 /api/group/7 /api/group/:id", w.Body.String())
+	assert.Equal(t, APIVersionV1, w.Header().Get(APIVersionHeader))
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/voice/lingecho/v1/session", nil))
+	assert.Equal(t, "/api/voice/lingecho/v1/session", w.Body.String())
+}
+
+func TestDeprecatedAPI_Headers(t *testing.T) {
+	r := newVersionedEngine(time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/group/7?full=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "legacy /api/group/7 /api/group/:id", w.Body.String())
+	assert.Equal(t, APIVersionLegacy, w.Header().Get(APIVersionHeader))
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/group/7?full=1>; rel="successor-version", </api/versions>; rel="deprecation"`, w.Header().Get("Link"))
+
+	// 未配置下线时间时不返回 Sunset
+	r = newVersionedEngine(time.Time{})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voice/lingecho/v1/session", nil))
+	assert.Equal(t, "/api/voice/lingecho/v1/session", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+}
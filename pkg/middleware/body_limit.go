@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// UploadIDHeader 客户端为上传请求生成的唯一ID，用于查询上传进度
+const UploadIDHeader = "X-Upload-Id"
+
+// uploadProgressTTL 上传结束后进度保留的时间
+const uploadProgressTTL = 10 * time.Minute
+
+// BodyLimitConfig 请求体大小限制配置
+type BodyLimitConfig struct {
+	DefaultMaxBytes int64            // 未单独配置的路由的最大请求体，<=0 不限制
+	MultipartMemory int64            // multipart 表单在内存中保留的上限，超出部分写入临时文件
+	EndpointLimits  map[string]int64 // 按路由模板（如 /api/group/:id/avatar）配置的最大请求体
+}
+
+// UploadProgress 上传进度
+type UploadProgress struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	Received  int64     `json:"received"`
+	Total     int64     `json:"total"` // Content-Length，未知时为 -1
+	Done      bool      `json:"done"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Percent 上传百分比，总大小未知时返回 -1
+func (p UploadProgress) Percent() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return float64(p.Received) * 100 / float64(p.Total)
+}
+
+// uploadTracker 记录进行中和最近完成的上传
+type uploadTracker struct {
+	mu      sync.Mutex
+	uploads map[string]*UploadProgress
+}
+
+var globalUploadTracker = &uploadTracker{uploads: make(map[string]*UploadProgress)}
+
+func (t *uploadTracker) start(id, path string, total int64) *UploadProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	// 顺带清理过期记录
+	for key, p := range t.uploads {
+		if p.Done && now.Sub(p.UpdatedAt) > uploadProgressTTL {
+			delete(t.uploads, key)
+		}
+	}
+	p := &UploadProgress{ID: id, Path: path, Total: total, StartedAt: now, UpdatedAt: now}
+	t.uploads[id] = p
+	return p
+}
+
+func (t *uploadTracker) add(p *UploadProgress, n int) {
+	t.mu.Lock()
+	p.Received += int64(n)
+	p.UpdatedAt = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *uploadTracker) finish(p *UploadProgress, err error) {
+	t.mu.Lock()
+	p.Done = true
+	if err != nil {
+		p.Error = err.Error()
+	}
+	p.UpdatedAt = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *uploadTracker) get(id string) (UploadProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.uploads[id]
+	if !ok {
+		return UploadProgress{}, false
+	}
+	return *p, true
+}
+
+// GetUploadProgress 查询上传进度
+func GetUploadProgress(id string) (UploadProgress, bool) {
+	return globalUploadTracker.get(id)
+}
+
+// progressReader 统计已读取的字节数
+type progressReader struct {
+	io.ReadCloser
+	progress *UploadProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		globalUploadTracker.add(r.progress, n)
+	}
+	return n, err
+}
+
+// getDefaultEndpointBodyLimits 获取默认的上传接口请求体限制
+func getDefaultEndpointBodyLimits(uploadMaxBytes int64) map[string]int64 {
+	const avatarMaxBytes = 5 << 20
+	return map[string]int64{
+		// 知识库文档
+		"/api/knowledge/create": uploadMaxBytes,
+		"/api/knowledge/upload": uploadMaxBytes,
+		// 音频上传
+		"/api/system/upload/audio":          uploadMaxBytes,
+		"/api/voiceprint/register":          uploadMaxBytes,
+		"/api/voiceprint/identify":          uploadMaxBytes,
+		"/api/voiceprint/verify":            uploadMaxBytes,
+		"/api/voice/training/submit-audio":  uploadMaxBytes,
+		"/api/xunfei/task/submit-audio":     uploadMaxBytes,
+		"/api/volcengine/task/submit-audio": uploadMaxBytes,
+		"/api/dids/import":                  uploadMaxBytes,
+		"/api/auth/avatar/upload":           avatarMaxBytes,
+		"/api/group/:id/avatar":             avatarMaxBytes,
+	}
+}
+
+// maxBytes 获取路由的最大请求体
+func (cfg BodyLimitConfig) maxBytes(route string) int64 {
+	if limit, ok := cfg.EndpointLimits[route]; ok {
+		return limit
+	}
+	return cfg.DefaultMaxBytes
+}
+
+// BodyLimitMiddleware 请求体大小限制中间件
+// 按路由限制请求体大小，multipart 表单以流式方式解析，超出内存上限的文件写入临时文件，
+// 请求带 X-Upload-Id 时记录上传进度
+func BodyLimitMiddleware(cfg BodyLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := stripAPIVersion(c, c.FullPath())
+		if route == "" {
+			route = UnversionedPath(c)
+		}
+		limit := cfg.maxBytes(route)
+
+		if limit > 0 && c.Request.ContentLength > limit {
+			rejectTooLarge(c, limit)
+			return
+		}
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if limit > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+
+		var progress *UploadProgress
+		if id := strings.TrimSpace(c.GetHeader(UploadIDHeader)); id != "" {
+			progress = globalUploadTracker.start(id, route, c.Request.ContentLength)
+			c.Request.Body = &progressReader{ReadCloser: c.Request.Body, progress: progress}
+		}
+
+		if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+			// 提前解析，后续 c.FormFile 直接复用，文件部分超出内存上限时落盘
+			err := c.Request.ParseMultipartForm(cfg.MultipartMemory)
+			if c.Request.MultipartForm != nil {
+				defer c.Request.MultipartForm.RemoveAll()
+			}
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				if progress != nil {
+					globalUploadTracker.finish(progress, err)
+				}
+				rejectTooLarge(c, limit)
+				return
+			}
+			if err != nil {
+				// 格式错误交给处理器返回各自的错误信息
+				logger.Debug("multipart form parse failed", zap.String("path", route), zap.Error(err))
+			}
+			if progress != nil {
+				globalUploadTracker.finish(progress, err)
+			}
+			c.Next()
+			return
+		}
+
+		c.Next()
+		if progress != nil {
+			globalUploadTracker.finish(progress, nil)
+		}
+	}
+}
+
+// rejectTooLarge 返回 413
+func rejectTooLarge(c *gin.Context, limit int64) {
+	logger.Warn("Request body too large",
+		zap.String("path", c.Request.URL.Path),
+		zap.Int64("contentLength", c.Request.ContentLength),
+		zap.Int64("limit", limit))
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"error":    "request_entity_too_large",
+		"message":  "Request body exceeds the size limit of this endpoint",
+		"maxBytes": limit,
+	})
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func multipartBody(t *testing.T, size int) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", "doc.txt")
+	require.NoError(t, err)
+	_, err = part.Write(bytes.Repeat([]byte("a"), size))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return &buf, w.FormDataContentType()
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	originalLogger := logger.Lg
+	logger.Lg = zap.NewNop()
+	defer func() { logger.Lg = originalLogger }()
+
+	var spilled string
+	r := gin.New()
+	r.Use(BodyLimitMiddleware(BodyLimitConfig{
+		DefaultMaxBytes: 64,
+		MultipartMemory: 16,
+		EndpointLimits:  map[string]int64{"/upload/:id": 4096},
+	}))
+	r.POST("/echo", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	r.POST("/upload/:id", func(c *gin.Context) {
+		header, err := c.FormFile("file")
+		require.NoError(t, err)
+		f, err := header.Open()
+		require.NoError(t, err)
+		defer f.Close()
+		if osFile, ok := f.(*os.File); ok {
+			spilled = osFile.Name()
+		}
+		c.String(http.StatusOK, "%d", header.Size)
+	})
+
+	// Default limit applies to routes without their own limit
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(strings.Repeat("x", 65))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Without Content-Length the body is cut off while reading
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/echo", io.NopCloser(strings.NewReader(strings.Repeat("x", 65))))
+	req.ContentLength = -1
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Per-route limit, the file spills to a temp file that is removed afterwards
+	body, contentType := multipartBody(t, 1024)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/upload/1", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(UploadIDHeader, "up-1")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1024", w.Body.String())
+	require.NotEmpty(t, spilled)
+	_, err := os.Stat(spilled)
+	assert.True(t, os.IsNotExist(err), "temp file should be removed")
+
+	progress, ok := GetUploadProgress("up-1")
+	require.True(t, ok)
+	assert.True(t, progress.Done)
+	assert.Equal(t, progress.Total, progress.Received)
+	assert.Equal(t, float64(100), progress.Percent())
+
+	// Streaming multipart over the route limit
+	body, contentType = multipartBody(t, 8192)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/upload/1", io.NopCloser(body))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", contentType)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -15,6 +15,7 @@ type MiddlewareManager struct {
 	config            config.MiddlewareConfig
 	rateLimiter       *RateLimiter
 	timeoutCircuitMgr *TimeoutCircuitManager
+	bodyLimit         BodyLimitConfig
 }
 
 // NewMiddlewareManager 创建中间件管理器
@@ -64,6 +65,19 @@ func NewMiddlewareManager(cfg config.MiddlewareConfig) *MiddlewareManager {
 			zap.Int("failureThreshold", cfg.CircuitBreaker.FailureThreshold))
 	}
 
+	// 初始化请求体限制
+	if cfg.EnableBodyLimit {
+		mgr.bodyLimit = BodyLimitConfig{
+			DefaultMaxBytes: cfg.BodyLimit.DefaultMaxBytes,
+			MultipartMemory: cfg.BodyLimit.MultipartMemory,
+			EndpointLimits:  getDefaultEndpointBodyLimits(cfg.BodyLimit.UploadMaxBytes),
+		}
+		logger.Info("Body limit initialized",
+			zap.Int64("defaultMaxBytes", cfg.BodyLimit.DefaultMaxBytes),
+			zap.Int64("uploadMaxBytes", cfg.BodyLimit.UploadMaxBytes),
+			zap.Int64("multipartMemory", cfg.BodyLimit.MultipartMemory))
+	}
+
 	return mgr
 }
 
@@ -134,7 +148,14 @@ func (mgr *MiddlewareManager) ApplyMiddlewares(r *gin.RouterGroup) {
 		zap.Bool("rateLimit", mgr.config.EnableRateLimit),
 		zap.Bool("timeout", mgr.config.EnableTimeout),
 		zap.Bool("circuitBreaker", mgr.config.EnableCircuitBreaker),
-		zap.Bool("operationLog", mgr.config.EnableOperationLog))
+		zap.Bool("operationLog", mgr.config.EnableOperationLog),
+		zap.Bool("bodyLimit", mgr.config.EnableBodyLimit))
+
+	// 0. 请求体限制（在读取请求体之前拒绝超大请求）
+	if mgr.config.EnableBodyLimit {
+		r.Use(BodyLimitMiddleware(mgr.bodyLimit))
+		logger.Info("Body limit middleware applied")
+	}
 
 	// 1. 限流中间件（最先执行）
 	if mgr.config.EnableRateLimit && mgr.rateLimiter != nil {
@@ -278,6 +299,12 @@ func GetGlobalMiddlewareManager() *MiddlewareManager {
 				EnableTimeout:        true,
 				EnableCircuitBreaker: true,
 				EnableOperationLog:   true,
+				BodyLimit: config.BodyLimitConfig{
+					DefaultMaxBytes: 10 << 20,
+					UploadMaxBytes:  200 << 20,
+					MultipartMemory: 8 << 20,
+				},
+				EnableBodyLimit: true,
 			}
 			globalMiddlewareManager = NewMiddlewareManager(defaultConfig)
 		}
@@ -37,7 +37,7 @@ func OperationLogMiddleware() gin.HandlerFunc {
 
 		// 基于配置智能判断是否应该记录此操作
 		method := c.Request.Method
-		path := c.Request.URL.Path
+		path := UnversionedPath(c)
 		if !operationLogConfig.ShouldLogOperation(method, path) {
 			return
 		}
@@ -62,7 +62,7 @@ func OperationLogMiddleware() gin.HandlerFunc {
 		// 生成更详细的操作描述
 		action := c.Request.Method
 		target := c.Request.URL.Path
-		details := operationLogConfig.GetOperationDescription(action, target)
+		details := operationLogConfig.GetOperationDescription(action, path)
 
 		// 记录操作日志（异步执行，避免影响响应时间）
 		go func() {
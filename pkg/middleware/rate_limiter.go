@@ -302,7 +302,7 @@ func RateLimitMiddleware() gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
-		endpoint := c.Request.URL.Path
+		endpoint := UnversionedPath(c)
 
 		var userID uint = 0
 
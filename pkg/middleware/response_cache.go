@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/cache"
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	responseCachePrefix = "resp_cache:"
+	// responseCacheVersionTTL must outlive every cached entry, so that an expired
+	// version marker never brings back entries written under the previous one
+	responseCacheVersionTTL = 24 * time.Hour
+	// ResponseCacheHeader reports HIT or MISS on cached endpoints
+	ResponseCacheHeader = "X-Cache"
+)
+
+// ResponseCache caches successful GET responses per user and query string.
+// Entries of a namespace are dropped with InvalidateResponseCache, which the
+// write paths of the cached resources call; ttl bounds staleness for writes
+// that bypass those hooks. Requests with "Cache-Control: no-cache" skip the
+// lookup but refresh the entry.
+func ResponseCache(namespace string, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := models.CurrentUser(c)
+		if c.Request.Method != http.MethodGet || user == nil {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		store := cache.GetGlobalCache()
+		key := responseCacheKey(ctx, store, namespace, user.ID, c.Request)
+
+		if c.GetHeader("Cache-Control") != "no-cache" {
+			if value, ok := store.Get(ctx, key); ok {
+				if body, ok := value.(string); ok {
+					c.Header(ResponseCacheHeader, "HIT")
+					c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(body))
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		writer := &responseCacheWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Header(ResponseCacheHeader, "MISS")
+		c.Next()
+
+		if writer.Status() != http.StatusOK || !isSuccessBody(writer.body.Bytes()) {
+			return
+		}
+		if err := store.Set(ctx, key, writer.body.String(), ttl); err != nil {
+			logger.Warn("Failed to cache response", zap.String("namespace", namespace), zap.Error(err))
+		}
+	}
+}
+
+// InvalidateResponseCache drops the cached responses of a namespace for the users
+func InvalidateResponseCache(ctx context.Context, namespace string, userIDs ...uint) {
+	store := cache.GetGlobalCache()
+	version := strconv.FormatInt(time.Now().UnixNano(), 10)
+	for _, userID := range userIDs {
+		if err := store.Set(ctx, responseCacheVersionKey(namespace, userID), version, responseCacheVersionTTL); err != nil {
+			logger.Warn("Failed to invalidate response cache",
+				zap.String("namespace", namespace), zap.Uint("userID", userID), zap.Error(err))
+		}
+	}
+}
+
+// responseCacheKey builds the entry key. The per-user version marker is part of
+// the key, so bumping it orphans every query variant at once.
+func responseCacheKey(ctx context.Context, store cache.Cache, namespace string, userID uint, r *http.Request) string {
+	version := "0"
+	if value, ok := store.Get(ctx, responseCacheVersionKey(namespace, userID)); ok {
+		version = fmt.Sprint(value)
+	}
+	// Encode sorts the parameters, so their order does not split the cache
+	sum := sha1.Sum([]byte(r.URL.Path + "?" + r.URL.Query().Encode()))
+	return fmt.Sprintf("%s%s:%d:%s:%s", responseCachePrefix, namespace, userID, version, hex.EncodeToString(sum[:]))
+}
+
+func responseCacheVersionKey(namespace string, userID uint) string {
+	return fmt.Sprintf("%sver:%s:%d", responseCachePrefix, namespace, userID)
+}
+
+// isSuccessBody reports whether the body is a response.Success payload
+func isSuccessBody(body []byte) bool {
+	var payload struct {
+		Code int `json:"code"`
+	}
+	return json.Unmarshal(body, &payload) == nil && payload.Code == http.StatusOK
+}
+
+// responseCacheWriter keeps a copy of the response body
+type responseCacheWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCacheWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCacheWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	fail := false
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(constants.UserField, &models.User{BaseModel: models.BaseModel{ID: 42}})
+	})
+	r.GET("/items", ResponseCache("test-items", time.Minute), func(c *gin.Context) {
+		calls++
+		if fail {
+			c.JSON(http.StatusOK, gin.H{"code": 500, "msg": "failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 200, "data": calls})
+	})
+
+	get := func(path string, headers ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(headers) == 2 {
+			req.Header.Set(headers[0], headers[1])
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("/items?a=1&b=2")
+	assert.Equal(t, "MISS", first.Header().Get(ResponseCacheHeader))
+
+	second := get("/items?b=2&a=1")
+	assert.Equal(t, "HIT", second.Header().Get(ResponseCacheHeader))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, 1, calls)
+
+	get("/items?a=2")
+	assert.Equal(t, 2, calls, "different query is a different entry")
+
+	get("/items?a=1&b=2", "Cache-Control", "no-cache")
+	assert.Equal(t, 3, calls, "no-cache bypasses the lookup")
+
+	InvalidateResponseCache(context.Background(), "test-items", 42)
+	fail = true
+	assert.Equal(t, "MISS", get("/items?a=1&b=2").Header().Get(ResponseCacheHeader))
+	assert.Equal(t, "MISS", get("/items?a=1&b=2").Header().Get(ResponseCacheHeader), "failures are not cached")
+	assert.Equal(t, 5, calls)
+}
@@ -275,7 +275,7 @@ func TimeoutMiddleware() gin.HandlerFunc {
 	manager := GetTimeoutCircuitManager()
 
 	return func(c *gin.Context) {
-		endpoint := c.Request.URL.Path
+		endpoint := UnversionedPath(c)
 		timeout := manager.getTimeout(endpoint)
 
 		// 创建带超时的上下文
@@ -322,7 +322,7 @@ func CircuitBreakerMiddleware() gin.HandlerFunc {
 	manager := GetTimeoutCircuitManager()
 
 	return func(c *gin.Context) {
-		endpoint := c.Request.URL.Path
+		endpoint := UnversionedPath(c)
 		cb := manager.getCircuitBreaker(endpoint)
 
 		// 检查熔断器是否允许请求
@@ -372,7 +372,7 @@ func CombinedTimeoutCircuitMiddleware() gin.HandlerFunc {
 	manager := GetTimeoutCircuitManager()
 
 	return func(c *gin.Context) {
-		endpoint := c.Request.URL.Path
+		endpoint := UnversionedPath(c)
 
 		// 跳过 WebSocket 语音连接的熔断器检查
 		// WebSocket 是长连接，不适合用熔断器
@@ -0,0 +1,172 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/queue"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Job types of notifications delivered through the job queue
+const (
+	JobTypeMail = "notification.mail"
+	JobTypeSMS  = "notification.sms"
+)
+
+// MailJob is a rendered email waiting for delivery. Only the sender identity travels
+// with the job, provider credentials come from the worker's configuration
+type MailJob struct {
+	To        string `json:"to"`
+	Subject   string `json:"subject"`
+	HTML      string `json:"html"`
+	From      string `json:"from,omitempty"`
+	FromName  string `json:"fromName,omitempty"`
+	UserID    uint   `json:"userId,omitempty"`
+	IPAddress string `json:"ipAddress,omitempty"`
+	Track     bool   `json:"track,omitempty"` // record the delivery in mail_logs
+}
+
+// SMSJob is a verification code waiting to be sent by SMS. The code is removed from the
+// job record once it has been delivered or dead lettered
+type SMSJob struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code,omitempty"`
+}
+
+// errSMSCodeRedacted a requeued SMS job whose code was already removed
+var errSMSCodeRedacted = errors.New("verification code was removed after delivery, request a new one")
+
+var (
+	jobQueueMu sync.RWMutex
+	jobQueue   *queue.Queue
+)
+
+// jobWorker delivers notification jobs
+type jobWorker struct {
+	db              *gorm.DB
+	mail            MailConfig
+	newMailProvider func(MailConfig) MailProvider
+	sms             SMSProvider
+}
+
+// RegisterJobs registers the notification handlers on q and routes mail and SMS sends
+// through it from then on. db records deliveries in mail_logs, mail and sms are the
+// provider settings used by the workers
+func RegisterJobs(q *queue.Queue, db *gorm.DB, mail MailConfig, sms SMSConfig) error {
+	w := &jobWorker{db: db, mail: mail, newMailProvider: createProvider}
+	if sms.Enabled() {
+		provider, err := NewSMSProvider(sms)
+		if err != nil {
+			return err
+		}
+		w.sms = provider
+	}
+	w.register(q)
+	setJobQueue(q)
+	return nil
+}
+
+func (w *jobWorker) register(q *queue.Queue) {
+	q.Register(JobTypeMail, w.sendMail)
+	q.OnDead(JobTypeMail, w.mailDead)
+	q.Register(JobTypeSMS, w.sendSMS)
+	q.Redact(JobTypeSMS, redactSMSCode)
+}
+
+func setJobQueue(q *queue.Queue) {
+	jobQueueMu.Lock()
+	defer jobQueueMu.Unlock()
+	jobQueue = q
+}
+
+// JobQueue returns the queue notifications go through, nil when they are sent directly
+func JobQueue() *queue.Queue {
+	jobQueueMu.RLock()
+	defer jobQueueMu.RUnlock()
+	return jobQueue
+}
+
+func (w *jobWorker) sendMail(_ context.Context, job *queue.Job) error {
+	var m MailJob
+	if err := job.Decode(&m); err != nil {
+		return queue.Permanent(err)
+	}
+	config := w.mail
+	if m.From != "" {
+		config.From = m.From
+		config.FromName = m.FromName
+	}
+	messageID, err := w.newMailProvider(config).SendHTML(m.To, m.Subject, m.HTML)
+	logger.Info("Email sent via provider",
+		zap.String("to", m.To),
+		zap.String("subject", m.Subject),
+		zap.String("messageId", messageID),
+		zap.String("jobId", job.ID),
+		zap.Int("attempt", job.Attempts),
+		zap.Error(err),
+		zap.Uint("userId", m.UserID))
+	if messageID != "" && m.Track && w.db != nil {
+		CreateMailLogWithIP(w.db, m.UserID, m.To, m.Subject, messageID, m.IPAddress)
+	}
+	return err
+}
+
+// mailDead records emails that could not be delivered so they show up in the mail log
+func (w *jobWorker) mailDead(_ context.Context, job *queue.Job) {
+	var m MailJob
+	if err := job.Decode(&m); err != nil || !m.Track || w.db == nil {
+		return
+	}
+	if _, err := CreateFailedMailLog(w.db, m.UserID, m.To, m.Subject, m.IPAddress, job.LastError); err != nil {
+		logger.Warn("Failed to record undelivered email", zap.String("jobId", job.ID), zap.Error(err))
+	}
+}
+
+func (w *jobWorker) sendSMS(ctx context.Context, job *queue.Job) error {
+	var m SMSJob
+	if err := job.Decode(&m); err != nil {
+		return queue.Permanent(err)
+	}
+	if w.sms == nil {
+		return queue.Permanent(ErrSMSNotConfigured)
+	}
+	if m.Code == "" {
+		return queue.Permanent(errSMSCodeRedacted)
+	}
+	err := SendSMSCode(ctx, w.sms, m.Phone, m.Code)
+	if errors.Is(err, ErrSMSInvalidPhone) {
+		return queue.Permanent(err)
+	}
+	return err
+}
+
+// redactSMSCode drops the verification code from finished SMS jobs, keeping the phone
+// number so dead letters can still be traced
+func redactSMSCode(payload json.RawMessage) json.RawMessage {
+	var m SMSJob
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil
+	}
+	m.Code = ""
+	redacted, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// DeliverSMSCode sends a verification code by SMS. Once RegisterJobs has been called the
+// code is queued and sent by the workers' provider, so errors from the provider such as
+// ErrSMSInvalidPhone are only returned when sending directly
+func DeliverSMSCode(ctx context.Context, provider SMSProvider, phone, code string) error {
+	if q := JobQueue(); q != nil {
+		_, err := q.Enqueue(ctx, JobTypeSMS, SMSJob{Phone: phone, Code: code})
+		return err
+	}
+	return SendSMSCode(ctx, provider, phone, code)
+}
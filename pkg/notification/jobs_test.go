@@ -0,0 +1,157 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type fakeMailProvider struct {
+	configs []MailConfig
+	err     error
+}
+
+func (p *fakeMailProvider) SendHTML(to, subject, htmlBody string) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	return "msg-1", nil
+}
+
+type fakeSMSProvider struct {
+	sent []string
+	err  error
+}
+
+func (p *fakeSMSProvider) Name() string { return "fake" }
+
+func (p *fakeSMSProvider) SendCode(ctx context.Context, phone, code string) error {
+	p.sent = append(p.sent, phone+":"+code)
+	return p.err
+}
+
+// newTestJobQueue registers the notification jobs on a memory queue and routes sends through it
+func newTestJobQueue(t *testing.T, mail *fakeMailProvider, sms SMSProvider) (*queue.Queue, *gorm.DB) {
+	logger.Lg = zap.NewNop()
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&MailLog{}))
+	q := queue.New(queue.NewMemoryBackend())
+	q.MaxAttempts = 1
+	w := &jobWorker{
+		db:   db,
+		mail: MailConfig{Provider: "smtp", Host: "smtp.example.com", From: "noreply@example.com"},
+		newMailProvider: func(config MailConfig) MailProvider {
+			mail.configs = append(mail.configs, config)
+			return mail
+		},
+		sms: sms,
+	}
+	w.register(q)
+	setJobQueue(q)
+	t.Cleanup(func() { setJobQueue(nil) })
+	return q, db
+}
+
+func TestMailNotification_Queued(t *testing.T) {
+	provider := &fakeMailProvider{}
+	q, db := newTestJobQueue(t, provider, nil)
+
+	mailer := NewMailNotificationWithIP(MailConfig{From: "support@acme.com", FromName: "Acme"}, db, "1.2.3.4")
+	require.NoError(t, mailer.SendVerificationCode("a@example.com", "123456"))
+	assert.Empty(t, provider.configs, "sending only queues the email")
+
+	q.RunDue(context.Background())
+	require.Len(t, provider.configs, 1)
+	assert.Equal(t, "support@acme.com", provider.configs[0].From)
+	assert.Equal(t, "Acme", provider.configs[0].FromName)
+	assert.Equal(t, "smtp.example.com", provider.configs[0].Host, "credentials come from the worker configuration")
+
+	log, err := GetMailLogByMessageID(db, "msg-1")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", log.IPAddress)
+}
+
+func TestMailNotification_DeadLetterRecorded(t *testing.T) {
+	provider := &fakeMailProvider{err: errors.New("550 mailbox unavailable")}
+	q, db := newTestJobQueue(t, provider, nil)
+
+	mailer := NewMailNotificationWithDB(MailConfig{}, db, 7)
+	require.NoError(t, mailer.SendHTML("a@example.com", "Welcome", "<p>hi</p>"))
+	q.RunDue(context.Background())
+
+	letters, err := q.DeadLetters(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, JobTypeMail, letters[0].Type)
+
+	logs, total, err := GetMailLogsWithStatus(db, 7, 1, 10, "failed")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, "550 mailbox unavailable", logs[0].ErrorMsg)
+}
+
+func TestDeliverSMSCode(t *testing.T) {
+	sms := &fakeSMSProvider{}
+	q, _ := newTestJobQueue(t, &fakeMailProvider{}, sms)
+
+	require.NoError(t, DeliverSMSCode(context.Background(), nil, "+8613800138000", "123456"))
+	assert.Empty(t, sms.sent)
+	q.RunDue(context.Background())
+	assert.Equal(t, []string{"+8613800138000:123456"}, sms.sent)
+
+	// Invalid numbers are not retried
+	sms.err = ErrSMSInvalidPhone
+	q.MaxAttempts = 5
+	require.NoError(t, DeliverSMSCode(context.Background(), nil, "+1", "123456"))
+	q.RunDue(context.Background())
+	letters, err := q.DeadLetters(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 1, letters[0].Attempts)
+}
+
+func TestSMSJob_CodeRedacted(t *testing.T) {
+	sms := &fakeSMSProvider{}
+	q, _ := newTestJobQueue(t, &fakeMailProvider{}, sms)
+	ctx := context.Background()
+
+	delivered, err := q.Enqueue(ctx, JobTypeSMS, SMSJob{Phone: "+8613800138000", Code: "123456"})
+	require.NoError(t, err)
+	q.RunDue(ctx)
+	sms.err = errors.New("gateway timeout")
+	dead, err := q.Enqueue(ctx, JobTypeSMS, SMSJob{Phone: "+8613800138001", Code: "654321"})
+	require.NoError(t, err)
+	q.RunDue(ctx)
+
+	for _, id := range []string{delivered.ID, dead.ID} {
+		job, err := q.Get(ctx, id)
+		require.NoError(t, err)
+		var m SMSJob
+		require.NoError(t, job.Decode(&m))
+		assert.NotEmpty(t, m.Phone)
+		assert.Empty(t, m.Code, "job %s keeps its code", job.Status)
+	}
+
+	// A requeued dead letter can't resend the removed code
+	sms.err = nil
+	_, err = q.Requeue(ctx, dead.ID)
+	require.NoError(t, err)
+	q.RunDue(ctx)
+	assert.Len(t, sms.sent, 2)
+	job, err := q.Get(ctx, dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, queue.StatusDead, job.Status)
+}
+
+func TestDeliverSMSCode_Direct(t *testing.T) {
+	sms := &fakeSMSProvider{}
+	require.NoError(t, DeliverSMSCode(context.Background(), sms, "+8613800138000", "654321"))
+	assert.Equal(t, []string{"+8613800138000:654321"}, sms.sent)
+}
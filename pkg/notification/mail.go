@@ -2,6 +2,7 @@ package notification
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
 
@@ -32,12 +33,14 @@ type MailConfig struct {
 	APIKey  string `json:"api_key"`  // SendCloud API Key
 
 	// Common
-	From string `json:"from"` // Sender email address
+	From     string `json:"from"`      // Sender email address
+	FromName string `json:"from_name"` // Sender display name, optional
 }
 
 // MailNotification email notification service (supports SMTP and SendCloud)
 type MailNotification struct {
 	provider  MailProvider
+	config    MailConfig
 	DB        *gorm.DB
 	UserID    uint
 	IPAddress string // For tracking emails sent without user context
@@ -48,6 +51,7 @@ func NewMailNotification(config MailConfig) *MailNotification {
 	provider := createProvider(config)
 	return &MailNotification{
 		provider: provider,
+		config:   config,
 	}
 }
 
@@ -56,6 +60,7 @@ func NewMailNotificationWithDB(config MailConfig, db *gorm.DB, userID uint) *Mai
 	provider := createProvider(config)
 	return &MailNotification{
 		provider: provider,
+		config:   config,
 		DB:       db,
 		UserID:   userID,
 	}
@@ -66,6 +71,7 @@ func NewMailNotificationWithIP(config MailConfig, db *gorm.DB, ipAddress string)
 	provider := createProvider(config)
 	return &MailNotification{
 		provider:  provider,
+		config:    config,
 		DB:        db,
 		IPAddress: ipAddress,
 	}
@@ -75,9 +81,10 @@ func NewMailNotificationWithIP(config MailConfig, db *gorm.DB, ipAddress string)
 func createProvider(config MailConfig) MailProvider {
 	if config.Provider == "sendcloud" {
 		return NewSendCloudClient(SendCloudConfig{
-			APIUser: config.APIUser,
-			APIKey:  config.APIKey,
-			From:    config.From,
+			APIUser:  config.APIUser,
+			APIKey:   config.APIKey,
+			From:     config.From,
+			FromName: config.FromName,
 		})
 	}
 	// Default to SMTP
@@ -87,27 +94,32 @@ func createProvider(config MailConfig) MailProvider {
 		Username: config.Username,
 		Password: config.Password,
 		From:     config.From,
+		FromName: config.FromName,
 	})
 }
 
 // Send sends email
 func (m *MailNotification) Send(to, subject, body string) error {
-	messageID, err := m.provider.SendHTML(to, subject, body)
-
-	// Only log if we have a messageID
-	if messageID != "" && m.DB != nil {
-		if m.UserID > 0 {
-			CreateMailLog(m.DB, m.UserID, to, subject, messageID)
-		} else if m.IPAddress != "" {
-			CreateMailLogWithIP(m.DB, 0, to, subject, messageID, m.IPAddress)
-		}
-	}
-
-	return err
+	return m.SendHTML(to, subject, body)
 }
 
-// SendHTML sends HTML email
+// SendHTML sends HTML email. Once the job queue is registered the email is queued and
+// retried by the workers, the returned error only covers queueing
 func (m *MailNotification) SendHTML(to, subject, htmlBody string) error {
+	if q := JobQueue(); q != nil {
+		_, err := q.Enqueue(context.Background(), JobTypeMail, MailJob{
+			To:        to,
+			Subject:   subject,
+			HTML:      htmlBody,
+			From:      m.config.From,
+			FromName:  m.config.FromName,
+			UserID:    m.UserID,
+			IPAddress: m.IPAddress,
+			Track:     m.DB != nil && (m.UserID > 0 || m.IPAddress != ""),
+		})
+		return err
+	}
+
 	messageID, err := m.provider.SendHTML(to, subject, htmlBody)
 
 	logger.Info("Email sent via provider",
@@ -203,6 +215,42 @@ func (m *MailNotification) SendPasswordResetEmail(to, username, resetURL string)
 	return m.SendHTML(to, "密码重置请求", htmlBody)
 }
 
+// SendAccountUnlockEmail sends the self-service unlock link of a locked account
+func (m *MailNotification) SendAccountUnlockEmail(to, username, unlockURL, unlockAt string) error {
+	data := map[string]string{
+		"Username":  username,
+		"UnlockURL": unlockURL,
+		"UnlockAt":  unlockAt,
+	}
+
+	htmlBody, err := renderTemplate(LingEcho.AccountUnlockHTML, data)
+	if err != nil {
+		return err
+	}
+
+	return m.SendHTML(to, "解锁您的 LingEcho 账号", htmlBody)
+}
+
+// SendAccountUnlockedEmail tells the user a login lock was lifted by an admin or expired
+func (m *MailNotification) SendAccountUnlockedEmail(to, username, lockedAt, unlockedAt, ipAddress string, failedAttempts int, byAdmin bool, resetPasswordURL string) error {
+	data := map[string]interface{}{
+		"Username":         username,
+		"LockedAt":         lockedAt,
+		"UnlockedAt":       unlockedAt,
+		"IPAddress":        ipAddress,
+		"FailedAttempts":   failedAttempts,
+		"ByAdmin":          byAdmin,
+		"ResetPasswordURL": resetPasswordURL,
+	}
+
+	htmlBody, err := renderTemplate(LingEcho.AccountUnlockedHTML, data)
+	if err != nil {
+		return err
+	}
+
+	return m.SendHTML(to, "您的账号已解锁", htmlBody)
+}
+
 // SendDeviceVerificationCode sends device verification code email using embedded template
 func (m *MailNotification) SendDeviceVerificationCode(to, username, code, deviceID string) error {
 	data := map[string]string{
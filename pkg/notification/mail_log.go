@@ -50,6 +50,23 @@ func CreateMailLogWithIP(db *gorm.DB, userID uint, toEmail, subject, messageID,
 	return log, nil
 }
 
+// CreateFailedMailLog records an email that could not be delivered
+func CreateFailedMailLog(db *gorm.DB, userID uint, toEmail, subject, ipAddress, errorMsg string) (*MailLog, error) {
+	log := &MailLog{
+		UserID:    userID,
+		ToEmail:   toEmail,
+		Subject:   subject,
+		Status:    "failed",
+		ErrorMsg:  errorMsg,
+		IPAddress: ipAddress,
+		SentAt:    time.Now(),
+	}
+	if err := db.Create(log).Error; err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
 // UpdateMailLogStatus updates the status of a mail log
 func UpdateMailLogStatus(db *gorm.DB, messageID, status, errorMsg string) error {
 	return db.Model(&MailLog{}).
@@ -0,0 +1,443 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
+)
+
+// Push platforms of registered device tokens
+const (
+	PushPlatformAndroid = "android" // Firebase Cloud Messaging
+	PushPlatformIOS     = "ios"     // Apple Push Notification service
+)
+
+const (
+	fcmScope        = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmEndpoint     = "https://fcm.googleapis.com"
+	apnsEndpoint    = "https://api.push.apple.com"
+	apnsSandboxURL  = "https://api.sandbox.push.apple.com"
+	apnsTokenMaxAge = 40 * time.Minute // APNs rejects provider tokens older than an hour
+	pushBodyMaxLen  = 512              // runes, keeps payloads well under the 4KB limits
+	pushSendTimeout = 10 * time.Second
+)
+
+// ErrPushTokenInvalid the provider reports the device token as unregistered or
+// malformed, the caller should forget it
+var ErrPushTokenInvalid = errors.New("push token is no longer valid")
+
+// ErrPushNotConfigured no sender is configured for the platform
+var ErrPushNotConfigured = errors.New("push notifications are not configured for this platform")
+
+// PushConfig push notification configuration, FCM for Android and APNs for iOS.
+// Either may be left empty to disable the platform
+type PushConfig struct {
+	// FCM HTTP v1 API with a service account
+	FCMProjectID       string `env:"PUSH_FCM_PROJECT_ID"`
+	FCMCredentialsFile string `env:"PUSH_FCM_CREDENTIALS_FILE"` // Service account JSON key
+
+	// APNs token-based authentication with a .p8 signing key
+	APNsKeyID    string `env:"PUSH_APNS_KEY_ID"`
+	APNsTeamID   string `env:"PUSH_APNS_TEAM_ID"`
+	APNsBundleID string `env:"PUSH_APNS_BUNDLE_ID"` // Sent as apns-topic
+	APNsKeyFile  string `env:"PUSH_APNS_KEY_FILE"`
+	APNsSandbox  bool   `env:"PUSH_APNS_SANDBOX"` // Development builds of the app
+}
+
+// FCMEnabled reports whether FCM is configured
+func (c PushConfig) FCMEnabled() bool {
+	return c.FCMProjectID != "" || c.FCMCredentialsFile != ""
+}
+
+// APNsEnabled reports whether APNs is configured
+func (c PushConfig) APNsEnabled() bool {
+	return c.APNsKeyID != "" || c.APNsTeamID != "" || c.APNsBundleID != "" || c.APNsKeyFile != ""
+}
+
+// PushMessage a rendered push notification
+type PushMessage struct {
+	Title    string
+	Body     string
+	Data     map[string]string // Delivered to the app alongside the alert
+	Critical bool              // Sent with high priority
+}
+
+// PushSender delivers a message to one device token
+type PushSender interface {
+	Send(ctx context.Context, token string, msg PushMessage) error
+}
+
+// PushNotification sends push notifications to Android and iOS devices
+type PushNotification struct {
+	senders map[string]PushSender
+}
+
+// NewPushNotification creates the senders of the configured platforms
+func NewPushNotification(config PushConfig) (*PushNotification, error) {
+	p := &PushNotification{senders: map[string]PushSender{}}
+	if config.FCMEnabled() {
+		credentials, err := os.ReadFile(config.FCMCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read FCM credentials: %w", err)
+		}
+		fcm, err := NewFCMSender(config.FCMProjectID, credentials)
+		if err != nil {
+			return nil, err
+		}
+		p.senders[PushPlatformAndroid] = fcm
+	}
+	if config.APNsEnabled() {
+		key, err := os.ReadFile(config.APNsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read APNs key: %w", err)
+		}
+		apns, err := NewAPNsSender(config.APNsKeyID, config.APNsTeamID, config.APNsBundleID, key, config.APNsSandbox)
+		if err != nil {
+			return nil, err
+		}
+		p.senders[PushPlatformIOS] = apns
+	}
+	return p, nil
+}
+
+// SetSender replaces the sender of a platform, e.g. with another push provider
+func (p *PushNotification) SetSender(platform string, sender PushSender) {
+	if p.senders == nil {
+		p.senders = map[string]PushSender{}
+	}
+	p.senders[platform] = sender
+}
+
+// Supports reports whether a sender is configured for the platform
+func (p *PushNotification) Supports(platform string) bool {
+	return p != nil && p.senders[platform] != nil
+}
+
+// Send sends a message to a device token of the platform
+func (p *PushNotification) Send(ctx context.Context, platform, token string, msg PushMessage) error {
+	if !p.Supports(platform) {
+		return ErrPushNotConfigured
+	}
+	if utf8.RuneCountInString(msg.Body) > pushBodyMaxLen {
+		msg.Body = string([]rune(msg.Body)[:pushBodyMaxLen-1]) + "…"
+	}
+	ctx, cancel := context.WithTimeout(ctx, pushSendTimeout)
+	defer cancel()
+	return p.senders[platform].Send(ctx, token, msg)
+}
+
+// FCMSender sends through the Firebase Cloud Messaging HTTP v1 API
+type FCMSender struct {
+	ProjectID string
+	Endpoint  string
+	client    *http.Client
+}
+
+// NewFCMSender creates an FCM sender from a service account JSON key. The
+// project ID defaults to the one in the key
+func NewFCMSender(projectID string, credentialsJSON []byte) (*FCMSender, error) {
+	var key struct {
+		ProjectID    string `json:"project_id"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(credentialsJSON, &key); err != nil {
+		return nil, fmt.Errorf("invalid FCM credentials: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, errors.New("invalid FCM credentials: client_email and private_key are required")
+	}
+	if projectID == "" {
+		projectID = key.ProjectID
+	}
+	if projectID == "" {
+		return nil, errors.New("FCM project ID is required")
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	conf := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		Scopes:       []string{fcmScope},
+		TokenURL:     key.TokenURI,
+	}
+	return newFCMSender(projectID, conf.TokenSource(context.Background())), nil
+}
+
+func newFCMSender(projectID string, ts oauth2.TokenSource) *FCMSender {
+	return &FCMSender{
+		ProjectID: projectID,
+		Endpoint:  fcmEndpoint,
+		client:    oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, xhttp.NewClient("fcm", 0)), ts),
+	}
+}
+
+// Send sends a message to a registration token
+func (s *FCMSender) Send(ctx context.Context, token string, msg PushMessage) error {
+	priority := "normal"
+	if msg.Critical {
+		priority = "high"
+	}
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        token,
+			"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+			"data":         msg.Data,
+			"android":      map[string]string{"priority": priority},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", s.Endpoint, url.PathEscape(s.ProjectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	// Tokens of uninstalled apps come back as 404 UNREGISTERED
+	if resp.StatusCode == http.StatusNotFound || bytes.Contains(respBody, []byte("UNREGISTERED")) {
+		return ErrPushTokenInvalid
+	}
+	if resp.StatusCode == http.StatusBadRequest && bytes.Contains(respBody, []byte("registration token")) {
+		return ErrPushTokenInvalid
+	}
+	return fmt.Errorf("FCM send failed: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+}
+
+// APNsSender sends through the APNs HTTP/2 provider API with token-based authentication
+type APNsSender struct {
+	KeyID    string
+	TeamID   string
+	BundleID string
+	Endpoint string
+	client   *http.Client
+	key      *ecdsa.PrivateKey
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNsSender creates an APNs sender from a .p8 signing key
+func NewAPNsSender(keyID, teamID, bundleID string, keyPEM []byte, sandbox bool) (*APNsSender, error) {
+	if keyID == "" || teamID == "" || bundleID == "" {
+		return nil, errors.New("APNs key ID, team ID and bundle ID are required")
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("invalid APNs key: no PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid APNs key: not an ECDSA key")
+	}
+	endpoint := apnsEndpoint
+	if sandbox {
+		endpoint = apnsSandboxURL
+	}
+	return &APNsSender{
+		KeyID:    keyID,
+		TeamID:   teamID,
+		BundleID: bundleID,
+		Endpoint: endpoint,
+		client:   xhttp.NewClient("apns", 0),
+		key:      key,
+	}, nil
+}
+
+// Send sends an alert to a device token
+func (s *APNsSender) Send(ctx context.Context, token string, msg PushMessage) error {
+	jwtToken, err := s.providerToken(time.Now())
+	if err != nil {
+		return err
+	}
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint+"/3/device/"+url.PathEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	priority := "5"
+	if msg.Critical {
+		priority = "10"
+	}
+	req.Header.Set("authorization", "bearer "+jwtToken)
+	req.Header.Set("apns-topic", s.BundleID)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", priority)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var reason struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&reason)
+	switch {
+	case resp.StatusCode == http.StatusGone, reason.Reason == "BadDeviceToken", reason.Reason == "Unregistered", reason.Reason == "DeviceTokenNotForTopic":
+		return ErrPushTokenInvalid
+	case reason.Reason == "ExpiredProviderToken":
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("APNs send failed: %s: %s", resp.Status, reason.Reason)
+}
+
+// providerToken returns the cached ES256 provider token, signing a new one when it is about to expire
+func (s *APNsSender) providerToken(now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && now.Sub(s.issuedAt) < apnsTokenMaxAge {
+		return s.token, nil
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": s.KeyID})
+	claims, _ := json.Marshal(map[string]any{"iss": s.TeamID, "iat": now.Unix()})
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signing))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS encodes the signature as the fixed-size concatenation of r and s
+	raw := make([]byte, 64)
+	fillBigInt(raw[:32], r)
+	fillBigInt(raw[32:], sig)
+	s.token = signing + "." + base64.RawURLEncoding.EncodeToString(raw)
+	s.issuedAt = now
+	return s.token, nil
+}
+
+func fillBigInt(dst []byte, n *big.Int) {
+	b := n.Bytes()
+	copy(dst[len(dst)-len(b):], b)
+}
+
+// PushTemplate title and body of a push notification, rendered with text/template
+type PushTemplate struct {
+	Title string
+	Body  string
+}
+
+// Built-in push templates
+const (
+	PushTemplateDeviceOffline   = "device_offline"
+	PushTemplateSuspiciousLogin = "suspicious_login"
+	PushTemplateMissedCall      = "missed_call"
+)
+
+var (
+	pushTemplatesMu sync.RWMutex
+	pushTemplates   = map[string]PushTemplate{
+		PushTemplateDeviceOffline: {
+			Title: "Device offline",
+			Body:  "{{.deviceName}} has not reported since {{.lastSeen}} and is now offline.",
+		},
+		PushTemplateSuspiciousLogin: {
+			Title: "Suspicious login to your account",
+			Body:  "A login from {{if .location}}{{.location}} {{end}}({{.clientIp}}) at {{.loginTime}} looks unusual. If this wasn't you, change your password now.",
+		},
+		PushTemplateMissedCall: {
+			Title: "Missed call",
+			Body:  "{{if .from}}{{.from}}{{else}}A caller{{end}} called {{if .to}}{{.to}} {{end}}at {{.time}} and was not answered.",
+		},
+	}
+)
+
+// RegisterPushTemplate adds or replaces a push template
+func RegisterPushTemplate(name string, tmpl PushTemplate) {
+	pushTemplatesMu.Lock()
+	defer pushTemplatesMu.Unlock()
+	pushTemplates[name] = tmpl
+}
+
+// RenderPush renders the named push template with data. The built-in templates
+// take a map[string]string with camelCase keys, which is also sent to the app
+func RenderPush(name string, data any) (PushMessage, error) {
+	pushTemplatesMu.RLock()
+	tmpl, ok := pushTemplates[name]
+	pushTemplatesMu.RUnlock()
+	if !ok {
+		return PushMessage{}, fmt.Errorf("unknown push template %q", name)
+	}
+	title, err := renderPushText(name+".title", tmpl.Title, data)
+	if err != nil {
+		return PushMessage{}, err
+	}
+	body, err := renderPushText(name+".body", tmpl.Body, data)
+	if err != nil {
+		return PushMessage{}, err
+	}
+	return PushMessage{Title: title, Body: body}, nil
+}
+
+func renderPushText(name, text string, data any) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse push template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render push template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,145 @@
+package notification
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestFCMSender_Send(t *testing.T) {
+	var got map[string]map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/demo/messages:send", r.URL.Path)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got["message"]["token"] == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"projects/demo/messages/1"}`))
+	}))
+	defer server.Close()
+
+	s := newFCMSender("demo", oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"}))
+	s.Endpoint = server.URL
+	p := &PushNotification{senders: map[string]PushSender{PushPlatformAndroid: s}}
+
+	err := p.Send(context.Background(), PushPlatformAndroid, "tok", PushMessage{Title: "t", Body: "b", Data: map[string]string{"event": "x"}, Critical: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"title": "t", "body": "b"}, got["message"]["notification"])
+	assert.Equal(t, map[string]any{"priority": "high"}, got["message"]["android"])
+	assert.Equal(t, map[string]any{"event": "x"}, got["message"]["data"])
+
+	err = p.Send(context.Background(), PushPlatformAndroid, "gone", PushMessage{Title: "t"})
+	assert.ErrorIs(t, err, ErrPushTokenInvalid)
+
+	err = p.Send(context.Background(), PushPlatformIOS, "tok", PushMessage{Title: "t"})
+	assert.ErrorIs(t, err, ErrPushNotConfigured)
+}
+
+func TestAPNsSender_Send(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	var auths []string
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("authorization"))
+		assert.Equal(t, "com.example.app", r.Header.Get("apns-topic"))
+		assert.Equal(t, "alert", r.Header.Get("apns-push-type"))
+		if r.URL.Path == "/3/device/gone" {
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"reason":"Unregistered"}`))
+			return
+		}
+		assert.Equal(t, "/3/device/abc", r.URL.Path)
+		assert.Equal(t, "5", r.Header.Get("apns-priority"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	s, err := NewAPNsSender("KEY123", "TEAM456", "com.example.app", keyPEM, true)
+	require.NoError(t, err)
+	assert.Equal(t, apnsSandboxURL, s.Endpoint)
+	s.Endpoint = server.URL
+
+	require.NoError(t, s.Send(context.Background(), "abc", PushMessage{Title: "t", Body: "b", Data: map[string]string{"callId": "c1"}}))
+	assert.Equal(t, "c1", payload["callId"])
+	assert.Equal(t, map[string]any{"title": "t", "body": "b"}, payload["aps"].(map[string]any)["alert"])
+
+	assert.ErrorIs(t, s.Send(context.Background(), "gone", PushMessage{}), ErrPushTokenInvalid)
+	require.Len(t, auths, 2)
+	assert.Equal(t, auths[0], auths[1], "provider token is reused")
+
+	// The provider token is an ES256 JWT signed with the key
+	parts := strings.Split(strings.TrimPrefix(auths[0], "bearer "), ".")
+	require.Len(t, parts, 3)
+	header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	assert.JSONEq(t, `{"alg":"ES256","kid":"KEY123"}`, string(header))
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	require.Len(t, sig, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])))
+
+	refreshed, err := s.providerToken(time.Now().Add(apnsTokenMaxAge + time.Minute))
+	require.NoError(t, err)
+	assert.NotEqual(t, strings.TrimPrefix(auths[0], "bearer "), refreshed)
+}
+
+func TestNewAPNsSender_InvalidKey(t *testing.T) {
+	_, err := NewAPNsSender("k", "t", "b", []byte("not a key"), false)
+	assert.Error(t, err)
+	_, err = NewAPNsSender("", "t", "b", nil, false)
+	assert.Error(t, err)
+}
+
+func TestRenderPush(t *testing.T) {
+	msg, err := RenderPush(PushTemplateMissedCall, map[string]string{"from": "1001", "time": "10:30"})
+	require.NoError(t, err)
+	assert.Equal(t, "Missed call", msg.Title)
+	assert.Equal(t, "1001 called at 10:30 and was not answered.", msg.Body)
+
+	RegisterPushTemplate("custom", PushTemplate{Title: "Hi {{.Name}}", Body: "{{.Name}}!"})
+	msg, err = RenderPush("custom", map[string]string{"Name": "Ann"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Ann", msg.Title)
+
+	_, err = RenderPush("nope", nil)
+	assert.Error(t, err)
+}
+
+func TestPushNotification_TruncatesBody(t *testing.T) {
+	var body string
+	p := &PushNotification{senders: map[string]PushSender{PushPlatformAndroid: pushSenderFunc(func(msg PushMessage) {
+		body = msg.Body
+	})}}
+	require.NoError(t, p.Send(context.Background(), PushPlatformAndroid, "tok", PushMessage{Body: strings.Repeat("字", 600)}))
+	assert.Equal(t, pushBodyMaxLen, len([]rune(body)))
+}
+
+type pushSenderFunc func(msg PushMessage)
+
+func (f pushSenderFunc) Send(_ context.Context, _ string, msg PushMessage) error {
+	f(msg)
+	return nil
+}
@@ -8,13 +8,16 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
 )
 
 // SendCloudConfig SendCloud configuration
 type SendCloudConfig struct {
-	APIUser string // API User
-	APIKey  string // API Key
-	From    string // Sender email address
+	APIUser  string // API User
+	APIKey   string // API Key
+	From     string // Sender email address
+	FromName string // Sender display name, optional
 }
 
 // SendCloudClient SendCloud API client
@@ -37,9 +40,7 @@ type SendCloudWebhookEvent struct {
 func NewSendCloudClient(config SendCloudConfig) *SendCloudClient {
 	return &SendCloudClient{
 		Config: config,
-		Client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		Client: xhttp.NewClient("sendcloud", 30*time.Second),
 	}
 }
 
@@ -58,6 +59,9 @@ func (s *SendCloudClient) SendHTML(to, subject, htmlBody string) (string, error)
 	data.Set("apiKey", s.Config.APIKey)
 	data.Set("to", to)
 	data.Set("from", s.Config.From)
+	if s.Config.FromName != "" {
+		data.Set("fromName", s.Config.FromName)
+	}
 	data.Set("subject", subject)
 	data.Set("html", htmlBody)
 
@@ -142,6 +146,9 @@ func (s *SendCloudClient) SendBatch(recipients []string, subject, htmlBody strin
 	data.Set("apiKey", s.Config.APIKey)
 	data.Set("to", toList)
 	data.Set("from", s.Config.From)
+	if s.Config.FromName != "" {
+		data.Set("fromName", s.Config.FromName)
+	}
 	data.Set("subject", subject)
 	data.Set("html", htmlBody)
 
@@ -0,0 +1,290 @@
+package notification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
+)
+
+// SMS providers
+const (
+	SMSProviderAliyun = "aliyun" // Aliyun Short Message Service
+	SMSProviderTwilio = "twilio" // Twilio Programmable Messaging
+)
+
+const (
+	aliyunSMSEndpoint  = "https://dysmsapi.aliyuncs.com"
+	twilioEndpoint     = "https://api.twilio.com"
+	smsSendTimeout     = 10 * time.Second
+	defaultTwilioBody  = "Your verification code is {code}. It expires in 5 minutes."
+	smsCodePlaceholder = "{code}"
+)
+
+// ErrSMSNotConfigured no SMS provider is configured
+var ErrSMSNotConfigured = errors.New("SMS is not configured")
+
+// ErrSMSInvalidPhone the provider rejected the phone number
+var ErrSMSInvalidPhone = errors.New("invalid phone number")
+
+// SMSConfig SMS configuration, Provider selects which of the provider settings is used
+type SMSConfig struct {
+	Provider string `env:"SMS_PROVIDER"` // aliyun or twilio, empty disables SMS
+
+	// Aliyun SMS, the template must have a ${code} variable
+	AliyunAccessKeyID     string `env:"SMS_ALIYUN_ACCESS_KEY_ID"`
+	AliyunAccessKeySecret string `env:"SMS_ALIYUN_ACCESS_KEY_SECRET"`
+	AliyunSignName        string `env:"SMS_ALIYUN_SIGN_NAME"`
+	AliyunTemplateCode    string `env:"SMS_ALIYUN_TEMPLATE_CODE"`
+
+	// Twilio, From is a Twilio number or a messaging service SID (MG...)
+	TwilioAccountSID string `env:"SMS_TWILIO_ACCOUNT_SID"`
+	TwilioAuthToken  string `env:"SMS_TWILIO_AUTH_TOKEN"`
+	TwilioFrom       string `env:"SMS_TWILIO_FROM"`
+	TwilioBody       string `env:"SMS_TWILIO_BODY"` // {code} is replaced with the code
+}
+
+// Enabled reports whether an SMS provider is selected
+func (c SMSConfig) Enabled() bool {
+	return c.Provider != ""
+}
+
+// SMSProvider sends one-time verification codes by text message
+type SMSProvider interface {
+	// Name returns the provider name, e.g. aliyun
+	Name() string
+	// SendCode sends the code to a phone number in E.164 format
+	SendCode(ctx context.Context, phone, code string) error
+}
+
+// NewSMSProvider creates the configured provider, ErrSMSNotConfigured when none is selected
+func NewSMSProvider(config SMSConfig) (SMSProvider, error) {
+	switch config.Provider {
+	case "":
+		return nil, ErrSMSNotConfigured
+	case SMSProviderAliyun:
+		return NewAliyunSMS(config.AliyunAccessKeyID, config.AliyunAccessKeySecret, config.AliyunSignName, config.AliyunTemplateCode)
+	case SMSProviderTwilio:
+		return NewTwilioSMS(config.TwilioAccountSID, config.TwilioAuthToken, config.TwilioFrom, config.TwilioBody)
+	default:
+		return nil, fmt.Errorf("unknown SMS provider %q", config.Provider)
+	}
+}
+
+// SendSMSCode sends a code with the send timeout applied
+func SendSMSCode(ctx context.Context, provider SMSProvider, phone, code string) error {
+	if provider == nil {
+		return ErrSMSNotConfigured
+	}
+	ctx, cancel := context.WithTimeout(ctx, smsSendTimeout)
+	defer cancel()
+	return provider.SendCode(ctx, phone, code)
+}
+
+// AliyunSMS sends through the Aliyun SMS SendSms API with RPC signature v1
+type AliyunSMS struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SignName        string
+	TemplateCode    string
+	Endpoint        string
+	client          *http.Client
+	now             func() time.Time
+}
+
+// NewAliyunSMS creates an Aliyun SMS provider
+func NewAliyunSMS(accessKeyID, accessKeySecret, signName, templateCode string) (*AliyunSMS, error) {
+	if accessKeyID == "" || accessKeySecret == "" {
+		return nil, errors.New("Aliyun SMS access key ID and secret are required")
+	}
+	if signName == "" || templateCode == "" {
+		return nil, errors.New("Aliyun SMS sign name and template code are required")
+	}
+	return &AliyunSMS{
+		AccessKeyID:     accessKeyID,
+		AccessKeySecret: accessKeySecret,
+		SignName:        signName,
+		TemplateCode:    templateCode,
+		Endpoint:        aliyunSMSEndpoint,
+		client:          xhttp.NewClient("aliyun-sms", 0),
+		now:             time.Now,
+	}, nil
+}
+
+// Name returns aliyun
+func (s *AliyunSMS) Name() string {
+	return SMSProviderAliyun
+}
+
+// SendCode sends the code with the configured template
+func (s *AliyunSMS) SendCode(ctx context.Context, phone, code string) error {
+	param, err := json.Marshal(map[string]string{"code": code})
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	params := url.Values{}
+	params.Set("AccessKeyId", s.AccessKeyID)
+	params.Set("Action", "SendSms")
+	params.Set("Format", "JSON")
+	params.Set("PhoneNumbers", strings.TrimPrefix(phone, "+"))
+	params.Set("RegionId", "cn-hangzhou")
+	params.Set("SignName", s.SignName)
+	params.Set("SignatureMethod", "HMAC-SHA1")
+	params.Set("SignatureNonce", hex.EncodeToString(nonce))
+	params.Set("SignatureVersion", "1.0")
+	params.Set("TemplateCode", s.TemplateCode)
+	params.Set("TemplateParam", string(param))
+	params.Set("Timestamp", s.now().UTC().Format("2006-01-02T15:04:05Z"))
+	params.Set("Version", "2017-05-25")
+	params.Set("Signature", aliyunSignature(http.MethodGet, params, s.AccessKeySecret))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Endpoint+"/?"+aliyunCanonicalQuery(params), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Code      string `json:"Code"`
+		Message   string `json:"Message"`
+		RequestID string `json:"RequestId"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result); err != nil {
+		return fmt.Errorf("Aliyun SMS send failed: %s", resp.Status)
+	}
+	switch result.Code {
+	case "OK":
+		return nil
+	case "isv.MOBILE_NUMBER_ILLEGAL", "isv.MOBILE_COUNT_OVER_LIMIT":
+		return ErrSMSInvalidPhone
+	}
+	return fmt.Errorf("Aliyun SMS send failed: %s: %s (request %s)", result.Code, result.Message, result.RequestID)
+}
+
+// aliyunEncode percent-encodes as required by the signature: spaces as %20, * as %2A, ~ kept
+func aliyunEncode(s string) string {
+	s = url.QueryEscape(s)
+	return strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").Replace(s)
+}
+
+// aliyunCanonicalQuery sorts and encodes the parameters
+func aliyunCanonicalQuery(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, aliyunEncode(k)+"="+aliyunEncode(params.Get(k)))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// aliyunSignature signs the parameters, Signature itself excluded
+func aliyunSignature(method string, params url.Values, secret string) string {
+	unsigned := url.Values{}
+	for k, v := range params {
+		if k != "Signature" {
+			unsigned[k] = v
+		}
+	}
+	stringToSign := method + "&" + aliyunEncode("/") + "&" + aliyunEncode(aliyunCanonicalQuery(unsigned))
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TwilioSMS sends through the Twilio Messages API
+type TwilioSMS struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	Body       string
+	Endpoint   string
+	client     *http.Client
+}
+
+// NewTwilioSMS creates a Twilio provider, body defaults to an English message
+func NewTwilioSMS(accountSID, authToken, from, body string) (*TwilioSMS, error) {
+	if accountSID == "" || authToken == "" || from == "" {
+		return nil, errors.New("Twilio account SID, auth token and sender are required")
+	}
+	if body == "" {
+		body = defaultTwilioBody
+	}
+	if !strings.Contains(body, smsCodePlaceholder) {
+		return nil, errors.New("Twilio message body must contain {code}")
+	}
+	return &TwilioSMS{
+		AccountSID: accountSID,
+		AuthToken:  authToken,
+		From:       from,
+		Body:       body,
+		Endpoint:   twilioEndpoint,
+		client:     xhttp.NewClient("twilio", 0),
+	}, nil
+}
+
+// Name returns twilio
+func (s *TwilioSMS) Name() string {
+	return SMSProviderTwilio
+}
+
+// SendCode sends the code in the configured message body
+func (s *TwilioSMS) SendCode(ctx context.Context, phone, code string) error {
+	form := url.Values{}
+	form.Set("To", phone)
+	if strings.HasPrefix(s.From, "MG") {
+		form.Set("MessagingServiceSid", s.From)
+	} else {
+		form.Set("From", s.From)
+	}
+	form.Set("Body", strings.ReplaceAll(s.Body, smsCodePlaceholder, code))
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.Endpoint, url.PathEscape(s.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result)
+	// 21211 invalid To number, 21614 not a mobile number
+	if result.Code == 21211 || result.Code == 21614 {
+		return ErrSMSInvalidPhone
+	}
+	return fmt.Errorf("Twilio send failed: %s: %d %s", resp.Status, result.Code, result.Message)
+}
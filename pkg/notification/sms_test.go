@@ -0,0 +1,104 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliyunSMS_SendCode(t *testing.T) {
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		if got.Get("PhoneNumbers") == "123" {
+			_, _ = w.Write([]byte(`{"Code":"isv.MOBILE_NUMBER_ILLEGAL","Message":"invalid"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Code":"OK","Message":"OK","BizId":"1"}`))
+	}))
+	defer server.Close()
+
+	s, err := NewAliyunSMS("key", "secret", "LingEcho", "SMS_1")
+	require.NoError(t, err)
+	s.Endpoint = server.URL
+	s.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, SendSMSCode(context.Background(), s, "+8613800138000", "123456"))
+	assert.Equal(t, "8613800138000", got.Get("PhoneNumbers"))
+	assert.Equal(t, "SMS_1", got.Get("TemplateCode"))
+	assert.Equal(t, "2026-01-01T00:00:00Z", got.Get("Timestamp"))
+	var param map[string]string
+	require.NoError(t, json.Unmarshal([]byte(got.Get("TemplateParam")), &param))
+	assert.Equal(t, "123456", param["code"])
+	assert.Equal(t, aliyunSignature(http.MethodGet, got, "secret"), got.Get("Signature"))
+
+	assert.ErrorIs(t, s.SendCode(context.Background(), "123", "123456"), ErrSMSInvalidPhone)
+}
+
+func TestAliyunSignature(t *testing.T) {
+	// Example from the Aliyun RPC signature documentation
+	params := url.Values{}
+	params.Set("AccessKeyId", "testid")
+	params.Set("Action", "DescribeRegions")
+	params.Set("Format", "XML")
+	params.Set("SignatureMethod", "HMAC-SHA1")
+	params.Set("SignatureNonce", "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf")
+	params.Set("SignatureVersion", "1.0")
+	params.Set("Timestamp", "2016-02-23T12:46:24Z")
+	params.Set("Version", "2014-05-26")
+	assert.Equal(t, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=", aliyunSignature(http.MethodGet, params, "testsecret"))
+}
+
+func TestTwilioSMS_SendCode(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2010-04-01/Accounts/AC1/Messages.json", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "AC1", user)
+		assert.Equal(t, "token", pass)
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		if form.Get("To") == "+1" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":21211,"message":"The 'To' number +1 is not a valid phone number."}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"sid":"SM1"}`))
+	}))
+	defer server.Close()
+
+	s, err := NewTwilioSMS("AC1", "token", "MG123", "Code: {code}")
+	require.NoError(t, err)
+	s.Endpoint = server.URL
+
+	require.NoError(t, s.SendCode(context.Background(), "+15550100", "654321"))
+	assert.Equal(t, "+15550100", form.Get("To"))
+	assert.Equal(t, "MG123", form.Get("MessagingServiceSid"))
+	assert.Equal(t, "Code: 654321", form.Get("Body"))
+
+	assert.ErrorIs(t, s.SendCode(context.Background(), "+1", "654321"), ErrSMSInvalidPhone)
+}
+
+func TestNewSMSProvider(t *testing.T) {
+	_, err := NewSMSProvider(SMSConfig{})
+	assert.ErrorIs(t, err, ErrSMSNotConfigured)
+
+	_, err = NewSMSProvider(SMSConfig{Provider: "carrier-pigeon"})
+	assert.Error(t, err)
+
+	_, err = NewSMSProvider(SMSConfig{Provider: SMSProviderTwilio, TwilioAccountSID: "AC1", TwilioAuthToken: "t", TwilioFrom: "+1555", TwilioBody: "no placeholder"})
+	assert.Error(t, err)
+
+	p, err := NewSMSProvider(SMSConfig{Provider: SMSProviderAliyun, AliyunAccessKeyID: "k", AliyunAccessKeySecret: "s", AliyunSignName: "n", AliyunTemplateCode: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, SMSProviderAliyun, p.Name())
+}
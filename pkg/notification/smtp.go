@@ -3,6 +3,7 @@ package notification
 import (
 	"crypto/tls"
 	"fmt"
+	"net/mail"
 	"net/smtp"
 	"time"
 )
@@ -14,6 +15,7 @@ type SMTPConfig struct {
 	Username string
 	Password string
 	From     string
+	FromName string // Display name in the From header, optional
 }
 
 // SMTPClient SMTP email client
@@ -28,12 +30,20 @@ func NewSMTPClient(config SMTPConfig) *SMTPClient {
 	}
 }
 
+// fromHeader formats the From header, with the display name when configured
+func (s *SMTPClient) fromHeader() string {
+	if s.Config.FromName == "" {
+		return s.Config.From
+	}
+	return (&mail.Address{Name: s.Config.FromName, Address: s.Config.From}).String()
+}
+
 // SendHTML sends HTML email via SMTP
 func (s *SMTPClient) SendHTML(to, subject, htmlBody string) (string, error) {
 	// Build MIME email message
 	msg := "MIME-Version: 1.0\r\n"
 	msg += "Content-Type: text/html; charset=\"UTF-8\"\r\n"
-	msg += fmt.Sprintf("From: %s\r\n", s.Config.From)
+	msg += fmt.Sprintf("From: %s\r\n", s.fromHeader())
 	msg += fmt.Sprintf("To: %s\r\n", to)
 	msg += fmt.Sprintf("Subject: %s\r\n", subject)
 	msg += "\r\n" + htmlBody
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+)
+
+// GitHubProvider signs users in with GitHub. The email comes from the user's
+// primary address, which GitHub reports as verified or not.
+type GitHubProvider struct {
+	ClientID     string
+	ClientSecret string
+	Endpoint     oauth2.Endpoint
+	APIBaseURL   string
+	Client       *http.Client
+}
+
+// NewGitHubProvider returns the provider with GitHub's endpoints
+func NewGitHubProvider(clientID, clientSecret string) *GitHubProvider {
+	return &GitHubProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://github.com/login/oauth/authorize",
+			TokenURL: "https://github.com/login/oauth/access_token",
+		},
+		APIBaseURL: "https://api.github.com",
+		Client:     httpClient,
+	}
+}
+
+func (p *GitHubProvider) Name() string { return ProviderGitHub }
+
+func (p *GitHubProvider) config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     p.Endpoint,
+		Scopes:       []string{"read:user", "user:email"},
+	}
+}
+
+func (p *GitHubProvider) AuthURL(state, redirectURL string) string {
+	return p.config(redirectURL).AuthCodeURL(state)
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code, redirectURL string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.Client)
+	token, err := p.config(redirectURL).Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := getJSON(ctx, p.Client, p.APIBaseURL+"/user", token.AccessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("oauth: github did not return an account ID")
+	}
+	identity := &Identity{
+		Provider:  ProviderGitHub,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Name:      user.Name,
+		AvatarURL: user.AvatarURL,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.Client, p.APIBaseURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			break
+		}
+	}
+	return identity, nil
+}
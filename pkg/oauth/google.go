@@ -0,0 +1,77 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// GoogleProvider signs users in with Google (OpenID Connect scopes)
+type GoogleProvider struct {
+	ClientID     string
+	ClientSecret string
+	Endpoint     oauth2.Endpoint
+	UserInfoURL  string
+	Client       *http.Client
+}
+
+// NewGoogleProvider returns the provider with Google's endpoints
+func NewGoogleProvider(clientID, clientSecret string) *GoogleProvider {
+	return &GoogleProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL: "https://oauth2.googleapis.com/token",
+		},
+		UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		Client:      httpClient,
+	}
+}
+
+func (p *GoogleProvider) Name() string { return ProviderGoogle }
+
+func (p *GoogleProvider) config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     p.Endpoint,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+func (p *GoogleProvider) AuthURL(state, redirectURL string) string {
+	return p.config(redirectURL).AuthCodeURL(state, oauth2.SetAuthURLParam("prompt", "select_account"))
+}
+
+func (p *GoogleProvider) Exchange(ctx context.Context, code, redirectURL string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.Client)
+	token, err := p.config(redirectURL).Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+	if err := getJSON(ctx, p.Client, p.UserInfoURL, token.AccessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Sub == "" {
+		return nil, errors.New("oauth: google did not return an account ID")
+	}
+	return &Identity{
+		Provider:      ProviderGoogle,
+		Subject:       info.Sub,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+		AvatarURL:     info.Picture,
+	}, nil
+}
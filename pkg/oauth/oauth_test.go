@@ -0,0 +1,121 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func tokenHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "the-code", r.PostForm.Get("code"))
+		writeJSON(w, map[string]interface{}{"access_token": "at", "token_type": "bearer"})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(NewGitHubProvider("id", "secret"))
+	r.Register(NewGoogleProvider("id", "secret"))
+
+	assert.Equal(t, []string{ProviderGitHub, ProviderGoogle}, r.Names())
+	p, err := r.Get("GitHub")
+	require.NoError(t, err)
+	assert.Equal(t, ProviderGitHub, p.Name())
+	_, err = r.Get(ProviderWeChat)
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+func TestGoogleProvider_Exchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", tokenHandler(t))
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer at", r.Header.Get("Authorization"))
+		writeJSON(w, map[string]interface{}{"sub": "1081", "email": "ann@example.com", "email_verified": true, "name": "Ann"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := NewGoogleProvider("id", "secret")
+	p.Endpoint = oauth2.Endpoint{AuthURL: srv.URL + "/auth", TokenURL: srv.URL + "/token"}
+	p.UserInfoURL = srv.URL + "/userinfo"
+
+	u, err := url.Parse(p.AuthURL("st", "https://app/cb"))
+	require.NoError(t, err)
+	assert.Equal(t, "st", u.Query().Get("state"))
+	assert.Equal(t, "https://app/cb", u.Query().Get("redirect_uri"))
+
+	identity, err := p.Exchange(context.Background(), "the-code", "https://app/cb")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Provider: ProviderGoogle, Subject: "1081", Email: "ann@example.com", EmailVerified: true, Name: "Ann"}, identity)
+}
+
+func TestGitHubProvider_Exchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", tokenHandler(t))
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"id": 42, "login": "octo"})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]interface{}{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octo@example.com", "primary": true, "verified": false},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := NewGitHubProvider("id", "secret")
+	p.Endpoint = oauth2.Endpoint{AuthURL: srv.URL + "/auth", TokenURL: srv.URL + "/token"}
+	p.APIBaseURL = srv.URL
+
+	identity, err := p.Exchange(context.Background(), "the-code", "https://app/cb")
+	require.NoError(t, err)
+	assert.Equal(t, "42", identity.Subject)
+	assert.Equal(t, "octo", identity.Name)
+	assert.Equal(t, "octo@example.com", identity.Email)
+	assert.False(t, identity.EmailVerified, "unverified primary email is reported as such")
+}
+
+func TestWeChatProvider_Exchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sns/oauth2/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("code") != "the-code" {
+			writeJSON(w, map[string]interface{}{"errcode": 40029, "errmsg": "invalid code"})
+			return
+		}
+		writeJSON(w, map[string]interface{}{"access_token": "at", "openid": "o-1", "unionid": "u-1"})
+	})
+	mux.HandleFunc("/sns/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "o-1", r.URL.Query().Get("openid"))
+		writeJSON(w, map[string]interface{}{"nickname": "小王", "headimgurl": "https://img/1"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := NewWeChatProvider("wx1", "secret")
+	p.APIBaseURL = srv.URL
+	assert.Contains(t, p.AuthURL("st", "https://app/cb"), "scope=snsapi_login")
+
+	identity, err := p.Exchange(context.Background(), "the-code", "https://app/cb")
+	require.NoError(t, err)
+	assert.Equal(t, "u-1", identity.Subject, "unionid is preferred over openid")
+	assert.Empty(t, identity.Email)
+	assert.Equal(t, "小王", identity.Name)
+
+	_, err = p.Exchange(context.Background(), "bad", "https://app/cb")
+	assert.ErrorContains(t, err, "40029")
+}
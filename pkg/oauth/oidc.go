@@ -0,0 +1,402 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// ProviderOIDC is the enterprise single sign-on provider configured per deployment
+const ProviderOIDC = "oidc"
+
+const (
+	oidcDiscoveryTTL    = time.Hour
+	oidcKeysRefreshWait = time.Minute // minimum delay between JWKS refetches on an unknown key ID
+	oidcClockSkew       = time.Minute
+)
+
+// Group roles granted through the group claim mapping, the same values as the group member roles
+const (
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+// GroupGrant is a membership granted to users in an IdP group
+type GroupGrant struct {
+	GroupID uint
+	Role    string
+}
+
+// GroupMapping maps IdP group names to the memberships they grant
+type GroupMapping map[string][]GroupGrant
+
+// ParseGroupMapping parses "engineering=12:member,platform-admins=12:admin,platform-admins=7".
+// The role defaults to member
+func ParseGroupMapping(s string) (GroupMapping, error) {
+	mapping := GroupMapping{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, grant, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("oidc: invalid group mapping %q, expected idp-group=groupID[:role]", item)
+		}
+		idStr, role, _ := strings.Cut(strings.TrimSpace(grant), ":")
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("oidc: invalid group ID in mapping %q", item)
+		}
+		switch role {
+		case "":
+			role = GroupRoleMember
+		case GroupRoleAdmin, GroupRoleMember:
+		default:
+			return nil, fmt.Errorf("oidc: invalid role %q in mapping %q, expected admin or member", role, item)
+		}
+		mapping[name] = append(mapping[name], GroupGrant{GroupID: uint(id), Role: role})
+	}
+	return mapping, nil
+}
+
+// GroupIDs lists every group managed by the mapping
+func (m GroupMapping) GroupIDs() []uint {
+	seen := map[uint]bool{}
+	var ids []uint
+	for _, grants := range m {
+		for _, g := range grants {
+			if !seen[g.GroupID] {
+				seen[g.GroupID] = true
+				ids = append(ids, g.GroupID)
+			}
+		}
+	}
+	return ids
+}
+
+// Grants resolves the memberships for the user's IdP groups, admin wins over member
+func (m GroupMapping) Grants(groups []string) map[uint]string {
+	grants := map[uint]string{}
+	for _, name := range groups {
+		for _, g := range m[name] {
+			if grants[g.GroupID] != GroupRoleAdmin {
+				grants[g.GroupID] = g.Role
+			}
+		}
+	}
+	return grants
+}
+
+// oidcDiscovery is the subset of the provider metadata the relying party uses
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCProvider signs users in with an OpenID Connect provider: the endpoints come from
+// discovery, the code is bound to a PKCE verifier and the ID token is verified against
+// the provider's signing keys
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients
+	Scopes       []string
+	GroupsClaim  string // claim listing the user's groups, e.g. groups or roles
+	TrustEmail   bool   // treat the email as verified when the provider omits email_verified
+	Client       *http.Client
+
+	mu           sync.Mutex
+	discovery    *oidcDiscovery
+	discoveredAt time.Time
+	keys         map[string]crypto.PublicKey
+	keysAt       time.Time
+	now          func() time.Time
+}
+
+// NewOIDCProvider returns the provider for the issuer, discovery runs on first use
+func NewOIDCProvider(issuer, clientID, clientSecret string) *OIDCProvider {
+	return &OIDCProvider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+		GroupsClaim:  "groups",
+		Client:       httpClient,
+		now:          time.Now,
+	}
+}
+
+func (p *OIDCProvider) Name() string { return ProviderOIDC }
+
+// discover fetches the provider metadata, cached for an hour
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	if p.discovery != nil && p.now().Sub(p.discoveredAt) < oidcDiscoveryTTL {
+		d := p.discovery
+		p.mu.Unlock()
+		return d, nil
+	}
+	p.mu.Unlock()
+
+	var d oidcDiscovery
+	if err := getJSON(ctx, p.Client, p.Issuer+"/.well-known/openid-configuration", "", &d); err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", d.Issuer, p.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+	p.mu.Lock()
+	p.discovery, p.discoveredAt = &d, p.now()
+	p.mu.Unlock()
+	return &d, nil
+}
+
+func (p *OIDCProvider) config(d *oidcDiscovery, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     oauth2.Endpoint{AuthURL: d.AuthorizationEndpoint, TokenURL: d.TokenEndpoint},
+		Scopes:       p.Scopes,
+	}
+}
+
+// AuthURL is the provider's login page; verifier is the PKCE code verifier and nonce
+// binds the ID token to this login, both are kept with the state until the callback
+func (p *OIDCProvider) AuthURL(ctx context.Context, state, nonce, verifier, redirectURL string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.config(d, redirectURL).AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+// Exchange trades the code for tokens and returns the identity from the verified ID token,
+// completed from the userinfo endpoint when the token has no email
+func (p *OIDCProvider) Exchange(ctx context.Context, code, redirectURL, verifier, nonce string) (*Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.Client)
+	token, err := p.config(d, redirectURL).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+	claims, err := p.verifyIDToken(ctx, d, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, errors.New("oidc: id_token nonce does not match the login request")
+	}
+	if _, ok := claims["email"]; !ok && d.UserInfoEndpoint != "" {
+		var info jwt.MapClaims
+		if err := getJSON(ctx, p.Client, d.UserInfoEndpoint, token.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		if sub, _ := info["sub"].(string); sub != claims["sub"] {
+			return nil, errors.New("oidc: userinfo subject does not match the id_token")
+		}
+		for k, v := range info {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+	return p.identity(claims)
+}
+
+// identity maps the standard claims and the groups claim
+func (p *OIDCProvider) identity(claims jwt.MapClaims) (*Identity, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, errors.New("oidc: id_token has no subject")
+	}
+	id := &Identity{Provider: ProviderOIDC, Subject: sub}
+	id.Email, _ = claims["email"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = v
+	case string:
+		id.EmailVerified = v == "true"
+	case nil:
+		id.EmailVerified = p.TrustEmail && id.Email != ""
+	}
+	if id.Name, _ = claims["name"].(string); id.Name == "" {
+		id.Name, _ = claims["preferred_username"].(string)
+	}
+	id.AvatarURL, _ = claims["picture"].(string)
+	switch v := claims[p.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok && s != "" {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	case string:
+		for _, s := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+			id.Groups = append(id.Groups, s)
+		}
+	}
+	return id, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience and expiry of the ID token
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, d *oidcDiscovery, raw string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.signingKey(ctx, d, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(oidcClockSkew),
+		jwt.WithTimeFunc(p.now))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id_token: %w", err)
+	}
+	// With several audiences the token must have been issued to this client
+	if azp, ok := claims["azp"].(string); ok && azp != p.ClientID {
+		return nil, errors.New("oidc: id_token was issued to another client")
+	}
+	return claims, nil
+}
+
+// signingKey looks the key up by ID, refetching the key set once when the provider rotated keys
+func (p *OIDCProvider) signingKey(ctx context.Context, d *oidcDiscovery, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	keys, fetchedAt := p.keys, p.keysAt
+	p.mu.Unlock()
+	if key := pickKey(keys, kid); key != nil {
+		return key, nil
+	}
+	if keys != nil && p.now().Sub(fetchedAt) < oidcKeysRefreshWait {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, p.Client, d.JWKSURI, "", &set); err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+	keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	p.mu.Lock()
+	p.keys, p.keysAt = keys, p.now()
+	p.mu.Unlock()
+	if key := pickKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// pickKey finds the key by ID, a token without kid may only use a single-key set
+func pickKey(keys map[string]crypto.PublicKey, kid string) crypto.PublicKey {
+	if key, ok := keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
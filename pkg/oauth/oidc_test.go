@@ -0,0 +1,176 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// testIdP is an OpenID provider issuing ID tokens signed with a fresh RSA key
+type testIdP struct {
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	claims    jwt.MapClaims
+	challenge string // PKCE challenge received on the authorization request
+	userinfo  map[string]interface{}
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &testIdP{key: key}
+	mux := http.NewServeMux()
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                 idp.srv.URL,
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"userinfo_endpoint":      idp.srv.URL + "/userinfo",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "the-code", r.PostForm.Get("code"))
+		assert.Equal(t, idp.challenge, oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")), "PKCE verifier must match")
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		writeJSON(w, map[string]interface{}{"access_token": "at", "token_type": "bearer", "id_token": signed})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer at", r.Header.Get("Authorization"))
+		writeJSON(w, idp.userinfo)
+	})
+	return idp
+}
+
+// authorize starts a login and remembers the PKCE challenge the IdP received
+func (idp *testIdP) authorize(t *testing.T, p *OIDCProvider, verifier string) *url.URL {
+	raw, err := p.AuthURL(context.Background(), "st", "n-1", verifier, "https://app/cb")
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	idp.challenge = u.Query().Get("code_challenge")
+	return u
+}
+
+func (idp *testIdP) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            idp.srv.URL,
+		"aud":            "client",
+		"sub":            "u-42",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          "n-1",
+		"email":          "ann@corp.example",
+		"email_verified": true,
+		"name":           "Ann",
+		"groups":         []string{"engineering", "platform-admins"},
+	}
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	idp := newTestIdP(t)
+	p := NewOIDCProvider(idp.srv.URL+"/", "client", "secret")
+	verifier := oauth2.GenerateVerifier()
+
+	u := idp.authorize(t, p, verifier)
+	assert.Equal(t, idp.srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, "n-1", u.Query().Get("nonce"))
+	assert.Equal(t, "openid email profile", u.Query().Get("scope"))
+
+	idp.claims = idp.validClaims()
+	identity, err := p.Exchange(context.Background(), "the-code", "https://app/cb", verifier, "n-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{
+		Provider:      ProviderOIDC,
+		Subject:       "u-42",
+		Email:         "ann@corp.example",
+		EmailVerified: true,
+		Name:          "Ann",
+		Groups:        []string{"engineering", "platform-admins"},
+	}, identity)
+}
+
+func TestOIDCProvider_RejectsInvalidTokens(t *testing.T) {
+	idp := newTestIdP(t)
+	p := NewOIDCProvider(idp.srv.URL, "client", "")
+	verifier := oauth2.GenerateVerifier()
+	idp.authorize(t, p, verifier)
+
+	cases := map[string]func(jwt.MapClaims){
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "other-client" },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"nonce replayed": func(c jwt.MapClaims) { c["nonce"] = "n-0" },
+		"other client":   func(c jwt.MapClaims) { c["aud"] = []string{"client", "other"}; c["azp"] = "other" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			idp.claims = idp.validClaims()
+			mutate(idp.claims)
+			_, err := p.Exchange(context.Background(), "the-code", "https://app/cb", verifier, "n-1")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestOIDCProvider_UserInfoFallback(t *testing.T) {
+	idp := newTestIdP(t)
+	p := NewOIDCProvider(idp.srv.URL, "client", "secret")
+	p.TrustEmail = true
+	verifier := oauth2.GenerateVerifier()
+	idp.authorize(t, p, verifier)
+
+	idp.claims = idp.validClaims()
+	delete(idp.claims, "email")
+	delete(idp.claims, "email_verified")
+	idp.userinfo = map[string]interface{}{"sub": "u-42", "email": "ann@corp.example"}
+	identity, err := p.Exchange(context.Background(), "the-code", "https://app/cb", verifier, "n-1")
+	require.NoError(t, err)
+	assert.Equal(t, "ann@corp.example", identity.Email)
+	assert.True(t, identity.EmailVerified, "trusted provider without email_verified")
+
+	idp.userinfo = map[string]interface{}{"sub": "someone-else", "email": "eve@corp.example"}
+	_, err = p.Exchange(context.Background(), "the-code", "https://app/cb", verifier, "n-1")
+	assert.Error(t, err)
+}
+
+func TestParseGroupMapping(t *testing.T) {
+	m, err := ParseGroupMapping("engineering=12, platform-admins=12:admin,platform-admins=7:member")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{12, 7}, m.GroupIDs())
+	assert.Equal(t, map[uint]string{12: GroupRoleAdmin, 7: GroupRoleMember}, m.Grants([]string{"engineering", "platform-admins"}))
+	assert.Equal(t, map[uint]string{12: GroupRoleMember}, m.Grants([]string{"engineering", "sales"}))
+
+	for _, bad := range []string{"engineering", "engineering=abc", "engineering=12:owner", "=12"} {
+		_, err := ParseGroupMapping(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,109 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// WeChatProvider signs users in by scanning a QR code with WeChat (website
+// application login). WeChat doesn't disclose an email, so the identity can only
+// sign in to an account that linked it beforehand. The subject is the unionid
+// when the app belongs to an open platform account, otherwise the openid.
+type WeChatProvider struct {
+	AppID      string
+	AppSecret  string
+	AuthBase   string
+	APIBaseURL string
+	Client     *http.Client
+}
+
+// NewWeChatProvider returns the provider with WeChat's endpoints
+func NewWeChatProvider(appID, appSecret string) *WeChatProvider {
+	return &WeChatProvider{
+		AppID:      appID,
+		AppSecret:  appSecret,
+		AuthBase:   "https://open.weixin.qq.com/connect/qrconnect",
+		APIBaseURL: "https://api.weixin.qq.com",
+		Client:     httpClient,
+	}
+}
+
+func (p *WeChatProvider) Name() string { return ProviderWeChat }
+
+func (p *WeChatProvider) AuthURL(state, redirectURL string) string {
+	q := url.Values{
+		"appid":         {p.AppID},
+		"redirect_uri":  {redirectURL},
+		"response_type": {"code"},
+		"scope":         {"snsapi_login"},
+		"state":         {state},
+	}
+	return p.AuthBase + "?" + q.Encode() + "#wechat_redirect"
+}
+
+// wechatError WeChat reports errors in a 200 response body
+type wechatError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e wechatError) err() error {
+	if e.ErrCode == 0 {
+		return nil
+	}
+	return fmt.Errorf("oauth: wechat error %d: %s", e.ErrCode, e.ErrMsg)
+}
+
+func (p *WeChatProvider) Exchange(ctx context.Context, code, redirectURL string) (*Identity, error) {
+	var token struct {
+		wechatError
+		AccessToken string `json:"access_token"`
+		OpenID      string `json:"openid"`
+		UnionID     string `json:"unionid"`
+	}
+	q := url.Values{
+		"appid":      {p.AppID},
+		"secret":     {p.AppSecret},
+		"code":       {code},
+		"grant_type": {"authorization_code"},
+	}
+	if err := getJSON(ctx, p.Client, p.APIBaseURL+"/sns/oauth2/access_token?"+q.Encode(), "", &token); err != nil {
+		return nil, err
+	}
+	if err := token.err(); err != nil {
+		return nil, err
+	}
+	if token.OpenID == "" {
+		return nil, errors.New("oauth: wechat did not return an openid")
+	}
+
+	var info struct {
+		wechatError
+		Nickname   string `json:"nickname"`
+		HeadImgURL string `json:"headimgurl"`
+		UnionID    string `json:"unionid"`
+	}
+	q = url.Values{"access_token": {token.AccessToken}, "openid": {token.OpenID}}
+	if err := getJSON(ctx, p.Client, p.APIBaseURL+"/sns/userinfo?"+q.Encode(), "", &info); err != nil {
+		return nil, err
+	}
+	if err := info.err(); err != nil {
+		return nil, err
+	}
+	subject := token.UnionID
+	if subject == "" {
+		subject = info.UnionID
+	}
+	if subject == "" {
+		subject = token.OpenID
+	}
+	return &Identity{
+		Provider:  ProviderWeChat,
+		Subject:   subject,
+		Name:      info.Nickname,
+		AvatarURL: info.HeadImgURL,
+	}, nil
+}
@@ -0,0 +1,212 @@
+// Package overload admission control for inbound sessions (for example SIP
+// INVITEs): a cap on concurrent sessions, a cap on requests processed at once
+// with a bounded wait queue in front of it, and priority shedding that keeps
+// part of the capacity for high priority (authenticated) callers.
+package overload
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/waitqueue"
+)
+
+var (
+	// ErrSaturated the concurrent session limit is reached
+	ErrSaturated = errors.New("too many concurrent sessions")
+	// ErrQueueFull every processing slot is busy and the pending queue is full
+	ErrQueueFull = errors.New("pending queue is full")
+	// ErrWaitTimeout no processing slot became free within the queue timeout
+	ErrWaitTimeout = errors.New("timed out waiting in the pending queue")
+)
+
+// DefaultQueueTimeout applies when a config with a queue has no timeout
+const DefaultQueueTimeout = 5 * time.Second
+
+// Priority of a request; high priority requests are served first from the
+// queue and may use the capacity reserved for them
+type Priority int
+
+const (
+	Low Priority = iota
+	High
+)
+
+func (p Priority) String() string {
+	if p == High {
+		return "high"
+	}
+	return "low"
+}
+
+// Config admission limits. Zero values mean unlimited.
+type Config struct {
+	MaxSessions  int           // Concurrent sessions, from admission until Release
+	MaxInFlight  int           // Requests processed at once, from admission until Ticket.Done
+	QueueSize    int           // Requests waiting for a processing slot
+	QueueTimeout time.Duration // Longest wait in the queue
+	// LowPriorityShare share of MaxSessions and QueueSize low priority requests may
+	// use, the rest is reserved for high priority ones. 0 or >= 1 reserves nothing.
+	LowPriorityShare float64
+}
+
+// Stats current load and counters since the controller was created
+type Stats struct {
+	Sessions        int   `json:"sessions"`
+	InFlight        int   `json:"inFlight"`
+	Queued          int   `json:"queued"`
+	MaxSessions     int   `json:"maxSessions"`
+	MaxInFlight     int   `json:"maxInFlight"`
+	QueueSize       int   `json:"queueSize"`
+	Admitted        int64 `json:"admitted"`
+	Saturated       int64 `json:"saturated"` // Shed because of the session limit
+	QueueFull       int64 `json:"queueFull"`
+	TimedOut        int64 `json:"timedOut"`
+	Abandoned       int64 `json:"abandoned"`       // Caller gave up while queued
+	ShedLowPriority int64 `json:"shedLowPriority"` // Shed low priority requests, included in the counters above
+}
+
+// Controller in-memory admission controller
+type Controller struct {
+	cfg      Config
+	mu       sync.Mutex
+	sessions map[string]struct{}
+	inFlight int
+	queue    *waitqueue.Queue // Level 0 high priority, level 1 low priority
+	stats    Stats
+}
+
+// New creates a controller with the given limits
+func New(cfg Config) *Controller {
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = DefaultQueueTimeout
+	}
+	return &Controller{cfg: cfg, sessions: make(map[string]struct{}), queue: waitqueue.New(2)}
+}
+
+// Config returns the limits of the controller
+func (c *Controller) Config() Config {
+	return c.cfg
+}
+
+// Ticket an admitted request. Done must be called once the request has been
+// processed; the session stays reserved until Controller.Release.
+type Ticket struct {
+	controller *Controller
+	once       sync.Once
+	// Waited time spent in the queue
+	Waited time.Duration
+}
+
+// Done frees the processing slot, handing it to the next waiter. Safe to call
+// more than once and on a nil ticket.
+func (t *Ticket) Done() {
+	if t == nil || t.controller == nil {
+		return
+	}
+	t.once.Do(t.controller.done)
+}
+
+// share applies LowPriorityShare to a limit for low priority requests
+func (c *Controller) share(limit int, p Priority) int {
+	if p == High || c.cfg.LowPriorityShare <= 0 || c.cfg.LowPriorityShare >= 1 {
+		return limit
+	}
+	return int(float64(limit) * c.cfg.LowPriorityShare)
+}
+
+func (c *Controller) shed(p Priority, counter *int64) {
+	*counter++
+	if p == Low {
+		c.stats.ShedLowPriority++
+	}
+}
+
+// Admit reserves a session for id and a processing slot, waiting in the queue
+// when every slot is busy. It returns ErrSaturated or ErrQueueFull right away,
+// ErrWaitTimeout when the queue timeout elapses and the context error when ctx is done.
+func (c *Controller) Admit(ctx context.Context, id string, p Priority) (*Ticket, error) {
+	ticket := &Ticket{controller: c}
+	start := time.Now()
+
+	c.mu.Lock()
+	if _, exists := c.sessions[id]; !exists && c.cfg.MaxSessions > 0 && len(c.sessions) >= c.share(c.cfg.MaxSessions, p) {
+		c.shed(p, &c.stats.Saturated)
+		c.mu.Unlock()
+		return nil, ErrSaturated
+	}
+	if c.cfg.MaxInFlight <= 0 || c.inFlight < c.cfg.MaxInFlight {
+		c.inFlight++
+		c.sessions[id] = struct{}{}
+		c.stats.Admitted++
+		c.mu.Unlock()
+		return ticket, nil
+	}
+	if c.queue.Len() >= c.share(c.cfg.QueueSize, p) {
+		c.shed(p, &c.stats.QueueFull)
+		c.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	// The session is reserved while queued so the queue cannot overshoot MaxSessions
+	c.sessions[id] = struct{}{}
+	level := 1
+	if p == High {
+		level = 0
+	}
+	w := c.queue.Push(level)
+	c.mu.Unlock()
+
+	err := w.Wait(ctx, c.cfg.QueueTimeout, nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && c.queue.Remove(w) {
+		delete(c.sessions, id)
+		if errors.Is(err, waitqueue.ErrTimeout) {
+			c.shed(p, &c.stats.TimedOut)
+			err = ErrWaitTimeout
+		} else {
+			c.stats.Abandoned++
+		}
+		return nil, err
+	}
+	// Granted, possibly racing with the timeout
+	ticket.Waited = time.Since(start)
+	c.stats.Admitted++
+	return ticket, nil
+}
+
+// done hands the processing slot to the oldest high priority waiter, then the oldest low priority one
+func (c *Controller) done() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queue.Grant() {
+		return
+	}
+	if c.inFlight > 0 {
+		c.inFlight--
+	}
+}
+
+// Release frees the session of id, no-op for unknown ids
+func (c *Controller) Release(id string) {
+	c.mu.Lock()
+	delete(c.sessions, id)
+	c.mu.Unlock()
+}
+
+// Stats returns the current load and counters
+func (c *Controller) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Sessions = len(c.sessions)
+	s.InFlight = c.inFlight
+	s.Queued = c.queue.Len()
+	s.MaxSessions = c.cfg.MaxSessions
+	s.MaxInFlight = c.cfg.MaxInFlight
+	s.QueueSize = c.cfg.QueueSize
+	return s
+}
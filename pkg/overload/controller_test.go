@@ -0,0 +1,126 @@
+package overload
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Unlimited(t *testing.T) {
+	c := New(Config{})
+	for _, id := range []string{"a", "b", "c"} {
+		ticket, err := c.Admit(context.Background(), id, Low)
+		require.NoError(t, err)
+		ticket.Done()
+	}
+	stats := c.Stats()
+	assert.Equal(t, 3, stats.Sessions)
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Equal(t, int64(3), stats.Admitted)
+}
+
+func TestController_SessionLimitReservesHighPriority(t *testing.T) {
+	c := New(Config{MaxSessions: 4, LowPriorityShare: 0.5})
+
+	for _, id := range []string{"a", "b"} {
+		_, err := c.Admit(context.Background(), id, Low)
+		require.NoError(t, err)
+	}
+	_, err := c.Admit(context.Background(), "c", Low)
+	assert.ErrorIs(t, err, ErrSaturated)
+
+	for _, id := range []string{"c", "d"} {
+		_, err := c.Admit(context.Background(), id, High)
+		require.NoError(t, err)
+	}
+	_, err = c.Admit(context.Background(), "e", High)
+	assert.ErrorIs(t, err, ErrSaturated)
+
+	c.Release("a")
+	c.Release("a") // idempotent
+	_, err = c.Admit(context.Background(), "e", High)
+	assert.NoError(t, err)
+
+	stats := c.Stats()
+	assert.Equal(t, 4, stats.Sessions)
+	assert.Equal(t, int64(2), stats.Saturated)
+	assert.Equal(t, int64(1), stats.ShedLowPriority)
+}
+
+func TestController_QueuePrefersHighPriority(t *testing.T) {
+	c := New(Config{MaxInFlight: 1, QueueSize: 2, LowPriorityShare: 0.5, QueueTimeout: time.Second})
+
+	held, err := c.Admit(context.Background(), "held", Low)
+	require.NoError(t, err)
+
+	lowDone := make(chan *Ticket, 1)
+	go func() {
+		ticket, err := c.Admit(context.Background(), "low", Low)
+		assert.NoError(t, err)
+		lowDone <- ticket
+	}()
+	require.Eventually(t, func() bool { return c.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+	// The low priority share of the queue is used up
+	_, err = c.Admit(context.Background(), "low2", Low)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	highDone := make(chan *Ticket, 1)
+	go func() {
+		ticket, err := c.Admit(context.Background(), "high", High)
+		assert.NoError(t, err)
+		highDone <- ticket
+	}()
+	require.Eventually(t, func() bool { return c.Stats().Queued == 2 }, time.Second, time.Millisecond)
+
+	_, err = c.Admit(context.Background(), "high2", High)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	// The freed slot goes to the high priority waiter although it queued later
+	held.Done()
+	held.Done()
+	high := <-highDone
+	select {
+	case <-lowDone:
+		t.Fatal("low priority request admitted before the high priority one finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	high.Done()
+	low := <-lowDone
+	low.Done()
+
+	stats := c.Stats()
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, 3, stats.Sessions)
+	assert.Equal(t, int64(2), stats.QueueFull)
+}
+
+func TestController_TimeoutAndCancel(t *testing.T) {
+	c := New(Config{MaxInFlight: 1, QueueSize: 2, QueueTimeout: 20 * time.Millisecond})
+	held, err := c.Admit(context.Background(), "held", High)
+	require.NoError(t, err)
+
+	_, err = c.Admit(context.Background(), "late", High)
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	_, err = c.Admit(ctx, "gone", Low)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	stats := c.Stats()
+	assert.Equal(t, 1, stats.Sessions)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, int64(1), stats.TimedOut)
+	assert.Equal(t, int64(1), stats.Abandoned)
+
+	held.Done()
+	assert.Equal(t, 0, c.Stats().InFlight)
+}
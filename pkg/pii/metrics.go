@@ -0,0 +1,101 @@
+package pii
+
+// Review states of a tagged entity
+const (
+	StatusDetected  = "detected"  // Found by Detect, not reviewed
+	StatusConfirmed = "confirmed" // A reviewer agreed with the detection
+	StatusRejected  = "rejected"  // A reviewer marked the detection as not personal data
+	StatusAdded     = "added"     // Missed by Detect and tagged by a reviewer
+)
+
+// ValidStatus reports whether s is a review state
+func ValidStatus(s string) bool {
+	switch s {
+	case StatusDetected, StatusConfirmed, StatusRejected, StatusAdded:
+		return true
+	}
+	return false
+}
+
+// Masked reports whether entities in this state hide their text; rejected
+// detections are not personal data
+func Masked(status string) bool {
+	return status != StatusRejected
+}
+
+// Counts detection quality of one entity type. Precision and recall only use
+// reviewed entities and are nil until something of the type was reviewed.
+type Counts struct {
+	Detected  int      `json:"detected"`  // Found by Detect, reviewed or not
+	Confirmed int      `json:"confirmed"` // True positives
+	Rejected  int      `json:"rejected"`  // False positives
+	Missed    int      `json:"missed"`    // False negatives, added by reviewers
+	Precision *float64 `json:"precision"`
+	Recall    *float64 `json:"recall"`
+	F1        *float64 `json:"f1"`
+}
+
+// Metrics detection quality over a set of transcripts
+type Metrics struct {
+	Transcripts int                `json:"transcripts"`
+	Reviewed    int                `json:"reviewed"` // Transcripts with at least one reviewed entity
+	Overall     Counts             `json:"overall"`
+	ByType      map[string]*Counts `json:"byType"`
+}
+
+// NewMetrics empty metrics with a row for every entity type
+func NewMetrics() *Metrics {
+	m := &Metrics{ByType: map[string]*Counts{}}
+	for _, t := range Types {
+		m.ByType[t] = &Counts{}
+	}
+	return m
+}
+
+// Add counts one tagged entity
+func (m *Metrics) Add(typ, status string) {
+	row, ok := m.ByType[typ]
+	if !ok {
+		row = &Counts{}
+		m.ByType[typ] = row
+	}
+	for _, c := range []*Counts{row, &m.Overall} {
+		switch status {
+		case StatusDetected:
+			c.Detected++
+		case StatusConfirmed:
+			c.Detected++
+			c.Confirmed++
+		case StatusRejected:
+			c.Detected++
+			c.Rejected++
+		case StatusAdded:
+			c.Missed++
+		}
+	}
+}
+
+// Finish computes precision, recall and F1 from the counts
+func (m *Metrics) Finish() *Metrics {
+	m.Overall.finish()
+	for _, c := range m.ByType {
+		c.finish()
+	}
+	return m
+}
+
+func (c *Counts) finish() {
+	c.Precision, c.Recall, c.F1 = nil, nil, nil
+	if reviewed := c.Confirmed + c.Rejected; reviewed > 0 {
+		p := float64(c.Confirmed) / float64(reviewed)
+		c.Precision = &p
+	}
+	if relevant := c.Confirmed + c.Missed; relevant > 0 {
+		r := float64(c.Confirmed) / float64(relevant)
+		c.Recall = &r
+	}
+	if c.Precision != nil && c.Recall != nil && *c.Precision+*c.Recall > 0 {
+		f := 2 * *c.Precision * *c.Recall / (*c.Precision + *c.Recall)
+		c.F1 = &f
+	}
+}
@@ -0,0 +1,251 @@
+// Package pii finds personal data in call transcripts: names, phone numbers,
+// email addresses, postal addresses, identity numbers and bank cards. Detection
+// is rule based, so it runs locally on every transcript without sending the
+// text anywhere. Every entity carries a confidence; checksummed numbers score
+// high, names found only from a cue like "my name is" score lower and are the
+// ones reviewers are expected to correct.
+package pii
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Entity types
+const (
+	TypeName     = "name"
+	TypePhone    = "phone"
+	TypeEmail    = "email"
+	TypeAddress  = "address"
+	TypeID       = "id_number"
+	TypeBankCard = "bank_card"
+)
+
+// Types every entity type, in the order they are listed to users
+var Types = []string{TypeName, TypePhone, TypeEmail, TypeAddress, TypeID, TypeBankCard}
+
+// MaskChar replaces every character of a masked entity
+const MaskChar = '*'
+
+// Entity personal data found in a text. Start and End are character (rune)
+// offsets, End exclusive.
+type Entity struct {
+	Type       string  `json:"type"`
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ValidType reports whether t is a known entity type
+func ValidType(t string) bool {
+	for _, known := range Types {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+type rule struct {
+	typ        string
+	re         *regexp.Regexp
+	group      int                    // submatch holding the entity, 0 for the whole match
+	confidence float64                // confidence of a match that passes check
+	check      func(text string) bool // rejects matches that only look like the entity
+	standalone bool                   // digits or letters must not continue right before or after the match
+	trim       func(text string) int  // bytes of leading context the pattern could not tell apart
+}
+
+var rules = []rule{
+	{typ: TypeEmail, re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), confidence: 0.98},
+	// Mainland resident identity card, 18 characters with a check digit
+	{typ: TypeID, re: regexp.MustCompile(`[1-9]\d{16}[\dXx]`), confidence: 0.97, check: validResidentID, standalone: true},
+	// US social security number
+	{typ: TypeID, re: regexp.MustCompile(`\d{3}-\d{2}-\d{4}`), confidence: 0.85, standalone: true},
+	// Passport numbers, only after a passport cue since the format alone is too common
+	{typ: TypeID, re: regexp.MustCompile(`(?i:passport|护照)[^A-Za-z0-9]{0,12}([A-Za-z]{1,2}\d{7,8})`), group: 1, confidence: 0.9},
+	{typ: TypeBankCard, re: regexp.MustCompile(`\d{4}(?:[ -]?\d{4}){2,3}(?:[ -]?\d{1,3})?`), confidence: 0.9, check: validCard, standalone: true},
+	// Mainland mobile numbers, optionally with the country code
+	{typ: TypePhone, re: regexp.MustCompile(`(?:\+?86[ -]?)?1[3-9]\d[ -]?\d{4}[ -]?\d{4}`), confidence: 0.95, standalone: true},
+	// International and landline numbers with separators
+	{typ: TypePhone, re: regexp.MustCompile(`\+\d{1,3}[ -]?\(?\d{1,4}\)?(?:[ -]?\d{2,4}){2,3}`), confidence: 0.85, standalone: true},
+	{typ: TypePhone, re: regexp.MustCompile(`\(?0\d{2,3}\)?[ -]\d{7,8}|\(?\d{3}\)?[ .-]\d{3}[ .-]\d{4}`), confidence: 0.8, standalone: true},
+	{typ: TypeAddress, re: regexp.MustCompile(`(?:\p{Han}{2,8}?(?:省|自治区|市|区|县|镇|乡|街道)){1,4}\p{Han}{0,12}?(?:路|街|大道|巷|弄|胡同|村)(?:\d+|[一二三四五六七八九十百]+)号(?:\d+(?:号楼|栋|幢|单元)){0,2}(?:\d+室)?`), confidence: 0.85, trim: addressCue},
+	{typ: TypeAddress, re: regexp.MustCompile(`\d{1,6}(?: [A-Z][a-z]+){1,4} (?:Street|St|Road|Rd|Avenue|Ave|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct|Way)\b\.?(?:,? (?:Apt|Suite|Unit)\.? ?\w+)?`), confidence: 0.85},
+	// Names are only taken from introductions, the cue itself is not part of the entity
+	{typ: TypeName, re: regexp.MustCompile(`(?:我叫|我的名字是|我的名字叫|本人姓名|名字叫|姓名是|我是)(\p{Han}{2,3})`), group: 1, confidence: 0.75, check: chineseSurname},
+	{typ: TypeName, re: regexp.MustCompile(`(?i:my name is|my name's|this is|i am|i'm|name is)\s+([A-Z][a-z]+(?: [A-Z][a-z]+){0,2})`), group: 1, confidence: 0.65, check: englishName},
+	{typ: TypeName, re: regexp.MustCompile(`(?i:mr|mrs|ms|miss|dr)\.? ([A-Z][a-z]+(?: [A-Z][a-z]+)?)`), group: 1, confidence: 0.7, check: englishName},
+	{typ: TypeName, re: regexp.MustCompile(`(\p{Han})(?:先生|女士|小姐)`), group: 1, confidence: 0.6, check: chineseSurname},
+}
+
+// Detect finds the personal data in text. Overlapping matches are resolved in
+// favor of the longer, then more confident entity.
+func Detect(text string) []Entity {
+	var found []Entity
+	for _, r := range rules {
+		for _, m := range r.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*r.group], m[2*r.group+1]
+			if start < 0 {
+				continue
+			}
+			if r.standalone && !standalone(text, start, end) {
+				continue
+			}
+			if r.trim != nil {
+				start += r.trim(text[start:end])
+			}
+			match := text[start:end]
+			if r.check != nil && !r.check(match) {
+				continue
+			}
+			found = append(found, Entity{
+				Type:       r.typ,
+				Start:      utf8.RuneCountInString(text[:start]),
+				End:        utf8.RuneCountInString(text[:end]),
+				Text:       match,
+				Confidence: r.confidence,
+			})
+		}
+	}
+	return resolveOverlaps(found)
+}
+
+func resolveOverlaps(found []Entity) []Entity {
+	sort.SliceStable(found, func(i, j int) bool {
+		li, lj := found[i].End-found[i].Start, found[j].End-found[j].Start
+		if li != lj {
+			return li > lj
+		}
+		return found[i].Confidence > found[j].Confidence
+	})
+	var kept []Entity
+	for _, e := range found {
+		overlaps := false
+		for _, k := range kept {
+			if e.Start < k.End && k.Start < e.End {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, e)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Start < kept[j].Start })
+	return kept
+}
+
+// Mask replaces the characters of the entities with MaskChar, keeping the
+// length so the masked text lines up with the original offsets
+func Mask(text string, entities []Entity) string {
+	if len(entities) == 0 {
+		return text
+	}
+	runes := []rune(text)
+	for _, e := range entities {
+		for i := e.Start; i < e.End && i < len(runes); i++ {
+			if i >= 0 && !unicode.IsSpace(runes[i]) {
+				runes[i] = MaskChar
+			}
+		}
+	}
+	return string(runes)
+}
+
+// standalone reports whether the match is not part of a longer run of letters or digits
+func standalone(text string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); isAlnum(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		if r, _ := utf8.DecodeRuneInString(text[end:]); isAlnum(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsDigit(r) || unicode.IsLetter(r))
+}
+
+// validResidentID verifies the ISO 7064 MOD 11-2 check digit of a resident ID
+func validResidentID(id string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(id[i]-'0') * w
+	}
+	return strings.EqualFold(string("10X98765432"[sum%11]), id[17:])
+}
+
+// validCard verifies the Luhn checksum of a 13 to 19 digit card number
+func validCard(s string) bool {
+	var digits []int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// addressCues words that lead into an address and are matched along with it
+var addressCues = []string{"住址是", "地址是", "地址", "住在", "送到", "寄到", "发到", "位于", "在", "到", "是"}
+
+// addressCue length of the cues in front of a Chinese address
+func addressCue(s string) int {
+	n := 0
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, cue := range addressCues {
+			if strings.HasPrefix(s[n:], cue) {
+				n += len(cue)
+				trimmed = true
+				break
+			}
+		}
+	}
+	return n
+}
+
+// commonSurnames the most frequent Chinese surnames, a cue followed by one of
+// them is far more likely a name than "我是客户"
+const commonSurnames = "王李张刘陈杨黄赵吴周徐孙马朱胡郭何高林罗郑梁谢宋唐许韩冯邓曹彭曾肖田董袁潘于蒋蔡余杜叶程苏魏吕丁任沈姚卢姜崔钟谭陆汪范金石廖贾夏韦付方白邹孟熊秦邱江尹薛闫段雷侯龙史陶黎贺顾毛郝龚邵万钱严覃武戴莫孔向汤"
+
+func chineseSurname(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return strings.ContainsRune(commonSurnames, r)
+}
+
+// notNames capitalized words following "this is" or "I am" that are not names
+var notNames = map[string]bool{
+	"The": true, "A": true, "An": true, "Not": true, "Just": true, "Calling": true, "Customer": true,
+	"Support": true, "Sorry": true, "Here": true, "Fine": true, "Good": true, "Great": true, "Okay": true,
+	"Ok": true, "Yes": true, "No": true, "It": true, "That": true, "This": true, "Your": true, "My": true,
+}
+
+func englishName(s string) bool {
+	first := strings.Fields(s)[0]
+	return !notNames[first]
+}
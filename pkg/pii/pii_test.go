@@ -0,0 +1,85 @@
+package pii
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func detectTypes(text string) map[string]string {
+	out := map[string]string{}
+	for _, e := range Detect(text) {
+		out[e.Type] = e.Text
+	}
+	return out
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		text string
+		want map[string]string
+	}{
+		{"我叫张伟，手机号是13812345678", map[string]string{TypeName: "张伟", TypePhone: "13812345678"}},
+		{"我的号码 +86 138-1234-5678 请回电", map[string]string{TypePhone: "+86 138-1234-5678"}},
+		{"身份证号11010519491231002X", map[string]string{TypeID: "11010519491231002X"}},
+		{"送到北京市朝阳区建国路88号2号楼1201室", map[string]string{TypeAddress: "北京市朝阳区建国路88号2号楼1201室"}},
+		{"Hi, my name is John Smith and my email is john.smith@example.com", map[string]string{TypeName: "John Smith", TypeEmail: "john.smith@example.com"}},
+		{"I live at 1600 Pennsylvania Avenue, call (202) 456-1111", map[string]string{TypeAddress: "1600 Pennsylvania Avenue", TypePhone: "(202) 456-1111"}},
+		{"card number 4111 1111 1111 1111 please", map[string]string{TypeBankCard: "4111 1111 1111 1111"}},
+		{"my SSN is 078-05-1120", map[string]string{TypeID: "078-05-1120"}},
+		{"护照号码 E12345678", map[string]string{TypeID: "E12345678"}},
+		{"王先生您好", map[string]string{TypeName: "王"}},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, detectTypes(tc.text), tc.text)
+	}
+}
+
+func TestDetectRejectsLookalikes(t *testing.T) {
+	for _, text := range []string{
+		"我是客户，想查订单",                        // 我是 followed by a word, not a surname
+		"订单号 110105194912310021",           // wrong ID check digit
+		"tracking 4111 1111 1111 1112",     // fails Luhn
+		"serial 1381234567890123",          // longer number containing a mobile number
+		"This is Customer Support calling", // not a name
+	} {
+		assert.Empty(t, Detect(text), text)
+	}
+}
+
+func TestDetectOffsets(t *testing.T) {
+	text := "您好，我叫李娜，电话13912345678。"
+	entities := Detect(text)
+	require.Len(t, entities, 2)
+	runes := []rune(text)
+	for _, e := range entities {
+		assert.Equal(t, e.Text, string(runes[e.Start:e.End]))
+	}
+	assert.Equal(t, "您好，我叫**，电话***********。", Mask(text, entities))
+	assert.Equal(t, text, Mask(text, nil))
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.Add(TypePhone, StatusConfirmed)
+	m.Add(TypePhone, StatusConfirmed)
+	m.Add(TypePhone, StatusConfirmed)
+	m.Add(TypePhone, StatusRejected)
+	m.Add(TypePhone, StatusAdded)
+	m.Add(TypeName, StatusDetected)
+	m.Finish()
+
+	phone := m.ByType[TypePhone]
+	assert.Equal(t, 4, phone.Detected)
+	require.NotNil(t, phone.Precision)
+	assert.InDelta(t, 0.75, *phone.Precision, 1e-9)
+	assert.InDelta(t, 0.75, *phone.Recall, 1e-9)
+	assert.InDelta(t, 0.75, *phone.F1, 1e-9)
+
+	name := m.ByType[TypeName]
+	assert.Equal(t, 1, name.Detected)
+	assert.Nil(t, name.Precision, "nothing reviewed")
+	assert.Equal(t, 5, m.Overall.Detected)
+	assert.Equal(t, 1, m.Overall.Missed)
+}
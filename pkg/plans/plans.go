@@ -0,0 +1,131 @@
+// Package plans defines the subscription plans organizations are on, the features
+// and limits each plan grants, and how a subscription moves from trial or paid
+// term through the grace period to read-only once it is not renewed.
+package plans
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Plan keys
+const (
+	PlanTrial      = "trial"
+	PlanPro        = "pro"
+	PlanEnterprise = "enterprise"
+)
+
+// Features gated by plan
+const (
+	FeatureSIPTrunking = "sip_trunking" // Outbound PSTN calls and dial-in numbers
+)
+
+// Subscription statuses. Grace keeps full access while the owner renews, read-only
+// blocks every change until the plan is renewed or changed.
+const (
+	StatusTrialing = "trialing"
+	StatusActive   = "active"
+	StatusGrace    = "grace"
+	StatusReadOnly = "read_only"
+)
+
+// Plan definition
+type Plan struct {
+	Key       string   `json:"key"`
+	Name      string   `json:"name"`
+	Rank      int      `json:"rank"`                  // Higher ranks grant more
+	TrialDays int      `json:"trialDays,omitempty"`   // Length of the trial, trial plans only
+	GraceDays int      `json:"graceDays"`             // Full access kept after the term ends
+	Features  []string `json:"features"`              // Gated features the plan includes
+	AIMinutes int64    `json:"aiMinutes"`             // Monthly AI call minutes, 0 is unlimited
+	Providers []string `json:"kbProviders,omitempty"` // Knowledge base providers, empty allows all
+}
+
+var builtin = []*Plan{
+	{Key: PlanTrial, Name: "Trial", Rank: 0, TrialDays: 14, GraceDays: 3, Features: []string{}, AIMinutes: 100, Providers: []string{"aliyun"}},
+	{Key: PlanPro, Name: "Pro", Rank: 1, GraceDays: 7, Features: []string{FeatureSIPTrunking}, AIMinutes: 5000, Providers: []string{"aliyun", "qdrant", "elasticsearch"}},
+	{Key: PlanEnterprise, Name: "Enterprise", Rank: 2, GraceDays: 30, Features: []string{FeatureSIPTrunking}},
+}
+
+// Get returns the plan with the key
+func Get(key string) (*Plan, bool) {
+	for _, p := range builtin {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// List returns the plans from the lowest to the highest rank
+func List() []*Plan {
+	list := append([]*Plan(nil), builtin...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Rank < list[j].Rank })
+	return list
+}
+
+// Allows reports whether the plan includes the feature
+func (p *Plan) Allows(feature string) bool {
+	for _, f := range p.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProvider reports whether knowledge bases may use the provider
+func (p *Plan) AllowsProvider(provider string) bool {
+	if len(p.Providers) == 0 {
+		return true
+	}
+	for _, v := range p.Providers {
+		if v == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTrial reports whether the plan is a time-limited trial
+func (p *Plan) IsTrial() bool {
+	return p.TrialDays > 0
+}
+
+// Term end of the trial or paid term started at start and the end of its grace
+// period. A paid plan without a term length (contract billed) never ends.
+func (p *Plan) Term(start time.Time, paidUntil *time.Time) (endsAt, graceEndsAt *time.Time, err error) {
+	switch {
+	case p.IsTrial():
+		end := start.AddDate(0, 0, p.TrialDays)
+		endsAt = &end
+	case paidUntil != nil:
+		if !paidUntil.After(start) {
+			return nil, nil, fmt.Errorf("paid term must end after %s", start.Format(time.RFC3339))
+		}
+		end := *paidUntil
+		endsAt = &end
+	default:
+		return nil, nil, nil
+	}
+	grace := endsAt.AddDate(0, 0, p.GraceDays)
+	return endsAt, &grace, nil
+}
+
+// EffectiveStatus status of a subscription at now: the stored trialing or active
+// status until the term ends, grace until the grace period ends, read-only after.
+func EffectiveStatus(status string, endsAt, graceEndsAt *time.Time, now time.Time) string {
+	if status == StatusReadOnly || endsAt == nil || now.Before(*endsAt) {
+		return status
+	}
+	if graceEndsAt != nil && now.Before(*graceEndsAt) {
+		return StatusGrace
+	}
+	return StatusReadOnly
+}
+
+// Writable reports whether members may change data under the status
+func Writable(status string) bool {
+	return status != StatusReadOnly
+}
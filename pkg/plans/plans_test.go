@@ -0,0 +1,69 @@
+package plans
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlans(t *testing.T) {
+	list := List()
+	require.Len(t, list, 3)
+	assert.Equal(t, []string{PlanTrial, PlanPro, PlanEnterprise}, []string{list[0].Key, list[1].Key, list[2].Key})
+
+	trial, ok := Get(PlanTrial)
+	require.True(t, ok)
+	assert.False(t, trial.Allows(FeatureSIPTrunking))
+	assert.True(t, trial.AllowsProvider("aliyun"))
+	assert.False(t, trial.AllowsProvider("pinecone"))
+
+	enterprise, _ := Get(PlanEnterprise)
+	assert.True(t, enterprise.Allows(FeatureSIPTrunking))
+	assert.True(t, enterprise.AllowsProvider("pinecone"))
+	assert.Zero(t, enterprise.AIMinutes)
+
+	_, ok = Get("gold")
+	assert.False(t, ok)
+}
+
+func TestPlan_Term(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	trial, _ := Get(PlanTrial)
+	endsAt, graceEndsAt, err := trial.Term(start, nil)
+	require.NoError(t, err)
+	assert.Equal(t, start.AddDate(0, 0, 14), *endsAt)
+	assert.Equal(t, start.AddDate(0, 0, 17), *graceEndsAt)
+
+	pro, _ := Get(PlanPro)
+	endsAt, graceEndsAt, err = pro.Term(start, nil)
+	require.NoError(t, err)
+	assert.Nil(t, endsAt, "contract billed")
+	assert.Nil(t, graceEndsAt)
+
+	paidUntil := start.AddDate(0, 1, 0)
+	endsAt, graceEndsAt, err = pro.Term(start, &paidUntil)
+	require.NoError(t, err)
+	assert.Equal(t, paidUntil, *endsAt)
+	assert.Equal(t, paidUntil.AddDate(0, 0, 7), *graceEndsAt)
+
+	_, _, err = pro.Term(start, &start)
+	assert.Error(t, err)
+}
+
+func TestEffectiveStatus(t *testing.T) {
+	endsAt := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	graceEndsAt := endsAt.AddDate(0, 0, 3)
+
+	assert.Equal(t, StatusTrialing, EffectiveStatus(StatusTrialing, &endsAt, &graceEndsAt, endsAt.Add(-time.Second)))
+	assert.Equal(t, StatusGrace, EffectiveStatus(StatusTrialing, &endsAt, &graceEndsAt, endsAt))
+	assert.Equal(t, StatusGrace, EffectiveStatus(StatusGrace, &endsAt, &graceEndsAt, graceEndsAt.Add(-time.Second)))
+	assert.Equal(t, StatusReadOnly, EffectiveStatus(StatusActive, &endsAt, &graceEndsAt, graceEndsAt))
+	assert.Equal(t, StatusReadOnly, EffectiveStatus(StatusActive, &endsAt, nil, endsAt))
+	assert.Equal(t, StatusActive, EffectiveStatus(StatusActive, nil, nil, graceEndsAt), "no term end")
+	assert.Equal(t, StatusReadOnly, EffectiveStatus(StatusReadOnly, nil, nil, endsAt))
+
+	assert.True(t, Writable(StatusGrace))
+	assert.False(t, Writable(StatusReadOnly))
+}
@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OPAEngine 调用 OPA 的 Data API 评估策略：POST {BaseURL}/v1/data/{Path}，请求体为 {"input": Input}。
+// 策略结果可以是布尔值（true 放行，false 拒绝），
+// 也可以是 {"allow": bool, "reason": string} 对象；结果未定义时视为没有策略适用。
+type OPAEngine struct {
+	BaseURL string // 如 http://localhost:8181
+	Path    string // 如 lingecho/authz
+	Client  *http.Client
+}
+
+// NewOPAEngine 创建 OPA 引擎
+func NewOPAEngine(baseURL, policyPath string) *OPAEngine {
+	return &OPAEngine{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Path:    strings.Trim(policyPath, "/"),
+		Client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (e *OPAEngine) Name() string {
+	return "opa"
+}
+
+type opaResult struct {
+	Result *json.RawMessage `json:"result"`
+}
+
+// Evaluate 评估输入
+func (e *OPAEngine) Evaluate(ctx context.Context, in Input) (Decision, error) {
+	decision := Decision{Effect: EffectNone, Engine: e.Name()}
+
+	body, err := json.Marshal(map[string]any{"input": in})
+	if err != nil {
+		return decision, err
+	}
+	url := e.BaseURL + "/v1/data/" + strings.ReplaceAll(e.Path, ".", "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return decision, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return decision, fmt.Errorf("opa request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decision, fmt.Errorf("opa returned status %d", resp.StatusCode)
+	}
+
+	var out opaResult
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return decision, fmt.Errorf("invalid opa response: %w", err)
+	}
+	if out.Result == nil {
+		decision.Reason = "policy result undefined"
+		return decision, nil
+	}
+
+	var allow bool
+	if err := json.Unmarshal(*out.Result, &allow); err != nil {
+		var obj struct {
+			Allow  *bool  `json:"allow"`
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal(*out.Result, &obj); err != nil {
+			return decision, fmt.Errorf("unsupported opa result: %s", string(*out.Result))
+		}
+		if obj.Allow == nil {
+			decision.Reason = obj.Reason
+			return decision, nil
+		}
+		allow, decision.Reason = *obj.Allow, obj.Reason
+	}
+	if allow {
+		decision.Effect = EffectAllow
+	} else {
+		decision.Effect = EffectDeny
+	}
+	return decision, nil
+}
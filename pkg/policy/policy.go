@@ -0,0 +1,293 @@
+// Package policy 基于属性的授权策略（policy-as-code），作为 RBAC 之后的可选评估层。
+// 内置 Casbin 风格的规则引擎（subject, resource, action, effect + 属性条件），
+// 也可以交由外部 OPA 服务评估。
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Effect 策略结论
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+	EffectNone  Effect = "none" // 没有策略适用，沿用 RBAC 的结论
+)
+
+// Subject 发起请求的用户
+type Subject struct {
+	ID     uint           `json:"id"`
+	Role   string         `json:"role"`
+	Groups []uint         `json:"groups,omitempty"`
+	Attrs  map[string]any `json:"attrs,omitempty"` // 如 region、city、locale
+}
+
+// Input 一次授权评估的输入
+type Input struct {
+	Subject  Subject        `json:"subject"`
+	Resource string         `json:"resource"` // 如 call_recordings、call_recordings/12
+	Action   string         `json:"action"`   // 如 read、write、delete
+	Object   map[string]any `json:"object,omitempty"`
+	Env      map[string]any `json:"env,omitempty"` // 如 ip、time
+}
+
+// Decision 评估结果
+type Decision struct {
+	Effect Effect `json:"effect"`
+	RuleID string `json:"ruleId,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Engine string `json:"engine"`
+}
+
+// Allowed 结论是否放行，没有策略适用时放行
+func (d Decision) Allowed() bool {
+	return d.Effect != EffectDeny
+}
+
+// Engine 策略评估引擎
+type Engine interface {
+	Name() string
+	Evaluate(ctx context.Context, in Input) (Decision, error)
+}
+
+// 条件运算符
+const (
+	OpEq     = "eq"
+	OpNe     = "ne"
+	OpIn     = "in"
+	OpNotIn  = "not_in"
+	OpPrefix = "prefix"
+	OpExists = "exists"
+)
+
+// Condition 属性条件，Attr 和以 $ 开头的字符串 Value 为属性路径：
+// subject.id、subject.role、subject.<attr>、object.<key>、env.<key>、resource、action
+type Condition struct {
+	Attr  string `json:"attr"`
+	Op    string `json:"op"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Rule 一条策略规则
+type Rule struct {
+	ID         string      `json:"id"`
+	Subject    string      `json:"subject"`  // *、role:<role>、user:<id>、group:<id>
+	Resource   string      `json:"resource"` // 资源名，* 匹配任意字符（含 /），如 call_recordings/*
+	Actions    []string    `json:"actions"`  // 为空或包含 * 表示全部
+	Effect     Effect      `json:"effect"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	Priority   int         `json:"priority"`
+}
+
+// Validate 检查规则格式
+func (r *Rule) Validate() error {
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("effect must be allow or deny")
+	}
+	if r.Subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+	if r.Subject != "*" {
+		kind, value, ok := strings.Cut(r.Subject, ":")
+		if !ok || value == "" || (kind != "role" && kind != "user" && kind != "group") {
+			return fmt.Errorf("subject must be *, role:<role>, user:<id> or group:<id>")
+		}
+	}
+	if r.Resource == "" {
+		return fmt.Errorf("resource is required")
+	}
+	for _, c := range r.Conditions {
+		switch c.Op {
+		case OpEq, OpNe, OpIn, OpNotIn, OpPrefix, OpExists:
+		default:
+			return fmt.Errorf("unsupported condition operator: %q", c.Op)
+		}
+		if c.Attr == "" {
+			return fmt.Errorf("condition attr is required")
+		}
+	}
+	return nil
+}
+
+// Matches 规则是否适用于输入
+func (r *Rule) Matches(in Input) bool {
+	return r.matchSubject(in.Subject) && r.matchResource(in.Resource) && r.matchAction(in.Action) && r.matchConditions(in)
+}
+
+func (r *Rule) matchSubject(s Subject) bool {
+	if r.Subject == "*" {
+		return true
+	}
+	kind, value, _ := strings.Cut(r.Subject, ":")
+	switch kind {
+	case "role":
+		return s.Role == value
+	case "user":
+		return strconv.FormatUint(uint64(s.ID), 10) == value
+	case "group":
+		for _, g := range s.Groups {
+			if strconv.FormatUint(uint64(g), 10) == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchResource(resource string) bool {
+	return wildcardMatch(r.Resource, resource)
+}
+
+// wildcardMatch 与 Casbin keyMatch 相同，* 匹配任意字符序列
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+func (r *Rule) matchAction(action string) bool {
+	if len(r.Actions) == 0 {
+		return true
+	}
+	for _, a := range r.Actions {
+		if a == "*" || a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchConditions(in Input) bool {
+	for _, c := range r.Conditions {
+		if !c.holds(in) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) holds(in Input) bool {
+	actual, exists := lookup(in, c.Attr)
+	if c.Op == OpExists {
+		return exists
+	}
+	expected := c.Value
+	if ref, ok := expected.(string); ok && strings.HasPrefix(ref, "$") {
+		var found bool
+		if expected, found = lookup(in, ref[1:]); !found {
+			return false
+		}
+	}
+
+	switch c.Op {
+	case OpEq:
+		return exists && sameValue(actual, expected)
+	case OpNe:
+		return !exists || !sameValue(actual, expected)
+	case OpIn, OpNotIn:
+		in := false
+		if list, ok := expected.([]any); ok {
+			for _, v := range list {
+				if exists && sameValue(actual, v) {
+					in = true
+					break
+				}
+			}
+		}
+		return in == (c.Op == OpIn)
+	case OpPrefix:
+		return exists && strings.HasPrefix(fmt.Sprint(actual), fmt.Sprint(expected))
+	}
+	return false
+}
+
+// sameValue 按字符串形式比较，JSON 解码的数字与整数 ID 可以直接比较
+func sameValue(a, b any) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// lookup 按路径读取输入中的属性
+func lookup(in Input, attr string) (any, bool) {
+	scope, key, _ := strings.Cut(attr, ".")
+	switch scope {
+	case "resource":
+		return in.Resource, true
+	case "action":
+		return in.Action, true
+	case "subject":
+		switch key {
+		case "id":
+			return in.Subject.ID, true
+		case "role":
+			return in.Subject.Role, true
+		case "groups":
+			return in.Subject.Groups, true
+		}
+		v, ok := in.Subject.Attrs[key]
+		return v, ok
+	case "object":
+		v, ok := in.Object[key]
+		return v, ok
+	case "env":
+		v, ok := in.Env[key]
+		return v, ok
+	}
+	return nil, false
+}
+
+// RuleEngine 内置规则引擎。适用的规则中优先级最高者决定结论，同优先级时 deny 优先
+type RuleEngine struct {
+	rules []Rule
+}
+
+// NewRuleEngine 创建规则引擎
+func NewRuleEngine(rules []Rule) *RuleEngine {
+	sorted := make([]Rule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].Effect == EffectDeny && sorted[j].Effect != EffectDeny
+	})
+	return &RuleEngine{rules: sorted}
+}
+
+func (e *RuleEngine) Name() string {
+	return "rules"
+}
+
+// Evaluate 评估输入
+func (e *RuleEngine) Evaluate(_ context.Context, in Input) (Decision, error) {
+	for i := range e.rules {
+		r := &e.rules[i]
+		if r.Matches(in) {
+			return Decision{
+				Effect: r.Effect,
+				RuleID: r.ID,
+				Reason: fmt.Sprintf("matched %s rule %s for %s on %s", r.Effect, r.ID, r.Subject, r.Resource),
+				Engine: e.Name(),
+			}, nil
+		}
+	}
+	return Decision{Effect: EffectNone, Reason: "no rule applies", Engine: e.Name()}, nil
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleEngine_RegionScopedRecordings(t *testing.T) {
+	rules := []Rule{
+		{
+			ID:       "support-other-region",
+			Subject:  "role:support",
+			Resource: "call_recordings*",
+			Actions:  []string{"read"},
+			Effect:   EffectDeny,
+			Conditions: []Condition{
+				{Attr: "object.region", Op: OpNe, Value: "$subject.region"},
+			},
+		},
+		{ID: "support-write", Subject: "role:support", Resource: "call_recordings*", Actions: []string{"write", "delete"}, Effect: EffectDeny},
+		{ID: "user-7", Subject: "user:7", Resource: "*", Effect: EffectAllow, Priority: 10},
+	}
+	for i := range rules {
+		require.NoError(t, rules[i].Validate())
+	}
+	engine := NewRuleEngine(rules)
+	ctx := context.Background()
+
+	support := Subject{ID: 3, Role: "support", Attrs: map[string]any{"region": "eu"}}
+	d, err := engine.Evaluate(ctx, Input{Subject: support, Resource: "call_recordings/1", Action: "read", Object: map[string]any{"region": "eu"}})
+	require.NoError(t, err)
+	assert.Equal(t, EffectNone, d.Effect)
+	assert.True(t, d.Allowed())
+
+	d, _ = engine.Evaluate(ctx, Input{Subject: support, Resource: "call_recordings/1", Action: "read", Object: map[string]any{"region": "us"}})
+	assert.Equal(t, EffectDeny, d.Effect)
+	assert.Equal(t, "support-other-region", d.RuleID)
+	assert.False(t, d.Allowed())
+
+	d, _ = engine.Evaluate(ctx, Input{Subject: support, Resource: "call_recordings", Action: "delete"})
+	assert.Equal(t, "support-write", d.RuleID)
+
+	// Higher priority wins over the deny rules
+	d, _ = engine.Evaluate(ctx, Input{Subject: Subject{ID: 7, Role: "support"}, Resource: "call_recordings", Action: "delete"})
+	assert.Equal(t, EffectAllow, d.Effect)
+}
+
+func TestRuleEngine_Conditions(t *testing.T) {
+	in := Input{
+		Subject:  Subject{ID: 5, Role: "user", Groups: []uint{2, 9}},
+		Resource: "assistants",
+		Action:   "read",
+		Object:   map[string]any{"ownerId": float64(5), "tier": "gold"},
+		Env:      map[string]any{"ip": "10.1.2.3"},
+	}
+	cases := []struct {
+		rule Rule
+		want bool
+	}{
+		{Rule{Subject: "group:9", Resource: "assistants"}, true},
+		{Rule{Subject: "group:3", Resource: "assistants"}, false},
+		{Rule{Subject: "*", Resource: "assistants", Conditions: []Condition{{Attr: "object.ownerId", Op: OpEq, Value: "$subject.id"}}}, true},
+		{Rule{Subject: "*", Resource: "assistants", Conditions: []Condition{{Attr: "object.tier", Op: OpIn, Value: []any{"gold", "silver"}}}}, true},
+		{Rule{Subject: "*", Resource: "assistants", Conditions: []Condition{{Attr: "object.tier", Op: OpNotIn, Value: []any{"gold"}}}}, false},
+		{Rule{Subject: "*", Resource: "assistants", Conditions: []Condition{{Attr: "env.ip", Op: OpPrefix, Value: "10."}}}, true},
+		{Rule{Subject: "*", Resource: "assistants", Conditions: []Condition{{Attr: "object.region", Op: OpExists}}}, false},
+		{Rule{Subject: "*", Resource: "assistants", Actions: []string{"write"}}, false},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.want, c.rule.Matches(in), "case %d", i)
+	}
+
+	assert.Error(t, (&Rule{Subject: "team:1", Resource: "x", Effect: EffectAllow}).Validate())
+	assert.Error(t, (&Rule{Subject: "*", Resource: "x", Effect: "maybe"}).Validate())
+	assert.Error(t, (&Rule{Subject: "*", Resource: "x", Effect: EffectDeny, Conditions: []Condition{{Attr: "a", Op: "like"}}}).Validate())
+}
+
+func TestOPAEngine(t *testing.T) {
+	var result string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/data/lingecho/authz", r.URL.Path)
+		var body struct {
+			Input Input `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "read", body.Input.Action)
+		_, _ = w.Write([]byte(result))
+	}))
+	defer srv.Close()
+
+	engine := NewOPAEngine(srv.URL+"/", "lingecho.authz")
+	in := Input{Subject: Subject{ID: 1}, Resource: "call_recordings", Action: "read"}
+
+	result = `{"result": true}`
+	d, err := engine.Evaluate(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, EffectAllow, d.Effect)
+
+	result = `{"result": {"allow": false, "reason": "region mismatch"}}`
+	d, err = engine.Evaluate(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, EffectDeny, d.Effect)
+	assert.Equal(t, "region mismatch", d.Reason)
+
+	result = `{}`
+	d, err = engine.Evaluate(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, EffectNone, d.Effect)
+
+	result = `{"result": "yes"}`
+	_, err = engine.Evaluate(context.Background(), in)
+	assert.Error(t, err)
+}
+
+func TestWildcardMatch(t *testing.T) {
+	assert.True(t, wildcardMatch("*", "a/b"))
+	assert.True(t, wildcardMatch("call_recordings*", "call_recordings/1/analysis"))
+	assert.True(t, wildcardMatch("a/*/c", "a/b/c"))
+	assert.False(t, wildcardMatch("a/*/c", "a/b/d"))
+	assert.False(t, wildcardMatch("abc", "abcd"))
+	assert.False(t, wildcardMatch("ab*ba", "aba"))
+}
@@ -0,0 +1,260 @@
+package presence
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Status presence status of a user or SIP agent
+type Status string
+
+const (
+	StatusAvailable Status = "available"
+	StatusBusy      Status = "busy"
+	StatusDND       Status = "dnd"
+	StatusOffline   Status = "offline"
+)
+
+// Subject kinds
+const (
+	KindUser = "user" // System user, ID is the user ID
+	KindSIP  = "sip"  // SIP agent, ID is the SIP username
+)
+
+var (
+	ErrInvalidStatus = errors.New("invalid presence status")
+	ErrInvalidKind   = errors.New("invalid presence subject kind")
+)
+
+// State presence of a subject.
+// Status is the effective status: a manual DND always wins, an active call
+// makes an otherwise available subject busy, then the manual status applies.
+type State struct {
+	Kind        string     `json:"kind"`
+	ID          string     `json:"id"`
+	Status      Status     `json:"status"`
+	Manual      Status     `json:"manual"`
+	Note        string     `json:"note,omitempty"`
+	Until       *time.Time `json:"until,omitempty"` // Manual status reverts to available afterwards
+	ActiveCalls int        `json:"activeCalls"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// Available reports whether the subject may be rung
+func (s State) Available() bool {
+	return s.Status == StatusAvailable
+}
+
+// ValidStatus reports whether status can be set manually
+func ValidStatus(status Status) bool {
+	switch status {
+	case StatusAvailable, StatusBusy, StatusDND, StatusOffline:
+		return true
+	}
+	return false
+}
+
+// ValidKind reports whether kind is a known subject kind
+func ValidKind(kind string) bool {
+	return kind == KindUser || kind == KindSIP
+}
+
+type entry struct {
+	manual    Status
+	note      string
+	until     *time.Time
+	calls     int
+	updatedAt time.Time
+	timer     *time.Timer
+}
+
+// Registry in-memory presence registry. Listeners are called on every
+// effective change, outside the registry lock.
+type Registry struct {
+	mu        sync.RWMutex
+	entries   map[string]*entry
+	listeners map[int]func(State)
+	nextID    int
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process-wide registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		entries:   make(map[string]*entry),
+		listeners: make(map[int]func(State)),
+	}
+}
+
+func key(kind, id string) string {
+	return kind + ":" + id
+}
+
+// Get returns the presence of a subject, available when unknown
+func (r *Registry) Get(kind, id string) State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state(kind, id)
+}
+
+// List returns the presence of the subjects in order
+func (r *Registry) List(kind string, ids []string) []State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	states := make([]State, 0, len(ids))
+	for _, id := range ids {
+		states = append(states, r.state(kind, id))
+	}
+	return states
+}
+
+// Set sets the manual status. A non-nil until makes the status revert to
+// available at that time.
+func (r *Registry) Set(kind, id string, status Status, note string, until *time.Time) (State, error) {
+	if !ValidKind(kind) {
+		return State{}, ErrInvalidKind
+	}
+	if !ValidStatus(status) {
+		return State{}, ErrInvalidStatus
+	}
+	if until != nil && !until.After(time.Now()) {
+		status, note, until = StatusAvailable, "", nil
+	}
+
+	r.mu.Lock()
+	e := r.entry(kind, id)
+	e.manual, e.note, e.until = status, note, until
+	e.updatedAt = time.Now()
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	if until != nil {
+		expiry := *until
+		e.timer = time.AfterFunc(time.Until(expiry), func() { r.expire(kind, id, expiry) })
+	}
+	state := r.state(kind, id)
+	r.mu.Unlock()
+
+	r.notify(state)
+	return state, nil
+}
+
+// CallStarted records an active call of the subject
+func (r *Registry) CallStarted(kind, id string) {
+	r.updateCalls(kind, id, 1)
+}
+
+// CallEnded records the end of an active call of the subject
+func (r *Registry) CallEnded(kind, id string) {
+	r.updateCalls(kind, id, -1)
+}
+
+// Subscribe registers a listener for presence changes and returns a function
+// removing it
+func (r *Registry) Subscribe(fn func(State)) func() {
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.listeners[id] = fn
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.listeners, id)
+		r.mu.Unlock()
+	}
+}
+
+func (r *Registry) updateCalls(kind, id string, delta int) {
+	r.mu.Lock()
+	e := r.entry(kind, id)
+	before := r.state(kind, id).Status
+	e.calls += delta
+	if e.calls < 0 {
+		e.calls = 0
+	}
+	e.updatedAt = time.Now()
+	state := r.state(kind, id)
+	if e.calls == 0 && e.manual == StatusAvailable && e.note == "" {
+		r.release(kind, id, e)
+	}
+	r.mu.Unlock()
+
+	if state.Status != before {
+		r.notify(state)
+	}
+}
+
+// expire reverts a timed manual status, unless it was replaced meanwhile
+func (r *Registry) expire(kind, id string, expiry time.Time) {
+	r.mu.Lock()
+	e, ok := r.entries[key(kind, id)]
+	if !ok || e.until == nil || !e.until.Equal(expiry) {
+		r.mu.Unlock()
+		return
+	}
+	e.manual, e.note, e.until, e.timer = StatusAvailable, "", nil, nil
+	e.updatedAt = time.Now()
+	state := r.state(kind, id)
+	r.mu.Unlock()
+
+	r.notify(state)
+}
+
+func (r *Registry) entry(kind, id string) *entry {
+	k := key(kind, id)
+	e, ok := r.entries[k]
+	if !ok {
+		e = &entry{manual: StatusAvailable}
+		r.entries[k] = e
+	}
+	return e
+}
+
+// release drops entries that carry no information
+func (r *Registry) release(kind, id string, e *entry) {
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(r.entries, key(kind, id))
+}
+
+func (r *Registry) state(kind, id string) State {
+	state := State{Kind: kind, ID: id, Status: StatusAvailable, Manual: StatusAvailable}
+	e, ok := r.entries[key(kind, id)]
+	if !ok {
+		return state
+	}
+	state.Manual, state.Note, state.Until = e.manual, e.note, e.until
+	state.ActiveCalls, state.UpdatedAt = e.calls, e.updatedAt
+	switch {
+	case e.manual == StatusDND:
+		state.Status = StatusDND
+	case e.calls > 0 && (e.manual == StatusAvailable || e.manual == StatusOffline):
+		state.Status = StatusBusy
+	default:
+		state.Status = e.manual
+	}
+	return state
+}
+
+func (r *Registry) notify(state State) {
+	r.mu.RLock()
+	listeners := make([]func(State), 0, len(r.listeners))
+	for _, fn := range r.listeners {
+		listeners = append(listeners, fn)
+	}
+	r.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(state)
+	}
+}
@@ -0,0 +1,76 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_EffectiveStatus(t *testing.T) {
+	r := NewRegistry()
+	assert.True(t, r.Get(KindSIP, "alice").Available())
+
+	r.CallStarted(KindSIP, "alice")
+	state := r.Get(KindSIP, "alice")
+	assert.Equal(t, StatusBusy, state.Status)
+	assert.Equal(t, StatusAvailable, state.Manual)
+	assert.Equal(t, 1, state.ActiveCalls)
+
+	_, err := r.Set(KindSIP, "alice", StatusDND, "focus", nil)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDND, r.Get(KindSIP, "alice").Status, "DND wins over calls")
+
+	_, err = r.Set(KindSIP, "alice", StatusAvailable, "", nil)
+	require.NoError(t, err)
+	r.CallEnded(KindSIP, "alice")
+	r.CallEnded(KindSIP, "alice")
+	state = r.Get(KindSIP, "alice")
+	assert.True(t, state.Available())
+	assert.Equal(t, 0, state.ActiveCalls)
+
+	_, err = r.Set(KindSIP, "alice", "away", "", nil)
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+	_, err = r.Set("queue", "alice", StatusBusy, "", nil)
+	assert.ErrorIs(t, err, ErrInvalidKind)
+}
+
+func TestRegistry_Subscribe(t *testing.T) {
+	r := NewRegistry()
+	var changes []State
+	unsubscribe := r.Subscribe(func(s State) { changes = append(changes, s) })
+
+	r.CallStarted(KindUser, "1")
+	r.CallStarted(KindUser, "1") // still busy, no change
+	r.CallEnded(KindUser, "1")
+	r.CallEnded(KindUser, "1")
+	require.Len(t, changes, 2)
+	assert.Equal(t, StatusBusy, changes[0].Status)
+	assert.Equal(t, StatusAvailable, changes[1].Status)
+
+	unsubscribe()
+	_, _ = r.Set(KindUser, "1", StatusBusy, "", nil)
+	assert.Len(t, changes, 2)
+}
+
+func TestRegistry_Until(t *testing.T) {
+	r := NewRegistry()
+	done := make(chan State, 2)
+	r.Subscribe(func(s State) { done <- s })
+
+	until := time.Now().Add(30 * time.Millisecond)
+	state, err := r.Set(KindUser, "7", StatusDND, "meeting", &until)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDND, state.Status)
+	<-done
+
+	select {
+	case s := <-done:
+		assert.Equal(t, StatusAvailable, s.Status)
+		assert.Empty(t, s.Note)
+	case <-time.After(time.Second):
+		t.Fatal("timed status did not expire")
+	}
+	assert.True(t, r.Get(KindUser, "7").Available())
+}
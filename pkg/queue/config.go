@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backends
+const (
+	BackendMemory = "memory" // single instance, jobs are lost on restart
+	BackendRedis  = "redis"  // shared by all instances, uses the REDIS_* connection settings
+)
+
+// Config job queue configuration
+type Config struct {
+	Backend     string `env:"QUEUE_BACKEND"`      // memory (default) or redis
+	Workers     int    `env:"QUEUE_WORKERS"`      // concurrent workers per instance
+	MaxAttempts int    `env:"QUEUE_MAX_ATTEMPTS"` // attempts before a job is dead lettered
+	RedisPrefix string `env:"QUEUE_REDIS_PREFIX"` // key prefix for the redis backend
+}
+
+// NewFromConfig creates a queue with the configured backend, redisOptions is only used by the redis backend
+func NewFromConfig(cfg Config, redisOptions *redis.Options) (*Queue, error) {
+	var backend Backend
+	switch cfg.Backend {
+	case "", BackendMemory:
+		backend = NewMemoryBackend()
+	case BackendRedis:
+		if redisOptions == nil {
+			return nil, fmt.Errorf("redis queue backend requires redis options")
+		}
+		client := redis.NewClient(redisOptions)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to redis: %w", err)
+		}
+		backend = NewRedisBackend(client, cfg.RedisPrefix)
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q", cfg.Backend)
+	}
+	q := New(backend)
+	if cfg.Workers > 0 {
+		q.Workers = cfg.Workers
+	}
+	if cfg.MaxAttempts > 0 {
+		q.MaxAttempts = cfg.MaxAttempts
+	}
+	return q, nil
+}
@@ -0,0 +1,162 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps jobs in process memory, jobs are lost on restart.
+// Finished jobs are kept for Retention, dead jobs for DeadRetention
+type MemoryBackend struct {
+	Retention     time.Duration
+	DeadRetention time.Duration
+	MaxDead       int // cap on the dead letter list
+
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	scheduled jobHeap
+	finished  map[string]time.Time // id -> time the record expires
+	dead      []string             // oldest first
+	lastPrune time.Time
+}
+
+// NewMemoryBackend creates an in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		Retention:     24 * time.Hour,
+		DeadRetention: 7 * 24 * time.Hour,
+		MaxDead:       1000,
+		jobs:          make(map[string]*Job),
+		finished:      make(map[string]time.Time),
+	}
+}
+
+// Enqueue stores the job and schedules it at job.RunAt
+func (b *MemoryBackend) Enqueue(_ context.Context, job *Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if old, ok := b.jobs[job.ID]; ok && old.Status == StatusDead {
+		b.removeDead(job.ID)
+	}
+	delete(b.finished, job.ID)
+	stored := *job
+	b.jobs[job.ID] = &stored
+	heap.Push(&b.scheduled, scheduledJob{id: job.ID, runAt: job.RunAt})
+	return nil
+}
+
+// Dequeue claims the earliest job due at now
+func (b *MemoryBackend) Dequeue(_ context.Context, now time.Time) (*Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prune(now)
+	for len(b.scheduled) > 0 && !b.scheduled[0].runAt.After(now) {
+		next := heap.Pop(&b.scheduled).(scheduledJob)
+		job, ok := b.jobs[next.id]
+		if !ok || (job.Status != StatusPending && job.Status != StatusRetrying) {
+			continue
+		}
+		job.Status = StatusRunning
+		job.Attempts++
+		job.UpdatedAt = now
+		claimed := *job
+		return &claimed, nil
+	}
+	return nil, nil
+}
+
+// Finish records the job's final status
+func (b *MemoryBackend) Finish(_ context.Context, job *Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stored := *job
+	b.jobs[job.ID] = &stored
+	if job.Status == StatusDead {
+		b.finished[job.ID] = job.UpdatedAt.Add(b.DeadRetention)
+		b.dead = append(b.dead, job.ID)
+		if b.MaxDead > 0 && len(b.dead) > b.MaxDead {
+			for _, id := range b.dead[:len(b.dead)-b.MaxDead] {
+				delete(b.jobs, id)
+				delete(b.finished, id)
+			}
+			b.dead = append([]string(nil), b.dead[len(b.dead)-b.MaxDead:]...)
+		}
+	} else {
+		b.finished[job.ID] = job.UpdatedAt.Add(b.Retention)
+	}
+	return nil
+}
+
+// Get returns a copy of the job
+func (b *MemoryBackend) Get(_ context.Context, id string) (*Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	job, ok := b.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	found := *job
+	return &found, nil
+}
+
+// DeadLetters lists dead jobs, newest first
+func (b *MemoryBackend) DeadLetters(_ context.Context, limit int) ([]*Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var jobs []*Job
+	for i := len(b.dead) - 1; i >= 0 && (limit <= 0 || len(jobs) < limit); i-- {
+		if job, ok := b.jobs[b.dead[i]]; ok {
+			found := *job
+			jobs = append(jobs, &found)
+		}
+	}
+	return jobs, nil
+}
+
+// prune drops finished jobs past their retention, at most once a minute
+func (b *MemoryBackend) prune(now time.Time) {
+	if now.Sub(b.lastPrune) < time.Minute {
+		return
+	}
+	b.lastPrune = now
+	for id, expiresAt := range b.finished {
+		if now.After(expiresAt) {
+			if b.jobs[id] != nil && b.jobs[id].Status == StatusDead {
+				b.removeDead(id)
+			}
+			delete(b.jobs, id)
+			delete(b.finished, id)
+		}
+	}
+}
+
+func (b *MemoryBackend) removeDead(id string) {
+	for i, deadID := range b.dead {
+		if deadID == id {
+			b.dead = append(b.dead[:i], b.dead[i+1:]...)
+			return
+		}
+	}
+}
+
+type scheduledJob struct {
+	id    string
+	runAt time.Time
+}
+
+// jobHeap orders scheduled jobs by run time
+type jobHeap []scheduledJob
+
+func (h jobHeap) Len() int            { return len(h) }
+func (h jobHeap) Less(i, j int) bool  { return h[i].runAt.Before(h[j].runAt) }
+func (h jobHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x interface{}) { *h = append(*h, x.(scheduledJob)) }
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
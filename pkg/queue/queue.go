@@ -0,0 +1,429 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Status is the delivery status of a job
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // waiting for its first attempt
+	StatusRunning   Status = "running"   // claimed by a worker
+	StatusRetrying  Status = "retrying"  // failed, scheduled for another attempt
+	StatusSucceeded Status = "succeeded" // handled successfully
+	StatusDead      Status = "dead"      // out of attempts or failed permanently, kept in the dead letter list
+)
+
+var (
+	// ErrJobNotFound the job doesn't exist or its record has expired
+	ErrJobNotFound = errors.New("job not found")
+	// ErrUnknownJobType no handler is registered for the job type
+	ErrUnknownJobType = errors.New("unknown job type")
+)
+
+// Job is a unit of work, Payload is the JSON handed to the handler of Type
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      Status          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	LastError   string          `json:"lastError,omitempty"`
+	RunAt       time.Time       `json:"runAt"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// Decode unmarshals the payload into v
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Backend stores jobs and hands them out to workers
+type Backend interface {
+	// Enqueue stores the job and schedules it at job.RunAt, also used to reschedule retries
+	Enqueue(ctx context.Context, job *Job) error
+	// Dequeue claims the next job due at now, marking it running and counting the attempt.
+	// Returns nil when no job is due
+	Dequeue(ctx context.Context, now time.Time) (*Job, error)
+	// Finish records a succeeded or dead job, dead jobs are added to the dead letter list
+	Finish(ctx context.Context, job *Job) error
+	// Get returns a job by ID
+	Get(ctx context.Context, id string) (*Job, error)
+	// DeadLetters lists dead jobs, newest first
+	DeadLetters(ctx context.Context, limit int) ([]*Job, error)
+}
+
+// Handler processes a job. Returning an error retries the job unless it is Permanent
+type Handler func(ctx context.Context, job *Job) error
+
+// DeadHandler is called once a job of its type has been moved to the dead letter list
+type DeadHandler func(ctx context.Context, job *Job)
+
+// RedactFunc returns the payload with the data that must not outlive delivery removed
+type RedactFunc func(payload json.RawMessage) json.RawMessage
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, the job goes straight to the dead letter list
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Queue runs registered handlers for enqueued jobs on a pool of workers,
+// retrying failures with exponential backoff
+type Queue struct {
+	backend Backend
+
+	Workers      int           // number of concurrent workers
+	MaxAttempts  int           // attempts before a job is dead lettered, per job override at Enqueue
+	PollInterval time.Duration // how often idle workers check for due jobs
+	JobTimeout   time.Duration // deadline for a single attempt
+	BaseBackoff  time.Duration // delay before the first retry, doubled on each further attempt
+	MaxBackoff   time.Duration // upper bound of the retry delay
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	dead     map[string]DeadHandler
+	redact   map[string]RedactFunc
+
+	now    func() time.Time
+	wake   chan struct{}
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	active bool
+}
+
+// New creates a queue on the backend with default settings, register handlers then Start it
+func New(backend Backend) *Queue {
+	return &Queue{
+		backend:      backend,
+		Workers:      4,
+		MaxAttempts:  5,
+		PollInterval: time.Second,
+		JobTimeout:   time.Minute,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   10 * time.Minute,
+		handlers:     make(map[string]Handler),
+		dead:         make(map[string]DeadHandler),
+		redact:       make(map[string]RedactFunc),
+		now:          time.Now,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler for a job type
+func (q *Queue) Register(jobType string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+// OnDead sets the callback run when a job of the type is dead lettered
+func (q *Queue) OnDead(jobType string, handler DeadHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dead[jobType] = handler
+}
+
+// Redact sets the function applied to the payload of jobs of the type once they have
+// succeeded or been dead lettered, so records kept for inspection don't hold secrets
+// such as one-time codes
+func (q *Queue) Redact(jobType string, fn RedactFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.redact[jobType] = fn
+}
+
+// EnqueueOption adjusts a job before it is stored
+type EnqueueOption func(*Job)
+
+// WithMaxAttempts overrides the queue's MaxAttempts for the job
+func WithMaxAttempts(n int) EnqueueOption {
+	return func(j *Job) { j.MaxAttempts = n }
+}
+
+// WithDelay schedules the first attempt after d
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(j *Job) { j.RunAt = j.RunAt.Add(d) }
+}
+
+// Enqueue stores a job of the registered type with payload marshalled to JSON
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	q.mu.RLock()
+	_, ok := q.handlers[jobType]
+	q.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", jobType, err)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	now := q.now()
+	job := &Job{
+		ID:          id,
+		Type:        jobType,
+		Payload:     data,
+		Status:      StatusPending,
+		MaxAttempts: q.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if err := q.backend.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	q.notify()
+	return job, nil
+}
+
+// Get returns the job with its current delivery status
+func (q *Queue) Get(ctx context.Context, id string) (*Job, error) {
+	return q.backend.Get(ctx, id)
+}
+
+// DeadLetters lists dead jobs, newest first
+func (q *Queue) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	return q.backend.DeadLetters(ctx, limit)
+}
+
+// Requeue gives a dead job a fresh set of attempts
+func (q *Queue) Requeue(ctx context.Context, id string) (*Job, error) {
+	job, err := q.backend.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != StatusDead {
+		return nil, fmt.Errorf("job %s is %s, only dead jobs can be requeued", id, job.Status)
+	}
+	now := q.now()
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.LastError = ""
+	job.RunAt = now
+	job.UpdatedAt = now
+	if err := q.backend.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	q.notify()
+	return job, nil
+}
+
+// Start launches the workers, Stop waits for the jobs in progress
+func (q *Queue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active {
+		return
+	}
+	q.active = true
+	q.stop = make(chan struct{})
+	workers := q.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work(q.stop)
+	}
+}
+
+// Stop stops the workers after their current job
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	if !q.active {
+		q.mu.Unlock()
+		return
+	}
+	q.active = false
+	close(q.stop)
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) work(stop <-chan struct{}) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if q.runNext(context.Background()) {
+			continue
+		}
+		select {
+		case <-stop:
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue handles the jobs due now on the calling goroutine and returns how many ran,
+// for tools and tests that don't start the workers
+func (q *Queue) RunDue(ctx context.Context) int {
+	n := 0
+	for q.runNext(ctx) {
+		n++
+	}
+	return n
+}
+
+// runNext handles one due job, returning false when none was due
+func (q *Queue) runNext(ctx context.Context) bool {
+	job, err := q.backend.Dequeue(ctx, q.now())
+	if err != nil {
+		logger.Warn("Failed to dequeue job", zap.Error(err))
+		return false
+	}
+	if job == nil {
+		return false
+	}
+	q.process(ctx, job)
+	return true
+}
+
+func (q *Queue) process(ctx context.Context, job *Job) {
+	q.mu.RLock()
+	handler := q.handlers[job.Type]
+	q.mu.RUnlock()
+
+	var err error
+	if handler == nil {
+		err = Permanent(fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type))
+	} else {
+		err = q.call(ctx, handler, job)
+	}
+
+	now := q.now()
+	job.UpdatedAt = now
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.LastError = ""
+		q.redactPayload(job)
+		if err := q.backend.Finish(ctx, job); err != nil {
+			logger.Warn("Failed to record finished job", zap.String("jobID", job.ID), zap.Error(err))
+		}
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		job.Status = StatusDead
+		job.LastError = err.Error()
+		q.redactPayload(job)
+		if err := q.backend.Finish(ctx, job); err != nil {
+			logger.Warn("Failed to record dead job", zap.String("jobID", job.ID), zap.Error(err))
+		}
+		logger.Error("Job moved to dead letter list",
+			zap.String("jobID", job.ID),
+			zap.String("type", job.Type),
+			zap.Int("attempts", job.Attempts),
+			zap.Error(err))
+		q.mu.RLock()
+		onDead := q.dead[job.Type]
+		q.mu.RUnlock()
+		if onDead != nil {
+			onDead(ctx, job)
+		}
+	default:
+		job.Status = StatusRetrying
+		job.LastError = err.Error()
+		job.RunAt = now.Add(q.backoff(job.Attempts))
+		if err := q.backend.Enqueue(ctx, job); err != nil {
+			logger.Error("Failed to reschedule job", zap.String("jobID", job.ID), zap.Error(err))
+			return
+		}
+		logger.Warn("Job failed, will retry",
+			zap.String("jobID", job.ID),
+			zap.String("type", job.Type),
+			zap.Int("attempts", job.Attempts),
+			zap.Time("runAt", job.RunAt),
+			zap.Error(err))
+	}
+}
+
+// redactPayload applies the job type's RedactFunc before the final record is stored
+func (q *Queue) redactPayload(job *Job) {
+	q.mu.RLock()
+	redact := q.redact[job.Type]
+	q.mu.RUnlock()
+	if redact != nil {
+		job.Payload = redact(job.Payload)
+	}
+}
+
+// call runs the handler with the job timeout, turning panics into errors
+func (q *Queue) call(ctx context.Context, handler Handler, job *Job) (err error) {
+	if q.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.JobTimeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff is the delay before the retry following the given attempt
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.BaseBackoff
+	for i := 1; i < attempt && d < q.MaxBackoff; i++ {
+		d *= 2
+	}
+	if q.MaxBackoff > 0 && d > q.MaxBackoff {
+		d = q.MaxBackoff
+	}
+	return d
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,208 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Lg = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+func newTestQueue(backend Backend) (*Queue, *time.Time) {
+	q := New(backend)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	return q, &now
+}
+
+func TestQueue_RetryThenSucceed(t *testing.T) {
+	q, now := newTestQueue(NewMemoryBackend())
+	var calls int32
+	q.Register("mail", func(ctx context.Context, job *Job) error {
+		var payload map[string]string
+		require.NoError(t, job.Decode(&payload))
+		assert.Equal(t, "a@example.com", payload["to"])
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("smtp unavailable")
+		}
+		return nil
+	})
+
+	job, err := q.Enqueue(context.Background(), "mail", map[string]string{"to": "a@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, job.Status)
+
+	ctx := context.Background()
+	assert.True(t, q.runNext(ctx))
+	got, err := q.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRetrying, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, "smtp unavailable", got.LastError)
+
+	// Not due until the backoff has passed
+	assert.False(t, q.runNext(ctx))
+	*now = now.Add(q.BaseBackoff)
+	assert.True(t, q.runNext(ctx))
+	assert.False(t, q.runNext(ctx), "second retry waits twice as long")
+	*now = now.Add(2 * q.BaseBackoff)
+	assert.True(t, q.runNext(ctx))
+
+	got, err = q.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, got.Status)
+	assert.Equal(t, 3, got.Attempts)
+	assert.Empty(t, got.LastError)
+}
+
+func TestQueue_DeadLetter(t *testing.T) {
+	q, now := newTestQueue(NewMemoryBackend())
+	q.MaxAttempts = 2
+	var dead []*Job
+	q.Register("sms", func(ctx context.Context, job *Job) error {
+		return errors.New("gateway timeout")
+	})
+	q.OnDead("sms", func(ctx context.Context, job *Job) { dead = append(dead, job) })
+	q.Register("invalid", func(ctx context.Context, job *Job) error {
+		return Permanent(errors.New("invalid phone number"))
+	})
+
+	ctx := context.Background()
+	job, err := q.Enqueue(ctx, "sms", nil)
+	require.NoError(t, err)
+	assert.True(t, q.runNext(ctx))
+	*now = now.Add(time.Hour)
+	assert.True(t, q.runNext(ctx))
+	require.Len(t, dead, 1)
+	assert.Equal(t, job.ID, dead[0].ID)
+	assert.Equal(t, StatusDead, dead[0].Status)
+
+	// Permanent errors skip the remaining attempts
+	invalid, err := q.Enqueue(ctx, "invalid", nil, WithMaxAttempts(10))
+	require.NoError(t, err)
+	assert.True(t, q.runNext(ctx))
+	got, err := q.Get(ctx, invalid.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDead, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+
+	letters, err := q.DeadLetters(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, invalid.ID, letters[0].ID, "newest first")
+
+	// Requeued jobs get a fresh set of attempts
+	requeued, err := q.Requeue(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, requeued.Status)
+	assert.Zero(t, requeued.Attempts)
+	letters, err = q.DeadLetters(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, letters, 1)
+	_, err = q.Requeue(ctx, job.ID)
+	assert.Error(t, err)
+}
+
+func TestQueue_Redact(t *testing.T) {
+	q, _ := newTestQueue(NewMemoryBackend())
+	q.MaxAttempts = 1
+	fail := false
+	q.Register("sms", func(ctx context.Context, job *Job) error {
+		var m map[string]string
+		require.NoError(t, job.Decode(&m))
+		assert.Equal(t, "123456", m["code"], "handlers see the full payload")
+		if fail {
+			return errors.New("gateway timeout")
+		}
+		return nil
+	})
+	q.Redact("sms", func(payload json.RawMessage) json.RawMessage {
+		return json.RawMessage(`{"code":""}`)
+	})
+
+	ctx := context.Background()
+	for _, fail = range []bool{false, true} {
+		job, err := q.Enqueue(ctx, "sms", map[string]string{"code": "123456"})
+		require.NoError(t, err)
+		assert.True(t, q.runNext(ctx))
+		got, err := q.Get(ctx, job.ID)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"code":""}`, string(got.Payload), "failed: %v", fail)
+	}
+}
+
+func TestQueue_EnqueueUnknownType(t *testing.T) {
+	q, _ := newTestQueue(NewMemoryBackend())
+	_, err := q.Enqueue(context.Background(), "missing", nil)
+	assert.ErrorIs(t, err, ErrUnknownJobType)
+}
+
+func TestQueue_PanicIsRetried(t *testing.T) {
+	q, _ := newTestQueue(NewMemoryBackend())
+	q.Register("boom", func(ctx context.Context, job *Job) error { panic("nil provider") })
+	ctx := context.Background()
+	job, err := q.Enqueue(ctx, "boom", nil)
+	require.NoError(t, err)
+	assert.True(t, q.runNext(ctx))
+	got, err := q.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRetrying, got.Status)
+	assert.Contains(t, got.LastError, "nil provider")
+}
+
+func TestQueue_Backoff(t *testing.T) {
+	q := New(NewMemoryBackend())
+	assert.Equal(t, 5*time.Second, q.backoff(1))
+	assert.Equal(t, 10*time.Second, q.backoff(2))
+	assert.Equal(t, 40*time.Second, q.backoff(4))
+	assert.Equal(t, q.MaxBackoff, q.backoff(20))
+}
+
+func TestQueue_Workers(t *testing.T) {
+	q := New(NewMemoryBackend())
+	q.PollInterval = 10 * time.Millisecond
+	done := make(chan string, 3)
+	q.Register("mail", func(ctx context.Context, job *Job) error {
+		done <- job.ID
+		return nil
+	})
+	q.Start()
+	defer q.Stop()
+
+	for i := 0; i < 3; i++ {
+		_, err := q.Enqueue(context.Background(), "mail", i)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("job was not processed")
+		}
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	q, err := NewFromConfig(Config{Workers: 2, MaxAttempts: 3}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, q.Workers)
+	assert.Equal(t, 3, q.MaxAttempts)
+	assert.IsType(t, &MemoryBackend{}, q.backend)
+
+	_, err = NewFromConfig(Config{Backend: BackendRedis}, nil)
+	assert.Error(t, err)
+	_, err = NewFromConfig(Config{Backend: "kafka"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBackend shares jobs between instances through Redis. A claimed job is leased
+// for LeaseTimeout, jobs whose worker died before finishing are handed out again
+// once the lease runs out.
+//
+// Keys under Prefix: job:<id> holds the job JSON, scheduled and running are sorted
+// sets of job IDs scored by run time and lease deadline, dead lists dead job IDs.
+type RedisBackend struct {
+	client        redis.UniversalClient
+	Prefix        string
+	LeaseTimeout  time.Duration
+	Retention     time.Duration
+	DeadRetention time.Duration
+	MaxDead       int64
+}
+
+// NewRedisBackend creates a Redis backend, prefix defaults to lingecho:queue
+func NewRedisBackend(client redis.UniversalClient, prefix string) *RedisBackend {
+	if prefix == "" {
+		prefix = "lingecho:queue"
+	}
+	return &RedisBackend{
+		client:        client,
+		Prefix:        prefix,
+		LeaseTimeout:  5 * time.Minute,
+		Retention:     24 * time.Hour,
+		DeadRetention: 7 * 24 * time.Hour,
+		MaxDead:       1000,
+	}
+}
+
+func (b *RedisBackend) jobKey(id string) string { return b.Prefix + ":job:" + id }
+func (b *RedisBackend) scheduledKey() string    { return b.Prefix + ":scheduled" }
+func (b *RedisBackend) runningKey() string      { return b.Prefix + ":running" }
+func (b *RedisBackend) deadKey() string         { return b.Prefix + ":dead" }
+
+// Enqueue stores the job and schedules it at job.RunAt
+func (b *RedisBackend) Enqueue(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, b.jobKey(job.ID), data, 0)
+		pipe.ZRem(ctx, b.runningKey(), job.ID)
+		pipe.LRem(ctx, b.deadKey(), 0, job.ID)
+		pipe.ZAdd(ctx, b.scheduledKey(), redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	return err
+}
+
+// dequeueScript moves expired leases back to scheduled, then moves the earliest
+// due job to running with a new lease
+var dequeueScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], ids[1])
+redis.call('ZADD', KEYS[2], ARGV[2], ids[1])
+return ids[1]
+`)
+
+// Dequeue claims the earliest job due at now
+func (b *RedisBackend) Dequeue(ctx context.Context, now time.Time) (*Job, error) {
+	id, err := dequeueScript.Run(ctx, b.client,
+		[]string{b.scheduledKey(), b.runningKey()},
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(now.Add(b.LeaseTimeout).UnixMilli(), 10),
+	).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job, err := b.Get(ctx, id)
+	if errors.Is(err, ErrJobNotFound) {
+		b.client.ZRem(ctx, b.runningKey(), id)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job.Status = StatusRunning
+	job.Attempts++
+	job.UpdatedAt = now
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.client.Set(ctx, b.jobKey(id), data, 0).Err(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Finish records the job's final status, the record expires after the retention
+func (b *RedisBackend) Finish(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	retention := b.Retention
+	if job.Status == StatusDead {
+		retention = b.DeadRetention
+	}
+	_, err = b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, b.jobKey(job.ID), data, retention)
+		pipe.ZRem(ctx, b.runningKey(), job.ID)
+		if job.Status == StatusDead {
+			pipe.LPush(ctx, b.deadKey(), job.ID)
+			pipe.LTrim(ctx, b.deadKey(), 0, b.MaxDead-1)
+		}
+		return nil
+	})
+	return err
+}
+
+// Get returns the job by ID
+func (b *RedisBackend) Get(ctx context.Context, id string) (*Job, error) {
+	data, err := b.client.Get(ctx, b.jobKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// DeadLetters lists dead jobs, newest first, skipping records that have expired
+func (b *RedisBackend) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit) - 1
+	}
+	ids, err := b.client.LRange(ctx, b.deadKey(), 0, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, id := range ids {
+		job, err := b.Get(ctx, id)
+		if errors.Is(err, ErrJobNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
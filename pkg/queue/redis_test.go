@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedisBackend connects to a local Redis, skipping the test when it isn't running
+func newTestRedisBackend(t *testing.T) *RedisBackend {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skip("Redis not available, skipping test")
+	}
+	b := NewRedisBackend(client, "lingecho:queue-test:"+time.Now().Format("150405.000000"))
+	t.Cleanup(func() {
+		keys, _ := client.Keys(context.Background(), b.Prefix+":*").Result()
+		if len(keys) > 0 {
+			client.Del(context.Background(), keys...)
+		}
+		client.Close()
+	})
+	return b
+}
+
+func TestRedisBackend_Lifecycle(t *testing.T) {
+	b := newTestRedisBackend(t)
+	q, now := newTestQueue(b)
+	q.MaxAttempts = 2
+	q.Register("mail", func(ctx context.Context, job *Job) error { return assert.AnError })
+	ctx := context.Background()
+
+	job, err := q.Enqueue(ctx, "mail", "payload")
+	require.NoError(t, err)
+	assert.True(t, q.runNext(ctx))
+	got, err := b.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRetrying, got.Status)
+
+	*now = now.Add(time.Hour)
+	assert.True(t, q.runNext(ctx))
+	letters, err := b.DeadLetters(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 2, letters[0].Attempts)
+}
+
+func TestRedisBackend_ExpiredLeaseIsRedelivered(t *testing.T) {
+	b := newTestRedisBackend(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, b.Enqueue(ctx, &Job{ID: "j1", Type: "mail", Status: StatusPending, MaxAttempts: 3, RunAt: now}))
+	claimed, err := b.Dequeue(ctx, now)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, 1, claimed.Attempts)
+
+	// The worker died: nothing is due until the lease runs out
+	next, err := b.Dequeue(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, next)
+
+	next, err = b.Dequeue(ctx, now.Add(b.LeaseTimeout+time.Second))
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.Equal(t, "j1", next.ID)
+	assert.Equal(t, 2, next.Attempts)
+}
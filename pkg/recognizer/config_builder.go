@@ -81,6 +81,46 @@ func (r *ConfigReader) Int(keys ...interface{}) int {
 	return defaultValue
 }
 
+// ConfigKeyHotWords 识别热词的配置键，值为 []HotWord、热词对象数组或逗号分隔的字符串，
+// 腾讯云和火山引擎LLM ASR 支持
+const ConfigKeyHotWords = "hotWords"
+
+// HotWords 读取热词配置，支持 []HotWord、[{"word": "..", "weight": 10}]、["..", ".."] 和 "a,b"
+func (r *ConfigReader) HotWords(keys ...string) []HotWord {
+	for _, key := range keys {
+		var words []HotWord
+		switch v := r.config[key].(type) {
+		case []HotWord:
+			words = v
+		case []interface{}:
+			for _, item := range v {
+				switch w := item.(type) {
+				case string:
+					words = append(words, HotWord{Word: w})
+				case map[string]interface{}:
+					word, _ := w["word"].(string)
+					weight, _ := w["weight"].(float64)
+					words = append(words, HotWord{Word: word, Weight: int(weight)})
+				}
+			}
+		case string:
+			for _, w := range strings.Split(v, ",") {
+				words = append(words, HotWord{Word: w})
+			}
+		}
+		result := make([]HotWord, 0, len(words))
+		for _, w := range words {
+			if w.Word = strings.TrimSpace(w.Word); w.Word != "" {
+				result = append(result, w)
+			}
+		}
+		if len(result) > 0 {
+			return result
+		}
+	}
+	return nil
+}
+
 // GetVendor 获取vendor枚举值（公开函数，供其他包使用）
 func GetVendor(provider string) Vendor {
 	if provider == "tencent" {
@@ -156,6 +196,7 @@ func buildQCloudConfig(config map[string]interface{}) (*QCloudASROption, error)
 	}
 
 	opt := NewQcloudASROption(appID, secretID, secretKey)
+	opt.HotWords = cfg.HotWords(ConfigKeyHotWords, "hot_words")
 	return &opt, nil
 }
 
@@ -256,6 +297,7 @@ func buildVolcengineLLMConfig(config map[string]interface{}) (*VolcengineLLMOpti
 		return nil, fmt.Errorf("火山引擎LLM ASR配置不完整：缺少token或appId")
 	}
 	opt := NewVolcengineLLMOption(token, appID)
+	opt.HotWords = cfg.HotWords(ConfigKeyHotWords, "hot_words")
 	return &opt, nil
 }
 
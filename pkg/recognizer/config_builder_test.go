@@ -0,0 +1,33 @@
+package recognizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigReader_HotWords(t *testing.T) {
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		want   []HotWord
+	}{
+		{"typed", map[string]interface{}{"hotWords": []HotWord{{Word: "灵犀", Weight: 10}}}, []HotWord{{Word: "灵犀", Weight: 10}}},
+		{"json objects", map[string]interface{}{"hotWords": []interface{}{map[string]interface{}{"word": "LingEcho", "weight": 8.0}, " 声网 "}}, []HotWord{{Word: "LingEcho", Weight: 8}, {Word: "声网"}}},
+		{"comma separated", map[string]interface{}{"hot_words": "a1, ,b2"}, []HotWord{{Word: "a1"}, {Word: "b2"}}},
+		{"missing", map[string]interface{}{}, nil},
+	}
+	for _, c := range cases {
+		got := NewConfigReader(c.config).HotWords(ConfigKeyHotWords, "hot_words")
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	opt, err := buildQCloudConfig(map[string]interface{}{"appId": "1", "secretId": "id", "secretKey": "key", "hotWords": "灵犀"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opt.HotWords) != 1 || opt.HotWords[0].Word != "灵犀" {
+		t.Errorf("qcloud hot words not applied: %v", opt.HotWords)
+	}
+}
@@ -21,7 +21,16 @@ func Success(c *gin.Context, msg string, data interface{}) {
 	})
 }
 
+// failedField marks requests answered by Fail, which keeps HTTP status 200
+const failedField = "_response_failed"
+
+// Failed reports whether the request was answered with Fail
+func Failed(c *gin.Context) bool {
+	return c.GetBool(failedField)
+}
+
 func Fail(c *gin.Context, msg string, data interface{}) {
+	c.Set(failedField, true)
 	// Standardize error response format
 	errorResponse := gin.H{
 		"code": 500,
@@ -80,6 +80,30 @@ func TestFail(t *testing.T) {
 	}
 }
 
+func TestFailed(t *testing.T) {
+	r, rr := newCtx()
+	var okFailed, failFailed bool
+	r.GET("/ok", func(c *gin.Context) {
+		Success(c, "ok", nil)
+		okFailed = Failed(c)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		Fail(c, "fail", nil)
+		failFailed = Failed(c)
+	})
+	req, _ := http.NewRequest(http.MethodGet, "/ok", nil)
+	r.ServeHTTP(rr, req)
+	req, _ = http.NewRequest(http.MethodGet, "/fail", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if okFailed {
+		t.Fatalf("Failed after Success = true, want false")
+	}
+	if !failFailed {
+		t.Fatalf("Failed after Fail = false, want true")
+	}
+}
+
 func TestResult_CustomHTTPStatus(t *testing.T) {
 	r, rr := newCtx()
 	r.GET("/result", func(c *gin.Context) {
@@ -0,0 +1,202 @@
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// 签名相关算法
+const (
+	AlgExcC14N             = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	AlgExcC14NWithComments = "http://www.w3.org/2001/10/xml-exc-c14n#WithComments"
+	AlgEnvelopedSignature  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	AlgRSASHA1             = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	AlgRSASHA256           = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgRSASHA512           = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	AlgSHA1                = "http://www.w3.org/2000/09/xmldsig#sha1"
+	AlgSHA256              = "http://www.w3.org/2001/04/xmlenc#sha256"
+	AlgSHA512              = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+// ErrNotSigned 元素没有签名
+var ErrNotSigned = errors.New("saml: element is not signed")
+
+var signatureHashes = map[string]crypto.Hash{
+	AlgRSASHA1:   crypto.SHA1,
+	AlgRSASHA256: crypto.SHA256,
+	AlgRSASHA512: crypto.SHA512,
+}
+
+var digestHashes = map[string]crypto.Hash{
+	AlgSHA1:   crypto.SHA1,
+	AlgSHA256: crypto.SHA256,
+	AlgSHA512: crypto.SHA512,
+}
+
+// VerifySignature 校验元素的 enveloped 签名。签名必须是元素的直接子元素，
+// 且只引用该元素本身；只信任传入的证书，忽略签名中携带的 KeyInfo
+func VerifySignature(el *Element, certs []*x509.Certificate) error {
+	sig := el.Child(NamespaceDSig, "Signature")
+	if sig == nil {
+		return ErrNotSigned
+	}
+	signedInfo := sig.Child(NamespaceDSig, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("saml: missing SignedInfo")
+	}
+
+	c14nMethod := signedInfo.Child(NamespaceDSig, "CanonicalizationMethod")
+	if c14nMethod == nil || !isExcC14N(c14nMethod.Attr("Algorithm")) {
+		return errors.New("saml: unsupported canonicalization method")
+	}
+	sigMethod := signedInfo.Child(NamespaceDSig, "SignatureMethod")
+	if sigMethod == nil {
+		return errors.New("saml: missing SignatureMethod")
+	}
+	sigHash, ok := signatureHashes[sigMethod.Attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("saml: unsupported signature method %s", sigMethod.Attr("Algorithm"))
+	}
+
+	refs := signedInfo.ChildrenOf(NamespaceDSig, "Reference")
+	if len(refs) != 1 {
+		return errors.New("saml: exactly one signature reference is required")
+	}
+	ref := refs[0]
+	id := el.Attr("ID")
+	if id == "" || ref.Attr("URI") != "#"+id {
+		return errors.New("saml: signature does not reference the signed element")
+	}
+
+	var inclusive []string
+	enveloped := false
+	if transforms := ref.Child(NamespaceDSig, "Transforms"); transforms != nil {
+		for _, t := range transforms.ChildrenOf(NamespaceDSig, "Transform") {
+			switch alg := t.Attr("Algorithm"); {
+			case alg == AlgEnvelopedSignature:
+				enveloped = true
+			case isExcC14N(alg):
+				inclusive = inclusivePrefixes(t)
+			default:
+				return fmt.Errorf("saml: unsupported transform %s", alg)
+			}
+		}
+	}
+	if !enveloped {
+		return errors.New("saml: enveloped-signature transform is required")
+	}
+
+	digestMethod := ref.Child(NamespaceDSig, "DigestMethod")
+	digestValue := ref.Child(NamespaceDSig, "DigestValue")
+	if digestMethod == nil || digestValue == nil {
+		return errors.New("saml: missing digest")
+	}
+	digestHash, ok := digestHashes[digestMethod.Attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("saml: unsupported digest method %s", digestMethod.Attr("Algorithm"))
+	}
+	expected, err := decodeBase64(digestValue.Text())
+	if err != nil {
+		return errors.New("saml: invalid digest value")
+	}
+	h := digestHash.New()
+	h.Write(Canonicalize(el, sig, inclusive))
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return errors.New("saml: digest mismatch")
+	}
+
+	sigValue := sig.Child(NamespaceDSig, "SignatureValue")
+	if sigValue == nil {
+		return errors.New("saml: missing SignatureValue")
+	}
+	signature, err := decodeBase64(sigValue.Text())
+	if err != nil {
+		return errors.New("saml: invalid signature value")
+	}
+	h = sigHash.New()
+	h.Write(Canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod)))
+	hashed := h.Sum(nil)
+	for _, cert := range certs {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		if rsa.VerifyPKCS1v15(pub, sigHash, hashed, signature) == nil {
+			return nil
+		}
+	}
+	return errors.New("saml: signature verification failed")
+}
+
+func isExcC14N(alg string) bool {
+	return alg == AlgExcC14N || alg == AlgExcC14NWithComments
+}
+
+// inclusivePrefixes 读取 <ec:InclusiveNamespaces PrefixList="...">
+func inclusivePrefixes(transform *Element) []string {
+	for _, child := range transform.ChildElements() {
+		if child.Tag == "InclusiveNamespaces" {
+			return strings.Fields(child.Attr("PrefixList"))
+		}
+	}
+	return nil
+}
+
+// decodeBase64 解码可能带换行的 base64
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// ParseCertificates 解析 PEM 或 base64 DER 格式的证书，可包含多个证书
+func ParseCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(strings.TrimSpace(data))
+	if !bytes.Contains(rest, []byte("-----BEGIN")) {
+		der, err := decodeBase64(string(rest))
+		if err != nil {
+			return nil, errors.New("saml: invalid certificate")
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("saml: no certificate found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,101 @@
+package saml
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+// 绑定与 NameID 格式
+const (
+	BindingHTTPRedirect     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingHTTPPost         = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	NameIDFormatUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	NameIDFormatEmail       = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+)
+
+// IdPMetadata 身份提供方元数据中 SP 需要的部分
+type IdPMetadata struct {
+	EntityID     string
+	SSOURL       string // HTTP-Redirect 绑定的 SingleSignOnService
+	Certificates []*x509.Certificate
+}
+
+// ParseIdPMetadata 解析 IdP 元数据（EntityDescriptor 或 EntitiesDescriptor）
+func ParseIdPMetadata(data []byte) (*IdPMetadata, error) {
+	root, err := ParseElement(data)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid metadata: %w", err)
+	}
+	var entities []*Element
+	switch {
+	case root.Is(NamespaceMetadata, "EntityDescriptor"):
+		entities = []*Element{root}
+	case root.Is(NamespaceMetadata, "EntitiesDescriptor"):
+		entities = root.ChildrenOf(NamespaceMetadata, "EntityDescriptor")
+	default:
+		return nil, errors.New("saml: metadata must be an EntityDescriptor")
+	}
+
+	for _, entity := range entities {
+		idp := entity.Child(NamespaceMetadata, "IDPSSODescriptor")
+		if idp == nil {
+			continue
+		}
+		meta := &IdPMetadata{EntityID: entity.Attr("entityID")}
+		for _, sso := range idp.ChildrenOf(NamespaceMetadata, "SingleSignOnService") {
+			if sso.Attr("Binding") == BindingHTTPRedirect {
+				meta.SSOURL = sso.Attr("Location")
+				break
+			}
+		}
+		for _, kd := range idp.ChildrenOf(NamespaceMetadata, "KeyDescriptor") {
+			if use := kd.Attr("use"); use != "" && use != "signing" {
+				continue
+			}
+			keyInfo := kd.Child(NamespaceDSig, "KeyInfo")
+			if keyInfo == nil {
+				continue
+			}
+			for _, data := range keyInfo.ChildrenOf(NamespaceDSig, "X509Data") {
+				for _, c := range data.ChildrenOf(NamespaceDSig, "X509Certificate") {
+					certs, err := ParseCertificates(c.Text())
+					if err != nil {
+						return nil, err
+					}
+					meta.Certificates = append(meta.Certificates, certs...)
+				}
+			}
+		}
+		if meta.EntityID == "" {
+			return nil, errors.New("saml: metadata has no entityID")
+		}
+		if meta.SSOURL == "" {
+			return nil, errors.New("saml: IdP does not support the HTTP-Redirect binding")
+		}
+		if len(meta.Certificates) == 0 {
+			return nil, errors.New("saml: metadata has no signing certificate")
+		}
+		return meta, nil
+	}
+	return nil, errors.New("saml: metadata has no IDPSSODescriptor")
+}
+
+// SPMetadata 生成 SP 元数据，供 IdP 导入
+func SPMetadata(entityID, acsURL string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<md:EntityDescriptor xmlns:md="` + NamespaceMetadata + `" entityID="`)
+	xml.EscapeText(&buf, []byte(entityID))
+	buf.WriteString(`">` + "\n")
+	buf.WriteString(`  <md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="` + NamespaceProtocol + `">` + "\n")
+	buf.WriteString(`    <md:NameIDFormat>` + NameIDFormatEmail + `</md:NameIDFormat>` + "\n")
+	buf.WriteString(`    <md:AssertionConsumerService Binding="` + BindingHTTPPost + `" Location="`)
+	xml.EscapeText(&buf, []byte(acsURL))
+	buf.WriteString(`" index="0" isDefault="true"/>` + "\n")
+	buf.WriteString(`  </md:SPSSODescriptor>` + "\n")
+	buf.WriteString(`</md:EntityDescriptor>` + "\n")
+	return buf.Bytes()
+}
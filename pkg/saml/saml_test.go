@@ -0,0 +1,244 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	// Exclusive XML Canonicalization 1.0, section 2.2
+	doc := `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2></n0:local>`
+	root, err := ParseElement([]byte(doc))
+	require.NoError(t, err)
+	elem2 := root.ChildElements()[0]
+	assert.Equal(t, `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`, string(Canonicalize(elem2, nil, nil)))
+
+	// Inherited namespaces are pushed down, attributes sorted, unused declarations dropped
+	doc = `<samlp:Response xmlns:samlp="P" xmlns:saml="A" xmlns:xs="X" xmlns="D" ID="r"><saml:Assertion Version="2.0" ID="a"><saml:Issuer>a &amp; b</saml:Issuer><Plain b="2" a="1"/></saml:Assertion></samlp:Response>`
+	root, err = ParseElement([]byte(doc))
+	require.NoError(t, err)
+	assertion := root.ChildElements()[0]
+	assert.Equal(t, `<saml:Assertion xmlns:saml="A" ID="a" Version="2.0"><saml:Issuer>a &amp; b</saml:Issuer><Plain xmlns="D" a="1" b="2"></Plain></saml:Assertion>`,
+		string(Canonicalize(assertion, nil, nil)))
+	assert.Equal(t, `<saml:Assertion xmlns:saml="A" xmlns:xs="X" ID="a" Version="2.0"><saml:Issuer>a &amp; b</saml:Issuer><Plain xmlns="D" a="1" b="2"></Plain></saml:Assertion>`,
+		string(Canonicalize(assertion, nil, []string{"xs"})))
+
+	_, err = ParseElement([]byte(`<!DOCTYPE x [<!ENTITY a "b">]><x>&a;</x>`))
+	assert.Error(t, err)
+}
+
+type testIdP struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+	pem  string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testIdP{key: key, cert: cert, pem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))}
+}
+
+const testSignature = `<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo><ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/><ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/><ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/><ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"><ec:InclusiveNamespaces xmlns:ec="http://www.w3.org/2001/10/xml-exc-c14n#" PrefixList="xs"/></ds:Transform></ds:Transforms><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/><ds:DigestValue></ds:DigestValue></ds:Reference></ds:SignedInfo><ds:SignatureValue></ds:SignatureValue></ds:Signature>`
+
+// sign fills the digest and signature of the element's ds:Signature
+func (idp *testIdP) sign(t *testing.T, el *Element) {
+	sig := el.Child(NamespaceDSig, "Signature")
+	require.NotNil(t, sig)
+	signedInfo := sig.Child(NamespaceDSig, "SignedInfo")
+	ref := signedInfo.Child(NamespaceDSig, "Reference")
+
+	h := crypto.SHA256.New()
+	h.Write(Canonicalize(el, sig, []string{"xs"}))
+	ref.Child(NamespaceDSig, "DigestValue").Children = []any{base64.StdEncoding.EncodeToString(h.Sum(nil))}
+
+	h = crypto.SHA256.New()
+	h.Write(Canonicalize(signedInfo, nil, nil))
+	value, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, h.Sum(nil))
+	require.NoError(t, err)
+	sig.Child(NamespaceDSig, "SignatureValue").Children = []any{base64.StdEncoding.EncodeToString(value)}
+}
+
+func testResponse(t *testing.T, idp *testIdP, requestID string, signAssertion bool, tamper func(string) string) string {
+	now := time.Now().UTC()
+	assertionSig := ""
+	if signAssertion {
+		assertionSig = fmt.Sprintf(testSignature, "a1")
+	}
+	doc := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="r1" InResponseTo="` + requestID + `" Version="2.0" Destination="https://sp.example.com/acs">` +
+		`<saml:Issuer>https://idp.example.com</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		`<saml:Assertion xmlns:xs="http://www.w3.org/2001/XMLSchema" ID="a1" Version="2.0" IssueInstant="` + now.Format(time.RFC3339) + `">` +
+		`<saml:Issuer>https://idp.example.com</saml:Issuer>` + assertionSig +
+		`<saml:Subject><saml:NameID Format="` + NameIDFormatEmail + `">alice@example.com</saml:NameID>` +
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"><saml:SubjectConfirmationData InResponseTo="` + requestID + `" NotOnOrAfter="` + now.Add(5*time.Minute).Format(time.RFC3339) + `" Recipient="https://sp.example.com/acs"/></saml:SubjectConfirmation></saml:Subject>` +
+		`<saml:Conditions NotBefore="` + now.Add(-time.Minute).Format(time.RFC3339) + `" NotOnOrAfter="` + now.Add(5*time.Minute).Format(time.RFC3339) + `"><saml:AudienceRestriction><saml:Audience>https://sp.example.com/metadata</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+		`<saml:AuthnStatement SessionIndex="s1"/>` +
+		`<saml:AttributeStatement><saml:Attribute Name="givenName" FriendlyName="First Name"><saml:AttributeValue>Alice</saml:AttributeValue></saml:Attribute></saml:AttributeStatement>` +
+		`</saml:Assertion></samlp:Response>`
+	root, err := ParseElement([]byte(doc))
+	require.NoError(t, err)
+	if signAssertion {
+		idp.sign(t, root.Child(NamespaceAssertion, "Assertion"))
+	}
+	out := serialize(root)
+	if tamper != nil {
+		out = tamper(out)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(out))
+}
+
+// serialize writes the element with all of its namespace declarations, as an IdP would
+func serialize(e *Element) string {
+	var buf bytes.Buffer
+	name := e.Tag
+	if e.Prefix != "" {
+		name = e.Prefix + ":" + name
+	}
+	buf.WriteString("<" + name)
+	for _, a := range e.Attrs {
+		qname := a.Name.Local
+		if a.Name.Space != "" {
+			qname = a.Name.Space + ":" + qname
+		}
+		buf.WriteString(" " + qname + `="`)
+		escapeAttr(&buf, a.Value)
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+	for _, c := range e.Children {
+		switch v := c.(type) {
+		case string:
+			escapeText(&buf, v)
+		case *Element:
+			buf.WriteString(serialize(v))
+		}
+	}
+	buf.WriteString("</" + name + ">")
+	return buf.String()
+}
+
+func testSP(idp *testIdP) *ServiceProvider {
+	return &ServiceProvider{
+		EntityID: "https://sp.example.com/metadata",
+		ACSURL:   "https://sp.example.com/acs",
+		IdP: IdPMetadata{
+			EntityID:     "https://idp.example.com",
+			SSOURL:       "https://idp.example.com/sso?tenant=1",
+			Certificates: []*x509.Certificate{idp.cert},
+		},
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	idp := newTestIdP(t)
+	sp := testSP(idp)
+
+	a, err := sp.ParseResponse(testResponse(t, idp, "_req1", true, nil), "_req1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", a.NameID)
+	assert.Equal(t, "s1", a.SessionIndex)
+	assert.Equal(t, "Alice", a.Attribute("first name"))
+	assert.Equal(t, "Alice", a.Attribute("givenName"))
+	assert.False(t, a.NotOnOrAfter.IsZero())
+
+	_, err = sp.ParseResponse(testResponse(t, idp, "_req1", true, nil), "_other")
+	assert.Error(t, err, "request id mismatch")
+
+	_, err = sp.ParseResponse(testResponse(t, idp, "_req1", false, nil), "_req1")
+	assert.Error(t, err, "unsigned assertion")
+
+	_, err = sp.ParseResponse(testResponse(t, idp, "_req1", true, func(s string) string {
+		return strings.Replace(s, "alice@example.com", "mallory@example.com", 1)
+	}), "_req1")
+	assert.ErrorContains(t, err, "digest mismatch")
+
+	other := newTestIdP(t)
+	_, err = testSP(other).ParseResponse(testResponse(t, idp, "_req1", true, nil), "_req1")
+	assert.ErrorContains(t, err, "verification failed")
+
+	sp.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err = sp.ParseResponse(testResponse(t, idp, "_req1", true, nil), "_req1")
+	assert.Error(t, err, "expired")
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	sp := testSP(newTestIdP(t))
+	raw, err := sp.AuthnRequestURL("_abc", "state1")
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "1", u.Query().Get("tenant"))
+	assert.Equal(t, "state1", u.Query().Get("RelayState"))
+
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	xmlData, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.NoError(t, err)
+	req, err := ParseElement(xmlData)
+	require.NoError(t, err)
+	assert.True(t, req.Is(NamespaceProtocol, "AuthnRequest"))
+	assert.Equal(t, "_abc", req.Attr("ID"))
+	assert.Equal(t, "https://sp.example.com/acs", req.Attr("AssertionConsumerServiceURL"))
+	assert.Equal(t, "https://sp.example.com/metadata", req.Child(NamespaceAssertion, "Issuer").Text())
+}
+
+func TestParseIdPMetadata(t *testing.T) {
+	idp := newTestIdP(t)
+	certB64 := base64.StdEncoding.EncodeToString(idp.cert.Raw)
+	doc := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>invalid</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>
+` + certB64 + `
+    </ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/redirect"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+	meta, err := ParseIdPMetadata([]byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com", meta.EntityID)
+	assert.Equal(t, "https://idp.example.com/redirect", meta.SSOURL)
+	require.Len(t, meta.Certificates, 1)
+	assert.True(t, meta.Certificates[0].Equal(idp.cert))
+
+	certs, err := ParseCertificates(idp.pem)
+	require.NoError(t, err)
+	assert.Len(t, certs, 1)
+
+	sp, err := ParseElement(SPMetadata("https://sp.example.com/metadata?a=1&b=2", "https://sp.example.com/acs"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://sp.example.com/metadata?a=1&b=2", sp.Attr("entityID"))
+}
@@ -0,0 +1,290 @@
+// Package saml SAML 2.0 Web SSO 的 SP 端：发起 HTTP-Redirect 认证请求，
+// 校验 HTTP-POST 返回的签名响应并提取断言。只依赖标准库，
+// 签名校验只支持 RSA 与 Exclusive C14N，不支持加密断言。
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StatusSuccess 成功状态码
+const StatusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+
+// DefaultClockSkew 与 IdP 的默认时钟偏差容忍
+const DefaultClockSkew = 3 * time.Minute
+
+// ServiceProvider SP 配置
+type ServiceProvider struct {
+	EntityID  string
+	ACSURL    string
+	IdP       IdPMetadata
+	ClockSkew time.Duration
+	Now       func() time.Time // 为空时使用 time.Now
+}
+
+// Assertion 校验通过的断言
+type Assertion struct {
+	ID           string
+	Issuer       string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	NotOnOrAfter time.Time // 断言失效时间，用于防重放缓存
+	Attributes   []Attribute
+}
+
+// Attribute 断言属性
+type Attribute struct {
+	Name         string
+	FriendlyName string
+	Values       []string
+}
+
+// Attribute 按 Name 或 FriendlyName（不区分大小写）获取第一个属性值
+func (a *Assertion) Attribute(name string) string {
+	for _, attr := range a.Attributes {
+		if (strings.EqualFold(attr.Name, name) || strings.EqualFold(attr.FriendlyName, name)) && len(attr.Values) > 0 {
+			return attr.Values[0]
+		}
+	}
+	return ""
+}
+
+// NewRequestID 生成认证请求 ID（必须以字母或下划线开头）
+func NewRequestID() string {
+	buf := make([]byte, 20)
+	_, _ = rand.Read(buf)
+	return "_" + hex.EncodeToString(buf)
+}
+
+func (sp *ServiceProvider) now() time.Time {
+	if sp.Now != nil {
+		return sp.Now()
+	}
+	return time.Now()
+}
+
+func (sp *ServiceProvider) skew() time.Duration {
+	if sp.ClockSkew > 0 {
+		return sp.ClockSkew
+	}
+	return DefaultClockSkew
+}
+
+// AuthnRequestURL 生成 HTTP-Redirect 绑定的认证请求地址
+func (sp *ServiceProvider) AuthnRequestURL(requestID, relayState string) (string, error) {
+	if sp.IdP.SSOURL == "" {
+		return "", errors.New("saml: IdP SSO URL is not configured")
+	}
+	var req bytes.Buffer
+	fmt.Fprintf(&req, `<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s">`,
+		NamespaceProtocol, NamespaceAssertion, escape(requestID), sp.now().UTC().Format(time.RFC3339),
+		escape(sp.IdP.SSOURL), escape(sp.ACSURL), BindingHTTPPost)
+	fmt.Fprintf(&req, `<saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy Format="%s" AllowCreate="true"/></samlp:AuthnRequest>`,
+		escape(sp.EntityID), NameIDFormatUnspecified)
+
+	var deflated bytes.Buffer
+	w, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	if _, err := w.Write(req.Bytes()); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(sp.IdP.SSOURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		q.Set("RelayState", relayState)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// ParseResponse 校验 HTTP-POST 绑定返回的 SAMLResponse，requestID 为发起认证时的请求 ID。
+// 响应或断言至少一个必须由 IdP 证书签名，只从已校验的元素中读取数据
+func (sp *ServiceProvider) ParseResponse(encoded, requestID string) (*Assertion, error) {
+	raw, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, errors.New("saml: invalid SAMLResponse encoding")
+	}
+	resp, err := ParseElement(raw)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid SAMLResponse: %w", err)
+	}
+	if !resp.Is(NamespaceProtocol, "Response") {
+		return nil, errors.New("saml: not a SAML Response")
+	}
+	if dest := resp.Attr("Destination"); dest != "" && dest != sp.ACSURL {
+		return nil, fmt.Errorf("saml: unexpected destination %s", dest)
+	}
+	if resp.Attr("InResponseTo") != requestID {
+		return nil, errors.New("saml: response does not match the authentication request")
+	}
+	if issuer := resp.Child(NamespaceAssertion, "Issuer"); issuer != nil && issuer.Text() != sp.IdP.EntityID {
+		return nil, fmt.Errorf("saml: unexpected issuer %s", issuer.Text())
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	responseSigned := false
+	switch err := VerifySignature(resp, sp.IdP.Certificates); {
+	case err == nil:
+		responseSigned = true
+	case !errors.Is(err, ErrNotSigned):
+		return nil, err
+	}
+
+	if resp.Child(NamespaceAssertion, "EncryptedAssertion") != nil {
+		return nil, errors.New("saml: encrypted assertions are not supported")
+	}
+	assertions := resp.ChildrenOf(NamespaceAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("saml: response must contain exactly one assertion")
+	}
+	el := assertions[0]
+	if err := VerifySignature(el, sp.IdP.Certificates); err != nil && (!responseSigned || !errors.Is(err, ErrNotSigned)) {
+		return nil, err
+	}
+	return sp.readAssertion(el, requestID)
+}
+
+func checkStatus(resp *Element) error {
+	status := resp.Child(NamespaceProtocol, "Status")
+	if status == nil {
+		return errors.New("saml: missing status")
+	}
+	code := status.Child(NamespaceProtocol, "StatusCode")
+	if code == nil {
+		return errors.New("saml: missing status code")
+	}
+	if code.Attr("Value") == StatusSuccess {
+		return nil
+	}
+	detail := code.Attr("Value")
+	if sub := code.Child(NamespaceProtocol, "StatusCode"); sub != nil {
+		detail += " / " + sub.Attr("Value")
+	}
+	if msg := status.Child(NamespaceProtocol, "StatusMessage"); msg != nil {
+		detail += ": " + msg.Text()
+	}
+	return fmt.Errorf("saml: IdP returned %s", detail)
+}
+
+func (sp *ServiceProvider) readAssertion(el *Element, requestID string) (*Assertion, error) {
+	now := sp.now()
+	skew := sp.skew()
+	a := &Assertion{ID: el.Attr("ID")}
+	if issuer := el.Child(NamespaceAssertion, "Issuer"); issuer != nil {
+		a.Issuer = issuer.Text()
+	}
+	if a.Issuer != sp.IdP.EntityID {
+		return nil, fmt.Errorf("saml: unexpected assertion issuer %s", a.Issuer)
+	}
+
+	subject := el.Child(NamespaceAssertion, "Subject")
+	if subject == nil {
+		return nil, errors.New("saml: assertion has no subject")
+	}
+	if nameID := subject.Child(NamespaceAssertion, "NameID"); nameID != nil {
+		a.NameID = nameID.Text()
+		a.NameIDFormat = nameID.Attr("Format")
+	}
+	confirmed := false
+	for _, sc := range subject.ChildrenOf(NamespaceAssertion, "SubjectConfirmation") {
+		if sc.Attr("Method") != "urn:oasis:names:tc:SAML:2.0:cm:bearer" {
+			continue
+		}
+		data := sc.Child(NamespaceAssertion, "SubjectConfirmationData")
+		if data == nil || data.Attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		if irt := data.Attr("InResponseTo"); irt != "" && irt != requestID {
+			continue
+		}
+		notOnOrAfter, err := parseTime(data.Attr("NotOnOrAfter"))
+		if err != nil || !now.Before(notOnOrAfter.Add(skew)) {
+			continue
+		}
+		a.NotOnOrAfter = notOnOrAfter
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return nil, errors.New("saml: no valid bearer subject confirmation")
+	}
+
+	conditions := el.Child(NamespaceAssertion, "Conditions")
+	if conditions == nil {
+		return nil, errors.New("saml: assertion has no conditions")
+	}
+	if v := conditions.Attr("NotBefore"); v != "" {
+		t, err := parseTime(v)
+		if err != nil || now.Add(skew).Before(t) {
+			return nil, errors.New("saml: assertion is not yet valid")
+		}
+	}
+	if v := conditions.Attr("NotOnOrAfter"); v != "" {
+		t, err := parseTime(v)
+		if err != nil || !now.Before(t.Add(skew)) {
+			return nil, errors.New("saml: assertion has expired")
+		}
+		if t.Before(a.NotOnOrAfter) {
+			a.NotOnOrAfter = t
+		}
+	}
+	restrictions := conditions.ChildrenOf(NamespaceAssertion, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return nil, errors.New("saml: assertion has no audience restriction")
+	}
+	for _, r := range restrictions {
+		ok := false
+		for _, aud := range r.ChildrenOf(NamespaceAssertion, "Audience") {
+			if aud.Text() == sp.EntityID {
+				ok = true
+			}
+		}
+		if !ok {
+			return nil, errors.New("saml: assertion is not intended for this service provider")
+		}
+	}
+
+	if stmt := el.Child(NamespaceAssertion, "AuthnStatement"); stmt != nil {
+		a.SessionIndex = stmt.Attr("SessionIndex")
+	}
+	for _, stmt := range el.ChildrenOf(NamespaceAssertion, "AttributeStatement") {
+		for _, attr := range stmt.ChildrenOf(NamespaceAssertion, "Attribute") {
+			item := Attribute{Name: attr.Attr("Name"), FriendlyName: attr.Attr("FriendlyName")}
+			for _, v := range attr.ChildrenOf(NamespaceAssertion, "AttributeValue") {
+				item.Values = append(item.Values, v.Text())
+			}
+			a.Attributes = append(a.Attributes, item)
+		}
+	}
+	return a, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339, s)
+}
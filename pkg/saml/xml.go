@@ -0,0 +1,296 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// 命名空间
+const (
+	NamespaceXML       = "http://www.w3.org/XML/1998/namespace"
+	NamespaceXMLNS     = "http://www.w3.org/2000/xmlns/"
+	NamespaceDSig      = "http://www.w3.org/2000/09/xmldsig#"
+	NamespaceAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	NamespaceProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	NamespaceMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+)
+
+// Element 保留原始前缀的 XML 元素，用于规范化和签名校验。
+// encoding/xml 的 Unmarshal 会丢失前缀和命名空间声明，无法还原被签名的字节
+type Element struct {
+	Prefix   string
+	Tag      string
+	Attrs    []xml.Attr // Name.Space 为前缀，命名空间声明为 xmlns / xmlns:p
+	Children []any      // *Element 或 string
+	Parent   *Element
+}
+
+// ParseElement 解析 XML 文档，拒绝 DTD 以避免实体扩展
+func ParseElement(data []byte) (*Element, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var root, cur *Element
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			el := &Element{Prefix: t.Name.Space, Tag: t.Name.Local, Parent: cur}
+			for _, a := range t.Attr {
+				el.Attrs = append(el.Attrs, xml.Attr{Name: a.Name, Value: a.Value})
+			}
+			if cur == nil {
+				if root != nil {
+					return nil, errors.New("multiple root elements")
+				}
+				root = el
+			} else {
+				cur.Children = append(cur.Children, el)
+			}
+			cur = el
+		case xml.EndElement:
+			if cur == nil || t.Name.Space != cur.Prefix || t.Name.Local != cur.Tag {
+				return nil, errors.New("mismatched end element")
+			}
+			cur = cur.Parent
+		case xml.CharData:
+			if cur != nil {
+				cur.Children = append(cur.Children, string(t))
+			}
+		case xml.Directive:
+			return nil, errors.New("DTD is not allowed")
+		}
+	}
+	if root == nil || cur != nil {
+		return nil, errors.New("incomplete XML document")
+	}
+	return root, nil
+}
+
+// LookupNamespace 解析前缀对应的命名空间
+func (e *Element) LookupNamespace(prefix string) string {
+	if prefix == "xml" {
+		return NamespaceXML
+	}
+	for el := e; el != nil; el = el.Parent {
+		for _, a := range el.Attrs {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") ||
+				(prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Namespace 元素的命名空间
+func (e *Element) Namespace() string {
+	return e.LookupNamespace(e.Prefix)
+}
+
+// Is 判断元素的命名空间和本地名
+func (e *Element) Is(namespace, tag string) bool {
+	return e.Tag == tag && e.Namespace() == namespace
+}
+
+// Attr 获取无前缀属性
+func (e *Element) Attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// ChildElements 子元素
+func (e *Element) ChildElements() []*Element {
+	var out []*Element
+	for _, c := range e.Children {
+		if el, ok := c.(*Element); ok {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// Child 第一个匹配的子元素
+func (e *Element) Child(namespace, tag string) *Element {
+	for _, el := range e.ChildElements() {
+		if el.Is(namespace, tag) {
+			return el
+		}
+	}
+	return nil
+}
+
+// ChildrenOf 所有匹配的子元素
+func (e *Element) ChildrenOf(namespace, tag string) []*Element {
+	var out []*Element
+	for _, el := range e.ChildElements() {
+		if el.Is(namespace, tag) {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// Text 元素的文本内容（不含子元素）
+func (e *Element) Text() string {
+	var sb strings.Builder
+	for _, c := range e.Children {
+		if s, ok := c.(string); ok {
+			sb.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Canonicalize 按 Exclusive XML Canonicalization 1.0（不含注释）输出元素，
+// exclude 为需要省略的子元素（enveloped-signature 变换），inclusive 为 InclusiveNamespaces 前缀列表
+func Canonicalize(e *Element, exclude *Element, inclusive []string) []byte {
+	var buf bytes.Buffer
+	c14n(&buf, e, exclude, inclusive, map[string]string{"": ""})
+	return buf.Bytes()
+}
+
+func c14n(buf *bytes.Buffer, e *Element, exclude *Element, inclusive []string, rendered map[string]string) {
+	// 可见使用的前缀：元素前缀、带前缀属性的前缀，以及 InclusiveNamespaces 中的前缀
+	used := map[string]bool{e.Prefix: true}
+	for _, a := range e.Attrs {
+		if a.Name.Space != "" && a.Name.Space != "xmlns" && a.Name.Space != "xml" {
+			used[a.Name.Space] = true
+		}
+	}
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		if p == "" || e.declaresInScope(p) {
+			used[p] = true
+		}
+	}
+
+	var prefixes []string
+	scope := make(map[string]string, len(rendered))
+	for k, v := range rendered {
+		scope[k] = v
+	}
+	for p := range used {
+		uri := e.LookupNamespace(p)
+		if prev, ok := rendered[p]; ok && prev == uri {
+			continue
+		}
+		if p != "" && uri == "" {
+			continue
+		}
+		prefixes = append(prefixes, p)
+		scope[p] = uri
+	}
+	sort.Strings(prefixes)
+
+	type attr struct{ ns, local, qname, value string }
+	var attrs []attr
+	for _, a := range e.Attrs {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		qname := a.Name.Local
+		ns := ""
+		if a.Name.Space != "" {
+			qname = a.Name.Space + ":" + a.Name.Local
+			ns = e.LookupNamespace(a.Name.Space)
+		}
+		attrs = append(attrs, attr{ns, a.Name.Local, qname, a.Value})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].ns != attrs[j].ns {
+			return attrs[i].ns < attrs[j].ns
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := e.Tag
+	if e.Prefix != "" {
+		name = e.Prefix + ":" + e.Tag
+	}
+	buf.WriteByte('<')
+	buf.WriteString(name)
+	for _, p := range prefixes {
+		if p == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + p + `="`)
+		}
+		escapeAttr(buf, scope[p])
+		buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + a.qname + `="`)
+		escapeAttr(buf, a.value)
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+	for _, c := range e.Children {
+		switch v := c.(type) {
+		case string:
+			escapeText(buf, v)
+		case *Element:
+			if v != exclude {
+				c14n(buf, v, exclude, inclusive, scope)
+			}
+		}
+	}
+	buf.WriteString("</" + name + ">")
+}
+
+// declaresInScope 前缀是否在作用域内声明
+func (e *Element) declaresInScope(prefix string) bool {
+	return e.LookupNamespace(prefix) != ""
+}
+
+func escapeText(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\t':
+			buf.WriteString("&#x9;")
+		case '\n':
+			buf.WriteString("&#xA;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
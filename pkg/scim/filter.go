@@ -0,0 +1,172 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 过滤运算符
+const (
+	OpEq = "eq"
+	OpNe = "ne"
+	OpCo = "co"
+	OpSw = "sw"
+	OpEw = "ew"
+	OpPr = "pr"
+)
+
+// Filter 属性比较表达式，如 userName eq "bjensen"
+type Filter struct {
+	Attr  string
+	Op    string
+	Value any // string、bool、float64 或 nil
+}
+
+// ParseFilter 解析过滤条件，支持以 and 连接的比较表达式（IdP 同步时使用的子集）
+func ParseFilter(s string) ([]Filter, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var filters []Filter
+	for s != "" {
+		f, rest, err := parseComparison(s)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		word, after, _ := strings.Cut(rest, " ")
+		if !strings.EqualFold(word, "and") {
+			return nil, NewError(http.StatusBadRequest, ErrTypeInvalidFilter, "unsupported filter expression near %q", rest)
+		}
+		s = strings.TrimSpace(after)
+	}
+	return filters, nil
+}
+
+func parseComparison(s string) (Filter, string, error) {
+	attr, rest, ok := strings.Cut(s, " ")
+	if !ok || attr == "" {
+		return Filter{}, "", NewError(http.StatusBadRequest, ErrTypeInvalidFilter, "invalid filter %q", s)
+	}
+	rest = strings.TrimSpace(rest)
+	op, rest, _ := strings.Cut(rest, " ")
+	op = strings.ToLower(op)
+	f := Filter{Attr: attr, Op: op}
+	switch op {
+	case OpPr:
+		return f, rest, nil
+	case OpEq, OpNe, OpCo, OpSw, OpEw:
+	default:
+		return Filter{}, "", NewError(http.StatusBadRequest, ErrTypeInvalidFilter, "unsupported filter operator %q", op)
+	}
+
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, `"`) {
+		end := 1
+		for end < len(rest) && (rest[end] != '"' || rest[end-1] == '\\') {
+			end++
+		}
+		if end >= len(rest) {
+			return Filter{}, "", NewError(http.StatusBadRequest, ErrTypeInvalidFilter, "unterminated string in filter")
+		}
+		value, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return Filter{}, "", NewError(http.StatusBadRequest, ErrTypeInvalidFilter, "invalid string in filter")
+		}
+		f.Value = value
+		return f, rest[end+1:], nil
+	}
+	token, remaining, _ := strings.Cut(rest, " ")
+	switch strings.ToLower(token) {
+	case "true":
+		f.Value = true
+	case "false":
+		f.Value = false
+	case "null":
+		f.Value = nil
+	default:
+		n, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return Filter{}, "", NewError(http.StatusBadRequest, ErrTypeInvalidFilter, "invalid filter value %q", token)
+		}
+		f.Value = n
+	}
+	return f, remaining, nil
+}
+
+// Match 判断资源（或多值属性中的元素）是否满足条件
+func (f Filter) Match(resource map[string]any) bool {
+	actual, ok := GetPath(resource, f.Attr)
+	if f.Op == OpPr {
+		return ok && actual != nil && actual != ""
+	}
+	if list, isList := actual.([]any); isList {
+		for _, item := range list {
+			if f.compare(item) {
+				return true
+			}
+		}
+		return false
+	}
+	if !ok {
+		return f.Op == OpNe
+	}
+	return f.compare(actual)
+}
+
+func (f Filter) compare(actual any) bool {
+	a, b := normalize(actual), normalize(f.Value)
+	if f.Op == OpEq {
+		return a == b
+	}
+	if f.Op == OpNe {
+		return a != b
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if !aok || !bok {
+		return false
+	}
+	switch f.Op {
+	case OpCo:
+		return strings.Contains(as, bs)
+	case OpSw:
+		return strings.HasPrefix(as, bs)
+	case OpEw:
+		return strings.HasSuffix(as, bs)
+	}
+	return false
+}
+
+// normalize 字符串比较不区分大小写（caseExact=false 的属性），数字统一为 float64
+func normalize(v any) any {
+	switch val := v.(type) {
+	case string:
+		return strings.ToLower(val)
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case uint:
+		return float64(val)
+	}
+	return v
+}
+
+// String 还原为过滤表达式
+func (f Filter) String() string {
+	if f.Op == OpPr {
+		return f.Attr + " pr"
+	}
+	if s, ok := f.Value.(string); ok {
+		return fmt.Sprintf("%s %s %q", f.Attr, f.Op, s)
+	}
+	return fmt.Sprintf("%s %s %v", f.Attr, f.Op, f.Value)
+}
@@ -0,0 +1,338 @@
+package scim
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// path 属性路径：[schema:]attr[filter][.sub]
+type path struct {
+	Schema string
+	Attr   string
+	Filter *Filter
+	Sub    string
+}
+
+func parsePath(p string) (path, error) {
+	var out path
+	p = strings.TrimSpace(p)
+	if strings.HasPrefix(strings.ToLower(p), "urn:") {
+		boundary := strings.IndexByte(p, '[')
+		if boundary < 0 {
+			boundary = len(p)
+		}
+		idx := strings.LastIndexByte(p[:boundary], ':')
+		out.Schema, p = p[:idx], p[idx+1:]
+	}
+	if i := strings.IndexAny(p, "[."); i < 0 {
+		out.Attr = p
+	} else if p[i] == '.' {
+		out.Attr, out.Sub = p[:i], p[i+1:]
+	} else {
+		end := strings.IndexByte(p, ']')
+		if end < i {
+			return out, NewError(http.StatusBadRequest, ErrTypeInvalidPath, "invalid path %q", p)
+		}
+		filters, err := ParseFilter(p[i+1 : end])
+		if err != nil || len(filters) != 1 {
+			return out, NewError(http.StatusBadRequest, ErrTypeInvalidPath, "invalid value filter in path %q", p)
+		}
+		out.Attr, out.Filter = p[:i], &filters[0]
+		if rest := p[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return out, NewError(http.StatusBadRequest, ErrTypeInvalidPath, "invalid path %q", p)
+			}
+			out.Sub = rest[1:]
+		}
+	}
+	if out.Attr == "" {
+		return out, NewError(http.StatusBadRequest, ErrTypeInvalidPath, "invalid path %q", p)
+	}
+	return out, nil
+}
+
+// lookupKey 属性名不区分大小写
+func lookupKey(m map[string]any, key string) (string, any, bool) {
+	if v, ok := m[key]; ok {
+		return key, v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return k, v, true
+		}
+	}
+	return key, nil, false
+}
+
+func container(resource map[string]any, schema string, create bool) map[string]any {
+	if schema == "" {
+		return resource
+	}
+	key, v, ok := lookupKey(resource, schema)
+	if m, isMap := v.(map[string]any); ok && isMap {
+		return m
+	}
+	if !create {
+		return nil
+	}
+	m := map[string]any{}
+	resource[key] = m
+	return m
+}
+
+// GetPath 读取属性值。带过滤条件时返回第一个匹配元素；多值属性的子属性返回所有元素的子属性
+func GetPath(resource map[string]any, p string) (any, bool) {
+	parsed, err := parsePath(p)
+	if err != nil {
+		return nil, false
+	}
+	c := container(resource, parsed.Schema, false)
+	if c == nil {
+		return nil, false
+	}
+	_, v, ok := lookupKey(c, parsed.Attr)
+	if !ok {
+		return nil, false
+	}
+	if parsed.Filter != nil {
+		list, _ := v.([]any)
+		for _, item := range list {
+			if m, isMap := item.(map[string]any); isMap && parsed.Filter.Match(m) {
+				if parsed.Sub == "" {
+					return m, true
+				}
+				_, sv, found := lookupKey(m, parsed.Sub)
+				return sv, found
+			}
+		}
+		return nil, false
+	}
+	if parsed.Sub == "" {
+		return v, true
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		_, sv, found := lookupKey(val, parsed.Sub)
+		return sv, found
+	case []any:
+		var values []any
+		for _, item := range val {
+			if m, isMap := item.(map[string]any); isMap {
+				if _, sv, found := lookupKey(m, parsed.Sub); found {
+					values = append(values, sv)
+				}
+			}
+		}
+		return values, len(values) > 0
+	}
+	return nil, false
+}
+
+// ApplyPatch 按 RFC 7644 3.5.2 对资源执行 PATCH 操作
+func ApplyPatch(resource map[string]any, ops []PatchOp) error {
+	for _, op := range ops {
+		name := strings.ToLower(op.Op)
+		switch name {
+		case "add", "replace", "remove":
+		default:
+			return NewError(http.StatusBadRequest, ErrTypeInvalidSyntax, "unsupported patch operation %q", op.Op)
+		}
+		if op.Path != "" {
+			if err := applyOp(resource, name, op.Path, op.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "remove" {
+			return NewError(http.StatusBadRequest, ErrTypeNoTarget, "remove requires a path")
+		}
+		values, ok := op.Value.(map[string]any)
+		if !ok {
+			return NewError(http.StatusBadRequest, ErrTypeInvalidValue, "patch value without path must be an object")
+		}
+		for k, v := range values {
+			if err := applyOp(resource, name, k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func applyOp(resource map[string]any, op, rawPath string, value any) error {
+	// 整个扩展模式对象，如 {"urn:...:enterprise:2.0:User": {"department": "x"}}
+	if values, ok := value.(map[string]any); ok && op != "remove" && isSchemaKey(resource, rawPath) {
+		mergeInto(container(resource, rawPath, true), values)
+		return nil
+	}
+	p, err := parsePath(rawPath)
+	if err != nil {
+		return err
+	}
+	c := container(resource, p.Schema, op != "remove")
+	if c == nil {
+		return nil
+	}
+	key, cur, exists := lookupKey(c, p.Attr)
+
+	switch {
+	case p.Filter != nil:
+		list, _ := cur.([]any)
+		matched := false
+		kept := list[:0:0]
+		for _, item := range list {
+			m, ok := item.(map[string]any)
+			if !ok || !p.Filter.Match(m) {
+				kept = append(kept, item)
+				continue
+			}
+			matched = true
+			switch {
+			case op == "remove" && p.Sub == "":
+				continue
+			case op == "remove":
+				subKey, _, _ := lookupKey(m, p.Sub)
+				delete(m, subKey)
+			case p.Sub != "":
+				subKey, _, _ := lookupKey(m, p.Sub)
+				m[subKey] = value
+			default:
+				mergeInto(m, value)
+			}
+			kept = append(kept, m)
+		}
+		if !matched {
+			if op == "remove" {
+				return nil
+			}
+			elem := map[string]any{}
+			if p.Filter.Op == OpEq {
+				elem[p.Filter.Attr] = p.Filter.Value
+			}
+			if p.Sub != "" {
+				elem[p.Sub] = value
+			} else if !mergeInto(elem, value) {
+				return NewError(http.StatusBadRequest, ErrTypeNoTarget, "no value matches %q", rawPath)
+			}
+			kept = append(kept, elem)
+		}
+		c[key] = kept
+	case p.Sub != "":
+		child, ok := cur.(map[string]any)
+		if !ok {
+			if _, isList := cur.([]any); isList {
+				return NewError(http.StatusBadRequest, ErrTypeInvalidPath, "path %q targets a multi-valued attribute", rawPath)
+			}
+			if op == "remove" {
+				return nil
+			}
+			child = map[string]any{}
+			c[key] = child
+		}
+		subKey, _, _ := lookupKey(child, p.Sub)
+		if op == "remove" {
+			delete(child, subKey)
+		} else {
+			child[subKey] = value
+		}
+	case op == "remove":
+		list, isList := cur.([]any)
+		if values, ok := value.([]any); ok && isList {
+			c[key] = removeValues(list, values)
+		} else {
+			delete(c, key)
+		}
+	default:
+		list, isList := cur.([]any)
+		switch {
+		case isList && op == "add":
+			c[key] = appendValues(list, value)
+		case isList && op == "replace":
+			if values, ok := value.([]any); ok {
+				c[key] = values
+			} else {
+				c[key] = []any{value}
+			}
+		case exists && mergeInto(asMap(cur), value):
+		default:
+			c[key] = value
+		}
+	}
+	return nil
+}
+
+// isSchemaKey 路径是否为扩展模式本身
+func isSchemaKey(resource map[string]any, p string) bool {
+	if strings.EqualFold(p, SchemaEnterpriseUser) {
+		return true
+	}
+	if !strings.HasPrefix(strings.ToLower(p), "urn:") {
+		return false
+	}
+	_, v, ok := lookupKey(resource, p)
+	_, isMap := v.(map[string]any)
+	return ok && isMap
+}
+
+func asMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+// mergeInto 将对象值的子属性写入 dst，value 不是对象时返回 false
+func mergeInto(dst map[string]any, value any) bool {
+	values, ok := value.(map[string]any)
+	if !ok || dst == nil {
+		return false
+	}
+	for k, v := range values {
+		key, _, _ := lookupKey(dst, k)
+		dst[key] = v
+	}
+	return true
+}
+
+// appendValues 添加多值属性，按 value 子属性去重
+func appendValues(list []any, value any) []any {
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+	for _, v := range values {
+		if !containsValue(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func removeValues(list []any, values []any) []any {
+	kept := list[:0:0]
+	for _, item := range list {
+		if !containsValue(values, item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func containsValue(list []any, v any) bool {
+	target := elementValue(v)
+	for _, item := range list {
+		if reflect.DeepEqual(normalize(elementValue(item)), normalize(target)) {
+			return true
+		}
+	}
+	return false
+}
+
+// elementValue 多值属性元素的 value 子属性，如 members[].value
+func elementValue(v any) any {
+	if m, ok := v.(map[string]any); ok {
+		if _, val, found := lookupKey(m, "value"); found {
+			return val
+		}
+	}
+	return v
+}
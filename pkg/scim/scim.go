@@ -0,0 +1,196 @@
+// Package scim SCIM 2.0（RFC 7643/7644）协议的通用部分：资源模式、错误与列表响应、
+// 过滤表达式和 PATCH 操作。资源以 map[string]any 表示，与具体的用户/组织模型解耦。
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 模式 URN
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaEnterpriseUser        = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaBulkRequest           = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	SchemaBulkResponse          = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProvider       = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	ContentType                 = "application/scim+json"
+	DefaultPageSize             = 100
+	MaxPageSize                 = 500
+	MaxBulkOperations           = 1000
+	MaxBulkPayloadSize    int64 = 1 << 20
+)
+
+// 错误类型（scimType）
+const (
+	ErrTypeInvalidFilter = "invalidFilter"
+	ErrTypeInvalidPath   = "invalidPath"
+	ErrTypeInvalidSyntax = "invalidSyntax"
+	ErrTypeInvalidValue  = "invalidValue"
+	ErrTypeNoTarget      = "noTarget"
+	ErrTypeUniqueness    = "uniqueness"
+	ErrTypeMutability    = "mutability"
+	ErrTypeTooMany       = "tooMany"
+)
+
+// Error SCIM 错误响应
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+	code     int
+}
+
+// NewError 创建 SCIM 错误
+func NewError(status int, scimType, format string, args ...any) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   fmt.Sprint(status),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+		code:     status,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.ScimType != "" {
+		return e.ScimType + ": " + e.Detail
+	}
+	return e.Detail
+}
+
+// StatusCode HTTP 状态码
+func (e *Error) StatusCode() int {
+	if e.code == 0 {
+		return http.StatusBadRequest
+	}
+	return e.code
+}
+
+// ErrNotFound 资源不存在
+func ErrNotFound(resourceType, id string) *Error {
+	return NewError(http.StatusNotFound, "", "%s %s not found", resourceType, id)
+}
+
+// ListResponse 列表响应
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// NewListResponse 创建列表响应
+func NewListResponse(resources []any, total int64, startIndex int) *ListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// Pagination 将 startIndex（从 1 开始）与 count 转换为偏移量和数量，count 为 0 时只返回总数
+func Pagination(startIndex, count int) (offset, limit int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > MaxPageSize {
+		count = MaxPageSize
+	}
+	return startIndex - 1, count
+}
+
+// Meta 资源元数据
+func Meta(resourceType, location string, created, lastModified string) map[string]any {
+	return map[string]any{
+		"resourceType": resourceType,
+		"location":     location,
+		"created":      created,
+		"lastModified": lastModified,
+	}
+}
+
+// PatchRequest PATCH 请求体
+type PatchRequest struct {
+	Schemas    []string  `json:"schemas"`
+	Operations []PatchOp `json:"Operations"`
+}
+
+// PatchOp 单个 PATCH 操作
+type PatchOp struct {
+	Op    string `json:"op"` // add, replace, remove（不区分大小写）
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// BulkRequest 批量请求
+type BulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+// BulkOperation 批量请求中的单个操作
+type BulkOperation struct {
+	Method  string         `json:"method"`
+	BulkID  string         `json:"bulkId,omitempty"`
+	Version string         `json:"version,omitempty"`
+	Path    string         `json:"path"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// BulkOperationResult 批量响应中的单个结果
+type BulkOperationResult struct {
+	Method   string `json:"method"`
+	BulkID   string `json:"bulkId,omitempty"`
+	Location string `json:"location,omitempty"`
+	Status   string `json:"status"`
+	Response any    `json:"response,omitempty"`
+}
+
+// BulkResponse 批量响应
+type BulkResponse struct {
+	Schemas    []string              `json:"schemas"`
+	Operations []BulkOperationResult `json:"Operations"`
+}
+
+// ResolveBulkIDs 将数据中的 "bulkId:<id>" 引用替换为已创建资源的 ID
+func ResolveBulkIDs(v any, ids map[string]string) any {
+	switch val := v.(type) {
+	case string:
+		if ref, ok := strings.CutPrefix(val, "bulkId:"); ok {
+			if id, found := ids[ref]; found {
+				return id
+			}
+		}
+		return val
+	case map[string]any:
+		for k, item := range val {
+			val[k] = ResolveBulkIDs(item, ids)
+		}
+		return val
+	case []any:
+		for i, item := range val {
+			val[i] = ResolveBulkIDs(item, ids)
+		}
+		return val
+	}
+	return v
+}
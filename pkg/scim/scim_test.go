@@ -0,0 +1,105 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) map[string]any {
+	var m map[string]any
+	require.NoError(t, json.Unmarshal([]byte(s), &m))
+	return m
+}
+
+func TestParseFilter(t *testing.T) {
+	filters, err := ParseFilter(`userName eq "Bjensen@Example.com" and active eq true`)
+	require.NoError(t, err)
+	require.Len(t, filters, 2)
+	assert.Equal(t, Filter{Attr: "userName", Op: OpEq, Value: "Bjensen@Example.com"}, filters[0])
+	assert.Equal(t, true, filters[1].Value)
+
+	user := decode(t, `{"userName":"bjensen@example.com","active":true,"emails":[{"value":"b@work.com","type":"work"}]}`)
+	for _, f := range filters {
+		assert.True(t, f.Match(user), f.String())
+	}
+	f, err := ParseFilter(`emails.value ew "@work.com"`)
+	require.NoError(t, err)
+	assert.True(t, f[0].Match(user))
+	f, _ = ParseFilter(`title pr`)
+	assert.False(t, f[0].Match(user))
+
+	_, err = ParseFilter(`userName eq "x" or userName eq "y"`)
+	assert.Error(t, err)
+	_, err = ParseFilter(`userName like "x"`)
+	assert.Error(t, err)
+	_, err = ParseFilter(`userName eq "x`)
+	assert.Error(t, err)
+}
+
+func TestGetPath(t *testing.T) {
+	user := decode(t, `{
+		"name": {"givenName": "Barbara"},
+		"emails": [{"value": "home@example.com", "type": "home"}, {"value": "work@example.com", "type": "work", "primary": true}],
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Sales"}
+	}`)
+	v, ok := GetPath(user, "name.givenName")
+	assert.True(t, ok)
+	assert.Equal(t, "Barbara", v)
+	v, _ = GetPath(user, "NAME.GIVENNAME")
+	assert.Equal(t, "Barbara", v)
+	v, _ = GetPath(user, `emails[primary eq true].value`)
+	assert.Equal(t, "work@example.com", v)
+	v, _ = GetPath(user, `emails[type eq "home"].value`)
+	assert.Equal(t, "home@example.com", v)
+	v, _ = GetPath(user, SchemaEnterpriseUser+":department")
+	assert.Equal(t, "Sales", v)
+	_, ok = GetPath(user, "title")
+	assert.False(t, ok)
+}
+
+func TestApplyPatch(t *testing.T) {
+	user := decode(t, `{"userName":"b","active":true,"name":{"givenName":"B"},"emails":[{"value":"a@x.com","type":"work"}]}`)
+	ops := []PatchOp{
+		{Op: "Replace", Path: "active", Value: false},
+		{Op: "replace", Value: map[string]any{"name.familyName": "Jensen", "displayName": "Babs"}},
+		{Op: "replace", Path: `emails[type eq "work"].value`, Value: "b@x.com"},
+		{Op: "add", Path: `phoneNumbers[type eq "mobile"].value`, Value: "+100"},
+		{Op: "add", Path: SchemaEnterpriseUser + ":department", Value: "R&D"},
+		{Op: "add", Value: map[string]any{SchemaEnterpriseUser: map[string]any{"employeeNumber": "7"}}},
+	}
+	require.NoError(t, ApplyPatch(user, ops))
+	assert.Equal(t, false, user["active"])
+	assert.Equal(t, "Jensen", user["name"].(map[string]any)["familyName"])
+	assert.Equal(t, "B", user["name"].(map[string]any)["givenName"])
+	assert.Equal(t, "Babs", user["displayName"])
+	v, _ := GetPath(user, `emails[type eq "work"].value`)
+	assert.Equal(t, "b@x.com", v)
+	v, _ = GetPath(user, `phoneNumbers[type eq "mobile"].value`)
+	assert.Equal(t, "+100", v)
+	ext := user[SchemaEnterpriseUser].(map[string]any)
+	assert.Equal(t, "R&D", ext["department"])
+	assert.Equal(t, "7", ext["employeeNumber"])
+
+	group := decode(t, `{"displayName":"eng","members":[{"value":"1"},{"value":"2"}]}`)
+	require.NoError(t, ApplyPatch(group, []PatchOp{
+		{Op: "add", Path: "members", Value: []any{map[string]any{"value": "3"}, map[string]any{"value": "1"}}},
+		{Op: "remove", Path: `members[value eq "2"]`},
+		{Op: "remove", Path: "members", Value: []any{map[string]any{"value": "3"}}},
+	}))
+	assert.Equal(t, []any{map[string]any{"value": "1"}}, group["members"])
+
+	assert.Error(t, ApplyPatch(group, []PatchOp{{Op: "move", Path: "members"}}))
+	assert.Error(t, ApplyPatch(group, []PatchOp{{Op: "remove"}}))
+	assert.Error(t, ApplyPatch(group, []PatchOp{{Op: "replace", Value: "x"}}))
+}
+
+func TestResolveBulkIDs(t *testing.T) {
+	data := decode(t, `{"members":[{"value":"bulkId:u1"},{"value":"bulkId:missing"}]}`)
+	ResolveBulkIDs(data, map[string]string{"u1": "42"})
+	members := data["members"].([]any)
+	assert.Equal(t, "42", members[0].(map[string]any)["value"])
+	assert.Equal(t, "bulkId:missing", members[1].(map[string]any)["value"])
+}
@@ -0,0 +1,347 @@
+// Package semcache semantic cache of LLM answers. Queries are normalized and
+// embedded; a later query whose embedding is close enough to a cached one
+// within the same scope (for example an assistant and the version of its
+// knowledge base) is answered from the cache until the entry expires.
+// Identical normalized queries are matched by content hash without a scan.
+package semcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Defaults applied to zero config values
+const (
+	DefaultThreshold  = 0.92
+	DefaultTTL        = 24 * time.Hour
+	DefaultMaxEntries = 1000
+	// Shorter queries ("yes", "the second one") depend on the conversation and are never cached
+	DefaultMinQueryRunes = 4
+	embeddingDim         = 256
+)
+
+// Embedder turns a normalized query into a vector; vectors are compared by cosine similarity
+type Embedder interface {
+	Embed(text string) []float32
+}
+
+// Config cache parameters
+type Config struct {
+	Threshold  float64       // Minimum cosine similarity of a semantic hit
+	TTL        time.Duration // Freshness of an entry
+	MaxEntries int           // Entries kept per scope, the oldest are evicted
+	MinRunes   int           // Minimum normalized query length
+}
+
+func (c Config) withDefaults() Config {
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		c.Threshold = DefaultThreshold
+	}
+	if c.TTL <= 0 {
+		c.TTL = DefaultTTL
+	}
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = DefaultMaxEntries
+	}
+	if c.MinRunes <= 0 {
+		c.MinRunes = DefaultMinQueryRunes
+	}
+	return c
+}
+
+// Hit a cached answer
+type Hit struct {
+	Answer     string
+	Query      string  // Normalized query the answer was cached for
+	Similarity float64 // 1 for exact matches
+	Exact      bool
+}
+
+// Stats hit counters since the cache was created, per scope or in total
+type Stats struct {
+	Scope        string  `json:"scope,omitempty"`
+	Entries      int     `json:"entries"`
+	ExactHits    int64   `json:"exactHits"`
+	SemanticHits int64   `json:"semanticHits"`
+	Misses       int64   `json:"misses"`
+	Stores       int64   `json:"stores"`
+	HitRate      float64 `json:"hitRate"`
+}
+
+func (s *Stats) finish() {
+	if total := s.ExactHits + s.SemanticHits + s.Misses; total > 0 {
+		s.HitRate = float64(s.ExactHits+s.SemanticHits) / float64(total)
+	}
+}
+
+type entry struct {
+	hash     string
+	query    string
+	vector   []float32
+	answer   string
+	storedAt time.Time
+}
+
+type scope struct {
+	entries []*entry // oldest first
+	stats   Stats
+}
+
+// Cache in-memory semantic cache
+type Cache struct {
+	mu       sync.Mutex
+	cfg      Config
+	embedder Embedder
+	scopes   map[string]*scope
+	now      func() time.Time
+}
+
+var defaultCache = New(Config{}, nil)
+
+// Default returns the process-wide cache
+func Default() *Cache {
+	return defaultCache
+}
+
+// New creates a cache, a nil embedder uses the built-in character n-gram embedding
+func New(cfg Config, embedder Embedder) *Cache {
+	if embedder == nil {
+		embedder = NGramEmbedder{}
+	}
+	return &Cache{cfg: cfg.withDefaults(), embedder: embedder, scopes: make(map[string]*scope), now: time.Now}
+}
+
+// Configure replaces the threshold, TTL and size limit
+func (c *Cache) Configure(cfg Config) {
+	c.mu.Lock()
+	c.cfg = cfg.withDefaults()
+	c.mu.Unlock()
+}
+
+// Config returns the current parameters
+func (c *Cache) Config() Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg
+}
+
+// Normalize lowercases the query, drops punctuation and symbols and collapses whitespace
+func Normalize(query string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(query) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+func hashQuery(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup finds a fresh answer for the query in the scope
+func (c *Cache) Lookup(scopeKey, query string) (Hit, bool) {
+	normalized := Normalize(query)
+	if !c.cacheable(normalized) {
+		return Hit{}, false
+	}
+	hash := hashQuery(normalized)
+	vector := c.embedder.Embed(normalized)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.scope(scopeKey)
+	c.expire(s)
+
+	var best *entry
+	bestSim := -1.0
+	for _, e := range s.entries {
+		if e.hash == hash {
+			s.stats.ExactHits++
+			return Hit{Answer: e.answer, Query: e.query, Similarity: 1, Exact: true}, true
+		}
+		if sim := cosine(vector, e.vector); sim > bestSim {
+			best, bestSim = e, sim
+		}
+	}
+	if best != nil && bestSim >= c.cfg.Threshold {
+		s.stats.SemanticHits++
+		return Hit{Answer: best.answer, Query: best.query, Similarity: bestSim}, true
+	}
+	s.stats.Misses++
+	return Hit{}, false
+}
+
+// Store caches the answer of a query, replacing an answer cached for the same normalized query
+func (c *Cache) Store(scopeKey, query, answer string) {
+	normalized := Normalize(query)
+	if !c.cacheable(normalized) || strings.TrimSpace(answer) == "" {
+		return
+	}
+	e := &entry{
+		hash:   hashQuery(normalized),
+		query:  normalized,
+		vector: c.embedder.Embed(normalized),
+		answer: answer,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.storedAt = c.now()
+	s := c.scope(scopeKey)
+	for i, old := range s.entries {
+		if old.hash == e.hash {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			break
+		}
+	}
+	s.entries = append(s.entries, e)
+	if over := len(s.entries) - c.cfg.MaxEntries; over > 0 {
+		s.entries = s.entries[over:]
+	}
+	s.stats.Stores++
+}
+
+// Invalidate drops the entries of every scope starting with prefix, all scopes for ""
+func (c *Cache) Invalidate(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dropped := 0
+	for key, s := range c.scopes {
+		if strings.HasPrefix(key, prefix) {
+			dropped += len(s.entries)
+			s.entries = nil
+		}
+	}
+	return dropped
+}
+
+// Stats returns the totals and the per scope counters, busiest scopes first
+func (c *Cache) Stats() (Stats, []Stats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total Stats
+	scopes := make([]Stats, 0, len(c.scopes))
+	for key, s := range c.scopes {
+		c.expire(s)
+		st := s.stats
+		st.Scope = key
+		st.Entries = len(s.entries)
+		st.finish()
+		scopes = append(scopes, st)
+
+		total.Entries += st.Entries
+		total.ExactHits += st.ExactHits
+		total.SemanticHits += st.SemanticHits
+		total.Misses += st.Misses
+		total.Stores += st.Stores
+	}
+	total.finish()
+	sort.Slice(scopes, func(i, j int) bool {
+		ti := scopes[i].ExactHits + scopes[i].SemanticHits + scopes[i].Misses
+		tj := scopes[j].ExactHits + scopes[j].SemanticHits + scopes[j].Misses
+		if ti != tj {
+			return ti > tj
+		}
+		return scopes[i].Scope < scopes[j].Scope
+	})
+	return total, scopes
+}
+
+func (c *Cache) cacheable(normalized string) bool {
+	c.mu.Lock()
+	min := c.cfg.MinRunes
+	c.mu.Unlock()
+	return utf8.RuneCountInString(normalized) >= min
+}
+
+func (c *Cache) scope(key string) *scope {
+	s, ok := c.scopes[key]
+	if !ok {
+		s = &scope{}
+		c.scopes[key] = s
+	}
+	return s
+}
+
+// expire drops entries older than the TTL, entries are ordered by store time
+func (c *Cache) expire(s *scope) {
+	cutoff := c.now().Add(-c.cfg.TTL)
+	i := 0
+	for i < len(s.entries) && s.entries[i].storedAt.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		s.entries = s.entries[i:]
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// NGramEmbedder hashes character unigrams and bigrams into a fixed size vector.
+// It needs no model and works for languages written without spaces; plug a
+// model-based Embedder for paraphrases that share few characters.
+type NGramEmbedder struct{}
+
+func (NGramEmbedder) Embed(text string) []float32 {
+	vector := make([]float32, embeddingDim)
+	add := func(gram string, weight float32) {
+		h := fnv.New32a()
+		h.Write([]byte(gram))
+		vector[h.Sum32()%embeddingDim] += weight
+	}
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for i, r := range runes {
+			add(string(r), 0.5)
+			if i+1 < len(runes) {
+				add(string(runes[i:i+2]), 1)
+			}
+		}
+	}
+	return vector
+}
+
+// Scope key of an assistant's answers for a knowledge base version; a new
+// version starts an empty scope so answers never outlive the documents
+func Scope(assistantID uint, kbVersion int64) string {
+	return AssistantPrefix(assistantID) + "kb:" + strconv.FormatInt(kbVersion, 10)
+}
+
+// AssistantPrefix prefix of all scopes of an assistant, for Invalidate
+func AssistantPrefix(assistantID uint) string {
+	return "assistant:" + strconv.FormatUint(uint64(assistantID), 10) + ":"
+}
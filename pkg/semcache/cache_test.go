@@ -0,0 +1,162 @@
+package semcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "what are your opening hours", Normalize("  What are your   opening hours?? "))
+	assert.Equal(t, "营业时间是几点", Normalize("营业时间是几点？"))
+	assert.Equal(t, "", Normalize("?!"))
+}
+
+func TestCache_ExactAndSemanticHits(t *testing.T) {
+	c := New(Config{Threshold: 0.8}, nil)
+	scope := Scope(1, 100)
+
+	_, ok := c.Lookup(scope, "How do I reset my password?")
+	assert.False(t, ok)
+
+	c.Store(scope, "How do I reset my password?", "Use the forgot password link.")
+
+	hit, ok := c.Lookup(scope, "how do i reset my password")
+	require.True(t, ok)
+	assert.True(t, hit.Exact)
+	assert.Equal(t, "Use the forgot password link.", hit.Answer)
+
+	hit, ok = c.Lookup(scope, "How can I reset my password?")
+	require.True(t, ok)
+	assert.False(t, hit.Exact)
+	assert.GreaterOrEqual(t, hit.Similarity, 0.8)
+
+	c.Store(scope, "你们的营业时间是几点", "早九点到晚六点。")
+	hit, ok = c.Lookup(scope, "营业时间是几点？")
+	require.True(t, ok)
+	assert.Equal(t, "早九点到晚六点。", hit.Answer)
+
+	_, ok = c.Lookup(scope, "What is the weather tomorrow?")
+	assert.False(t, ok)
+
+	// Other assistants and knowledge base versions do not share answers
+	_, ok = c.Lookup(Scope(2, 100), "How do I reset my password?")
+	assert.False(t, ok)
+	_, ok = c.Lookup(Scope(1, 101), "How do I reset my password?")
+	assert.False(t, ok)
+
+	total, scopes := c.Stats()
+	assert.Equal(t, int64(1), total.ExactHits)
+	assert.Equal(t, int64(2), total.SemanticHits)
+	assert.Equal(t, int64(4), total.Misses)
+	assert.Equal(t, int64(2), total.Stores)
+	assert.InDelta(t, 3.0/7.0, total.HitRate, 1e-9)
+	require.Len(t, scopes, 3)
+	assert.Equal(t, scope, scopes[0].Scope)
+	assert.Equal(t, 2, scopes[0].Entries)
+}
+
+func TestCache_TTLEvictionAndInvalidate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(Config{TTL: time.Hour, MaxEntries: 2}, nil)
+	c.now = func() time.Time { return now }
+
+	c.Store(Scope(1, 1), "first question", "a")
+	c.Store(Scope(1, 1), "second question", "b")
+	c.Store(Scope(1, 1), "third question", "c")
+	_, ok := c.Lookup(Scope(1, 1), "first question")
+	assert.False(t, ok, "oldest entry is evicted over the size limit")
+	_, ok = c.Lookup(Scope(1, 1), "third question")
+	assert.True(t, ok)
+
+	now = now.Add(2 * time.Hour)
+	_, ok = c.Lookup(Scope(1, 1), "third question")
+	assert.False(t, ok, "expired")
+
+	c.Store(Scope(1, 1), "third question", "c")
+	c.Store(Scope(12, 1), "third question", "c")
+	assert.Equal(t, 1, c.Invalidate(AssistantPrefix(1)))
+	_, ok = c.Lookup(Scope(12, 1), "third question")
+	assert.True(t, ok)
+
+	// Short follow-ups depend on the conversation
+	c.Store(Scope(1, 1), "yes", "done")
+	_, ok = c.Lookup(Scope(1, 1), "yes")
+	assert.False(t, ok)
+}
+
+type fakeProvider struct {
+	llm.LLMProvider
+	calls    int
+	messages []llm.Message
+	toolTurn bool
+	err      error
+}
+
+func (f *fakeProvider) Query(text, model string) (string, error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+	f.messages = append(f.messages, llm.Message{Role: "user", Content: text})
+	if f.toolTurn {
+		f.messages = append(f.messages, llm.Message{Role: "assistant"}, llm.Message{Role: "tool"})
+	}
+	f.messages = append(f.messages, llm.Message{Role: "assistant", Content: "answer to " + text})
+	return "answer to " + text, nil
+}
+
+func (f *fakeProvider) QueryStream(text string, options llm.QueryOptions, callback func(string, bool) error) (string, error) {
+	answer, err := f.Query(text, options.Model)
+	if err == nil {
+		err = callback(answer, true)
+	}
+	return answer, err
+}
+
+func (f *fakeProvider) GetMessages() []llm.Message { return f.messages }
+
+func (f *fakeProvider) AppendMessages(messages ...llm.Message) {
+	f.messages = append(f.messages, messages...)
+}
+
+func TestProvider(t *testing.T) {
+	fake := &fakeProvider{}
+	p := Wrap(fake, New(Config{}, nil), Scope(1, 0))
+
+	first, err := p.Query("What is the refund policy?", "")
+	require.NoError(t, err)
+	second, err := p.Query("what is the refund policy", "")
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, fake.calls)
+	assert.Len(t, fake.messages, 4, "cache hits are kept in the history")
+
+	var streamed []string
+	answer, err := p.QueryStream("What is the refund policy?", llm.QueryOptions{}, func(segment string, isComplete bool) error {
+		assert.True(t, isComplete)
+		streamed = append(streamed, segment)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{first}, streamed)
+	assert.Equal(t, first, answer)
+	assert.Equal(t, 1, fake.calls)
+
+	// Answers produced with tools or failed calls are not cached
+	fake.toolTurn = true
+	p.Query("Book a table for two", "")
+	p.Query("Book a table for two", "")
+	assert.Equal(t, 3, fake.calls)
+
+	fake.toolTurn, fake.err = false, errors.New("boom")
+	_, err = p.Query("Where is the office?", "")
+	assert.Error(t, err)
+	fake.err = nil
+	p.Query("Where is the office?", "")
+	assert.Equal(t, 5, fake.calls)
+}
@@ -0,0 +1,71 @@
+package semcache
+
+import "github.com/code-100-precent/LingEcho/pkg/llm"
+
+// Provider answers repeated questions from the cache and forwards the rest to
+// the wrapped provider. Turns that ran tool calls are not cached since their
+// answers depend on the outcome of the tools.
+type Provider struct {
+	llm.LLMProvider
+	cache *Cache
+	scope string
+}
+
+// Wrap caches the answers of p in the scope
+func Wrap(p llm.LLMProvider, cache *Cache, scope string) *Provider {
+	return &Provider{LLMProvider: p, cache: cache, scope: scope}
+}
+
+func (p *Provider) Query(text, model string) (string, error) {
+	return p.cached(text, func() (string, error) {
+		return p.LLMProvider.Query(text, model)
+	})
+}
+
+func (p *Provider) QueryWithOptions(text string, options llm.QueryOptions) (string, error) {
+	return p.cached(text, func() (string, error) {
+		return p.LLMProvider.QueryWithOptions(text, options)
+	})
+}
+
+func (p *Provider) QueryStream(text string, options llm.QueryOptions, callback func(segment string, isComplete bool) error) (string, error) {
+	if hit, ok := p.cache.Lookup(p.scope, text); ok {
+		p.remember(text, hit.Answer)
+		if callback != nil {
+			if err := callback(hit.Answer, true); err != nil {
+				return hit.Answer, err
+			}
+		}
+		return hit.Answer, nil
+	}
+	return p.store(text, func() (string, error) {
+		return p.LLMProvider.QueryStream(text, options, callback)
+	})
+}
+
+func (p *Provider) cached(text string, query func() (string, error)) (string, error) {
+	if hit, ok := p.cache.Lookup(p.scope, text); ok {
+		p.remember(text, hit.Answer)
+		return hit.Answer, nil
+	}
+	return p.store(text, query)
+}
+
+func (p *Provider) store(text string, query func() (string, error)) (string, error) {
+	before := len(p.GetMessages())
+	answer, err := query()
+	// A plain turn appends the user and assistant messages only
+	if err == nil && len(p.GetMessages())-before <= 2 {
+		p.cache.Store(p.scope, text, answer)
+	}
+	return answer, err
+}
+
+// remember keeps the conversation history consistent when the answer came from the cache
+func (p *Provider) remember(text, answer string) {
+	if h, ok := p.LLMProvider.(interface {
+		AppendMessages(...llm.Message)
+	}); ok {
+		h.AppendMessages(llm.Message{Role: "user", Content: text}, llm.Message{Role: "assistant", Content: answer})
+	}
+}
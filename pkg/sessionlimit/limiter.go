@@ -0,0 +1,213 @@
+// Package sessionlimit caps the number of concurrent sessions per key (for
+// example an assistant) with a small FIFO wait queue in front of each key.
+package sessionlimit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/waitqueue"
+)
+
+var (
+	// ErrQueueFull all slots are taken and the wait queue is full
+	ErrQueueFull = errors.New("all sessions are busy")
+	// ErrWaitTimeout no slot became free within the queue timeout
+	ErrWaitTimeout = errors.New("timed out waiting for a free session")
+)
+
+// DefaultQueueTimeout applies when a limit with a queue has no timeout
+const DefaultQueueTimeout = 30 * time.Second
+
+// Limit concurrency limit of a key. MaxConcurrent <= 0 means unlimited.
+type Limit struct {
+	MaxConcurrent int
+	QueueSize     int
+	QueueTimeout  time.Duration
+}
+
+// Unlimited reports whether the limit admits every session
+func (l Limit) Unlimited() bool {
+	return l.MaxConcurrent <= 0
+}
+
+// Stats counters of a key since the process started
+type Stats struct {
+	Key           string        `json:"key"`
+	Active        int           `json:"active"`
+	Waiting       int           `json:"waiting"`
+	MaxConcurrent int           `json:"maxConcurrent"`
+	Admitted      int64         `json:"admitted"`
+	Queued        int64         `json:"queued"`   // Admissions that had to wait
+	Rejected      int64         `json:"rejected"` // Queue was full
+	TimedOut      int64         `json:"timedOut"`
+	Abandoned     int64         `json:"abandoned"` // Caller gave up while waiting
+	TotalWait     time.Duration `json:"totalWait"`
+	MaxWait       time.Duration `json:"maxWait"`
+}
+
+// AvgWait average wait of the sessions that were queued and admitted
+func (s Stats) AvgWait() time.Duration {
+	if s.Queued == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Queued)
+}
+
+type entry struct {
+	max     int
+	active  int
+	waiters *waitqueue.Queue
+	stats   Stats
+}
+
+// Limiter in-memory per-key limiter
+type Limiter struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+var defaultLimiter = New()
+
+// Default returns the process-wide limiter
+func Default() *Limiter {
+	return defaultLimiter
+}
+
+// New creates an empty limiter
+func New() *Limiter {
+	return &Limiter{entries: make(map[string]*entry)}
+}
+
+// Ticket a held slot, Release must be called once the session ends
+type Ticket struct {
+	limiter *Limiter
+	key     string
+	once    sync.Once
+	// Waited time spent in the queue before the slot was granted
+	Waited time.Duration
+}
+
+// Release frees the slot, handing it to the oldest waiter. Safe to call more than once.
+func (t *Ticket) Release() {
+	if t == nil || t.limiter == nil {
+		return
+	}
+	t.once.Do(func() { t.limiter.release(t.key) })
+}
+
+func (l *Limiter) entry(key string) *entry {
+	e, ok := l.entries[key]
+	if !ok {
+		e = &entry{waiters: waitqueue.New(1), stats: Stats{Key: key}}
+		l.entries[key] = e
+	}
+	return e
+}
+
+// Acquire takes a slot of key, waiting in the queue when all slots are busy.
+// It returns ErrQueueFull right away when the queue is full, ErrWaitTimeout
+// when the queue timeout elapses and the context error when ctx is done.
+func (l *Limiter) Acquire(ctx context.Context, key string, limit Limit) (*Ticket, error) {
+	return l.AcquireHeld(ctx, key, limit, nil)
+}
+
+// AcquireHeld is Acquire with a queue timeout that can be changed while
+// waiting: a duration received on hold restarts the timeout with it, keeping
+// the place in the queue. It holds the place of a caller who asked to be
+// called back instead of waiting on the line.
+func (l *Limiter) AcquireHeld(ctx context.Context, key string, limit Limit, hold <-chan time.Duration) (*Ticket, error) {
+	ticket := &Ticket{limiter: l, key: key}
+	start := time.Now()
+
+	l.mu.Lock()
+	e := l.entry(key)
+	e.max = limit.MaxConcurrent
+	e.stats.MaxConcurrent = limit.MaxConcurrent
+	if limit.Unlimited() || e.active < limit.MaxConcurrent {
+		e.active++
+		e.stats.Admitted++
+		l.mu.Unlock()
+		return ticket, nil
+	}
+	if e.waiters.Len() >= limit.QueueSize {
+		e.stats.Rejected++
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := e.waiters.Push(0)
+	l.mu.Unlock()
+
+	timeout := limit.QueueTimeout
+	if timeout <= 0 {
+		timeout = DefaultQueueTimeout
+	}
+	err := w.Wait(ctx, timeout, hold)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil && e.waiters.Remove(w) {
+		if errors.Is(err, waitqueue.ErrTimeout) {
+			e.stats.TimedOut++
+			err = ErrWaitTimeout
+		} else {
+			e.stats.Abandoned++
+		}
+		return nil, err
+	}
+	// Granted, possibly racing with the timeout
+	ticket.Waited = time.Since(start)
+	e.stats.Admitted++
+	e.stats.Queued++
+	e.stats.TotalWait += ticket.Waited
+	if ticket.Waited > e.stats.MaxWait {
+		e.stats.MaxWait = ticket.Waited
+	}
+	return ticket, nil
+}
+
+func (l *Limiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok || e.active == 0 {
+		return
+	}
+	// The slot moves to the next waiter unless the limit was lowered meanwhile
+	if (e.max <= 0 || e.active <= e.max) && e.waiters.Grant() {
+		return
+	}
+	e.active--
+}
+
+// Stats returns the counters of a key
+func (l *Limiter) Stats(key string) Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		return e.snapshot()
+	}
+	return Stats{Key: key}
+}
+
+// All returns the counters of every key, ordered by key
+func (l *Limiter) All() []Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all := make([]Stats, 0, len(l.entries))
+	for _, e := range l.entries {
+		all = append(all, e.snapshot())
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+	return all
+}
+
+func (e *entry) snapshot() Stats {
+	s := e.stats
+	s.Active = e.active
+	s.Waiting = e.waiters.Len()
+	return s
+}
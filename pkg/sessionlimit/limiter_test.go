@@ -0,0 +1,131 @@
+package sessionlimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := New()
+	for i := 0; i < 5; i++ {
+		_, err := l.Acquire(context.Background(), "a", Limit{})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 5, l.Stats("a").Active)
+}
+
+func TestLimiter_QueueHandOff(t *testing.T) {
+	l := New()
+	limit := Limit{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: time.Second}
+
+	first, err := l.Acquire(context.Background(), "a", limit)
+	require.NoError(t, err)
+
+	done := make(chan *Ticket)
+	go func() {
+		ticket, err := l.Acquire(context.Background(), "a", limit)
+		assert.NoError(t, err)
+		done <- ticket
+	}()
+	require.Eventually(t, func() bool { return l.Stats("a").Waiting == 1 }, time.Second, time.Millisecond)
+
+	_, err = l.Acquire(context.Background(), "a", limit)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	time.Sleep(10 * time.Millisecond)
+	first.Release()
+	first.Release() // idempotent
+	second := <-done
+	assert.GreaterOrEqual(t, second.Waited, 10*time.Millisecond)
+
+	stats := l.Stats("a")
+	assert.Equal(t, 1, stats.Active)
+	assert.Equal(t, 0, stats.Waiting)
+	assert.Equal(t, int64(2), stats.Admitted)
+	assert.Equal(t, int64(1), stats.Queued)
+	assert.Equal(t, int64(1), stats.Rejected)
+	assert.Equal(t, second.Waited, stats.AvgWait())
+
+	second.Release()
+	assert.Equal(t, 0, l.Stats("a").Active)
+}
+
+func TestLimiter_TimeoutAndCancel(t *testing.T) {
+	l := New()
+	limit := Limit{MaxConcurrent: 1, QueueSize: 2, QueueTimeout: 20 * time.Millisecond}
+	held, err := l.Acquire(context.Background(), "a", limit)
+	require.NoError(t, err)
+
+	_, err = l.Acquire(context.Background(), "a", limit)
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = l.Acquire(ctx, "a", limit)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	stats := l.Stats("a")
+	assert.Equal(t, int64(1), stats.TimedOut)
+	assert.Equal(t, int64(1), stats.Abandoned)
+	assert.Equal(t, 0, stats.Waiting)
+
+	held.Release()
+	assert.Equal(t, 0, l.Stats("a").Active)
+}
+
+func TestLimiter_LoweredLimit(t *testing.T) {
+	l := New()
+	a, _ := l.Acquire(context.Background(), "a", Limit{MaxConcurrent: 2})
+	b, _ := l.Acquire(context.Background(), "a", Limit{MaxConcurrent: 2})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(context.Background(), "a", Limit{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 50 * time.Millisecond})
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return l.Stats("a").Waiting == 1 }, time.Second, time.Millisecond)
+
+	// Two sessions are active over the new limit of one, the first release must not hand off
+	a.Release()
+	assert.ErrorIs(t, <-done, ErrWaitTimeout)
+	assert.Equal(t, 1, l.Stats("a").Active)
+	b.Release()
+	assert.Len(t, l.All(), 1)
+}
+
+func TestLimiter_AcquireHeld(t *testing.T) {
+	l := New()
+	limit := Limit{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond}
+	held, err := l.Acquire(context.Background(), "a", limit)
+	require.NoError(t, err)
+
+	hold := make(chan time.Duration)
+	done := make(chan *Ticket)
+	go func() {
+		ticket, err := l.AcquireHeld(context.Background(), "a", limit, hold)
+		assert.NoError(t, err)
+		done <- ticket
+	}()
+	// Extended well past the queue timeout, the place is kept
+	hold <- time.Second
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, l.Stats("a").Waiting)
+
+	held.Release()
+	ticket := <-done
+	assert.GreaterOrEqual(t, ticket.Waited, 50*time.Millisecond)
+	assert.Equal(t, int64(0), l.Stats("a").TimedOut)
+
+	go func() {
+		_, err := l.AcquireHeld(context.Background(), "a", limit, hold)
+		assert.ErrorIs(t, err, ErrWaitTimeout)
+		close(done)
+	}()
+	hold <- time.Millisecond
+	<-done
+	ticket.Release()
+}
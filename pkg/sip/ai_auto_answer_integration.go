@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
 	"github.com/code-100-precent/LingEcho/pkg/llm"
 	"github.com/code-100-precent/LingEcho/pkg/recognizer"
+	"github.com/code-100-precent/LingEcho/pkg/semcache"
 	"github.com/code-100-precent/LingEcho/pkg/sip/codec"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer"
 	"github.com/code-100-precent/LingEcho/pkg/voice/factory"
 	"github.com/emiago/sipgo/sip"
 	"github.com/pion/rtp"
@@ -107,12 +110,13 @@ func (as *SipServer) checkAIAutoAnswer(req *sip.Request) (bool, *models.SipUser,
 	return true, &sipUser, &assistant, nil
 }
 
-// startAIVoiceSession 启动 AI 语音会话
+// startAIVoiceSession 启动 AI 语音会话，resume 不为空时从检查点恢复中断前的对话状态
 func (as *SipServer) startAIVoiceSession(
 	callID string,
 	clientRTPAddr *net.UDPAddr,
 	sipUser *models.SipUser,
 	assistant *models.Assistant,
+	resume *models.AICallCheckpoint,
 ) error {
 	logrus.WithFields(logrus.Fields{
 		"call_id":     callID,
@@ -121,65 +125,13 @@ func (as *SipServer) startAIVoiceSession(
 		"client_addr": clientRTPAddr.String(),
 	}).Info("🤖 启动 AI 语音会话")
 
-	// 获取用户凭证
-	// 如果 Assistant 配置了 ApiKey 和 ApiSecret，通过它们查找对应的凭证
-	// 否则使用 Assistant 用户的第一个凭证
-	var credential *models.UserCredential
-
-	// 方案1：如果 Assistant 有 ApiKey 和 ApiSecret，通过它们查找凭证
-	if assistant.ApiKey != "" && assistant.ApiSecret != "" {
-		var cred models.UserCredential
-		if err := as.db.Where("api_key = ? AND api_secret = ?", assistant.ApiKey, assistant.ApiSecret).First(&cred).Error; err == nil {
-			credential = &cred
-			logrus.WithFields(logrus.Fields{
-				"call_id":       callID,
-				"api_key":       assistant.ApiKey,
-				"credential_id": cred.ID,
-				"user_id":       cred.UserID,
-				"asr_provider":  cred.GetASRProvider(),
-				"tts_provider":  cred.GetTTSProvider(),
-			}).Info("✓ 通过 ApiKey/ApiSecret 找到凭证")
-		} else {
-			logrus.WithFields(logrus.Fields{
-				"call_id": callID,
-				"api_key": assistant.ApiKey,
-				"error":   err,
-			}).Warn("⚠️  未找到 ApiKey/ApiSecret 对应的凭证")
-		}
-	}
-
-	// 方案2：尝试从 Assistant 的用户获取凭证
-	if credential == nil && assistant.UserID > 0 {
-		var cred models.UserCredential
-		if err := as.db.Where("user_id = ?", assistant.UserID).First(&cred).Error; err == nil {
-			credential = &cred
-			logrus.WithFields(logrus.Fields{
-				"call_id":        callID,
-				"user_id":        assistant.UserID,
-				"credential_id":  cred.ID,
-				"asr_provider":   cred.GetASRProvider(),
-				"tts_provider":   cred.GetTTSProvider(),
-				"has_asr_config": cred.AsrConfig != nil && len(cred.AsrConfig) > 0,
-				"has_tts_config": cred.TtsConfig != nil && len(cred.TtsConfig) > 0,
-			}).Info("✓ 使用 Assistant 用户的凭证")
-		} else {
-			logrus.WithFields(logrus.Fields{
-				"call_id": callID,
-				"user_id": assistant.UserID,
-				"error":   err,
-			}).Warn("⚠️  未找到 Assistant 用户的凭证")
-		}
+	if resume == nil && assistant.Interpreting() {
+		return as.startInterpretedCall(callID, clientRTPAddr, assistant)
 	}
 
-	// 方案3：如果还是没有，使用第一个可用凭证
-	if credential == nil {
-		var cred models.UserCredential
-		if err := as.db.First(&cred).Error; err == nil {
-			credential = &cred
-			logrus.WithField("call_id", callID).Warn("⚠️  使用默认凭证（第一个可用凭证）")
-		} else {
-			return fmt.Errorf("no credential available for AI session")
-		}
+	credential, err := as.assistantCredential(callID, assistant)
+	if err != nil {
+		return err
 	}
 
 	// 创建服务工厂
@@ -200,17 +152,27 @@ func (as *SipServer) startAIVoiceSession(
 	}
 
 	// 创建 TTS 服务
-	ttsService, err := serviceFactory.CreateTTS(credential, assistant.Speaker)
+	ttsService, err := serviceFactory.CreateNormalizedTTS(credential, assistant.Speaker, assistant.TextNormalization())
 	if err != nil {
 		return fmt.Errorf("failed to create TTS service: %w", err)
 	}
 
+	// 呼入时用 CRM 中的客户信息个性化提示词和开场白，从检查点恢复的通话已经问候过
+	systemPrompt := assistant.SystemPrompt
+	if resume == nil {
+		var callerContext string
+		callerContext, sipUser = as.enrichInboundCall(callID, sipUser, assistant)
+		if callerContext != "" {
+			systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + callerContext)
+		}
+	}
+
 	// 创建 LLM Provider
 	// 注意：需要将助手的模型配置传递给 LLM Provider
 	llmProvider, err := serviceFactory.CreateLLM(
 		context.Background(),
 		credential,
-		assistant.SystemPrompt,
+		systemPrompt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create LLM provider: %w", err)
@@ -229,6 +191,17 @@ func (as *SipServer) startAIVoiceSession(
 		}
 	}
 
+	// 启用语义缓存时，相近的问题直接复用该助手之前的回答
+	if as.db != nil {
+		if cache := models.LLMCache(as.db); cache != nil {
+			knowledgeKey := ""
+			if assistant.KnowledgeBaseID != nil {
+				knowledgeKey = *assistant.KnowledgeBaseID
+			}
+			llmProvider = semcache.Wrap(llmProvider, cache, models.LLMCacheScope(as.db, uint(assistant.ID), knowledgeKey))
+		}
+	}
+
 	// 创建 VoiceConversationHandler
 	handler := NewVoiceConversationHandler(
 		callID,
@@ -240,6 +213,32 @@ func (as *SipServer) startAIVoiceSession(
 		llmProvider,
 		sipUser, // 传递 SipUser 配置
 	)
+	as.attachCallSurvey(handler, sipUser, assistant)
+	handler.enableProviderGuard(as.providerGuardFor(callID, serviceFactory, credential, assistant, systemPrompt, llmProvider, ttsService))
+
+	// 方案开启静音抑制且对端协商了舒适噪声时，静音期间停发语音包
+	as.aiSessionMutex.RLock()
+	info := as.aiSessionInfo[callID]
+	as.aiSessionMutex.RUnlock()
+	if info != nil {
+		handler.enableSilenceSuppression(silenceSuppressionFor(sipUser, info.ComfortNoise))
+	}
+
+	// 外呼时被叫方常为 IVR，允许 LLM 发送 DTMF 按键导航菜单
+	if as.isOutgoingCall(callID) {
+		handler.enableDTMFTool()
+	}
+
+	// 保存检查点，进程重启后由本实例或 HA 对端恢复
+	if as.db != nil {
+		if resume != nil {
+			handler.restoreCheckpoint(resume)
+		} else {
+			resume = as.newAICallCheckpoint(callID, clientRTPAddr, sipUser, assistant)
+		}
+		resume.Instance = as.instance
+		handler.enableCheckpoint(as.db, resume)
+	}
 
 	// 保存 handler
 	as.voiceHandlersMu.Lock()
@@ -249,6 +248,9 @@ func (as *SipServer) startAIVoiceSession(
 	// 启动 handler
 	handler.Start()
 
+	// 开放外部媒体流
+	handler.openMediaStream()
+
 	// 启动 RTP 接收协程
 	go as.receiveRTPForAI(callID, clientRTPAddr, handler)
 
@@ -257,9 +259,114 @@ func (as *SipServer) startAIVoiceSession(
 	return nil
 }
 
+// providerGuardFor 按助手配置创建 LLM/TTS 超时保护，配置了备用凭证时加入备用服务商
+func (as *SipServer) providerGuardFor(
+	callID string,
+	serviceFactory *factory.ServiceFactory,
+	credential *models.UserCredential,
+	assistant *models.Assistant,
+	systemPrompt string,
+	llmProvider llm.LLMProvider,
+	ttsService synthesizer.SynthesisService,
+) *providerGuard {
+	cfg := assistant.Guard()
+	llms := []guardedLLM{{name: credential.LLMProvider, provider: llmProvider}}
+	ttss := []guardedTTS{{name: credential.GetTTSProvider(), service: ttsService}}
+
+	if cfg.FailoverCredentialID != 0 && as.db != nil {
+		var failover models.UserCredential
+		err := as.db.Where("id = ? AND user_id = ?", cfg.FailoverCredentialID, assistant.UserID).First(&failover).Error
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"call_id":       callID,
+				"credential_id": cfg.FailoverCredentialID,
+				"error":         err,
+			}).Warn("⚠️  未找到备用服务商凭证")
+		} else {
+			if failoverLLM, err := serviceFactory.CreateLLM(context.Background(), &failover, systemPrompt); err == nil {
+				llms = append(llms, guardedLLM{name: failover.LLMProvider, provider: failoverLLM})
+			} else {
+				logrus.WithFields(logrus.Fields{"call_id": callID, "error": err}).Warn("⚠️  创建备用 LLM 失败")
+			}
+			if failoverTTS, err := serviceFactory.CreateNormalizedTTS(&failover, assistant.Speaker, assistant.TextNormalization()); err == nil {
+				ttss = append(ttss, guardedTTS{name: failover.GetTTSProvider(), service: failoverTTS})
+			} else {
+				logrus.WithFields(logrus.Fields{"call_id": callID, "error": err}).Warn("⚠️  创建备用 TTS 失败")
+			}
+		}
+	}
+	return newProviderGuard(cfg, as.db, llms, ttss)
+}
+
+// assistantCredential 获取助手使用的用户凭证
+func (as *SipServer) assistantCredential(callID string, assistant *models.Assistant) (*models.UserCredential, error) {
+	// 获取用户凭证
+	// 如果 Assistant 配置了 ApiKey 和 ApiSecret，通过它们查找对应的凭证
+	// 否则使用 Assistant 用户的第一个凭证
+	var credential *models.UserCredential
+
+	// 方案1：如果 Assistant 有 ApiKey 和 ApiSecret，通过它们查找凭证
+	if assistant.ApiKey != "" && assistant.ApiSecret != "" {
+		var cred models.UserCredential
+		if err := as.db.Where("api_key = ? AND api_secret = ?", assistant.ApiKey, assistant.ApiSecret).First(&cred).Error; err == nil {
+			credential = &cred
+			logrus.WithFields(logrus.Fields{
+				"call_id":       callID,
+				"api_key":       assistant.ApiKey,
+				"credential_id": cred.ID,
+				"user_id":       cred.UserID,
+				"asr_provider":  cred.GetASRProvider(),
+				"tts_provider":  cred.GetTTSProvider(),
+			}).Info("✓ 通过 ApiKey/ApiSecret 找到凭证")
+		} else {
+			logrus.WithFields(logrus.Fields{
+				"call_id": callID,
+				"api_key": assistant.ApiKey,
+				"error":   err,
+			}).Warn("⚠️  未找到 ApiKey/ApiSecret 对应的凭证")
+		}
+	}
+
+	// 方案2：尝试从 Assistant 的用户获取凭证
+	if credential == nil && assistant.UserID > 0 {
+		var cred models.UserCredential
+		if err := as.db.Where("user_id = ?", assistant.UserID).First(&cred).Error; err == nil {
+			credential = &cred
+			logrus.WithFields(logrus.Fields{
+				"call_id":        callID,
+				"user_id":        assistant.UserID,
+				"credential_id":  cred.ID,
+				"asr_provider":   cred.GetASRProvider(),
+				"tts_provider":   cred.GetTTSProvider(),
+				"has_asr_config": cred.AsrConfig != nil && len(cred.AsrConfig) > 0,
+				"has_tts_config": cred.TtsConfig != nil && len(cred.TtsConfig) > 0,
+			}).Info("✓ 使用 Assistant 用户的凭证")
+		} else {
+			logrus.WithFields(logrus.Fields{
+				"call_id": callID,
+				"user_id": assistant.UserID,
+				"error":   err,
+			}).Warn("⚠️  未找到 Assistant 用户的凭证")
+		}
+	}
+
+	// 方案3：如果还是没有，使用第一个可用凭证
+	if credential == nil {
+		var cred models.UserCredential
+		if err := as.db.First(&cred).Error; err == nil {
+			credential = &cred
+			logrus.WithField("call_id", callID).Warn("⚠️  使用默认凭证（第一个可用凭证）")
+		} else {
+			return nil, fmt.Errorf("no credential available for AI session")
+		}
+	}
+	return credential, nil
+}
+
 // receiveRTPForAI 接收 RTP 包并转发给 AI handler
 func (as *SipServer) receiveRTPForAI(callID string, clientAddr *net.UDPAddr, handler *VoiceConversationHandler) {
 	buffer := make([]byte, 1500)
+	filler := newRTPGapFiller()
 
 	logrus.WithFields(logrus.Fields{
 		"call_id":     callID,
@@ -291,8 +398,8 @@ func (as *SipServer) receiveRTPForAI(callID string, clientAddr *net.UDPAddr, han
 			continue
 		}
 
-		// 检查是否来自目标客户端
-		if !receivedAddr.IP.Equal(clientAddr.IP) {
+		// 检查是否来自目标客户端（re-INVITE 后按新地址过滤）
+		if !receivedAddr.IP.Equal(handler.rtpTarget().IP) {
 			continue
 		}
 
@@ -302,11 +409,27 @@ func (as *SipServer) receiveRTPForAI(callID string, clientAddr *net.UDPAddr, han
 			continue
 		}
 
-		// 只处理 PCMU (payload type 0)
+		// 对端静音抑制留下的空隙按舒适噪声补齐，录音和 VAD 看到连续的音频
+		var gap []byte
+		switch packet.PayloadType {
+		case 0: // PCMU
+			gap = filler.audio(packet.Timestamp, len(packet.Payload))
+		case comfortNoisePayloadType:
+			gap = filler.comfortNoise(packet.Timestamp, packet.Payload)
+		default:
+			continue
+		}
+		for _, frame := range splitPCMUFrames(gap) {
+			handler.publishMedia(frame)
+			handler.ProcessAudioPacket(frame)
+		}
 		if packet.PayloadType != 0 {
 			continue
 		}
 
+		// 转发给外部媒体流
+		handler.publishMedia(packet.Payload)
+
 		// 转发给 handler
 		handler.ProcessAudioPacket(packet.Payload)
 	}
@@ -337,6 +460,7 @@ func (as *SipServer) stopAIVoiceSession(callID string) {
 		}
 
 		handler.Stop()
+		handler.dropCheckpoint()
 		logrus.WithField("call_id", callID).Info("✅ AI 语音会话已停止")
 	}
 }
@@ -347,6 +471,10 @@ func (as *SipServer) saveAIRecording(callID string, pcmuAudio []byte, handler *V
 		logrus.WithField("call_id", callID).Warn("数据库未初始化，无法保存录音")
 		return
 	}
+	if !as.recordingAllowed(callID) {
+		logrus.WithField("call_id", callID).Info("未获得录音同意，不保存AI通话录音")
+		return
+	}
 
 	// 创建录音目录
 	recordDir := "uploads/audio"
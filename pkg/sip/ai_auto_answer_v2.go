@@ -32,7 +32,7 @@ func (as *SipServer) handleAIAutoAnswerV2(req *sip.Request, tx sip.ServerTransac
 
 	// 2. 生成 SDP 响应
 	serverIP := getServerIPFromRequest(req)
-	sdp := generateSDP(serverIP, as.RPTPort)
+	sdp := generateSDP(serverIP, as.RPTPort, sdpOffersComfortNoise(sdpBody))
 	sdpBytes := []byte(sdp)
 
 	// 3. 发送 180 Ringing（如果配置了延迟）
@@ -119,6 +119,7 @@ func (as *SipServer) handleAIAutoAnswerV2(req *sip.Request, tx sip.ServerTransac
 			LocalRTPAddr:  localRTPAddr,
 			RemoteRTPAddr: clientRTPAddr,
 			StartTime:     now,
+			Region:        models.LocalRegion(),
 		}
 
 		if err := as.db.Create(sipCall).Error; err != nil {
@@ -0,0 +1,378 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	checkpointHeartbeatInterval = 10 * time.Second
+	// checkpointStaleAfter 其他实例的检查点心跳超过该时长视为实例已宕机
+	checkpointStaleAfter = 30 * time.Second
+	// checkpointResumeWindow 中断超过该时长的通话不再恢复，直接挂断
+	checkpointResumeWindow = 2 * time.Minute
+	// maxCheckpointResumes 反复恢复失败的通话直接挂断
+	maxCheckpointResumes = 2
+)
+
+// aiDialog SIP 对话标识，恢复失败时用于挂断通话
+type aiDialog struct {
+	LocalURI     string
+	LocalTag     string
+	RemoteURI    string
+	RemoteTag    string
+	RemoteTarget string
+}
+
+// dialogFromInvite 从 INVITE 和本端的 200 OK 中提取对话标识
+func dialogFromInvite(req *sip.Request, res *sip.Response) aiDialog {
+	var d aiDialog
+	if to := res.To(); to != nil {
+		d.LocalURI = to.Address.String()
+		if to.Params != nil {
+			d.LocalTag, _ = to.Params.Get("tag")
+		}
+	}
+	if from := req.From(); from != nil {
+		d.RemoteURI = from.Address.String()
+		d.RemoteTarget = d.RemoteURI
+		if from.Params != nil {
+			d.RemoteTag, _ = from.Params.Get("tag")
+		}
+	}
+	if contact := req.Contact(); contact != nil {
+		d.RemoteTarget = contact.Address.String()
+	}
+	return d
+}
+
+// dialogFromOutgoing 从本端发出的 INVITE 和对端的 200 OK 中提取对话标识
+func dialogFromOutgoing(req *sip.Request, res *sip.Response) aiDialog {
+	var d aiDialog
+	if from := req.From(); from != nil {
+		d.LocalURI = from.Address.String()
+		if from.Params != nil {
+			d.LocalTag, _ = from.Params.Get("tag")
+		}
+	}
+	if to := res.To(); to != nil {
+		d.RemoteURI = to.Address.String()
+		d.RemoteTarget = d.RemoteURI
+		if to.Params != nil {
+			d.RemoteTag, _ = to.Params.Get("tag")
+		}
+	}
+	if contact := res.Contact(); contact != nil {
+		d.RemoteTarget = contact.Address.String()
+	}
+	return d
+}
+
+// checkpointInstance 当前实例标识，SIP_INSTANCE_ID 未设置时使用主机名
+func checkpointInstance() string {
+	if id := os.Getenv("SIP_INSTANCE_ID"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "sip-server"
+	}
+	return host
+}
+
+// newAICallCheckpoint 为新接通的 AI 通话创建检查点
+func (as *SipServer) newAICallCheckpoint(callID string, clientRTPAddr *net.UDPAddr, sipUser *models.SipUser, assistant *models.Assistant) *models.AICallCheckpoint {
+	cp := &models.AICallCheckpoint{
+		CallID:        callID,
+		Instance:      as.instance,
+		SipUserID:     sipUser.ID,
+		AssistantID:   assistant.ID,
+		ClientRTPAddr: clientRTPAddr.String(),
+		State:         models.AICallStateListening,
+	}
+	as.aiSessionMutex.RLock()
+	if info, ok := as.aiSessionInfo[callID]; ok && info != nil {
+		cp.LocalURI, cp.LocalTag = info.Dialog.LocalURI, info.Dialog.LocalTag
+		cp.RemoteURI, cp.RemoteTag = info.Dialog.RemoteURI, info.Dialog.RemoteTag
+		cp.RemoteTarget = info.Dialog.RemoteTarget
+	}
+	as.aiSessionMutex.RUnlock()
+	return cp
+}
+
+// runCheckpointLoop 刷新本实例 AI 通话的心跳，并接管重启前遗留或对端宕机留下的通话
+func (as *SipServer) runCheckpointLoop(ctx context.Context) {
+	as.recoverOrphanedCalls()
+
+	ticker := time.NewTicker(checkpointHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			as.touchCheckpoints()
+			as.recoverOrphanedCalls()
+		}
+	}
+}
+
+func (as *SipServer) activeAICalls() []string {
+	as.voiceHandlersMu.RLock()
+	defer as.voiceHandlersMu.RUnlock()
+	callIDs := make([]string, 0, len(as.voiceHandlers))
+	for callID := range as.voiceHandlers {
+		callIDs = append(callIDs, callID)
+	}
+	return callIDs
+}
+
+func (as *SipServer) touchCheckpoints() {
+	if as.db == nil || !as.mediaActive() {
+		return
+	}
+	if err := models.TouchAICallCheckpoints(as.db, as.instance, as.activeAICalls()); err != nil {
+		logrus.WithError(err).Warn("Failed to refresh AI call checkpoints")
+	}
+}
+
+func (as *SipServer) recoverOrphanedCalls() {
+	// 备用实例不处理媒体，由活动实例接管
+	if as.db == nil || !as.mediaActive() {
+		return
+	}
+	orphans, err := models.ListOrphanedAICallCheckpoints(as.db, as.instance, as.activeAICalls(), time.Now().Add(-checkpointStaleAfter))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list orphaned AI call checkpoints")
+		return
+	}
+	for i := range orphans {
+		as.recoverAICall(&orphans[i])
+	}
+}
+
+// callRecovery 接管通话的结果
+type callRecovery int
+
+const (
+	callNotClaimed callRecovery = iota // 已被其他实例接管
+	callResumed
+	callClosed
+)
+
+// recoverAICall 接管一个无人持有的 AI 通话：中断不久的恢复会话，否则挂断并补全通话记录
+func (as *SipServer) recoverAICall(cp *models.AICallCheckpoint) callRecovery {
+	lastSeen := cp.Heartbeat
+	previous := cp.Instance
+	claimed, err := models.ClaimAICallCheckpoint(as.db, cp, as.instance)
+	if err != nil || !claimed {
+		return callNotClaimed
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":  cp.CallID,
+		"instance": previous,
+		"state":    cp.State,
+		"turns":    cp.Turns,
+	})
+
+	switch {
+	case time.Since(lastSeen) > checkpointResumeWindow:
+		as.closeOrphanedCall(cp, lastSeen, "AI session interrupted too long ago to resume")
+		return callClosed
+	case cp.Resumes >= maxCheckpointResumes:
+		as.closeOrphanedCall(cp, lastSeen, "AI session could not be resumed")
+		return callClosed
+	case cp.State == models.AICallStateMessage:
+		// 留言录音在中断时已丢失，无法续录
+		as.closeOrphanedCall(cp, lastSeen, "AI session interrupted while recording a message")
+		return callClosed
+	}
+
+	var sipUser models.SipUser
+	var assistant models.Assistant
+	if err := as.db.First(&assistant, cp.AssistantID).Error; err != nil {
+		as.closeOrphanedCall(cp, lastSeen, "assistant of the interrupted AI session not found")
+		return callClosed
+	}
+	if cp.SipUserID == 0 {
+		// DID 呼入没有 SIP 账号，按通话记录中的 DID 重建代接配置
+		did, err := as.checkpointDID(cp.CallID)
+		if err != nil {
+			as.closeOrphanedCall(cp, lastSeen, "DID of the interrupted AI session not found")
+			return callClosed
+		}
+		sipUser = *didSipUser(did, &assistant)
+	} else if err := as.db.First(&sipUser, cp.SipUserID).Error; err != nil {
+		as.closeOrphanedCall(cp, lastSeen, "SIP user of the interrupted AI session not found")
+		return callClosed
+	}
+	clientAddr, err := net.ResolveUDPAddr("udp", cp.ClientRTPAddr)
+	if err != nil {
+		as.closeOrphanedCall(cp, lastSeen, "invalid RTP address in AI session checkpoint")
+		return callClosed
+	}
+
+	as.aiSessionMutex.Lock()
+	as.aiSessionInfo[cp.CallID] = &AISessionInfo{
+		SipUser:   &sipUser,
+		Assistant: &assistant,
+		Dialog: aiDialog{
+			LocalURI:     cp.LocalURI,
+			LocalTag:     cp.LocalTag,
+			RemoteURI:    cp.RemoteURI,
+			RemoteTag:    cp.RemoteTag,
+			RemoteTarget: cp.RemoteTarget,
+		},
+	}
+	as.aiSessionMutex.Unlock()
+
+	cp.Resumes++
+	if err := as.startAIVoiceSession(cp.CallID, clientAddr, &sipUser, &assistant, cp); err != nil {
+		log.WithError(err).Warn("Failed to resume AI session from checkpoint")
+		as.aiSessionMutex.Lock()
+		delete(as.aiSessionInfo, cp.CallID)
+		as.aiSessionMutex.Unlock()
+		as.closeOrphanedCall(cp, lastSeen, "AI session could not be resumed: "+err.Error())
+		return callClosed
+	}
+	if cp.SipUserID != 0 {
+		as.trackPresenceCall(cp.CallID, sipUser.Username)
+	}
+	log.Info("Resumed AI session from checkpoint")
+	return callResumed
+}
+
+// checkpointDID 查找 DID 呼入通话匹配的 DID
+func (as *SipServer) checkpointDID(callID string) (*models.DIDNumber, error) {
+	var call models.SipCall
+	if err := as.db.Where("call_id = ?", callID).First(&call).Error; err != nil {
+		return nil, err
+	}
+	if call.DIDNumberID == nil {
+		return nil, fmt.Errorf("call %s was not routed by DID", callID)
+	}
+	var did models.DIDNumber
+	if err := as.db.First(&did, *call.DIDNumberID).Error; err != nil {
+		return nil, err
+	}
+	return &did, nil
+}
+
+// closeOrphanedCall 挂断无法恢复的通话，通话记录以最后一次心跳为结束时间
+func (as *SipServer) closeOrphanedCall(cp *models.AICallCheckpoint, lastSeen time.Time, reason string) {
+	log := logrus.WithFields(logrus.Fields{
+		"call_id": cp.CallID,
+		"reason":  reason,
+	})
+	if err := as.sendCheckpointBye(cp); err != nil {
+		log.WithError(err).Warn("Failed to send BYE for interrupted AI call")
+	}
+	if err := models.EndInterruptedSipCall(as.db, cp, lastSeen, reason); err != nil {
+		log.WithError(err).Error("Failed to close record of interrupted AI call")
+	}
+	if err := models.DeleteAICallCheckpoint(as.db, cp.CallID); err != nil {
+		log.WithError(err).Warn("Failed to delete AI call checkpoint")
+	}
+	as.releasePresenceCall(cp.CallID)
+	log.Info("Closed interrupted AI call")
+}
+
+// sendCheckpointBye 以检查点中的对话标识向对方发送 BYE
+func (as *SipServer) sendCheckpointBye(cp *models.AICallCheckpoint) error {
+	if cp.RemoteTarget == "" || cp.LocalURI == "" || cp.RemoteURI == "" {
+		return fmt.Errorf("dialog of call %s was not checkpointed", cp.CallID)
+	}
+	return as.sendDialogBye(cp.CallID, aiDialog{
+		LocalURI:     cp.LocalURI,
+		LocalTag:     cp.LocalTag,
+		RemoteURI:    cp.RemoteURI,
+		RemoteTag:    cp.RemoteTag,
+		RemoteTarget: cp.RemoteTarget,
+	})
+}
+
+// sendDialogBye 以本端应答时的对话标识向对方发送 BYE
+func (as *SipServer) sendDialogBye(callID string, d aiDialog) error {
+	var target, local, remote sip.Uri
+	if err := sip.ParseUri(d.RemoteTarget, &target); err != nil {
+		return fmt.Errorf("invalid remote target: %w", err)
+	}
+	if err := sip.ParseUri(d.LocalURI, &local); err != nil {
+		return fmt.Errorf("invalid local URI: %w", err)
+	}
+	if err := sip.ParseUri(d.RemoteURI, &remote); err != nil {
+		return fmt.Errorf("invalid remote URI: %w", err)
+	}
+
+	byeReq := sip.NewRequest(sip.BYE, &target)
+	from := &sip.FromHeader{Address: local, Params: sip.NewParams()}
+	if d.LocalTag != "" {
+		from.Params.Add("tag", d.LocalTag)
+	}
+	byeReq.AppendHeader(from)
+	to := &sip.ToHeader{Address: remote, Params: sip.NewParams()}
+	if d.RemoteTag != "" {
+		to.Params.Add("tag", d.RemoteTag)
+	}
+	byeReq.AppendHeader(to)
+	callIDHeader := sip.CallIDHeader(callID)
+	byeReq.AppendHeader(&callIDHeader)
+	// 本端在该对话中发出的第一个请求
+	byeReq.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: sip.BYE})
+	cl := sip.ContentLengthHeader(0)
+	byeReq.AppendHeader(&cl)
+
+	return as.client.WriteRequest(byeReq)
+}
+
+// enableCheckpoint 开始为会话保存检查点
+func (h *VoiceConversationHandler) enableCheckpoint(db *gorm.DB, cp *models.AICallCheckpoint) {
+	h.checkpointMu.Lock()
+	h.db = db
+	h.checkpoint = cp
+	h.checkpointMu.Unlock()
+	h.saveCheckpoint(func(*models.AICallCheckpoint) {})
+}
+
+// restoreCheckpoint 恢复中断前的对话状态
+func (h *VoiceConversationHandler) restoreCheckpoint(cp *models.AICallCheckpoint) {
+	h.resumed = true
+	h.isFirstMessage = false
+	h.conversationCount = cp.Turns
+	h.pendingTurn = cp.PendingTurn
+}
+
+// saveCheckpoint 修改并保存检查点，未启用检查点时不做任何事
+func (h *VoiceConversationHandler) saveCheckpoint(update func(cp *models.AICallCheckpoint)) {
+	h.checkpointMu.Lock()
+	defer h.checkpointMu.Unlock()
+	if h.db == nil || h.checkpoint == nil {
+		return
+	}
+	update(h.checkpoint)
+	if err := models.SaveAICallCheckpoint(h.db, h.checkpoint); err != nil {
+		logrus.WithError(err).WithField("call_id", h.callID).Warn("Failed to save AI call checkpoint")
+	}
+}
+
+// dropCheckpoint 通话正常结束，删除检查点
+func (h *VoiceConversationHandler) dropCheckpoint() {
+	h.checkpointMu.Lock()
+	defer h.checkpointMu.Unlock()
+	if h.db == nil || h.checkpoint == nil {
+		return
+	}
+	if err := models.DeleteAICallCheckpoint(h.db, h.callID); err != nil {
+		logrus.WithError(err).WithField("call_id", h.callID).Warn("Failed to delete AI call checkpoint")
+	}
+	h.checkpoint = nil
+}
@@ -0,0 +1,194 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/events"
+	"github.com/code-100-precent/LingEcho/pkg/metrics"
+	"github.com/code-100-precent/LingEcho/pkg/sessionlimit"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+// busyRetryAfter Retry-After of the 486 sent when an assistant is saturated
+const busyRetryAfter = 30 * time.Second
+
+// busyPromptFile WAV played to callers before hanging up when every session of
+// the assistant is busy, SIP_BUSY_PROMPT; a 486 Busy Here is sent when unset
+func busyPromptFile() string {
+	return os.Getenv("SIP_BUSY_PROMPT")
+}
+
+// admitAISession takes a session slot of the assistant for an inbound AI call.
+// While all slots are busy the caller hears ringback (182 Queued) until a slot
+// frees up; a full queue or a queue timeout gets the busy prompt instead.
+// Assistants offering callbacks let callers who waited long enough hang up
+// and be called back when their turn comes.
+// It reports whether the call may be answered, the INVITE has been answered otherwise.
+func (as *SipServer) admitAISession(req *sip.Request, tx sip.ServerTransaction, clientRTPAddr string, sipUser *models.SipUser, assistant *models.Assistant) bool {
+	callID := req.CallID().Value()
+	key := models.AssistantSessionKey(assistant.ID)
+	limit := assistant.SessionLimit()
+	limiter := sessionlimit.Default()
+
+	queued := !limit.Unlimited() && limiter.Stats(key).Active >= limit.MaxConcurrent && limit.QueueSize > 0
+	if queued {
+		res := sip.NewResponseFromRequest(req, sip.StatusQueued, "Queued", nil)
+		if err := tx.Respond(res); err != nil {
+			logrus.WithError(err).WithField("call_id", callID).Warn("Failed to send 182 Queued")
+		}
+		publishCallEvent(events.CallQueued, callID, map[string]interface{}{"queue": key})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	as.aiLimitMutex.Lock()
+	as.queuedCalls[callID] = cancel
+	as.aiLimitMutex.Unlock()
+
+	var offer *callbackOffer
+	if queued {
+		offer = as.offerCallback(req, tx, clientRTPAddr, assistant, sipUser)
+	}
+	slots := make(chan acquiredSlot, 1)
+	go func() {
+		ticket, err := limiter.AcquireHeld(ctx, key, limit, offer.holdChan())
+		slots <- acquiredSlot{ticket: ticket, err: err}
+	}()
+	var slot acquiredSlot
+	received := false
+	select {
+	case slot = <-slots:
+		received = true
+	case <-offer.acceptedChan():
+	}
+	if offer.close() {
+		// The caller hung up for a callback, the place in the queue is kept in the background
+		as.aiLimitMutex.Lock()
+		delete(as.queuedCalls, callID)
+		as.aiLimitMutex.Unlock()
+		if received {
+			slots <- slot
+		}
+		go as.holdCallback(offer, slots, cancel)
+		return false
+	}
+	ticket, err := slot.ticket, slot.err
+
+	as.aiLimitMutex.Lock()
+	delete(as.queuedCalls, callID)
+	if err == nil {
+		as.aiTickets[callID] = ticket
+	}
+	as.aiLimitMutex.Unlock()
+	cancel()
+
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":      callID,
+		"assistant_id": assistant.ID,
+	})
+	switch {
+	case err == nil:
+		recordAISessionAdmission(assistant.ID, "admitted", ticket.Waited)
+		if ticket.Waited > 0 {
+			log.WithField("waited", ticket.Waited).Info("AI session admitted after queueing")
+		}
+		return true
+	case errors.Is(err, context.Canceled):
+		recordAISessionAdmission(assistant.ID, "abandoned", 0)
+		log.Info("Caller hung up while queued for the assistant")
+		res := sip.NewResponseFromRequest(req, sip.StatusRequestTerminated, "Request Terminated", nil)
+		if err := tx.Respond(res); err != nil {
+			log.WithError(err).Warn("Failed to send 487 Request Terminated")
+		}
+		return false
+	default:
+		outcome := "rejected"
+		if errors.Is(err, sessionlimit.ErrWaitTimeout) {
+			outcome = "timeout"
+		}
+		recordAISessionAdmission(assistant.ID, outcome, 0)
+		log.WithError(err).Warn("All sessions of the assistant are busy")
+		as.rejectAssistantBusy(req, tx, clientRTPAddr)
+		return false
+	}
+}
+
+// releaseAISession frees the assistant slot held by a call, no-op for other calls
+func (as *SipServer) releaseAISession(callID string) {
+	as.aiLimitMutex.Lock()
+	ticket := as.aiTickets[callID]
+	delete(as.aiTickets, callID)
+	as.aiLimitMutex.Unlock()
+	ticket.Release()
+}
+
+// abortQueuedCall stops waiting for a slot when the caller cancels the INVITE
+func (as *SipServer) abortQueuedCall(callID string) {
+	as.aiLimitMutex.Lock()
+	if cancel, ok := as.queuedCalls[callID]; ok {
+		cancel()
+	}
+	as.aiLimitMutex.Unlock()
+}
+
+// rejectAssistantBusy answers the call, plays the busy prompt and hangs up, or
+// refuses it with 486 Busy Here when no prompt is configured
+func (as *SipServer) rejectAssistantBusy(req *sip.Request, tx sip.ServerTransaction, clientRTPAddr string) {
+	callID := req.CallID().Value()
+	prompt := busyPromptFile()
+	if prompt != "" {
+		if _, err := os.Stat(prompt); err != nil {
+			logrus.WithError(err).WithField("call_id", callID).Warn("Busy prompt missing, rejecting with 486")
+			prompt = ""
+		}
+	}
+	if prompt == "" {
+		res := sip.NewResponseFromRequest(req, sip.StatusBusyHere, "Busy Here", nil)
+		res.AppendHeader(sip.NewHeader("Retry-After", strconv.Itoa(int(busyRetryAfter.Seconds()))))
+		if err := tx.Respond(res); err != nil {
+			logrus.WithError(err).WithField("call_id", callID).Error("Failed to send busy response")
+		}
+		return
+	}
+
+	serverIP := getServerIPFromRequest(req)
+	sdpBytes := []byte(generateSDP(serverIP, as.RPTPort, sdpOffersComfortNoise(string(req.Body()))))
+	res := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", sdpBytes)
+	cl := sip.ContentLengthHeader(len(sdpBytes))
+	res.AppendHeader(&cl)
+	contentType := sip.ContentTypeHeader("application/sdp")
+	res.AppendHeader(&contentType)
+	res.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Host: serverIP, Port: as.SipPort}})
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).WithField("call_id", callID).Error("Failed to answer for the busy prompt")
+		return
+	}
+
+	dialog := dialogFromInvite(req, res)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		as.sendAudioFromFileWithContext(clientRTPAddr, prompt, 160, ctx)
+		if err := as.sendDialogBye(callID, dialog); err != nil {
+			logrus.WithError(err).WithField("call_id", callID).Warn("Failed to hang up after the busy prompt")
+		}
+	}()
+}
+
+// recordAISessionAdmission exports the admission outcome and queue wait of an assistant
+func recordAISessionAdmission(assistantID int64, outcome string, waited time.Duration) {
+	m := metrics.NewMetrics()
+	label := strconv.FormatInt(assistantID, 10)
+	m.RecordBusinessOperation("assistant_session", outcome, "sip")
+	if outcome == "admitted" {
+		m.RecordBusinessDuration("assistant_session_wait", label, waited)
+	}
+	stats := sessionlimit.Default().Stats(models.AssistantSessionKey(assistantID))
+	m.SetBusinessMetric("assistant_sessions_active", label, float64(stats.Active))
+	m.SetBusinessMetric("assistant_sessions_waiting", label, float64(stats.Waiting))
+}
@@ -0,0 +1,52 @@
+package sip
+
+import (
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/events"
+	"github.com/emiago/sipgo/sip"
+)
+
+// callEventSource source of the call events published on the event bus
+const callEventSource = "sip"
+
+// publishCallEvent publishes a call lifecycle event, data may be nil
+func publishCallEvent(eventType, callID string, data map[string]interface{}) {
+	if data == nil {
+		data = make(map[string]interface{}, 1)
+	}
+	data["callId"] = callID
+	events.PublishEvent(eventType, data, callEventSource)
+}
+
+// publishInboundOffered announces a new inbound call
+func publishInboundOffered(req *sip.Request) {
+	data := map[string]interface{}{"direction": string(models.SipCallDirectionInbound)}
+	if from := req.From(); from != nil {
+		data["from"] = from.Address.User
+	}
+	if to := req.To(); to != nil {
+		data["to"] = to.Address.User
+	}
+	publishCallEvent(events.CallOffered, req.CallID().Value(), data)
+}
+
+// publishCallEnded announces the end of a call
+func publishCallEnded(callID, reason string) {
+	publishCallEvent(events.CallEnded, callID, map[string]interface{}{"reason": reason})
+}
+
+// publishCallStatus maps a SipCall status update to the call events
+func publishCallStatus(callID, status string) {
+	switch models.SipCallStatus(status) {
+	case models.SipCallStatusRinging:
+		publishCallEvent(events.CallRinging, callID, nil)
+	case models.SipCallStatusAnswered:
+		publishCallEvent(events.CallAnswered, callID, nil)
+	case models.SipCallStatusEnded:
+		publishCallEnded(callID, events.CallEndHangup)
+	case models.SipCallStatusCancelled:
+		publishCallEnded(callID, events.CallEndCancelled)
+	case models.SipCallStatusFailed:
+		publishCallEnded(callID, events.CallEndFailed)
+	}
+}
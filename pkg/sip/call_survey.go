@@ -0,0 +1,233 @@
+package sip
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// pendingSurvey survey waiting for the rating key of a call
+type pendingSurvey struct {
+	digits chan string
+	cancel context.CancelFunc
+}
+
+// surveyTarget call receiving the post-call satisfaction survey
+type surveyTarget struct {
+	callID      string
+	channel     string
+	settings    *models.CallSurveySettings
+	groupID     *uint
+	assistantID *int64
+	agentUserID *uint
+}
+
+// surveySettingsFor returns the survey settings of the call owner when a
+// survey applies to the channel, nil otherwise
+func (as *SipServer) surveySettingsFor(ownerID *uint, channel string) *models.CallSurveySettings {
+	if as.db == nil || ownerID == nil {
+		return nil
+	}
+	settings, err := models.GetCallSurveySettings(as.db, *ownerID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", *ownerID).Warn("Failed to load call survey settings")
+		return nil
+	}
+	if settings == nil || !settings.AppliesTo(channel) {
+		return nil
+	}
+	return settings
+}
+
+// runCallSurvey plays the survey prompt and waits for the rating key. Keys
+// pressed while the prompt plays count, invalid keys are ignored until the
+// timeout. The result is stored with the call and returned.
+func (as *SipServer) runCallSurvey(ctx context.Context, t surveyTarget, play func(ctx context.Context) error) *models.CallSurvey {
+	survey := &models.CallSurvey{
+		CallID:      t.callID,
+		UserID:      t.settings.UserID,
+		GroupID:     t.groupID,
+		AssistantID: t.assistantID,
+		AgentUserID: t.agentUserID,
+		Channel:     t.channel,
+		Scale:       t.settings.Scale,
+		PromptedAt:  time.Now(),
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"call_id": t.callID,
+		"channel": t.channel,
+	})
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dtmf := as.beginSurvey(t.callID, cancel)
+	defer as.endSurvey(t.callID)
+
+	log.Info("Playing call survey prompt")
+	if err := play(ctx); err != nil {
+		if ctx.Err() != nil {
+			survey.Status = models.CallSurveyHangup
+		} else {
+			log.WithError(err).Warn("Call survey prompt could not be played")
+			survey.Status = models.CallSurveyUnavailable
+		}
+		as.saveSurvey(survey)
+		return survey
+	}
+
+	timer := time.NewTimer(time.Duration(t.settings.TimeoutSeconds) * time.Second)
+	defer timer.Stop()
+	for survey.Status == "" {
+		select {
+		case digit := <-dtmf:
+			if score := t.settings.Score(digit); score > 0 {
+				now := time.Now()
+				survey.Status = models.CallSurveyAnswered
+				survey.Digit, survey.Score, survey.AnsweredAt = digit, score, &now
+			}
+		case <-timer.C:
+			survey.Status = models.CallSurveyNoResponse
+		case <-ctx.Done():
+			survey.Status = models.CallSurveyHangup
+		}
+	}
+	as.saveSurvey(survey)
+	return survey
+}
+
+// attachCallSurvey enables the post-call survey of an AI call when the owner
+// of the answering scheme has surveys on for AI calls
+func (as *SipServer) attachCallSurvey(h *VoiceConversationHandler, sipUser *models.SipUser, assistant *models.Assistant) {
+	settings := as.surveySettingsFor(sipUser.UserID, models.CallSurveyChannelAI)
+	if settings == nil {
+		return
+	}
+	assistantID := assistant.ID
+	target := surveyTarget{
+		callID:      h.callID,
+		channel:     models.CallSurveyChannelAI,
+		settings:    settings,
+		groupID:     sipUser.GroupID,
+		assistantID: &assistantID,
+	}
+	h.survey = func(ctx context.Context) {
+		survey := as.runCallSurvey(ctx, target, func(ctx context.Context) error {
+			if settings.PromptFile != "" {
+				return as.playSurveyFile(ctx, h.rtpTarget().String(), settings.PromptFile)
+			}
+			return h.speak(ctx, settings.PromptText())
+		})
+		if survey.Status == models.CallSurveyAnswered {
+			if err := h.speak(ctx, settings.ThanksText()); err != nil {
+				logrus.WithError(err).WithField("call_id", h.callID).Debug("Survey thanks not played")
+			}
+		}
+	}
+}
+
+// playSurveyFile plays a WAV prompt, the playback stops when ctx is cancelled
+func (as *SipServer) playSurveyFile(ctx context.Context, rtpAddr, filename string) error {
+	if _, err := os.Stat(filename); err != nil {
+		return err
+	}
+	as.sendAudioFromFileWithContext(rtpAddr, filename, 160, ctx)
+	return ctx.Err()
+}
+
+func (as *SipServer) saveSurvey(survey *models.CallSurvey) {
+	logrus.WithFields(logrus.Fields{
+		"call_id": survey.CallID,
+		"status":  survey.Status,
+		"score":   survey.Score,
+	}).Info("Call survey finished")
+	if err := models.SaveCallSurvey(as.db, survey); err != nil {
+		logrus.WithError(err).WithField("call_id", survey.CallID).Error("Failed to save call survey")
+	}
+}
+
+// beginSurvey routes the DTMF digits of the call to the survey until endSurvey
+func (as *SipServer) beginSurvey(callID string, cancel context.CancelFunc) chan string {
+	ch := make(chan string, 4)
+	as.surveyMutex.Lock()
+	as.pendingSurveys[callID] = &pendingSurvey{digits: ch, cancel: cancel}
+	as.surveyMutex.Unlock()
+	return ch
+}
+
+func (as *SipServer) endSurvey(callID string) {
+	as.surveyMutex.Lock()
+	delete(as.pendingSurveys, callID)
+	as.surveyMutex.Unlock()
+}
+
+// abortSurvey stops the survey of a call that ended
+func (as *SipServer) abortSurvey(callID string) {
+	as.surveyMutex.Lock()
+	if ps, ok := as.pendingSurveys[callID]; ok {
+		ps.cancel()
+	}
+	as.surveyMutex.Unlock()
+}
+
+// deliverSurveyDigit hands a DTMF digit to a running survey, reports whether
+// the call is being surveyed
+func (as *SipServer) deliverSurveyDigit(callID, digit string) bool {
+	as.surveyMutex.Lock()
+	defer as.surveyMutex.Unlock()
+	ps, ok := as.pendingSurveys[callID]
+	if !ok {
+		return false
+	}
+	select {
+	case ps.digits <- digit:
+	default:
+	}
+	return true
+}
+
+// SurveyAndHangupOutgoingCall surveys the callee of an answered outgoing call
+// once the agent hangs up, then sends the BYE. The survey runs in the
+// background and surveying reports whether it was started; without applicable
+// settings the call is hung up right away.
+func (as *SipServer) SurveyAndHangupOutgoingCall(callID string) (surveying bool, err error) {
+	as.outgoingMutex.Lock()
+	session, exists := as.outgoingSessions[callID]
+	var status, rtpAddr string
+	if exists {
+		status, rtpAddr = session.Status, session.RemoteRTPAddr
+	}
+	as.outgoingMutex.Unlock()
+	if !exists || status != "answered" || as.db == nil {
+		return false, as.HangupOutgoingCall(callID)
+	}
+
+	call, err := models.GetSipCallByCallID(as.db, callID)
+	if err != nil {
+		return false, as.HangupOutgoingCall(callID)
+	}
+	// Agent calls have no TTS, the prompt must be a recording
+	settings := as.surveySettingsFor(call.UserID, models.CallSurveyChannelAgent)
+	if settings == nil || settings.PromptFile == "" {
+		return false, as.HangupOutgoingCall(callID)
+	}
+
+	go func() {
+		// The survey stops through abortSurvey when the callee hangs up
+		as.runCallSurvey(context.Background(), surveyTarget{
+			callID:      callID,
+			channel:     models.CallSurveyChannelAgent,
+			settings:    settings,
+			groupID:     call.GroupID,
+			agentUserID: call.UserID,
+		}, func(ctx context.Context) error {
+			return as.playSurveyFile(ctx, rtpAddr, settings.PromptFile)
+		})
+		if err := as.HangupOutgoingCall(callID); err != nil {
+			logrus.WithError(err).WithField("call_id", callID).Warn("Failed to hang up surveyed call")
+		}
+	}()
+	return true, nil
+}
@@ -0,0 +1,43 @@
+package sip
+
+import (
+	"context"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// enrichInboundCall 呼入接通前按主叫号码查询助手配置的 CRM，返回追加到系统提示词的客户信息，
+// 以及替换开场白后的 SipUser。查询失败或未配置时原样返回，不影响通话
+func (as *SipServer) enrichInboundCall(callID string, sipUser *models.SipUser, assistant *models.Assistant) (string, *models.SipUser) {
+	if as.db == nil || as.isOutgoingCall(callID) {
+		return "", sipUser
+	}
+	numbers := as.inboundCallNumbers(callID)
+	if len(numbers) < 2 {
+		return "", sipUser
+	}
+
+	enrichment, err := models.EnrichInboundCall(context.Background(), as.db, assistant, callID, numbers[0], numbers[1])
+	if err != nil {
+		logrus.WithError(err).WithField("call_id", callID).Warn("⚠️  来电客户信息查询记录保存失败")
+	}
+	if enrichment == nil {
+		return "", sipUser
+	}
+	logrus.WithFields(logrus.Fields{
+		"call_id":     callID,
+		"caller":      numbers[0],
+		"status":      enrichment.Log.Status,
+		"fields":      enrichment.Log.Fields,
+		"duration_ms": enrichment.Log.DurationMs,
+		"greeting":    enrichment.Greeting != "",
+	}).Info("📇 来电客户信息查询完成")
+
+	if enrichment.Greeting != "" {
+		personalized := *sipUser
+		personalized.OpeningMessage = enrichment.Greeting
+		sipUser = &personalized
+	}
+	return enrichment.Prompt, sipUser
+}
@@ -0,0 +1,243 @@
+package sip
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	workflowdef "github.com/code-100-precent/LingEcho/internal/workflow"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+// ivrWorkflowTimeout IVR 工作流需在该时长内给出去向，否则按兜底配置处理
+const ivrWorkflowTimeout = 5 * time.Second
+
+// IVR 工作流通过这些输出决定通话去向
+const (
+	ivrOutputTransferTo  = "transferTo"  // 转接的坐席 SIP 用户名或号码
+	ivrOutputAssistantID = "assistantId" // 接听的 AI 助手
+)
+
+// didCall 匹配到 DID 并由 AI 助手接听的呼入通话
+type didCall struct {
+	did       *models.DIDNumber
+	sipUser   *models.SipUser
+	assistant *models.Assistant
+}
+
+// routeDID 被叫号码匹配 DID 时按其去向处理 INVITE。
+// 转坐席和拒接时直接应答并返回 handled；由 AI 接听时返回 didCall，由调用方继续接通；
+// 未匹配 DID 时两者都为空，按代接方案处理。
+func (as *SipServer) routeDID(req *sip.Request, tx sip.ServerTransaction, clientRTPAddr string) (*didCall, bool) {
+	if as.db == nil {
+		return nil, false
+	}
+	to := req.To()
+	if to == nil || to.Address.User == "" {
+		return nil, false
+	}
+	did, err := models.MatchDIDNumber(as.db, to.Address.User)
+	if err != nil {
+		logrus.WithError(err).WithField("to_username", to.Address.User).Warn("Failed to match DID")
+		return nil, false
+	}
+	if did == nil {
+		return nil, false
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"call_id": req.CallID().Value(),
+		"did":     did.Number,
+		"target":  did.TargetType,
+	})
+	log.Info("Routing inbound call by DID")
+
+	assistantID := did.AssistantID
+	switch did.TargetType {
+	case models.DIDTargetQueue:
+		for _, agent := range did.Agents() {
+			if as.isRegisteredUser(agent) && agentPresence(agent).Available() {
+				as.redirectDIDCall(req, tx, did, agent, clientRTPAddr)
+				return nil, true
+			}
+		}
+		log.Info("No queue agent available")
+	case models.DIDTargetIVR:
+		transferTo, ivrAssistant := as.runIVRWorkflow(req, did)
+		if transferTo != "" {
+			as.redirectDIDCall(req, tx, did, transferTo, clientRTPAddr)
+			return nil, true
+		}
+		if ivrAssistant != nil {
+			assistantID = ivrAssistant
+		}
+	}
+
+	// assistant 去向，或队列、IVR 无法转接时的兜底助手
+	if assistantID != nil && *assistantID != 0 {
+		var assistant models.Assistant
+		if err := as.db.First(&assistant, *assistantID).Error; err == nil {
+			return &didCall{did: did, sipUser: didSipUser(did, &assistant), assistant: &assistant}, false
+		}
+		log.WithField("assistant_id", *assistantID).Warn("Assistant of DID not found")
+	}
+
+	as.rejectDIDCall(req, tx, did, clientRTPAddr)
+	return nil, true
+}
+
+// didSipUser 为 DID 呼入构造代接配置，DID 没有对应的 SIP 账号
+func didSipUser(did *models.DIDNumber, assistant *models.Assistant) *models.SipUser {
+	assistantID := uint(assistant.ID)
+	userID := did.UserID
+	return &models.SipUser{
+		SchemeName:       did.Label,
+		Username:         did.Number,
+		UserID:           &userID,
+		GroupID:          did.GroupID,
+		AssistantID:      &assistantID,
+		AutoAnswer:       true,
+		OpeningMessage:   did.Greeting,
+		AIFreeResponse:   true,
+		RecordingEnabled: true,
+		RecordingMode:    models.RecordingModeFull,
+		Enabled:          true,
+		IsActive:         true,
+	}
+}
+
+// runIVRWorkflow 执行 DID 的 IVR 工作流，返回工作流输出的转接目标或助手
+func (as *SipServer) runIVRWorkflow(req *sip.Request, did *models.DIDNumber) (string, *int64) {
+	if did.WorkflowID == nil {
+		return "", nil
+	}
+	params := map[string]interface{}{
+		"callId":    req.CallID().Value(),
+		"didNumber": did.Number,
+		"didLabel":  did.Label,
+	}
+	if from := req.From(); from != nil {
+		params["caller"] = from.Address.User
+		params["callerUri"] = from.Address.String()
+	}
+
+	type result struct {
+		instance *models.WorkflowInstance
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		instance, err := workflowdef.NewWorkflowTriggerManager(as.db).TriggerWorkflow(*did.WorkflowID, params, "sip:did:"+did.Number)
+		done <- result{instance, err}
+	}()
+
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":     params["callId"],
+		"did":         did.Number,
+		"workflow_id": *did.WorkflowID,
+	})
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(ivrWorkflowTimeout):
+		log.Warn("IVR workflow did not finish in time")
+		return "", nil
+	}
+	if res.err != nil || res.instance == nil {
+		log.WithError(res.err).Warn("IVR workflow failed")
+		return "", nil
+	}
+
+	output := res.instance.ContextData
+	if v, ok := output[ivrOutputTransferTo]; ok && v != nil {
+		if transferTo := strings.TrimSpace(fmt.Sprint(v)); transferTo != "" {
+			return transferTo, nil
+		}
+	}
+	if v, ok := output[ivrOutputAssistantID]; ok {
+		if id, err := strconv.ParseInt(fmt.Sprint(v), 10, 64); err == nil && id > 0 {
+			return "", &id
+		}
+	}
+	return "", nil
+}
+
+// redirectDIDCall 以 302 将主叫重定向到坐席：已注册的坐席直接给出其 Contact，
+// 其他目标指向本服务器，由新的 INVITE 按号码重新路由
+func (as *SipServer) redirectDIDCall(req *sip.Request, tx sip.ServerTransaction, did *models.DIDNumber, target, clientRTPAddr string) {
+	as.registerMutex.RLock()
+	addr, registered := as.registeredUsers[target]
+	as.registerMutex.RUnlock()
+
+	uri := sip.Uri{User: target, Host: getServerIPFromRequest(req), Port: as.SipPort}
+	if registered {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			uri.Host = host
+			uri.Port, _ = strconv.Atoi(port)
+		}
+	}
+
+	res := sip.NewResponseFromRequest(req, sip.StatusMovedTemporarily, "Moved Temporarily", nil)
+	res.AppendHeader(&sip.ContactHeader{Address: uri})
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).Error("Failed to send redirect response")
+	}
+	logrus.WithFields(logrus.Fields{
+		"call_id": req.CallID().Value(),
+		"did":     did.Number,
+		"agent":   target,
+	}).Info("Redirected DID call")
+	as.recordDIDCall(req, did, models.DIDRouteAgent, clientRTPAddr, 0, "redirected to "+target)
+}
+
+// rejectDIDCall DID 没有可用去向时拒接
+func (as *SipServer) rejectDIDCall(req *sip.Request, tx sip.ServerTransaction, did *models.DIDNumber, clientRTPAddr string) {
+	res := sip.NewResponseFromRequest(req, sip.StatusTemporarilyUnavailable, "Temporarily Unavailable", nil)
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).Error("Failed to send unavailable response")
+	}
+	logrus.WithFields(logrus.Fields{
+		"call_id": req.CallID().Value(),
+		"did":     did.Number,
+	}).Info("No route for DID call, rejected")
+	as.recordDIDCall(req, did, models.DIDRouteRejected, clientRTPAddr, int(sip.StatusTemporarilyUnavailable), "no route for DID")
+}
+
+// recordDIDCall 为未由本服务器接通的 DID 呼入创建通话记录，用于 DID 统计
+func (as *SipServer) recordDIDCall(req *sip.Request, did *models.DIDNumber, route models.DIDRoute, clientRTPAddr string, errorCode int, message string) {
+	now := time.Now()
+	didID := did.ID
+	call := &models.SipCall{
+		CallID:        req.CallID().Value(),
+		Direction:     models.SipCallDirectionInbound,
+		Status:        models.SipCallStatusEnded,
+		RemoteRTPAddr: clientRTPAddr,
+		StartTime:     now,
+		EndTime:       &now,
+		UserID:        &did.UserID,
+		GroupID:       did.GroupID,
+		ErrorCode:     errorCode,
+		ErrorMessage:  message,
+		DIDNumberID:   &didID,
+		DIDRoute:      route,
+		Region:        models.LocalRegion(),
+	}
+	if route == models.DIDRouteRejected {
+		call.Status = models.SipCallStatusFailed
+	}
+	if from := req.From(); from != nil {
+		call.FromUsername = from.Address.User
+		call.FromURI = from.Address.String()
+	}
+	if to := req.To(); to != nil {
+		call.ToUsername = to.Address.User
+		call.ToURI = to.Address.String()
+	}
+	if err := as.db.Create(call).Error; err != nil {
+		logrus.WithError(err).WithField("call_id", call.CallID).Error("Failed to create DID call record")
+	}
+}
@@ -0,0 +1,252 @@
+package sip
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/sirupsen/logrus"
+)
+
+// RFC 4733 telephone-event parameters
+const (
+	dtmfPayloadType    = 101
+	dtmfVolume         = 10 // -10 dBm0
+	dtmfToneDuration   = 100 * time.Millisecond
+	dtmfInterDigitGap  = 80 * time.Millisecond
+	dtmfPacketInterval = 20 * time.Millisecond
+	dtmfEndRepeats     = 3
+)
+
+// Limits of the send_dtmf tool, so a confused model cannot flood the far end
+const (
+	maxDTMFDigitsPerSend = 20
+	maxDTMFDigitsPerCall = 64
+	dtmfMinSendInterval  = time.Second
+)
+
+var (
+	ErrInvalidDTMF     = errors.New("invalid DTMF digits, use 0-9, *, #, A-D and , for a pause")
+	ErrDTMFRateLimited = errors.New("DTMF sent too frequently")
+	ErrDTMFCallLimit   = errors.New("DTMF digit limit of the call reached")
+)
+
+// dtmfEvent maps a key to its RFC 4733 event code
+func dtmfEvent(key rune) (byte, bool) {
+	switch {
+	case key >= '0' && key <= '9':
+		return byte(key - '0'), true
+	case key == '*':
+		return 10, true
+	case key == '#':
+		return 11, true
+	case key >= 'A' && key <= 'D':
+		return byte(12 + key - 'A'), true
+	case key >= 'a' && key <= 'd':
+		return byte(12 + key - 'a'), true
+	}
+	return 0, false
+}
+
+// normalizeDTMF validates the digits, spaces and dashes are dropped; ',' is a pause
+func normalizeDTMF(digits string) (string, error) {
+	var b strings.Builder
+	for _, r := range digits {
+		switch {
+		case r == ' ' || r == '-':
+			continue
+		case r == ',':
+			b.WriteRune(r)
+		default:
+			if _, ok := dtmfEvent(r); !ok {
+				return "", ErrInvalidDTMF
+			}
+			b.WriteRune(r)
+		}
+	}
+	if strings.Trim(b.String(), ",") == "" {
+		return "", ErrInvalidDTMF
+	}
+	return strings.ToUpper(b.String()), nil
+}
+
+// dtmfPayload telephone-event payload: event, E bit + volume, duration in timestamp units
+func dtmfPayload(event byte, end bool, duration uint16) []byte {
+	payload := make([]byte, 4)
+	payload[0] = event
+	payload[1] = dtmfVolume
+	if end {
+		payload[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(payload[2:], duration)
+	return payload
+}
+
+// dtmfDigitPackets RTP packets of one digit at 8kHz: updates every 20ms with growing
+// duration, the first one marked, and the final end packet sent three times with
+// the same sequence-independent timestamp as required by RFC 4733
+func dtmfDigitPackets(event byte, ssrc uint32, seq uint16, timestamp uint32) []*rtp.Packet {
+	step := uint16(dtmfPacketInterval / time.Millisecond * 8)
+	total := uint16(dtmfToneDuration / time.Millisecond * 8)
+	var packets []*rtp.Packet
+	for duration := step; duration <= total; duration += step {
+		end := duration == total
+		repeats := 1
+		if end {
+			repeats = dtmfEndRepeats
+		}
+		for i := 0; i < repeats; i++ {
+			packets = append(packets, &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         len(packets) == 0,
+					PayloadType:    dtmfPayloadType,
+					SequenceNumber: seq,
+					Timestamp:      timestamp,
+					SSRC:           ssrc,
+				},
+				Payload: dtmfPayload(event, end, duration),
+			})
+			seq++
+		}
+	}
+	return packets
+}
+
+// dtmfLimiter per-call limits of the send_dtmf tool
+type dtmfLimiter struct {
+	mu   sync.Mutex
+	last time.Time
+	sent int
+}
+
+func (l *dtmfLimiter) allow(digits int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() && time.Since(l.last) < dtmfMinSendInterval {
+		return ErrDTMFRateLimited
+	}
+	if l.sent+digits > maxDTMFDigitsPerCall {
+		return ErrDTMFCallLimit
+	}
+	l.last = time.Now()
+	l.sent += digits
+	return nil
+}
+
+// SendDTMF sends the digits as RFC 4733 telephone events on the call's RTP leg,
+// continuing the sequence numbers and timestamps of the synthesized speech
+func (h *VoiceConversationHandler) SendDTMF(ctx context.Context, digits string) error {
+	digits, err := normalizeDTMF(digits)
+	if err != nil {
+		return err
+	}
+
+	h.rtpMutex.Lock()
+	seq := h.rtpSeqNum
+	timestamp := h.rtpTimestamp
+	h.rtpMutex.Unlock()
+	defer func() {
+		h.rtpMutex.Lock()
+		h.rtpSeqNum = seq
+		h.rtpTimestamp = timestamp
+		h.rtpMutex.Unlock()
+	}()
+
+	pause := func(d time.Duration) error {
+		timestamp += uint32(d / time.Millisecond * 8)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			return nil
+		}
+	}
+
+	for _, key := range digits {
+		if key == ',' {
+			if err := pause(500 * time.Millisecond); err != nil {
+				return err
+			}
+			continue
+		}
+		event, _ := dtmfEvent(key)
+		packets := dtmfDigitPackets(event, h.rtpSSRC, seq, timestamp)
+		for i, packet := range packets {
+			data, err := packet.Marshal()
+			if err != nil {
+				return err
+			}
+			if _, err := h.rtpConn.WriteToUDP(data, h.rtpTarget()); err != nil {
+				return fmt.Errorf("send DTMF: %w", err)
+			}
+			// End packets are retransmitted back to back
+			if i+1 < len(packets) && packets[i+1].Payload[1]&0x80 == 0 {
+				time.Sleep(dtmfPacketInterval)
+			}
+		}
+		seq += uint16(len(packets))
+		if err := pause(dtmfToneDuration + dtmfInterDigitGap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendDTMFToolParams JSON schema of the send_dtmf tool
+var sendDTMFToolParams = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"digits": {"type": "string", "description": "Keys to press: 0-9, * and #; use , for a half second pause"},
+		"reason": {"type": "string", "description": "The IVR prompt being answered, for the call log"}
+	},
+	"required": ["digits"]
+}`)
+
+// enableDTMFTool lets the LLM press keys on the call, used on outbound calls to
+// navigate the IVR menus of the called party
+func (h *VoiceConversationHandler) enableDTMFTool() {
+	limiter := &dtmfLimiter{}
+	h.llmProvider.RegisterFunctionTool("send_dtmf",
+		"Press phone keys (DTMF) on the current call, for example to answer an automated menu such as \"press 2 for support\". Only use it when the other party is an automated system asking for a key.",
+		sendDTMFToolParams,
+		func(args map[string]interface{}) (string, error) {
+			digits, _ := args["digits"].(string)
+			reason, _ := args["reason"].(string)
+			log := logrus.WithFields(logrus.Fields{
+				"call_id": h.callID,
+				"digits":  digits,
+				"reason":  reason,
+			})
+			normalized, err := normalizeDTMF(digits)
+			if err == nil && len(strings.ReplaceAll(normalized, ",", "")) > maxDTMFDigitsPerSend {
+				err = fmt.Errorf("at most %d digits per call of send_dtmf", maxDTMFDigitsPerSend)
+			}
+			if err == nil {
+				err = limiter.allow(len(strings.ReplaceAll(normalized, ",", "")))
+			}
+			if err == nil {
+				err = h.SendDTMF(h.ctx, normalized)
+			}
+			if err != nil {
+				log.WithError(err).Warn("DTMF from the assistant not sent")
+				return fmt.Sprintf("DTMF not sent: %v", err), nil
+			}
+			log.Info("DTMF sent by the assistant")
+			return fmt.Sprintf("Pressed %s", normalized), nil
+		})
+}
+
+// isOutgoingCall reports whether callID is a call placed by this server
+func (as *SipServer) isOutgoingCall(callID string) bool {
+	as.outgoingMutex.RLock()
+	defer as.outgoingMutex.RUnlock()
+	_, ok := as.outgoingSessions[callID]
+	return ok
+}
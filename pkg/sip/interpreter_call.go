@@ -0,0 +1,439 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/interpreter"
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/code-100-precent/LingEcho/pkg/recognizer"
+	"github.com/code-100-precent/LingEcho/pkg/sip/codec"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer"
+	"github.com/code-100-precent/LingEcho/pkg/voice/factory"
+	"github.com/pion/rtp"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+)
+
+// interpreterFrameSize one 20ms PCMU frame
+const interpreterFrameSize = 160
+
+// interpretedCall a caller and the party the assistant dialed for them; the
+// assistant interprets between the two legs, or relays the audio as is while
+// interpretation is turned off
+type interpretedCall struct {
+	server       *SipServer
+	callID       string // the caller's call
+	calleeCallID string // the call the assistant placed
+	assistant    *models.Assistant
+	callerDialog aiDialog
+	engine       *interpreter.Engine
+	legs         map[interpreter.Leg]*interpreterLeg
+	providers    []llm.LLMProvider
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// interpreterLeg media of one party
+type interpreterLeg struct {
+	leg      interpreter.Leg
+	language string
+	asr      recognizer.TranscribeService
+	tts      synthesizer.SynthesisService
+
+	mu        sync.Mutex // serializes audio sent to the party
+	addr      *net.UDPAddr
+	ssrc      uint32
+	seq       uint16
+	timestamp uint32
+}
+
+// startInterpretedCall answers the caller as an interpreter: the assistant's
+// target is dialed and interpretation starts once it answers. Interpretation
+// can be toggled with SetInterpretation from the moment the caller is answered
+func (as *SipServer) startInterpretedCall(callID string, clientRTPAddr *net.UDPAddr, assistant *models.Assistant) error {
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":      callID,
+		"assistant_id": assistant.ID,
+		"target":       assistant.InterpreterTarget,
+	})
+	credential, err := as.assistantCredential(callID, assistant)
+	if err != nil {
+		return err
+	}
+
+	zapLogger, _ := zap.NewProduction()
+	if zapLogger == nil {
+		zapLogger = zap.NewNop()
+	}
+	serviceFactory := factory.NewServiceFactory(recognizer.GetGlobalFactory(), zapLogger)
+
+	ic := &interpretedCall{
+		server:    as,
+		callID:    callID,
+		assistant: assistant,
+		legs:      make(map[interpreter.Leg]*interpreterLeg, 2),
+	}
+	ic.ctx, ic.cancel = context.WithCancel(context.Background())
+	as.aiSessionMutex.RLock()
+	if info, ok := as.aiSessionInfo[callID]; ok && info != nil {
+		ic.callerDialog = info.Dialog
+	}
+	as.aiSessionMutex.RUnlock()
+
+	// The caller speaks the assistant's language, the dialed party the interpreter language
+	legConfigs := []struct {
+		leg      interpreter.Leg
+		language string
+		speaker  string
+	}{
+		{interpreter.LegA, assistant.Language, assistant.Speaker},
+		{interpreter.LegB, assistant.InterpreterLanguage, assistant.InterpreterSpeaker},
+	}
+	for _, cfg := range legConfigs {
+		asr, err := serviceFactory.CreateASR(credential, cfg.language)
+		if err != nil {
+			ic.close()
+			return fmt.Errorf("failed to create ASR service for %s: %w", cfg.language, err)
+		}
+		tts, err := serviceFactory.CreateTTS(credential, cfg.speaker)
+		if err != nil {
+			ic.close()
+			return fmt.Errorf("failed to create TTS service for %s: %w", cfg.language, err)
+		}
+		ic.legs[cfg.leg] = &interpreterLeg{leg: cfg.leg, language: cfg.language, asr: asr, tts: tts, ssrc: rand.Uint32()}
+		// One provider per leg, both parties are translated concurrently
+		provider, err := serviceFactory.CreateLLM(ic.ctx, credential, "")
+		if err != nil {
+			ic.close()
+			return fmt.Errorf("failed to create LLM provider: %w", err)
+		}
+		ic.providers = append(ic.providers, provider)
+	}
+	ic.legs[interpreter.LegA].addr = clientRTPAddr
+	ic.engine = interpreter.New(interpreter.Config{
+		LanguageA: assistant.Language,
+		LanguageB: assistant.InterpreterLanguage,
+		OnEntry:   func(e interpreter.Entry) { as.saveInterpretationEntry(ic, e) },
+	}, interpreter.NewLLMTranslator(assistant.LLMModel, ic.providers...), ic)
+
+	as.interpreterMutex.Lock()
+	as.interpretedCalls[callID] = ic
+	as.interpreterMutex.Unlock()
+
+	calleeCallID, err := as.startOutgoingCall(assistant.InterpreterTarget, func(remoteRTPAddr string, dialog aiDialog) {
+		as.connectInterpretedCall(ic, remoteRTPAddr)
+	})
+	if err != nil {
+		as.interpreterMutex.Lock()
+		delete(as.interpretedCalls, callID)
+		as.interpreterMutex.Unlock()
+		ic.close()
+		return fmt.Errorf("failed to dial %s: %w", assistant.InterpreterTarget, err)
+	}
+	as.interpreterMutex.Lock()
+	ic.calleeCallID = calleeCallID
+	as.interpretedCalls[calleeCallID] = ic
+	as.interpreterMutex.Unlock()
+
+	if as.db != nil {
+		sipCall := &models.SipCall{
+			CallID:    calleeCallID,
+			Direction: models.SipCallDirectionOutbound,
+			Status:    models.SipCallStatusCalling,
+			ToURI:     assistant.InterpreterTarget,
+			StartTime: time.Now(),
+			UserID:    &assistant.UserID,
+			GroupID:   assistant.GroupID,
+			Notes:     "Interpreted call for " + callID,
+			Region:    models.LocalRegion(),
+		}
+		if err := models.CreateSipCall(as.db, sipCall); err != nil {
+			log.WithError(err).Warn("Failed to create interpreted call record")
+		}
+	}
+	log.WithField("callee_call_id", calleeCallID).Info("Dialing the other party for interpretation")
+	return nil
+}
+
+// connectInterpretedCall starts interpreting once the dialed party answered
+func (as *SipServer) connectInterpretedCall(ic *interpretedCall, remoteRTPAddr string) {
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":        ic.callID,
+		"callee_call_id": ic.calleeCallID,
+	})
+	addr, err := net.ResolveUDPAddr("udp", remoteRTPAddr)
+	if err != nil {
+		log.WithError(err).Error("Invalid RTP address of the dialed party")
+		as.endInterpretedCall(ic.calleeCallID)
+		return
+	}
+	callee := ic.legs[interpreter.LegB]
+	callee.mu.Lock()
+	callee.addr = addr
+	callee.mu.Unlock()
+
+	for _, l := range ic.legs {
+		as.startInterpreterASR(ic, l)
+	}
+	go as.receiveInterpretedRTP(ic)
+	log.Info("Interpretation started")
+}
+
+// startInterpreterASR streams a leg's speech to its recognizer; partial
+// results are passed on so clauses are translated before the sentence ends
+func (as *SipServer) startInterpreterASR(ic *interpretedCall, l *interpreterLeg) {
+	log := logrus.WithFields(logrus.Fields{"call_id": ic.callID, "leg": l.leg})
+	l.asr.Init(
+		func(text string, isLast bool, duration time.Duration, uuid string) {
+			if err := ic.engine.HandleTranscript(l.leg, text, isLast); err != nil && ic.ctx.Err() == nil {
+				log.WithError(err).Warn("Failed to interpret transcript")
+			}
+		},
+		func(err error, isFatal bool) {
+			log.WithError(err).WithField("fatal", isFatal).Warn("Interpreter ASR error")
+			if isFatal && ic.ctx.Err() == nil {
+				go l.asr.RestartClient()
+			}
+		},
+	)
+	if err := l.asr.ConnAndReceive(fmt.Sprintf("%s-%s", ic.callID, l.leg)); err != nil {
+		log.WithError(err).Error("Failed to connect interpreter ASR")
+	}
+}
+
+// receiveInterpretedRTP reads the audio of both parties: it is recognized
+// while interpreting and relayed to the other party otherwise
+func (as *SipServer) receiveInterpretedRTP(ic *interpretedCall) {
+	buffer := make([]byte, 1500)
+	for ic.ctx.Err() == nil {
+		n, from, err := as.rtpConn.ReadFromUDP(buffer)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			logrus.WithError(err).WithField("call_id", ic.callID).Error("Failed to read RTP data")
+			continue
+		}
+		l := ic.legFrom(from)
+		if l == nil {
+			continue
+		}
+		packet := &rtp.Packet{}
+		if err := packet.Unmarshal(buffer[:n]); err != nil || packet.PayloadType != 0 {
+			continue
+		}
+
+		if !ic.engine.Enabled() {
+			ic.legs[l.leg.Other()].writeFrames(as, packet.Payload)
+			continue
+		}
+		pcm16k := codec.ResampleAudio(codec.PCMUToPCM16(packet.Payload), 8000, 16000)
+		if err := l.asr.SendAudioBytes(pcm16k); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"call_id": ic.callID, "leg": l.leg}).Debug("Failed to send audio to interpreter ASR")
+		}
+	}
+}
+
+// legFrom the leg an RTP packet came from, by address and then by IP for NATed parties
+func (ic *interpretedCall) legFrom(from *net.UDPAddr) *interpreterLeg {
+	var byIP *interpreterLeg
+	for _, l := range ic.legs {
+		l.mu.Lock()
+		addr := l.addr
+		l.mu.Unlock()
+		if addr == nil || !addr.IP.Equal(from.IP) {
+			continue
+		}
+		if addr.Port == from.Port {
+			return l
+		}
+		byIP = l
+	}
+	return byIP
+}
+
+// Speak implements interpreter.Speaker, the translation is synthesized in the
+// listener's language and played into their leg
+func (ic *interpretedCall) Speak(ctx context.Context, leg interpreter.Leg, text, language string) error {
+	l := ic.legs[leg]
+	buffer := &synthesizer.SynthesisBuffer{}
+	if err := l.tts.Synthesize(ctx, buffer, text); err != nil {
+		return err
+	}
+	pcm := buffer.Data
+	if rate := l.tts.Format().SampleRate; rate != 8000 {
+		pcm = codec.ResampleAudio(pcm, rate, 8000)
+	}
+	pcmu := codec.PCM16ToPCMU(pcm)
+	for start := 0; start < len(pcmu); start += interpreterFrameSize {
+		end := start + interpreterFrameSize
+		if end > len(pcmu) {
+			end = len(pcmu)
+		}
+		if err := l.writeFrame(ic.server, pcmu[start:end]); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// writeFrames relays audio received from the other party
+func (l *interpreterLeg) writeFrames(as *SipServer, pcmu []byte) {
+	for start := 0; start < len(pcmu); start += interpreterFrameSize {
+		end := start + interpreterFrameSize
+		if end > len(pcmu) {
+			end = len(pcmu)
+		}
+		if err := l.writeFrame(as, pcmu[start:end]); err != nil {
+			return
+		}
+	}
+}
+
+// writeFrame sends one PCMU frame to the party, frames shorter than 20ms are padded with silence
+func (l *interpreterLeg) writeFrame(as *SipServer, frame []byte) error {
+	if len(frame) < interpreterFrameSize {
+		padded := make([]byte, interpreterFrameSize)
+		copy(padded, frame)
+		for i := len(frame); i < interpreterFrameSize; i++ {
+			padded[i] = 0xFF // PCMU 静音值
+		}
+		frame = padded
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.addr == nil {
+		return fmt.Errorf("leg %s is not connected", l.leg)
+	}
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    0,
+			SequenceNumber: l.seq,
+			Timestamp:      l.timestamp,
+			SSRC:           l.ssrc,
+		},
+		Payload: frame,
+	}
+	l.seq++
+	l.timestamp += interpreterFrameSize
+	data, err := packet.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = as.rtpConn.WriteToUDP(data, l.addr)
+	return err
+}
+
+func (as *SipServer) saveInterpretationEntry(ic *interpretedCall, e interpreter.Entry) {
+	if as.db == nil {
+		return
+	}
+	segment := &models.InterpretationSegment{
+		CallID:         ic.callID,
+		Seq:            e.Seq,
+		AssistantID:    ic.assistant.ID,
+		Leg:            string(e.Leg),
+		SourceLanguage: e.SourceLanguage,
+		TargetLanguage: e.TargetLanguage,
+		SourceText:     e.Source,
+		TranslatedText: e.Translation,
+		RecognizedAt:   e.RecognizedAt,
+		LatencyMs:      e.Latency.Milliseconds(),
+		Error:          e.Error,
+	}
+	if err := models.CreateInterpretationSegment(as.db, segment); err != nil {
+		logrus.WithError(err).WithField("call_id", ic.callID).Error("Failed to save interpretation segment")
+	}
+}
+
+// interpretedCall the interpreted call either leg belongs to
+func (as *SipServer) interpretedCall(callID string) (*interpretedCall, bool) {
+	as.interpreterMutex.Lock()
+	defer as.interpreterMutex.Unlock()
+	ic, ok := as.interpretedCalls[callID]
+	return ic, ok
+}
+
+// Interpretation the assistant interpreting a call and whether interpretation
+// is on, callID may be either leg
+func (as *SipServer) Interpretation(callID string) (assistantID int64, enabled bool, ok bool) {
+	ic, ok := as.interpretedCall(callID)
+	if !ok {
+		return 0, false, false
+	}
+	return ic.assistant.ID, ic.engine.Enabled(), true
+}
+
+// SetInterpretation turns interpretation of a call on or off; while off the
+// parties hear each other directly
+func (as *SipServer) SetInterpretation(callID string, enabled bool) error {
+	ic, ok := as.interpretedCall(callID)
+	if !ok {
+		return fmt.Errorf("interpreted call not found: %s", callID)
+	}
+	ic.engine.SetEnabled(enabled)
+	logrus.WithFields(logrus.Fields{"call_id": ic.callID, "enabled": enabled}).Info("Interpretation toggled")
+	return nil
+}
+
+// endInterpretedCall stops interpreting once either leg ended and hangs up the other one
+func (as *SipServer) endInterpretedCall(callID string) {
+	as.interpreterMutex.Lock()
+	ic, ok := as.interpretedCalls[callID]
+	if ok {
+		delete(as.interpretedCalls, ic.callID)
+		delete(as.interpretedCalls, ic.calleeCallID)
+	}
+	as.interpreterMutex.Unlock()
+	if !ok {
+		return
+	}
+	ic.close()
+
+	log := logrus.WithFields(logrus.Fields{"call_id": ic.callID, "callee_call_id": ic.calleeCallID})
+	if callID == ic.callID {
+		if err := as.HangupOutgoingCall(ic.calleeCallID); err != nil {
+			if err := as.CancelOutgoingCall(ic.calleeCallID); err != nil {
+				log.WithError(err).Warn("Failed to end the interpreted call")
+			}
+		}
+		return
+	}
+	if err := as.sendDialogBye(ic.callID, ic.callerDialog); err != nil {
+		log.WithError(err).Warn("Failed to hang up the caller")
+	}
+	now := time.Now()
+	as.updateCallStatusInDB(ic.callID, "ended", &now)
+	log.Info("Interpreted call ended")
+}
+
+func (ic *interpretedCall) close() {
+	ic.closeOnce.Do(func() {
+		ic.cancel()
+		if ic.engine != nil {
+			ic.engine.Close()
+		}
+		for _, l := range ic.legs {
+			if l.asr != nil {
+				_ = l.asr.StopConn()
+			}
+			if l.tts != nil {
+				_ = l.tts.Close()
+			}
+		}
+	})
+}
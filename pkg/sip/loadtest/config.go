@@ -0,0 +1,109 @@
+// Package loadtest 内置的 SIP 压测/浸泡测试工具：模拟 N 个 UA 客户端对目标服务器
+// 注册、呼叫、按 PCM 素材收发 RTP 并挂断，统计建立时延、失败率和资源占用，用于发版前验证容量。
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxUsers 单次压测允许的最大并发 UA 数
+	MaxUsers = 2000
+	// MaxDuration 单次压测允许的最长时间
+	MaxDuration = 24 * time.Hour
+
+	defaultUsers        = 10
+	defaultCallsPerUser = 1
+	defaultDuration     = 5 * time.Minute
+	defaultCallHold     = 10 * time.Second
+	defaultCallTimeout  = 30 * time.Second
+	defaultUserPrefix   = "loadtest"
+)
+
+// Config 压测参数
+type Config struct {
+	Target       string        `json:"target"`       // 目标 SIP 服务器 host:port
+	LocalIP      string        `json:"localIp"`      // 本地 SIP/RTP 绑定地址，默认按目标地址自动选择
+	Users        int           `json:"users"`        // 并发模拟 UA 数
+	CallsPerUser int           `json:"callsPerUser"` // 每个 UA 的呼叫次数，<=0 时持续呼叫直到 Duration 结束（浸泡测试）
+	Duration     time.Duration `json:"duration"`     // 总时长上限
+	RampUp       time.Duration `json:"rampUp"`       // 所有 UA 启动完毕所需时间，0 表示同时启动
+	CallHold     time.Duration `json:"callHold"`     // 每通呼叫接通后保持的时长
+	Pause        time.Duration `json:"pause"`        // 同一 UA 两次呼叫之间的间隔
+	CallTimeout  time.Duration `json:"callTimeout"`  // 等待应答的超时
+	Register     bool          `json:"register"`     // 呼叫前先 REGISTER
+	UserPrefix   string        `json:"userPrefix"`   // UA 用户名前缀，用户名为前缀加序号
+	Callee       string        `json:"callee"`       // 被叫用户名，如 DID 或绑定助手的 SIP 用户
+	PCMFile      string        `json:"pcmFile"`      // 8kHz 16bit 单声道 PCM 或 WAV 素材，循环发送；为空时发送静音
+}
+
+// withDefaults 填充默认值
+func (c Config) withDefaults() Config {
+	if c.Users <= 0 {
+		c.Users = defaultUsers
+	}
+	if c.CallsPerUser == 0 {
+		c.CallsPerUser = defaultCallsPerUser
+	}
+	if c.Duration <= 0 {
+		c.Duration = defaultDuration
+	}
+	if c.CallHold <= 0 {
+		c.CallHold = defaultCallHold
+	}
+	if c.CallTimeout <= 0 {
+		c.CallTimeout = defaultCallTimeout
+	}
+	if c.UserPrefix == "" {
+		c.UserPrefix = defaultUserPrefix
+	}
+	return c
+}
+
+// Validate 校验参数
+func (c Config) Validate() error {
+	if c.Target == "" {
+		return errors.New("target is required")
+	}
+	host, port, err := net.SplitHostPort(c.Target)
+	if err != nil || host == "" {
+		return fmt.Errorf("invalid target %q, use host:port", c.Target)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("invalid target port %q", port)
+	}
+	if c.Users > MaxUsers {
+		return fmt.Errorf("users must not exceed %d", MaxUsers)
+	}
+	if c.Duration > MaxDuration {
+		return fmt.Errorf("duration must not exceed %s", MaxDuration)
+	}
+	if c.RampUp < 0 || c.Pause < 0 {
+		return errors.New("rampUp and pause must not be negative")
+	}
+	if c.LocalIP != "" && net.ParseIP(c.LocalIP) == nil {
+		return fmt.Errorf("invalid local IP %q", c.LocalIP)
+	}
+	return nil
+}
+
+// targetHostPort 解析目标地址
+func (c Config) targetHostPort() (string, int) {
+	host, port, _ := net.SplitHostPort(c.Target)
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+// localIPFor 选择访问目标时使用的本地地址
+func localIPFor(target string) string {
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
@@ -0,0 +1,136 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cfg := Config{Target: "127.0.0.1:5060"}.withDefaults()
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, defaultUsers, cfg.Users)
+	assert.Equal(t, defaultCallHold, cfg.CallHold)
+
+	assert.Error(t, Config{}.Validate())
+	assert.Error(t, Config{Target: "127.0.0.1"}.Validate())
+	assert.Error(t, Config{Target: "127.0.0.1:70000"}.Validate())
+	assert.Error(t, Config{Target: "127.0.0.1:5060", Users: MaxUsers + 1}.Validate())
+	assert.Error(t, Config{Target: "127.0.0.1:5060", LocalIP: "nope"}.Validate())
+}
+
+func TestWavData(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	assert.Equal(t, pcm, wavData(pcm))
+
+	wav := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	wav = binary.LittleEndian.AppendUint32(wav, 2)
+	wav = append(wav, 0, 0, 'd', 'a', 't', 'a')
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(pcm)))
+	wav = append(wav, pcm...)
+	assert.Equal(t, pcm, wavData(wav))
+}
+
+func TestAnswerRTPAddr(t *testing.T) {
+	addr, err := answerRTPAddr(offerSDP("127.0.0.1", 40000))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:40000", addr.String())
+
+	_, err = answerRTPAddr([]byte("garbage"))
+	assert.Error(t, err)
+}
+
+// startFakeUAS 启动一个应答 REGISTER/INVITE 的本地 SIP 服务，RTP 原样回显
+func startFakeUAS(t *testing.T) string {
+	t.Helper()
+	echo, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { echo.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := echo.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			echo.WriteToUDP(buf[:n], from)
+		}
+	}()
+
+	ua, err := sipgo.NewUA()
+	require.NoError(t, err)
+	t.Cleanup(func() { ua.Close() })
+	srv, err := sipgo.NewServer(ua)
+	require.NoError(t, err)
+
+	port, err := freeUDPPort("127.0.0.1")
+	require.NoError(t, err)
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	answer := offerSDP("127.0.0.1", echo.LocalAddr().(*net.UDPAddr).Port)
+	srv.OnRegister(func(req *sip.Request, tx sip.ServerTransaction) {
+		tx.Respond(sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil))
+	})
+	srv.OnInvite(func(req *sip.Request, tx sip.ServerTransaction) {
+		res := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", answer)
+		res.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+		res.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Host: "127.0.0.1", Port: port}})
+		tx.Respond(res)
+	})
+	srv.OnAck(func(req *sip.Request, tx sip.ServerTransaction) {})
+	srv.OnBye(func(req *sip.Request, tx sip.ServerTransaction) {
+		tx.Respond(sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ready := make(chan struct{})
+	go srv.ListenAndServe(context.WithValue(ctx, sipgo.ListenReadyCtxKey, sipgo.ListenReadyCtxValue(ready)), "udp", addr)
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fake UAS did not start")
+	}
+	return addr
+}
+
+func TestRunnerRun(t *testing.T) {
+	target := startFakeUAS(t)
+
+	runner, err := New(Config{
+		Target:      target,
+		LocalIP:     "127.0.0.1",
+		Users:       2,
+		Duration:    20 * time.Second,
+		CallHold:    200 * time.Millisecond,
+		CallTimeout: 5 * time.Second,
+		Register:    true,
+		Callee:      "echo",
+	})
+	require.NoError(t, err)
+
+	report, err := runner.Run(context.Background())
+	require.NoError(t, err)
+	assert.False(t, report.Running)
+	assert.NotNil(t, report.FinishedAt)
+	assert.Equal(t, int64(2), report.Registrations)
+	assert.Equal(t, int64(2), report.CallsAttempted)
+	assert.Equal(t, int64(2), report.CallsAnswered)
+	assert.Equal(t, int64(2), report.CallsCompleted, "failures: %v", report.Failures)
+	assert.Zero(t, report.CallsFailed)
+	assert.Equal(t, int64(2), report.SetupLatency.Count)
+	assert.Positive(t, report.RTPPacketsSent)
+	assert.Positive(t, report.RTPPacketsReceived)
+	assert.Positive(t, report.Resources.PeakGoroutines)
+
+	_, err = runner.Run(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/sip/codec"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+const (
+	frameSamples  = 160 // 20ms @ 8kHz
+	frameInterval = 20 * time.Millisecond
+	pcmuSilence   = 0xFF
+)
+
+// loadFrames 读取 PCM 素材并编码为 20ms 的 PCMU 帧
+func loadFrames(path string) ([][]byte, error) {
+	if path == "" {
+		return [][]byte{bytes.Repeat([]byte{pcmuSilence}, frameSamples)}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pcm fixture: %w", err)
+	}
+	pcm := wavData(data)
+	if len(pcm) < frameSamples*2 {
+		return nil, fmt.Errorf("pcm fixture %s is shorter than one frame", path)
+	}
+	ulaw := codec.PCM16ToPCMU(pcm)
+	frames := make([][]byte, 0, len(ulaw)/frameSamples)
+	for i := 0; i+frameSamples <= len(ulaw); i += frameSamples {
+		frames = append(frames, ulaw[i:i+frameSamples])
+	}
+	return frames, nil
+}
+
+// wavData 去掉 WAV 头，原始 PCM 原样返回
+func wavData(data []byte) []byte {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return data
+	}
+	for i := 12; i+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		if string(data[i:i+4]) == "data" {
+			end := i + 8 + size
+			if end > len(data) {
+				end = len(data)
+			}
+			return data[i+8 : end]
+		}
+		i += 8 + size + size%2
+	}
+	return nil
+}
+
+// offerSDP 生成 PCMU 的 SDP offer
+func offerSDP(ip string, port int) []byte {
+	id := uint64(time.Now().UnixNano())
+	session := sdp.SessionDescription{
+		Origin: sdp.Origin{
+			Username:       "-",
+			SessionID:      id,
+			SessionVersion: id,
+			NetworkType:    "IN",
+			AddressType:    "IP4",
+			UnicastAddress: ip,
+		},
+		SessionName: "LingEcho Load Test",
+		ConnectionInformation: &sdp.ConnectionInformation{
+			NetworkType: "IN",
+			AddressType: "IP4",
+			Address:     &sdp.Address{Address: ip},
+		},
+		TimeDescriptions: []sdp.TimeDescription{{}},
+		MediaDescriptions: []*sdp.MediaDescription{{
+			MediaName: sdp.MediaName{
+				Media:   "audio",
+				Port:    sdp.RangedPort{Value: port},
+				Protos:  []string{"RTP", "AVP"},
+				Formats: []string{"0"},
+			},
+			Attributes: []sdp.Attribute{
+				{Key: "rtpmap", Value: "0 PCMU/8000"},
+				{Key: "sendrecv"},
+			},
+		}},
+	}
+	body, _ := session.Marshal()
+	return body
+}
+
+// answerRTPAddr 从 SDP answer 中解析对端 RTP 地址
+func answerRTPAddr(body []byte) (*net.UDPAddr, error) {
+	var session sdp.SessionDescription
+	if err := session.Unmarshal(body); err != nil {
+		return nil, fmt.Errorf("parse sdp answer: %w", err)
+	}
+	var ip string
+	if session.ConnectionInformation != nil && session.ConnectionInformation.Address != nil {
+		ip = session.ConnectionInformation.Address.Address
+	}
+	for _, m := range session.MediaDescriptions {
+		if m.MediaName.Media != "audio" {
+			continue
+		}
+		if m.ConnectionInformation != nil && m.ConnectionInformation.Address != nil {
+			ip = m.ConnectionInformation.Address.Address
+		}
+		if ip == "" || m.MediaName.Port.Value == 0 {
+			break
+		}
+		return net.ResolveUDPAddr("udp", net.JoinHostPort(ip, strconv.Itoa(m.MediaName.Port.Value)))
+	}
+	return nil, fmt.Errorf("no audio media in sdp answer")
+}
+
+// mediaStream 一通呼叫的 RTP 收发
+type mediaStream struct {
+	conn     *net.UDPConn
+	remote   *net.UDPAddr
+	frames   [][]byte
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// receive 统计收到的 RTP 包，连接关闭时退出
+func (m *mediaStream) receive() {
+	buf := make([]byte, 1500)
+	var pkt rtp.Packet
+	for {
+		n, _, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if pkt.Unmarshal(buf[:n]) == nil {
+			m.received.Add(1)
+		}
+	}
+}
+
+// send 按 20ms 节奏循环发送素材，直到 hold 结束或 stop 关闭；返回 true 表示被 stop 打断
+func (m *mediaStream) send(hold time.Duration, stop <-chan struct{}) bool {
+	ssrc := uint32(time.Now().UnixNano())
+	seq := uint16(ssrc)
+	var ts uint32
+	ticker := time.NewTicker(frameInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(hold)
+	defer deadline.Stop()
+
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			return true
+		case <-deadline.C:
+			return false
+		case <-ticker.C:
+		}
+		pkt := rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    0,
+				SequenceNumber: seq,
+				Timestamp:      ts,
+				SSRC:           ssrc,
+				Marker:         i == 0,
+			},
+			Payload: m.frames[i%len(m.frames)],
+		}
+		if data, err := pkt.Marshal(); err == nil {
+			if _, err := m.conn.WriteToUDP(data, m.remote); err == nil {
+				m.sent.Add(1)
+			}
+		}
+		seq++
+		ts += frameSamples
+	}
+}
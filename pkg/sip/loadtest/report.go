@@ -0,0 +1,212 @@
+package loadtest
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxLatencySamples 保留用于计算分位数的样本上限，超出后只更新计数、均值和极值
+const maxLatencySamples = 200000
+
+// LatencyStats 时延统计，单位毫秒
+type LatencyStats struct {
+	Count int64   `json:"count"`
+	Min   float64 `json:"minMs"`
+	Avg   float64 `json:"avgMs"`
+	P50   float64 `json:"p50Ms"`
+	P95   float64 `json:"p95Ms"`
+	P99   float64 `json:"p99Ms"`
+	Max   float64 `json:"maxMs"`
+}
+
+// ResourceUsage 压测进程的资源占用峰值
+type ResourceUsage struct {
+	PeakGoroutines int    `json:"peakGoroutines"`
+	PeakHeapBytes  uint64 `json:"peakHeapBytes"`
+	PeakSysBytes   uint64 `json:"peakSysBytes"`
+	GCCycles       uint32 `json:"gcCycles"`
+}
+
+// Report 压测结果，运行中为实时快照
+type Report struct {
+	Running              bool             `json:"running"`
+	Config               Config           `json:"config"`
+	StartedAt            time.Time        `json:"startedAt"`
+	FinishedAt           *time.Time       `json:"finishedAt,omitempty"`
+	Elapsed              float64          `json:"elapsedSeconds"`
+	ActiveUsers          int64            `json:"activeUsers"`
+	ActiveCalls          int64            `json:"activeCalls"`
+	Registrations        int64            `json:"registrations"`
+	RegistrationFailures int64            `json:"registrationFailures"`
+	CallsAttempted       int64            `json:"callsAttempted"`
+	CallsAnswered        int64            `json:"callsAnswered"`
+	CallsCompleted       int64            `json:"callsCompleted"` // 接通并正常挂断
+	CallsFailed          int64            `json:"callsFailed"`
+	RemoteHangups        int64            `json:"remoteHangups"` // 保持期间被对端挂断
+	FailureRate          float64          `json:"failureRate"`
+	CallsPerSecond       float64          `json:"callsPerSecond"`
+	Failures             map[string]int64 `json:"failures"` // 失败原因，SIP 状态码或 timeout/transport/bye 等
+	RegisterLatency      LatencyStats     `json:"registerLatency"`
+	SetupLatency         LatencyStats     `json:"setupLatency"` // INVITE 到 200 OK
+	RTPPacketsSent       int64            `json:"rtpPacketsSent"`
+	RTPPacketsReceived   int64            `json:"rtpPacketsReceived"`
+	Resources            ResourceUsage    `json:"resources"`
+	Error                string           `json:"error,omitempty"`
+}
+
+// latencyRecorder 时延样本
+type latencyRecorder struct {
+	samples []time.Duration
+	count   int64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+}
+
+func (l *latencyRecorder) add(d time.Duration) {
+	if l.count == 0 || d < l.min {
+		l.min = d
+	}
+	if d > l.max {
+		l.max = d
+	}
+	l.count++
+	l.sum += d
+	if len(l.samples) < maxLatencySamples {
+		l.samples = append(l.samples, d)
+	}
+}
+
+func (l *latencyRecorder) stats() LatencyStats {
+	if l.count == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), l.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyStats{
+		Count: l.count,
+		Min:   ms(l.min),
+		Avg:   ms(l.sum / time.Duration(l.count)),
+		P50:   ms(percentile(sorted, 0.50)),
+		P95:   ms(percentile(sorted, 0.95)),
+		P99:   ms(percentile(sorted, 0.99)),
+		Max:   ms(l.max),
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1)*p + 0.5)
+	return sorted[idx]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// collector 汇总各 UA 的结果
+type collector struct {
+	mu        sync.Mutex
+	report    Report
+	register  latencyRecorder
+	setup     latencyRecorder
+	resources ResourceUsage
+	gcStart   uint32
+}
+
+func newCollector(cfg Config) *collector {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return &collector{
+		report: Report{
+			Running:   true,
+			Config:    cfg,
+			StartedAt: time.Now(),
+			Failures:  make(map[string]int64),
+		},
+		gcStart: mem.NumGC,
+	}
+}
+
+func (c *collector) update(fn func(r *Report)) {
+	c.mu.Lock()
+	fn(&c.report)
+	c.mu.Unlock()
+}
+
+func (c *collector) registered(d time.Duration, failure string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if failure != "" {
+		c.report.RegistrationFailures++
+		c.report.Failures["register:"+failure]++
+		return
+	}
+	c.report.Registrations++
+	c.register.add(d)
+}
+
+func (c *collector) answered(d time.Duration) {
+	c.mu.Lock()
+	c.report.CallsAnswered++
+	c.setup.add(d)
+	c.mu.Unlock()
+}
+
+func (c *collector) failed(reason string) {
+	c.mu.Lock()
+	c.report.CallsFailed++
+	c.report.Failures[reason]++
+	c.mu.Unlock()
+}
+
+// sampleResources 记录资源占用峰值
+func (c *collector) sampleResources() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	goroutines := runtime.NumGoroutine()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if goroutines > c.resources.PeakGoroutines {
+		c.resources.PeakGoroutines = goroutines
+	}
+	if mem.HeapAlloc > c.resources.PeakHeapBytes {
+		c.resources.PeakHeapBytes = mem.HeapAlloc
+	}
+	if mem.Sys > c.resources.PeakSysBytes {
+		c.resources.PeakSysBytes = mem.Sys
+	}
+	c.resources.GCCycles = mem.NumGC - c.gcStart
+}
+
+// snapshot 生成当前报告
+func (c *collector) snapshot() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.report
+	r.Failures = make(map[string]int64, len(c.report.Failures))
+	for k, v := range c.report.Failures {
+		r.Failures[k] = v
+	}
+	end := time.Now()
+	if r.FinishedAt != nil {
+		end = *r.FinishedAt
+	}
+	elapsed := end.Sub(r.StartedAt)
+	r.Elapsed = elapsed.Seconds()
+	if r.CallsAttempted > 0 {
+		r.FailureRate = float64(r.CallsFailed) / float64(r.CallsAttempted)
+	}
+	if elapsed > 0 {
+		r.CallsPerSecond = float64(r.CallsAttempted) / elapsed.Seconds()
+	}
+	r.RegisterLatency = c.register.stats()
+	r.SetupLatency = c.setup.stats()
+	r.Resources = c.resources
+	return &r
+}
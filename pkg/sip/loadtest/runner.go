@@ -0,0 +1,344 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+// resourceSampleInterval 资源占用采样间隔
+const resourceSampleInterval = 500 * time.Millisecond
+
+// Runner 一次压测
+type Runner struct {
+	cfg    Config
+	frames [][]byte
+	stats  *collector
+	port   int // 本地 SIP 端口
+	once   sync.Once
+}
+
+// New 校验参数并加载音频素材
+func New(cfg Config) (*Runner, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	frames, err := loadFrames(cfg.PCMFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LocalIP == "" {
+		cfg.LocalIP = localIPFor(cfg.Target)
+	}
+	return &Runner{cfg: cfg, frames: frames, stats: newCollector(cfg)}, nil
+}
+
+// Snapshot 当前进度，可在运行中调用
+func (r *Runner) Snapshot() *Report {
+	return r.stats.snapshot()
+}
+
+// Run 执行压测直到所有 UA 完成呼叫、Duration 到期或 ctx 取消，返回最终报告
+func (r *Runner) Run(ctx context.Context) (report *Report, err error) {
+	started := false
+	r.once.Do(func() { started = true })
+	if !started {
+		return nil, errors.New("load test runner can only run once")
+	}
+	defer func() {
+		r.stats.sampleResources()
+		r.stats.update(func(rep *Report) {
+			now := time.Now()
+			rep.Running = false
+			rep.FinishedAt = &now
+			if err != nil {
+				rep.Error = err.Error()
+			}
+		})
+		report = r.stats.snapshot()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Duration)
+	defer cancel()
+
+	ua, err := sipgo.NewUA(sipgo.WithUserAgent("LingEcho-LoadTest"), sipgo.WithUserAgentHostname(r.cfg.LocalIP))
+	if err != nil {
+		return nil, fmt.Errorf("create user agent: %w", err)
+	}
+	defer ua.Close()
+
+	port, err := freeUDPPort(r.cfg.LocalIP)
+	if err != nil {
+		return nil, err
+	}
+	r.port = port
+	client, err := sipgo.NewClient(ua, sipgo.WithClientHostname(r.cfg.LocalIP), sipgo.WithClientPort(port))
+	if err != nil {
+		return nil, fmt.Errorf("create client: %w", err)
+	}
+	server, err := sipgo.NewServer(ua)
+	if err != nil {
+		return nil, fmt.Errorf("create server: %w", err)
+	}
+
+	contact := sip.ContactHeader{Address: sip.Uri{User: r.cfg.UserPrefix, Host: r.cfg.LocalIP, Port: port}}
+	dialogs := sipgo.NewDialogClient(client, contact)
+	server.OnBye(func(req *sip.Request, tx sip.ServerTransaction) {
+		if err := dialogs.ReadBye(req, tx); err != nil {
+			tx.Respond(sip.NewResponseFromRequest(req, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist", nil))
+		}
+	})
+	server.OnOptions(func(req *sip.Request, tx sip.ServerTransaction) {
+		tx.Respond(sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil))
+	})
+
+	ready := make(chan struct{})
+	listenErr := make(chan error, 1)
+	go func() {
+		listenCtx := context.WithValue(ctx, sipgo.ListenReadyCtxKey, sipgo.ListenReadyCtxValue(ready))
+		listenErr <- server.ListenAndServe(listenCtx, "udp", net.JoinHostPort(r.cfg.LocalIP, strconv.Itoa(port)))
+	}()
+	select {
+	case <-ready:
+	case err := <-listenErr:
+		return nil, fmt.Errorf("listen on %s:%d: %w", r.cfg.LocalIP, port, err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"target": r.cfg.Target,
+		"users":  r.cfg.Users,
+		"local":  net.JoinHostPort(r.cfg.LocalIP, strconv.Itoa(port)),
+	}).Info("SIP load test started")
+
+	samplerDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(resourceSampleInterval)
+		defer ticker.Stop()
+		for {
+			r.stats.sampleResources()
+			select {
+			case <-ticker.C:
+			case <-samplerDone:
+				return
+			}
+		}
+	}()
+	defer close(samplerDone)
+
+	var step time.Duration
+	if r.cfg.Users > 1 {
+		step = r.cfg.RampUp / time.Duration(r.cfg.Users-1)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < r.cfg.Users; i++ {
+		if i > 0 && step > 0 {
+			select {
+			case <-time.After(step):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.runUser(ctx, client, dialogs, fmt.Sprintf("%s%04d", r.cfg.UserPrefix, i+1))
+		}(i)
+	}
+	wg.Wait()
+
+	logrus.WithField("target", r.cfg.Target).Info("SIP load test finished")
+	return nil, nil
+}
+
+// runUser 单个模拟 UA：注册后按配置循环呼叫
+func (r *Runner) runUser(ctx context.Context, client *sipgo.Client, dialogs *sipgo.DialogClient, user string) {
+	r.stats.update(func(rep *Report) { rep.ActiveUsers++ })
+	defer r.stats.update(func(rep *Report) { rep.ActiveUsers-- })
+
+	if r.cfg.Register {
+		if !r.register(ctx, client, user) {
+			return
+		}
+	}
+	for n := 0; r.cfg.CallsPerUser < 0 || n < r.cfg.CallsPerUser; n++ {
+		if ctx.Err() != nil {
+			return
+		}
+		r.call(ctx, dialogs, user)
+		if r.cfg.Pause > 0 {
+			select {
+			case <-time.After(r.cfg.Pause):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// register 发送 REGISTER 并等待最终响应
+func (r *Runner) register(ctx context.Context, client *sipgo.Client, user string) bool {
+	host, port := r.cfg.targetHostPort()
+	req := sip.NewRequest(sip.REGISTER, &sip.Uri{Host: host, Port: port})
+	aor := sip.Uri{User: user, Host: host}
+	from := &sip.FromHeader{Address: aor, Params: sip.NewParams()}
+	from.Params.Add("tag", sip.GenerateTagN(16))
+	req.AppendHeader(from)
+	req.AppendHeader(&sip.ToHeader{Address: aor, Params: sip.NewParams()})
+	req.AppendHeader(&sip.ContactHeader{Address: sip.Uri{User: user, Host: r.cfg.LocalIP, Port: r.port}})
+	req.AppendHeader(sip.NewHeader("Expires", "3600"))
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.CallTimeout)
+	defer cancel()
+	start := time.Now()
+	tx, err := client.TransactionRequest(ctx, req)
+	if err != nil {
+		r.stats.registered(0, "transport")
+		return false
+	}
+	defer tx.Terminate()
+	for {
+		select {
+		case res := <-tx.Responses():
+			if res.IsProvisional() {
+				continue
+			}
+			if !res.IsSuccess() {
+				r.stats.registered(0, strconv.Itoa(int(res.StatusCode)))
+				return false
+			}
+			r.stats.registered(time.Since(start), "")
+			return true
+		case <-tx.Done():
+			r.stats.registered(0, "transport")
+			return false
+		case <-ctx.Done():
+			r.stats.registered(0, "timeout")
+			return false
+		}
+	}
+}
+
+// call 完成一通呼叫：INVITE、ACK、收发 RTP、BYE
+func (r *Runner) call(ctx context.Context, dialogs *sipgo.DialogClient, user string) {
+	r.stats.update(func(rep *Report) { rep.CallsAttempted++ })
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(r.cfg.LocalIP)})
+	if err != nil {
+		r.stats.failed("rtp_socket")
+		return
+	}
+	defer conn.Close()
+	media := &mediaStream{conn: conn, frames: r.frames}
+	defer func() {
+		r.stats.update(func(rep *Report) {
+			rep.RTPPacketsSent += media.sent.Load()
+			rep.RTPPacketsReceived += media.received.Load()
+		})
+	}()
+
+	host, port := r.cfg.targetHostPort()
+	req := sip.NewRequest(sip.INVITE, &sip.Uri{User: r.cfg.Callee, Host: host, Port: port})
+	from := &sip.FromHeader{Address: sip.Uri{User: user, Host: host}, Params: sip.NewParams()}
+	from.Params.Add("tag", sip.GenerateTagN(16))
+	req.AppendHeader(from)
+	contentType := sip.ContentTypeHeader("application/sdp")
+	req.AppendHeader(&contentType)
+	req.SetBody(offerSDP(r.cfg.LocalIP, conn.LocalAddr().(*net.UDPAddr).Port))
+
+	start := time.Now()
+	session, err := dialogs.WriteInvite(ctx, req)
+	if err != nil {
+		r.stats.failed("transport")
+		return
+	}
+	defer session.Close()
+
+	answerCtx, cancel := context.WithTimeout(ctx, r.cfg.CallTimeout)
+	err = session.WaitAnswer(answerCtx, sipgo.AnswerOptions{})
+	cancel()
+	if err != nil {
+		r.stats.failed(failureReason(err))
+		return
+	}
+	r.stats.answered(time.Since(start))
+
+	if err := session.Ack(ctx); err != nil {
+		r.stats.failed("ack")
+		return
+	}
+	remote, err := answerRTPAddr(session.InviteResponse.Body())
+	if err != nil {
+		r.stats.failed("sdp")
+		r.hangup(session)
+		return
+	}
+	media.remote = remote
+
+	r.stats.update(func(rep *Report) { rep.ActiveCalls++ })
+	defer r.stats.update(func(rep *Report) { rep.ActiveCalls-- })
+
+	go media.receive()
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-session.Done():
+		case <-ctx.Done():
+		}
+		close(stop)
+	}()
+	if media.send(r.cfg.CallHold, stop) {
+		select {
+		case <-session.Done():
+			r.stats.update(func(rep *Report) { rep.RemoteHangups++ })
+			return
+		default:
+		}
+	}
+	r.hangup(session)
+}
+
+// hangup 发送 BYE
+func (r *Runner) hangup(session *sipgo.DialogClientSession) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.CallTimeout)
+	defer cancel()
+	if err := session.Bye(ctx); err != nil {
+		r.stats.failed("bye")
+		return
+	}
+	r.stats.update(func(rep *Report) { rep.CallsCompleted++ })
+}
+
+// failureReason 失败原因：SIP 状态码、timeout 或 transport
+func failureReason(err error) string {
+	var res *sipgo.ErrDialogResponse
+	if errors.As(err, &res) && res.Res != nil {
+		return strconv.Itoa(int(res.Res.StatusCode))
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return "timeout"
+	}
+	return "transport"
+}
+
+// freeUDPPort 选一个空闲的本地 UDP 端口
+func freeUDPPort(ip string) (int, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(ip)})
+	if err != nil {
+		return 0, fmt.Errorf("allocate local port: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port, nil
+}
@@ -0,0 +1,81 @@
+package sip
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/mediastream"
+	"github.com/code-100-precent/LingEcho/pkg/sip/codec"
+	"github.com/pion/rtp"
+	"github.com/sirupsen/logrus"
+)
+
+// openMediaStream makes the call available to external media streams and
+// starts playing the audio they inject
+func (h *VoiceConversationHandler) openMediaStream() {
+	h.media = mediastream.Default().OpenCall(h.callID)
+	h.wg.Add(1)
+	go h.playInjectedAudio()
+}
+
+// publishMedia hands the caller's audio to the attached streams, decoding only when someone listens
+func (h *VoiceConversationHandler) publishMedia(pcmu []byte) {
+	if h.media == nil || !h.media.Streaming() {
+		return
+	}
+	h.media.Publish(codec.PCMUToPCM16(pcmu))
+}
+
+// playInjectedAudio plays the audio injected by duplex streams in real time
+func (h *VoiceConversationHandler) playInjectedAudio() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	var pending []byte
+	for {
+		if len(pending) == 0 {
+			select {
+			case <-h.ctx.Done():
+				return
+			case <-h.media.Done():
+				return
+			case frame := <-h.media.Injected():
+				pending = codec.PCM16ToPCMU(frame)
+			}
+		}
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n := min(len(pending), 160)
+		h.writePCMUFrame(pending[:n])
+		pending = pending[n:]
+	}
+}
+
+// writePCMUFrame sends one packet of at most 20ms on the call's RTP leg
+func (h *VoiceConversationHandler) writePCMUFrame(payload []byte) {
+	h.rtpMutex.Lock()
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    0, // PCMU
+			SequenceNumber: h.rtpSeqNum,
+			Timestamp:      h.rtpTimestamp,
+			SSRC:           h.rtpSSRC,
+		},
+		Payload: payload,
+	}
+	h.rtpSeqNum++
+	h.rtpTimestamp += uint32(len(payload))
+	h.rtpMutex.Unlock()
+
+	data, err := packet.Marshal()
+	if err != nil {
+		return
+	}
+	if _, err := h.rtpConn.WriteToUDP(data, h.rtpTarget()); err != nil {
+		logrus.WithError(err).WithField("call_id", h.callID).Warn("Failed to send injected audio")
+	}
+}
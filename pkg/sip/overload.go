@@ -0,0 +1,144 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/metrics"
+	"github.com/code-100-precent/LingEcho/pkg/overload"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults of the INVITE admission control, overridable from the environment
+const (
+	defaultInviteWorkers     = 32
+	defaultInviteQueue       = 128
+	defaultUnregisteredShare = 0.8
+	defaultOverloadRetry     = 30 * time.Second
+)
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// inviteOverloadConfig limits of inbound INVITEs:
+//   - SIP_MAX_SESSIONS concurrent inbound calls, 0 (default) for unlimited
+//   - SIP_INVITE_WORKERS INVITEs processed at once, default 32
+//   - SIP_INVITE_QUEUE INVITEs waiting for a worker, default 128
+//   - SIP_INVITE_QUEUE_TIMEOUT longest wait in the queue in seconds, default 5
+//   - SIP_UNREGISTERED_SHARE share of the sessions and queue available to callers
+//     that are not registered users, default 0.8; 1 disables the reservation
+func inviteOverloadConfig() overload.Config {
+	return overload.Config{
+		MaxSessions:      envInt("SIP_MAX_SESSIONS", 0),
+		MaxInFlight:      envInt("SIP_INVITE_WORKERS", defaultInviteWorkers),
+		QueueSize:        envInt("SIP_INVITE_QUEUE", defaultInviteQueue),
+		QueueTimeout:     time.Duration(envInt("SIP_INVITE_QUEUE_TIMEOUT", 5)) * time.Second,
+		LowPriorityShare: envFloat("SIP_UNREGISTERED_SHARE", defaultUnregisteredShare),
+	}
+}
+
+// overloadRetryAfter Retry-After of the 503 sent when overloaded, SIP_OVERLOAD_RETRY_AFTER seconds
+func overloadRetryAfter() time.Duration {
+	return time.Duration(envInt("SIP_OVERLOAD_RETRY_AFTER", int(defaultOverloadRetry.Seconds()))) * time.Second
+}
+
+// admitInvite applies admission control to an inbound INVITE. Registered users
+// are served first and keep a share of the capacity; everyone else is shed
+// earlier. In-dialog re-INVITEs are not counted and get a nil ticket.
+// It reports whether the INVITE may be processed, a 503 has been sent otherwise.
+func (as *SipServer) admitInvite(req *sip.Request, tx sip.ServerTransaction) (*overload.Ticket, bool) {
+	if to := req.To(); to != nil && to.Params.Has("tag") {
+		return nil, true
+	}
+
+	callID := req.CallID().Value()
+	priority := overload.Low
+	if from := req.From(); from != nil && as.isRegisteredUser(from.Address.User) {
+		priority = overload.High
+	}
+
+	// Stop waiting when the caller cancels the INVITE
+	ctx, cancel := context.WithCancel(context.Background())
+	as.aiLimitMutex.Lock()
+	as.queuedCalls[callID] = cancel
+	as.aiLimitMutex.Unlock()
+
+	ticket, err := as.admission.Admit(ctx, callID, priority)
+
+	as.aiLimitMutex.Lock()
+	delete(as.queuedCalls, callID)
+	as.aiLimitMutex.Unlock()
+	cancel()
+
+	recordInviteAdmission(as.admission, priority, err)
+	if err == nil {
+		return ticket, true
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":  callID,
+		"priority": priority.String(),
+	})
+	if errors.Is(err, context.Canceled) {
+		log.Info("Caller cancelled the INVITE while queued")
+		res := sip.NewResponseFromRequest(req, sip.StatusRequestTerminated, "Request Terminated", nil)
+		if err := tx.Respond(res); err != nil {
+			log.WithError(err).Warn("Failed to send 487 Request Terminated")
+		}
+		return nil, false
+	}
+
+	log.WithError(err).Warn("Shedding INVITE, server overloaded")
+	res := sip.NewResponseFromRequest(req, sip.StatusServiceUnavailable, "Service Unavailable", nil)
+	res.AppendHeader(sip.NewHeader("Retry-After", strconv.Itoa(int(overloadRetryAfter().Seconds()))))
+	if err := tx.Respond(res); err != nil {
+		log.WithError(err).Error("Failed to send 503 Service Unavailable")
+	}
+	return nil, false
+}
+
+// releaseInviteSession frees the session slot held by an inbound call, safe to call repeatedly
+func (as *SipServer) releaseInviteSession(callID string) {
+	as.admission.Release(callID)
+}
+
+// AdmissionStats current inbound load and shedding counters
+func (as *SipServer) AdmissionStats() overload.Stats {
+	return as.admission.Stats()
+}
+
+// recordInviteAdmission exports the admission outcome and the current inbound load
+func recordInviteAdmission(c *overload.Controller, priority overload.Priority, err error) {
+	outcome := "admitted"
+	switch {
+	case errors.Is(err, overload.ErrSaturated):
+		outcome = "saturated"
+	case errors.Is(err, overload.ErrQueueFull):
+		outcome = "queue_full"
+	case errors.Is(err, overload.ErrWaitTimeout):
+		outcome = "timeout"
+	case err != nil:
+		outcome = "abandoned"
+	}
+	m := metrics.NewMetrics()
+	m.RecordBusinessOperation("sip_invite", outcome, priority.String())
+	stats := c.Stats()
+	m.SetBusinessMetric("sip_sessions_active", "inbound", float64(stats.Sessions))
+	m.SetBusinessMetric("sip_invites_in_flight", "inbound", float64(stats.InFlight))
+	m.SetBusinessMetric("sip_invites_queued", "inbound", float64(stats.Queued))
+}
@@ -0,0 +1,105 @@
+package sip
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/presence"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+// presenceSubject subject whose presence is busy because of a call
+type presenceSubject struct {
+	kind string
+	id   string
+}
+
+// agentPresence returns the presence of a SIP agent
+func agentPresence(username string) presence.State {
+	return presence.Default().Get(presence.KindSIP, username)
+}
+
+// rejectUnavailable answers an INVITE for a busy or DND agent instead of ringing it
+func (as *SipServer) rejectUnavailable(req *sip.Request, tx sip.ServerTransaction, state presence.State) {
+	code, reason := sip.StatusTemporarilyUnavailable, "Temporarily Unavailable"
+	if state.Status == presence.StatusBusy {
+		code, reason = sip.StatusBusyHere, "Busy Here"
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"call_id":  req.CallID().Value(),
+		"agent":    state.ID,
+		"presence": state.Status,
+	}).Info("Callee is not available, rejecting INVITE")
+
+	res := sip.NewResponseFromRequest(req, code, reason, nil)
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).Error("Failed to send unavailable response")
+	}
+}
+
+// checkOutgoingPresence refuses to dial an agent that is busy or in DND
+func checkOutgoingPresence(targetURI string) error {
+	var uri sip.Uri
+	if err := sip.ParseUri(targetURI, &uri); err != nil || uri.User == "" {
+		return nil
+	}
+	if state := agentPresence(uri.User); !state.Available() {
+		return fmt.Errorf("target %s is %s", uri.User, state.Status)
+	}
+	return nil
+}
+
+// trackPresenceCall marks the SIP agents of a call, and the users they belong
+// to, busy until the call ends
+func (as *SipServer) trackPresenceCall(callID string, usernames ...string) {
+	var subjects []presenceSubject
+	for _, username := range usernames {
+		if username == "" {
+			continue
+		}
+		subjects = append(subjects, presenceSubject{kind: presence.KindSIP, id: username})
+		if as.db != nil {
+			if sipUser, err := models.GetSipUserByUsername(as.db, username); err == nil && sipUser.UserID != nil {
+				subjects = append(subjects, presenceSubject{kind: presence.KindUser, id: strconv.FormatUint(uint64(*sipUser.UserID), 10)})
+			}
+		}
+	}
+	if len(subjects) == 0 {
+		return
+	}
+
+	as.presenceMutex.Lock()
+	if _, exists := as.presenceCalls[callID]; exists {
+		as.presenceMutex.Unlock()
+		return
+	}
+	as.presenceCalls[callID] = subjects
+	as.presenceMutex.Unlock()
+
+	for _, s := range subjects {
+		presence.Default().CallStarted(s.kind, s.id)
+	}
+}
+
+// releasePresenceCall ends the busy state set by trackPresenceCall, safe to call repeatedly
+func (as *SipServer) releasePresenceCall(callID string) {
+	as.presenceMutex.Lock()
+	subjects := as.presenceCalls[callID]
+	delete(as.presenceCalls, callID)
+	as.presenceMutex.Unlock()
+
+	for _, s := range subjects {
+		presence.Default().CallEnded(s.kind, s.id)
+	}
+}
+
+// isRegisteredUser reports whether the username is a registered SIP agent
+func (as *SipServer) isRegisteredUser(username string) bool {
+	as.registerMutex.RLock()
+	defer as.registerMutex.RUnlock()
+	_, ok := as.registeredUsers[username]
+	return ok
+}
@@ -0,0 +1,290 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// errStageTimeout is returned when a provider did not answer within the stage budget
+var errStageTimeout = errors.New("provider exceeded the stage budget")
+
+// voicemailOffer is appended to the apology when the scheme records voicemail
+const voicemailOffer = "。您有15秒的时间进行留言"
+
+// guardedLLM is one LLM the guard may use, named after the credential's provider
+type guardedLLM struct {
+	name     string
+	provider llm.LLMProvider
+}
+
+// guardedTTS is one TTS service the guard may use
+type guardedTTS struct {
+	name    string
+	service synthesizer.SynthesisService
+}
+
+// promptAudio is PCM16 audio together with the sample rate of the TTS that produced it
+type promptAudio struct {
+	pcm        []byte
+	sampleRate int
+}
+
+// providerGuard bounds how long a turn waits on the LLM and TTS. When a provider
+// exceeds its budget or fails, the caller hears the filler prompt while the next
+// provider is tried; when every provider fails the turn ends with the apology and,
+// if the scheme records, voicemail. Filler and apology are synthesized when the
+// call starts so they still play while the TTS is down.
+type providerGuard struct {
+	llmBudget   time.Duration
+	ttsBudget   time.Duration
+	llms        []guardedLLM // primary first
+	ttss        []guardedTTS // primary first
+	fillerText  string
+	apologyText string
+	db          *gorm.DB
+
+	filler  *promptAudio
+	apology *promptAudio
+	ready   chan struct{} // closed once filler and apology are synthesized
+
+	fillerDone chan struct{} // playback of the filler in the current turn, nil if not played
+}
+
+func newProviderGuard(cfg models.ProviderGuard, db *gorm.DB, llms []guardedLLM, ttss []guardedTTS) *providerGuard {
+	return &providerGuard{
+		llmBudget:   time.Duration(cfg.LLMBudgetMs) * time.Millisecond,
+		ttsBudget:   time.Duration(cfg.TTSBudgetMs) * time.Millisecond,
+		llms:        llms,
+		ttss:        ttss,
+		fillerText:  cfg.FillerPrompt,
+		apologyText: cfg.ApologyPrompt,
+		db:          db,
+		ready:       make(chan struct{}),
+	}
+}
+
+// withinBudget runs call and gives up after budget. The call keeps running in the
+// background; its result is discarded.
+func withinBudget[T any](budget time.Duration, call func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := call()
+		done <- result{v, err}
+	}()
+
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-timer.C:
+		var zero T
+		return zero, errStageTimeout
+	}
+}
+
+// prepare synthesizes the filler and the apology ahead of any outage
+func (g *providerGuard) prepare(callID string, offerVoicemail bool) {
+	defer close(g.ready)
+
+	apology := g.apologyText
+	if offerVoicemail {
+		apology += voicemailOffer
+	}
+	if g.fillerText != "" {
+		g.filler = g.preSynthesize(callID, g.fillerText)
+	}
+	g.apology = g.preSynthesize(callID, apology)
+}
+
+func (g *providerGuard) preSynthesize(callID, text string) *promptAudio {
+	for _, tts := range g.ttss {
+		audio, err := g.synthesizeWith(tts, text)
+		if err == nil {
+			return audio
+		}
+		logrus.WithFields(logrus.Fields{
+			"call_id":  callID,
+			"provider": tts.name,
+			"error":    err,
+		}).Warn("failed to pre-synthesize guard prompt")
+	}
+	return nil
+}
+
+func (g *providerGuard) synthesizeWith(tts guardedTTS, text string) (*promptAudio, error) {
+	return withinBudget(g.ttsBudget, func() (*promptAudio, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), g.ttsBudget)
+		defer cancel()
+		buf := &synthesizer.SynthesisBuffer{}
+		if err := tts.service.Synthesize(ctx, buf, text); err != nil {
+			return nil, err
+		}
+		return &promptAudio{pcm: buf.Data, sampleRate: tts.service.Format().SampleRate}, nil
+	})
+}
+
+// record counts an incident for the provider; a nil err counts a successful failover
+func (g *providerGuard) record(callID, provider, stage string, err error) {
+	kind := models.ProviderIncidentFailover
+	detail := ""
+	if errors.Is(err, errStageTimeout) {
+		kind = models.ProviderIncidentTimeout
+	} else if err != nil {
+		kind = models.ProviderIncidentError
+		detail = err.Error()
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"call_id":  callID,
+			"provider": provider,
+			"stage":    stage,
+			"error":    err,
+		}).Warn("voice pipeline provider failed")
+	}
+	if g.db == nil {
+		return
+	}
+	if dbErr := models.RecordProviderIncident(g.db, provider, stage, kind, detail, time.Now()); dbErr != nil {
+		logrus.WithError(dbErr).Warn("failed to record provider incident")
+	}
+}
+
+// beginTurn forgets the filler of the previous turn
+func (g *providerGuard) beginTurn() {
+	g.fillerDone = nil
+}
+
+// playFiller starts the filler once per turn while the next provider is tried
+func (h *VoiceConversationHandler) playFiller() {
+	g := h.guard
+	if g.fillerDone != nil {
+		return
+	}
+	select {
+	case <-g.ready:
+	default:
+		return // still synthesizing, nothing to play yet
+	}
+	if g.filler == nil {
+		return
+	}
+	done := make(chan struct{})
+	g.fillerDone = done
+	go func() {
+		defer close(done)
+		h.sendAudioAtRate(g.filler.pcm, g.filler.sampleRate)
+	}()
+}
+
+// awaitFiller waits for the filler so its packets do not interleave with the reply
+func (h *VoiceConversationHandler) awaitFiller() {
+	if h.guard != nil && h.guard.fillerDone != nil {
+		<-h.guard.fillerDone
+	}
+}
+
+// queryLLM asks each LLM in turn, the primary first, within the LLM budget
+func (h *VoiceConversationHandler) queryLLM(text string) (string, error) {
+	if h.guard == nil {
+		return h.llmProvider.Query(text, "")
+	}
+	g := h.guard
+	var lastErr error
+	for i, p := range g.llms {
+		if i > 0 {
+			h.playFiller()
+		}
+		reply, err := withinBudget(g.llmBudget, func() (string, error) {
+			return p.provider.Query(text, "")
+		})
+		if err == nil {
+			if i > 0 {
+				g.record(h.callID, p.name, models.ProviderStageLLM, nil)
+			}
+			return reply, nil
+		}
+		g.record(h.callID, p.name, models.ProviderStageLLM, err)
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// synthesize runs each TTS in turn, the primary first, within the TTS budget
+func (h *VoiceConversationHandler) synthesize(text string) (*promptAudio, error) {
+	if h.guard == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		buf := &synthesizer.SynthesisBuffer{}
+		if err := h.ttsService.Synthesize(ctx, buf, text); err != nil {
+			return nil, err
+		}
+		return &promptAudio{pcm: buf.Data, sampleRate: h.ttsService.Format().SampleRate}, nil
+	}
+	g := h.guard
+	var lastErr error
+	for i, tts := range g.ttss {
+		if i > 0 {
+			h.playFiller()
+		}
+		audio, err := g.synthesizeWith(tts, text)
+		if err == nil {
+			if i > 0 {
+				g.record(h.callID, tts.name, models.ProviderStageTTS, nil)
+			}
+			return audio, nil
+		}
+		g.record(h.callID, tts.name, models.ProviderStageTTS, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// apologize plays the apology after every provider failed and offers voicemail when
+// the scheme records, otherwise ends the call. Without a guard the turn is skipped
+// as before.
+func (h *VoiceConversationHandler) apologize() {
+	if h.guard == nil {
+		return
+	}
+	h.awaitFiller()
+	var apology *promptAudio
+	select {
+	case <-h.guard.ready:
+		apology = h.guard.apology
+	case <-time.After(h.guard.ttsBudget):
+	}
+
+	logrus.WithField("call_id", h.callID).Warn("all voice pipeline providers failed, apologizing")
+	if apology != nil {
+		h.sendAudioAtRate(apology.pcm, apology.sampleRate)
+	}
+	if h.sipUser != nil && h.sipUser.RecordingEnabled {
+		h.enterMessageMode()
+	} else {
+		h.finish()
+	}
+}
+
+// enableProviderGuard puts the LLM and TTS of every turn under the guard
+func (h *VoiceConversationHandler) enableProviderGuard(g *providerGuard) {
+	h.guard = g
+	go g.prepare(h.callID, h.sipUser != nil && h.sipUser.RecordingEnabled)
+	logrus.WithFields(logrus.Fields{
+		"call_id":    h.callID,
+		"llm_budget": g.llmBudget,
+		"tts_budget": g.ttsBudget,
+		"failover":   len(g.llms) > 1 || len(g.ttss) > 1,
+	}).Info("provider guard enabled")
+}
@@ -0,0 +1,431 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/sessionlimit"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// callbackOfferWindow time the caller has to choose once the offer starts,
+	// the place in the queue is kept meanwhile
+	callbackOfferWindow = time.Minute
+	// callbackDigitTimeout time to wait for the next key
+	callbackDigitTimeout = 10 * time.Second
+	// callbackConfirmDigit ends the number, alone it confirms the caller's own number
+	callbackConfirmDigit = "#"
+	// callbackDeclineDigit keeps waiting on the line
+	callbackDeclineDigit = "*"
+	maxCallbackDigits    = 20
+)
+
+// acquiredSlot result of waiting for an assistant slot
+type acquiredSlot struct {
+	ticket *sessionlimit.Ticket
+	err    error
+}
+
+// callbackOffer callback offered to a queued caller. Whichever comes first
+// wins: the caller accepting the callback or a slot becoming free.
+type callbackOffer struct {
+	req           *sip.Request
+	tx            sip.ServerTransaction
+	clientRTPAddr string
+	assistant     *models.Assistant
+	sipUser       *models.SipUser
+	queuedAt      time.Time
+	queueTimeout  time.Duration
+	hold          chan time.Duration // extends the wait of the queued caller
+	accepted      chan struct{}      // closed when the caller accepted the callback
+
+	mu       sync.Mutex
+	closed   bool // the wait for a slot ended before the caller accepted
+	callback *models.SipCallback
+	cancel   context.CancelFunc
+	timer    *time.Timer
+}
+
+// offerCallback schedules the callback offer of a queued AI call; nil when the
+// assistant does not offer callbacks or the offer would come after the queue timeout
+func (as *SipServer) offerCallback(req *sip.Request, tx sip.ServerTransaction, clientRTPAddr string, assistant *models.Assistant, sipUser *models.SipUser) *callbackOffer {
+	after := assistant.CallbackOffer()
+	if after == 0 || as.db == nil || sipUser == nil {
+		return nil
+	}
+	timeout := assistant.SessionLimit().QueueTimeout
+	if timeout <= 0 {
+		timeout = sessionlimit.DefaultQueueTimeout
+	}
+	if after >= timeout {
+		return nil
+	}
+	if _, err := os.Stat(assistant.CallbackPromptFile); err != nil {
+		logrus.WithError(err).WithField("assistant_id", assistant.ID).Warn("Callback prompt missing, callbacks are not offered")
+		return nil
+	}
+	o := &callbackOffer{
+		req:           req,
+		tx:            tx,
+		clientRTPAddr: clientRTPAddr,
+		assistant:     assistant,
+		sipUser:       sipUser,
+		queuedAt:      time.Now(),
+		queueTimeout:  timeout,
+		hold:          make(chan time.Duration, 2),
+		accepted:      make(chan struct{}),
+	}
+	o.timer = time.AfterFunc(after, func() { as.runCallbackOffer(o) })
+	return o
+}
+
+// holdChan the channel extending the wait, nil without an offer
+func (o *callbackOffer) holdChan() <-chan time.Duration {
+	if o == nil {
+		return nil
+	}
+	return o.hold
+}
+
+// acceptedChan closed once the caller accepted, nil without an offer
+func (o *callbackOffer) acceptedChan() <-chan struct{} {
+	if o == nil {
+		return nil
+	}
+	return o.accepted
+}
+
+// extend changes the remaining wait; the waiter may be gone already
+func (o *callbackOffer) extend(d time.Duration) {
+	select {
+	case o.hold <- d:
+	default:
+	}
+}
+
+// close ends the offer because the wait ended, reports whether the caller
+// accepted the callback before that
+func (o *callbackOffer) close() bool {
+	if o == nil {
+		return false
+	}
+	o.timer.Stop()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.callback != nil {
+		return true
+	}
+	o.closed = true
+	if o.cancel != nil {
+		o.cancel()
+	}
+	return false
+}
+
+// runCallbackOffer plays the callback prompt as early media and collects the
+// caller's choice: # alone to be called back at the calling number, a number
+// followed by # for another number, * to keep waiting
+func (as *SipServer) runCallbackOffer(o *callbackOffer) {
+	callID := o.req.CallID().Value()
+	ctx, cancel := context.WithTimeout(context.Background(), callbackOfferWindow)
+	defer cancel()
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return
+	}
+	o.cancel = cancel
+	o.mu.Unlock()
+
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":      callID,
+		"assistant_id": o.assistant.ID,
+	})
+	// The caller must not time out while choosing
+	o.extend(callbackOfferWindow)
+
+	callback := &models.SipCallback{
+		CallID:       callID,
+		UserID:       o.sipUser.UserID,
+		GroupID:      o.sipUser.GroupID,
+		AssistantID:  o.assistant.ID,
+		SipUserID:    o.sipUser.ID,
+		CallerNumber: o.req.From().Address.User,
+		QueueWait:    int(time.Since(o.queuedAt).Seconds()),
+		OfferedAt:    time.Now(),
+	}
+
+	serverIP := getServerIPFromRequest(o.req)
+	sdpBytes := []byte(generateSDP(serverIP, as.RPTPort, sdpOffersComfortNoise(string(o.req.Body()))))
+	progress := sip.NewResponseFromRequest(o.req, sip.StatusSessionInProgress, "Session Progress", sdpBytes)
+	cl := sip.ContentLengthHeader(len(sdpBytes))
+	progress.AppendHeader(&cl)
+	contentType := sip.ContentTypeHeader("application/sdp")
+	progress.AppendHeader(&contentType)
+	if err := o.tx.Respond(progress); err != nil {
+		log.WithError(err).Warn("Failed to send early media for the callback offer")
+		return
+	}
+
+	dtmf := as.beginCallbackPrompt(callID)
+	defer as.endCallbackPrompt(callID)
+	log.Info("Offering a callback to the queued caller")
+	go as.sendAudioFromFileWithContext(o.clientRTPAddr, o.assistant.CallbackPromptFile, 160, ctx)
+
+	number, status := collectCallbackNumber(ctx, dtmf, callback.CallerNumber)
+	if status != models.SipCallbackWaiting {
+		// Back to the remaining queue time
+		if remaining := o.queueTimeout - time.Since(o.queuedAt); remaining > time.Second {
+			o.extend(remaining)
+		} else {
+			o.extend(time.Second)
+		}
+		callback.Status = status
+		as.saveCallbackOffer(callback)
+		return
+	}
+
+	now := time.Now()
+	callback.CallbackNumber = number
+	callback.Status = models.SipCallbackWaiting
+	callback.AcceptedAt = &now
+	o.mu.Lock()
+	if o.closed {
+		// A slot became free while the caller was choosing, the call is answered normally
+		o.mu.Unlock()
+		callback.Status, callback.AcceptedAt = models.SipCallbackNoResponse, nil
+		callback.Error = "admitted before the callback was confirmed"
+		as.saveCallbackOffer(callback)
+		return
+	}
+	o.callback = callback
+	o.mu.Unlock()
+
+	o.extend(models.DefaultCallbackHold)
+	as.saveCallbackOffer(callback)
+	res := sip.NewResponseFromRequest(o.req, sip.StatusBusyHere, "Callback Scheduled", nil)
+	if err := o.tx.Respond(res); err != nil {
+		log.WithError(err).Warn("Failed to end the call after the callback was accepted")
+	}
+	log.WithField("callback_number", number).Info("Caller accepted a callback, place in the queue kept")
+	close(o.accepted)
+}
+
+// collectCallbackNumber reads the caller's keys until the number is confirmed,
+// the offer is declined or nothing is pressed in time
+func collectCallbackNumber(ctx context.Context, dtmf <-chan string, callerNumber string) (string, models.SipCallbackStatus) {
+	timer := time.NewTimer(callbackDigitTimeout)
+	defer timer.Stop()
+	number := ""
+	for {
+		select {
+		case digit := <-dtmf:
+			timer.Reset(callbackDigitTimeout)
+			switch {
+			case digit == callbackDeclineDigit:
+				return "", models.SipCallbackDeclined
+			case digit == callbackConfirmDigit:
+				if number == "" {
+					number = callerNumber
+				}
+				if models.ValidCallbackNumber(number) {
+					return number, models.SipCallbackWaiting
+				}
+				// Start over, the caller can enter another number
+				number = ""
+			case len(digit) == 1 && digit[0] >= '0' && digit[0] <= '9' && len(number) < maxCallbackDigits:
+				number += digit
+			}
+		case <-timer.C:
+			return "", models.SipCallbackNoResponse
+		case <-ctx.Done():
+			return "", models.SipCallbackNoResponse
+		}
+	}
+}
+
+// holdCallback waits for the slot of a caller who accepted a callback, then
+// calls them back and hands the call to the assistant
+func (as *SipServer) holdCallback(o *callbackOffer, slots <-chan acquiredSlot, cancel context.CancelFunc) {
+	defer cancel()
+	callback := o.callback
+	log := logrus.WithFields(logrus.Fields{
+		"call_id":      callback.CallID,
+		"assistant_id": callback.AssistantID,
+	})
+	slot := <-slots
+	if slot.err != nil {
+		status := models.SipCallbackFailed
+		if errors.Is(slot.err, sessionlimit.ErrWaitTimeout) {
+			status = models.SipCallbackExpired
+		}
+		recordAISessionAdmission(o.assistant.ID, "callback_"+string(status), 0)
+		log.WithError(slot.err).Warn("Callback expired before a slot became free")
+		as.updateCallback(callback, status, slot.err.Error())
+		return
+	}
+	recordAISessionAdmission(o.assistant.ID, "admitted", slot.ticket.Waited)
+
+	from := o.req.From().Address
+	target := sip.Uri{User: callback.CallbackNumber, Host: from.Host, Port: from.Port}
+	callback.CallbackURI = target.String()
+
+	callbackCallID, err := as.startOutgoingCall(callback.CallbackURI, func(remoteRTPAddr string, dialog aiDialog) {
+		as.bridgeCallback(callback, o, remoteRTPAddr, dialog)
+	})
+	if err != nil {
+		slot.ticket.Release()
+		log.WithError(err).Warn("Failed to call back the caller")
+		as.updateCallback(callback, models.SipCallbackFailed, err.Error())
+		return
+	}
+	callback.CallbackCallID = callbackCallID
+
+	// The slot and the outcome follow the callback call until it ends
+	as.aiLimitMutex.Lock()
+	as.aiTickets[callbackCallID] = slot.ticket
+	as.aiLimitMutex.Unlock()
+	as.callbackMutex.Lock()
+	as.callbacks[callbackCallID] = callback
+	as.callbackMutex.Unlock()
+
+	sipCall := &models.SipCall{
+		CallID:    callbackCallID,
+		Direction: models.SipCallDirectionOutbound,
+		Status:    models.SipCallStatusCalling,
+		ToURI:     callback.CallbackURI,
+		StartTime: time.Now(),
+		UserID:    callback.UserID,
+		GroupID:   callback.GroupID,
+		Notes:     "Callback for queued call " + callback.CallID,
+		Region:    models.LocalRegion(),
+	}
+	if err := models.CreateSipCall(as.db, sipCall); err != nil {
+		log.WithError(err).Warn("Failed to create callback call record")
+	}
+	as.updateCallback(callback, models.SipCallbackDialing, "")
+	log.WithField("callback_call_id", callbackCallID).Info("Calling back the caller")
+}
+
+// bridgeCallback starts the assistant on the answered callback call
+func (as *SipServer) bridgeCallback(callback *models.SipCallback, o *callbackOffer, remoteRTPAddr string, dialog aiDialog) {
+	callID := callback.CallbackCallID
+	fail := func(err error) {
+		as.callbackMutex.Lock()
+		delete(as.callbacks, callID)
+		as.callbackMutex.Unlock()
+		logrus.WithError(err).WithField("call_id", callID).Error("Failed to hand the callback to the assistant")
+		as.updateCallback(callback, models.SipCallbackFailed, err.Error())
+		if err := as.HangupOutgoingCall(callID); err != nil {
+			logrus.WithError(err).WithField("call_id", callID).Warn("Failed to hang up the callback")
+		}
+	}
+	clientAddr, err := net.ResolveUDPAddr("udp", remoteRTPAddr)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	as.aiSessionMutex.Lock()
+	as.aiSessionInfo[callID] = &AISessionInfo{
+		SipUser:   o.sipUser,
+		Assistant: o.assistant,
+		Dialog:    dialog,
+	}
+	as.aiSessionMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	_, err = as.obtainRecordingConsent(ctx, callID, remoteRTPAddr, callback.CallbackNumber)
+	cancel()
+	if err != nil {
+		return
+	}
+	if err := as.startAIVoiceSession(callID, clientAddr, o.sipUser, o.assistant, nil); err != nil {
+		fail(err)
+	}
+}
+
+// trackCallbackCall follows the status of a callback call: answered calls are
+// connected, the outcome is final once the call ends
+func (as *SipServer) trackCallbackCall(callID, status string, ended bool) {
+	as.callbackMutex.Lock()
+	callback, ok := as.callbacks[callID]
+	if ok && ended {
+		delete(as.callbacks, callID)
+	}
+	as.callbackMutex.Unlock()
+	if !ok {
+		return
+	}
+	switch {
+	case ended && callback.Status == models.SipCallbackConnected:
+		as.updateCallback(callback, models.SipCallbackCompleted, "")
+	case ended:
+		errMsg := status
+		as.outgoingMutex.RLock()
+		if session, exists := as.outgoingSessions[callID]; exists && session.Error != "" {
+			errMsg = session.Error
+		}
+		as.outgoingMutex.RUnlock()
+		as.updateCallback(callback, models.SipCallbackFailed, errMsg)
+	case status == "answered":
+		as.updateCallback(callback, models.SipCallbackConnected, "")
+	}
+}
+
+func (as *SipServer) saveCallbackOffer(callback *models.SipCallback) {
+	logrus.WithFields(logrus.Fields{
+		"call_id": callback.CallID,
+		"status":  callback.Status,
+	}).Info("Callback offer resolved")
+	if err := models.CreateSipCallback(as.db, callback); err != nil {
+		logrus.WithError(err).WithField("call_id", callback.CallID).Error("Failed to save callback offer")
+	}
+}
+
+func (as *SipServer) updateCallback(callback *models.SipCallback, status models.SipCallbackStatus, errMsg string) {
+	if err := models.UpdateSipCallbackStatus(as.db, callback, status, errMsg); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"call_id": callback.CallID,
+			"status":  status,
+		}).Error("Failed to update callback")
+	}
+}
+
+// beginCallbackPrompt routes the DTMF digits of the call to the callback offer until endCallbackPrompt
+func (as *SipServer) beginCallbackPrompt(callID string) chan string {
+	ch := make(chan string, maxCallbackDigits)
+	as.callbackMutex.Lock()
+	as.pendingCallbacks[callID] = ch
+	as.callbackMutex.Unlock()
+	return ch
+}
+
+func (as *SipServer) endCallbackPrompt(callID string) {
+	as.callbackMutex.Lock()
+	delete(as.pendingCallbacks, callID)
+	as.callbackMutex.Unlock()
+}
+
+// deliverCallbackDigit hands a DTMF digit to a callback offer, reports whether
+// the call is being offered a callback
+func (as *SipServer) deliverCallbackDigit(callID, digit string) bool {
+	as.callbackMutex.Lock()
+	defer as.callbackMutex.Unlock()
+	digits, ok := as.pendingCallbacks[callID]
+	if !ok {
+		return false
+	}
+	select {
+	case digits <- digit:
+	default:
+	}
+	return true
+}
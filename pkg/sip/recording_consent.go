@@ -0,0 +1,162 @@
+package sip
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultConsentTimeout = 10 * time.Second
+
+// pendingConsent consent prompt waiting for the DTMF acknowledgement of a call
+type pendingConsent struct {
+	digits chan string
+	cancel context.CancelFunc
+}
+
+// obtainRecordingConsent applies the recording consent policy of the call parties
+// and reports whether the call may be recorded. In two-party regions the consent
+// prompt is played and the remote party must press the accept digit; a decline,
+// a timeout or a prompt that cannot be played all suppress recording.
+// The error is set when the call ended while waiting for the acknowledgement.
+func (as *SipServer) obtainRecordingConsent(ctx context.Context, callID, rtpAddr string, numbers ...string) (bool, error) {
+	if as.db == nil {
+		return true, nil
+	}
+
+	policy, err := models.MatchRecordingConsentPolicy(as.db, numbers...)
+	if err != nil {
+		// Fail closed, an unknown jurisdiction may require consent
+		logrus.WithError(err).WithField("call_id", callID).Error("Failed to load recording consent policies, recording suppressed")
+		return false, nil
+	}
+	if policy == nil {
+		return true, nil
+	}
+	if policy.Mode != models.RecordingConsentTwoParty {
+		as.saveConsent(callID, policy.Region, models.RecordingConsentNotRequired, "")
+		return true, nil
+	}
+
+	if policy.PromptFile == "" {
+		as.saveConsent(callID, policy.Region, models.RecordingConsentUnavailable, "")
+		return false, nil
+	}
+	if _, err := os.Stat(policy.PromptFile); err != nil {
+		logrus.WithError(err).WithField("call_id", callID).Warn("Recording consent prompt missing, recording suppressed")
+		as.saveConsent(callID, policy.Region, models.RecordingConsentUnavailable, "")
+		return false, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dtmf := as.beginConsent(callID, cancel)
+	defer as.endConsent(callID)
+
+	logrus.WithFields(logrus.Fields{
+		"call_id": callID,
+		"region":  policy.Region,
+	}).Info("Playing recording consent prompt")
+	as.sendAudioFromFileWithContext(rtpAddr, policy.PromptFile, 160, ctx)
+
+	timeout := defaultConsentTimeout
+	if policy.TimeoutSeconds > 0 {
+		timeout = time.Duration(policy.TimeoutSeconds) * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case digit := <-dtmf:
+			switch digit {
+			case policy.AcceptDigit:
+				as.saveConsent(callID, policy.Region, models.RecordingConsentGranted, digit)
+				return true, nil
+			case policy.DeclineDigit:
+				as.saveConsent(callID, policy.Region, models.RecordingConsentDeclined, digit)
+				return false, nil
+			}
+		case <-timer.C:
+			as.saveConsent(callID, policy.Region, models.RecordingConsentTimeout, "")
+			return false, nil
+		case <-ctx.Done():
+			as.saveConsent(callID, policy.Region, models.RecordingConsentTimeout, "")
+			return false, ctx.Err()
+		}
+	}
+}
+
+// beginConsent routes the DTMF digits of the call to the consent prompt until endConsent
+func (as *SipServer) beginConsent(callID string, cancel context.CancelFunc) chan string {
+	ch := make(chan string, 4)
+	as.consentMutex.Lock()
+	as.pendingConsents[callID] = &pendingConsent{digits: ch, cancel: cancel}
+	as.consentMutex.Unlock()
+	return ch
+}
+
+func (as *SipServer) endConsent(callID string) {
+	as.consentMutex.Lock()
+	delete(as.pendingConsents, callID)
+	as.consentMutex.Unlock()
+}
+
+// abortConsent stops waiting for the consent of a call that ended
+func (as *SipServer) abortConsent(callID string) {
+	as.consentMutex.Lock()
+	if pc, ok := as.pendingConsents[callID]; ok {
+		pc.cancel()
+	}
+	as.consentMutex.Unlock()
+}
+
+// deliverConsentDigit hands a DTMF digit to a pending consent prompt, reports
+// whether the call is waiting for consent
+func (as *SipServer) deliverConsentDigit(callID, digit string) bool {
+	as.consentMutex.Lock()
+	defer as.consentMutex.Unlock()
+	pc, ok := as.pendingConsents[callID]
+	if !ok {
+		return false
+	}
+	select {
+	case pc.digits <- digit:
+	default:
+	}
+	return true
+}
+
+func (as *SipServer) saveConsent(callID, region string, status models.RecordingConsentStatus, digit string) {
+	logrus.WithFields(logrus.Fields{
+		"call_id": callID,
+		"region":  region,
+		"consent": status,
+	}).Info("Recording consent resolved")
+	if err := models.SaveSipCallConsent(as.db, callID, region, status, digit); err != nil {
+		logrus.WithError(err).WithField("call_id", callID).Error("Failed to save recording consent")
+	}
+}
+
+// recordingAllowed reports whether the consent recorded for the call permits recording
+func (as *SipServer) recordingAllowed(callID string) bool {
+	if as.db == nil {
+		return true
+	}
+	return models.SipCallRecordingAllowed(as.db, callID)
+}
+
+// inboundCallNumbers returns the caller and callee numbers of an inbound call
+func (as *SipServer) inboundCallNumbers(callID string) []string {
+	if as.db == nil {
+		return nil
+	}
+	call, err := models.GetSipCallByCallID(as.db, callID)
+	if err != nil {
+		return nil
+	}
+	return []string{call.FromUsername, call.ToUsername}
+}
@@ -0,0 +1,120 @@
+package sip
+
+import (
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/metrics"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+// 通话在地区间的去向，作为指标标签
+const (
+	regionRouteLocal   = "local"   // 由本地区接通
+	regionRouteHandoff = "handoff" // 转交到被叫所在地区
+)
+
+// remoteRegion 返回 SIP 用户固定的其他地区，未固定、固定在本地区或地区已停用时返回 nil
+func (as *SipServer) remoteRegion(sipUser *models.SipUser) *models.Region {
+	if as.db == nil || sipUser == nil || !models.IsRemoteRegion(sipUser.Region) {
+		return nil
+	}
+	return models.PinnedRegion(as.db, sipUser.Region)
+}
+
+// redirectToRegion 被叫固定在其他地区时以 302 将呼叫转交到该地区的 SIP 节点，
+// 由被叫所在地区接通并提供媒体，本地只记录转交
+func (as *SipServer) redirectToRegion(req *sip.Request, tx sip.ServerTransaction, sipUser *models.SipUser, clientRTPAddr string) bool {
+	region := as.remoteRegion(sipUser)
+	if region == nil {
+		return false
+	}
+
+	uri := sip.Uri{User: req.To().Address.User, Host: region.SIPHost, Port: region.SIPPort}
+	res := sip.NewResponseFromRequest(req, sip.StatusMovedTemporarily, "Moved Temporarily", nil)
+	res.AppendHeader(&sip.ContactHeader{Address: uri})
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).Error("Failed to send region redirect response")
+	}
+	logrus.WithFields(logrus.Fields{
+		"call_id":  req.CallID().Value(),
+		"sip_user": sipUser.Username,
+		"region":   region.Code,
+		"target":   uri.String(),
+	}).Info("🌐 被叫固定在其他地区，转交呼叫")
+
+	as.recordRegionHandoff(req, sipUser, region, clientRTPAddr)
+	recordRegionCall(regionRouteHandoff)
+	return true
+}
+
+// redirectRegisterToRegion SIP 用户固定在其他地区时，以 302 让终端向该地区注册
+func (as *SipServer) redirectRegisterToRegion(req *sip.Request, tx sip.ServerTransaction, sipUser *models.SipUser) bool {
+	region := as.remoteRegion(sipUser)
+	if region == nil {
+		return false
+	}
+	uri := sip.Uri{User: sipUser.Username, Host: region.SIPHost, Port: region.SIPPort}
+	res := sip.NewResponseFromRequest(req, sip.StatusMovedTemporarily, "Moved Temporarily", nil)
+	res.AppendHeader(&sip.ContactHeader{Address: uri})
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).Error("Failed to send register redirect response")
+	}
+	logrus.WithFields(logrus.Fields{
+		"username": sipUser.Username,
+		"region":   region.Code,
+	}).Info("SIP user pinned to another region, register redirected")
+	return true
+}
+
+// recordRegionHandoff 为转交到其他地区的呼叫创建通话记录，接通后的记录由被叫所在地区生成
+func (as *SipServer) recordRegionHandoff(req *sip.Request, sipUser *models.SipUser, region *models.Region, clientRTPAddr string) {
+	now := time.Now()
+	call := &models.SipCall{
+		CallID:        req.CallID().Value(),
+		Direction:     models.SipCallDirectionInbound,
+		Status:        models.SipCallStatusEnded,
+		RemoteRTPAddr: clientRTPAddr,
+		StartTime:     now,
+		EndTime:       &now,
+		UserID:        sipUser.UserID,
+		GroupID:       sipUser.GroupID,
+		Region:        models.LocalRegion(),
+		PeerRegion:    region.Code,
+		ErrorMessage:  "handed off to region " + region.Code,
+	}
+	if from := req.From(); from != nil {
+		call.FromUsername = from.Address.User
+		call.FromURI = from.Address.String()
+	}
+	if to := req.To(); to != nil {
+		call.ToUsername = to.Address.User
+		call.ToURI = to.Address.String()
+	}
+	if err := as.db.Create(call).Error; err != nil {
+		logrus.WithError(err).WithField("call_id", call.CallID).Error("Failed to create region handoff record")
+	}
+}
+
+// sdpMediaHost 主备模式下通告 VIP；本地区配置了媒体地址时在 SDP 中通告该地址，否则使用请求到达的地址
+func (as *SipServer) sdpMediaHost(req *sip.Request) string {
+	if as.ha != nil && as.ha.cfg.VIP != "" {
+		return as.ha.cfg.VIP
+	}
+	if as.db != nil {
+		if region := models.PinnedRegion(as.db, models.LocalRegion()); region != nil && region.RTPHost != "" {
+			return region.RTPHost
+		}
+	}
+	return getServerIPFromRequest(req)
+}
+
+// recordRegionCall 按地区导出呼入通话的去向
+func recordRegionCall(route string) {
+	region := models.LocalRegion()
+	if region == "" {
+		region = "default"
+	}
+	metrics.NewMetrics().RecordBusinessOperation("sip_call_region", route, region)
+}
@@ -0,0 +1,263 @@
+package sip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+// statusRequestPending 491，对话仍在建立中时拒绝 re-INVITE（sipgo 未定义该状态码）
+const statusRequestPending sip.StatusCode = 491
+
+// isInDialogInvite To 头带 tag 的 INVITE 属于已建立的对话，即 re-INVITE
+func isInDialogInvite(req *sip.Request) bool {
+	to := req.To()
+	return to != nil && to.Params != nil && to.Params.Has("tag")
+}
+
+// sdpMediaOffer 从 re-INVITE 的 SDP 中解析出的媒体参数
+type sdpMediaOffer struct {
+	RTPAddr      string // 对端 RTP 地址 ip:port
+	Direction    string // sendrecv / sendonly / recvonly / inactive
+	PCMU         bool   // 音频媒体包含 PCMU
+	ComfortNoise bool   // 音频媒体包含 RFC 3389 舒适噪声
+}
+
+// Hold 对端将通话置为保持：连接地址为 0.0.0.0 或不再向我方发送媒体
+func (o *sdpMediaOffer) Hold() bool {
+	return strings.HasPrefix(o.RTPAddr, "0.0.0.0:") || o.Direction == "sendonly" || o.Direction == "inactive"
+}
+
+// parseSDPMediaOffer 解析对端 SDP 中的媒体地址、方向和编解码
+func parseSDPMediaOffer(sdpBody string) (*sdpMediaOffer, error) {
+	addr, err := parseSDPForRTPAddress(sdpBody)
+	if err != nil {
+		return nil, err
+	}
+	offer := &sdpMediaOffer{
+		RTPAddr:      addr,
+		Direction:    "sendrecv",
+		ComfortNoise: sdpOffersComfortNoise(sdpBody),
+	}
+	for _, line := range strings.FieldsFunc(sdpBody, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		switch line {
+		case "a=sendrecv", "a=sendonly", "a=recvonly", "a=inactive":
+			offer.Direction = line[2:]
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 3 && fields[0] == "m=audio" {
+			for _, format := range fields[3:] {
+				if format == "0" {
+					offer.PCMU = true
+				}
+			}
+		}
+		if strings.HasPrefix(strings.ToLower(line), "a=rtpmap:") && strings.Contains(strings.ToUpper(line), " PCMU/8000") {
+			offer.PCMU = true
+		}
+	}
+	return offer, nil
+}
+
+// answerDirection 应答的媒体方向与对端方向对应
+func answerDirection(offer string) string {
+	switch offer {
+	case "sendonly":
+		return "recvonly"
+	case "recvonly":
+		return "sendonly"
+	case "inactive":
+		return "inactive"
+	}
+	return "sendrecv"
+}
+
+// handleReInvite 处理对话内的 re-INVITE。PBX 在取消保持、转接后常通过 re-INVITE 更换媒体地址或编解码，
+// 这里重新解析 SDP，原子地切换会话的 RTP 目标，并以新的 SDP 应答，录音和 AI 流水线不中断
+func (as *SipServer) handleReInvite(req *sip.Request, tx sip.ServerTransaction) {
+	callID := req.CallID().Value()
+	log := logrus.WithField("call_id", callID)
+
+	if as.isPendingSession(callID) {
+		// 200 OK 尚未被 ACK 确认，按 RFC 3261 14.2 返回 491，对端稍后重试
+		log.Warn("re-INVITE received before ACK")
+		as.respondReInvite(req, tx, statusRequestPending, "Request Pending", nil)
+		return
+	}
+	if !as.isEstablishedCall(callID) {
+		log.Warn("re-INVITE for unknown dialog")
+		as.respondReInvite(req, tx, sip.StatusCallTransactionDoesNotExists, "Call/Transaction Does Not Exist", nil)
+		return
+	}
+
+	body := string(req.Body())
+	direction, comfortNoise := "sendrecv", as.sessionComfortNoise(callID)
+	if strings.TrimSpace(body) == "" {
+		// 不带 SDP 的 re-INVITE 由我方提供 offer，对端在 ACK 中应答
+		log.Info("re-INVITE without SDP, offering current media")
+	} else {
+		offer, err := parseSDPMediaOffer(body)
+		if err != nil {
+			log.WithError(err).Warn("Failed to parse re-INVITE SDP")
+			as.respondReInvite(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here", nil)
+			return
+		}
+		if !offer.PCMU {
+			log.Warn("re-INVITE offers no supported codec")
+			as.respondReInvite(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here", nil)
+			return
+		}
+		direction, comfortNoise = answerDirection(offer.Direction), offer.ComfortNoise
+		if offer.Hold() {
+			// 保持期间保留原地址，取消保持的 re-INVITE 会带上新的地址
+			log.WithField("direction", offer.Direction).Info("Call put on hold by peer")
+		} else if err := as.updateRTPTarget(callID, offer.RTPAddr); err != nil {
+			log.WithError(err).Warn("Failed to update RTP target")
+			as.respondReInvite(req, tx, sip.StatusNotAcceptableHere, "Not Acceptable Here", nil)
+			return
+		}
+	}
+
+	sdp := generateSDP(as.sdpMediaHost(req), as.RPTPort, comfortNoise)
+	if direction != "sendrecv" {
+		sdp = strings.Replace(sdp, "a=sendrecv", "a="+direction, 1)
+	}
+	as.respondReInvite(req, tx, sip.StatusOK, "OK", []byte(sdp))
+	log.WithField("direction", direction).Info("re-INVITE answered")
+}
+
+// applyAckSDP 不带 SDP 的 re-INVITE 由 ACK 携带对端应答，按其中的地址切换 RTP 目标
+func (as *SipServer) applyAckSDP(req *sip.Request) {
+	body := string(req.Body())
+	if strings.TrimSpace(body) == "" {
+		return
+	}
+	callID := req.CallID().Value()
+	offer, err := parseSDPMediaOffer(body)
+	if err != nil || offer.Hold() {
+		return
+	}
+	if err := as.updateRTPTarget(callID, offer.RTPAddr); err != nil {
+		logrus.WithError(err).WithField("call_id", callID).Warn("Failed to update RTP target from ACK")
+	}
+}
+
+// respondReInvite 应答 re-INVITE，body 非空时携带 SDP 和 Contact
+func (as *SipServer) respondReInvite(req *sip.Request, tx sip.ServerTransaction, code sip.StatusCode, reason string, body []byte) {
+	res := sip.NewResponseFromRequest(req, code, reason, body)
+	if body != nil {
+		cl := sip.ContentLengthHeader(len(body))
+		res.AppendHeader(&cl)
+		contentType := sip.ContentTypeHeader("application/sdp")
+		res.AppendHeader(&contentType)
+		res.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Host: getServerIPFromRequest(req), Port: as.SipPort}})
+	}
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).WithField("call_id", req.CallID().Value()).Error("Failed to respond to re-INVITE")
+	}
+}
+
+// updateRTPTarget 将通话的 RTP 目标切换到新地址：AI 会话、普通会话、外呼会话、检查点和通话记录一并更新
+func (as *SipServer) updateRTPTarget(callID, rtpAddr string) error {
+	addr, err := net.ResolveUDPAddr("udp", rtpAddr)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", rtpAddr, err)
+	}
+
+	var previous string
+	as.voiceHandlersMu.RLock()
+	handler := as.voiceHandlers[callID]
+	as.voiceHandlersMu.RUnlock()
+	if handler != nil {
+		previous = handler.rtpTarget().String()
+		handler.setRTPTarget(addr)
+		handler.saveCheckpoint(func(cp *models.AICallCheckpoint) {
+			cp.ClientRTPAddr = addr.String()
+		})
+	}
+
+	as.activeMutex.Lock()
+	if session, ok := as.activeSessions[callID]; ok {
+		if previous == "" && session.ClientRTPAddr != nil {
+			previous = session.ClientRTPAddr.String()
+		}
+		session.ClientRTPAddr = addr
+	}
+	as.activeMutex.Unlock()
+
+	as.outgoingMutex.Lock()
+	if session, ok := as.outgoingSessions[callID]; ok {
+		if previous == "" {
+			previous = session.RemoteRTPAddr
+		}
+		session.RemoteRTPAddr = addr.String()
+	}
+	as.outgoingMutex.Unlock()
+
+	if previous == addr.String() {
+		return nil
+	}
+	if as.db != nil {
+		if err := as.db.Model(&models.SipCall{}).Where("call_id = ?", callID).
+			Update("remote_rtp_addr", addr.String()).Error; err != nil {
+			logrus.WithError(err).WithField("call_id", callID).Warn("Failed to update remote RTP address of call record")
+		}
+	}
+	logrus.WithFields(logrus.Fields{
+		"call_id": callID,
+		"from":    previous,
+		"to":      addr.String(),
+	}).Info("RTP target switched by re-INVITE")
+	return nil
+}
+
+// activeRTPAddr 普通会话当前的对端 RTP 地址，会话不存在时返回 fallback
+func (as *SipServer) activeRTPAddr(callID string, fallback *net.UDPAddr) *net.UDPAddr {
+	as.activeMutex.RLock()
+	defer as.activeMutex.RUnlock()
+	if session, ok := as.activeSessions[callID]; ok && session.ClientRTPAddr != nil {
+		return session.ClientRTPAddr
+	}
+	return fallback
+}
+
+// isPendingSession 已发送 200 OK 但尚未收到 ACK
+func (as *SipServer) isPendingSession(callID string) bool {
+	as.sessionsMutex.RLock()
+	defer as.sessionsMutex.RUnlock()
+	_, ok := as.pendingSessions[callID]
+	return ok
+}
+
+// isEstablishedCall 通话已接通：AI 会话、普通会话或已应答的外呼
+func (as *SipServer) isEstablishedCall(callID string) bool {
+	as.voiceHandlersMu.RLock()
+	_, ok := as.voiceHandlers[callID]
+	as.voiceHandlersMu.RUnlock()
+	if ok {
+		return true
+	}
+	as.activeMutex.RLock()
+	_, ok = as.activeSessions[callID]
+	as.activeMutex.RUnlock()
+	if ok {
+		return true
+	}
+	as.outgoingMutex.RLock()
+	defer as.outgoingMutex.RUnlock()
+	session, ok := as.outgoingSessions[callID]
+	return ok && session.Status == "answered"
+}
+
+// sessionComfortNoise 通话建立时是否协商了舒适噪声，应答中沿用
+func (as *SipServer) sessionComfortNoise(callID string) bool {
+	as.aiSessionMutex.RLock()
+	defer as.aiSessionMutex.RUnlock()
+	info, ok := as.aiSessionInfo[callID]
+	return ok && info != nil && info.ComfortNoise
+}
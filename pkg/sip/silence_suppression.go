@@ -0,0 +1,233 @@
+package sip
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+)
+
+const (
+	// comfortNoisePayloadType RFC 3389 舒适噪声的静态负载类型
+	comfortNoisePayloadType = 13
+	// silenceHangoverFrames 语音结束后继续发送的帧数，避免切掉尾音
+	silenceHangoverFrames = 10
+	// comfortNoiseRefreshFrames 静音期间至少每隔该帧数重发一次舒适噪声包
+	comfortNoiseRefreshFrames = 50
+	// comfortNoiseLevelStep 噪声电平变化超过该值（dB）时立即更新舒适噪声
+	comfortNoiseLevelStep = 3
+	// maxGapFillSamples 单次最多补齐的样本数（10 秒 @ 8kHz），更大的时间戳跳变视为流重置
+	maxGapFillSamples = 10 * 8000
+	// pcmuFrameSize 20ms @ 8kHz 的 PCMU 帧长
+	pcmuFrameSize = 160
+)
+
+// SilenceSuppressionConfig 发送方向的静音抑制配置，来自代接方案
+type SilenceSuppressionConfig struct {
+	Enabled      bool
+	ThresholdDB  float64 // 静音判定电平（dBov）
+	ComfortNoise bool    // 对端在 SDP 中协商了 CN，静音期间发送舒适噪声包
+}
+
+// silenceSuppressionFor 根据方案和对端 SDP 得到通话的静音抑制配置。
+// 对端未协商 CN 时不做抑制，避免不支持非连续发送的终端误判断线
+func silenceSuppressionFor(sipUser *models.SipUser, peerComfortNoise bool) SilenceSuppressionConfig {
+	if sipUser == nil || !sipUser.SilenceSuppression || !peerComfortNoise {
+		return SilenceSuppressionConfig{}
+	}
+	threshold := sipUser.SilenceThreshold
+	if threshold == 0 {
+		threshold = models.DefaultSilenceThreshold
+	}
+	return SilenceSuppressionConfig{Enabled: true, ThresholdDB: float64(threshold), ComfortNoise: true}
+}
+
+// sdpOffersComfortNoise 对端 SDP 的音频媒体是否包含 CN 负载
+func sdpOffersComfortNoise(sdpBody string) bool {
+	for _, line := range strings.FieldsFunc(sdpBody, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if fields := strings.Fields(line); len(fields) > 3 && fields[0] == "m=audio" {
+			for _, format := range fields[3:] {
+				if format == "13" {
+					return true
+				}
+			}
+		}
+		if strings.HasPrefix(strings.ToLower(line), "a=rtpmap:") && strings.Contains(strings.ToUpper(line), " CN/8000") {
+			return true
+		}
+	}
+	return false
+}
+
+// pcmuLevelDB PCMU 帧的均方根电平（dBov），全零帧返回 -127
+func pcmuLevelDB(payload []byte) float64 {
+	if len(payload) == 0 {
+		return -127
+	}
+	var sum float64
+	for _, b := range payload {
+		v := float64(mulawToLinear(b))
+		sum += v * v
+	}
+	rms := math.Sqrt(sum / float64(len(payload)))
+	if rms < 1 {
+		return -127
+	}
+	return math.Max(20*math.Log10(rms/32768), -127)
+}
+
+// comfortNoiseLevel RFC 3389 的噪声电平字节：-dBov，取值 0-127
+func comfortNoiseLevel(levelDB float64) byte {
+	level := int(math.Round(-levelDB))
+	if level < 0 {
+		level = 0
+	}
+	if level > 127 {
+		level = 127
+	}
+	return byte(level)
+}
+
+// frameAction 一帧音频的发送方式
+type frameAction int
+
+const (
+	frameSend         frameAction = iota // 正常发送语音包
+	frameComfortNoise                    // 发送舒适噪声包
+	frameSkip                            // 静音期间不发送
+)
+
+// silenceSuppressor 发送方向的静音抑制状态（VAD + 拖尾），每路通话一个
+type silenceSuppressor struct {
+	cfg         SilenceSuppressionConfig
+	silentRun   int  // 连续静音帧数
+	suppressing bool // 处于静音抑制期
+	sinceCN     int  // 上次发送舒适噪声后的帧数
+	lastLevel   byte // 上次发送的噪声电平
+}
+
+func newSilenceSuppressor(cfg SilenceSuppressionConfig) *silenceSuppressor {
+	return &silenceSuppressor{cfg: cfg}
+}
+
+// next 决定一帧 PCMU 的发送方式。marker 表示静音后的第一帧语音（RFC 3551 讲话突发开始），
+// cn 为需要发送的舒适噪声负载
+func (s *silenceSuppressor) next(payload []byte) (action frameAction, marker bool, cn []byte) {
+	if s == nil || !s.cfg.Enabled {
+		return frameSend, false, nil
+	}
+	levelDB := pcmuLevelDB(payload)
+	if levelDB > s.cfg.ThresholdDB {
+		s.silentRun = 0
+		if s.suppressing {
+			s.suppressing = false
+			return frameSend, true, nil
+		}
+		return frameSend, false, nil
+	}
+
+	s.silentRun++
+	if s.silentRun <= silenceHangoverFrames {
+		return frameSend, false, nil
+	}
+	level := comfortNoiseLevel(levelDB)
+	if !s.suppressing {
+		s.suppressing = true
+		s.sinceCN = 0
+		s.lastLevel = level
+		if s.cfg.ComfortNoise {
+			return frameComfortNoise, false, []byte{level}
+		}
+		return frameSkip, false, nil
+	}
+	s.sinceCN++
+	if s.cfg.ComfortNoise && (s.sinceCN >= comfortNoiseRefreshFrames || absDiff(level, s.lastLevel) >= comfortNoiseLevelStep) {
+		s.sinceCN = 0
+		s.lastLevel = level
+		return frameComfortNoise, false, []byte{level}
+	}
+	return frameSkip, false, nil
+}
+
+func absDiff(a, b byte) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// rtpGapFiller 接收方向按 RTP 时间戳补齐对端静音抑制留下的空隙，
+// 使录音和 VAD 看到的音频与对端连续发送时一致
+type rtpGapFiller struct {
+	started bool
+	nextTS  uint32 // 下一包的期望时间戳
+	noiseDB byte   // 最近一次舒适噪声电平，127 表示数字静音
+	rng     *rand.Rand
+}
+
+func newRTPGapFiller() *rtpGapFiller {
+	return &rtpGapFiller{noiseDB: 127, rng: rand.New(rand.NewSource(1))}
+}
+
+// audio 收到一包 PCMU 语音，返回其之前需要补齐的音频
+func (f *rtpGapFiller) audio(timestamp uint32, samples int) []byte {
+	gap := f.gapBefore(timestamp)
+	f.nextTS = timestamp + uint32(samples)
+	if samples > 0 {
+		// 收到语音后噪声电平重新以下一个 CN 包为准
+		f.noiseDB = 127
+	}
+	return gap
+}
+
+// comfortNoise 收到一个 RFC 3389 舒适噪声包，补齐到该包时间戳，之后以其电平填充
+func (f *rtpGapFiller) comfortNoise(timestamp uint32, payload []byte) []byte {
+	gap := f.gapBefore(timestamp)
+	f.nextTS = timestamp
+	if len(payload) > 0 {
+		f.noiseDB = payload[0] & 0x7F
+	}
+	return gap
+}
+
+func (f *rtpGapFiller) gapBefore(timestamp uint32) []byte {
+	if !f.started {
+		f.started = true
+		return nil
+	}
+	missing := int32(timestamp - f.nextTS)
+	if missing <= 0 || missing > maxGapFillSamples {
+		return nil
+	}
+	return f.noise(int(missing))
+}
+
+// noise 生成指定电平的 PCMU 白噪声，电平为 127 时生成数字静音
+func (f *rtpGapFiller) noise(samples int) []byte {
+	out := make([]byte, samples)
+	if f.noiseDB >= 127 {
+		for i := range out {
+			out[i] = 0xFF // PCMU 静音值
+		}
+		return out
+	}
+	// 均匀分布 [-A, A] 的均方根为 A/√3
+	amplitude := math.Min(32768*math.Pow(10, -float64(f.noiseDB)/20)*math.Sqrt(3), 32767)
+	for i := range out {
+		out[i] = linearToMulaw(int16((f.rng.Float64()*2 - 1) * amplitude))
+	}
+	return out
+}
+
+// splitPCMUFrames 将补齐的音频按 20ms 分帧，末帧可能不足一帧
+func splitPCMUFrames(data []byte) [][]byte {
+	var frames [][]byte
+	for len(data) > 0 {
+		n := min(pcmuFrameSize, len(data))
+		frames = append(frames, data[:n])
+		data = data[n:]
+	}
+	return frames
+}
@@ -13,6 +13,9 @@ import (
 	"time"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/events"
+	"github.com/code-100-precent/LingEcho/pkg/overload"
+	"github.com/code-100-precent/LingEcho/pkg/sessionlimit"
 	"github.com/emiago/sipgo"
 	"github.com/emiago/sipgo/sip"
 	"github.com/pion/rtp"
@@ -44,13 +47,32 @@ type SipServer struct {
 	voiceHandlersMu  sync.RWMutex
 	aiSessionInfo    map[string]*AISessionInfo // Call-ID -> AI session info
 	aiSessionMutex   sync.RWMutex
+	presenceCalls    map[string][]presenceSubject // Call-ID -> subjects made busy by the call
+	presenceMutex    sync.Mutex
+	pendingConsents  map[string]*pendingConsent // Call-ID -> recording consent prompt awaiting DTMF
+	consentMutex     sync.Mutex
+	pendingSurveys   map[string]*pendingSurvey // Call-ID -> post-call survey awaiting DTMF
+	surveyMutex      sync.Mutex
+	aiTickets        map[string]*sessionlimit.Ticket // Call-ID -> assistant session slot
+	queuedCalls      map[string]context.CancelFunc   // Call-ID -> wait for an assistant slot
+	aiLimitMutex     sync.Mutex
+	pendingCallbacks map[string]chan string         // Call-ID -> callback offer awaiting DTMF
+	callbacks        map[string]*models.SipCallback // callback Call-ID -> callback being dialed or connected
+	callbackMutex    sync.Mutex
+	interpretedCalls map[string]*interpretedCall // Call-ID of either leg -> call interpreted by an assistant
+	interpreterMutex sync.Mutex
+	instance         string // 实例标识，用于 AI 通话检查点
 	db               *gorm.DB
+	admission        *overload.Controller // Inbound INVITE admission control
+	ha               *haController        // 主备媒体切换，未配置时为 nil
 }
 
 // AISessionInfo 存储 AI 会话信息
 type AISessionInfo struct {
-	SipUser   *models.SipUser
-	Assistant *models.Assistant
+	SipUser      *models.SipUser
+	Assistant    *models.Assistant
+	Dialog       aiDialog
+	ComfortNoise bool // 对端 SDP 协商了 RFC 3389 舒适噪声
 }
 
 type OutgoingSession struct {
@@ -67,6 +89,8 @@ type OutgoingSession struct {
 	LastResponse  *sip.Response         // 保存最后的响应，用于发送BYE
 	Transaction   sip.ClientTransaction // 保存事务，用于发送CANCEL
 	RecordingFile string                // 录音文件路径
+
+	onAnswered func(remoteRTPAddr string, dialog aiDialog) // 接通后接管媒体，为空时播放默认音频
 }
 
 type SessionInfo struct {
@@ -110,7 +134,7 @@ func NewSipServer(rptPort int) *SipServer {
 		logrus.WithError(err).Fatal("Create SIP Client Failed")
 	}
 
-	return &SipServer{
+	as := &SipServer{
 		RPTPort:          rptPort,
 		server:           server,
 		rtpConn:          rtpConn,
@@ -122,7 +146,19 @@ func NewSipServer(rptPort int) *SipServer {
 		registeredUsers:  make(map[string]string),
 		voiceHandlers:    make(map[string]*VoiceConversationHandler),
 		aiSessionInfo:    make(map[string]*AISessionInfo),
-	}
+		presenceCalls:    make(map[string][]presenceSubject),
+		pendingConsents:  make(map[string]*pendingConsent),
+		pendingSurveys:   make(map[string]*pendingSurvey),
+		aiTickets:        make(map[string]*sessionlimit.Ticket),
+		queuedCalls:      make(map[string]context.CancelFunc),
+		pendingCallbacks: make(map[string]chan string),
+		callbacks:        make(map[string]*models.SipCallback),
+		interpretedCalls: make(map[string]*interpretedCall),
+		admission:        overload.New(inviteOverloadConfig()),
+		instance:         checkpointInstance(),
+	}
+	as.ha = newHAController(as, haConfigFromEnv())
+	return as
 }
 
 func (as *SipServer) Close() {
@@ -135,6 +171,14 @@ func (as *SipServer) Start(sipPort int, targetURI string) {
 	as.SipPort = sipPort
 	as.RegisterFunc()
 
+	// 主备模式下先确定角色，备用实例不处理媒体
+	if as.ha != nil {
+		go as.ha.run(ctx)
+	}
+
+	// 接管重启前或宕机实例遗留的 AI 通话
+	go as.runCheckpointLoop(ctx)
+
 	// Only make outgoing call if targetURI is provided
 	if targetURI != "" {
 		go func() {
@@ -206,7 +250,7 @@ func (as *SipServer) makeOutgoingCall(targetURI string, sipPort int, rtpPort int
 	}
 
 	// 生成 SDP offer
-	sdpOffer := generateSDP(localIP, rtpPort)
+	sdpOffer := generateSDP(localIP, rtpPort, true)
 	sdpBytes := []byte(sdpOffer)
 
 	log.Printf("生成的 SDP Offer:\n%s", sdpOffer)
@@ -368,15 +412,26 @@ func (as *SipServer) makeOutgoingCall(targetURI string, sipPort int, rtpPort int
 
 // MakeOutgoingCall 发起呼出呼叫（公共方法，供API调用）
 func (as *SipServer) MakeOutgoingCall(targetURI string) (string, error) {
+	return as.startOutgoingCall(targetURI, nil)
+}
+
+// startOutgoingCall 发起呼出呼叫，onAnswered 不为空时接通后由其处理媒体
+func (as *SipServer) startOutgoingCall(targetURI string, onAnswered func(remoteRTPAddr string, dialog aiDialog)) (string, error) {
+	// 不呼叫忙碌或免打扰的坐席
+	if err := checkOutgoingPresence(targetURI); err != nil {
+		return "", err
+	}
+
 	callID := generateCallID()
 
 	// 创建呼出会话记录
 	now := time.Now()
 	session := &OutgoingSession{
-		CallID:    callID,
-		TargetURI: targetURI,
-		Status:    "calling",
-		StartTime: now,
+		CallID:     callID,
+		TargetURI:  targetURI,
+		Status:     "calling",
+		StartTime:  now,
+		onAnswered: onAnswered,
 	}
 
 	as.outgoingMutex.Lock()
@@ -394,6 +449,10 @@ func (as *SipServer) MakeOutgoingCall(targetURI string) (string, error) {
 // makeOutgoingCallWithID 发起呼出呼叫（带CallID）
 func (as *SipServer) makeOutgoingCallWithID(targetURI string, sipPort int, rtpPort int, callID string) {
 	logrus.WithField("call_id", callID).Info("=== 开始发起呼叫 ===")
+	publishCallEvent(events.CallOffered, callID, map[string]interface{}{
+		"direction": string(models.SipCallDirectionOutbound),
+		"to":        targetURI,
+	})
 
 	// 更新会话状态
 	as.outgoingMutex.Lock()
@@ -450,7 +509,7 @@ func (as *SipServer) makeOutgoingCallWithID(targetURI string, sipPort int, rtpPo
 	}
 
 	// 生成 SDP offer
-	sdpOffer := generateSDP(localIP, rtpPort)
+	sdpOffer := generateSDP(localIP, rtpPort, true)
 	sdpBytes := []byte(sdpOffer)
 
 	// 创建 INVITE 请求
@@ -575,8 +634,10 @@ func (as *SipServer) makeOutgoingCallWithID(targetURI string, sipPort int, rtpPo
 				}
 				recordingFile := fmt.Sprintf("%s/recorded_%s.wav", recordDir, callID)
 
+				var onAnswered func(remoteRTPAddr string, dialog aiDialog)
 				as.outgoingMutex.Lock()
 				if session, exists := as.outgoingSessions[callID]; exists {
+					onAnswered = session.onAnswered
 					session.RemoteRTPAddr = remoteRTPAddr
 					session.Status = "answered"
 					session.AnswerTime = &now
@@ -587,6 +648,7 @@ func (as *SipServer) makeOutgoingCallWithID(targetURI string, sipPort int, rtpPo
 
 				// 更新数据库状态
 				as.updateCallStatusInDB(callID, "answered", nil)
+				as.trackPresenceCall(callID, targetUsername)
 
 				// 发送 ACK
 				ackReq := sip.NewAckRequest(inviteReq, res, nil)
@@ -595,11 +657,25 @@ func (as *SipServer) makeOutgoingCallWithID(targetURI string, sipPort int, rtpPo
 					return
 				}
 
-				// 启动录音（持续录音直到通话结束）
-				go as.recordAudioContinuous(remoteRTPAddr, callID, recordingFile, ctx)
+				if onAnswered != nil {
+					go onAnswered(remoteRTPAddr, dialogFromOutgoing(inviteReq, res))
+					return
+				}
 
-				// 开始发送音频
-				go as.sendAudioForOutgoing(remoteRTPAddr, callID)
+				go func() {
+					// 双方同意地区需先获得被叫的录音同意
+					allowed, err := as.obtainRecordingConsent(ctx, callID, remoteRTPAddr, targetUsername)
+					if err != nil {
+						return
+					}
+					// 启动录音（持续录音直到通话结束）
+					if allowed {
+						go as.recordAudioContinuous(remoteRTPAddr, callID, recordingFile, ctx)
+					}
+
+					// 开始发送音频
+					as.sendAudioForOutgoing(remoteRTPAddr, callID)
+				}()
 				return
 			} else {
 				errMsg := fmt.Sprintf("呼叫失败: %d %s", res.StatusCode, res.Reason)
@@ -645,6 +721,17 @@ func (as *SipServer) updateOutgoingSessionStatus(callID, status, errorMsg string
 
 // updateCallStatusInDB 更新数据库中的通话状态
 func (as *SipServer) updateCallStatusInDB(callID string, status string, endTime *time.Time) {
+	publishCallStatus(callID, status)
+
+	// 通话结束，恢复相关坐席的在线状态
+	if endTime != nil {
+		as.releasePresenceCall(callID)
+		as.releaseAISession(callID)
+		as.releaseInviteSession(callID)
+		as.endInterpretedCall(callID)
+	}
+	as.trackCallbackCall(callID, status, endTime != nil)
+
 	if as.db == nil {
 		return
 	}
@@ -682,6 +769,10 @@ func (as *SipServer) saveRecordingURL(callID string, recordingFile string) {
 		logrus.WithField("call_id", callID).Warn("Database not configured, skipping recording URL save")
 		return
 	}
+	if !as.recordingAllowed(callID) {
+		logrus.WithField("call_id", callID).Info("Recording consent not given, recording URL not saved")
+		return
+	}
 
 	// 检查文件是否存在
 	if _, err := os.Stat(recordingFile); os.IsNotExist(err) {
@@ -1074,6 +1165,34 @@ func (as *SipServer) RegisterFunc() {
 func (as *SipServer) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 	logrus.WithField("start_line", req.StartLine()).Info("Received INVITE request")
 
+	// 对话内的 re-INVITE 只更新媒体，不经过准入控制和路由
+	if isInDialogInvite(req) {
+		as.handleReInvite(req, tx)
+		return
+	}
+
+	// 备用实例不接听新的呼叫
+	if as.rejectOnStandby(req, tx) {
+		return
+	}
+
+	// 过载保护：会话数或待处理 INVITE 队列已满时返回 503，未注册主叫先被拒绝
+	ticket, admitted := as.admitInvite(req, tx)
+	if !admitted {
+		return
+	}
+	publishInboundOffered(req)
+	answered := false
+	defer func() {
+		ticket.Done()
+		if ticket != nil && !answered {
+			as.releaseInviteSession(req.CallID().Value())
+		}
+		if !answered {
+			publishCallEnded(req.CallID().Value(), events.CallEndRejected)
+		}
+	}()
+
 	// Parse SDP to get client RTP address
 	sdpBody := string(req.Body())
 	clientRTPAddr, err := parseSDPForRTPAddress(sdpBody)
@@ -1089,7 +1208,7 @@ func (as *SipServer) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 
 	// Generate SDP response (use request source address to determine server IP)
 	serverIP := getServerIPFromRequest(req)
-	sdp := generateSDP(serverIP, as.RPTPort)
+	sdp := generateSDP(as.sdpMediaHost(req), as.RPTPort, sdpOffersComfortNoise(sdpBody))
 	sdpBytes := []byte(sdp)
 
 	// Log SDP content for debugging
@@ -1098,12 +1217,46 @@ func (as *SipServer) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 	// Create 200 OK response
 	// 先检查是否需要启动 AI 代接（在发送 200 OK 之前）
 	callID := req.CallID().Value()
-	shouldStartAI, sipUser, assistant, err := as.checkAIAutoAnswer(req)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"call_id": callID,
-			"error":   err,
-		}).Warn("Failed to check AI auto-answer")
+
+	// 被叫号码为 DID 时按其去向路由，转坐席或拒接时已应答
+	dc, handled := as.routeDID(req, tx, clientRTPAddr)
+	if handled {
+		return
+	}
+
+	var shouldStartAI bool
+	var sipUser *models.SipUser
+	var assistant *models.Assistant
+	if dc != nil {
+		shouldStartAI, sipUser, assistant = true, dc.sipUser, dc.assistant
+	} else {
+		shouldStartAI, sipUser, assistant, err = as.checkAIAutoAnswer(req)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"call_id": callID,
+				"error":   err,
+			}).Warn("Failed to check AI auto-answer")
+		}
+	}
+
+	// 被叫固定在其他地区时转交到该地区
+	if dc == nil && as.redirectToRegion(req, tx, sipUser, clientRTPAddr) {
+		return
+	}
+
+	// 被叫坐席忙碌或免打扰时不振铃，AI 代接除外
+	if sipUser != nil && !shouldStartAI {
+		if state := agentPresence(sipUser.Username); !state.Available() {
+			as.rejectUnavailable(req, tx, state)
+			return
+		}
+	}
+
+	// 助手并发已满时排队等待，排不上时播放繁忙提示
+	if shouldStartAI && sipUser != nil && assistant != nil {
+		if !as.admitAISession(req, tx, clientRTPAddr, sipUser, assistant) {
+			return
+		}
 	}
 
 	// 根据 AI 检查结果决定保存的地址格式
@@ -1115,8 +1268,9 @@ func (as *SipServer) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 		// 保存 AI 会话信息
 		as.aiSessionMutex.Lock()
 		as.aiSessionInfo[callID] = &AISessionInfo{
-			SipUser:   sipUser,
-			Assistant: assistant,
+			SipUser:      sipUser,
+			Assistant:    assistant,
+			ComfortNoise: sdpOffersComfortNoise(sdpBody),
 		}
 		as.aiSessionMutex.Unlock()
 
@@ -1156,15 +1310,36 @@ func (as *SipServer) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 	res.AppendHeader(contact)
 	logrus.WithField("contact", contact.String()).Debug("Contact header")
 
+	if shouldStartAI {
+		as.aiSessionMutex.Lock()
+		if info, ok := as.aiSessionInfo[callID]; ok {
+			info.Dialog = dialogFromInvite(req, res)
+		}
+		as.aiSessionMutex.Unlock()
+	}
+
 	// Send 200 OK response
 	if err := tx.Respond(res); err != nil {
 		logrus.WithError(err).Error("Failed to send response")
+		as.releaseAISession(callID)
 		return
 	}
 
+	answered = true
+	recordRegionCall(regionRouteLocal)
 	logrus.Info("200 OK response sent with SDP and Contact header")
 	logrus.Info("200 OK response sent, waiting for ACK...")
 
+	// 通话中的坐席标记为忙碌
+	var busyAgents []string
+	if sipUser != nil && dc == nil {
+		busyAgents = append(busyAgents, sipUser.Username)
+	}
+	if from := req.From(); from != nil && as.isRegisteredUser(from.Address.User) {
+		busyAgents = append(busyAgents, from.Address.User)
+	}
+	as.trackPresenceCall(callID, busyAgents...)
+
 	// 创建呼入通话的数据库记录
 	if as.db != nil {
 		now := time.Now()
@@ -1204,6 +1379,13 @@ func (as *SipServer) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 			LocalRTPAddr:  localRTPAddr,
 			RemoteRTPAddr: clientRTPAddr,
 			StartTime:     now,
+			Region:        models.LocalRegion(),
+		}
+		if dc != nil {
+			sipCall.UserID = &dc.did.UserID
+			sipCall.GroupID = dc.did.GroupID
+			sipCall.DIDNumberID = &dc.did.ID
+			sipCall.DIDRoute = models.DIDRouteAssistant
 		}
 
 		if err := as.db.Create(sipCall).Error; err != nil {
@@ -1501,6 +1683,11 @@ func (as *SipServer) handleRegister(req *sip.Request, tx sip.ServerTransaction)
 			return
 		}
 
+		// Users pinned to another region register there
+		if as.redirectRegisterToRegion(req, tx, &sipUser) {
+			return
+		}
+
 		// Extract registration information from request
 		contact := req.Contact()
 		var contactStr string
@@ -1569,6 +1756,10 @@ func (as *SipServer) handleRegister(req *sip.Request, tx sip.ServerTransaction)
 			as.registeredUsers[username] = fmt.Sprintf("%s:%d", contactIP, contactPort)
 			as.registerMutex.Unlock()
 		}
+		events.PublishEvent(events.AgentRegistered, map[string]interface{}{
+			"agent":   username,
+			"expires": expires,
+		}, callEventSource)
 
 		logrus.WithFields(logrus.Fields{
 			"username":       username,
@@ -1660,6 +1851,11 @@ func (as *SipServer) handleAck(req *sip.Request, tx sip.ServerTransaction) {
 		"clientRTPAddr": clientRTPAddr,
 	}).Debug("Pending session info")
 
+	if !exists && as.isEstablishedCall(callID) {
+		// re-INVITE 的 ACK
+		as.applyAckSDP(req)
+		return
+	}
 	if !exists {
 		logrus.WithField("call_id", callID).Warn("Received ACK but could not find corresponding session")
 		logrus.Debug("Current pending sessions list:")
@@ -1719,6 +1915,15 @@ func (as *SipServer) handleAck(req *sip.Request, tx sip.ServerTransaction) {
 	}
 	logrus.WithField("client_rtp_addr", actualRTPAddr).Info("Session established, starting to send audio")
 
+	// 双方同意地区需先播放录音提示并等待按键确认
+	consentCtx, consentCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	recordingAllowed, err := as.obtainRecordingConsent(consentCtx, callID, actualRTPAddr, as.inboundCallNumbers(callID)...)
+	consentCancel()
+	if err != nil {
+		logrus.WithField("call_id", callID).Info("Call ended while waiting for recording consent")
+		return
+	}
+
 	// 如果是 AI 代接会话，启动 AI 语音处理
 	if isAISession {
 		// 从 aiSessionInfo 中获取 AI 会话信息
@@ -1731,7 +1936,7 @@ func (as *SipServer) handleAck(req *sip.Request, tx sip.ServerTransaction) {
 			isAISession = false
 		} else {
 			// 启动 AI 语音会话
-			if err := as.startAIVoiceSession(callID, clientAddr, aiInfo.SipUser, aiInfo.Assistant); err != nil {
+			if err := as.startAIVoiceSession(callID, clientAddr, aiInfo.SipUser, aiInfo.Assistant, nil); err != nil {
 				logrus.WithFields(logrus.Fields{
 					"call_id": callID,
 					"error":   err,
@@ -1773,8 +1978,10 @@ func (as *SipServer) handleAck(req *sip.Request, tx sip.ServerTransaction) {
 		as.updateCallStatusInDB(callID, "answered", &now)
 	}
 
-	// 启动录音（持续录音直到通话结束）
-	go as.recordAudioContinuous(actualRTPAddr, callID, recordingFile, ctx)
+	// 启动录音（持续录音直到通话结束），未获得录音同意时不录音
+	if recordingAllowed {
+		go as.recordAudioContinuous(actualRTPAddr, callID, recordingFile, ctx)
+	}
 
 	// Send audio in goroutine
 	go as.sendAudioWithCallback(actualRTPAddr, callID)
@@ -1804,6 +2011,10 @@ func (as *SipServer) sendAudioWithCallback(clientAddr string, callID string) {
 		// 1. Play initial audio
 		NewPlayAudioEvent(callID, session.CancelCtx, clientAddr, "", 8000, 160),
 	}
+	if !as.recordingAllowed(callID) {
+		// No recording consent, skip the record and playback echo
+		events = events[2:]
+	}
 
 	// Process event sequence
 	if err := processor.ProcessSequence(events); err != nil {
@@ -1864,17 +2075,26 @@ func (as *SipServer) handleInfo(req *sip.Request, tx sip.ServerTransaction) {
 			"call_id": callID,
 		}).Info("Detected DTMF key")
 
-		// Send DTMF to session channel
-		as.activeMutex.RLock()
-		if session, exists := as.activeSessions[callID]; exists {
-			select {
-			case session.DTMFChannel <- dtmfDigit:
-				logrus.WithField("dtmf", dtmfDigit).Debug("DTMF key sent to session channel")
-			default:
-				logrus.WithField("dtmf", dtmfDigit).Warn("DTMF channel full, dropping key")
+		// A pending recording consent prompt takes the key first
+		if as.deliverCallbackDigit(callID, dtmfDigit) {
+			logrus.WithField("dtmf", dtmfDigit).Debug("DTMF key sent to callback offer")
+		} else if as.deliverConsentDigit(callID, dtmfDigit) {
+			logrus.WithField("dtmf", dtmfDigit).Debug("DTMF key sent to recording consent prompt")
+		} else if as.deliverSurveyDigit(callID, dtmfDigit) {
+			logrus.WithField("dtmf", dtmfDigit).Debug("DTMF key sent to call survey")
+		} else {
+			// Send DTMF to session channel
+			as.activeMutex.RLock()
+			if session, exists := as.activeSessions[callID]; exists {
+				select {
+				case session.DTMFChannel <- dtmfDigit:
+					logrus.WithField("dtmf", dtmfDigit).Debug("DTMF key sent to session channel")
+				default:
+					logrus.WithField("dtmf", dtmfDigit).Warn("DTMF channel full, dropping key")
+				}
 			}
+			as.activeMutex.RUnlock()
 		}
-		as.activeMutex.RUnlock()
 	}
 
 	// Return 200 OK
@@ -2102,6 +2322,7 @@ func (as *SipServer) recordAudioContinuous(clientAddr string, callID string, fil
 	buffer := make([]byte, 1500)
 	packetCount := 0
 	sampleRate := 8000
+	filler := newRTPGapFiller()
 
 	// 设置读取超时（用于定期检查取消信号）
 	as.rtpConn.SetReadDeadline(time.Now().Add(1 * time.Second))
@@ -2142,8 +2363,8 @@ func (as *SipServer) recordAudioContinuous(clientAddr string, callID string, fil
 			continue
 		}
 
-		// 检查是否来自目标客户端
-		if !receivedAddr.IP.Equal(addr.IP) {
+		// 检查是否来自目标客户端（re-INVITE 后按新地址过滤）
+		if !receivedAddr.IP.Equal(as.activeRTPAddr(callID, addr).IP) {
 			continue
 		}
 
@@ -2154,7 +2375,19 @@ func (as *SipServer) recordAudioContinuous(clientAddr string, callID string, fil
 			continue
 		}
 
-		// 只处理 PCMU (payload type 0)
+		// 对端静音抑制的空隙按舒适噪声补齐，保持录音时长与通话一致
+		var gap []byte
+		switch packet.PayloadType {
+		case 0: // PCMU
+			gap = filler.audio(packet.Timestamp, len(packet.Payload))
+		case comfortNoisePayloadType:
+			gap = filler.comfortNoise(packet.Timestamp, packet.Payload)
+		default:
+			continue
+		}
+		for _, mulawByte := range gap {
+			pcmData = append(pcmData, mulawToLinear(mulawByte))
+		}
 		if packet.PayloadType != 0 {
 			continue
 		}
@@ -2329,6 +2562,10 @@ func (as *SipServer) handleBye(req *sip.Request, tx sip.ServerTransaction) {
 		"call_id":    callID,
 	}).Info("Received BYE request")
 
+	// 挂断时仍在等待录音同意或满意度评分
+	as.abortConsent(callID)
+	as.abortSurvey(callID)
+
 	// 停止 AI 语音会话（如果存在）
 	as.stopAIVoiceSession(callID)
 
@@ -2424,6 +2661,12 @@ func (as *SipServer) handleCancel(req *sip.Request, tx sip.ServerTransaction) {
 		"call_id":    callID,
 	}).Info("Received CANCEL request")
 
+	publishCallEnded(callID, events.CallEndCancelled)
+	as.releasePresenceCall(callID)
+	as.abortQueuedCall(callID)
+	as.releaseAISession(callID)
+	as.releaseInviteSession(callID)
+
 	// Clean up pending session (CANCEL is sent before ACK)
 	as.sessionsMutex.Lock()
 	if clientRTPAddr, exists := as.pendingSessions[callID]; exists {
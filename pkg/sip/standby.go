@@ -0,0 +1,309 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/emiago/sipgo/sip"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHALeaseTTL = 15 * time.Second
+	// haCommandTimeout bounds the VIP up/down commands
+	haCommandTimeout = 10 * time.Second
+)
+
+// haConfig hot standby of the media path, read from the environment:
+//   - SIP_HA_CLUSTER name shared by the active and standby instances; empty (default)
+//     disables hot standby and every instance handles media
+//   - SIP_HA_VIP media address the active instance advertises in SDP
+//   - SIP_HA_LEASE_TTL seconds the active instance holds the lease without renewing, default 15
+//   - SIP_HA_VIP_UP / SIP_HA_VIP_DOWN shell commands that move the VIP to or away from
+//     this host (e.g. "ip addr add $SIP_HA_VIP/32 dev eth0"), run with SIP_HA_VIP set
+type haConfig struct {
+	Cluster  string
+	VIP      string
+	LeaseTTL time.Duration
+	UpCmd    string
+	DownCmd  string
+}
+
+func haConfigFromEnv() haConfig {
+	return haConfig{
+		Cluster:  strings.TrimSpace(os.Getenv("SIP_HA_CLUSTER")),
+		VIP:      strings.TrimSpace(os.Getenv("SIP_HA_VIP")),
+		LeaseTTL: time.Duration(envInt("SIP_HA_LEASE_TTL", int(defaultHALeaseTTL.Seconds()))) * time.Second,
+		UpCmd:    os.Getenv("SIP_HA_VIP_UP"),
+		DownCmd:  os.Getenv("SIP_HA_VIP_DOWN"),
+	}
+}
+
+// haController keeps one instance of the cluster active through a lease in the
+// shared database. The standby mirrors the registrar from the shared sip_users
+// table and rejects new calls; when the lease of the active instance expires
+// (crash or drill) it takes over the VIP and resumes or hangs up the in-flight
+// AI calls from their checkpoints.
+type haController struct {
+	as  *SipServer
+	cfg haConfig
+
+	mu        sync.RWMutex
+	active    bool
+	epoch     int64
+	renewedAt time.Time
+	mirrored  int
+}
+
+// newHAController returns nil when hot standby is not configured
+func newHAController(as *SipServer, cfg haConfig) *haController {
+	if cfg.Cluster == "" {
+		return nil
+	}
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = defaultHALeaseTTL
+	}
+	return &haController{as: as, cfg: cfg}
+}
+
+// mediaActive reports whether this instance handles media; always true without hot standby
+func (as *SipServer) mediaActive() bool {
+	return as.ha == nil || as.ha.isActive()
+}
+
+func (ha *haController) isActive() bool {
+	ha.mu.RLock()
+	defer ha.mu.RUnlock()
+	return ha.active
+}
+
+func (ha *haController) run(ctx context.Context) {
+	ha.tick(time.Now())
+	ticker := time.NewTicker(ha.cfg.LeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if ha.isActive() && ha.as.db != nil {
+				// Let the standby take over right away instead of waiting for the lease to expire
+				if err := models.ReleaseSipHALease(ha.as.db, ha.cfg.Cluster, ha.as.instance, 0, time.Now()); err != nil {
+					logrus.WithError(err).Warn("Failed to release SIP HA lease")
+				}
+			}
+			return
+		case now := <-ticker.C:
+			ha.tick(now)
+		}
+	}
+}
+
+func (ha *haController) tick(now time.Time) {
+	db := ha.as.db
+	if db == nil {
+		return
+	}
+	previous, acquired, err := models.AcquireSipHALease(db, ha.cfg.Cluster, ha.as.instance, ha.cfg.LeaseTTL, now)
+	active := ha.isActive()
+	switch {
+	case err != nil:
+		logrus.WithError(err).Warn("Failed to renew SIP HA lease")
+		// Without the database the lease can't be confirmed; stop before another instance takes over
+		ha.mu.RLock()
+		expired := now.Sub(ha.renewedAt) >= ha.cfg.LeaseTTL
+		ha.mu.RUnlock()
+		if active && expired {
+			ha.stepDown("lease could not be renewed: " + err.Error())
+		}
+	case acquired:
+		ha.mu.Lock()
+		ha.renewedAt = now
+		ha.mu.Unlock()
+		if !active {
+			ha.promote(previous)
+		}
+	case active:
+		ha.stepDown("lease taken over by " + previous.Holder)
+	}
+
+	if !ha.isActive() {
+		ha.mirrorRegistrar()
+	}
+	ha.heartbeat(now)
+}
+
+// mirrorRegistrar replaces the in-memory registrations with the ones in the shared registrar,
+// so the standby routes calls to registered users as soon as it takes over
+func (ha *haController) mirrorRegistrar() {
+	users, err := models.GetRegisteredSipUsers(ha.as.db)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to mirror SIP registrations")
+		return
+	}
+	registered := make(map[string]string, len(users))
+	for i := range users {
+		u := &users[i]
+		if u.IsExpired() || u.ContactIP == "" {
+			continue
+		}
+		registered[u.Username] = fmt.Sprintf("%s:%d", u.ContactIP, u.ContactPort)
+	}
+	ha.as.registerMutex.Lock()
+	ha.as.registeredUsers = registered
+	ha.as.registerMutex.Unlock()
+
+	ha.mu.Lock()
+	ha.mirrored = len(registered)
+	ha.mu.Unlock()
+}
+
+func (ha *haController) heartbeat(now time.Time) {
+	role := models.SipHARoleStandby
+	if ha.isActive() {
+		role = models.SipHARoleActive
+	}
+	ha.mu.RLock()
+	mirrored := ha.mirrored
+	ha.mu.RUnlock()
+	node := &models.SipHANode{
+		Cluster:       ha.cfg.Cluster,
+		Instance:      ha.as.instance,
+		Role:          role,
+		VIP:           ha.cfg.VIP,
+		HeartbeatAt:   now,
+		MirroredUsers: mirrored,
+		ActiveCalls:   len(ha.as.activeAICalls()),
+	}
+	if err := models.HeartbeatSipHANode(ha.as.db, node); err != nil {
+		logrus.WithError(err).Warn("Failed to record SIP HA heartbeat")
+	}
+}
+
+// promote takes over the VIP and the calls of the previous holder
+func (ha *haController) promote(previous models.SipHALease) {
+	log := logrus.WithFields(logrus.Fields{"cluster": ha.cfg.Cluster, "previous": previous.Holder})
+	if err := ha.runCommand(ha.cfg.UpCmd); err != nil {
+		log.WithError(err).Error("Failed to take over the SIP media VIP")
+	}
+	ha.mirrorRegistrar()
+	ha.mu.Lock()
+	ha.active = true
+	ha.epoch = previous.Epoch + 1
+	ha.mu.Unlock()
+	log.Info("SIP media instance became active")
+
+	if previous.Holder == "" || previous.Holder == ha.as.instance {
+		return
+	}
+	event := &models.SipFailoverEvent{
+		Cluster:      ha.cfg.Cluster,
+		Kind:         models.SipFailoverEventFailover,
+		FromInstance: previous.Holder,
+		ToInstance:   ha.as.instance,
+		Epoch:        previous.Epoch + 1,
+		Reason:       "lease of the active instance expired",
+	}
+	if previous.DrainedInstance == previous.Holder {
+		event.Kind = models.SipFailoverEventDrill
+		event.Reason = "failover drill"
+	}
+	checkpoints, err := models.ListInstanceAICallCheckpoints(ha.as.db, previous.Holder)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list AI calls of the previous active instance")
+	}
+	for i := range checkpoints {
+		switch ha.as.recoverAICall(&checkpoints[i]) {
+		case callResumed:
+			event.ResumedCalls++
+		case callClosed:
+			event.TerminatedCalls++
+		}
+	}
+	if takeover := time.Since(previous.ExpiresAt); !previous.ExpiresAt.IsZero() && takeover > 0 {
+		event.TakeoverMs = takeover.Milliseconds()
+	}
+	if err := models.RecordSipFailoverEvent(ha.as.db, event); err != nil {
+		log.WithError(err).Warn("Failed to record SIP failover")
+	}
+	log.WithFields(logrus.Fields{
+		"kind":       event.Kind,
+		"resumed":    event.ResumedCalls,
+		"terminated": event.TerminatedCalls,
+	}).Warn("SIP media failover completed")
+}
+
+// stepDown stops handling media after losing the lease. AI sessions are stopped
+// without hanging up and keep their checkpoints, so the new active instance resumes them.
+func (ha *haController) stepDown(reason string) {
+	ha.mu.Lock()
+	ha.active = false
+	ha.mu.Unlock()
+	log := logrus.WithFields(logrus.Fields{"cluster": ha.cfg.Cluster, "reason": reason})
+	if err := ha.runCommand(ha.cfg.DownCmd); err != nil {
+		log.WithError(err).Error("Failed to release the SIP media VIP")
+	}
+	suspended := ha.as.suspendAISessions()
+	log.WithField("suspended_calls", suspended).Warn("SIP media instance stepped down to standby")
+
+	event := &models.SipFailoverEvent{
+		Cluster:      ha.cfg.Cluster,
+		Kind:         models.SipFailoverEventStepDown,
+		FromInstance: ha.as.instance,
+		Epoch:        ha.epoch,
+		Reason:       reason,
+	}
+	if err := models.RecordSipFailoverEvent(ha.as.db, event); err != nil {
+		log.WithError(err).Warn("Failed to record SIP step down")
+	}
+}
+
+func (ha *haController) runCommand(command string) error {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), haCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), "SIP_HA_VIP="+ha.cfg.VIP, "SIP_HA_CLUSTER="+ha.cfg.Cluster)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// suspendAISessions stops the local AI sessions without ending the calls; the
+// checkpoints stay in place for the instance taking over
+func (as *SipServer) suspendAISessions() int {
+	as.voiceHandlersMu.Lock()
+	handlers := as.voiceHandlers
+	as.voiceHandlers = make(map[string]*VoiceConversationHandler)
+	as.voiceHandlersMu.Unlock()
+
+	for callID, handler := range handlers {
+		handler.Stop()
+		as.aiSessionMutex.Lock()
+		delete(as.aiSessionInfo, callID)
+		as.aiSessionMutex.Unlock()
+	}
+	return len(handlers)
+}
+
+// rejectOnStandby answers new INVITEs with 503 while this instance is the standby,
+// so upstream proxies retry the active instance
+func (as *SipServer) rejectOnStandby(req *sip.Request, tx sip.ServerTransaction) bool {
+	if as.mediaActive() {
+		return false
+	}
+	res := sip.NewResponseFromRequest(req, sip.StatusServiceUnavailable, "Service Unavailable", nil)
+	retryAfter := sip.NewHeader("Retry-After", "5")
+	res.AppendHeader(retryAfter)
+	if err := tx.Respond(res); err != nil {
+		logrus.WithError(err).Error("Failed to send 503 response on standby")
+	}
+	return true
+}
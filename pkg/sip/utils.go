@@ -3,6 +3,7 @@ package sip
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -152,9 +153,21 @@ func getServerIPFromRequest(req *sip.Request) string {
 	return localIP
 }
 
-func generateSDP(serverIP string, rtpPort int) string {
+// generateSDP 生成 SDP，comfortNoise 为 true 时同时声明 RFC 3389 舒适噪声负载（应答中仅在对端提供时声明）
+func generateSDP(serverIP string, rtpPort int, comfortNoise bool) string {
 	// Use pion/sdp library to generate standard SDP response
 	sessionID := time.Now().Unix()
+	formats := []string{"0", strconv.Itoa(dtmfPayloadType)}
+	rtpmaps := []sdp.Attribute{
+		{Key: "rtpmap", Value: "0 PCMU/8000/1"},
+		{Key: "rtpmap", Value: fmt.Sprintf("%d telephone-event/8000", dtmfPayloadType)},
+	}
+	fallbackCN := ""
+	if comfortNoise {
+		formats = append(formats, strconv.Itoa(comfortNoisePayloadType))
+		rtpmaps = append(rtpmaps, sdp.Attribute{Key: "rtpmap", Value: fmt.Sprintf("%d CN/8000", comfortNoisePayloadType)})
+		fallbackCN = fmt.Sprintf(" %d", comfortNoisePayloadType)
+	}
 
 	session := sdp.SessionDescription{
 		Version: 0,
@@ -181,12 +194,12 @@ func generateSDP(serverIP string, rtpPort int) string {
 					Media:   "audio",
 					Port:    sdp.RangedPort{Value: rtpPort},
 					Protos:  []string{"RTP", "AVP"},
-					Formats: []string{"0"},
-				},
-				Attributes: []sdp.Attribute{
-					{Key: "rtpmap", Value: "0 PCMU/8000/1"},
-					{Key: "sendrecv", Value: ""},
+					Formats: formats,
 				},
+				Attributes: append(rtpmaps,
+					sdp.Attribute{Key: "fmtp", Value: fmt.Sprintf("%d 0-15", dtmfPayloadType)},
+					sdp.Attribute{Key: "sendrecv", Value: ""},
+				),
 			},
 		},
 	}
@@ -201,10 +214,12 @@ func generateSDP(serverIP string, rtpPort int) string {
 			"s=SIP Call\r\n"+
 			"c=IN IP4 %s\r\n"+
 			"t=0 0\r\n"+
-			"m=audio %d RTP/AVP 0\r\n"+
+			"m=audio %d RTP/AVP 0 %d%s\r\n"+
 			"a=rtpmap:0 PCMU/8000/1\r\n"+
+			"a=rtpmap:%d telephone-event/8000\r\n"+
+			"a=fmtp:%d 0-15\r\n"+
 			"a=sendrecv\r\n",
-			sessionID, sessionID, serverIP, serverIP, rtpPort)
+			sessionID, sessionID, serverIP, serverIP, rtpPort, dtmfPayloadType, fallbackCN, dtmfPayloadType, dtmfPayloadType)
 	}
 
 	return string(sdpBytes)
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
 	"github.com/code-100-precent/LingEcho/pkg/llm"
+	"github.com/code-100-precent/LingEcho/pkg/mediastream"
 	"github.com/code-100-precent/LingEcho/pkg/recognizer"
 	"github.com/code-100-precent/LingEcho/pkg/sip/codec"
 	"github.com/code-100-precent/LingEcho/pkg/synthesizer"
@@ -21,7 +23,7 @@ import (
 type VoiceConversationHandler struct {
 	// 基本信息
 	callID        string
-	clientRTPAddr *net.UDPAddr
+	clientRTPAddr atomic.Pointer[net.UDPAddr] // 对端 RTP 地址，re-INVITE 时原子替换
 	rtpConn       *net.UDPConn
 
 	// 服务
@@ -64,11 +66,32 @@ type VoiceConversationHandler struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
+	// 结束通话前的满意度调查，未启用时为空
+	survey     func(ctx context.Context)
+	finishOnce sync.Once
+	finishing  bool // 对话已结束，不再处理用户语音
+
+	// 检查点，进程重启后据此恢复会话
+	db           *gorm.DB
+	checkpoint   *models.AICallCheckpoint
+	checkpointMu sync.Mutex
+	resumed      bool   // 由检查点恢复的会话，不再播放开场白
+	pendingTurn  string // 恢复前尚未回答的用户话语
+
+	// 外部媒体流，供外部服务收听通话音频并注入音频
+	media *mediastream.Call
+
 	// RTP 发送参数
 	rtpSSRC      uint32
 	rtpSeqNum    uint16
 	rtpTimestamp uint32
 	rtpMutex     sync.Mutex
+
+	// 发送方向静音抑制，未启用时为空
+	suppressor *silenceSuppressor
+
+	// LLM/TTS 超时保护和备用服务商，未启用时为空
+	guard *providerGuard
 }
 
 // NewVoiceConversationHandler 创建语音对话处理器
@@ -87,9 +110,8 @@ func NewVoiceConversationHandler(
 	// 判断是否需要录音
 	isRecording := sipUser != nil && sipUser.RecordingEnabled
 
-	return &VoiceConversationHandler{
+	h := &VoiceConversationHandler{
 		callID:            callID,
-		clientRTPAddr:     clientRTPAddr,
 		rtpConn:           rtpConn,
 		credential:        credential,
 		asrTranscriber:    asrTranscriber,
@@ -113,6 +135,18 @@ func NewVoiceConversationHandler(
 		rtpSeqNum:         0,
 		rtpTimestamp:      0,
 	}
+	h.clientRTPAddr.Store(clientRTPAddr)
+	return h
+}
+
+// rtpTarget 返回当前的对端 RTP 地址
+func (h *VoiceConversationHandler) rtpTarget() *net.UDPAddr {
+	return h.clientRTPAddr.Load()
+}
+
+// setRTPTarget 切换对端 RTP 地址，发送和接收协程从下一个包起使用新地址
+func (h *VoiceConversationHandler) setRTPTarget(addr *net.UDPAddr) {
+	h.clientRTPAddr.Store(addr)
 }
 
 // Start 启动语音对话处理
@@ -126,6 +160,26 @@ func (h *VoiceConversationHandler) Start() {
 	h.wg.Add(1)
 	go h.processAudioLoop()
 
+	// 恢复的会话先回答中断前未回答的话语
+	if h.resumed {
+		if h.pendingTurn != "" {
+			h.processingMu.Lock()
+			h.isProcessing = true
+			h.processingMu.Unlock()
+			h.wg.Add(1)
+			go func(text string) {
+				defer h.wg.Done()
+				defer func() {
+					h.processingMu.Lock()
+					h.isProcessing = false
+					h.processingMu.Unlock()
+				}()
+				h.respond(text)
+			}(h.pendingTurn)
+		}
+		return
+	}
+
 	// 如果配置了开场白，立即播放
 	if h.sipUser != nil && h.sipUser.OpeningMessage != "" {
 		logrus.WithFields(logrus.Fields{
@@ -145,6 +199,11 @@ func (h *VoiceConversationHandler) Stop() {
 	// 取消 context
 	h.cancel()
 
+	// 结束外部媒体流
+	if h.media != nil {
+		h.media.Close()
+	}
+
 	// 清空音频缓冲区
 	h.bufferMutex.Lock()
 	h.audioBuffer = h.audioBuffer[:0]
@@ -177,7 +236,7 @@ func (h *VoiceConversationHandler) ProcessAudioPacket(audioData []byte) {
 				"call_id":  h.callID,
 				"duration": duration.Seconds(),
 			}).Info("📞 留言时间到，准备挂断")
-			h.cancel() // 触发挂断
+			h.finish()
 			return
 		}
 		// 在留言阶段不处理语音识别，只录音
@@ -247,7 +306,7 @@ func (h *VoiceConversationHandler) detectVoiceActivity(pcmuData []byte) bool {
 // tryProcessBuffer 尝试处理缓冲区
 func (h *VoiceConversationHandler) tryProcessBuffer() {
 	h.processingMu.Lock()
-	if h.isProcessing {
+	if h.isProcessing || h.finishing {
 		h.processingMu.Unlock()
 		return
 	}
@@ -497,6 +556,25 @@ func (h *VoiceConversationHandler) processBufferedAudio() {
 		"text":    text,
 	}).Info("✓ ASR 识别结果")
 
+	h.respond(text)
+}
+
+// respond 回答一轮识别出的用户话语：关键词/LLM 回复、TTS 合成并播放
+func (h *VoiceConversationHandler) respond(text string) {
+	if h.guard != nil {
+		h.guard.beginTurn()
+	}
+	h.saveCheckpoint(func(cp *models.AICallCheckpoint) {
+		cp.State = models.AICallStateThinking
+		cp.PendingTurn = text
+	})
+	defer h.saveCheckpoint(func(cp *models.AICallCheckpoint) {
+		if cp.State != models.AICallStateMessage {
+			cp.State = models.AICallStateListening
+		}
+		cp.PendingTurn = ""
+	})
+
 	// 3. 检查关键词回复
 	var aiResponse string
 	if keywordReply, matched := h.checkKeywordReply(text); matched {
@@ -509,14 +587,14 @@ func (h *VoiceConversationHandler) processBufferedAudio() {
 	} else if h.sipUser != nil && h.sipUser.AIFreeResponse {
 		// 4. 启用了AI自由回答，使用 LLM 对话
 		var err error
-		aiResponse, err = h.llmProvider.Query(text, "")
+		aiResponse, err = h.queryLLM(text)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"call_id": h.callID,
 				"error":   err,
 			}).Error("❌ LLM 对话失败")
 
-			// 如果配置了兜底回复，使用兜底回复
+			// 如果配置了兜底回复，使用兜底回复，否则致歉并转入留言
 			if h.sipUser != nil && h.sipUser.FallbackMessage != "" {
 				aiResponse = h.sipUser.FallbackMessage
 				logrus.WithFields(logrus.Fields{
@@ -524,6 +602,7 @@ func (h *VoiceConversationHandler) processBufferedAudio() {
 					"reply":   aiResponse,
 				}).Info("🔄 使用兜底回复")
 			} else {
+				h.apologize()
 				return
 			}
 		} else {
@@ -555,10 +634,6 @@ func (h *VoiceConversationHandler) processBufferedAudio() {
 		shouldEnterMessage = true
 	}
 
-	// 5. TTS 合成 - 使用独立的 context
-	ttsCtx, ttsCancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer ttsCancel()
-
 	// 如果需要进入留言阶段，在AI回复后添加留言提示
 	ttsText := aiResponse
 	if shouldEnterMessage {
@@ -572,25 +647,34 @@ func (h *VoiceConversationHandler) processBufferedAudio() {
 		}).Info("📞 准备进入留言阶段")
 	}
 
-	// 创建 TTS handler 来接收音频数据
-	ttsBuffer := &synthesizer.SynthesisBuffer{}
-	if err := h.ttsService.Synthesize(ttsCtx, ttsBuffer, ttsText); err != nil {
+	// 5. TTS 合成，主服务商超时或失败时切换备用服务商
+	synthesized, err := h.synthesize(ttsText)
+	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"call_id": h.callID,
 			"error":   err,
 		}).Error("❌ TTS 合成失败")
+		h.apologize()
 		return
 	}
 
-	audioResponse := ttsBuffer.Data
+	audioResponse := synthesized.pcm
 
 	logrus.WithFields(logrus.Fields{
 		"call_id": h.callID,
 		"bytes":   len(audioResponse),
 	}).Info("🔊 TTS 合成成功")
 
-	// 6. 发送音频到客户端
-	h.sendAudioToClient(audioResponse)
+	h.saveCheckpoint(func(cp *models.AICallCheckpoint) {
+		cp.State = models.AICallStateSpeaking
+		cp.Turns = h.conversationCount
+		cp.LastUserText = text
+		cp.LastAIText = aiResponse
+	})
+
+	// 6. 发送音频到客户端，等待垫话播放完毕以免交错
+	h.awaitFiller()
+	h.sendAudioAtRate(audioResponse, synthesized.sampleRate)
 
 	// 7. 如果需要进入留言阶段，播放完后进入留言状态
 	if shouldEnterMessage {
@@ -604,7 +688,7 @@ func (h *VoiceConversationHandler) processBufferedAudio() {
 		if h.conversationCount >= 2 {
 			logrus.WithField("call_id", h.callID).Info("📞 对话结束，未启用录音，准备挂断")
 			time.Sleep(2 * time.Second) // 等待2秒后挂断
-			h.cancel()
+			h.finish()
 		}
 	}
 }
@@ -616,6 +700,11 @@ func (h *VoiceConversationHandler) enterMessageMode() {
 	h.messageStartTime = time.Now()
 	h.recordingMutex.Unlock()
 
+	h.saveCheckpoint(func(cp *models.AICallCheckpoint) {
+		cp.State = models.AICallStateMessage
+		cp.MessageAt = &h.messageStartTime
+	})
+
 	logrus.WithField("call_id", h.callID).Info("📞 已进入留言阶段，继续录音15秒")
 
 	// 启动定时器，15秒后自动结束
@@ -625,13 +714,42 @@ func (h *VoiceConversationHandler) enterMessageMode() {
 		if h.isInMessageMode {
 			h.recordingMutex.Unlock()
 			logrus.WithField("call_id", h.callID).Info("📞 留言时间到，自动挂断")
-			h.cancel() // 触发挂断
+			h.finish()
 		} else {
 			h.recordingMutex.Unlock()
 		}
 	}()
 }
 
+// finish 助手结束对话：启用了满意度调查时先进行调查，再触发挂断。可重复调用
+func (h *VoiceConversationHandler) finish() {
+	h.finishOnce.Do(func() {
+		h.processingMu.Lock()
+		h.finishing = true
+		h.processingMu.Unlock()
+
+		go func() {
+			if h.survey != nil && h.ctx.Err() == nil {
+				h.survey(h.ctx)
+			}
+			h.cancel() // 触发挂断
+		}()
+	})
+}
+
+// speak 合成并播放一段提示语
+func (h *VoiceConversationHandler) speak(ctx context.Context, text string) error {
+	ttsCtx, ttsCancel := context.WithTimeout(ctx, 15*time.Second)
+	defer ttsCancel()
+
+	ttsBuffer := &synthesizer.SynthesisBuffer{}
+	if err := h.ttsService.Synthesize(ttsCtx, ttsBuffer, text); err != nil {
+		return err
+	}
+	h.sendAudioToClient(ttsBuffer.Data)
+	return ctx.Err()
+}
+
 // checkKeywordReply 检查是否匹配关键词回复
 func (h *VoiceConversationHandler) checkKeywordReply(text string) (string, bool) {
 	if h.sipUser == nil || len(h.sipUser.KeywordReplies) == 0 {
@@ -716,9 +834,11 @@ func (h *VoiceConversationHandler) sendAudioToClient(audioData []byte) {
 	}).Info("准备发送音频")
 
 	// 获取 TTS 服务的实际采样率
-	ttsFormat := h.ttsService.Format()
-	ttsSampleRate := ttsFormat.SampleRate
+	h.sendAudioAtRate(audioData, h.ttsService.Format().SampleRate)
+}
 
+// sendAudioAtRate 将指定采样率的 PCM16 音频转为 PCMU 发送，备用服务商的采样率可能与主服务商不同
+func (h *VoiceConversationHandler) sendAudioAtRate(audioData []byte, ttsSampleRate int) {
 	logrus.WithFields(logrus.Fields{
 		"call_id":         h.callID,
 		"tts_sample_rate": ttsSampleRate,
@@ -749,7 +869,7 @@ func (h *VoiceConversationHandler) sendAudioToClient(audioData []byte) {
 		"pcmu_len": len(pcmuData),
 	}).Info("🔄 PCM -> PCMU 转换完成")
 
-	// 3. 如果启用了录音，将AI的音频也添加到录音缓冲区
+	// 3. 如果启用了录音，将AI的音频也添加到录音缓冲区（录音使用完整音频，不受静音抑制影响）
 	if h.isRecording {
 		h.recordingMutex.Lock()
 		h.recordingBuffer = append(h.recordingBuffer, pcmuData...)
@@ -774,13 +894,14 @@ func (h *VoiceConversationHandler) sendPCMUPackets(pcmuData []byte) {
 	logrus.WithFields(logrus.Fields{
 		"call_id":     h.callID,
 		"packets":     packetsCount,
-		"client_addr": h.clientRTPAddr.String(),
+		"client_addr": h.rtpTarget().String(),
 	}).Info("📦 开始发送 RTP 包")
 
 	h.rtpMutex.Lock()
 	seqNum := h.rtpSeqNum
 	timestamp := h.rtpTimestamp
 	h.rtpMutex.Unlock()
+	suppressed := 0
 
 	for i := 0; i < packetsCount; i++ {
 		start := i * packetSize
@@ -798,13 +919,27 @@ func (h *VoiceConversationHandler) sendPCMUPackets(pcmuData []byte) {
 
 		payload := pcmuData[start:end]
 
+		// 静音抑制：静音帧不发送或改发舒适噪声，时间戳照常推进
+		action, talkspurt, cn := h.suppressor.next(payload)
+		if action == frameSkip {
+			suppressed++
+			timestamp += 160
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		payloadType := uint8(0) // PCMU
+		if action == frameComfortNoise {
+			payloadType, payload = comfortNoisePayloadType, cn
+			suppressed++
+		}
+
 		packet := &rtp.Packet{
 			Header: rtp.Header{
 				Version:        2,
 				Padding:        false,
 				Extension:      false,
-				Marker:         i == packetsCount-1,
-				PayloadType:    0, // PCMU
+				Marker:         i == packetsCount-1 || talkspurt,
+				PayloadType:    payloadType,
 				SequenceNumber: seqNum,
 				Timestamp:      timestamp,
 				SSRC:           h.rtpSSRC,
@@ -821,7 +956,7 @@ func (h *VoiceConversationHandler) sendPCMUPackets(pcmuData []byte) {
 			continue
 		}
 
-		_, err = h.rtpConn.WriteToUDP(packetBytes, h.clientRTPAddr)
+		_, err = h.rtpConn.WriteToUDP(packetBytes, h.rtpTarget())
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"call_id": h.callID,
@@ -842,7 +977,23 @@ func (h *VoiceConversationHandler) sendPCMUPackets(pcmuData []byte) {
 	h.rtpTimestamp = timestamp
 	h.rtpMutex.Unlock()
 
-	logrus.WithField("call_id", h.callID).Info("✓ 音频发送完成")
+	logrus.WithFields(logrus.Fields{
+		"call_id":    h.callID,
+		"suppressed": suppressed,
+	}).Info("✓ 音频发送完成")
+}
+
+// enableSilenceSuppression 按方案配置开启发送方向的静音抑制
+func (h *VoiceConversationHandler) enableSilenceSuppression(cfg SilenceSuppressionConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	h.suppressor = newSilenceSuppressor(cfg)
+	logrus.WithFields(logrus.Fields{
+		"call_id":        h.callID,
+		"threshold_dbov": cfg.ThresholdDB,
+		"comfort_noise":  cfg.ComfortNoise,
+	}).Info("🔇 已启用静音抑制")
 }
 
 // processAudioLoop 音频处理循环
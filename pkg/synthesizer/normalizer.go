@@ -0,0 +1,31 @@
+package synthesizer
+
+import (
+	"context"
+
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer/textnorm"
+)
+
+// normalizingService rewrites numbers, currencies, dates and phone numbers into
+// speakable words before handing the text to the wrapped service
+type normalizingService struct {
+	SynthesisService
+	opts textnorm.Options
+}
+
+// WithTextNormalization wraps svc so that every text is normalized for speech
+// first, see textnorm.Normalize
+func WithTextNormalization(svc SynthesisService, opts textnorm.Options) SynthesisService {
+	if svc == nil || opts.Disabled {
+		return svc
+	}
+	return &normalizingService{SynthesisService: svc, opts: opts}
+}
+
+func (s *normalizingService) CacheKey(text string) string {
+	return s.SynthesisService.CacheKey(textnorm.Normalize(text, s.opts))
+}
+
+func (s *normalizingService) Synthesize(ctx context.Context, handler SynthesisHandler, text string) error {
+	return s.SynthesisService.Synthesize(ctx, handler, textnorm.Normalize(text, s.opts))
+}
@@ -0,0 +1,182 @@
+package textnorm
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	enOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	enTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	enScales = []struct {
+		value int64
+		name  string
+	}{{1000000000, "billion"}, {1000000, "million"}, {1000, "thousand"}}
+	enMonths = []string{"", "January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+	// enOrdinals irregular ordinals, keyed by the cardinal word they replace
+	enOrdinals = map[string]string{
+		"one": "first", "two": "second", "three": "third", "five": "fifth",
+		"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+	}
+	// enCurrencies singular, plural, minor singular, minor plural
+	enCurrencies = map[string][4]string{
+		"USD": {"dollar", "dollars", "cent", "cents"},
+		"EUR": {"euro", "euros", "cent", "cents"},
+		"GBP": {"pound", "pounds", "penny", "pence"},
+		"CNY": {"yuan", "yuan", "fen", "fen"},
+	}
+)
+
+// enSpeller English readings
+type enSpeller struct{}
+
+func (enSpeller) minus() string {
+	return "minus "
+}
+
+func (e enSpeller) cardinal(n int64) string {
+	if n < 0 {
+		return e.minus() + e.cardinal(-n)
+	}
+	if n < 1000 {
+		return enHundreds(int(n))
+	}
+	var parts []string
+	for _, scale := range enScales {
+		if n >= scale.value {
+			parts = append(parts, e.cardinal(n/scale.value)+" "+scale.name)
+			n %= scale.value
+		}
+	}
+	if n > 0 {
+		parts = append(parts, enHundreds(int(n)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// enHundreds reads 0-999
+func enHundreds(n int) string {
+	if n < 20 {
+		return enOnes[n]
+	}
+	if n < 100 {
+		if n%10 == 0 {
+			return enTens[n/10]
+		}
+		return enTens[n/10] + "-" + enOnes[n%10]
+	}
+	s := enOnes[n/100] + " hundred"
+	if n%100 > 0 {
+		s += " " + enHundreds(n%100)
+	}
+	return s
+}
+
+func (e enSpeller) decimal(intPart int64, frac string) string {
+	return e.cardinal(intPart) + " point " + e.digits(frac)
+}
+
+func (enSpeller) digits(s string) string {
+	words := make([]string, 0, len(s))
+	for _, r := range s {
+		if isDigit(r) {
+			words = append(words, enOnes[r-'0'])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (e enSpeller) phone(groups []string) string {
+	parts := make([]string, 0, len(groups))
+	for _, g := range groups {
+		prefix := ""
+		if strings.HasPrefix(g, "+") {
+			prefix = "plus "
+		}
+		parts = append(parts, prefix+e.digits(g))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (e enSpeller) money(currency string, intPart int64, frac string) string {
+	names, ok := enCurrencies[currency]
+	if !ok {
+		names = enCurrencies["USD"]
+	}
+	minor := 0
+	if frac != "" {
+		minor, _ = strconv.Atoi((frac + "0")[:2])
+	}
+
+	var parts []string
+	if intPart > 0 || minor == 0 {
+		unit := names[1]
+		if intPart == 1 {
+			unit = names[0]
+		}
+		parts = append(parts, e.cardinal(intPart)+" "+unit)
+	}
+	if minor > 0 {
+		unit := names[3]
+		if minor == 1 {
+			unit = names[2]
+		}
+		parts = append(parts, e.cardinal(int64(minor))+" "+unit)
+	}
+	return strings.Join(parts, " and ")
+}
+
+func (e enSpeller) date(year, month, day int) string {
+	return enMonths[month] + " " + enOrdinal(e.cardinal(int64(day))) + ", " + e.year(year)
+}
+
+// year reads years the way they are spoken: "nineteen ninety-nine", "two thousand five"
+func (e enSpeller) year(y int) string {
+	hi, lo := y/100, y%100
+	switch {
+	case y >= 2000 && y < 2010, hi%10 == 0 && lo == 0:
+		return e.cardinal(int64(y))
+	case lo == 0:
+		return enHundreds(hi) + " hundred"
+	case lo < 10:
+		return enHundreds(hi) + " oh " + enOnes[lo]
+	}
+	return enHundreds(hi) + " " + enHundreds(lo)
+}
+
+func (e enSpeller) clock(hour, minute int) string {
+	switch {
+	case minute == 0:
+		return e.cardinal(int64(hour)) + " o'clock"
+	case minute < 10:
+		return e.cardinal(int64(hour)) + " oh " + enOnes[minute]
+	}
+	return e.cardinal(int64(hour)) + " " + e.cardinal(int64(minute))
+}
+
+func (e enSpeller) percent(value string) string {
+	intStr, frac, _ := strings.Cut(value, ".")
+	n, ok := parseInt(intStr)
+	if !ok {
+		return e.digits(value) + " percent"
+	}
+	if frac != "" {
+		return e.decimal(n, frac) + " percent"
+	}
+	return e.cardinal(n) + " percent"
+}
+
+// enOrdinal turns the last word of a cardinal into its ordinal form
+func enOrdinal(cardinal string) string {
+	cut := strings.LastIndexAny(cardinal, " -") + 1
+	head, last := cardinal[:cut], cardinal[cut:]
+	if o, ok := enOrdinals[last]; ok {
+		return head + o
+	}
+	if strings.HasSuffix(last, "y") {
+		return head + strings.TrimSuffix(last, "y") + "ieth"
+	}
+	return head + last + "th"
+}
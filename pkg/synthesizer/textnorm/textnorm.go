@@ -0,0 +1,324 @@
+// Package textnorm rewrites numbers, currencies, dates and phone numbers in
+// LLM output into the words a TTS engine should speak.
+package textnorm
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule names, usable in Options.SkipRules
+const (
+	RuleDate     = "date"
+	RuleTime     = "time"
+	RuleCurrency = "currency"
+	RulePercent  = "percent"
+	RulePhone    = "phone"
+	RuleNumber   = "number"
+)
+
+// Options 文本规范化选项
+type Options struct {
+	Disabled     bool              `json:"disabled,omitempty"`
+	Language     string            `json:"language,omitempty"`     // zh, en; other languages only get Replacements
+	SkipRules    []string          `json:"skipRules,omitempty"`    // Rules left untouched, e.g. ["phone"]
+	Replacements map[string]string `json:"replacements,omitempty"` // Literal rewrites applied first, e.g. {"VIP": "V I P"}
+}
+
+// speller language specific readings
+type speller interface {
+	cardinal(n int64) string
+	decimal(intPart int64, frac string) string
+	digits(s string) string
+	phone(groups []string) string
+	money(currency string, intPart int64, frac string) string
+	date(year, month, day int) string
+	clock(hour, minute int) string
+	percent(value string) string
+	minus() string
+}
+
+var (
+	replacementBoundary = regexp.MustCompile(`\s+`)
+
+	ymdRe     = regexp.MustCompile(`(\d{4})\s?[-/.年]\s?(\d{1,2})\s?[-/.月]\s?(\d{1,2})日?`)
+	zhYearRe  = regexp.MustCompile(`(\d{4})年`)
+	clockRe   = regexp.MustCompile(`(\d{1,2}):(\d{2})`)
+	moneyRe   = regexp.MustCompile(`(US\$|\$|¥|￥|RMB|CNY|USD|€|EUR|£|GBP)\s?(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d{1,2}))?`)
+	yuanRe    = regexp.MustCompile(`(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d{1,2}))?\s?(元|块钱|块)`)
+	percentRe = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?[%％]`)
+	phoneRe   = regexp.MustCompile(`\+?\d[\d-]*\d`)
+	numberRe  = regexp.MustCompile(`-?(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d+))?`)
+	mobileRe  = regexp.MustCompile(`^1[3-9]\d{9}$`)
+)
+
+// maxCardinal numbers above are read digit by digit
+const maxCardinal = 999999999999
+
+var currencyCodes = map[string]string{
+	"US$": "USD", "$": "USD", "USD": "USD",
+	"¥": "CNY", "￥": "CNY", "RMB": "CNY", "CNY": "CNY", "元": "CNY", "块": "CNY", "块钱": "CNY",
+	"€": "EUR", "EUR": "EUR",
+	"£": "GBP", "GBP": "GBP",
+}
+
+// Normalize rewrites text into speakable form
+func Normalize(text string, opts Options) string {
+	if opts.Disabled || text == "" {
+		return text
+	}
+	text = applyReplacements(text, opts.Replacements)
+
+	sp := spellerFor(opts.Language)
+	if sp == nil {
+		return text
+	}
+	skip := make(map[string]bool, len(opts.SkipRules))
+	for _, r := range opts.SkipRules {
+		skip[strings.ToLower(strings.TrimSpace(r))] = true
+	}
+	zh := isChinese(opts.Language)
+
+	if !skip[RuleDate] {
+		text = replace(text, ymdRe, func(m []string, before, after rune) (string, bool) {
+			if isDigit(before) || isDigit(after) {
+				return "", false
+			}
+			year, _ := strconv.Atoi(m[1])
+			month, _ := strconv.Atoi(m[2])
+			day, _ := strconv.Atoi(m[3])
+			if month < 1 || month > 12 || day < 1 || day > 31 {
+				return "", false
+			}
+			return sp.date(year, month, day), true
+		})
+		if zh {
+			text = replace(text, zhYearRe, func(m []string, before, after rune) (string, bool) {
+				if isDigit(before) {
+					return "", false
+				}
+				return sp.digits(m[1]) + "年", true
+			})
+		}
+	}
+
+	if !skip[RuleTime] {
+		text = replace(text, clockRe, func(m []string, before, after rune) (string, bool) {
+			if isDigit(before) || isDigit(after) || before == ':' || after == ':' {
+				return "", false
+			}
+			hour, _ := strconv.Atoi(m[1])
+			minute, _ := strconv.Atoi(m[2])
+			if hour > 23 || minute > 59 {
+				return "", false
+			}
+			return sp.clock(hour, minute), true
+		})
+	}
+
+	if !skip[RuleCurrency] {
+		text = replace(text, moneyRe, func(m []string, before, after rune) (string, bool) {
+			if isDigit(after) {
+				return "", false
+			}
+			intPart, ok := parseInt(m[2])
+			if !ok {
+				return "", false
+			}
+			return sp.money(currencyCodes[m[1]], intPart, m[3]), true
+		})
+		if zh {
+			text = replace(text, yuanRe, func(m []string, before, after rune) (string, bool) {
+				if isDigit(before) || before == '.' {
+					return "", false
+				}
+				intPart, ok := parseInt(m[1])
+				if !ok {
+					return "", false
+				}
+				return sp.money(currencyCodes[m[3]], intPart, m[2]), true
+			})
+		}
+	}
+
+	if !skip[RulePercent] {
+		text = replace(text, percentRe, func(m []string, before, after rune) (string, bool) {
+			if isDigit(before) || before == '.' {
+				return "", false
+			}
+			return sp.percent(m[1]), true
+		})
+	}
+
+	if !skip[RulePhone] {
+		text = replace(text, phoneRe, func(m []string, before, after rune) (string, bool) {
+			if isNumberPart(before) || isNumberPart(after) {
+				return "", false
+			}
+			groups, ok := phoneGroups(m[0])
+			if !ok {
+				return "", false
+			}
+			return sp.phone(groups), true
+		})
+	}
+
+	if !skip[RuleNumber] {
+		text = replace(text, numberRe, func(m []string, before, after rune) (string, bool) {
+			if isDigit(before) || before == '.' || before == ',' || isDigit(after) {
+				return "", false
+			}
+			intPart, ok := parseInt(m[1])
+			var spoken string
+			switch {
+			case !ok:
+				spoken = sp.digits(strings.ReplaceAll(m[1], ",", ""))
+			case m[2] != "":
+				spoken = sp.decimal(intPart, m[2])
+			default:
+				spoken = sp.cardinal(intPart)
+			}
+			if strings.HasPrefix(m[0], "-") {
+				if isLetter(before) || isDigit(before) {
+					// A hyphen, not a minus sign: "GPT-4"
+					spoken = "-" + spoken
+				} else {
+					spoken = sp.minus() + spoken
+				}
+			}
+			return spoken, true
+		})
+	}
+	return text
+}
+
+// phoneGroups splits a phone-like digit string into reading groups, false when
+// the string reads better as a number
+func phoneGroups(s string) ([]string, bool) {
+	plus := strings.HasPrefix(s, "+")
+	s = strings.TrimPrefix(s, "+")
+	if strings.Contains(s, "-") {
+		groups := strings.Split(s, "-")
+		total := 0
+		for _, g := range groups {
+			if len(g) < 2 {
+				return nil, false
+			}
+			total += len(g)
+		}
+		if total < 7 {
+			return nil, false
+		}
+		if plus {
+			groups[0] = "+" + groups[0]
+		}
+		return groups, true
+	}
+	switch {
+	case mobileRe.MatchString(s):
+		return []string{s[:3], s[3:7], s[7:]}, true
+	case plus && len(s) >= 7, len(s) >= 9:
+		var groups []string
+		if plus {
+			s = "+" + s
+		}
+		for len(s) > 4 {
+			groups = append(groups, s[:4])
+			s = s[4:]
+		}
+		return append(groups, s), true
+	}
+	return nil, false
+}
+
+// applyReplacements applies the literal rewrites, longest first so that
+// overlapping keys behave predictably
+func applyReplacements(text string, replacements map[string]string) string {
+	if len(replacements) == 0 {
+		return text
+	}
+	keys := make([]string, 0, len(replacements))
+	for k := range replacements {
+		if strings.TrimSpace(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	pairs := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		pairs = append(pairs, k, replacementBoundary.ReplaceAllString(replacements[k], " "))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// replace is ReplaceAllStringFunc with access to the characters around the
+// match, fn returns false to keep the match unchanged
+func replace(text string, re *regexp.Regexp, fn func(m []string, before, after rune) (string, bool)) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range matches {
+		m := make([]string, len(loc)/2)
+		for i := range m {
+			if loc[2*i] >= 0 {
+				m[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		var before, after rune
+		if loc[0] > 0 {
+			before, _ = utf8.DecodeLastRuneInString(text[:loc[0]])
+		}
+		if loc[1] < len(text) {
+			after, _ = utf8.DecodeRuneInString(text[loc[1]:])
+		}
+		spoken, ok := fn(m, before, after)
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(spoken)
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func spellerFor(language string) speller {
+	switch {
+	case isChinese(language):
+		return zhSpeller{}
+	case strings.HasPrefix(strings.ToLower(language), "en"):
+		return enSpeller{}
+	}
+	return nil
+}
+
+func isChinese(language string) bool {
+	return strings.HasPrefix(strings.ToLower(language), "zh") || strings.HasPrefix(strings.ToLower(language), "cmn")
+}
+
+func parseInt(s string) (int64, bool) {
+	n, err := strconv.ParseInt(strings.ReplaceAll(s, ",", ""), 10, 64)
+	if err != nil || n > maxCardinal {
+		return 0, false
+	}
+	return n, true
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isNumberPart(r rune) bool {
+	return isDigit(r) || r == '.' || r == ','
+}
@@ -0,0 +1,74 @@
+package textnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeChinese(t *testing.T) {
+	opts := Options{Language: "zh-CN"}
+	cases := map[string]string{
+		"请拨打13800138000联系我们":  "请拨打幺三八，零零幺三，八零零零联系我们",
+		"总价¥1,234.50":         "总价一千二百三十四元五角",
+		"只要3.05元":             "只要三元零五分",
+		"会议在2026-10-15 14:05": "会议在二零二六年十月十五日 十四点零五分",
+		"增长了12.5%":            "增长了百分之十二点五",
+		"共10005人，其中15人缺席":     "共一万零五人，其中十五人缺席",
+		"气温-3度":               "气温负三度",
+		"GPT-4":               "GPT-四",
+		"2020年以来":             "二零二零年以来",
+		"售价$20":               "售价二十美元",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, Normalize(in, opts), in)
+	}
+}
+
+func TestNormalizeEnglish(t *testing.T) {
+	opts := Options{Language: "en-US"}
+	cases := map[string]string{
+		"Call 13800138000 now":       "Call one three eight, zero zero one three, eight zero zero zero now",
+		"It costs ¥1,234.50":         "It costs one thousand two hundred thirty-four yuan and fifty fen",
+		"Only $1.01 left":            "Only one dollar and one cent left",
+		"See you on 2026-10-15":      "See you on October fifteenth, twenty twenty-six",
+		"Meet at 9:05 or 10:00":      "Meet at nine oh five or ten o'clock",
+		"Up 40% since 2003/1/2":      "Up forty percent since January second, two thousand three",
+		"Dial 555-0100":              "Dial five five five, zero one zero zero",
+		"We have 1,000,021 users":    "We have one million twenty-one users",
+		"Pi is about 3.14":           "Pi is about three point one four",
+		"Order 12345678901234567890": "Order one two three four, five six seven eight, nine zero one two, three four five six, seven eight nine zero",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, Normalize(in, opts), in)
+	}
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	text := "Call 13800138000, VIP price $5"
+
+	assert.Equal(t, text, Normalize(text, Options{Language: "en", Disabled: true}))
+	assert.Equal(t, "Call 13800138000, V I P price five dollars",
+		Normalize(text, Options{Language: "en", SkipRules: []string{RulePhone, RuleNumber}, Replacements: map[string]string{"VIP": "V I P"}}))
+
+	// Unsupported languages only get the literal replacements
+	assert.Equal(t, "Call 13800138000, V.I.P. price $5",
+		Normalize(text, Options{Language: "ja", Replacements: map[string]string{"VIP": "V.I.P."}}))
+}
+
+func TestChineseCardinal(t *testing.T) {
+	z := zhSpeller{}
+	cases := map[int64]string{
+		0:          "零",
+		10:         "十",
+		110:        "一百一十",
+		1005:       "一千零五",
+		20300:      "二万零三百",
+		150000:     "十五万",
+		100010000:  "一亿零一万",
+		1200000000: "十二亿",
+	}
+	for n, want := range cases {
+		assert.Equal(t, want, z.cardinal(n), n)
+	}
+}
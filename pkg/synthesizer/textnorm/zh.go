@@ -0,0 +1,166 @@
+package textnorm
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	zhDigits     = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	zhSmallUnits = []string{"", "十", "百", "千"}
+	zhBigUnits   = []string{"", "万", "亿"}
+	zhCurrencies = map[string]string{"USD": "美元", "EUR": "欧元", "GBP": "英镑"}
+)
+
+// zhSpeller 中文读法
+type zhSpeller struct{}
+
+func (zhSpeller) minus() string {
+	return "负"
+}
+
+// cardinal 读作中文数字，按万、亿分节，节内和节间的零只读一次
+func (z zhSpeller) cardinal(n int64) string {
+	if n < 0 {
+		return z.minus() + z.cardinal(-n)
+	}
+	if n == 0 {
+		return zhDigits[0]
+	}
+
+	var sections []int
+	for n > 0 {
+		sections = append(sections, int(n%10000))
+		n /= 10000
+	}
+
+	var b strings.Builder
+	needZero := false
+	for i := len(sections) - 1; i >= 0; i-- {
+		sec := sections[i]
+		if sec == 0 {
+			needZero = b.Len() > 0
+			continue
+		}
+		if needZero || (b.Len() > 0 && sec < 1000) {
+			b.WriteString(zhDigits[0])
+		}
+		b.WriteString(zhSection(sec))
+		b.WriteString(zhBigUnits[i])
+		needZero = false
+	}
+
+	s := b.String()
+	// 10-19 读作“十X”而不是“一十X”
+	if strings.HasPrefix(s, "一十") && sections[len(sections)-1] < 20 {
+		s = strings.TrimPrefix(s, "一")
+	}
+	return s
+}
+
+// zhSection 读 1-9999
+func zhSection(n int) string {
+	var b strings.Builder
+	zero := false
+	for pos := 3; pos >= 0; pos-- {
+		unit := 1
+		for i := 0; i < pos; i++ {
+			unit *= 10
+		}
+		d := n / unit % 10
+		if d == 0 {
+			zero = b.Len() > 0
+			continue
+		}
+		if zero {
+			b.WriteString(zhDigits[0])
+			zero = false
+		}
+		b.WriteString(zhDigits[d])
+		b.WriteString(zhSmallUnits[pos])
+	}
+	return b.String()
+}
+
+func (z zhSpeller) decimal(intPart int64, frac string) string {
+	return z.cardinal(intPart) + "点" + z.digits(frac)
+}
+
+func (zhSpeller) digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if isDigit(r) {
+			b.WriteString(zhDigits[r-'0'])
+		}
+	}
+	return b.String()
+}
+
+// phone 号码逐位读，1 读作“幺”
+func (z zhSpeller) phone(groups []string) string {
+	parts := make([]string, 0, len(groups))
+	for _, g := range groups {
+		prefix := ""
+		if strings.HasPrefix(g, "+") {
+			prefix = "加"
+		}
+		parts = append(parts, prefix+strings.ReplaceAll(z.digits(g), "一", "幺"))
+	}
+	return strings.Join(parts, "，")
+}
+
+func (z zhSpeller) money(currency string, intPart int64, frac string) string {
+	if name, ok := zhCurrencies[currency]; ok {
+		if frac == "" || strings.Trim(frac, "0") == "" {
+			return z.cardinal(intPart) + name
+		}
+		return z.decimal(intPart, strings.TrimRight(frac, "0")) + name
+	}
+
+	// 人民币读作元角分
+	frac = (frac + "00")[:2]
+	jiao, fen := int(frac[0]-'0'), int(frac[1]-'0')
+	s := z.cardinal(intPart) + "元"
+	if intPart == 0 && (jiao > 0 || fen > 0) {
+		s = ""
+	}
+	if jiao > 0 {
+		s += zhDigits[jiao] + "角"
+	} else if fen > 0 && s != "" {
+		s += zhDigits[0]
+	}
+	if fen > 0 {
+		s += zhDigits[fen] + "分"
+	}
+	return s
+}
+
+func (z zhSpeller) date(year, month, day int) string {
+	return z.digits(strconv.Itoa(year)) + "年" + z.cardinal(int64(month)) + "月" + z.cardinal(int64(day)) + "日"
+}
+
+func (z zhSpeller) clock(hour, minute int) string {
+	s := z.cardinal(int64(hour)) + "点"
+	if hour == 2 {
+		s = "两点"
+	}
+	switch {
+	case minute == 0:
+		return s
+	case minute < 10:
+		return s + zhDigits[0] + zhDigits[minute] + "分"
+	}
+	return s + z.cardinal(int64(minute)) + "分"
+}
+
+func (z zhSpeller) percent(value string) string {
+	intStr, frac, _ := strings.Cut(value, ".")
+	n, ok := parseInt(intStr)
+	if !ok {
+		return "百分之" + z.digits(value)
+	}
+	if frac != "" {
+		return "百分之" + z.decimal(n, frac)
+	}
+	return "百分之" + z.cardinal(n)
+}
@@ -0,0 +1,117 @@
+package transcript
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+
+const docxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+
+// WriteDOCX writes a Word document: title, call facts, summary and the
+// transcript with the offset and speaker of every utterance
+func (t *Transcript) WriteDOCX(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name string
+		body string
+	}{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRels},
+		{"word/document.xml", t.documentXML()},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (t *Transcript) documentXML() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	b.WriteString(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+
+	title := t.Title
+	if title == "" {
+		title = "Conversation transcript"
+	}
+	docxParagraph(&b, title, true, 32)
+
+	facts := [][2]string{{"Started", t.StartedAt.Format(time.RFC3339)}}
+	if t.Duration > 0 {
+		facts = append(facts, [2]string{"Duration", t.Duration.Round(time.Second).String()})
+	}
+	keys := make([]string, 0, len(t.Metadata))
+	for k := range t.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		facts = append(facts, [2]string{k, t.Metadata[k]})
+	}
+	for _, fact := range facts {
+		docxParagraph(&b, fact[0]+": "+fact[1], false, 0)
+	}
+
+	if t.Summary != "" {
+		docxParagraph(&b, "Summary", true, 26)
+		docxParagraph(&b, t.Summary, false, 0)
+	}
+
+	docxParagraph(&b, "Transcript", true, 26)
+	for _, s := range t.cues() {
+		line := fmt.Sprintf("[%s] %s", formatTimestamp(s.Start, ".")[:8], cueText(s))
+		if s.Corrected {
+			line += " (corrected)"
+		}
+		docxParagraph(&b, line, false, 0)
+	}
+
+	b.WriteString(`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="720" w:footer="720" w:gutter="0"/></w:sectPr>`)
+	b.WriteString(`</w:body></w:document>`)
+	return b.String()
+}
+
+// docxParagraph appends a paragraph, size in half points (0 keeps the default)
+func docxParagraph(b *strings.Builder, text string, bold bool, size int) {
+	b.WriteString("<w:p><w:r>")
+	if bold || size > 0 {
+		b.WriteString("<w:rPr>")
+		if bold {
+			b.WriteString("<w:b/>")
+		}
+		if size > 0 {
+			fmt.Fprintf(b, `<w:sz w:val="%d"/>`, size)
+		}
+		b.WriteString("</w:rPr>")
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.WriteString("<w:br/>")
+		}
+		b.WriteString(`<w:t xml:space="preserve">`)
+		_ = xml.EscapeText(b, []byte(line))
+		b.WriteString("</w:t>")
+	}
+	b.WriteString("</w:r></w:p>")
+}
@@ -0,0 +1,199 @@
+// Package transcript renders a conversation in standard formats: JSON with
+// timings for machines, SRT and WebVTT subtitles aligned to the recording, and
+// a DOCX document for people. Segment offsets are relative to the start of the
+// recording, so subtitles line up when played alongside the audio.
+package transcript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats
+const (
+	FormatJSON = "json"
+	FormatSRT  = "srt"
+	FormatVTT  = "vtt"
+	FormatDOCX = "docx"
+)
+
+// minCueDuration shortest time a subtitle stays on screen
+const minCueDuration = time.Second
+
+var ErrUnknownFormat = errors.New("unknown transcript format")
+
+// Segment one utterance of the conversation
+type Segment struct {
+	Speaker   string        `json:"speaker"` // "user" or "assistant"
+	Text      string        `json:"text"`
+	Start     time.Duration `json:"-"`
+	End       time.Duration `json:"-"`
+	Corrected bool          `json:"-"` // Text was corrected by a reviewer; marked in JSON and DOCX, not in subtitles
+}
+
+// Transcript a conversation with its metadata
+type Transcript struct {
+	Title     string            `json:"title"`
+	Source    string            `json:"source"`   // call_recording or chat_session
+	SourceID  string            `json:"sourceId"` // Recording ID or chat session ID
+	StartedAt time.Time         `json:"startedAt"`
+	Duration  time.Duration     `json:"-"`
+	Summary   string            `json:"summary,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"` // Extra facts listed in the document, e.g. assistant name
+	Segments  []Segment         `json:"-"`
+}
+
+// ValidFormat reports whether the format can be rendered
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatSRT, FormatVTT, FormatDOCX:
+		return true
+	}
+	return false
+}
+
+// ContentType MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatSRT:
+		return "application/x-subrip; charset=utf-8"
+	case FormatVTT:
+		return "text/vtt; charset=utf-8"
+	case FormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	}
+	return "application/octet-stream"
+}
+
+// Render writes the transcript in the given format
+func (t *Transcript) Render(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return t.WriteJSON(w)
+	case FormatSRT:
+		return t.WriteSRT(w)
+	case FormatVTT:
+		return t.WriteVTT(w)
+	case FormatDOCX:
+		return t.WriteDOCX(w)
+	}
+	return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+type jsonSegment struct {
+	Index     int       `json:"index"`
+	Speaker   string    `json:"speaker"`
+	Text      string    `json:"text"`
+	StartMs   int64     `json:"startMs"`
+	EndMs     int64     `json:"endMs"`
+	At        time.Time `json:"at"` // Wall clock time of the start
+	Corrected bool      `json:"corrected,omitempty"`
+}
+
+// WriteJSON writes the transcript with per segment offsets in milliseconds
+func (t *Transcript) WriteJSON(w io.Writer) error {
+	segments := make([]jsonSegment, 0, len(t.Segments))
+	for i, s := range t.cues() {
+		segments = append(segments, jsonSegment{
+			Index:     i + 1,
+			Speaker:   s.Speaker,
+			Text:      s.Text,
+			StartMs:   s.Start.Milliseconds(),
+			EndMs:     s.End.Milliseconds(),
+			At:        t.StartedAt.Add(s.Start),
+			Corrected: s.Corrected,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		*Transcript
+		DurationMs int64         `json:"durationMs"`
+		Segments   []jsonSegment `json:"segments"`
+	}{t, t.Duration.Milliseconds(), segments})
+}
+
+// WriteSRT writes SubRip subtitles
+func (t *Transcript) WriteSRT(w io.Writer) error {
+	var b strings.Builder
+	for i, s := range t.cues() {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(s.Start, ","), formatTimestamp(s.End, ","), cueText(s))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteVTT writes WebVTT subtitles, speakers marked with voice spans
+func (t *Transcript) WriteVTT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, s := range t.cues() {
+		text := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s.Text)
+		if s.Speaker != "" {
+			text = fmt.Sprintf("<v %s>%s", speakerLabel(s.Speaker), text)
+		}
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(s.Start, "."), formatTimestamp(s.End, "."), text)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cues segments with text, ordered and with a usable end: a cue without an
+// end lasts until the next one starts, at least minCueDuration
+func (t *Transcript) cues() []Segment {
+	cues := make([]Segment, 0, len(t.Segments))
+	for _, s := range t.Segments {
+		s.Text = strings.TrimSpace(s.Text)
+		if s.Text == "" {
+			continue
+		}
+		if s.Start < 0 {
+			s.Start = 0
+		}
+		cues = append(cues, s)
+	}
+	for i := range cues {
+		if cues[i].End > cues[i].Start {
+			continue
+		}
+		end := cues[i].Start + minCueDuration
+		if i+1 < len(cues) && cues[i+1].Start > end {
+			end = cues[i+1].Start
+		}
+		cues[i].End = end
+	}
+	return cues
+}
+
+func cueText(s Segment) string {
+	if s.Speaker == "" {
+		return s.Text
+	}
+	return speakerLabel(s.Speaker) + ": " + s.Text
+}
+
+// speakerLabel display name of a speaker
+func speakerLabel(speaker string) string {
+	switch speaker {
+	case "user":
+		return "User"
+	case "assistant", "ai":
+		return "Assistant"
+	}
+	return speaker
+}
+
+// formatTimestamp HH:MM:SS with the milliseconds separator of the format
+func formatTimestamp(d time.Duration, sep string) string {
+	ms := d.Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
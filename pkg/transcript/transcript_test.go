@@ -0,0 +1,107 @@
+package transcript
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTranscript() *Transcript {
+	return &Transcript{
+		Title:     "Support call",
+		Source:    "call_recording",
+		SourceID:  "42",
+		StartedAt: time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC),
+		Duration:  75 * time.Second,
+		Summary:   "Customer asked about <billing> & refunds",
+		Metadata:  map[string]string{"Assistant": "Helpdesk"},
+		Segments: []Segment{
+			{Speaker: "user", Text: "Hello, I need help", Start: 1500 * time.Millisecond, End: 3200 * time.Millisecond},
+			{Speaker: "assistant", Text: "Sure, what is <wrong>?", Start: 4 * time.Second},
+			{Speaker: "user", Text: "   "},
+			{Speaker: "user", Text: "My bill", Start: 62*time.Second + 5*time.Millisecond, End: 63 * time.Second, Corrected: true},
+		},
+	}
+}
+
+func TestWriteSRT(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testTranscript().Render(&buf, FormatSRT))
+	expected := "1\n00:00:01,500 --> 00:00:03,200\nUser: Hello, I need help\n\n" +
+		"2\n00:00:04,000 --> 00:01:02,005\nAssistant: Sure, what is <wrong>?\n\n" +
+		"3\n00:01:02,005 --> 00:01:03,000\nUser: My bill\n\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteVTT(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testTranscript().Render(&buf, FormatVTT))
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "WEBVTT\n\n"))
+	assert.Contains(t, out, "1\n00:00:01.500 --> 00:00:03.200\n<v User>Hello, I need help\n")
+	assert.Contains(t, out, "<v Assistant>Sure, what is &lt;wrong&gt;?")
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testTranscript().Render(&buf, FormatJSON))
+	var out struct {
+		Title      string `json:"title"`
+		SourceID   string `json:"sourceId"`
+		DurationMs int64  `json:"durationMs"`
+		Segments   []struct {
+			Index     int       `json:"index"`
+			Speaker   string    `json:"speaker"`
+			StartMs   int64     `json:"startMs"`
+			EndMs     int64     `json:"endMs"`
+			At        time.Time `json:"at"`
+			Corrected bool      `json:"corrected"`
+		} `json:"segments"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, "Support call", out.Title)
+	assert.Equal(t, "42", out.SourceID)
+	assert.Equal(t, int64(75000), out.DurationMs)
+	require.Len(t, out.Segments, 3)
+	assert.Equal(t, int64(1500), out.Segments[0].StartMs)
+	assert.Equal(t, int64(62005), out.Segments[1].EndMs)
+	assert.True(t, out.Segments[0].At.Equal(time.Date(2026, 5, 1, 9, 0, 1, 500e6, time.UTC)))
+	assert.False(t, out.Segments[0].Corrected)
+	assert.True(t, out.Segments[2].Corrected)
+}
+
+func TestWriteDOCX(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testTranscript().Render(&buf, FormatDOCX))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	names := map[string]*zip.File{}
+	for _, f := range zr.File {
+		names[f.Name] = f
+	}
+	require.Contains(t, names, "[Content_Types].xml")
+	require.Contains(t, names, "_rels/.rels")
+	require.Contains(t, names, "word/document.xml")
+
+	rc, err := names["word/document.xml"].Open()
+	require.NoError(t, err)
+	doc, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Contains(t, string(doc), "Customer asked about &lt;billing&gt; &amp; refunds")
+	assert.Contains(t, string(doc), "[00:01:02] User: My bill (corrected)")
+	assert.Contains(t, string(doc), "Assistant: Helpdesk")
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	assert.ErrorIs(t, testTranscript().Render(io.Discard, "pdf"), ErrUnknownFormat)
+	assert.False(t, ValidFormat("pdf"))
+	assert.True(t, ValidFormat(FormatDOCX))
+}
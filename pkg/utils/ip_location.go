@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -38,6 +39,8 @@ type IPGeolocationResponse struct {
 	ISP         string  `json:"isp"`
 	Org         string  `json:"org"`
 	AS          string  `json:"as"`
+	Proxy       bool    `json:"proxy"`   // 代理、VPN 或 Tor 出口
+	Hosting     bool    `json:"hosting"` // 数据中心 IP
 	Query       string  `json:"query"`
 	Status      string  `json:"status"`
 	Message     string  `json:"message"`
@@ -227,6 +230,96 @@ func (ils *IPLocationService) getLocationFromIPAPI(ip string) (country, city, lo
 	return country, city, location, nil
 }
 
+// GetCoordinates 获取IP对应的经纬度（始终使用ip-api，pconline不返回坐标）
+func (ils *IPLocationService) GetCoordinates(ip string) (lat, lon float64, country, city string, err error) {
+	if IsInternalIP(ip) || ip == "127.0.0.1" || ip == "::1" || ip == "localhost" {
+		return 0, 0, "", "", fmt.Errorf("cannot geolocate internal ip %s", ip)
+	}
+
+	url := fmt.Sprintf("%s%s?fields=status,message,country,city,lat,lon,query", IP_API_URL, ip)
+	client := &http.Client{
+		Timeout: ils.timeout,
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, 0, "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, "", "", fmt.Errorf("ip geolocation api returned status %d", resp.StatusCode)
+	}
+
+	var geoResp IPGeolocationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geoResp); err != nil {
+		return 0, 0, "", "", err
+	}
+	if geoResp.Status == "fail" {
+		return 0, 0, "", "", fmt.Errorf("ip geolocation failed: %s", geoResp.Message)
+	}
+
+	return geoResp.Lat, geoResp.Lon, geoResp.Country, geoResp.City, nil
+}
+
+// IPRiskInfo 登录风险评分使用的 IP 信息
+type IPRiskInfo struct {
+	Latitude  float64
+	Longitude float64
+	Proxy     bool
+	Hosting   bool
+}
+
+// ipRiskCacheTTL IP 信息缓存时长，避免同一 IP 反复登录时重复查询
+const ipRiskCacheTTL = time.Hour
+
+type ipRiskCacheEntry struct {
+	info    IPRiskInfo
+	expires time.Time
+}
+
+var ipRiskCache sync.Map
+
+// LookupIPRisk 查询 IP 的经纬度及是否为代理或数据中心（使用ip-api），结果缓存一小时
+func (ils *IPLocationService) LookupIPRisk(ip string) (*IPRiskInfo, error) {
+	if IsInternalIP(ip) || ip == "127.0.0.1" || ip == "::1" || ip == "localhost" {
+		return nil, fmt.Errorf("cannot look up internal ip %s", ip)
+	}
+	if v, ok := ipRiskCache.Load(ip); ok {
+		entry := v.(ipRiskCacheEntry)
+		if time.Now().Before(entry.expires) {
+			info := entry.info
+			return &info, nil
+		}
+		ipRiskCache.Delete(ip)
+	}
+
+	url := fmt.Sprintf("%s%s?fields=status,message,lat,lon,proxy,hosting,query", IP_API_URL, ip)
+	client := &http.Client{
+		Timeout: ils.timeout,
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ip geolocation api returned status %d", resp.StatusCode)
+	}
+	var geoResp IPGeolocationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geoResp); err != nil {
+		return nil, err
+	}
+	if geoResp.Status == "fail" {
+		return nil, fmt.Errorf("ip geolocation failed: %s", geoResp.Message)
+	}
+
+	info := IPRiskInfo{Latitude: geoResp.Lat, Longitude: geoResp.Lon, Proxy: geoResp.Proxy, Hosting: geoResp.Hosting}
+	ipRiskCache.Store(ip, ipRiskCacheEntry{info: info, expires: time.Now().Add(ipRiskCacheTTL)})
+	return &info, nil
+}
+
 // GetRealAddressByIP 根据IP获取真实地址（兼容旧接口，返回完整地址字符串）
 func GetRealAddressByIP(ip string) string {
 	// 内网不查询
@@ -0,0 +1,363 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 登录异常评分模式
+const (
+	LoginAnomalyModeOff     = "off"     // 只使用规则评分
+	LoginAnomalyModeShadow  = "shadow"  // 调用模型并记录评分，判定仍使用规则，用于上线前评估
+	LoginAnomalyModeEnforce = "enforce" // 使用模型评分判定，模型不可用时回退到规则
+)
+
+// 登录异常评分来源
+const (
+	LoginAnomalySourceRules = "rules"
+	LoginAnomalySourceModel = "model"
+)
+
+const (
+	defaultLoginAnomalyTimeout   = 300 * time.Millisecond
+	defaultLoginAnomalyThreshold = 0.7
+	loginGeoJumpWindow           = 24 * time.Hour
+)
+
+// LoginAnomalyConfig 外部登录异常模型配置
+type LoginAnomalyConfig struct {
+	ModelURL  string        `env:"LOGIN_ANOMALY_MODEL_URL"`     // 模型服务地址，为空时只使用规则评分
+	APIKey    string        `env:"LOGIN_ANOMALY_MODEL_API_KEY"` // 以 Bearer 令牌发送
+	Mode      string        `env:"LOGIN_ANOMALY_MODE"`          // off、shadow、enforce，配置了模型时默认 shadow
+	Timeout   time.Duration `env:"LOGIN_ANOMALY_TIMEOUT"`       // 单次评分超时，默认 300ms
+	Threshold float64       `env:"LOGIN_ANOMALY_THRESHOLD"`     // 评分达到该值视为可疑，默认 0.7
+
+	ChallengeThreshold float64 `env:"LOGIN_RISK_CHALLENGE_THRESHOLD"` // 评分达到该值时要求第二因素，0 表示不要求，默认 0.7
+	BlockThreshold     float64 `env:"LOGIN_RISK_BLOCK_THRESHOLD"`     // 评分达到该值时拒绝登录，0 表示不拒绝，默认 0.9
+	RuleWeights        string  `env:"LOGIN_RISK_RULE_WEIGHTS"`        // 覆盖规则权重，如 impossible_travel=0.6,proxy=0.2
+	MaxTravelSpeed     float64 `env:"LOGIN_RISK_MAX_TRAVEL_SPEED"`    // 超过该速度（km/h）视为不可能的移动，默认 1000
+	IPBlocklist        string  `env:"LOGIN_RISK_IP_BLOCKLIST"`        // 信誉差的 IP 或 CIDR，逗号分隔
+}
+
+// LoginAttempt 一次登录尝试，用于计算特征
+type LoginAttempt struct {
+	At       time.Time
+	IP       string
+	DeviceID string
+	Country  string
+	City     string
+	Success  bool
+
+	Latitude  float64 // 坐标未知时为 0
+	Longitude float64
+	Proxy     bool // 代理、VPN 或 Tor 出口
+	Hosting   bool // 数据中心 IP
+}
+
+// hasCoordinates 是否有可用的定位坐标
+func (a LoginAttempt) hasCoordinates() bool {
+	return a.Latitude != 0 || a.Longitude != 0
+}
+
+// LoginFeatures 发送给模型的登录特征
+type LoginFeatures struct {
+	UserID              uint    `json:"userId"`
+	LoginsLastHour      int     `json:"loginsLastHour"`      // 最近 1 小时的登录尝试次数
+	LoginsLastDay       int     `json:"loginsLastDay"`       // 最近 24 小时的登录尝试次数
+	FailuresLastHour    int     `json:"failuresLastHour"`    // 最近 1 小时的失败次数
+	DistinctIPsLastDay  int     `json:"distinctIpsLastDay"`  // 最近 24 小时使用过的 IP 数（含本次）
+	DeviceEntropy       float64 `json:"deviceEntropy"`       // 成功登录设备分布的香农熵（bit，含本次）
+	NewDevice           bool    `json:"newDevice"`           // 设备从未成功登录过
+	NewCountry          bool    `json:"newCountry"`          // 国家从未成功登录过
+	GeoJump             bool    `json:"geoJump"`             // 24 小时内与上次成功登录的国家不同
+	HoursSinceLastLogin float64 `json:"hoursSinceLastLogin"` // 距上次成功登录的小时数，首次登录为 -1
+	HourOfDay           int     `json:"hourOfDay"`           // 登录时间（UTC 小时）
+	HistorySize         int     `json:"historySize"`         // 参与计算的历史登录数
+	TravelDistanceKm    float64 `json:"travelDistanceKm"`    // 与上次有坐标的成功登录之间的距离，未知为 -1
+	TravelSpeedKmh      float64 `json:"travelSpeedKmh"`      // 从上次登录位置到达所需的速度，未知为 -1
+	Proxy               bool    `json:"proxy"`               // 本次 IP 为代理、VPN 或 Tor 出口
+	Hosting             bool    `json:"hosting"`             // 本次 IP 属于数据中心
+	IPReputation        float64 `json:"ipReputation"`        // IP 信誉风险（0-1），越高越差
+	IPFailuresLastHour  int     `json:"ipFailuresLastHour"`  // 最近 1 小时该 IP 在所有账号上的失败次数
+	UnusualHour         bool    `json:"unusualHour"`         // 不在用户惯常的登录时段
+}
+
+// knownCountry 未能解析的位置不参与地理特征
+func knownCountry(country string) bool {
+	return country != "" && !strings.EqualFold(country, "unknown")
+}
+
+// BuildLoginFeatures 根据本次登录和历史登录计算特征，history 不含本次登录
+func BuildLoginFeatures(userID uint, current LoginAttempt, history []LoginAttempt) LoginFeatures {
+	f := LoginFeatures{
+		UserID:              userID,
+		HourOfDay:           current.At.UTC().Hour(),
+		HistorySize:         len(history),
+		HoursSinceLastLogin: -1,
+		NewDevice:           current.DeviceID != "",
+		NewCountry:          knownCountry(current.Country),
+		TravelDistanceKm:    -1,
+		TravelSpeedKmh:      -1,
+		Proxy:               current.Proxy,
+		Hosting:             current.Hosting,
+	}
+
+	ips := map[string]bool{current.IP: true}
+	devices := map[string]int{}
+	if current.DeviceID != "" {
+		devices[current.DeviceID]++
+	}
+	var last, lastLocated *LoginAttempt
+	for i := range history {
+		a := &history[i]
+		age := current.At.Sub(a.At)
+		if age < 0 {
+			continue
+		}
+		if age <= time.Hour {
+			f.LoginsLastHour++
+			if !a.Success {
+				f.FailuresLastHour++
+			}
+		}
+		if age <= 24*time.Hour {
+			f.LoginsLastDay++
+			ips[a.IP] = true
+		}
+		if !a.Success {
+			continue
+		}
+		if a.DeviceID != "" {
+			devices[a.DeviceID]++
+			if a.DeviceID == current.DeviceID {
+				f.NewDevice = false
+			}
+		}
+		if a.Country == current.Country {
+			f.NewCountry = false
+		}
+		if last == nil || a.At.After(last.At) {
+			last = a
+		}
+		if a.hasCoordinates() && (lastLocated == nil || a.At.After(lastLocated.At)) {
+			lastLocated = a
+		}
+	}
+	f.DistinctIPsLastDay = len(ips)
+	f.DeviceEntropy = shannonEntropy(devices)
+	f.UnusualHour = unusualHour(current.At, history)
+	if lastLocated != nil {
+		f.TravelDistanceKm, f.TravelSpeedKmh = travelFeatures(current, *lastLocated)
+	}
+
+	if last != nil {
+		f.HoursSinceLastLogin = current.At.Sub(last.At).Hours()
+		f.GeoJump = knownCountry(current.Country) && knownCountry(last.Country) &&
+			last.Country != current.Country && current.At.Sub(last.At) <= loginGeoJumpWindow
+	} else {
+		// 首次登录没有可比较的历史
+		f.NewDevice, f.NewCountry = false, false
+	}
+	return f
+}
+
+// shannonEntropy 计数分布的香农熵（bit）
+func shannonEntropy(counts map[string]int) float64 {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return math.Round(entropy*1000) / 1000
+}
+
+// RuleLoginScore 基于默认规则的登录异常评分（0-1），模型不可用时的回退
+func RuleLoginScore(f LoginFeatures) float64 {
+	score, _ := DefaultLoginRiskRules().Evaluate(f)
+	return score
+}
+
+// LoginModelScore 模型返回的评分
+type LoginModelScore struct {
+	Score        float64 `json:"score"`        // 0-1，越高越可疑
+	ModelVersion string  `json:"modelVersion"` // 产生该评分的模型版本
+}
+
+// LoginAnomalyModel 可插拔的登录异常模型
+type LoginAnomalyModel interface {
+	Score(ctx context.Context, features LoginFeatures) (*LoginModelScore, error)
+}
+
+// HTTPLoginAnomalyModel 通过 HTTP 调用外部模型服务：
+// POST {"features": {...}}，返回 {"score": 0.42, "modelVersion": "v3"}，
+// 响应体未带版本时读取 X-Model-Version 头
+type HTTPLoginAnomalyModel struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// NewHTTPLoginAnomalyModel 创建 HTTP 模型客户端，超时由调用方的 context 控制
+func NewHTTPLoginAnomalyModel(url, apiKey string) *HTTPLoginAnomalyModel {
+	return &HTTPLoginAnomalyModel{URL: url, APIKey: apiKey, Client: &http.Client{}}
+}
+
+// Score 请求模型服务为登录评分
+func (m *HTTPLoginAnomalyModel) Score(ctx context.Context, features LoginFeatures) (*LoginModelScore, error) {
+	body, err := json.Marshal(map[string]interface{}{"features": features})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.APIKey)
+	}
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("model server returned %d", resp.StatusCode)
+	}
+	var score LoginModelScore
+	if err := json.Unmarshal(data, &score); err != nil {
+		return nil, fmt.Errorf("decode model response: %w", err)
+	}
+	if math.IsNaN(score.Score) || score.Score < 0 || score.Score > 1 {
+		return nil, fmt.Errorf("model score %v out of range", score.Score)
+	}
+	if score.ModelVersion == "" {
+		score.ModelVersion = resp.Header.Get("X-Model-Version")
+	}
+	return &score, nil
+}
+
+// LoginAnomalyResult 一次登录的异常评分结果
+type LoginAnomalyResult struct {
+	Score        float64  `json:"score"`                  // 参与判定的评分
+	RuleScore    float64  `json:"ruleScore"`              // 规则评分，总是计算
+	ModelScore   *float64 `json:"modelScore,omitempty"`   // 模型评分，未调用或失败时为空
+	ModelVersion string   `json:"modelVersion,omitempty"` // 模型版本
+	Mode         string   `json:"mode"`                   // 评分时的模式
+	Source       string   `json:"source"`                 // 判定评分的来源：rules 或 model
+	Fallback     string   `json:"fallback,omitempty"`     // 模型失败回退到规则的原因
+	Suspicious   bool     `json:"suspicious"`             // 评分达到阈值
+	Reasons      []string `json:"reasons,omitempty"`      // 命中的风险规则
+	Decision     string   `json:"decision"`               // allow、challenge 或 block
+	Latitude     float64  `json:"latitude,omitempty"`     // 本次登录的定位坐标，记入登录历史供下次计算移动速度
+	Longitude    float64  `json:"longitude,omitempty"`
+}
+
+// ConfigureLoginAnomaly 按配置接入外部模型，未配置地址时保持只用规则
+func (lsm *LoginSecurityManager) ConfigureLoginAnomaly(cfg LoginAnomalyConfig) {
+	if cfg.Timeout > 0 {
+		lsm.anomalyTimeout = cfg.Timeout
+	}
+	if cfg.Threshold > 0 && cfg.Threshold <= 1 {
+		lsm.anomalyThreshold = cfg.Threshold
+	}
+	lsm.configureLoginRisk(cfg)
+	if cfg.ModelURL == "" {
+		return
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = LoginAnomalyModeShadow
+	}
+	lsm.SetLoginAnomalyModel(NewHTTPLoginAnomalyModel(cfg.ModelURL, cfg.APIKey), mode)
+}
+
+// SetLoginAnomalyModel 替换登录异常模型和模式，model 为空时只使用规则
+func (lsm *LoginSecurityManager) SetLoginAnomalyModel(model LoginAnomalyModel, mode string) {
+	switch mode {
+	case LoginAnomalyModeShadow, LoginAnomalyModeEnforce:
+	default:
+		mode = LoginAnomalyModeOff
+	}
+	if model == nil {
+		mode = LoginAnomalyModeOff
+	}
+	lsm.anomalyMu.Lock()
+	lsm.anomalyModel, lsm.anomalyMode = model, mode
+	lsm.anomalyMu.Unlock()
+	lsm.logger.Info("Login anomaly model configured",
+		zap.String("mode", mode),
+		zap.Float64("threshold", lsm.anomalyThreshold),
+		zap.Duration("timeout", lsm.anomalyTimeout))
+}
+
+// LoginAnomalySettings 当前的评分模式和阈值
+func (lsm *LoginSecurityManager) LoginAnomalySettings() (mode string, threshold float64) {
+	lsm.anomalyMu.RLock()
+	defer lsm.anomalyMu.RUnlock()
+	return lsm.anomalyMode, lsm.anomalyThreshold
+}
+
+// ScoreLoginAnomaly 为登录评分。规则评分总是计算；shadow 模式下模型评分只记录不判定，
+// enforce 模式下以模型评分判定，模型超时或出错时回退到规则评分
+func (lsm *LoginSecurityManager) ScoreLoginAnomaly(ctx context.Context, features LoginFeatures) *LoginAnomalyResult {
+	lsm.anomalyMu.RLock()
+	model, mode, threshold, timeout := lsm.anomalyModel, lsm.anomalyMode, lsm.anomalyThreshold, lsm.anomalyTimeout
+	rules, challengeThreshold, blockThreshold := lsm.riskRules, lsm.challengeThreshold, lsm.blockThreshold
+	lsm.anomalyMu.RUnlock()
+
+	result := &LoginAnomalyResult{
+		Mode:   mode,
+		Source: LoginAnomalySourceRules,
+	}
+	result.RuleScore, result.Reasons = rules.Evaluate(features)
+	result.Score = result.RuleScore
+
+	if model != nil && mode != LoginAnomalyModeOff {
+		scoreCtx, cancel := context.WithTimeout(ctx, timeout)
+		score, err := model.Score(scoreCtx, features)
+		cancel()
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			result.Fallback = "timeout"
+		case err != nil:
+			result.Fallback = err.Error()
+		default:
+			result.ModelScore = &score.Score
+			result.ModelVersion = score.ModelVersion
+			if mode == LoginAnomalyModeEnforce {
+				result.Score, result.Source = score.Score, LoginAnomalySourceModel
+			}
+		}
+		if result.Fallback != "" {
+			lsm.logger.Warn("Login anomaly model unavailable, using rule score",
+				zap.Uint("userID", features.UserID),
+				zap.String("mode", mode),
+				zap.String("reason", result.Fallback))
+		}
+	}
+	result.Suspicious = result.Score >= threshold
+	result.Decision = LoginRiskDecision(result.Score, challengeThreshold, blockThreshold)
+	return result
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+type stubLoginModel struct {
+	score *LoginModelScore
+	err   error
+	delay time.Duration
+}
+
+func (m *stubLoginModel) Score(ctx context.Context, _ LoginFeatures) (*LoginModelScore, error) {
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return m.score, m.err
+}
+
+func TestBuildLoginFeatures(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	history := []LoginAttempt{
+		{At: now.Add(-2 * time.Hour), IP: "10.0.0.1", DeviceID: "laptop", Country: "中国", Success: true},
+		{At: now.Add(-3 * 24 * time.Hour), IP: "10.0.0.2", DeviceID: "phone", Country: "中国", Success: true},
+		{At: now.Add(-30 * time.Minute), IP: "10.0.0.9", DeviceID: "tablet", Country: "美国", Success: false},
+		{At: now.Add(-20 * time.Minute), IP: "10.0.0.9", DeviceID: "tablet", Country: "美国", Success: false},
+	}
+
+	f := BuildLoginFeatures(7, LoginAttempt{At: now, IP: "203.0.113.5", DeviceID: "tablet", Country: "美国"}, history)
+	if f.LoginsLastHour != 2 || f.FailuresLastHour != 2 || f.LoginsLastDay != 3 {
+		t.Fatalf("unexpected velocity %+v", f)
+	}
+	if f.DistinctIPsLastDay != 3 {
+		t.Fatalf("expected 3 distinct IPs, got %d", f.DistinctIPsLastDay)
+	}
+	if !f.NewDevice || !f.NewCountry || !f.GeoJump {
+		t.Fatalf("expected new device, new country and geo jump: %+v", f)
+	}
+	if f.HoursSinceLastLogin != 2 {
+		t.Fatalf("expected 2 hours since last login, got %v", f.HoursSinceLastLogin)
+	}
+	if f.DeviceEntropy != 1.585 {
+		t.Fatalf("expected entropy of three equally used devices, got %v", f.DeviceEntropy)
+	}
+
+	f = BuildLoginFeatures(7, LoginAttempt{At: now, IP: "10.0.0.1", DeviceID: "laptop", Country: "中国"}, history)
+	if f.NewDevice || f.NewCountry || f.GeoJump {
+		t.Fatalf("known device and country flagged: %+v", f)
+	}
+
+	f = BuildLoginFeatures(7, LoginAttempt{At: now, IP: "10.0.0.1", DeviceID: "laptop", Country: "中国"}, nil)
+	if f.NewDevice || f.NewCountry || f.HoursSinceLastLogin != -1 {
+		t.Fatalf("first login flagged: %+v", f)
+	}
+}
+
+func TestRuleLoginScore(t *testing.T) {
+	if score := RuleLoginScore(LoginFeatures{}); score != 0 {
+		t.Fatalf("expected 0 for a normal login, got %v", score)
+	}
+	score := RuleLoginScore(LoginFeatures{GeoJump: true, NewCountry: true, NewDevice: true, FailuresLastHour: 5})
+	if score < 0.79 || score > 0.81 {
+		t.Fatalf("expected 0.8, got %v", score)
+	}
+	if score := RuleLoginScore(LoginFeatures{GeoJump: true, NewDevice: true, FailuresLastHour: 5, LoginsLastHour: 20, DistinctIPsLastDay: 9, DeviceEntropy: 3}); score != 1 {
+		t.Fatalf("expected score capped at 1, got %v", score)
+	}
+}
+
+func TestScoreLoginAnomaly(t *testing.T) {
+	lsm := NewLoginSecurityManager(zaptest.NewLogger(t))
+	risky := LoginFeatures{GeoJump: true, NewDevice: true, FailuresLastHour: 3}
+
+	result := lsm.ScoreLoginAnomaly(context.Background(), risky)
+	if result.Source != LoginAnomalySourceRules || !result.Suspicious || result.ModelScore != nil {
+		t.Fatalf("expected rule-based suspicious result, got %+v", result)
+	}
+
+	model := &stubLoginModel{score: &LoginModelScore{Score: 0.1, ModelVersion: "v2"}}
+	lsm.SetLoginAnomalyModel(model, LoginAnomalyModeShadow)
+	result = lsm.ScoreLoginAnomaly(context.Background(), risky)
+	if result.Source != LoginAnomalySourceRules || !result.Suspicious {
+		t.Fatalf("shadow mode must decide with rules, got %+v", result)
+	}
+	if result.ModelScore == nil || *result.ModelScore != 0.1 || result.ModelVersion != "v2" {
+		t.Fatalf("shadow mode must record the model score, got %+v", result)
+	}
+
+	lsm.SetLoginAnomalyModel(model, LoginAnomalyModeEnforce)
+	result = lsm.ScoreLoginAnomaly(context.Background(), risky)
+	if result.Source != LoginAnomalySourceModel || result.Suspicious || result.Score != 0.1 {
+		t.Fatalf("enforce mode must decide with the model, got %+v", result)
+	}
+
+	lsm.anomalyTimeout = 10 * time.Millisecond
+	model.delay = time.Second
+	result = lsm.ScoreLoginAnomaly(context.Background(), risky)
+	if result.Source != LoginAnomalySourceRules || result.Fallback != "timeout" || !result.Suspicious {
+		t.Fatalf("expected rule fallback on timeout, got %+v", result)
+	}
+
+	model.delay, model.err = 0, errors.New("unavailable")
+	result = lsm.ScoreLoginAnomaly(context.Background(), risky)
+	if result.Source != LoginAnomalySourceRules || result.Fallback != "unavailable" {
+		t.Fatalf("expected rule fallback on error, got %+v", result)
+	}
+
+	lsm.SetLoginAnomalyModel(nil, LoginAnomalyModeEnforce)
+	if mode, _ := lsm.LoginAnomalySettings(); mode != LoginAnomalyModeOff {
+		t.Fatalf("expected off without a model, got %s", mode)
+	}
+}
+
+func TestHTTPLoginAnomalyModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Features LoginFeatures `json:"features"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Model-Version", "2026.03")
+		if body.Features.UserID == 42 {
+			w.Write([]byte(`{"score": 1.5}`))
+			return
+		}
+		w.Write([]byte(`{"score": 0.25}`))
+	}))
+	defer server.Close()
+
+	model := NewHTTPLoginAnomalyModel(server.URL, "secret")
+	score, err := model.Score(context.Background(), LoginFeatures{UserID: 1})
+	if err != nil {
+		t.Fatalf("Score failed: %v", err)
+	}
+	if score.Score != 0.25 || score.ModelVersion != "2026.03" {
+		t.Fatalf("unexpected score %+v", score)
+	}
+	if _, err := model.Score(context.Background(), LoginFeatures{UserID: 42}); err == nil {
+		t.Fatal("expected an error for an out of range score")
+	}
+	if _, err := NewHTTPLoginAnomalyModel(server.URL, "wrong").Score(context.Background(), LoginFeatures{}); err == nil {
+		t.Fatal("expected an error for a non-2xx response")
+	}
+}
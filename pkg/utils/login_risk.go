@@ -0,0 +1,370 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 登录风险规则名称，可通过 LOGIN_RISK_RULE_WEIGHTS 调整权重
+const (
+	LoginRiskRuleGeoJump          = "geo_jump"          // 24 小时内换了国家
+	LoginRiskRuleNewCountry       = "new_country"       // 从未在该国家登录过（已计入 geo_jump 时不重复计算）
+	LoginRiskRuleNewDevice        = "new_device"        // 新设备
+	LoginRiskRuleFailureBurst     = "failure_burst"     // 最近 1 小时该账号失败 3 次以上
+	LoginRiskRuleLoginBurst       = "login_burst"       // 最近 1 小时该账号登录尝试 10 次以上
+	LoginRiskRuleManyIPs          = "many_ips"          // 最近 24 小时使用了 5 个以上 IP
+	LoginRiskRuleDeviceSpread     = "device_spread"     // 设备分布过于分散
+	LoginRiskRuleImpossibleTravel = "impossible_travel" // 与上次登录的距离无法在间隔时间内到达
+	LoginRiskRuleProxy            = "proxy"             // 代理、VPN 或 Tor 出口
+	LoginRiskRuleHosting          = "hosting"           // 数据中心 IP
+	LoginRiskRuleIPReputation     = "ip_reputation"     // IP 信誉差，按信誉风险比例计分
+	LoginRiskRuleIPFailures       = "ip_failures"       // 最近 1 小时该 IP 在各账号上失败 10 次以上
+	LoginRiskRuleUnusualHour      = "unusual_hour"      // 不在用户惯常的登录时段
+)
+
+// 登录风险判定
+const (
+	LoginRiskAllow     = "allow"     // 放行
+	LoginRiskChallenge = "challenge" // 需要第二因素
+	LoginRiskBlock     = "block"     // 拒绝登录
+)
+
+const (
+	defaultMaxTravelSpeedKmh      = 1000.0 // 超过民航飞机的速度
+	defaultLoginChallengeScore    = 0.7
+	defaultLoginBlockScore        = 0.9
+	impossibleTravelMinDistanceKm = 300.0 // 小于该距离时 IP 定位误差太大，不判断
+	loginIPFailureWindow          = time.Hour
+	loginIPFailureBurst           = 10
+	unusualHourMinHistory         = 10   // 成功登录少于该次数时不判断登录时段
+	unusualHourMaxShare           = 0.05 // 前后 1 小时内的登录占比低于该值视为异常时段
+)
+
+// defaultLoginRiskWeights 各规则的默认权重，总分封顶为 1
+var defaultLoginRiskWeights = map[string]float64{
+	LoginRiskRuleGeoJump:          0.4,
+	LoginRiskRuleNewCountry:       0.25,
+	LoginRiskRuleNewDevice:        0.2,
+	LoginRiskRuleFailureBurst:     0.2,
+	LoginRiskRuleLoginBurst:       0.2,
+	LoginRiskRuleManyIPs:          0.1,
+	LoginRiskRuleDeviceSpread:     0.1,
+	LoginRiskRuleImpossibleTravel: 0.5,
+	LoginRiskRuleProxy:            0.3,
+	LoginRiskRuleHosting:          0.2,
+	LoginRiskRuleIPReputation:     0.5,
+	LoginRiskRuleIPFailures:       0.3,
+	LoginRiskRuleUnusualHour:      0.15,
+}
+
+// LoginRiskRules 规则评分的权重和参数
+type LoginRiskRules struct {
+	Weights           map[string]float64
+	MaxTravelSpeedKmh float64 // 超过该速度视为不可能的移动
+}
+
+// DefaultLoginRiskRules 默认规则
+func DefaultLoginRiskRules() LoginRiskRules {
+	weights := make(map[string]float64, len(defaultLoginRiskWeights))
+	for name, w := range defaultLoginRiskWeights {
+		weights[name] = w
+	}
+	return LoginRiskRules{Weights: weights, MaxTravelSpeedKmh: defaultMaxTravelSpeedKmh}
+}
+
+// ParseLoginRiskWeights 解析 "impossible_travel=0.6,proxy=0.2" 格式的权重，权重为 0 表示停用该规则
+func ParseLoginRiskWeights(s string) (map[string]float64, error) {
+	weights := map[string]float64{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid rule weight %q, expected name=weight", item)
+		}
+		if _, known := defaultLoginRiskWeights[name]; !known {
+			return nil, fmt.Errorf("unknown login risk rule %q", name)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || w < 0 || w > 1 {
+			return nil, fmt.Errorf("invalid weight %q for rule %s, expected 0-1", value, name)
+		}
+		weights[name] = w
+	}
+	return weights, nil
+}
+
+// Evaluate 按规则为登录评分（0-1），返回命中的规则名称
+func (r LoginRiskRules) Evaluate(f LoginFeatures) (float64, []string) {
+	maxSpeed := r.MaxTravelSpeedKmh
+	if maxSpeed <= 0 {
+		maxSpeed = defaultMaxTravelSpeedKmh
+	}
+	hits := map[string]float64{
+		LoginRiskRuleNewDevice:        boolWeight(f.NewDevice),
+		LoginRiskRuleFailureBurst:     boolWeight(f.FailuresLastHour >= 3),
+		LoginRiskRuleLoginBurst:       boolWeight(f.LoginsLastHour >= 10),
+		LoginRiskRuleManyIPs:          boolWeight(f.DistinctIPsLastDay >= 5),
+		LoginRiskRuleDeviceSpread:     boolWeight(f.DeviceEntropy >= 2.5),
+		LoginRiskRuleImpossibleTravel: boolWeight(f.TravelDistanceKm >= impossibleTravelMinDistanceKm && f.TravelSpeedKmh > maxSpeed),
+		LoginRiskRuleProxy:            boolWeight(f.Proxy),
+		LoginRiskRuleHosting:          boolWeight(f.Hosting),
+		LoginRiskRuleIPReputation:     math.Max(0, math.Min(f.IPReputation, 1)),
+		LoginRiskRuleIPFailures:       boolWeight(f.IPFailuresLastHour >= loginIPFailureBurst),
+		LoginRiskRuleUnusualHour:      boolWeight(f.UnusualHour),
+	}
+	if f.GeoJump {
+		hits[LoginRiskRuleGeoJump] = 1
+	} else if f.NewCountry {
+		hits[LoginRiskRuleNewCountry] = 1
+	}
+
+	// 按名称顺序累加，保证同样的特征得到完全相同的评分
+	names := make([]string, 0, len(hits))
+	for name := range hits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	score := 0.0
+	var reasons []string
+	for _, name := range names {
+		weight, ok := r.Weights[name]
+		if !ok {
+			weight = defaultLoginRiskWeights[name]
+		}
+		if hits[name] <= 0 || weight <= 0 {
+			continue
+		}
+		score += weight * hits[name]
+		reasons = append(reasons, name)
+	}
+	return math.Min(score, 1.0), reasons
+}
+
+func boolWeight(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// LoginRiskDecision 按阈值判定，阈值为 0 表示不启用该判定
+func LoginRiskDecision(score, challengeThreshold, blockThreshold float64) string {
+	switch {
+	case blockThreshold > 0 && score >= blockThreshold:
+		return LoginRiskBlock
+	case challengeThreshold > 0 && score >= challengeThreshold:
+		return LoginRiskChallenge
+	default:
+		return LoginRiskAllow
+	}
+}
+
+// travelFeatures 与上次成功登录之间的距离（km）和所需速度（km/h），坐标未知时为 -1
+func travelFeatures(current, last LoginAttempt) (distanceKm, speedKmh float64) {
+	if !current.hasCoordinates() || !last.hasCoordinates() {
+		return -1, -1
+	}
+	distanceKm = GetDistance(last.Longitude, last.Latitude, current.Longitude, current.Latitude) / 1000
+	hours := current.At.Sub(last.At).Hours()
+	if hours < 1.0/60 {
+		hours = 1.0 / 60
+	}
+	return math.Round(distanceKm), math.Round(distanceKm / hours)
+}
+
+// unusualHour 成功登录足够多时，判断当前时段（前后 1 小时）是否很少登录
+func unusualHour(current time.Time, history []LoginAttempt) bool {
+	hour := current.UTC().Hour()
+	total, near := 0, 0
+	for _, a := range history {
+		if !a.Success {
+			continue
+		}
+		total++
+		d := a.At.UTC().Hour() - hour
+		if d < 0 {
+			d = -d
+		}
+		if d > 12 {
+			d = 24 - d
+		}
+		if d <= 1 {
+			near++
+		}
+	}
+	return total >= unusualHourMinHistory && float64(near)/float64(total) < unusualHourMaxShare
+}
+
+// IPReputationProvider IP 信誉来源，返回 0-1 的风险值，越高越差
+type IPReputationProvider interface {
+	Reputation(ctx context.Context, ip string) (float64, error)
+}
+
+// IPBlocklist 按 IP 或 CIDR 列表判断信誉，命中时风险为 1
+type IPBlocklist struct {
+	nets []*net.IPNet
+}
+
+// ParseIPBlocklist 解析逗号分隔的 IP 或 CIDR
+func ParseIPBlocklist(s string) (*IPBlocklist, error) {
+	list := &IPBlocklist{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", item)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			item = fmt.Sprintf("%s/%d", item, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", item)
+		}
+		list.nets = append(list.nets, ipNet)
+	}
+	return list, nil
+}
+
+// Reputation 命中列表返回 1，否则返回 0
+func (l *IPBlocklist) Reputation(_ context.Context, ip string) (float64, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return 0, nil
+	}
+	for _, n := range l.nets {
+		if n.Contains(parsed) {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// ipFailureTracker 统计各 IP 在所有账号上的登录失败
+type ipFailureTracker struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+func (t *ipFailureTracker) record(ip string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures == nil {
+		t.failures = make(map[string][]time.Time)
+	}
+	t.failures[ip] = append(t.prune(ip, now), now)
+}
+
+func (t *ipFailureTracker) count(ip string, now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.prune(ip, now))
+}
+
+// prune 丢弃窗口外的记录，调用方持有锁
+func (t *ipFailureTracker) prune(ip string, now time.Time) []time.Time {
+	history := t.failures[ip]
+	i := 0
+	for i < len(history) && now.Sub(history[i]) > loginIPFailureWindow {
+		i++
+	}
+	history = history[i:]
+	if len(history) == 0 {
+		delete(t.failures, ip)
+		return nil
+	}
+	t.failures[ip] = history
+	return history
+}
+
+// EnrichLoginFeatures 补充依赖全局状态的 IP 特征：跨账号失败次数和 IP 信誉
+func (lsm *LoginSecurityManager) EnrichLoginFeatures(ctx context.Context, f *LoginFeatures, ip string) {
+	f.IPFailuresLastHour = lsm.ipFailures.count(ip, time.Now())
+
+	lsm.anomalyMu.RLock()
+	reputation := lsm.ipReputation
+	lsm.anomalyMu.RUnlock()
+	if reputation == nil || ip == "" {
+		return
+	}
+	score, err := reputation.Reputation(ctx, ip)
+	if err != nil {
+		lsm.logger.Warn("IP reputation lookup failed", zap.String("ip", ip), zap.Error(err))
+		return
+	}
+	f.IPReputation = score
+}
+
+// SetIPReputation 替换 IP 信誉来源，为空时不检查
+func (lsm *LoginSecurityManager) SetIPReputation(provider IPReputationProvider) {
+	lsm.anomalyMu.Lock()
+	lsm.ipReputation = provider
+	lsm.anomalyMu.Unlock()
+}
+
+// SetLoginRiskPolicy 替换评分规则和判定阈值，阈值为 0 表示不启用
+func (lsm *LoginSecurityManager) SetLoginRiskPolicy(rules LoginRiskRules, challengeThreshold, blockThreshold float64) {
+	lsm.anomalyMu.Lock()
+	lsm.riskRules = rules
+	lsm.challengeThreshold, lsm.blockThreshold = challengeThreshold, blockThreshold
+	lsm.anomalyMu.Unlock()
+}
+
+// configureLoginRisk 按配置设置规则权重、判定阈值和 IP 黑名单，配置有误的项保持默认
+func (lsm *LoginSecurityManager) configureLoginRisk(cfg LoginAnomalyConfig) {
+	rules := DefaultLoginRiskRules()
+	if cfg.MaxTravelSpeed > 0 {
+		rules.MaxTravelSpeedKmh = cfg.MaxTravelSpeed
+	}
+	if cfg.RuleWeights != "" {
+		weights, err := ParseLoginRiskWeights(cfg.RuleWeights)
+		if err != nil {
+			lsm.logger.Warn("Invalid login risk rule weights, using defaults", zap.Error(err))
+		}
+		for name, w := range weights {
+			rules.Weights[name] = w
+		}
+	}
+	challenge, block := lsm.challengeThreshold, lsm.blockThreshold
+	if cfg.ChallengeThreshold >= 0 && cfg.ChallengeThreshold <= 1 {
+		challenge = cfg.ChallengeThreshold
+	}
+	if cfg.BlockThreshold >= 0 && cfg.BlockThreshold <= 1 {
+		block = cfg.BlockThreshold
+	}
+	lsm.SetLoginRiskPolicy(rules, challenge, block)
+
+	if cfg.IPBlocklist != "" {
+		blocklist, err := ParseIPBlocklist(cfg.IPBlocklist)
+		if err != nil {
+			lsm.logger.Warn("Invalid login risk IP blocklist, ignoring it", zap.Error(err))
+			return
+		}
+		lsm.SetIPReputation(blocklist)
+	}
+	lsm.logger.Info("Login risk policy configured",
+		zap.Float64("challengeThreshold", challenge),
+		zap.Float64("blockThreshold", block),
+		zap.Float64("maxTravelSpeedKmh", rules.MaxTravelSpeedKmh))
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBuildLoginFeatures_Travel(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	// 北京 -> 纽约，两小时内
+	history := []LoginAttempt{
+		{At: now.Add(-2 * time.Hour), IP: "10.0.0.1", Country: "中国", Latitude: 39.9, Longitude: 116.4, Success: true},
+		{At: now.Add(-time.Hour), IP: "10.0.0.2", Country: "中国", Success: true},
+	}
+	f := BuildLoginFeatures(7, LoginAttempt{At: now, IP: "203.0.113.5", Country: "美国", Latitude: 40.7, Longitude: -74.0, Proxy: true}, history)
+	if f.TravelDistanceKm < 10000 || f.TravelSpeedKmh < 5000 {
+		t.Fatalf("expected an impossible journey, got %vkm at %vkm/h", f.TravelDistanceKm, f.TravelSpeedKmh)
+	}
+	if !f.Proxy {
+		t.Fatal("proxy flag not copied from the attempt")
+	}
+
+	f = BuildLoginFeatures(7, LoginAttempt{At: now, IP: "203.0.113.5", Country: "美国"}, history)
+	if f.TravelDistanceKm != -1 || f.TravelSpeedKmh != -1 {
+		t.Fatalf("travel should be unknown without coordinates, got %v %v", f.TravelDistanceKm, f.TravelSpeedKmh)
+	}
+}
+
+func TestBuildLoginFeatures_UnusualHour(t *testing.T) {
+	now := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	var history []LoginAttempt
+	for i := 1; i <= 12; i++ {
+		history = append(history, LoginAttempt{At: time.Date(2026, 2, i, 14, 0, 0, 0, time.UTC), Success: true})
+	}
+	if f := BuildLoginFeatures(7, LoginAttempt{At: now}, history); !f.UnusualHour {
+		t.Fatal("3am login of a user who always logs in at 2pm should be unusual")
+	}
+	if f := BuildLoginFeatures(7, LoginAttempt{At: now.Add(10 * time.Hour)}, history); f.UnusualHour {
+		t.Fatal("1pm login should be within the usual hours")
+	}
+	if f := BuildLoginFeatures(7, LoginAttempt{At: now}, history[:5]); f.UnusualHour {
+		t.Fatal("short history should not flag the hour")
+	}
+}
+
+func TestLoginRiskRules_Evaluate(t *testing.T) {
+	rules := DefaultLoginRiskRules()
+	score, reasons := rules.Evaluate(LoginFeatures{TravelDistanceKm: 8000, TravelSpeedKmh: 4000, Proxy: true})
+	if math.Abs(score-0.8) > 1e-9 {
+		t.Fatalf("expected 0.8, got %v", score)
+	}
+	if !reflect.DeepEqual(reasons, []string{LoginRiskRuleImpossibleTravel, LoginRiskRuleProxy}) {
+		t.Fatalf("unexpected reasons %v", reasons)
+	}
+
+	// 短距离内的定位误差不算不可能的移动
+	if score, _ := rules.Evaluate(LoginFeatures{TravelDistanceKm: 100, TravelSpeedKmh: 6000}); score != 0 {
+		t.Fatalf("expected nearby jump to be ignored, got %v", score)
+	}
+
+	weights, err := ParseLoginRiskWeights("proxy=0, impossible_travel=0.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, w := range weights {
+		rules.Weights[name] = w
+	}
+	score, reasons = rules.Evaluate(LoginFeatures{TravelDistanceKm: 8000, TravelSpeedKmh: 4000, Proxy: true})
+	if math.Abs(score-0.9) > 1e-9 || len(reasons) != 1 {
+		t.Fatalf("expected weights to apply, got %v %v", score, reasons)
+	}
+	if score, _ := rules.Evaluate(LoginFeatures{IPReputation: 0.5}); score != 0.25 {
+		t.Fatalf("expected reputation to scale its weight, got %v", score)
+	}
+
+	for _, bad := range []string{"proxy", "vpn=0.3", "proxy=2"} {
+		if _, err := ParseLoginRiskWeights(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestLoginRiskDecision(t *testing.T) {
+	cases := []struct {
+		score            float64
+		challenge, block float64
+		want             string
+	}{
+		{0.5, 0.7, 0.9, LoginRiskAllow},
+		{0.7, 0.7, 0.9, LoginRiskChallenge},
+		{0.95, 0.7, 0.9, LoginRiskBlock},
+		{0.95, 0.7, 0, LoginRiskChallenge},
+		{0.95, 0, 0, LoginRiskAllow},
+	}
+	for _, c := range cases {
+		if got := LoginRiskDecision(c.score, c.challenge, c.block); got != c.want {
+			t.Errorf("LoginRiskDecision(%v, %v, %v) = %s, want %s", c.score, c.challenge, c.block, got, c.want)
+		}
+	}
+}
+
+func TestIPBlocklist(t *testing.T) {
+	list, err := ParseIPBlocklist("203.0.113.0/24, 198.51.100.7,2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]float64{"203.0.113.9": 1, "198.51.100.7": 1, "198.51.100.8": 0, "2001:db8::1": 1, "bad": 0} {
+		if got, _ := list.Reputation(context.Background(), ip); got != want {
+			t.Errorf("Reputation(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if _, err := ParseIPBlocklist("203.0.113.0/99"); err == nil {
+		t.Fatal("expected invalid CIDR to be rejected")
+	}
+}
+
+func TestScoreLoginAnomaly_Decision(t *testing.T) {
+	lsm := NewLoginSecurityManager(zaptest.NewLogger(t))
+	lsm.ConfigureLoginAnomaly(LoginAnomalyConfig{ChallengeThreshold: 0.3, BlockThreshold: 0.9, IPBlocklist: "203.0.113.0/24"})
+
+	for i := 0; i < loginIPFailureBurst; i++ {
+		lsm.ipFailures.record("198.51.100.7", time.Now())
+	}
+	f := LoginFeatures{UserID: 7}
+	lsm.EnrichLoginFeatures(context.Background(), &f, "198.51.100.7")
+	if f.IPFailuresLastHour != loginIPFailureBurst {
+		t.Fatalf("expected %d failures from the IP, got %d", loginIPFailureBurst, f.IPFailuresLastHour)
+	}
+	result := lsm.ScoreLoginAnomaly(context.Background(), f)
+	if result.Decision != LoginRiskChallenge || !reflect.DeepEqual(result.Reasons, []string{LoginRiskRuleIPFailures}) {
+		t.Fatalf("expected challenge for a brute-forcing IP, got %+v", result)
+	}
+
+	f = LoginFeatures{UserID: 7, GeoJump: true, NewDevice: true}
+	lsm.EnrichLoginFeatures(context.Background(), &f, "203.0.113.5")
+	if result := lsm.ScoreLoginAnomaly(context.Background(), f); result.Decision != LoginRiskBlock {
+		t.Fatalf("expected blocklisted IP to be blocked, got %+v", result)
+	}
+
+	if result := lsm.ScoreLoginAnomaly(context.Background(), LoginFeatures{UserID: 7}); result.Decision != LoginRiskAllow {
+		t.Fatalf("expected clean login to be allowed, got %+v", result)
+	}
+}
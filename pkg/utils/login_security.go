@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -19,6 +20,20 @@ type LoginSecurityManager struct {
 	maxPasswordLogins    int           // 密码登录最大次数（默认25次）
 	ipRateLimitPerMinute int           // IP每分钟登录次数限制
 	logger               *zap.Logger
+
+	// 登录异常模型，未配置时只使用规则评分
+	anomalyMu        sync.RWMutex
+	anomalyModel     LoginAnomalyModel
+	anomalyMode      string
+	anomalyTimeout   time.Duration
+	anomalyThreshold float64
+
+	// 登录风险规则和判定阈值
+	riskRules          LoginRiskRules
+	challengeThreshold float64
+	blockThreshold     float64
+	ipReputation       IPReputationProvider
+	ipFailures         ipFailureTracker
 }
 
 // NewLoginSecurityManager 创建登录安全管理器
@@ -29,6 +44,12 @@ func NewLoginSecurityManager(logger *zap.Logger) *LoginSecurityManager {
 		maxPasswordLogins:    25,
 		ipRateLimitPerMinute: 7, // 每个IP每分钟最多7次登录尝试
 		logger:               logger,
+		anomalyMode:          LoginAnomalyModeOff,
+		anomalyTimeout:       defaultLoginAnomalyTimeout,
+		anomalyThreshold:     defaultLoginAnomalyThreshold,
+		riskRules:            DefaultLoginRiskRules(),
+		challengeThreshold:   defaultLoginChallengeScore,
+		blockThreshold:       defaultLoginBlockScore,
 	}
 }
 
@@ -68,7 +89,7 @@ func (lsm *LoginSecurityManager) CheckAccountLock(db *gorm.DB, email string, use
 			zap.Time("unlockAt", lockInfo.UnlockAt),
 			zap.Duration("remainingTime", remainingTime))
 
-		return fmt.Errorf("account is locked due to too many failed login attempts. Please try again after %d minutes", int(remainingTime.Minutes())+1)
+		return fmt.Errorf("account is locked due to too many failed login attempts. Please try again after %d minutes or request an unlock email", int(remainingTime.Minutes())+1)
 	}
 
 	return nil
@@ -91,6 +112,9 @@ func (lsm *LoginSecurityManager) RecordFailedLogin(db *gorm.DB, email string, us
 	}
 
 	failedCount++
+	if ipAddress != "" {
+		lsm.ipFailures.record(ipAddress, time.Now())
+	}
 	if GlobalCache != nil {
 		GlobalCache.Add(key, failedCount)
 	}
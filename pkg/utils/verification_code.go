@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 验证码用途，同一接收方不同用途的验证码互不通用
+const (
+	CodePurposeLogin          = "login"           // 邮箱或手机号验证码登录
+	CodePurposeRegister       = "register"        // 邮箱验证码注册
+	CodePurposeChangePassword = "change_password" // 登录后通过邮箱验证码修改密码
+	CodePurposePhoneVerify    = "phone_verify"    // 验证账号的手机号
+	codePurposeDevice         = "device_verify"   // 新设备验证，与设备绑定
+)
+
+// 验证码的限制范围
+const (
+	CodeScopeEmail = "email" // 同一邮箱
+	CodeScopePhone = "phone" // 同一手机号
+	CodeScopeIP    = "ip"    // 同一客户端 IP
+)
+
+var (
+	// ErrCodeInvalid 验证码错误、过期、不存在或用途不符
+	ErrCodeInvalid = errors.New("invalid verification code")
+	// ErrCodeExhausted 验证码连续校验失败次数过多，已作废
+	ErrCodeExhausted = errors.New("too many failed verification attempts, please request a new code")
+)
+
+// CodeCooldownError 发送过于频繁，RetryAfter 后才能再次发送
+type CodeCooldownError struct {
+	Scope      string        // email、phone 或 ip
+	RetryAfter time.Duration // 剩余冷却时间
+}
+
+func (e *CodeCooldownError) Error() string {
+	return fmt.Sprintf("verification code requested too frequently for this %s, retry after %d seconds", e.Scope, e.RetrySeconds())
+}
+
+// RetrySeconds 剩余冷却秒数，不足一秒按一秒计
+func (e *CodeCooldownError) RetrySeconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// DeviceVerifyPurpose 设备验证码的用途，验证码只能用于信任发送时指定的设备
+func DeviceVerifyPurpose(deviceID string) string {
+	return codePurposeDevice + ":" + deviceID
+}
+
+// VerificationCodeService 验证码的发放与校验，验证码按 用途+接收方 存放：
+// 按接收方和 IP 分别限制发送间隔与时间窗内的发送次数（不区分用途），
+// 每个验证码最多校验失败 MaxFailures 次，超过后作废需重新获取
+type VerificationCodeService struct {
+	Scope             string        // 接收方的类型，email 或 phone
+	CodeTTL           time.Duration // 验证码有效期
+	RecipientCooldown time.Duration // 同一接收方两次发送的最小间隔
+	IPCooldown        time.Duration // 同一 IP 两次发送的最小间隔
+	IssueWindow       time.Duration // 发送次数统计窗口
+	MaxIssuesPerKey   int           // 窗口内同一接收方最多发送次数，同一 IP 为其 4 倍
+	MaxFailures       int           // 每个验证码允许的校验失败次数
+
+	mu     sync.Mutex
+	codes  map[string]*verificationCode // 用途:接收方 -> 验证码
+	issues map[string][]time.Time       // 范围:键 -> 窗口内的发送时间
+	now    func() time.Time
+}
+
+type verificationCode struct {
+	code      string
+	expiresAt time.Time
+	failures  int
+}
+
+// NewVerificationCodeService 创建使用默认限制的验证码服务
+func NewVerificationCodeService(scope string) *VerificationCodeService {
+	return &VerificationCodeService{
+		Scope:             scope,
+		CodeTTL:           5 * time.Minute,
+		RecipientCooldown: 60 * time.Second,
+		IPCooldown:        10 * time.Second,
+		IssueWindow:       time.Hour,
+		MaxIssuesPerKey:   5,
+		MaxFailures:       5,
+		codes:             make(map[string]*verificationCode),
+		issues:            make(map[string][]time.Time),
+		now:               time.Now,
+	}
+}
+
+// NewSMSCodeService 创建短信验证码服务，短信有成本所以同一 IP 的间隔更长
+func NewSMSCodeService() *VerificationCodeService {
+	s := NewVerificationCodeService(CodeScopePhone)
+	s.IPCooldown = 30 * time.Second
+	return s
+}
+
+// GlobalEmailCodes 全局邮箱验证码服务
+var GlobalEmailCodes = NewVerificationCodeService(CodeScopeEmail)
+
+// GlobalSMSCodes 全局短信验证码服务，用于手机验证和手机号登录
+var GlobalSMSCodes = NewSMSCodeService()
+
+func normalizeRecipient(recipient string) string {
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
+
+func codeKey(purpose, recipient string) string {
+	return purpose + ":" + recipient
+}
+
+// newCode 用 crypto/rand 生成 6 位数字验证码
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// Issue 为接收方生成指定用途的新验证码，替换该用途之前的验证码。冷却中时返回 *CodeCooldownError
+func (s *VerificationCodeService) Issue(purpose, recipient, ip string) (string, error) {
+	if purpose == "" {
+		return "", errors.New("verification code purpose is required")
+	}
+	recipient = normalizeRecipient(recipient)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	recipientKey := s.Scope + ":" + recipient
+	ipKey := CodeScopeIP + ":" + ip
+	if wait := s.cooldown(recipientKey, now, s.RecipientCooldown, s.MaxIssuesPerKey); wait > 0 {
+		return "", &CodeCooldownError{Scope: s.Scope, RetryAfter: wait}
+	}
+	if ip != "" {
+		if wait := s.cooldown(ipKey, now, s.IPCooldown, s.MaxIssuesPerKey*4); wait > 0 {
+			return "", &CodeCooldownError{Scope: CodeScopeIP, RetryAfter: wait}
+		}
+	}
+	code, err := newCode()
+	if err != nil {
+		return "", err
+	}
+	if ip != "" {
+		s.issues[ipKey] = append(s.issues[ipKey], now)
+	}
+	s.issues[recipientKey] = append(s.issues[recipientKey], now)
+
+	s.codes[codeKey(purpose, recipient)] = &verificationCode{code: code, expiresAt: now.Add(s.CodeTTL)}
+	return code, nil
+}
+
+// Cooldown 接收方当前还需等待多久才能再次发送
+func (s *VerificationCodeService) Cooldown(recipient string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cooldown(s.Scope+":"+normalizeRecipient(recipient), s.now(), s.RecipientCooldown, s.MaxIssuesPerKey)
+}
+
+// Verify 校验指定用途的验证码，成功后验证码失效。比较使用常量时间
+func (s *VerificationCodeService) Verify(purpose, recipient, code string) error {
+	return s.verify(purpose, recipient, code, true)
+}
+
+// Check 校验验证码但不使其失效，用于还需要其他校验（如两步验证）的场景，失败同样计数
+func (s *VerificationCodeService) Check(purpose, recipient, code string) error {
+	return s.verify(purpose, recipient, code, false)
+}
+
+func (s *VerificationCodeService) verify(purpose, recipient, code string, consume bool) error {
+	key := codeKey(purpose, normalizeRecipient(recipient))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.codes[key]
+	if !ok || s.now().After(c.expiresAt) {
+		delete(s.codes, key)
+		return ErrCodeInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(c.code), []byte(code)) != 1 {
+		c.failures++
+		if c.failures >= s.MaxFailures {
+			delete(s.codes, key)
+			return ErrCodeExhausted
+		}
+		return ErrCodeInvalid
+	}
+	if consume {
+		delete(s.codes, key)
+	}
+	return nil
+}
+
+// Revoke 作废接收方指定用途的验证码，如发送失败时
+func (s *VerificationCodeService) Revoke(purpose, recipient string) {
+	s.mu.Lock()
+	delete(s.codes, codeKey(purpose, normalizeRecipient(recipient)))
+	s.mu.Unlock()
+}
+
+// cooldown 返回 key 的剩余等待时间：距上次发送不足 interval，或窗口内已达 max 次
+func (s *VerificationCodeService) cooldown(key string, now time.Time, interval time.Duration, max int) time.Duration {
+	history := s.issues[key]
+	if len(history) == 0 {
+		return 0
+	}
+	var wait time.Duration
+	if d := history[len(history)-1].Add(interval).Sub(now); d > 0 {
+		wait = d
+	}
+	if len(history) >= max {
+		// 最早一次发送移出窗口后才能再发
+		if d := history[len(history)-max].Add(s.IssueWindow).Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// prune 清理过期的验证码和窗口外的发送记录
+func (s *VerificationCodeService) prune(now time.Time) {
+	for key, c := range s.codes {
+		if now.After(c.expiresAt) {
+			delete(s.codes, key)
+		}
+	}
+	for key, history := range s.issues {
+		i := 0
+		for i < len(history) && now.Sub(history[i]) >= s.IssueWindow {
+			i++
+		}
+		if i == len(history) {
+			delete(s.issues, key)
+		} else if i > 0 {
+			s.issues[key] = history[i:]
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCodeService(scope string) (*VerificationCodeService, *time.Time) {
+	s := NewVerificationCodeService(scope)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestVerificationCodeService_Cooldowns(t *testing.T) {
+	s, now := newTestCodeService(CodeScopeEmail)
+
+	_, err := s.Issue(CodePurposeLogin, "User@Example.com", "1.1.1.1")
+	require.NoError(t, err)
+
+	// Throttling is per recipient, whatever the purpose
+	_, err = s.Issue(CodePurposeRegister, "user@example.com ", "2.2.2.2")
+	var cd *CodeCooldownError
+	require.True(t, errors.As(err, &cd))
+	assert.Equal(t, CodeScopeEmail, cd.Scope)
+	assert.Equal(t, 60, cd.RetrySeconds())
+
+	_, err = s.Issue(CodePurposeLogin, "other@example.com", "1.1.1.1")
+	require.True(t, errors.As(err, &cd))
+	assert.Equal(t, CodeScopeIP, cd.Scope)
+
+	*now = now.Add(30 * time.Second)
+	assert.Equal(t, 30*time.Second, s.Cooldown("user@example.com"))
+	_, err = s.Issue(CodePurposeLogin, "other@example.com", "1.1.1.1")
+	assert.NoError(t, err)
+
+	// Window cap: five codes per hour for the same email
+	for i := 0; i < 3; i++ {
+		*now = now.Add(time.Minute)
+		_, err = s.Issue(CodePurposeLogin, "user@example.com", "3.3.3.3")
+		require.NoError(t, err)
+	}
+	*now = now.Add(time.Minute)
+	_, err = s.Issue(CodePurposeLogin, "user@example.com", "3.3.3.3")
+	require.NoError(t, err)
+	*now = now.Add(time.Minute)
+	_, err = s.Issue(CodePurposeLogin, "user@example.com", "3.3.3.3")
+	require.True(t, errors.As(err, &cd))
+	assert.Greater(t, cd.RetryAfter, 50*time.Minute)
+
+	*now = now.Add(time.Hour)
+	_, err = s.Issue(CodePurposeLogin, "user@example.com", "3.3.3.3")
+	assert.NoError(t, err)
+
+	_, err = s.Issue("", "user@example.com", "")
+	assert.Error(t, err)
+}
+
+func TestVerificationCodeService_Verify(t *testing.T) {
+	s, now := newTestCodeService(CodeScopeEmail)
+
+	code, err := s.Issue(CodePurposeLogin, "a@example.com", "")
+	require.NoError(t, err)
+	assert.Len(t, code, 6)
+	assert.ErrorIs(t, s.Verify(CodePurposeLogin, "a@example.com", "wrong"), ErrCodeInvalid)
+	assert.NoError(t, s.Verify(CodePurposeLogin, "A@example.com", code))
+	assert.ErrorIs(t, s.Verify(CodePurposeLogin, "a@example.com", code), ErrCodeInvalid, "codes are single use")
+
+	// Too many failures invalidate the code
+	*now = now.Add(time.Minute)
+	code, err = s.Issue(CodePurposeLogin, "a@example.com", "")
+	require.NoError(t, err)
+	for i := 0; i < s.MaxFailures-1; i++ {
+		assert.ErrorIs(t, s.Verify(CodePurposeLogin, "a@example.com", "000000x"), ErrCodeInvalid)
+	}
+	assert.ErrorIs(t, s.Verify(CodePurposeLogin, "a@example.com", "000000x"), ErrCodeExhausted)
+	assert.ErrorIs(t, s.Verify(CodePurposeLogin, "a@example.com", code), ErrCodeInvalid)
+
+	// Expired codes are rejected
+	*now = now.Add(time.Minute)
+	code, err = s.Issue(CodePurposeLogin, "a@example.com", "")
+	require.NoError(t, err)
+	*now = now.Add(s.CodeTTL + time.Second)
+	assert.ErrorIs(t, s.Verify(CodePurposeLogin, "a@example.com", code), ErrCodeInvalid)
+
+	*now = now.Add(time.Minute)
+	code, err = s.Issue(CodePurposeLogin, "a@example.com", "")
+	require.NoError(t, err)
+	s.Revoke(CodePurposeLogin, "a@example.com")
+	assert.ErrorIs(t, s.Verify(CodePurposeLogin, "a@example.com", code), ErrCodeInvalid)
+}
+
+func TestVerificationCodeService_Check(t *testing.T) {
+	s, _ := newTestCodeService(CodeScopePhone)
+
+	code, err := s.Issue(CodePurposeLogin, "+8613800000000", "")
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Check(CodePurposeLogin, "+8613800000000", "wrong"), ErrCodeInvalid)
+	assert.NoError(t, s.Check(CodePurposeLogin, "+8613800000000", code))
+	assert.NoError(t, s.Check(CodePurposeLogin, "+8613800000000", code), "checking doesn't consume the code")
+	assert.NoError(t, s.Verify(CodePurposeLogin, "+8613800000000", code))
+	assert.ErrorIs(t, s.Check(CodePurposeLogin, "+8613800000000", code), ErrCodeInvalid)
+}
+
+func TestVerificationCodeService_PurposeIsolation(t *testing.T) {
+	s, now := newTestCodeService(CodeScopeEmail)
+
+	registerCode, err := s.Issue(CodePurposeRegister, "a@example.com", "")
+	require.NoError(t, err)
+	*now = now.Add(time.Minute)
+	loginCode, err := s.Issue(CodePurposeLogin, "a@example.com", "")
+	require.NoError(t, err)
+
+	// A code issued for one purpose can't be replayed for another
+	assert.ErrorIs(t, s.Verify(CodePurposeChangePassword, "a@example.com", registerCode), ErrCodeInvalid)
+	assert.NoError(t, s.Verify(CodePurposeRegister, "a@example.com", registerCode))
+	assert.NoError(t, s.Verify(CodePurposeLogin, "a@example.com", loginCode))
+
+	*now = now.Add(time.Minute)
+	deviceCode, err := s.Issue(DeviceVerifyPurpose("device-a"), "a@example.com", "")
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Verify(DeviceVerifyPurpose("device-b"), "a@example.com", deviceCode), ErrCodeInvalid)
+	assert.NoError(t, s.Verify(DeviceVerifyPurpose("device-a"), "a@example.com", deviceCode))
+}
+
+func TestSMSCodeService_Scope(t *testing.T) {
+	s := NewSMSCodeService()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	code, err := s.Issue(CodePurposeLogin, "+8613800138000", "1.1.1.1")
+	require.NoError(t, err)
+
+	_, err = s.Issue(CodePurposeLogin, "+8613800138000", "2.2.2.2")
+	var cd *CodeCooldownError
+	require.True(t, errors.As(err, &cd))
+	assert.Equal(t, CodeScopePhone, cd.Scope)
+
+	now = now.Add(20 * time.Second)
+	_, err = s.Issue(CodePurposeLogin, "+8613900139000", "1.1.1.1")
+	require.True(t, errors.As(err, &cd))
+	assert.Equal(t, CodeScopeIP, cd.Scope)
+
+	assert.NoError(t, s.Verify(CodePurposeLogin, "+8613800138000", code))
+}
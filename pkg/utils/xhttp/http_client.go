@@ -13,6 +13,8 @@ const (
 	HTTP_REQUEST_TIME_OUT_SECOND = time.Second * 10
 )
 
+var defaultClient = NewClient("xhttp", HTTP_REQUEST_TIME_OUT_SECOND)
+
 type HeaderOption struct {
 	Key   string
 	Value string
@@ -51,11 +53,8 @@ func Get(url string, params map[string]interface{}, headerOptions ...*HeaderOpti
 		req.Header.Set(option.Key, option.Value)
 	}
 
-	var (
-		client = http.Client{Timeout: HTTP_REQUEST_TIME_OUT_SECOND}
-		resp   *http.Response
-	)
-	if resp, err = client.Do(req); err != nil {
+	var resp *http.Response
+	if resp, err = defaultClient.Do(req); err != nil {
 		return
 	}
 	defer resp.Body.Close()
@@ -82,11 +81,8 @@ func Post(url string, params map[string]interface{}, headerOptions ...*HeaderOpt
 	for _, option = range headerOptions {
 		req.Header.Set(option.Key, option.Value)
 	}
-	var (
-		client = &http.Client{Timeout: HTTP_REQUEST_TIME_OUT_SECOND}
-		resp   *http.Response
-	)
-	if resp, err = client.Do(req); err != nil {
+	var resp *http.Response
+	if resp, err = defaultClient.Do(req); err != nil {
 		return
 	}
 	defer resp.Body.Close()
@@ -0,0 +1,239 @@
+package xhttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Defaults of the transport shared by outbound integrations
+const (
+	DefaultMaxIdleConns        = 512
+	DefaultMaxIdleConnsPerHost = 64
+	DefaultMaxConnsPerHost     = 256
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultDNSCacheTTL         = time.Minute
+)
+
+// TransportOptions tunes connection pooling and DNS caching of an outbound transport
+type TransportOptions struct {
+	MaxIdleConns        int           // idle connections kept across all hosts
+	MaxIdleConnsPerHost int           // idle connections kept per host, Go's default of 2 forces new connections under load
+	MaxConnsPerHost     int           // connections per host including active ones, 0 means unlimited
+	IdleConnTimeout     time.Duration // idle connections are closed after this long
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	DNSCacheTTL         time.Duration // 0 disables DNS caching
+	// PublicOnly refuses connections to non public addresses and bypasses proxies,
+	// for targets configured by users
+	PublicOnly bool
+}
+
+// DefaultTransportOptions options of the shared transport
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        DefaultMaxIdleConns,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		MaxConnsPerHost:     DefaultMaxConnsPerHost,
+		IdleConnTimeout:     DefaultIdleConnTimeout,
+		DialTimeout:         DefaultDialTimeout,
+		TLSHandshakeTimeout: DefaultTLSHandshakeTimeout,
+		DNSCacheTTL:         DefaultDNSCacheTTL,
+	}
+}
+
+var (
+	outboundRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbound_http_requests_total",
+			Help: "Total number of outbound HTTP requests by integration",
+		},
+		[]string{"integration", "host", "status"},
+	)
+	outboundDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "outbound_http_request_duration_seconds",
+			Help:    "Outbound HTTP request duration until response headers in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"integration", "host"},
+	)
+	outboundInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "outbound_http_requests_in_flight",
+			Help: "Number of outbound HTTP requests waiting for response headers",
+		},
+		[]string{"integration"},
+	)
+	dnsLookups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbound_dns_lookups_total",
+			Help: "Outbound DNS lookups by result: hit, miss or stale (cached answer served after a failed lookup)",
+		},
+		[]string{"result"},
+	)
+)
+
+var (
+	sharedTransportOnce sync.Once
+	sharedTransport     *http.Transport
+)
+
+// SharedTransport the pooled transport all outbound integrations share, so
+// connections to the same host are reused instead of exhausting ephemeral ports
+func SharedTransport() *http.Transport {
+	sharedTransportOnce.Do(func() {
+		sharedTransport = NewTransport(DefaultTransportOptions())
+	})
+	return sharedTransport
+}
+
+// NewTransport creates a transport with the given pooling and DNS caching options.
+// Prefer NewClient, which shares one transport; use this only for integrations
+// that need their own pool
+func NewTransport(opts TransportOptions) *http.Transport {
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}
+	proxy := http.ProxyFromEnvironment
+	if opts.PublicOnly {
+		dialer.Control = publicOnlyControl
+		// A proxy would dial the target on our behalf, past the check
+		proxy = nil
+	}
+	dial := dialer.DialContext
+	if opts.DNSCacheTTL > 0 {
+		cache := newDNSCache(opts.DNSCacheTTL, net.DefaultResolver.LookupHost)
+		dial = cache.dialer(dialer.DialContext)
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// NewClient creates a client for an outbound integration on the shared
+// transport. Requests are counted per integration (e.g. "live", "pinecone",
+// "sendcloud"). A zero timeout means none, for streaming responses bounded by
+// the request context
+func NewClient(integration string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: InstrumentTransport(integration, SharedTransport()),
+	}
+}
+
+// InstrumentTransport records the outbound metrics of integration for requests through base
+func InstrumentTransport(integration string, base http.RoundTripper) http.RoundTripper {
+	return &instrumentedTransport{integration: integration, base: base}
+}
+
+type instrumentedTransport struct {
+	integration string
+	base        http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	inFlight := outboundInFlight.WithLabelValues(t.integration)
+	inFlight.Inc()
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	inFlight.Dec()
+
+	host := req.URL.Host
+	outboundDuration.WithLabelValues(t.integration, host).Observe(time.Since(start).Seconds())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	outboundRequests.WithLabelValues(t.integration, host, status).Inc()
+	return resp, err
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsCache caches host lookups for ttl; when a lookup fails the expired answer
+// is served rather than failing the request
+type dnsCache struct {
+	ttl     time.Duration
+	lookup  func(ctx context.Context, host string) ([]string, error)
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+func newDNSCache(ttl time.Duration, lookup func(ctx context.Context, host string) ([]string, error)) *dnsCache {
+	return &dnsCache{ttl: ttl, lookup: lookup, now: time.Now, entries: make(map[string]dnsEntry)}
+}
+
+func (c *dnsCache) lookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		dnsLookups.WithLabelValues("hit").Inc()
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.lookup(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		if ok {
+			dnsLookups.WithLabelValues("stale").Inc()
+			return entry.addrs, nil
+		}
+		if err == nil {
+			err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+		}
+		return nil, err
+	}
+	dnsLookups.WithLabelValues("miss").Inc()
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// dialer resolves the host through the cache and dials its addresses in order
+func (c *dnsCache) dialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := c.lookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, lastErr
+	}
+}
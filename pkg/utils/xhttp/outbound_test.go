@@ -0,0 +1,89 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDNSCache(t *testing.T) {
+	lookups := 0
+	var lookupErr error
+	now := time.Now()
+	cache := newDNSCache(time.Minute, func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return []string{"10.0.0.1"}, lookupErr
+	})
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		addrs, err := cache.lookupHost(context.Background(), "api.example.com")
+		if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+			t.Fatalf("lookupHost() = %v, %v", addrs, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("lookups = %d, want 1 while cached", lookups)
+	}
+
+	if addrs, _ := cache.lookupHost(context.Background(), "127.0.0.1"); len(addrs) != 1 || lookups != 1 {
+		t.Errorf("IP addresses should not be looked up")
+	}
+
+	// An expired answer is served when the lookup fails
+	now = now.Add(2 * time.Minute)
+	lookupErr = errors.New("timeout")
+	addrs, err := cache.lookupHost(context.Background(), "api.example.com")
+	if err != nil || len(addrs) != 1 {
+		t.Errorf("stale lookupHost() = %v, %v", addrs, err)
+	}
+	if lookups != 2 {
+		t.Errorf("lookups = %d, want 2 after expiry", lookups)
+	}
+	if _, err := cache.lookupHost(context.Background(), "other.example.com"); err == nil {
+		t.Error("expected error for uncached host")
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	a := NewClient("test-a", time.Second)
+	b := NewClient("test-b", 0)
+	if a.Transport.(*instrumentedTransport).base != b.Transport.(*instrumentedTransport).base {
+		t.Error("clients should share the pooled transport")
+	}
+	if SharedTransport().MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("MaxIdleConnsPerHost = %d", SharedTransport().MaxIdleConnsPerHost)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := a.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	host := server.Listener.Addr().String()
+	if got := testutil.ToFloat64(outboundRequests.WithLabelValues("test-a", host, "418")); got != 2 {
+		t.Errorf("requests counted = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(outboundInFlight.WithLabelValues("test-a")); got != 0 {
+		t.Errorf("in flight = %v, want 0", got)
+	}
+
+	if _, err := b.Get("http://127.0.0.1:1"); err == nil {
+		t.Fatal("expected connection error")
+	}
+	if got := testutil.ToFloat64(outboundRequests.WithLabelValues("test-b", "127.0.0.1:1", "error")); got != 1 {
+		t.Errorf("errors counted = %v, want 1", got)
+	}
+}
@@ -0,0 +1,85 @@
+package xhttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublicAddress a public-only client refused to connect to a loopback,
+// link-local, private or otherwise non public address
+var ErrNonPublicAddress = errors.New("target is not a public address")
+
+// nonPublicNets ranges not covered by the net.IP predicates used in IsPublicIP
+var nonPublicNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),     // "This" network
+	mustCIDR("100.64.0.0/10"), // Carrier-grade NAT
+	mustCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustCIDR("198.18.0.0/15"), // Benchmarking
+	mustCIDR("240.0.0.0/4"),   // Reserved, includes broadcast
+	mustCIDR("64:ff9b::/96"),  // NAT64, may map to private IPv4 addresses
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// IsPublicIP reports whether ip is a globally routable unicast address
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicOnlyControl runs on the resolved address of every dial, redirects
+// included, so DNS rebinding cannot point a validated host at an internal service
+func publicOnlyControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIP(net.ParseIP(host)) {
+		return ErrNonPublicAddress
+	}
+	return nil
+}
+
+var (
+	publicTransportOnce sync.Once
+	publicTransport     *http.Transport
+)
+
+// SharedPublicTransport the pooled transport shared by public-only clients
+func SharedPublicTransport() *http.Transport {
+	publicTransportOnce.Do(func() {
+		opts := DefaultTransportOptions()
+		opts.PublicOnly = true
+		publicTransport = NewTransport(opts)
+	})
+	return publicTransport
+}
+
+// NewPublicClient is NewClient for user-configured targets (webhooks, CalDAV
+// servers): it only connects to public addresses and never uses a proxy
+func NewPublicClient(integration string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: InstrumentTransport(integration, SharedPublicTransport()),
+	}
+}
@@ -0,0 +1,53 @@
+package xhttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for _, ip := range []string{"8.8.8.8", "1.1.1.1", "2606:4700:4700::1111"} {
+		if !IsPublicIP(net.ParseIP(ip)) {
+			t.Errorf("%s should be public", ip)
+		}
+	}
+	for _, ip := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"0.0.0.0", "100.64.0.1", "255.255.255.255", "224.0.0.1",
+		"::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "64:ff9b::a00:1",
+	} {
+		if IsPublicIP(net.ParseIP(ip)) {
+			t.Errorf("%s should not be public", ip)
+		}
+	}
+	if IsPublicIP(nil) {
+		t.Error("nil should not be public")
+	}
+}
+
+func TestNewPublicClient(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	client := NewPublicClient("test-public", time.Second)
+	if client.Transport.(*instrumentedTransport).base != SharedPublicTransport() {
+		t.Error("public clients should share the public transport")
+	}
+	if SharedPublicTransport().Proxy != nil {
+		t.Error("public transport must not use a proxy")
+	}
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Fatalf("err = %v, want ErrNonPublicAddress", err)
+	}
+	if calls != 0 {
+		t.Errorf("server was called %d times", calls)
+	}
+}
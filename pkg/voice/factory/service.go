@@ -8,6 +8,7 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/llm"
 	"github.com/code-100-precent/LingEcho/pkg/recognizer"
 	"github.com/code-100-precent/LingEcho/pkg/synthesizer"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer/textnorm"
 	"github.com/code-100-precent/LingEcho/pkg/voice/errhandler"
 	"go.uber.org/zap"
 )
@@ -99,6 +100,15 @@ func (f *ServiceFactory) CreateTTS(credential *models.UserCredential, speaker st
 	return ttsService, nil
 }
 
+// CreateNormalizedTTS 创建TTS服务，合成前按助手配置将数字、金额、日期和电话转换为可朗读的文本
+func (f *ServiceFactory) CreateNormalizedTTS(credential *models.UserCredential, speaker string, opts textnorm.Options) (synthesizer.SynthesisService, error) {
+	ttsService, err := f.CreateTTS(credential, speaker)
+	if err != nil {
+		return nil, err
+	}
+	return synthesizer.WithTextNormalization(ttsService, opts), nil
+}
+
 // CreateLLM 创建LLM服务
 func (f *ServiceFactory) CreateLLM(ctx context.Context, credential *models.UserCredential, systemPrompt string) (llm.LLMProvider, error) {
 	provider, err := llm.NewLLMProvider(ctx, credential.LLMProvider, credential.LLMApiKey, credential.LLMApiURL, systemPrompt)
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer/textnorm"
 	"github.com/code-100-precent/LingEcho/pkg/voice/asr"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -69,6 +70,7 @@ func (h *Handler) HandleWebSocket(
 	enableVAD := true
 	vadThreshold := 500.0
 	vadConsecutiveFrames := 2
+	textNormalization := textnorm.Options{Language: language}
 	if assistantID > 0 && db != nil {
 		var assistant models.Assistant
 		if err := db.First(&assistant, assistantID).Error; err == nil {
@@ -89,6 +91,7 @@ func (h *Handler) HandleWebSocket(
 			if assistant.VADConsecutiveFrames > 0 {
 				vadConsecutiveFrames = assistant.VADConsecutiveFrames
 			}
+			textNormalization = assistant.TextNormalization()
 		}
 	}
 
@@ -117,6 +120,7 @@ func (h *Handler) HandleWebSocket(
 		EnableVAD:            enableVAD,
 		VADThreshold:         vadThreshold,
 		VADConsecutiveFrames: vadConsecutiveFrames,
+		TextNormalization:    textNormalization,
 	}
 
 	// 创建会话
@@ -17,7 +17,6 @@ import (
 	"github.com/code-100-precent/LingEcho/pkg/voice/tts"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
 const (
@@ -122,7 +121,7 @@ func NewSession(config *SessionConfig) (*Session, error) {
 	}
 
 	// 创建TTS服务
-	synthesizer, err := serviceFactory.CreateTTS(config.Credential, config.Speaker)
+	synthesizer, err := serviceFactory.CreateNormalizedTTS(config.Credential, config.Speaker, config.TextNormalization)
 	if err != nil {
 		cancel()
 		return nil, errhandler.NewRecoverableError("Session", "创建TTS服务失败", err)
@@ -407,13 +406,11 @@ func recordASRUsage(ctx context.Context, config *SessionConfig, duration time.Du
 		assistantID = &aid
 	}
 
-	// 获取组织ID（如果助手属于组织）
+	// 获取组织ID（助手属于组织，或通过组织共享给调用的成员）
 	var groupID *uint
-	if assistantID != nil && config.DB != nil {
-		var assistant models.Assistant
-		if err := config.DB.WithContext(recordCtx).Where("id = ?", *assistantID).First(&assistant).Error; err == nil {
-			groupID = assistant.GroupID
-		} else if err != gorm.ErrRecordNotFound {
+	if config.DB != nil {
+		var err error
+		if groupID, err = models.AssistantUsageGroupIDByID(config.DB.WithContext(recordCtx), assistantID, config.Credential.UserID); err != nil {
 			logger.Warn("查询助手信息失败", zap.Error(err))
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/code-100-precent/LingEcho/internal/models"
+	"github.com/code-100-precent/LingEcho/pkg/synthesizer/textnorm"
 	"github.com/code-100-precent/LingEcho/pkg/voice/asr"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -30,4 +31,6 @@ type SessionConfig struct {
 	EnableVAD            bool    // 是否启用VAD
 	VADThreshold         float64 // VAD阈值
 	VADConsecutiveFrames int     // 需要连续超过阈值的帧数
+	// TTS 文本规范化配置
+	TextNormalization textnorm.Options
 }
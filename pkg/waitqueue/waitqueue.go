@@ -0,0 +1,96 @@
+// Package waitqueue FIFO wait queues with priority levels, shared by the
+// admission limiters (pkg/sessionlimit, pkg/overload) to hand a freed slot
+// to the next caller in line.
+package waitqueue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTimeout the waiter was not granted a slot within the timeout
+var ErrTimeout = errors.New("wait queue timeout")
+
+// Waiter a caller waiting in the queue
+type Waiter struct {
+	granted chan struct{}
+}
+
+// Wait blocks until the waiter is granted a slot, the timeout elapses or ctx is
+// done. A duration received on hold restarts the timeout with it, keeping the
+// place in the queue; hold may be nil. It returns ErrTimeout or the context error.
+// A waiter that was not granted must be removed with Queue.Remove under the
+// owner's lock, which also resolves a grant racing with the timeout.
+func (w *Waiter) Wait(ctx context.Context, timeout time.Duration, hold <-chan time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.granted:
+			return nil
+		case d := <-hold:
+			timer.Reset(d)
+		case <-timer.C:
+			return ErrTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Queue waiters by priority level, level 0 is served first and each level in
+// arrival order. Not safe for concurrent use, the owner guards it with its own lock.
+type Queue struct {
+	levels [][]*Waiter
+}
+
+// New creates a queue with the given number of priority levels (at least one)
+func New(levels int) *Queue {
+	if levels < 1 {
+		levels = 1
+	}
+	return &Queue{levels: make([][]*Waiter, levels)}
+}
+
+// Push appends a waiter at the given level
+func (q *Queue) Push(level int) *Waiter {
+	w := &Waiter{granted: make(chan struct{})}
+	q.levels[level] = append(q.levels[level], w)
+	return w
+}
+
+// Len number of waiters over all levels
+func (q *Queue) Len() int {
+	n := 0
+	for _, level := range q.levels {
+		n += len(level)
+	}
+	return n
+}
+
+// Remove removes a waiter that was not granted a slot yet, false when it was already granted
+func (q *Queue) Remove(w *Waiter) bool {
+	for l, level := range q.levels {
+		for i, other := range level {
+			if other == w {
+				q.levels[l] = append(level[:i], level[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Grant hands a slot to the oldest waiter of the highest priority level, false when the queue is empty
+func (q *Queue) Grant() bool {
+	for l, level := range q.levels {
+		if len(level) > 0 {
+			w := level[0]
+			q.levels[l] = level[1:]
+			close(w.granted)
+			return true
+		}
+	}
+	return false
+}
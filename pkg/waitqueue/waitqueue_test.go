@@ -0,0 +1,54 @@
+package waitqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_GrantOrder(t *testing.T) {
+	q := New(2)
+	low := q.Push(1)
+	high := q.Push(0)
+	second := q.Push(0)
+	assert.Equal(t, 3, q.Len())
+
+	require.True(t, q.Grant())
+	assert.NoError(t, high.Wait(context.Background(), time.Second, nil))
+	require.True(t, q.Grant())
+	assert.NoError(t, second.Wait(context.Background(), time.Second, nil))
+	require.True(t, q.Grant())
+	assert.NoError(t, low.Wait(context.Background(), time.Second, nil))
+	assert.False(t, q.Grant())
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestWaiter_TimeoutAndRemove(t *testing.T) {
+	q := New(1)
+	w := q.Push(0)
+	assert.ErrorIs(t, w.Wait(context.Background(), 10*time.Millisecond, nil), ErrTimeout)
+	assert.True(t, q.Remove(w))
+	assert.False(t, q.Remove(w))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = q.Push(0)
+	assert.ErrorIs(t, w.Wait(ctx, time.Second, nil), context.Canceled)
+}
+
+func TestWaiter_Hold(t *testing.T) {
+	q := New(1)
+	w := q.Push(0)
+	hold := make(chan time.Duration, 1)
+	hold <- time.Second
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		q.Grant()
+	}()
+	// The initial timeout is shorter than the grant, the hold extends it
+	assert.NoError(t, w.Wait(context.Background(), 10*time.Millisecond, hold))
+}
@@ -0,0 +1,474 @@
+// Package wallboard keeps the live call-center figures shown to supervisors:
+// active calls, queue depths, agent presence and today's KPIs. Everything is
+// aggregated in memory from the call events of the event bus, so reading the
+// wallboard never touches the database.
+package wallboard
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/events"
+	"github.com/code-100-precent/LingEcho/pkg/presence"
+	"github.com/code-100-precent/LingEcho/pkg/sessionlimit"
+)
+
+// endedTTL how long ended calls are remembered, so that events delivered out
+// of order do not bring them back
+const endedTTL = 10 * time.Minute
+
+// CallState state of an active call
+type CallState string
+
+const (
+	CallStateOffered  CallState = "offered"
+	CallStateRinging  CallState = "ringing"
+	CallStateQueued   CallState = "queued"
+	CallStateAnswered CallState = "answered"
+)
+
+func (s CallState) rank() int {
+	switch s {
+	case CallStateRinging, CallStateQueued:
+		return 1
+	case CallStateAnswered:
+		return 2
+	}
+	return 0
+}
+
+// Outcomes of ended calls
+const (
+	outcomeAnswered  = "answered"
+	outcomeAbandoned = "abandoned"
+	outcomeMissed    = "missed"
+)
+
+// Call active call
+type Call struct {
+	CallID     string     `json:"callId"`
+	Direction  string     `json:"direction,omitempty"`
+	From       string     `json:"from,omitempty"`
+	To         string     `json:"to,omitempty"`
+	Queue      string     `json:"queue,omitempty"` // Session queue the call waits or waited in
+	State      CallState  `json:"state"`
+	StartedAt  time.Time  `json:"startedAt"`
+	AnsweredAt *time.Time `json:"answeredAt,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// Queue depth of an assistant session queue
+type Queue struct {
+	Key            string  `json:"key"`
+	Waiting        int     `json:"waiting"`
+	Active         int     `json:"active"`
+	MaxConcurrent  int     `json:"maxConcurrent"`
+	AvgWaitSeconds float64 `json:"avgWaitSeconds"`
+}
+
+// Agent presence of a registered SIP agent
+type Agent struct {
+	presence.State
+	RegisteredUntil time.Time `json:"registeredUntil"`
+}
+
+// KPIs figures of the current day, in local time
+type KPIs struct {
+	Date           string  `json:"date"`
+	Offered        int     `json:"offered"`
+	Answered       int     `json:"answered"`
+	Abandoned      int     `json:"abandoned"` // Caller hung up before the call was answered
+	Missed         int     `json:"missed"`    // Rejected, failed or never answered
+	AnswerRate     float64 `json:"answerRate"`
+	AvgWaitSeconds float64 `json:"avgWaitSeconds"` // Time to answer of answered calls
+	MaxWaitSeconds float64 `json:"maxWaitSeconds"`
+	AvgTalkSeconds float64 `json:"avgTalkSeconds"` // Of answered calls that ended
+}
+
+// Snapshot everything shown on the wallboard
+type Snapshot struct {
+	Calls       []Call    `json:"calls"`
+	Queues      []Queue   `json:"queues"`
+	Agents      []Agent   `json:"agents"`
+	KPIs        KPIs      `json:"kpis"`
+	GeneratedAt time.Time `json:"generatedAt"`
+}
+
+type activeCall struct {
+	Call
+	wait time.Duration // Time to answer counted in the KPIs
+}
+
+type endedCall struct {
+	day       string
+	startedAt time.Time
+	endedAt   time.Time
+	outcome   string
+}
+
+type dayStats struct {
+	date                          string
+	offered, answered             int
+	abandoned, missed             int
+	totalWait, maxWait, totalTalk time.Duration
+	talked                        int
+}
+
+// Board in-memory wallboard aggregates
+type Board struct {
+	mu       sync.Mutex
+	calls    map[string]*activeCall
+	ended    map[string]*endedCall
+	agents   map[string]time.Time // Registration expiry per SIP agent
+	day      dayStats
+	presence *presence.Registry
+	limiter  *sessionlimit.Limiter
+	now      func() time.Time
+}
+
+var (
+	defaultBoard = New(presence.Default(), sessionlimit.Default())
+	subscribe    sync.Once
+)
+
+// Default returns the process-wide board
+func Default() *Board {
+	return defaultBoard
+}
+
+// Start subscribes the default board to the call events of the global event bus
+func Start() {
+	subscribe.Do(func() {
+		defaultBoard.Subscribe(events.GetEventBus())
+	})
+}
+
+// New creates an empty board reading agent presence and queue depths from
+// the given registry and limiter
+func New(registry *presence.Registry, limiter *sessionlimit.Limiter) *Board {
+	return &Board{
+		calls:    make(map[string]*activeCall),
+		ended:    make(map[string]*endedCall),
+		agents:   make(map[string]time.Time),
+		presence: registry,
+		limiter:  limiter,
+		now:      time.Now,
+	}
+}
+
+// Subscribe feeds the board with the call events of the bus
+func (b *Board) Subscribe(bus *events.EventBus) {
+	for _, eventType := range []string{
+		events.CallOffered, events.CallRinging, events.CallQueued,
+		events.CallAnswered, events.CallEnded, events.AgentRegistered,
+	} {
+		bus.Subscribe(eventType, b.Handle)
+	}
+}
+
+// Handle applies a call event. The bus delivers events concurrently, so they
+// may arrive out of order: states only move forward and the event timestamps
+// decide when a call started, was answered and ended.
+func (b *Board) Handle(event events.Event) error {
+	at := event.Timestamp
+	if at.IsZero() {
+		at = b.now()
+	}
+	if event.Type == events.AgentRegistered {
+		agent := stringValue(event.Data, "agent")
+		if agent == "" {
+			return fmt.Errorf("wallboard: %s without agent", event.Type)
+		}
+		b.registerAgent(agent, intValue(event.Data, "expires"), at)
+		return nil
+	}
+
+	callID := stringValue(event.Data, "callId")
+	if callID == "" {
+		return fmt.Errorf("wallboard: %s without callId", event.Type)
+	}
+	switch event.Type {
+	case events.CallOffered:
+		b.update(callID, CallStateOffered, event.Data, at)
+	case events.CallRinging:
+		b.update(callID, CallStateRinging, event.Data, at)
+	case events.CallQueued:
+		b.update(callID, CallStateQueued, event.Data, at)
+	case events.CallAnswered:
+		b.update(callID, CallStateAnswered, event.Data, at)
+	case events.CallEnded:
+		b.end(callID, stringValue(event.Data, "reason"), at)
+	}
+	return nil
+}
+
+func (b *Board) registerAgent(agent string, expires int, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if expires <= 0 {
+		delete(b.agents, agent)
+		return
+	}
+	b.agents[agent] = at.Add(time.Duration(expires) * time.Second)
+}
+
+func (b *Board) update(callID string, state CallState, data map[string]interface{}, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover(at)
+
+	if ended, ok := b.ended[callID]; ok {
+		// Answered before it ended, the answer was delivered late
+		if state == CallStateAnswered && ended.outcome != outcomeAnswered && !at.After(ended.endedAt) && ended.day == b.day.date {
+			b.countOutcome(ended.outcome, -1)
+			ended.outcome = outcomeAnswered
+			b.day.answered++
+			b.countTalk(ended.endedAt.Sub(at))
+			b.countWait(at.Sub(ended.startedAt), 0)
+		}
+		return
+	}
+
+	c := b.call(callID, at)
+	c.fill(data)
+	if at.Before(c.StartedAt) {
+		c.StartedAt = at
+	}
+	if state.rank() > c.State.rank() || (state.rank() == c.State.rank() && !at.Before(c.UpdatedAt)) {
+		c.State = state
+	}
+	if state == CallStateAnswered && (c.AnsweredAt == nil || at.Before(*c.AnsweredAt)) {
+		if c.AnsweredAt == nil {
+			b.day.answered++
+		}
+		answeredAt := at
+		c.AnsweredAt = &answeredAt
+	}
+	if c.AnsweredAt != nil {
+		c.wait = b.countWait(c.AnsweredAt.Sub(c.StartedAt), c.wait)
+	}
+	if at.After(c.UpdatedAt) {
+		c.UpdatedAt = at
+	}
+}
+
+func (b *Board) end(callID, reason string, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover(at)
+
+	if ended, ok := b.ended[callID]; ok {
+		// The first way the call ended decides how an unanswered call is counted
+		if ended.outcome != outcomeAnswered && at.Before(ended.endedAt) && ended.day == b.day.date {
+			b.countOutcome(ended.outcome, -1)
+			ended.outcome = unansweredOutcome(reason)
+			b.countOutcome(ended.outcome, 1)
+			ended.endedAt = at
+		}
+		return
+	}
+
+	c := b.call(callID, at)
+	delete(b.calls, callID)
+	ended := &endedCall{day: b.day.date, startedAt: c.StartedAt, endedAt: at}
+	if c.AnsweredAt != nil {
+		ended.outcome = outcomeAnswered
+		b.countTalk(at.Sub(*c.AnsweredAt))
+	} else {
+		ended.outcome = unansweredOutcome(reason)
+		b.countOutcome(ended.outcome, 1)
+	}
+	b.ended[callID] = ended
+
+	for id, e := range b.ended {
+		if at.Sub(e.endedAt) > endedTTL {
+			delete(b.ended, id)
+		}
+	}
+}
+
+// call returns the active call, starting it when it is new
+func (b *Board) call(callID string, at time.Time) *activeCall {
+	c, ok := b.calls[callID]
+	if !ok {
+		c = &activeCall{Call: Call{CallID: callID, State: CallStateOffered, StartedAt: at, UpdatedAt: at}}
+		b.calls[callID] = c
+		b.day.offered++
+	}
+	return c
+}
+
+// rollover starts a new day of KPIs
+func (b *Board) rollover(at time.Time) {
+	date := at.Local().Format("2006-01-02")
+	if date > b.day.date {
+		b.day = dayStats{date: date}
+	}
+}
+
+// countWait replaces the counted time to answer of a call and returns the new one
+func (b *Board) countWait(wait, counted time.Duration) time.Duration {
+	if wait < 0 {
+		wait = 0
+	}
+	b.day.totalWait += wait - counted
+	if wait > b.day.maxWait {
+		b.day.maxWait = wait
+	}
+	return wait
+}
+
+func (b *Board) countTalk(talk time.Duration) {
+	if talk < 0 {
+		talk = 0
+	}
+	b.day.totalTalk += talk
+	b.day.talked++
+}
+
+func (b *Board) countOutcome(outcome string, delta int) {
+	switch outcome {
+	case outcomeAbandoned:
+		b.day.abandoned += delta
+	case outcomeMissed:
+		b.day.missed += delta
+	case outcomeAnswered:
+		b.day.answered += delta
+	}
+}
+
+func unansweredOutcome(reason string) string {
+	if reason == events.CallEndCancelled {
+		return outcomeAbandoned
+	}
+	return outcomeMissed
+}
+
+// Calls returns the active calls, oldest first
+func (b *Board) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	calls := make([]Call, 0, len(b.calls))
+	for _, c := range b.calls {
+		call := c.Call
+		if c.AnsweredAt != nil {
+			answeredAt := *c.AnsweredAt
+			call.AnsweredAt = &answeredAt
+		}
+		calls = append(calls, call)
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i].StartedAt.Before(calls[j].StartedAt) })
+	return calls
+}
+
+// Queues returns the depth of every assistant session queue
+func (b *Board) Queues() []Queue {
+	stats := b.limiter.All()
+	queues := make([]Queue, 0, len(stats))
+	for _, s := range stats {
+		queues = append(queues, Queue{
+			Key:            s.Key,
+			Waiting:        s.Waiting,
+			Active:         s.Active,
+			MaxConcurrent:  s.MaxConcurrent,
+			AvgWaitSeconds: s.AvgWait().Seconds(),
+		})
+	}
+	return queues
+}
+
+// Agents returns the presence of the registered SIP agents, by username
+func (b *Board) Agents() []Agent {
+	now := b.now()
+	b.mu.Lock()
+	names := make([]string, 0, len(b.agents))
+	until := make(map[string]time.Time, len(b.agents))
+	for name, expiry := range b.agents {
+		if now.After(expiry) {
+			delete(b.agents, name)
+			continue
+		}
+		names = append(names, name)
+		until[name] = expiry
+	}
+	b.mu.Unlock()
+
+	sort.Strings(names)
+	states := b.presence.List(presence.KindSIP, names)
+	agents := make([]Agent, 0, len(states))
+	for _, state := range states {
+		agents = append(agents, Agent{State: state, RegisteredUntil: until[state.ID]})
+	}
+	return agents
+}
+
+// KPIs returns today's figures
+func (b *Board) KPIs() KPIs {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover(now)
+
+	d := b.day
+	kpis := KPIs{
+		Date:           d.date,
+		Offered:        d.offered,
+		Answered:       d.answered,
+		Abandoned:      d.abandoned,
+		Missed:         d.missed,
+		MaxWaitSeconds: d.maxWait.Seconds(),
+	}
+	if d.offered > 0 {
+		kpis.AnswerRate = float64(d.answered) / float64(d.offered)
+	}
+	if d.answered > 0 {
+		kpis.AvgWaitSeconds = d.totalWait.Seconds() / float64(d.answered)
+	}
+	if d.talked > 0 {
+		kpis.AvgTalkSeconds = d.totalTalk.Seconds() / float64(d.talked)
+	}
+	return kpis
+}
+
+// Snapshot returns everything shown on the wallboard
+func (b *Board) Snapshot() Snapshot {
+	return Snapshot{
+		Calls:       b.Calls(),
+		Queues:      b.Queues(),
+		Agents:      b.Agents(),
+		KPIs:        b.KPIs(),
+		GeneratedAt: b.now(),
+	}
+}
+
+func (c *activeCall) fill(data map[string]interface{}) {
+	for key, field := range map[string]*string{
+		"direction": &c.Direction,
+		"from":      &c.From,
+		"to":        &c.To,
+		"queue":     &c.Queue,
+	} {
+		if v := stringValue(data, key); v != "" {
+			*field = v
+		}
+	}
+}
+
+func stringValue(data map[string]interface{}, key string) string {
+	v, _ := data[key].(string)
+	return v
+}
+
+func intValue(data map[string]interface{}, key string) int {
+	switch v := data[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
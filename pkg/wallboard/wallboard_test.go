@@ -0,0 +1,150 @@
+package wallboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/events"
+	"github.com/code-100-precent/LingEcho/pkg/presence"
+	"github.com/code-100-precent/LingEcho/pkg/sessionlimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBoard(now time.Time) *Board {
+	b := New(presence.NewRegistry(), sessionlimit.New())
+	b.now = func() time.Time { return now }
+	return b
+}
+
+func callEvent(eventType, callID string, at time.Time, data map[string]interface{}) events.Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["callId"] = callID
+	return events.Event{Type: eventType, Timestamp: at, Data: data}
+}
+
+func TestBoard_CallLifecycle(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	b := newTestBoard(start.Add(time.Minute))
+
+	require.NoError(t, b.Handle(callEvent(events.CallOffered, "c1", start, map[string]interface{}{"direction": "inbound", "from": "1001", "to": "2000"})))
+	require.NoError(t, b.Handle(callEvent(events.CallQueued, "c1", start.Add(time.Second), map[string]interface{}{"queue": "assistant:7"})))
+
+	calls := b.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, CallStateQueued, calls[0].State)
+	assert.Equal(t, "assistant:7", calls[0].Queue)
+	assert.Equal(t, "1001", calls[0].From)
+
+	require.NoError(t, b.Handle(callEvent(events.CallAnswered, "c1", start.Add(10*time.Second), nil)))
+	assert.Equal(t, CallStateAnswered, b.Calls()[0].State)
+
+	// Caller gives up while ringing
+	require.NoError(t, b.Handle(callEvent(events.CallOffered, "c2", start, nil)))
+	require.NoError(t, b.Handle(callEvent(events.CallEnded, "c2", start.Add(5*time.Second), map[string]interface{}{"reason": events.CallEndCancelled})))
+	// Refused call
+	require.NoError(t, b.Handle(callEvent(events.CallOffered, "c3", start, nil)))
+	require.NoError(t, b.Handle(callEvent(events.CallEnded, "c3", start.Add(time.Second), map[string]interface{}{"reason": events.CallEndRejected})))
+
+	require.NoError(t, b.Handle(callEvent(events.CallEnded, "c1", start.Add(70*time.Second), map[string]interface{}{"reason": events.CallEndHangup})))
+	assert.Empty(t, b.Calls())
+
+	kpis := b.KPIs()
+	assert.Equal(t, "2026-03-02", kpis.Date)
+	assert.Equal(t, 3, kpis.Offered)
+	assert.Equal(t, 1, kpis.Answered)
+	assert.Equal(t, 1, kpis.Abandoned)
+	assert.Equal(t, 1, kpis.Missed)
+	assert.InDelta(t, 1.0/3, kpis.AnswerRate, 1e-9)
+	assert.Equal(t, 10.0, kpis.AvgWaitSeconds)
+	assert.Equal(t, 60.0, kpis.AvgTalkSeconds)
+
+	// Late events of an ended call are ignored
+	require.NoError(t, b.Handle(callEvent(events.CallRinging, "c1", start.Add(2*time.Second), nil)))
+	assert.Empty(t, b.Calls())
+	assert.Equal(t, 3, b.KPIs().Offered)
+}
+
+func TestBoard_OutOfOrderEvents(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	b := newTestBoard(start.Add(time.Minute))
+
+	// Answer delivered before the offer: the offer still sets the start time
+	require.NoError(t, b.Handle(callEvent(events.CallAnswered, "c1", start.Add(4*time.Second), nil)))
+	require.NoError(t, b.Handle(callEvent(events.CallOffered, "c1", start, map[string]interface{}{"direction": "inbound"})))
+	calls := b.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, CallStateAnswered, calls[0].State)
+	assert.Equal(t, start, calls[0].StartedAt)
+	assert.Equal(t, "inbound", calls[0].Direction)
+	assert.Equal(t, 4.0, b.KPIs().AvgWaitSeconds)
+
+	// A rejection published after the CANCEL but delivered first is reclassified
+	require.NoError(t, b.Handle(callEvent(events.CallOffered, "c2", start, nil)))
+	require.NoError(t, b.Handle(callEvent(events.CallEnded, "c2", start.Add(3*time.Second), map[string]interface{}{"reason": events.CallEndRejected})))
+	require.NoError(t, b.Handle(callEvent(events.CallEnded, "c2", start.Add(2*time.Second), map[string]interface{}{"reason": events.CallEndCancelled})))
+	kpis := b.KPIs()
+	assert.Equal(t, 1, kpis.Abandoned)
+	assert.Equal(t, 0, kpis.Missed)
+
+	// Answer delivered after the hang up
+	require.NoError(t, b.Handle(callEvent(events.CallOffered, "c3", start, nil)))
+	require.NoError(t, b.Handle(callEvent(events.CallEnded, "c3", start.Add(30*time.Second), map[string]interface{}{"reason": events.CallEndHangup})))
+	require.NoError(t, b.Handle(callEvent(events.CallAnswered, "c3", start.Add(2*time.Second), nil)))
+	kpis = b.KPIs()
+	assert.Equal(t, 3, kpis.Offered)
+	assert.Equal(t, 2, kpis.Answered)
+	assert.Equal(t, 0, kpis.Missed)
+	assert.Equal(t, 3.0, kpis.AvgWaitSeconds)
+	assert.Equal(t, 28.0, kpis.AvgTalkSeconds)
+
+	assert.Error(t, b.Handle(events.Event{Type: events.CallEnded, Data: map[string]interface{}{}}))
+}
+
+func TestBoard_DayRollover(t *testing.T) {
+	day := time.Date(2026, 3, 2, 23, 59, 0, 0, time.Local)
+	b := newTestBoard(day)
+	require.NoError(t, b.Handle(callEvent(events.CallOffered, "c1", day, nil)))
+	assert.Equal(t, 1, b.KPIs().Offered)
+
+	b.now = func() time.Time { return day.Add(2 * time.Minute) }
+	kpis := b.KPIs()
+	assert.Equal(t, "2026-03-03", kpis.Date)
+	assert.Equal(t, 0, kpis.Offered)
+	assert.Len(t, b.Calls(), 1, "calls in progress survive the rollover")
+}
+
+func TestBoard_AgentsAndQueues(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	registry := presence.NewRegistry()
+	limiter := sessionlimit.New()
+	b := New(registry, limiter)
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.Handle(events.Event{Type: events.AgentRegistered, Timestamp: now, Data: map[string]interface{}{"agent": "bob", "expires": 3600}}))
+	require.NoError(t, b.Handle(events.Event{Type: events.AgentRegistered, Timestamp: now, Data: map[string]interface{}{"agent": "alice", "expires": 60}}))
+	require.NoError(t, b.Handle(events.Event{Type: events.AgentRegistered, Timestamp: now, Data: map[string]interface{}{"agent": "carol", "expires": 60}}))
+	require.NoError(t, b.Handle(events.Event{Type: events.AgentRegistered, Timestamp: now, Data: map[string]interface{}{"agent": "carol", "expires": 0}}))
+	registry.CallStarted(presence.KindSIP, "bob")
+
+	agents := b.Agents()
+	require.Len(t, agents, 2)
+	assert.Equal(t, "alice", agents[0].ID)
+	assert.Equal(t, presence.StatusAvailable, agents[0].Status)
+	assert.Equal(t, "bob", agents[1].ID)
+	assert.Equal(t, presence.StatusBusy, agents[1].Status)
+
+	b.now = func() time.Time { return now.Add(2 * time.Minute) }
+	agents = b.Agents()
+	require.Len(t, agents, 1, "expired registrations are dropped")
+	assert.Equal(t, "bob", agents[0].ID)
+
+	_, err := limiter.Acquire(t.Context(), "assistant:7", sessionlimit.Limit{MaxConcurrent: 2})
+	require.NoError(t, err)
+	queues := b.Queues()
+	require.Len(t, queues, 1)
+	assert.Equal(t, "assistant:7", queues[0].Key)
+	assert.Equal(t, 1, queues[0].Active)
+}
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
+)
+
+var (
+	// ErrInvalidURL is returned by ValidateURL for URLs that are not absolute http(s) URLs
+	ErrInvalidURL = errors.New("webhook url must be an absolute http or https url")
+	// ErrUnsafeTarget the target resolves to a loopback, link-local, private or otherwise non public address
+	ErrUnsafeTarget = xhttp.ErrNonPublicAddress
+)
+
+// ValidateURL checks that raw is an http(s) URL whose host resolves only to public
+// addresses. Used when a user saves a webhook target; DeliverPublic checks the
+// dialed address again at send time since DNS answers may change in between.
+func ValidateURL(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve webhook host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !xhttp.IsPublicIP(addr.IP) {
+			return ErrUnsafeTarget
+		}
+	}
+	return nil
+}
+
+// NewPublicClient creates a webhook client that only connects to public
+// addresses, for targets configured by users
+func NewPublicClient() *Client {
+	return NewClient(xhttp.NewPublicClient("webhook", DefaultTimeout))
+}
+
+var publicClient = NewPublicClient()
+
+// DeliverPublic sends req with a client restricted to public addresses, see NewPublicClient
+func DeliverPublic(ctx context.Context, req Request) (*Result, error) {
+	return publicClient.Deliver(ctx, req)
+}
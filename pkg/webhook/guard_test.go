@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateURL(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, ValidateURL(ctx, "https://8.8.8.8/hook"))
+
+	assert.ErrorIs(t, ValidateURL(ctx, "ftp://8.8.8.8/hook"), ErrInvalidURL)
+	assert.ErrorIs(t, ValidateURL(ctx, "/relative"), ErrInvalidURL)
+	assert.ErrorIs(t, ValidateURL(ctx, "http://127.0.0.1:8080/hook"), ErrUnsafeTarget)
+	assert.ErrorIs(t, ValidateURL(ctx, "http://[::1]/hook"), ErrUnsafeTarget)
+	assert.ErrorIs(t, ValidateURL(ctx, "http://169.254.169.254/latest/meta-data"), ErrUnsafeTarget)
+	assert.ErrorIs(t, ValidateURL(ctx, "http://localhost/hook"), ErrUnsafeTarget)
+}
+
+func TestDeliverPublic_RefusesLoopback(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	result, err := DeliverPublic(context.Background(), Request{
+		URL:         server.URL,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	})
+	require.ErrorIs(t, err, ErrUnsafeTarget)
+	assert.Equal(t, 1, result.Attempts) // Not retried
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/code-100-precent/LingEcho/pkg/logger"
+	"github.com/code-100-precent/LingEcho/pkg/utils/xhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Timestamp"
+
+	DefaultUserAgent = "LingEcho-Webhook/1.0"
+	DefaultTimeout   = 10 * time.Second
+
+	// MaxResponseBody caps how much of the response body is kept in Result
+	MaxResponseBody = 64 << 10
+)
+
+var (
+	// ErrEmptyURL is returned when a delivery has no target URL
+	ErrEmptyURL = errors.New("webhook url is empty")
+	// ErrInvalidSignature is returned by Verify for unsigned, tampered or stale requests
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// DefaultSignatureTolerance max age of a signed inbound request accepted by Verify
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// Request describes a single outbound webhook delivery
+type Request struct {
+	URL       string
+	Method    string            // Defaults to POST
+	Event     string            // Sent in X-Webhook-Event
+	Secret    string            // Signs the body with HMAC-SHA256 when set
+	UserAgent string            // Defaults to DefaultUserAgent
+	Headers   map[string]string // Extra request headers
+	Payload   interface{}       // JSON encoded as request body
+
+	// MaxAttempts number of attempts on network errors or 5xx responses (default 1)
+	MaxAttempts int
+	// Backoff base delay between attempts, doubled on each retry (default 1s)
+	Backoff time.Duration
+}
+
+// Result delivery outcome
+type Result struct {
+	StatusCode int
+	Attempts   int
+	Body       []byte // Response body of the last attempt, truncated to MaxResponseBody
+}
+
+// Client delivers webhooks over HTTP
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a webhook client, a nil httpClient uses DefaultTimeout
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = xhttp.NewClient("webhook", DefaultTimeout)
+	}
+	return &Client{http: httpClient}
+}
+
+var defaultClient = NewClient(nil)
+
+// Deliver sends req with the default client
+func Deliver(ctx context.Context, req Request) (*Result, error) {
+	return defaultClient.Deliver(ctx, req)
+}
+
+// DeliverAsync sends req in the background and logs the outcome
+func DeliverAsync(req Request, done func(*Result, error)) {
+	go func() {
+		result, err := Deliver(context.Background(), req)
+		if err != nil {
+			logger.Warn("webhook delivery failed",
+				zap.String("event", req.Event),
+				zap.String("url", req.URL),
+				zap.Error(err))
+		}
+		if done != nil {
+			done(result, err)
+		}
+	}()
+}
+
+// Sign computes the hex HMAC-SHA256 of timestamp + body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks an inbound request signed like Sign: the signature must match
+// timestamp + body and the unix timestamp must be within tolerance of now
+func Verify(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	if secret == "" || timestamp == "" || signature == "" {
+		return ErrInvalidSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Deliver sends req, retrying on network errors and 5xx responses
+func (c *Client) Deliver(ctx context.Context, req Request) (*Result, error) {
+	if req.URL == "" {
+		return nil, ErrEmptyURL
+	}
+
+	body, err := json.Marshal(req.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	attempts := req.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	backoff := req.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	result := &Result{}
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		result.Attempts = i + 1
+		status, respBody, err := c.send(ctx, req, body)
+		result.StatusCode = status
+		result.Body = respBody
+		if err == nil && status < 400 {
+			return result, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("webhook returned status code %d", status)
+			// 4xx responses will not succeed on retry
+			if status < 500 {
+				return result, err
+			}
+		}
+		if i == attempts-1 || errors.Is(err, ErrUnsafeTarget) {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) send(ctx context.Context, req Request, body []byte) (int, []byte, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, req.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	userAgent := req.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", userAgent)
+	if req.Event != "" {
+		httpReq.Header.Set(HeaderEvent, req.Event)
+	}
+	if req.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		httpReq.Header.Set(HeaderTimestamp, timestamp)
+		httpReq.Header.Set(HeaderSignature, Sign(req.Secret, timestamp, body))
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBody))
+	return resp.StatusCode, respBody, nil
+}
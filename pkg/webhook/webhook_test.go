@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliver_SignsPayload(t *testing.T) {
+	var gotBody []byte
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	result, err := Deliver(context.Background(), Request{
+		URL:     server.URL,
+		Event:   "test.event",
+		Secret:  "s3cret",
+		Payload: map[string]interface{}{"hello": "world"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, 1, result.Attempts)
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(gotBody, &payload))
+	assert.Equal(t, "world", payload["hello"])
+	assert.Equal(t, "test.event", gotHeader.Get(HeaderEvent))
+	assert.Equal(t, DefaultUserAgent, gotHeader.Get("User-Agent"))
+	assert.Equal(t, Sign("s3cret", gotHeader.Get(HeaderTimestamp), gotBody), gotHeader.Get(HeaderSignature))
+}
+
+func TestDeliver_NoSecretNoSignature(t *testing.T) {
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	_, err := Deliver(context.Background(), Request{URL: server.URL, Payload: "x"})
+	require.NoError(t, err)
+	assert.Empty(t, gotHeader.Get(HeaderSignature))
+}
+
+func TestDeliver_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	result, err := Deliver(context.Background(), Request{
+		URL:         server.URL,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Attempts)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+}
+
+func TestDeliver_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	result, err := Deliver(context.Background(), Request{
+		URL:         server.URL,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.Attempts)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDeliver_EmptyURL(t *testing.T) {
+	_, err := Deliver(context.Background(), Request{})
+	assert.ErrorIs(t, err, ErrEmptyURL)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"status":"SUCCEEDED"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("s3cret", timestamp, body)
+
+	assert.NoError(t, Verify("s3cret", timestamp, signature, body, now, DefaultSignatureTolerance))
+	assert.ErrorIs(t, Verify("other", timestamp, signature, body, now, DefaultSignatureTolerance), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("s3cret", timestamp, signature, []byte(`{}`), now, DefaultSignatureTolerance), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("s3cret", timestamp, "", body, now, DefaultSignatureTolerance), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("s3cret", "abc", signature, body, now, DefaultSignatureTolerance), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("s3cret", timestamp, signature, body, now.Add(10*time.Minute), DefaultSignatureTolerance), ErrInvalidSignature)
+}
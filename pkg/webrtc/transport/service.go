@@ -191,14 +191,7 @@ func NewAIClient(conn *websocket.Conn, transport *rtcmedia.WebRTCTransport, sess
 						sessionID = fmt.Sprintf("webrtc_%d_%d", client.userID, time.Now().Unix())
 					}
 
-					// 获取组织ID（如果助手属于组织）
-					var groupID *uint
-					if client.assistantID != nil {
-						var assistant models.Assistant
-						if err := client.db.Where("id = ?", *client.assistantID).First(&assistant).Error; err == nil {
-							groupID = assistant.GroupID
-						}
-					}
+					groupID, _ := models.AssistantUsageGroupIDByID(client.db, client.assistantID, client.userID)
 
 					if err := models.RecordASRUsage(
 						client.db,
@@ -405,14 +398,7 @@ func NewAIClientWithCredential(
 						sessionID = fmt.Sprintf("webrtc_%d_%d", client.userID, time.Now().Unix())
 					}
 
-					// 获取组织ID（如果助手属于组织）
-					var groupID *uint
-					if client.assistantID != nil {
-						var assistant models.Assistant
-						if err := client.db.Where("id = ?", *client.assistantID).First(&assistant).Error; err == nil {
-							groupID = assistant.GroupID
-						}
-					}
+					groupID, _ := models.AssistantUsageGroupIDByID(client.db, client.assistantID, client.userID)
 
 					if err := models.RecordASRUsage(
 						client.db,
@@ -874,14 +860,7 @@ func (c *AIClient) GenerateTTS(text string) {
 
 			sessionID := fmt.Sprintf("webrtc_%d_%d", c.userID, time.Now().Unix())
 
-			// 获取组织ID（如果助手属于组织）
-			var groupID *uint
-			if c.assistantID != nil {
-				var assistant models.Assistant
-				if err := c.db.Where("id = ?", *c.assistantID).First(&assistant).Error; err == nil {
-					groupID = assistant.GroupID
-				}
-			}
+			groupID, _ := models.AssistantUsageGroupIDByID(c.db, c.assistantID, c.userID)
 
 			if err := models.RecordTTSUsage(
 				c.db,
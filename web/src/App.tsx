@@ -21,6 +21,7 @@ import Layout from "@/components/Layout/Layout.tsx";
 import KnowledgeBase from "@/pages/KnowledgeBase.tsx";
 import KnowledgeBaseDetail from "@/pages/KnowledgeBaseDetail.tsx";
 import ResetPassword from "@/pages/ResetPassword.tsx";
+import UnlockAccount from "@/pages/UnlockAccount.tsx";
 import CredentialManager from "@/pages/CredentialManager.tsx";
 import ProtectedRoute from "@/components/Auth/ProtectedRoute.tsx";
 import JSTemplateManager from "@/pages/JSTemplateManager.tsx";
@@ -72,6 +73,9 @@ function App() {
                         
                         {/* 重置密码页面 - 不需要登录 */}
                         <Route path="/reset-password" element={<ResetPassword />} />
+
+                        {/* 账号自助解锁页面 - 不需要登录 */}
+                        <Route path="/unlock-account" element={<UnlockAccount />} />
                         
                         {/* 需要登录的页面 */}
                         <Route path="/overview" element={
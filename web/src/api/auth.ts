@@ -47,6 +47,7 @@ export interface CaptchaResponse {
 // 发送邮箱验证码请求类型
 export interface SendEmailCodeRequest {
   email: string
+  purpose?: 'login' | 'register' | 'change_password' // 验证码用途，默认 login
   clientIp?: string
   userAgent?: string
 }
@@ -84,6 +85,7 @@ export interface EmailCodeLoginForm {
 // 登录响应数据类型
 export interface LoginResponseData {
   token?: string
+  refreshToken?: string
   user?: {
     id?: number | string
     createdAt?: string
@@ -100,6 +102,7 @@ export interface LoginResponseData {
     token?: string
     authToken?: string
     AuthToken?: string
+    refreshToken?: string
     requiresTwoFactor?: boolean
     [key: string]: any
   }
@@ -116,6 +119,8 @@ export interface LoginResponseData {
   timezone?: string
   requiresTwoFactor?: boolean
   requiresDeviceVerification?: boolean
+  requiresEmailVerification?: boolean
+  riskChallenge?: boolean
   deviceId?: string
   message?: string
   suspiciousLogin?: boolean
@@ -214,9 +219,16 @@ export const getUserInfo = async (): Promise<ApiResponse<User>> => {
   return get<User>('/auth/info')
 }
 
+// 刷新令牌换发的新令牌，旧的刷新令牌随即失效
+export interface TokenPair {
+  token: string
+  refreshToken: string
+  expiresAt: string
+}
+
 // 刷新token
-export const refreshToken = async (): Promise<ApiResponse<{ token: string }>> => {
-  return post<{ token: string }>('/auth/refresh')
+export const refreshToken = async (refreshToken: string): Promise<ApiResponse<TokenPair>> => {
+  return post<TokenPair>('/auth/token/refresh', { refreshToken })
 }
 
 // 发送邮箱验证邮件
@@ -254,3 +266,13 @@ export const forgotPassword = async (email: string): Promise<ApiResponse<null>>
 export const resetPasswordConfirm = async (token: string, password: string): Promise<ApiResponse<null>> => {
   return post<null>('/auth/reset-password/confirm', { token, password })
 }
+
+// 账号被锁定时发送自助解锁邮件
+export const requestAccountUnlock = async (email: string): Promise<ApiResponse<null>> => {
+  return post<null>('/auth/unlock-account', { email })
+}
+
+// 使用解锁邮件中的令牌解锁账号
+export const confirmAccountUnlock = async (token: string): Promise<ApiResponse<null>> => {
+  return post<null>('/auth/unlock-account/confirm', { token })
+}
@@ -10,7 +10,7 @@ import CaptchaModal from './CaptchaModal'
 import DeviceVerificationModal from './DeviceVerificationModal'
 import { useAuthStore } from '@/stores/authStore.ts'
 import { showAlert } from '@/utils/notification'
-import { sendEmailCode, registerUserByEmail, registerUser, loginWithPassword, loginWithEmailCode, forgotPassword } from '@/api/auth.ts'
+import { sendEmailCode, registerUserByEmail, registerUser, loginWithPassword, loginWithEmailCode, forgotPassword, requestAccountUnlock } from '@/api/auth.ts'
 import { encryptPasswordToString } from '@/utils/passwordEncrypt.ts'
 import { getSystemInit } from '@/api/system.ts'
 import { BehaviorTracker } from '@/utils/behaviorTracker.ts'
@@ -183,7 +183,7 @@ const AuthModal = ({ isOpen, onClose, initialMode = 'login' }: AuthModalProps) =
         }
         
         // 使用authStore的login方法处理登录成功
-        const loginSuccess = await login(token)
+        const loginSuccess = await login(token, response.data.refreshToken || response.data.user?.refreshToken)
         if (loginSuccess) {
           // 如果登录接口返回了user对象，直接更新authStore（确保显示最新的用户信息）
           if (response.data.user) {
@@ -241,6 +241,7 @@ const AuthModal = ({ isOpen, onClose, initialMode = 'login' }: AuthModalProps) =
       // 调用发送验证码的API
       const response = await sendEmailCode({
         email: formData.email,
+        purpose: mode === 'register' ? 'register' : 'login',
         clientIp: '', // 由后端自动获取
         userAgent: navigator.userAgent
       })
@@ -340,7 +341,7 @@ const AuthModal = ({ isOpen, onClose, initialMode = 'login' }: AuthModalProps) =
             }
             
             // 使用authStore的login方法处理登录成功
-            const loginSuccess = await login(token)
+            const loginSuccess = await login(token, response.data.refreshToken || response.data.user?.refreshToken)
             if (loginSuccess) {
               setLoginSuccessData(response.data)
               setIsLoginSuccess(true)
@@ -391,7 +392,11 @@ const AuthModal = ({ isOpen, onClose, initialMode = 'login' }: AuthModalProps) =
           if (response.code === 200) {
             // 检查是否需要邮箱验证
             if (response.data.requiresEmailVerification) {
-              showAlert('密码登录次数过多，请使用邮箱验证码登录', 'warning', '需要邮箱验证')
+              if (response.data.riskChallenge) {
+                showAlert('本次登录存在异常，请使用邮箱验证码登录', 'warning', '需要邮箱验证')
+              } else {
+                showAlert('密码登录次数过多，请使用邮箱验证码登录', 'warning', '需要邮箱验证')
+              }
               // 自动切换到邮箱验证码登录模式
               setLoginType('email')
               return
@@ -423,7 +428,7 @@ const AuthModal = ({ isOpen, onClose, initialMode = 'login' }: AuthModalProps) =
             }
             
             // 使用authStore的login方法处理登录成功
-            const loginSuccess = await login(token)
+            const loginSuccess = await login(token, response.data.refreshToken || response.data.user?.refreshToken)
             if (loginSuccess) {
               setLoginSuccessData(response.data)
               setIsLoginSuccess(true)
@@ -447,6 +452,12 @@ const AuthModal = ({ isOpen, onClose, initialMode = 'login' }: AuthModalProps) =
               throw new Error('登录处理失败：无法获取用户信息')
             }
           } else {
+            // 账号因多次登录失败被锁定时，向邮箱发送自助解锁链接
+            if (response.msg === 'account is locked') {
+              await requestAccountUnlock(formData.email).catch(() => undefined)
+              showAlert('登录失败次数过多，账号已被临时锁定。解锁链接已发送到您的邮箱，也可以等待锁定到期后再试', 'warning', '账号已锁定')
+              return
+            }
             // 从response中获取详细错误信息
             const errorMessage = response.data?.message || response.msg || '登录失败'
             throw new Error(errorMessage)
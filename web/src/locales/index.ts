@@ -14,6 +14,7 @@ import { device } from './modules/device'
 import { jsTemplate } from './modules/jsTemplate'
 import { quota } from './modules/quota'
 import { resetPassword } from './modules/resetPassword'
+import { unlockAccount } from './modules/unlockAccount'
 import { animation } from './modules/animation'
 
 // 合并所有翻译模块
@@ -50,6 +51,7 @@ export const translations = mergeTranslations(
   jsTemplate,
   quota,
   resetPassword,
+  unlockAccount,
   animation
 )
 
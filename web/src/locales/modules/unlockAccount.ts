@@ -0,0 +1,43 @@
+import { Language } from './common'
+
+export const unlockAccount: Record<Language, Record<string, string>> = {
+  zh: {
+    'unlockAccount.title': '解锁账号',
+    'unlockAccount.subtitle': '您的账号因多次登录失败被临时锁定，确认是本人操作后即可立即解锁',
+    'unlockAccount.unlockButton': '立即解锁',
+    'unlockAccount.unlocking': '解锁中...',
+    'unlockAccount.backToHome': '返回首页',
+    'unlockAccount.loginNow': '立即登录',
+    'unlockAccount.invalidTitle': '链接无效',
+    'unlockAccount.invalidMessage': '解锁链接无效、已过期或已被使用。您可以在登录时重新申请解锁邮件。',
+    'unlockAccount.successTitle': '账号已解锁',
+    'unlockAccount.successMessage': '您现在可以重新登录。如果登录失败不是您本人造成的，请尽快修改密码。',
+    'unlockAccount.failedTitle': '解锁失败',
+  },
+  en: {
+    'unlockAccount.title': 'Unlock Account',
+    'unlockAccount.subtitle': 'Your account was temporarily locked after too many failed logins. If that was you, unlock it now',
+    'unlockAccount.unlockButton': 'Unlock Now',
+    'unlockAccount.unlocking': 'Unlocking...',
+    'unlockAccount.backToHome': 'Back to Home',
+    'unlockAccount.loginNow': 'Sign In Now',
+    'unlockAccount.invalidTitle': 'Invalid Link',
+    'unlockAccount.invalidMessage': 'The unlock link is invalid, expired or already used. You can request a new unlock email when signing in.',
+    'unlockAccount.successTitle': 'Account Unlocked',
+    'unlockAccount.successMessage': 'You can sign in again. If the failed logins were not you, change your password as soon as possible.',
+    'unlockAccount.failedTitle': 'Unlock Failed',
+  },
+  ja: {
+    'unlockAccount.title': 'アカウントのロック解除',
+    'unlockAccount.subtitle': 'ログインの失敗が続いたため、アカウントが一時的にロックされました。ご本人の操作であれば今すぐ解除できます',
+    'unlockAccount.unlockButton': '今すぐ解除',
+    'unlockAccount.unlocking': '解除中...',
+    'unlockAccount.backToHome': 'ホームに戻る',
+    'unlockAccount.loginNow': '今すぐログイン',
+    'unlockAccount.invalidTitle': '無効なリンク',
+    'unlockAccount.invalidMessage': 'ロック解除リンクが無効、期限切れ、または使用済みです。ログイン時に解除メールを再度リクエストできます。',
+    'unlockAccount.successTitle': 'ロックを解除しました',
+    'unlockAccount.successMessage': '再度ログインできます。ログインの失敗に心当たりがない場合は、早めにパスワードを変更してください。',
+    'unlockAccount.failedTitle': 'ロック解除に失敗しました',
+  },
+}
@@ -455,7 +455,7 @@ const Profile = () => {
 
     setIsSendingCode(true)
     try {
-      const response = await sendEmailCode({ email: user.email })
+      const response = await sendEmailCode({ email: user.email, purpose: 'change_password' })
       if (response.code === 200) {
         showAlert('验证码已发送到您的邮箱', 'success', '发送成功')
         setCountdown(60)
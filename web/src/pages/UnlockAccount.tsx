@@ -0,0 +1,113 @@
+import { useState, useEffect } from 'react'
+import { useNavigate, useSearchParams } from 'react-router-dom'
+import { motion } from 'framer-motion'
+import { Unlock, CheckCircle, AlertTriangle } from 'lucide-react'
+import Button from '../components/UI/Button'
+import Card, { CardContent, CardHeader, CardTitle } from '../components/UI/Card'
+import { confirmAccountUnlock } from '../api/auth'
+import { showAlert } from '../utils/notification'
+import { useI18nStore } from '../stores/i18nStore'
+
+const UnlockAccount = () => {
+  const navigate = useNavigate()
+  const { t } = useI18nStore()
+  const [searchParams] = useSearchParams()
+  const token = searchParams.get('token')
+
+  const [isLoading, setIsLoading] = useState(false)
+  const [isSuccess, setIsSuccess] = useState(false)
+  const [isTokenValid, setIsTokenValid] = useState(true)
+
+  useEffect(() => {
+    if (!token) {
+      setIsTokenValid(false)
+    }
+  }, [token])
+
+  // 需要用户点击确认，避免邮件安全扫描预先打开链接时消耗令牌
+  const handleUnlock = async () => {
+    if (!token) {
+      setIsTokenValid(false)
+      return
+    }
+
+    setIsLoading(true)
+    try {
+      const response = await confirmAccountUnlock(token)
+      if (response.code === 200) {
+        setIsSuccess(true)
+      } else {
+        setIsTokenValid(false)
+      }
+    } catch (error: any) {
+      showAlert(error?.msg || error?.message || t('unlockAccount.invalidMessage'), 'error', t('unlockAccount.failedTitle'))
+      setIsTokenValid(false)
+    } finally {
+      setIsLoading(false)
+    }
+  }
+
+  const status = !isTokenValid ? 'invalid' : isSuccess ? 'success' : 'confirm'
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-blue-50 via-white to-purple-50 dark:from-gray-900 dark:via-gray-800 dark:to-gray-900 flex items-center justify-center p-4">
+      <motion.div
+        initial={{ opacity: 0, y: 20 }}
+        animate={{ opacity: 1, y: 0 }}
+        className="w-full max-w-md"
+      >
+        <Card className="shadow-xl border-0 bg-white/80 dark:bg-gray-800/80 backdrop-blur-sm">
+          <CardHeader className="text-center pb-6">
+            {status === 'invalid' && (
+              <div className="w-16 h-16 bg-red-100 dark:bg-red-900/20 rounded-full flex items-center justify-center mx-auto mb-4">
+                <AlertTriangle className="w-8 h-8 text-red-600 dark:text-red-400" />
+              </div>
+            )}
+            {status === 'success' && (
+              <div className="w-16 h-16 bg-green-100 dark:bg-green-900/20 rounded-full flex items-center justify-center mx-auto mb-4">
+                <CheckCircle className="w-8 h-8 text-green-600 dark:text-green-400" />
+              </div>
+            )}
+            {status === 'confirm' && (
+              <div className="w-16 h-16 bg-blue-100 dark:bg-blue-900/20 rounded-full flex items-center justify-center mx-auto mb-4">
+                <Unlock className="w-8 h-8 text-blue-600 dark:text-blue-400" />
+              </div>
+            )}
+            <CardTitle className="text-2xl font-bold text-gray-900 dark:text-white">
+              {status === 'invalid' && t('unlockAccount.invalidTitle')}
+              {status === 'success' && t('unlockAccount.successTitle')}
+              {status === 'confirm' && t('unlockAccount.title')}
+            </CardTitle>
+          </CardHeader>
+          <CardContent className="text-center">
+            <p className="text-gray-600 dark:text-gray-400 mb-6">
+              {status === 'invalid' && t('unlockAccount.invalidMessage')}
+              {status === 'success' && t('unlockAccount.successMessage')}
+              {status === 'confirm' && t('unlockAccount.subtitle')}
+            </p>
+            {status === 'confirm' ? (
+              <Button
+                variant="primary"
+                onClick={handleUnlock}
+                className="w-full"
+                disabled={isLoading}
+              >
+                {isLoading ? t('unlockAccount.unlocking') : t('unlockAccount.unlockButton')}
+              </Button>
+            ) : (
+              <Button
+                variant="primary"
+                onClick={() => navigate('/', { replace: true })}
+                className="w-full"
+              >
+                {status === 'success' ? t('unlockAccount.loginNow') : t('unlockAccount.backToHome')}
+              </Button>
+            )}
+          </CardContent>
+        </Card>
+      </motion.div>
+    </div>
+  )
+}
+
+export default UnlockAccount
@@ -8,7 +8,8 @@ interface AuthState {
   isLoading: boolean
   token: string | null
   currentOrganizationId: number | null
-  login: (token: string) => Promise<boolean>
+  login: (token: string, refreshToken?: string) => Promise<boolean>
+  setTokens: (token: string, refreshToken?: string) => void
   register: (data: RegisterUserForm) => Promise<boolean>
   logout: (next?: string) => Promise<void>
   setLoading: (loading: boolean) => void
@@ -28,11 +29,16 @@ export const useAuthStore = create<AuthState>()(
       token: null,
       currentOrganizationId: null,
 
-      login: async (token: string) => {
+      login: async (token: string, refreshToken?: string) => {
         set({ isLoading: true })
         try {
-          // 存储token
+          // 存储token，访问令牌有效期很短，过期后用刷新令牌换发
           localStorage.setItem('auth_token', token)
+          if (refreshToken) {
+            localStorage.setItem('auth_refresh_token', refreshToken)
+          } else {
+            localStorage.removeItem('auth_refresh_token')
+          }
           
           set({
             isAuthenticated: true, 
@@ -88,10 +94,20 @@ export const useAuthStore = create<AuthState>()(
         } finally {
           // 清除本地存储
           localStorage.removeItem('auth_token')
+          localStorage.removeItem('auth_refresh_token')
           set({ user: null, isAuthenticated: false, token: null, currentOrganizationId: null })
         }
       },
 
+      // 刷新令牌换发新令牌后更新本地存储
+      setTokens: (token: string, refreshToken?: string) => {
+        localStorage.setItem('auth_token', token)
+        if (refreshToken) {
+          localStorage.setItem('auth_refresh_token', refreshToken)
+        }
+        set({ token })
+      },
+
       setLoading: (loading: boolean) => {
         set({ isLoading: loading })
       },
@@ -111,6 +127,7 @@ export const useAuthStore = create<AuthState>()(
           console.error('Failed to refresh user info:', error)
           // 如果获取用户信息失败，清除认证状态
           localStorage.removeItem('auth_token')
+          localStorage.removeItem('auth_refresh_token')
           set({ user: null, isAuthenticated: false, token: null })
         }
       },
@@ -125,6 +142,7 @@ export const useAuthStore = create<AuthState>()(
         // 新增的清除用户信息方法
         clearUser: () => {
             localStorage.removeItem('auth_token')
+            localStorage.removeItem('auth_refresh_token')
             set({ user: null, isAuthenticated: false, token: null, currentOrganizationId: null })
         },
 
@@ -54,13 +54,53 @@ axiosInstance.interceptors.request.use(
   }
 )
 
+// 正在进行的令牌刷新，并发的 401 请求共用同一次刷新，避免刷新令牌被重复使用而导致登录被撤销
+let refreshing: Promise<string | null> | null = null
+
+// 用刷新令牌换发新的访问令牌，失败时返回 null
+const refreshAccessToken = (): Promise<string | null> => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('auth_refresh_token')
+    if (!refreshToken) {
+      return Promise.resolve(null)
+    }
+    // 直接使用 axios，不经过本实例的拦截器
+    refreshing = axios
+      .post(`${getApiBaseUrl()}/auth/token/refresh`, { refreshToken })
+      .then((response) => {
+        const data = response.data
+        if (data?.code !== 200 || !data.data?.token) {
+          return null
+        }
+        useAuthStore.getState().setTokens(data.data.token, data.data.refreshToken)
+        return data.data.token as string
+      })
+      .catch(() => null)
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
 // 响应拦截器 - 只处理通用错误，不处理业务逻辑
 axiosInstance.interceptors.response.use(
   (response: AxiosResponse) => {
     // 直接返回完整响应，让业务层处理
     return response
   },
-  (error) => {
+  async (error) => {
+    // 访问令牌过期时先尝试刷新并重试一次，刷新失败才退出登录
+    const original = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined
+    if (error.response?.status === 401 && original && !original._retried) {
+      original._retried = true
+      const token = await refreshAccessToken()
+      if (token) {
+        original.headers.Authorization = `Bearer ${token}`
+        return axiosInstance(original)
+      }
+    }
+
       console.error('Response interceptor error:', error)
     // 处理网络错误和HTTP状态码错误
     if (error.response) {